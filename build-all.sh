@@ -211,7 +211,7 @@ build_webview_gui() {
     if ! pip install -r requirements.txt >/dev/null 2>&1; then
         echo -e "${YELLOW}  ⚠️ Requirements.txt installation failed, trying individual packages...${NC}"
         # Try installing packages individually as fallback
-        if ! pip install flask flask-cors flask-socketio eventlet gevent pywebview pyinstaller >/dev/null 2>&1; then
+        if ! pip install flask flask-cors flask-socketio eventlet gevent pywebview pystray Pillow pyinstaller >/dev/null 2>&1; then
             echo -e "${RED}  ❌ Failed to install dependencies, skipping WebView GUI build...${NC}"
             cd "$SCRIPT_DIR"
             return
@@ -393,6 +393,8 @@ After=network.target
 Type=simple
 User=servin
 ExecStart=/usr/local/bin/servin daemon --config /etc/servin/servin.conf
+ExecReload=/bin/kill -HUP $MAINPID
+KillMode=process
 Restart=always
 RestartSec=5
 
@@ -2,36 +2,71 @@ package cmd
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"servin/pkg/builder"
+	"servin/pkg/container"
 	"servin/pkg/errors"
+	"servin/pkg/health"
 	"servin/pkg/image"
 	"servin/pkg/logger"
+	"servin/pkg/telemetry"
 
 	"github.com/spf13/cobra"
 )
 
 var buildCmd = &cobra.Command{
 	Use:   "build [OPTIONS] PATH",
-	Short: "Build an image from a Buildfile",
+	Short: "Build an image from a Buildfile or Dockerfile",
 	Long: `Build a container image from a Buildfile (similar to Dockerfile).
 The build context is the PATH where the Buildfile is located.
 
+A file named Dockerfile, Dockerfile.<name> or <name>.Dockerfile is read
+with Dockerfile semantics: line continuations, ARG with defaults, quoting
+and variable expansion, SHELL, ONBUILD and STOPSIGNAL. Without -f, a
+context with a Dockerfile and no Buildfile builds the Dockerfile. Files
+matching the patterns in the context's .dockerignore are left out of COPY,
+ADD and remote builds.
+
 Example Buildfile:
   FROM alpine:latest
   RUN apk add --no-cache curl
   COPY . /app
   WORKDIR /app
+  HEALTHCHECK --interval=30s --retries=3 CMD wget -q -O- http://localhost/ || exit 1
   CMD ["./app"]
 
 Examples:
   servin build .
   servin build -t myapp:v1.0 .
-  servin build -f MyBuildfile .`,
+  servin build -f MyBuildfile .
+  servin build -f Dockerfile --build-arg VERSION=1.2 .
+  servin build -t myapp:dev --watch .
+  servin build -t myapp:dev --watch --restart-container myapp .
+  SOURCE_DATE_EPOCH=$(git log -1 --format=%ct) servin build --reproducible -t myapp:v1.0 .
+  servin build --builder ssh://user@buildhost -t myapp:v1.0 .
+  servin build --builder big --push -t registry.example.com/myapp:v1.0 .
+  tar -c . | servin build -t myapp:v1.0 -
+
+A PATH of - reads the build context from standard input as a tarball,
+optionally gzipped.
+
+With --builder, the context is streamed over SSH to a remote builder (a
+name from 'servin builder ls' or an ssh:// endpoint), built there and
+loaded into the local image store, or pushed from the builder with --push.
+'servin builder use' sets the builder used when --builder is not given.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runBuild,
 }
@@ -43,6 +78,18 @@ var (
 	buildQuiet   bool
 	buildArgs    []string
 	buildLabels  []string
+	buildTarget  string
+
+	buildReproducible bool
+
+	buildBuilder string
+	buildPush    bool
+	buildForce   bool
+
+	// Watch mode flags
+	buildWatch            bool
+	buildWatchInterval    time.Duration
+	buildRestartContainer string
 )
 
 func init() {
@@ -50,11 +97,19 @@ func init() {
 
 	// Add flags for build options
 	buildCmd.Flags().StringVarP(&buildTag, "tag", "t", "", "Name and optionally a tag in the 'name:tag' format")
-	buildCmd.Flags().StringVarP(&buildFile, "file", "f", "Buildfile", "Name of the Buildfile (default 'Buildfile')")
+	buildCmd.Flags().StringVarP(&buildFile, "file", "f", "Buildfile", "Name of the Buildfile, or of a Dockerfile read with Dockerfile semantics")
 	buildCmd.Flags().BoolVar(&buildNoCache, "no-cache", false, "Do not use cache when building the image")
 	buildCmd.Flags().BoolVarP(&buildQuiet, "quiet", "q", false, "Suppress the build output and print image ID on success")
 	buildCmd.Flags().StringArrayVar(&buildArgs, "build-arg", []string{}, "Set build-time variables")
 	buildCmd.Flags().StringArrayVar(&buildLabels, "label", []string{}, "Set metadata for an image")
+	buildCmd.Flags().StringVar(&buildTarget, "target", "", "Build the stage with this name instead of the last stage")
+	buildCmd.Flags().BoolVar(&buildReproducible, "reproducible", false, "Produce the same image ID for the same build context, failing on nondeterministic steps (timestamps come from SOURCE_DATE_EPOCH, default 0)")
+	buildCmd.Flags().StringVar(&buildBuilder, "builder", "", "Build on this remote builder (name or ssh:// endpoint), or 'local'")
+	buildCmd.Flags().BoolVar(&buildPush, "push", false, "Push the image to its registry after the build (requires --tag)")
+	buildCmd.Flags().BoolVarP(&buildWatch, "watch", "w", false, "Rebuild the image whenever the build context changes")
+	buildCmd.Flags().DurationVar(&buildWatchInterval, "watch-interval", time.Second, "How often to check the build context for changes")
+	buildCmd.Flags().StringVar(&buildRestartContainer, "restart-container", "", "Restart this container with the new image after each successful build (requires --watch)")
+	buildCmd.Flags().BoolVar(&buildForce, "force", false, forceTagUsage)
 }
 
 func runBuild(cmd *cobra.Command, args []string) error {
@@ -62,6 +117,30 @@ func runBuild(cmd *cobra.Command, args []string) error {
 
 	logger.Debug("Building image from context: %s", buildContext)
 
+	if buildPush && buildTag == "" {
+		return errors.NewValidationError("build", "--push requires --tag")
+	}
+	remote, err := builder.Resolve(buildBuilder)
+	if err != nil {
+		return errors.NewValidationError("build", err.Error())
+	}
+
+	// A context on standard input is unpacked to a temporary directory
+	if buildContext == "-" {
+		if buildWatch {
+			return errors.NewValidationError("build", "--watch cannot be used with a context on standard input")
+		}
+		dir, err := os.MkdirTemp("", "servin-context-")
+		if err != nil {
+			return fmt.Errorf("failed to create build context: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		if err := builder.ExtractContext(os.Stdin, dir); err != nil {
+			return errors.NewValidationError("build", err.Error())
+		}
+		buildContext = dir
+	}
+
 	// Resolve build context path
 	buildContextPath, err := filepath.Abs(buildContext)
 	if err != nil {
@@ -75,6 +154,15 @@ func runBuild(cmd *cobra.Command, args []string) error {
 		return errors.NewNotFoundError("build", fmt.Sprintf("build context '%s' not found", buildContextPath))
 	}
 
+	// Projects with a Dockerfile and no Buildfile build unmodified
+	if !cmd.Flags().Changed("file") {
+		if _, err := os.Stat(filepath.Join(buildContextPath, buildFile)); os.IsNotExist(err) {
+			if _, err := os.Stat(filepath.Join(buildContextPath, "Dockerfile")); err == nil {
+				buildFile = "Dockerfile"
+			}
+		}
+	}
+
 	// Resolve Buildfile path
 	buildfilePath := filepath.Join(buildContextPath, buildFile)
 	if _, err := os.Stat(buildfilePath); os.IsNotExist(err) {
@@ -84,6 +172,13 @@ func runBuild(cmd *cobra.Command, args []string) error {
 
 	logger.Debug("Using Buildfile: %s", buildfilePath)
 
+	if buildRestartContainer != "" && !buildWatch {
+		return errors.NewValidationError("build", "--restart-container requires --watch")
+	}
+	if buildWatch && buildWatchInterval <= 0 {
+		return errors.NewValidationError("build", "watch interval must be positive")
+	}
+
 	// Parse build arguments
 	buildArgMap := make(map[string]string)
 	for _, arg := range buildArgs {
@@ -104,6 +199,24 @@ func runBuild(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// SOURCE_DATE_EPOCH pins the timestamps written to the image; reproducible
+	// builds fall back to the Unix epoch
+	epoch, err := image.SourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	if epoch == nil && buildReproducible {
+		unixEpoch := time.Unix(0, 0).UTC()
+		epoch = &unixEpoch
+	}
+
+	if remote != nil {
+		if buildWatch {
+			return errors.NewValidationError("build", "--watch is not supported with a remote builder")
+		}
+		return runRemoteBuild(remote, buildContextPath, epoch)
+	}
+
 	// Create build configuration
 	buildConfig := &BuildConfig{
 		ContextPath: buildContextPath,
@@ -113,14 +226,31 @@ func runBuild(cmd *cobra.Command, args []string) error {
 		Quiet:       buildQuiet,
 		BuildArgs:   buildArgMap,
 		Labels:      labelMap,
+		Dockerfile:  isDockerfile(buildfilePath),
+		Target:      buildTarget,
+
+		Reproducible: buildReproducible,
+		Epoch:        epoch,
 	}
 
 	// Execute the build
-	builder := NewImageBuilder()
-	imageID, err := builder.Build(buildConfig)
+	imageBuilder := NewImageBuilder()
+	if imageBuilder.imgManager, err = taggingManager(buildForce); err != nil {
+		return err
+	}
+	if buildWatch {
+		return watchBuild(imageBuilder, buildConfig)
+	}
+
+	imageID, err := imageBuilder.Build(buildConfig)
 	if err != nil {
 		logger.Error("Build failed: %v", err)
-		return errors.NewImageError("build", fmt.Sprintf("image build failed: %v", err))
+		// Failures with a category, such as a missing base image, keep it
+		errType := errors.ErrTypeImage
+		if servinErr, ok := errors.As(err); ok {
+			errType = servinErr.Type
+		}
+		return errors.NewError(errType, "build", fmt.Sprintf("image build failed: %v", err))
 	}
 
 	if buildQuiet {
@@ -132,6 +262,11 @@ func runBuild(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if buildPush {
+		if err := imageBuilder.imgManager.PushImage(buildTag, image.PushOptions{ChunkSize: image.DefaultChunkSize}); err != nil {
+			return fmt.Errorf("failed to push image: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -144,6 +279,17 @@ type BuildConfig struct {
 	Quiet       bool
 	BuildArgs   map[string]string
 	Labels      map[string]string
+
+	// Dockerfile reads the build file with Dockerfile semantics
+	Dockerfile bool
+	// Target names the stage the image is built from; the last stage if empty
+	Target string
+
+	// Reproducible builds every layer twice and fails if the results differ
+	Reproducible bool
+	// Epoch, if set, is the creation time of the image and the latest
+	// modification time of files in its layers
+	Epoch *time.Time
 }
 
 // BuildStep represents a single step in the Buildfile
@@ -156,33 +302,206 @@ type BuildStep struct {
 // ImageBuilder handles the image building process
 type ImageBuilder struct {
 	imgManager *image.Manager
+
+	// cache maps a step's cache key to the image state after that step. It
+	// fronts the image store's persistent build cache, so repeated builds
+	// only re-run invalidated steps. Stages building concurrently share it
+	// under cacheMu.
+	cache   map[string]*image.Image
+	cacheMu *sync.Mutex
+
+	// upToDate is set when the last build reused every step and produced
+	// the same image as before
+	upToDate bool
+
+	// ignore holds the .dockerignore patterns of the build context
+	ignore *builder.Ignore
+	// scope holds the build arguments of a Dockerfile build; nil for a
+	// Buildfile, whose arguments are substituted as it is parsed. Each
+	// stage has its own.
+	scope *dockerfileScope
+
+	// stage is the stage being built and stages every stage of the build;
+	// both are nil outside a stage
+	stage  *buildStage
+	stages []*buildStage
+	// out and errOut receive the stage's build output
+	out    io.Writer
+	errOut io.Writer
 }
 
 // NewImageBuilder creates a new image builder
 func NewImageBuilder() *ImageBuilder {
 	return &ImageBuilder{
 		imgManager: image.NewManager(),
+		cache:      make(map[string]*image.Image),
+		cacheMu:    &sync.Mutex{},
+		out:        os.Stdout,
+		errOut:     os.Stderr,
 	}
 }
 
-// Build executes the image build process
+// Build executes the image build process. The build file is split into
+// stages at each FROM; the stages the target needs run as soon as the
+// stages they build on or copy from are done, independent ones concurrently.
 func (b *ImageBuilder) Build(config *BuildConfig) (string, error) {
 	logger.Info("Starting image build")
 	logger.Debug("Build context: %s", config.ContextPath)
 	logger.Debug("Buildfile: %s", config.Buildfile)
 
 	// Parse the Buildfile
-	steps, err := b.parseBuildfile(config.Buildfile, config.BuildArgs)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse Buildfile: %v", err)
+	var steps []BuildStep
+	var err error
+	b.scope = nil
+	if config.Dockerfile {
+		var escape byte
+		steps, escape, err = parseDockerfile(config.Buildfile)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse Dockerfile: %v", err)
+		}
+		b.scope = newDockerfileScope(escape, config.BuildArgs)
+	} else {
+		steps, err = b.parseBuildfile(config.Buildfile, config.BuildArgs)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse Buildfile: %v", err)
+		}
+	}
+	if b.ignore, err = builder.LoadIgnore(config.ContextPath); err != nil {
+		return "", err
 	}
 
 	logger.Debug("Parsed %d build steps", len(steps))
 
+	buildID := generateImageID()
+	created := time.Now()
+	if config.Epoch != nil {
+		created = *config.Epoch
+	}
+
+	buildSpan := telemetry.StartSpan("image.build", nil)
+	buildSpan.SetAttribute("build.context", config.ContextPath)
+	buildSpan.SetAttribute("build.tag", config.Tag)
+
+	// ARGs before the first FROM are visible to every FROM; they are part
+	// of the cache keys of every stage
+	b.upToDate = false
+	cacheKey := buildCacheSeed(config)
+	var globals []BuildStep
+	for b.scope != nil && len(steps) > 0 && steps[0].Instruction == "ARG" {
+		step, err := b.scope.expand(steps[0], nil)
+		if err == nil {
+			var resolved []string
+			if resolved, err = b.scope.declare(step); err == nil {
+				cacheKey = b.stepCacheKey(cacheKey, BuildStep{Instruction: "ARG", RawLine: "ARG " + strings.Join(resolved, " ")}, config.ContextPath)
+			}
+		}
+		if err != nil {
+			buildSpan.Finish(err)
+			return "", fmt.Errorf("step %d failed: %w", len(globals)+1, err)
+		}
+		globals = append(globals, steps[0])
+		steps = steps[1:]
+	}
+
+	// Only the stages the target builds on or copies from are built
+	stages, err := b.planStages(steps, len(globals))
+	var target *buildStage
+	if err == nil {
+		target, err = targetStage(stages, config.Target)
+	}
+	if err == nil {
+		err = b.prepareStages(stages, target, len(globals))
+	}
+	if err != nil {
+		buildSpan.Finish(err)
+		return "", err
+	}
+
+	total := len(globals)
+	for _, stage := range stages {
+		total += len(stage.steps)
+	}
+	if !config.Quiet {
+		for i, step := range globals {
+			fmt.Fprintf(b.out, "Step %d/%d : %s\n", i+1, total, step.RawLine)
+		}
+	}
+
+	run := &stageRun{
+		config:  config,
+		buildID: buildID,
+		created: created,
+		seed:    cacheKey,
+		total:   total,
+		span:    buildSpan,
+	}
+	if err := b.runStages(stages, target, run); err != nil {
+		buildSpan.Finish(err)
+		return "", err
+	}
+
+	if b.scope != nil && !config.Quiet {
+		if unused := b.scope.unconsumed(); len(unused) > 0 {
+			sort.Strings(unused)
+			fmt.Fprintf(b.out, "Warning: build arguments were not consumed: %s\n", strings.Join(unused, ", "))
+		}
+	}
+
+	// Every step was cached: the image from the previous build is still current
+	img := target.img
+	cacheKey = target.cacheKey
+	if target.allCached && len(target.steps) > 0 {
+		if existing, err := b.imgManager.GetImage(img.ID); err == nil && existing.ID == img.ID {
+			b.upToDate = true
+			err = b.tagImage(existing, config.Tag)
+			buildSpan.Finish(err)
+			return existing.ID, err
+		}
+	}
+	img.ID = buildID
+
+	// If no FROM instruction was processed, create a minimal image
+	if len(target.steps) == 0 || target.steps[0].Instruction != "FROM" {
+		if !config.Quiet {
+			fmt.Fprintln(b.out, "Warning: No FROM instruction found, creating minimal image")
+		}
+		if len(img.LayerChain) == 0 {
+			img.Layers = []string{"scratch"}
+		}
+	}
+
+	// The image is named after its config, so the same build produces the
+	// same ID; the last cached state is updated to match for the next build
+	if err := b.imgManager.CommitConfig(img); err != nil {
+		buildSpan.Finish(err)
+		return "", fmt.Errorf("failed to write image config: %v", err)
+	}
+	if snapshot := b.cachedStep(cacheKey); snapshot != nil {
+		snapshot.ID = img.ID
+		snapshot.ConfigDigest = img.ConfigDigest
+		b.cacheStep(cacheKey, snapshot)
+	}
+
+	// Save the image, moving the tag off any image that had it before
+	err = b.imgManager.AddImage(img, config.Tag)
+	buildSpan.Finish(err)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("Image build completed successfully: %s", img.ID)
+	return img.ID, nil
+}
+
+// runStage builds the steps of one stage, reusing cached results until the
+// first step whose inputs changed
+func (b *ImageBuilder) runStage(stage *buildStage, run *stageRun) error {
+	config := run.config
+
 	// Create a new image
 	img := &image.Image{
-		ID:         generateImageID(),
-		Created:    time.Now(),
+		ID:         run.buildID,
+		Created:    run.created,
 		Size:       0,
 		Layers:     []string{},
 		RootFSType: "layer",
@@ -193,35 +512,93 @@ func (b *ImageBuilder) Build(config *BuildConfig) (string, error) {
 			WorkingDir:   "/",
 			User:         "root",
 			ExposedPorts: make(map[string]struct{}),
-			Labels:       config.Labels,
+			Labels:       make(map[string]string),
 		},
 		Metadata: make(map[string]string),
 	}
+	for key, value := range config.Labels {
+		img.Config.Labels[key] = value
+	}
 
 	// Add build metadata
 	img.Metadata["build.context"] = config.ContextPath
 	img.Metadata["build.buildfile"] = config.Buildfile
-	img.Metadata["build.timestamp"] = time.Now().Format(time.RFC3339)
-
-	// Process each step
-	var fromProcessed bool
-	for i, step := range steps {
+	img.Metadata["build.timestamp"] = run.created.Format(time.RFC3339)
+
+	var err error
+	allCached := true
+	cacheKey := run.seed
+	for i, step := range stage.steps {
+		number := stage.first + i
+		if run.aborted() {
+			return errStageAborted
+		}
 		if !config.Quiet {
-			fmt.Printf("Step %d/%d : %s\n", i+1, len(steps), step.RawLine)
+			fmt.Fprintf(b.out, "Step %d/%d : %s\n", number, run.total, step.RawLine)
+		}
+
+		// FROM was expanded with the global arguments when the stage was planned
+		if b.scope != nil && step.Instruction != "FROM" {
+			if step, err = b.scope.expand(step, img.Config.Env); err != nil {
+				return fmt.Errorf("step %d failed: %w", number, err)
+			}
+
+			// ARG only changes the variables later steps see, so it is
+			// part of their cache keys rather than a step of its own
+			if step.Instruction == "ARG" {
+				resolved, err := b.scope.declare(step)
+				if err != nil {
+					return fmt.Errorf("step %d failed: %w", number, err)
+				}
+				cacheKey = b.stepCacheKey(cacheKey, BuildStep{Instruction: "ARG", RawLine: "ARG " + strings.Join(resolved, " ")}, config.ContextPath)
+				continue
+			}
+		}
+
+		// The base image's ONBUILD triggers run right after FROM
+		if step.Instruction == "FROM" && stage.triggers > 0 && !config.Quiet {
+			name, _, _ := parseFrom(step)
+			fmt.Fprintf(b.out, " ---> Executing %d build triggers from %s\n", stage.triggers, name)
 		}
 
-		logger.Debug("Executing step %d: %s %v", i+1, step.Instruction, step.Arguments)
+		if step.Instruction == "CMD" && b.scope != nil {
+			b.scope.cmdSet = true
+		}
+
+		cacheKey = b.stepCacheKey(cacheKey, step, config.ContextPath)
+		if !config.NoCache && allCached {
+			if snapshot := b.cachedStep(cacheKey); snapshot != nil {
+				img = snapshot
+				if !config.Quiet {
+					fmt.Fprintln(b.out, " ---> Using cache")
+				}
+				continue
+			}
+		}
+		if allCached {
+			allCached = false
+			img.ID = run.buildID
+		}
+
+		stepSpan := telemetry.StartSpan("image.build.step", run.span)
+		stepSpan.SetAttribute("build.step", fmt.Sprintf("%d", number))
+		stepSpan.SetAttribute("build.instruction", step.Instruction)
+
+		logger.Debug("Executing step %d: %s %v", number, step.Instruction, step.Arguments)
 
 		switch strings.ToUpper(step.Instruction) {
 		case "FROM":
-			_, err = b.processFrom(step, img)
-			fromProcessed = true
+			if stage.base != nil {
+				b.processFromStage(stage.base, img)
+			} else {
+				_, err = b.processFrom(step, img)
+			}
 		case "RUN":
-			err = b.processRun(step, img, config.ContextPath)
+			err = b.processRun(step, img, config)
 		case "COPY":
-			err = b.processCopy(step, img, config.ContextPath)
+			err = b.processCopy(step, img, config)
 		case "ADD":
-			err = b.processAdd(step, img, config.ContextPath)
+			err = b.processAdd(step, img, config)
 		case "WORKDIR":
 			err = b.processWorkdir(step, img)
 		case "ENV":
@@ -234,45 +611,39 @@ func (b *ImageBuilder) Build(config *BuildConfig) (string, error) {
 			err = b.processEntrypoint(step, img)
 		case "LABEL":
 			err = b.processLabel(step, img)
+		case "MAINTAINER":
+			img.Config.Labels["maintainer"] = instructionArgs(step)
+		case "SHELL":
+			err = b.processShell(step, img)
+		case "STOPSIGNAL":
+			err = b.processStopsignal(step, img)
+		case "ONBUILD":
+			err = b.processOnbuild(step, img)
 		case "USER":
 			err = b.processUser(step, img)
 		case "VOLUME":
 			err = b.processVolume(step, img)
+		case "HEALTHCHECK":
+			err = b.processHealthcheck(step, img)
 		default:
 			logger.Warn("Unknown instruction: %s", step.Instruction)
 			if !config.Quiet {
-				fmt.Printf("Warning: Unknown instruction '%s' - skipping\n", step.Instruction)
+				fmt.Fprintf(b.out, "Warning: Unknown instruction '%s' - skipping\n", step.Instruction)
 			}
 		}
 
+		stepSpan.Finish(err)
 		if err != nil {
-			return "", fmt.Errorf("step %d failed: %v", i+1, err)
+			return fmt.Errorf("step %d failed: %w", number, err)
 		}
-	}
 
-	// If no FROM instruction was processed, create a minimal image
-	if !fromProcessed {
-		if !config.Quiet {
-			fmt.Println("Warning: No FROM instruction found, creating minimal image")
-		}
-		img.Layers = []string{"scratch"}
+		b.cacheStep(cacheKey, img)
 	}
 
-	// Set image tag if specified
-	if config.Tag != "" {
-		img.RepoTags = []string{config.Tag}
-	} else {
-		img.RepoTags = []string{"<none>:<none>"}
-	}
-
-	// Save the image
-	err = b.imgManager.SaveImage(img)
-	if err != nil {
-		return "", fmt.Errorf("failed to save image: %v", err)
-	}
-
-	logger.Info("Image build completed successfully: %s", img.ID)
-	return img.ID, nil
+	stage.img = img
+	stage.cacheKey = cacheKey
+	stage.allCached = allCached
+	return nil
 }
 
 // parseBuildfile parses the Buildfile and returns build steps
@@ -338,7 +709,10 @@ func (b *ImageBuilder) processFrom(step BuildStep, img *image.Image) (*image.Ima
 		return nil, fmt.Errorf("FROM instruction requires an argument")
 	}
 
-	baseImageName := step.Arguments[0]
+	baseImageName, _, err := parseFrom(step)
+	if err != nil {
+		return nil, err
+	}
 	logger.Debug("Using base image: %s", baseImageName)
 
 	// Handle special case for scratch
@@ -350,67 +724,455 @@ func (b *ImageBuilder) processFrom(step BuildStep, img *image.Image) (*image.Ima
 	// Try to find the base image
 	baseImage, err := b.imgManager.GetImage(baseImageName)
 	if err != nil {
-		return nil, fmt.Errorf("base image '%s' not found: %v", baseImageName, err)
+		return nil, errors.WrapError(err, errors.ErrTypeNotFound, "build", fmt.Sprintf("base image '%s' not found", baseImageName))
 	}
 
-	// Copy configuration from base image
+	// Copy configuration from base image; its ONBUILD triggers run in this
+	// build and are not passed on
 	img.Config = baseImage.Config
+	img.Config.OnBuild = nil
 	img.Layers = append(img.Layers, baseImage.Layers...)
+	img.LayerChain = append(img.LayerChain, baseImage.LayerChain...)
 	img.RootFSType = baseImage.RootFSType
+	img.Platform = baseImage.Platform
 
 	return baseImage, nil
 }
 
-// processRun handles RUN instruction
-func (b *ImageBuilder) processRun(step BuildStep, img *image.Image, contextPath string) error {
+// parseFrom splits a FROM instruction into the base image and the
+// --platform it is pulled for. The stage name given with AS is returned by
+// fromStageName.
+func parseFrom(step BuildStep) (string, string, error) {
+	args := step.Arguments
+	platform := ""
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		name, value, _ := strings.Cut(strings.TrimPrefix(args[0], "--"), "=")
+		if name != "platform" {
+			return "", "", fmt.Errorf("unknown FROM option '%s'", args[0])
+		}
+		platform = value
+		args = args[1:]
+	}
+
+	switch {
+	case len(args) == 1:
+	case len(args) == 3 && strings.EqualFold(args[1], "AS"):
+	default:
+		return "", "", fmt.Errorf("FROM expects an image and an optional AS name")
+	}
+	return args[0], platform, nil
+}
+
+// onbuildTriggers makes sure the base image of a FROM step is available,
+// pulling it if needed, and returns the ONBUILD triggers it carries
+func (b *ImageBuilder) onbuildTriggers(step BuildStep) ([]BuildStep, error) {
+	if len(step.Arguments) == 0 {
+		return nil, fmt.Errorf("FROM instruction requires an argument")
+	}
+	name, platform, err := parseFrom(step)
+	if err != nil {
+		return nil, err
+	}
+	if name == "scratch" {
+		return nil, nil
+	}
+
+	base, err := b.ensureImage(name, platform)
+	if err != nil {
+		return nil, fmt.Errorf("base %v", err)
+	}
+
+	var triggers []BuildStep
+	for _, trigger := range base.Config.OnBuild {
+		triggerStep, err := parseDockerfileLine(trigger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ONBUILD trigger in '%s': %v", name, err)
+		}
+		triggers = append(triggers, triggerStep)
+	}
+	return triggers, nil
+}
+
+// ensureImage returns the image a build uses, pulling it if it is missing
+// or, with a platform, not built for that platform
+func (b *ImageBuilder) ensureImage(name, platform string) (*image.Image, error) {
+	if platform != "" {
+		if err := ensureImagePlatform(name, platform); err != nil {
+			return nil, err
+		}
+	} else if _, err := b.imgManager.GetImage(name); err != nil {
+		if err := b.imgManager.PullImage(name, image.PullOptions{}); err != nil {
+			return nil, errors.WrapError(err, errors.ErrTypeNotFound, "build", fmt.Sprintf("image '%s' not found", name))
+		}
+	}
+
+	img, err := b.imgManager.GetImage(name)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeNotFound, "build", fmt.Sprintf("image '%s' not found", name))
+	}
+	return img, nil
+}
+
+// buildStepProcess is a command a RUN instruction executes in the image
+type buildStepProcess struct {
+	Args    []string
+	Env     []string
+	WorkDir string
+	User    string
+	// Stdout and Stderr receive the command's output
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// processRun handles RUN instruction: the command runs in a temporary root
+// filesystem holding the image so far, and the files it changes become a
+// new layer
+func (b *ImageBuilder) processRun(step BuildStep, img *image.Image, config *BuildConfig) error {
 	if len(step.Arguments) == 0 {
 		return fmt.Errorf("RUN instruction requires an argument")
 	}
 
-	command := strings.Join(step.Arguments, " ")
+	command := strings.TrimSpace(step.RawLine[len(step.Instruction):])
 	logger.Debug("RUN: %s", command)
 
-	// For now, we'll simulate the RUN instruction by adding it as metadata
-	// In a full implementation, this would execute the command in a container
-	layerID := fmt.Sprintf("run-%d", time.Now().UnixNano())
-	img.Layers = append(img.Layers, layerID)
+	// The exec form is a JSON array; anything else runs in the SHELL
+	args := append(imageShell(img), command)
+	if execArgs, ok := parseJSONForm(command); ok && len(execArgs) > 0 {
+		args = execArgs
+	}
+
+	// Build arguments are in the environment of RUN, below the ENV values
+	env := img.Config.Env
+	if b.scope != nil {
+		env = append(b.scope.runEnv(), env...)
+	}
+	process := buildStepProcess{
+		Args:    args,
+		Env:     buildStepEnv(env),
+		WorkDir: img.Config.WorkingDir,
+		User:    img.Config.User,
+		Stdout:  b.out,
+		Stderr:  b.errOut,
+	}
+
+	parent := ""
+	if len(img.LayerChain) > 0 {
+		parent = img.LayerChain[len(img.LayerChain)-1]
+	}
+	opts := image.LayerOptions{Epoch: config.Epoch}
+	layer, err := b.runStep(img, parent, process, opts)
+	if err != nil {
+		return err
+	}
+
+	// A reproducible build runs the command again on a fresh copy and
+	// compares the two layers
+	if config.Reproducible {
+		again, err := b.runStep(img, parent, process, opts)
+		if err != nil {
+			return err
+		}
+		if again.DiffID != layer.DiffID {
+			return fmt.Errorf("RUN is not reproducible: layer content changed between two runs (%s, %s)",
+				layer.DiffID, again.DiffID)
+		}
+	}
+
+	img.Layers = append(img.Layers, layer.Digest)
+	img.LayerChain = append(img.LayerChain, layer.ChainID)
+	img.Size += layer.Size
+
+	layerID := fmt.Sprintf("run-%d", len(img.Layers)-1)
 	img.Metadata[fmt.Sprintf("layer.%s.command", layerID)] = command
 	img.Metadata[fmt.Sprintf("layer.%s.type", layerID)] = "run"
 
 	return nil
 }
 
-// processCopy handles COPY instruction
-func (b *ImageBuilder) processCopy(step BuildStep, img *image.Image, contextPath string) error {
-	if len(step.Arguments) < 2 {
-		return fmt.Errorf("COPY instruction requires at least 2 arguments")
+// runStep executes a RUN command over the image's layers and commits the
+// files it changed as a layer on top of parent
+func (b *ImageBuilder) runStep(img *image.Image, parent string, process buildStepProcess, opts image.LayerOptions) (*image.Layer, error) {
+	root, err := os.MkdirTemp("", "servin-build-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build root: %v", err)
 	}
+	defer os.RemoveAll(root)
 
-	sources := step.Arguments[:len(step.Arguments)-1]
-	dest := step.Arguments[len(step.Arguments)-1]
+	if len(img.LayerChain) > 0 || img.RootFSPath != "" {
+		if err := b.imgManager.ExtractRootFS(img, root); err != nil {
+			return nil, fmt.Errorf("failed to prepare build root: %v", err)
+		}
+	}
+	if err := prepareBuildRoot(root); err != nil {
+		return nil, err
+	}
 
-	logger.Debug("COPY: %v -> %s", sources, dest)
+	before, err := image.TakeSnapshot(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := runBuildStep(root, process); err != nil {
+		return nil, fmt.Errorf("the command '%s' failed: %v", strings.Join(process.Args, " "), err)
+	}
+
+	layer, err := b.imgManager.CreateLayerFromChanges(parent, before, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit RUN layer: %v", err)
+	}
+	return layer, nil
+}
+
+// imageShell returns the shell that runs the shell form of RUN, CMD and
+// ENTRYPOINT, set with SHELL
+func imageShell(img *image.Image) []string {
+	if len(img.Config.Shell) > 0 {
+		return append([]string(nil), img.Config.Shell...)
+	}
+	return []string{"/bin/sh", "-c"}
+}
+
+// buildStepEnv returns the environment a RUN command sees: the image's ENV
+// values, later ones winning, and a default PATH
+func buildStepEnv(imageEnv []string) []string {
+	values := make(map[string]string)
+	var keys []string
+	for _, kv := range imageEnv {
+		key, value, _ := strings.Cut(kv, "=")
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	if _, ok := values["PATH"]; !ok {
+		keys = append(keys, "PATH")
+		values["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	}
+
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, key+"="+values[key])
+	}
+	return env
+}
+
+// copyFlags are the options of a COPY or ADD instruction
+type copyFlags struct {
+	// chown is the owner of the files added, as USER[:GROUP]
+	chown string
+	// chmod is the permission bits of the files added, if set
+	chmod os.FileMode
+	// from is the stage or image the files are copied from instead of the
+	// build context
+	from string
+}
 
-	// Validate source files exist in build context
+// parseCopyFlags splits the leading --chown, --chmod, --link and --from
+// options off the arguments of COPY or ADD
+func parseCopyFlags(args []string) (copyFlags, []string, error) {
+	var flags copyFlags
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		name, value, _ := strings.Cut(strings.TrimPrefix(args[0], "--"), "=")
+		switch name {
+		case "chown":
+			flags.chown = value
+		case "chmod":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || mode > 07777 {
+				return flags, nil, fmt.Errorf("invalid --chmod value '%s'", value)
+			}
+			flags.chmod = os.FileMode(mode)
+		case "link":
+			// Layers are always written independently of the ones below
+		case "from":
+			if value == "" {
+				return flags, nil, fmt.Errorf("--from requires a stage or image")
+			}
+			flags.from = value
+		default:
+			return flags, nil, fmt.Errorf("unknown option '%s'", args[0])
+		}
+		args = args[1:]
+	}
+	return flags, args, nil
+}
+
+// contextSources resolves the sources of COPY or ADD to paths in the build
+// context, expanding wildcards. Files the .dockerignore excludes are not
+// in the context.
+func (b *ImageBuilder) contextSources(contextPath string, sources []string) ([]string, error) {
+	var paths []string
 	for _, src := range sources {
 		srcPath := filepath.Join(contextPath, src)
-		if _, err := os.Stat(srcPath); os.IsNotExist(err) {
-			return fmt.Errorf("source file '%s' not found in build context", src)
+		if rel, err := filepath.Rel(contextPath, srcPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("source '%s' is outside the build context", src)
+		}
+
+		matches := []string{srcPath}
+		if strings.ContainsAny(src, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(srcPath); err != nil {
+				return nil, fmt.Errorf("invalid source pattern '%s': %v", src, err)
+			}
+		}
+
+		found := false
+		for _, match := range matches {
+			rel, _ := filepath.Rel(contextPath, match)
+			if rel != "." && b.ignore.Matches(rel) {
+				continue
+			}
+			if _, err := os.Lstat(match); err != nil {
+				continue
+			}
+			paths = append(paths, match)
+			found = true
+		}
+		if !found {
+			return nil, errors.NewNotFoundError("build", fmt.Sprintf("source file '%s' not found in build context", src))
+		}
+	}
+	return paths, nil
+}
+
+// copyOwner resolves the --chown of COPY or ADD to a UID and GID. Names are
+// looked up in the image's /etc/passwd and /etc/group.
+func (b *ImageBuilder) copyOwner(spec string, img *image.Image) (int, int, error) {
+	if spec == "" {
+		return 0, 0, nil
+	}
+	user, group, _ := strings.Cut(spec, ":")
+	uid, uidErr := strconv.Atoi(user)
+	gid, gidErr := strconv.Atoi(group)
+	if uidErr == nil && (group == "" || gidErr == nil) {
+		if group == "" {
+			gid = uid
+		}
+		return uid, gid, nil
+	}
+
+	root, err := os.MkdirTemp("", "servin-build-")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create build root: %v", err)
+	}
+	defer os.RemoveAll(root)
+	if len(img.LayerChain) > 0 || img.RootFSPath != "" {
+		if err := b.imgManager.ExtractRootFS(img, root); err != nil {
+			return 0, 0, fmt.Errorf("failed to read the image's users: %v", err)
 		}
 	}
 
-	// Add copy operation as a layer
-	layerID := fmt.Sprintf("copy-%d", time.Now().UnixNano())
-	img.Layers = append(img.Layers, layerID)
+	uid32, gid32, err := lookupBuildUser(root, spec)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --chown '%s': %v", spec, err)
+	}
+	return int(uid32), int(gid32), nil
+}
+
+// processCopy handles COPY instruction
+func (b *ImageBuilder) processCopy(step BuildStep, img *image.Image, config *BuildConfig) error {
+	flags, args, err := parseCopyFlags(step.Arguments)
+	if err != nil {
+		return fmt.Errorf("%s: %v", step.Instruction, err)
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("%s instruction requires at least 2 arguments", step.Instruction)
+	}
+
+	sources := args[:len(args)-1]
+	dest := args[len(args)-1]
+
+	logger.Debug("COPY: %v -> %s", sources, dest)
+
+	// Files the .dockerignore excludes are left out of copied directories
+	var paths []string
+	skip := func(file string, info os.FileInfo) bool {
+		rel, err := filepath.Rel(config.ContextPath, file)
+		return err == nil && b.ignore.Matches(rel)
+	}
+	if flags.from != "" {
+		if step.Instruction != "COPY" {
+			return fmt.Errorf("%s does not support --from", step.Instruction)
+		}
+		root, err := b.copySourceRoot(flags.from)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(root)
+		if paths, err = rootSources(root, sources); err != nil {
+			return fmt.Errorf("%v in '%s'", err, flags.from)
+		}
+		skip = nil
+	} else if paths, err = b.contextSources(config.ContextPath, sources); err != nil {
+		return err
+	}
+	uid, gid, err := b.copyOwner(flags.chown, img)
+	if err != nil {
+		return err
+	}
+
+	// Relative destinations are inside the working directory; with several
+	// sources or a trailing slash the destination is a directory
+	destPath := dest
+	if !path.IsAbs(destPath) {
+		destPath = path.Join(img.Config.WorkingDir, destPath)
+	}
+	intoDir := len(paths) > 1 || strings.HasSuffix(dest, "/")
+
+	var layerSources []image.LayerSource
+	for _, srcPath := range paths {
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return err
+		}
+
+		target := destPath
+		if intoDir && !info.IsDir() {
+			target = path.Join(destPath, filepath.Base(srcPath))
+		}
+		layerSources = append(layerSources, image.LayerSource{
+			Path: srcPath, Dest: target,
+			UID: uid, GID: gid, Mode: flags.chmod,
+			Skip: skip,
+		})
+	}
+
+	parent := ""
+	if len(img.LayerChain) > 0 {
+		parent = img.LayerChain[len(img.LayerChain)-1]
+	}
+	opts := image.LayerOptions{Epoch: config.Epoch}
+	layer, err := b.imgManager.CreateLayerFromSources(parent, layerSources, opts)
+	if err != nil {
+		return err
+	}
+
+	// A reproducible build writes the layer again and compares the two, to
+	// catch sources that change while the build runs
+	if config.Reproducible {
+		again, err := b.imgManager.CreateLayerFromSources(parent, layerSources, opts)
+		if err != nil {
+			return err
+		}
+		if again.DiffID != layer.DiffID {
+			return fmt.Errorf("%s is not reproducible: layer content changed between two writes (%s, %s)",
+				step.Instruction, layer.DiffID, again.DiffID)
+		}
+	}
+
+	img.Layers = append(img.Layers, layer.Digest)
+	img.LayerChain = append(img.LayerChain, layer.ChainID)
+	img.Size += layer.Size
+
+	layerID := fmt.Sprintf("%s-%d", strings.ToLower(step.Instruction), len(img.Layers)-1)
 	img.Metadata[fmt.Sprintf("layer.%s.sources", layerID)] = strings.Join(sources, ",")
-	img.Metadata[fmt.Sprintf("layer.%s.dest", layerID)] = dest
+	img.Metadata[fmt.Sprintf("layer.%s.dest", layerID)] = destPath
 	img.Metadata[fmt.Sprintf("layer.%s.type", layerID)] = "copy"
 
 	return nil
 }
 
 // processAdd handles ADD instruction (similar to COPY but with URL support)
-func (b *ImageBuilder) processAdd(step BuildStep, img *image.Image, contextPath string) error {
+func (b *ImageBuilder) processAdd(step BuildStep, img *image.Image, config *BuildConfig) error {
 	if len(step.Arguments) < 2 {
 		return fmt.Errorf("ADD instruction requires at least 2 arguments")
 	}
@@ -420,9 +1182,20 @@ func (b *ImageBuilder) processAdd(step BuildStep, img *image.Image, contextPath
 
 	logger.Debug("ADD: %v -> %s", sources, dest)
 
+	// Remote sources can change between builds, so they cannot be cached or
+	// reproduced
+	for _, src := range sources {
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			if config.Reproducible {
+				return fmt.Errorf("ADD %s is not reproducible: remote sources can change between builds", src)
+			}
+			return fmt.Errorf("ADD from a URL is not supported: %s", src)
+		}
+	}
+
 	// For now, treat ADD the same as COPY
-	// In a full implementation, ADD would support URLs and automatic extraction
-	return b.processCopy(step, img, contextPath)
+	// In a full implementation, ADD would support automatic extraction
+	return b.processCopy(step, img, config)
 }
 
 // processWorkdir handles WORKDIR instruction
@@ -431,7 +1204,11 @@ func (b *ImageBuilder) processWorkdir(step BuildStep, img *image.Image) error {
 		return fmt.Errorf("WORKDIR instruction requires an argument")
 	}
 
+	// A relative path is inside the previous working directory
 	workdir := step.Arguments[0]
+	if !path.IsAbs(workdir) {
+		workdir = path.Join(img.Config.WorkingDir, workdir)
+	}
 	img.Config.WorkingDir = workdir
 	logger.Debug("WORKDIR: %s", workdir)
 
@@ -492,8 +1269,8 @@ func (b *ImageBuilder) processCmd(step BuildStep, img *image.Image) error {
 		return fmt.Errorf("CMD instruction requires an argument")
 	}
 
-	img.Config.Cmd = step.Arguments
-	logger.Debug("CMD: %v", step.Arguments)
+	img.Config.Cmd = b.commandArgs(step, img)
+	logger.Debug("CMD: %v", img.Config.Cmd)
 
 	return nil
 }
@@ -504,12 +1281,30 @@ func (b *ImageBuilder) processEntrypoint(step BuildStep, img *image.Image) error
 		return fmt.Errorf("ENTRYPOINT instruction requires an argument")
 	}
 
-	img.Config.Entrypoint = step.Arguments
-	logger.Debug("ENTRYPOINT: %v", step.Arguments)
+	// As in Docker, a new ENTRYPOINT drops the CMD of the base image
+	img.Config.Entrypoint = b.commandArgs(step, img)
+	if b.scope != nil && !b.scope.cmdSet {
+		img.Config.Cmd = []string{}
+	}
+	logger.Debug("ENTRYPOINT: %v", img.Config.Entrypoint)
 
 	return nil
 }
 
+// commandArgs returns the command of a CMD or ENTRYPOINT step. The exec
+// form is a JSON array; a Dockerfile runs the shell form in the SHELL,
+// while a Buildfile splits it into words.
+func (b *ImageBuilder) commandArgs(step BuildStep, img *image.Image) []string {
+	args := instructionArgs(step)
+	if list, ok := parseJSONForm(args); ok {
+		return list
+	}
+	if b.scope != nil {
+		return append(imageShell(img), args)
+	}
+	return step.Arguments
+}
+
 // processLabel handles LABEL instruction
 func (b *ImageBuilder) processLabel(step BuildStep, img *image.Image) error {
 	if len(step.Arguments) == 0 {
@@ -546,10 +1341,238 @@ func (b *ImageBuilder) processVolume(step BuildStep, img *image.Image) error {
 		return fmt.Errorf("VOLUME instruction requires an argument")
 	}
 
-	// Add volume information to metadata
-	volumes := strings.Join(step.Arguments, ",")
-	img.Metadata["volumes"] = volumes
-	logger.Debug("VOLUME: %s", volumes)
+	if img.Config.Volumes == nil {
+		img.Config.Volumes = make(map[string]struct{})
+	}
+	// VOLUME takes paths or a JSON array of them
+	dirs := step.Arguments
+	if list, ok := parseJSONForm(instructionArgs(step)); ok {
+		dirs = list
+	}
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir, "/") {
+			return fmt.Errorf("VOLUME path '%s' must be absolute", dir)
+		}
+		img.Config.Volumes[path.Clean(dir)] = struct{}{}
+		logger.Debug("VOLUME: %s", dir)
+	}
+
+	return nil
+}
+
+// processHealthcheck handles HEALTHCHECK instruction
+func (b *ImageBuilder) processHealthcheck(step BuildStep, img *image.Image) error {
+	if len(step.Arguments) == 0 {
+		return fmt.Errorf("HEALTHCHECK instruction requires an argument")
+	}
+
+	healthcheck, err := health.ParseInstruction(step.Arguments)
+	if err != nil {
+		return err
+	}
+
+	img.Config.Healthcheck = healthcheck
+	logger.Debug("HEALTHCHECK: %v", healthcheck.Test)
+
+	return nil
+}
+
+// processShell handles SHELL instruction, which sets the shell for the
+// shell form of later instructions
+func (b *ImageBuilder) processShell(step BuildStep, img *image.Image) error {
+	shell, ok := parseJSONForm(instructionArgs(step))
+	if !ok || len(shell) == 0 {
+		return fmt.Errorf("SHELL requires a JSON array, such as [\"/bin/bash\", \"-c\"]")
+	}
+
+	img.Config.Shell = shell
+	logger.Debug("SHELL: %v", shell)
+
+	return nil
+}
+
+// processStopsignal handles STOPSIGNAL instruction
+func (b *ImageBuilder) processStopsignal(step BuildStep, img *image.Image) error {
+	if len(step.Arguments) != 1 {
+		return fmt.Errorf("STOPSIGNAL instruction requires exactly one argument")
+	}
+	if _, err := container.ParseStopSignal(step.Arguments[0]); err != nil {
+		return err
+	}
+
+	img.Config.StopSignal = step.Arguments[0]
+	logger.Debug("STOPSIGNAL: %s", step.Arguments[0])
+
+	return nil
+}
+
+// processOnbuild handles ONBUILD instruction: the trigger is stored in the
+// image and runs when another build uses it as its base
+func (b *ImageBuilder) processOnbuild(step BuildStep, img *image.Image) error {
+	trigger := instructionArgs(step)
+	if trigger == "" {
+		return fmt.Errorf("ONBUILD instruction requires an argument")
+	}
+	triggerStep, err := parseDockerfileLine(trigger)
+	if err != nil {
+		return err
+	}
+	switch triggerStep.Instruction {
+	case "ONBUILD", "FROM", "MAINTAINER":
+		return fmt.Errorf("%s is not allowed as an ONBUILD trigger", triggerStep.Instruction)
+	}
+
+	img.Config.OnBuild = append(img.Config.OnBuild, triggerStep.RawLine)
+	logger.Debug("ONBUILD: %s", triggerStep.RawLine)
 
 	return nil
 }
+
+// stepCacheKey derives a step's cache key from the previous step's key and
+// the step's own inputs: the instruction, the base image for FROM and the
+// contents of the source files for COPY and ADD
+func (b *ImageBuilder) stepCacheKey(parent string, step BuildStep, contextPath string) string {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%s\n%s\n", parent, step.RawLine)
+
+	switch step.Instruction {
+	case "FROM":
+		if b.stage != nil && b.stage.base != nil {
+			fmt.Fprintf(hasher, "stage %s\n", b.stage.base.cacheKey)
+		} else if name, _, err := parseFrom(step); err == nil {
+			if base, err := b.imgManager.GetImage(name); err == nil {
+				fmt.Fprintf(hasher, "base %s\n", base.ID)
+			}
+		}
+	case "COPY", "ADD":
+		flags, args, err := parseCopyFlags(step.Arguments)
+		switch {
+		case err != nil || len(args) < 2:
+		case flags.from != "":
+			// Files from a stage are covered by the stage's own cache key
+			if stage := b.dependency(flags.from); stage != nil {
+				fmt.Fprintf(hasher, "stage %s\n", stage.cacheKey)
+			} else if src, err := b.imgManager.GetImage(flags.from); err == nil {
+				fmt.Fprintf(hasher, "image %s\n", src.ID)
+			}
+		default:
+			sources, _ := b.contextSources(contextPath, args[:len(args)-1])
+			for _, src := range sources {
+				hashContextPath(hasher, contextPath, src, b.ignore)
+			}
+		}
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// buildCacheSeed seeds the step cache keys with the build settings that
+// change every step's result: the --label values and the timestamp epoch
+func buildCacheSeed(config *BuildConfig) string {
+	keys := make([]string, 0, len(config.Labels))
+	for key := range config.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hasher := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "%s=%s\n", key, config.Labels[key])
+	}
+	if config.Epoch != nil {
+		fmt.Fprintf(hasher, "epoch %d\n", config.Epoch.Unix())
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// cachedStep returns a copy of the image state after the step with the
+// given cache key, from this builder or the image store's build cache
+func (b *ImageBuilder) cachedStep(key string) *image.Image {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	if snapshot, ok := b.cache[key]; ok {
+		return cloneImage(snapshot)
+	}
+	snapshot := b.imgManager.CachedBuildStep(key)
+	if snapshot != nil {
+		b.cache[key] = cloneImage(snapshot)
+	}
+	return snapshot
+}
+
+// cacheStep records the image state after a step for later builds. A
+// failure to persist it only costs a rebuild of the step next time.
+func (b *ImageBuilder) cacheStep(key string, img *image.Image) {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	b.cache[key] = cloneImage(img)
+	if err := b.imgManager.CacheBuildStep(key, img); err != nil {
+		logger.Warn("Failed to update build cache: %v", err)
+	}
+}
+
+// hashContextPath adds the names relative to the context, modes and
+// contents of the files under path, so the key does not depend on where
+// the context is. Files the .dockerignore excludes are not copied and
+// are left out.
+func hashContextPath(w io.Writer, contextPath, path string, ignore *builder.Ignore) {
+	filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		name, _ := filepath.Rel(contextPath, file)
+		if err != nil {
+			fmt.Fprintf(w, "missing %s\n", filepath.ToSlash(name))
+			return nil
+		}
+		if ignore.Matches(name) {
+			if info.IsDir() && ignore.SkipDir(name) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fmt.Fprintf(w, "%s %o\n", filepath.ToSlash(name), info.Mode())
+		if info.Mode().IsRegular() {
+			if f, err := os.Open(file); err == nil {
+				io.Copy(w, f)
+				f.Close()
+			}
+		}
+		return nil
+	})
+}
+
+// cloneImage deep-copies an image so cached build states are not modified
+// by later steps
+func cloneImage(img *image.Image) *image.Image {
+	data, err := json.Marshal(img)
+	if err != nil {
+		return img
+	}
+	var clone image.Image
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return img
+	}
+	if clone.Metadata == nil {
+		clone.Metadata = make(map[string]string)
+	}
+	return &clone
+}
+
+// tagImage makes sure an unchanged image carries the requested tag
+func (b *ImageBuilder) tagImage(img *image.Image, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, existing := range img.RepoTags {
+		if existing == tag {
+			return nil
+		}
+	}
+	if err := b.imgManager.Untag(tag); err != nil {
+		return err
+	}
+
+	if len(img.RepoTags) == 1 && img.RepoTags[0] == "<none>:<none>" {
+		img.RepoTags = nil
+	}
+	img.RepoTags = append(img.RepoTags, tag)
+	return b.imgManager.SaveImage(img)
+}
@@ -0,0 +1,503 @@
+package cmd
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"servin/pkg/errors"
+	"servin/pkg/image"
+	"servin/pkg/logger"
+	"servin/pkg/telemetry"
+)
+
+// errStageAborted stops a stage when another stage of the build failed
+var errStageAborted = stderrors.New("build aborted")
+
+// buildStage is one FROM section of a build file. Stages form a graph: a
+// stage depends on the stage it is built FROM and the stages it copies
+// files from with COPY --from.
+type buildStage struct {
+	index int
+	// name is the name given with FROM ... AS, if any
+	name  string
+	steps []BuildStep
+	// first is the number of the stage's first step in the whole build
+	first int
+	// triggers counts the ONBUILD triggers inserted after FROM
+	triggers int
+
+	// base is the earlier stage FROM builds on, nil for an image
+	base *buildStage
+	deps []*buildStage
+	// images are the images COPY --from copies files from
+	images []string
+
+	// done is closed once the stage has been built or has failed
+	done      chan struct{}
+	img       *image.Image
+	cacheKey  string
+	allCached bool
+	err       error
+}
+
+// label names the stage in build output: its name, or its index
+func (s *buildStage) label() string {
+	if s.name != "" {
+		return s.name
+	}
+	return strconv.Itoa(s.index)
+}
+
+// addDep records that the stage needs dep to be built first
+func (s *buildStage) addDep(dep *buildStage) {
+	for _, existing := range s.deps {
+		if existing == dep {
+			return
+		}
+	}
+	s.deps = append(s.deps, dep)
+}
+
+// needed returns the set of the stage and every stage it depends on
+func (s *buildStage) needed() map[*buildStage]bool {
+	needed := make(map[*buildStage]bool)
+	var visit func(*buildStage)
+	visit = func(stage *buildStage) {
+		if needed[stage] {
+			return
+		}
+		needed[stage] = true
+		for _, dep := range stage.deps {
+			visit(dep)
+		}
+	}
+	visit(s)
+	return needed
+}
+
+// stageRun holds what every stage of one build shares
+type stageRun struct {
+	config  *BuildConfig
+	buildID string
+	created time.Time
+	// seed is the cache key the first step of every stage builds on
+	seed  string
+	total int
+	span  *telemetry.Span
+
+	abortOnce sync.Once
+	abortCh   chan struct{}
+	// failed is the stage whose failure aborted the build
+	failed *buildStage
+}
+
+// abort stops the other stages after stage failed; only the first
+// failure is kept
+func (r *stageRun) abort(stage *buildStage) {
+	r.abortOnce.Do(func() {
+		r.failed = stage
+		close(r.abortCh)
+	})
+}
+
+// aborted reports whether a stage of the build failed
+func (r *stageRun) aborted() bool {
+	select {
+	case <-r.abortCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// fromStageName returns the name a FROM instruction gives its stage with AS
+func fromStageName(step BuildStep) string {
+	args := step.Arguments
+	if len(args) >= 3 && strings.EqualFold(args[len(args)-2], "AS") {
+		return args[len(args)-1]
+	}
+	return ""
+}
+
+// findStage looks up a stage by name, or with byIndex also by its index
+func findStage(stages []*buildStage, ref string, byIndex bool) *buildStage {
+	if byIndex {
+		if n, err := strconv.Atoi(ref); err == nil {
+			if n >= 0 && n < len(stages) {
+				return stages[n]
+			}
+			return nil
+		}
+	}
+	for _, stage := range stages {
+		if stage.name != "" && strings.EqualFold(stage.name, ref) {
+			return stage
+		}
+	}
+	return nil
+}
+
+// planStages splits the steps following the global ARGs into stages and
+// works out which stages each one needs. A FROM naming an earlier stage
+// builds on it; anything else is an image. offset is the number of steps
+// before the first stage.
+func (b *ImageBuilder) planStages(steps []BuildStep, offset int) ([]*buildStage, error) {
+	var stages []*buildStage
+	for i, step := range steps {
+		number := offset + i + 1
+		if step.Instruction == "FROM" || len(stages) == 0 {
+			stage := &buildStage{index: len(stages), first: number, done: make(chan struct{})}
+			if step.Instruction == "FROM" {
+				// FROM only sees the global arguments
+				if b.scope != nil {
+					var err error
+					if step, err = b.scope.expand(step, nil); err != nil {
+						return nil, fmt.Errorf("step %d failed: %w", number, err)
+					}
+				}
+				name, _, err := parseFrom(step)
+				if err != nil {
+					return nil, fmt.Errorf("step %d failed: %w", number, err)
+				}
+				stage.name = fromStageName(step)
+				if stage.name != "" && findStage(stages, stage.name, false) != nil {
+					return nil, fmt.Errorf("step %d failed: duplicate stage name '%s'", number, stage.name)
+				}
+				if base := findStage(stages, name, false); base != nil {
+					stage.base = base
+					stage.addDep(base)
+				}
+			}
+			stages = append(stages, stage)
+		}
+
+		stage := stages[len(stages)-1]
+		stage.steps = append(stage.steps, step)
+
+		if step.Instruction != "COPY" {
+			continue
+		}
+		flags, _, err := parseCopyFlags(step.Arguments)
+		if err != nil || flags.from == "" {
+			// A malformed COPY fails when it runs
+			continue
+		}
+		if strings.Contains(flags.from, "$") {
+			return nil, fmt.Errorf("step %d failed: COPY --from cannot use variables", number)
+		}
+		if dep := findStage(stages[:stage.index], flags.from, true); dep != nil {
+			stage.addDep(dep)
+		} else if findStage(stages, flags.from, true) != nil {
+			return nil, fmt.Errorf("step %d failed: COPY --from=%s refers to the stage itself or a later one", number, flags.from)
+		} else {
+			stage.images = append(stage.images, flags.from)
+		}
+	}
+
+	// An empty build file builds an empty image
+	if len(stages) == 0 {
+		stages = append(stages, &buildStage{first: offset + 1, done: make(chan struct{})})
+	}
+	return stages, nil
+}
+
+// targetStage returns the stage named by --target, or the last stage
+func targetStage(stages []*buildStage, name string) (*buildStage, error) {
+	if name == "" {
+		return stages[len(stages)-1], nil
+	}
+	if stage := findStage(stages, name, false); stage != nil {
+		return stage, nil
+	}
+	return nil, errors.NewNotFoundError("build", fmt.Sprintf("target stage '%s' not found", name))
+}
+
+// prepareStages pulls the images the stages target needs use, one at a
+// time so the image store is only written by one pull, and inserts the
+// ONBUILD triggers of base images after FROM. Steps are numbered
+// throughout the build once the triggers are in.
+func (b *ImageBuilder) prepareStages(stages []*buildStage, target *buildStage, offset int) error {
+	needed := target.needed()
+	next := offset + 1
+	for _, stage := range stages {
+		stage.first = next
+		if needed[stage] && stage.base == nil && len(stage.steps) > 0 && stage.steps[0].Instruction == "FROM" {
+			triggers, err := b.onbuildTriggers(stage.steps[0])
+			if err != nil {
+				return fmt.Errorf("step %d failed: %w", stage.first, err)
+			}
+			stage.steps = append(stage.steps[:1], append(triggers, stage.steps[1:]...)...)
+			stage.triggers = len(triggers)
+		}
+		if needed[stage] {
+			for _, name := range stage.images {
+				if _, err := b.ensureImage(name, ""); err != nil {
+					return fmt.Errorf("stage %s: COPY --from: %w", stage.label(), err)
+				}
+			}
+		}
+		next += len(stage.steps)
+	}
+	return nil
+}
+
+// runStages builds the target and the stages it needs. Each stage starts
+// once its dependencies are done, so independent stages build
+// concurrently; their output lines are prefixed with the stage and
+// interleave whole. The first failure stops the other stages.
+func (b *ImageBuilder) runStages(stages []*buildStage, target *buildStage, run *stageRun) error {
+	needed := target.needed()
+	run.abortCh = make(chan struct{})
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var scopes []*dockerfileScope
+	for _, stage := range stages {
+		if !needed[stage] {
+			logger.Debug("Skipping stage %s: the target does not need it", stage.label())
+			continue
+		}
+
+		sb := &ImageBuilder{
+			imgManager: b.imgManager,
+			cache:      b.cache,
+			cacheMu:    b.cacheMu,
+			ignore:     b.ignore,
+			stage:      stage,
+			stages:     stages,
+			out:        b.out,
+			errOut:     b.errOut,
+		}
+		if b.scope != nil {
+			sb.scope = b.scope.stage()
+			scopes = append(scopes, sb.scope)
+		}
+		var writers []*stageWriter
+		if len(needed) > 1 {
+			prefix := "[" + stage.label() + "] "
+			out := &stageWriter{mu: &mu, w: b.out, prefix: prefix}
+			errOut := &stageWriter{mu: &mu, w: b.errOut, prefix: prefix}
+			sb.out, sb.errOut = out, errOut
+			writers = append(writers, out, errOut)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(stage.done)
+			for _, dep := range stage.deps {
+				<-dep.done
+				if dep.err != nil {
+					stage.err = errStageAborted
+					return
+				}
+			}
+
+			stage.err = sb.runStage(stage, run)
+			for _, w := range writers {
+				w.Flush()
+			}
+			if stage.err != nil {
+				run.abort(stage)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Arguments any stage declared count as consumed
+	for _, scope := range scopes {
+		for name := range scope.consumed {
+			b.scope.consumed[name] = true
+		}
+	}
+
+	if failed := run.failed; failed != nil {
+		if len(needed) > 1 {
+			return fmt.Errorf("stage %s: %w", failed.label(), failed.err)
+		}
+		return failed.err
+	}
+	return nil
+}
+
+// dependency returns the earlier stage a COPY --from of the stage being
+// built names, or nil for an image
+func (b *ImageBuilder) dependency(ref string) *buildStage {
+	if b.stage == nil {
+		return nil
+	}
+	dep := findStage(b.stages[:b.stage.index], ref, true)
+	for _, d := range b.stage.deps {
+		if d == dep {
+			return dep
+		}
+	}
+	return nil
+}
+
+// processFromStage starts the image of a stage from the image an earlier
+// stage built
+func (b *ImageBuilder) processFromStage(base *buildStage, img *image.Image) {
+	from := cloneImage(base.img)
+	img.Config = from.Config
+	img.Layers = append(img.Layers, from.Layers...)
+	img.LayerChain = append(img.LayerChain, from.LayerChain...)
+	img.RootFSType = from.RootFSType
+	img.Platform = from.Platform
+}
+
+// copySourceRoot extracts the files of the stage or image COPY --from
+// names to a temporary directory the caller removes
+func (b *ImageBuilder) copySourceRoot(ref string) (string, error) {
+	var src *image.Image
+	if stage := b.dependency(ref); stage != nil {
+		src = stage.img
+	} else {
+		var err error
+		if src, err = b.imgManager.GetImage(ref); err != nil {
+			return "", errors.WrapError(err, errors.ErrTypeNotFound, "build", fmt.Sprintf("--from: image '%s' not found", ref))
+		}
+	}
+
+	root, err := os.MkdirTemp("", "servin-build-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create build root: %v", err)
+	}
+	if len(src.LayerChain) > 0 || src.RootFSPath != "" {
+		if err := b.imgManager.ExtractRootFS(src, root); err != nil {
+			os.RemoveAll(root)
+			return "", fmt.Errorf("failed to extract '%s': %v", ref, err)
+		}
+	}
+	return root, nil
+}
+
+// rootSources resolves the sources of COPY --from to paths in the root
+// filesystem at root, expanding wildcards. Symlinks are followed inside
+// root, as if it were /.
+func rootSources(root string, sources []string) ([]string, error) {
+	var paths []string
+	for _, src := range sources {
+		dir, err := resolveInRoot(root, path.Dir(path.Clean("/"+filepath.ToSlash(src))))
+		if err != nil {
+			return nil, err
+		}
+		base := path.Base(filepath.ToSlash(src))
+
+		var matches []string
+		if strings.ContainsAny(base, "*?[") {
+			if matches, err = filepath.Glob(filepath.Join(dir, base)); err != nil {
+				return nil, fmt.Errorf("invalid source pattern '%s': %v", src, err)
+			}
+		} else if base == "/" {
+			matches = []string{root}
+		} else {
+			rel, _ := filepath.Rel(root, filepath.Join(dir, base))
+			match, err := resolveInRoot(root, rel)
+			if err != nil {
+				return nil, err
+			}
+			matches = []string{match}
+		}
+
+		found := false
+		for _, match := range matches {
+			if _, err := os.Lstat(match); err == nil {
+				paths = append(paths, match)
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.NewNotFoundError("build", fmt.Sprintf("source file '%s' not found", src))
+		}
+	}
+	return paths, nil
+}
+
+// resolveInRoot returns the host path of name in the root filesystem at
+// root, following symlinks as if root were /, so the result never leaves
+// root. Missing path elements are kept as they are.
+func resolveInRoot(root, name string) (string, error) {
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	resolved := "/"
+	for links := 0; len(parts) > 0; {
+		part := parts[0]
+		parts = parts[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		hostPath := filepath.Join(root, filepath.FromSlash(next))
+		info, err := os.Lstat(hostPath)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > 40 {
+			return "", fmt.Errorf("too many levels of symbolic links in '%s'", name)
+		}
+		target, err := os.Readlink(hostPath)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+// stageWriter prefixes each line a build stage writes with the stage's
+// label. Stages share the lock, so lines of concurrent stages interleave
+// whole instead of mixing.
+type stageWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+// Write writes the complete lines in p and keeps a partial last line
+func (sw *stageWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	sw.buf = append(sw.buf, p...)
+	for {
+		i := bytes.IndexByte(sw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(sw.w, "%s%s", sw.prefix, sw.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		sw.buf = sw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a partial last line
+func (sw *stageWriter) Flush() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if len(sw.buf) > 0 {
+		fmt.Fprintf(sw.w, "%s%s\n", sw.prefix, sw.buf)
+		sw.buf = nil
+	}
+}
@@ -0,0 +1,174 @@
+//go:build linux
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+// Environment variables passing a RUN step's settings to the build-step
+// process; they are removed before the command runs
+const (
+	buildStepRootEnv    = "SERVIN_BUILD_ROOTFS"
+	buildStepWorkDirEnv = "SERVIN_BUILD_WORKDIR"
+	buildStepUserEnv    = "SERVIN_BUILD_USER"
+	buildStepResolvEnv  = "SERVIN_BUILD_RESOLV_CONF"
+)
+
+var buildStepCmd = &cobra.Command{
+	Use:    "build-step COMMAND [ARG...]",
+	Short:  "Run a build RUN instruction in its root filesystem (internal command)",
+	Hidden: true,
+	// The command line belongs to the RUN instruction
+	DisableFlagParsing: true,
+	SilenceUsage:       true,
+	RunE:               runBuildStepInit,
+}
+
+func init() {
+	rootCmd.AddCommand(buildStepCmd)
+}
+
+// prepareBuildRoot creates the mount points and the resolv.conf a RUN step
+// needs before the root is snapshotted, so they are not part of its layer
+func prepareBuildRoot(root string) error {
+	for _, dir := range []string{"proc", "dev", "etc"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return fmt.Errorf("failed to prepare build root: %v", err)
+		}
+	}
+	resolvConf := filepath.Join(root, "etc", "resolv.conf")
+	if _, err := os.Lstat(resolvConf); os.IsNotExist(err) {
+		if err := os.WriteFile(resolvConf, nil, 0644); err != nil {
+			return fmt.Errorf("failed to prepare build root: %v", err)
+		}
+	}
+	return nil
+}
+
+// runBuildStep runs a RUN command chrooted into root, in new mount, PID,
+// UTS and IPC namespaces that share the host network for package downloads
+func runBuildStep(root string, process buildStepProcess) error {
+	// The host's DNS settings are bind-mounted from a copy, so the command
+	// can neither change the host's file nor leave them in the layer
+	resolv, err := os.CreateTemp("", "servin-build-resolv-")
+	if err != nil {
+		return fmt.Errorf("failed to prepare resolv.conf: %v", err)
+	}
+	defer os.Remove(resolv.Name())
+	if data, err := os.ReadFile("/etc/resolv.conf"); err == nil {
+		resolv.Write(data)
+	}
+	resolv.Close()
+
+	cmd := exec.Command("/proc/self/exe", append([]string{"build-step"}, process.Args...)...)
+	cmd.Env = append(append([]string{}, process.Env...),
+		buildStepRootEnv+"="+root,
+		buildStepWorkDirEnv+"="+process.WorkDir,
+		buildStepUserEnv+"="+process.User,
+		buildStepResolvEnv+"="+resolv.Name(),
+	)
+	cmd.Stdin = nil
+	cmd.Stdout = process.Stdout
+	cmd.Stderr = process.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC,
+	}
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("returned a non-zero code: %d", exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
+
+// runBuildStepInit runs inside the namespaces created by runBuildStep: it
+// mounts /proc and a minimal /dev, changes root and runs the command as the
+// image user
+func runBuildStepInit(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("build-step requires a command")
+	}
+	root := os.Getenv(buildStepRootEnv)
+	workDir := os.Getenv(buildStepWorkDirEnv)
+	user := os.Getenv(buildStepUserEnv)
+	resolv := os.Getenv(buildStepResolvEnv)
+	if root == "" {
+		return fmt.Errorf("build-step must be started by servin build")
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "SERVIN_BUILD_") {
+			env = append(env, kv)
+		}
+	}
+
+	// Keep the mounts below out of the host's mount namespace
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %v", err)
+	}
+	if resolv != "" {
+		if err := unix.Mount(resolv, filepath.Join(root, "etc", "resolv.conf"), "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("failed to mount resolv.conf: %v", err)
+		}
+	}
+	if err := unix.Mount("tmpfs", filepath.Join(root, "dev"), "tmpfs", unix.MS_NOSUID, "mode=755"); err != nil {
+		return fmt.Errorf("failed to mount /dev: %v", err)
+	}
+	if err := unix.Chroot(root); err != nil {
+		return fmt.Errorf("chroot failed: %v", err)
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return fmt.Errorf("failed to chdir after chroot: %v", err)
+	}
+	if err := mountProc(); err != nil {
+		return fmt.Errorf("failed to mount /proc: %v", err)
+	}
+	if err := setupDevices(); err != nil {
+		return err
+	}
+
+	if workDir == "" {
+		workDir = "/"
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return fmt.Errorf("failed to create working directory %s: %v", workDir, err)
+	}
+	uid, gid, err := lookupBuildUser("/", user)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the command with the image's PATH, not servin's
+	for _, kv := range env {
+		if path, ok := strings.CutPrefix(kv, "PATH="); ok {
+			os.Setenv("PATH", path)
+		}
+	}
+	run := exec.Command(args[0], args[1:]...)
+	run.Env = env
+	run.Dir = workDir
+	run.Stdin = nil
+	run.Stdout = os.Stdout
+	run.Stderr = os.Stderr
+	run.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: uid, Gid: gid}}
+
+	if err := run.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		return err
+	}
+	return nil
+}
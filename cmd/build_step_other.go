@@ -0,0 +1,15 @@
+//go:build !linux
+
+package cmd
+
+import "fmt"
+
+// prepareBuildRoot fails on platforms that cannot run RUN instructions
+func prepareBuildRoot(root string) error {
+	return fmt.Errorf("RUN instructions need Linux namespaces; build on Linux or inside the servin VM ('servin vm start')")
+}
+
+// runBuildStep is only supported on Linux
+func runBuildStep(root string, process buildStepProcess) error {
+	return fmt.Errorf("RUN instructions are only supported on Linux")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lookupBuildUser resolves a USER value (name or UID, optionally with a
+// group name or GID after a colon) against /etc/passwd and /etc/group in
+// the image root filesystem at root
+func lookupBuildUser(root, spec string) (uint32, uint32, error) {
+	passwd := filepath.Join(root, "etc", "passwd")
+	group := filepath.Join(root, "etc", "group")
+
+	userPart, groupPart, hasGroup := strings.Cut(spec, ":")
+	// Images without a passwd file still run as root
+	if userPart == "" || userPart == "root" {
+		userPart = "0"
+	}
+
+	uid, gid := -1, -1
+	if n, err := strconv.Atoi(userPart); err == nil {
+		uid, gid = n, n
+		if entry := findDatabaseEntry(passwd, 2, userPart); entry != nil {
+			gid, _ = strconv.Atoi(entry[3])
+		}
+	} else if entry := findDatabaseEntry(passwd, 0, userPart); entry != nil && len(entry) > 3 {
+		uid, _ = strconv.Atoi(entry[2])
+		gid, _ = strconv.Atoi(entry[3])
+	} else {
+		return 0, 0, fmt.Errorf("unable to find user %s: no matching entries in passwd file", userPart)
+	}
+
+	if hasGroup {
+		if groupPart == "root" {
+			gid = 0
+		} else if n, err := strconv.Atoi(groupPart); err == nil {
+			gid = n
+		} else if entry := findDatabaseEntry(group, 0, groupPart); entry != nil {
+			gid, _ = strconv.Atoi(entry[2])
+		} else {
+			return 0, 0, fmt.Errorf("unable to find group %s: no matching entries in group file", groupPart)
+		}
+	}
+	return uint32(uid), uint32(gid), nil
+}
+
+// findDatabaseEntry returns the fields of the first line of a passwd-style
+// file whose field at index equals value
+func findDatabaseEntry(path string, index int, value string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) > 3 && fields[index] == value {
+			return fields
+		}
+	}
+	return nil
+}
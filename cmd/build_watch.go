@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/logger"
+	"servin/pkg/rootfs"
+	"servin/pkg/state"
+)
+
+// containerStopTimeout bounds how long a linked container may take to exit
+// before it is killed
+const containerStopTimeout = 10 * time.Second
+
+// watchBuild builds the image, then rebuilds it whenever the build context
+// changes until interrupted. Unchanged steps are served from the builder's cache.
+func watchBuild(builder *ImageBuilder, config *BuildConfig) error {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	snapshot := snapshotBuildContext(config.ContextPath)
+	watchRebuild(builder, config)
+	fmt.Printf("Watching %s for changes (Press Ctrl+C to stop)...\n", config.ContextPath)
+
+	ticker := time.NewTicker(buildWatchInterval)
+	defer ticker.Stop()
+
+	pending := false
+	for {
+		select {
+		case <-sigChan:
+			fmt.Println("\nStopped watching")
+			return nil
+		case <-ticker.C:
+			current := snapshotBuildContext(config.ContextPath)
+			if current != snapshot {
+				// Wait for the context to settle so a burst of saves triggers one build
+				snapshot = current
+				pending = true
+				continue
+			}
+			if !pending {
+				continue
+			}
+			pending = false
+
+			fmt.Printf("\nChange detected in %s, rebuilding...\n", config.ContextPath)
+			watchRebuild(builder, config)
+		}
+	}
+}
+
+// watchRebuild runs one build of the watch loop. Failures are reported and
+// watching continues so the next change can fix them.
+func watchRebuild(builder *ImageBuilder, config *BuildConfig) {
+	start := time.Now()
+	imageID, err := builder.Build(config)
+	if err != nil {
+		logger.Error("Build failed: %v", err)
+		fmt.Printf("Build failed: %v\n", err)
+		return
+	}
+
+	if builder.upToDate {
+		fmt.Printf("Image %s is up to date\n", shortImageID(imageID))
+		return
+	}
+	fmt.Printf("Successfully built image: %s (%s)\n", imageID, time.Since(start).Round(time.Millisecond))
+
+	if buildRestartContainer == "" {
+		return
+	}
+
+	imageRef := imageID
+	if config.Tag != "" {
+		imageRef = config.Tag
+	}
+	if err := restartWithImage(buildRestartContainer, imageRef); err != nil {
+		fmt.Printf("Failed to restart container %s: %v\n", buildRestartContainer, err)
+	}
+}
+
+// restartWithImage stops a container, switches it to the given image and
+// starts it again in the background
+func restartWithImage(ref, imageRef string) error {
+	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, ref)
+	if err != nil {
+		return err
+	}
+	cs, err := sm.LoadContainer(containerID)
+	if err != nil {
+		return err
+	}
+
+	if cs.Status == state.StatusRunning && cs.PID > 0 {
+		fmt.Printf("Stopping container %s...\n", ref)
+		if err := stopAndWait(cs.PID); err != nil {
+			return err
+		}
+		// The process running the container removes its root filesystem once
+		// the container exits; wait for that before recreating it
+		waitForRemoval(filepath.Dir(rootfs.New(cs.ID, cs.Image).RootPath), containerStopTimeout)
+	}
+
+	// Reload: the exit of the old process updates the state
+	if cs, err = sm.LoadContainer(containerID); err != nil {
+		return err
+	}
+	cs.Image = imageRef
+	cs.Status = state.StatusCreated
+	cs.PID = 0
+	if err := sm.SaveContainer(cs); err != nil {
+		return err
+	}
+
+	c := container.FromState(cs)
+	fmt.Printf("Starting container %s with image %s\n", ref, imageRef)
+	go func() {
+		if err := c.RunWithVM(); err != nil {
+			fmt.Printf("Container %s exited with error: %v\n", ref, err)
+		}
+	}()
+	return nil
+}
+
+// stopAndWait sends SIGTERM to a process and waits for it to exit, killing
+// it if it does not exit in time
+func stopAndWait(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return errors.WrapError(err, errors.ErrTypeNotFound, "build", fmt.Sprintf("process %d not found", pid))
+	}
+	process.Signal(syscall.SIGTERM)
+
+	deadline := time.Now().Add(containerStopTimeout)
+	for processExists(pid) {
+		if time.Now().After(deadline) {
+			if err := process.Signal(syscall.SIGKILL); err != nil {
+				return fmt.Errorf("failed to kill process %d: %v", pid, err)
+			}
+			deadline = time.Now().Add(containerStopTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
+// waitForRemoval waits until path no longer exists or the timeout expires
+func waitForRemoval(path string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// snapshotBuildContext fingerprints the names, sizes, modes and modification
+// times of the files in the build context
+func snapshotBuildContext(contextPath string) string {
+	hasher := sha256.New()
+	filepath.Walk(contextPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && path != contextPath {
+			switch info.Name() {
+			case ".git", ".hg", ".svn":
+				return filepath.SkipDir
+			}
+		}
+		fmt.Fprintf(hasher, "%s %d %o %d\n", path, info.Size(), info.Mode(), info.ModTime().UnixNano())
+		return nil
+	})
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+func shortImageID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
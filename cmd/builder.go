@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"servin/pkg/builder"
+	"servin/pkg/image"
+	"servin/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+var builderCmd = &cobra.Command{
+	Use:   "builder",
+	Short: "Manage remote builders and the build cache",
+	Long: `Manage remote builders. A builder is a host running servin, reached over
+SSH, that 'servin build --builder' streams the build context to. The image is
+built there and loaded into the local image store, or pushed to its registry
+straight from the builder with --push.
+
+SSH uses your own configuration (~/.ssh/config, keys and agent), so a
+builder is anything 'ssh [user@]host' can log in to. The builder named
+'local' builds on this machine.
+
+Examples:
+  servin builder create big ssh://ci@buildhost
+  servin builder create gpu ssh://buildhost:2222 --command "sudo servin" --use
+  servin builder ls
+  servin builder use big
+  servin builder use local
+  servin builder prune`,
+}
+
+var builderCreateCmd = &cobra.Command{
+	Use:   "create NAME ENDPOINT",
+	Short: "Add a remote builder",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runBuilderCreate,
+}
+
+var builderLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List builders",
+	RunE:    runBuilderList,
+}
+
+var builderUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "Set the builder used when --builder is not given",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBuilderUse,
+}
+
+var builderRmCmd = &cobra.Command{
+	Use:     "rm NAME",
+	Aliases: []string{"remove"},
+	Short:   "Remove a builder",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runBuilderRemove,
+}
+
+var builderPruneCmd = &cobra.Command{
+	Use:   "prune [OPTIONS]",
+	Short: "Remove the build cache",
+	Long: `Remove the build cache of local builds: the image state recorded after each
+Buildfile step, which lets later builds skip unchanged steps, and the layers
+only the cache uses. The next build runs every step again.
+
+Filters:
+  until=<time>   Only remove cache entries last used before a time, given
+                 as a duration ago (e.g. 24h) or an RFC 3339 timestamp
+
+Examples:
+  servin builder prune
+  servin builder prune --filter until=168h --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runBuilderPrune,
+}
+
+var (
+	builderCommand string
+	builderUse     bool
+	builderNoCheck bool
+)
+
+func init() {
+	builderCmd.AddCommand(builderCreateCmd)
+	builderCmd.AddCommand(builderLsCmd)
+	builderCmd.AddCommand(builderUseCmd)
+	builderCmd.AddCommand(builderRmCmd)
+	builderCmd.AddCommand(builderPruneCmd)
+
+	builderCreateCmd.Flags().StringVar(&builderCommand, "command", builder.DefaultCommand, "Command that runs servin on the builder")
+	builderCreateCmd.Flags().BoolVar(&builderUse, "use", false, "Use the new builder for builds")
+	builderCreateCmd.Flags().BoolVar(&builderNoCheck, "no-check", false, "Do not check that the builder can be reached")
+	addPruneFlags(builderPruneCmd)
+
+	rootCmd.AddCommand(builderCmd)
+}
+
+func runBuilderCreate(cmd *cobra.Command, args []string) error {
+	b := &builder.Builder{
+		Name:     args[0],
+		Endpoint: args[1],
+		Command:  builderCommand,
+	}
+	if _, _, err := builder.ParseEndpoint(b.Endpoint); err != nil {
+		return err
+	}
+	if !builderNoCheck {
+		if err := b.Check(); err != nil {
+			return fmt.Errorf("%v (use --no-check to add it anyway)", err)
+		}
+	}
+	if err := builder.Create(b); err != nil {
+		return err
+	}
+	if builderUse {
+		if err := builder.Use(b.Name); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(b.Name)
+	return nil
+}
+
+func runBuilderList(cmd *cobra.Command, args []string) error {
+	builders, err := builder.List()
+	if err != nil {
+		return err
+	}
+
+	current := builder.Current()
+	active := func(name string) string {
+		if name == current {
+			return "*"
+		}
+		return ""
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tENDPOINT\tCOMMAND\tCREATED\tACTIVE")
+	fmt.Fprintf(w, "%s\t%s\t\t\t%s\n", builder.Local, "(this machine)", active(builder.Local))
+	for _, b := range builders {
+		command := b.Command
+		if command == "" {
+			command = builder.DefaultCommand
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", b.Name, b.Endpoint, command,
+			b.Created.Format("2006-01-02 15:04:05"), active(b.Name))
+	}
+	return w.Flush()
+}
+
+func runBuilderUse(cmd *cobra.Command, args []string) error {
+	if err := builder.Use(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Now using builder '%s'\n", args[0])
+	return nil
+}
+
+func runBuilderRemove(cmd *cobra.Command, args []string) error {
+	if err := builder.Remove(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Println(args[0])
+	return nil
+}
+
+func runBuilderPrune(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+	until, err := parsePruneFilters(pruneFilters)
+	if err != nil {
+		return err
+	}
+	if !confirmPrune("  - all build cache") {
+		return nil
+	}
+
+	report, err := image.NewManager().PruneBuildCache(image.BuildCachePruneOptions{
+		Until:  until,
+		DryRun: pruneDryRun,
+	})
+	if report != nil {
+		printBuildCachePrune(report)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune build cache: %v", err)
+	}
+	printReclaimed(report.SpaceReclaimed)
+	return nil
+}
+
+// printBuildCachePrune reports the build cache entries a prune removed or
+// would remove
+func printBuildCachePrune(report *image.BuildCacheReport) {
+	if report.Entries == 0 {
+		return
+	}
+	if pruneDryRun {
+		fmt.Printf("Would delete %d build cache entries\n", report.Entries)
+	} else {
+		fmt.Printf("Deleted %d build cache entries\n", report.Entries)
+	}
+}
+
+// runRemoteBuild builds the context on a remote builder, then either pushes
+// the image from there or loads it into the local image store
+func runRemoteBuild(remote *builder.Builder, contextPath string, epoch *time.Time) error {
+	// The remote build always runs on the builder itself
+	args := []string{"--builder", builder.Local, "--file", buildFile}
+	if buildTag != "" {
+		args = append(args, "--tag", buildTag)
+	}
+	for _, arg := range buildArgs {
+		args = append(args, "--build-arg", arg)
+	}
+	for _, label := range buildLabels {
+		args = append(args, "--label", label)
+	}
+	if buildTarget != "" {
+		args = append(args, "--target", buildTarget)
+	}
+	if buildNoCache {
+		args = append(args, "--no-cache")
+	}
+	if buildReproducible {
+		args = append(args, "--reproducible")
+	}
+	var env []string
+	if epoch != nil {
+		env = append(env, "SOURCE_DATE_EPOCH="+strconv.FormatInt(epoch.Unix(), 10))
+	}
+
+	if !buildQuiet {
+		fmt.Printf("Sending build context to %s\n", remote.Endpoint)
+	}
+	imageID, err := remote.Build(contextPath, builder.BuildOptions{Args: args, File: buildFile, Env: env, Quiet: buildQuiet}, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	ref := imageID
+	if buildTag != "" {
+		ref = buildTag
+	}
+	if buildPush {
+		return remote.Push(ref, os.Stdout)
+	}
+
+	// servin load reads the archive from a file, so it is staged first
+	tmp, err := os.CreateTemp("", "servin-remote-build-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create staging file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := remote.Save(ref, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	names, err := image.NewManager().LoadImages(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("failed to load image built on %s: %v", remote.Endpoint, err)
+	}
+	logger.Debug("Loaded %v from %s", names, remote.Endpoint)
+
+	if buildQuiet {
+		fmt.Println(imageID)
+	} else {
+		fmt.Printf("Loaded image %s from %s\n", ref, remote.Endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+
+	"servin/pkg/checkpoint"
+	"servin/pkg/state"
+
+	"github.com/spf13/cobra"
+)
+
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint CONTAINER",
+	Short: "Checkpoint a running container",
+	Long: `Save the in-memory state of a running container to disk using CRIU.
+
+The container's processes are stopped once the checkpoint is written unless
+--leave-running is given. A copy of the container's root filesystem is kept
+with the checkpoint so it can be restored with 'servin restore' later, even
+after the container's own files have been cleaned up.
+
+Requires Linux and the criu binary in PATH.
+
+Examples:
+  servin checkpoint web                       # Checkpoint and stop
+  servin checkpoint --name before-upgrade web # Named checkpoint
+  servin checkpoint --leave-running web       # Snapshot without stopping
+  servin checkpoint ls web                    # List checkpoints
+  servin checkpoint rm web before-upgrade     # Delete a checkpoint`,
+	Args: cobra.ExactArgs(1),
+	RunE: createCheckpoint,
+}
+
+var checkpointLsCmd = &cobra.Command{
+	Use:     "ls CONTAINER",
+	Aliases: []string{"list"},
+	Short:   "List checkpoints of a container",
+	Args:    cobra.ExactArgs(1),
+	RunE:    listCheckpoints,
+}
+
+var checkpointRmCmd = &cobra.Command{
+	Use:     "rm CONTAINER CHECKPOINT",
+	Aliases: []string{"remove"},
+	Short:   "Remove a checkpoint",
+	Args:    cobra.ExactArgs(2),
+	RunE:    removeCheckpoint,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore CONTAINER",
+	Short: "Restore a container from a checkpoint",
+	Long: `Restore a stopped container's processes from a checkpoint created with
+'servin checkpoint'. The most recent checkpoint is used unless --checkpoint
+is given.
+
+Examples:
+  servin restore web
+  servin restore --checkpoint before-upgrade web`,
+	Args: cobra.ExactArgs(1),
+	RunE: restoreContainer,
+}
+
+var (
+	checkpointName         string
+	checkpointLeaveRunning bool
+	restoreCheckpointName  string
+)
+
+func init() {
+	rootCmd.AddCommand(checkpointCmd)
+	rootCmd.AddCommand(restoreCmd)
+	checkpointCmd.AddCommand(checkpointLsCmd)
+	checkpointCmd.AddCommand(checkpointRmCmd)
+
+	checkpointCmd.Flags().StringVar(&checkpointName, "name", "", "Checkpoint name (default: checkpoint-<timestamp>)")
+	checkpointCmd.Flags().BoolVar(&checkpointLeaveRunning, "leave-running", false, "Keep the container running after the checkpoint is written")
+	restoreCmd.Flags().StringVar(&restoreCheckpointName, "checkpoint", "", "Checkpoint to restore (default: most recent)")
+}
+
+func createCheckpoint(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
+	}
+
+	container, err := sm.LoadContainer(containerID)
+	if err != nil {
+		return fmt.Errorf("failed to load container %s: %v", args[0], err)
+	}
+
+	if container.Status != state.StatusRunning {
+		return fmt.Errorf("container %s is not running (status: %s)", args[0], container.Status)
+	}
+
+	// Mark the container stopped before its processes exit so the exit is not
+	// reported as a failure and restart policies leave it alone
+	if !checkpointLeaveRunning {
+		if err := sm.UpdateContainerStatus(containerID, state.StatusStopped); err != nil {
+			return fmt.Errorf("failed to update container status: %v", err)
+		}
+	}
+
+	fmt.Printf("Checkpointing container %s...\n", args[0])
+	cp, err := checkpoint.NewManager().Create(container, checkpointName, checkpointLeaveRunning)
+	if err != nil {
+		if !checkpointLeaveRunning {
+			sm.UpdateContainerStatus(containerID, state.StatusRunning)
+		}
+		return err
+	}
+
+	if !checkpointLeaveRunning {
+		if err := sm.UpdateContainerPID(containerID, 0); err != nil {
+			fmt.Printf("Warning: failed to update container PID: %v\n", err)
+		}
+	}
+
+	fmt.Printf("Created checkpoint %s for container %s\n", cp.Name, args[0])
+	return nil
+}
+
+func listCheckpoints(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := checkpoint.NewManager().List(containerID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-30s %-20s %s\n", "CHECKPOINT", "CREATED", "LEFT RUNNING")
+	for _, cp := range checkpoints {
+		fmt.Printf("%-30s %-20s %t\n", cp.Name, formatTime(cp.Created), cp.LeaveRunning)
+	}
+
+	return nil
+}
+
+func removeCheckpoint(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := checkpoint.NewManager().Remove(containerID, args[1]); err != nil {
+		return err
+	}
+
+	fmt.Println(args[1])
+	return nil
+}
+
+func restoreContainer(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
+	}
+
+	container, err := sm.LoadContainer(containerID)
+	if err != nil {
+		return fmt.Errorf("failed to load container %s: %v", args[0], err)
+	}
+
+	if container.Status == state.StatusRunning {
+		return fmt.Errorf("container %s is already running", args[0])
+	}
+
+	manager := checkpoint.NewManager()
+	var cp *checkpoint.Checkpoint
+	if restoreCheckpointName != "" {
+		cp, err = manager.Get(containerID, restoreCheckpointName)
+	} else {
+		cp, err = manager.Latest(containerID)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring container %s from checkpoint %s...\n", args[0], cp.Name)
+	pid, err := manager.Restore(container, cp)
+	if err != nil {
+		return err
+	}
+
+	if err := sm.UpdateContainerPID(containerID, pid); err != nil {
+		fmt.Printf("Warning: failed to update container PID: %v\n", err)
+	}
+	if err := sm.UpdateContainerStatus(containerID, state.StatusRunning); err != nil {
+		return fmt.Errorf("failed to update container status: %v", err)
+	}
+
+	fmt.Printf("Container %s restored (PID %d)\n", args[0], pid)
+	return nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"servin/pkg/container"
+	"servin/pkg/image"
+	"servin/pkg/state"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	commitChanges []string
+	commitMessage string
+	commitAuthor  string
+	commitForce   bool
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit [OPTIONS] CONTAINER [REPOSITORY[:TAG]]",
+	Short: "Create a new image from a container's changes",
+	Long: `Create a new image from a running container. The files the container
+added, changed or removed are written as one layer on top of its image's
+layers, and the new image starts with the same config as the container's
+image. Files servin writes into every container (/etc/hosts,
+/etc/resolv.conf) and mounted volumes are left out.
+
+--change applies a Dockerfile instruction to the new image's config; CMD,
+ENTRYPOINT, ENV, EXPOSE, LABEL, ONBUILD, STOPSIGNAL, USER, VOLUME and
+WORKDIR are supported.
+
+Examples:
+  servin commit web myapp:v2
+  servin commit -c 'CMD ["/app/server", "--port", "8080"]' -c "EXPOSE 8080" web myapp
+  servin commit -m "install debugging tools" -a "ops@example.com" web`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runCommit,
+}
+
+func init() {
+	rootCmd.AddCommand(commitCmd)
+
+	commitCmd.Flags().StringArrayVarP(&commitChanges, "change", "c", nil, "Apply a Dockerfile instruction to the image config")
+	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "Commit message")
+	commitCmd.Flags().StringVarP(&commitAuthor, "author", "a", "", "Author (e.g., \"Jane Doe <jane@example.com>\")")
+	commitCmd.Flags().BoolVarP(&commitForce, "force", "f", false, forceTagUsage)
+}
+
+func runCommit(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	// Check the changes before writing a layer for them
+	builder := &ImageBuilder{scope: newDockerfileScope('\\', nil)}
+	builder.scope.from()
+	var steps []BuildStep
+	for _, change := range commitChanges {
+		step, err := parseCommitChange(change)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, step)
+	}
+
+	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
+	}
+	cs, err := sm.LoadContainer(containerID)
+	if err != nil {
+		return fmt.Errorf("failed to load container %s: %v", args[0], err)
+	}
+	if cs.Status != state.StatusRunning {
+		return fmt.Errorf("container %s is not running (status: %s); only running containers can be committed", args[0], cs.Status)
+	}
+
+	img, err := container.Commit(cs)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := builder.applyChange(step, img); err != nil {
+			return fmt.Errorf("invalid --change '%s': %v", step.RawLine, err)
+		}
+	}
+	if commitMessage != "" {
+		img.Metadata["commit.message"] = commitMessage
+	}
+	if commitAuthor != "" {
+		img.Metadata["commit.author"] = commitAuthor
+	}
+	img.Metadata["commit.timestamp"] = img.Created.Format(time.RFC3339)
+
+	tag := ""
+	if len(args) == 2 {
+		tag = args[1]
+		if strings.LastIndex(tag, ":") <= strings.LastIndex(tag, "/") {
+			tag += ":latest"
+		}
+	}
+
+	imgManager, err := taggingManager(commitForce)
+	if err != nil {
+		return err
+	}
+	if err := imgManager.CommitConfig(img); err != nil {
+		return fmt.Errorf("failed to write image config: %v", err)
+	}
+	if err := imgManager.AddImage(img, tag); err != nil {
+		return err
+	}
+
+	fmt.Printf("sha256:%s\n", img.ID)
+	return nil
+}
+
+// parseCommitChange parses a --change value as a Dockerfile instruction
+func parseCommitChange(change string) (BuildStep, error) {
+	if strings.TrimSpace(change) == "" {
+		return BuildStep{}, fmt.Errorf("invalid --change: empty instruction")
+	}
+	step, err := parseDockerfileLine(strings.TrimSpace(change))
+	if err != nil {
+		return BuildStep{}, fmt.Errorf("invalid --change '%s': %v", change, err)
+	}
+	switch step.Instruction {
+	case "CMD", "ENTRYPOINT", "ENV", "EXPOSE", "LABEL", "ONBUILD", "STOPSIGNAL", "USER", "VOLUME", "WORKDIR":
+		return step, nil
+	}
+	return BuildStep{}, fmt.Errorf("invalid --change '%s': %s is not supported (valid: CMD, ENTRYPOINT, ENV, EXPOSE, LABEL, ONBUILD, STOPSIGNAL, USER, VOLUME, WORKDIR)",
+		change, step.Instruction)
+}
+
+// applyChange applies a --change instruction to the image config, expanding
+// its arguments against the image's environment as a Dockerfile would
+func (b *ImageBuilder) applyChange(step BuildStep, img *image.Image) error {
+	step, err := b.scope.expand(step, img.Config.Env)
+	if err != nil {
+		return err
+	}
+
+	switch step.Instruction {
+	case "CMD":
+		b.scope.cmdSet = true
+		return b.processCmd(step, img)
+	case "ENTRYPOINT":
+		return b.processEntrypoint(step, img)
+	case "ENV":
+		return b.processEnv(step, img)
+	case "EXPOSE":
+		return b.processExpose(step, img)
+	case "LABEL":
+		return b.processLabel(step, img)
+	case "ONBUILD":
+		return b.processOnbuild(step, img)
+	case "STOPSIGNAL":
+		return b.processStopsignal(step, img)
+	case "USER":
+		return b.processUser(step, img)
+	case "VOLUME":
+		return b.processVolume(step, img)
+	case "WORKDIR":
+		return b.processWorkdir(step, img)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 
 	"servin/pkg/compose"
+	"servin/pkg/errors"
 	"servin/pkg/logger"
 
 	"github.com/spf13/cobra"
@@ -271,7 +272,7 @@ func resolveComposeFile(file string) (string, error) {
 	// If absolute path, use as-is
 	if filepath.IsAbs(file) {
 		if _, err := os.Stat(file); err != nil {
-			return "", fmt.Errorf("compose file not found: %s", file)
+			return "", errors.NewNotFoundError("compose", fmt.Sprintf("compose file not found: %s", file))
 		}
 		return file, nil
 	}
@@ -284,7 +285,7 @@ func resolveComposeFile(file string) (string, error) {
 
 	composeFile := filepath.Join(currentDir, file)
 	if _, err := os.Stat(composeFile); err != nil {
-		return "", fmt.Errorf("compose file not found: %s", composeFile)
+		return "", errors.NewNotFoundError("compose", fmt.Sprintf("compose file not found: %s", composeFile))
 	}
 
 	return composeFile, nil
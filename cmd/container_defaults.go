@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"servin/pkg/cgroups"
+	"servin/pkg/config"
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/restart"
+	"servin/pkg/security"
+
+	"github.com/spf13/cobra"
+)
+
+// containerDefaults are the daemon-level defaults from config.yaml, checked
+// up front so a bad config file fails every run rather than some containers
+type containerDefaults struct {
+	config.ContainerDefaults
+	ulimits []security.Ulimit
+	path    string // config file they were read from, empty without one
+}
+
+// loadContainerDefaults reads and validates the container defaults
+func loadContainerDefaults() (*containerDefaults, error) {
+	cfg, path, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &containerDefaults{ContainerDefaults: cfg.Daemon.ContainerDefaults, path: path}
+	invalid := func(field string, err error) error {
+		msg := err.Error()
+		if se, ok := err.(*errors.ServinError); ok {
+			msg = se.Message
+		}
+		return errors.NewConfigError("loadContainerDefaults",
+			fmt.Sprintf("%s: daemon.container_defaults.%s: %s", path, field, msg))
+	}
+
+	if d.Memory != "" && runtime.GOOS == "linux" {
+		if _, err := cgroups.ParseMemoryString(d.Memory); err != nil {
+			return nil, invalid("memory", err)
+		}
+	}
+	if err := validateCPUs(d.CPUs); err != nil {
+		return nil, invalid("cpus", err)
+	}
+	if _, err := restart.ParsePolicy(d.Restart); err != nil {
+		return nil, invalid("restart", err)
+	}
+	if err := security.ValidateSeccomp(d.Seccomp); err != nil {
+		return nil, invalid("seccomp", err)
+	}
+	for _, u := range d.Ulimits {
+		limit, err := security.ParseUlimit(u.Spec())
+		if err != nil {
+			return nil, invalid("ulimits", err)
+		}
+		d.ulimits = append(d.ulimits, limit)
+	}
+
+	return d, nil
+}
+
+// validateCPUs checks a CPU limit such as "1.5"; empty means no limit
+func validateCPUs(cpus string) error {
+	if cpus == "" {
+		return nil
+	}
+	if n, err := strconv.ParseFloat(cpus, 64); err != nil || n < 0 {
+		return fmt.Errorf("invalid CPU limit '%s'", cpus)
+	}
+	return nil
+}
+
+// apply sets the defaults on a new container for every setting whose run
+// flag was not given
+func (d *containerDefaults) apply(cmd *cobra.Command, config *container.Config) error {
+	flags := cmd.Flags()
+
+	if !flags.Changed("memory") {
+		config.Memory = d.Memory
+	}
+	if !flags.Changed("cpus") {
+		config.CPUs = d.CPUs
+	}
+	if !flags.Changed("restart") && d.Restart != "" {
+		policy, _ := restart.ParsePolicy(d.Restart)
+		if policy.Name != restart.PolicyNo {
+			config.RestartPolicy = policy.String()
+		}
+	}
+
+	config.Seccomp = d.Seccomp
+	config.NoNewPrivileges = d.NoNewPrivileges
+	for _, opt := range securityOpts {
+		name, value, hasValue := strings.Cut(opt, "=")
+		switch name {
+		case "seccomp":
+			if err := security.ValidateSeccomp(value); err != nil || !hasValue {
+				return fmt.Errorf("invalid --security-opt '%s', expected seccomp=%s|%s", opt, security.SeccompDefault, security.SeccompUnconfined)
+			}
+			config.Seccomp = value
+		case "no-new-privileges":
+			enabled := true
+			if hasValue {
+				var err error
+				if enabled, err = strconv.ParseBool(value); err != nil {
+					return fmt.Errorf("invalid --security-opt '%s', expected no-new-privileges[=true|false]", opt)
+				}
+			}
+			config.NoNewPrivileges = enabled
+		default:
+			return fmt.Errorf("unknown --security-opt '%s' (valid: seccomp, no-new-privileges)", opt)
+		}
+	}
+
+	var overrides []security.Ulimit
+	for _, spec := range ulimitSpecs {
+		limit, err := security.ParseUlimit(spec)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, limit)
+	}
+	config.Ulimits = nil
+	for _, limit := range security.MergeUlimits(d.ulimits, overrides) {
+		config.Ulimits = append(config.Ulimits, limit.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/state"
+	"servin/pkg/vfs"
+	"servin/pkg/vm"
+
+	"github.com/spf13/cobra"
+)
+
+var cpCmd = &cobra.Command{
+	Use: `cp [OPTIONS] CONTAINER:SRC_PATH DEST_PATH|-
+  servin cp [OPTIONS] SRC_PATH|- CONTAINER:DEST_PATH`,
+	Short: "Copy files between a container and the host",
+	Long: `Copy files or directories between a container and the host, or between
+two containers.
+
+Directories are copied with their contents. Permissions, modification times
+and symlinks are kept; a symlink given as the source is copied as a link
+unless --follow-link is set. Paths in a container are resolved as the
+container sees them, so its symlinks cannot lead outside it, and a running
+container's volumes are included.
+
+As with cp, the copy goes into the destination when it is a directory, and
+otherwise replaces it or is created with its name, in a directory that must
+exist. A destination ending in / must be a directory.
+
+A source of - reads a tar archive from standard input and a destination of -
+writes one to standard output. Paths with a colon on the host are given as
+./NAME or absolute. On macOS and Windows, containers run in the VM and the
+files are streamed through it.`,
+	Example: `  servin cp web:/etc/nginx/nginx.conf .
+  servin cp ./site web:/usr/share/nginx/html
+  servin cp web:/var/log - | tar -tv`,
+	Args: cobra.ExactArgs(2),
+	RunE: copyFiles,
+}
+
+var cpFollowLink bool
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+
+	cpCmd.Flags().BoolVarP(&cpFollowLink, "follow-link", "L", false, "Copy what a symlink given as SRC_PATH points to")
+	cpCmd.Flags().BoolP("recursive", "r", false, "Copy directories recursively")
+	cpCmd.Flags().MarkDeprecated("recursive", "directories are always copied with their contents")
+}
+
+// copyEndpoint is one end of a copy: a path on the host or in a container,
+// or "-" for a tar archive on standard input or output
+type copyEndpoint struct {
+	containerID string
+	path        string
+}
+
+func (e copyEndpoint) stream() bool {
+	return e.containerID == "" && e.path == "-"
+}
+
+func copyFiles(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	sm := state.NewStateManager()
+	src, err := parseCopyEndpoint(sm, args[0])
+	if err != nil {
+		return err
+	}
+	dst, err := parseCopyEndpoint(sm, args[1])
+	if err != nil {
+		return err
+	}
+	if src.containerID == "" && dst.containerID == "" {
+		return errors.NewValidationError("cp", "one of the paths must be in a container, as CONTAINER:PATH")
+	}
+
+	// Containers on macOS and Windows run inside the VM, whose servin
+	// reads and writes the archives
+	var vmCopy vm.CopyProvider
+	if runtime.GOOS != "linux" {
+		if vmManager, err := container.NewVMContainerManager(); err == nil && vmManager.IsEnabled() {
+			if vmCopy, err = vmManager.VMContainerCopy(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The source is archived into a pipe the destination unpacks. Its error
+	// is sent before the pipe is closed, so when the source failed first
+	// that error is reported rather than the failed read it caused.
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := writeCopySource(src, vmCopy, pw)
+		done <- err
+		pw.CloseWithError(err)
+	}()
+	err = extractCopyDest(dst, vmCopy, pr)
+	if err == nil {
+		// Let the source write what follows the end of the archive
+		io.Copy(io.Discard, pr)
+	}
+	select {
+	case srcErr := <-done:
+		if srcErr != nil {
+			err = srcErr
+		}
+	default:
+		pr.CloseWithError(err)
+		<-done
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %v", args[0], args[1], err)
+	}
+	return nil
+}
+
+// parseCopyEndpoint parses a cp argument: CONTAINER:PATH, or a host path,
+// which is any path starting with / or . and any without a colon
+func parseCopyEndpoint(sm *state.StateManager, arg string) (copyEndpoint, error) {
+	if arg == "" {
+		return copyEndpoint{}, errors.NewValidationError("cp", "empty path")
+	}
+	if filepath.IsAbs(arg) || strings.HasPrefix(arg, ".") {
+		return copyEndpoint{path: arg}, nil
+	}
+	ref, p, ok := strings.Cut(arg, ":")
+	if !ok {
+		return copyEndpoint{path: arg}, nil
+	}
+	if ref == "" || p == "" {
+		return copyEndpoint{}, errors.NewValidationError("cp", fmt.Sprintf("invalid path '%s' (expected CONTAINER:PATH)", arg))
+	}
+	containerID, err := resolveContainerRef(sm, ref)
+	if err != nil {
+		return copyEndpoint{}, err
+	}
+	return copyEndpoint{containerID: containerID, path: p}, nil
+}
+
+// writeCopySource writes the source of a copy to w as a tar archive
+func writeCopySource(src copyEndpoint, vmCopy vm.CopyProvider, w io.Writer) error {
+	switch {
+	case src.stream():
+		_, err := io.Copy(w, os.Stdin)
+		return err
+	case src.containerID == "":
+		p, err := filepath.Abs(src.path)
+		if err != nil {
+			return err
+		}
+		if cpFollowLink {
+			if p, err = filepath.EvalSymlinks(p); err != nil {
+				return err
+			}
+		}
+		if _, err := os.Lstat(p); err != nil {
+			return err
+		}
+		return vfs.WriteArchive(w, p, copyArchiveName(filepath.ToSlash(p)))
+	case vmCopy != nil:
+		return vmCopy.CopyFromContainer(src.containerID, src.path, cpFollowLink, w)
+	}
+
+	root, err := containerCopyRoot(src.containerID)
+	if err != nil {
+		return err
+	}
+	p, err := vfs.ResolvePath(root, src.path, cpFollowLink)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(p); err != nil {
+		return fmt.Errorf("%s: no such file or directory in the container", src.path)
+	}
+	return vfs.WriteArchive(w, p, copyArchiveName(src.path))
+}
+
+// extractCopyDest unpacks the tar archive r at the destination of a copy
+func extractCopyDest(dst copyEndpoint, vmCopy vm.CopyProvider, r io.Reader) error {
+	switch {
+	case dst.stream():
+		_, err := io.Copy(os.Stdout, r)
+		return err
+	case dst.containerID == "":
+		// The archive is kept to the directory the destination is in
+		p, err := filepath.Abs(dst.path)
+		if err != nil {
+			return err
+		}
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			p = resolved
+		}
+		dest := filepath.Base(p)
+		if strings.HasSuffix(dst.path, "/") || strings.HasSuffix(dst.path, string(filepath.Separator)) {
+			if !isDir(p) {
+				return fmt.Errorf("destination directory %s does not exist", dst.path)
+			}
+			dest += "/"
+		}
+		if !isDir(filepath.Dir(p)) {
+			return fmt.Errorf("destination directory %s does not exist", filepath.Dir(dst.path))
+		}
+		return vfs.ExtractArchive(r, filepath.Dir(p), dest)
+	case vmCopy != nil:
+		return vmCopy.CopyToContainer(dst.containerID, dst.path, r)
+	}
+
+	root, err := containerCopyRoot(dst.containerID)
+	if err != nil {
+		return err
+	}
+	return vfs.ExtractArchive(r, root, dst.path)
+}
+
+// copyArchiveName returns the name a path is archived under
+func copyArchiveName(p string) string {
+	name := path.Base(path.Clean("/" + p))
+	if name == "/" {
+		return "rootfs"
+	}
+	return name
+}
+
+// containerCopyRoot returns the host directory of a container's filesystem:
+// the root of its process while it runs, which includes its volumes, or
+// else its rootfs
+func containerCopyRoot(containerID string) (string, error) {
+	cs, err := state.NewStateManager().LoadContainer(containerID)
+	if err != nil {
+		return "", err
+	}
+
+	root := ""
+	if runtime.GOOS == "linux" && cs.Status == state.StatusRunning && cs.PID > 0 {
+		if procRoot := fmt.Sprintf("/proc/%d/root", cs.PID); isDir(procRoot) {
+			root = procRoot
+		}
+	}
+	if root == "" {
+		if root, err = getContainerRootFS(containerID); err != nil {
+			return "", err
+		}
+	}
+
+	vfsManager, err := vfs.NewVFSManager()
+	if err != nil {
+		return "", fmt.Errorf("failed to create VFS manager: %v", err)
+	}
+	vfsSystem := vfsManager.GetVFS()
+	if err := vfsSystem.Initialize(containerID, root); err != nil {
+		return "", fmt.Errorf("failed to initialize container VFS: %v", err)
+	}
+	if err := vfsSystem.Mount(containerID); err != nil {
+		return "", fmt.Errorf("container %s has no filesystem on this host: %v", cs.Name, err)
+	}
+	return vfsSystem.GetHostPath(containerID, "/")
+}
+
+func isDir(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.IsDir()
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"servin/pkg/cri"
@@ -37,7 +38,8 @@ This server provides HTTP endpoints that are compatible with the Kubernetes
 CRI specification, allowing Servin to be used as a container runtime
 for Kubernetes and other orchestration platforms.
 
-The server will listen on the specified port and provide endpoints for:
+The server will listen on the specified port, by default $SERVIN_CRI_PORT or
+8080, and provide endpoints for:
 - Runtime operations (version, status, pod/container lifecycle)
 - Image operations (list, pull, remove, status)
 - Health checks
@@ -86,6 +88,18 @@ var (
 	criVerbose bool
 )
 
+// criPortEnvVar names the variable that changes the default CRI port
+const criPortEnvVar = "SERVIN_CRI_PORT"
+
+// defaultCRIPort returns the port in $SERVIN_CRI_PORT, or 8080 when it is
+// unset or not a valid port
+func defaultCRIPort() int {
+	if port, err := strconv.Atoi(os.Getenv(criPortEnvVar)); err == nil && port > 0 && port < 65536 {
+		return port
+	}
+	return 8080
+}
+
 func init() {
 	rootCmd.AddCommand(criCmd)
 	criCmd.AddCommand(criStartCmd)
@@ -93,15 +107,16 @@ func init() {
 	criCmd.AddCommand(criStatusCmd)
 
 	// CRI start command flags
-	criStartCmd.Flags().IntVarP(&criPort, "port", "p", 8080, "Port to listen on")
+	port := defaultCRIPort()
+	criStartCmd.Flags().IntVarP(&criPort, "port", "p", port, "Port to listen on (also $"+criPortEnvVar+")")
 	criStartCmd.Flags().BoolVarP(&criVerbose, "verbose", "v", false, "Enable verbose logging")
 
 	// CRI test command flags
-	criTestCmd.Flags().IntVarP(&criPort, "port", "p", 8080, "Port to connect to")
+	criTestCmd.Flags().IntVarP(&criPort, "port", "p", port, "Port to connect to (also $"+criPortEnvVar+")")
 	criTestCmd.Flags().StringVarP(&criHost, "host", "H", "localhost", "Host to connect to")
 
 	// CRI status command flags
-	criStatusCmd.Flags().IntVarP(&criPort, "port", "p", 8080, "Port to connect to")
+	criStatusCmd.Flags().IntVarP(&criPort, "port", "p", port, "Port to connect to (also $"+criPortEnvVar+")")
 	criStatusCmd.Flags().StringVarP(&criHost, "host", "H", "localhost", "Host to connect to")
 	criStatusCmd.Flags().BoolVarP(&criVerbose, "verbose", "v", false, "Show detailed status")
 }
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"servin/pkg/ide"
+	"servin/pkg/logger"
+	"servin/pkg/logs"
+	"servin/pkg/restart"
+	"servin/pkg/shim"
+	"servin/pkg/state"
+	"servin/pkg/stats"
+	"servin/pkg/telemetry"
+	"servin/pkg/volume"
+
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the Servin daemon",
+	Long: `Run the Servin daemon in the foreground.
+
+The daemon supervises containers started with a restart policy and restarts
+them when they exit, waiting with an exponential backoff (100ms doubling up to
+one minute) between attempts. The backoff is reset once a container stays up
+for at least 10 seconds.
+
+Restart policies:
+  no              Never restart the container (default)
+  always          Always restart; containers stopped with 'servin stop' are
+                  started again when the daemon starts
+  on-failure[:N]  Restart only on a non-zero exit code, at most N times
+  unless-stopped  Like always, but containers stopped with 'servin stop'
+                  stay stopped
+
+The daemon can also push container and runtime metrics to an observability
+stack. Exporters: statsd (UDP, default 127.0.0.1:8125) and otlp (OTLP/HTTP
+JSON, default http://localhost:4318). Image pull and build spans are exported
+through the same exporter when SERVIN_TELEMETRY_EXPORTER and
+SERVIN_TELEMETRY_ENDPOINT are set for the CLI.
+
+While running, the daemon also samples the resource usage of running
+containers and retains it for a short window, so it can be exported with
+'servin stats export'. Use --stats-retention 0 to disable it.
+
+Images on the pre-pull list in config.yaml (daemon.prefetch) are pulled in
+the background, one at a time and only within the off-peak window if one is
+set, so the first run of common base images does not wait for a pull. Their
+progress is shown by 'servin jobs ls'.
+
+Detached containers run under a shim process of their own, which owns the
+container process and writes its logs, so the daemon can be restarted or
+upgraded without stopping them. On start the daemon reconnects to the shims
+of running containers. Send it SIGHUP (systemctl reload servin) after
+replacing the servin binary to re-execute the new one in place.
+
+With --ide-listen, the daemon serves the endpoint IDE extensions use to wait
+for a container's lifecycle events and stream its logs. Bind it to a loopback
+address; the handshake is described in the CLI documentation.
+
+Examples:
+  servin daemon                        # Run the daemon
+  servin daemon --restart-interval 5s  # Check containers every 5 seconds
+  servin daemon --telemetry-exporter statsd --telemetry-endpoint 127.0.0.1:8125
+  servin daemon --telemetry-exporter otlp --telemetry-endpoint http://collector:4318
+  servin daemon --stats-retention 6h --stats-interval 10s
+  servin daemon --ide-listen 127.0.0.1:7475
+  servin run --restart=on-failure:3 alpine /bin/app`,
+	RunE: runDaemon,
+}
+
+var (
+	daemonRestartInterval time.Duration
+	daemonTelemetry       string
+	daemonTelemetryURL    string
+	daemonMetricsInterval time.Duration
+	daemonStatsInterval   time.Duration
+	daemonStatsRetention  time.Duration
+	daemonIDEListen       string
+)
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.Flags().DurationVar(&daemonRestartInterval, "restart-interval", time.Second, "How often to check containers for restart")
+	daemonCmd.Flags().StringVar(&daemonTelemetry, "telemetry-exporter", "", fmt.Sprintf("Metrics exporter (%s)", strings.Join(telemetry.Exporters(), ", ")))
+	daemonCmd.Flags().StringVar(&daemonTelemetryURL, "telemetry-endpoint", "", "Collector endpoint for the metrics exporter")
+	daemonCmd.Flags().DurationVar(&daemonMetricsInterval, "metrics-interval", 10*time.Second, "How often to push metrics")
+	daemonCmd.Flags().DurationVar(&daemonStatsInterval, "stats-interval", 5*time.Second, "How often to record container stats")
+	daemonCmd.Flags().DurationVar(&daemonStatsRetention, "stats-retention", time.Hour, "How long to retain recorded container stats (0 disables recording)")
+	daemonCmd.Flags().StringVar(&daemonIDEListen, "ide-listen", "", "Address to serve IDE clients on, e.g. 127.0.0.1:7475 (disabled when empty)")
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	if err := checkRootForContainerOps(); err != nil {
+		return err
+	}
+
+	if daemonRestartInterval <= 0 {
+		return fmt.Errorf("restart interval must be positive")
+	}
+	if daemonMetricsInterval <= 0 {
+		return fmt.Errorf("metrics interval must be positive")
+	}
+	if daemonStatsInterval <= 0 {
+		return fmt.Errorf("stats interval must be positive")
+	}
+	if daemonStatsRetention < 0 {
+		return fmt.Errorf("stats retention must not be negative")
+	}
+
+	prefetch, err := loadPrefetcher()
+	if err != nil {
+		return err
+	}
+
+	// The daemon supervises containers in every namespace
+	sm := state.NewStateManager().AllNamespaces()
+	supervisor := restart.NewSupervisor(sm, daemonRestartInterval)
+
+	// Flags override the telemetry configuration from the environment
+	if daemonTelemetry != "" {
+		if err := telemetry.Init(telemetry.Config{Exporter: daemonTelemetry, Endpoint: daemonTelemetryURL}); err != nil {
+			return err
+		}
+	}
+	defer telemetry.Shutdown()
+
+	var ideServer *ide.Server
+	if daemonIDEListen != "" {
+		ideServer = ide.NewServer(daemonIDEListen, sm, logs.Dir)
+		if err := ideServer.Listen(); err != nil {
+			return err
+		}
+	}
+
+	// Resolve the binary now: once an upgrade replaces it, /proc/self/exe
+	// names the deleted file
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the servin binary: %v", err)
+	}
+
+	reconnectShims()
+
+	// Volumes on tmpfs, loop images or network shares lose their mounts on reboot
+	if err := volume.NewManager().MountAll(); err != nil {
+		logger.Warn("%v", err)
+	}
+
+	// Setup graceful shutdown; SIGHUP re-executes the daemon
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	stop := make(chan struct{})
+	go supervisor.Run(stop)
+
+	if telemetry.Enabled() {
+		go pushMetrics(sm, daemonMetricsInterval, stop)
+	}
+	if daemonStatsRetention > 0 {
+		go recordStats(sm, daemonStatsInterval, daemonStatsRetention, stop)
+	}
+	if prefetch != nil {
+		go prefetch.run(stop)
+	}
+	if ideServer != nil {
+		go func() {
+			if err := ideServer.Serve(); err != nil {
+				logger.Warn("IDE endpoint stopped: %v", err)
+			}
+		}()
+		defer ideServer.Stop()
+	}
+
+	fmt.Println("Servin daemon started")
+	fmt.Println("Press Ctrl+C to stop the daemon...")
+
+	sig := <-sigChan
+	if sig == syscall.SIGHUP {
+		fmt.Println("\nRe-executing Servin daemon; containers keep running...")
+	} else {
+		fmt.Println("\nShutting down Servin daemon...")
+	}
+	close(stop)
+
+	// Listeners are closed on exec; IDE clients reconnect to the new daemon
+	if sig == syscall.SIGHUP {
+		telemetry.Shutdown()
+		return reexec(executable)
+	}
+	return nil
+}
+
+// reconnectShims attaches to the shims of containers that kept running while
+// no daemon was, logging their exits. Restart policies apply to those exits
+// through the container state the shims record.
+func reconnectShims() {
+	shims, err := shim.List()
+	if err != nil {
+		logger.Warn("Failed to list container shims: %v", err)
+		return
+	}
+
+	for _, s := range shims {
+		if !s.Running {
+			continue
+		}
+		logger.Info("Reconnected to container %s (shim pid %d, container pid %d)",
+			s.ContainerID[:12], s.ShimPID, s.ContainerPID)
+		go func(s *shim.Status) {
+			final, err := shim.Wait(s.ContainerID)
+			if err != nil {
+				logger.Warn("Lost connection to the shim of container %s: %v", s.ContainerID[:12], err)
+			} else if final.Error != "" {
+				logger.Info("Container %s exited: %s", s.ContainerID[:12], final.Error)
+			} else {
+				logger.Info("Container %s exited", s.ContainerID[:12])
+			}
+			shim.Reap(s.ShimPID)
+		}(s)
+	}
+}
+
+// pushMetrics periodically collects container metrics and sends them to the exporter
+func pushMetrics(sm *state.StateManager, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			metrics, err := telemetry.CollectContainerMetrics(sm)
+			if err != nil {
+				logger.Warn("Failed to collect container metrics: %v", err)
+				continue
+			}
+			telemetry.RecordMetrics(metrics)
+		}
+	}
+}
+
+// recordStats periodically samples running containers and retains the
+// samples for 'servin stats export'
+func recordStats(sm *state.StateManager, interval, retention time.Duration, stop <-chan struct{}) {
+	source := newStatsSource()
+	recorder := stats.NewRecorder(interval, retention)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			all, err := sm.ListContainers()
+			if err != nil {
+				logger.Warn("Failed to list containers for stats: %v", err)
+				continue
+			}
+
+			// Keep the history of stopped containers until they are removed
+			known := make(map[string]bool, len(all))
+			var running []*state.ContainerState
+			for _, c := range all {
+				known[c.ID] = true
+				if c.Status == state.StatusRunning {
+					running = append(running, c)
+				}
+			}
+			recorder.Forget(known)
+
+			if len(running) == 0 {
+				continue
+			}
+			if err := recorder.Record(source.collect(running)); err != nil {
+				logger.Warn("Failed to record container stats: %v", err)
+			}
+		}
+	}
+}
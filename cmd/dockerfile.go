@@ -0,0 +1,476 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isDockerfile reports whether a build file is read with Dockerfile
+// semantics: Dockerfile, Dockerfile.<name> or <name>.Dockerfile
+func isDockerfile(file string) bool {
+	name := strings.ToLower(filepath.Base(file))
+	return name == "dockerfile" || strings.HasPrefix(name, "dockerfile.") || strings.HasSuffix(name, ".dockerfile")
+}
+
+// dockerfileExpanded lists the instructions whose arguments a Dockerfile
+// expands build arguments and environment variables in. RUN, CMD and
+// ENTRYPOINT are left to the shell.
+var dockerfileExpanded = map[string]bool{
+	"ADD": true, "ARG": true, "COPY": true, "ENV": true, "EXPOSE": true, "FROM": true,
+	"LABEL": true, "STOPSIGNAL": true, "USER": true, "VOLUME": true, "WORKDIR": true,
+}
+
+// parseDockerfile reads a Dockerfile into build steps. An instruction
+// continues on the next line after the escape character, comment lines
+// inside it are dropped, and the escape parser directive is honoured. It
+// returns the escape character for expanding the steps later.
+func parseDockerfile(path string) ([]BuildStep, byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	escape := byte('\\')
+	directives := true
+	var steps []BuildStep
+	var pending strings.Builder
+	lineNum, startLine := 0, 0
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+
+		// Parser directives (# escape=`) may only precede everything else
+		if directives {
+			if key, value, ok := parseDirective(trimmed); ok {
+				if key == "escape" {
+					if value != "\\" && value != "`" {
+						return nil, 0, fmt.Errorf("line %d: invalid escape character %q", lineNum, value)
+					}
+					escape = value[0]
+				}
+				continue
+			}
+			directives = false
+		}
+
+		if strings.HasPrefix(trimmed, "#") || (trimmed == "" && pending.Len() > 0) {
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+		if pending.Len() == 0 {
+			startLine = lineNum
+		}
+
+		if line[len(line)-1] == escape {
+			pending.WriteString(strings.TrimLeft(line[:len(line)-1], " \t"))
+			continue
+		}
+		pending.WriteString(strings.TrimLeft(line, " \t"))
+
+		step, err := parseDockerfileLine(pending.String())
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", startLine, err)
+		}
+		steps = append(steps, step)
+		pending.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading Dockerfile at line %d: %v", lineNum, err)
+	}
+	if pending.Len() > 0 {
+		step, err := parseDockerfileLine(pending.String())
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", startLine, err)
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, escape, nil
+}
+
+// parseDirective parses a "# key=value" parser directive
+func parseDirective(line string) (string, string, bool) {
+	if !strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(strings.TrimSpace(line[1:]), "=")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !ok || (key != "escape" && key != "syntax" && key != "check") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// parseDockerfileLine splits one instruction, with its continuation lines
+// joined, into a build step
+func parseDockerfileLine(line string) (BuildStep, error) {
+	parts := strings.Fields(line)
+	instruction := strings.ToUpper(parts[0])
+	if strings.Contains(line, "<<") && (instruction == "RUN" || instruction == "COPY" || instruction == "ADD") {
+		if rest := strings.TrimSpace(line[len(parts[0]):]); strings.HasPrefix(rest, "<<") || strings.Contains(rest, " <<") {
+			return BuildStep{}, fmt.Errorf("here-documents are not supported in %s", instruction)
+		}
+	}
+	return BuildStep{
+		Instruction: instruction,
+		Arguments:   parts[1:],
+		RawLine:     instruction + " " + strings.TrimSpace(line[len(parts[0]):]),
+	}, nil
+}
+
+// instructionArgs returns the text of a step after its instruction
+func instructionArgs(step BuildStep) string {
+	return strings.TrimSpace(step.RawLine[len(step.Instruction):])
+}
+
+// parseJSONForm parses the exec form of an instruction, a JSON array of
+// strings, reporting false for the shell form
+func parseJSONForm(args string) ([]string, bool) {
+	if !strings.HasPrefix(args, "[") {
+		return nil, false
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(args), &list); err != nil {
+		return nil, false
+	}
+	return list, true
+}
+
+// dockerfileScope holds the build arguments a Dockerfile declares with ARG.
+// Arguments declared before FROM are only visible to FROM, unless a stage
+// declares them again without a default.
+type dockerfileScope struct {
+	escape byte
+
+	// buildArgs are the --build-arg values, which override ARG defaults
+	buildArgs map[string]string
+	// consumed marks the --build-arg values some ARG declared
+	consumed map[string]bool
+
+	global    map[string]string
+	args      map[string]string
+	argNames  []string
+	fromFound bool
+
+	// cmdSet records a CMD in this build, which a later ENTRYPOINT keeps
+	cmdSet bool
+}
+
+// newDockerfileScope creates the scope for one Dockerfile build
+func newDockerfileScope(escape byte, buildArgs map[string]string) *dockerfileScope {
+	return &dockerfileScope{
+		escape:    escape,
+		buildArgs: buildArgs,
+		consumed:  make(map[string]bool),
+		global:    make(map[string]string),
+		args:      make(map[string]string),
+	}
+}
+
+// declare processes an ARG instruction, whose arguments have already been
+// expanded, and returns the resolved "name=value" pairs
+func (s *dockerfileScope) declare(step BuildStep) ([]string, error) {
+	if len(step.Arguments) == 0 {
+		return nil, fmt.Errorf("ARG instruction requires an argument")
+	}
+
+	var resolved []string
+	for _, arg := range step.Arguments {
+		name, value, hasDefault := strings.Cut(arg, "=")
+		if name == "" {
+			return nil, fmt.Errorf("invalid ARG '%s'", arg)
+		}
+		if override, ok := s.buildArgs[name]; ok {
+			value = override
+			s.consumed[name] = true
+		} else if !hasDefault && s.fromFound {
+			value = s.global[name]
+		}
+
+		if !s.fromFound {
+			s.global[name] = value
+		} else {
+			if _, ok := s.args[name]; !ok {
+				s.argNames = append(s.argNames, name)
+			}
+			s.args[name] = value
+		}
+		resolved = append(resolved, name+"="+value)
+	}
+	return resolved, nil
+}
+
+// from starts a build stage, in which only re-declared arguments are set
+func (s *dockerfileScope) from() {
+	s.fromFound = true
+	s.args = make(map[string]string)
+	s.argNames = nil
+}
+
+// stage returns the scope of one build stage, which sees the global
+// arguments and declares its own. Stages build concurrently, so each
+// records the arguments it consumed separately.
+func (s *dockerfileScope) stage() *dockerfileScope {
+	stage := newDockerfileScope(s.escape, s.buildArgs)
+	stage.global = s.global
+	stage.from()
+	return stage
+}
+
+// runEnv returns the stage's build arguments, which RUN sees as
+// environment variables
+func (s *dockerfileScope) runEnv() []string {
+	env := make([]string, 0, len(s.argNames))
+	for _, name := range s.argNames {
+		env = append(env, name+"="+s.args[name])
+	}
+	return env
+}
+
+// unconsumed returns the --build-arg names no ARG declared
+func (s *dockerfileScope) unconsumed() []string {
+	var names []string
+	for name := range s.buildArgs {
+		if !s.consumed[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// expand replaces the arguments of a step with its words after quote
+// removal and variable expansion. Variables are the image's ENV values,
+// then the build arguments in scope.
+func (s *dockerfileScope) expand(step BuildStep, env []string) (BuildStep, error) {
+	if !dockerfileExpanded[step.Instruction] {
+		return step, nil
+	}
+
+	values := make(map[string]string)
+	vars := s.args
+	if !s.fromFound || step.Instruction == "FROM" {
+		vars = s.global
+	}
+	for name, value := range vars {
+		values[name] = value
+	}
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		values[name] = value
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+
+	args := instructionArgs(step)
+	var words []string
+	if list, ok := parseJSONForm(args); ok && (step.Instruction == "COPY" || step.Instruction == "ADD" || step.Instruction == "VOLUME") {
+		for _, item := range list {
+			word, err := expandWord(item, s.escape, lookup)
+			if err != nil {
+				return step, err
+			}
+			words = append(words, word)
+		}
+	} else {
+		var err error
+		if words, err = splitWords(args, s.escape, lookup); err != nil {
+			return step, fmt.Errorf("%s: %v", step.Instruction, err)
+		}
+	}
+
+	step.Arguments = words
+	return step, nil
+}
+
+// expandWord expands the variables in a single word that is not split
+func expandWord(word string, escape byte, lookup func(string) (string, bool)) (string, error) {
+	lex := &wordLexer{input: word, escape: escape, lookup: lookup, noSplit: true}
+	words, err := lex.words()
+	if err != nil || len(words) == 0 {
+		return "", err
+	}
+	return words[0], nil
+}
+
+// splitWords splits instruction arguments into words like a shell would:
+// whitespace separates words, quotes group them, the escape character
+// quotes the next character, and $NAME, ${NAME}, ${NAME:-word} and
+// ${NAME:+word} are expanded outside single quotes
+func splitWords(input string, escape byte, lookup func(string) (string, bool)) ([]string, error) {
+	lex := &wordLexer{input: input, escape: escape, lookup: lookup}
+	return lex.words()
+}
+
+// wordLexer scans Dockerfile arguments into words
+type wordLexer struct {
+	input   string
+	pos     int
+	escape  byte
+	lookup  func(string) (string, bool)
+	noSplit bool
+}
+
+func (l *wordLexer) words() ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case (c == ' ' || c == '\t') && !l.noSplit:
+			l.pos++
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == l.escape:
+			l.pos++
+			if l.pos < len(l.input) {
+				word.WriteByte(l.input[l.pos])
+				l.pos++
+			}
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(l.input[l.pos+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			word.WriteString(l.input[l.pos+1 : l.pos+1+end])
+			l.pos += end + 2
+			inWord = true
+		case c == '"':
+			l.pos++
+			if err := l.doubleQuoted(&word); err != nil {
+				return nil, err
+			}
+			inWord = true
+		case c == '$':
+			value, err := l.variable()
+			if err != nil {
+				return nil, err
+			}
+			word.WriteString(value)
+			inWord = true
+		default:
+			word.WriteByte(c)
+			l.pos++
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// doubleQuoted scans up to the closing double quote; the escape character
+// only quotes ", $ and itself there
+func (l *wordLexer) doubleQuoted(word *strings.Builder) error {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return nil
+		case c == l.escape && l.pos+1 < len(l.input) && strings.IndexByte("\"$"+string(l.escape), l.input[l.pos+1]) >= 0:
+			word.WriteByte(l.input[l.pos+1])
+			l.pos += 2
+		case c == '$':
+			value, err := l.variable()
+			if err != nil {
+				return err
+			}
+			word.WriteString(value)
+		default:
+			word.WriteByte(c)
+			l.pos++
+		}
+	}
+	return fmt.Errorf("unterminated double quote")
+}
+
+// variable expands the variable reference at the current position
+func (l *wordLexer) variable() (string, error) {
+	l.pos++ // $
+	if l.pos >= len(l.input) {
+		return "$", nil
+	}
+
+	if l.input[l.pos] != '{' {
+		start := l.pos
+		for l.pos < len(l.input) && isNameChar(l.input[l.pos], l.pos == start) {
+			l.pos++
+		}
+		if l.pos == start {
+			return "$", nil
+		}
+		value, _ := l.lookup(l.input[start:l.pos])
+		return value, nil
+	}
+
+	end := strings.IndexByte(l.input[l.pos:], '}')
+	if end < 0 {
+		return "", fmt.Errorf("missing '}' in variable reference")
+	}
+	expr := l.input[l.pos+1 : l.pos+end]
+	l.pos += end + 1
+
+	name := expr
+	for i := 0; i < len(expr); i++ {
+		if !isNameChar(expr[i], i == 0) {
+			name = expr[:i]
+			break
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("invalid variable reference '${%s}'", expr)
+	}
+	value, set := l.lookup(name)
+
+	modifier := expr[len(name):]
+	switch {
+	case modifier == "":
+		return value, nil
+	case strings.HasPrefix(modifier, ":-"):
+		if value == "" {
+			return expandWord(modifier[2:], l.escape, l.lookup)
+		}
+		return value, nil
+	case strings.HasPrefix(modifier, "-"):
+		if !set {
+			return expandWord(modifier[1:], l.escape, l.lookup)
+		}
+		return value, nil
+	case strings.HasPrefix(modifier, ":+"):
+		if value != "" {
+			return expandWord(modifier[2:], l.escape, l.lookup)
+		}
+		return "", nil
+	case strings.HasPrefix(modifier, "+"):
+		if set {
+			return expandWord(modifier[1:], l.escape, l.lookup)
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported modifier in '${%s}'", expr)
+}
+
+// isNameChar reports whether c can appear in a variable name
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
@@ -5,9 +5,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"servin/pkg/container"
+	"servin/pkg/errors"
 	"servin/pkg/state"
+	"servin/pkg/terminal"
 
 	"github.com/spf13/cobra"
 )
@@ -49,11 +53,22 @@ func execInContainer(cmd *cobra.Command, args []string) error {
 		// Try as ID
 		_, err = sm.LoadContainer(containerIDOrName)
 		if err != nil {
-			return fmt.Errorf("container not found: %s", containerIDOrName)
+			return errors.WrapError(err, errors.ErrTypeNotFound, "exec", fmt.Sprintf("container not found: %s", containerIDOrName))
 		}
 		containerID = containerIDOrName
 	}
 
+	// Get flags
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	tty, _ := cmd.Flags().GetBool("tty")
+
+	// Containers on macOS and Windows run inside the VM, where Servin executes the command itself
+	if runtime.GOOS != "linux" {
+		if vmManager, err := container.NewVMContainerManager(); err == nil && vmManager.IsEnabled() {
+			return vmManager.VMContainerExec(containerID, args[1:], interactive, tty)
+		}
+	}
+
 	// Check if container exists and get its rootfs path
 	rootfsPath, err := getContainerRootFS(containerID)
 	if err != nil {
@@ -62,10 +77,6 @@ func execInContainer(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Executing '%s %v' in container %s...\n", command, commandArgs, containerID)
 
-	// Get flags
-	interactive, _ := cmd.Flags().GetBool("interactive")
-	tty, _ := cmd.Flags().GetBool("tty")
-
 	// For macOS, execute the command in a chroot-like environment
 	// since full namespace isolation requires Linux
 	return executeInContainerRootfs(rootfsPath, command, commandArgs, interactive, tty)
@@ -92,6 +103,11 @@ func executeInContainerRootfs(rootfsPath, command string, args []string, interac
 		filepath.Join(rootfsPath, "usr/sbin", command),
 	}
 
+	// A command given as a path, such as /bin/sh, is looked up as it is
+	if strings.Contains(command, "/") {
+		possiblePaths = []string{filepath.Join(rootfsPath, command)}
+	}
+
 	// Try to find the command in the container's filesystem
 	for _, path := range possiblePaths {
 		if _, err := os.Stat(path); err == nil {
@@ -105,7 +121,7 @@ func executeInContainerRootfs(rootfsPath, command string, args []string, interac
 		// Check if command exists on host
 		hostCmd, err := exec.LookPath(command)
 		if err != nil {
-			return fmt.Errorf("command '%s' not found in container or host", command)
+			return errors.NewNotFoundError("exec", fmt.Sprintf("command '%s' not found in container or host", command))
 		}
 
 		// For simple commands like ls, cat, etc., we can run them with the rootfs as working directory
@@ -113,7 +129,7 @@ func executeInContainerRootfs(rootfsPath, command string, args []string, interac
 			return executeSimpleCommand(rootfsPath, hostCmd, args, interactive, tty)
 		}
 
-		return fmt.Errorf("command '%s' not found in container filesystem", command)
+		return errors.NewNotFoundError("exec", fmt.Sprintf("command '%s' not found in container filesystem", command))
 	}
 
 	// Execute the command found in container
@@ -122,17 +138,34 @@ func executeInContainerRootfs(rootfsPath, command string, args []string, interac
 	// Set working directory to container root
 	execCmd.Dir = rootfsPath
 
-	// Set up I/O
-	if interactive {
-		execCmd.Stdin = os.Stdin
-	}
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-
 	// Set environment variables from container
 	execCmd.Env = buildContainerEnv(rootfsPath)
 
-	return execCmd.Run()
+	return runExecCommand(execCmd, interactive, tty)
+}
+
+// runExecCommand runs an exec'd command on servin's stdio or, with tty, on
+// a pseudo-terminal that follows resizes of servin's terminal
+func runExecCommand(execCmd *exec.Cmd, interactive, tty bool) error {
+	if !tty {
+		if interactive {
+			execCmd.Stdin = os.Stdin
+		}
+		execCmd.Stdout = os.Stdout
+		execCmd.Stderr = os.Stderr
+		return execCmd.Run()
+	}
+
+	master, err := terminal.StartPTY(execCmd)
+	if err != nil {
+		return fmt.Errorf("failed to allocate a TTY: %v", err)
+	}
+	defer master.Close()
+
+	detach := terminal.Connect(master, interactive, os.Stdout)
+	err = execCmd.Wait()
+	detach()
+	return err
 }
 
 // executeInSimulatedContainer executes commands in a simulated container environment for testing
@@ -142,7 +175,7 @@ func executeInSimulatedContainer(command string, args []string, interactive, tty
 	// Check if command exists on host
 	hostCmd, err := exec.LookPath(command)
 	if err != nil {
-		return fmt.Errorf("command '%s' not found", command)
+		return errors.NewNotFoundError("exec", fmt.Sprintf("command '%s' not found", command))
 	}
 
 	// For demonstration, create a simple simulated environment
@@ -193,21 +226,14 @@ func executeInSimulatedContainer(command string, args []string, interactive, tty
 			}
 			return execCmd.Run()
 		}
-		return fmt.Errorf("file not found: %s", strings.Join(args, " "))
+		return errors.NewNotFoundError("exec", fmt.Sprintf("file not found: %s", strings.Join(args, " ")))
 
 	default:
 		// For other commands, execute them normally
 		execCmd := exec.Command(hostCmd, args...)
-
-		if interactive {
-			execCmd.Stdin = os.Stdin
-		}
-		execCmd.Stdout = os.Stdout
-		execCmd.Stderr = os.Stderr
-
 		execCmd.Env = buildContainerEnv("/")
 
-		return execCmd.Run()
+		return runExecCommand(execCmd, interactive, tty)
 	}
 }
 
@@ -227,13 +253,7 @@ func executeSimpleCommand(rootfsPath, hostCmd string, args []string, interactive
 	execCmd := exec.Command(hostCmd, adjustedArgs...)
 	execCmd.Dir = rootfsPath
 
-	if interactive {
-		execCmd.Stdin = os.Stdin
-	}
-	execCmd.Stdout = os.Stdout
-	execCmd.Stderr = os.Stderr
-
-	return execCmd.Run()
+	return runExecCommand(execCmd, interactive, tty)
 }
 
 // isSimpleCommand checks if a command is a simple filesystem operation
@@ -2,22 +2,12 @@ package cmd
 
 import (
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-var cpCmd = &cobra.Command{
-	Use:   "cp SOURCE_CONTAINER:SOURCE_PATH DEST_CONTAINER:DEST_PATH",
-	Short: "Copy files between containers or between host and container",
-	Long:  "Copy files/directories between containers or between host filesystem and container",
-	Args:  cobra.ExactArgs(2),
-	RunE:  copyFiles,
-}
-
 var mvCmd = &cobra.Command{
 	Use:   "mv CONTAINER OLD_PATH NEW_PATH",
 	Short: "Move/rename files in container",
@@ -59,7 +49,6 @@ var chmodCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.AddCommand(cpCmd)
 	rootCmd.AddCommand(mvCmd)
 	rootCmd.AddCommand(mkdirCmd)
 	rootCmd.AddCommand(rmdirCmd)
@@ -67,41 +56,11 @@ func init() {
 	rootCmd.AddCommand(chmodCmd)
 
 	// Add flags
-	cpCmd.Flags().BoolP("recursive", "r", false, "Copy directories recursively")
 	mkdirCmd.Flags().BoolP("parents", "p", false, "Create parent directories as needed")
 	rmCmd.Flags().BoolP("recursive", "r", false, "Remove directories recursively")
 	rmCmd.Flags().BoolP("force", "f", false, "Force removal without prompting")
 }
 
-func copyFiles(cmd *cobra.Command, args []string) error {
-	if err := checkRoot(); err != nil {
-		return err
-	}
-
-	source := args[0]
-	dest := args[1]
-
-	// Parse source and destination
-	sourceContainer, sourcePath := parseContainerPath(source)
-	destContainer, destPath := parseContainerPath(dest)
-
-	recursive, _ := cmd.Flags().GetBool("recursive")
-
-	// Handle different copy scenarios
-	if sourceContainer != "" && destContainer != "" {
-		// Container to container copy
-		return copyContainerToContainer(sourceContainer, sourcePath, destContainer, destPath, recursive)
-	} else if sourceContainer != "" {
-		// Container to host copy
-		return copyContainerToHost(sourceContainer, sourcePath, destPath, recursive)
-	} else if destContainer != "" {
-		// Host to container copy
-		return copyHostToContainer(sourcePath, destContainer, destPath, recursive)
-	} else {
-		return fmt.Errorf("invalid copy arguments - at least one path must specify a container")
-	}
-}
-
 func moveFiles(cmd *cobra.Command, args []string) error {
 	if err := checkRoot(); err != nil {
 		return err
@@ -295,136 +254,3 @@ func changeMode(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Changed mode of %s to %s in container %s\n", filePath, modeStr, containerID[:12])
 	return nil
 }
-
-// Helper functions
-
-func parseContainerPath(path string) (container, filePath string) {
-	parts := strings.SplitN(path, ":", 2)
-	if len(parts) == 2 {
-		return parts[0], parts[1]
-	}
-	return "", path
-}
-
-func copyContainerToContainer(sourceContainer, sourcePath, destContainer, destPath string, recursive bool) error {
-	// Get source container rootfs
-	sourceRootfs, err := getContainerRootFS(sourceContainer)
-	if err != nil {
-		return fmt.Errorf("source container error: %v", err)
-	}
-
-	// Get destination container rootfs
-	destRootfs, err := getContainerRootFS(destContainer)
-	if err != nil {
-		return fmt.Errorf("destination container error: %v", err)
-	}
-
-	sourceFullPath := filepath.Join(sourceRootfs, sourcePath)
-	destFullPath := filepath.Join(destRootfs, destPath)
-
-	return copyPath(sourceFullPath, destFullPath, recursive)
-}
-
-func copyContainerToHost(containerID, containerPath, hostPath string, recursive bool) error {
-	// Get container rootfs
-	rootfsPath, err := getContainerRootFS(containerID)
-	if err != nil {
-		return err
-	}
-
-	sourceFullPath := filepath.Join(rootfsPath, containerPath)
-	return copyPath(sourceFullPath, hostPath, recursive)
-}
-
-func copyHostToContainer(hostPath, containerID, containerPath string, recursive bool) error {
-	// Get container rootfs
-	rootfsPath, err := getContainerRootFS(containerID)
-	if err != nil {
-		return err
-	}
-
-	destFullPath := filepath.Join(rootfsPath, containerPath)
-	return copyPath(hostPath, destFullPath, recursive)
-}
-
-func copyPath(src, dst string, recursive bool) error {
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return fmt.Errorf("source path error: %v", err)
-	}
-
-	if srcInfo.IsDir() {
-		if !recursive {
-			return fmt.Errorf("source is a directory, use -r flag for recursive copy")
-		}
-		return copyDir(src, dst)
-	}
-
-	return copyFile(src, dst)
-}
-
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	// Create destination directory if needed
-	destDir := filepath.Dir(dst)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return err
-	}
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
-	}
-
-	// Copy file permissions
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return err
-	}
-	return os.Chmod(dst, srcInfo.Mode())
-}
-
-func copyDir(src, dst string) error {
-	srcInfo, err := os.Stat(src)
-	if err != nil {
-		return err
-	}
-
-	// Create destination directory
-	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
-		return err
-	}
-
-	entries, err := os.ReadDir(src)
-	if err != nil {
-		return err
-	}
-
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
-
-		if entry.IsDir() {
-			if err := copyDir(srcPath, dstPath); err != nil {
-				return err
-			}
-		} else {
-			if err := copyFile(srcPath, dstPath); err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
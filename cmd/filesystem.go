@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"servin/pkg/errors"
 	"servin/pkg/state"
 	"servin/pkg/vfs"
 
@@ -17,9 +21,13 @@ import (
 var lsCmd = &cobra.Command{
 	Use:   "fs-ls [FLAGS] CONTAINER [PATH]",
 	Short: "List files and directories in a container",
-	Long:  "List files and directories in the specified container filesystem path",
-	Args:  cobra.MinimumNArgs(1),
-	RunE:  listFiles,
+	Long: `List files and directories in the specified container filesystem path.
+
+With --format json the entries are printed as one object, directories first,
+with the total number of entries so that large directories can be read a
+page at a time with --offset and --limit.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: listFiles,
 }
 
 var catCmd = &cobra.Command{
@@ -66,6 +74,9 @@ func init() {
 	lsCmd.Flags().BoolP("all", "a", false, "Show hidden files")
 	lsCmd.Flags().Bool("human", false, "Human readable sizes") // Remove -h shorthand to avoid conflict
 	lsCmd.Flags().BoolP("recursive", "R", false, "List subdirectories recursively")
+	lsCmd.Flags().String("format", "table", "Output format (table, json)")
+	lsCmd.Flags().Int("offset", 0, "Skip this many entries (json format)")
+	lsCmd.Flags().Int("limit", 0, "List at most this many entries, 0 for all (json format)")
 
 	// Add flags for find command
 	findCmd.Flags().StringP("name", "n", "", "Find files by name pattern")
@@ -74,6 +85,15 @@ func init() {
 }
 
 func listFiles(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	offset, _ := cmd.Flags().GetInt("offset")
+	limit, _ := cmd.Flags().GetInt("limit")
+	if format != "table" && format != "json" {
+		return errors.NewValidationError("fs-ls", fmt.Sprintf("unknown format '%s' (expected table or json)", format))
+	}
+	if offset < 0 || limit < 0 {
+		return errors.NewValidationError("fs-ls", "--offset and --limit cannot be negative")
+	}
 	if err := checkRoot(); err != nil {
 		return err
 	}
@@ -101,6 +121,9 @@ func listFiles(cmd *cobra.Command, args []string) error {
 	humanReadable, _ := cmd.Flags().GetBool("human")
 	recursive, _ := cmd.Flags().GetBool("recursive")
 
+	if format == "json" {
+		return listDirectoryJSON(vfsManager, containerID, path, showAll, offset, limit)
+	}
 	return listDirectoryVFS(vfsManager, containerID, path, longFormat, showAll, humanReadable, recursive)
 }
 
@@ -132,13 +155,10 @@ func displayFile(cmd *cobra.Command, args []string) error {
 	}
 	defer reader.Close()
 
-	// Copy contents to stdout
-	content, err := io.ReadAll(reader)
-	if err != nil {
+	// Stream contents to stdout, which may be a binary download
+	if _, err := io.Copy(os.Stdout, reader); err != nil {
 		return fmt.Errorf("failed to read file contents: %v", err)
 	}
-
-	fmt.Print(string(content))
 	return nil
 }
 
@@ -233,7 +253,7 @@ func getContainerRootFS(containerID string) (string, error) {
 	// Load container state
 	container, err := sm.LoadContainer(containerID)
 	if err != nil {
-		return "", fmt.Errorf("container not found: %s", containerID)
+		return "", errors.WrapError(err, errors.ErrTypeNotFound, "container", fmt.Sprintf("container not found: %s", containerID))
 	}
 
 	// Try different possible rootfs paths
@@ -447,3 +467,78 @@ func listDirectoryVFS(vfsManager *vfs.VFSManager, containerID, path string, long
 
 	return nil
 }
+
+// fsListing is the output of fs-ls --format json: a page of a directory's
+// entries and how many there are in all
+type fsListing struct {
+	Path    string           `json:"path"`
+	Total   int              `json:"total"`
+	Offset  int              `json:"offset"`
+	Entries []fsListingEntry `json:"entries"`
+}
+
+type fsListingEntry struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Size        int64     `json:"size"`
+	Mode        string    `json:"mode"`
+	Permissions string    `json:"permissions"`
+	Modified    time.Time `json:"modified"`
+}
+
+// List a page of a directory as JSON, directories first, for the GUI file
+// browser
+func listDirectoryJSON(vfsManager *vfs.VFSManager, containerID, path string, showAll bool, offset, limit int) error {
+	files, err := vfsManager.GetVFS().List(containerID, path)
+	if err != nil {
+		return fmt.Errorf("failed to list directory: %v", err)
+	}
+
+	entries := []fsListingEntry{}
+	for _, file := range files {
+		if !showAll && strings.HasPrefix(file.Name, ".") {
+			continue
+		}
+		entries = append(entries, fsListingEntry{
+			Name:        file.Name,
+			Type:        fileTypeName(file.Mode),
+			Size:        file.Size,
+			Mode:        fmt.Sprintf("%03o", file.Mode.Perm()),
+			Permissions: file.Permissions,
+			Modified:    file.ModTime,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Type == "directory" && entries[j].Type != "directory"
+	})
+
+	listing := fsListing{Path: path, Total: len(entries), Offset: offset}
+	if offset > len(entries) {
+		offset = len(entries)
+	}
+	end := len(entries)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	listing.Entries = entries[offset:end]
+
+	data, err := json.MarshalIndent(listing, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode listing: %v", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// fileTypeName names the type of a file for listings
+func fileTypeName(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsRegular():
+		return "file"
+	}
+	return "other"
+}
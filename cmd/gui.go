@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"runtime"
 
+	"servin/pkg/errors"
+
 	"github.com/spf13/cobra"
 )
 
@@ -28,15 +30,17 @@ CRI server, volumes, and registry operations. It includes:
 Examples:
   servin gui                    # Launch GUI
   servin gui --tui              # Launch Terminal UI instead
-  servin gui --dev              # Launch in development mode`,
+  servin gui --dev              # Launch in development mode
+  servin gui --read-only        # Launch with all mutating actions disabled`,
 	RunE: runGUI,
 }
 
 var (
-	useTUI  bool
-	devMode bool
-	guiPort int
-	guiHost string
+	useTUI      bool
+	devMode     bool
+	guiPort     int
+	guiHost     string
+	guiReadOnly bool
 )
 
 func init() {
@@ -46,6 +50,7 @@ func init() {
 	guiCmd.Flags().BoolVar(&devMode, "dev", false, "Launch in development mode")
 	guiCmd.Flags().IntVar(&guiPort, "port", 8081, "Port for GUI web interface")
 	guiCmd.Flags().StringVar(&guiHost, "host", "localhost", "Host for GUI web interface")
+	guiCmd.Flags().BoolVar(&guiReadOnly, "read-only", false, "Disable all mutating actions (for demos, kiosks and teaching)")
 }
 
 func runGUI(cmd *cobra.Command, args []string) error {
@@ -102,7 +107,7 @@ func runWebViewGUI() error {
 
 	// Check if GUI executable exists
 	if _, err := os.Stat(guiPath); os.IsNotExist(err) {
-		return fmt.Errorf("GUI executable not found at %s", guiPath)
+		return errors.NewNotFoundError("gui", fmt.Sprintf("GUI executable not found at %s", guiPath))
 	}
 
 	// Launch the GUI executable
@@ -115,6 +120,9 @@ func runWebViewGUI() error {
 	// Set environment variables
 	cmd.Env = append(os.Environ(), fmt.Sprintf("SERVIN_GUI_PORT=%d", guiPort))
 	cmd.Env = append(cmd.Env, fmt.Sprintf("SERVIN_GUI_HOST=%s", guiHost))
+	if guiReadOnly {
+		cmd.Env = append(cmd.Env, "SERVIN_GUI_READ_ONLY=1")
+	}
 
 	return cmd.Start()
 }
@@ -136,7 +144,7 @@ func runTUI() error {
 
 	// Check if TUI executable exists
 	if _, err := os.Stat(tuiPath); os.IsNotExist(err) {
-		return fmt.Errorf("TUI executable not found at %s", tuiPath)
+		return errors.NewNotFoundError("tui", fmt.Sprintf("TUI executable not found at %s", tuiPath))
 	}
 
 	// Launch the TUI
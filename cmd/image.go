@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"servin/pkg/errors"
 	"servin/pkg/image"
+	"servin/pkg/state"
 
 	"github.com/spf13/cobra"
 )
@@ -19,12 +24,30 @@ var imageCmd = &cobra.Command{
 }
 
 var imageLsCmd = &cobra.Command{
-	Use:     "ls",
+	Use:     "ls [OPTIONS]",
 	Aliases: []string{"list"},
 	Short:   "List images",
-	RunE:    runImageList,
+	Long: `List images with when a container was last created or started from them.
+
+With --unused, only images no container uses are listed, with the space
+removing them would reclaim; --since narrows this to images not used since a
+time, given as a duration ago (e.g. 30d, 72h) or an RFC 3339 timestamp. Images
+no container has used yet count from when they were created.
+
+Examples:
+  servin image ls
+  servin image ls --unused --since 30d
+  servin image ls --format json`,
+	Args: cobra.NoArgs,
+	RunE: runImageList,
 }
 
+var (
+	imageLsUnused bool
+	imageLsSince  string
+	imageLsFormat string
+)
+
 var imageImportCmd = &cobra.Command{
 	Use:   "import TARBALL NAME:TAG",
 	Short: "Import an image from a tarball",
@@ -42,19 +65,179 @@ var imageRmCmd = &cobra.Command{
 	Use:     "rm IMAGE [IMAGE...]",
 	Aliases: []string{"remove"},
 	Short:   "Remove one or more images",
-	Args:    cobra.MinimumNArgs(1),
-	RunE:    runImageRemove,
+	Long: `Remove one or more images, with all their tags.
+
+An image labelled protected is only removed with --override-protection, which
+is recorded in the audit trail. An image a container was created from, or one
+with tags when daemon.images.immutable_tags is set in config.yaml, needs
+--force.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runImageRemove,
+}
+
+var imagePruneCmd = &cobra.Command{
+	Use:   "prune [OPTIONS]",
+	Short: "Remove unused images",
+	Long: `Remove dangling images (images without a tag), or with -a every image no
+container was created from, then the layers and blobs no image uses.
+
+Filters:
+  until=<time>          Only remove images created before a time, given as a
+                        duration ago (e.g. 24h, 30d) or an RFC 3339 timestamp
+  unused-since=<time>   Only remove images no container was created or
+                        started from since a time (see 'servin image ls --unused')
+
+Examples:
+  servin image prune
+  servin image prune -a --filter until=720h
+  servin image prune -a --filter unused-since=90d
+  servin image prune -a --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runImagePrune,
 }
 
 var imagePullCmd = &cobra.Command{
 	Use:   "pull IMAGE",
 	Short: "Pull an image from a registry",
 	Long: `Pull an image from a container registry.
-This is a placeholder for future registry support.`,
+
+For multi-platform images, the image for --platform is pulled. By default
+this is Linux on the host's architecture, which is also the architecture of
+the VM containers run in (linux/arm64 on Apple Silicon).
+
+Examples:
+  servin pull alpine:latest
+  servin pull --platform linux/amd64 alpine:latest
+  servin image pull --platform linux/arm/v7 nginx:alpine`,
 	Args: cobra.ExactArgs(1),
 	RunE: runImagePull,
 }
 
+// servin pull is a shortcut for servin image pull
+var rootPullCmd = &cobra.Command{
+	Use:   imagePullCmd.Use,
+	Short: imagePullCmd.Short,
+	Long:  imagePullCmd.Long,
+	Args:  imagePullCmd.Args,
+	RunE:  runImagePull,
+}
+
+var imagePushCmd = &cobra.Command{
+	Use:   "push IMAGE[:TAG]",
+	Short: "Push an image to a registry",
+	Long: `Push an image to the registry named in its reference, using the
+Distribution (Docker Registry v2) API. Blobs the registry already has are
+skipped; blobs larger than --chunk-size are uploaded in chunks.
+
+References without a registry host push to Docker Hub. Tag the image with the
+target registry first to push elsewhere.
+
+Examples:
+  servin image tag myapp:latest localhost:5000/myapp:latest
+  servin image push localhost:5000/myapp:latest
+  servin image push --all-tags registry.example.com/team/myapp`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImagePush,
+}
+
+var imageConvertCmd = &cobra.Command{
+	Use:   "convert SOURCE DESTINATION",
+	Short: "Convert an image between archive formats and the image store",
+	Long: `Copy an image between a docker-archive tarball, an OCI image layout
+directory and the servin image store, in any direction. Every blob is checked
+against its digest and every layer against the diff ID in the image config.
+
+Locations:
+  docker-archive:PATH[:REF]   Tarball as written by 'docker save' (may be gzipped)
+  oci:DIR[:REF]               OCI image layout, as used by skopeo and ctr
+  servin:IMAGE                Image in the servin image store
+
+REF selects the image when the source holds several, and names the image in
+the destination. In an OCI layout it is the org.opencontainers.image.ref.name
+annotation.
+
+Examples:
+  servin image convert docker-archive:alpine.tar servin:alpine:latest
+  servin image convert servin:myapp:v1 oci:./myapp-oci:v1
+  servin image convert oci:./layout:v1 docker-archive:myapp.tar:myapp:v1`,
+	Args: cobra.ExactArgs(2),
+	RunE: runImageConvert,
+}
+
+var imageSaveCmd = &cobra.Command{
+	Use:   "save [OPTIONS] IMAGE [IMAGE...]",
+	Short: "Save one or more images to a tar archive",
+	Long: `Save images to a tar archive, written to standard output unless -o
+is given, so they can be loaded with 'servin load', 'docker load',
+'podman load' or skopeo.
+
+Formats:
+  docker-archive   Tarball as written by 'docker save' (default)
+  oci-archive      Tarball of an OCI image layout
+  oci              OCI image layout directory, written to -o
+
+Images named by tag are saved under that tag; images named by ID keep all
+their tags.
+
+Examples:
+  servin save -o alpine.tar alpine:latest
+  servin save nginx:latest redis:alpine | gzip > images.tar.gz
+  servin save --format oci-archive -o myapp-oci.tar myapp:v1
+  servin save --format oci -o ./layout myapp:v1`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runImageSave,
+}
+
+// servin save is a shortcut for servin image save
+var rootSaveCmd = &cobra.Command{
+	Use:   imageSaveCmd.Use,
+	Short: imageSaveCmd.Short,
+	Long:  imageSaveCmd.Long,
+	Args:  imageSaveCmd.Args,
+	RunE:  runImageSave,
+}
+
+var imageLoadCmd = &cobra.Command{
+	Use:   "load [OPTIONS]",
+	Short: "Load images from a tar archive",
+	Long: `Load every image in a docker-archive tarball, an OCI archive or an OCI
+image layout directory, read from standard input unless -i is given. Gzipped
+archives are accepted. Every blob is checked against its digest and every
+layer against the diff ID in the image config.
+
+Examples:
+  servin load -i alpine.tar
+  gunzip -c images.tar.gz | servin load
+  servin load -i ./layout`,
+	Args: cobra.NoArgs,
+	RunE: runImageLoad,
+}
+
+// servin load is a shortcut for servin image load
+var rootLoadCmd = &cobra.Command{
+	Use:   imageLoadCmd.Use,
+	Short: imageLoadCmd.Short,
+	Long:  imageLoadCmd.Long,
+	Args:  imageLoadCmd.Args,
+	RunE:  runImageLoad,
+}
+
+var (
+	imageSaveOutput string
+	imageSaveFormat string
+	imageLoadInput  string
+	imageLoadForce  bool
+	imageRmForce    bool
+
+	imageConvertPlatform string
+	imagePullPlatform    string
+	imagePullLimitRate   string
+	imagePushAllTags     bool
+	imagePushChunkSize   int64
+	imagePushLimitRate   string
+	imageInspectJSON     bool
+)
+
 var imageInspectCmd = &cobra.Command{
 	Use:   "inspect IMAGE",
 	Short: "Display detailed information about an image",
@@ -70,7 +253,10 @@ var imageTagCmd = &cobra.Command{
 Examples:
   servin image tag alpine:latest alpine:v1.0
   servin image tag 45b0a36b30b7 myapp:latest
-  servin image tag ubuntu ubuntu:backup`,
+  servin image tag ubuntu ubuntu:backup
+
+An existing tag moves to the new image. When daemon.images.immutable_tags is
+set in config.yaml, moving it to a different image needs --force.`,
 	Args: cobra.ExactArgs(2),
 	RunE: runImageTag,
 }
@@ -81,14 +267,80 @@ func init() {
 	imageCmd.AddCommand(imageImportCmd)
 	imageCmd.AddCommand(imageRmCmd)
 	imageCmd.AddCommand(imagePullCmd)
+	imageCmd.AddCommand(imagePushCmd)
 	imageCmd.AddCommand(imageInspectCmd)
 	imageCmd.AddCommand(imageTagCmd)
+	imageCmd.AddCommand(imageConvertCmd)
+	imageCmd.AddCommand(imageSaveCmd)
+	imageCmd.AddCommand(imageLoadCmd)
+	imageCmd.AddCommand(imagePruneCmd)
+
+	for _, c := range []*cobra.Command{imagePullCmd, rootPullCmd} {
+		c.Flags().StringVar(&imagePullPlatform, "platform", "", "Pull the image for this platform (OS/ARCH[/VARIANT], e.g. linux/arm64)")
+		c.Flags().StringVar(&imagePullLimitRate, "limit-rate", "", limitRateUsage)
+	}
+
+	for _, c := range []*cobra.Command{imageSaveCmd, rootSaveCmd} {
+		c.Flags().StringVarP(&imageSaveOutput, "output", "o", "", "Write to a file (or directory for --format oci) instead of standard output")
+		c.Flags().StringVar(&imageSaveFormat, "format", image.FormatDockerArchive, "Archive format: docker-archive, oci-archive or oci")
+	}
+	for _, c := range []*cobra.Command{imageLoadCmd, rootLoadCmd} {
+		c.Flags().StringVarP(&imageLoadInput, "input", "i", "", "Read from a tar archive file or OCI layout directory instead of standard input")
+		c.Flags().BoolVarP(&imageLoadForce, "force", "f", false, forceTagUsage)
+	}
+
+	imageLsCmd.Flags().BoolVar(&imageLsUnused, "unused", false, "Only list images no container uses")
+	imageLsCmd.Flags().StringVar(&imageLsSince, "since", "", "With --unused, only list images not used since a time (e.g. 30d, or an RFC 3339 timestamp)")
+	imageLsCmd.Flags().StringVar(&imageLsFormat, "format", "table", "Output format (table, json)")
+
+	imageRmCmd.Flags().BoolVarP(&imageRmForce, "force", "f", false, "Remove images containers were created from, and tagged images when tags are immutable")
+	imageRmCmd.Flags().BoolVar(&overrideProtection, "override-protection", false, "Also remove images labelled protected")
+
+	imageInspectCmd.Flags().BoolVarP(&imageInspectJSON, "format", "f", false, "Format output as JSON")
+
+	imagePruneCmd.Flags().BoolVarP(&pruneAll, "all", "a", false, "Remove all unused images, not just dangling ones")
+	addPruneFlags(imagePruneCmd)
+
+	imageConvertCmd.Flags().StringVar(&imageConvertPlatform, "platform", "", "Platform to select from a multi-platform OCI index (OS/ARCH[/VARIANT])")
+
+	imagePushCmd.Flags().BoolVarP(&imagePushAllTags, "all-tags", "a", false, "Push all local tags of the repository")
+	imagePushCmd.Flags().Int64Var(&imagePushChunkSize, "chunk-size", image.DefaultChunkSize, "Upload blobs larger than this many bytes in chunks")
+	imagePushCmd.Flags().StringVar(&imagePushLimitRate, "limit-rate", "", limitRateUsage)
 
 	// Add image command to root
 	rootCmd.AddCommand(imageCmd)
+	rootCmd.AddCommand(rootPullCmd)
+	rootCmd.AddCommand(rootSaveCmd)
+	rootCmd.AddCommand(rootLoadCmd)
+}
+
+// imageListEntry is an image in the output of image ls --format json
+type imageListEntry struct {
+	ID         string     `json:"id"`
+	Repository string     `json:"repository"`
+	Tag        string     `json:"tag"`
+	Created    time.Time  `json:"created"`
+	Size       int64      `json:"size"`
+	LastUsed   *time.Time `json:"last_used"`
+	Containers int        `json:"containers"`
 }
 
 func runImageList(cmd *cobra.Command, args []string) error {
+	if imageLsFormat != "table" && imageLsFormat != "json" {
+		return errors.NewValidationError("image ls", fmt.Sprintf("unknown format '%s' (expected table or json)", imageLsFormat))
+	}
+	var since time.Time
+	if imageLsSince != "" {
+		if !imageLsUnused {
+			return errors.NewValidationError("image ls", "--since needs --unused")
+		}
+		t, err := parseTimeOption(imageLsSince)
+		if err != nil {
+			return errors.NewValidationError("image ls", fmt.Sprintf("invalid --since: %v", err))
+		}
+		since = t
+	}
+
 	// Image listing doesn't require root privileges
 	imgManager := image.NewManager()
 	images, err := imgManager.ListImages()
@@ -96,37 +348,107 @@ func runImageList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to list images: %v", err)
 	}
 
-	if len(images) == 0 {
-		fmt.Println("No images found")
-		return nil
+	// Count the containers using each image; without access to the container
+	// state only --unused needs it
+	containers, err := state.NewStateManager().ListContainers()
+	if err != nil && imageLsUnused {
+		return fmt.Errorf("failed to list containers: %v", err)
+	}
+	users := make(map[string]int)
+	for _, c := range containers {
+		if id := imgManager.ContainerImageID(c); id != "" {
+			users[id]++
+		}
 	}
 
-	// Create table output
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	defer w.Flush()
-
-	fmt.Fprintln(w, "REPOSITORY\tTAG\tIMAGE ID\tCREATED\tSIZE")
-
+	var listed []*image.Image
 	for _, img := range images {
-		for _, repoTag := range img.RepoTags {
-			parts := strings.Split(repoTag, ":")
-			repo := parts[0]
-			tag := "latest"
-			if len(parts) > 1 {
-				tag = parts[1]
+		if imageLsUnused && users[img.ID] > 0 {
+			continue
+		}
+		if !since.IsZero() && !img.LastActivity().Before(since) {
+			continue
+		}
+		listed = append(listed, img)
+	}
+
+	if imageLsFormat == "json" {
+		entries := []imageListEntry{}
+		for _, img := range listed {
+			for _, repoTag := range img.RepoTags {
+				repo, tag := splitRepoTag(repoTag)
+				entry := imageListEntry{
+					ID:         img.ID,
+					Repository: repo,
+					Tag:        tag,
+					Created:    img.Created,
+					Size:       img.Size,
+					Containers: users[img.ID],
+				}
+				if !img.LastUsed.IsZero() {
+					lastUsed := img.LastUsed
+					entry.LastUsed = &lastUsed
+				}
+				entries = append(entries, entry)
 			}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
 
-			created := formatTimeImage(img.Created)
-			size := formatSize(img.Size)
+	if len(listed) == 0 {
+		if imageLsUnused {
+			fmt.Println("No unused images found")
+		} else {
+			fmt.Println("No images found")
+		}
+		return nil
+	}
 
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-				repo, tag, img.ID[:12], created, size)
+	// Create table output
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tTAG\tIMAGE ID\tCREATED\tLAST USED\tSIZE")
+
+	var total int64
+	for _, img := range listed {
+		total += img.Size
+		lastUsed := "Never"
+		if !img.LastUsed.IsZero() {
+			lastUsed = formatTimeImage(img.LastUsed)
+		}
+		for _, repoTag := range img.RepoTags {
+			repo, tag := splitRepoTag(repoTag)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				repo, tag, img.ID[:12], formatTimeImage(img.Created), lastUsed, formatSize(img.Size))
 		}
 	}
+	w.Flush()
 
+	if imageLsUnused {
+		prune := "servin image prune -a"
+		if imageLsSince != "" {
+			prune += " --filter unused-since=" + imageLsSince
+		}
+		fmt.Printf("\n%d unused images, %s in total (remove them with '%s')\n", len(listed), formatSize(total), prune)
+	}
 	return nil
 }
 
+// splitRepoTag splits "repo:tag" into its parts; a missing tag is "latest"
+func splitRepoTag(repoTag string) (repo, tag string) {
+	parts := strings.Split(repoTag, ":")
+	repo = parts[0]
+	tag = "latest"
+	if len(parts) > 1 {
+		tag = parts[1]
+	}
+	return repo, tag
+}
+
 func runImageImport(cmd *cobra.Command, args []string) error {
 	if err := checkRoot(); err != nil {
 		return err
@@ -137,7 +459,7 @@ func runImageImport(cmd *cobra.Command, args []string) error {
 
 	// Check if tarball exists
 	if _, err := os.Stat(tarballPath); os.IsNotExist(err) {
-		return fmt.Errorf("tarball file not found: %s", tarballPath)
+		return errors.NewNotFoundError("image import", fmt.Sprintf("tarball file not found: %s", tarballPath))
 	}
 
 	// Parse image reference
@@ -160,37 +482,273 @@ func runImageRemove(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	imgManager := image.NewManager()
+	imgManager, err := taggingManager(imageRmForce)
+	if err != nil {
+		return err
+	}
 
+	// Every image is tried; the command fails if any could not be removed
+	var failures []error
 	for _, imageRef := range args {
 		fmt.Printf("Removing image %s...\n", imageRef)
 
-		if err := imgManager.RemoveImage(imageRef); err != nil {
-			fmt.Printf("Error removing image %s: %v\n", imageRef, err)
+		img, err := imgManager.RemoveChecked(imageRef, overrideProtection)
+		if err != nil {
+			failures = append(failures, err)
 			continue
 		}
+		if img.IsProtected() {
+			recordOverride("image rm", fmt.Sprintf("image %s (%s)", imageRef, img.ID[:12]), "removed protected image with --override-protection")
+		}
 
 		fmt.Printf("Successfully removed image %s\n", imageRef)
 	}
 
+	return argumentsError("image rm", failures)
+}
+
+func runImagePrune(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	until, unusedSince, err := parseImagePruneFilters(pruneFilters)
+	if err != nil {
+		return err
+	}
+
+	warning := "  - all dangling images"
+	if pruneAll {
+		warning = "  - all images without at least one container associated to them"
+	}
+	if !confirmPrune(warning) {
+		return nil
+	}
+
+	containers, err := state.NewStateManager().ListContainers()
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	imgManager := image.NewManager()
+	report, err := imgManager.PruneImages(image.PruneOptions{
+		All:         pruneAll,
+		Until:       until,
+		UnusedSince: unusedSince,
+		InUse:       imagesUsedBy(imgManager, containers),
+		DryRun:      pruneDryRun,
+	})
+	if report != nil {
+		printImagePrune(report)
+		printReclaimed(report.SpaceReclaimed)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune images: %v", err)
+	}
 	return nil
 }
 
 func runImagePull(cmd *cobra.Command, args []string) error {
 	// Remove root check for image pulling - it only requires write access to image directory
 	imageRef := args[0]
+
+	var opts image.PullOptions
+	if imagePullPlatform != "" {
+		platform, err := image.ParsePlatform(imagePullPlatform)
+		if err != nil {
+			return err
+		}
+		opts.Platform = platform
+	}
+	rate, err := limitRate(imagePullLimitRate, false)
+	if err != nil {
+		return err
+	}
+	opts.LimitRate = rate
+
 	fmt.Printf("Pulling image %s...\n", imageRef)
 
 	imgManager := image.NewManager()
 
 	// Try to pull from Docker Hub/registry
-	if err := imgManager.PullImage(imageRef); err != nil {
+	if err := imgManager.PullImage(imageRef, opts); err != nil {
 		return fmt.Errorf("failed to pull image: %v", err)
 	}
 
 	return nil
 }
 
+func runImageConvert(cmd *cobra.Command, args []string) error {
+	src, err := image.ParseImageLocation(args[0])
+	if err != nil {
+		return err
+	}
+	dst, err := image.ParseImageLocation(args[1])
+	if err != nil {
+		return err
+	}
+
+	var opts image.ConvertOptions
+	if imageConvertPlatform != "" {
+		if opts.Platform, err = image.ParsePlatform(imageConvertPlatform); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Converting %s to %s...\n", src, dst)
+	if err := image.NewManager().ConvertImage(src, dst, opts); err != nil {
+		return fmt.Errorf("failed to convert image: %v", err)
+	}
+
+	fmt.Printf("Successfully converted %s to %s\n", src, dst)
+	return nil
+}
+
+func runImageSave(cmd *cobra.Command, args []string) error {
+	imgManager := image.NewManager()
+
+	if imageSaveFormat == image.FormatOCI {
+		if imageSaveOutput == "" {
+			return errors.NewValidationError("image save", "--format oci writes a directory, give it with -o")
+		}
+		if err := imgManager.SaveImagesToLayout(args, imageSaveOutput, os.Stderr); err != nil {
+			return fmt.Errorf("failed to save images: %v", err)
+		}
+		return nil
+	}
+
+	if imageSaveOutput == "" {
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return errors.NewValidationError("image save", "refusing to write an archive to a terminal, use -o or redirect the output")
+		}
+		if err := imgManager.SaveImages(args, os.Stdout, imageSaveFormat, os.Stderr); err != nil {
+			return fmt.Errorf("failed to save images: %v", err)
+		}
+		return nil
+	}
+
+	// The archive is written next to the output and renamed into place, so
+	// a failed save does not leave a truncated file
+	tmp := imageSaveOutput + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", imageSaveOutput, err)
+	}
+	defer os.Remove(tmp)
+
+	err = imgManager.SaveImages(args, file, imageSaveFormat, os.Stdout)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save images: %v", err)
+	}
+	if err := os.Rename(tmp, imageSaveOutput); err != nil {
+		return fmt.Errorf("failed to save images: %v", err)
+	}
+	fmt.Printf("Saved %s to %s\n", strings.Join(args, ", "), imageSaveOutput)
+	return nil
+}
+
+func runImageLoad(cmd *cobra.Command, args []string) error {
+	input := imageLoadInput
+	if input == "" {
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return errors.NewValidationError("image load", "no archive on standard input, use -i or redirect the input")
+		}
+		// Archives are read more than once, so standard input is spooled
+		// to a temporary file first
+		tmp, err := os.CreateTemp("", "servin-load-*.tar")
+		if err != nil {
+			return fmt.Errorf("failed to buffer input: %v", err)
+		}
+		defer os.Remove(tmp.Name())
+		_, err = io.Copy(tmp, os.Stdin)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+		input = tmp.Name()
+	}
+
+	imgManager, err := taggingManager(imageLoadForce)
+	if err != nil {
+		return err
+	}
+	names, err := imgManager.LoadImages(input)
+	if err != nil {
+		return fmt.Errorf("failed to load images: %v", err)
+	}
+
+	for _, name := range names {
+		if strings.HasPrefix(name, "sha256:") {
+			fmt.Printf("Loaded image ID: %s\n", name)
+		} else {
+			fmt.Printf("Loaded image: %s\n", name)
+		}
+	}
+	return nil
+}
+
+func runImagePush(cmd *cobra.Command, args []string) error {
+	if imagePushChunkSize <= 0 {
+		return errors.NewValidationError("image push", "chunk size must be positive")
+	}
+	rate, err := limitRate(imagePushLimitRate, true)
+	if err != nil {
+		return err
+	}
+
+	imageRef := args[0]
+	ref := image.ParseReference(imageRef)
+	fmt.Printf("Pushing %s to %s...\n", ref.Repository, ref.Registry)
+
+	imgManager := image.NewManager()
+	if err := imgManager.PushImage(imageRef, image.PushOptions{
+		AllTags:   imagePushAllTags,
+		ChunkSize: imagePushChunkSize,
+		LimitRate: rate,
+	}); err != nil {
+		return fmt.Errorf("failed to push image: %v", err)
+	}
+
+	return nil
+}
+
+// imageInspectEntry is the JSON output of image inspect
+type imageInspectEntry struct {
+	ID           string               `json:"id"`
+	RepoTags     []string             `json:"repo_tags"`
+	Created      time.Time            `json:"created"`
+	LastUsed     *time.Time           `json:"last_used"`
+	Size         int64                `json:"size"`
+	Platform     string               `json:"platform,omitempty"`
+	ConfigDigest string               `json:"config_digest,omitempty"`
+	RootFSType   string               `json:"rootfs_type"`
+	Layers       []imageLayerEntry    `json:"layers"`
+	Config       image.ImageConfig    `json:"config"`
+	History      []image.HistoryEntry `json:"history"`
+	Containers   []imageUserEntry     `json:"containers"`
+	Metadata     map[string]string    `json:"metadata,omitempty"`
+}
+
+// imageLayerEntry is a layer of an inspected image, base layer first
+type imageLayerEntry struct {
+	DiffID  string `json:"diff_id"`
+	Digest  string `json:"digest"`
+	Size    int64  `json:"size"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// imageUserEntry is a container created from an inspected image
+type imageUserEntry struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
 func runImageInspect(cmd *cobra.Command, args []string) error {
 	// Image inspection doesn't require root privileges
 	imageRef := args[0]
@@ -201,12 +759,83 @@ func runImageInspect(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get image: %v", err)
 	}
 
+	var layers []imageLayerEntry
+	for _, chainID := range img.LayerChain {
+		if layer, err := imgManager.GetLayer(chainID); err == nil {
+			layers = append(layers, imageLayerEntry{DiffID: layer.DiffID, Digest: layer.Digest, Size: layer.Size})
+		} else {
+			layers = append(layers, imageLayerEntry{DiffID: chainID, Missing: true})
+		}
+	}
+
+	// A missing config blob only loses the history, which is informational
+	history, _ := imgManager.History(img)
+
+	// Without access to the container state no containers are listed
+	users := []imageUserEntry{}
+	containers, _ := state.NewStateManager().ListContainers()
+	for _, c := range containers {
+		if imgManager.ContainerImageID(c) == img.ID {
+			users = append(users, imageUserEntry{ID: c.ID, Name: c.Name, Status: c.Status})
+		}
+	}
+
+	if imageInspectJSON {
+		entry := imageInspectEntry{
+			ID:           img.ID,
+			RepoTags:     img.RepoTags,
+			Created:      img.Created,
+			Size:         img.Size,
+			Platform:     img.Platform,
+			ConfigDigest: img.ConfigDigest,
+			RootFSType:   img.RootFSType,
+			Layers:       layers,
+			Config:       img.Config,
+			History:      history,
+			Containers:   users,
+			Metadata:     img.Metadata,
+		}
+		if entry.RepoTags == nil {
+			entry.RepoTags = []string{}
+		}
+		if entry.Layers == nil {
+			entry.Layers = []imageLayerEntry{}
+		}
+		if entry.History == nil {
+			entry.History = []image.HistoryEntry{}
+		}
+		if !img.LastUsed.IsZero() {
+			lastUsed := img.LastUsed
+			entry.LastUsed = &lastUsed
+		}
+		data, err := json.MarshalIndent(entry, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
 	fmt.Printf("Image: %s\n", imageRef)
 	fmt.Printf("ID: %s\n", img.ID)
 	fmt.Printf("Created: %s\n", img.Created.Format(time.RFC3339))
 	fmt.Printf("Size: %s\n", formatSize(img.Size))
+	if img.Platform != "" {
+		fmt.Printf("Platform: %s\n", img.Platform)
+	}
 	fmt.Printf("RootFS Type: %s\n", img.RootFSType)
-	fmt.Printf("RootFS Path: %s\n", img.RootFSPath)
+	if len(layers) > 0 {
+		fmt.Printf("Layers:\n")
+		for _, layer := range layers {
+			if layer.Missing {
+				fmt.Printf("  %s (missing)\n", layer.DiffID)
+			} else {
+				fmt.Printf("  %s (%s)\n", layer.DiffID, formatSize(layer.Size))
+			}
+		}
+	} else {
+		fmt.Printf("RootFS Path: %s\n", img.RootFSPath)
+	}
 
 	if len(img.RepoTags) > 0 {
 		fmt.Printf("Repo Tags: %s\n", strings.Join(img.RepoTags, ", "))
@@ -223,6 +852,15 @@ func runImageInspect(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Default Command: %s\n", strings.Join(img.Config.Cmd, " "))
 	}
 
+	if len(img.Config.ExposedPorts) > 0 {
+		var ports []string
+		for port := range img.Config.ExposedPorts {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+		fmt.Printf("Exposed Ports: %s\n", strings.Join(ports, ", "))
+	}
+
 	if img.Config.WorkingDir != "" {
 		fmt.Printf("Working Directory: %s\n", img.Config.WorkingDir)
 	}
@@ -231,6 +869,20 @@ func runImageInspect(cmd *cobra.Command, args []string) error {
 		fmt.Printf("User: %s\n", img.Config.User)
 	}
 
+	if len(history) > 0 {
+		fmt.Printf("History:\n")
+		for _, step := range history {
+			fmt.Printf("  %s\n", step.CreatedBy)
+		}
+	}
+
+	if len(users) > 0 {
+		fmt.Printf("Containers:\n")
+		for _, user := range users {
+			fmt.Printf("  %s (%s, %s)\n", user.Name, user.ID[:12], user.Status)
+		}
+	}
+
 	if len(img.Metadata) > 0 {
 		fmt.Printf("Metadata:\n")
 		for key, value := range img.Metadata {
@@ -288,7 +940,10 @@ func runImageTag(cmd *cobra.Command, args []string) error {
 	sourceRef := args[0]
 	targetTag := args[1]
 
-	imgManager := image.NewManager()
+	imgManager, err := taggingManager(imageTagForce)
+	if err != nil {
+		return err
+	}
 
 	// Tag the image
 	if err := imgManager.TagImage(sourceRef, targetTag); err != nil {
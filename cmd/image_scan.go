@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"servin/pkg/errors"
+	"servin/pkg/image"
+	"servin/pkg/inventory"
+	"servin/pkg/vulnerability"
+
+	"github.com/spf13/cobra"
+)
+
+var imageScanCmd = &cobra.Command{
+	Use:   "scan [OPTIONS] IMAGE",
+	Short: "Scan an image for known vulnerabilities",
+	Long: `Read the package databases of an image (apk, dpkg and rpm) straight
+from its layers and check every installed package against the OSV
+vulnerability database (https://osv.dev). Vulnerabilities are listed most
+severe first.
+
+Alpine, Debian, Ubuntu, AlmaLinux and Rocky Linux images are supported. Set
+SERVIN_OSV_URL to query a mirror of the OSV API.
+
+Examples:
+  servin image scan alpine:3.18
+  servin image scan --severity high nginx:latest
+  servin image scan --format json myapp:v1 > report.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImageScan,
+}
+
+var (
+	imageScanFormat   string
+	imageScanSeverity string
+)
+
+func init() {
+	imageCmd.AddCommand(imageScanCmd)
+
+	imageScanCmd.Flags().StringVar(&imageScanFormat, "format", "table", "Output format (table, json)")
+	imageScanCmd.Flags().StringVar(&imageScanSeverity, "severity", "unknown", "Only report vulnerabilities at least this severe (critical, high, medium, low, unknown)")
+}
+
+// imageScanReport is the output of image scan
+type imageScanReport struct {
+	Image string `json:"image"`
+	*vulnerability.Report
+}
+
+func runImageScan(cmd *cobra.Command, args []string) error {
+	if imageScanFormat != "table" && imageScanFormat != "json" {
+		return errors.NewValidationError("image scan", fmt.Sprintf("unknown format '%s' (expected table or json)", imageScanFormat))
+	}
+	minSeverity, err := vulnerability.ParseSeverity(imageScanSeverity)
+	if err != nil {
+		return err
+	}
+
+	imgManager := image.NewManager()
+	img, err := imgManager.GetImage(args[0])
+	if err != nil {
+		return errors.NewNotFoundError("image scan", fmt.Sprintf("image %s not found", args[0]))
+	}
+	fs, err := imgManager.ImageFS(img)
+	if err != nil {
+		return fmt.Errorf("failed to read image %s: %v", args[0], err)
+	}
+	inv, err := inventory.Scan(fs)
+	if err != nil {
+		return fmt.Errorf("failed to read package databases: %v", err)
+	}
+
+	report, err := vulnerability.Scan(inv, vulnerability.NewClient())
+	if err != nil {
+		return fmt.Errorf("failed to scan image %s: %v", args[0], err)
+	}
+
+	var findings []vulnerability.Finding
+	for _, finding := range report.Findings {
+		if vulnerability.AtLeast(finding.Severity, minSeverity) {
+			findings = append(findings, finding)
+		}
+	}
+	report.Findings = findings
+	if report.Findings == nil {
+		report.Findings = []vulnerability.Finding{}
+	}
+
+	if imageScanFormat == "json" {
+		data, err := json.MarshalIndent(imageScanReport{Image: args[0], Report: report}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printScanReport(args[0], report)
+	return nil
+}
+
+func printScanReport(ref string, report *vulnerability.Report) {
+	fmt.Printf("Image: %s\n", ref)
+	if report.OS != "" {
+		fmt.Printf("OS: %s\n", report.OS)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	counts := make(map[string]int)
+	for _, finding := range report.Findings {
+		counts[finding.Severity]++
+	}
+	fmt.Printf("Packages scanned: %d\n", report.Packages)
+	fmt.Printf("Vulnerabilities: %d (critical: %d, high: %d, medium: %d, low: %d, unknown: %d)\n",
+		len(report.Findings), counts[vulnerability.SeverityCritical], counts[vulnerability.SeverityHigh],
+		counts[vulnerability.SeverityMedium], counts[vulnerability.SeverityLow], counts[vulnerability.SeverityUnknown])
+
+	if len(report.Findings) == 0 {
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tID\tPACKAGE\tINSTALLED\tFIXED\tSUMMARY")
+	for _, f := range report.Findings {
+		severity := f.Severity
+		if f.Score > 0 {
+			severity = fmt.Sprintf("%s (%.1f)", f.Severity, f.Score)
+		}
+		fixed := f.FixedVersion
+		if fixed == "" {
+			fixed = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", severity, f.ID, f.Package, f.Version, fixed, truncateString(f.Summary, 60))
+	}
+	w.Flush()
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"servin/pkg/config"
+	"servin/pkg/errors"
+	"servin/pkg/image"
+
+	"github.com/spf13/cobra"
+)
+
+// forceTagUsage is the help text of the --force flags of commands that set
+// a tag
+const forceTagUsage = "Move the tag to the new image even when daemon.images.immutable_tags is set"
+
+var (
+	imageTagForce         bool
+	imageTagHistoryAt     string
+	imageTagHistoryFormat string
+)
+
+var imageTagHistoryCmd = &cobra.Command{
+	Use:   "tag-history REPOSITORY[:TAG]",
+	Short: "Show which images a tag pointed to over time",
+	Long: `Show every image a tag was set to, oldest first, with when it was set.
+Tagging, building, committing, loading and pulling record the tag's new image
+whenever it changes.
+
+With --at, only the image the tag pointed to at that time is shown.
+
+Examples:
+  servin image tag-history myapp:latest
+  servin image tag-history --at 2026-10-06 myapp
+  servin image tag-history --at 168h myapp:latest`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImageTagHistory,
+}
+
+func init() {
+	imageCmd.AddCommand(imageTagHistoryCmd)
+	imageTagHistoryCmd.Flags().StringVar(&imageTagHistoryAt, "at", "", "Show the image the tag pointed to at this time (a date, an RFC 3339 timestamp, or a duration ago such as 30d)")
+	imageTagHistoryCmd.Flags().StringVar(&imageTagHistoryFormat, "format", "table", "Output format (table, json)")
+
+	imageTagCmd.Flags().BoolVarP(&imageTagForce, "force", "f", false, forceTagUsage)
+}
+
+// taggingManager returns the image manager of a command that sets or
+// removes a tag, refusing to move or remove tags when config.yaml makes them
+// immutable and the command was not given --force
+func taggingManager(force bool) (*image.Manager, error) {
+	cfg, _, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return image.NewManager().WithImmutableTags(cfg.Daemon.Images.ImmutableTags).WithForce(force), nil
+}
+
+func runImageTagHistory(cmd *cobra.Command, args []string) error {
+	if imageTagHistoryFormat != "table" && imageTagHistoryFormat != "json" {
+		return errors.NewValidationError("image tag-history", fmt.Sprintf("unknown format '%s' (expected table or json)", imageTagHistoryFormat))
+	}
+
+	history, err := image.NewManager().TagHistory(args[0])
+	if err != nil {
+		return err
+	}
+
+	if imageTagHistoryAt != "" {
+		at, err := parseTimeOption(imageTagHistoryAt)
+		if err != nil {
+			return errors.NewValidationError("image tag-history", fmt.Sprintf("invalid --at: %v", err))
+		}
+		event := image.TagAt(history, at)
+		if event == nil {
+			return errors.NewNotFoundError("image tag-history", fmt.Sprintf("%s was not set at %s", args[0], at.Format(time.RFC3339)))
+		}
+		history = []image.TagEvent{*event}
+	}
+
+	if imageTagHistoryFormat == "json" {
+		if history == nil {
+			history = []image.TagEvent{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(history)
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("No history recorded for %s\n", args[0])
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "SET\tIMAGE ID\tDIGEST\tFORCED")
+	for _, e := range history {
+		digest := e.Digest
+		if digest == "" {
+			digest = "<none>"
+		}
+		forced := ""
+		if e.Forced {
+			forced = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), shortImageID(e.ImageID), digest, forced)
+	}
+	return w.Flush()
+}
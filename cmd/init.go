@@ -5,6 +5,9 @@ import (
 	"os"
 	"os/exec"
 
+	"servin/pkg/namespaces"
+	"servin/pkg/security"
+
 	"github.com/spf13/cobra"
 )
 
@@ -24,6 +27,9 @@ func initContainer(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("init command requires at least one argument")
 	}
 
+	// Wait for servin to finish setting the process up
+	namespaces.WaitForStart()
+
 	fmt.Printf("Initializing container as PID %d\n", os.Getpid())
 
 	// Set up the container environment using namespaces
@@ -31,6 +37,11 @@ func initContainer(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to setup container environment: %v", err)
 	}
 
+	// Restrict the process last, as the setup above mounts filesystems
+	if err := security.Apply(security.FromEnv()); err != nil {
+		return fmt.Errorf("failed to apply security options: %v", err)
+	}
+
 	// Execute the target command
 	command := args[0]
 	commandArgs := args[1:]
@@ -40,5 +51,11 @@ func initContainer(cmd *cobra.Command, args []string) error {
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
 
-	return execCmd.Run()
+	if err := execCmd.Start(); err != nil {
+		return err
+	}
+	stop := forwardSignals(execCmd.Process)
+	defer stop()
+
+	return execCmd.Wait()
 }
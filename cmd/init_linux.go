@@ -5,6 +5,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"syscall"
 
@@ -160,3 +161,23 @@ func fallbackChroot(rootfsPath string) error {
 	fmt.Printf("Changed root using chroot to: %s\n", rootfsPath)
 	return nil
 }
+
+// forwardSignals passes the signals sent to the container's init on to the
+// process it runs. As PID 1 of its namespace, init would otherwise ignore
+// them, so a stop signal would never reach the process.
+func forwardSignals(process *os.Process) func() {
+	sigChan := make(chan os.Signal, 8)
+	signal.Notify(sigChan)
+	go func() {
+		for sig := range sigChan {
+			if sig == unix.SIGCHLD || sig == unix.SIGURG {
+				continue
+			}
+			process.Signal(sig)
+		}
+	}()
+	return func() {
+		signal.Stop(sigChan)
+		close(sigChan)
+	}
+}
@@ -12,3 +12,8 @@ func setupContainerEnvironment() error {
 	fmt.Fprintf(os.Stderr, "Error: This containerization tool only works on Linux\n")
 	return fmt.Errorf("unsupported platform")
 }
+
+// forwardSignals has nothing to forward; containers only run on Linux
+func forwardSignals(process *os.Process) func() {
+	return func() {}
+}
@@ -1,14 +1,24 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
+	"runtime"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
+	"servin/pkg/cgroups"
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/health"
+	"servin/pkg/restart"
 	"servin/pkg/state"
+	"servin/pkg/tenancy"
+	"servin/pkg/volume"
 
 	"github.com/spf13/cobra"
 )
@@ -16,44 +26,31 @@ import (
 var inspectCmd = &cobra.Command{
 	Use:   "inspect CONTAINER",
 	Short: "Display detailed container information",
-	Long:  "Display comprehensive information about a container including state, config, and runtime details",
-	Args:  cobra.ExactArgs(1),
-	RunE:  inspectContainer,
-}
-
-var psCmd = &cobra.Command{
-	Use:   "ps CONTAINER",
-	Short: "List processes running in container",
-	Long:  "Display all processes currently running inside the specified container",
-	Args:  cobra.ExactArgs(1),
-	RunE:  listContainerProcesses,
+	Long: `Display comprehensive information about a container including state, config, and runtime details.
+
+With --contents, list the OS packages installed in a container or image and
+the top-level processes of a running container. Packages are read from the
+apk, dpkg and rpm databases in the root filesystem, without running anything
+inside it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: inspectContainer,
 }
 
 var topCmd = &cobra.Command{
 	Use:   "top CONTAINER",
 	Short: "Display running processes in container",
-	Long:  "Display a live view of processes running in the container (similar to htop)",
+	Long:  "List the processes running in the container's PID namespace, or inside the VM on macOS and Windows",
 	Args:  cobra.ExactArgs(1),
 	RunE:  showContainerTop,
 }
 
-var statsCmd = &cobra.Command{
-	Use:   "stats [CONTAINER...]",
-	Short: "Display container resource usage statistics",
-	Long:  "Display live resource usage statistics for one or more containers",
-	RunE:  showContainerStats,
-}
-
 func init() {
 	rootCmd.AddCommand(inspectCmd)
-	rootCmd.AddCommand(psCmd)
 	rootCmd.AddCommand(topCmd)
-	rootCmd.AddCommand(statsCmd)
 
 	// Add flags
 	inspectCmd.Flags().BoolP("format", "f", false, "Format output as JSON")
-	statsCmd.Flags().BoolP("no-stream", "n", false, "Disable streaming stats and only pull the first result")
-	statsCmd.Flags().IntP("interval", "i", 1, "Refresh interval in seconds")
+	inspectCmd.Flags().Bool("contents", false, "List installed packages and processes of a container or image")
 }
 
 func inspectContainer(cmd *cobra.Command, args []string) error {
@@ -61,46 +58,62 @@ func inspectContainer(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	containerID := args[0]
+	format, _ := cmd.Flags().GetBool("format")
+	if contents, _ := cmd.Flags().GetBool("contents"); contents {
+		return inspectContents(args[0], format)
+	}
+
 	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
+	}
 
 	// Load container state
 	container, err := sm.LoadContainer(containerID)
 	if err != nil {
-		return fmt.Errorf("container not found: %s", containerID)
+		return errors.WrapError(err, errors.ErrTypeNotFound, "inspect", fmt.Sprintf("container not found: %s", containerID))
 	}
 
-	format, _ := cmd.Flags().GetBool("format")
+	effectiveCpus, effectiveMems := effectiveCpuset(container)
 
 	if format {
-		// JSON format output
-		fmt.Printf(`{
-  "Id": "%s",
-  "Name": "%s",
-  "Image": "%s",
-  "Command": "%s",
-  "Args": %q,
-  "Status": "%s",
-  "Created": "%s",
-  "Started": "%s",
-  "PID": %d,
-  "RootFS": "%s",
-  "NetworkMode": "%s"
-}`, container.ID, container.Name, container.Image, container.Command,
-			container.Args, container.Status, container.Created.Format(time.RFC3339),
-			container.Started.Format(time.RFC3339), container.PID,
-			getContainerRootFSPath(container.ID), container.NetworkMode)
+		data, err := json.MarshalIndent(newInspectEntry(container, effectiveCpus, effectiveMems), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
 	} else {
 		// Human readable format
 		fmt.Printf("Container ID: %s\n", container.ID)
 		fmt.Printf("Name: %s\n", container.Name)
+		fmt.Printf("Namespace: %s\n", tenancy.Normalize(container.Namespace))
 		fmt.Printf("Image: %s\n", container.Image)
 		fmt.Printf("Command: %s %s\n", container.Command, strings.Join(container.Args, " "))
 		fmt.Printf("Status: %s\n", container.Status)
 		fmt.Printf("Created: %s\n", container.Created.Format(time.RFC3339))
 		fmt.Printf("Started: %s\n", container.Started.Format(time.RFC3339))
 		fmt.Printf("PID: %d\n", container.PID)
+		if container.Status == state.StatusExited {
+			fmt.Printf("Exit Code: %d\n", container.ExitCode)
+		}
 		fmt.Printf("Network Mode: %s\n", container.NetworkMode)
+		showNetworkSettings(container)
+		fmt.Printf("Restart Policy: %s (restarted %d times)\n", restartPolicyName(container.RestartPolicy), container.RestartCount)
+		showHealthInfo(container)
+		if container.CpusetCpus != "" || container.CpusetMems != "" {
+			fmt.Printf("Cpuset: cpus=%s mems=%s\n", valueOrAll(container.CpusetCpus), valueOrAll(container.CpusetMems))
+		}
+		if effectiveCpus != "" {
+			fmt.Printf("Effective Cpuset: cpus=%s mems=%s\n", effectiveCpus, effectiveMems)
+		}
+		showEnvAndMounts(container)
+		if len(container.Hooks) > 0 {
+			fmt.Printf("Hooks:\n")
+			for _, hook := range container.Hooks {
+				fmt.Printf("  %s: %s\n", hook.Event, hook.Command)
+			}
+		}
 
 		// Show rootfs information
 		rootfsPath := getContainerRootFSPath(container.ID)
@@ -119,159 +132,317 @@ func inspectContainer(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func listContainerProcesses(cmd *cobra.Command, args []string) error {
-	if err := checkRoot(); err != nil {
-		return err
-	}
+// inspectEntry is a container as 'servin inspect --format' prints it
+type inspectEntry struct {
+	ID            string         `json:"Id"`
+	Name          string         `json:"Name"`
+	Namespace     string         `json:"Namespace"`
+	Image         string         `json:"Image"`
+	Command       string         `json:"Command"`
+	Args          []string       `json:"Args"`
+	Status        string         `json:"Status"`
+	Created       string         `json:"Created"`
+	Started       string         `json:"Started"`
+	PID           int            `json:"PID"`
+	ExitCode      int            `json:"ExitCode"`
+	RootFS        string         `json:"RootFS"`
+	NetworkMode   string         `json:"NetworkMode"`
+	RestartPolicy string         `json:"RestartPolicy"`
+	RestartCount  int            `json:"RestartCount"`
+	Health        string         `json:"Health"`
+	CpusetCpus    string         `json:"CpusetCpus"`
+	CpusetMems    string         `json:"CpusetMems"`
+	EffectiveCpus string         `json:"EffectiveCpus"`
+	EffectiveMems string         `json:"EffectiveMems"`
+	Env           []string       `json:"Env"`
+	Mounts        []inspectMount `json:"Mounts"`
+}
 
-	containerID := args[0]
-	sm := state.NewStateManager()
+// inspectMount is a volume or bind mount of a container. Propagation is
+// read from the running container's mount table, and is empty otherwise.
+type inspectMount struct {
+	Type        string `json:"Type"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	Mode        string `json:"Mode"`
+	RW          bool   `json:"RW"`
+	Propagation string `json:"Propagation"`
+}
 
-	// Load container state
-	container, err := sm.LoadContainer(containerID)
-	if err != nil {
-		return fmt.Errorf("container not found: %s", containerID)
+func newInspectEntry(container *state.ContainerState, effectiveCpus, effectiveMems string) inspectEntry {
+	args := container.Args
+	if args == nil {
+		args = []string{}
+	}
+	return inspectEntry{
+		ID:            container.ID,
+		Name:          container.Name,
+		Namespace:     tenancy.Normalize(container.Namespace),
+		Image:         container.Image,
+		Command:       container.Command,
+		Args:          args,
+		Status:        container.Status,
+		Created:       container.Created.Format(time.RFC3339),
+		Started:       container.Started.Format(time.RFC3339),
+		PID:           container.PID,
+		ExitCode:      container.ExitCode,
+		RootFS:        getContainerRootFSPath(container.ID),
+		NetworkMode:   container.NetworkMode,
+		RestartPolicy: restartPolicyName(container.RestartPolicy),
+		RestartCount:  container.RestartCount,
+		Health:        healthStatus(container),
+		CpusetCpus:    container.CpusetCpus,
+		CpusetMems:    container.CpusetMems,
+		EffectiveCpus: effectiveCpus,
+		EffectiveMems: effectiveMems,
+		Env:           containerEnv(container),
+		Mounts:        containerMounts(container),
 	}
+}
 
-	if container.Status != state.StatusRunning {
-		return fmt.Errorf("container is not running")
+// containerEnv returns the container's environment as sorted KEY=VALUE pairs
+func containerEnv(container *state.ContainerState) []string {
+	env := make([]string, 0, len(container.Env))
+	for key, value := range container.Env {
+		env = append(env, key+"="+value)
 	}
+	sort.Strings(env)
+	return env
+}
 
-	// Use ps to find processes in the container's namespace
-	// This is a simplified approach - in a full implementation you'd check the actual namespace
-	fmt.Printf("%-8s %-8s %-8s %-20s %s\n", "PID", "PPID", "USER", "TIME", "COMMAND")
+// containerMounts returns the container's mounts sorted by destination
+func containerMounts(container *state.ContainerState) []inspectMount {
+	var propagation map[string]string
+	if container.Status == state.StatusRunning && container.PID > 0 {
+		propagation = mountPropagation(container.PID)
+	}
 
-	if container.PID > 0 {
-		// Show the main container process
-		if processExists(container.PID) {
-			cmd := exec.Command("ps", "-p", fmt.Sprintf("%d", container.PID), "-o", "pid,ppid,user,time,cmd", "--no-headers")
-			output, err := cmd.Output()
-			if err == nil {
-				fmt.Print(string(output))
+	mounts := make([]inspectMount, 0, len(container.Volumes))
+	for source, mount := range container.Volumes {
+		destination, readOnly := strings.TrimSuffix(mount, ":ro"), strings.HasSuffix(mount, ":ro")
+		m := inspectMount{
+			Type:        "bind",
+			Source:      source,
+			Destination: destination,
+			Mode:        "rw",
+			RW:          !readOnly,
+			Propagation: propagation[destination],
+		}
+		if spec, err := volume.ParseSpec(source + ":" + destination); err == nil && spec.Named {
+			m.Type = "volume"
+		}
+		if readOnly {
+			m.Mode = "ro"
+		}
+		mounts = append(mounts, m)
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Destination < mounts[j].Destination })
+	return mounts
+}
+
+// mountPropagation reads the propagation of a process's mounts from its
+// mountinfo, by mount point as the process sees it. Processes in the VM are
+// not visible here, so their mounts have no propagation.
+func mountPropagation(pid int) map[string]string {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/mountinfo", pid))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	propagation := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// ID PARENT MAJOR:MINOR ROOT MOUNTPOINT OPTIONS [OPTIONAL...] - TYPE SOURCE SUPEROPTIONS
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 {
+			continue
+		}
+		var modes []string
+		for _, field := range fields[6:] {
+			if field == "-" {
+				break
+			}
+			switch {
+			case strings.HasPrefix(field, "shared:"):
+				modes = append(modes, "shared")
+			case strings.HasPrefix(field, "master:"):
+				modes = append(modes, "slave")
+			case field == "unbindable":
+				modes = append(modes, "unbindable")
 			}
 		}
+		if len(modes) == 0 {
+			modes = append(modes, "private")
+		}
+		propagation[fields[4]] = strings.Join(modes, ",")
+	}
+	return propagation
+}
 
-		// Try to find child processes
-		cmd := exec.Command("pgrep", "-P", fmt.Sprintf("%d", container.PID))
-		output, err := cmd.Output()
-		if err == nil {
-			pids := strings.Fields(string(output))
-			for _, pid := range pids {
-				cmd := exec.Command("ps", "-p", pid, "-o", "pid,ppid,user,time,cmd", "--no-headers")
-				if childOutput, err := cmd.Output(); err == nil {
-					fmt.Print(string(childOutput))
-				}
+// showEnvAndMounts prints the container's environment and mounts
+func showEnvAndMounts(container *state.ContainerState) {
+	if env := containerEnv(container); len(env) > 0 {
+		fmt.Printf("Environment:\n")
+		for _, variable := range env {
+			fmt.Printf("  %s\n", variable)
+		}
+	}
+	if mounts := containerMounts(container); len(mounts) > 0 {
+		fmt.Printf("Mounts:\n")
+		for _, m := range mounts {
+			line := fmt.Sprintf("  %s %s -> %s (%s", m.Type, m.Source, m.Destination, m.Mode)
+			if m.Propagation != "" {
+				line += ", " + m.Propagation
 			}
+			fmt.Println(line + ")")
 		}
-	} else {
-		fmt.Println("No processes found (container may not be running)")
 	}
+}
 
-	return nil
+// showNetworkSettings prints how a container is named and resolves names on
+// its networks, skipping what was left unset
+func showNetworkSettings(container *state.ContainerState) {
+	if container.Hostname != "" {
+		fmt.Printf("Hostname: %s\n", container.Hostname)
+	}
+	if len(container.Networks) > 0 {
+		fmt.Printf("Networks: %s\n", strings.Join(container.Networks, ", "))
+	}
+	if len(container.NetworkAliases) > 0 {
+		fmt.Printf("Network Aliases: %s\n", strings.Join(container.NetworkAliases, ", "))
+	}
+	if len(container.Links) > 0 {
+		fmt.Printf("Links: %s\n", strings.Join(container.Links, ", "))
+	}
+	if dns := container.DNS; dns != nil {
+		if len(dns.Servers) > 0 {
+			fmt.Printf("DNS Servers: %s\n", strings.Join(dns.Servers, ", "))
+		}
+		if len(dns.Search) > 0 {
+			fmt.Printf("DNS Search: %s\n", strings.Join(dns.Search, ", "))
+		}
+		if len(dns.Options) > 0 {
+			fmt.Printf("DNS Options: %s\n", strings.Join(dns.Options, ", "))
+		}
+		if len(dns.Hosts) > 0 {
+			hosts := make([]string, 0, len(dns.Hosts))
+			for host, ip := range dns.Hosts {
+				hosts = append(hosts, host+":"+ip)
+			}
+			sort.Strings(hosts)
+			fmt.Printf("Extra Hosts: %s\n", strings.Join(hosts, ", "))
+		}
+	}
 }
 
-func showContainerTop(cmd *cobra.Command, args []string) error {
-	if err := checkRoot(); err != nil {
-		return err
+// effectiveCpuset returns the CPUs and memory nodes a running container is pinned to
+func effectiveCpuset(container *state.ContainerState) (string, string) {
+	if container.Status != state.StatusRunning || (container.CpusetCpus == "" && container.CpusetMems == "") {
+		return "", ""
 	}
+	cpus, mems, err := cgroups.New(container.ID).EffectiveCpuset()
+	if err != nil {
+		return "", ""
+	}
+	return cpus, mems
+}
 
-	containerID := args[0]
-	sm := state.NewStateManager()
+// valueOrAll returns value, or "all" when no restriction is configured
+func valueOrAll(value string) string {
+	if value == "" {
+		return "all"
+	}
+	return value
+}
 
-	// Load container state
-	container, err := sm.LoadContainer(containerID)
-	if err != nil {
-		return fmt.Errorf("container not found: %s", containerID)
+// restartPolicyName returns the restart policy for display, defaulting to "no"
+func restartPolicyName(policy string) string {
+	if policy == "" {
+		return restart.PolicyNo
 	}
+	return policy
+}
 
-	if container.Status != state.StatusRunning {
-		return fmt.Errorf("container is not running")
+// healthStatus returns the container health status, or "none" without a health check
+func healthStatus(container *state.ContainerState) string {
+	if container.Health == nil {
+		return health.StatusNone
 	}
+	return container.Health.Status
+}
 
-	// Simple top-like display
-	fmt.Printf("Processes in container %s (PID: %d)\n", containerID[:12], container.PID)
-	fmt.Printf("%-8s %-8s %-8s %-8s %-20s %s\n", "PID", "USER", "CPU%", "MEM%", "TIME", "COMMAND")
+// showHealthInfo prints the health check configuration and recent probe results
+func showHealthInfo(container *state.ContainerState) {
+	if container.Healthcheck.Disabled() {
+		return
+	}
 
-	if container.PID > 0 && processExists(container.PID) {
-		// Show detailed process information
-		cmd := exec.Command("ps", "-p", fmt.Sprintf("%d", container.PID), "-o", "pid,user,pcpu,pmem,time,cmd", "--no-headers")
-		output, err := cmd.Output()
-		if err == nil {
-			fmt.Print(string(output))
-		}
-	} else {
-		fmt.Println("No processes found")
+	fmt.Printf("Health Check: %s\n", container.Healthcheck)
+	fmt.Printf("  Status: %s\n", healthStatus(container))
+	if container.Health == nil {
+		return
 	}
 
-	return nil
+	fmt.Printf("  Failing Streak: %d\n", container.Health.FailingStreak)
+	for _, result := range container.Health.Log {
+		fmt.Printf("  %s exit=%d %s\n", result.Start.Format(time.RFC3339), result.ExitCode,
+			strings.TrimSpace(result.Output))
+	}
 }
 
-func showContainerStats(cmd *cobra.Command, args []string) error {
+func showContainerTop(cmd *cobra.Command, args []string) error {
 	if err := checkRoot(); err != nil {
 		return err
 	}
 
 	sm := state.NewStateManager()
-	var containers []*state.ContainerState
-
-	if len(args) == 0 {
-		// Show stats for all running containers
-		allContainers, err := sm.ListContainers()
-		if err != nil {
-			return err
-		}
-		for _, c := range allContainers {
-			if c.Status == state.StatusRunning {
-				containers = append(containers, c)
-			}
-		}
-	} else {
-		// Show stats for specified containers
-		for _, containerID := range args {
-			container, err := sm.LoadContainer(containerID)
-			if err != nil {
-				fmt.Printf("Warning: container %s not found\n", containerID)
-				continue
-			}
-			containers = append(containers, container)
-		}
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
 	}
 
-	if len(containers) == 0 {
-		fmt.Println("No running containers found")
-		return nil
+	cs, err := sm.LoadContainer(containerID)
+	if err != nil {
+		return errors.WrapError(err, errors.ErrTypeNotFound, "inspect", fmt.Sprintf("container not found: %s", args[0]))
 	}
 
-	noStream, _ := cmd.Flags().GetBool("no-stream")
-	interval, _ := cmd.Flags().GetInt("interval")
-
-	// Print header
-	fmt.Printf("%-12s %-20s %-8s %-8s %-12s %-12s\n",
-		"CONTAINER", "NAME", "CPU %", "MEM %", "MEM USAGE", "NET I/O")
+	if cs.Status != state.StatusRunning {
+		return fmt.Errorf("container %s is not running", args[0])
+	}
 
-	if noStream {
-		// Single snapshot
-		for _, container := range containers {
-			showContainerResourceUsage(container)
-		}
-	} else {
-		// Continuous monitoring
-		for {
-			fmt.Print("\033[2J\033[H") // Clear screen and move cursor to top
-			fmt.Printf("%-12s %-20s %-8s %-8s %-12s %-12s\n",
-				"CONTAINER", "NAME", "CPU %", "MEM %", "MEM USAGE", "NET I/O")
-
-			for _, container := range containers {
-				showContainerResourceUsage(container)
+	// Containers on macOS and Windows run inside the VM, where Servin lists them itself
+	if runtime.GOOS != "linux" {
+		if vmManager, err := container.NewVMContainerManager(); err == nil && vmManager.IsEnabled() {
+			if output, err := vmManager.VMContainerTop(containerID); err == nil {
+				fmt.Print(string(output))
+				return nil
 			}
-
-			time.Sleep(time.Duration(interval) * time.Second)
 		}
 	}
 
+	processes, err := container.ListProcesses(cs.PID)
+	if err != nil {
+		return fmt.Errorf("failed to list processes: %v", err)
+	}
+
+	fmt.Printf("%-10s %-8s %-8s %-5s %-10s %-10s %s\n",
+		"USER", "PID", "PPID", "STAT", "TIME", "RSS", "COMMAND")
+	for _, p := range processes {
+		fmt.Printf("%-10s %-8d %-8d %-5s %-10s %-10s %s\n",
+			truncateString(p.User, 10), p.PID, p.PPID, p.State,
+			formatCPUTime(p.CPUTime), formatSize(int64(p.RSS)), p.Command)
+	}
+
 	return nil
 }
 
+// formatCPUTime formats cumulative CPU time like ps (HH:MM:SS)
+func formatCPUTime(d time.Duration) string {
+	seconds := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
 // Helper functions
 
 func getContainerRootFSPath(containerID string) string {
@@ -324,46 +495,3 @@ func showProcessInfo(pid int) {
 		fmt.Printf("Process Status: Running (limited info available)\n")
 	}
 }
-
-func showContainerResourceUsage(container *state.ContainerState) {
-	containerShort := container.ID
-	if len(containerShort) > 12 {
-		containerShort = containerShort[:12]
-	}
-
-	name := container.Name
-	if len(name) > 20 {
-		name = name[:17] + "..."
-	}
-
-	if container.PID <= 0 || !processExists(container.PID) {
-		fmt.Printf("%-12s %-20s %-8s %-8s %-12s %-12s\n",
-			containerShort, name, "0.00%", "0.00%", "0B / 0B", "0B / 0B")
-		return
-	}
-
-	// Get CPU and memory usage
-	cpuPercent := "0.00%"
-	memPercent := "0.00%"
-	memUsage := "0B / 0B"
-	netIO := "0B / 0B"
-
-	// Try to get actual stats from /proc
-	statusFile := fmt.Sprintf("/proc/%d/status", container.PID)
-	if content, err := os.ReadFile(statusFile); err == nil {
-		lines := strings.Split(string(content), "\n")
-		for _, line := range lines {
-			if strings.HasPrefix(line, "VmRSS:") {
-				// Extract memory usage
-				fields := strings.Fields(line)
-				if len(fields) >= 2 {
-					memUsage = fmt.Sprintf("%s kB / -", fields[1])
-				}
-				break
-			}
-		}
-	}
-
-	fmt.Printf("%-12s %-20s %-8s %-8s %-12s %-12s\n",
-		containerShort, name, cpuPercent, memPercent, memUsage, netIO)
-}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"text/tabwriter"
+
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/image"
+	"servin/pkg/inventory"
+	"servin/pkg/state"
+)
+
+// contentsReport is the output of inspect --contents
+type contentsReport struct {
+	Container string `json:"container,omitempty"`
+	Image     string `json:"image,omitempty"`
+	*inventory.Inventory
+	Processes []contentsProcess `json:"processes,omitempty"`
+}
+
+type contentsProcess struct {
+	PID     int    `json:"pid"`
+	PPID    int    `json:"ppid"`
+	User    string `json:"user"`
+	Command string `json:"command"`
+}
+
+// inspectContents lists the packages and top-level processes of a container,
+// or the packages of an image when ref names no container
+func inspectContents(ref string, asJSON bool) error {
+	report := &contentsReport{}
+	var src inventory.Source
+
+	sm := state.NewStateManager()
+	imageRef := ref
+	if containerID, err := resolveContainerRef(sm, ref); err == nil {
+		cs, err := sm.LoadContainer(containerID)
+		if err != nil {
+			return errors.WrapError(err, errors.ErrTypeNotFound, "inspect", fmt.Sprintf("container not found: %s", ref))
+		}
+		report.Container = cs.ID
+		report.Image = cs.Image
+		imageRef = cs.Image
+
+		// A running container's root filesystem reflects changes made since
+		// it started. Containers on macOS and Windows run inside the VM, so
+		// only their image can be read.
+		rootPath := getContainerRootFSPath(cs.ID)
+		if cs.Status == state.StatusRunning && runtime.GOOS == "linux" {
+			if info, err := os.Stat(rootPath); err == nil && info.IsDir() {
+				src = inventory.DirSource(rootPath)
+			}
+			report.Processes = topLevelProcesses(cs.PID)
+		}
+	}
+
+	if src == nil {
+		imageManager := image.NewManager()
+		img, err := imageManager.GetImage(imageRef)
+		if err != nil {
+			if report.Container != "" {
+				return errors.WrapError(err, errors.ErrTypeNotFound, "inspect", fmt.Sprintf("image %s of container %s not found", imageRef, ref))
+			}
+			return errors.NewNotFoundError("inspect", fmt.Sprintf("no container or image found: %s", ref))
+		}
+		if report.Image == "" {
+			report.Image = img.ID
+		}
+
+		fs, err := imageManager.ImageFS(img)
+		if err != nil {
+			return fmt.Errorf("failed to read image %s: %v", imageRef, err)
+		}
+		src = fs
+	}
+
+	inv, err := inventory.Scan(src)
+	if err != nil {
+		return fmt.Errorf("failed to read package databases: %v", err)
+	}
+	report.Inventory = inv
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printContents(report)
+	return nil
+}
+
+// topLevelProcesses returns a container's init process and its direct
+// children, or nothing if they cannot be listed
+func topLevelProcesses(initPID int) []contentsProcess {
+	processes, err := container.ListProcesses(initPID)
+	if err != nil {
+		return nil
+	}
+
+	var top []contentsProcess
+	for _, p := range processes {
+		if p.PID == initPID || p.PPID == initPID {
+			top = append(top, contentsProcess{PID: p.PID, PPID: p.PPID, User: p.User, Command: p.Command})
+		}
+	}
+	return top
+}
+
+func printContents(report *contentsReport) {
+	if report.Container != "" {
+		fmt.Printf("Container: %s\n", report.Container)
+	}
+	fmt.Printf("Image: %s\n", report.Image)
+	if report.OS != "" {
+		fmt.Printf("OS: %s\n", report.OS)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	fmt.Printf("\nPackages (%d):\n", len(report.Packages))
+	if len(report.Packages) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVERSION\tARCH\tMANAGER")
+		for _, pkg := range report.Packages {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", pkg.Name, pkg.Version, pkg.Arch, pkg.Manager)
+		}
+		w.Flush()
+	}
+
+	if report.Container == "" || report.Processes == nil {
+		return
+	}
+	fmt.Printf("\nProcesses (%d):\n", len(report.Processes))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tPPID\tUSER\tCOMMAND")
+	for _, p := range report.Processes {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", p.PID, p.PPID, p.User, p.Command)
+	}
+	w.Flush()
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"servin/pkg/errors"
+	"servin/pkg/jobs"
+
+	"github.com/spf13/cobra"
+)
+
+var jobsLsFormat string
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Show the daemon's background jobs",
+	Long: `Show the background work the daemon does, such as pulling the images on
+its pre-pull list (daemon.prefetch in config.yaml).`,
+}
+
+var jobsLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List background jobs",
+	Long: `List the daemon's background jobs and their status.
+
+Statuses:
+  queued   Waiting for an earlier job to finish
+  waiting  Waiting for its schedule, such as an off-peak window
+  running  In progress
+  done     Finished; prefetched images are present locally
+  failed   Failed; retried on the next check
+
+Examples:
+  servin jobs ls
+  servin jobs ls --format json`,
+	Args: cobra.NoArgs,
+	RunE: runJobsLs,
+}
+
+func init() {
+	rootCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsLsCmd)
+
+	jobsLsCmd.Flags().StringVar(&jobsLsFormat, "format", "table", "Output format (table, json)")
+}
+
+func runJobsLs(cmd *cobra.Command, args []string) error {
+	if jobsLsFormat != "table" && jobsLsFormat != "json" {
+		return errors.NewValidationError("jobs ls", fmt.Sprintf("unknown format '%s' (expected table or json)", jobsLsFormat))
+	}
+
+	list, err := jobs.NewStore().List()
+	if err != nil {
+		return err
+	}
+
+	if jobsLsFormat == "json" {
+		if list == nil {
+			list = []*jobs.Job{}
+		}
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(list) == 0 {
+		fmt.Println("No background jobs")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tTARGET\tSTATUS\tUPDATED\tNEXT RUN\tMESSAGE")
+	for _, job := range list {
+		nextRun := "-"
+		if !job.NextRun.IsZero() {
+			nextRun = job.NextRun.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			job.Type, job.Target, job.Status, formatTimeImage(job.Updated), nextRun, jobMessage(job))
+	}
+	return w.Flush()
+}
+
+// jobMessage returns a job's message, with how long it has been running
+func jobMessage(job *jobs.Job) string {
+	if job.Status == jobs.StatusRunning && !job.Started.IsZero() {
+		return fmt.Sprintf("running for %s", time.Since(job.Started).Round(time.Second))
+	}
+	if job.Message == "" {
+		return "-"
+	}
+	return job.Message
+}
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/network"
 	"servin/pkg/state"
 
 	"github.com/spf13/cobra"
@@ -14,15 +19,43 @@ var listCmd = &cobra.Command{
 	Aliases: []string{"list", "ps"},
 	Short:   "List containers",
 	Long:    "List all containers (running and stopped)",
-	RunE:    listContainers,
+	Example: `  servin ls
+  servin ls --format json`,
+	RunE: listContainers,
 }
 
+var listFormat string
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().BoolP("detailed", "d", false, "Show detailed container information including port mappings")
+	listCmd.Flags().BoolP("size", "s", false, "Display writable layer and total root filesystem sizes")
+	listCmd.Flags().StringVar(&listFormat, "format", "table", "Output format (table, json)")
+}
+
+// containerListEntry is a container in the output of ls --format json
+type containerListEntry struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Image       string                `json:"image"`
+	Command     string                `json:"command"`
+	Args        []string              `json:"args"`
+	Created     time.Time             `json:"created"`
+	Status      string                `json:"status"`
+	ExitCode    int                   `json:"exit_code"`
+	Health      string                `json:"health,omitempty"`
+	NetworkMode string                `json:"network_mode"`
+	IPAddress   string                `json:"ip_address,omitempty"`
+	IP6Address  string                `json:"ip6_address,omitempty"`
+	Ports       []network.PortMapping `json:"ports"`
+	Labels      map[string]string     `json:"labels,omitempty"`
+	Protected   bool                  `json:"protected,omitempty"`
 }
 
 func listContainers(cmd *cobra.Command, args []string) error {
+	if listFormat != "table" && listFormat != "json" {
+		return errors.NewValidationError("ls", fmt.Sprintf("unknown format '%s' (expected table or json)", listFormat))
+	}
 	if err := checkRoot(); err != nil {
 		return err
 	}
@@ -36,29 +69,77 @@ func listContainers(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to list containers: %v", err)
 	}
 
+	if listFormat == "json" {
+		entries := []containerListEntry{}
+		for _, c := range containers {
+			entry := containerListEntry{
+				ID:          c.ID,
+				Name:        c.Name,
+				Image:       c.Image,
+				Command:     c.Command,
+				Args:        c.Args,
+				Created:     c.Created,
+				Status:      c.Status,
+				ExitCode:    c.ExitCode,
+				NetworkMode: c.NetworkMode,
+				IPAddress:   c.IPAddress,
+				IP6Address:  c.IP6Address,
+				Ports:       c.PublishedPorts,
+				Labels:      c.Labels,
+				Protected:   isProtected(c.Labels),
+			}
+			if c.Health != nil {
+				entry.Health = c.Health.Status
+			}
+			if entry.Ports == nil {
+				entry.Ports = []network.PortMapping{}
+			}
+			entries = append(entries, entry)
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode containers: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
 	if len(containers) == 0 {
-		fmt.Println("CONTAINER ID   IMAGE     COMMAND   CREATED   STATUS    NAMES")
+		fmt.Println("CONTAINER ID   IMAGE     COMMAND   CREATED   STATUS    PORTS     NAMES")
 		fmt.Println("(No containers found)")
 		return nil
 	}
 
+	detailed, _ := cmd.Flags().GetBool("detailed")
+	showSize, _ := cmd.Flags().GetBool("size")
+
 	// Print header
-	fmt.Printf("%-12s %-15s %-20s %-15s %-10s %s\n",
-		"CONTAINER ID", "IMAGE", "COMMAND", "CREATED", "STATUS", "NAMES")
+	if showSize {
+		fmt.Printf("%-12s %-15s %-20s %-15s %-20s %-25s %-20s %s\n",
+			"CONTAINER ID", "IMAGE", "COMMAND", "CREATED", "STATUS", "PORTS", "NAMES", "SIZE")
+	} else {
+		fmt.Printf("%-12s %-15s %-20s %-15s %-20s %-25s %s\n",
+			"CONTAINER ID", "IMAGE", "COMMAND", "CREATED", "STATUS", "PORTS", "NAMES")
+	}
 
 	// Print each container
-	detailed, _ := cmd.Flags().GetBool("detailed")
 
 	for _, container := range containers {
 		shortID := container.ID[:12]
 		image := truncateString(container.Image, 15)
 		command := truncateString(container.Command, 20)
 		created := formatTime(container.Created)
-		status := container.Status
+		status := containerStatus(container)
+		ports := formatPorts(container.PublishedPorts)
 		name := container.Name
 
-		fmt.Printf("%-12s %-15s %-20s %-15s %-10s %s\n",
-			shortID, image, command, created, status, name)
+		if showSize {
+			fmt.Printf("%-12s %-15s %-20s %-15s %-20s %-25s %-20s %s\n",
+				shortID, image, command, created, status, ports, name, containerSize(container))
+		} else {
+			fmt.Printf("%-12s %-15s %-20s %-15s %-20s %-25s %s\n",
+				shortID, image, command, created, status, ports, name)
+		}
 
 		// Show detailed information if requested
 		if detailed {
@@ -88,6 +169,35 @@ func listContainers(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// containerStatus returns the container status, including health for running containers
+func containerStatus(container *state.ContainerState) string {
+	if container.Status == state.StatusRunning && container.Health != nil {
+		return fmt.Sprintf("%s (%s)", container.Status, container.Health.Status)
+	}
+	return container.Status
+}
+
+// formatPorts formats the ports a running container publishes, or "-"
+func formatPorts(ports []network.PortMapping) string {
+	if len(ports) == 0 {
+		return "-"
+	}
+	formatted := make([]string, len(ports))
+	for i, port := range ports {
+		formatted[i] = port.String()
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// containerSize formats the writable layer size and the total root filesystem size
+func containerSize(cs *state.ContainerState) string {
+	writable, total, err := container.DiskUsage(cs)
+	if err != nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (virtual %s)", formatSize(writable), formatSize(total))
+}
+
 // truncateString truncates a string to the specified length
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -2,15 +2,20 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"servin/pkg/errors"
 	"servin/pkg/logger"
+	"servin/pkg/logs"
 	"servin/pkg/state"
+	"servin/pkg/terminal"
 
 	"github.com/spf13/cobra"
 )
@@ -19,7 +24,11 @@ var logsCmd = &cobra.Command{
 	Use:   "logs [OPTIONS] CONTAINER",
 	Short: "Fetch the logs of a container",
 	Long: `Fetch and display the logs of a running or stopped container.
-The logs command retrieves stdout and stderr output from the container.`,
+The logs command retrieves stdout and stderr output from the container.
+
+With --follow, new output is shown as it is written until the container
+stops. With --format json each line is printed as an object with its time,
+stream (stdout or stderr) and text, for programs that read the logs.`,
 	Args: cobra.ExactArgs(1),
 	RunE: showContainerLogs,
 }
@@ -30,6 +39,9 @@ var (
 	tail       string
 	since      string
 	until      string
+	logsWrap   bool
+	logsTrunc  bool
+	logsFormat string
 )
 
 func init() {
@@ -40,11 +52,30 @@ func init() {
 	logsCmd.Flags().BoolVarP(&timestamps, "timestamps", "t", false, "Show timestamps")
 	logsCmd.Flags().StringVar(&tail, "tail", "all", "Number of lines to show from the end of the logs")
 	logsCmd.Flags().StringVar(&since, "since", "", "Show logs since timestamp (e.g. 2013-01-02T13:23:37Z) or relative (e.g. 42m for 42 minutes)")
+	logsCmd.Flags().BoolVar(&logsWrap, "wrap", false, "Wrap long lines at the terminal width, indenting the continuation")
+	logsCmd.Flags().BoolVar(&logsTrunc, "truncate", false, "Cut long lines off at the terminal width")
 	logsCmd.Flags().StringVar(&until, "until", "", "Show logs before a timestamp (e.g. 2013-01-02T13:23:37Z) or relative (e.g. 42m for 42 minutes)")
+	logsCmd.Flags().StringVar(&logsFormat, "format", "text", "Output format (text, json)")
+}
+
+// logLineJSON is a line in the output of logs --format json
+type logLineJSON struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Line   string    `json:"line"`
 }
 
 func showContainerLogs(cmd *cobra.Command, args []string) error {
 	containerIDOrName := args[0]
+	if logsWrap && logsTrunc {
+		return errors.NewValidationError("logs", "--wrap and --truncate cannot be used together")
+	}
+	if logsFormat != "text" && logsFormat != "json" {
+		return errors.NewValidationError("logs", fmt.Sprintf("unknown format '%s' (expected text or json)", logsFormat))
+	}
+	if logsFormat == "json" && (logsWrap || logsTrunc) {
+		return errors.NewValidationError("logs", "--wrap and --truncate only apply to text output")
+	}
 
 	logger.Debug("Showing logs for container: %s", containerIDOrName)
 
@@ -79,7 +110,7 @@ func showContainerLogs(cmd *cobra.Command, args []string) error {
 	logger.Debug("Found container: %s (status: %s)", container.ID, container.Status)
 
 	// Get log file paths
-	logDir := getContainerLogDir(container.ID)
+	logDir := logs.Dir(container.ID)
 	stdoutPath := filepath.Join(logDir, "stdout.log")
 	stderrPath := filepath.Join(logDir, "stderr.log")
 
@@ -89,7 +120,9 @@ func showContainerLogs(cmd *cobra.Command, args []string) error {
 	if _, err := os.Stat(stdoutPath); os.IsNotExist(err) {
 		if _, err := os.Stat(stderrPath); os.IsNotExist(err) {
 			logger.Warn("No log files found for container: %s", container.ID)
-			fmt.Printf("No logs available for container %s\n", containerIDOrName)
+			if logsFormat == "text" {
+				fmt.Printf("No logs available for container %s\n", containerIDOrName)
+			}
 			return nil
 		}
 	}
@@ -121,27 +154,22 @@ func showContainerLogs(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Long lines are fitted to the terminal, which may be resized while following
+	if logsWrap || logsTrunc {
+		stop := logRender.track()
+		defer stop()
+	}
+
 	// Display logs
 	if follow && container.Status == state.StatusRunning {
 		logger.Debug("Following logs for running container")
-		return followLogs(stdoutPath, stderrPath, timestamps, sinceTime, untilTime)
+		return followLogs(sm, container.ID, stdoutPath, stderrPath, timestamps, tailLines, sinceTime, untilTime)
 	} else {
 		logger.Debug("Displaying static logs (tail: %d)", tailLines)
 		return displayLogs(stdoutPath, stderrPath, timestamps, tailLines, sinceTime, untilTime)
 	}
 }
 
-// getContainerLogDir returns the directory where container logs are stored
-func getContainerLogDir(containerID string) string {
-	// Use the same base directory as state manager but for logs
-	sm := state.NewStateManager()
-	stateDir := sm.GetStateDir()
-
-	// Replace "containers" with "logs" in the path
-	logDir := filepath.Join(filepath.Dir(stateDir), "logs", containerID)
-	return logDir
-}
-
 // displayLogs shows static logs from log files
 func displayLogs(stdoutPath, stderrPath string, showTimestamps bool, tailLines int, since, until time.Time) error {
 	var lines []LogLine
@@ -176,65 +204,64 @@ func displayLogs(stdoutPath, stderrPath string, showTimestamps bool, tailLines i
 
 	// Display lines
 	for _, line := range lines {
-		if showTimestamps {
-			fmt.Printf("%s [%s] %s\n", line.Timestamp.Format(time.RFC3339), line.Stream, line.Content)
-		} else {
-			fmt.Printf("%s\n", line.Content)
-		}
+		printLogLine(line, showTimestamps)
 	}
 
 	return nil
 }
 
-// followLogs tails log files in real-time
-func followLogs(stdoutPath, stderrPath string, showTimestamps bool, since, until time.Time) error {
-	// This is a simplified implementation
-	// In a production system, you might use inotify or similar for efficient file watching
+// followLogs shows the logs and then new lines as they are written, until
+// the container stops running
+func followLogs(sm *state.StateManager, containerID, stdoutPath, stderrPath string, showTimestamps bool, tailLines int, since, until time.Time) error {
+	// Note the file sizes first, so that a line written while the existing
+	// ones are shown is not missed
+	var lastStdoutSize, lastStderrSize int64
+	if stat, err := os.Stat(stdoutPath); err == nil {
+		lastStdoutSize = stat.Size()
+	}
+	if stat, err := os.Stat(stderrPath); err == nil {
+		lastStderrSize = stat.Size()
+	}
 
-	// For now, we'll just display existing logs and then poll for new content
-	err := displayLogs(stdoutPath, stderrPath, showTimestamps, -1, since, until)
+	err := displayLogs(stdoutPath, stderrPath, showTimestamps, tailLines, since, until)
 	if err != nil {
 		return err
 	}
 
-	// TODO: Implement real-time following
-	// This would require tracking file positions and polling for new content
-	fmt.Println("\n[Following logs - press Ctrl+C to exit...]")
+	if logsFormat == "text" {
+		fmt.Println("\n[Following logs - press Ctrl+C to exit...]")
+	}
 
 	// Simple polling implementation (not efficient, but functional)
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	var lastStdoutSize, lastStderrSize int64
-
-	// Get initial file sizes
-	if stat, err := os.Stat(stdoutPath); err == nil {
-		lastStdoutSize = stat.Size()
-	}
-	if stat, err := os.Stat(stderrPath); err == nil {
-		lastStderrSize = stat.Size()
-	}
+	for range ticker.C {
+		// Checked before reading, so the output written before it stopped is shown
+		running := false
+		if cs, err := sm.LoadContainer(containerID); err == nil {
+			running = cs.Status == state.StatusRunning
+		}
 
-	for {
-		select {
-		case <-ticker.C:
-			// Check for new content in stdout
-			if stat, err := os.Stat(stdoutPath); err == nil && stat.Size() > lastStdoutSize {
-				if err := displayNewLogContent(stdoutPath, lastStdoutSize, "stdout", showTimestamps); err != nil {
-					logger.Error("Failed to read new stdout content: %v", err)
-				}
-				lastStdoutSize = stat.Size()
+		// Check for new content in stdout
+		if stat, err := os.Stat(stdoutPath); err == nil && stat.Size() > lastStdoutSize {
+			if lastStdoutSize, err = displayNewLogContent(stdoutPath, lastStdoutSize, "stdout", showTimestamps); err != nil {
+				logger.Error("Failed to read new stdout content: %v", err)
 			}
+		}
 
-			// Check for new content in stderr
-			if stat, err := os.Stat(stderrPath); err == nil && stat.Size() > lastStderrSize {
-				if err := displayNewLogContent(stderrPath, lastStderrSize, "stderr", showTimestamps); err != nil {
-					logger.Error("Failed to read new stderr content: %v", err)
-				}
-				lastStderrSize = stat.Size()
+		// Check for new content in stderr
+		if stat, err := os.Stat(stderrPath); err == nil && stat.Size() > lastStderrSize {
+			if lastStderrSize, err = displayNewLogContent(stderrPath, lastStderrSize, "stderr", showTimestamps); err != nil {
+				logger.Error("Failed to read new stderr content: %v", err)
 			}
 		}
+
+		if !running {
+			return nil
+		}
 	}
+	return nil
 }
 
 // LogLine represents a single log line with metadata
@@ -261,43 +288,68 @@ func readLogFile(path, stream string, since, until time.Time) ([]LogLine, error)
 			continue
 		}
 
-		// Parse timestamp if present (format: YYYY-MM-DDTHH:MM:SS.sssZ message)
-		timestamp := time.Now() // Default to current time
-		content := line
-
-		// Try to parse RFC3339 timestamp at the beginning
-		if len(line) > 20 && line[19] == '.' || len(line) > 19 && (line[19] == 'Z' || line[19] == '+' || line[19] == '-') {
-			if t, err := time.Parse(time.RFC3339Nano, line[:20]+"Z"); err == nil {
-				timestamp = t
-				if len(line) > 21 {
-					content = line[21:] // Skip timestamp and space
-				}
-			} else if t, err := time.Parse(time.RFC3339, line[:20]); err == nil {
-				timestamp = t
-				if len(line) > 21 {
-					content = line[21:] // Skip timestamp and space
-				}
-			}
-		}
+		logLine := parseLogLine(line, stream)
 
 		// Apply time filters
-		if !since.IsZero() && timestamp.Before(since) {
+		if !since.IsZero() && logLine.Timestamp.Before(since) {
 			continue
 		}
-		if !until.IsZero() && timestamp.After(until) {
+		if !until.IsZero() && logLine.Timestamp.After(until) {
 			continue
 		}
 
-		lines = append(lines, LogLine{
-			Timestamp: timestamp,
-			Stream:    stream,
-			Content:   content,
-		})
+		lines = append(lines, logLine)
 	}
 
 	return lines, scanner.Err()
 }
 
+// parseLogLine splits a line of a log file into its timestamp, if it has
+// one, and its content
+func parseLogLine(line, stream string) LogLine {
+	// Parse timestamp if present (format: YYYY-MM-DDTHH:MM:SS.sssZ message)
+	timestamp := time.Now() // Default to current time
+	content := line
+
+	// Try to parse RFC3339 timestamp at the beginning
+	if len(line) > 20 && line[19] == '.' || len(line) > 19 && (line[19] == 'Z' || line[19] == '+' || line[19] == '-') {
+		if t, err := time.Parse(time.RFC3339Nano, line[:20]+"Z"); err == nil {
+			timestamp = t
+			if len(line) > 21 {
+				content = line[21:] // Skip timestamp and space
+			}
+		} else if t, err := time.Parse(time.RFC3339, line[:20]); err == nil {
+			timestamp = t
+			if len(line) > 21 {
+				content = line[21:] // Skip timestamp and space
+			}
+		}
+	}
+
+	return LogLine{
+		Timestamp: timestamp,
+		Stream:    stream,
+		Content:   content,
+	}
+}
+
+// printLogLine prints a log line in the format asked for
+func printLogLine(line LogLine, showTimestamps bool) {
+	if logsFormat == "json" {
+		data, err := json.Marshal(logLineJSON{Time: line.Timestamp, Stream: line.Stream, Line: line.Content})
+		if err == nil {
+			fmt.Println(string(data))
+		}
+		return
+	}
+
+	if showTimestamps {
+		logRender.print(fmt.Sprintf("%s [%s] ", line.Timestamp.Format(time.RFC3339), line.Stream), line.Content)
+	} else {
+		logRender.print("", line.Content)
+	}
+}
+
 // sortLogLines sorts log lines by timestamp
 func sortLogLines(lines []LogLine) {
 	// Simple bubble sort (fine for typical log volumes)
@@ -311,34 +363,83 @@ func sortLogLines(lines []LogLine) {
 	}
 }
 
-// displayNewLogContent shows new content from a specific position in the file
-func displayNewLogContent(path string, startPos int64, stream string, showTimestamps bool) error {
+// displayNewLogContent shows the complete lines written to a log file from
+// a position on, and returns the position after them; a line still being
+// written is left for the next call
+func displayNewLogContent(path string, startPos int64, stream string, showTimestamps bool) (int64, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return err
+		return startPos, err
 	}
 	defer file.Close()
 
 	// Seek to the start position
 	if _, err := file.Seek(startPos, 0); err != nil {
-		return err
+		return startPos, err
 	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+	pos := startPos
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// A partial line is read again once it is complete
+			return pos, nil
+		}
+		pos += int64(len(line))
+
+		line = strings.TrimRight(line, "\r\n")
 		if line == "" {
 			continue
 		}
+		printLogLine(parseLogLine(line, stream), showTimestamps)
+	}
+}
 
-		if showTimestamps {
-			fmt.Printf("%s [%s] %s\n", time.Now().Format(time.RFC3339), stream, line)
-		} else {
-			fmt.Printf("%s\n", line)
-		}
+// logRenderer fits log lines to the width of the terminal for --wrap and
+// --truncate
+type logRenderer struct {
+	// width is the terminal width in columns, 0 while not fitting lines
+	width atomic.Int64
+}
+
+// logRender prints the lines of servin logs
+var logRender logRenderer
+
+// track follows the terminal width until the returned function is called
+func (r *logRenderer) track() func() {
+	update := func() {
+		r.width.Store(int64(terminal.Width(os.Stdout)))
+	}
+	update()
+	return terminal.OnResize(update)
+}
+
+// print writes a log line after its prefix, such as the timestamp. Wrapped
+// lines continue under the content, not the prefix, when there is room.
+func (r *logRenderer) print(prefix, content string) {
+	width := int(r.width.Load())
+	line := []rune(prefix + content)
+	if width <= 0 || len(line) <= width {
+		fmt.Printf("%s%s\n", prefix, content)
+		return
 	}
 
-	return scanner.Err()
+	if logsTrunc {
+		fmt.Printf("%s…\n", string(line[:width-1]))
+		return
+	}
+
+	indent := len([]rune(prefix))
+	if indent > width/2 {
+		indent = 0
+	}
+	fmt.Println(string(line[:width]))
+	for rest := line[width:]; len(rest) > 0; {
+		n := min(width-indent, len(rest))
+		fmt.Printf("%s%s\n", strings.Repeat(" ", indent), string(rest[:n]))
+		rest = rest[n:]
+	}
 }
 
 // parseTimeOption parses various time formats for since/until options
@@ -353,10 +454,22 @@ func parseTimeOption(timeStr string) (time.Time, error) {
 		return t, nil
 	}
 
+	// Try a date, in local time
+	if t, err := time.ParseInLocation("2006-01-02", timeStr, time.Local); err == nil {
+		return t, nil
+	}
+
 	// Try relative time format (e.g., "1h", "30m", "45s")
 	if duration, err := time.ParseDuration(timeStr); err == nil {
 		return time.Now().Add(-duration), nil
 	}
 
+	// Try relative days (e.g., "30d")
+	if days, ok := strings.CutSuffix(timeStr, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+
 	return time.Time{}, fmt.Errorf("invalid time format: %s", timeStr)
 }
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"servin/pkg/errors"
+	"servin/pkg/image"
+	"servin/pkg/state"
+	"servin/pkg/tenancy"
+
+	"github.com/spf13/cobra"
+)
+
+var namespaceCmd = &cobra.Command{
+	Use:     "namespace",
+	Aliases: []string{"ns"},
+	Short:   "Manage namespaces",
+	Long: `Manage namespaces. Containers and images are scoped to a namespace, so one
+servin installation can serve isolated project- or user-scoped views.
+
+The active namespace is taken from the --namespace flag, then the
+SERVIN_NAMESPACE environment variable, then 'servin namespace use', and
+defaults to "default".`,
+}
+
+var namespaceLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List namespaces",
+	RunE:    runNamespaceList,
+}
+
+var namespaceCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Create a namespace",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNamespaceCreate,
+}
+
+var namespaceRmCmd = &cobra.Command{
+	Use:     "rm NAME",
+	Aliases: []string{"remove"},
+	Short:   "Remove an empty namespace",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runNamespaceRemove,
+}
+
+var namespaceUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "Set the namespace used when --namespace is not given",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runNamespaceUse,
+}
+
+var namespaceQuiet bool
+
+func init() {
+	namespaceCmd.AddCommand(namespaceLsCmd)
+	namespaceCmd.AddCommand(namespaceCreateCmd)
+	namespaceCmd.AddCommand(namespaceRmCmd)
+	namespaceCmd.AddCommand(namespaceUseCmd)
+
+	namespaceLsCmd.Flags().BoolVarP(&namespaceQuiet, "quiet", "q", false, "Only display namespace names")
+
+	rootCmd.AddCommand(namespaceCmd)
+}
+
+func runNamespaceList(cmd *cobra.Command, args []string) error {
+	names, err := tenancy.List()
+	if err != nil {
+		return err
+	}
+
+	if namespaceQuiet {
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	current := tenancy.Current()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCONTAINERS\tIMAGES\tACTIVE")
+	for _, name := range names {
+		containers, images := namespaceUsage(name)
+		active := ""
+		if name == current {
+			active = "*"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", name, containers, images, active)
+	}
+	return w.Flush()
+}
+
+func runNamespaceCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if tenancy.Exists(name) {
+		return fmt.Errorf("namespace '%s' already exists", name)
+	}
+	if err := tenancy.Create(name); err != nil {
+		return err
+	}
+
+	fmt.Println(name)
+	return nil
+}
+
+func runNamespaceRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if !tenancy.Exists(name) {
+		return errors.NewNotFoundError("namespace rm", fmt.Sprintf("namespace '%s' not found", name))
+	}
+
+	containers, images := namespaceUsage(name)
+	if containers > 0 || images > 0 {
+		return fmt.Errorf("namespace '%s' is not empty: %d containers, %d images", name, containers, images)
+	}
+	if err := tenancy.Remove(name); err != nil {
+		return err
+	}
+
+	fmt.Println(name)
+	return nil
+}
+
+func runNamespaceUse(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if !tenancy.Exists(name) {
+		return errors.NewNotFoundError("namespace use", fmt.Sprintf("namespace '%s' not found (create it with 'servin namespace create %s')", name, name))
+	}
+	if err := tenancy.Use(name); err != nil {
+		return err
+	}
+
+	fmt.Printf("Now using namespace '%s'\n", name)
+	return nil
+}
+
+// namespaceUsage counts the containers and images in a namespace
+func namespaceUsage(name string) (containers int, images int) {
+	if list, err := state.NewStateManager().InNamespace(name).ListContainers(); err == nil {
+		containers = len(list)
+	}
+	if list, err := image.NewManager().InNamespace(name).ListImages(); err == nil {
+		images = len(list)
+	}
+	return containers, images
+}
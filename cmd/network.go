@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
+	"servin/pkg/container"
+	"servin/pkg/errors"
 	"servin/pkg/network"
+	"servin/pkg/state"
 
 	"github.com/spf13/cobra"
 )
@@ -29,28 +35,206 @@ var networkInspectCmd = &cobra.Command{
 	RunE:  inspectNetwork,
 }
 
+var networkCreateCmd = &cobra.Command{
+	Use:   "create NETWORK",
+	Short: "Create a network",
+	Long: `Create a user-defined network.
+
+Each network can carry its own DNS configuration. Upstream nameservers, search
+domains, resolver options and static host entries set on a network are written
+into the resolv.conf and hosts files of every container attached to it, taking
+precedence over the host's defaults.
+
+Examples:
+  servin network create backend
+  servin network create --subnet 10.10.0.0/24 --gateway 10.10.0.1 backend
+  servin network create --ipv6 backend
+  servin network create --ipv6 --ipv6-subnet fd00:10::/64 backend
+  servin network create --dns 10.0.0.2 --dns-search corp.example backend
+  servin network create --add-host db.internal:10.0.0.5 backend`,
+	Args: cobra.ExactArgs(1),
+	RunE: createNetwork,
+}
+
+var networkUpdateCmd = &cobra.Command{
+	Use:   "update NETWORK",
+	Short: "Update the DNS configuration of a network",
+	Long: `Update the DNS configuration of a user-defined network.
+
+Each flag replaces the corresponding setting; settings that are not given are
+left unchanged. Changes apply to containers started after the update.
+
+Examples:
+  servin network update --dns 1.1.1.1 --dns 1.0.0.1 backend
+  servin network update --add-host cache.internal:10.0.0.9 backend
+  servin network update --remove-host cache.internal backend
+  servin network update --reset-dns backend`,
+	Args: cobra.ExactArgs(1),
+	RunE: updateNetwork,
+}
+
+var networkReassignCmd = &cobra.Command{
+	Use:   "reassign NETWORK",
+	Short: "Move a bridge network to another subnet",
+	Long: `Move a bridge network to another subnet.
+
+Networks created without --subnet, and the default servin0 bridge, get a
+private subnet that no host interface or route uses. A VPN connected later
+can push routes overlapping it, making hosts behind the VPN unreachable from
+containers; 'servin network ls' and 'servin network inspect' flag such
+conflicts. Reassigning picks the next free subnet, or the one given with
+--subnet, and a new gateway.
+
+Containers on the network must be stopped first; they get addresses in the
+new subnet when started again.
+
+Examples:
+  servin network reassign servin0
+  servin network reassign --subnet 10.200.0.0/24 backend`,
+	Args: cobra.ExactArgs(1),
+	RunE: reassignNetwork,
+}
+
+var networkRmCmd = &cobra.Command{
+	Use:     "rm NETWORK [NETWORK...]",
+	Aliases: []string{"remove"},
+	Short:   "Remove one or more networks",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    removeNetworks,
+}
+
+var networkConnectCmd = &cobra.Command{
+	Use:   "connect NETWORK CONTAINER",
+	Short: "Connect a container to a network",
+	Long: `Connect a container to a bridge network besides its own.
+
+A running container gets an interface on the network at once, named eth1,
+eth2 and so on after its eth0; the default route stays on its own network.
+The container is connected again on every start until it is disconnected.
+Containers on the host network or without one cannot be connected.
+
+Examples:
+  servin network connect backend web
+  servin network connect servin0 worker`,
+	Args: cobra.ExactArgs(2),
+	RunE: connectNetwork,
+}
+
+var networkDisconnectCmd = &cobra.Command{
+	Use:   "disconnect NETWORK CONTAINER",
+	Short: "Disconnect a container from a network",
+	Long: `Disconnect a container from a network it was connected to with
+'servin network connect'. A running container loses its interface on the
+network at once. A container cannot be disconnected from the network it was
+created on.`,
+	Args: cobra.ExactArgs(2),
+	RunE: disconnectNetwork,
+}
+
+var (
+	networkDriver      string
+	networkSubnet      string
+	networkGateway     string
+	networkIPv6        bool
+	networkSubnet6     string
+	networkGateway6    string
+	networkDNS         []string
+	networkDNSSearch   []string
+	networkDNSOptions  []string
+	networkAddHosts    []string
+	networkRemoveHosts []string
+	networkResetDNS    bool
+	networkJSON        bool
+	networkLsFormat    string
+)
+
 func init() {
 	rootCmd.AddCommand(networkCmd)
 	networkCmd.AddCommand(networkLsCmd)
 	networkCmd.AddCommand(networkInspectCmd)
+	networkCmd.AddCommand(networkCreateCmd)
+	networkCmd.AddCommand(networkUpdateCmd)
+	networkCmd.AddCommand(networkRmCmd)
+	networkCmd.AddCommand(networkReassignCmd)
+	networkCmd.AddCommand(networkConnectCmd)
+	networkCmd.AddCommand(networkDisconnectCmd)
+
+	networkCreateCmd.Flags().StringVarP(&networkDriver, "driver", "d", "bridge", "Network driver (bridge, host, none)")
+	networkCreateCmd.Flags().StringVar(&networkSubnet, "subnet", "", "Subnet in CIDR format")
+	networkCreateCmd.Flags().StringVar(&networkGateway, "gateway", "", "Gateway for the subnet")
+	networkCreateCmd.Flags().BoolVar(&networkIPv6, "ipv6", false, "Give containers an IPv6 address as well (dual-stack)")
+	networkCreateCmd.Flags().StringVar(&networkSubnet6, "ipv6-subnet", "", "IPv6 subnet in CIDR format (default: a free unique local /64)")
+	networkCreateCmd.Flags().StringVar(&networkGateway6, "ipv6-gateway", "", "IPv6 gateway for the subnet")
+
+	for _, c := range []*cobra.Command{networkCreateCmd, networkUpdateCmd} {
+		c.Flags().StringSliceVar(&networkDNS, "dns", nil, "Upstream DNS server for containers on this network")
+		c.Flags().StringSliceVar(&networkDNSSearch, "dns-search", nil, "DNS search domain")
+		c.Flags().StringSliceVar(&networkDNSOptions, "dns-option", nil, "resolv.conf option (e.g. ndots:2)")
+		c.Flags().StringSliceVar(&networkAddHosts, "add-host", nil, "Static host entry (host:ip)")
+	}
+	networkUpdateCmd.Flags().StringSliceVar(&networkRemoveHosts, "remove-host", nil, "Remove a static host entry")
+	networkUpdateCmd.Flags().BoolVar(&networkResetDNS, "reset-dns", false, "Remove all DNS settings so the host defaults apply")
+
+	networkReassignCmd.Flags().StringVar(&networkSubnet, "subnet", "", "Subnet in CIDR format (default: next free private subnet)")
+
+	networkInspectCmd.Flags().BoolVarP(&networkJSON, "format", "f", false, "Format output as JSON")
+	networkLsCmd.Flags().StringVar(&networkLsFormat, "format", "table", "Output format (table, json)")
 }
 
 func listNetworks(cmd *cobra.Command, args []string) error {
+	if networkLsFormat != "table" && networkLsFormat != "json" {
+		return errors.NewValidationError("network ls", fmt.Sprintf("unknown format '%s' (expected table or json)", networkLsFormat))
+	}
 	if err := checkRoot(); err != nil {
 		return err
 	}
 
-	nm := network.NewNetworkManager()
+	store := network.NewStore()
+	networks, err := store.List()
+	if err != nil {
+		return err
+	}
+	def, err := store.Default()
+	if err != nil {
+		return err
+	}
+
+	if networkLsFormat == "json" {
+		data, err := json.MarshalIndent(append([]*network.NetworkConfig{def}, networks...), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode networks: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("%-15s %-10s %-15s %-20s %-10s %-18s\n",
+		"NETWORK ID", "NAME", "DRIVER", "SCOPE", "IPAM", "SUBNET")
 
-	fmt.Printf("%-15s %-10s %-15s %-20s %-10s\n",
-		"NETWORK ID", "NAME", "DRIVER", "SCOPE", "IPAM")
+	// The default bridge network is always present
+	routes := network.HostRoutes()
+	var conflicts []string
+	for _, n := range append([]*network.NetworkConfig{def}, networks...) {
+		subnet := n.Subnet
+		if subnet == "" {
+			subnet = "-"
+		}
+		overlapping := network.SubnetConflicts(n.Subnet, routes)
+		if len(overlapping) > 0 {
+			subnet += " (!)"
+		}
+		for _, r := range overlapping {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s) overlaps host route %s; run 'servin network reassign %s'",
+				n.Name, n.Subnet, r, n.Name))
+		}
+		fmt.Printf("%-15s %-10s %-15s %-20s %-10s %-18s\n",
+			n.Name, n.Name, n.Driver, "local", "default", subnet)
+	}
 
-	// For now, just show the default network
-	// In a full implementation, you'd have network persistence
-	fmt.Printf("%-15s %-10s %-15s %-20s %-10s\n",
-		"servin0", "servin0", "bridge", "local", "default")
+	for _, c := range conflicts {
+		fmt.Printf("Warning: %s\n", c)
+	}
 
-	_ = nm // Avoid unused variable warning
 	return nil
 }
 
@@ -64,11 +248,380 @@ func inspectNetwork(cmd *cobra.Command, args []string) error {
 		networkName = args[0]
 	}
 
-	fmt.Printf("Network: %s\n", networkName)
-	fmt.Printf("Driver: bridge\n")
-	fmt.Printf("Subnet: 172.17.0.0/16\n")
-	fmt.Printf("Gateway: 172.17.0.1\n")
-	fmt.Printf("Containers: (network inspection not fully implemented)\n")
+	store := network.NewStore()
+	var n *network.NetworkConfig
+	var err error
+	if networkName == network.DefaultBridge || network.IsBuiltinNetwork(networkName) {
+		n, err = store.Default()
+	} else {
+		n, err = store.Get(networkName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if networkJSON {
+		data, err := json.MarshalIndent(n, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode network: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Network: %s\n", n.Name)
+	fmt.Printf("Driver: %s\n", n.Driver)
+	if n.Subnet != "" {
+		fmt.Printf("Subnet: %s\n", n.Subnet)
+	}
+	if n.Gateway != "" {
+		fmt.Printf("Gateway: %s\n", n.Gateway)
+	}
+	if n.IPv6 {
+		fmt.Printf("IPv6 Subnet: %s\n", n.Subnet6)
+		fmt.Printf("IPv6 Gateway: %s\n", n.Gateway6)
+	}
+	if network.NetworkMode(n.Driver) == network.BridgeMode {
+		fmt.Printf("Bridge: %s\n", n.BridgeName())
+	}
+	fmt.Printf("Created: %s\n", n.CreatedAt.Format("2006-01-02 15:04:05"))
+	if conflicts := network.SubnetConflicts(n.Subnet, network.HostRoutes()); len(conflicts) > 0 {
+		fmt.Println("Conflicts:")
+		for _, r := range conflicts {
+			fmt.Printf("  %s\n", r)
+		}
+		fmt.Printf("  Run 'servin network reassign %s' to move the network to a free subnet\n", n.Name)
+	}
+	showNetworkDNS(network.DefaultDNSConfig().Merge(n.DNS))
+	showNetworkContainers(n.Name)
+
+	return nil
+}
+
+// showNetworkContainers prints the containers on a network with their
+// addresses, for those running
+func showNetworkContainers(name string) {
+	containers, err := networkContainers(name)
+	if err != nil || len(containers) == 0 {
+		return
+	}
+	fmt.Println("Containers:")
+	for _, c := range containers {
+		addr := c.AddressOn(name)
+		if addr == "" {
+			addr = "(" + c.Status + ")"
+		} else if addr6 := c.Address6On(name); addr6 != "" {
+			addr += ", " + addr6
+		}
+		fmt.Printf("  %-20s %-14s %s\n", c.Name, c.ID[:12], addr)
+	}
+}
+
+// networkContainers returns the containers of every namespace that are on
+// the named network, as their own or one they were connected to
+func networkContainers(name string) ([]*state.ContainerState, error) {
+	containers, err := state.NewStateManager().AllNamespaces().ListContainers()
+	if err != nil {
+		return nil, err
+	}
+	var on []*state.ContainerState
+	for _, c := range containers {
+		if c.OnNetwork(name) {
+			on = append(on, c)
+		}
+	}
+	return on, nil
+}
+
+// showNetworkDNS prints the effective DNS configuration for containers on a network
+func showNetworkDNS(dns *network.DNSConfig) {
+	fmt.Println("DNS:")
+	fmt.Printf("  Servers: %s\n", joinOrNone(dns.Servers))
+	fmt.Printf("  Search: %s\n", joinOrNone(dns.Search))
+	fmt.Printf("  Options: %s\n", joinOrNone(dns.Options))
+	if len(dns.Hosts) > 0 {
+		fmt.Println("  Hosts:")
+		hosts := make([]string, 0, len(dns.Hosts))
+		for host := range dns.Hosts {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			fmt.Printf("    %s -> %s\n", host, dns.Hosts[host])
+		}
+	}
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "(none)"
+	}
+	return strings.Join(values, ", ")
+}
+
+func createNetwork(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	hosts, err := parseHostEntries(networkAddHosts)
+	if err != nil {
+		return err
+	}
+
+	config := &network.NetworkConfig{
+		Name:    args[0],
+		Driver:  networkDriver,
+		Subnet:  networkSubnet,
+		Gateway: networkGateway,
+
+		// An IPv6 subnet or gateway implies IPv6
+		IPv6:     networkIPv6 || networkSubnet6 != "" || networkGateway6 != "",
+		Subnet6:  networkSubnet6,
+		Gateway6: networkGateway6,
+	}
+
+	dns := &network.DNSConfig{
+		Servers: networkDNS,
+		Search:  networkDNSSearch,
+		Options: networkDNSOptions,
+		Hosts:   hosts,
+	}
+	if len(dns.Servers) > 0 || len(dns.Search) > 0 || len(dns.Options) > 0 || len(dns.Hosts) > 0 {
+		config.DNS = dns
+	}
+
+	if err := network.NewStore().Create(config); err != nil {
+		return err
+	}
+
+	fmt.Println(config.Name)
+	return nil
+}
+
+func updateNetwork(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	store := network.NewStore()
+	config, err := store.Get(args[0])
+	if err != nil {
+		return err
+	}
+
+	hosts, err := parseHostEntries(networkAddHosts)
+	if err != nil {
+		return err
+	}
+
+	dns := &network.DNSConfig{}
+	if config.DNS != nil && !networkResetDNS {
+		dns = config.DNS
+	}
+
+	if cmd.Flags().Changed("dns") {
+		dns.Servers = networkDNS
+	}
+	if cmd.Flags().Changed("dns-search") {
+		dns.Search = networkDNSSearch
+	}
+	if cmd.Flags().Changed("dns-option") {
+		dns.Options = networkDNSOptions
+	}
+	if len(hosts) > 0 && dns.Hosts == nil {
+		dns.Hosts = make(map[string]string)
+	}
+	for host, ip := range hosts {
+		dns.Hosts[host] = ip
+	}
+	for _, host := range networkRemoveHosts {
+		delete(dns.Hosts, host)
+	}
+
+	if len(dns.Servers) > 0 || len(dns.Search) > 0 || len(dns.Options) > 0 || len(dns.Hosts) > 0 {
+		config.DNS = dns
+	} else {
+		config.DNS = nil
+	}
+
+	if err := store.Save(config); err != nil {
+		return err
+	}
+
+	fmt.Printf("Network %s updated\n", config.Name)
+	return nil
+}
+
+func reassignNetwork(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	name := args[0]
+	if name == string(network.BridgeMode) {
+		name = network.DefaultBridge
+	}
+
+	if running := runningOnNetwork(name); len(running) > 0 {
+		return errors.NewConflictError("network reassign",
+			fmt.Sprintf("network %s has running containers: %s; stop them first", name, strings.Join(running, ", ")))
+	}
+
+	store := network.NewStore()
+	old, err := store.Resolve(name)
+	if err != nil {
+		return err
+	}
+
+	updated, err := store.Reassign(name, networkSubnet)
+	if err != nil {
+		return err
+	}
+
+	// Drop the network's bridge and resolver so the next container start
+	// recreates them on the new subnet
+	network.StopResolver(old.Name)
+	if err := network.ResetBridge(old.BridgeName(), old.Subnet, old.Subnet6); err != nil {
+		return err
+	}
+
+	fmt.Printf("Network %s moved from %s to %s (gateway %s)\n", updated.Name, old.Subnet, updated.Subnet, updated.Gateway)
+	return nil
+}
+
+func removeNetworks(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	store := network.NewStore()
+	for _, name := range args {
+		if name == "servin0" || network.IsBuiltinNetwork(name) {
+			return errors.NewValidationError("network rm", fmt.Sprintf("cannot remove built-in network %s", name))
+		}
+		config, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		if running := runningOnNetwork(name); len(running) > 0 {
+			return errors.NewConflictError("network rm",
+				fmt.Sprintf("network %s has running containers: %s; stop or disconnect them first", name, strings.Join(running, ", ")))
+		}
+		if network.NetworkMode(config.Driver) == network.BridgeMode {
+			network.StopResolver(name)
+			if err := network.ResetBridge(config.BridgeName(), config.Subnet, config.Subnet6); err != nil {
+				return err
+			}
+		}
+		if err := store.Remove(name); err != nil {
+			return err
+		}
+		fmt.Println(name)
+	}
 
 	return nil
 }
+
+// runningOnNetwork returns the names of the running containers on a network
+func runningOnNetwork(name string) []string {
+	containers, _ := networkContainers(name)
+	var running []string
+	for _, c := range containers {
+		if c.Status == state.StatusRunning {
+			running = append(running, c.Name)
+		}
+	}
+	return running
+}
+
+func connectNetwork(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	config, cs, sm, err := networkAndContainer(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if network.NetworkMode(config.Driver) != network.BridgeMode {
+		return errors.NewValidationError("network connect",
+			fmt.Sprintf("network %s uses the %s driver; only bridge networks can be connected", config.Name, config.Driver))
+	}
+	if driver := container.NetworkDriver(cs.NetworkMode); driver != network.BridgeMode {
+		return errors.NewValidationError("network connect",
+			fmt.Sprintf("container %s uses the %s network and cannot be connected to others", cs.Name, driver))
+	}
+	if cs.OnNetwork(config.Name) {
+		return errors.NewConflictError("network connect",
+			fmt.Sprintf("container %s is already on network %s", cs.Name, config.Name))
+	}
+
+	if err := container.Connect(sm, cs, config.Name); err != nil {
+		return err
+	}
+	if addr := cs.AddressOn(config.Name); addr != "" {
+		fmt.Printf("Connected %s to %s (IP: %s)\n", cs.Name, config.Name, addr)
+	} else {
+		fmt.Printf("Connected %s to %s; it joins the network when started\n", cs.Name, config.Name)
+	}
+	return nil
+}
+
+func disconnectNetwork(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	config, cs, sm, err := networkAndContainer(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	if network.CanonicalName(cs.NetworkMode) == config.Name {
+		return errors.NewValidationError("network disconnect",
+			fmt.Sprintf("%s is the network container %s was created on and cannot be disconnected", config.Name, cs.Name))
+	}
+	if !cs.OnNetwork(config.Name) {
+		return errors.NewNotFoundError("network disconnect",
+			fmt.Sprintf("container %s is not connected to network %s", cs.Name, config.Name))
+	}
+
+	if err := container.Disconnect(sm, cs, config.Name); err != nil {
+		return err
+	}
+	fmt.Printf("Disconnected %s from %s\n", cs.Name, config.Name)
+	return nil
+}
+
+// networkAndContainer looks up the network and container 'network connect'
+// and 'network disconnect' are given
+func networkAndContainer(networkName, containerRef string) (*network.NetworkConfig, *state.ContainerState, *state.StateManager, error) {
+	config, err := network.NewStore().Resolve(networkName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sm := state.NewStateManager()
+	id, err := resolveContainerRef(sm, containerRef)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cs, err := sm.LoadContainer(id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return config, cs, sm, nil
+}
+
+// parseHostEntries parses host:ip pairs into a hostname to IP map
+func parseHostEntries(entries []string) (map[string]string, error) {
+	hosts := make(map[string]string)
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.NewValidationError("network", fmt.Sprintf("invalid host entry %q (expected host:ip)", entry))
+		}
+		hosts[parts[0]] = parts[1]
+	}
+	return hosts, nil
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"net"
+	"strings"
+
+	"servin/pkg/network"
+	"servin/pkg/state"
+
+	"github.com/spf13/cobra"
+)
+
+var networkResolverCmd = &cobra.Command{
+	Use:   "resolver NETWORK",
+	Short: "Run the DNS resolver of a network",
+	Long: `Serve DNS on the gateway of a user-defined network, answering the names,
+hostnames and network aliases of its containers and forwarding other queries
+upstream. Containers on the network start it when needed; it is not meant to
+be run by hand.`,
+	Hidden:       true,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runNetworkResolver,
+}
+
+func init() {
+	networkCmd.AddCommand(networkResolverCmd)
+}
+
+func runNetworkResolver(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	resolver := &network.Resolver{
+		Network: name,
+		Lookup: func(client net.IP, host string) ([]net.IP, bool) {
+			return lookupContainer(name, client, host)
+		},
+		Upstream: func(client net.IP) []string {
+			return containerUpstream(name, client)
+		},
+		Active: func() bool {
+			return len(runningOnNetwork(name)) > 0
+		},
+	}
+	return resolver.Run()
+}
+
+// lookupContainer resolves host for a client on a network: to the addresses
+// of the running container with that name, hostname or alias on the
+// network, or on another network the client is on as well
+func lookupContainer(networkName string, client net.IP, host string) ([]net.IP, bool) {
+	containers, err := state.NewStateManager().AllNamespaces().ListContainers()
+	if err != nil {
+		return nil, false
+	}
+
+	var running []*state.ContainerState
+	networks := []string{networkName}
+	for _, c := range containers {
+		if c.Status != state.StatusRunning {
+			continue
+		}
+		running = append(running, c)
+		// Names on the default bridge are not served, as on Docker
+		if client != nil && c.AddressOn(networkName) == client.String() {
+			for _, n := range append([]string{network.CanonicalName(c.NetworkMode)}, c.Networks...) {
+				if n != network.DefaultBridge {
+					networks = append(networks, n)
+				}
+			}
+		}
+	}
+
+	for _, n := range networks {
+		for _, c := range running {
+			addr := c.AddressOn(n)
+			if addr == "" || !answersTo(c, host) {
+				continue
+			}
+			// Both addresses on networks with IPv6, for A and AAAA queries
+			var ips []net.IP
+			for _, a := range []string{addr, c.Address6On(n)} {
+				if ip := net.ParseIP(a); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+			if len(ips) > 0 {
+				return ips, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// containerUpstream returns the nameservers the running container at client
+// on a network was given with --dns, if any
+func containerUpstream(networkName string, client net.IP) []string {
+	if client == nil {
+		return nil
+	}
+	containers, _ := networkContainers(networkName)
+	for _, c := range containers {
+		if c.Status == state.StatusRunning && c.DNS != nil && c.AddressOn(networkName) == client.String() {
+			return c.DNS.Servers
+		}
+	}
+	return nil
+}
+
+// answersTo reports whether a container is known by host
+func answersTo(c *state.ContainerState, host string) bool {
+	if strings.EqualFold(c.Name, host) || strings.EqualFold(c.Hostname, host) {
+		return true
+	}
+	for _, alias := range c.NetworkAliases {
+		if strings.EqualFold(alias, host) {
+			return true
+		}
+	}
+	return false
+}
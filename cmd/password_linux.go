@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// readPassword reads a line from the terminal without echoing it
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		// Not a terminal
+		return readLine()
+	}
+
+	noEcho := *termios
+	noEcho.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &noEcho); err != nil {
+		return "", fmt.Errorf("failed to disable echo: %v", err)
+	}
+	defer func() {
+		unix.IoctlSetTermios(fd, unix.TCSETS, termios)
+		fmt.Println()
+	}()
+
+	return readLine()
+}
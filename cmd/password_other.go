@@ -0,0 +1,9 @@
+//go:build !linux
+
+package cmd
+
+// readPassword reads a line from stdin. Input is echoed on this platform;
+// use --password-stdin to avoid it.
+func readPassword() (string, error) {
+	return readLine()
+}
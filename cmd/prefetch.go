@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"servin/pkg/compose"
+	"servin/pkg/config"
+	"servin/pkg/errors"
+	"servin/pkg/image"
+	"servin/pkg/jobs"
+	"servin/pkg/logger"
+	"servin/pkg/ratelimit"
+)
+
+// defaultPrefetchInterval is how often the pre-pull list is checked for
+// missing images unless config.yaml sets daemon.prefetch.interval
+const defaultPrefetchInterval = 6 * time.Hour
+
+// prefetcher pulls the images on the daemon's pre-pull list in the
+// background, one at a time, and records its progress as jobs
+type prefetcher struct {
+	config.Prefetch
+	interval time.Duration
+	window   *offPeakWindow
+	platform image.Platform
+	// limitRate is the daemon.transfers download limit, in bytes per second
+	limitRate int64
+
+	jobs     *jobs.Store
+	manager  *image.Manager
+	lastPull map[string]time.Time // failed pulls, retried after an interval
+}
+
+// loadPrefetcher reads the pre-pull list from config.yaml. It returns nil
+// when there is nothing to prefetch.
+func loadPrefetcher() (*prefetcher, error) {
+	cfg, path, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	pc := cfg.Daemon.Prefetch
+	if len(pc.Images) == 0 && len(pc.ComposeFiles) == 0 {
+		return nil, nil
+	}
+
+	invalid := func(field, msg string) error {
+		return errors.NewConfigError("loadPrefetcher", fmt.Sprintf("%s: daemon.prefetch.%s: %s", path, field, msg))
+	}
+	p := &prefetcher{
+		Prefetch: pc,
+		interval: defaultPrefetchInterval,
+		jobs:     jobs.NewStore(),
+		manager:  image.NewManager(),
+		lastPull: make(map[string]time.Time),
+	}
+	if pc.Interval != "" {
+		if p.interval, err = time.ParseDuration(pc.Interval); err != nil || p.interval <= 0 {
+			return nil, invalid("interval", fmt.Sprintf("invalid interval '%s'", pc.Interval))
+		}
+	}
+	if pc.Window != "" {
+		if p.window, err = parseOffPeakWindow(pc.Window); err != nil {
+			return nil, invalid("window", err.Error())
+		}
+	}
+	if p.limitRate, err = ratelimit.ParseRate(cfg.Daemon.Transfers.DownloadRate); err != nil {
+		return nil, errors.NewConfigError("loadPrefetcher",
+			fmt.Sprintf("%s: daemon.transfers.download_rate: invalid rate '%s'", path, cfg.Daemon.Transfers.DownloadRate))
+	}
+	if pc.Platform != "" {
+		if p.platform, err = image.ParsePlatform(pc.Platform); err != nil {
+			if se, ok := err.(*errors.ServinError); ok {
+				return nil, invalid("platform", se.Message)
+			}
+			return nil, invalid("platform", err.Error())
+		}
+	}
+	return p, nil
+}
+
+// run checks the pre-pull list now and then every interval, or when the
+// off-peak window opens for images waiting for it, until stop is closed
+func (p *prefetcher) run(stop <-chan struct{}) {
+	for {
+		wait := p.round(stop)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// images returns the pre-pull list: the configured images followed by the
+// images of the compose files
+func (p *prefetcher) images() []string {
+	var list []string
+	seen := make(map[string]bool)
+	add := func(ref string) {
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			list = append(list, ref)
+		}
+	}
+
+	for _, ref := range p.Images {
+		add(ref)
+	}
+	for _, file := range p.ComposeFiles {
+		project, err := compose.ParseComposeFile(file)
+		if err != nil {
+			logger.Warn("Prefetch: skipping compose file %s: %v", file, err)
+			continue
+		}
+		names := make([]string, 0, len(project.Services))
+		for name := range project.Services {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(project.Services[name].Image)
+		}
+		for _, ref := range project.Prefetch {
+			add(ref)
+		}
+	}
+	return list
+}
+
+// round pulls the missing images on the list and returns how long to wait
+// before the next round
+func (p *prefetcher) round(stop <-chan struct{}) time.Duration {
+	wait := p.interval
+	list := p.images()
+
+	keep := make(map[string]bool, len(list))
+	for _, ref := range list {
+		keep[prefetchJobID(ref)] = true
+	}
+	if err := p.jobs.Retain(jobs.TypePrefetch, keep); err != nil {
+		logger.Warn("Prefetch: failed to update jobs: %v", err)
+	}
+
+	var missing []string
+	for _, ref := range list {
+		if _, err := p.findImage(ref); err == nil {
+			p.record(ref, jobs.StatusDone, "present locally", time.Time{})
+			continue
+		}
+		if last, ok := p.lastPull[ref]; ok && time.Since(last) < p.interval {
+			// Failed recently; the job keeps its error until the retry
+			continue
+		}
+		missing = append(missing, ref)
+		p.record(ref, jobs.StatusQueued, "", time.Time{})
+	}
+
+	for i, ref := range missing {
+		select {
+		case <-stop:
+			return wait
+		default:
+		}
+
+		now := time.Now()
+		if p.window != nil && !p.window.contains(now) {
+			// Leave the rest for the off-peak window
+			opens := p.window.next(now)
+			for _, waiting := range missing[i:] {
+				p.record(waiting, jobs.StatusWaiting, fmt.Sprintf("waiting for the off-peak window %s", p.window), opens)
+			}
+			if until := opens.Sub(now); until < wait {
+				wait = until
+			}
+			break
+		}
+
+		p.pull(ref)
+	}
+	return wait
+}
+
+// pull pulls one image, recording the job as it goes
+func (p *prefetcher) pull(ref string) {
+	job := &jobs.Job{ID: prefetchJobID(ref), Type: jobs.TypePrefetch, Target: ref, Status: jobs.StatusRunning, Started: time.Now()}
+	p.update(job)
+	logger.Info("Prefetch: pulling %s", ref)
+
+	err := p.manager.PullImage(ref, image.PullOptions{Platform: p.platform, LimitRate: p.limitRate})
+	job.Finished = time.Now()
+	if err != nil {
+		logger.Warn("Prefetch: failed to pull %s: %v", ref, err)
+		p.lastPull[ref] = job.Finished
+		job.Status = jobs.StatusFailed
+		job.Message = err.Error()
+		job.NextRun = job.Finished.Add(p.interval)
+	} else {
+		delete(p.lastPull, ref)
+		job.Status = jobs.StatusDone
+		job.Message = fmt.Sprintf("pulled in %s", job.Finished.Sub(job.Started).Round(time.Second))
+	}
+	p.update(job)
+}
+
+// findImage looks up a pre-pull list image, which may omit its tag
+func (p *prefetcher) findImage(ref string) (*image.Image, error) {
+	img, err := p.manager.GetImage(ref)
+	if err != nil {
+		img, err = p.manager.GetImage(ref + ":latest")
+	}
+	return img, err
+}
+
+// record sets the status of an image's job, keeping the times and message
+// of an earlier pull when the status is unchanged
+func (p *prefetcher) record(ref string, status jobs.Status, message string, nextRun time.Time) {
+	job := &jobs.Job{ID: prefetchJobID(ref), Type: jobs.TypePrefetch, Target: ref}
+	if existing, err := p.jobs.List(); err == nil {
+		for _, j := range existing {
+			if j.ID == job.ID {
+				job = j
+			}
+		}
+	}
+	if job.Status == status && status == jobs.StatusDone {
+		return
+	}
+	job.Status = status
+	job.Message = message
+	job.NextRun = nextRun
+	p.update(job)
+}
+
+func (p *prefetcher) update(job *jobs.Job) {
+	if err := p.jobs.Update(job); err != nil {
+		logger.Warn("Prefetch: failed to record job %s: %v", job.ID, err)
+	}
+}
+
+func prefetchJobID(ref string) string {
+	return jobs.TypePrefetch + ":" + ref
+}
+
+// offPeakWindow is a daily time window in local time, which may wrap past
+// midnight
+type offPeakWindow struct {
+	start, end int // minutes after midnight
+}
+
+// parseOffPeakWindow parses a window such as "22:00-06:00"
+func parseOffPeakWindow(spec string) (*offPeakWindow, error) {
+	var startH, startM, endH, endM int
+	if n, err := fmt.Sscanf(spec, "%d:%d-%d:%d", &startH, &startM, &endH, &endM); err != nil || n != 4 ||
+		startH < 0 || startH > 23 || endH < 0 || endH > 24 || startM < 0 || startM > 59 || endM < 0 || endM > 59 {
+		return nil, fmt.Errorf("invalid window '%s', expected HH:MM-HH:MM", spec)
+	}
+	w := &offPeakWindow{start: startH*60 + startM, end: endH*60 + endM}
+	if w.start == w.end {
+		return nil, fmt.Errorf("invalid window '%s': start and end are the same", spec)
+	}
+	return w, nil
+}
+
+func (w *offPeakWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// next returns when the window next opens after t
+func (w *offPeakWindow) next(t time.Time) time.Time {
+	opens := time.Date(t.Year(), t.Month(), t.Day(), w.start/60, w.start%60, 0, 0, t.Location())
+	if !opens.After(t) {
+		opens = opens.AddDate(0, 0, 1)
+	}
+	return opens
+}
+
+func (w *offPeakWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
+	"servin/pkg/credentials"
+	"servin/pkg/errors"
+	"servin/pkg/image"
 	"servin/pkg/logger"
 	"servin/pkg/registry"
 
@@ -69,38 +75,103 @@ Examples:
 }
 
 var loginCmd = &cobra.Command{
-	Use:   "login REGISTRY_URL",
+	Use:   "login [REGISTRY]",
 	Short: "Login to a registry",
-	Long: `Authenticate with a registry to enable push/pull operations.
+	Long: `Authenticate with a registry (Docker Hub by default). The credentials are
+checked against the registry and stored for image pull and image push.
+
+Credentials are stored in ~/.servin/auth.json, or in the OS keychain when a
+Docker credential helper is configured there ("credsStore" or "credHelpers").
+On macOS and Windows the keychain is used automatically when
+docker-credential-osxkeychain or docker-credential-wincred is installed.
 
 Examples:
-  servin registry login docker.io
-  servin registry login localhost:5001`,
-	Args: cobra.ExactArgs(1),
+  servin registry login
+  servin registry login ghcr.io -u octocat
+  echo "$TOKEN" | servin registry login registry.example.com -u ci --password-stdin`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runLogin,
 }
 
 var logoutCmd = &cobra.Command{
-	Use:   "logout REGISTRY_URL",
+	Use:   "logout [REGISTRY]",
 	Short: "Logout from a registry",
-	Long: `Remove authentication credentials for a registry.
+	Long: `Remove stored credentials for a registry (Docker Hub by default).
 
 Examples:
-  servin registry logout docker.io
+  servin registry logout
   servin registry logout localhost:5001`,
-	Args: cobra.ExactArgs(1),
+	Args: cobra.MaximumNArgs(1),
 	RunE: runLogout,
 }
 
+// servin login and servin logout are shortcuts for the registry subcommands
+var rootLoginCmd = &cobra.Command{
+	Use:   loginCmd.Use,
+	Short: loginCmd.Short,
+	Long:  loginCmd.Long,
+	Args:  loginCmd.Args,
+	RunE:  runLogin,
+}
+
+var rootLogoutCmd = &cobra.Command{
+	Use:   logoutCmd.Use,
+	Short: logoutCmd.Short,
+	Long:  logoutCmd.Long,
+	Args:  logoutCmd.Args,
+	RunE:  runLogout,
+}
+
 var registryListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List configured registries and their status",
-	Long:  `Display information about all configured registries including status and available images.`,
-	RunE:  runRegistryList,
+	Long: `Display the local registry and the remote registries added with registry
+add, with whether they answer and whether credentials are stored for them.`,
+	RunE: runRegistryList,
+}
+
+var registryAddCmd = &cobra.Command{
+	Use:   "add NAME REGISTRY",
+	Short: "Add a remote registry to the list",
+	Long: `Add a remote registry under a name, so registry list shows it, or change the
+registry a name stands for. Use registry login to store credentials for it.
+
+Examples:
+  servin registry add github ghcr.io
+  servin registry add team registry.example.com:5000`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRegistryAdd,
+}
+
+var registryRemoveCmd = &cobra.Command{
+	Use:     "remove NAME",
+	Aliases: []string{"rm"},
+	Short:   "Remove a remote registry from the list",
+	Long: `Remove a remote registry added with registry add. Its credentials are kept;
+use registry logout to remove them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegistryRemove,
+}
+
+var registryDefaultCmd = &cobra.Command{
+	Use:   "default [REGISTRY]",
+	Short: "Show or set the default registry",
+	Long: `Show the registry that registry push and registry pull use when none is
+given, or set it. Without a default they use the local registry
+(localhost:5000). The default is kept in ~/.servin/registry/registry-config.json.
+
+Examples:
+  servin registry default                      # Show the default
+  servin registry default registry.example.com # Set it
+  servin registry default --unset              # Use the local registry again`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRegistryDefault,
 }
 
 func init() {
 	rootCmd.AddCommand(registryCmd)
+	rootCmd.AddCommand(rootLoginCmd)
+	rootCmd.AddCommand(rootLogoutCmd)
 
 	registryCmd.AddCommand(startRegistryCmd)
 	registryCmd.AddCommand(stopRegistryCmd)
@@ -109,6 +180,12 @@ func init() {
 	registryCmd.AddCommand(loginCmd)
 	registryCmd.AddCommand(logoutCmd)
 	registryCmd.AddCommand(registryListCmd)
+	registryCmd.AddCommand(registryDefaultCmd)
+	registryCmd.AddCommand(registryAddCmd)
+	registryCmd.AddCommand(registryRemoveCmd)
+
+	registryListCmd.Flags().String("format", "table", "Output format (table, json)")
+	registryDefaultCmd.Flags().Bool("unset", false, "Remove the default, so the local registry is used")
 
 	// Start registry flags
 	startRegistryCmd.Flags().Int("port", 5000, "Port for the registry server")
@@ -118,15 +195,20 @@ func init() {
 	// Push flags
 	pushCmd.Flags().Bool("force", false, "Force push even if image exists")
 	pushCmd.Flags().BoolP("quiet", "q", false, "Suppress output")
+	pushCmd.Flags().String("limit-rate", "", limitRateUsage)
 
 	// Pull flags
 	pullCmd.Flags().BoolP("quiet", "q", false, "Suppress output")
 	pullCmd.Flags().String("platform", "", "Set platform if server is multi-platform capable")
+	pullCmd.Flags().String("limit-rate", "", limitRateUsage)
 
 	// Login flags
-	loginCmd.Flags().StringP("username", "u", "", "Username for authentication")
-	loginCmd.Flags().StringP("password", "p", "", "Password for authentication")
-	loginCmd.Flags().String("email", "", "Email for authentication")
+	for _, c := range []*cobra.Command{loginCmd, rootLoginCmd} {
+		c.Flags().StringP("username", "u", "", "Username for authentication")
+		c.Flags().StringP("password", "p", "", "Password for authentication")
+		c.Flags().String("email", "", "Email for authentication (ignored)")
+		c.Flags().Bool("password-stdin", false, "Read the password from stdin")
+	}
 }
 
 func runStartRegistry(cmd *cobra.Command, args []string) error {
@@ -173,6 +255,11 @@ func runPush(cmd *cobra.Command, args []string) error {
 	// Get flags
 	force, _ := cmd.Flags().GetBool("force")
 	quiet, _ := cmd.Flags().GetBool("quiet")
+	limitFlag, _ := cmd.Flags().GetString("limit-rate")
+	rate, err := limitRate(limitFlag, true)
+	if err != nil {
+		return err
+	}
 
 	// Create registry client
 	client, err := registry.NewClient(getRegistryDataDir())
@@ -181,9 +268,10 @@ func runPush(cmd *cobra.Command, args []string) error {
 	}
 
 	options := &registry.PushOptions{
-		Registry: registryURL,
-		Force:    force,
-		Quiet:    quiet,
+		Registry:  registryURL,
+		Force:     force,
+		Quiet:     quiet,
+		LimitRate: rate,
 	}
 
 	return client.PushImage(imageName, tag, registryURL, options)
@@ -202,6 +290,11 @@ func runPull(cmd *cobra.Command, args []string) error {
 	// Get flags
 	quiet, _ := cmd.Flags().GetBool("quiet")
 	platform, _ := cmd.Flags().GetString("platform")
+	limitFlag, _ := cmd.Flags().GetString("limit-rate")
+	rate, err := limitRate(limitFlag, false)
+	if err != nil {
+		return err
+	}
 
 	// Create registry client
 	client, err := registry.NewClient(getRegistryDataDir())
@@ -210,31 +303,67 @@ func runPull(cmd *cobra.Command, args []string) error {
 	}
 
 	options := &registry.PullOptions{
-		Registry: registryURL,
-		Quiet:    quiet,
-		Platform: platform,
+		Registry:  registryURL,
+		Quiet:     quiet,
+		Platform:  platform,
+		LimitRate: rate,
 	}
 
 	return client.PullImage(imageName, tag, registryURL, options)
 }
 
 func runLogin(cmd *cobra.Command, args []string) error {
-	registryURL := args[0]
+	registryURL := credentials.DockerHub
+	if len(args) > 0 {
+		registryURL = args[0]
+	}
 
 	// Get credentials from flags or prompt
 	username, _ := cmd.Flags().GetString("username")
 	password, _ := cmd.Flags().GetString("password")
 	email, _ := cmd.Flags().GetString("email")
+	passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+
+	if passwordStdin {
+		if password != "" {
+			return fmt.Errorf("--password and --password-stdin are mutually exclusive")
+		}
+		if username == "" {
+			return fmt.Errorf("--password-stdin requires --username")
+		}
+		line, err := readLine()
+		if err != nil {
+			return fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		password = line
+	}
 
 	if username == "" {
 		fmt.Print("Username: ")
-		fmt.Scanln(&username)
+		line, err := readLine()
+		if err != nil {
+			return fmt.Errorf("failed to read username: %w", err)
+		}
+		username = strings.TrimSpace(line)
 	}
 
 	if password == "" {
 		fmt.Print("Password: ")
-		// TODO: Use a proper password input library to hide input
-		fmt.Scanln(&password)
+		line, err := readPassword()
+		if err != nil {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		password = line
+	}
+
+	if username == "" || password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+
+	// Check the credentials before storing them
+	cred, err := image.Login(registryURL, &credentials.Credential{Username: username, Password: password})
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
 	}
 
 	// Create registry client
@@ -243,16 +372,24 @@ func runLogin(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create registry client: %w", err)
 	}
 
-	if err := client.LoginToRegistry(registryURL, username, password, email); err != nil {
-		return fmt.Errorf("login failed: %w", err)
+	if cred.IdentityToken != "" {
+		err = credentials.Save(registryURL, cred)
+	} else {
+		err = client.LoginToRegistry(registryURL, username, password, email)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store credentials: %w", err)
 	}
 
-	fmt.Printf("Login succeeded for %s\n", registryURL)
+	fmt.Printf("Login succeeded for %s\n", credentials.Normalize(registryURL))
 	return nil
 }
 
 func runLogout(cmd *cobra.Command, args []string) error {
-	registryURL := args[0]
+	registryURL := credentials.DockerHub
+	if len(args) > 0 {
+		registryURL = args[0]
+	}
 
 	// Create registry client
 	client, err := registry.NewClient(getRegistryDataDir())
@@ -261,14 +398,22 @@ func runLogout(cmd *cobra.Command, args []string) error {
 	}
 
 	if err := client.LogoutFromRegistry(registryURL); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("not logged in to %s", credentials.Normalize(registryURL))
+		}
 		return fmt.Errorf("logout failed: %w", err)
 	}
 
-	fmt.Printf("Logout succeeded for %s\n", registryURL)
+	fmt.Printf("Logout succeeded for %s\n", credentials.Normalize(registryURL))
 	return nil
 }
 
 func runRegistryList(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "table" && format != "json" {
+		return errors.NewValidationError("registry list", fmt.Sprintf("unknown format '%s' (expected table or json)", format))
+	}
+
 	// Create registry client
 	client, err := registry.NewClient(getRegistryDataDir())
 	if err != nil {
@@ -280,6 +425,17 @@ func runRegistryList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get registry information: %w", err)
 	}
 
+	// Remote registries in name order after the local one
+	sort.SliceStable(registries, func(i, j int) bool {
+		return registries[i].Type == "local" || registries[j].Type != "local" && registries[i].Name < registries[j].Name
+	})
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(registries)
+	}
+
 	// Display registry information
 	fmt.Println("REGISTRY NAME        TYPE     STATUS      URL")
 	fmt.Println("----------------------------------------------------")
@@ -295,8 +451,94 @@ func runRegistryList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runRegistryAdd(cmd *cobra.Command, args []string) error {
+	name := strings.TrimSpace(args[0])
+	if name == "" || name == "local" || strings.ContainsAny(name, " \t/") {
+		return errors.NewValidationError("registry add", fmt.Sprintf("invalid name '%s'", args[0]))
+	}
+	registryURL, err := validRegistryHost("registry add", args[1])
+	if err != nil {
+		return err
+	}
+
+	client, err := registry.NewClient(getRegistryDataDir())
+	if err != nil {
+		return fmt.Errorf("failed to create registry client: %w", err)
+	}
+	if err := client.AddRegistry(name, registryURL); err != nil {
+		return fmt.Errorf("failed to save the registry: %w", err)
+	}
+
+	fmt.Printf("Registry %s added as %s\n", registryURL, name)
+	return nil
+}
+
+func runRegistryRemove(cmd *cobra.Command, args []string) error {
+	client, err := registry.NewClient(getRegistryDataDir())
+	if err != nil {
+		return fmt.Errorf("failed to create registry client: %w", err)
+	}
+	if err := client.RemoveRegistry(args[0]); err != nil {
+		if os.IsNotExist(err) {
+			return errors.NewNotFoundError("registry remove", fmt.Sprintf("no registry named %s", args[0]))
+		}
+		return fmt.Errorf("failed to save the registry config: %w", err)
+	}
+
+	fmt.Printf("Registry %s removed\n", args[0])
+	return nil
+}
+
+func runRegistryDefault(cmd *cobra.Command, args []string) error {
+	unset, _ := cmd.Flags().GetBool("unset")
+	if unset && len(args) > 0 {
+		return errors.NewValidationError("registry default", "--unset takes no registry")
+	}
+
+	client, err := registry.NewClient(getRegistryDataDir())
+	if err != nil {
+		return fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	if !unset && len(args) == 0 {
+		if current := client.DefaultRegistry(); current != "" {
+			fmt.Println(current)
+		} else {
+			fmt.Println("No default registry; the local registry is used")
+		}
+		return nil
+	}
+
+	registryURL := ""
+	if len(args) > 0 {
+		if registryURL, err = validRegistryHost("registry default", args[0]); err != nil {
+			return err
+		}
+	}
+	if err := client.SetDefaultRegistry(registryURL); err != nil {
+		return fmt.Errorf("failed to save the default registry: %w", err)
+	}
+
+	if registryURL == "" {
+		fmt.Println("Default registry removed; the local registry is used")
+	} else {
+		fmt.Printf("Default registry set to %s\n", registryURL)
+	}
+	return nil
+}
+
 // Helper functions
 
+// validRegistryHost checks a registry given as HOST[:PORT] and returns it
+// without a trailing slash
+func validRegistryHost(operation, arg string) (string, error) {
+	registryURL := strings.TrimSuffix(strings.TrimSpace(arg), "/")
+	if registryURL == "" || strings.ContainsAny(registryURL, " \t") || strings.Contains(registryURL, "://") {
+		return "", errors.NewValidationError(operation, fmt.Sprintf("invalid registry '%s' (expected HOST[:PORT], without a scheme)", arg))
+	}
+	return registryURL, nil
+}
+
 func parseImageTag(imageArg string) (string, string) {
 	parts := strings.Split(imageArg, ":")
 	if len(parts) == 1 {
@@ -313,3 +555,12 @@ func getRegistryDataDir() string {
 	}
 	return homeDir + "/.servin/registry"
 }
+
+// readLine reads a line from stdin without the line ending
+func readLine() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"servin/pkg/errors"
+	"servin/pkg/image"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	registryCatalogLimit     int
+	registryCatalogFormat    string
+	registryTagsFormat       string
+	registryManifestFormat   string
+	registryManifestPlatform string
+)
+
+var registryCatalogCmd = &cobra.Command{
+	Use:   "catalog REGISTRY",
+	Short: "List the repositories of a registry",
+	Long: `List the repositories of a registry through the Distribution API's catalog
+endpoint, using the credentials stored for the registry. Registries such as
+Docker Hub do not offer the catalog.
+
+Examples:
+  servin registry catalog registry.example.com
+  servin registry catalog -n 20 localhost:5000`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegistryCatalog,
+}
+
+var registryTagsCmd = &cobra.Command{
+	Use:   "tags REPOSITORY",
+	Short: "List the tags of a repository in a registry",
+	Long: `List the tags of a repository in a registry, without pulling it.
+
+Examples:
+  servin registry tags alpine
+  servin registry tags ghcr.io/user/app`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegistryTags,
+}
+
+var registryManifestCmd = &cobra.Command{
+	Use:   "manifest IMAGE[:TAG]",
+	Short: "Show the manifest of an image in a registry",
+	Long: `Show an image's manifest and config from its registry without pulling its
+layers: digest, platform, size, layers, entrypoint, command, ports and
+labels. For a multi-platform image the platforms are listed and the image for
+--platform is shown.
+
+Examples:
+  servin registry manifest alpine:3.20
+  servin registry manifest --platform linux/arm64 nginx:alpine`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegistryManifest,
+}
+
+func init() {
+	registryCmd.AddCommand(registryCatalogCmd)
+	registryCmd.AddCommand(registryTagsCmd)
+	registryCmd.AddCommand(registryManifestCmd)
+
+	registryCatalogCmd.Flags().IntVarP(&registryCatalogLimit, "limit", "n", 0, "List at most this many repositories (0 for all)")
+	registryCatalogCmd.Flags().StringVar(&registryCatalogFormat, "format", "table", "Output format (table, json)")
+	registryTagsCmd.Flags().StringVar(&registryTagsFormat, "format", "table", "Output format (table, json)")
+	registryManifestCmd.Flags().StringVar(&registryManifestFormat, "format", "table", "Output format (table, json)")
+	registryManifestCmd.Flags().StringVar(&registryManifestPlatform, "platform", "", "Platform to show from a multi-platform image (OS/ARCH[/VARIANT])")
+}
+
+func runRegistryCatalog(cmd *cobra.Command, args []string) error {
+	if registryCatalogFormat != "table" && registryCatalogFormat != "json" {
+		return errors.NewValidationError("registry catalog", fmt.Sprintf("unknown format '%s' (expected table or json)", registryCatalogFormat))
+	}
+	if registryCatalogLimit < 0 {
+		return errors.NewValidationError("registry catalog", "--limit must not be negative")
+	}
+	registryURL, err := validRegistryHost("registry catalog", args[0])
+	if err != nil {
+		return err
+	}
+
+	repositories, err := image.Catalog(registryURL, registryCatalogLimit)
+	if err != nil {
+		return err
+	}
+
+	if registryCatalogFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(repositories)
+	}
+	if len(repositories) == 0 {
+		fmt.Printf("No repositories in %s\n", registryURL)
+		return nil
+	}
+	for _, repository := range repositories {
+		fmt.Println(repository)
+	}
+	return nil
+}
+
+func runRegistryTags(cmd *cobra.Command, args []string) error {
+	if registryTagsFormat != "table" && registryTagsFormat != "json" {
+		return errors.NewValidationError("registry tags", fmt.Sprintf("unknown format '%s' (expected table or json)", registryTagsFormat))
+	}
+
+	tags, err := image.ListTags(args[0])
+	if err != nil {
+		return err
+	}
+
+	if registryTagsFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tags)
+	}
+	if len(tags) == 0 {
+		fmt.Printf("No tags in %s\n", args[0])
+		return nil
+	}
+	for _, tag := range tags {
+		fmt.Println(tag)
+	}
+	return nil
+}
+
+func runRegistryManifest(cmd *cobra.Command, args []string) error {
+	if registryManifestFormat != "table" && registryManifestFormat != "json" {
+		return errors.NewValidationError("registry manifest", fmt.Sprintf("unknown format '%s' (expected table or json)", registryManifestFormat))
+	}
+	var platform image.Platform
+	if registryManifestPlatform != "" {
+		var err error
+		if platform, err = image.ParsePlatform(registryManifestPlatform); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := image.InspectRemote(args[0], platform)
+	if err != nil {
+		return err
+	}
+
+	if registryManifestFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(manifest)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "Reference:\t%s\n", manifest.Reference)
+	fmt.Fprintf(w, "Digest:\t%s\n", manifest.Digest)
+	fmt.Fprintf(w, "Media type:\t%s\n", manifest.MediaType)
+	for i, p := range manifest.Platforms {
+		label := ""
+		if i == 0 {
+			label = "Platforms:"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", label, p.Platform, p.Digest)
+	}
+	if manifest.ConfigDigest == "" {
+		if platform.OS == "" {
+			platform = image.DefaultPlatform()
+		}
+		fmt.Fprintf(w, "Platform:\tnone for %s\n", platform)
+		return w.Flush()
+	}
+
+	fmt.Fprintf(w, "Platform:\t%s\n", manifest.Platform)
+	if !manifest.Created.IsZero() {
+		fmt.Fprintf(w, "Created:\t%s\n", manifest.Created.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(w, "Size:\t%s\n", formatSize(manifest.Size))
+	if len(manifest.Entrypoint) > 0 {
+		fmt.Fprintf(w, "Entrypoint:\t%s\n", strings.Join(manifest.Entrypoint, " "))
+	}
+	if len(manifest.Cmd) > 0 {
+		fmt.Fprintf(w, "Command:\t%s\n", strings.Join(manifest.Cmd, " "))
+	}
+	if len(manifest.ExposedPorts) > 0 {
+		fmt.Fprintf(w, "Ports:\t%s\n", strings.Join(manifest.ExposedPorts, ", "))
+	}
+	fmt.Fprintf(w, "Layers:\t%d\n", len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		fmt.Fprintf(w, "\t%s\t%s\n", layer.Digest, formatSize(layer.Size))
+	}
+	return w.Flush()
+}
@@ -2,8 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
+	"servin/pkg/checkpoint"
+	"servin/pkg/errors"
+	"servin/pkg/hooks"
+	"servin/pkg/shim"
 	"servin/pkg/state"
+	"servin/pkg/volume"
 
 	"github.com/spf13/cobra"
 )
@@ -13,7 +19,15 @@ var removeCmd = &cobra.Command{
 	Aliases: []string{"remove"},
 	Short:   "Remove one or more containers",
 	Long: `Remove one or more containers. By default, running containers cannot be removed.
-Use the --force flag to stop and remove running containers.`,
+Use the --force flag to stop and remove running containers.
+
+Containers labelled 'protected' (servin run --label protected) are never
+removed, not even with --force, unless --override-protection is given. Every
+override is recorded in the audit trail.
+
+With --volumes, the anonymous volumes created for a container (servin run -v
+/path, or a VOLUME of its image) are removed with it, unless another container
+mounts them. Named volumes are always kept.`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		// If --all flag is used, we don't need container arguments
 		if removeAll {
@@ -26,8 +40,9 @@ Use the --force flag to stop and remove running containers.`,
 }
 
 var (
-	forceRemove bool
-	removeAll   bool
+	forceRemove   bool
+	removeAll     bool
+	removeVolumes bool
 )
 
 func init() {
@@ -35,6 +50,8 @@ func init() {
 
 	removeCmd.Flags().BoolVarP(&forceRemove, "force", "f", false, "Force removal of running containers")
 	removeCmd.Flags().BoolVarP(&removeAll, "all", "a", false, "Remove all stopped containers")
+	removeCmd.Flags().BoolVar(&removeVolumes, "volumes", false, "Also remove the anonymous volumes of the containers")
+	removeCmd.Flags().BoolVar(&overrideProtection, "override-protection", false, "Also remove containers labelled protected")
 }
 
 func removeContainers(cmd *cobra.Command, args []string) error {
@@ -46,6 +63,8 @@ func removeContainers(cmd *cobra.Command, args []string) error {
 	sm := state.NewStateManager()
 
 	var containersToRemove []string
+	// Every container is tried; the command fails if any could not be removed
+	var failures []error
 
 	// If --all flag is used, get all stopped containers
 	if removeAll {
@@ -55,9 +74,14 @@ func removeContainers(cmd *cobra.Command, args []string) error {
 		}
 
 		for _, container := range containers {
-			if container.Status != "running" {
-				containersToRemove = append(containersToRemove, container.ID)
+			if container.Status == "running" {
+				continue
 			}
+			if isProtected(container.Labels) && !overrideProtection {
+				fmt.Printf("Skipping protected container %s\n", container.Name)
+				continue
+			}
+			containersToRemove = append(containersToRemove, container.ID)
 		}
 
 		if len(containersToRemove) == 0 {
@@ -69,7 +93,7 @@ func removeContainers(cmd *cobra.Command, args []string) error {
 		for _, containerRef := range args {
 			containerID, err := resolveContainerRef(sm, containerRef)
 			if err != nil {
-				fmt.Printf("Error: %v\n", err)
+				failures = append(failures, err)
 				continue
 			}
 			containersToRemove = append(containersToRemove, containerID)
@@ -79,10 +103,22 @@ func removeContainers(cmd *cobra.Command, args []string) error {
 	// Remove each container
 	var removedCount int
 	for _, containerID := range containersToRemove {
+		var anonymous []string
+		if cs, err := sm.LoadContainer(containerID); err == nil {
+			anonymous = cs.AnonymousVolumes
+		}
 		if err := removeContainer(sm, containerID, forceRemove); err != nil {
-			fmt.Printf("Error removing container %s: %v\n", containerID[:12], err)
-		} else {
-			removedCount++
+			failures = append(failures, err)
+			continue
+		}
+		removedCount++
+		if removeVolumes && len(anonymous) > 0 {
+			containers, err := sm.AllNamespaces().ListContainers()
+			if err != nil {
+				fmt.Printf("Warning: failed to list containers, keeping volumes: %v\n", err)
+				continue
+			}
+			removeAnonymousVolumes(anonymous, usedVolumes(containers))
 		}
 	}
 
@@ -90,26 +126,33 @@ func removeContainers(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Removed %d stopped containers\n", removedCount)
 	}
 
-	return nil
+	return argumentsError("rm", failures)
 }
 
 func removeContainer(sm *state.StateManager, containerID string, force bool) error {
 	// Load container state
 	container, err := sm.LoadContainer(containerID)
 	if err != nil {
-		return fmt.Errorf("container not found: %v", err)
+		return errors.WrapError(err, errors.ErrTypeNotFound, "rm", fmt.Sprintf("container %s not found", containerID))
+	}
+
+	if isProtected(container.Labels) {
+		if !overrideProtection {
+			return errors.NewConflictError("rm", fmt.Sprintf("container %s is protected; use --override-protection to remove it", container.Name))
+		}
+		recordOverride("rm", fmt.Sprintf("container %s (%s)", container.Name, containerID[:12]), "removed protected container with --override-protection")
 	}
 
 	// Check if container is running
 	if container.Status == "running" {
 		if !force {
-			return fmt.Errorf("cannot remove running container %s. Stop the container before removing or use --force", container.Name)
+			return errors.NewConflictError("rm", fmt.Sprintf("cannot remove running container %s. Stop the container before removing or use --force", container.Name))
 		}
 
 		// Force stop the container first
 		fmt.Printf("Stopping running container %s...\n", container.Name)
 		if container.PID > 0 {
-			if err := stopContainerProcess(container.PID); err != nil {
+			if err := stopContainerProcess(container.PID, container.StopSignal); err != nil {
 				fmt.Printf("Warning: failed to stop container process: %v\n", err)
 			}
 		}
@@ -118,6 +161,9 @@ func removeContainer(sm *state.StateManager, containerID string, force bool) err
 		if err := sm.UpdateContainerStatus(containerID, "stopped"); err != nil {
 			fmt.Printf("Warning: failed to update container status: %v\n", err)
 		}
+		if container.PID == 0 {
+			runStoppedHooks(sm, containerID)
+		}
 	}
 
 	// Remove container resources
@@ -127,13 +173,35 @@ func removeContainer(sm *state.StateManager, containerID string, force bool) err
 
 	// Remove container state file
 	if err := sm.DeleteContainer(containerID); err != nil {
-		return fmt.Errorf("failed to remove container state: %v", err)
+		return errors.WrapError(err, errors.ErrTypeIO, "rm", "failed to remove container state")
 	}
 
 	fmt.Printf("Removed container %s (%s)\n", container.Name, containerID[:12])
+	runContainerHooks(container, hooks.PostRemove)
 	return nil
 }
 
+// removeAnonymousVolumes removes the anonymous volumes named, but those
+// inUse reports another container mounts
+func removeAnonymousVolumes(names []string, inUse func(*volume.Volume) bool) {
+	volManager := volume.NewManager()
+	for _, name := range names {
+		vol, err := volManager.GetVolume(name)
+		if err != nil || !vol.Anonymous {
+			continue
+		}
+		if inUse != nil && inUse(vol) {
+			fmt.Printf("  Keeping volume %s, another container uses it\n", name)
+			continue
+		}
+		if err := volManager.RemoveVolume(name, false); err != nil {
+			fmt.Printf("Warning: failed to remove volume %s: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("  Removed volume %s\n", name)
+	}
+}
+
 // cleanupContainerResources removes container-specific resources
 func cleanupContainerResources(container *state.ContainerState) error {
 	// This function would clean up:
@@ -153,6 +221,13 @@ func cleanupContainerResources(container *state.ContainerState) error {
 		fmt.Printf("  - Would unmount %d volumes\n", len(container.Volumes))
 	}
 
+	if err := checkpoint.NewManager().RemoveAll(container.ID); err != nil {
+		fmt.Printf("  - Failed to remove checkpoints: %v\n", err)
+	}
+
+	// The shim is gone once the container exits; only its output remains
+	os.Remove(shim.LogPath(container.ID))
+
 	if container.NetworkMode == "bridge" {
 		fmt.Printf("  - Would cleanup network interfaces\n")
 	}
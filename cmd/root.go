@@ -4,11 +4,17 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
+	"sync"
 
 	"servin/pkg/errors"
 	"servin/pkg/logger"
+	"servin/pkg/telemetry"
+	"servin/pkg/tenancy"
+	"servin/pkg/vm"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var rootCmd = &cobra.Command{
@@ -17,11 +23,61 @@ var rootCmd = &cobra.Command{
 	Long: `Servin is a lightweight container runtime built from scratch in Go.
 It implements core containerization features using Linux namespaces, cgroups,
 and chroot without relying on external container runtimes.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if format, _ := cmd.Flags().GetString("errors"); format != errorsText && format != errorsJSON {
+			return errors.NewValidationError("servin", fmt.Sprintf("unknown --errors format '%s' (expected text or json)", format))
+		}
+		initAutoVM(cmd)
+		return initNamespace(cmd)
+	},
+	// Errors are reported by ReportError, in the format --errors asks for
+	SilenceErrors: true,
 }
 
+// Error report formats of --errors
+const (
+	errorsText = "text"
+	errorsJSON = "json"
+)
+
 // Execute runs the root command
 func Execute() error {
-	return rootCmd.Execute()
+	return execute(os.Args[1:])
+}
+
+// ExecuteArgs runs a servin command line in this process, as the servin-tui
+// command prompt does. What an earlier command line set, its flags,
+// --namespace and --no-auto-vm, is reset first, so each runs as it would on
+// its own.
+func ExecuteArgs(args []string) error {
+	resetFlags(rootCmd)
+	tenancy.Unset()
+	vm.ResetAutoProvision()
+	return execute(args)
+}
+
+// markUsage marks usage errors once, however many command lines run
+var markUsage sync.Once
+
+func execute(args []string) error {
+	// Usage text would mix with the error object on stderr
+	rootCmd.SilenceUsage = errorFormat(args) == errorsJSON
+	markUsage.Do(func() { markUsageErrors(rootCmd) })
+
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	// cobra reports unknown commands before any of them validates its args
+	if err != nil && strings.HasPrefix(err.Error(), "unknown command") {
+		err = errors.NewValidationError("servin", err.Error())
+	}
+
+	// Finish the command span and flush any exported telemetry
+	if span := telemetry.Root(); span != nil {
+		span.Finish(err)
+	}
+	telemetry.Shutdown()
+
+	return err
 }
 
 func init() {
@@ -30,9 +86,139 @@ func init() {
 	rootCmd.PersistentFlags().Bool("dev", false, "development mode (skip root check)")
 	rootCmd.PersistentFlags().String("log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().String("log-file", "", "log file path (default: platform-specific)")
+	rootCmd.PersistentFlags().String("namespace", "", fmt.Sprintf("namespace to scope containers and images to (default from $%s or 'servin namespace use')", tenancy.EnvVar))
+	rootCmd.PersistentFlags().Bool("trace", false, "trace this command and print the span tree (or export it when SERVIN_TELEMETRY_EXPORTER is set)")
+	rootCmd.PersistentFlags().Bool("no-auto-vm", false, fmt.Sprintf("do not create and boot the VM when a container command first needs it (also $%s)", vm.NoAutoVMEnvVar))
+	rootCmd.PersistentFlags().String("errors", errorsText, "how to report a failure on stderr: text, or json for one {\"error\": {...}} object with its category and exit code")
 
-	// Initialize logging
-	cobra.OnInitialize(initLogging)
+	// Initialize logging and telemetry
+	cobra.OnInitialize(initLogging, initTelemetry)
+}
+
+// ReportError writes err to stderr in the format given with --errors and
+// returns the exit code of its category (see errors.ExitCode)
+func ReportError(err error) int {
+	if errorFormat(os.Args[1:]) == errorsJSON {
+		os.Stderr.Write(errors.JSON(err))
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	return errors.ExitCode(err)
+}
+
+// errorFormat finds --errors in the arguments. It is read before cobra
+// parses them, so errors parsing the command line are reported in it too.
+func errorFormat(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--errors="); ok {
+			return value
+		}
+		if arg == "--errors" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return errorsText
+}
+
+// markUsageErrors makes errors in the flags and arguments of every command
+// validation errors, so they exit with errors.ExitUsage
+func markUsageErrors(cmd *cobra.Command) {
+	cmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return errors.NewValidationError(c.CommandPath(), err.Error())
+	})
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(c *cobra.Command, a []string) error {
+			if err := args(c, a); err != nil {
+				return errors.NewValidationError(c.CommandPath(), err.Error())
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
+
+// resetFlags sets every flag of cmd and its subcommands back to its default
+// and unchanged
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			var values []string
+			if def := strings.Trim(f.DefValue, "[]"); def != "" {
+				values = strings.Split(def, ",")
+			}
+			slice.Replace(values)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// initAutoVM turns automatic VM provisioning off with --no-auto-vm or
+// SERVIN_NO_AUTO_VM. Downloads of a VM provisioned on the way show their
+// progress.
+func initAutoVM(cmd *cobra.Command) {
+	vm.SetDefaultProgress(printDownloadEvent)
+	noAuto, _ := cmd.Flags().GetBool("no-auto-vm")
+	if env := strings.ToLower(os.Getenv(vm.NoAutoVMEnvVar)); env == "1" || env == "true" {
+		noAuto = true
+	}
+	if noAuto {
+		vm.SetAutoProvision(false)
+	}
+}
+
+// initNamespace selects the namespace containers and images are scoped to
+func initNamespace(cmd *cobra.Command) error {
+	name, _ := cmd.Flags().GetString("namespace")
+	if name == "" {
+		name = os.Getenv(tenancy.EnvVar)
+	}
+	if name == "" {
+		return nil
+	}
+	if err := tenancy.Set(name); err != nil {
+		return err
+	}
+	logger.Debug("Using namespace: %s", name)
+	return nil
+}
+
+// initTelemetry enables the metric/trace exporter configured in the environment
+// and starts a span covering the whole command
+func initTelemetry() {
+	config := telemetry.ConfigFromEnv()
+	if trace, _ := rootCmd.PersistentFlags().GetBool("trace"); trace && config.Exporter == "" {
+		config.Exporter = "console"
+	}
+
+	if err := telemetry.Init(config); err != nil {
+		logger.Warn("Telemetry disabled: %v", err)
+		return
+	}
+	if !telemetry.Enabled() {
+		return
+	}
+
+	name := "servin"
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil {
+		name = cmd.CommandPath()
+	}
+	span := telemetry.StartRootSpan(name)
+	span.SetAttribute("os", runtime.GOOS)
 }
 
 // initLogging initializes the logging system
@@ -2,11 +2,23 @@ package cmd
 
 import (
 	"fmt"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"servin/pkg/cgroups"
 	"servin/pkg/container"
+	"servin/pkg/health"
+	"servin/pkg/hooks"
+	"servin/pkg/image"
 	"servin/pkg/network"
+	"servin/pkg/restart"
+	"servin/pkg/state"
+	"servin/pkg/systemd"
+	"servin/pkg/telemetry"
+	"servin/pkg/volume"
 
 	"github.com/spf13/cobra"
 )
@@ -26,13 +38,46 @@ var (
 	containerName string
 	memory        string
 	cpus          string
+	cpusetCpus    string
+	cpusetMems    string
 	networkMode   string
 	volumes       []string
 	workdir       string
 	env           []string
 	hostname      string
 	ports         []string
+	publishAll    bool
 	detach        bool
+	restartPolicy string
+	hookSpecs     []string
+	runPlatform   string
+	runLabels     []string
+	networkAlias  []string
+	links         []string
+	linkEnv       bool
+	runTTY        bool
+
+	// DNS flags, applied over the DNS settings of the container's network
+	runDNS        []string
+	runDNSSearch  []string
+	runDNSOptions []string
+	runAddHosts   []string
+
+	// Health check flags (override the image HEALTHCHECK)
+	healthCmd         string
+	healthInterval    time.Duration
+	healthTimeout     time.Duration
+	healthStartPeriod time.Duration
+	healthRetries     int
+	noHealthcheck     bool
+
+	// registerMachine registers the container with systemd-machined
+	registerMachine bool
+
+	// securityOpts and ulimitSpecs restrict the container process,
+	// overriding the defaults from config.yaml
+	securityOpts []string
+	ulimitSpecs  []string
 )
 
 func init() {
@@ -42,13 +87,37 @@ func init() {
 	runCmd.Flags().StringVar(&containerName, "name", "", "Assign a name to the container")
 	runCmd.Flags().StringVar(&memory, "memory", "", "Memory limit (e.g., 128m, 1g)")
 	runCmd.Flags().StringVar(&cpus, "cpus", "", "CPU limit (e.g., 0.5, 2)")
-	runCmd.Flags().StringVar(&networkMode, "network", "bridge", "Network mode (bridge, host, none)")
-	runCmd.Flags().StringSliceVar(&volumes, "volume", []string{}, "Bind mount volumes (host:container)")
+	runCmd.Flags().StringVar(&cpusetCpus, "cpuset-cpus", "", "CPUs in which to allow execution (e.g., 0-3, 0,2)")
+	runCmd.Flags().StringVar(&cpusetMems, "cpuset-mems", "", "NUMA memory nodes in which to allow allocation (e.g., 0-1, 0)")
+	runCmd.Flags().StringVar(&networkMode, "network", "bridge", "Network mode (bridge, host, none) or the name of a user-defined network")
+	runCmd.Flags().StringArrayVar(&networkAlias, "network-alias", nil, "Add a name other containers on the network resolve this one by")
+	runCmd.Flags().StringArrayVar(&links, "link", nil, "Add a running container to /etc/hosts (NAME[:ALIAS])")
+	runCmd.Flags().BoolVar(&linkEnv, "link-env", false, "Also set legacy <ALIAS>_PORT_* environment variables for each --link")
+	runCmd.Flags().StringArrayVar(&runDNS, "dns", nil, "Set a nameserver for the container, replacing the network's")
+	runCmd.Flags().StringArrayVar(&runDNSSearch, "dns-search", nil, "Set a DNS search domain for the container, replacing the network's")
+	runCmd.Flags().StringArrayVar(&runDNSOptions, "dns-option", nil, "Set a resolv.conf option for the container (e.g. ndots:2)")
+	runCmd.Flags().StringArrayVar(&runAddHosts, "add-host", nil, "Add an /etc/hosts entry (HOST:IP, or HOST:host-gateway for the host)")
+	runCmd.Flags().StringArrayVar(&volumes, "volume", []string{}, "Bind mount a host path or volume (SOURCE:TARGET[:ro|rw])")
 	runCmd.Flags().StringVar(&workdir, "workdir", "/", "Working directory inside container")
 	runCmd.Flags().StringSliceVar(&env, "env", []string{}, "Set environment variables")
 	runCmd.Flags().StringVar(&hostname, "hostname", "", "Container hostname")
-	runCmd.Flags().StringSliceVarP(&ports, "publish", "p", []string{}, "Publish container ports (host:container or hostPort:containerPort/protocol)")
+	runCmd.Flags().StringSliceVarP(&ports, "publish", "p", []string{}, "Publish container ports (host:container or hostPort:containerPort/protocol; an empty host port is allocated)")
+	runCmd.Flags().BoolVarP(&publishAll, "publish-all", "P", false, "Publish all ports the image exposes on allocated host ports")
 	runCmd.Flags().BoolVarP(&detach, "detach", "d", false, "Run container in background and print container ID")
+	runCmd.Flags().BoolVarP(&runTTY, "tty", "t", false, "Allocate a pseudo-TTY attached to this terminal")
+	runCmd.Flags().StringVar(&healthCmd, "health-cmd", "", "Command to run to check health")
+	runCmd.Flags().DurationVar(&healthInterval, "health-interval", 0, "Time between running the health check (default 30s)")
+	runCmd.Flags().DurationVar(&healthTimeout, "health-timeout", 0, "Maximum time to allow one health check to run (default 30s)")
+	runCmd.Flags().DurationVar(&healthStartPeriod, "health-start-period", 0, "Start period for the container to initialize before failures count")
+	runCmd.Flags().IntVar(&healthRetries, "health-retries", 0, "Consecutive failures needed to report unhealthy (default 3)")
+	runCmd.Flags().BoolVar(&noHealthcheck, "no-healthcheck", false, "Disable any container-specified HEALTHCHECK")
+	runCmd.Flags().StringVar(&restartPolicy, "restart", "no", "Restart policy applied by 'servin daemon' (no, always, on-failure[:N], unless-stopped)")
+	runCmd.Flags().StringVar(&runPlatform, "platform", "", "Use the image for this platform (OS/ARCH[/VARIANT]), pulling it if the local image is for another")
+	runCmd.Flags().StringArrayVarP(&runLabels, "label", "l", nil, "Set metadata on the container (KEY=VALUE; 'protected' blocks removal)")
+	runCmd.Flags().BoolVar(&registerMachine, "register-machine", false, "Register the container with systemd-machined (machinectl) and forward its output to the journal")
+	runCmd.Flags().StringArrayVar(&securityOpts, "security-opt", nil, "Security options (seccomp=default|unconfined, no-new-privileges[=true|false])")
+	runCmd.Flags().StringArrayVar(&ulimitSpecs, "ulimit", nil, "Set a resource limit (NAME=SOFT[:HARD], e.g. nofile=1024:4096)")
+	runCmd.Flags().StringArrayVar(&hookSpecs, "hook", nil, "Run a host command at a lifecycle event (EVENT=COMMAND; events: pre-start, post-start, post-stop, post-remove)")
 }
 
 func runContainer(cmd *cobra.Command, args []string) error {
@@ -60,6 +129,90 @@ func runContainer(cmd *cobra.Command, args []string) error {
 	command := args[1]
 	commandArgs := args[2:]
 
+	policy, err := restart.ParsePolicy(restartPolicy)
+	if err != nil {
+		return err
+	}
+	if err := validateCPUs(cpus); err != nil {
+		return err
+	}
+
+	defaults, err := loadContainerDefaults()
+	if err != nil {
+		return err
+	}
+
+	containerVolumes, anonymousMounts, err := parseVolumes(volumes)
+	if err != nil {
+		return err
+	}
+
+	if err := cgroups.ValidateCpuset(cpusetCpus, cpusetMems); err != nil {
+		return fmt.Errorf("invalid cpuset: %v", err)
+	}
+
+	var containerHooks []hooks.Hook
+	for _, spec := range hookSpecs {
+		hook, err := hooks.Parse(spec)
+		if err != nil {
+			return err
+		}
+		containerHooks = append(containerHooks, hook)
+	}
+
+	if runPlatform != "" {
+		if err := ensureImagePlatform(image, runPlatform); err != nil {
+			return err
+		}
+	}
+
+	if !network.IsBuiltinNetwork(networkMode) {
+		if _, err := network.NewStore().Get(networkMode); err != nil {
+			return err
+		}
+	}
+
+	containerLinks, err := resolveLinks(links)
+	if err != nil {
+		return err
+	}
+	if len(networkAlias) > 0 {
+		if container.NetworkDriver(networkMode) != network.BridgeMode {
+			return fmt.Errorf("--network-alias needs a container network, not '%s'", networkMode)
+		}
+		for _, alias := range networkAlias {
+			if err := container.ValidateAlias(alias); err != nil {
+				return err
+			}
+		}
+	}
+	containerDNS, err := container.ParseDNS(runDNS, runDNSSearch, runDNSOptions, runAddHosts)
+	if err != nil {
+		return err
+	}
+	portMappings, err := parsePortMappings(ports)
+	if err != nil {
+		return err
+	}
+	if publishAll {
+		portMappings = append(portMappings, exposedPortMappings(image, portMappings)...)
+	}
+	if len(portMappings) > 0 && container.NetworkDriver(networkMode) == network.BridgeMode {
+		// Fail before creating the container; the start checks again
+		if _, err := container.AllocatePorts("", portMappings); err != nil {
+			return err
+		}
+	}
+	if runTTY && detach {
+		return fmt.Errorf("--tty cannot be used with --detach")
+	}
+	if linkEnv && len(containerLinks) == 0 {
+		return fmt.Errorf("--link-env needs at least one --link")
+	}
+	if registerMachine && !systemd.Available() {
+		return fmt.Errorf("--register-machine needs a Linux host running systemd")
+	}
+
 	// Create container configuration
 	config := &container.Config{
 		Image:        image,
@@ -69,22 +222,51 @@ func runContainer(cmd *cobra.Command, args []string) error {
 		WorkDir:      workdir,
 		Hostname:     hostname,
 		Env:          parseEnvVars(env),
-		Volumes:      parseVolumes(volumes),
+		Volumes:      containerVolumes,
 		NetworkMode:  networkMode,
-		PortMappings: parsePortMappings(ports),
+		PortMappings: portMappings,
+		Hooks:        containerHooks,
+		Labels:       parseLabels(runLabels),
+
+		NetworkAliases: networkAlias,
+		Links:          containerLinks,
+		LinkEnv:        linkEnv,
+		DNS:            containerDNS,
+		Machine:        registerMachine,
+		TTY:            runTTY,
+	}
+
+	if policy.Name != restart.PolicyNo {
+		config.RestartPolicy = policy.String()
 	}
 
-	// Apply resource limits if specified
-	if memory != "" {
-		config.Memory = memory
+	config.Healthcheck = resolveHealthcheck(image)
+	var imageVolumes map[string]struct{}
+	if img, err := resolveImage(image); err == nil {
+		config.StopSignal = img.Config.StopSignal
+		imageVolumes = img.Config.Volumes
 	}
-	if cpus != "" {
-		config.CPUs = cpus
+
+	// Apply resource limits, falling back to the defaults from config.yaml
+	config.Memory = memory
+	config.CPUs = cpus
+	if err := defaults.apply(cmd, config); err != nil {
+		return err
+	}
+	config.CpusetCpus = cpusetCpus
+	config.CpusetMems = cpusetMems
+
+	// Paths given without a source and those the image declares with
+	// VOLUME, unless something is mounted there, get anonymous volumes
+	config.AnonymousVolumes, err = createAnonymousVolumes(containerVolumes, anonymousMounts, imageVolumes)
+	if err != nil {
+		return err
 	}
 
 	// Create and run the container
 	c, err := container.New(config)
 	if err != nil {
+		removeAnonymousVolumes(config.AnonymousVolumes, nil)
 		return fmt.Errorf("failed to create container: %v", err)
 	}
 
@@ -95,21 +277,95 @@ func runContainer(cmd *cobra.Command, args []string) error {
 	}
 
 	if detach {
-		// Run in background
+		// Run in background, owned by a shim rather than this process
+		if err := c.RunDetached(); err != nil {
+			return fmt.Errorf("failed to start container: %v", err)
+		}
 		fmt.Printf("%s\n", c.ID)
-		go func() {
-			if err := c.RunWithVM(); err != nil {
-				fmt.Printf("Container %s exited with error: %v\n", c.ID[:12], err)
-			}
-		}()
 		return nil
 	} else {
-		// Show exit instructions for foreground runs
-		fmt.Printf("Starting container... (Press Ctrl+C to exit)\n")
+		// Show exit instructions for foreground runs; on a TTY Ctrl+C goes to the container
+		if runTTY {
+			fmt.Printf("Starting container...\n")
+		} else {
+			fmt.Printf("Starting container... (Press Ctrl+C to exit)\n")
+		}
 		return c.RunWithVM()
 	}
 }
 
+// resolveImage looks up an image in the local store
+func resolveImage(imageName string) (*image.Image, error) {
+	span := telemetry.StartSpan("image.resolve", nil)
+	span.SetAttribute("image.ref", imageName)
+	img, err := image.NewManager().GetImage(imageName)
+	span.Finish(err)
+	return img, err
+}
+
+// ensureImagePlatform pulls the image for a platform unless the local
+// image is already for it. Images with no recorded platform, such as
+// imported ones, are used as they are.
+func ensureImagePlatform(imageName, spec string) error {
+	platform, err := image.ParsePlatform(spec)
+	if err != nil {
+		return err
+	}
+
+	if img, err := resolveImage(imageName); err == nil {
+		if img.Platform == "" {
+			return nil
+		}
+		if local, err := image.ParsePlatform(img.Platform); err == nil && platform.Matches(local) {
+			return nil
+		}
+	}
+
+	if err := image.NewManager().PullImage(imageName, image.PullOptions{Platform: platform}); err != nil {
+		return fmt.Errorf("failed to pull image for %s: %v", platform, err)
+	}
+	return nil
+}
+
+// resolveHealthcheck combines the image HEALTHCHECK with the --health-* flags
+func resolveHealthcheck(imageName string) *health.Config {
+	if noHealthcheck {
+		return nil
+	}
+
+	var healthcheck *health.Config
+	if img, err := resolveImage(imageName); err == nil && img.Config.Healthcheck != nil {
+		hc := *img.Config.Healthcheck
+		healthcheck = &hc
+	}
+
+	if healthCmd != "" {
+		if healthcheck == nil {
+			healthcheck = &health.Config{}
+		}
+		healthcheck.Test = []string{"CMD-SHELL", healthCmd}
+	}
+
+	if healthcheck.Disabled() {
+		return nil
+	}
+
+	if healthInterval > 0 {
+		healthcheck.Interval = healthInterval
+	}
+	if healthTimeout > 0 {
+		healthcheck.Timeout = healthTimeout
+	}
+	if healthStartPeriod > 0 {
+		healthcheck.StartPeriod = healthStartPeriod
+	}
+	if healthRetries > 0 {
+		healthcheck.Retries = healthRetries
+	}
+
+	return healthcheck
+}
+
 // parseEnvVars parses environment variables from KEY=VALUE format
 func parseEnvVars(envs []string) map[string]string {
 	result := make(map[string]string)
@@ -122,36 +378,123 @@ func parseEnvVars(envs []string) map[string]string {
 	return result
 }
 
-// parseVolumes parses volume mounts from host:container format
-func parseVolumes(vols []string) map[string]string {
+// parseLabels parses labels from KEY=VALUE format; a bare KEY gets an empty value
+func parseLabels(labels []string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	result := make(map[string]string)
+	for _, label := range labels {
+		key, value, _ := strings.Cut(label, "=")
+		result[key] = value
+	}
+	return result
+}
+
+// resolveLinks checks that every NAME[:ALIAS] link names a running
+// container and returns the links by container name
+func resolveLinks(specs []string) ([]string, error) {
+	sm := state.NewStateManager()
+	var resolved []string
+	for _, spec := range specs {
+		name, alias, err := container.ParseLink(spec)
+		if err != nil {
+			return nil, err
+		}
+		id, err := resolveContainerRef(sm, name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot link to %s: %v", name, err)
+		}
+		target, err := sm.LoadContainer(id)
+		if err != nil {
+			return nil, err
+		}
+		if target.Status != state.StatusRunning {
+			return nil, fmt.Errorf("cannot link to %s: container is not running", name)
+		}
+		resolved = append(resolved, target.Name+":"+alias)
+	}
+	return resolved, nil
+}
+
+// parseVolumes parses volume specs into the container's mounts, by host
+// path or volume name, and the mounts of anonymous volumes, which have no
+// source yet. Host paths are resolved for this platform, so a Windows path
+// given in WSL or a WSL path given on Windows still mounts.
+func parseVolumes(vols []string) (map[string]string, []string, error) {
 	result := make(map[string]string)
+	var anonymous []string
 	for _, vol := range vols {
-		parts := strings.SplitN(vol, ":", 2)
-		if len(parts) == 2 {
-			result[parts[0]] = parts[1]
+		spec, err := volume.ParseSpec(vol)
+		if err != nil {
+			return nil, nil, err
+		}
+		if spec.Anonymous {
+			anonymous = append(anonymous, spec.Mount())
+			continue
+		}
+		source := spec.Source
+		if !spec.Named {
+			if source, err = volume.HostPath(spec.Source); err != nil {
+				return nil, nil, err
+			}
 		}
+		result[source] = spec.Mount()
 	}
-	return result
+	return result, anonymous, nil
+}
+
+// createAnonymousVolumes creates a volume for each anonymous mount and each
+// path the image declares a volume at that nothing is mounted at, adding
+// them to the container's mounts, and returns their names
+func createAnonymousVolumes(mounts map[string]string, anonymous []string, imageVolumes map[string]struct{}) ([]string, error) {
+	mounted := make(map[string]bool)
+	for _, mount := range mounts {
+		mounted[strings.TrimSuffix(mount, ":ro")] = true
+	}
+	for _, mount := range anonymous {
+		mounted[strings.TrimSuffix(mount, ":ro")] = true
+	}
+	declared := make([]string, 0, len(imageVolumes))
+	for target := range imageVolumes {
+		if !mounted[target] {
+			declared = append(declared, target)
+		}
+	}
+	sort.Strings(declared)
+
+	volManager := volume.NewManager()
+	var names []string
+	for _, mount := range append(anonymous, declared...) {
+		vol, err := volManager.CreateAnonymousVolume()
+		if err != nil {
+			removeAnonymousVolumes(names, nil)
+			return nil, fmt.Errorf("failed to create volume for %s: %v", mount, err)
+		}
+		mounts[vol.Name] = mount
+		names = append(names, vol.Name)
+	}
+	return names, nil
 }
 
 // parsePortMappings parses port mappings from various formats
-func parsePortMappings(portSpecs []string) []network.PortMapping {
+func parsePortMappings(portSpecs []string) ([]network.PortMapping, error) {
 	var mappings []network.PortMapping
 
 	for _, spec := range portSpecs {
 		mapping, err := parsePortMapping(spec)
 		if err != nil {
-			fmt.Printf("Warning: invalid port mapping '%s': %v\n", spec, err)
-			continue
+			return nil, fmt.Errorf("invalid port mapping '%s': %v", spec, err)
 		}
 		mappings = append(mappings, mapping)
 	}
 
-	return mappings
+	return mappings, nil
 }
 
 // parsePortMapping parses a single port mapping specification
-// Supports formats: port, hostPort:containerPort, hostIP:hostPort:containerPort, hostPort:containerPort/protocol
+// Supports formats: port, hostPort:containerPort, hostIP:hostPort:containerPort, [IPv6]:hostPort:containerPort, hostPort:containerPort/protocol
+// An empty or 0 host port, as in :80 or hostIP::80, has one allocated when the container starts
 func parsePortMapping(spec string) (network.PortMapping, error) {
 	var mapping network.PortMapping
 
@@ -162,16 +505,35 @@ func parsePortMapping(spec string) (network.PortMapping, error) {
 	if len(parts) == 2 {
 		protocol = strings.ToLower(parts[1])
 	}
+	if protocol != "tcp" && protocol != "udp" {
+		return mapping, fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+
+	// An IPv6 host address is bracketed: [hostIP]:hostPort:containerPort
+	var hostIP string
+	if strings.HasPrefix(portPart, "[") {
+		end := strings.Index(portPart, "]:")
+		if end < 0 || net.ParseIP(portPart[1:end]) == nil {
+			return mapping, fmt.Errorf("invalid IPv6 host address in %s", spec)
+		}
+		hostIP, portPart = portPart[1:end], portPart[end+2:]
+	}
 
 	// Split port part on ':'
 	portParts := strings.Split(portPart, ":")
+	if hostIP != "" {
+		if len(portParts) != 2 {
+			return mapping, fmt.Errorf("invalid port mapping format")
+		}
+		portParts = append([]string{hostIP}, portParts...)
+	}
 
 	switch len(portParts) {
 	case 1:
 		// Format: port (same for host and container)
-		port, err := strconv.Atoi(portParts[0])
+		port, err := parsePort(portParts[0], "port", false)
 		if err != nil {
-			return mapping, fmt.Errorf("invalid port number: %s", portParts[0])
+			return mapping, err
 		}
 		mapping = network.PortMapping{
 			HostPort:      port,
@@ -180,13 +542,13 @@ func parsePortMapping(spec string) (network.PortMapping, error) {
 		}
 	case 2:
 		// Format: hostPort:containerPort
-		hostPort, err := strconv.Atoi(portParts[0])
+		hostPort, err := parsePort(portParts[0], "host port", true)
 		if err != nil {
-			return mapping, fmt.Errorf("invalid host port: %s", portParts[0])
+			return mapping, err
 		}
-		containerPort, err := strconv.Atoi(portParts[1])
+		containerPort, err := parsePort(portParts[1], "container port", false)
 		if err != nil {
-			return mapping, fmt.Errorf("invalid container port: %s", portParts[1])
+			return mapping, err
 		}
 		mapping = network.PortMapping{
 			HostPort:      hostPort,
@@ -195,14 +557,17 @@ func parsePortMapping(spec string) (network.PortMapping, error) {
 		}
 	case 3:
 		// Format: hostIP:hostPort:containerPort
-		hostIP := portParts[0]
-		hostPort, err := strconv.Atoi(portParts[1])
+		hostIP = portParts[0]
+		if net.ParseIP(hostIP) == nil {
+			return mapping, fmt.Errorf("invalid host address: %s", hostIP)
+		}
+		hostPort, err := parsePort(portParts[1], "host port", true)
 		if err != nil {
-			return mapping, fmt.Errorf("invalid host port: %s", portParts[1])
+			return mapping, err
 		}
-		containerPort, err := strconv.Atoi(portParts[2])
+		containerPort, err := parsePort(portParts[2], "container port", false)
 		if err != nil {
-			return mapping, fmt.Errorf("invalid container port: %s", portParts[2])
+			return mapping, err
 		}
 		mapping = network.PortMapping{
 			HostIP:        hostIP,
@@ -216,3 +581,51 @@ func parsePortMapping(spec string) (network.PortMapping, error) {
 
 	return mapping, nil
 }
+
+// parsePort parses a port number; with allocate set, an empty one is 0,
+// for a port allocated when the container starts
+func parsePort(value, what string, allocate bool) (int, error) {
+	if value == "" && allocate {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 || (port == 0 && !allocate) {
+		return 0, fmt.Errorf("invalid %s: %s", what, value)
+	}
+	return port, nil
+}
+
+// exposedPortMappings returns mappings publishing the ports an image
+// exposes, for --publish-all, on host ports allocated when the container
+// starts; ports the mappings already publish are skipped
+func exposedPortMappings(imageName string, mappings []network.PortMapping) []network.PortMapping {
+	img, err := resolveImage(imageName)
+	if err != nil {
+		return nil
+	}
+	published := make(map[string]bool)
+	for _, mapping := range mappings {
+		published[fmt.Sprintf("%d/%s", mapping.ContainerPort, mapping.Protocol)] = true
+	}
+
+	var exposed []network.PortMapping
+	for spec := range img.Config.ExposedPorts {
+		port, protocol, _ := strings.Cut(spec, "/")
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		protocol = strings.ToLower(protocol)
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || published[fmt.Sprintf("%d/%s", n, protocol)] {
+			continue
+		}
+		exposed = append(exposed, network.PortMapping{ContainerPort: n, Protocol: protocol})
+	}
+	sort.Slice(exposed, func(i, j int) bool {
+		if exposed[i].ContainerPort != exposed[j].ContainerPort {
+			return exposed[i].ContainerPort < exposed[j].ContainerPort
+		}
+		return exposed[i].Protocol < exposed[j].Protocol
+	})
+	return exposed
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"servin/pkg/audit"
+	"servin/pkg/tenancy"
+)
+
+// protectedLabel marks a container or volume that may not be removed, pruned
+// included, unless --override-protection is given
+const protectedLabel = "protected"
+
+// overrideProtection lets rm, volume rm and volume rm-all remove protected objects
+var overrideProtection bool
+
+// isProtected reports whether labels carry the protected label. Any value
+// but "false" protects the object, so a bare --label protected is enough.
+func isProtected(labels map[string]string) bool {
+	value, ok := labels[protectedLabel]
+	return ok && value != "false"
+}
+
+// confirmTyped asks the user to type expected before a high blast radius
+// operation goes ahead. Anything else, including no input, cancels it.
+func confirmTyped(warning, expected string) bool {
+	fmt.Printf("WARNING! %s\nThis cannot be undone. Type '%s' to continue: ", warning, expected)
+	response, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if strings.TrimSpace(response) != expected {
+		fmt.Println("Operation cancelled")
+		return false
+	}
+	return true
+}
+
+// recordOverride writes a bypassed safety interlock to the audit trail. A
+// failure to record it is reported but does not stop the operation.
+func recordOverride(action, target, override string) {
+	err := audit.Record(audit.Entry{
+		Namespace: tenancy.Current(),
+		Action:    action,
+		Target:    target,
+		Override:  override,
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to record override in the audit trail: %v\n", err)
+	}
+}
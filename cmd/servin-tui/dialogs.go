@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// showDialog shows a primitive over the panes, centered at the given size
+func (tui *ServinTUI) showDialog(p tview.Primitive, width, height int) {
+	column := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(p, height, 0, true).
+		AddItem(nil, 0, 1, false)
+	centered := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(column, width, 0, true).
+		AddItem(nil, 0, 1, false)
+	tui.pages.AddPage("dialog", centered, true, true)
+	tui.app.SetFocus(p)
+}
+
+func (tui *ServinTUI) closeDialog() {
+	tui.pages.RemovePage("dialog")
+	tui.app.SetFocus(tui.panes[tui.focused])
+}
+
+// confirm asks before doing something that cannot be undone
+func (tui *ServinTUI) confirm(text, button string, yes func()) {
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{button, "Cancel"}).
+		SetDoneFunc(func(index int, label string) {
+			tui.closeDialog()
+			if label == button {
+				yes()
+			}
+		})
+	tui.pages.AddPage("dialog", modal, true, true)
+	tui.app.SetFocus(modal)
+}
+
+// prompt asks for one value, calling done with it unless it is left empty
+func (tui *ServinTUI) prompt(title, label, value string, done func(string)) {
+	form := tview.NewForm().AddInputField(label, value, 40, nil, nil)
+	field := form.GetFormItem(0).(*tview.InputField)
+	form.AddButton("OK", func() {
+		text := strings.TrimSpace(field.GetText())
+		tui.closeDialog()
+		if text != "" {
+			done(text)
+		}
+	})
+	form.AddButton("Cancel", tui.closeDialog)
+	form.SetCancelFunc(tui.closeDialog)
+	form.SetBorder(true).SetTitle(" " + title + " ")
+	tui.showDialog(form, 60, 7)
+}
+
+// showRunForm asks for what to run in a new container; detached, it runs in
+// the background, otherwise on the terminal until it exits
+func (tui *ServinTUI) showRunForm(image string) {
+	form := tview.NewForm().
+		AddInputField("Image", image, 40, nil, nil).
+		AddInputField("Name", "", 40, nil, nil).
+		AddInputField("Command", "", 40, nil, nil).
+		AddCheckbox("Detach", true, nil)
+	text := func(label string) string {
+		return strings.TrimSpace(form.GetFormItemByLabel(label).(*tview.InputField).GetText())
+	}
+	form.AddButton("Run", func() {
+		image := text("Image")
+		if image == "" {
+			tui.setStatus("An image is required", true)
+			return
+		}
+		name := text("Name")
+		argv := strings.Fields(text("Command"))
+		detach := form.GetFormItemByLabel("Detach").(*tview.Checkbox).IsChecked()
+
+		tui.closeDialog()
+		if detach {
+			tui.runAction("Starting "+image, "Started "+image, func() error { return startContainer(image, name, argv) })
+		} else {
+			title := strings.TrimSpace("run " + image + " " + strings.Join(argv, " "))
+			tui.runInTerminal(title, true, func() error { return runContainer(image, name, argv) })
+		}
+	})
+	form.AddButton("Cancel", tui.closeDialog)
+	form.SetCancelFunc(tui.closeDialog)
+	form.SetBorder(true).SetTitle(" Run Container ")
+	tui.showDialog(form, 60, 13)
+}
+
+// promptCommand runs any servin command on the terminal, in this process,
+// for what the panes have no key for; logs for a container opens the log
+// viewer instead
+func (tui *ServinTUI) promptCommand() {
+	tui.prompt("Run servin Command", "servin", "", func(command string) {
+		args := strings.Fields(command)
+		if len(args) == 2 && args[0] == "logs" {
+			for _, c := range tui.containers {
+				if c.Name == args[1] || strings.HasPrefix(c.ID, args[1]) {
+					tui.openLogViewer(c)
+					return
+				}
+			}
+		}
+		tui.runInTerminal("servin "+command, true, func() error { return runCommand(args) })
+	})
+}
+
+func (tui *ServinTUI) showHelp() {
+	help := tview.NewTextView().SetText(`Panes
+  tab, shift+tab   Next, previous pane
+  1, 2, 3          Containers, images, volumes
+  enter            Scroll the details; esc returns
+
+Containers
+  s                Stop
+  d                Remove
+  x                Open a shell in the container
+  l                Open the logs full screen
+  i                Switch between logs and inspect
+  n                Run a new container
+
+Images
+  d                Remove
+  n                Run a container from the image
+  p                Pull an image
+
+Volumes
+  d                Remove
+  c                Create
+
+Logs (l)
+  f, space         Follow, pause
+  /, n, N          Search by regexp, next, previous
+  g                Go to a time, such as 14:05 or 10m
+  t                Show or hide times
+  esc              Back to the panes
+
+Stats
+  t                Show CPU and memory of the running
+                   containers; t or esc returns
+  c, m, n, p       Sort by CPU, memory, name, PIDs;
+                   again to reverse
+
+Anywhere
+  :                Run any servin command
+  r                Refresh now
+  q                Quit`)
+	help.SetBorder(true).SetTitle(" Help (esc to close) ")
+	help.SetDoneFunc(func(key tcell.Key) { tui.closeDialog() })
+	tui.showDialog(help, 54, 44)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"servin/pkg/logs"
+)
+
+const (
+	// logTailLines is how much of the logs the details pane starts with
+	logTailLines = 200
+	// logPollInterval is how often a tail looks for new output
+	logPollInterval = 500 * time.Millisecond
+)
+
+// logLine is a line of a container's output. On Linux the runtime stamps
+// each line with when it was written; elsewhere Time is zero.
+type logLine struct {
+	Time   time.Time
+	Stream string
+	Text   string
+}
+
+// parseLogLine splits the timestamp the runtime wrote off a line
+func parseLogLine(line, stream string) logLine {
+	if stamp, text, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			return logLine{Time: t, Stream: stream, Text: text}
+		}
+	}
+	return logLine{Stream: stream, Text: line}
+}
+
+// logTail follows the logs of one container, sending them on from its own
+// goroutine until stopped. Lines read as it is stopped may still be sent, so
+// receivers check the tail is still theirs.
+type logTail struct {
+	files []*logs.File
+	send  func([]logLine)
+	done  chan struct{}
+	stop  sync.Once
+}
+
+// startLogTail sends the last lines of a container's logs, stdout and
+// stderr merged in the order they were written, then follows them
+func startLogTail(containerID string, lines int, send func([]logLine)) *logTail {
+	tail := &logTail{
+		files: logs.Files(logs.Dir(containerID)),
+		send:  send,
+		done:  make(chan struct{}),
+	}
+	go tail.follow(lines)
+	return tail
+}
+
+func (t *logTail) follow(lines int) {
+	var last []logLine
+	for _, f := range t.files {
+		last = append(last, logLines(f, f.ReadTail(lines))...)
+	}
+	sort.SliceStable(last, func(i, j int) bool { return last[i].Time.Before(last[j].Time) })
+	if len(last) > lines {
+		last = last[len(last)-lines:]
+	}
+	t.deliver(last)
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			for _, f := range t.files {
+				t.deliver(logLines(f, f.ReadNew()))
+			}
+		}
+	}
+}
+
+// deliver passes lines on until the tail is stopped
+func (t *logTail) deliver(lines []logLine) {
+	if len(lines) == 0 {
+		return
+	}
+	select {
+	case <-t.done:
+	default:
+		t.send(lines)
+	}
+}
+
+// Stop ends the tail
+func (t *logTail) Stop() {
+	t.stop.Do(func() { close(t.done) })
+}
+
+// logLines parses lines read from one of a container's log files
+func logLines(f *logs.File, lines []string) []logLine {
+	parsed := make([]logLine, 0, len(lines))
+	for _, line := range lines {
+		parsed = append(parsed, parseLogLine(line, f.Stream))
+	}
+	return parsed
+}
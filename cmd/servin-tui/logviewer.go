@@ -0,0 +1,379 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/errors"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	// logViewerLines is how much of the logs the viewer keeps; older lines
+	// are dropped as new ones arrive
+	logViewerLines = 10000
+	// logViewerTrim is how many lines over logViewerLines are let in before
+	// the oldest are dropped, so the view is not redrawn for every new line
+	logViewerTrim = 1000
+)
+
+// logViewer shows one container's logs full screen. Following keeps the
+// newest line in view; pausing holds new lines back until it is resumed.
+type logViewer struct {
+	tui  *ServinTUI
+	tail *logTail
+
+	view  *tview.TextView
+	info  *tview.TextView
+	keys  *tview.TextView
+	input *tview.InputField
+	root  *tview.Flex
+
+	// Like the widgets, only touched on the UI goroutine
+	lines      []logLine
+	held       []logLine
+	follow     bool
+	paused     bool
+	timestamps bool
+	search     *regexp.Regexp
+	matches    int
+	current    int
+	note       string
+}
+
+// openLogViewer shows a container's logs full screen, following them
+func (tui *ServinTUI) openLogViewer(c containerEntry) {
+	v := &logViewer{tui: tui, follow: true, timestamps: true, current: -1}
+
+	v.view = tview.NewTextView().SetDynamicColors(true).SetRegions(true).SetWrap(false)
+	v.view.SetBorder(true).SetTitle(" Logs: " + tview.Escape(c.Name) + " ")
+	v.info = tview.NewTextView().SetDynamicColors(true)
+	v.keys = tview.NewTextView().SetTextColor(tcell.ColorGray).
+		SetText("f follow  space pause  / search  n/N next/prev  g go to time  t times  |  esc back")
+	v.input = tview.NewInputField().SetFieldWidth(0)
+	v.root = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(v.view, 0, 1, true).
+		AddItem(v.info, 1, 0, false).
+		AddItem(v.keys, 1, 0, false)
+
+	tui.viewer = v
+	tui.pages.AddPage("logs", v.root, true, true)
+	tui.app.SetFocus(v.view)
+	v.showInfo()
+
+	v.tail = startLogTail(c.ID, logViewerLines, func(lines []logLine) {
+		tui.app.QueueUpdateDraw(func() {
+			if tui.viewer == v {
+				v.add(lines)
+			}
+		})
+	})
+}
+
+// close returns to the panes
+func (v *logViewer) close() {
+	v.tail.Stop()
+	v.tui.viewer = nil
+	v.tui.pages.RemovePage("logs")
+	v.tui.app.SetFocus(v.tui.panes[v.tui.focused])
+}
+
+// add shows new lines, or holds them back while paused
+func (v *logViewer) add(lines []logLine) {
+	if v.paused {
+		v.held = append(v.held, lines...)
+		v.showInfo()
+		return
+	}
+	v.lines = append(v.lines, lines...)
+	if len(v.lines) > logViewerLines+logViewerTrim {
+		v.lines = append([]logLine(nil), v.lines[len(v.lines)-logViewerLines:]...)
+		v.render()
+	} else {
+		var text strings.Builder
+		for _, line := range lines {
+			v.writeLine(&text, line)
+		}
+		v.view.Write([]byte(text.String()))
+	}
+	if v.follow {
+		v.view.ScrollToEnd()
+	}
+	v.showInfo()
+}
+
+// render redraws every line, numbering the search matches afresh
+func (v *logViewer) render() {
+	v.matches = 0
+	var text strings.Builder
+	for _, line := range v.lines {
+		v.writeLine(&text, line)
+	}
+	v.view.SetText(text.String())
+	if v.current >= v.matches {
+		v.current = -1
+	}
+	v.showMatch()
+	if v.current < 0 && v.follow {
+		v.view.ScrollToEnd()
+	}
+}
+
+// writeLine formats a line: its time, dimmed, then its text, in red for
+// stderr, with the search matches marked as regions
+func (v *logViewer) writeLine(text *strings.Builder, line logLine) {
+	if v.timestamps {
+		stamp := strings.Repeat(" ", len("15:04:05.000"))
+		if !line.Time.IsZero() {
+			stamp = line.Time.Local().Format("15:04:05.000")
+		}
+		text.WriteString("[gray]" + stamp + "[-] ")
+	}
+	if line.Stream == "stderr" {
+		text.WriteString("[red]")
+	}
+
+	rest := line.Text
+	if v.search != nil {
+		for _, match := range v.search.FindAllStringIndex(line.Text, -1) {
+			start, end := match[0]-(len(line.Text)-len(rest)), match[1]-(len(line.Text)-len(rest))
+			if end == start {
+				continue
+			}
+			text.WriteString(tview.Escape(rest[:start]))
+			fmt.Fprintf(text, `["%d"][black:yellow]%s[-:-]`, v.matches, tview.Escape(rest[start:end]))
+			if line.Stream == "stderr" {
+				text.WriteString(`[""][red]`)
+			} else {
+				text.WriteString(`[""]`)
+			}
+			v.matches++
+			rest = rest[end:]
+		}
+	}
+	text.WriteString(tview.Escape(rest))
+
+	if line.Stream == "stderr" {
+		text.WriteString("[-]")
+	}
+	text.WriteString("\n")
+}
+
+// showInfo fills the line under the logs with the viewer's state
+func (v *logViewer) showInfo() {
+	var parts []string
+	switch {
+	case v.paused:
+		parts = append(parts, fmt.Sprintf("[yellow]PAUSED[-] (%d new)", len(v.held)))
+	case v.follow:
+		parts = append(parts, "[green]FOLLOWING[-]")
+	default:
+		parts = append(parts, "scrolled back")
+	}
+	parts = append(parts, fmt.Sprintf("%d lines", len(v.lines)))
+	if v.search != nil {
+		match := "-"
+		if v.current >= 0 {
+			match = strconv.Itoa(v.current + 1)
+		}
+		parts = append(parts, fmt.Sprintf("/%s  %s of %d", tview.Escape(v.search.String()), match, v.matches))
+	}
+	if v.note != "" {
+		parts = append(parts, v.note)
+	}
+	v.info.SetText(strings.Join(parts, "  |  "))
+}
+
+// showMatch highlights the current match and scrolls to it
+func (v *logViewer) showMatch() {
+	if v.current < 0 {
+		v.view.Highlight()
+		return
+	}
+	v.view.Highlight(strconv.Itoa(v.current)).ScrollToHighlight()
+}
+
+func (v *logViewer) setFollow(follow bool) {
+	v.follow = follow
+	if follow {
+		v.view.ScrollToEnd()
+	}
+	v.showInfo()
+}
+
+func (v *logViewer) setPaused(paused bool) {
+	v.paused = paused
+	if !paused && len(v.held) > 0 {
+		held := v.held
+		v.held = nil
+		v.add(held)
+		return
+	}
+	v.showInfo()
+}
+
+// setSearch marks the matches of a regular expression, or clears them for
+// an empty one, and moves to the first match
+func (v *logViewer) setSearch(pattern string) error {
+	v.search, v.current = nil, -1
+	if pattern != "" {
+		search, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.NewValidationError("search", fmt.Sprintf("invalid regular expression: %v", err))
+		}
+		v.search = search
+	}
+	v.render()
+	if v.matches > 0 {
+		v.setFollow(false)
+		v.current = 0
+		v.showMatch()
+	}
+	v.showInfo()
+	return nil
+}
+
+// nextMatch moves by step through the matches, wrapping around
+func (v *logViewer) nextMatch(step int) {
+	if v.matches == 0 {
+		return
+	}
+	v.setFollow(false)
+	v.current = ((v.current+step)%v.matches + v.matches) % v.matches
+	v.showMatch()
+	v.showInfo()
+}
+
+// jumpTo scrolls to the first line written at or after a time
+func (v *logViewer) jumpTo(value string) error {
+	last := time.Now()
+	if n := len(v.lines); n > 0 && !v.lines[n-1].Time.IsZero() {
+		last = v.lines[n-1].Time
+	}
+	target, err := parseJumpTime(value, last)
+	if err != nil {
+		return err
+	}
+	i, stamped := 0, false
+	for ; i < len(v.lines); i++ {
+		if t := v.lines[i].Time; !t.IsZero() {
+			stamped = true
+			if !t.Before(target) {
+				break
+			}
+		}
+	}
+	if !stamped {
+		return errors.NewNotFoundError("go to time", "these logs have no timestamps")
+	}
+	if i == len(v.lines) {
+		return errors.NewNotFoundError("go to time", fmt.Sprintf("no lines at or after %s", target.Local().Format("2006-01-02 15:04:05")))
+	}
+	v.setFollow(false)
+	v.view.ScrollTo(i, 0)
+	v.note = fmt.Sprintf("at %s, line %d", v.lines[i].Time.Local().Format("15:04:05"), i+1)
+	v.showInfo()
+	return nil
+}
+
+// parseJumpTime reads a time to jump to: a clock time on the day of the
+// newest line, a date and time, RFC 3339, or a duration ago such as 10m
+func parseJumpTime(value string, last time.Time) (time.Time, error) {
+	last = last.Local()
+	for _, layout := range []string{"15:04", "15:04:05", "15:04:05.000"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return time.Date(last.Year(), last.Month(), last.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, errors.NewValidationError("go to time", fmt.Sprintf("cannot read %q as a time (try 14:05, 2006-01-02 14:05 or 10m)", value))
+}
+
+// ask reads a value on the key line, calling done with it once Enter is
+// pressed; Esc gives up
+func (v *logViewer) ask(label, value string, done func(string) error) {
+	v.input.SetLabel(label).SetText(value)
+	v.input.SetDoneFunc(func(key tcell.Key) {
+		v.root.RemoveItem(v.input)
+		v.root.AddItem(v.keys, 1, 0, false)
+		v.tui.app.SetFocus(v.view)
+		if key != tcell.KeyEnter {
+			return
+		}
+		if err := done(strings.TrimSpace(v.input.GetText())); err != nil {
+			v.note = "[red]" + tview.Escape(errorText(err)) + "[-]"
+			v.showInfo()
+		}
+	})
+	v.root.RemoveItem(v.keys)
+	v.root.AddItem(v.input, 1, 0, false)
+	v.tui.app.SetFocus(v.input)
+}
+
+func (v *logViewer) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	// The input line takes its own keys
+	if v.tui.app.GetFocus() == v.input {
+		return event
+	}
+	v.note = ""
+
+	switch event.Key() {
+	case tcell.KeyEscape:
+		v.close()
+		return nil
+	case tcell.KeyUp, tcell.KeyPgUp, tcell.KeyHome:
+		v.setFollow(false)
+		return event
+	case tcell.KeyEnd:
+		v.setFollow(true)
+		return nil
+	case tcell.KeyRune:
+	default:
+		return event
+	}
+
+	switch event.Rune() {
+	case 'q':
+		v.close()
+	case 'f', 'G':
+		v.setFollow(!v.follow || event.Rune() == 'G')
+	case ' ', 'p':
+		v.setPaused(!v.paused)
+	case '/':
+		pattern := ""
+		if v.search != nil {
+			pattern = v.search.String()
+		}
+		v.ask("/", pattern, v.setSearch)
+	case 'n':
+		v.nextMatch(1)
+	case 'N':
+		v.nextMatch(-1)
+	case 'g':
+		v.ask("Go to time: ", "", v.jumpTo)
+	case 't':
+		v.timestamps = !v.timestamps
+		v.render()
+	case 'k':
+		v.setFollow(false)
+		return event
+	default:
+		return event
+	}
+	return nil
+}
@@ -1,619 +1,637 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"time"
-)
-
-// ServinTUI represents a Terminal User Interface for Servin
-type ServinTUI struct {
-	running bool
-}
 
-// NewServinTUI creates a new TUI instance
-func NewServinTUI() *ServinTUI {
-	return &ServinTUI{running: true}
-}
+	cli "servin/cmd"
 
-// Run starts the TUI application
-func (tui *ServinTUI) Run() {
-	for tui.running {
-		tui.clearScreen()
-		tui.showBanner()
-		tui.showMainMenu()
-		choice := tui.getInput("Select an option: ")
-		tui.handleMainMenu(choice)
-	}
-}
-
-// showBanner displays the Servin banner
-func (tui *ServinTUI) showBanner() {
-	fmt.Println("╔════════════════════════════════════════════════════════════════╗")
-	fmt.Println("║                         Servin Desktop                         ║")
-	fmt.Println("║                Container Runtime Management                    ║")
-	fmt.Println("╚════════════════════════════════════════════════════════════════╝")
-	fmt.Println()
-}
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
 
-// clearScreen clears the terminal screen
-func (tui *ServinTUI) clearScreen() {
-	// Clear screen command for Unix/Windows
-	cmd := exec.Command("clear")
-	if os.Getenv("OS") == "Windows_NT" {
-		cmd = exec.Command("cmd", "/c", "cls")
-	}
-	cmd.Stdout = os.Stdout
-	cmd.Run()
-}
+// The resource lists down the left of the screen, in the order Tab moves
+// through them
+const (
+	paneContainers = iota
+	paneImages
+	paneVolumes
+	paneCount
+)
 
-// showMainMenu displays the main menu options
-func (tui *ServinTUI) showMainMenu() {
-	fmt.Println("┌─────────────────── Main Menu ────────────────────┐")
-	fmt.Println("│  1. Container Management                          │")
-	fmt.Println("│  2. Image Management                              │")
-	fmt.Println("│  3. CRI Server Control                            │")
-	fmt.Println("│  4. Volume Management                             │")
-	fmt.Println("│  5. Registry Operations                           │")
-	fmt.Println("│  6. System Information                            │")
-	fmt.Println("│  7. Exit                                          │")
-	fmt.Println("└───────────────────────────────────────────────────┘")
-	fmt.Println()
+// paneKeys are the keys each pane accepts, shown at the bottom of the screen
+var paneKeys = [paneCount]string{
+	"s stop  d remove  x shell  l logs  i inspect  n run  enter scroll",
+	"d remove  n run  p pull  enter scroll",
+	"d remove  c create  enter scroll",
 }
 
-// handleMainMenu processes main menu selections
-func (tui *ServinTUI) handleMainMenu(choice string) {
-	switch choice {
-	case "1":
-		tui.containerMenu()
-	case "2":
-		tui.imageMenu()
-	case "3":
-		tui.criMenu()
-	case "4":
-		tui.volumeMenu()
-	case "5":
-		tui.registryMenu()
-	case "6":
-		tui.systemInfo()
-		if tui.running {
-			tui.getInput("Press Enter to continue...")
-		}
-	case "7":
-		tui.running = false
-		fmt.Println("Thank you for using Servin Desktop!")
-	default:
-		fmt.Println("Invalid option. Please try again.")
-		if tui.running {
-			tui.getInput("Press Enter to continue...")
-		}
+// ServinTUI represents a Terminal User Interface for Servin
+type ServinTUI struct {
+	app    *tview.Application
+	pages  *tview.Pages
+	panes  [paneCount]*tview.Table
+	detail *tview.TextView
+	status *tview.TextView
+	keys   *tview.TextView
+
+	focused int
+
+	// The lists as last refreshed, in the order of the table rows; like
+	// every widget they are only touched on the UI goroutine
+	containers []containerEntry
+	images     []imageEntry
+	volumes    []volumeEntry
+
+	// What the detail pane shows, and the log tail filling it
+	shown   string
+	tail    *logTail
+	inspect bool
+
+	// The full-screen log viewer, while it is open
+	viewer *logViewer
+
+	// The stats view, its rows as last sampled and how they are sorted
+	statsTable   *tview.Table
+	stats        []statsRow
+	statsSort    int
+	statsReverse bool
+
+	interval time.Duration
+
+	mu           sync.Mutex
+	refreshing   bool
+	refreshAgain bool
+}
+
+// NewServinTUI creates a new TUI instance that refreshes every interval
+func NewServinTUI(interval time.Duration) *ServinTUI {
+	tui := &ServinTUI{
+		app:      tview.NewApplication(),
+		pages:    tview.NewPages(),
+		interval: interval,
+	}
+
+	for i, title := range [paneCount]string{"Containers", "Images", "Volumes"} {
+		table := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+		table.SetBorder(true).SetTitle(fmt.Sprintf(" %s %s ", tview.Escape(fmt.Sprintf("[%d]", i+1)), title))
+		pane := i
+		table.SetSelectionChangedFunc(func(row, column int) {
+			if tui.focused == pane {
+				tui.showDetail()
+			}
+		})
+		table.SetFocusFunc(func() {
+			tui.focused = pane
+			tui.keys.SetText(paneKeys[pane] + "  |  t stats  ? help  q quit")
+			tui.showDetail()
+		})
+		tui.panes[i] = table
+	}
+
+	tui.detail = tview.NewTextView().SetScrollable(true).SetWrap(true)
+	tui.detail.SetBorder(true).SetTitle(" Details ")
+	tui.detail.SetDoneFunc(func(key tcell.Key) {
+		tui.app.SetFocus(tui.panes[tui.focused])
+	})
+
+	tui.status = tview.NewTextView().SetDynamicColors(true)
+	tui.keys = tview.NewTextView().SetTextColor(tcell.ColorGray)
+
+	lists := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tui.panes[paneContainers], 0, 2, true).
+		AddItem(tui.panes[paneImages], 0, 1, false).
+		AddItem(tui.panes[paneVolumes], 0, 1, false)
+	body := tview.NewFlex().
+		AddItem(lists, 0, 1, true).
+		AddItem(tui.detail, 0, 1, false)
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(body, 0, 1, true).
+		AddItem(tui.status, 1, 0, false).
+		AddItem(tui.keys, 1, 0, false)
+	tui.pages.AddPage("main", root, true, true)
+	tui.pages.AddPage("stats", tui.newStatsView(), true, false)
+
+	tui.app.SetRoot(tui.pages, true).SetInputCapture(tui.handleKey)
+	return tui
+}
+
+// Run starts the TUI application and returns when it is quit
+func (tui *ServinTUI) Run() error {
+	tui.app.SetFocus(tui.panes[paneContainers])
+	tui.refresh()
+	if tui.interval > 0 {
+		ticker := time.NewTicker(tui.interval)
+		defer ticker.Stop()
+		go func() {
+			for range ticker.C {
+				tui.refresh()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go tui.sampleStats(done)
+
+	err := tui.app.Run()
+	if tui.tail != nil {
+		tui.tail.Stop()
+	}
+	if tui.viewer != nil {
+		tui.viewer.tail.Stop()
+	}
+	return err
+}
+
+// refresh reloads the lists in the background; a refresh asked for while
+// one runs is done once it finishes, so an action's result always shows
+func (tui *ServinTUI) refresh() {
+	tui.mu.Lock()
+	defer tui.mu.Unlock()
+	if tui.refreshing {
+		tui.refreshAgain = true
+		return
 	}
-	fmt.Println()
+	tui.refreshing = true
+	go tui.load()
 }
 
-// containerMenu handles container management
-func (tui *ServinTUI) containerMenu() {
+func (tui *ServinTUI) load() {
 	for {
-		tui.clearScreen()
-		fmt.Println("┌─────────────── Container Management ──────────────┐")
-		fmt.Println("│  1. List Containers                               │")
-		fmt.Println("│  2. Run New Container                             │")
-		fmt.Println("│  3. Start Container                               │")
-		fmt.Println("│  4. Stop Container                                │")
-		fmt.Println("│  5. Remove Container                              │")
-		fmt.Println("│  6. View Container Logs                           │")
-		fmt.Println("│  7. Execute Command in Container                  │")
-		fmt.Println("│  8. Back to Main Menu                             │")
-		fmt.Println("└───────────────────────────────────────────────────┘")
-
-		choice := tui.getInput("Select an option: ")
-
-		switch choice {
-		case "1":
-			tui.runCommand("servin", "ls")
-			tui.getInput("Press Enter to continue...")
-		case "2":
-			tui.runNewContainer()
-			tui.getInput("Press Enter to continue...")
-		case "3":
-			tui.startContainer()
-			tui.getInput("Press Enter to continue...")
-		case "4":
-			tui.stopContainer()
-			tui.getInput("Press Enter to continue...")
-		case "5":
-			tui.removeContainer()
-			tui.getInput("Press Enter to continue...")
-		case "6":
-			tui.viewContainerLogs()
-			tui.getInput("Press Enter to continue...")
-		case "7":
-			tui.execInContainer()
-			tui.getInput("Press Enter to continue...")
-		case "8":
+		containers, containersErr := listContainers()
+		images, imagesErr := listImages()
+		volumes, volumesErr := listVolumes()
+
+		tui.app.QueueUpdateDraw(func() {
+			if containersErr == nil {
+				tui.setContainers(containers)
+			}
+			if imagesErr == nil {
+				tui.setImages(images)
+			}
+			if volumesErr == nil {
+				tui.setVolumes(volumes)
+			}
+			for _, err := range []error{containersErr, imagesErr, volumesErr} {
+				if err != nil {
+					tui.setStatus(errorText(err), true)
+					break
+				}
+			}
+			tui.showDetail()
+		})
+
+		tui.mu.Lock()
+		if !tui.refreshAgain {
+			tui.refreshing = false
+			tui.mu.Unlock()
 			return
-		default:
-			fmt.Println("Invalid option. Please try again.")
-			tui.getInput("Press Enter to continue...")
 		}
-		fmt.Println()
+		tui.refreshAgain = false
+		tui.mu.Unlock()
 	}
 }
 
-// imageMenu handles image management
-func (tui *ServinTUI) imageMenu() {
-	for {
-		fmt.Println("┌──────────────── Image Management ─────────────────┐")
-		fmt.Println("│  1. List Images                                   │")
-		fmt.Println("│  2. Import Image                                  │")
-		fmt.Println("│  3. Remove Image                                  │")
-		fmt.Println("│  4. Tag Image                                     │")
-		fmt.Println("│  5. Inspect Image                                 │")
-		fmt.Println("│  6. Build Image                                   │")
-		fmt.Println("│  7. Back to Main Menu                             │")
-		fmt.Println("└───────────────────────────────────────────────────┘")
-
-		choice := tui.getInput("Select an option: ")
-
-		switch choice {
-		case "1":
-			tui.runCommand("servin", "image", "ls")
-		case "2":
-			tui.importImage()
-		case "3":
-			tui.removeImage()
-		case "4":
-			tui.tagImage()
-		case "5":
-			tui.inspectImage()
-		case "6":
-			tui.buildImage()
-		case "7":
-			return
-		default:
-			fmt.Println("Invalid option. Please try again.")
-		}
-		fmt.Println()
+func (tui *ServinTUI) setContainers(containers []containerEntry) {
+	selected := ""
+	if c, ok := tui.selectedContainer(); ok {
+		selected = c.ID
 	}
-}
+	sort.SliceStable(containers, func(i, j int) bool { return containers[i].Created.After(containers[j].Created) })
+	tui.containers = containers
 
-// criMenu handles CRI server operations
-func (tui *ServinTUI) criMenu() {
-	for {
-		fmt.Println("┌────────────────── CRI Server ─────────────────────┐")
-		fmt.Println("│  1. Start CRI Server                             │")
-		fmt.Println("│  2. Check CRI Server Status                      │")
-		fmt.Println("│  3. Test CRI Connection                           │")
-		fmt.Println("│  4. View CRI Endpoints                            │")
-		fmt.Println("│  5. Back to Main Menu                             │")
-		fmt.Println("└───────────────────────────────────────────────────┘")
-
-		choice := tui.getInput("Select an option: ")
-
-		switch choice {
-		case "1":
-			tui.startCRIServer()
-		case "2":
-			tui.runCommand("servin", "cri", "status")
-		case "3":
-			tui.testCRIConnection()
-		case "4":
-			tui.showCRIEndpoints()
-		case "5":
-			return
-		default:
-			fmt.Println("Invalid option. Please try again.")
+	rows := make([][]string, len(containers))
+	keys := make([]string, len(containers))
+	for i, c := range containers {
+		status := c.Status
+		if c.Health != "" {
+			status += " (" + c.Health + ")"
 		}
-		fmt.Println()
+		rows[i] = []string{c.Name, c.Image, status, shortID(c.ID)}
+		keys[i] = c.ID
+	}
+	table := tui.panes[paneContainers]
+	fillTable(table, []string{"NAME", "IMAGE", "STATUS", "ID"}, rows, keys, selected)
+	for i, c := range containers {
+		table.GetCell(i+1, 2).SetTextColor(statusColor(c.Status))
 	}
 }
 
-// volumeMenu handles volume management
-func (tui *ServinTUI) volumeMenu() {
-	for {
-		fmt.Println("┌─────────────── Volume Management ─────────────────┐")
-		fmt.Println("│  1. List Volumes                                  │")
-		fmt.Println("│  2. Create Volume                                 │")
-		fmt.Println("│  3. Remove Volume                                 │")
-		fmt.Println("│  4. Inspect Volume                                │")
-		fmt.Println("│  5. Remove All Volumes                            │")
-		fmt.Println("│  6. Back to Main Menu                             │")
-		fmt.Println("└───────────────────────────────────────────────────┘")
-
-		choice := tui.getInput("Select an option: ")
-
-		switch choice {
-		case "1":
-			tui.runCommand("servin", "volume", "ls")
-		case "2":
-			tui.createVolume()
-		case "3":
-			tui.removeVolume()
-		case "4":
-			tui.inspectVolume()
-		case "5":
-			tui.removeAllVolumes()
-		case "6":
-			return
-		default:
-			fmt.Println("Invalid option. Please try again.")
-		}
-		fmt.Println()
+func (tui *ServinTUI) setImages(images []imageEntry) {
+	selected := ""
+	if img, ok := tui.selectedImage(); ok {
+		selected = img.Ref()
 	}
-}
+	sort.SliceStable(images, func(i, j int) bool { return images[i].Ref() < images[j].Ref() })
+	tui.images = images
 
-// registryMenu handles registry operations
-func (tui *ServinTUI) registryMenu() {
-	for {
-		fmt.Println("┌────────────── Registry Operations ────────────────┐")
-		fmt.Println("│  1. Start Local Registry                          │")
-		fmt.Println("│  2. Stop Local Registry                           │")
-		fmt.Println("│  3. Push Image to Registry                        │")
-		fmt.Println("│  4. Pull Image from Registry                      │")
-		fmt.Println("│  5. List Registries                               │")
-		fmt.Println("│  6. Back to Main Menu                             │")
-		fmt.Println("└───────────────────────────────────────────────────┘")
-
-		choice := tui.getInput("Select an option: ")
-
-		switch choice {
-		case "1":
-			tui.runCommand("servin", "registry", "start")
-		case "2":
-			tui.runCommand("servin", "registry", "stop")
-		case "3":
-			tui.pushToRegistry()
-		case "4":
-			tui.pullFromRegistry()
-		case "5":
-			tui.runCommand("servin", "registry", "list")
-		case "6":
-			return
-		default:
-			fmt.Println("Invalid option. Please try again.")
-		}
-		fmt.Println()
+	rows := make([][]string, len(images))
+	keys := make([]string, len(images))
+	for i, img := range images {
+		rows[i] = []string{img.Ref(), formatSize(img.Size), fmt.Sprint(img.Containers)}
+		keys[i] = img.Ref()
 	}
+	fillTable(tui.panes[paneImages], []string{"IMAGE", "SIZE", "CONTAINERS"}, rows, keys, selected)
 }
 
-// systemInfo displays system information
-func (tui *ServinTUI) systemInfo() {
-	fmt.Println("╔════════════════ System Information ═══════════════╗")
-
-	// Get Servin version
-	fmt.Println("║ Servin Runtime Information:                        ║")
-	tui.runCommand("servin", "--version")
-
-	// Show platform info
-	fmt.Printf("║ Platform: %s\n", "Windows/Linux/macOS")
-	fmt.Printf("║ Time: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-
-	// Show container count
-	fmt.Println("║                                                    ║")
-	fmt.Println("║ Container Statistics:                              ║")
-	tui.runCommand("servin", "ls")
-
-	// Show image count
-	fmt.Println("║                                                    ║")
-	fmt.Println("║ Image Statistics:                                  ║")
-	tui.runCommand("servin", "image", "ls")
+func (tui *ServinTUI) setVolumes(volumes []volumeEntry) {
+	selected := ""
+	if vol, ok := tui.selectedVolume(); ok {
+		selected = vol.Name
+	}
+	sort.SliceStable(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	tui.volumes = volumes
 
-	fmt.Println("╚════════════════════════════════════════════════════╝")
+	rows := make([][]string, len(volumes))
+	keys := make([]string, len(volumes))
+	for i, vol := range volumes {
+		rows[i] = []string{vol.Name, vol.Driver, fmt.Sprint(vol.Containers)}
+		keys[i] = vol.Name
+	}
+	fillTable(tui.panes[paneVolumes], []string{"NAME", "DRIVER", "CONTAINERS"}, rows, keys, selected)
 }
 
-// Helper methods for specific operations
-
-func (tui *ServinTUI) runNewContainer() {
-	image := tui.getInput("Enter image name: ")
-	if image == "" {
-		fmt.Println("Image name is required.")
+// fillTable replaces a table's rows, keeping the row with the selected key
+// selected
+func fillTable(table *tview.Table, header []string, rows [][]string, keys []string, selected string) {
+	table.Clear()
+	for col, title := range header {
+		table.SetCell(0, col, tview.NewTableCell(title).SetSelectable(false).SetTextColor(tcell.ColorYellow))
+	}
+	if len(rows) == 0 {
+		table.SetCell(1, 0, tview.NewTableCell("(none)").SetSelectable(false).SetTextColor(tcell.ColorGray))
 		return
 	}
-
-	name := tui.getInput("Enter container name (optional): ")
-	command := tui.getInput("Enter command to run (optional): ")
-
-	args := []string{"run"}
-	if name != "" {
-		args = append(args, "--name", name)
+	for row, cells := range rows {
+		for col, text := range cells {
+			// The first column takes the space left over
+			expansion := 0
+			if col == 0 {
+				expansion = 1
+			}
+			table.SetCell(row+1, col, tview.NewTableCell(tview.Escape(text)).SetExpansion(expansion))
+		}
 	}
-	args = append(args, image)
-	if command != "" {
-		args = append(args, strings.Fields(command)...)
+	row := 1
+	for i, key := range keys {
+		if key == selected {
+			row = i + 1
+		}
 	}
-
-	tui.runCommand("servin", args...)
+	table.Select(row, 0)
 }
 
-func (tui *ServinTUI) startContainer() {
-	containerID := tui.getInput("Enter container ID: ")
-	if containerID == "" {
-		fmt.Println("Container ID is required.")
-		return
+func (tui *ServinTUI) selectedContainer() (containerEntry, bool) {
+	row, _ := tui.panes[paneContainers].GetSelection()
+	if row < 1 || row > len(tui.containers) {
+		return containerEntry{}, false
 	}
-	tui.runCommand("servin", "start", containerID)
+	return tui.containers[row-1], true
 }
 
-func (tui *ServinTUI) stopContainer() {
-	containerID := tui.getInput("Enter container ID: ")
-	if containerID == "" {
-		fmt.Println("Container ID is required.")
-		return
+func (tui *ServinTUI) selectedImage() (imageEntry, bool) {
+	row, _ := tui.panes[paneImages].GetSelection()
+	if row < 1 || row > len(tui.images) {
+		return imageEntry{}, false
 	}
-	tui.runCommand("servin", "stop", containerID)
+	return tui.images[row-1], true
 }
 
-func (tui *ServinTUI) removeContainer() {
-	containerID := tui.getInput("Enter container ID: ")
-	if containerID == "" {
-		fmt.Println("Container ID is required.")
-		return
-	}
-
-	confirm := tui.getInput("Are you sure? (y/N): ")
-	if strings.ToLower(confirm) == "y" || strings.ToLower(confirm) == "yes" {
-		tui.runCommand("servin", "rm", containerID)
+func (tui *ServinTUI) selectedVolume() (volumeEntry, bool) {
+	row, _ := tui.panes[paneVolumes].GetSelection()
+	if row < 1 || row > len(tui.volumes) {
+		return volumeEntry{}, false
 	}
+	return tui.volumes[row-1], true
 }
 
-func (tui *ServinTUI) viewContainerLogs() {
-	containerID := tui.getInput("Enter container ID: ")
-	if containerID == "" {
-		fmt.Println("Container ID is required.")
-		return
-	}
-
-	follow := tui.getInput("Follow logs? (y/N): ")
-	if strings.ToLower(follow) == "y" || strings.ToLower(follow) == "yes" {
-		tui.runCommand("servin", "logs", "-f", containerID)
-	} else {
-		tui.runCommand("servin", "logs", containerID)
+// showDetail fills the detail pane for the selection in the focused pane:
+// a container's logs, followed as they are written, or what inspect says
+// about it, an image or a volume
+func (tui *ServinTUI) showDetail() {
+	var key, title string
+	var inspect func() ([]byte, error)
+	switch tui.focused {
+	case paneContainers:
+		if c, ok := tui.selectedContainer(); ok && tui.inspect {
+			key, title = "container:"+c.ID, "Container "+c.Name
+			inspect = func() ([]byte, error) { return inspectContainer(c.ID) }
+		} else if ok {
+			key, title = "logs:"+c.ID, "Logs: "+c.Name
+		}
+	case paneImages:
+		if img, ok := tui.selectedImage(); ok {
+			key, title = "image:"+img.Ref(), "Image "+img.Ref()
+			inspect = func() ([]byte, error) { return inspectImage(img.Ref()) }
+		}
+	case paneVolumes:
+		if vol, ok := tui.selectedVolume(); ok {
+			key, title = "volume:"+vol.Name, "Volume "+vol.Name
+			inspect = func() ([]byte, error) { return inspectVolume(vol.Name) }
+		}
 	}
-}
-
-func (tui *ServinTUI) execInContainer() {
-	containerID := tui.getInput("Enter container ID: ")
-	if containerID == "" {
-		fmt.Println("Container ID is required.")
+	if key == tui.shown {
 		return
 	}
+	tui.shown = key
 
-	command := tui.getInput("Enter command to execute: ")
-	if command == "" {
-		command = "sh"
+	if tui.tail != nil {
+		tui.tail.Stop()
+		tui.tail = nil
 	}
-
-	args := append([]string{"exec", containerID}, strings.Fields(command)...)
-	tui.runCommand("servin", args...)
-}
-
-func (tui *ServinTUI) importImage() {
-	filepath := tui.getInput("Enter image file path: ")
-	if filepath == "" {
-		fmt.Println("File path is required.")
+	tui.detail.Clear()
+	if key == "" {
+		tui.detail.SetTitle(" Details ")
 		return
 	}
-	tui.runCommand("servin", "image", "import", filepath)
-}
-
-func (tui *ServinTUI) removeImage() {
-	imageName := tui.getInput("Enter image name: ")
-	if imageName == "" {
-		fmt.Println("Image name is required.")
+	tui.detail.SetTitle(" " + tview.Escape(title) + " ")
+
+	if inspect == nil {
+		c, _ := tui.selectedContainer()
+		var tail *logTail
+		tail = startLogTail(c.ID, logTailLines, func(lines []logLine) {
+			tui.app.QueueUpdateDraw(func() {
+				if tui.tail != tail {
+					return
+				}
+				var text strings.Builder
+				for _, line := range lines {
+					text.WriteString(line.Text + "\n")
+				}
+				tui.detail.Write([]byte(text.String()))
+			})
+		})
+		tui.tail = tail
+		tui.detail.ScrollToEnd()
 		return
 	}
 
-	confirm := tui.getInput("Are you sure? (y/N): ")
-	if strings.ToLower(confirm) == "y" || strings.ToLower(confirm) == "yes" {
-		tui.runCommand("servin", "image", "rm", imageName)
+	go func() {
+		out, err := inspect()
+		tui.app.QueueUpdateDraw(func() {
+			if tui.shown != key {
+				return
+			}
+			if err != nil {
+				tui.detail.SetText(errorText(err))
+			} else {
+				tui.detail.SetText(string(out))
+			}
+			tui.detail.ScrollToBeginning()
+		})
+	}()
+}
+
+// reloadDetail fetches what the detail pane shows again
+func (tui *ServinTUI) reloadDetail() {
+	tui.shown = ""
+	tui.showDetail()
+}
+
+func (tui *ServinTUI) focusPane(pane int) {
+	tui.app.SetFocus(tui.panes[pane])
+}
+
+func (tui *ServinTUI) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	// Dialogs take their own keys
+	switch name, _ := tui.pages.GetFrontPage(); name {
+	case "main":
+	case "stats":
+		return tui.statsKey(event)
+	case "logs":
+		return tui.viewer.handleKey(event)
+	default:
+		return event
+	}
+
+	switch event.Key() {
+	case tcell.KeyTab:
+		tui.focusPane((tui.focused + 1) % paneCount)
+		return nil
+	case tcell.KeyBacktab:
+		tui.focusPane((tui.focused + paneCount - 1) % paneCount)
+		return nil
+	case tcell.KeyEnter:
+		if tui.app.GetFocus() != tui.detail {
+			tui.app.SetFocus(tui.detail)
+			return nil
+		}
+	case tcell.KeyRune:
+	default:
+		return event
+	}
+
+	switch event.Rune() {
+	case 'q':
+		tui.app.Stop()
+	case '1', '2', '3':
+		tui.focusPane(int(event.Rune() - '1'))
+	case 'r':
+		tui.reloadDetail()
+		tui.refresh()
+	case 't':
+		tui.toggleStats()
+	case '?':
+		tui.showHelp()
+	case ':':
+		tui.promptCommand()
+	default:
+		switch tui.focused {
+		case paneContainers:
+			return tui.containerKey(event)
+		case paneImages:
+			return tui.imageKey(event)
+		case paneVolumes:
+			return tui.volumeKey(event)
+		}
+		return event
 	}
+	return nil
 }
 
-func (tui *ServinTUI) tagImage() {
-	source := tui.getInput("Enter source image: ")
-	if source == "" {
-		fmt.Println("Source image is required.")
-		return
+func (tui *ServinTUI) containerKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Rune() == 'n' {
+		tui.showRunForm("")
+		return nil
 	}
-
-	target := tui.getInput("Enter target tag: ")
-	if target == "" {
-		fmt.Println("Target tag is required.")
-		return
+	c, ok := tui.selectedContainer()
+	if !ok {
+		return event
 	}
-
-	tui.runCommand("servin", "image", "tag", source, target)
-}
-
-func (tui *ServinTUI) inspectImage() {
-	imageName := tui.getInput("Enter image name: ")
-	if imageName == "" {
-		fmt.Println("Image name is required.")
-		return
+	switch event.Rune() {
+	case 's':
+		if c.Status != "running" {
+			tui.setStatus(c.Name+" is not running", true)
+			return nil
+		}
+		tui.runAction("Stopping "+c.Name, "Stopped "+c.Name, func() error { return stopContainer(c.ID) })
+	case 'd':
+		if c.Protected {
+			tui.setStatus(c.Name+" is protected", true)
+			return nil
+		}
+		text := fmt.Sprintf("Remove the container %s?", c.Name)
+		if c.Status == "running" {
+			text = fmt.Sprintf("Stop and remove the running container %s?", c.Name)
+		}
+		tui.confirm(text, "Remove", func() {
+			tui.runAction("Removing "+c.Name, "Removed "+c.Name, func() error { return removeContainer(c.ID) })
+		})
+	case 'x':
+		if c.Status != "running" {
+			tui.setStatus(c.Name+" is not running", true)
+			return nil
+		}
+		tui.runInTerminal("sh in "+c.Name, false, func() error { return openShell(c.ID) })
+	case 'i':
+		tui.inspect = !tui.inspect
+		tui.showDetail()
+	case 'l':
+		tui.openLogViewer(c)
+	default:
+		return event
 	}
-	tui.runCommand("servin", "image", "inspect", imageName)
+	return nil
 }
 
-func (tui *ServinTUI) buildImage() {
-	path := tui.getInput("Enter build context path (default: .): ")
-	if path == "" {
-		path = "."
+func (tui *ServinTUI) imageKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Rune() == 'p' {
+		tui.prompt("Pull Image", "Image", "", func(ref string) {
+			tui.runAction("Pulling "+ref, "Pulled "+ref, func() error { return pullImage(ref) })
+		})
+		return nil
 	}
-
-	tag := tui.getInput("Enter image tag (optional): ")
-
-	args := []string{"build"}
-	if tag != "" {
-		args = append(args, "-t", tag)
+	img, ok := tui.selectedImage()
+	if !ok {
+		return event
 	}
-	args = append(args, path)
-
-	tui.runCommand("servin", args...)
+	switch event.Rune() {
+	case 'd':
+		tui.confirm(fmt.Sprintf("Remove the image %s?", img.Ref()), "Remove", func() {
+			tui.runAction("Removing "+img.Ref(), "Removed "+img.Ref(), func() error { return removeImage(img.Ref()) })
+		})
+	case 'n':
+		tui.showRunForm(img.Ref())
+	default:
+		return event
+	}
+	return nil
 }
 
-func (tui *ServinTUI) startCRIServer() {
-	port := tui.getInput("Enter port (default: 8080): ")
-	if port == "" {
-		port = "8080"
+func (tui *ServinTUI) volumeKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Rune() == 'c' {
+		tui.prompt("Create Volume", "Name", "", func(name string) {
+			tui.runAction("Creating "+name, "Created "+name, func() error { return createVolume(name) })
+		})
+		return nil
 	}
-
-	// Validate port
-	if _, err := strconv.Atoi(port); err != nil {
-		fmt.Println("Invalid port number.")
-		return
+	vol, ok := tui.selectedVolume()
+	if !ok {
+		return event
 	}
-
-	fmt.Printf("Starting CRI server on port %s...\n", port)
-	fmt.Println("Note: This will run in the background. Use Ctrl+C to stop.")
-	tui.runCommand("servin", "cri", "start", "--port", port, "--verbose")
-}
-
-func (tui *ServinTUI) testCRIConnection() {
-	fmt.Println("Testing CRI connection to http://localhost:8080...")
-
-	// Test using curl if available, otherwise use servin cri test
-	cmd := exec.Command("curl", "-s", "http://localhost:8080/health")
-	if err := cmd.Run(); err != nil {
-		fmt.Println("Curl not available, trying servin cri test...")
-		tui.runCommand("servin", "cri", "test")
-	} else {
-		fmt.Println("✓ CRI server is responding")
+	switch event.Rune() {
+	case 'd':
+		if vol.Protected {
+			tui.setStatus(vol.Name+" is protected", true)
+			return nil
+		}
+		tui.confirm(fmt.Sprintf("Remove the volume %s and the data in it?", vol.Name), "Remove", func() {
+			tui.runAction("Removing "+vol.Name, "Removed "+vol.Name, func() error { return removeVolume(vol.Name) })
+		})
+	default:
+		return event
 	}
+	return nil
 }
 
-func (tui *ServinTUI) showCRIEndpoints() {
-	fmt.Println("╔═══════════════ CRI Endpoints ════════════════════╗")
-	fmt.Println("║ Health Check:                                     ║")
-	fmt.Println("║   GET  /health                                    ║")
-	fmt.Println("║                                                   ║")
-	fmt.Println("║ Runtime Operations:                               ║")
-	fmt.Println("║   POST /v1/runtime/version                        ║")
-	fmt.Println("║   POST /v1/runtime/status                         ║")
-	fmt.Println("║                                                   ║")
-	fmt.Println("║ Pod Sandbox Operations:                           ║")
-	fmt.Println("║   POST /v1/runtime/sandbox/list                   ║")
-	fmt.Println("║   POST /v1/runtime/sandbox/create                 ║")
-	fmt.Println("║   POST /v1/runtime/sandbox/start                  ║")
-	fmt.Println("║   POST /v1/runtime/sandbox/stop                   ║")
-	fmt.Println("║   POST /v1/runtime/sandbox/remove                 ║")
-	fmt.Println("║                                                   ║")
-	fmt.Println("║ Container Operations:                             ║")
-	fmt.Println("║   POST /v1/runtime/container/list                 ║")
-	fmt.Println("║   POST /v1/runtime/container/create               ║")
-	fmt.Println("║   POST /v1/runtime/container/start                ║")
-	fmt.Println("║   POST /v1/runtime/container/stop                 ║")
-	fmt.Println("║   POST /v1/runtime/container/remove               ║")
-	fmt.Println("║   POST /v1/runtime/container/status               ║")
-	fmt.Println("║                                                   ║")
-	fmt.Println("║ Image Operations:                                 ║")
-	fmt.Println("║   POST /v1/image/list                             ║")
-	fmt.Println("║   POST /v1/image/status                           ║")
-	fmt.Println("║   POST /v1/image/pull                             ║")
-	fmt.Println("║   POST /v1/image/remove                           ║")
-	fmt.Println("║   POST /v1/image/fs                               ║")
-	fmt.Println("╚═══════════════════════════════════════════════════╝")
+// runAction runs an operation in the background, reporting in the status
+// line while it runs and once it is done
+func (tui *ServinTUI) runAction(doing, done string, action func() error) {
+	tui.setStatus(doing+"...", false)
+	go func() {
+		err := action()
+		tui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				tui.setStatus(errorText(err), true)
+			} else {
+				tui.setStatus(done, false)
+			}
+			tui.reloadDetail()
+		})
+		tui.refresh()
+	}()
 }
 
-func (tui *ServinTUI) createVolume() {
-	name := tui.getInput("Enter volume name: ")
-	if name == "" {
-		fmt.Println("Volume name is required.")
-		return
+func (tui *ServinTUI) setStatus(message string, failed bool) {
+	color := "green"
+	if failed {
+		color = "red"
 	}
-	tui.runCommand("servin", "volume", "create", name)
+	// Only the first line of an error fits
+	message, _, _ = strings.Cut(message, "\n")
+	tui.status.SetText(fmt.Sprintf("[%s]%s", color, tview.Escape(message)))
 }
 
-func (tui *ServinTUI) removeVolume() {
-	name := tui.getInput("Enter volume name: ")
-	if name == "" {
-		fmt.Println("Volume name is required.")
-		return
-	}
-
-	confirm := tui.getInput("Are you sure? (y/N): ")
-	if strings.ToLower(confirm) == "y" || strings.ToLower(confirm) == "yes" {
-		tui.runCommand("servin", "volume", "rm", name)
+// statusColor matches the GUI's status badges
+func statusColor(status string) tcell.Color {
+	switch status {
+	case "running":
+		return tcell.ColorGreen
+	case "exited", "stopped":
+		return tcell.ColorRed
+	case "paused":
+		return tcell.ColorYellow
+	case "created":
+		return tcell.ColorBlue
 	}
+	return tcell.ColorDefault
 }
 
-func (tui *ServinTUI) inspectVolume() {
-	name := tui.getInput("Enter volume name: ")
-	if name == "" {
-		fmt.Println("Volume name is required.")
-		return
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
 	}
-	tui.runCommand("servin", "volume", "inspect", name)
+	return id
 }
 
-func (tui *ServinTUI) removeAllVolumes() {
-	confirm := tui.getInput("Remove ALL volumes? This cannot be undone! (y/N): ")
-	if strings.ToLower(confirm) == "y" || strings.ToLower(confirm) == "yes" {
-		tui.runCommand("servin", "volume", "rm-all")
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
 	}
-}
-
-func (tui *ServinTUI) pushToRegistry() {
-	image := tui.getInput("Enter image name to push: ")
-	if image == "" {
-		fmt.Println("Image name is required.")
-		return
-	}
-
-	registry := tui.getInput("Enter registry address (optional): ")
-
-	if registry != "" {
-		tui.runCommand("servin", "registry", "push", image, registry)
-	} else {
-		tui.runCommand("servin", "registry", "push", image)
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
 	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-func (tui *ServinTUI) pullFromRegistry() {
-	image := tui.getInput("Enter image name to pull: ")
-	if image == "" {
-		fmt.Println("Image name is required.")
+func main() {
+	// The runtime starts this binary again for a container's init, its shim
+	// and its network's resolver, as it does servin; run those as servin would
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := cli.Execute(); err != nil {
+			os.Exit(cli.ReportError(err))
+		}
 		return
 	}
 
-	registry := tui.getInput("Enter registry address (optional): ")
+	interval := flag.Duration("refresh", 2*time.Second, "How often the lists refresh (0 to only refresh on r)")
+	flag.Parse()
 
-	if registry != "" {
-		tui.runCommand("servin", "registry", "pull", image, registry)
-	} else {
-		tui.runCommand("servin", "registry", "pull", image)
+	if err := redirectOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-}
-
-// Utility methods
-
-func (tui *ServinTUI) getInput(prompt string) string {
-	fmt.Print(prompt)
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	return strings.TrimSpace(input)
-}
-
-func (tui *ServinTUI) runCommand(command string, args ...string) {
-	fmt.Printf("Running: %s %s\n", command, strings.Join(args, " "))
-	fmt.Println("─────────────────────────────────────────────────────")
-
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
+	if err := NewServinTUI(*interval).Run(); err != nil {
+		fmt.Fprintf(termErr, "Error: %s\n", errorText(err))
+		os.Exit(1)
 	}
-
-	fmt.Println("─────────────────────────────────────────────────────")
-}
-
-func main() {
-	tui := NewServinTUI()
-	tui.Run()
 }
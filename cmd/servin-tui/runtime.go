@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"servin/pkg/config"
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/image"
+	"servin/pkg/network"
+	"servin/pkg/state"
+	"servin/pkg/volume"
+)
+
+// protectedLabel marks a container or volume servin rm and volume rm refuse
+// to remove without --override-protection; the TUI never overrides it
+const protectedLabel = "protected"
+
+// containerEntry is a row of the containers pane
+type containerEntry struct {
+	ID        string
+	Name      string
+	Image     string
+	Created   time.Time
+	Status    string
+	Health    string
+	Protected bool
+}
+
+// imageEntry is a row of the images pane, one for each tag of an image
+type imageEntry struct {
+	ID         string
+	Repository string
+	Tag        string
+	Size       int64
+	Containers int
+}
+
+// Ref is the name the image is referred to by
+func (img imageEntry) Ref() string {
+	if img.Repository == "" || img.Repository == "<none>" {
+		return img.ID
+	}
+	return img.Repository + ":" + img.Tag
+}
+
+// volumeEntry is a row of the volumes pane
+type volumeEntry struct {
+	Name       string
+	Driver     string
+	Created    time.Time
+	Containers int
+	Protected  bool
+}
+
+// isProtected matches the CLI: any value but "false" protects
+func isProtected(labels map[string]string) bool {
+	value, ok := labels[protectedLabel]
+	return ok && value != "false"
+}
+
+// typed keeps the error type of an error the runtime already typed, and
+// gives errType to one it did not
+func typed(err error, errType errors.ErrorType, operation, message string) error {
+	if _, ok := errors.As(err); ok {
+		return err
+	}
+	return errors.WrapError(err, errType, operation, message)
+}
+
+func listContainers() ([]containerEntry, error) {
+	containers, err := state.NewStateManager().ListContainers()
+	if err != nil {
+		return nil, typed(err, errors.ErrTypeIO, "ls", "failed to list containers")
+	}
+	entries := make([]containerEntry, 0, len(containers))
+	for _, c := range containers {
+		entry := containerEntry{
+			ID:        c.ID,
+			Name:      c.Name,
+			Image:     c.Image,
+			Created:   c.Created,
+			Status:    c.Status,
+			Protected: isProtected(c.Labels),
+		}
+		if c.Health != nil {
+			entry.Health = c.Health.Status
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func listImages() ([]imageEntry, error) {
+	imgManager := image.NewManager()
+	images, err := imgManager.ListImages()
+	if err != nil {
+		return nil, typed(err, errors.ErrTypeImage, "image ls", "failed to list images")
+	}
+
+	// Count the containers using each image, as servin image ls does
+	users := make(map[string]int)
+	if containers, err := state.NewStateManager().ListContainers(); err == nil {
+		for _, c := range containers {
+			if id := imgManager.ContainerImageID(c); id != "" {
+				users[id]++
+			}
+		}
+	}
+
+	var entries []imageEntry
+	for _, img := range images {
+		for _, repoTag := range img.RepoTags {
+			repo, tag := repoTag, "latest"
+			if i := strings.LastIndex(repoTag, ":"); i > strings.LastIndex(repoTag, "/") {
+				repo, tag = repoTag[:i], repoTag[i+1:]
+			}
+			entries = append(entries, imageEntry{
+				ID:         img.ID,
+				Repository: repo,
+				Tag:        tag,
+				Size:       img.Size,
+				Containers: users[img.ID],
+			})
+		}
+	}
+	return entries, nil
+}
+
+func listVolumes() ([]volumeEntry, error) {
+	volumes, err := volume.NewManager().ListVolumes()
+	if err != nil {
+		return nil, typed(err, errors.ErrTypeVolume, "volume ls", "failed to list volumes")
+	}
+
+	// Volumes are shared by every namespace, so containers in all of them
+	// use a volume
+	used := make(map[string]int)
+	if containers, err := state.NewStateManager().AllNamespaces().ListContainers(); err == nil {
+		for _, c := range containers {
+			for source := range c.Volumes {
+				used[source]++
+			}
+		}
+	}
+
+	entries := make([]volumeEntry, 0, len(volumes))
+	for _, vol := range volumes {
+		entries = append(entries, volumeEntry{
+			Name:       vol.Name,
+			Driver:     vol.Driver,
+			Created:    vol.CreatedAt,
+			Containers: used[vol.Name] + used[vol.Mountpoint],
+			Protected:  isProtected(vol.Labels),
+		})
+	}
+	return entries, nil
+}
+
+func loadContainer(operation, id string) (*state.StateManager, *state.ContainerState, error) {
+	sm := state.NewStateManager()
+	cs, err := sm.LoadContainer(id)
+	if err != nil {
+		return nil, nil, errors.WrapError(err, errors.ErrTypeNotFound, operation, fmt.Sprintf("container %s not found", shortID(id)))
+	}
+	return sm, cs, nil
+}
+
+func inspectContainer(id string) ([]byte, error) {
+	_, cs, err := loadContainer("inspect", id)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(cs, "", "  ")
+}
+
+func inspectImage(ref string) ([]byte, error) {
+	img, err := image.NewManager().GetImage(ref)
+	if err != nil {
+		return nil, typed(err, errors.ErrTypeNotFound, "image inspect", fmt.Sprintf("image %s not found", ref))
+	}
+	return json.MarshalIndent(img, "", "  ")
+}
+
+func inspectVolume(name string) ([]byte, error) {
+	vol, err := volume.NewManager().GetVolume(name)
+	if err != nil {
+		return nil, typed(err, errors.ErrTypeNotFound, "volume inspect", fmt.Sprintf("volume %s not found", name))
+	}
+	return json.MarshalIndent(vol, "", "  ")
+}
+
+func stopContainer(id string) error {
+	sm, cs, err := loadContainer("stop", id)
+	if err != nil {
+		return err
+	}
+	return container.Stop(sm, cs)
+}
+
+// removeContainer removes a container, stopping it first if it runs
+func removeContainer(id string) error {
+	sm, cs, err := loadContainer("rm", id)
+	if err != nil {
+		return err
+	}
+	if isProtected(cs.Labels) {
+		return errors.NewConflictError("rm", fmt.Sprintf("container %s is protected; remove it with servin rm --override-protection", cs.Name))
+	}
+	if cs.Status == state.StatusRunning {
+		if err := container.Stop(sm, cs); err != nil {
+			return err
+		}
+		cs.Status = state.StatusStopped
+	}
+	return container.Remove(sm, cs)
+}
+
+// newContainer creates a container of an image running argv, with the
+// image's health check and stop signal as servin run gives it; with no argv
+// it runs the image's entrypoint and command
+func newContainer(ref, name string, argv []string) (*container.Container, error) {
+	spec := &container.Config{
+		Image:       ref,
+		Name:        name,
+		WorkDir:     "/",
+		Env:         map[string]string{},
+		NetworkMode: string(network.BridgeMode),
+	}
+	if img, err := image.NewManager().GetImage(ref); err == nil {
+		if hc := img.Config.Healthcheck; !hc.Disabled() {
+			copied := *hc
+			spec.Healthcheck = &copied
+		}
+		spec.StopSignal = img.Config.StopSignal
+		if len(argv) == 0 {
+			argv = append(append([]string{}, img.Config.Entrypoint...), img.Config.Cmd...)
+		}
+	}
+	if len(argv) == 0 {
+		return nil, errors.NewValidationError("run", fmt.Sprintf("no command given and image %s sets none", ref))
+	}
+	spec.Command, spec.Args = argv[0], argv[1:]
+
+	c, err := container.New(spec)
+	if err != nil {
+		return nil, typed(err, errors.ErrTypeContainer, "run", "failed to create container")
+	}
+	return c, nil
+}
+
+// startContainer runs a new container in the background, under a shim as
+// servin run --detach does
+func startContainer(ref, name string, argv []string) error {
+	c, err := newContainer(ref, name, argv)
+	if err != nil {
+		return err
+	}
+	if err := c.RunDetached(); err != nil {
+		return typed(err, errors.ErrTypeContainer, "run", fmt.Sprintf("failed to start container %s", c.Config.Name))
+	}
+	return nil
+}
+
+// runContainer runs a new container attached to the terminal until it exits
+func runContainer(ref, name string, argv []string) error {
+	c, err := newContainer(ref, name, argv)
+	if err != nil {
+		return err
+	}
+	return c.RunWithVM()
+}
+
+// openShell runs sh in a running container, attached to the terminal
+func openShell(id string) error {
+	_, cs, err := loadContainer("exec", id)
+	if err != nil {
+		return err
+	}
+	return container.Exec(cs, []string{"sh"})
+}
+
+// removeImage removes an image unless servin image rm would refuse to
+// without --force or --override-protection
+func removeImage(ref string) error {
+	cfg, _, err := config.Load()
+	if err != nil {
+		return typed(err, errors.ErrTypeConfig, "image rm", "failed to load config.yaml")
+	}
+	_, err = image.NewManager().WithImmutableTags(cfg.Daemon.Images.ImmutableTags).RemoveChecked(ref, false)
+	return err
+}
+
+func pullImage(ref string) error {
+	if err := image.NewManager().PullImage(ref, image.PullOptions{}); err != nil {
+		return typed(err, errors.ErrTypeImage, "image pull", fmt.Sprintf("failed to pull image %s", ref))
+	}
+	return nil
+}
+
+func createVolume(name string) error {
+	_, err := volume.NewManager().CreateVolume(name, "local", map[string]string{}, map[string]string{})
+	return err
+}
+
+func removeVolume(name string) error {
+	volManager := volume.NewManager()
+	if vol, err := volManager.GetVolume(name); err == nil && isProtected(vol.Labels) {
+		return errors.NewConflictError("volume rm", fmt.Sprintf("volume %s is protected; remove it with servin volume rm --override-protection", name))
+	}
+	return volManager.RemoveVolume(name, false)
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/logger"
+	"servin/pkg/state"
+	"servin/pkg/stats"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	// statsInterval is how often the running containers are sampled
+	statsInterval = 2 * time.Second
+	// statsHistory is how many samples a sparkline spans, thirty seconds' worth
+	statsHistory = 15
+)
+
+// sparkBlocks draw a sparkline, lowest value first
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// The columns the stats view sorts by, with the key that picks each
+const (
+	sortCPU = iota
+	sortMemory
+	sortName
+	sortPIDs
+)
+
+var statsSortKeys = map[rune]int{'c': sortCPU, 'm': sortMemory, 'n': sortName, 'p': sortPIDs}
+
+// statsRow is a running container's latest sample and those before it
+type statsRow struct {
+	*stats.Stats
+	CPU    []float64
+	Memory []float64
+}
+
+// statsSampler samples the running containers, locally or through the VM
+// where containers run inside one, keeping each one's recent history. It is
+// only used from the sampling goroutine.
+type statsSampler struct {
+	collector *stats.Collector
+	vmManager *container.VMContainerManager
+	histories map[string]*stats.History
+}
+
+func newStatsSampler() *statsSampler {
+	s := &statsSampler{
+		collector: stats.NewCollector(),
+		histories: make(map[string]*stats.History),
+	}
+	if runtime.GOOS != "linux" {
+		if vmManager, err := container.NewVMContainerManager(); err == nil && vmManager.IsEnabled() {
+			s.vmManager = vmManager
+		}
+	}
+	return s
+}
+
+// sample takes a sample of every running container; containers that
+// stopped are forgotten
+func (s *statsSampler) sample() ([]statsRow, error) {
+	all, err := state.NewStateManager().ListContainers()
+	if err != nil {
+		return nil, typed(err, errors.ErrTypeIO, "stats", "failed to list containers")
+	}
+	var running []*state.ContainerState
+	for _, c := range all {
+		if c.Status == state.StatusRunning {
+			running = append(running, c)
+		}
+	}
+
+	keep := make(map[string]bool)
+	rows := make([]statsRow, 0, len(running))
+	for _, sample := range s.collect(running) {
+		keep[sample.ID] = true
+		h, ok := s.histories[sample.ID]
+		if !ok {
+			h = stats.NewHistory(statsHistory)
+			s.histories[sample.ID] = h
+		}
+		h.Add(sample)
+
+		row := statsRow{Stats: sample}
+		for _, prev := range h.Samples() {
+			row.CPU = append(row.CPU, prev.CPUPercent)
+			row.Memory = append(row.Memory, float64(prev.MemoryUsage))
+		}
+		rows = append(rows, row)
+	}
+	for id := range s.histories {
+		if !keep[id] {
+			delete(s.histories, id)
+		}
+	}
+	return rows, nil
+}
+
+func (s *statsSampler) collect(containers []*state.ContainerState) []*stats.Stats {
+	if s.vmManager != nil && len(containers) > 0 {
+		ids := make([]string, len(containers))
+		for i, c := range containers {
+			ids[i] = c.ID
+		}
+		samples, err := s.vmManager.VMContainerStats(ids)
+		if err == nil {
+			for _, sample := range samples {
+				s.collector.Update(sample)
+			}
+			return samples
+		}
+		logger.Debug("Falling back to local stats: %v", err)
+	}
+
+	samples := make([]*stats.Stats, 0, len(containers))
+	for _, c := range containers {
+		sample, err := s.collector.Collect(c)
+		if err != nil {
+			logger.Debug("Failed to collect stats for %s: %v", c.ID, err)
+			sample = &stats.Stats{ID: c.ID, Name: c.Name, Read: time.Now()}
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// sampleStats samples the running containers every statsInterval for the
+// stats view, until the TUI quits
+func (tui *ServinTUI) sampleStats(done <-chan struct{}) {
+	sampler := newStatsSampler()
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	for {
+		rows, err := sampler.sample()
+		tui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				if tui.statsShown() {
+					tui.setStatus(errorText(err), true)
+				}
+				return
+			}
+			tui.setStats(rows)
+		})
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newStatsView builds the stats page: a row for each running container with
+// its CPU and memory use over the last samples, then their totals
+func (tui *ServinTUI) newStatsView() tview.Primitive {
+	tui.statsTable = tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	tui.statsTable.SetBorder(true).SetTitle(" Stats ")
+	keys := tview.NewTextView().SetTextColor(tcell.ColorGray).
+		SetText("c cpu  m memory  n name  p pids (again to reverse)  |  t, esc back  q quit")
+	tui.setStats(nil)
+	return tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tui.statsTable, 0, 1, true).
+		AddItem(keys, 1, 0, false)
+}
+
+func (tui *ServinTUI) statsShown() bool {
+	name, _ := tui.pages.GetFrontPage()
+	return name == "stats"
+}
+
+// toggleStats switches between the panes and the stats view
+func (tui *ServinTUI) toggleStats() {
+	if tui.statsShown() {
+		tui.pages.SwitchToPage("main")
+		tui.app.SetFocus(tui.panes[tui.focused])
+		return
+	}
+	tui.pages.SwitchToPage("stats")
+	tui.app.SetFocus(tui.statsTable)
+}
+
+func (tui *ServinTUI) statsKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape {
+		tui.toggleStats()
+		return nil
+	}
+	if event.Key() != tcell.KeyRune {
+		return event
+	}
+	switch r := event.Rune(); r {
+	case 't':
+		tui.toggleStats()
+	case 'q':
+		tui.app.Stop()
+	default:
+		column, ok := statsSortKeys[r]
+		if !ok {
+			return event
+		}
+		if column == tui.statsSort {
+			tui.statsReverse = !tui.statsReverse
+		} else {
+			tui.statsSort, tui.statsReverse = column, false
+		}
+		tui.setStats(tui.stats)
+	}
+	return nil
+}
+
+// setStats fills the stats view, sorted by the chosen column: names A to Z,
+// the rest largest first
+func (tui *ServinTUI) setStats(rows []statsRow) {
+	selected := ""
+	if row, _ := tui.statsTable.GetSelection(); row >= 1 && row <= len(tui.stats) {
+		selected = tui.stats[row-1].ID
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if tui.statsReverse {
+			a, b = b, a
+		}
+		switch tui.statsSort {
+		case sortCPU:
+			return a.CPUPercent > b.CPUPercent
+		case sortMemory:
+			return a.MemoryUsage > b.MemoryUsage
+		case sortPIDs:
+			return a.PIDs > b.PIDs
+		}
+		return a.Name < b.Name
+	})
+	tui.stats = rows
+
+	table := tui.statsTable
+	table.Clear()
+	header := []string{"NAME", "CPU %", "CPU", "MEM USAGE", "MEM %", "MEMORY", "PIDS"}
+	sorted := map[int]int{sortName: 0, sortCPU: 1, sortMemory: 3, sortPIDs: 6}[tui.statsSort]
+	for col, title := range header {
+		if col == sorted {
+			if tui.statsReverse {
+				title += " ▲"
+			} else {
+				title += " ▼"
+			}
+		}
+		table.SetCell(0, col, tview.NewTableCell(title).SetSelectable(false).SetTextColor(tcell.ColorYellow))
+	}
+	if len(rows) == 0 {
+		table.SetCell(1, 0, tview.NewTableCell("(no running containers)").SetSelectable(false).SetTextColor(tcell.ColorGray))
+		return
+	}
+
+	var total stats.Stats
+	for i, row := range rows {
+		cells := []string{
+			tview.Escape(row.Name),
+			fmt.Sprintf("%.1f%%", row.CPUPercent),
+			sparkline(row.CPU, 0, 100),
+			formatSize(int64(row.MemoryUsage)),
+			fmt.Sprintf("%.1f%%", row.MemoryPercent),
+			sparkline(row.Memory, lowest(row.Memory), 1<<20),
+			fmt.Sprint(row.PIDs),
+		}
+		for col, text := range cells {
+			cell := tview.NewTableCell(text)
+			switch col {
+			case 0:
+				cell.SetExpansion(1)
+			case 2:
+				cell.SetTextColor(tcell.ColorGreen)
+			case 5:
+				cell.SetTextColor(tcell.ColorBlue)
+			}
+			table.SetCell(i+1, col, cell)
+		}
+
+		total.CPUPercent += row.CPUPercent
+		total.MemoryUsage += row.MemoryUsage
+		total.MemoryPercent += row.MemoryPercent
+		total.PIDs += row.PIDs
+	}
+
+	totals := []string{
+		fmt.Sprintf("TOTAL (%d running)", len(rows)),
+		fmt.Sprintf("%.1f%%", total.CPUPercent), "",
+		formatSize(int64(total.MemoryUsage)),
+		fmt.Sprintf("%.1f%%", total.MemoryPercent), "",
+		fmt.Sprint(total.PIDs),
+	}
+	for col, text := range totals {
+		table.SetCell(len(rows)+1, col, tview.NewTableCell(text).SetSelectable(false).SetAttributes(tcell.AttrBold))
+	}
+
+	row := 1
+	for i, r := range rows {
+		if r.ID == selected {
+			row = i + 1
+		}
+	}
+	table.Select(row, 0)
+}
+
+// sparkline draws values oldest first, from low up to the largest of them,
+// or up to low+span when they stay closer together than that
+func sparkline(values []float64, low, span float64) string {
+	top := low + span
+	for _, v := range values {
+		if v > top {
+			top = v
+		}
+	}
+	var b strings.Builder
+	for i := len(values); i < statsHistory; i++ {
+		b.WriteRune(' ')
+	}
+	for _, v := range values {
+		level := int((v-low)/(top-low)*float64(len(sparkBlocks)-1) + 0.5)
+		if level < 0 {
+			level = 0
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// lowest is the smallest of values, so memory sparklines show how use
+// changes rather than how much there is
+func lowest(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	low := values[0]
+	for _, v := range values[1:] {
+		if v < low {
+			low = v
+		}
+	}
+	return low
+}
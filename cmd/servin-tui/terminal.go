@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	cli "servin/cmd"
+	"servin/pkg/errors"
+	"servin/pkg/logger"
+)
+
+// The terminal the TUI was started on. While it runs, os.Stdout and
+// os.Stderr go to the log file, so what the runtime packages print does not
+// land on the screen.
+var (
+	termOut = os.Stdout
+	termErr = os.Stderr
+)
+
+// logPath is the log file the TUI writes to
+var logPath string
+
+// redirectOutput sends the logger, os.Stdout and os.Stderr to a log file
+// next to the CLI's, or in the temporary directory when that is not writable
+func redirectOutput() error {
+	logPath = filepath.Join(filepath.Dir(logger.GetLogPath()), "servin-tui.log")
+	if err := logger.InitFileLogger(logger.INFO, logPath); err != nil {
+		logPath = filepath.Join(os.TempDir(), "servin-tui.log")
+		if err := logger.InitFileLogger(logger.INFO, logPath); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	os.Stdout = file
+	os.Stderr = file
+	return nil
+}
+
+// runCommand runs a servin command line in this process. The command sets
+// up logging as servin does, so the logger goes back to the TUI's log after.
+func runCommand(args []string) error {
+	err := cli.ExecuteArgs(args)
+	logger.Close()
+	if logErr := logger.InitFileLogger(logger.INFO, logPath); logErr != nil {
+		fmt.Fprintf(termErr, "Warning: failed to reopen %s: %v\n", logPath, logErr)
+	}
+	return err
+}
+
+// runInTerminal leaves the TUI to run fn on the terminal, for a shell, a
+// container attached to it or a command that prints or prompts; os.Stdout
+// and os.Stderr are the terminal while it runs. With wait, it returns once
+// Enter is pressed so the output can be read.
+func (tui *ServinTUI) runInTerminal(title string, wait bool, fn func() error) {
+	tui.app.Suspend(func() {
+		// Ctrl+C is for what runs on the terminal, not the TUI
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		defer signal.Stop(interrupts)
+
+		fmt.Fprintf(termOut, "$ %s\n", title)
+		logOut, logErr := os.Stdout, os.Stderr
+		os.Stdout, os.Stderr = termOut, termErr
+		err := fn()
+		os.Stdout, os.Stderr = logOut, logErr
+
+		if err != nil {
+			fmt.Fprintf(termOut, "Error: %s\n", errorText(err))
+			wait = true
+		}
+		if wait {
+			fmt.Fprint(termOut, "\nPress Enter to return to Servin...")
+			bufio.NewReader(os.Stdin).ReadString('\n')
+		}
+	})
+	tui.refresh()
+}
+
+// errorText is how an error shows in the TUI: its category, then what went
+// wrong and why
+func errorText(err error) string {
+	report := errors.NewReport(err)
+	text := report.Category + ": " + report.Message
+	if report.Cause != "" {
+		text += ": " + report.Cause
+	}
+	return text
+}
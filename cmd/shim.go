@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/shim"
+	"servin/pkg/state"
+
+	"github.com/spf13/cobra"
+)
+
+var shimCmd = &cobra.Command{
+	Use:   "shim CONTAINER_ID",
+	Short: "Run a container under a shim process",
+	Long: `Run a saved container as the child of this process, writing its logs and
+recording its exit. 'servin run -d' and the daemon start a shim for every
+detached container so the container does not depend on the process that
+started it; it is not meant to be run by hand.`,
+	Hidden:       true,
+	SilenceUsage: true,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runShim,
+}
+
+func init() {
+	rootCmd.AddCommand(shimCmd)
+}
+
+func runShim(cmd *cobra.Command, args []string) error {
+	cs, err := state.NewStateManager().AllNamespaces().LoadContainer(args[0])
+	if err != nil {
+		return errors.WrapError(err, errors.ErrTypeNotFound, "shim", fmt.Sprintf("container not found: %s", args[0]))
+	}
+
+	fmt.Printf("Shim for container %s started\n", cs.ID[:12])
+	return shim.Serve(cs.ID, container.FromState(cs).Run)
+}
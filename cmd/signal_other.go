@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package cmd
+
+import (
+	"fmt"
+)
+
+// reexec replaces this process with the binary at path (unsupported here)
+func reexec(path string) error {
+	return fmt.Errorf("re-executing the daemon is not supported on this platform")
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// reexec replaces this process with the binary at path, run with the same
+// arguments and environment; the process ID, and so its children, are kept
+func reexec(path string) error {
+	return syscall.Exec(path, os.Args, os.Environ())
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/logger"
+	"servin/pkg/state"
+	"servin/pkg/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [CONTAINER...]",
+	Short: "Display container resource usage statistics",
+	Long: `Display live resource usage statistics for one or more containers.
+
+Shows CPU %, memory usage and limit, network I/O, block I/O and process count.
+On Linux the figures are read from the container's cgroups; on macOS and
+Windows they are reported by Servin running inside the VM.
+
+Without arguments all running containers are shown and the list is refreshed
+as containers start and stop.
+
+Examples:
+  servin stats                        # Stream stats for all running containers
+  servin stats web db                 # Stream stats for specific containers
+  servin stats --no-stream            # Print a single snapshot
+  servin stats --no-stream --format json`,
+	RunE: showContainerStats,
+}
+
+var statsExportCmd = &cobra.Command{
+	Use:   "export CONTAINER",
+	Short: "Export a container's recent resource usage history",
+	Long: `Export the resource usage samples retained for a container as CSV or JSON,
+for example to attach a resource profile to a bug report.
+
+Samples are recorded by 'servin daemon' while it runs (every 5 seconds for the
+last hour by default; see --stats-interval and --stats-retention).
+
+Examples:
+  servin stats export web                              # CSV of all retained samples
+  servin stats export web --since 30m --format csv -o web.csv
+  servin stats export web --since 2024-01-02T13:00:00Z --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: exportContainerStats,
+}
+
+var (
+	statsNoStream bool
+	statsInterval int
+	statsFormat   string
+
+	statsExportSince  string
+	statsExportFormat string
+	statsExportOutput string
+)
+
+// statsPrimeDelay is how long to wait between the first two samples so CPU % can be computed
+const statsPrimeDelay = 500 * time.Millisecond
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().BoolVarP(&statsNoStream, "no-stream", "n", false, "Disable streaming stats and only pull the first result")
+	statsCmd.Flags().IntVarP(&statsInterval, "interval", "i", 1, "Refresh interval in seconds")
+	statsCmd.Flags().StringVar(&statsFormat, "format", "table", "Output format (table, json)")
+
+	statsCmd.AddCommand(statsExportCmd)
+	statsExportCmd.Flags().StringVar(&statsExportSince, "since", "", "Only export samples since timestamp (e.g. 2013-01-02T13:23:37Z) or relative (e.g. 30m)")
+	statsExportCmd.Flags().StringVar(&statsExportFormat, "format", "csv", "Output format (csv, json)")
+	statsExportCmd.Flags().StringVarP(&statsExportOutput, "output", "o", "", "Write to a file instead of stdout")
+}
+
+func showContainerStats(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	if statsFormat != "table" && statsFormat != "json" {
+		return errors.NewValidationError("stats", fmt.Sprintf("unsupported format %q (use table or json)", statsFormat))
+	}
+	if statsInterval < 1 {
+		return errors.NewValidationError("stats", "interval must be at least 1 second")
+	}
+
+	sm := state.NewStateManager()
+	containers, err := statsContainers(sm, args)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 0 && statsFormat == "table" {
+		fmt.Println("No running containers found")
+		return nil
+	}
+
+	source := newStatsSource()
+
+	// The first sample only establishes a CPU baseline
+	source.collect(containers)
+	time.Sleep(statsPrimeDelay)
+
+	for {
+		samples := source.collect(containers)
+
+		if statsFormat == "json" {
+			data, err := json.Marshal(samples)
+			if err != nil {
+				return fmt.Errorf("failed to encode stats: %v", err)
+			}
+			fmt.Println(string(data))
+		} else {
+			if !statsNoStream {
+				fmt.Print("\033[2J\033[H") // Clear screen and move cursor to top
+			}
+			printStatsTable(samples)
+		}
+
+		if statsNoStream {
+			return nil
+		}
+
+		time.Sleep(time.Duration(statsInterval) * time.Second)
+
+		// Pick up containers that started or stopped since the last refresh
+		if len(args) == 0 {
+			if containers, err = statsContainers(sm, args); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// statsContainers returns the containers named in args, or all running containers
+func statsContainers(sm *state.StateManager, args []string) ([]*state.ContainerState, error) {
+	var containers []*state.ContainerState
+
+	if len(args) == 0 {
+		allContainers, err := sm.ListContainers()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range allContainers {
+			if c.Status == state.StatusRunning {
+				containers = append(containers, c)
+			}
+		}
+		return containers, nil
+	}
+
+	for _, containerRef := range args {
+		containerID, err := resolveContainerRef(sm, containerRef)
+		if err != nil {
+			return nil, err
+		}
+		c, err := sm.LoadContainer(containerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load container %s: %v", containerRef, err)
+		}
+		containers = append(containers, c)
+	}
+
+	return containers, nil
+}
+
+// statsSource samples containers locally, or through the VM on platforms
+// where containers run inside one
+type statsSource struct {
+	collector *stats.Collector
+	vmManager *container.VMContainerManager
+}
+
+func newStatsSource() *statsSource {
+	source := &statsSource{collector: stats.NewCollector()}
+
+	if runtime.GOOS != "linux" {
+		if vmManager, err := container.NewVMContainerManager(); err == nil && vmManager.IsEnabled() {
+			source.vmManager = vmManager
+		}
+	}
+
+	return source
+}
+
+func (s *statsSource) collect(containers []*state.ContainerState) []*stats.Stats {
+	if s.vmManager != nil && len(containers) > 0 {
+		ids := make([]string, len(containers))
+		for i, c := range containers {
+			ids[i] = c.ID
+		}
+
+		samples, err := s.vmManager.VMContainerStats(ids)
+		if err == nil {
+			for _, sample := range samples {
+				s.collector.Update(sample)
+			}
+			return samples
+		}
+		logger.Debug("Falling back to local stats: %v", err)
+	}
+
+	samples := make([]*stats.Stats, 0, len(containers))
+	for _, c := range containers {
+		sample, err := s.collector.Collect(c)
+		if err != nil {
+			logger.Debug("Failed to collect stats for %s: %v", c.ID, err)
+			sample = &stats.Stats{ID: c.ID, Name: c.Name, Read: time.Now()}
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+func printStatsTable(samples []*stats.Stats) {
+	fmt.Printf("%-12s %-20s %-8s %-22s %-8s %-22s %-22s %-6s\n",
+		"CONTAINER ID", "NAME", "CPU %", "MEM USAGE / LIMIT", "MEM %", "NET I/O", "BLOCK I/O", "PIDS")
+
+	for _, s := range samples {
+		containerShort := s.ID
+		if len(containerShort) > 12 {
+			containerShort = containerShort[:12]
+		}
+
+		name := s.Name
+		if len(name) > 20 {
+			name = name[:17] + "..."
+		}
+
+		fmt.Printf("%-12s %-20s %-8s %-22s %-8s %-22s %-22s %-6d\n",
+			containerShort, name,
+			fmt.Sprintf("%.2f%%", s.CPUPercent),
+			formatSize(int64(s.MemoryUsage))+" / "+formatSize(int64(s.MemoryLimit)),
+			fmt.Sprintf("%.2f%%", s.MemoryPercent),
+			formatSize(int64(s.NetRx))+" / "+formatSize(int64(s.NetTx)),
+			formatSize(int64(s.BlockRead))+" / "+formatSize(int64(s.BlockWrite)),
+			s.PIDs)
+	}
+}
+
+func exportContainerStats(cmd *cobra.Command, args []string) error {
+	if statsExportFormat != "csv" && statsExportFormat != "json" {
+		return errors.NewValidationError("stats export", fmt.Sprintf("unsupported format %q (use csv or json)", statsExportFormat))
+	}
+
+	var since time.Time
+	if statsExportSince != "" {
+		t, err := parseTimeOption(statsExportSince)
+		if err != nil {
+			return errors.NewValidationError("stats export", err.Error())
+		}
+		since = t
+	}
+
+	sm := state.NewStateManager()
+	containerID, err := resolveContainerRef(sm, args[0])
+	if err != nil {
+		return err
+	}
+
+	history, err := stats.LoadHistory(containerID)
+	if os.IsNotExist(err) {
+		return errors.NewNotFoundError("stats export", fmt.Sprintf("no stats history for container %s (history is recorded by 'servin daemon')", args[0]))
+	}
+	if err != nil {
+		return err
+	}
+
+	samples := make([]*stats.Stats, 0, len(history))
+	for _, s := range history {
+		if !s.Read.Before(since) {
+			samples = append(samples, s)
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if statsExportOutput != "" {
+		f, err := os.Create(statsExportOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if statsExportFormat == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(samples)
+	}
+	return writeStatsCSV(out, samples)
+}
+
+// writeStatsCSV writes samples as CSV with one row per sample
+func writeStatsCSV(out io.Writer, samples []*stats.Stats) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{
+		"timestamp", "container_id", "name", "cpu_percent", "memory_usage_bytes",
+		"memory_limit_bytes", "memory_percent", "net_rx_bytes", "net_tx_bytes",
+		"block_read_bytes", "block_write_bytes", "pids",
+	})
+
+	for _, s := range samples {
+		w.Write([]string{
+			s.Read.UTC().Format(time.RFC3339),
+			s.ID,
+			s.Name,
+			strconv.FormatFloat(s.CPUPercent, 'f', 2, 64),
+			strconv.FormatUint(s.MemoryUsage, 10),
+			strconv.FormatUint(s.MemoryLimit, 10),
+			strconv.FormatFloat(s.MemoryPercent, 'f', 2, 64),
+			strconv.FormatUint(s.NetRx, 10),
+			strconv.FormatUint(s.NetTx, 10),
+			strconv.FormatUint(s.BlockRead, 10),
+			strconv.FormatUint(s.BlockWrite, 10),
+			strconv.FormatUint(s.PIDs, 10),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
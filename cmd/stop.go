@@ -3,6 +3,9 @@ package cmd
 import (
 	"fmt"
 
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/hooks"
 	"servin/pkg/state"
 
 	"github.com/spf13/cobra"
@@ -27,20 +30,22 @@ func stopContainers(cmd *cobra.Command, args []string) error {
 	// Create state manager
 	sm := state.NewStateManager()
 
+	// Every container is tried; the command fails if any could not be stopped
+	var failures []error
 	for _, containerRef := range args {
 		fmt.Printf("Stopping container %s...\n", containerRef)
 
 		// Find the container (could be ID, short ID, or name)
 		containerID, err := resolveContainerRef(sm, containerRef)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
+			failures = append(failures, err)
 			continue
 		}
 
 		// Load container state
 		container, err := sm.LoadContainer(containerID)
 		if err != nil {
-			fmt.Printf("Error: failed to load container %s: %v\n", containerRef, err)
+			failures = append(failures, errors.WrapError(err, errors.ErrTypeIO, "stop", fmt.Sprintf("failed to load container %s", containerRef)))
 			continue
 		}
 
@@ -52,8 +57,8 @@ func stopContainers(cmd *cobra.Command, args []string) error {
 
 		// Stop the container process
 		if container.PID > 0 {
-			if err := stopContainerProcess(container.PID); err != nil {
-				fmt.Printf("Error stopping container %s: %v\n", containerRef, err)
+			if err := stopContainerProcess(container.PID, container.StopSignal); err != nil {
+				failures = append(failures, errors.WrapError(err, errors.ErrTypeContainer, "stop", fmt.Sprintf("failed to stop container %s", containerRef)))
 				continue
 			}
 		}
@@ -63,8 +68,28 @@ func stopContainers(cmd *cobra.Command, args []string) error {
 			fmt.Printf("Warning: failed to update container status: %v\n", err)
 		}
 
+		// Native containers run their post-stop hooks when the process exits.
+		// Containers in the VM have no host process, so run them here.
+		if container.PID == 0 {
+			runStoppedHooks(sm, containerID)
+		}
+
 		fmt.Printf("Container %s stopped\n", containerRef)
 	}
 
-	return nil
+	return argumentsError("stop", failures)
+}
+
+// runStoppedHooks runs the post-stop hooks of a container that was stopped
+func runStoppedHooks(sm *state.StateManager, containerID string) {
+	if cs, err := sm.LoadContainer(containerID); err == nil {
+		runContainerHooks(cs, hooks.PostStop)
+	}
+}
+
+// runContainerHooks runs a container's hooks for an event, reporting failures
+func runContainerHooks(cs *state.ContainerState, event string) {
+	if err := container.RunHooks(cs, event); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
 }
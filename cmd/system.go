@@ -0,0 +1,847 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"servin/pkg/audit"
+	"servin/pkg/errors"
+	"servin/pkg/image"
+	"servin/pkg/restart"
+	"servin/pkg/security"
+	"servin/pkg/state"
+	"servin/pkg/volume"
+
+	"github.com/spf13/cobra"
+)
+
+var systemCmd = &cobra.Command{
+	Use:   "system",
+	Short: "Manage servin",
+	Long:  "Manage servin as a whole, including reclaiming disk space used by unused data.",
+}
+
+var systemPruneCmd = &cobra.Command{
+	Use:   "prune [OPTIONS]",
+	Short: "Remove unused data",
+	Long: `Remove stopped containers, dangling images, the build cache and the layers
+no image uses. With -a, every image not used by a remaining container is removed, which must
+be confirmed by typing 'prune all' (or skipped with --force, which is recorded
+in the audit trail). Volumes no container references are only removed with
+--volumes. Containers and volumes labelled 'protected' are never pruned.
+
+Filters:
+  until=<time>          Only remove objects created before a time, given as a
+                        duration ago (e.g. 24h, 30d) or an RFC 3339 timestamp
+  unused-since=<time>   Only remove images no container was created or
+                        started from since a time
+
+Examples:
+  servin system prune
+  servin system prune -a --volumes
+  servin system prune --filter until=72h --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runSystemPrune,
+}
+
+var systemAuditCmd = &cobra.Command{
+	Use:   "audit [OPTIONS]",
+	Short: "Show the audit trail of overridden safety interlocks",
+	Long: `Show who skipped a typed confirmation with --force or removed a protected
+container or volume with --override-protection, oldest first.
+
+Examples:
+  servin system audit
+  servin system audit --since 24h --format json`,
+	Args: cobra.NoArgs,
+	RunE: runSystemAudit,
+}
+
+var (
+	auditFormat string
+	auditSince  string
+)
+
+var systemDfCmd = &cobra.Command{
+	Use:   "df [OPTIONS]",
+	Short: "Show disk usage",
+	Long: `Show the disk space used by images, containers and volumes, how much of it a
+prune would reclaim, and what the shared layer cache holds and saves.
+
+Examples:
+  servin system df
+  servin system df -v --format json`,
+	Args: cobra.NoArgs,
+	RunE: runSystemDf,
+}
+
+var (
+	systemDfVerbose bool
+	systemDfFormat  string
+)
+
+var systemInfoCmd = &cobra.Command{
+	Use:   "info [OPTIONS]",
+	Short: "Show system-wide information",
+	Long: `Show the platform servin runs on, the config file it read and the defaults
+every new container gets unless overridden: memory and CPU limits, restart
+policy, seccomp profile, no-new-privileges and ulimits. The defaults are set in
+the daemon.container_defaults section of config.yaml.
+
+Examples:
+  servin system info
+  servin system info --format json`,
+	Args: cobra.NoArgs,
+	RunE: runSystemInfo,
+}
+
+var systemInfoFormat string
+
+var systemCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the shared layer cache",
+	Long: `Manage the optional shared layer cache: a root-owned, read-only,
+content-addressed store of blobs and extracted layers that the image stores
+of every user on the machine hard-link from, so identical base layers are
+stored once.
+
+Once created, pulls run as root add to the cache and every other pull looks
+there first. The cache lives in /var/cache/servin (%ProgramData%\servin\cache
+on Windows); set SERVIN_SHARED_CACHE to use another directory, or to "off" to
+ignore the cache.`,
+}
+
+var systemCacheInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the shared layer cache",
+	Args:  cobra.NoArgs,
+	RunE:  runSystemCacheInit,
+}
+
+var systemCachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached layers no image store uses",
+	Args:  cobra.NoArgs,
+	RunE:  runSystemCachePrune,
+}
+
+// Prune flags shared by image, volume and system prune
+var (
+	pruneAll     bool
+	pruneForce   bool
+	pruneDryRun  bool
+	pruneVolumes bool
+	pruneFilters []string
+)
+
+func init() {
+	systemCmd.AddCommand(systemPruneCmd)
+	systemCmd.AddCommand(systemAuditCmd)
+	systemCmd.AddCommand(systemDfCmd)
+	systemCmd.AddCommand(systemInfoCmd)
+	systemCmd.AddCommand(systemCacheCmd)
+	systemCacheCmd.AddCommand(systemCacheInitCmd)
+	systemCacheCmd.AddCommand(systemCachePruneCmd)
+
+	systemDfCmd.Flags().BoolVarP(&systemDfVerbose, "verbose", "v", false, "Show the space used by each image, container and volume")
+	systemDfCmd.Flags().StringVar(&systemDfFormat, "format", "table", "Output format (table, json)")
+
+	systemInfoCmd.Flags().StringVar(&systemInfoFormat, "format", "table", "Output format (table, json)")
+
+	systemAuditCmd.Flags().StringVar(&auditFormat, "format", "table", "Output format (table, json)")
+	systemAuditCmd.Flags().StringVar(&auditSince, "since", "", "Only show entries since a timestamp or a duration ago (e.g. 24h)")
+
+	systemPruneCmd.Flags().BoolVarP(&pruneAll, "all", "a", false, "Remove all unused images, not just dangling ones")
+	systemPruneCmd.Flags().BoolVar(&pruneVolumes, "volumes", false, "Also remove volumes no container references")
+	addPruneFlags(systemPruneCmd)
+
+	rootCmd.AddCommand(systemCmd)
+}
+
+// addPruneFlags registers the flags every prune command takes
+func addPruneFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&pruneForce, "force", "f", false, "Do not prompt for confirmation")
+	cmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Show what would be removed and the space it would reclaim")
+	cmd.Flags().StringArrayVar(&pruneFilters, "filter", nil, "Filter what is removed (until=<duration or timestamp>)")
+}
+
+// parsePruneFilters parses the --filter flags into the time objects must
+// have been created before to be pruned, or the zero time if there is none
+func parsePruneFilters(filters []string) (until time.Time, err error) {
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok || key != "until" {
+			return until, errors.NewValidationError("prune",
+				fmt.Sprintf("unsupported filter '%s' (expected until=<duration or timestamp>)", filter))
+		}
+		t, err := parseTimeOption(value)
+		if err != nil {
+			return until, errors.NewValidationError("prune", fmt.Sprintf("invalid until filter: %v", err))
+		}
+		until = t
+	}
+	return until, nil
+}
+
+// parseImagePruneFilters parses the --filter flags of an image prune, which
+// also takes unused-since=<time> to only remove images no container has
+// used since then
+func parseImagePruneFilters(filters []string) (until, unusedSince time.Time, err error) {
+	var rest []string
+	for _, filter := range filters {
+		key, value, _ := strings.Cut(filter, "=")
+		if key != "unused-since" {
+			rest = append(rest, filter)
+			continue
+		}
+		if unusedSince, err = parseTimeOption(value); err != nil {
+			return until, unusedSince, errors.NewValidationError("prune", fmt.Sprintf("invalid unused-since filter: %v", err))
+		}
+	}
+	until, err = parsePruneFilters(rest)
+	return until, unusedSince, err
+}
+
+// confirmPrune asks before removing anything, unless --force or --dry-run
+// was given
+func confirmPrune(warning string) bool {
+	if pruneForce || pruneDryRun {
+		return true
+	}
+	fmt.Printf("WARNING! This will remove:\n%s\nAre you sure you want to continue? [y/N] ", warning)
+	var response string
+	fmt.Scanln(&response)
+
+	if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
+		fmt.Println("Operation cancelled")
+		return false
+	}
+	return true
+}
+
+// stoppedContainers splits containers into the stopped ones a prune removes
+// and the rest; protected containers are always kept
+func stoppedContainers(containers []*state.ContainerState, until time.Time) (stopped, kept []*state.ContainerState) {
+	for _, c := range containers {
+		if c.Status != state.StatusRunning && !isProtected(c.Labels) && (until.IsZero() || c.Created.Before(until)) {
+			stopped = append(stopped, c)
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	return stopped, kept
+}
+
+// imagesUsedBy returns a check for images the containers were created from
+func imagesUsedBy(imgManager *image.Manager, containers []*state.ContainerState) func(*image.Image) bool {
+	used := make(map[string]bool)
+	for _, c := range containers {
+		if id := imgManager.ContainerImageID(c); id != "" {
+			used[id] = true
+		}
+	}
+	return func(img *image.Image) bool { return used[img.ID] }
+}
+
+// volumesToKeep returns a check for volumes a prune must keep: the protected
+// ones and those the containers mount, by name or by mountpoint
+func volumesToKeep(containers []*state.ContainerState) func(*volume.Volume) bool {
+	used := usedVolumes(containers)
+	return func(vol *volume.Volume) bool {
+		return isProtected(vol.Labels) || used(vol)
+	}
+}
+
+// usedVolumes returns a check for volumes the containers mount, by name or
+// by mountpoint
+func usedVolumes(containers []*state.ContainerState) func(*volume.Volume) bool {
+	used := make(map[string]bool)
+	for _, c := range containers {
+		for source := range c.Volumes {
+			used[source] = true
+		}
+	}
+	return func(vol *volume.Volume) bool {
+		return used[vol.Name] || used[vol.Mountpoint]
+	}
+}
+
+// printImagePrune reports the images an image prune removed or would remove
+func printImagePrune(report *image.PruneReport) {
+	if len(report.Images) == 0 {
+		return
+	}
+	if pruneDryRun {
+		fmt.Println("Would delete images:")
+	} else {
+		fmt.Println("Deleted images:")
+	}
+	for _, img := range report.Images {
+		for _, tag := range img.RepoTags {
+			if tag != "<none>:<none>" {
+				fmt.Printf("  untagged: %s\n", tag)
+			}
+		}
+		fmt.Printf("  deleted: sha256:%s\n", img.ID)
+	}
+}
+
+// printVolumePrune reports the volumes a volume prune removed or would remove
+func printVolumePrune(report *volume.PruneReport) {
+	if len(report.Volumes) == 0 {
+		return
+	}
+	if pruneDryRun {
+		fmt.Println("Would delete volumes:")
+	} else {
+		fmt.Println("Deleted volumes:")
+	}
+	for _, name := range report.Volumes {
+		fmt.Printf("  %s\n", name)
+	}
+}
+
+// printReclaimed reports the space a prune freed or would free
+func printReclaimed(bytes int64) {
+	if pruneDryRun {
+		fmt.Printf("Total reclaimable space: %s\n", formatSize(bytes))
+	} else {
+		fmt.Printf("Total reclaimed space: %s\n", formatSize(bytes))
+	}
+}
+
+func runSystemPrune(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	until, unusedSince, err := parseImagePruneFilters(pruneFilters)
+	if err != nil {
+		return err
+	}
+
+	warning := "  - all stopped containers\n"
+	if pruneAll {
+		warning += "  - all images without at least one container associated to them\n"
+	} else {
+		warning += "  - all dangling images\n"
+	}
+	if pruneVolumes {
+		warning += "  - all volumes not used by at least one container\n"
+	}
+	warning += "  - all build cache\n"
+	warning += "  - all layers no image uses"
+	if pruneAll && !pruneDryRun {
+		// Removing every unused image is hard to undo, so it takes more
+		// than a y to confirm
+		if pruneForce {
+			recordOverride("system prune", "all unused images", "skipped typed confirmation with --force")
+		} else if !confirmTyped("This will remove:\n"+warning, "prune all") {
+			return nil
+		}
+	} else if !confirmPrune(warning) {
+		return nil
+	}
+
+	sm := state.NewStateManager()
+	containers, err := sm.ListContainers()
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %v", err)
+	}
+	stopped, kept := stoppedContainers(containers, until)
+
+	pruned := make(map[string]bool)
+	if pruneDryRun && len(stopped) > 0 {
+		fmt.Println("Would delete containers:")
+	}
+	for _, c := range stopped {
+		if pruneDryRun {
+			fmt.Printf("  %s (%s)\n", c.ID, c.Name)
+		} else if err := removeContainer(sm, c.ID, false); err != nil {
+			fmt.Printf("Error removing container %s: %v\n", c.ID[:12], err)
+			kept = append(kept, c)
+			continue
+		}
+		pruned[c.ID] = true
+	}
+
+	var reclaimed int64
+	if pruneVolumes {
+		// Volumes are shared by every namespace, so containers in all of
+		// them keep a volume
+		all, err := sm.AllNamespaces().ListContainers()
+		if err != nil {
+			return fmt.Errorf("failed to list containers: %v", err)
+		}
+		var users []*state.ContainerState
+		for _, c := range all {
+			if !pruned[c.ID] {
+				users = append(users, c)
+			}
+		}
+
+		report, err := volume.NewManager().PruneVolumes(volume.PruneOptions{
+			InUse:  volumesToKeep(users),
+			Until:  until,
+			DryRun: pruneDryRun,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prune volumes: %v", err)
+		}
+		printVolumePrune(report)
+		reclaimed += report.SpaceReclaimed
+	}
+
+	imgManager := image.NewManager()
+	cacheReport, err := imgManager.PruneBuildCache(image.BuildCachePruneOptions{
+		Until:  until,
+		DryRun: pruneDryRun,
+	})
+	if cacheReport != nil {
+		printBuildCachePrune(cacheReport)
+		reclaimed += cacheReport.SpaceReclaimed
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune build cache: %v", err)
+	}
+
+	report, err := imgManager.PruneImages(image.PruneOptions{
+		All:         pruneAll,
+		Until:       until,
+		UnusedSince: unusedSince,
+		InUse:       imagesUsedBy(imgManager, kept),
+		DryRun:      pruneDryRun,
+	})
+	if report != nil {
+		printImagePrune(report)
+		reclaimed += report.SpaceReclaimed
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune images: %v", err)
+	}
+
+	printReclaimed(reclaimed)
+	return nil
+}
+
+func runSystemAudit(cmd *cobra.Command, args []string) error {
+	if auditFormat != "table" && auditFormat != "json" {
+		return errors.NewValidationError("system audit", fmt.Sprintf("unknown format '%s' (expected table or json)", auditFormat))
+	}
+	var since time.Time
+	if auditSince != "" {
+		t, err := parseTimeOption(auditSince)
+		if err != nil {
+			return errors.NewValidationError("system audit", fmt.Sprintf("invalid --since: %v", err))
+		}
+		since = t
+	}
+
+	all, err := audit.Entries()
+	if err != nil {
+		return err
+	}
+	entries := []audit.Entry{}
+	for _, entry := range all {
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if auditFormat == "json" {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No overrides recorded")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tACTION\tTARGET\tOVERRIDE")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.User, entry.Action, entry.Target, entry.Override)
+	}
+	w.Flush()
+	return nil
+}
+
+// diskUsage returns the total size of the regular files under path
+func diskUsage(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// reclaimable formats the space a prune would free as a share of size
+func reclaimable(bytes, size int64) string {
+	if size <= 0 {
+		return formatSize(bytes)
+	}
+	return fmt.Sprintf("%s (%d%%)", formatSize(bytes), bytes*100/size)
+}
+
+// systemDf is the output of system df; the lists of each image, container
+// and volume are only filled in with --verbose
+type systemDf struct {
+	Images      systemDfImages          `json:"images"`
+	Containers  systemDfContainers      `json:"containers"`
+	Volumes     systemDfVolumes         `json:"volumes"`
+	BuildCache  systemDfUsage           `json:"build_cache"`
+	SharedCache *image.SharedCacheStats `json:"shared_cache,omitempty"`
+}
+
+// systemDfUsage is the space one type of object uses, and how much of it a
+// prune would reclaim
+type systemDfUsage struct {
+	Total       int   `json:"total"`
+	Active      int   `json:"active"`
+	Size        int64 `json:"size"`
+	Reclaimable int64 `json:"reclaimable"`
+}
+
+type systemDfImages struct {
+	systemDfUsage
+	Items []systemDfImage `json:"items,omitzero"`
+}
+
+type systemDfImage struct {
+	ID         string    `json:"id"`
+	RepoTags   []string  `json:"repo_tags"`
+	Created    time.Time `json:"created"`
+	Size       int64     `json:"size"`
+	Containers int       `json:"containers"`
+}
+
+type systemDfContainers struct {
+	systemDfUsage
+	Items []systemDfContainer `json:"items,omitzero"`
+}
+
+type systemDfContainer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Image  string `json:"image"`
+	Status string `json:"status"`
+	Size   int64  `json:"size"`
+}
+
+type systemDfVolumes struct {
+	systemDfUsage
+	Items []systemDfVolume `json:"items,omitzero"`
+}
+
+type systemDfVolume struct {
+	Name  string `json:"name"`
+	InUse bool   `json:"in_use"`
+	Size  int64  `json:"size"`
+}
+
+func runSystemDf(cmd *cobra.Command, args []string) error {
+	if systemDfFormat != "table" && systemDfFormat != "json" {
+		return errors.NewValidationError("system df", fmt.Sprintf("unknown format '%s' (expected table or json)", systemDfFormat))
+	}
+
+	sm := state.NewStateManager()
+	containers, err := sm.ListContainers()
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %v", err)
+	}
+	all, err := sm.AllNamespaces().ListContainers()
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	var df systemDf
+	imgManager := image.NewManager()
+	images, err := imgManager.ListImages()
+	if err != nil {
+		return fmt.Errorf("failed to list images: %v", err)
+	}
+	inUse := imagesUsedBy(imgManager, containers)
+	df.Images.Total = len(images)
+	for _, img := range images {
+		if inUse(img) {
+			df.Images.Active++
+		}
+	}
+	df.Images.Size = imgManager.DiskUsage()
+	imageReport, err := imgManager.PruneImages(image.PruneOptions{All: true, InUse: inUse, DryRun: true})
+	if err != nil {
+		return fmt.Errorf("failed to compute image usage: %v", err)
+	}
+	df.Images.Reclaimable = imageReport.SpaceReclaimed
+
+	df.Containers.Total = len(containers)
+	containerSizes := make(map[string]int64)
+	for _, c := range containers {
+		size := diskUsage(c.RootPath)
+		containerSizes[c.ID] = size
+		df.Containers.Size += size
+		if c.Status == state.StatusRunning {
+			df.Containers.Active++
+		} else {
+			df.Containers.Reclaimable += size
+		}
+	}
+
+	volManager := volume.NewManager()
+	volumes, err := volManager.ListVolumes()
+	if err != nil {
+		return fmt.Errorf("failed to list volumes: %v", err)
+	}
+	// Volumes are shared by every namespace
+	keep := volumesToKeep(all)
+	df.Volumes.Total = len(volumes)
+	volumeSizes := make(map[string]int64)
+	for _, vol := range volumes {
+		size := volManager.DiskUsage(vol)
+		volumeSizes[vol.Name] = size
+		df.Volumes.Size += size
+		if keep(vol) {
+			df.Volumes.Active++
+		} else {
+			df.Volumes.Reclaimable += size
+		}
+	}
+
+	cacheEntries, cacheSize, err := imgManager.BuildCacheUsage()
+	if err != nil {
+		return fmt.Errorf("failed to compute build cache usage: %v", err)
+	}
+	df.BuildCache = systemDfUsage{Total: cacheEntries, Size: cacheSize, Reclaimable: cacheSize}
+
+	cache := image.OpenSharedCache()
+	var cacheErr error
+	if cache != nil {
+		df.SharedCache, cacheErr = cache.Stats()
+	}
+
+	if systemDfVerbose {
+		users := make(map[string]int)
+		for _, c := range containers {
+			users[imgManager.ContainerImageID(c)]++
+		}
+		df.Images.Items = []systemDfImage{}
+		for _, img := range images {
+			df.Images.Items = append(df.Images.Items, systemDfImage{
+				ID:         img.ID,
+				RepoTags:   img.RepoTags,
+				Created:    img.Created,
+				Size:       img.Size,
+				Containers: users[img.ID],
+			})
+		}
+		df.Containers.Items = []systemDfContainer{}
+		for _, c := range containers {
+			df.Containers.Items = append(df.Containers.Items, systemDfContainer{
+				ID:     c.ID,
+				Name:   c.Name,
+				Image:  c.Image,
+				Status: c.Status,
+				Size:   containerSizes[c.ID],
+			})
+		}
+		df.Volumes.Items = []systemDfVolume{}
+		for _, vol := range volumes {
+			df.Volumes.Items = append(df.Volumes.Items, systemDfVolume{Name: vol.Name, InUse: keep(vol), Size: volumeSizes[vol.Name]})
+		}
+	}
+
+	if systemDfFormat == "json" {
+		data, err := json.MarshalIndent(df, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tTOTAL\tACTIVE\tSIZE\tRECLAIMABLE")
+	for _, row := range []struct {
+		name  string
+		usage systemDfUsage
+	}{
+		{"Images", df.Images.systemDfUsage},
+		{"Containers", df.Containers.systemDfUsage},
+		{"Local Volumes", df.Volumes.systemDfUsage},
+		{"Build Cache", df.BuildCache},
+	} {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", row.name, row.usage.Total, row.usage.Active, formatSize(row.usage.Size),
+			reclaimable(row.usage.Reclaimable, row.usage.Size))
+	}
+	w.Flush()
+
+	fmt.Println()
+	if cache == nil {
+		fmt.Println("Shared layer cache: not enabled (create it with 'servin system cache init')")
+	} else if cacheErr != nil {
+		fmt.Printf("Shared layer cache: %s (failed to read: %v)\n", cache.Dir(), cacheErr)
+	} else {
+		stats := df.SharedCache
+		fmt.Printf("Shared layer cache: %s\n", stats.Dir)
+		fmt.Printf("  Blobs: %d, layers: %d, size: %s\n", stats.Blobs, stats.Layers, formatSize(stats.Size))
+		fmt.Printf("  Files linked by image stores: %d, space saved: %s\n", stats.Linked, formatSize(stats.Saved))
+	}
+
+	if !systemDfVerbose {
+		return nil
+	}
+
+	fmt.Println("\nImages space usage:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY:TAG\tIMAGE ID\tCREATED\tSIZE\tCONTAINERS")
+	for _, img := range df.Images.Items {
+		tag := "<none>:<none>"
+		if len(img.RepoTags) > 0 {
+			tag = img.RepoTags[0]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", tag, truncateString(img.ID, 12),
+			img.Created.Format("2006-01-02 15:04:05"), formatSize(img.Size), img.Containers)
+	}
+	w.Flush()
+
+	fmt.Println("\nContainers space usage:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER ID\tIMAGE\tSTATUS\tSIZE\tNAME")
+	for _, c := range df.Containers.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", truncateString(c.ID, 12), c.Image, c.Status, formatSize(c.Size), c.Name)
+	}
+	w.Flush()
+
+	fmt.Println("\nLocal Volumes space usage:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "VOLUME NAME\tIN USE\tSIZE")
+	for _, vol := range df.Volumes.Items {
+		fmt.Fprintf(w, "%s\t%t\t%s\n", vol.Name, vol.InUse, formatSize(vol.Size))
+	}
+	w.Flush()
+	return nil
+}
+
+func runSystemCacheInit(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	cache, err := image.InitSharedCache()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Shared layer cache ready at %s\n", cache.Dir())
+	fmt.Println("Images pulled as root from now on are added to it; other users' pulls link from it.")
+	return nil
+}
+
+func runSystemCachePrune(cmd *cobra.Command, args []string) error {
+	if err := checkRoot(); err != nil {
+		return err
+	}
+
+	cache := image.OpenSharedCache()
+	if cache == nil {
+		fmt.Println("Shared layer cache is not enabled")
+		return nil
+	}
+	freed, err := cache.Prune()
+	if err != nil {
+		return fmt.Errorf("failed to prune shared layer cache: %v", err)
+	}
+	fmt.Printf("Total reclaimed space: %s\n", formatSize(freed))
+	return nil
+}
+
+// systemInfo is the output of system info
+type systemInfo struct {
+	Platform          string                `json:"platform"`
+	ConfigFile        string                `json:"config_file"`
+	ContainerDefaults containerDefaultsInfo `json:"container_defaults"`
+}
+
+type containerDefaultsInfo struct {
+	Memory          string   `json:"memory"`
+	CPUs            string   `json:"cpus"`
+	Restart         string   `json:"restart"`
+	Seccomp         string   `json:"seccomp"`
+	NoNewPrivileges bool     `json:"no_new_privileges"`
+	Ulimits         []string `json:"ulimits"`
+}
+
+func runSystemInfo(cmd *cobra.Command, args []string) error {
+	if systemInfoFormat != "table" && systemInfoFormat != "json" {
+		return errors.NewValidationError("system info", fmt.Sprintf("unknown format '%s' (expected table or json)", systemInfoFormat))
+	}
+
+	defaults, err := loadContainerDefaults()
+	if err != nil {
+		return err
+	}
+
+	info := systemInfo{
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		ConfigFile: defaults.path,
+		ContainerDefaults: containerDefaultsInfo{
+			Memory:          defaults.Memory,
+			CPUs:            defaults.CPUs,
+			Restart:         restart.PolicyNo,
+			Seccomp:         security.SeccompUnconfined,
+			NoNewPrivileges: defaults.NoNewPrivileges,
+			Ulimits:         []string{},
+		},
+	}
+	if defaults.Restart != "" {
+		policy, _ := restart.ParsePolicy(defaults.Restart)
+		info.ContainerDefaults.Restart = policy.String()
+	}
+	if defaults.Seccomp != "" {
+		info.ContainerDefaults.Seccomp = defaults.Seccomp
+	}
+	for _, limit := range defaults.ulimits {
+		info.ContainerDefaults.Ulimits = append(info.ContainerDefaults.Ulimits, limit.String())
+	}
+
+	if systemInfoFormat == "json" {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	orNone := func(value string) string {
+		if value == "" {
+			return "none"
+		}
+		return value
+	}
+	d := info.ContainerDefaults
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Platform:\t%s\n", info.Platform)
+	fmt.Fprintf(w, "Config file:\t%s\n", orNone(info.ConfigFile))
+	fmt.Fprintln(w, "Container defaults:\t")
+	fmt.Fprintf(w, "  Memory limit:\t%s\n", orNone(d.Memory))
+	fmt.Fprintf(w, "  CPU limit:\t%s\n", orNone(d.CPUs))
+	fmt.Fprintf(w, "  Restart policy:\t%s\n", d.Restart)
+	fmt.Fprintf(w, "  Seccomp profile:\t%s\n", d.Seccomp)
+	fmt.Fprintf(w, "  No new privileges:\t%t\n", d.NoNewPrivileges)
+	fmt.Fprintf(w, "  Ulimits:\t%s\n", orNone(strings.Join(d.Ulimits, ", ")))
+	return w.Flush()
+}
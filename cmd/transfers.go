@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"servin/pkg/config"
+	"servin/pkg/errors"
+	"servin/pkg/ratelimit"
+)
+
+// limitRateUsage is the help text of the --limit-rate flags
+const limitRateUsage = "Limit the transfer rate (e.g. 500KB/s, 5MB/s; 0 for no limit). Defaults to the daemon.transfers setting of config.yaml"
+
+// limitRate returns the bandwidth limit of a transfer in bytes per second:
+// the --limit-rate flag when given, or else the config.yaml default for
+// downloads or uploads
+func limitRate(flag string, upload bool) (int64, error) {
+	if flag != "" {
+		return ratelimit.ParseRate(flag)
+	}
+
+	cfg, path, err := config.Load()
+	if err != nil {
+		return 0, err
+	}
+	field, value := "download_rate", cfg.Daemon.Transfers.DownloadRate
+	if upload {
+		field, value = "upload_rate", cfg.Daemon.Transfers.UploadRate
+	}
+	rate, err := ratelimit.ParseRate(value)
+	if err != nil {
+		return 0, errors.NewConfigError("limitRate",
+			fmt.Sprintf("%s: daemon.transfers.%s: invalid rate '%s'", path, field, value))
+	}
+	return rate, nil
+}
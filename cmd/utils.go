@@ -2,9 +2,10 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"syscall"
+	"strings"
 
+	"servin/pkg/container"
+	"servin/pkg/errors"
 	"servin/pkg/state"
 )
 
@@ -25,28 +26,46 @@ func resolveContainerRef(sm *state.StateManager, ref string) (string, error) {
 		return fullID, nil
 	}
 
-	return "", fmt.Errorf("container '%s' not found", ref)
+	return "", errors.NewNotFoundError("resolveContainerRef", fmt.Sprintf("container '%s' not found", ref))
 }
 
-// stopContainerProcess stops a container process by PID
-func stopContainerProcess(pid int) error {
-	// First try SIGTERM for graceful shutdown
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return fmt.Errorf("process %d not found: %v", pid, err)
+// argumentsError is the error of a command that acts on each of its
+// arguments in turn, carrying on past those that fail: nil when none did,
+// the failure itself when one did, and when several did, their messages
+// joined under the category they share, or a general failure when they
+// differ
+func argumentsError(operation string, failures []error) error {
+	switch len(failures) {
+	case 0:
+		return nil
+	case 1:
+		return failures[0]
 	}
 
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		// If SIGTERM fails, try SIGKILL
-		if err := process.Signal(syscall.SIGKILL); err != nil {
-			return fmt.Errorf("failed to kill process %d: %v", pid, err)
+	errType := errors.ErrTypeSystem
+	if first, ok := errors.As(failures[0]); ok {
+		errType = first.Type
+	}
+	messages := make([]string, len(failures))
+	for i, err := range failures {
+		if errors.ExitCode(err) != errors.ExitCode(failures[0]) {
+			errType = errors.ErrTypeSystem
 		}
+		messages[i] = errors.NewReport(err).Message
 	}
+	return errors.NewError(errType, operation, strings.Join(messages, "; ")).
+		WithContext("failures", len(failures))
+}
 
-	// Wait for process to exit (with timeout would be better)
-	state, err := process.Wait()
+// stopContainerProcess stops a container process by PID, sending the
+// image's stop signal (SIGTERM by default)
+func stopContainerProcess(pid int, stopSignal string) error {
+	if _, err := container.ParseStopSignal(stopSignal); err != nil {
+		fmt.Printf("Warning: %v, using SIGTERM\n", err)
+	}
+	state, err := container.StopProcess(pid, stopSignal)
 	if err != nil {
-		return fmt.Errorf("failed to wait for process %d: %v", pid, err)
+		return err
 	}
 
 	fmt.Printf("Process %d exited with status: %s\n", pid, state)
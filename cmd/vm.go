@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
 
 	"servin/pkg/container"
+	"servin/pkg/errors"
 	"servin/pkg/vm"
 
 	"github.com/spf13/cobra"
@@ -29,17 +34,50 @@ var vmStatusCmd = &cobra.Command{
 }
 
 var vmStartCmd = &cobra.Command{
-	Use:   "start",
-	Short: "Start the VM",
-	Run:   runVMStart,
+	Use:     "start",
+	Aliases: []string{"up"},
+	Short:   "Start the VM",
+	Long: `Start the VM, creating it first if needed.
+
+Creating the VM downloads its kernel, initramfs, ISO or rootfs. Progress is
+shown for each asset; press Ctrl+C to cancel the download. With
+--progress=json each progress event is printed as a JSON object on its own
+line, for tools such as the GUI.
+
+Assets are kept in the cache shared by all VMs, ~/.servin/cache, and checked
+against their SHA256 digest. With --offline, or daemon.vm_assets.offline in
+config.yaml, the VM is created from the cache alone; see 'servin vm cache'.`,
+	Run: runVMStart,
 }
 
+var (
+	vmStartProgress  string
+	vmStartLimitRate string
+	vmStartOffline   bool
+)
+
 var vmStopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop the VM",
 	Run:   runVMStop,
 }
 
+var vmDestroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Delete the VM and everything in it",
+	Long: `Stop the VM and delete it along with its disk, including every container
+and image inside it. The VM is created again on the next 'servin vm start'.
+
+The deletion must be confirmed by typing the VM name. --force skips the
+confirmation and is recorded in the audit trail.`,
+	Args: cobra.NoArgs,
+	RunE: runVMDestroy,
+}
+
+var vmDestroyForce bool
+
+var vmListProvidersFormat string
+
 var vmConfigCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Configure VM settings",
@@ -61,7 +99,14 @@ var vmDisableCmd = &cobra.Command{
 var vmListProvidersCmd = &cobra.Command{
 	Use:   "list-providers",
 	Short: "List available VM providers",
-	Run:   runVMListProviders,
+	Long: `List the VM providers of this platform in priority order, with whether each
+can run on this host. The first available provider is used unless
+SERVIN_VM_PROVIDER names another.
+
+With --format json, each provider is an object with its name, description,
+priority, acceleration, whether it is available (and why not), and whether
+new VMs use it.`,
+	RunE: runVMListProviders,
 }
 
 var vmCheckKVMCmd = &cobra.Command{
@@ -110,6 +155,7 @@ func init() {
 	vmCmd.AddCommand(vmStatusCmd)
 	vmCmd.AddCommand(vmStartCmd)
 	vmCmd.AddCommand(vmStopCmd)
+	vmCmd.AddCommand(vmDestroyCmd)
 	vmCmd.AddCommand(vmConfigCmd)
 	vmCmd.AddCommand(vmEnableCmd)
 	vmCmd.AddCommand(vmDisableCmd)
@@ -125,6 +171,14 @@ func init() {
 	// Add flags for download-image command
 	vmDownloadImageCmd.Flags().Bool("dry-run", false, "Show what would be downloaded without downloading")
 
+	vmDestroyCmd.Flags().BoolVarP(&vmDestroyForce, "force", "f", false, "Do not prompt for confirmation")
+
+	vmListProvidersCmd.Flags().StringVar(&vmListProvidersFormat, "format", "table", "Output format (table, json)")
+
+	vmStartCmd.Flags().StringVar(&vmStartProgress, "progress", "text", "How to report VM asset download progress: text or json")
+	vmStartCmd.Flags().StringVar(&vmStartLimitRate, "limit-rate", "", limitRateUsage)
+	vmStartCmd.Flags().BoolVar(&vmStartOffline, "offline", false, "Create the VM from the asset cache only, without downloading")
+
 	rootCmd.AddCommand(vmCmd)
 }
 
@@ -154,12 +208,41 @@ func runVMStatus(cmd *cobra.Command, args []string) {
 
 	fmt.Println("VM mode: Enabled")
 	fmt.Printf("VM Name: %s\n", info.Name)
-	fmt.Printf("VM Status: %s\n", info.Status)
+	if _, selectedBy := vm.CurrentVM(); selectedBy != vm.SelectedByDefault {
+		fmt.Printf("Selected By: %s\n", vmSelectionSource(selectedBy))
+	}
+	if vmManager.VMCreated() {
+		fmt.Printf("VM Status: %s\n", info.Status)
+	} else {
+		fmt.Println("VM Status: not created ('servin vm start' creates it)")
+	}
+	if e := info.LastEvent; e != nil {
+		event := e.Event
+		if e.Reason != "" {
+			event += " (" + e.Reason + ")"
+		}
+		fmt.Printf("Last Event: %s at %s\n", event, e.Time.Format("2006-01-02 15:04:05"))
+		if e.Crashed() {
+			fmt.Println("The VM crashed; see 'servin vm console --log' and restart it with 'servin vm start'")
+		}
+	}
 	fmt.Printf("VM Provider: %s\n", info.Provider)
 	fmt.Printf("Platform: %s\n", info.Platform)
 	fmt.Printf("IP Address: %s\n", info.IPAddress)
-	fmt.Printf("SSH Port: %d\n", info.SSHPort)
+	if info.AgentPort != 0 {
+		fmt.Printf("Agent Port: %d\n", info.AgentPort)
+	}
 	fmt.Printf("Docker Port: %d\n", info.DockerPort)
+	if len(info.Services) > 0 {
+		fmt.Println("Services:")
+		for _, s := range info.Services {
+			if s.Detail != "" {
+				fmt.Printf("  %s: %s (%s)\n", s.Name, s.State, s.Detail)
+			} else {
+				fmt.Printf("  %s: %s\n", s.Name, s.State)
+			}
+		}
+	}
 
 	// List containers in VM
 	containers, err := vmManager.ListVMContainers()
@@ -186,6 +269,22 @@ func runVMStart(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	progress, err := vmDownloadProgress(vmStartProgress)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	rate, err := limitRate(vmStartLimitRate, false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Ctrl+C cancels any asset download in progress
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	vmManager.SetDownloadOptions(vm.DownloadOptions{Context: ctx, Progress: progress, LimitRate: rate, Offline: vmStartOffline})
+
 	fmt.Println("Starting VM...")
 	if err := vmManager.EnsureVMRunning(); err != nil {
 		fmt.Printf("Error starting VM: %v\n", err)
@@ -195,6 +294,48 @@ func runVMStart(cmd *cobra.Command, args []string) {
 	fmt.Println("VM started successfully!")
 }
 
+// vmDownloadProgress returns the reporter for VM asset downloads in the
+// given format
+func vmDownloadProgress(format string) (func(vm.DownloadEvent), error) {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		return func(event vm.DownloadEvent) {
+			encoder.Encode(event)
+		}, nil
+	case "text", "":
+		return printDownloadEvent, nil
+	default:
+		return nil, fmt.Errorf("unknown progress format %q (expected text or json)", format)
+	}
+}
+
+// printDownloadEvent shows a download's progress on a single line
+func printDownloadEvent(event vm.DownloadEvent) {
+	switch event.State {
+	case vm.DownloadStarted:
+		if event.Total >= 0 {
+			fmt.Printf("Downloading %s (%s)...\n", event.Asset, formatSize(event.Total))
+		} else {
+			fmt.Printf("Downloading %s...\n", event.Asset)
+		}
+	case vm.DownloadProgress:
+		line := fmt.Sprintf("  %s: %s", event.Asset, formatSize(event.Downloaded))
+		if event.Total > 0 {
+			line += fmt.Sprintf(" / %s (%d%%)", formatSize(event.Total), event.Downloaded*100/event.Total)
+		}
+		fmt.Printf("\r%s, %s/s   ", line, formatSize(int64(event.BytesPerSecond)))
+	case vm.DownloadCached:
+		fmt.Printf("Using cached %s (%s)\n", event.Asset, formatSize(event.Downloaded))
+	case vm.DownloadDone:
+		fmt.Printf("\r  %s: downloaded %s                    \n", event.Asset, formatSize(event.Downloaded))
+	case vm.DownloadCancelled:
+		fmt.Printf("\n  %s: download cancelled\n", event.Asset)
+	case vm.DownloadFailed:
+		fmt.Printf("\n  %s: download failed: %s\n", event.Asset, event.Error)
+	}
+}
+
 func runVMStop(cmd *cobra.Command, args []string) {
 	vmManager, err := container.NewVMContainerManager()
 	if err != nil {
@@ -216,20 +357,51 @@ func runVMStop(cmd *cobra.Command, args []string) {
 	fmt.Println("VM stopped successfully!")
 }
 
+func runVMDestroy(cmd *cobra.Command, args []string) error {
+	vmManager, err := container.NewVMContainerManager()
+	if err != nil {
+		return err
+	}
+
+	if !vmManager.IsEnabled() {
+		fmt.Println("VM mode is not enabled.")
+		return nil
+	}
+
+	name := vmManager.VMName()
+	if vmDestroyForce {
+		recordOverride("vm destroy", "vm "+name, "skipped typed confirmation with --force")
+	} else if !confirmTyped(fmt.Sprintf("This will delete the VM %s with every container and image inside it.", name), name) {
+		return nil
+	}
+
+	fmt.Printf("Destroying VM %s...\n", name)
+	if err := vmManager.Destroy(); err != nil {
+		return fmt.Errorf("failed to destroy VM: %v", err)
+	}
+
+	fmt.Println("VM destroyed successfully!")
+	return nil
+}
+
 func runVMConfig(cmd *cobra.Command, args []string) {
-	fmt.Println("VM Configuration:")
+	config, err := currentVMConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
 
-	config := vm.DefaultVMConfig("servin-vm")
+	fmt.Println("VM Configuration:")
 	fmt.Printf("  Name: %s\n", config.Name)
 	fmt.Printf("  CPUs: %d\n", config.CPUs)
 	fmt.Printf("  Memory: %d MB\n", config.Memory)
 	fmt.Printf("  Disk: %d GB\n", config.DiskSize)
 	fmt.Printf("  Linux Distro: %s\n", config.LinuxDistro)
 	fmt.Printf("  Container Runtime: %s\n", config.ContainerRuntime)
-	fmt.Printf("  SSH Port: %d\n", config.SSHPort)
+	fmt.Printf("  Agent Port: %d\n", config.AgentPort)
 	fmt.Printf("  Docker Port: %d\n", config.DockerPort)
 
-	fmt.Println("\nTo customize configuration, edit ~/.servin/vm-config.json")
+	fmt.Printf("\nTo customize configuration, edit ~/.servin/vms/%s.json or use 'servin vm resize'\n", config.Name)
 }
 
 func runVMEnable(cmd *cobra.Command, args []string) {
@@ -307,49 +479,65 @@ func showVMCapabilities() {
 	fmt.Println("\nTo enable VM mode: servin vm enable")
 }
 
-func runVMListProviders(cmd *cobra.Command, args []string) {
-	fmt.Println("Available VM providers for", runtime.GOOS)
-	fmt.Println("================================")
-
-	switch runtime.GOOS {
-	case "linux":
-		fmt.Println("1. KVM (Kernel-based Virtual Machine)")
-		fmt.Println("   Priority: 1 (highest)")
-		fmt.Println("   Acceleration: Hardware")
-		fmt.Println("   Status: Checking...")
-
-		fmt.Println("\n2. QEMU (Quick Emulator)")
-		fmt.Println("   Priority: 2")
-		fmt.Println("   Acceleration: Software")
-		fmt.Println("   Status: Checking...")
-
-	case "darwin":
-		fmt.Println("1. Virtualization.framework")
-		fmt.Println("   Priority: 1 (highest)")
-		fmt.Println("   Acceleration: Hardware")
-		fmt.Println("   Status: Checking...")
+// vmProviderEntry is a VM provider as 'servin vm list-providers --format
+// json' prints it
+type vmProviderEntry struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Priority     int    `json:"priority"`
+	Acceleration string `json:"acceleration"`
+	Available    bool   `json:"available"`
+	Reason       string `json:"reason,omitempty"`
+	Selected     bool   `json:"selected"`
+}
 
-		fmt.Println("\n2. QEMU (Quick Emulator)")
-		fmt.Println("   Priority: 2")
-		fmt.Println("   Acceleration: Software")
-		fmt.Println("   Status: Checking...")
+func runVMListProviders(cmd *cobra.Command, args []string) error {
+	if vmListProvidersFormat != "table" && vmListProvidersFormat != "json" {
+		return errors.NewValidationError("vm list-providers", fmt.Sprintf("unknown format '%s' (expected table or json)", vmListProvidersFormat))
+	}
 
-	case "windows":
-		fmt.Println("1. Hyper-V")
-		fmt.Println("   Priority: 1 (highest)")
-		fmt.Println("   Acceleration: Hardware")
-		fmt.Println("   Status: Checking...")
+	if vmListProvidersFormat == "json" {
+		selected, _ := vm.SelectedBackend()
+		entries := []vmProviderEntry{}
+		for _, b := range vm.Backends() {
+			entry := vmProviderEntry{
+				Name:         b.Name,
+				Description:  b.Description,
+				Priority:     b.Priority,
+				Acceleration: b.Acceleration,
+				Available:    true,
+				Selected:     b.Name == selected.Name,
+			}
+			if err := b.Check(); err != nil {
+				entry.Available = false
+				entry.Reason = err.Error()
+			}
+			entries = append(entries, entry)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
 
-		fmt.Println("\n2. VirtualBox")
-		fmt.Println("   Priority: 2")
-		fmt.Println("   Acceleration: Software")
-		fmt.Println("   Status: Checking...")
+	fmt.Println("Available VM providers for", runtime.GOOS)
+	fmt.Println("================================")
 
-		fmt.Println("\n3. WSL2")
-		fmt.Println("   Priority: 3")
-		fmt.Println("   Acceleration: Hardware")
-		fmt.Println("   Status: Checking...")
+	for i, b := range vm.Backends() {
+		status := "available"
+		if err := b.Check(); err != nil {
+			status = "unavailable: " + err.Error()
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%d. %s (%s)\n", i+1, b.Description, b.Name)
+		fmt.Printf("   Priority: %d\n", b.Priority)
+		fmt.Printf("   Acceleration: %s\n", b.Acceleration)
+		fmt.Printf("   Status: %s\n", status)
 	}
+
+	fmt.Printf("\nThe first available provider is used; set %s to choose one by name.\n", vm.ProviderEnvVar)
+	return nil
 }
 
 func runVMCheckKVM(cmd *cobra.Command, args []string) {
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"servin/pkg/vm/agent"
+
+	"github.com/spf13/cobra"
+)
+
+var vmAgentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run the guest agent inside a servin VM",
+	Long: `Run the agent servin talks to inside its VMs. It runs commands, transfers
+files and reports health for the host, and only accepts requests carrying
+the token in --token-file. The VM's seed disc installs and starts it; it is
+not meant to be run on the host.`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE:   runVMAgent,
+}
+
+var (
+	vmAgentListen    string
+	vmAgentTokenFile string
+)
+
+func init() {
+	vmCmd.AddCommand(vmAgentCmd)
+
+	vmAgentCmd.Flags().StringVar(&vmAgentListen, "listen", fmt.Sprintf(":%d", agent.GuestPort), "Address to accept host requests on")
+	vmAgentCmd.Flags().StringVar(&vmAgentTokenFile, "token-file", agent.GuestTokenPath, "File holding the token requests must carry")
+}
+
+func runVMAgent(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(vmAgentTokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read agent token: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", vmAgentListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", vmAgentListen, err)
+	}
+	defer ln.Close()
+
+	fmt.Printf("Servin VM agent listening on %s\n", ln.Addr())
+	server := &agent.Server{Token: strings.TrimSpace(string(data))}
+	return server.Serve(ln)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"servin/pkg/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var vmExportCmd = &cobra.Command{
+	Use:   "export FILE",
+	Short: "Save the VM to a file",
+	Long: `Save the whole VM, with every container and image inside it, to a tar file
+that 'servin vm import' restores. A running VM is stopped for the export and
+started again. Only supported with WSL2; other providers have snapshots.
+
+Examples:
+  servin vm export servin-vm.tar`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMExport,
+}
+
+var vmImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Replace the VM with one saved by 'servin vm export'",
+	Long: `Replace the VM with one saved by 'servin vm export', deleting every
+container and image in the current one. The imported VM runs as WSL 2
+whatever the default WSL version is.
+
+The replacement must be confirmed by typing the VM name. --force skips the
+confirmation and is recorded in the audit trail.
+
+Examples:
+  servin vm import servin-vm.tar`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMImport,
+}
+
+var vmImportForce bool
+
+func init() {
+	vmCmd.AddCommand(vmExportCmd)
+	vmCmd.AddCommand(vmImportCmd)
+
+	vmImportCmd.Flags().BoolVarP(&vmImportForce, "force", "f", false, "Replace the VM without confirmation")
+}
+
+func runVMExport(cmd *cobra.Command, args []string) error {
+	vmManager, err := vmContainerManager()
+	if err != nil {
+		return err
+	}
+	backup, err := vmManager.VMBackup()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exporting VM %s to %s...\n", vmManager.VMName(), args[0])
+	if err := backup.Export(args[0]); err != nil {
+		return fmt.Errorf("failed to export VM: %v", err)
+	}
+
+	fmt.Printf("VM %s exported to %s\n", vmManager.VMName(), args[0])
+	return nil
+}
+
+func runVMImport(cmd *cobra.Command, args []string) error {
+	if info, err := os.Stat(args[0]); err != nil || info.IsDir() {
+		return errors.NewNotFoundError("vm import", fmt.Sprintf("no such file: %s", args[0]))
+	}
+
+	vmManager, err := vmContainerManager()
+	if err != nil {
+		return err
+	}
+	backup, err := vmManager.VMBackup()
+	if err != nil {
+		return err
+	}
+
+	name := vmManager.VMName()
+	if vmImportForce {
+		recordOverride("vm import", "vm "+name, "skipped typed confirmation with --force")
+	} else if !confirmTyped(fmt.Sprintf("This will replace the VM %s, deleting every container and image inside it.", name), name) {
+		return nil
+	}
+
+	fmt.Printf("Importing VM %s from %s...\n", name, args[0])
+	if err := backup.Import(args[0]); err != nil {
+		return fmt.Errorf("failed to import VM: %v", err)
+	}
+
+	fmt.Printf("VM %s imported; start it with 'servin vm start'\n", name)
+	return nil
+}
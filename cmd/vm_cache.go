@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"servin/pkg/errors"
+	"servin/pkg/vm"
+
+	"github.com/spf13/cobra"
+)
+
+var vmCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the VM asset cache",
+	Long: `Manage the cache of kernels, initramfs, ISOs and root filesystems VMs are
+created from. It is shared by all VMs and kept in ~/.servin/cache, so each
+asset is downloaded once.
+
+Every asset is checked against its SHA256 digest: the one set in
+daemon.vm_assets.checksums in config.yaml, else the one published next to
+it, else the one taken when it was first downloaded. daemon.vm_assets.mirror
+downloads from a mirror of https://dl-cdn.alpinelinux.org/alpine instead.
+
+For hosts without internet access, import the assets into the cache and
+create VMs with 'servin vm start --offline' or daemon.vm_assets.offline.`,
+}
+
+var vmCacheLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List cached VM assets",
+	Args:    cobra.NoArgs,
+	RunE:    runVMCacheList,
+}
+
+var vmCacheImportCmd = &cobra.Command{
+	Use:   "import FILE PATH",
+	Short: "Add a VM asset to the cache",
+	Long: `Add a file to the cache as the asset at PATH, so VMs can be created from it
+offline. PATH is the asset's path in the cache: its path in the Alpine tree
+under alpine/, as an offline 'servin vm start' names it.
+
+Examples:
+  servin vm cache import vmlinuz-virt alpine/v3.19/releases/x86_64/netboot-3.19.1/vmlinuz-virt
+  servin vm cache import initramfs-virt alpine/v3.19/releases/x86_64/netboot-3.19.1/initramfs-virt`,
+	Args: cobra.ExactArgs(2),
+	RunE: runVMCacheImport,
+}
+
+var vmCacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all cached VM assets",
+	Long: `Delete all cached VM assets. VMs keep the assets they were created from;
+new VMs download them again.`,
+	Args: cobra.NoArgs,
+	RunE: runVMCacheClear,
+}
+
+var vmCacheFormat string
+
+func init() {
+	vmCmd.AddCommand(vmCacheCmd)
+	vmCacheCmd.AddCommand(vmCacheLsCmd)
+	vmCacheCmd.AddCommand(vmCacheImportCmd)
+	vmCacheCmd.AddCommand(vmCacheClearCmd)
+
+	vmCacheLsCmd.Flags().StringVar(&vmCacheFormat, "format", "table", "Output format (table, json)")
+}
+
+func runVMCacheList(cmd *cobra.Command, args []string) error {
+	if vmCacheFormat != "table" && vmCacheFormat != "json" {
+		return errors.NewValidationError("vm cache ls", fmt.Sprintf("unknown format '%s' (expected table or json)", vmCacheFormat))
+	}
+
+	assets, err := vm.ListCachedAssets()
+	if err != nil {
+		return err
+	}
+
+	if vmCacheFormat == "json" {
+		if assets == nil {
+			assets = []vm.CachedAsset{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(assets)
+	}
+
+	if len(assets) == 0 {
+		fmt.Println("No cached VM assets")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PATH\tSIZE\tSHA256\tCHECKSUM")
+	for _, a := range assets {
+		digest := a.SHA256
+		if len(digest) > 12 {
+			digest = digest[:12]
+		}
+		if digest == "" {
+			digest = "<unknown>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.Key, formatSize(a.Size), digest, a.Checksum)
+	}
+	return w.Flush()
+}
+
+func runVMCacheImport(cmd *cobra.Command, args []string) error {
+	if info, err := os.Stat(args[0]); err != nil || info.IsDir() {
+		return errors.NewNotFoundError("vm cache import", fmt.Sprintf("no such file: %s", args[0]))
+	}
+
+	asset, err := vm.ImportAsset(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %s (%s, sha256:%s)\n", asset.Key, formatSize(asset.Size), asset.SHA256)
+	return nil
+}
+
+func runVMCacheClear(cmd *cobra.Command, args []string) error {
+	freed, err := vm.ClearAssetCache()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cleared VM asset cache, freed %s\n", formatSize(freed))
+	return nil
+}
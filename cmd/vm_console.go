@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"servin/pkg/container"
+	"servin/pkg/terminal"
+	"servin/pkg/vm"
+
+	"github.com/spf13/cobra"
+)
+
+var vmShellCmd = &cobra.Command{
+	Use:     "shell [-- COMMAND [ARG...]]",
+	Aliases: []string{"ssh"},
+	Short:   "Open a shell in the VM",
+	Long: `Open a login shell in the running VM, or run COMMAND in it. The session goes
+through the servin agent with the VM's token, like every other request to
+the VM, so no SSH server, key or password is needed.
+
+The shell gets a terminal when standard input is one.
+
+Examples:
+  servin vm shell
+  servin vm ssh -- dmesg | tail
+  servin vm shell -- df -h /`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runVMShell,
+}
+
+var vmConsoleCmd = &cobra.Command{
+	Use:   "console",
+	Short: "Attach to the serial console of the VM",
+	Long: `Attach to the serial console of the running VM, to debug a guest whose
+servin agent does not come up. The guest runs a login prompt on the console.
+Press Ctrl-] to detach.
+
+QEMU/KVM also log the console to ~/.servin/vms/NAME/console.log, which --log
+prints even when the VM is stopped. vfkit's console is only logged, so
+--log is used with it. WSL2 distributions have no serial console; use
+'servin vm shell'.
+
+Examples:
+  servin vm console
+  servin vm console --log
+  servin vm console --log -f`,
+	Args: cobra.NoArgs,
+	RunE: runVMConsole,
+}
+
+var (
+	vmConsoleLog    bool
+	vmConsoleFollow bool
+)
+
+// consoleDetachKey is Ctrl-], which detaches from the console
+const consoleDetachKey = 0x1d
+
+func init() {
+	vmCmd.AddCommand(vmShellCmd)
+	vmCmd.AddCommand(vmConsoleCmd)
+
+	vmConsoleCmd.Flags().BoolVar(&vmConsoleLog, "log", false, "Print the console log instead of attaching")
+	vmConsoleCmd.Flags().BoolVarP(&vmConsoleFollow, "follow", "f", false, "Follow the console log (with --log)")
+}
+
+// vmContainerManager returns the manager of the VM in use, which must be
+// in VM mode
+func vmContainerManager() (*container.VMContainerManager, error) {
+	vmManager, err := container.NewVMContainerManager()
+	if err != nil {
+		return nil, err
+	}
+	if !vmManager.IsEnabled() {
+		return nil, fmt.Errorf("VM mode is not enabled. Use 'servin vm enable' first")
+	}
+	return vmManager, nil
+}
+
+func runVMShell(cmd *cobra.Command, args []string) error {
+	vmManager, err := vmContainerManager()
+	if err != nil {
+		return err
+	}
+	shell, err := vmManager.VMShell()
+	if err != nil {
+		return err
+	}
+	return shell.Shell(args)
+}
+
+func runVMConsole(cmd *cobra.Command, args []string) error {
+	vmManager, err := vmContainerManager()
+	if err != nil {
+		return err
+	}
+	console, err := vmManager.VMConsole()
+	if err != nil {
+		return err
+	}
+
+	if vmConsoleLog || vmConsoleFollow {
+		return printConsoleLog(console.ConsoleLog(), vmConsoleFollow)
+	}
+
+	conn, err := console.OpenConsole()
+	if errors.Is(err, vm.ErrConsoleReadOnly) {
+		fmt.Fprintln(os.Stderr, "The console of this VM is read-only; following its log (Ctrl+C to stop)")
+		return printConsoleLog(console.ConsoleLog(), true)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return attachConsole(conn)
+}
+
+// attachConsole connects the terminal to the VM console until Ctrl-] is
+// pressed or the console closes
+func attachConsole(conn io.ReadWriteCloser) error {
+	fmt.Fprintln(os.Stderr, "Connected to the VM console. Press Ctrl-] to detach.")
+	if terminal.IsTerminal(os.Stdin) {
+		restore, err := terminal.MakeRaw(os.Stdin)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(os.Stdout, conn)
+		done <- err
+	}()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				data := buf[:n]
+				detach := bytes.IndexByte(data, consoleDetachKey)
+				if detach >= 0 {
+					data = data[:detach]
+				}
+				if _, werr := conn.Write(data); werr != nil {
+					done <- werr
+					return
+				}
+				if detach >= 0 {
+					done <- nil
+					return
+				}
+			}
+			if err != nil {
+				done <- nil
+				return
+			}
+		}
+	}()
+
+	err := <-done
+	fmt.Fprint(os.Stderr, "\r\nDetached from the VM console\r\n")
+	return err
+}
+
+// printConsoleLog prints the console log and, with follow, what the VM
+// writes to it from then on
+func printConsoleLog(log string, follow bool) error {
+	if log == "" {
+		return fmt.Errorf("the console of this VM is not logged")
+	}
+	f, err := os.Open(log)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no console log yet: %s is created when the VM starts", log)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for {
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			return err
+		}
+		if !follow {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
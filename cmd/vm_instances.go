@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"servin/pkg/errors"
+	"servin/pkg/vm"
+
+	"github.com/spf13/cobra"
+)
+
+var vmCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Create a named VM",
+	Long: `Create a named VM with its own resources, containers and images, so projects
+can be isolated from each other. The VM itself is set up on its first
+'servin vm start', like the default VM.
+
+The VM in use is taken from the SERVIN_VM_NAME environment variable, then
+the nearest .servin-vm file in the working directory or its parents (see
+'servin vm use --project'), then 'servin vm use', and defaults to
+"servin-vm".
+
+With --remote the VM is an existing Linux host reached over SSH with the
+key given by --identity, for machines that cannot run a VM: servin is
+installed on it on the first 'servin vm start' and containers run there as
+root, through passwordless sudo for other users.
+
+Examples:
+  servin vm create ml --cpus 8 --memory 16384 --disk 100
+  servin vm create web --use
+  servin vm create box --remote ubuntu@build.example.com --identity ~/.ssh/id_ed25519`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMCreate,
+}
+
+var vmUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "Set the VM used when no other selects one",
+	Long: `Set the VM commands use. With --project, the VM is only used for commands
+run in the working directory and its subdirectories: a .servin-vm file
+naming it is written there, which can be committed with the project.
+
+SERVIN_VM_NAME still takes precedence, as does a project's .servin-vm file
+over 'servin vm use'.
+
+Examples:
+  servin vm use ml
+  cd ~/src/web && servin vm use web --project`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMUse,
+}
+
+var vmLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List VMs",
+	Args:    cobra.NoArgs,
+	RunE:    runVMList,
+}
+
+var (
+	vmCreateCPUs     int
+	vmCreateMemory   int
+	vmCreateDisk     int
+	vmCreateUse      bool
+	vmCreateRemote   string
+	vmCreateIdentity string
+	vmUseProject     bool
+	vmLsQuiet        bool
+	vmLsFormat       string
+)
+
+func init() {
+	vmCmd.AddCommand(vmCreateCmd)
+	vmCmd.AddCommand(vmUseCmd)
+	vmCmd.AddCommand(vmLsCmd)
+
+	defaults := vm.DefaultVMConfig(vm.DefaultVMName)
+	vmCreateCmd.Flags().IntVar(&vmCreateCPUs, "cpus", defaults.CPUs, "Number of CPUs")
+	vmCreateCmd.Flags().IntVar(&vmCreateMemory, "memory", defaults.Memory, "Memory in MB")
+	vmCreateCmd.Flags().IntVar(&vmCreateDisk, "disk", defaults.DiskSize, "Disk size in GB")
+	vmCreateCmd.Flags().BoolVar(&vmCreateUse, "use", false, "Use the new VM, as 'servin vm use' does")
+	vmCreateCmd.Flags().StringVar(&vmCreateRemote, "remote", "", "Run containers on this Linux host over SSH ([USER@]HOST[:PORT])")
+	vmCreateCmd.Flags().StringVar(&vmCreateIdentity, "identity", "", "SSH private key for --remote")
+
+	vmUseCmd.Flags().BoolVar(&vmUseProject, "project", false, "Only use the VM in the working directory, with a .servin-vm file")
+
+	vmLsCmd.Flags().BoolVarP(&vmLsQuiet, "quiet", "q", false, "Only display VM names")
+	vmLsCmd.Flags().StringVar(&vmLsFormat, "format", "table", "Output format (table, json)")
+}
+
+// currentVMConfig returns the configuration of the VM in use
+func currentVMConfig() (*vm.VMConfig, error) {
+	name, _ := vm.CurrentVM()
+	return vm.LoadVMConfig(name)
+}
+
+// vmSelectionSource describes what selected the VM in use
+func vmSelectionSource(selectedBy string) string {
+	switch selectedBy {
+	case vm.SelectedByEnv:
+		return "$" + vm.NameEnvVar
+	case vm.SelectedByProject:
+		if _, path := vm.ProjectVM(); path != "" {
+			return path
+		}
+		return vm.ProjectFile
+	case vm.SelectedByUse:
+		return "servin vm use"
+	}
+	return "default"
+}
+
+func runVMCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := vm.ValidateVMName(name); err != nil {
+		return errors.NewValidationError("vm create", err.Error())
+	}
+	if vm.VMExists(name) {
+		return errors.NewConflictError("vm create", fmt.Sprintf("VM %s already exists", name))
+	}
+	for _, flag := range []struct {
+		name  string
+		value int
+	}{{"cpus", vmCreateCPUs}, {"memory", vmCreateMemory}, {"disk", vmCreateDisk}} {
+		if flag.value <= 0 {
+			return errors.NewValidationError("vm create", fmt.Sprintf("--%s must be positive", flag.name))
+		}
+	}
+
+	config := vm.DefaultVMConfig(name)
+	config.CPUs = vmCreateCPUs
+	config.Memory = vmCreateMemory
+	config.DiskSize = vmCreateDisk
+	if vmCreateRemote != "" || vmCreateIdentity != "" {
+		remote, err := remoteConfig(vmCreateRemote, vmCreateIdentity)
+		if err != nil {
+			return err
+		}
+		config.Remote = remote
+	}
+	if err := vm.SaveVMConfig(config); err != nil {
+		return err
+	}
+	if vmCreateUse {
+		if err := vm.UseVM(name); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(name)
+	return nil
+}
+
+// remoteConfig validates --remote and --identity, which go together
+func remoteConfig(target, identity string) (*vm.RemoteConfig, error) {
+	if target == "" || identity == "" {
+		return nil, errors.NewValidationError("vm create", "--remote and --identity must be given together")
+	}
+	if rest, ok := strings.CutPrefix(identity, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		identity = filepath.Join(home, rest)
+	}
+	identity, err := filepath.Abs(identity)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(identity); err != nil || info.IsDir() {
+		return nil, errors.NewNotFoundError("vm create", fmt.Sprintf("SSH key %s not found", identity))
+	}
+
+	remote, err := vm.ParseRemote(target, identity)
+	if err != nil {
+		return nil, errors.NewValidationError("vm create", err.Error())
+	}
+	return remote, nil
+}
+
+func runVMUse(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := vm.ValidateVMName(name); err != nil {
+		return errors.NewValidationError("vm use", err.Error())
+	}
+	if !vm.VMExists(name) {
+		return errors.NewNotFoundError("vm use", fmt.Sprintf("VM %s not found (create it with 'servin vm create %s')", name, name))
+	}
+
+	if vmUseProject {
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		path, err := vm.UseVMInProject(dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Now using VM %s in %s (%s)\n", name, dir, path)
+	} else {
+		if err := vm.UseVM(name); err != nil {
+			return err
+		}
+		fmt.Printf("Now using VM %s\n", name)
+	}
+
+	if current, selectedBy := vm.CurrentVM(); current != name {
+		fmt.Printf("Note: %s selects VM %s here\n", vmSelectionSource(selectedBy), current)
+	}
+	return nil
+}
+
+// vmListEntry is a VM as 'servin vm ls --format json' prints it. Remote is
+// the host of a VM created with --remote; Error is set when the VM's
+// configuration cannot be read.
+type vmListEntry struct {
+	Name       string `json:"name"`
+	CPUs       int    `json:"cpus"`
+	Memory     int    `json:"memory_mb"`
+	Disk       int    `json:"disk_size_gb"`
+	Remote     string `json:"remote,omitempty"`
+	Active     bool   `json:"active"`
+	SelectedBy string `json:"selected_by,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func runVMList(cmd *cobra.Command, args []string) error {
+	if vmLsFormat != "table" && vmLsFormat != "json" {
+		return errors.NewValidationError("vm ls", fmt.Sprintf("unknown format '%s' (expected table or json)", vmLsFormat))
+	}
+
+	names, err := vm.ListVMs()
+	if err != nil {
+		return err
+	}
+
+	if vmLsFormat == "json" {
+		current, selectedBy := vm.CurrentVM()
+		entries := []vmListEntry{}
+		for _, name := range names {
+			entry := vmListEntry{Name: name, Active: name == current}
+			if entry.Active {
+				entry.SelectedBy = vmSelectionSource(selectedBy)
+			}
+			if config, err := vm.LoadVMConfig(name); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.CPUs, entry.Memory, entry.Disk = config.CPUs, config.Memory, config.DiskSize
+				if config.Remote != nil {
+					entry.Remote = config.Remote.Host
+				}
+			}
+			entries = append(entries, entry)
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if vmLsQuiet {
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	current, selectedBy := vm.CurrentVM()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCPUS\tMEMORY\tDISK\tACTIVE")
+	for _, name := range names {
+		config, err := vm.LoadVMConfig(name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t%v\n", name, err)
+			continue
+		}
+		active := ""
+		if name == current {
+			active = "* (" + vmSelectionSource(selectedBy) + ")"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d MB\t%d GB\t%s\n", name, config.CPUs, config.Memory, config.DiskSize, active)
+	}
+	return w.Flush()
+}
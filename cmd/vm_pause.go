@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var vmPauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Freeze the VM",
+	Long: `Freeze the running VM, with every container in it, keeping its memory. The
+VM uses no CPU while paused; 'servin vm resume' or 'servin vm start' lets it
+continue where it was.`,
+	Args: cobra.NoArgs,
+	RunE: runVMPause,
+}
+
+var vmResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Let a paused VM continue",
+	Args:  cobra.NoArgs,
+	RunE:  runVMResume,
+}
+
+func init() {
+	vmCmd.AddCommand(vmPauseCmd)
+	vmCmd.AddCommand(vmResumeCmd)
+}
+
+func runVMPause(cmd *cobra.Command, args []string) error {
+	vmManager, err := vmContainerManager()
+	if err != nil {
+		return err
+	}
+	pauser, err := vmManager.VMPause()
+	if err != nil {
+		return err
+	}
+
+	if err := pauser.Pause(); err != nil {
+		return fmt.Errorf("failed to pause VM: %v", err)
+	}
+	fmt.Println("VM paused")
+	return nil
+}
+
+func runVMResume(cmd *cobra.Command, args []string) error {
+	vmManager, err := vmContainerManager()
+	if err != nil {
+		return err
+	}
+	pauser, err := vmManager.VMPause()
+	if err != nil {
+		return err
+	}
+
+	if err := pauser.Resume(); err != nil {
+		return fmt.Errorf("failed to resume VM: %v", err)
+	}
+	fmt.Println("VM resumed")
+	return nil
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"servin/pkg/container"
+	"servin/pkg/errors"
+	"servin/pkg/vm"
+
+	"github.com/spf13/cobra"
+)
+
+var vmResizeCmd = &cobra.Command{
+	Use:   "resize",
+	Short: "Change the CPUs, memory and disk size of the VM",
+	Long: `Change the CPUs, memory (in MB) and disk size (in GB) of the VM without
+recreating it, keeping the containers and images inside it.
+
+A running VM is stopped for the change and started again. The disk can only
+grow: its image is grown in place and the guest grows its filesystem to fill
+it. The new sizes are saved in the VM's configuration, ~/.servin/vms/NAME.json.
+
+QEMU refuses to grow a qcow2 disk with snapshots; delete them with
+'servin vm snapshot rm' first. WSL2 takes its resources from .wslconfig.
+
+Examples:
+  servin vm resize --cpus 4 --memory 8192
+  servin vm resize --disk 60`,
+	Args: cobra.NoArgs,
+	RunE: runVMResize,
+}
+
+var (
+	vmResizeCPUs   int
+	vmResizeMemory int
+	vmResizeDisk   int
+)
+
+func init() {
+	vmCmd.AddCommand(vmResizeCmd)
+	vmResizeCmd.Flags().IntVar(&vmResizeCPUs, "cpus", 0, "Number of CPUs")
+	vmResizeCmd.Flags().IntVar(&vmResizeMemory, "memory", 0, "Memory in MB")
+	vmResizeCmd.Flags().IntVar(&vmResizeDisk, "disk", 0, "Disk size in GB (can only grow)")
+}
+
+func runVMResize(cmd *cobra.Command, args []string) error {
+	res := vm.Resources{}
+	for _, flag := range []struct {
+		name  string
+		value int
+		field *int
+	}{
+		{"cpus", vmResizeCPUs, &res.CPUs},
+		{"memory", vmResizeMemory, &res.Memory},
+		{"disk", vmResizeDisk, &res.DiskSize},
+	} {
+		if !cmd.Flags().Changed(flag.name) {
+			continue
+		}
+		if flag.value <= 0 {
+			return errors.NewValidationError("vm resize", fmt.Sprintf("--%s must be positive", flag.name))
+		}
+		*flag.field = flag.value
+	}
+	if res == (vm.Resources{}) {
+		return errors.NewValidationError("vm resize", "specify --cpus, --memory or --disk")
+	}
+	current, err := currentVMConfig()
+	if err != nil {
+		return err
+	}
+	if res.DiskSize != 0 && res.DiskSize < current.DiskSize {
+		return errors.NewValidationError("vm resize", fmt.Sprintf("cannot shrink the VM disk from %dGB to %dGB", current.DiskSize, res.DiskSize))
+	}
+
+	vmManager, err := container.NewVMContainerManager()
+	if err != nil {
+		return err
+	}
+	if !vmManager.IsEnabled() {
+		return fmt.Errorf("VM mode is not enabled. Use 'servin vm enable' first")
+	}
+
+	fmt.Printf("Resizing VM %s...\n", vmManager.VMName())
+	restarted, err := vmManager.ResizeVM(res)
+	if err != nil {
+		return fmt.Errorf("failed to resize VM: %v", err)
+	}
+
+	config, err := currentVMConfig()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("VM resized: %d CPUs, %d MB memory, %d GB disk\n", config.CPUs, config.Memory, config.DiskSize)
+	if !restarted {
+		fmt.Println("The new resources apply when the VM next starts")
+	}
+	return nil
+}
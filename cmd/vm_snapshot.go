@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"servin/pkg/container"
+	"servin/pkg/vm"
+
+	"github.com/spf13/cobra"
+)
+
+var vmSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage VM snapshots",
+	Long: `Save the state of the VM and roll it back in seconds instead of recreating it.
+
+Snapshots are qcow2 internal snapshots with QEMU/KVM, checkpoints with
+Hyper-V and snapshots with VirtualBox. A snapshot of a running VM includes
+its memory, so restoring it resumes the VM where it was: the containers and
+images in it are exactly as they were when the snapshot was taken.`,
+}
+
+var vmSnapshotCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Take a snapshot of the VM",
+	Long: `Take a snapshot of the VM. Take one right after setting the VM up to have a
+clean state to return to.
+
+Examples:
+  servin vm snapshot create clean
+  servin vm snapshot create before-upgrade`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMSnapshotCreate,
+}
+
+var vmSnapshotRestoreCmd = &cobra.Command{
+	Use:   "restore NAME",
+	Short: "Roll the VM back to a snapshot",
+	Long: `Roll the VM back to a snapshot, discarding every change made in it since.
+
+With QEMU/KVM a running VM is rolled back in place. A snapshot restored
+while the VM is stopped is resumed, memory included, when it next starts.
+Hyper-V and VirtualBox turn a running VM off, apply the snapshot and start
+it again.
+
+Examples:
+  servin vm snapshot restore clean`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVMSnapshotRestore,
+}
+
+var vmSnapshotListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List VM snapshots",
+	Args:    cobra.NoArgs,
+	RunE:    runVMSnapshotList,
+}
+
+var vmSnapshotRmCmd = &cobra.Command{
+	Use:     "rm NAME [NAME...]",
+	Aliases: []string{"remove"},
+	Short:   "Delete VM snapshots",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runVMSnapshotRm,
+}
+
+func init() {
+	vmCmd.AddCommand(vmSnapshotCmd)
+	vmSnapshotCmd.AddCommand(vmSnapshotCreateCmd)
+	vmSnapshotCmd.AddCommand(vmSnapshotRestoreCmd)
+	vmSnapshotCmd.AddCommand(vmSnapshotListCmd)
+	vmSnapshotCmd.AddCommand(vmSnapshotRmCmd)
+}
+
+// vmSnapshots returns the snapshot support of the VM in use
+func vmSnapshots() (vm.SnapshotProvider, error) {
+	vmManager, err := container.NewVMContainerManager()
+	if err != nil {
+		return nil, err
+	}
+	if !vmManager.IsEnabled() {
+		return nil, fmt.Errorf("VM mode is not enabled. Use 'servin vm enable' first")
+	}
+	return vmManager.VMSnapshots()
+}
+
+func runVMSnapshotCreate(cmd *cobra.Command, args []string) error {
+	snapshots, err := vmSnapshots()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Taking snapshot %s...\n", args[0])
+	if err := snapshots.CreateSnapshot(args[0]); err != nil {
+		return fmt.Errorf("failed to take snapshot: %v", err)
+	}
+
+	fmt.Printf("Snapshot %s created\n", args[0])
+	return nil
+}
+
+func runVMSnapshotRestore(cmd *cobra.Command, args []string) error {
+	snapshots, err := vmSnapshots()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring snapshot %s...\n", args[0])
+	if err := snapshots.RestoreSnapshot(args[0]); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %v", err)
+	}
+
+	fmt.Printf("VM rolled back to snapshot %s\n", args[0])
+	return nil
+}
+
+func runVMSnapshotList(cmd *cobra.Command, args []string) error {
+	snapshots, err := vmSnapshots()
+	if err != nil {
+		return err
+	}
+
+	list, err := snapshots.ListSnapshots()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED")
+	for _, s := range list {
+		created := "-"
+		if !s.Created.IsZero() {
+			created = s.Created.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\n", s.Name, created)
+	}
+	return w.Flush()
+}
+
+func runVMSnapshotRm(cmd *cobra.Command, args []string) error {
+	snapshots, err := vmSnapshots()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args {
+		if err := snapshots.DeleteSnapshot(name); err != nil {
+			return fmt.Errorf("failed to delete snapshot %s: %v", name, err)
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
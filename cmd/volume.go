@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"servin/pkg/errors"
 	"servin/pkg/logger"
+	"servin/pkg/state"
+	"servin/pkg/tenancy"
 	"servin/pkg/volume"
 
 	"github.com/spf13/cobra"
@@ -24,7 +29,13 @@ var volumeLsCmd = &cobra.Command{
 	Use:     "ls",
 	Aliases: []string{"list"},
 	Short:   "List volumes",
-	RunE:    runVolumeList,
+	Long: `List volumes.
+
+Filters (--filter KEY=VALUE, all must match):
+  dangling=true|false  volumes no container mounts, or those one does
+  driver=NAME          volumes of a driver
+  label=KEY[=VALUE]    volumes with a label, or a label set to a value`,
+	RunE: runVolumeList,
 }
 
 var volumeCreateCmd = &cobra.Command{
@@ -32,9 +43,20 @@ var volumeCreateCmd = &cobra.Command{
 	Short: "Create a volume",
 	Long: `Create a new volume that containers can mount.
 
+Drivers and their options (--opt KEY=VALUE):
+  local  a directory, or a mount with type, device and o as for mount(8)
+  tmpfs  memory-backed storage; size, mode, uid, gid
+  loop   a filesystem image that caps the volume's size; size (required), fs (ext4), o
+  nfs    an NFS export; server, share (required), o
+  cifs   an SMB share; server, share (required), username, password, domain, o
+
 Examples:
   servin volume create myvolume
-  servin volume create --driver local --label env=prod datavolume`,
+  servin volume create --driver local --label env=prod datavolume
+  servin volume create --driver tmpfs --opt size=256m scratch
+  servin volume create --driver loop --opt size=10g quota-data
+  servin volume create --driver nfs --opt server=10.0.0.5 --opt share=/exports/data --opt o=vers=4,rw shared
+  servin volume create --driver local --opt type=nfs --opt device=10.0.0.5:/exports/data --opt o=rw shared2`,
 	Args: cobra.ExactArgs(1),
 	RunE: runVolumeCreate,
 }
@@ -45,6 +67,9 @@ var volumeRmCmd = &cobra.Command{
 	Short:   "Remove one or more volumes",
 	Long: `Remove one or more volumes. You cannot remove a volume that is in use by a container.
 
+Volumes labelled 'protected' (servin volume create --label protected) are only
+removed with --override-protection, which is recorded in the audit trail.
+
 Examples:
   servin volume rm myvolume
   servin volume rm volume1 volume2
@@ -54,10 +79,16 @@ Examples:
 }
 
 var volumePruneCmd = &cobra.Command{
-	Use:   "prune",
+	Use:   "prune [OPTIONS]",
 	Short: "Remove all unused local volumes",
-	Long:  "Remove all unused local volumes. Unused volumes are those not referenced by any containers.",
-	RunE:  runVolumePrune,
+	Long: `Remove all unused local volumes. Unused volumes are those not referenced by any containers.
+Volumes labelled 'protected' are never pruned.
+
+Examples:
+  servin volume prune
+  servin volume prune --filter until=168h --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runVolumePrune,
 }
 
 var volumeInspectCmd = &cobra.Command{
@@ -70,8 +101,13 @@ var volumeInspectCmd = &cobra.Command{
 var volumeRmAllCmd = &cobra.Command{
 	Use:   "rm-all",
 	Short: "Remove all volumes",
-	Long:  "Remove all volumes. Use with caution as this will remove all data in volumes.",
-	RunE:  runVolumeRemoveAll,
+	Long: `Remove all volumes. Use with caution as this will remove all data in volumes.
+
+The removal must be confirmed by typing 'all volumes'. --force skips the
+confirmation and is recorded in the audit trail. Protected volumes are kept
+unless --override-protection is given.`,
+	Args: cobra.NoArgs,
+	RunE: runVolumeRemoveAll,
 }
 
 // Volume create flags
@@ -81,6 +117,17 @@ var (
 	volumeOpts   []string
 )
 
+// Volume list flags
+var (
+	volumeListFilters []string
+	volumeLsFormat    string
+)
+
+// Volume inspect flags
+var (
+	volumeInspectJSON bool
+)
+
 // Volume remove flags
 var (
 	volumeForce bool
@@ -95,34 +142,151 @@ func init() {
 	volumeCmd.AddCommand(volumePruneCmd)
 	volumeCmd.AddCommand(volumeInspectCmd)
 
+	// Volume list flags
+	volumeLsCmd.Flags().StringArrayVarP(&volumeListFilters, "filter", "f", nil, "Filter the volumes listed (dangling=true|false, driver=NAME, label=KEY[=VALUE])")
+	volumeLsCmd.Flags().StringVar(&volumeLsFormat, "format", "table", "Output format (table, json)")
+
+	// Volume inspect flags
+	volumeInspectCmd.Flags().BoolVarP(&volumeInspectJSON, "format", "f", false, "Format output as JSON")
+
 	// Volume create flags
-	volumeCreateCmd.Flags().StringVar(&volumeDriver, "driver", "local", "Specify volume driver name")
+	volumeCreateCmd.Flags().StringVarP(&volumeDriver, "driver", "d", "local", "Volume driver (local, tmpfs, loop, nfs, cifs)")
 	volumeCreateCmd.Flags().StringSliceVarP(&volumeLabels, "label", "l", []string{}, "Set metadata for a volume")
-	volumeCreateCmd.Flags().StringSliceVar(&volumeOpts, "opt", []string{}, "Set driver specific options")
+	volumeCreateCmd.Flags().StringArrayVarP(&volumeOpts, "opt", "o", []string{}, "Set a driver specific option (KEY=VALUE)")
 
 	// Volume remove flags
 	volumeRmCmd.Flags().BoolVarP(&volumeForce, "force", "f", false, "Force the removal of one or more volumes")
+	volumeRmCmd.Flags().BoolVar(&overrideProtection, "override-protection", false, "Also remove volumes labelled protected")
+	volumeRmAllCmd.Flags().BoolVarP(&volumeForce, "force", "f", false, "Do not prompt for confirmation")
+	volumeRmAllCmd.Flags().BoolVar(&overrideProtection, "override-protection", false, "Also remove volumes labelled protected")
+
+	// Volume prune flags
+	addPruneFlags(volumePruneCmd)
 
 	// Add volume command to root
 	rootCmd.AddCommand(volumeCmd)
 }
 
+// volumeListEntry is a volume in the JSON output of volume ls
+type volumeListEntry struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	Created    time.Time         `json:"created"`
+	Scope      string            `json:"scope"`
+	Labels     map[string]string `json:"labels"`
+	Anonymous  bool              `json:"anonymous"`
+	Containers int               `json:"containers"`
+}
+
+// volumeInspectEntry is a volume in the JSON output of volume inspect
+type volumeInspectEntry struct {
+	volumeListEntry
+	Options    map[string]string `json:"options"`
+	Status     map[string]string `json:"status"`
+	Size       int64             `json:"size"`
+	Containers []volumeUserEntry `json:"containers"`
+}
+
+// volumeUserEntry is a container that mounts an inspected volume
+type volumeUserEntry struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	Status      string `json:"status"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// volumeUsers returns the containers that mount a volume, by name or by
+// mountpoint
+func volumeUsers(containers []*state.ContainerState, vol *volume.Volume) []volumeUserEntry {
+	users := []volumeUserEntry{}
+	for _, c := range containers {
+		for source, mount := range c.Volumes {
+			if source != vol.Name && source != vol.Mountpoint {
+				continue
+			}
+			users = append(users, volumeUserEntry{
+				ID:          c.ID,
+				Name:        c.Name,
+				Namespace:   tenancy.Normalize(c.Namespace),
+				Status:      c.Status,
+				Destination: strings.TrimSuffix(mount, ":ro"),
+				ReadOnly:    strings.HasSuffix(mount, ":ro"),
+			})
+		}
+	}
+	return users
+}
+
+func newVolumeListEntry(vol *volume.Volume, containers int) volumeListEntry {
+	labels := vol.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return volumeListEntry{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Mountpoint: vol.Mountpoint,
+		Created:    vol.CreatedAt,
+		Scope:      vol.Scope,
+		Labels:     labels,
+		Anonymous:  vol.Anonymous,
+		Containers: containers,
+	}
+}
+
 func runVolumeList(cmd *cobra.Command, args []string) error {
 	logger.Debug("Starting volume list operation")
 
+	if volumeLsFormat != "table" && volumeLsFormat != "json" {
+		return errors.NewValidationError("volume ls", fmt.Sprintf("unknown format '%s' (expected table or json)", volumeLsFormat))
+	}
+
 	if err := checkRoot(); err != nil {
 		return err
 	}
 
+	match, err := parseVolumeFilters(volumeListFilters)
+	if err != nil {
+		return err
+	}
+
 	volManager := volume.NewManager()
-	volumes, err := volManager.ListVolumes()
+	all, err := volManager.ListVolumes()
 	if err != nil {
 		logger.Error("Failed to list volumes: %v", err)
 		return errors.WrapError(err, errors.ErrTypeVolume, "runVolumeList", "failed to retrieve volume list")
 	}
+	var volumes []*volume.Volume
+	for _, vol := range all {
+		if match(vol) {
+			volumes = append(volumes, vol)
+		}
+	}
 
 	logger.Info("Found %d volumes", len(volumes))
 
+	if volumeLsFormat == "json" {
+		// Volumes are shared by every namespace, so containers in all of
+		// them use a volume
+		containers, err := state.NewStateManager().AllNamespaces().ListContainers()
+		if err != nil {
+			return fmt.Errorf("failed to list containers: %v", err)
+		}
+		entries := []volumeListEntry{}
+		for _, vol := range volumes {
+			entries = append(entries, newVolumeListEntry(vol, len(volumeUsers(containers, vol))))
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
 	if len(volumes) == 0 {
 		fmt.Println("No volumes found")
 		return nil
@@ -145,6 +309,58 @@ func runVolumeList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// parseVolumeFilters parses the --filter flags of volume ls into a check
+// for the volumes to list
+func parseVolumeFilters(filters []string) (func(*volume.Volume) bool, error) {
+	var checks []func(*volume.Volume) bool
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, "=")
+		if !ok {
+			return nil, errors.NewValidationError("volume.filter",
+				fmt.Sprintf("invalid filter '%s' (expected KEY=VALUE)", filter))
+		}
+		switch key {
+		case "dangling":
+			dangling, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, errors.NewValidationError("volume.filter",
+					fmt.Sprintf("invalid dangling filter '%s' (expected true or false)", value))
+			}
+			// Volumes are shared by every namespace, so containers in all
+			// of them use a volume
+			containers, err := state.NewStateManager().AllNamespaces().ListContainers()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list containers: %v", err)
+			}
+			used := usedVolumes(containers)
+			checks = append(checks, func(vol *volume.Volume) bool {
+				return used(vol) != dangling
+			})
+		case "driver":
+			checks = append(checks, func(vol *volume.Volume) bool {
+				return vol.Driver == value
+			})
+		case "label":
+			label, want, hasValue := strings.Cut(value, "=")
+			checks = append(checks, func(vol *volume.Volume) bool {
+				got, ok := vol.Labels[label]
+				return ok && (!hasValue || got == want)
+			})
+		default:
+			return nil, errors.NewValidationError("volume.filter",
+				fmt.Sprintf("unsupported filter '%s' (use dangling, driver or label)", key))
+		}
+	}
+	return func(vol *volume.Volume) bool {
+		for _, check := range checks {
+			if !check(vol) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
 func runVolumeCreate(cmd *cobra.Command, args []string) error {
 	volumeName := args[0]
 	logger.Debug("Starting volume create operation for: %s", volumeName)
@@ -192,6 +408,9 @@ func runVolumeCreate(cmd *cobra.Command, args []string) error {
 	vol, err := volManager.CreateVolume(volumeName, volumeDriver, options, labels)
 	if err != nil {
 		logger.Error("Failed to create volume '%s': %v", volumeName, err)
+		if errors.IsType(err, errors.ErrTypeValidation) {
+			return err
+		}
 		return errors.WrapError(err, errors.ErrTypeVolume, "runVolumeCreate",
 			fmt.Sprintf("failed to create volume '%s'", volumeName)).
 			WithContext("volume_name", volumeName).
@@ -219,6 +438,10 @@ func runVolumeRemove(cmd *cobra.Command, args []string) error {
 	for _, volumeName := range args {
 		logger.Debug("Attempting to remove volume: %s", volumeName)
 
+		if err := checkVolumeProtection(volManager, "volume rm", volumeName); err != nil {
+			errorList = append(errorList, err.Error())
+			continue
+		}
 		if err := volManager.RemoveVolume(volumeName, volumeForce); err != nil {
 			logger.Error("Failed to remove volume '%s': %v", volumeName, err)
 			errorList = append(errorList, fmt.Sprintf("failed to remove volume '%s': %v", volumeName, err))
@@ -245,32 +468,37 @@ func runVolumePrune(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	volManager := volume.NewManager()
-
-	// Get confirmation from user
-	fmt.Print("WARNING! This will remove all unused volumes.\nAre you sure you want to continue? [y/N] ")
-	var response string
-	fmt.Scanln(&response)
+	until, err := parsePruneFilters(pruneFilters)
+	if err != nil {
+		return err
+	}
 
-	if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
-		fmt.Println("Operation cancelled")
+	if !confirmPrune("  - all volumes not used by at least one container") {
 		return nil
 	}
 
-	prunedVolumes, err := volManager.PruneVolumes()
+	// Volumes are shared by every namespace
+	containers, err := state.NewStateManager().AllNamespaces().ListContainers()
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %v", err)
+	}
+
+	volManager := volume.NewManager()
+	report, err := volManager.PruneVolumes(volume.PruneOptions{
+		InUse:  volumesToKeep(containers),
+		Until:  until,
+		DryRun: pruneDryRun,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to prune volumes: %v", err)
 	}
 
-	if len(prunedVolumes) == 0 {
+	if len(report.Volumes) == 0 {
 		fmt.Println("No unused volumes found")
-	} else {
-		fmt.Printf("Removed volumes:\n")
-		for _, volumeName := range prunedVolumes {
-			fmt.Printf("  %s\n", volumeName)
-		}
+		return nil
 	}
-
+	printVolumePrune(report)
+	printReclaimed(report.SpaceReclaimed)
 	return nil
 }
 
@@ -281,6 +509,35 @@ func runVolumeInspect(cmd *cobra.Command, args []string) error {
 
 	volManager := volume.NewManager()
 
+	if volumeInspectJSON {
+		containers, err := state.NewStateManager().AllNamespaces().ListContainers()
+		if err != nil {
+			return fmt.Errorf("failed to list containers: %v", err)
+		}
+		entries := []volumeInspectEntry{}
+		for _, volumeName := range args {
+			vol, err := volManager.GetVolume(volumeName)
+			if err != nil {
+				return errors.NewNotFoundError("volume inspect", fmt.Sprintf("volume '%s' not found", volumeName))
+			}
+			users := volumeUsers(containers, vol)
+			entry := volumeInspectEntry{
+				volumeListEntry: newVolumeListEntry(vol, len(users)),
+				Options:         vol.Options,
+				Status:          vol.Status,
+				Size:            volManager.DiskUsage(vol),
+				Containers:      users,
+			}
+			entries = append(entries, entry)
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
 	for i, volumeName := range args {
 		if i > 0 {
 			fmt.Println() // Add spacing between volumes
@@ -297,6 +554,9 @@ func runVolumeInspect(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Mountpoint: %s\n", vol.Mountpoint)
 		fmt.Printf("Created: %s\n", vol.CreatedAt.Format("2006-01-02 15:04:05"))
 		fmt.Printf("Scope: %s\n", vol.Scope)
+		if vol.Anonymous {
+			fmt.Println("Anonymous: true")
+		}
 
 		if len(vol.Labels) > 0 {
 			fmt.Println("Labels:")
@@ -307,8 +567,13 @@ func runVolumeInspect(cmd *cobra.Command, args []string) error {
 
 		if len(vol.Options) > 0 {
 			fmt.Println("Options:")
-			for key, value := range vol.Options {
-				fmt.Printf("  %s=%s\n", key, value)
+			keys := make([]string, 0, len(vol.Options))
+			for key := range vol.Options {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("  %s=%s\n", key, vol.Options[key])
 			}
 		}
 
@@ -334,16 +599,6 @@ func runVolumeRemoveAll(cmd *cobra.Command, args []string) error {
 
 	volManager := volume.NewManager()
 
-	// Get confirmation from user
-	fmt.Print("WARNING! This will remove ALL volumes and their data.\nAre you sure you want to continue? [y/N] ")
-	var response string
-	fmt.Scanln(&response)
-
-	if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
-		fmt.Println("Operation cancelled")
-		return nil
-	}
-
 	// Get list of volumes before removal
 	volumes, err := volManager.ListVolumes()
 	if err != nil {
@@ -355,15 +610,51 @@ func runVolumeRemoveAll(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	// Remove all volumes
-	if err := volManager.RemoveAllVolumes(volumeForce); err != nil {
-		return fmt.Errorf("failed to remove all volumes: %v", err)
+	if volumeForce {
+		recordOverride("volume rm-all", fmt.Sprintf("%d volumes", len(volumes)), "skipped typed confirmation with --force")
+	} else if !confirmTyped(fmt.Sprintf("This will remove ALL %d volumes and their data.", len(volumes)), "all volumes") {
+		return nil
 	}
 
-	fmt.Printf("Successfully removed %d volumes:\n", len(volumes))
+	var removed []string
+	var errorList []string
 	for _, vol := range volumes {
-		fmt.Printf("  %s\n", vol.Name)
+		if isProtected(vol.Labels) {
+			if !overrideProtection {
+				fmt.Printf("Skipping protected volume %s\n", vol.Name)
+				continue
+			}
+			recordOverride("volume rm-all", "volume "+vol.Name, "removed protected volume with --override-protection")
+		}
+		if err := volManager.RemoveVolume(vol.Name, volumeForce); err != nil {
+			errorList = append(errorList, fmt.Sprintf("failed to remove volume '%s': %v", vol.Name, err))
+			continue
+		}
+		removed = append(removed, vol.Name)
 	}
 
+	fmt.Printf("Successfully removed %d volumes:\n", len(removed))
+	for _, name := range removed {
+		fmt.Printf("  %s\n", name)
+	}
+
+	if len(errorList) > 0 {
+		return fmt.Errorf("failed to remove all volumes:\n%s", strings.Join(errorList, "\n"))
+	}
+	return nil
+}
+
+// checkVolumeProtection refuses to remove a protected volume unless
+// --override-protection was given, in which case the override is audited
+func checkVolumeProtection(volManager *volume.Manager, action, name string) error {
+	vol, err := volManager.GetVolume(name)
+	if err != nil || !isProtected(vol.Labels) {
+		// Removal reports a missing volume itself
+		return nil
+	}
+	if !overrideProtection {
+		return fmt.Errorf("volume '%s' is protected; use --override-protection to remove it", name)
+	}
+	recordOverride(action, "volume "+name, "removed protected volume with --override-protection")
 	return nil
 }
@@ -405,6 +405,7 @@ Requires=libvirtd.service
 [Service]
 Type=notify
 ExecStart=$INSTALL_DIR/servin daemon
+ExecReload=/bin/kill -HUP \$MAINPID
 Restart=on-failure
 RestartSec=5
 Delegate=yes
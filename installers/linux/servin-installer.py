@@ -520,6 +520,8 @@ Type=simple
 User=servin
 Group=servin
 ExecStart={self.install_dir.get()}/servin daemon --config {self.config_dir.get()}/servin.conf
+ExecReload=/bin/kill -HUP $MAINPID
+KillMode=process
 Restart=on-failure
 RestartSec=5
 
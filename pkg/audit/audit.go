@@ -0,0 +1,102 @@
+// Package audit keeps an append-only record of destructive operations that
+// bypassed a safety interlock, such as a skipped confirmation or the removal
+// of an object labelled protected.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Entry is one record of the audit trail
+type Entry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Namespace string    `json:"namespace,omitempty"`
+	// Action is the command that ran, e.g. "volume rm-all"
+	Action string `json:"action"`
+	// Target names the object acted on
+	Target string `json:"target"`
+	// Override describes the interlock that was bypassed
+	Override string `json:"override"`
+}
+
+// Path returns the file the audit trail is kept in, one JSON entry per line
+func Path() string {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, ".servin", "audit.log")
+	default:
+		return "/var/lib/servin/audit.log"
+	}
+}
+
+// Record appends an entry to the audit trail, filling in the time and user
+func Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if entry.User == "" {
+		entry.User = currentUser()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit trail: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit trail: %v", err)
+	}
+	return nil
+}
+
+// Entries reads the audit trail, oldest first. Lines that cannot be parsed
+// are skipped.
+func Entries() ([]Entry, error) {
+	f, err := os.Open(Path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit trail: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// currentUser names the user running servin, including who invoked sudo
+func currentUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		name = fmt.Sprintf("%s (via sudo from %s)", name, sudoUser)
+	}
+	return name
+}
@@ -0,0 +1,230 @@
+// Package builder manages remote builders: hosts running servin that build
+// images on behalf of this machine. The build context is streamed to the
+// builder over SSH, the build runs there, and the image is brought back or
+// pushed to a registry straight from the builder.
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Local is the name of the built-in builder that builds on this machine
+const Local = "local"
+
+// DefaultCommand is the command that runs servin on a builder
+const DefaultCommand = "servin"
+
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+
+// Builder is a remote host that runs builds
+type Builder struct {
+	Name string `json:"name"`
+	// Endpoint is the builder's address, ssh://[user@]host[:port]
+	Endpoint string `json:"endpoint"`
+	// Command runs servin on the builder, e.g. "sudo servin"
+	Command string    `json:"command,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// configFile is the on-disk list of builders
+type configFile struct {
+	// Current is the builder used when --builder is not given
+	Current  string              `json:"current,omitempty"`
+	Builders map[string]*Builder `json:"builders"`
+}
+
+// Path returns the file builders are stored in. Builders use the current
+// user's SSH configuration and keys, so they are kept per user.
+func Path() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".servin", "builders.json")
+}
+
+// Validate checks that name can be used as a builder name
+func Validate(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid builder name %q: use lowercase letters, digits, '.', '_' or '-' (max 63 characters)", name)
+	}
+	return nil
+}
+
+// ParseEndpoint checks a builder endpoint and returns its SSH destination
+// ([user@]host) and port, which is empty for the default
+func ParseEndpoint(endpoint string) (destination, port string, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" {
+		return "", "", fmt.Errorf("invalid builder endpoint %q: use ssh://[user@]host[:port]", endpoint)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return "", "", fmt.Errorf("invalid builder endpoint %q: paths are not supported", endpoint)
+	}
+	destination = u.Hostname()
+	if u.User != nil {
+		destination = u.User.Username() + "@" + destination
+	}
+	return destination, u.Port(), nil
+}
+
+// IsEndpoint reports whether ref is an endpoint rather than a builder name
+func IsEndpoint(ref string) bool {
+	return strings.Contains(ref, "://")
+}
+
+// List returns the configured builders sorted by name
+func List() ([]*Builder, error) {
+	file, err := load()
+	if err != nil {
+		return nil, err
+	}
+	builders := make([]*Builder, 0, len(file.Builders))
+	for _, b := range file.Builders {
+		builders = append(builders, b)
+	}
+	sort.Slice(builders, func(i, j int) bool { return builders[i].Name < builders[j].Name })
+	return builders, nil
+}
+
+// Get returns a builder by name
+func Get(name string) (*Builder, error) {
+	file, err := load()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := file.Builders[name]
+	if !ok {
+		return nil, fmt.Errorf("builder '%s' not found", name)
+	}
+	return b, nil
+}
+
+// Create adds a builder
+func Create(b *Builder) error {
+	if err := Validate(b.Name); err != nil {
+		return err
+	}
+	if b.Name == Local {
+		return fmt.Errorf("'%s' is the built-in builder", Local)
+	}
+	if _, _, err := ParseEndpoint(b.Endpoint); err != nil {
+		return err
+	}
+
+	file, err := load()
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Builders[b.Name]; ok {
+		return fmt.Errorf("builder '%s' already exists", b.Name)
+	}
+	if b.Created.IsZero() {
+		b.Created = time.Now()
+	}
+	file.Builders[b.Name] = b
+	return save(file)
+}
+
+// Remove deletes a builder. If it was in use, builds go back to Local.
+func Remove(name string) error {
+	file, err := load()
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Builders[name]; !ok {
+		return fmt.Errorf("builder '%s' not found", name)
+	}
+	delete(file.Builders, name)
+	if file.Current == name {
+		file.Current = ""
+	}
+	return save(file)
+}
+
+// Use selects the builder used when --builder is not given
+func Use(name string) error {
+	file, err := load()
+	if err != nil {
+		return err
+	}
+	if _, ok := file.Builders[name]; !ok && name != Local {
+		return fmt.Errorf("builder '%s' not found", name)
+	}
+	file.Current = name
+	if name == Local {
+		file.Current = ""
+	}
+	return save(file)
+}
+
+// Current returns the name of the builder selected with Use, or Local
+func Current() string {
+	file, err := load()
+	if err != nil || file.Current == "" {
+		return Local
+	}
+	if _, ok := file.Builders[file.Current]; !ok {
+		return Local
+	}
+	return file.Current
+}
+
+// Resolve returns the remote builder for a --builder value: a builder name,
+// an ssh:// endpoint, or empty for the current builder. It returns nil when
+// the build should run locally.
+func Resolve(ref string) (*Builder, error) {
+	if ref == "" {
+		ref = Current()
+	}
+	if ref == Local {
+		return nil, nil
+	}
+	if IsEndpoint(ref) {
+		if _, _, err := ParseEndpoint(ref); err != nil {
+			return nil, err
+		}
+		return &Builder{Name: ref, Endpoint: ref}, nil
+	}
+	return Get(ref)
+}
+
+func load() (*configFile, error) {
+	file := &configFile{}
+	data, err := os.ReadFile(Path())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", Path(), err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", Path(), err)
+		}
+	}
+	if file.Builders == nil {
+		file.Builders = make(map[string]*Builder)
+	}
+	return file, nil
+}
+
+// save writes the builder list atomically
+func save(file *configFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,172 @@
+package builder
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"servin/pkg/vfs"
+)
+
+// WriteContext writes the build context in dir to w as a gzipped tarball,
+// leaving out the files its .dockerignore excludes. The build file, given
+// relative to dir, and the .dockerignore itself are always sent.
+func WriteContext(dir, file string, w io.Writer) error {
+	ignore, err := LoadIgnore(dir)
+	if err != nil {
+		return err
+	}
+	file = filepath.ToSlash(filepath.Clean(file))
+	keep := map[string]bool{IgnoreFile: true, file: true}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		if name := filepath.ToSlash(relPath); !keep[name] && ignore.Matches(name) {
+			if info.IsDir() && ignore.SkipDir(name) && !strings.HasPrefix(file, name+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send build context: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExtractContext unpacks a build context tarball, gzipped or not, into dir
+func ExtractContext(r io.Reader, dir string) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to read build context: %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read build context: %v", err)
+		}
+
+		target, err := contextPath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		existing, statErr := os.Lstat(target)
+		if statErr == nil && !existing.IsDir() {
+			// Replace rather than write through what is there, which may be
+			// a symlink out of the context
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		} else if statErr == nil && header.Typeflag != tar.TypeDir {
+			return fmt.Errorf("cannot overwrite directory %s with a file", header.Name)
+		}
+
+		mode := os.FileMode(header.Mode) & os.ModePerm
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %v", header.Name, err)
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// contextPath returns the path in dir of name, an entry of a build context,
+// or "" when the entry would land outside dir: its name leads out with
+// "..", or the directory it is in is reached through a symlink, which would
+// lead wherever the symlink points
+func contextPath(dir, name string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(name))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", nil
+	}
+	clean = path.Clean("/" + clean)
+	parent, err := vfs.ResolvePath(dir, path.Dir(clean), true)
+	if err != nil {
+		return "", err
+	}
+	if parent != filepath.Join(dir, filepath.FromSlash(path.Dir(clean))) {
+		return "", nil
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,81 @@
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// contextTar builds an uncompressed build context from headers, giving each
+// regular file body as its contents
+func contextTar(t *testing.T, headers []*tar.Header, body string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(body))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// TestExtractContextStaysInDir tests that no entry of a build context is
+// written outside the directory it is unpacked to
+func TestExtractContextStaysInDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "context")
+	outside := filepath.Join(root, "outside")
+	if err := os.MkdirAll(filepath.Join(outside, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	victim := filepath.Join(outside, "victim")
+	if err := os.WriteFile(victim, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := []*tar.Header{
+		// A symlink, then a file of the same name written through it
+		{Name: "x", Typeflag: tar.TypeSymlink, Linkname: victim},
+		{Name: "x", Typeflag: tar.TypeReg, Mode: 0644},
+		// A file below a symlinked directory
+		{Name: "d", Typeflag: tar.TypeSymlink, Linkname: outside},
+		{Name: "d/victim", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "d/sub/new", Typeflag: tar.TypeReg, Mode: 0644},
+		// Names leading out of the context
+		{Name: "../outside/victim", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "a/../../outside/escaped", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "Dockerfile", Typeflag: tar.TypeReg, Mode: 0644},
+	}
+	if err := ExtractContext(contextTar(t, headers, "overwritten"), dir); err != nil {
+		t.Fatalf("ExtractContext returned error: %v", err)
+	}
+
+	if data, err := os.ReadFile(victim); err != nil || string(data) != "original" {
+		t.Errorf("file outside the context = %q, %v; want it untouched", data, err)
+	}
+	for _, name := range []string{"sub/new", "escaped"} {
+		if _, err := os.Lstat(filepath.Join(outside, name)); err == nil {
+			t.Errorf("%s was created outside the context", name)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "x")); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("x in the context should be a regular file replacing the symlink: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "Dockerfile")); err != nil || string(data) != "overwritten" {
+		t.Errorf("Dockerfile = %q, %v; want it unpacked", data, err)
+	}
+}
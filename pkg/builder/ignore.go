@@ -0,0 +1,163 @@
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile is the file in the build context root listing the files
+// builds leave out, in the .dockerignore format
+const IgnoreFile = ".dockerignore"
+
+// ignorePattern is one line of an ignore file
+type ignorePattern struct {
+	regexp *regexp.Regexp
+	// exception marks a "!" line, which adds back files an earlier line excluded
+	exception bool
+}
+
+// Ignore matches paths in a build context against the patterns of its
+// .dockerignore. The zero value and nil ignore nothing.
+type Ignore struct {
+	patterns      []ignorePattern
+	hasExceptions bool
+}
+
+// LoadIgnore reads the .dockerignore in the context directory dir. A
+// context without one ignores nothing.
+func LoadIgnore(dir string) (*Ignore, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return &Ignore{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", IgnoreFile, err)
+	}
+	return ParseIgnore(lines)
+}
+
+// ParseIgnore compiles the lines of an ignore file. Blank lines and lines
+// starting with # are skipped; later lines take precedence over earlier ones.
+func ParseIgnore(lines []string) (*Ignore, error) {
+	ignore := &Ignore{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		exception := strings.HasPrefix(line, "!")
+		if exception {
+			line = strings.TrimSpace(line[1:])
+		}
+		pattern := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(line)), "/")
+		if pattern == "" {
+			continue
+		}
+
+		re, err := ignoreRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %v", IgnoreFile, line, err)
+		}
+		ignore.patterns = append(ignore.patterns, ignorePattern{regexp: re, exception: exception})
+		ignore.hasExceptions = ignore.hasExceptions || exception
+	}
+	return ignore, nil
+}
+
+// Matches reports whether the file at rel, a slash-separated path relative
+// to the context root, is excluded. A file is also excluded when a pattern
+// matches one of its parent directories.
+func (ig *Ignore) Matches(rel string) bool {
+	if ig == nil || len(ig.patterns) == 0 {
+		return false
+	}
+	rel = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(rel)), "/")
+	if rel == "" {
+		return false
+	}
+
+	// The path and each of its parents, shortest first
+	parts := strings.Split(rel, "/")
+	candidates := make([]string, len(parts))
+	for i := range parts {
+		candidates[i] = strings.Join(parts[:i+1], "/")
+	}
+
+	excluded := false
+	for _, pattern := range ig.patterns {
+		for _, candidate := range candidates {
+			if pattern.regexp.MatchString(candidate) {
+				excluded = !pattern.exception
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// SkipDir reports whether a directory at rel can be left out whole. With
+// exception patterns a file inside an excluded directory may still be
+// added back, so the directory has to be walked.
+func (ig *Ignore) SkipDir(rel string) bool {
+	return ig != nil && !ig.hasExceptions && ig.Matches(rel)
+}
+
+// ignoreRegexp translates a .dockerignore pattern to a regular expression:
+// * and ? match within one path element, ** matches any number of them,
+// [...] is a character class and \ escapes the next character
+func ignoreRegexp(pattern string) (*regexp.Regexp, error) {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			i++
+			if strings.HasPrefix(pattern[i+1:], "/") {
+				// **/ matches zero or more directories
+				i++
+				re.WriteString("(.*/)?")
+			} else {
+				re.WriteString(".*")
+			}
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// BuildOptions are passed on to servin build on the builder
+type BuildOptions struct {
+	// Args are the servin build flags, without the context
+	Args []string
+	// File is the build file relative to the context, which is sent even
+	// if .dockerignore excludes it
+	File string
+	// Env is set for the remote build, e.g. SOURCE_DATE_EPOCH
+	Env []string
+	// Quiet keeps the remote build output off Stdout
+	Quiet bool
+}
+
+// builtPrefix starts the line servin build prints with the new image's ID
+const builtPrefix = "Successfully built image: "
+
+// Build streams the build context in contextDir to the builder, builds it
+// there and returns the ID of the image built. The build output is copied
+// to stdout.
+func (b *Builder) Build(contextDir string, opts BuildOptions, stdout io.Writer) (string, error) {
+	args := append(append([]string{}, opts.Args...), "-")
+	if opts.Quiet {
+		args = append([]string{"--quiet"}, args...)
+	}
+	cmd, err := b.command(opts.Env, append([]string{"build"}, args...)...)
+	if err != nil {
+		return "", err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(WriteContext(contextDir, opts.File, pw))
+	}()
+	defer pr.Close()
+
+	var output bytes.Buffer
+	cmd.Stdin = pr
+	cmd.Stdout = &output
+	if !opts.Quiet {
+		cmd.Stdout = io.MultiWriter(stdout, &output)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("build on %s failed: %v", b.Endpoint, err)
+	}
+
+	// A quiet build prints only the ID
+	var imageID string
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if opts.Quiet && line != "" {
+			imageID = line
+		} else if id, ok := strings.CutPrefix(line, builtPrefix); ok {
+			imageID = id
+		}
+	}
+	if imageID == "" {
+		return "", fmt.Errorf("build on %s did not report an image ID", b.Endpoint)
+	}
+	return imageID, nil
+}
+
+// Save writes the image ref on the builder to w as a docker-archive
+func (b *Builder) Save(ref string, w io.Writer) error {
+	cmd, err := b.command(nil, "save", ref)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to fetch %s from %s: %v", ref, b.Endpoint, err)
+	}
+	return nil
+}
+
+// Push pushes the image ref from the builder to its registry
+func (b *Builder) Push(ref string, stdout io.Writer) error {
+	cmd, err := b.command(nil, "image", "push", ref)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("push from %s failed: %v", b.Endpoint, err)
+	}
+	return nil
+}
+
+// Check makes sure the builder can be reached and runs servin
+func (b *Builder) Check() error {
+	cmd, err := b.command(nil, "help")
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot reach builder at %s: %v: %s", b.Endpoint, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// command returns the ssh command running servin with args on the builder.
+// The remote shell sees one command line, so every argument is quoted.
+func (b *Builder) command(env []string, args ...string) (*exec.Cmd, error) {
+	destination, port, err := ParseEndpoint(b.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	servin := b.Command
+	if servin == "" {
+		servin = DefaultCommand
+	}
+
+	var remote []string
+	if len(env) > 0 {
+		remote = append(remote, "env")
+		for _, kv := range env {
+			remote = append(remote, shellQuote(kv))
+		}
+	}
+	// The command is user-supplied shell, e.g. "sudo servin"
+	remote = append(remote, servin)
+	for _, arg := range args {
+		remote = append(remote, shellQuote(arg))
+	}
+
+	sshArgs := []string{}
+	if port != "" {
+		sshArgs = append(sshArgs, "-p", port)
+	}
+	sshArgs = append(sshArgs, "--", destination, strings.Join(remote, " "))
+	return exec.Command("ssh", sshArgs...), nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
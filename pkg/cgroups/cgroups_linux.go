@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 )
@@ -54,6 +55,95 @@ func (c *CGroup) SetCPULimit(shares int) error {
 	return writeToFile(cpuPath, strconv.Itoa(shares))
 }
 
+// cpuPeriod is the CFS scheduling period CPU quotas are expressed in (100ms)
+const cpuPeriod = 100000
+
+// SetCPUQuota limits the container to the given number of CPUs (e.g. 1.5)
+func (c *CGroup) SetCPUQuota(cpus float64) error {
+	cpuDir := filepath.Join("/sys/fs/cgroup", "cpu", "servin", c.ContainerID)
+	if err := writeToFile(filepath.Join(cpuDir, "cpu.cfs_period_us"), strconv.Itoa(cpuPeriod)); err != nil {
+		return err
+	}
+	quota := int64(cpus * cpuPeriod)
+	if quota < 1000 {
+		quota = 1000 // the kernel's minimum quota
+	}
+	return writeToFile(filepath.Join(cpuDir, "cpu.cfs_quota_us"), strconv.FormatInt(quota, 10))
+}
+
+// SetCpuset pins the container to the given CPUs and memory nodes. Empty
+// values inherit the parent cgroup's set. The cpuset cgroup is only created
+// when pinning is requested.
+func (c *CGroup) SetCpuset(cpus, mems string) error {
+	parentPath := filepath.Join("/sys/fs/cgroup", "cpuset", "servin")
+	cpusetPath := filepath.Join(parentPath, c.ContainerID)
+	if err := os.MkdirAll(cpusetPath, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup directory %s: %v", cpusetPath, err)
+	}
+
+	// cgroup v1 requires cpus and mems on every level before tasks can join
+	for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+		if err := inheritCpuset(parentPath, file); err != nil {
+			return err
+		}
+	}
+
+	if cpus == "" {
+		cpus, _ = readFromFile(filepath.Join(parentPath, "cpuset.cpus"))
+	}
+	if mems == "" {
+		mems, _ = readFromFile(filepath.Join(parentPath, "cpuset.mems"))
+	}
+
+	if err := writeToFile(filepath.Join(cpusetPath, "cpuset.cpus"), strings.TrimSpace(cpus)); err != nil {
+		return fmt.Errorf("failed to set cpuset.cpus: %v", err)
+	}
+	if err := writeToFile(filepath.Join(cpusetPath, "cpuset.mems"), strings.TrimSpace(mems)); err != nil {
+		return fmt.Errorf("failed to set cpuset.mems: %v", err)
+	}
+
+	return nil
+}
+
+// EffectiveCpuset returns the CPUs and memory nodes the container can actually use
+func (c *CGroup) EffectiveCpuset() (cpus, mems string, err error) {
+	cpusetPath := filepath.Join("/sys/fs/cgroup", "cpuset", "servin", c.ContainerID)
+
+	// Kernels without effective_* files report the configured sets
+	for _, name := range []string{"cpuset.effective_cpus", "cpuset.cpus"} {
+		if cpus, err = readFromFile(filepath.Join(cpusetPath, name)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+	for _, name := range []string{"cpuset.effective_mems", "cpuset.mems"} {
+		if mems, err = readFromFile(filepath.Join(cpusetPath, name)); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(cpus), strings.TrimSpace(mems), nil
+}
+
+// inheritCpuset copies a cpuset file from the root cgroup into dir if it is empty
+func inheritCpuset(dir, file string) error {
+	path := filepath.Join(dir, file)
+	if current, err := readFromFile(path); err == nil && strings.TrimSpace(current) != "" {
+		return nil
+	}
+
+	value, err := readFromFile(filepath.Join(filepath.Dir(dir), file))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return writeToFile(path, strings.TrimSpace(value))
+}
+
 // SetPIDLimit sets the maximum number of processes
 func (c *CGroup) SetPIDLimit(max int) error {
 	pidsPath := filepath.Join("/sys/fs/cgroup", "pids", "servin", c.ContainerID, "pids.max")
@@ -72,6 +162,14 @@ func (c *CGroup) AddProcess(pid int) error {
 		}
 	}
 
+	// The cpuset cgroup only exists for pinned containers
+	cpusetTasks := filepath.Join("/sys/fs/cgroup", "cpuset", "servin", c.ContainerID, "tasks")
+	if _, err := os.Stat(cpusetTasks); err == nil {
+		if err := writeToFile(cpusetTasks, pidStr); err != nil {
+			return fmt.Errorf("failed to add process %d to cpuset cgroup: %v", pid, err)
+		}
+	}
+
 	fmt.Printf("Added process %d to cgroups\n", pid)
 	return nil
 }
@@ -103,7 +201,7 @@ func (c *CGroup) GetStats() (map[string]string, error) {
 
 // Cleanup removes the cgroup directories
 func (c *CGroup) Cleanup() error {
-	subsystems := []string{"memory", "cpu", "pids"}
+	subsystems := []string{"memory", "cpu", "pids", "cpuset"}
 
 	for _, subsystem := range subsystems {
 		subsystemPath := filepath.Join("/sys/fs/cgroup", subsystem, "servin", c.ContainerID)
@@ -147,6 +245,31 @@ func ParseMemoryString(memStr string) (int64, error) {
 	return int64(num * float64(multiplier)), nil
 }
 
+// onlineCPUs returns the IDs of the host's online CPUs
+func onlineCPUs() []int {
+	if data, err := readFromFile("/sys/devices/system/cpu/online"); err == nil {
+		if ids, err := ParseCPUSet(data); err == nil {
+			return ids
+		}
+	}
+
+	ids := make([]int, runtime.NumCPU())
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
+
+// onlineMemoryNodes returns the IDs of the host's online NUMA nodes
+func onlineMemoryNodes() []int {
+	if data, err := readFromFile("/sys/devices/system/node/online"); err == nil {
+		if ids, err := ParseCPUSet(data); err == nil {
+			return ids
+		}
+	}
+	return []int{0}
+}
+
 // Helper functions
 func writeToFile(path, content string) error {
 	return os.WriteFile(path, []byte(content), 0644)
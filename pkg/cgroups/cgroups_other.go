@@ -2,7 +2,10 @@
 
 package cgroups
 
-import "fmt"
+import (
+	"fmt"
+	"runtime"
+)
 
 // CGroup manages container resource limits (placeholder for non-Linux)
 type CGroup struct {
@@ -33,11 +36,26 @@ func (c *CGroup) SetCPULimit(shares int) error {
 	return fmt.Errorf("cgroups are only supported on Linux")
 }
 
+// SetCPUQuota returns an error on non-Linux platforms
+func (c *CGroup) SetCPUQuota(cpus float64) error {
+	return fmt.Errorf("cgroups are only supported on Linux")
+}
+
 // SetPIDLimit returns an error on non-Linux platforms
 func (c *CGroup) SetPIDLimit(max int) error {
 	return fmt.Errorf("cgroups are only supported on Linux")
 }
 
+// SetCpuset returns an error on non-Linux platforms
+func (c *CGroup) SetCpuset(cpus, mems string) error {
+	return fmt.Errorf("cgroups are only supported on Linux")
+}
+
+// EffectiveCpuset returns an error on non-Linux platforms
+func (c *CGroup) EffectiveCpuset() (string, string, error) {
+	return "", "", fmt.Errorf("cgroups are only supported on Linux")
+}
+
 // AddProcess returns an error on non-Linux platforms
 func (c *CGroup) AddProcess(pid int) error {
 	return fmt.Errorf("cgroups are only supported on Linux")
@@ -57,3 +75,17 @@ func (c *CGroup) Cleanup() error {
 func ParseMemoryString(memStr string) (int64, error) {
 	return 0, fmt.Errorf("memory parsing not implemented for non-Linux")
 }
+
+// onlineCPUs returns the IDs of the host's CPUs
+func onlineCPUs() []int {
+	ids := make([]int, runtime.NumCPU())
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
+
+// onlineMemoryNodes reports a single memory node on non-Linux platforms
+func onlineMemoryNodes() []int {
+	return []int{0}
+}
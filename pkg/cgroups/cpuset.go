@@ -0,0 +1,97 @@
+package cgroups
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseCPUSet parses a cpuset list such as "0-3,6" into sorted, de-duplicated IDs
+func ParseCPUSet(spec string) ([]int, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty cpuset")
+	}
+
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		start, end := part, part
+		if lo, hi, isRange := strings.Cut(part, "-"); isRange {
+			start, end = lo, hi
+		}
+
+		first, err := strconv.Atoi(start)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid cpuset %q", spec)
+		}
+		last, err := strconv.Atoi(end)
+		if err != nil || last < first {
+			return nil, fmt.Errorf("invalid cpuset %q", spec)
+		}
+
+		for id := first; id <= last; id++ {
+			seen[id] = true
+		}
+	}
+
+	ids := make([]int, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// ValidateCpuset checks that the requested CPUs and memory nodes exist and are
+// online on this host
+func ValidateCpuset(cpus, mems string) error {
+	if cpus != "" {
+		if err := validateAgainst(cpus, onlineCPUs(), "CPU"); err != nil {
+			return err
+		}
+	}
+	if mems != "" {
+		if err := validateAgainst(mems, onlineMemoryNodes(), "memory node"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAgainst(spec string, online []int, kind string) error {
+	ids, err := ParseCPUSet(spec)
+	if err != nil {
+		return err
+	}
+
+	available := make(map[int]bool, len(online))
+	for _, id := range online {
+		available[id] = true
+	}
+	for _, id := range ids {
+		if !available[id] {
+			return fmt.Errorf("%s %d is not available on this host (online: %s)", kind, id, formatCPUSet(online))
+		}
+	}
+	return nil
+}
+
+// formatCPUSet renders IDs in cpuset list form, collapsing consecutive runs
+func formatCPUSet(ids []int) string {
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
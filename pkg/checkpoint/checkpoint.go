@@ -0,0 +1,140 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metadataFile holds the checkpoint description inside each checkpoint directory
+const metadataFile = "checkpoint.json"
+
+// rootfsArchive holds a copy of the container's root filesystem so it can be
+// restored after the container's own files have been cleaned up
+const rootfsArchive = "rootfs.tar"
+
+// Checkpoint describes a saved container process image
+type Checkpoint struct {
+	Name         string    `json:"name"`
+	ContainerID  string    `json:"container_id"`
+	Image        string    `json:"image"`
+	PID          int       `json:"pid"`
+	LeaveRunning bool      `json:"leave_running"`
+	Created      time.Time `json:"created"`
+}
+
+// Manager stores container checkpoints
+type Manager struct {
+	baseDir string
+}
+
+// NewManager creates a new checkpoint manager
+func NewManager() *Manager {
+	var baseDir string
+
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		homeDir, _ := os.UserHomeDir()
+		baseDir = filepath.Join(homeDir, ".servin", "checkpoints")
+	default:
+		baseDir = "/var/lib/servin/checkpoints"
+	}
+
+	return &Manager{baseDir: baseDir}
+}
+
+// Dir returns the directory holding the named checkpoint of a container
+func (m *Manager) Dir(containerID, name string) string {
+	return filepath.Join(m.baseDir, containerID, name)
+}
+
+// List returns the checkpoints of a container, oldest first
+func (m *Manager) List(containerID string) ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(filepath.Join(m.baseDir, containerID))
+	if os.IsNotExist(err) {
+		return []*Checkpoint{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints: %v", err)
+	}
+
+	checkpoints := []*Checkpoint{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if cp, err := m.Get(containerID, entry.Name()); err == nil {
+			checkpoints = append(checkpoints, cp)
+		}
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Created.Before(checkpoints[j].Created) })
+	return checkpoints, nil
+}
+
+// Get loads a checkpoint by name
+func (m *Manager) Get(containerID, name string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(m.Dir(containerID, name), metadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint '%s' not found for container %s", name, shortID(containerID))
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint '%s': %v", name, err)
+	}
+	return &cp, nil
+}
+
+// Latest returns the most recent checkpoint of a container
+func (m *Manager) Latest(containerID string) (*Checkpoint, error) {
+	checkpoints, err := m.List(containerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, fmt.Errorf("container %s has no checkpoints", shortID(containerID))
+	}
+	return checkpoints[len(checkpoints)-1], nil
+}
+
+// Remove deletes a checkpoint
+func (m *Manager) Remove(containerID, name string) error {
+	if _, err := m.Get(containerID, name); err != nil {
+		return err
+	}
+	return os.RemoveAll(m.Dir(containerID, name))
+}
+
+// RemoveAll deletes every checkpoint of a container
+func (m *Manager) RemoveAll(containerID string) error {
+	return os.RemoveAll(filepath.Join(m.baseDir, containerID))
+}
+
+func (m *Manager) save(cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %v", err)
+	}
+	return os.WriteFile(filepath.Join(m.Dir(cp.ContainerID, cp.Name), metadataFile), data, 0644)
+}
+
+// validateName rejects checkpoint names that would escape the checkpoint directory
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("invalid checkpoint name: %q", name)
+	}
+	return nil
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,154 @@
+//go:build linux
+
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/rootfs"
+	"servin/pkg/state"
+)
+
+// commonCRIUArgs are passed to both dump and restore so the process image is
+// restored with the same resources it was dumped with
+var commonCRIUArgs = []string{"--shell-job", "--tcp-established", "--file-locks", "--ext-unix-sk"}
+
+// Create dumps the container's process tree with CRIU. Unless leaveRunning is
+// set the container's processes are stopped once the dump completes.
+func (m *Manager) Create(cs *state.ContainerState, name string, leaveRunning bool) (*Checkpoint, error) {
+	criu, err := criuPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = "checkpoint-" + time.Now().Format("20060102-150405")
+	}
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	if cs.PID <= 0 {
+		return nil, fmt.Errorf("container %s has no running process", shortID(cs.ID))
+	}
+
+	dir := m.Dir(cs.ID, name)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("checkpoint '%s' already exists for container %s", name, shortID(cs.ID))
+	}
+	imagesDir := filepath.Join(dir, "images")
+	if err := os.MkdirAll(imagesDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %v", err)
+	}
+
+	// Archive the root filesystem first: it is removed once the dumped process exits
+	rootPath := rootfs.New(cs.ID, cs.Image).RootPath
+	if _, err := os.Stat(rootPath); err == nil {
+		if output, err := exec.Command("tar", "-C", rootPath, "-cf", filepath.Join(dir, rootfsArchive), ".").CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to archive root filesystem: %v: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	args := []string{"dump", "--tree", strconv.Itoa(cs.PID), "--images-dir", imagesDir, "--log-file", "dump.log"}
+	args = append(args, commonCRIUArgs...)
+	if leaveRunning {
+		args = append(args, "--leave-running")
+	}
+
+	if output, err := exec.Command(criu, args...).CombinedOutput(); err != nil {
+		logTail := criuLogTail(filepath.Join(imagesDir, "dump.log"))
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("criu dump failed: %v: %s%s", err, strings.TrimSpace(string(output)), logTail)
+	}
+
+	cp := &Checkpoint{
+		Name:         name,
+		ContainerID:  cs.ID,
+		Image:        cs.Image,
+		PID:          cs.PID,
+		LeaveRunning: leaveRunning,
+		Created:      time.Now(),
+	}
+	if err := m.save(cp); err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// Restore starts the container's processes from a checkpoint and returns the
+// PID of the restored init process
+func (m *Manager) Restore(cs *state.ContainerState, cp *Checkpoint) (int, error) {
+	criu, err := criuPath()
+	if err != nil {
+		return 0, err
+	}
+
+	dir := m.Dir(cp.ContainerID, cp.Name)
+	imagesDir := filepath.Join(dir, "images")
+
+	// Recreate the root filesystem if the container's files were cleaned up
+	rootPath := rootfs.New(cs.ID, cs.Image).RootPath
+	archive := filepath.Join(dir, rootfsArchive)
+	if _, err := os.Stat(rootPath); os.IsNotExist(err) {
+		if _, err := os.Stat(archive); err != nil {
+			return 0, fmt.Errorf("root filesystem %s is missing and the checkpoint has no copy of it", rootPath)
+		}
+		if err := os.MkdirAll(rootPath, 0755); err != nil {
+			return 0, fmt.Errorf("failed to create root filesystem: %v", err)
+		}
+		if output, err := exec.Command("tar", "-C", rootPath, "-xf", archive).CombinedOutput(); err != nil {
+			return 0, fmt.Errorf("failed to extract root filesystem: %v: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	pidFile := filepath.Join(dir, "restore.pid")
+	os.Remove(pidFile)
+
+	args := []string{"restore", "--images-dir", imagesDir, "--log-file", "restore.log",
+		"--restore-detached", "--pidfile", pidFile, "--root", rootPath}
+	args = append(args, commonCRIUArgs...)
+
+	if output, err := exec.Command(criu, args...).CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("criu restore failed: %v: %s%s", err, strings.TrimSpace(string(output)),
+			criuLogTail(filepath.Join(imagesDir, "restore.log")))
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read restored PID: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid restored PID: %v", err)
+	}
+
+	return pid, nil
+}
+
+func criuPath() (string, error) {
+	path, err := exec.LookPath("criu")
+	if err != nil {
+		return "", fmt.Errorf("criu not found in PATH: install CRIU to checkpoint and restore containers")
+	}
+	return path, nil
+}
+
+// criuLogTail returns the last lines of a CRIU log for error messages
+func criuLogTail(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) > 5 {
+		lines = lines[len(lines)-5:]
+	}
+	return "\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package checkpoint
+
+import (
+	"fmt"
+
+	"servin/pkg/state"
+)
+
+// Create is not supported on non-Linux platforms
+func (m *Manager) Create(cs *state.ContainerState, name string, leaveRunning bool) (*Checkpoint, error) {
+	return nil, fmt.Errorf("checkpoint requires CRIU and is only supported on Linux")
+}
+
+// Restore is not supported on non-Linux platforms
+func (m *Manager) Restore(cs *state.ContainerState, cp *Checkpoint) (int, error) {
+	return 0, fmt.Errorf("restore requires CRIU and is only supported on Linux")
+}
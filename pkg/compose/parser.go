@@ -16,6 +16,9 @@ type ComposeFile struct {
 	Services map[string]ServiceConfig `yaml:"services"`
 	Networks map[string]NetworkConfig `yaml:"networks,omitempty"`
 	Volumes  map[string]VolumeConfig  `yaml:"volumes,omitempty"`
+	// Prefetch lists images the daemon pulls ahead of time, besides the
+	// services' own, when the file is on its pre-pull list
+	Prefetch []string `yaml:"x-prefetch,omitempty"`
 }
 
 // ServiceConfig represents a service configuration
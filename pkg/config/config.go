@@ -0,0 +1,171 @@
+// Package config loads servin's config.yaml. Only daemon-wide settings are
+// read from it so far: the resource limits and security settings every new
+// container starts with unless overridden on the command line, the images
+// the daemon prefetches, the bandwidth limits of downloads and uploads,
+// whether image tags may be moved, and where VM assets are downloaded from.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"servin/pkg/errors"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvVar names the variable that points servin at a config file, taking
+// precedence over the standard locations
+const EnvVar = "SERVIN_CONFIG"
+
+// Config is the content of config.yaml
+type Config struct {
+	Daemon Daemon `yaml:"daemon"`
+}
+
+// Daemon holds the daemon-level settings
+type Daemon struct {
+	ContainerDefaults ContainerDefaults `yaml:"container_defaults"`
+	Prefetch          Prefetch          `yaml:"prefetch"`
+	Transfers         Transfers         `yaml:"transfers"`
+	Images            Images            `yaml:"images"`
+	VMAssets          VMAssets          `yaml:"vm_assets"`
+}
+
+// ContainerDefaults are applied to every new container unless the matching
+// run flag is given
+type ContainerDefaults struct {
+	Memory  string `yaml:"memory"`  // e.g. "512m"
+	CPUs    string `yaml:"cpus"`    // e.g. "1.5"
+	Restart string `yaml:"restart"` // restart policy, as with --restart
+
+	// Seccomp is the profile name ("default" or "unconfined")
+	Seccomp         string   `yaml:"seccomp"`
+	NoNewPrivileges bool     `yaml:"no_new_privileges"`
+	Ulimits         []Ulimit `yaml:"ulimits"`
+}
+
+// Prefetch is the pre-pull list: images the daemon pulls in the background,
+// so the first container run from them does not wait for a pull
+type Prefetch struct {
+	Images []string `yaml:"images"`
+	// ComposeFiles adds the images of these compose files' services and
+	// their x-prefetch lists
+	ComposeFiles []string `yaml:"compose_files"`
+	// Interval is how often missing images are looked for (default 6h)
+	Interval string `yaml:"interval"`
+	// Window restricts pulls to off-peak hours in local time, e.g.
+	// "22:00-06:00"; empty pulls at any time
+	Window   string `yaml:"window"`
+	Platform string `yaml:"platform"` // e.g. "linux/amd64"
+}
+
+// Transfers are the default bandwidth limits of registry pulls and pushes
+// and VM asset downloads, e.g. "5MB/s". The --limit-rate flag of a command
+// overrides them; empty means no limit.
+type Transfers struct {
+	DownloadRate string `yaml:"download_rate"`
+	UploadRate   string `yaml:"upload_rate"`
+}
+
+// Images are the settings of the local image store
+type Images struct {
+	// ImmutableTags refuses to point an existing tag at a different image
+	// when tagging, building, committing or loading, unless --force is given.
+	// Pulls still update tags to match the registry.
+	ImmutableTags bool `yaml:"immutable_tags"`
+}
+
+// VMAssets are the settings of the kernel, initramfs, ISO and rootfs
+// downloads VMs are created from, which are kept in ~/.servin/cache
+type VMAssets struct {
+	// Mirror replaces https://dl-cdn.alpinelinux.org/alpine in asset URLs
+	Mirror string `yaml:"mirror"`
+	// Offline never downloads: VMs are created from the cache alone
+	Offline bool `yaml:"offline"`
+	// Checksums are the SHA256 digests of assets, by their path in the
+	// cache as 'servin vm cache ls' shows it
+	Checksums map[string]string `yaml:"checksums"`
+}
+
+// Ulimit is a default resource limit. Soft and Hard are numbers or
+// "unlimited"; an empty Hard uses the soft limit.
+type Ulimit struct {
+	Name string `yaml:"name"`
+	Soft string `yaml:"soft"`
+	Hard string `yaml:"hard"`
+}
+
+// Spec returns the limit in the form used by --ulimit (name=soft[:hard])
+func (u Ulimit) Spec() string {
+	if u.Hard == "" {
+		return fmt.Sprintf("%s=%s", u.Name, u.Soft)
+	}
+	return fmt.Sprintf("%s=%s:%s", u.Name, u.Soft, u.Hard)
+}
+
+// Paths returns the standard config file locations, highest priority first:
+// the system file, the user file and servin.yaml in the working directory
+func Paths() []string {
+	var paths []string
+	homeDir, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "windows":
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		paths = append(paths, filepath.Join(programData, "Servin", "config.yaml"))
+		if homeDir != "" {
+			paths = append(paths, filepath.Join(homeDir, ".servin", "config.yaml"))
+		}
+	case "darwin":
+		paths = append(paths, "/etc/servin/config.yaml")
+		if homeDir != "" {
+			paths = append(paths, filepath.Join(homeDir, "Library", "Application Support", "servin", "config.yaml"))
+		}
+	default:
+		paths = append(paths, "/etc/servin/config.yaml")
+		if homeDir != "" {
+			paths = append(paths, filepath.Join(homeDir, ".config", "servin", "config.yaml"))
+		}
+	}
+
+	return append(paths, "servin.yaml")
+}
+
+// Load reads the config file named by $SERVIN_CONFIG, or else the first of
+// Paths that exists, and returns it with the path it was read from. Without
+// a config file it returns an empty config and path.
+func Load() (*Config, string, error) {
+	if path := os.Getenv(EnvVar); path != "" {
+		cfg, err := loadFile(path)
+		return cfg, path, err
+	}
+
+	for _, path := range Paths() {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		cfg, err := loadFile(path)
+		return cfg, path, err
+	}
+
+	return &Config{}, "", nil
+}
+
+func loadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.NewConfigError("config.Load", fmt.Sprintf("failed to read %s: %v", path, err))
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.NewConfigError("config.Load", fmt.Sprintf("invalid config file %s: %v", path, err))
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,89 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"servin/pkg/image"
+	"servin/pkg/rootfs"
+	"servin/pkg/state"
+)
+
+// commitExcluded are the files servin writes into every container, which
+// belong to the container rather than to an image made from it
+var commitExcluded = []string{"/.old_root", "/proc", "/sys", "/dev", "/etc/resolv.conf", "/etc/hosts", "/etc/hostname"}
+
+// Commit snapshots a running container's writable layer as a new image:
+// the container's image with one more layer holding the files the container
+// added, changed or removed. The image is returned unsaved, without an ID,
+// so the caller can adjust its config before committing it.
+func Commit(cs *state.ContainerState) (*image.Image, error) {
+	rfs := rootfs.New(cs.ID, cs.Image)
+	if _, err := os.Stat(rfs.RootPath); err != nil {
+		// The root filesystem is removed once the container exits
+		return nil, fmt.Errorf("container %s has no root filesystem; only running containers can be committed", cs.ID)
+	}
+
+	imageManager := image.NewManager().InNamespace(cs.Namespace)
+	base, err := imageManager.GetImage(cs.Image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find image %s of container %s: %v", cs.Image, cs.ID, err)
+	}
+
+	changes := image.ContainerChanges{Root: rfs.RootPath, Exclude: append([]string(nil), commitExcluded...)}
+	if _, err := os.Stat(rfs.UpperPath()); err == nil {
+		changes.Upper = rfs.UpperPath()
+	}
+	for _, mount := range cs.Volumes {
+		containerPath, _, _ := strings.Cut(mount, ":")
+		changes.Exclude = append(changes.Exclude, containerPath)
+	}
+
+	epoch, err := image.SourceDateEpoch()
+	if err != nil {
+		return nil, err
+	}
+	layer, err := imageManager.CreateLayerFromContainer(base, changes, image.LayerOptions{Epoch: epoch})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit container %s: %v", cs.ID, err)
+	}
+
+	img := &image.Image{
+		Created:    time.Now(),
+		Size:       base.Size + layer.Size,
+		Layers:     append(append([]string(nil), base.Layers...), layer.Digest),
+		LayerChain: append(append([]string(nil), base.LayerChain...), layer.ChainID),
+		Config:     base.Config,
+		RootFSType: base.RootFSType,
+		Platform:   base.Platform,
+		Metadata: map[string]string{
+			"container":    cs.ID,
+			"parent_image": base.ID,
+		},
+	}
+
+	// The config's slices and maps are copied, so changes to the new image
+	// leave the base image alone
+	img.Config.Env = append([]string(nil), base.Config.Env...)
+	img.Config.Cmd = append([]string(nil), base.Config.Cmd...)
+	img.Config.Entrypoint = append([]string(nil), base.Config.Entrypoint...)
+	img.Config.Shell = append([]string(nil), base.Config.Shell...)
+	img.Config.OnBuild = append([]string(nil), base.Config.OnBuild...)
+	img.Config.ExposedPorts = make(map[string]struct{}, len(base.Config.ExposedPorts))
+	for port := range base.Config.ExposedPorts {
+		img.Config.ExposedPorts[port] = struct{}{}
+	}
+	if len(base.Config.Volumes) > 0 {
+		img.Config.Volumes = make(map[string]struct{}, len(base.Config.Volumes))
+		for path := range base.Config.Volumes {
+			img.Config.Volumes[path] = struct{}{}
+		}
+	}
+	img.Config.Labels = make(map[string]string, len(base.Config.Labels))
+	for key, value := range base.Config.Labels {
+		img.Config.Labels[key] = value
+	}
+	return img, nil
+}
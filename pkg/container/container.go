@@ -3,15 +3,23 @@ package container
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"path/filepath"
+	"os/exec"
+	"strconv"
 	"time"
 
 	"servin/pkg/cgroups"
+	"servin/pkg/health"
+	"servin/pkg/hooks"
+	"servin/pkg/image"
+	"servin/pkg/logs"
 	"servin/pkg/namespaces"
 	"servin/pkg/network"
 	"servin/pkg/rootfs"
+	"servin/pkg/security"
 	"servin/pkg/state"
+	"servin/pkg/telemetry"
 )
 
 // Config represents container configuration
@@ -23,11 +31,64 @@ type Config struct {
 	WorkDir      string
 	Hostname     string
 	Env          map[string]string
-	Volumes      map[string]string
+	Volumes      map[string]string // host path or volume name -> container path, with ":ro" if read-only
 	NetworkMode  string
 	Memory       string
 	CPUs         string
 	PortMappings []network.PortMapping
+
+	// CpusetCpus and CpusetMems pin the container to CPUs and NUMA memory nodes (e.g. "0-3")
+	CpusetCpus string
+	CpusetMems string
+
+	// RestartPolicy is applied by the daemon supervisor once the container exits
+	RestartPolicy string
+
+	// Seccomp is the seccomp profile ("default" or "unconfined"),
+	// NoNewPrivileges sets no_new_privs and Ulimits are resource limits in
+	// the form used by --ulimit; all apply to the container process
+	Seccomp         string
+	NoNewPrivileges bool
+	Ulimits         []string
+
+	// Healthcheck is probed periodically while the container runs
+	Healthcheck *health.Config
+
+	// StopSignal is sent to stop the container, from the image's STOPSIGNAL
+	StopSignal string
+
+	// Hooks run on the host at container lifecycle events
+	Hooks []hooks.Hook
+
+	// Labels are user metadata, such as the protected label
+	Labels map[string]string
+
+	// AnonymousVolumes are the volumes of Volumes created for the container
+	AnonymousVolumes []string
+
+	// NetworkAliases are extra names other containers on the network resolve it by
+	NetworkAliases []string
+
+	// Networks are bridge networks the container is connected to besides
+	// its own, with 'servin network connect'
+	Networks []string
+
+	// Links are containers ("name[:alias]") added to /etc/hosts; LinkEnv also
+	// passes their addresses in legacy <ALIAS>_PORT_* variables
+	Links   []string
+	LinkEnv bool
+
+	// DNS overrides the DNS settings of the container's network; its Hosts
+	// are --add-host entries, with "host-gateway" for the network's gateway
+	DNS *network.DNSConfig
+
+	// Machine registers the container with systemd-machined while it runs,
+	// so machinectl lists it, and forwards its output to the journal
+	Machine bool
+
+	// TTY runs the process on a pseudo-terminal attached to the one servin
+	// runs on; it only applies to foreground runs and is not persisted
+	TTY bool
 }
 
 // Container represents a running container
@@ -42,6 +103,14 @@ type Container struct {
 	StateManager   *state.StateManager
 	NetworkManager *network.NetworkManager
 	ContainerNet   *network.ContainerNetwork
+
+	// publishedPorts are the port mappings with their host ports allocated,
+	// published once the container is attached to its network
+	publishedPorts []network.PortMapping
+
+	// preStartRan is set once pre-start hooks have run, so falling back from
+	// the VM to a native run does not run them twice
+	preStartRan bool
 }
 
 // New creates a new container with the given configuration
@@ -94,14 +163,40 @@ func New(config *Config) (*Container, error) {
 }
 
 // Run starts the container with namespace isolation, filesystem isolation, and resource limits
-func (c *Container) Run() error {
+func (c *Container) Run() (err error) {
 	fmt.Printf("Running container %s (%s)\n", c.Config.Name, c.ID[:12])
 
+	span := telemetry.StartSpan("container.run", nil)
+	span.SetAttribute("container.id", c.ID)
+	span.SetAttribute("container.image", c.Config.Image)
+	defer func() { span.Finish(err) }()
+
+	if err := c.runPreStartHooks(); err != nil {
+		return err
+	}
+
+	// Allocate host ports before anything is set up, so a conflict fails the start
+	if len(c.Config.PortMappings) > 0 {
+		if c.driver() != network.BridgeMode {
+			fmt.Printf("Warning: published ports are ignored on the %s network\n", c.driver())
+		} else if c.publishedPorts, err = AllocatePorts(c.ID, c.Config.PortMappings); err != nil {
+			return err
+		}
+	}
+
 	// Create the container's root filesystem
-	if err := c.RootFS.Create(); err != nil {
+	phase := telemetry.StartSpan("container.rootfs", span)
+	err = c.RootFS.Create()
+	phase.Finish(err)
+	if err != nil {
 		return fmt.Errorf("failed to create rootfs: %v", err)
 	}
 
+	// Record the use for the unused-image report and prune filters
+	if err := c.RootFS.ImageManager.MarkUsed(c.Config.Image); err != nil {
+		fmt.Printf("Warning: failed to record image use: %v\n", err)
+	}
+
 	// Setup filesystem mounts for the container
 	if err := c.RootFS.SetupMounts(); err != nil {
 		fmt.Printf("Warning: failed to setup mounts: %v\n", err)
@@ -113,9 +208,13 @@ func (c *Container) Run() error {
 	}
 
 	// Create cgroups for resource control
+	phase = telemetry.StartSpan("container.cgroups", span)
+	cgroupsCreated := false
 	if err := c.CGroup.Create(); err != nil {
 		fmt.Printf("Warning: failed to create cgroups: %v\n", err)
 	} else {
+		cgroupsCreated = true
+
 		// Set resource limits if specified
 		if c.Config.Memory != "" {
 			if memBytes, err := cgroups.ParseMemoryString(c.Config.Memory); err == nil && memBytes > 0 {
@@ -127,11 +226,26 @@ func (c *Container) Run() error {
 			}
 		}
 
+		if cpus, err := strconv.ParseFloat(c.Config.CPUs, 64); err == nil && cpus > 0 {
+			if err := c.CGroup.SetCPUQuota(cpus); err != nil {
+				fmt.Printf("Warning: failed to set CPU limit: %v\n", err)
+			} else {
+				fmt.Printf("Set CPU limit to %g CPUs\n", cpus)
+			}
+		}
+
 		// Set default PID limit to prevent fork bombs
 		if err := c.CGroup.SetPIDLimit(1024); err != nil {
 			fmt.Printf("Warning: failed to set PID limit: %v\n", err)
 		}
+
+		if c.Config.CpusetCpus != "" || c.Config.CpusetMems != "" {
+			if err := c.CGroup.SetCpuset(c.Config.CpusetCpus, c.Config.CpusetMems); err != nil {
+				fmt.Printf("Warning: failed to set CPU affinity: %v\n", err)
+			}
+		}
 	}
+	phase.Finish(nil)
 
 	// Clean up on exit
 	defer func() {
@@ -148,20 +262,37 @@ func (c *Container) Run() error {
 		}
 	}()
 
-	// Set up networking if not in host mode
-	if c.Config.NetworkMode != "host" && c.Config.NetworkMode != "none" {
-		containerNet, err := c.NetworkManager.CreateVethPair(c.ID)
+	// Set up networking unless the container shares the host's or has none
+	driver := c.driver()
+	if driver == network.BridgeMode {
+		phase = telemetry.StartSpan("container.network", span)
+		containerNet, err := c.NetworkManager.CreateVethPair(c.ID, c.Config.NetworkMode)
+		phase.Finish(err)
 		if err != nil {
 			fmt.Printf("Warning: failed to create network interface: %v\n", err)
 		} else {
 			c.ContainerNet = containerNet
 			fmt.Printf("Created network interface for container\n")
+
+			// Other containers on the network resolve this one by its address
+			if c.StateManager != nil && containerNet.IP != nil {
+				if err := c.StateManager.UpdateContainerIP(c.ID, containerNet.IP.String(), ipString(containerNet.IP6)); err != nil {
+					fmt.Printf("Warning: failed to record container address: %v\n", err)
+				}
+			}
 		}
 	}
 
+	// Configure name resolution from the network the container is attached to
+	if err := c.setupDNS(); err != nil {
+		fmt.Printf("Warning: failed to configure DNS: %v\n", err)
+	}
+
 	// Create log directory for container output
-	sm := state.NewStateManager()
-	logDir := filepath.Join(filepath.Dir(sm.GetStateDir()), "logs", c.ID)
+	logDir := logs.Dir(c.ID)
+
+	// Health probes run from when the container process starts until it exits
+	stopHealthMonitor := func() {}
 
 	// Create namespace configuration
 	nsConfig := &namespaces.ContainerConfig{
@@ -171,31 +302,70 @@ func (c *Container) Run() error {
 		WorkDir:     c.Config.WorkDir,
 		LogDir:      logDir,
 		RootFS:      c.RootPath + "/rootfs", // Pass the rootfs path
-		Environment: c.Config.Env,           // Pass environment variables
+		Environment: c.environment(),        // Pass environment variables
+		TTY:         c.Config.TTY,
+		Security: security.Options{
+			Seccomp:         c.Config.Seccomp,
+			NoNewPrivileges: c.Config.NoNewPrivileges,
+			Ulimits:         c.Config.Ulimits,
+		},
+		OnStart: func(pid int) {
+			// Record the PID and place the process in its cgroups so usage can be tracked
+			if err := c.UpdatePID(pid); err != nil {
+				fmt.Printf("Warning: failed to record container PID: %v\n", err)
+			}
+			if cgroupsCreated {
+				if err := c.CGroup.AddProcess(pid); err != nil {
+					fmt.Printf("Warning: failed to add process to cgroups: %v\n", err)
+				}
+			}
+			c.attachNetwork(pid)
+			c.registerMachine(pid)
+			stopHealthMonitor = c.startHealthMonitor(pid)
+			c.runHooks(hooks.PostStart)
+		},
 		OnExit: func(err error) {
-			// Update container status when process exits
-			c.UpdateStatus("exited")
+			stopHealthMonitor()
+			c.unregisterMachine()
+			c.disconnectNetworks()
+
+			// Record the exit code so restart policies can tell failures apart
+			c.Status = state.StatusExited
+			if c.StateManager != nil {
+				c.StateManager.UpdateContainerExit(c.ID, exitCodeFromError(err))
+			}
 			if err != nil {
 				fmt.Printf("Container %s exited with error: %v\n", c.Config.Name, err)
 			} else {
 				fmt.Printf("Container %s exited successfully\n", c.Config.Name)
 			}
+			c.runHooks(hooks.PostStop)
 		},
 		Namespaces: []namespaces.NamespaceFlags{
 			namespaces.CLONE_NEWPID, // New PID namespace
 			namespaces.CLONE_NEWUTS, // New UTS namespace (hostname)
 			namespaces.CLONE_NEWIPC, // New IPC namespace
 			namespaces.CLONE_NEWNS,  // New mount namespace
-			namespaces.CLONE_NEWNET, // New network namespace
 		},
 	}
+	if driver != network.HostMode {
+		nsConfig.Namespaces = append(nsConfig.Namespaces, namespaces.CLONE_NEWNET) // New network namespace
+	}
+
+	if c.Config.Machine {
+		closeJournal := c.forwardToJournal(nsConfig)
+		defer closeJournal()
+	}
 
 	c.Status = "running"
 	c.UpdateStatus("running")
 
-	err := namespaces.CreateContainer(nsConfig)
+	phase = telemetry.StartSpan("container.process", span)
+	err = namespaces.CreateContainer(nsConfig)
+	phase.Finish(err)
 
 	if err != nil {
+		stopHealthMonitor()
 		c.UpdateStatus("exited")
 		return fmt.Errorf("container failed: %v", err)
 	}
@@ -205,6 +375,96 @@ func (c *Container) Run() error {
 	return nil
 }
 
+// attachNetwork wires the network namespace of the container process pid
+// before its command runs: loopback, and the container's veth pair with
+// its published ports and the networks it was connected to unless it has
+// no network. A container whose network cannot be set up runs with
+// loopback only.
+func (c *Container) attachNetwork(pid int) {
+	if c.driver() == network.HostMode {
+		return
+	}
+	if err := network.SetupLoopback(pid); err != nil {
+		fmt.Printf("Warning: failed to bring up loopback: %v\n", err)
+	}
+	if c.ContainerNet == nil {
+		return
+	}
+
+	if err := c.NetworkManager.AttachContainerToNetwork(c.ContainerNet, pid); err != nil {
+		fmt.Printf("Warning: failed to attach container to network: %v\n", err)
+		return
+	}
+	var published []network.PortMapping
+	for _, mapping := range c.publishedPorts {
+		if err := c.NetworkManager.SetupPortMapping(c.ContainerNet, mapping); err != nil {
+			fmt.Printf("Warning: failed to publish port %d: %v\n", mapping.HostPort, err)
+			continue
+		}
+		published = append(published, mapping)
+	}
+	if c.StateManager != nil && len(published) > 0 {
+		if err := c.StateManager.UpdateContainerPorts(c.ID, published); err != nil {
+			fmt.Printf("Warning: failed to record published ports: %v\n", err)
+		}
+	}
+	c.connectNetworks(pid)
+}
+
+// FromState rebuilds a container from its persisted state so it can be started again
+func FromState(cs *state.ContainerState) *Container {
+	config := &Config{
+		Image:         cs.Image,
+		Command:       cs.Command,
+		Args:          cs.Args,
+		Name:          cs.Name,
+		WorkDir:       cs.WorkDir,
+		Hostname:      cs.Hostname,
+		Env:           cs.Env,
+		Volumes:       cs.Volumes,
+		NetworkMode:   cs.NetworkMode,
+		Memory:        cs.Memory,
+		CPUs:          cs.CPUs,
+		CpusetCpus:    cs.CpusetCpus,
+		CpusetMems:    cs.CpusetMems,
+		PortMappings:  cs.PortMappings,
+		RestartPolicy: cs.RestartPolicy,
+		Healthcheck:   cs.Healthcheck,
+
+		Seccomp:         cs.Seccomp,
+		NoNewPrivileges: cs.NoNewPrivileges,
+		Ulimits:         cs.Ulimits,
+		StopSignal:      cs.StopSignal,
+		Hooks:           cs.Hooks,
+		Labels:          cs.Labels,
+
+		AnonymousVolumes: cs.AnonymousVolumes,
+
+		NetworkAliases: cs.NetworkAliases,
+		Networks:       cs.Networks,
+		Links:          cs.Links,
+		LinkEnv:        cs.LinkEnv,
+		DNS:            cs.DNS,
+		Machine:        cs.Machine,
+	}
+
+	// The container may belong to another namespace than the active one (e.g. in the daemon)
+	rfs := rootfs.New(cs.ID, cs.Image)
+	rfs.ImageManager = image.NewManager().InNamespace(cs.Namespace)
+
+	return &Container{
+		ID:             cs.ID,
+		Config:         config,
+		PID:            cs.PID,
+		Status:         cs.Status,
+		RootPath:       cs.RootPath,
+		RootFS:         rfs,
+		CGroup:         cgroups.New(cs.ID),
+		StateManager:   state.NewStateManager().InNamespace(cs.Namespace),
+		NetworkManager: network.NewNetworkManager(),
+	}
+}
+
 // GetStats returns container resource usage statistics
 func (c *Container) GetStats() (map[string]string, error) {
 	return c.CGroup.GetStats()
@@ -234,6 +494,27 @@ func (c *Container) SaveState() error {
 		PortMappings: c.Config.PortMappings,
 		Memory:       c.Config.Memory,
 		CPUs:         c.Config.CPUs,
+		CpusetCpus:   c.Config.CpusetCpus,
+		CpusetMems:   c.Config.CpusetMems,
+
+		RestartPolicy: c.Config.RestartPolicy,
+		Healthcheck:   c.Config.Healthcheck,
+
+		Seccomp:         c.Config.Seccomp,
+		NoNewPrivileges: c.Config.NoNewPrivileges,
+		Ulimits:         c.Config.Ulimits,
+		StopSignal:      c.Config.StopSignal,
+		Hooks:           c.Config.Hooks,
+		Labels:          c.Config.Labels,
+
+		AnonymousVolumes: c.Config.AnonymousVolumes,
+
+		NetworkAliases: c.Config.NetworkAliases,
+		Networks:       c.Config.Networks,
+		Links:          c.Config.Links,
+		LinkEnv:        c.Config.LinkEnv,
+		DNS:            c.Config.DNS,
+		Machine:        c.Config.Machine,
 	}
 
 	return c.StateManager.SaveContainer(containerState)
@@ -257,6 +538,20 @@ func (c *Container) UpdatePID(pid int) error {
 	return nil
 }
 
+// exitCodeFromError extracts the process exit code from the error returned by CreateContainer
+func exitCodeFromError(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return 1
+}
+
 // generateID creates a random container ID
 func generateID() (string, error) {
 	bytes := make([]byte, 16)
@@ -0,0 +1,27 @@
+package container
+
+import (
+	"runtime"
+
+	"servin/pkg/logger"
+	"servin/pkg/shim"
+)
+
+// RunDetached starts the container in the background and returns. Natively
+// it runs under a shim, so it keeps running after this process exits and
+// while the daemon restarts; in VM mode the VM owns the container.
+func (c *Container) RunDetached() error {
+	if runtime.GOOS != "linux" {
+		return c.RunWithVM()
+	}
+	if vmManager, err := NewVMContainerManager(); err == nil && vmManager.IsEnabled() {
+		return c.RunWithVM()
+	}
+
+	status, err := shim.Start(c.ID)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Container %s running under shim (pid %d)", c.ID[:12], status.ShimPID)
+	return nil
+}
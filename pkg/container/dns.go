@@ -0,0 +1,152 @@
+package container
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"servin/pkg/network"
+)
+
+// HostGateway stands for the address of the host in an --add-host entry
+const HostGateway = "host-gateway"
+
+// ParseDNS builds a container's DNS settings from its --dns, --dns-search,
+// --dns-option and --add-host (host:ip) flags, nil when none is set
+func ParseDNS(servers, search, options, addHosts []string) (*network.DNSConfig, error) {
+	if len(servers) == 0 && len(search) == 0 && len(options) == 0 && len(addHosts) == 0 {
+		return nil, nil
+	}
+	dns := &network.DNSConfig{Servers: servers, Search: search, Options: options}
+
+	// host-gateway is only known once the container is attached, so it is
+	// checked as a stand-in address
+	check := &network.DNSConfig{Servers: servers, Search: search, Hosts: make(map[string]string)}
+	for _, entry := range addHosts {
+		host, ip, found := strings.Cut(entry, ":")
+		if !found || host == "" || ip == "" {
+			return nil, fmt.Errorf("invalid host entry %q (expected host:ip)", entry)
+		}
+		if dns.Hosts == nil {
+			dns.Hosts = make(map[string]string)
+		}
+		dns.Hosts[host] = ip
+		if ip == HostGateway {
+			ip = "127.0.0.1"
+		}
+		check.Hosts[host] = ip
+	}
+	if err := check.Validate(); err != nil {
+		return nil, err
+	}
+	return dns, nil
+}
+
+// setupDNS writes /etc/resolv.conf and /etc/hosts into the container's root
+// filesystem using the DNS settings of the network it is attached to, with
+// the container's own settings applied over them
+func (c *Container) setupDNS() error {
+	dns, err := network.NewStore().DNSFor(c.Config.NetworkMode)
+	if err != nil {
+		return fmt.Errorf("failed to resolve DNS configuration: %v", err)
+	}
+	dns = dns.Merge(c.dnsOverride())
+
+	etcDir := filepath.Join(c.RootFS.RootPath, "etc")
+	if err := os.MkdirAll(etcDir, 0755); err != nil {
+		return fmt.Errorf("failed to create /etc: %v", err)
+	}
+
+	// Containers on a user-defined network query its resolver, which
+	// forwards what it does not answer to the container's nameservers, or
+	// else the network's
+	if name := c.resolverNetwork(); name != "" {
+		if gateway, err := startResolver(name); err != nil {
+			fmt.Printf("Warning: failed to start DNS resolver of network %s, containers will not resolve each other: %v\n", name, err)
+		} else {
+			dns.Servers = []string{gateway}
+		}
+	}
+
+	// A container without networking has nothing to resolve against
+	if c.driver() != network.NoneMode {
+		if err := os.WriteFile(filepath.Join(etcDir, "resolv.conf"), dns.ResolvConf(), 0644); err != nil {
+			return fmt.Errorf("failed to write resolv.conf: %v", err)
+		}
+	}
+
+	// Static entries configured on the network or with --add-host win over
+	// container names
+	hosts := c.networkHosts()
+	for host, addr := range dns.Hosts {
+		hosts[host] = addr
+	}
+	dns.Hosts = hosts
+
+	var ips []net.IP
+	if c.ContainerNet != nil {
+		ips = append(ips, c.ContainerNet.IP, c.ContainerNet.IP6)
+	}
+	if err := os.WriteFile(filepath.Join(etcDir, "hosts"), dns.HostsFile(c.Config.Hostname, ips...), 0644); err != nil {
+		return fmt.Errorf("failed to write hosts file: %v", err)
+	}
+
+	return nil
+}
+
+// startResolver starts the resolver of a network unless it runs and returns
+// the address it listens on
+func startResolver(name string) (string, error) {
+	config, err := network.NewStore().Get(name)
+	if err != nil {
+		return "", err
+	}
+	if err := network.StartResolver(name); err != nil {
+		return "", err
+	}
+	return config.Gateway, nil
+}
+
+// dnsOverride returns the container's own DNS settings, with host-gateway
+// entries resolved to the gateway of its network
+func (c *Container) dnsOverride() *network.DNSConfig {
+	if c.Config.DNS == nil {
+		return nil
+	}
+	override := &network.DNSConfig{
+		Servers: c.Config.DNS.Servers,
+		Search:  c.Config.DNS.Search,
+		Options: c.Config.DNS.Options,
+		Hosts:   make(map[string]string),
+	}
+	for host, addr := range c.Config.DNS.Hosts {
+		if addr == HostGateway {
+			gateway, err := c.hostGateway()
+			if err != nil {
+				fmt.Printf("Warning: skipping host entry %s: %v\n", host, err)
+				continue
+			}
+			addr = gateway
+		}
+		override.Hosts[host] = addr
+	}
+	return override
+}
+
+// hostGateway returns the address the container reaches the host at: the
+// gateway of its network, or loopback when it shares the host's network
+func (c *Container) hostGateway() (string, error) {
+	switch c.driver() {
+	case network.HostMode:
+		return "127.0.0.1", nil
+	case network.NoneMode:
+		return "", fmt.Errorf("container has no network to reach the host over")
+	}
+	config, err := network.NewStore().Resolve(c.Config.NetworkMode)
+	if err != nil {
+		return "", err
+	}
+	return config.Gateway, nil
+}
@@ -0,0 +1,53 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"servin/pkg/errors"
+	"servin/pkg/state"
+)
+
+// commandPath is the PATH a command run in a container gets when the
+// container sets none
+const commandPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// commandEnv is the environment of a command run in the container: the
+// container's own, and none of the host's
+func (c *Container) commandEnv() []string {
+	env := c.environment()
+	var vars []string
+	if _, ok := env["PATH"]; !ok {
+		vars = append(vars, "PATH="+commandPath)
+	}
+	for key, value := range env {
+		vars = append(vars, fmt.Sprintf("%s=%s", key, value))
+	}
+	return vars
+}
+
+// Exec runs argv in a running container attached to this process's
+// standard input and output, with the container's environment, and returns
+// once it exits. In VM mode the VM runs it.
+func Exec(cs *state.ContainerState, argv []string) error {
+	if len(argv) == 0 {
+		return errors.NewValidationError("exec", "no command to run")
+	}
+	if cs.Status != state.StatusRunning {
+		return errors.NewConflictError("exec", fmt.Sprintf("container %s is not running", cs.Name))
+	}
+	if vmManager, err := NewVMContainerManager(); err == nil && vmManager.IsEnabled() {
+		return vmManager.VMContainerExec(cs.ID, argv, true, true)
+	}
+	if cs.PID <= 0 {
+		return errors.NewConflictError("exec", fmt.Sprintf("container %s has no process to run in", cs.Name))
+	}
+
+	cmd := containerCommand(context.Background(), cs.PID, argv)
+	cmd.Env = FromState(cs).commandEnv()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
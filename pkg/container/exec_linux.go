@@ -0,0 +1,22 @@
+//go:build linux
+
+package container
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+)
+
+// containerCommand runs argv in the namespaces and root of the container
+// process pid, so it sees the container's network, processes and filesystem
+// rather than the host's
+func containerCommand(ctx context.Context, pid int, argv []string) *exec.Cmd {
+	args := []string{
+		"--target", strconv.Itoa(pid),
+		"--mount", "--uts", "--ipc", "--net", "--pid",
+		"--root", "--wd",
+		"--",
+	}
+	return exec.CommandContext(ctx, "nsenter", append(args, argv...)...)
+}
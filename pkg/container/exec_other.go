@@ -0,0 +1,14 @@
+//go:build !linux
+
+package container
+
+import (
+	"context"
+	"os/exec"
+)
+
+// containerCommand runs argv on the host, where containers run on platforms
+// without namespaces
+func containerCommand(ctx context.Context, pid int, argv []string) *exec.Cmd {
+	return exec.CommandContext(ctx, argv[0], argv[1:]...)
+}
@@ -0,0 +1,56 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"servin/pkg/health"
+)
+
+// startHealthMonitor starts the health probe loop for the container process pid
+// if the container has a health check. The returned function stops the loop and
+// is safe to call more than once.
+func (c *Container) startHealthMonitor(pid int) func() {
+	if c.Config.Healthcheck.Disabled() {
+		return func() {}
+	}
+
+	probe := func(ctx context.Context, argv []string) (int, string, error) {
+		return c.probe(ctx, pid, argv)
+	}
+	monitor := health.NewMonitor(c.Config.Healthcheck, probe, func(h *health.State) {
+		if c.StateManager == nil {
+			return
+		}
+		if err := c.StateManager.UpdateContainerHealth(c.ID, h); err != nil {
+			fmt.Printf("Warning: failed to update container health: %v\n", err)
+		}
+	})
+	monitor.Start()
+
+	return monitor.Stop
+}
+
+// probe runs a health check command in the container of process pid, with
+// the container's environment and none of the host's
+func (c *Container) probe(ctx context.Context, pid int, argv []string) (int, string, error) {
+	if len(argv) == 0 {
+		return 0, "", fmt.Errorf("empty health check command")
+	}
+
+	cmd := probeCommand(ctx, pid, argv)
+	cmd.Env = c.commandEnv()
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), string(output), nil
+		}
+		return -1, string(output), err
+	}
+
+	return 0, string(output), nil
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package container
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// probeCommand runs a health check in the container process pid. The check
+// runs in its own process group, which is killed as a whole when it times
+// out.
+func probeCommand(ctx context.Context, pid int, argv []string) *exec.Cmd {
+	cmd := containerCommand(ctx, pid, argv)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd
+}
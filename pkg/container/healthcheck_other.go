@@ -0,0 +1,13 @@
+//go:build !linux
+
+package container
+
+import (
+	"context"
+	"os/exec"
+)
+
+// probeCommand runs a health check in the container process pid
+func probeCommand(ctx context.Context, pid int, argv []string) *exec.Cmd {
+	return containerCommand(ctx, pid, argv)
+}
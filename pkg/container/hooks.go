@@ -0,0 +1,89 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"servin/pkg/hooks"
+	"servin/pkg/state"
+)
+
+// hookPayload is the JSON document hooks receive on stdin
+type hookPayload struct {
+	Hook      string                `json:"hook"`
+	IP        string                `json:"ip,omitempty"`
+	Container *state.ContainerState `json:"container"`
+}
+
+// RunHooks runs a container's hooks for an event. Hooks receive the
+// container's metadata in SERVIN_* environment variables and its full
+// state as JSON on stdin.
+func RunHooks(cs *state.ContainerState, event string) error {
+	return runHooks(cs, event, "")
+}
+
+func runHooks(cs *state.ContainerState, event, ip string) error {
+	if len(cs.Hooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(hookPayload{Hook: event, IP: ip, Container: cs})
+	if err != nil {
+		return fmt.Errorf("failed to encode hook payload: %v", err)
+	}
+
+	env := []string{
+		"SERVIN_HOOK=" + event,
+		"SERVIN_CONTAINER_ID=" + cs.ID,
+		"SERVIN_CONTAINER_NAME=" + cs.Name,
+		"SERVIN_CONTAINER_NAMESPACE=" + cs.Namespace,
+		"SERVIN_CONTAINER_IMAGE=" + cs.Image,
+		"SERVIN_CONTAINER_STATUS=" + cs.Status,
+		"SERVIN_CONTAINER_PID=" + strconv.Itoa(cs.PID),
+		"SERVIN_CONTAINER_EXIT_CODE=" + strconv.Itoa(cs.ExitCode),
+		"SERVIN_CONTAINER_IP=" + ip,
+	}
+	return hooks.Run(cs.Hooks, event, env, payload)
+}
+
+// runHooks runs the container's hooks for an event with its current state.
+// Failures are reported but do not affect the container.
+func (c *Container) runHooks(event string) {
+	if len(c.Config.Hooks) == 0 {
+		return
+	}
+
+	cs, err := c.StateManager.LoadContainer(c.ID)
+	if err != nil {
+		fmt.Printf("Warning: failed to run %s hooks: %v\n", event, err)
+		return
+	}
+
+	ip := ""
+	if c.ContainerNet != nil && c.ContainerNet.IP != nil {
+		ip = c.ContainerNet.IP.String()
+	}
+	if err := runHooks(cs, event, ip); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// runPreStartHooks runs the container's pre-start hooks once. A failing
+// hook prevents the container from starting.
+func (c *Container) runPreStartHooks() error {
+	if c.preStartRan || len(c.Config.Hooks) == 0 {
+		return nil
+	}
+	c.preStartRan = true
+
+	cs, err := c.StateManager.LoadContainer(c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to run pre-start hooks: %v", err)
+	}
+	if err := runHooks(cs, hooks.PreStart, ""); err != nil {
+		c.UpdateStatus(state.StatusExited)
+		return fmt.Errorf("container not started: %v", err)
+	}
+	return nil
+}
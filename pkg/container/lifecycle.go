@@ -0,0 +1,83 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"servin/pkg/checkpoint"
+	"servin/pkg/errors"
+	"servin/pkg/hooks"
+	"servin/pkg/logger"
+	"servin/pkg/shim"
+	"servin/pkg/state"
+)
+
+// StopProcess stops a container process by PID with the image's stop
+// signal, SIGTERM when it is invalid, and SIGKILL when the signal cannot be
+// sent; it returns once the process has exited
+func StopProcess(pid int, stopSignal string) (*os.ProcessState, error) {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, fmt.Errorf("process %d not found: %v", pid, err)
+	}
+
+	sig, err := ParseStopSignal(stopSignal)
+	if err != nil {
+		sig = syscall.SIGTERM
+	}
+	if err := process.Signal(sig); err != nil {
+		if err := process.Signal(syscall.SIGKILL); err != nil {
+			return nil, fmt.Errorf("failed to kill process %d: %v", pid, err)
+		}
+	}
+
+	ps, err := process.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for process %d: %v", pid, err)
+	}
+	return ps, nil
+}
+
+// Stop stops a running container and marks it stopped. Containers in the
+// VM have no host process to run their post-stop hooks on exit, so they
+// run here.
+func Stop(sm *state.StateManager, cs *state.ContainerState) error {
+	if cs.Status != state.StatusRunning {
+		return errors.NewConflictError("stop", fmt.Sprintf("container %s is not running (status: %s)", cs.Name, cs.Status))
+	}
+	if cs.PID > 0 {
+		if _, err := StopProcess(cs.PID, cs.StopSignal); err != nil {
+			return errors.WrapError(err, errors.ErrTypeContainer, "stop", fmt.Sprintf("failed to stop container %s", cs.Name))
+		}
+	}
+	if err := sm.UpdateContainerStatus(cs.ID, state.StatusStopped); err != nil {
+		return errors.WrapError(err, errors.ErrTypeIO, "stop", "failed to update container status")
+	}
+	if cs.PID == 0 {
+		if err := RunHooks(cs, hooks.PostStop); err != nil {
+			logger.Warn("%v", err)
+		}
+	}
+	return nil
+}
+
+// Remove removes a container that is not running, with its checkpoints and
+// shim output
+func Remove(sm *state.StateManager, cs *state.ContainerState) error {
+	if cs.Status == state.StatusRunning {
+		return errors.NewConflictError("remove", fmt.Sprintf("cannot remove running container %s; stop it first", cs.Name))
+	}
+	if err := checkpoint.NewManager().RemoveAll(cs.ID); err != nil {
+		logger.Warn("failed to remove checkpoints of %s: %v", cs.Name, err)
+	}
+	os.Remove(shim.LogPath(cs.ID))
+
+	if err := sm.DeleteContainer(cs.ID); err != nil {
+		return errors.WrapError(err, errors.ErrTypeIO, "remove", "failed to remove container state")
+	}
+	if err := RunHooks(cs, hooks.PostRemove); err != nil {
+		logger.Warn("%v", err)
+	}
+	return nil
+}
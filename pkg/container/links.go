@@ -0,0 +1,200 @@
+package container
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"servin/pkg/image"
+	"servin/pkg/network"
+	"servin/pkg/state"
+)
+
+// aliasPattern matches names usable as a network alias or link alias
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateAlias checks that a network or link alias is a valid host name
+func ValidateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("invalid alias %q: use letters, digits, '.', '_' and '-'", alias)
+	}
+	return nil
+}
+
+// ParseLink splits a link of the form NAME[:ALIAS]; the alias defaults to
+// the name
+func ParseLink(spec string) (string, string, error) {
+	name, alias, found := strings.Cut(spec, ":")
+	if !found {
+		alias = name
+	}
+	if name == "" {
+		return "", "", fmt.Errorf("invalid link %q: expected NAME[:ALIAS]", spec)
+	}
+	if err := ValidateAlias(alias); err != nil {
+		return "", "", fmt.Errorf("invalid link %q: %v", spec, err)
+	}
+	return name, alias, nil
+}
+
+// findContainer looks up a container by name or ID prefix in the
+// container's namespace
+func (c *Container) findContainer(ref string) (*state.ContainerState, error) {
+	id, err := c.StateManager.FindContainerByName(ref)
+	if err != nil {
+		if id, err = c.StateManager.FindContainerByShortID(ref); err != nil {
+			return nil, err
+		}
+	}
+	return c.StateManager.LoadContainer(id)
+}
+
+// networkHosts returns the /etc/hosts entries for the container's own
+// aliases, the containers running on the same network and its links
+func (c *Container) networkHosts() map[string]string {
+	hosts := make(map[string]string)
+	if c.StateManager == nil {
+		return hosts
+	}
+
+	if c.ContainerNet != nil && c.ContainerNet.IP != nil {
+		for _, alias := range c.Config.NetworkAliases {
+			hosts[alias] = c.ContainerNet.IP.String()
+		}
+	}
+
+	// Only containers running when this one starts are listed, by their
+	// address on the default bridge; the resolvers of user-defined networks
+	// serve the names of their containers
+	if c.onDefaultBridge() {
+		peers, _ := c.StateManager.ListContainers()
+		for _, peer := range peers {
+			addr := peer.AddressOn(network.DefaultBridge)
+			if peer.ID == c.ID || peer.Status != state.StatusRunning || addr == "" {
+				continue
+			}
+			hosts[peer.Name] = addr
+			if peer.Hostname != "" {
+				hosts[peer.Hostname] = addr
+			}
+			for _, alias := range peer.NetworkAliases {
+				hosts[alias] = addr
+			}
+		}
+	}
+
+	// Links resolve across networks, as they did for the default bridge
+	for _, link := range c.Config.Links {
+		name, alias, err := ParseLink(link)
+		if err != nil {
+			continue
+		}
+		target, err := c.findContainer(name)
+		if err != nil || target.IPAddress == "" {
+			fmt.Printf("Warning: linked container %s has no address\n", name)
+			continue
+		}
+		hosts[target.Name] = target.IPAddress
+		hosts[alias] = target.IPAddress
+	}
+
+	return hosts
+}
+
+// environment returns the environment the container process starts with:
+// the legacy link variables when LinkEnv is set, overridden by its own
+func (c *Container) environment() map[string]string {
+	if !c.Config.LinkEnv || len(c.Config.Links) == 0 || c.StateManager == nil {
+		return c.Config.Env
+	}
+
+	env := make(map[string]string)
+	for _, link := range c.Config.Links {
+		name, alias, err := ParseLink(link)
+		if err != nil {
+			continue
+		}
+		target, err := c.findContainer(name)
+		if err != nil || target.IPAddress == "" {
+			continue
+		}
+		for key, value := range linkEnv(c.Config.Name, alias, target) {
+			env[key] = value
+		}
+	}
+	for key, value := range c.Config.Env {
+		env[key] = value
+	}
+	return env
+}
+
+// linkPort is a port a linked container listens on
+type linkPort struct {
+	port     int
+	protocol string
+}
+
+// linkEnv returns the variables Docker links set for a linked container:
+// <ALIAS>_NAME, <ALIAS>_PORT for its lowest port and the
+// <ALIAS>_PORT_<PORT>_<PROTO>[_ADDR|_PORT|_PROTO] set for every port
+func linkEnv(name, alias string, target *state.ContainerState) map[string]string {
+	prefix := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(alias))
+	env := map[string]string{
+		prefix + "_NAME": "/" + name + "/" + alias,
+	}
+
+	ports := linkPorts(target)
+	for i, p := range ports {
+		url := fmt.Sprintf("%s://%s:%d", p.protocol, target.IPAddress, p.port)
+		if i == 0 {
+			env[prefix+"_PORT"] = url
+		}
+		key := fmt.Sprintf("%s_PORT_%d_%s", prefix, p.port, strings.ToUpper(p.protocol))
+		env[key] = url
+		env[key+"_ADDR"] = target.IPAddress
+		env[key+"_PORT"] = strconv.Itoa(p.port)
+		env[key+"_PROTO"] = p.protocol
+	}
+	return env
+}
+
+// linkPorts returns the ports a linked container publishes or its image
+// exposes, lowest first
+func linkPorts(target *state.ContainerState) []linkPort {
+	seen := make(map[linkPort]bool)
+	add := func(port int, protocol string) {
+		if port <= 0 {
+			return
+		}
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		seen[linkPort{port, strings.ToLower(protocol)}] = true
+	}
+
+	for _, mapping := range target.PortMappings {
+		add(mapping.ContainerPort, mapping.Protocol)
+	}
+	if img, err := image.NewManager().InNamespace(target.Namespace).GetImage(target.Image); err == nil {
+		for spec := range img.Config.ExposedPorts {
+			port, protocol, _ := strings.Cut(spec, "/")
+			if n, err := strconv.Atoi(port); err == nil {
+				add(n, protocol)
+			}
+		}
+	}
+
+	ports := make([]linkPort, 0, len(seen))
+	for p := range seen {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].port != ports[j].port {
+			return ports[i].port < ports[j].port
+		}
+		return ports[i].protocol < ports[j].protocol
+	})
+	return ports
+}
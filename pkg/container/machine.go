@@ -0,0 +1,71 @@
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"servin/pkg/namespaces"
+	"servin/pkg/systemd"
+)
+
+// machineName is the name the container is registered under with
+// systemd-machined
+func (c *Container) machineName() string {
+	name := systemd.MachineName(c.Config.Name)
+	if name == "" {
+		name = c.ID[:12]
+	}
+	return name
+}
+
+// registerMachine registers the running container with systemd-machined.
+// Failing to do so does not stop the container.
+func (c *Container) registerMachine(pid int) {
+	if !c.Config.Machine {
+		return
+	}
+	machine := systemd.Machine{
+		Name:          c.machineName(),
+		ID:            c.ID,
+		Leader:        pid,
+		RootDirectory: filepath.Join(c.RootPath, "rootfs"),
+	}
+	if err := systemd.RegisterMachine(machine); err != nil {
+		fmt.Printf("Warning: failed to register machine %s: %v\n", machine.Name, err)
+		return
+	}
+	fmt.Printf("Registered machine %s with systemd-machined\n", machine.Name)
+}
+
+// unregisterMachine removes the container's machine once it has exited
+func (c *Container) unregisterMachine() {
+	if !c.Config.Machine {
+		return
+	}
+	// machined may already have dropped the machine with its leader
+	systemd.UnregisterMachine(c.machineName())
+}
+
+// forwardToJournal also sends the container's output to the journal and
+// returns a function that flushes and disconnects it
+func (c *Container) forwardToJournal(nsConfig *namespaces.ContainerConfig) func() {
+	fields := systemd.JournalFields(c.ID, c.Config.Name, c.Config.Image)
+	stdout, err := systemd.NewJournalWriter(systemd.PriorityInfo, fields)
+	if err != nil {
+		fmt.Printf("Warning: failed to forward output to the journal: %v\n", err)
+		return func() {}
+	}
+	stderr, err := systemd.NewJournalWriter(systemd.PriorityError, fields)
+	if err != nil {
+		stdout.Close()
+		fmt.Printf("Warning: failed to forward output to the journal: %v\n", err)
+		return func() {}
+	}
+
+	nsConfig.ForwardStdout = stdout
+	nsConfig.ForwardStderr = stderr
+	return func() {
+		stdout.Close()
+		stderr.Close()
+	}
+}
@@ -0,0 +1,161 @@
+package container
+
+import (
+	"fmt"
+	"net"
+
+	"servin/pkg/network"
+	"servin/pkg/state"
+)
+
+// driver returns the driver of the container's network: the built-in modes
+// are their own driver, user-defined networks have the one they were
+// created with
+func (c *Container) driver() network.NetworkMode {
+	return NetworkDriver(c.Config.NetworkMode)
+}
+
+// NetworkDriver returns the driver of the network a container's network
+// mode refers to, bridge when the network cannot be loaded so that the
+// failure surfaces when the container is attached to it
+func NetworkDriver(mode string) network.NetworkMode {
+	if mode == "" {
+		return network.BridgeMode
+	}
+	if network.IsBuiltinNetwork(mode) {
+		return network.NetworkMode(mode)
+	}
+	config, err := network.NewStore().Get(mode)
+	if err != nil {
+		return network.BridgeMode
+	}
+	return network.NetworkMode(config.Driver)
+}
+
+// onDefaultBridge reports whether the container is on the default bridge,
+// as its own network or one it was connected to
+func (c *Container) onDefaultBridge() bool {
+	if c.driver() != network.BridgeMode {
+		return false
+	}
+	for _, name := range append([]string{c.Config.NetworkMode}, c.Config.Networks...) {
+		if network.CanonicalName(name) == network.DefaultBridge {
+			return true
+		}
+	}
+	return false
+}
+
+// resolverNetwork returns the user-defined bridge network whose resolver
+// the container uses as its nameserver: its own network, else the first one
+// it was connected to, or "" when it is on the default bridge only
+func (c *Container) resolverNetwork() string {
+	if c.driver() != network.BridgeMode {
+		return ""
+	}
+	for _, name := range append([]string{c.Config.NetworkMode}, c.Config.Networks...) {
+		if network.CanonicalName(name) != network.DefaultBridge {
+			return name
+		}
+	}
+	return ""
+}
+
+// connectNetworks attaches the container process pid to the networks it
+// was connected to besides its own, recording its address on each
+func (c *Container) connectNetworks(pid int) {
+	for _, name := range c.Config.Networks {
+		containerNet, err := c.NetworkManager.CreateVethPair(c.ID, name)
+		if err == nil {
+			if err = c.NetworkManager.AttachContainerToNetwork(containerNet, pid); err != nil {
+				c.NetworkManager.DetachContainerFromNetwork(containerNet)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Warning: failed to connect container to network %s: %v\n", name, err)
+			continue
+		}
+		if c.StateManager != nil {
+			if err := c.StateManager.UpdateContainerNetworkIP(c.ID, containerNet.NetworkName, containerNet.IP.String(), ipString(containerNet.IP6)); err != nil {
+				fmt.Printf("Warning: failed to record container address: %v\n", err)
+			}
+		}
+	}
+}
+
+// disconnectNetworks releases the container's addresses on the networks it
+// was connected to besides its own, whether at start or while it ran
+func (c *Container) disconnectNetworks() {
+	if c.StateManager == nil {
+		return
+	}
+	cs, err := c.StateManager.LoadContainer(c.ID)
+	if err != nil {
+		return
+	}
+	for name, ip := range cs.NetworkIPs {
+		if err := c.NetworkManager.DetachContainerFromNetwork(connectedNetwork(c.ID, name, ip, cs.NetworkIP6s[name])); err != nil {
+			fmt.Printf("Warning: failed to disconnect container from network %s: %v\n", name, err)
+		}
+	}
+}
+
+// connectedNetwork describes the attachment of a container to a network it
+// was connected to, enough to detach it
+func connectedNetwork(id, name, ip, ip6 string) *network.ContainerNetwork {
+	return &network.ContainerNetwork{
+		ContainerID: id,
+		NetworkName: name,
+		IP:          net.ParseIP(ip),
+		IP6:         net.ParseIP(ip6),
+		VethHost:    network.VethName(id, name),
+	}
+}
+
+// ipString returns the text form of an address, or "" for none
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// Connect connects a container to a bridge network besides its own: at
+// once when it is running, and on every start from then on
+func Connect(sm *state.StateManager, cs *state.ContainerState, networkName string) error {
+	if cs.Status == state.StatusRunning && cs.PID > 0 {
+		nm := network.NewNetworkManager()
+		containerNet, err := nm.CreateVethPair(cs.ID, networkName)
+		if err != nil {
+			return err
+		}
+		if err := nm.AttachContainerToNetwork(containerNet, cs.PID); err != nil {
+			nm.DetachContainerFromNetwork(containerNet)
+			return err
+		}
+		cs.SetNetworkIP(networkName, containerNet.IP.String(), ipString(containerNet.IP6))
+	}
+
+	cs.Networks = append(cs.Networks, networkName)
+	return sm.SaveContainer(cs)
+}
+
+// Disconnect disconnects a container from a network it was connected to,
+// removing its interface at once when it is running
+func Disconnect(sm *state.StateManager, cs *state.ContainerState, networkName string) error {
+	if ip := cs.NetworkIPs[networkName]; ip != "" {
+		if err := network.NewNetworkManager().DetachContainerFromNetwork(connectedNetwork(cs.ID, networkName, ip, cs.NetworkIP6s[networkName])); err != nil {
+			return err
+		}
+		cs.SetNetworkIP(networkName, "", "")
+	}
+
+	var remaining []string
+	for _, n := range cs.Networks {
+		if n != networkName {
+			remaining = append(remaining, n)
+		}
+	}
+	cs.Networks = remaining
+	return sm.SaveContainer(cs)
+}
@@ -0,0 +1,93 @@
+package container
+
+import (
+	"fmt"
+	"math/rand"
+
+	"servin/pkg/network"
+	"servin/pkg/state"
+)
+
+// publishedPort is a port published by a running container
+type publishedPort struct {
+	owner   string
+	mapping network.PortMapping
+}
+
+// AllocatePorts returns the port mappings a container publishes with host
+// ports allocated for those without one, from the host's ephemeral range.
+// A host port another running container publishes, that something on the
+// host listens on or that the mappings publish twice is a conflict.
+func AllocatePorts(id string, mappings []network.PortMapping) ([]network.PortMapping, error) {
+	taken := runningPorts(id)
+	published := make([]network.PortMapping, 0, len(mappings))
+
+	for _, mapping := range mappings {
+		if mapping.HostPort == 0 {
+			port, err := freePort(mapping, taken)
+			if err != nil {
+				return nil, err
+			}
+			mapping.HostPort = port
+		} else {
+			if owner := portOwner(mapping, taken); owner != "" {
+				return nil, fmt.Errorf("port %s is already published by %s", mapping, owner)
+			}
+			if err := network.PortFree(mapping); err != nil {
+				return nil, fmt.Errorf("port %s is not available on the host: %v", mapping, err)
+			}
+		}
+		published = append(published, mapping)
+		taken = append(taken, publishedPort{owner: "this container", mapping: mapping})
+	}
+	return published, nil
+}
+
+// freePort returns a host port of the ephemeral range that is free for a
+// mapping, starting from a random one so containers started together do
+// not race for the same
+func freePort(mapping network.PortMapping, taken []publishedPort) (int, error) {
+	low, high := network.LocalPortRange()
+	size := high - low + 1
+	start := rand.Intn(size)
+	for i := 0; i < size; i++ {
+		mapping.HostPort = low + (start+i)%size
+		if portOwner(mapping, taken) != "" {
+			continue
+		}
+		if network.PortFree(mapping) == nil {
+			return mapping.HostPort, nil
+		}
+	}
+	return 0, fmt.Errorf("no free host port in %d-%d for container port %d", low, high, mapping.ContainerPort)
+}
+
+// portOwner returns the container publishing a host port a mapping would
+// publish too, or ""
+func portOwner(mapping network.PortMapping, taken []publishedPort) string {
+	for _, port := range taken {
+		if port.mapping.Overlaps(mapping) {
+			return port.owner
+		}
+	}
+	return ""
+}
+
+// runningPorts returns the ports published by running containers of every
+// namespace but the container id
+func runningPorts(id string) []publishedPort {
+	containers, err := state.NewStateManager().AllNamespaces().ListContainers()
+	if err != nil {
+		return nil
+	}
+	var taken []publishedPort
+	for _, cs := range containers {
+		if cs.ID == id || cs.Status != state.StatusRunning {
+			continue
+		}
+		for _, mapping := range cs.PublishedPorts {
+			taken = append(taken, publishedPort{owner: "container " + cs.Name, mapping: mapping})
+		}
+	}
+	return taken
+}
@@ -0,0 +1,14 @@
+package container
+
+import "time"
+
+// Process describes a process running inside a container
+type Process struct {
+	PID     int
+	PPID    int
+	User    string
+	State   string
+	CPUTime time.Duration
+	RSS     uint64 // Resident memory in bytes
+	Command string
+}
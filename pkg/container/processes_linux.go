@@ -0,0 +1,111 @@
+//go:build linux
+
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the USER_HZ value used by /proc/<pid>/stat
+const clockTicks = 100
+
+// ListProcesses returns every process that shares the PID namespace of the
+// container's init process, found by scanning /proc on the host
+func ListProcesses(initPID int) ([]*Process, error) {
+	if initPID <= 0 {
+		return nil, fmt.Errorf("container has no running process")
+	}
+
+	namespace, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", initPID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PID namespace of process %d: %v", initPID, err)
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc: %v", err)
+	}
+
+	var processes []*Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes can exit while we scan, so skip anything we can't read
+		if ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", pid)); err != nil || ns != namespace {
+			continue
+		}
+		if p, err := readProcess(pid); err == nil {
+			processes = append(processes, p)
+		}
+	}
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+	return processes, nil
+}
+
+func readProcess(pid int) (*Process, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	// The command name may contain spaces, so split after its closing parenthesis
+	content := string(data)
+	start, end := strings.Index(content, "("), strings.LastIndex(content, ")")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("malformed stat for process %d", pid)
+	}
+	name := content[start+1 : end]
+	fields := strings.Fields(content[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed stat for process %d", pid)
+	}
+
+	p := &Process{PID: pid, State: fields[0], Command: name}
+	p.PPID, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	p.CPUTime = time.Duration(utime+stime) * time.Second / clockTicks
+	rssPages, _ := strconv.ParseUint(fields[21], 10, 64)
+	p.RSS = rssPages * uint64(os.Getpagesize())
+
+	if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil && len(cmdline) > 0 {
+		p.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+
+	p.User = processUser(pid)
+	return p, nil
+}
+
+// processUser returns the name of the real user running the process, or its UID
+func processUser(pid int) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return "?"
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Uid:"))
+		if len(fields) == 0 {
+			break
+		}
+		if u, err := user.LookupId(fields[0]); err == nil {
+			return u.Username
+		}
+		return fields[0]
+	}
+
+	return "?"
+}
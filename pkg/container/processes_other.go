@@ -0,0 +1,82 @@
+//go:build !linux
+
+package container
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListProcesses returns the container's init process and its descendants.
+// Without PID namespaces the process tree is taken from ps.
+func ListProcesses(initPID int) ([]*Process, error) {
+	if initPID <= 0 {
+		return nil, fmt.Errorf("container has no running process")
+	}
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("process listing is not supported on Windows outside VM mode")
+	}
+
+	output, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,user=,state=,time=,rss=,command=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %v", err)
+	}
+
+	all := make(map[int]*Process)
+	children := make(map[int][]int)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		rssKB, _ := strconv.ParseUint(fields[5], 10, 64)
+
+		all[pid] = &Process{
+			PID:     pid,
+			PPID:    ppid,
+			User:    fields[2],
+			State:   fields[3],
+			CPUTime: parsePSTime(fields[4]),
+			RSS:     rssKB * 1024,
+			Command: strings.Join(fields[6:], " "),
+		}
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	var processes []*Process
+	queue := []int{initPID}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if p, ok := all[pid]; ok {
+			processes = append(processes, p)
+			queue = append(queue, children[pid]...)
+		}
+	}
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+	return processes, nil
+}
+
+// parsePSTime parses ps cumulative CPU time such as "1:02.50" or "01:02:03"
+func parsePSTime(value string) time.Duration {
+	var total time.Duration
+	for _, part := range strings.Split(value, ":") {
+		seconds, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + time.Duration(seconds*float64(time.Second))
+	}
+	return total
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+)
+
+// ParseStopSignal converts an image's STOPSIGNAL to a signal. Signal names
+// cannot be resolved here, so only numbers are honoured and names stop
+// the container with SIGTERM.
+func ParseStopSignal(name string) (syscall.Signal, error) {
+	if name == "" {
+		return syscall.SIGTERM, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > 64 {
+			return 0, fmt.Errorf("invalid stop signal '%s'", name)
+		}
+		return syscall.Signal(n), nil
+	}
+	return syscall.SIGTERM, nil
+}
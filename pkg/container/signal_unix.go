@@ -0,0 +1,36 @@
+//go:build linux || darwin
+
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ParseStopSignal converts an image's STOPSIGNAL, a signal name with or
+// without the SIG prefix or a signal number, to the signal; the default is
+// SIGTERM
+func ParseStopSignal(name string) (syscall.Signal, error) {
+	if name == "" {
+		return syscall.SIGTERM, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > 64 {
+			return 0, fmt.Errorf("invalid stop signal '%s'", name)
+		}
+		return syscall.Signal(n), nil
+	}
+
+	upper := strings.ToUpper(name)
+	if !strings.HasPrefix(upper, "SIG") {
+		upper = "SIG" + upper
+	}
+	if sig := unix.SignalNum(upper); sig != 0 {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("invalid stop signal '%s'", name)
+}
@@ -0,0 +1,82 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+
+	"servin/pkg/image"
+	"servin/pkg/rootfs"
+	"servin/pkg/state"
+)
+
+// DiskUsage returns the size of a container's writable layer and the total
+// size of its root filesystem. The writable layer is every regular file that
+// is not part of the image, or that was modified after the container started.
+// For overlay-backed containers it is the overlay's upper directory.
+func DiskUsage(cs *state.ContainerState) (writable int64, total int64, err error) {
+	rfs := rootfs.New(cs.ID, cs.Image)
+	rootPath := rfs.RootPath
+	if _, err := os.Stat(rootPath); os.IsNotExist(err) {
+		// The root filesystem is removed once the container exits
+		return 0, 0, nil
+	}
+
+	if _, err := os.Stat(rfs.UpperPath()); err == nil {
+		return regularFileSize(rfs.UpperPath()), regularFileSize(rootPath), nil
+	}
+
+	var layerDirs []string
+	imageManager := image.NewManager().InNamespace(cs.Namespace)
+	if img, err := imageManager.GetImage(cs.Image); err == nil {
+		layerDirs, _ = imageManager.LayerDirs(img)
+	}
+
+	err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Files can disappear while a running container is walked
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		total += info.Size()
+
+		if !cs.Started.IsZero() && info.ModTime().After(cs.Started) {
+			writable += info.Size()
+			return nil
+		}
+		if len(layerDirs) == 0 {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return nil
+		}
+		for _, dir := range layerDirs {
+			if _, err := os.Lstat(filepath.Join(dir, relPath)); err == nil {
+				return nil
+			}
+		}
+		writable += info.Size()
+		return nil
+	})
+
+	return writable, total, err
+}
+
+// regularFileSize returns the total size of the regular files under dir
+func regularFileSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -6,8 +6,11 @@ import (
 	"path/filepath"
 	"strings"
 
+	"servin/pkg/hooks"
 	"servin/pkg/network"
+	"servin/pkg/stats"
 	"servin/pkg/vm"
+	"servin/pkg/volume"
 )
 
 // Helper function to convert PortMappings to map[string]string
@@ -35,12 +38,14 @@ func NewVMContainerManager() (*VMContainerManager, error) {
 		return &VMContainerManager{enabled: false}, nil
 	}
 
-	// Create VM configuration
-	vmConfig := vm.DefaultVMConfig("servin-vm")
-
-	// Customize VM config based on environment
-	if customConfig := getCustomVMConfig(); customConfig != nil {
-		vmConfig = customConfig
+	// Use the VM selected for this project, shell or user
+	name, _ := vm.CurrentVM()
+	if !vm.VMExists(name) {
+		return nil, fmt.Errorf("VM %s not found (create it with 'servin vm create %s')", name, name)
+	}
+	vmConfig, err := vm.LoadVMConfig(name)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create VM manager
@@ -61,6 +66,19 @@ func (vcm *VMContainerManager) IsEnabled() bool {
 	return vcm.enabled
 }
 
+// VMCreated reports whether the VM has been created
+func (vcm *VMContainerManager) VMCreated() bool {
+	return vcm.enabled && vcm.vmManager.Created()
+}
+
+// SetDownloadOptions sets how VM asset downloads report progress and are
+// cancelled when the VM is created
+func (vcm *VMContainerManager) SetDownloadOptions(opts vm.DownloadOptions) {
+	if vcm.vmConfig != nil {
+		vcm.vmConfig.Downloads = opts
+	}
+}
+
 // EnsureVMRunning ensures the VM is running and ready for containers
 func (vcm *VMContainerManager) EnsureVMRunning() error {
 	if !vcm.enabled {
@@ -76,11 +94,26 @@ func (vcm *VMContainerManager) RunContainer(container *Container) (*VMContainerR
 		return nil, fmt.Errorf("VM mode is not enabled")
 	}
 
-	// Ensure VM is running
-	if err := vcm.EnsureVMRunning(); err != nil {
+	// Ensure VM is running, provisioning it on first use
+	if err := vcm.vmManager.AutoStart(); err != nil {
 		return nil, fmt.Errorf("failed to ensure VM is running: %v", err)
 	}
 
+	// Host paths are mounted from where the VM sees them, which must be
+	// a directory the provider shares with it
+	volumes := make(map[string]string, len(container.Config.Volumes))
+	sharer, ok := vcm.vmManager.Provider.(vm.SharedFolderProvider)
+	for hostPath, mount := range container.Config.Volumes {
+		vmPath, err := volume.VMPath(hostPath)
+		if err != nil {
+			return nil, err
+		}
+		if ok && strings.HasPrefix(vmPath, "/") && !vm.SharedGuestPath(sharer.SharedFolders(), vmPath) {
+			return nil, sharedFolderError(hostPath, sharer.SharedFolders())
+		}
+		volumes[vmPath] = mount
+	}
+
 	// Convert Servin container config to VM container config
 	vmContainerConfig := &vm.ContainerConfig{
 		Image:       container.Config.Image,
@@ -88,7 +121,7 @@ func (vcm *VMContainerManager) RunContainer(container *Container) (*VMContainerR
 		Command:     append([]string{container.Config.Command}, container.Config.Args...),
 		Environment: container.Config.Env,
 		Ports:       convertPortMappings(container.Config.PortMappings),
-		Volumes:     container.Config.Volumes,
+		Volumes:     volumes,
 		WorkDir:     container.Config.WorkDir,
 		Detached:    true, // Always run detached in VM
 	}
@@ -111,6 +144,19 @@ func (vcm *VMContainerManager) RunContainer(container *Container) (*VMContainerR
 	}, nil
 }
 
+// sharedFolderError explains that a host path cannot be mounted because the
+// VM does not share it
+func sharedFolderError(hostPath string, shares []vm.Share) error {
+	if len(shares) == 0 {
+		return fmt.Errorf("cannot mount '%s': the VM provider shares no host directories with the VM", hostPath)
+	}
+	var dirs []string
+	for _, s := range shares {
+		dirs = append(dirs, s.HostPath)
+	}
+	return fmt.Errorf("cannot mount '%s': only %s are shared with the VM", hostPath, strings.Join(dirs, ", "))
+}
+
 // GetVMInfo returns information about the VM
 func (vcm *VMContainerManager) GetVMInfo() (*vm.VMInfo, error) {
 	if !vcm.enabled {
@@ -129,6 +175,133 @@ func (vcm *VMContainerManager) ListVMContainers() ([]*vm.ContainerInfo, error) {
 	return vcm.vmManager.Provider.ListContainers()
 }
 
+// VMContainerStats returns resource usage reported by the VM for the given containers
+func (vcm *VMContainerManager) VMContainerStats(containerIDs []string) ([]*stats.Stats, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.StatsProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support container stats")
+	}
+
+	return provider.ContainerStats(containerIDs)
+}
+
+// VMContainerTop returns the process listing reported by the VM for a container
+func (vcm *VMContainerManager) VMContainerTop(containerID string) ([]byte, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.ProcessProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support listing container processes")
+	}
+
+	return provider.ContainerTop(containerID)
+}
+
+// VMContainerExec runs a command in a container in the VM, attached to stdio
+func (vcm *VMContainerManager) VMContainerExec(containerID string, command []string, interactive, tty bool) error {
+	if !vcm.enabled {
+		return fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.ExecProvider)
+	if !ok {
+		return fmt.Errorf("VM provider does not support exec")
+	}
+
+	return provider.ContainerExec(containerID, command, interactive, tty)
+}
+
+// VMContainerCopy returns the file copy support of the VM provider for
+// containers in the VM
+func (vcm *VMContainerManager) VMContainerCopy() (vm.CopyProvider, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.CopyProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support copying files")
+	}
+
+	return provider, nil
+}
+
+// VMSnapshots returns the snapshot support of the VM provider
+func (vcm *VMContainerManager) VMSnapshots() (vm.SnapshotProvider, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.SnapshotProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support snapshots")
+	}
+
+	return provider, nil
+}
+
+// VMConsole returns the serial console access of the VM provider
+func (vcm *VMContainerManager) VMConsole() (vm.ConsoleProvider, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.ConsoleProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support console access")
+	}
+
+	return provider, nil
+}
+
+// VMShell returns the guest shell access of the VM provider
+func (vcm *VMContainerManager) VMShell() (vm.ShellProvider, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.ShellProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support guest shells")
+	}
+
+	return provider, nil
+}
+
+// VMPause returns the pause support of the VM provider
+func (vcm *VMContainerManager) VMPause() (vm.PauseProvider, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.PauseProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support pausing the VM")
+	}
+
+	return provider, nil
+}
+
+// VMBackup returns the export and import support of the VM provider
+func (vcm *VMContainerManager) VMBackup() (vm.BackupProvider, error) {
+	if !vcm.enabled {
+		return nil, fmt.Errorf("VM mode is not enabled")
+	}
+
+	provider, ok := vcm.vmManager.Provider.(vm.BackupProvider)
+	if !ok {
+		return nil, fmt.Errorf("VM provider does not support exporting the VM")
+	}
+
+	return provider, nil
+}
+
 // StopVMContainer stops a container in the VM
 func (vcm *VMContainerManager) StopVMContainer(containerID string) error {
 	if !vcm.enabled {
@@ -156,6 +329,40 @@ func (vcm *VMContainerManager) Shutdown() error {
 	return vcm.vmManager.Shutdown()
 }
 
+// ResizeVM changes the CPUs, memory and disk size of the VM, restarting it
+// if it is running, and saves them in the VM configuration. It reports
+// whether the VM was restarted.
+func (vcm *VMContainerManager) ResizeVM(res vm.Resources) (bool, error) {
+	if !vcm.enabled {
+		return false, fmt.Errorf("VM mode is not enabled")
+	}
+
+	// The configuration is saved even when the VM failed to restart, as
+	// its resources have changed by then
+	restarted, err := vcm.vmManager.Resize(res)
+	if saveErr := vm.SaveVMConfig(vcm.vmConfig); err == nil {
+		err = saveErr
+	}
+	return restarted, err
+}
+
+// Destroy deletes the VM along with every container and image inside it
+func (vcm *VMContainerManager) Destroy() error {
+	if !vcm.enabled {
+		return fmt.Errorf("VM mode is not enabled")
+	}
+
+	return vcm.vmManager.Destroy()
+}
+
+// VMName returns the name of the VM containers run in
+func (vcm *VMContainerManager) VMName() string {
+	if vcm.vmConfig == nil {
+		return ""
+	}
+	return vcm.vmConfig.Name
+}
+
 // VMContainerResult represents the result of running a container in a VM
 type VMContainerResult struct {
 	ContainerID string  `json:"container_id"`
@@ -174,7 +381,7 @@ type VMInfo struct {
 	Platform   string `json:"platform"`
 	Provider   string `json:"provider"`
 	IPAddress  string `json:"ip_address"`
-	SSHPort    int    `json:"ssh_port"`
+	AgentPort  int    `json:"agent_port"`
 	DockerPort int    `json:"docker_port"`
 }
 
@@ -212,20 +419,6 @@ func autoDetectVMMode() bool {
 	return true // Enable VM mode by default for consistency
 }
 
-func getCustomVMConfig() *vm.VMConfig {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil
-	}
-
-	_ = filepath.Join(homeDir, ".servin", "vm-config.json")
-
-	// In a real implementation, we would load and parse the JSON config
-	// For now, return nil to use defaults
-
-	return nil
-}
-
 func (vcm *VMContainerManager) getVMInfo() *VMInfo {
 	info, err := vcm.vmManager.Provider.GetInfo()
 	if err != nil {
@@ -242,7 +435,7 @@ func (vcm *VMContainerManager) getVMInfo() *VMInfo {
 		Platform:   info.Platform,
 		Provider:   info.Provider,
 		IPAddress:  info.IPAddress,
-		SSHPort:    info.SSHPort,
+		AgentPort:  info.AgentPort,
 		DockerPort: info.DockerPort,
 	}
 }
@@ -254,6 +447,10 @@ func (c *Container) RunWithVM() error {
 	// Try VM mode first if available
 	vmManager, err := NewVMContainerManager()
 	if err == nil && vmManager.IsEnabled() {
+		if err := c.runPreStartHooks(); err != nil {
+			return err
+		}
+
 		result, vmErr := vmManager.RunContainer(c)
 		if vmErr == nil {
 			// Update container with VM result
@@ -262,6 +459,7 @@ func (c *Container) RunWithVM() error {
 			c.UpdateStatus(result.Status)
 
 			fmt.Printf("Container %s running in VM (%s)\n", c.Config.Name, result.VMInfo.Provider)
+			c.runHooks(hooks.PostStart)
 			return nil
 		}
 
@@ -0,0 +1,253 @@
+// Package credentials stores registry credentials. Credentials live in an
+// auth file in the servin config directory, in the same format as Docker's
+// config.json, or in an OS keychain through Docker credential helpers
+// (docker-credential-osxkeychain, -wincred, -secretservice, -pass).
+package credentials
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DockerHub is the key under which Docker Hub credentials are stored
+const DockerHub = "docker.io"
+
+// dockerHubHelperKey is the server URL Docker uses for Docker Hub in
+// credential helpers, so credentials stored by `docker login` are found
+const dockerHubHelperKey = "https://index.docker.io/v1/"
+
+// Credential authenticates with a registry
+type Credential struct {
+	Username string
+	Password string
+	// IdentityToken is a refresh token issued by the registry's token
+	// server, used instead of the password when set
+	IdentityToken string
+}
+
+// authFile is the on-disk auth file
+type authFile struct {
+	Auths map[string]authEntry `json:"auths"`
+	// CredsStore is the credential helper used for every registry
+	CredsStore string `json:"credsStore,omitempty"`
+	// CredHelpers overrides the credential helper per registry
+	CredHelpers map[string]string `json:"credHelpers,omitempty"`
+}
+
+type authEntry struct {
+	// Auth is base64 of "username:password"
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+var mu sync.Mutex
+
+// Get returns the stored credential for a registry. It returns an error
+// satisfying os.IsNotExist when there is none.
+func Get(registry string) (*Credential, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registry = Normalize(registry)
+	file, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	if helper := file.helper(registry); helper != "" {
+		return helperGet(helper, registry)
+	}
+
+	entry, ok := file.Auths[registry]
+	if !ok {
+		return nil, notFound(registry)
+	}
+	return entry.credential()
+}
+
+// Save stores the credential for a registry. The first time credentials
+// are saved on macOS and Windows, the platform keychain is selected if its
+// credential helper is installed.
+func Save(registry string, cred *Credential) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registry = Normalize(registry)
+	file, err := load()
+	if err != nil {
+		return err
+	}
+
+	if file.CredsStore == "" && len(file.Auths) == 0 {
+		file.CredsStore = defaultStore()
+	}
+
+	if helper := file.helper(registry); helper != "" {
+		if err := helperStore(helper, registry, cred); err != nil {
+			return err
+		}
+		// Keep an empty entry so List shows the registry
+		file.Auths[registry] = authEntry{}
+		return save(file)
+	}
+
+	entry := authEntry{IdentityToken: cred.IdentityToken}
+	if cred.IdentityToken == "" {
+		entry.Auth = base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Password))
+	}
+	file.Auths[registry] = entry
+	return save(file)
+}
+
+// Erase removes the stored credential for a registry
+func Erase(registry string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registry = Normalize(registry)
+	file, err := load()
+	if err != nil {
+		return err
+	}
+
+	if helper := file.helper(registry); helper != "" {
+		if err := helperErase(helper, registry); err != nil {
+			return err
+		}
+	} else if _, ok := file.Auths[registry]; !ok {
+		return notFound(registry)
+	}
+
+	delete(file.Auths, registry)
+	return save(file)
+}
+
+// List returns the registries with stored credentials
+func List() ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	file, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	var registries []string
+	for registry := range file.Auths {
+		registries = append(registries, registry)
+	}
+	return registries, nil
+}
+
+// Normalize reduces a registry URL or host to the key credentials are
+// stored under: the host and port, with Docker Hub's aliases folded into
+// docker.io
+func Normalize(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry, _, _ = strings.Cut(registry, "/")
+	registry = strings.ToLower(registry)
+
+	switch registry {
+	case "", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return DockerHub
+	}
+	return registry
+}
+
+// Path returns the location of the auth file, which SERVIN_AUTH_FILE overrides
+func Path() string {
+	if path := os.Getenv("SERVIN_AUTH_FILE"); path != "" {
+		return path
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".servin", "auth.json")
+}
+
+func load() (*authFile, error) {
+	file := &authFile{}
+	data, err := os.ReadFile(Path())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", Path(), err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", Path(), err)
+		}
+	}
+	if file.Auths == nil {
+		file.Auths = make(map[string]authEntry)
+	}
+	return file, nil
+}
+
+// save writes the auth file atomically. It holds secrets, so only its
+// owner can read it.
+func save(file *authFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// helper returns the credential helper for a registry, if any
+func (f *authFile) helper(registry string) string {
+	if helper, ok := f.CredHelpers[registry]; ok {
+		return helper
+	}
+	return f.CredsStore
+}
+
+func (e authEntry) credential() (*Credential, error) {
+	cred := &Credential{IdentityToken: e.IdentityToken}
+	if e.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stored credentials: %v", err)
+		}
+		user, password, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid stored credentials")
+		}
+		cred.Username, cred.Password = user, password
+	}
+	return cred, nil
+}
+
+// defaultStore returns the platform keychain helper if it is installed
+func defaultStore() string {
+	var helper string
+	switch runtime.GOOS {
+	case "darwin":
+		helper = "osxkeychain"
+	case "windows":
+		helper = "wincred"
+	default:
+		return ""
+	}
+	if _, err := exec.LookPath(helperBinary(helper)); err != nil {
+		return ""
+	}
+	return helper
+}
+
+func notFound(registry string) error {
+	return &os.PathError{Op: "credentials", Path: registry, Err: os.ErrNotExist}
+}
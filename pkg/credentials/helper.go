@@ -0,0 +1,90 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Credential helpers implement Docker's credential helper protocol: the
+// action is the only argument, and the server URL or credential is passed
+// on stdin.
+
+// helperCredential is the credential exchanged with a helper
+type helperCredential struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// tokenUsername marks a stored identity token instead of a password
+const tokenUsername = "<token>"
+
+func helperBinary(helper string) string {
+	return "docker-credential-" + helper
+}
+
+// helperKey returns the server URL a registry is stored under in helpers
+func helperKey(registry string) string {
+	if registry == DockerHub {
+		return dockerHubHelperKey
+	}
+	return registry
+}
+
+func runHelper(helper, action string, input []byte) ([]byte, error) {
+	cmd := exec.Command(helperBinary(helper), action)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// Helpers report errors such as missing credentials on stdout
+		message := strings.TrimSpace(stdout.String() + stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("%s %s: %s", helperBinary(helper), action, message)
+	}
+	return stdout.Bytes(), nil
+}
+
+func helperGet(helper, registry string) (*Credential, error) {
+	output, err := runHelper(helper, "get", []byte(helperKey(registry)))
+	if err != nil {
+		if strings.Contains(err.Error(), "credentials not found") {
+			return nil, notFound(registry)
+		}
+		return nil, err
+	}
+
+	var stored helperCredential
+	if err := json.Unmarshal(output, &stored); err != nil {
+		return nil, fmt.Errorf("invalid output from %s: %v", helperBinary(helper), err)
+	}
+	if stored.Username == tokenUsername {
+		return &Credential{IdentityToken: stored.Secret}, nil
+	}
+	return &Credential{Username: stored.Username, Password: stored.Secret}, nil
+}
+
+func helperStore(helper, registry string, cred *Credential) error {
+	stored := helperCredential{ServerURL: helperKey(registry), Username: cred.Username, Secret: cred.Password}
+	if cred.IdentityToken != "" {
+		stored.Username, stored.Secret = tokenUsername, cred.IdentityToken
+	}
+	input, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	_, err = runHelper(helper, "store", input)
+	return err
+}
+
+func helperErase(helper, registry string) error {
+	_, err := runHelper(helper, "erase", []byte(helperKey(registry)))
+	return err
+}
@@ -81,6 +81,7 @@ func (s *ServinImageService) ImageStatus(ctx context.Context, req *ImageStatusRe
 			"created":  img.Created.Format(time.RFC3339),
 			"repoTags": strings.Join(img.RepoTags, ","),
 			"rootfs":   img.RootFSPath,
+			"layers":   strings.Join(img.LayerChain, ","),
 		}
 	}
 
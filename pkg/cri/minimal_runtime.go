@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"servin/pkg/image"
@@ -117,6 +119,11 @@ func (s *MinimalRuntimeService) RunPodSandbox(ctx context.Context, req *RunPodSa
 		return nil, fmt.Errorf("failed to save pod sandbox state: %v", err)
 	}
 
+	// Keep the log directory, as container log paths are relative to it
+	if err := s.savePodSandboxConfig(podID, req.Config); err != nil {
+		return nil, fmt.Errorf("failed to save pod sandbox config: %v", err)
+	}
+
 	s.logger.Info("Created pod sandbox: %s", podID)
 	return &RunPodSandboxResponse{PodSandboxId: podID}, nil
 }
@@ -130,6 +137,21 @@ func (s *MinimalRuntimeService) StopPodSandbox(ctx context.Context, req *StopPod
 		return nil, fmt.Errorf("failed to update pod sandbox state: %v", err)
 	}
 
+	// Stopping a pod stops its containers
+	containers, err := s.loadContainerRecords()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read containers: %v", err)
+	}
+	for _, container := range containers {
+		if container.PodSandboxID == req.PodSandboxId && container.State == ContainerStateRunning {
+			container.State = ContainerStateExited
+			container.FinishedAt = time.Now().UnixNano()
+			if err := s.saveContainerRecord(container); err != nil {
+				return nil, fmt.Errorf("failed to save container state: %v", err)
+			}
+		}
+	}
+
 	return &StopPodSandboxResponse{}, nil
 }
 
@@ -143,6 +165,17 @@ func (s *MinimalRuntimeService) RemovePodSandbox(ctx context.Context, req *Remov
 		return nil, fmt.Errorf("failed to remove pod directory: %v", err)
 	}
 
+	// Containers go with their pod
+	containers, err := s.loadContainerRecords()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read containers: %v", err)
+	}
+	for _, container := range containers {
+		if container.PodSandboxID == req.PodSandboxId {
+			os.Remove(s.containerStateFile(container.ID))
+		}
+	}
+
 	return &RemovePodSandboxResponse{}, nil
 }
 
@@ -223,11 +256,38 @@ func (s *MinimalRuntimeService) ListPodSandbox(ctx context.Context, req *ListPod
 func (s *MinimalRuntimeService) CreateContainer(ctx context.Context, req *CreateContainerRequest) (*CreateContainerResponse, error) {
 	s.logger.Info("CRI CreateContainer called for container: %s", req.Config.Metadata.Name)
 
+	if _, err := s.loadPodSandboxState(req.PodSandboxId); err != nil {
+		return nil, fmt.Errorf("pod sandbox %s not found", req.PodSandboxId)
+	}
+
 	// Generate container ID
 	containerID := generateContainerID(req.Config.Metadata, req.PodSandboxId)
 
-	// For minimal implementation, just return the ID
+	// For minimal implementation, only the container's record is kept
 	// In a full implementation, this would create the actual container
+	record := &containerRecord{
+		Container: Container{
+			ID:           containerID,
+			PodSandboxID: req.PodSandboxId,
+			Metadata:     req.Config.Metadata,
+			Image:        req.Config.Image,
+			State:        ContainerStateCreated,
+			CreatedAt:    time.Now().UnixNano(),
+			Labels:       req.Config.Labels,
+			Annotations:  req.Config.Annotations,
+		},
+		LogPath: req.Config.LogPath,
+	}
+	if req.Config.Image != nil {
+		record.ImageRef = req.Config.Image.Image
+	}
+	if podConfig, err := s.loadPodSandboxConfig(req.PodSandboxId); err == nil && podConfig.LogDirectory != "" && record.LogPath != "" {
+		record.LogPath = filepath.Join(podConfig.LogDirectory, record.LogPath)
+	}
+
+	if err := s.saveContainerRecord(record); err != nil {
+		return nil, fmt.Errorf("failed to save container state: %v", err)
+	}
 
 	s.logger.Info("Created container: %s", containerID)
 	return &CreateContainerResponse{ContainerId: containerID}, nil
@@ -237,8 +297,14 @@ func (s *MinimalRuntimeService) CreateContainer(ctx context.Context, req *Create
 func (s *MinimalRuntimeService) StartContainer(ctx context.Context, req *StartContainerRequest) (*StartContainerResponse, error) {
 	s.logger.Info("CRI StartContainer called for container: %s", req.ContainerId)
 
-	// For minimal implementation, just log the action
+	// For minimal implementation, just record the new state
 	// In a full implementation, this would start the actual container
+	if err := s.updateContainerRecord(req.ContainerId, func(record *containerRecord) {
+		record.State = ContainerStateRunning
+		record.StartedAt = time.Now().UnixNano()
+	}); err != nil {
+		return nil, err
+	}
 
 	return &StartContainerResponse{}, nil
 }
@@ -247,8 +313,16 @@ func (s *MinimalRuntimeService) StartContainer(ctx context.Context, req *StartCo
 func (s *MinimalRuntimeService) StopContainer(ctx context.Context, req *StopContainerRequest) (*StopContainerResponse, error) {
 	s.logger.Info("CRI StopContainer called for container: %s", req.ContainerId)
 
-	// For minimal implementation, just log the action
+	// For minimal implementation, just record the new state
 	// In a full implementation, this would stop the actual container
+	if err := s.updateContainerRecord(req.ContainerId, func(record *containerRecord) {
+		if record.State == ContainerStateRunning {
+			record.State = ContainerStateExited
+			record.FinishedAt = time.Now().UnixNano()
+		}
+	}); err != nil {
+		return nil, err
+	}
 
 	return &StopContainerResponse{}, nil
 }
@@ -257,8 +331,11 @@ func (s *MinimalRuntimeService) StopContainer(ctx context.Context, req *StopCont
 func (s *MinimalRuntimeService) RemoveContainer(ctx context.Context, req *RemoveContainerRequest) (*RemoveContainerResponse, error) {
 	s.logger.Info("CRI RemoveContainer called for container: %s", req.ContainerId)
 
-	// For minimal implementation, just log the action
+	// For minimal implementation, just forget the container
 	// In a full implementation, this would remove the actual container
+	if err := os.Remove(s.containerStateFile(req.ContainerId)); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove container state: %v", err)
+	}
 
 	return &RemoveContainerResponse{}, nil
 }
@@ -267,32 +344,44 @@ func (s *MinimalRuntimeService) RemoveContainer(ctx context.Context, req *Remove
 func (s *MinimalRuntimeService) ListContainers(ctx context.Context, req *ListContainersRequest) (*ListContainersResponse, error) {
 	s.logger.Info("CRI ListContainers called")
 
-	// For minimal implementation, return empty list
-	// In a full implementation, this would list actual containers
+	records, err := s.loadContainerRecords()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read containers: %v", err)
+	}
 
-	return &ListContainersResponse{Containers: []*Container{}}, nil
+	containers := []*Container{}
+	for _, record := range records {
+		if req.Filter != nil && !matchesContainerFilter(&record.Container, req.Filter) {
+			continue
+		}
+		container := record.Container
+		containers = append(containers, &container)
+	}
+
+	return &ListContainersResponse{Containers: containers}, nil
 }
 
 // ContainerStatus returns status of the container
 func (s *MinimalRuntimeService) ContainerStatus(ctx context.Context, req *ContainerStatusRequest) (*ContainerStatusResponse, error) {
 	s.logger.Info("CRI ContainerStatus called for container: %s", req.ContainerId)
 
-	// For minimal implementation, return a basic status
-	// In a full implementation, this would return actual container status
+	record, err := s.loadContainerRecord(req.ContainerId)
+	if err != nil {
+		return nil, err
+	}
 
 	status := &ContainerStatus{
-		Id: req.ContainerId,
-		Metadata: &ContainerMetadata{
-			Name:    "test-container",
-			Attempt: 0,
-		},
-		State:     ContainerStateRunning,
-		CreatedAt: time.Now().UnixNano(),
-		StartedAt: time.Now().UnixNano(),
-		Image: &ImageSpec{
-			Image: "alpine:latest",
-		},
-		ImageRef: "alpine:latest",
+		Id:          record.ID,
+		Metadata:    record.Metadata,
+		State:       record.State,
+		CreatedAt:   record.CreatedAt,
+		StartedAt:   record.StartedAt,
+		FinishedAt:  record.FinishedAt,
+		Image:       record.Image,
+		ImageRef:    record.ImageRef,
+		Labels:      record.Labels,
+		Annotations: record.Annotations,
+		LogPath:     record.LogPath,
 	}
 
 	response := &ContainerStatusResponse{
@@ -341,6 +430,14 @@ func (s *MinimalRuntimeService) ListContainerStats(ctx context.Context, req *Lis
 func (s *MinimalRuntimeService) ExecSync(ctx context.Context, req *ExecSyncRequest) (*ExecSyncResponse, error) {
 	s.logger.Info("CRI ExecSync called for container: %s", req.ContainerId)
 
+	record, err := s.loadContainerRecord(req.ContainerId)
+	if err != nil {
+		return nil, err
+	}
+	if record.State != ContainerStateRunning {
+		return nil, fmt.Errorf("container %s is not running", req.ContainerId)
+	}
+
 	return &ExecSyncResponse{
 		Stdout:   []byte("exec not implemented\n"),
 		Stderr:   []byte(""),
@@ -460,3 +557,134 @@ func (s *MinimalRuntimeService) matchesPodSandboxFilter(pod *PodSandbox, filter
 
 	return true
 }
+
+// savePodSandboxConfig saves the config a pod sandbox was created with
+func (s *MinimalRuntimeService) savePodSandboxConfig(podID string, config *PodSandboxConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(s.criBaseDir, "pods", podID, "config.json"), data, 0644)
+}
+
+// loadPodSandboxConfig loads the config a pod sandbox was created with
+func (s *MinimalRuntimeService) loadPodSandboxConfig(podID string) (*PodSandboxConfig, error) {
+	data, err := os.ReadFile(filepath.Join(s.criBaseDir, "pods", podID, "config.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var config PodSandboxConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// containerRecord is what is kept of a container between calls
+type containerRecord struct {
+	Container
+	StartedAt  int64  `json:"started_at,omitempty"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+	LogPath    string `json:"log_path,omitempty"` // absolute, under the pod's log directory
+}
+
+// containerStateFile returns where the record of a container is kept
+func (s *MinimalRuntimeService) containerStateFile(containerID string) string {
+	return filepath.Join(s.criBaseDir, "containers", filepath.Base(containerID)+".json")
+}
+
+// saveContainerRecord saves a container's record to disk
+func (s *MinimalRuntimeService) saveContainerRecord(record *containerRecord) error {
+	if err := os.MkdirAll(filepath.Join(s.criBaseDir, "containers"), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.containerStateFile(record.ID), data, 0644)
+}
+
+// loadContainerRecord loads a container's record from disk
+func (s *MinimalRuntimeService) loadContainerRecord(containerID string) (*containerRecord, error) {
+	data, err := os.ReadFile(s.containerStateFile(containerID))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("container %s not found", containerID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record containerRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// loadContainerRecords loads the records of all containers, oldest first
+func (s *MinimalRuntimeService) loadContainerRecords() ([]*containerRecord, error) {
+	entries, err := os.ReadDir(filepath.Join(s.criBaseDir, "containers"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*containerRecord
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		record, err := s.loadContainerRecord(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			s.logger.Info("Failed to load container %s: %v", entry.Name(), err)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt < records[j].CreatedAt })
+	return records, nil
+}
+
+// updateContainerRecord changes a container's record with update
+func (s *MinimalRuntimeService) updateContainerRecord(containerID string, update func(*containerRecord)) error {
+	record, err := s.loadContainerRecord(containerID)
+	if err != nil {
+		return err
+	}
+
+	update(record)
+	return s.saveContainerRecord(record)
+}
+
+// matchesContainerFilter checks if a container matches the given filter
+func matchesContainerFilter(container *Container, filter *ContainerFilter) bool {
+	if filter.Id != "" && filter.Id != container.ID {
+		return false
+	}
+
+	if filter.PodSandboxId != "" && filter.PodSandboxId != container.PodSandboxID {
+		return false
+	}
+
+	if filter.State != nil && filter.State.State != container.State {
+		return false
+	}
+
+	for key, value := range filter.LabelSelector {
+		if container.Labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
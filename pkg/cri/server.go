@@ -9,6 +9,7 @@ import (
 	"servin/pkg/image"
 	"servin/pkg/logger"
 	"servin/pkg/state"
+	"servin/pkg/telemetry"
 )
 
 // CRIServer provides HTTP-based CRI API endpoints
@@ -37,7 +38,7 @@ func NewCRIHTTPServer(imageManager *image.Manager, stateManager *state.StateMana
 
 	server.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+		Handler: traceRequests(mux),
 	}
 
 	return server
@@ -55,6 +56,17 @@ func (s *CRIHTTPServer) Stop() error {
 	return s.server.Shutdown(context.Background())
 }
 
+// traceRequests records a span for every API request, continuing the caller's
+// trace when a traceparent header is present
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := telemetry.StartSpan("cri "+r.URL.Path, telemetry.ParseTraceparent(r.Header.Get("traceparent")))
+		span.SetAttribute("http.method", r.Method)
+		next.ServeHTTP(w, r)
+		span.Finish(nil)
+	})
+}
+
 // setupRoutes configures HTTP routes for CRI API
 func (s *CRIHTTPServer) setupRoutes(mux *http.ServeMux) {
 	// Runtime Service endpoints
@@ -76,6 +88,7 @@ func (s *CRIHTTPServer) setupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/v1/runtime/container/list", s.handleListContainers)
 	mux.HandleFunc("/v1/runtime/container/status", s.handleContainerStatus)
 	mux.HandleFunc("/v1/runtime/container/stats", s.handleContainerStats)
+	mux.HandleFunc("/v1/runtime/container/exec_sync", s.handleExecSync)
 
 	// Image Service endpoints
 	mux.HandleFunc("/v1/image/list", s.handleListImages)
@@ -400,6 +413,28 @@ func (s *CRIHTTPServer) handleContainerStats(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(resp)
 }
 
+func (s *CRIHTTPServer) handleExecSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExecSyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.runtimeService.ExecSync(r.Context(), &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
 // Image Service handlers
 
 func (s *CRIHTTPServer) handleListImages(w http.ResponseWriter, r *http.Request) {
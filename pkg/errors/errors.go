@@ -122,7 +122,7 @@ func getStackTrace() string {
 
 // IsType checks if an error is of a specific type
 func IsType(err error, errType ErrorType) bool {
-	if servinErr, ok := err.(*ServinError); ok {
+	if servinErr, ok := As(err); ok {
 		return servinErr.Type == errType
 	}
 	return false
@@ -130,7 +130,7 @@ func IsType(err error, errType ErrorType) bool {
 
 // GetType returns the error type if it's a ServinError
 func GetType(err error) (ErrorType, bool) {
-	if servinErr, ok := err.(*ServinError); ok {
+	if servinErr, ok := As(err); ok {
 		return servinErr.Type, true
 	}
 	return "", false
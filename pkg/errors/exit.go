@@ -0,0 +1,111 @@
+package errors
+
+import (
+	"encoding/json"
+	stderrors "errors"
+)
+
+// Exit codes of the servin CLI by error category, so scripts and the GUI
+// can branch on the kind of failure instead of matching messages
+const (
+	ExitOK = 0
+	// ExitFailure is a runtime failure, or an error without a category
+	ExitFailure = 1
+	// ExitUsage is an invalid command line or config (VALIDATION, CONFIG)
+	ExitUsage        = 2
+	ExitNotFound     = 3
+	ExitConflict     = 4
+	ExitPermission   = 5
+	ExitConnectivity = 6 // NETWORK: a registry, the VM or the daemon is unreachable
+)
+
+// categories names the exit codes in error reports
+var categories = map[int]string{
+	ExitFailure:      "failure",
+	ExitUsage:        "usage",
+	ExitNotFound:     "not_found",
+	ExitConflict:     "conflict",
+	ExitPermission:   "permission",
+	ExitConnectivity: "connectivity",
+}
+
+// As returns the first ServinError in err's chain, following errors wrapped
+// with %w
+func As(err error) (*ServinError, bool) {
+	var servinErr *ServinError
+	if stderrors.As(err, &servinErr) {
+		return servinErr, true
+	}
+	return nil, false
+}
+
+// ExitCode returns the exit code for err: 0 for nil, the code of its
+// category for a ServinError and ExitFailure otherwise
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	servinErr, ok := As(err)
+	if !ok {
+		return ExitFailure
+	}
+	switch servinErr.Type {
+	case ErrTypeValidation, ErrTypeConfig:
+		return ExitUsage
+	case ErrTypeNotFound:
+		return ExitNotFound
+	case ErrTypeConflict:
+		return ExitConflict
+	case ErrTypePermission:
+		return ExitPermission
+	case ErrTypeNetwork:
+		return ExitConnectivity
+	}
+	return ExitFailure
+}
+
+// Report is the machine-readable form of an error
+type Report struct {
+	// Category and ExitCode are what callers branch on; Type is the
+	// finer-grained error type, empty for errors without one
+	Category  string                 `json:"category"`
+	ExitCode  int                    `json:"exit_code"`
+	Type      ErrorType              `json:"type,omitempty"`
+	Operation string                 `json:"operation,omitempty"`
+	Message   string                 `json:"message"`
+	Cause     string                 `json:"cause,omitempty"`
+	Context   map[string]interface{} `json:"context,omitempty"`
+}
+
+// NewReport describes err for machines
+func NewReport(err error) Report {
+	code := ExitCode(err)
+	report := Report{Category: categories[code], ExitCode: code, Message: err.Error()}
+	if servinErr, ok := As(err); ok {
+		report.Type = servinErr.Type
+		report.Operation = servinErr.Operation
+		if servinErr == err {
+			report.Message = servinErr.Message
+		}
+		if servinErr.Cause != nil {
+			report.Cause = servinErr.Cause.Error()
+		}
+		if len(servinErr.Context) > 0 {
+			report.Context = servinErr.Context
+		}
+	}
+	return report
+}
+
+// JSON renders the report of err as one line of JSON, {"error": {...}}
+func JSON(err error) []byte {
+	data, marshalErr := json.Marshal(struct {
+		Error Report `json:"error"`
+	}{NewReport(err)})
+	if marshalErr != nil {
+		data, _ = json.Marshal(map[string]interface{}{"error": map[string]interface{}{
+			"category": categories[ExitFailure], "exit_code": ExitFailure, "message": err.Error(),
+		}})
+	}
+	return append(data, '\n')
+}
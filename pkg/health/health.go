@@ -0,0 +1,295 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health status values
+const (
+	StatusNone      = "none"
+	StatusStarting  = "starting"
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Defaults applied when a HEALTHCHECK omits an option
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultTimeout  = 30 * time.Second
+	DefaultRetries  = 3
+
+	// maxLogEntries is the number of probe results kept in container state
+	maxLogEntries = 5
+	// maxOutputLen truncates probe output stored in container state
+	maxOutputLen = 4096
+)
+
+// Config describes how to probe a container. Test is either ["NONE"],
+// ["CMD", arg...] or ["CMD-SHELL", command].
+type Config struct {
+	Test        []string      `json:"test"`
+	Interval    time.Duration `json:"interval,omitempty"`
+	Timeout     time.Duration `json:"timeout,omitempty"`
+	StartPeriod time.Duration `json:"start_period,omitempty"`
+	Retries     int           `json:"retries,omitempty"`
+}
+
+// Result is the outcome of a single probe
+type Result struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exit_code"`
+	Output   string    `json:"output"`
+}
+
+// State is the health of a container as tracked by the probe loop
+type State struct {
+	Status        string   `json:"status"`
+	FailingStreak int      `json:"failing_streak"`
+	Log           []Result `json:"log,omitempty"`
+}
+
+// Disabled reports whether the config turns health checking off
+func (c *Config) Disabled() bool {
+	return c == nil || len(c.Test) == 0 || c.Test[0] == "NONE"
+}
+
+// Command returns the argv to execute for the probe
+func (c *Config) Command() []string {
+	if c.Disabled() {
+		return nil
+	}
+	switch c.Test[0] {
+	case "CMD":
+		return c.Test[1:]
+	case "CMD-SHELL":
+		return []string{"/bin/sh", "-c", strings.Join(c.Test[1:], " ")}
+	default:
+		return c.Test
+	}
+}
+
+// String returns the probe command for display
+func (c *Config) String() string {
+	if c.Disabled() {
+		return "NONE"
+	}
+	return strings.Join(c.Test[1:], " ")
+}
+
+// withDefaults returns a copy of the config with unset options filled in
+func (c *Config) withDefaults() Config {
+	cfg := *c
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = DefaultRetries
+	}
+	return cfg
+}
+
+// ParseInstruction parses the arguments of a HEALTHCHECK instruction:
+//
+//	HEALTHCHECK [--interval=D] [--timeout=D] [--start-period=D] [--retries=N] CMD command
+//	HEALTHCHECK NONE
+func ParseInstruction(args []string) (*Config, error) {
+	config := &Config{}
+
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "--"); i++ {
+		name, value, ok := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		if !ok {
+			return nil, fmt.Errorf("HEALTHCHECK option '%s' requires a value", args[i])
+		}
+
+		var err error
+		switch name {
+		case "interval":
+			config.Interval, err = parsePositiveDuration(value)
+		case "timeout":
+			config.Timeout, err = parsePositiveDuration(value)
+		case "start-period":
+			config.StartPeriod, err = time.ParseDuration(value)
+		case "retries":
+			config.Retries, err = strconv.Atoi(value)
+			if err == nil && config.Retries < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		default:
+			return nil, fmt.Errorf("unknown HEALTHCHECK option '--%s'", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid HEALTHCHECK --%s value '%s': %v", name, value, err)
+		}
+	}
+
+	rest := args[i:]
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("HEALTHCHECK requires CMD or NONE")
+	}
+
+	switch strings.ToUpper(rest[0]) {
+	case "NONE":
+		if len(rest) > 1 || i > 0 {
+			return nil, fmt.Errorf("HEALTHCHECK NONE takes no other arguments")
+		}
+		config.Test = []string{"NONE"}
+	case "CMD":
+		if len(rest) < 2 {
+			return nil, fmt.Errorf("HEALTHCHECK CMD requires a command")
+		}
+		command := strings.Join(rest[1:], " ")
+
+		// Exec form: CMD ["executable", "arg"]
+		var argv []string
+		if strings.HasPrefix(command, "[") && json.Unmarshal([]byte(command), &argv) == nil {
+			if len(argv) == 0 {
+				return nil, fmt.Errorf("HEALTHCHECK CMD requires a command")
+			}
+			config.Test = append([]string{"CMD"}, argv...)
+		} else {
+			config.Test = []string{"CMD-SHELL", command}
+		}
+	default:
+		return nil, fmt.Errorf("HEALTHCHECK expects CMD or NONE, got '%s'", rest[0])
+	}
+
+	return config, nil
+}
+
+func parsePositiveDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, nil
+}
+
+// ProbeFunc executes a probe command inside the container and returns its exit code and output
+type ProbeFunc func(ctx context.Context, argv []string) (int, string, error)
+
+// Monitor runs a container's health probe on an interval
+type Monitor struct {
+	config  Config
+	probe   ProbeFunc
+	update  func(*State)
+	started time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewMonitor creates a monitor that reports every state change through update
+func NewMonitor(config *Config, probe ProbeFunc, update func(*State)) *Monitor {
+	return &Monitor{
+		config: config.withDefaults(),
+		probe:  probe,
+		update: update,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start launches the probe loop in the background
+func (m *Monitor) Start() {
+	m.started = time.Now()
+	go m.run()
+}
+
+// Stop ends the probe loop. It is safe to call more than once.
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
+func (m *Monitor) run() {
+	state := &State{Status: StatusStarting}
+	m.update(state)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			result := m.runProbe()
+
+			// Don't report results for a container that has already exited
+			select {
+			case <-m.stop:
+				return
+			default:
+			}
+
+			state.record(result, &m.config, m.started)
+			m.update(state)
+		}
+	}
+}
+
+// runProbe executes the probe once, enforcing the timeout
+func (m *Monitor) runProbe() Result {
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	result := Result{Start: time.Now()}
+	exitCode, output, err := m.probe(ctx, m.config.Command())
+	result.End = time.Now()
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.ExitCode = -1
+		result.Output = fmt.Sprintf("Health check exceeded timeout (%v)", m.config.Timeout)
+	case err != nil:
+		result.ExitCode = -1
+		result.Output = err.Error()
+	default:
+		result.ExitCode = exitCode
+		result.Output = output
+	}
+
+	if len(result.Output) > maxOutputLen {
+		result.Output = result.Output[:maxOutputLen]
+	}
+
+	return result
+}
+
+// record updates the state with a probe result. Failures during the start
+// period do not count towards the retry limit.
+func (s *State) record(result Result, config *Config, started time.Time) {
+	s.Log = append(s.Log, result)
+	if len(s.Log) > maxLogEntries {
+		s.Log = s.Log[len(s.Log)-maxLogEntries:]
+	}
+
+	if result.ExitCode == 0 {
+		s.Status = StatusHealthy
+		s.FailingStreak = 0
+		return
+	}
+
+	if s.Status == StatusStarting && result.Start.Sub(started) < config.StartPeriod {
+		return
+	}
+
+	s.FailingStreak++
+	if s.FailingStreak >= config.Retries {
+		s.Status = StatusUnhealthy
+	}
+}
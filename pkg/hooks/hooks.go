@@ -0,0 +1,126 @@
+// Package hooks runs per-container lifecycle hooks: host commands run at
+// points in a container's life, such as registering it with a service
+// discovery tool after it starts.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"servin/pkg/errors"
+	"servin/pkg/logger"
+)
+
+// Hook events
+const (
+	// PreStart runs before the container process starts. A failing
+	// pre-start hook prevents the container from starting.
+	PreStart = "pre-start"
+	// PostStart runs once the container process has started
+	PostStart = "post-start"
+	// PostStop runs after the container process exits
+	PostStop = "post-stop"
+	// PostRemove runs after the container is removed
+	PostRemove = "post-remove"
+)
+
+// Events lists the hook events in the order they occur
+var Events = []string{PreStart, PostStart, PostStop, PostRemove}
+
+// Timeout bounds how long a hook may run
+const Timeout = 30 * time.Second
+
+// Hook is a command run on the host at a container lifecycle event
+type Hook struct {
+	Event   string `json:"event"`
+	Command string `json:"command"`
+	// Dir is the directory the hook runs in, so relative paths resolve
+	// from where the container was created
+	Dir string `json:"dir,omitempty"`
+}
+
+// Parse parses a hook in the form used by --hook ("pre-start=./script.sh").
+// The command runs through the shell, so it may include arguments.
+func Parse(spec string) (Hook, error) {
+	event, command, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(command) == "" {
+		return Hook{}, errors.NewValidationError("hooks.Parse",
+			fmt.Sprintf("invalid hook '%s', expected EVENT=COMMAND", spec))
+	}
+
+	event = strings.TrimSpace(event)
+	valid := false
+	for _, e := range Events {
+		valid = valid || e == event
+	}
+	if !valid {
+		return Hook{}, errors.NewValidationError("hooks.Parse",
+			fmt.Sprintf("unknown hook event '%s' (expected one of %s)", event, strings.Join(Events, ", ")))
+	}
+
+	dir, _ := os.Getwd()
+	return Hook{Event: event, Command: command, Dir: dir}, nil
+}
+
+// Run runs the hooks for an event in order. Each hook receives env on top
+// of servin's environment and payload on stdin. Pre-start hooks stop at the
+// first failure; hooks for other events all run and their errors are combined.
+func Run(hooks []Hook, event string, env []string, payload []byte) error {
+	var failures []string
+	for _, hook := range hooks {
+		if hook.Event != event {
+			continue
+		}
+
+		if err := run(hook, env, payload); err != nil {
+			if event == PreStart {
+				return err
+			}
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func run(hook Hook, env []string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", hook.Command)
+	}
+	cmd.Dir = hook.Dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(payload)
+
+	logger.Debug("Running %s hook: %s", hook.Event, hook.Command)
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		logger.Debug("%s hook output: %s", hook.Event, strings.TrimSpace(string(output)))
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s hook '%s' timed out after %v", hook.Event, hook.Command, Timeout)
+	}
+	if err != nil {
+		message := strings.TrimSpace(string(output))
+		if message == "" {
+			return fmt.Errorf("%s hook '%s' failed: %v", hook.Event, hook.Command, err)
+		}
+		return fmt.Errorf("%s hook '%s' failed: %v: %s", hook.Event, hook.Command, err, message)
+	}
+	return nil
+}
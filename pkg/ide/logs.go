@@ -0,0 +1,13 @@
+package ide
+
+import "servin/pkg/logs"
+
+// logRecords turns lines read from one of a container's log files into
+// records
+func logRecords(f *logs.File, lines []string) []Record {
+	var records []Record
+	for _, line := range lines {
+		records = append(records, Record{Type: "log", Stream: f.Stream, Line: line})
+	}
+	return records
+}
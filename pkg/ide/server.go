@@ -0,0 +1,369 @@
+// Package ide serves the endpoint IDE extensions use to follow a container:
+// a long-poll for its lifecycle events and a stream of its logs. The
+// daemon runs it with --ide-listen; the handshake is documented in
+// docs/cli.md.
+package ide
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"servin/pkg/logger"
+	"servin/pkg/logs"
+	"servin/pkg/state"
+)
+
+// ProtocolVersion is the version of the endpoint's protocol. It changes only
+// when existing fields or endpoints change meaning.
+const ProtocolVersion = 1
+
+// ProtocolHeader carries the protocol version the client speaks. Every
+// request but the handshake must send it. Browsers cannot send a custom
+// header cross-origin without a preflight, which the server never answers,
+// so web pages cannot read container logs through the endpoint.
+const ProtocolHeader = "Servin-IDE-Protocol"
+
+// Capabilities are the features of this server, listed in the handshake
+const (
+	CapabilityEvents = "events"
+	CapabilityLogs   = "logs"
+)
+
+// pollInterval is how often state and log files are checked for changes
+const pollInterval = 100 * time.Millisecond
+
+// Long-poll timeouts: the default and the most a client may ask for
+const (
+	defaultPollTimeout = 30 * time.Second
+	maxPollTimeout     = 5 * time.Minute
+)
+
+// StatusRemoved is the status of an event for a container that was removed
+const StatusRemoved = "removed"
+
+// Handshake is the response of GET /ide/v1/handshake
+type Handshake struct {
+	Protocol       int      `json:"protocol"`
+	Capabilities   []string `json:"capabilities"`
+	MaxPollTimeout string   `json:"max_poll_timeout"`
+}
+
+// Event is the lifecycle state of a container. Revision identifies the
+// state; a client passes the last revision it saw to wait for the next.
+type Event struct {
+	Revision     string    `json:"revision"`
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	ExitCode     int       `json:"exit_code"`
+	RestartCount int       `json:"restart_count"`
+	Health       string    `json:"health,omitempty"`
+	Started      time.Time `json:"started,omitzero"`
+	Finished     time.Time `json:"finished,omitzero"`
+}
+
+// Record is one line of a log stream: a log line, or the final exit event
+// of a followed container
+type Record struct {
+	Type   string `json:"type"` // "log" or "exit"
+	Stream string `json:"stream,omitempty"`
+	Line   string `json:"line,omitempty"`
+	Event  *Event `json:"event,omitempty"`
+}
+
+// Server serves the IDE endpoint
+type Server struct {
+	sm     *state.StateManager
+	logDir func(containerID string) string
+	server *http.Server
+	ln     net.Listener
+}
+
+// NewServer creates a server on addr. logDir returns the directory holding a
+// container's stdout.log and stderr.log.
+func NewServer(addr string, sm *state.StateManager, logDir func(containerID string) string) *Server {
+	s := &Server{sm: sm, logDir: logDir}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ide/v1/handshake", s.handleHandshake)
+	mux.HandleFunc("GET /ide/v1/containers/{ref}/events", s.requireProtocol(s.handleEvents))
+	mux.HandleFunc("GET /ide/v1/containers/{ref}/logs", s.requireProtocol(s.handleLogs))
+
+	s.server = &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// Listen binds the server's address, so a port in use is reported before
+// the daemon starts
+func (s *Server) Listen() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for IDE clients on %s: %v", s.server.Addr, err)
+	}
+	s.ln = ln
+	return nil
+}
+
+// Serve serves IDE clients until Stop is called
+func (s *Server) Serve() error {
+	logger.Info("Serving IDE clients on %s", s.ln.Addr())
+	if err := s.server.Serve(s.ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Stop closes the server and the streams it serves
+func (s *Server) Stop() error {
+	return s.server.Shutdown(context.Background())
+}
+
+// requireProtocol rejects requests that do not speak this protocol version
+func (s *Server) requireProtocol(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version := r.Header.Get(ProtocolHeader)
+		if version == "" {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("missing %s header, see /ide/v1/handshake", ProtocolHeader))
+			return
+		}
+		if version != strconv.Itoa(ProtocolVersion) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported protocol version %s (server speaks %d)", version, ProtocolVersion))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleHandshake(w http.ResponseWriter, r *http.Request) {
+	if client := r.URL.Query().Get("client"); client != "" {
+		logger.Info("IDE client connected: %s", client)
+	}
+	writeJSON(w, http.StatusOK, Handshake{
+		Protocol:       ProtocolVersion,
+		Capabilities:   []string{CapabilityEvents, CapabilityLogs},
+		MaxPollTimeout: maxPollTimeout.String(),
+	})
+}
+
+// handleEvents answers with the container's state as soon as its revision
+// differs from ?after, or 204 No Content when ?timeout passes first
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	id, err := s.resolve(r.PathValue("ref"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	timeout := defaultPollTimeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		timeout, err = time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid timeout '%s'", value))
+			return
+		}
+		if timeout > maxPollTimeout {
+			timeout = maxPollTimeout
+		}
+	}
+
+	after := r.URL.Query().Get("after")
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	watch := s.watch(id)
+	for {
+		if event := watch.next(); event.Revision != after {
+			writeJSON(w, http.StatusOK, event)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleLogs streams the container's logs as newline-delimited JSON records.
+// ?tail=N starts with the last N lines of each stream; with ?follow=1 the
+// stream stays open while the container runs and ends with an exit record.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	id, err := s.resolve(r.PathValue("ref"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	tail := -1
+	if value := r.URL.Query().Get("tail"); value != "" && value != "all" {
+		tail, err = strconv.Atoi(value)
+		if err != nil || tail < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid tail '%s'", value))
+			return
+		}
+	}
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+
+	streams := logs.Files(s.logDir(id))
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	send := func(records []Record) bool {
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return false
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	for _, f := range streams {
+		if !send(logRecords(f, f.ReadTail(tail))) {
+			return
+		}
+	}
+	if !follow {
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	watch := s.watch(id)
+	for {
+		// Read the logs before checking the state, so the last lines a
+		// container writes are sent before its exit record
+		var records []Record
+		for _, f := range streams {
+			records = append(records, logRecords(f, f.ReadNew())...)
+		}
+		event := watch.next()
+		if event.Status != state.StatusRunning {
+			for _, f := range streams {
+				records = append(records, logRecords(f, f.ReadNew())...)
+			}
+			records = append(records, Record{Type: "exit", Event: &event})
+			send(records)
+			return
+		}
+		if len(records) > 0 && !send(records) {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolve returns the ID of a container given by ID, short ID or name
+func (s *Server) resolve(ref string) (string, error) {
+	if _, err := s.sm.LoadContainer(ref); err == nil {
+		return ref, nil
+	}
+	if id, err := s.sm.FindContainerByShortID(ref); err == nil {
+		return id, nil
+	}
+	if id, err := s.sm.FindContainerByName(ref); err == nil {
+		return id, nil
+	}
+	return "", fmt.Errorf("no container found with ID or name '%s'", ref)
+}
+
+// watcher follows a container's state file, reloading it only when it changes
+type watcher struct {
+	sm      *state.StateManager
+	id      string
+	path    string
+	modTime time.Time
+	size    int64
+	event   Event
+	loaded  bool
+}
+
+func (s *Server) watch(id string) *watcher {
+	return &watcher{sm: s.sm, id: id, path: filepath.Join(s.sm.GetStateDir(), id+".json")}
+}
+
+// next returns the container's current state
+func (w *watcher) next() Event {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			w.event = Event{ID: w.id, Name: w.event.Name, Status: StatusRemoved}
+			w.event.Revision = revision(w.event)
+		}
+		return w.event
+	}
+	if w.loaded && info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return w.event
+	}
+
+	container, err := w.sm.LoadContainer(w.id)
+	if err != nil {
+		// The file may be mid-write; try again on the next poll
+		return w.event
+	}
+	w.modTime, w.size, w.loaded = info.ModTime(), info.Size(), true
+	w.event = eventFor(container)
+	return w.event
+}
+
+// eventFor returns the lifecycle event for a container's state
+func eventFor(c *state.ContainerState) Event {
+	event := Event{
+		ID:           c.ID,
+		Name:         c.Name,
+		Status:       c.Status,
+		ExitCode:     c.ExitCode,
+		RestartCount: c.RestartCount,
+		Started:      c.Started,
+		Finished:     c.Finished,
+	}
+	if c.Health != nil {
+		event.Health = c.Health.Status
+	}
+	event.Revision = revision(event)
+	return event
+}
+
+// revision identifies the lifecycle fields of an event
+func revision(e Event) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%d|%d|%s|%d|%d", e.Status, e.ExitCode, e.RestartCount, e.Health,
+		e.Started.UnixNano(), e.Finished.UnixNano())
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
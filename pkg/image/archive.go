@@ -0,0 +1,323 @@
+package image
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// dockerArchiveManifest is an entry of manifest.json in a docker-archive
+// tarball. Paths are relative to the root of the tarball.
+type dockerArchiveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// readDockerArchive stores the config and layers of an image in a
+// docker-archive tarball (which may be gzip-compressed) in the blob store.
+// ref selects the image when the archive holds several.
+func (m *Manager) readDockerArchive(archivePath, ref string) (*imageBlobs, error) {
+	entries, links, err := readArchiveManifest(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := selectArchiveImage(entries, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := m.readArchiveImages(archivePath, []dockerArchiveManifest{entry}, links)
+	if err != nil {
+		return nil, err
+	}
+	if ref != "" {
+		images[0].RepoTags = []string{ref}
+	}
+	return images[0], nil
+}
+
+// readArchiveManifest reads manifest.json of a docker-archive tarball, and
+// the symlinks docker save uses for layers shared between images
+func readArchiveManifest(archivePath string) ([]dockerArchiveManifest, map[string]string, error) {
+	var entries []dockerArchiveManifest
+	links := make(map[string]string)
+	err := walkArchive(archivePath, func(header *tar.Header, r io.Reader) error {
+		name := path.Clean(header.Name)
+		switch {
+		case header.Typeflag == tar.TypeSymlink:
+			links[name] = path.Join(path.Dir(name), header.Linkname)
+		case name == "manifest.json":
+			if err := json.NewDecoder(r).Decode(&entries); err != nil {
+				return fmt.Errorf("failed to parse manifest.json: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if entries == nil {
+		return nil, nil, fmt.Errorf("%s is not a docker-archive: manifest.json not found", archivePath)
+	}
+	return entries, links, nil
+}
+
+// readArchiveImages stores the configs and layers of images in a
+// docker-archive tarball in the blob store. The tarball is read a second
+// time, after manifest.json, which may come after the layers.
+func (m *Manager) readArchiveImages(archivePath string, entries []dockerArchiveManifest, links map[string]string) ([]*imageBlobs, error) {
+	resolve := func(name string) string {
+		name = path.Clean(name)
+		for i := 0; i < 40; i++ {
+			target, ok := links[name]
+			if !ok {
+				break
+			}
+			name = target
+		}
+		return name
+	}
+	wanted := make(map[string]string)
+	for _, entry := range entries {
+		wanted[resolve(entry.Config)] = ""
+		for _, layer := range entry.Layers {
+			wanted[resolve(layer)] = ""
+		}
+	}
+
+	err := walkArchive(archivePath, func(header *tar.Header, r io.Reader) error {
+		name := path.Clean(header.Name)
+		if _, ok := wanted[name]; !ok || header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		// Blobs named by digest, as in newer archives, are checked against it
+		expected := ""
+		if encoded, ok := strings.CutPrefix(name, "blobs/sha256/"); ok {
+			expected = "sha256:" + encoded
+		}
+		digest, _, err := m.PutBlob(r, expected)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		wanted[name] = digest
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, digest := range wanted {
+		if digest == "" {
+			return nil, fmt.Errorf("%s not found in archive", name)
+		}
+	}
+
+	var images []*imageBlobs
+	for _, entry := range entries {
+		configDigest := wanted[resolve(entry.Config)]
+		config, err := m.readConfigBlob(configDigest)
+		if err != nil {
+			return nil, err
+		}
+		blobs := &imageBlobs{
+			Config:   descriptor{MediaType: mediaTypeOCIConfig, Digest: configDigest},
+			DiffIDs:  config.RootFS.DiffIDs,
+			RepoTags: entry.RepoTags,
+		}
+		for _, layer := range entry.Layers {
+			desc, err := m.blobDescriptor(wanted[resolve(layer)])
+			if err != nil {
+				return nil, err
+			}
+			blobs.Layers = append(blobs.Layers, desc)
+		}
+		if blobs.Config.Size, err = m.blobSize(configDigest); err != nil {
+			return nil, err
+		}
+		images = append(images, blobs)
+	}
+	return images, nil
+}
+
+// selectArchiveImage picks the image tagged ref, or the only image
+func selectArchiveImage(entries []dockerArchiveManifest, ref string) (dockerArchiveManifest, error) {
+	if ref == "" {
+		if len(entries) != 1 {
+			return dockerArchiveManifest{}, fmt.Errorf("archive holds %d images, select one with docker-archive:PATH:REF", len(entries))
+		}
+		return entries[0], nil
+	}
+
+	want := ParseReference(ref)
+	for _, entry := range entries {
+		for _, tag := range entry.RepoTags {
+			if ParseReference(tag) == want {
+				return entry, nil
+			}
+		}
+	}
+	return dockerArchiveManifest{}, fmt.Errorf("image %s not found in archive", ref)
+}
+
+// walkArchive calls fn for each entry of a tarball, which may be
+// gzip-compressed
+func walkArchive(archivePath string, fn func(header *tar.Header, r io.Reader) error) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	reader, err := decompress(file)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeDockerArchive writes images as a docker-archive tarball that
+// `docker load` accepts, tagged with their RepoTags. Layers are written
+// uncompressed and named by their diff ID, as Docker does.
+func (m *Manager) writeDockerArchive(archivePath string, images []*imageBlobs, progress io.Writer) error {
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	tmp := archivePath + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+	defer os.Remove(tmp)
+
+	err = m.writeDockerArchiveTo(file, images, progress)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, archivePath)
+}
+
+// writeDockerArchiveTo writes a docker-archive tarball to w. Blobs shared
+// by several images are written once.
+func (m *Manager) writeDockerArchiveTo(w io.Writer, images []*imageBlobs, progress io.Writer) error {
+	tw := tar.NewWriter(w)
+	written := make(map[string]bool)
+	var entries []dockerArchiveManifest
+
+	for _, blobs := range images {
+		entry := dockerArchiveManifest{Config: blobPathInArchive(blobs.Config.Digest), RepoTags: []string{}}
+		for _, tag := range blobs.RepoTags {
+			entry.RepoTags = append(entry.RepoTags, normalizeTag(tag))
+		}
+
+		if !written[entry.Config] {
+			err := writeArchiveEntry(tw, entry.Config, blobs.Config.Size, func(w io.Writer) error {
+				_, err := m.copyBlob(w, blobs.Config.Digest)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			written[entry.Config] = true
+		}
+		for i, layer := range blobs.Layers {
+			name := blobPathInArchive(blobs.DiffIDs[i])
+			entry.Layers = append(entry.Layers, name)
+			if written[name] {
+				continue
+			}
+			fmt.Fprintf(progress, "Writing layer %d/%d %s...\n", i+1, len(blobs.Layers), shortDigest(layer.Digest))
+			if err := m.writeArchiveLayer(tw, name, layer.Digest); err != nil {
+				return err
+			}
+			written[name] = true
+		}
+		entries = append(entries, entry)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	err = writeArchiveEntry(tw, "manifest.json", int64(len(data)), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// writeArchiveLayer adds a layer to a docker-archive uncompressed. The size
+// of the uncompressed tar must be known up front, so compressed layers are
+// decompressed twice.
+func (m *Manager) writeArchiveLayer(tw *tar.Writer, name, digest string) error {
+	uncompressed := func(w io.Writer) (int64, error) {
+		blob, err := m.OpenBlob(digest)
+		if err != nil {
+			return 0, err
+		}
+		defer blob.Close()
+		reader, err := decompress(blob)
+		if err != nil {
+			return 0, err
+		}
+		return io.Copy(w, reader)
+	}
+
+	size, err := uncompressed(io.Discard)
+	if err != nil {
+		return fmt.Errorf("failed to read layer %s: %v", shortDigest(digest), err)
+	}
+	return writeArchiveEntry(tw, name, size, func(w io.Writer) error {
+		_, err := uncompressed(w)
+		return err
+	})
+}
+
+// writeArchiveEntry adds a file of the given size to a tarball
+func writeArchiveEntry(tw *tar.Writer, name string, size int64, write func(io.Writer) error) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: size, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	if err := write(tw); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return nil
+}
+
+// blobPathInArchive returns where a blob is stored in archives and layouts
+func blobPathInArchive(digest string) string {
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algorithm, encoded)
+}
+
+// blobSize returns the size of a blob in the store
+func (m *Manager) blobSize(digest string) (int64, error) {
+	info, err := os.Stat(m.blobPath(digest))
+	if err != nil {
+		return 0, fmt.Errorf("blob %s not found", digest)
+	}
+	return info.Size(), nil
+}
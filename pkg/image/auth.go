@@ -0,0 +1,288 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"servin/pkg/credentials"
+	"servin/pkg/logger"
+)
+
+// tokenExpiryMargin is how long before expiry a bearer token is renewed,
+// so requests in flight do not fail with an expired token. Short-lived
+// tokens are renewed halfway through their lifetime.
+const tokenExpiryMargin = 10 * time.Second
+
+// defaultTokenLifetime is the lifetime the Distribution token spec assumes
+// when the token server does not say
+const defaultTokenLifetime = 60 * time.Second
+
+// tokenClientID identifies servin to token servers
+const tokenClientID = "servin"
+
+// newClientFor returns a client for the registry in ref that uses the
+// credentials stored for it, if any
+func newClientFor(ref Reference) *RegistryClient {
+	client := NewRegistryClient(ref.URL())
+	client.registry = ref.Registry
+
+	cred, err := credentials.Get(ref.Registry)
+	switch {
+	case err == nil:
+		client.credential = cred
+	case !os.IsNotExist(err):
+		logger.Warn("Failed to read credentials for %s: %v", ref.Registry, err)
+	}
+	return client
+}
+
+// Login checks a credential against a registry. It returns the credential
+// to store, which is a refresh token instead of the password when the
+// registry's token server issues one.
+func Login(registry string, cred *credentials.Credential) (*credentials.Credential, error) {
+	ref := Reference{Registry: credentials.Normalize(registry)}
+	client := NewRegistryClient(ref.URL())
+	client.registry = ref.Registry
+	client.credential = cred
+
+	if err := client.authenticate("", ""); err != nil {
+		return nil, err
+	}
+	if client.basic {
+		// Basic auth is only checked when a request is made with it
+		req, err := http.NewRequest(http.MethodGet, client.registryURL+"/v2/", nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("invalid username or password for %s", ref.Registry)
+		}
+	}
+
+	if client.issuedToken != "" {
+		return &credentials.Credential{IdentityToken: client.issuedToken}, nil
+	}
+	return cred, nil
+}
+
+// authenticate prepares the client to access repo with the given actions
+// (e.g. "pull" or "pull,push"). It obtains a bearer token when the registry
+// asks for one, or uses basic auth. Registries that allow anonymous access
+// need neither.
+func (rc *RegistryClient) authenticate(repo, actions string) error {
+	scope := ""
+	if repo != "" {
+		scope = fmt.Sprintf("repository:%s:%s", repo, actions)
+	}
+	return rc.authenticateScope(scope)
+}
+
+// authenticateScope is authenticate for any token scope, such as
+// "registry:catalog:*" for listing the registry's repositories
+func (rc *RegistryClient) authenticateScope(scope string) error {
+	rc.scope = scope
+
+	resp, err := rc.client.Get(rc.registryURL + "/v2/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return nil
+	}
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch {
+	case strings.EqualFold(scheme, "basic"):
+		if rc.credential == nil || rc.credential.Username == "" {
+			return rc.loginRequired()
+		}
+		rc.basic = true
+		return nil
+	case strings.EqualFold(scheme, "bearer") && params["realm"] != "":
+		rc.realm, rc.service = params["realm"], params["service"]
+		return rc.fetchToken()
+	default:
+		return fmt.Errorf("registry requires %s authentication", scheme)
+	}
+}
+
+// fetchToken obtains a bearer token from the token server, exchanging the
+// stored refresh token when there is one, or the username and password
+func (rc *RegistryClient) fetchToken() error {
+	req, err := rc.tokenRequest()
+	if err != nil {
+		return err
+	}
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized && rc.credential == nil {
+		return rc.loginRequired()
+	}
+	// Token servers reject a bad password with 401 and a bad refresh token
+	// with 400
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusBadRequest && rc.credential != nil {
+		return fmt.Errorf("invalid credentials for %s", rc.registry)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		Token        string `json:"token"`
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to decode token response: %v", err)
+	}
+
+	rc.token = token.Token
+	if rc.token == "" {
+		rc.token = token.AccessToken
+	}
+
+	lifetime := defaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	rc.expires = time.Now().Add(lifetime - min(tokenExpiryMargin, lifetime/2))
+
+	if token.RefreshToken != "" {
+		rc.issuedToken = token.RefreshToken
+	}
+	return nil
+}
+
+// tokenRequest builds the request for a bearer token
+func (rc *RegistryClient) tokenRequest() (*http.Request, error) {
+	if rc.credential != nil && rc.credential.IdentityToken != "" {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {rc.credential.IdentityToken},
+			"service":       {rc.service},
+			"client_id":     {tokenClientID},
+		}
+		if rc.scope != "" {
+			form.Set("scope", rc.scope)
+		}
+		req, err := http.NewRequest(http.MethodPost, rc.realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}
+
+	tokenURL, err := url.Parse(rc.realm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token realm: %v", err)
+	}
+	query := tokenURL.Query()
+	if rc.service != "" {
+		query.Set("service", rc.service)
+	}
+	if rc.scope != "" {
+		query.Set("scope", rc.scope)
+	}
+	withPassword := rc.credential != nil && rc.credential.Username != ""
+	if withPassword {
+		query.Set("offline_token", "true")
+		query.Set("client_id", tokenClientID)
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if withPassword {
+		req.SetBasicAuth(rc.credential.Username, rc.credential.Password)
+	}
+	return req, nil
+}
+
+// loginRequired is the error for a registry that refuses anonymous access
+func (rc *RegistryClient) loginRequired() error {
+	return fmt.Errorf("authentication required: run 'servin registry login %s'", rc.registry)
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and parameters
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return scheme, params
+}
+
+// authorize adds the client's credentials to a request
+func (rc *RegistryClient) authorize(req *http.Request) {
+	switch {
+	case rc.basic:
+		req.SetBasicAuth(rc.credential.Username, rc.credential.Password)
+	case rc.token != "":
+		req.Header.Set("Authorization", "Bearer "+rc.token)
+	}
+}
+
+// do sends a request with the client's credentials. Bearer tokens are
+// renewed shortly before they expire, and a request the registry rejects
+// as unauthorized is retried once with a new token, so long pulls and
+// pushes outlive short-lived tokens.
+func (rc *RegistryClient) do(req *http.Request) (*http.Response, error) {
+	if rc.realm != "" && time.Now().After(rc.expires) {
+		if err := rc.fetchToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	rc.authorize(req)
+	resp, err := rc.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || rc.realm == "" {
+		return resp, err
+	}
+
+	// Requests whose body cannot be replayed are not retried
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if err := rc.fetchToken(); err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	rc.authorize(retry)
+	return rc.client.Do(retry)
+}
@@ -0,0 +1,329 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"servin/pkg/credentials"
+)
+
+// manifestAccept lists the manifest and index media types servin reads
+const manifestAccept = "application/vnd.docker.distribution.manifest.v2+json, application/vnd.docker.distribution.manifest.list.v2+json, application/vnd.oci.image.manifest.v1+json, application/vnd.oci.image.index.v1+json"
+
+// maxBrowsePages bounds how many pages of a catalog or tag list are read,
+// so a registry that keeps answering with a next page cannot loop forever
+const maxBrowsePages = 100
+
+// RemoteManifest describes an image in a registry without pulling it
+type RemoteManifest struct {
+	Reference string `json:"reference"`
+	MediaType string `json:"media_type"`
+	// Digest is the digest of the manifest, or of the manifest list for a
+	// multi-platform image
+	Digest string `json:"digest"`
+	// Platforms lists the images of a manifest list
+	Platforms []RemotePlatform `json:"platforms,omitempty"`
+
+	// The rest describe the image itself, or for a manifest list the image
+	// for the platform asked for
+	Platform     string            `json:"platform,omitempty"`
+	Created      time.Time         `json:"created,omitzero"`
+	Size         int64             `json:"size"`
+	ConfigDigest string            `json:"config_digest"`
+	Layers       []RemoteLayer     `json:"layers"`
+	Env          []string          `json:"env,omitempty"`
+	Entrypoint   []string          `json:"entrypoint,omitempty"`
+	Cmd          []string          `json:"cmd,omitempty"`
+	WorkingDir   string            `json:"working_dir,omitempty"`
+	User         string            `json:"user,omitempty"`
+	ExposedPorts []string          `json:"exposed_ports,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// RemotePlatform is an image listed in a manifest list
+type RemotePlatform struct {
+	Platform string `json:"platform"`
+	Digest   string `json:"digest"`
+	Size     int64  `json:"size"`
+}
+
+// RemoteLayer is a layer of an image in a registry
+type RemoteLayer struct {
+	MediaType string `json:"media_type"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Catalog lists the repositories of a registry through the Distribution
+// API's catalog endpoint, at most limit of them (0 for all). Registries
+// such as Docker Hub do not offer the catalog and answer with an error.
+func Catalog(registry string, limit int) ([]string, error) {
+	ref := Reference{Registry: credentials.Normalize(registry)}
+	client := newClientFor(ref)
+	if err := client.authenticateScope("registry:catalog:*"); err != nil {
+		return nil, fmt.Errorf("failed to authenticate with %s: %v", ref.Registry, err)
+	}
+
+	path := "/v2/_catalog"
+	if limit > 0 {
+		path += fmt.Sprintf("?n=%d", limit)
+	}
+	repositories := []string{}
+	err := client.eachPage(path, func(body io.Reader) error {
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return fmt.Errorf("failed to decode catalog: %v", err)
+		}
+		repositories = append(repositories, page.Repositories...)
+		return nil
+	}, func() bool { return limit > 0 && len(repositories) >= limit })
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the repositories of %s: %v", ref.Registry, err)
+	}
+
+	if limit > 0 && len(repositories) > limit {
+		repositories = repositories[:limit]
+	}
+	return repositories, nil
+}
+
+// ListTags lists the tags of a repository in a registry, such as
+// ghcr.io/user/app or alpine, sorted by name. A tag in the reference is
+// ignored.
+func ListTags(repository string) ([]string, error) {
+	ref := ParseReference(repository)
+	client := newClientFor(ref)
+	if err := client.authenticate(ref.Repository, "pull"); err != nil {
+		return nil, fmt.Errorf("failed to authenticate with %s: %v", ref.Registry, err)
+	}
+
+	tags := []string{}
+	err := client.eachPage(fmt.Sprintf("/v2/%s/tags/list", ref.Repository), func(body io.Reader) error {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return fmt.Errorf("failed to decode tag list: %v", err)
+		}
+		tags = append(tags, page.Tags...)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the tags of %s/%s: %v", ref.Registry, ref.Repository, err)
+	}
+
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// InspectRemote describes an image in a registry from its manifest and
+// config, without downloading its layers. For a multi-platform image the
+// platforms are listed, and the image for platform is described.
+func InspectRemote(imageRef string, platform Platform) (*RemoteManifest, error) {
+	if platform.OS == "" {
+		platform = DefaultPlatform()
+	}
+
+	ref := ParseReference(imageRef)
+	client := newClientFor(ref)
+	if err := client.authenticate(ref.Repository, "pull"); err != nil {
+		return nil, fmt.Errorf("failed to authenticate with %s: %v", ref.Registry, err)
+	}
+
+	body, mediaType, digest, err := client.fetchManifest(ref.Repository, ref.Tag)
+	if err != nil {
+		return nil, err
+	}
+	result := &RemoteManifest{
+		Reference: fmt.Sprintf("%s/%s:%s", ref.Registry, ref.Repository, ref.Tag),
+		MediaType: mediaType,
+		Digest:    digest,
+	}
+
+	if mediaType == "application/vnd.docker.distribution.manifest.list.v2+json" ||
+		mediaType == "application/vnd.oci.image.index.v1+json" {
+		var list ManifestList
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest list: %v", err)
+		}
+
+		var platforms []Platform
+		for _, m := range list.Manifests {
+			p := Platform{OS: m.Platform.OS, Architecture: m.Platform.Architecture, Variant: m.Platform.Variant}
+			platforms = append(platforms, p)
+			// Attestations are listed with an unknown platform
+			if p.OS != "unknown" {
+				result.Platforms = append(result.Platforms, RemotePlatform{Platform: p.String(), Digest: m.Digest, Size: m.Size})
+			}
+		}
+
+		i := selectPlatform(platform, platforms)
+		if i < 0 {
+			return result, nil
+		}
+		if body, _, _, err = client.fetchManifest(ref.Repository, list.Manifests[i].Digest); err != nil {
+			return nil, err
+		}
+		result.Platform = platforms[i].String()
+	}
+
+	var manifest ManifestV2
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %v", err)
+	}
+	result.ConfigDigest = manifest.Config.Digest
+	result.Layers = []RemoteLayer{}
+	for _, layer := range manifest.Layers {
+		result.Layers = append(result.Layers, RemoteLayer{MediaType: layer.MediaType, Digest: layer.Digest, Size: layer.Size})
+		result.Size += layer.Size
+	}
+
+	config, err := client.fetchConfig(ref.Repository, manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if result.Platform == "" && config.OS != "" {
+		result.Platform = Platform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}.String()
+	}
+	result.Created = config.Created
+	result.Env = config.Config.Env
+	result.Entrypoint = config.Config.Entrypoint
+	result.Cmd = config.Config.Cmd
+	result.WorkingDir = config.Config.WorkingDir
+	result.User = config.Config.User
+	result.Labels = config.Config.Labels
+	for port := range config.Config.ExposedPorts {
+		result.ExposedPorts = append(result.ExposedPorts, port)
+	}
+	sort.Strings(result.ExposedPorts)
+	return result, nil
+}
+
+// eachPage gets path and the pages that follow it through the Link
+// header, passing each body to read, until there is no next page or done
+// returns true
+func (rc *RegistryClient) eachPage(path string, read func(io.Reader) error, done func() bool) error {
+	next := rc.registryURL + path
+	for page := 0; next != "" && page < maxBrowsePages; page++ {
+		req, err := http.NewRequest(http.MethodGet, next, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := rc.do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusUnauthorized {
+				return rc.loginRequired()
+			}
+			return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		err = read(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if done != nil && done() {
+			return nil
+		}
+		next = nextPage(req.URL, resp.Header.Get("Link"))
+	}
+	return nil
+}
+
+// nextPage returns the URL of the next page in a Link header such as
+// `</v2/_catalog?last=app&n=100>; rel="next"`, or "" for the last page
+func nextPage(base *url.URL, link string) string {
+	target, params, found := strings.Cut(link, ";")
+	if !found || !strings.Contains(params, `rel="next"`) {
+		return ""
+	}
+	target = strings.Trim(strings.TrimSpace(target), "<>")
+	next, err := base.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return next.String()
+}
+
+// fetchManifest gets the manifest or manifest list a tag or digest names,
+// with its media type and digest
+func (rc *RegistryClient) fetchManifest(repo, reference string) ([]byte, string, string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v2/%s/manifests/%s", rc.registryURL, repo, reference), nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	req.Header.Set("Accept", manifestAccept)
+
+	resp, err := rc.do(req)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read manifest: %v", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", "", fmt.Errorf("%s:%s not found", repo, reference)
+	default:
+		return nil, "", "", fmt.Errorf("manifest request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var generic struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal(body, &generic); err != nil {
+		return nil, "", "", fmt.Errorf("failed to decode manifest: %v", err)
+	}
+	mediaType := generic.MediaType
+	if mediaType == "" {
+		mediaType, _, _ = strings.Cut(resp.Header.Get("Content-Type"), ";")
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	}
+	return body, mediaType, digest, nil
+}
+
+// fetchConfig gets an image's config blob, which is small enough to read
+// without the blob store
+func (rc *RegistryClient) fetchConfig(repo, digest string) (*ociConfig, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v2/%s/blobs/%s", rc.registryURL, repo, digest), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rc.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("config request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var config ociConfig
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode image config: %v", err)
+	}
+	return &config, nil
+}
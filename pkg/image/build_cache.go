@@ -0,0 +1,146 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// buildCacheKeyPattern matches the hex keys build steps are cached under
+var buildCacheKeyPattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// BuildCachePruneOptions selects the build cache entries PruneBuildCache
+// removes
+type BuildCachePruneOptions struct {
+	// Until only removes entries last used before this time, if set
+	Until time.Time
+	// DryRun reports what would be removed without removing anything
+	DryRun bool
+}
+
+// BuildCacheReport lists what PruneBuildCache removed, or would remove in a
+// dry run
+type BuildCacheReport struct {
+	Entries int
+	// SpaceReclaimed counts the layers and blobs only the removed entries
+	// referenced
+	SpaceReclaimed int64
+}
+
+// buildCacheDir holds one file per cached build step, named by its cache
+// key. The cache is shared by every namespace, like layers.
+func (m *Manager) buildCacheDir() string {
+	return filepath.Join(m.imageDir, "buildcache")
+}
+
+// CachedBuildStep returns the image state recorded after the build step
+// with the given cache key, or nil if there is none or its layers are gone
+func (m *Manager) CachedBuildStep(key string) *Image {
+	if !buildCacheKeyPattern.MatchString(key) {
+		return nil
+	}
+	path := filepath.Join(m.buildCacheDir(), key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var img Image
+	if err := json.Unmarshal(data, &img); err != nil {
+		return nil
+	}
+	for _, chainID := range img.LayerChain {
+		if _, err := m.GetLayer(chainID); err != nil {
+			return nil
+		}
+	}
+	if img.Metadata == nil {
+		img.Metadata = make(map[string]string)
+	}
+
+	// The modification time records when the entry was last used
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return &img
+}
+
+// CacheBuildStep records the image state after a build step under its
+// cache key
+func (m *Manager) CacheBuildStep(key string, img *Image) error {
+	if !buildCacheKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid build cache key %q", key)
+	}
+	data, err := json.Marshal(img)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.buildCacheDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create build cache: %v", err)
+	}
+	path := filepath.Join(m.buildCacheDir(), key+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write build cache: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// buildCacheEntries returns the cached build steps by key
+func (m *Manager) buildCacheEntries() map[string]*Image {
+	entries := make(map[string]*Image)
+	files, _ := filepath.Glob(filepath.Join(m.buildCacheDir(), "*.json"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var img Image
+		if json.Unmarshal(data, &img) == nil {
+			entries[strings.TrimSuffix(filepath.Base(file), ".json")] = &img
+		}
+	}
+	return entries
+}
+
+// BuildCacheUsage returns the number of cached build steps and the space
+// only they use, which PruneBuildCache would reclaim
+func (m *Manager) BuildCacheUsage() (int, int64, error) {
+	report, err := m.PruneBuildCache(BuildCachePruneOptions{DryRun: true})
+	if err != nil {
+		return 0, 0, err
+	}
+	return report.Entries, report.SpaceReclaimed, nil
+}
+
+// PruneBuildCache removes cached build steps, then the layers and blobs
+// neither an image nor a remaining entry references
+func (m *Manager) PruneBuildCache(opts BuildCachePruneOptions) (*BuildCacheReport, error) {
+	report := &BuildCacheReport{}
+	dropping := make(map[string]bool)
+	for key := range m.buildCacheEntries() {
+		path := filepath.Join(m.buildCacheDir(), key+".json")
+		if !opts.Until.IsZero() {
+			if info, err := os.Stat(path); err != nil || !info.ModTime().Before(opts.Until) {
+				continue
+			}
+		}
+		dropping[key] = true
+		report.Entries++
+		if opts.DryRun {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return report, fmt.Errorf("failed to remove build cache entry: %v", err)
+		}
+	}
+
+	freed, err := m.collectGarbage(nil, dropping, opts.DryRun)
+	report.SpaceReclaimed = freed
+	if err != nil {
+		return report, fmt.Errorf("failed to remove unused layers: %v", err)
+	}
+	return report, nil
+}
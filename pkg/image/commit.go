@@ -0,0 +1,239 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// ContainerChanges describes a container's root filesystem to compare
+// against the image it was created from
+type ContainerChanges struct {
+	// Root is the container's root filesystem on the host
+	Root string
+	// Upper is the overlay's writable layer when Root is an overlay mount.
+	// Only paths it holds can differ from the image, so the rest of the
+	// tree is not compared. Empty compares the whole of Root.
+	Upper string
+	// Exclude lists paths inside the container left out of the layer, such
+	// as mount points and files servin writes at startup. Directories are
+	// excluded with everything below them.
+	Exclude []string
+}
+
+// CreateLayerFromContainer writes the files a container added, changed or
+// removed relative to its image as a new layer on top of the image's
+// layers. Added and changed files keep their owners; removed files become
+// whiteouts.
+func (m *Manager) CreateLayerFromContainer(img *Image, changes ContainerChanges, opts LayerOptions) (*Layer, error) {
+	if len(img.LayerChain) == 0 {
+		return nil, fmt.Errorf("image %s is not stored as layers", img.ID)
+	}
+	fs, err := m.ImageFS(img)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &containerDiff{changes: changes, image: fs, exclude: make(map[string]bool)}
+	for _, p := range changes.Exclude {
+		if name := path.Join(splitPath(p)...); name != "" {
+			d.exclude[name] = true
+		}
+	}
+	if err := d.compareDir(""); err != nil {
+		return nil, fmt.Errorf("failed to compare container filesystem: %v", err)
+	}
+
+	sort.Slice(d.entries, func(i, j int) bool { return d.entries[i].name < d.entries[j].name })
+	return m.writeLayer(img.LayerChain[len(img.LayerChain)-1], d.entries, opts)
+}
+
+// containerDiff collects the layer entries for a container's changes
+type containerDiff struct {
+	changes ContainerChanges
+	image   *LayerFS
+	exclude map[string]bool
+	entries []layerEntry
+}
+
+// compareDir compares the directory dir, a slash-separated path relative to
+// the root, with the image and adds the differences below it
+func (d *containerDiff) compareDir(dir string) error {
+	var upper map[string]bool
+	if d.changes.Upper != "" {
+		entries, err := os.ReadDir(filepath.Join(d.changes.Upper, filepath.FromSlash(dir)))
+		if os.IsNotExist(err) {
+			// Nothing below dir was written
+			return nil
+		} else if err != nil {
+			return err
+		}
+		upper = make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			upper[entry.Name()] = true
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(d.changes.Root, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+	current := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		name := path.Join(dir, entry.Name())
+		current[entry.Name()] = true
+		if d.exclude[name] || (upper != nil && !upper[entry.Name()]) {
+			continue
+		}
+
+		source := d.source(name)
+		info, err := os.Lstat(source)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Removed while the container was compared
+				continue
+			}
+			return err
+		}
+		if info.Mode()&os.ModeSocket != 0 {
+			continue
+		}
+
+		old, err := d.image.Lstat("/" + name)
+		if err != nil {
+			if err := d.addTree(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if changed, err := d.changed(name, info, old); err != nil {
+			return err
+		} else if changed {
+			d.add(name, info)
+		}
+		if info.IsDir() && old.IsDir() {
+			if err := d.compareDir(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Files in the image that the container no longer has are removed
+	if old, err := d.image.ReadDir("/" + dir); err == nil {
+		for _, entryName := range old {
+			name := path.Join(dir, entryName)
+			if !current[entryName] && !d.exclude[name] {
+				d.entries = append(d.entries, layerEntry{
+					name:     path.Join(dir, whiteoutPrefix+entryName),
+					whiteout: true,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// addTree adds a file the image does not have, with everything below it
+func (d *containerDiff) addTree(name string) error {
+	return filepath.Walk(d.source(name), func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(d.changes.Root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.exclude[rel] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSocket == 0 {
+			d.add(rel, info)
+		}
+		return nil
+	})
+}
+
+// add adds a file of the container to the layer
+func (d *containerDiff) add(name string, info os.FileInfo) {
+	uid, gid := owner(info)
+	d.entries = append(d.entries, layerEntry{name: name, source: d.source(name), info: info, uid: uid, gid: gid})
+}
+
+// source returns the host path of a file in the container
+func (d *containerDiff) source(name string) string {
+	return filepath.Join(d.changes.Root, filepath.FromSlash(name))
+}
+
+// changed reports whether a file differs from the image's file of the same
+// name. Directories are compared by mode and owner alone, as their
+// modification times change with their entries.
+func (d *containerDiff) changed(name string, info, old os.FileInfo) (bool, error) {
+	if info.Mode() != old.Mode() {
+		return true, nil
+	}
+	uid, gid := owner(info)
+	oldUID, oldGID := owner(old)
+	if uid != oldUID || gid != oldGID {
+		return true, nil
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(d.source(name))
+		if err != nil {
+			return false, err
+		}
+		oldLink, err := d.image.Readlink("/" + name)
+		return link != oldLink, err
+	case info.Mode().IsRegular():
+		if info.Size() != old.Size() {
+			return true, nil
+		}
+		return d.contentChanged(name)
+	}
+	return false, nil
+}
+
+// contentChanged compares a regular file with the image's file of the same
+// size byte by byte
+func (d *containerDiff) contentChanged(name string) (bool, error) {
+	f, err := os.Open(d.source(name))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	old, err := d.image.Open("/" + name)
+	if err != nil {
+		return false, err
+	}
+	defer old.Close()
+
+	buf, oldBuf := make([]byte, 32*1024), make([]byte, 32*1024)
+	for {
+		n, err := io.ReadFull(f, buf)
+		oldN, oldErr := io.ReadFull(old, oldBuf)
+		if n != oldN || !bytes.Equal(buf[:n], oldBuf[:oldN]) {
+			return true, nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return oldErr != io.EOF && oldErr != io.ErrUnexpectedEOF, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if oldErr != nil {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package image
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"servin/pkg/errors"
+	"servin/pkg/telemetry"
+)
+
+// Transports name the formats images are converted between
+const (
+	// TransportDockerArchive is a tarball as written by `docker save`
+	TransportDockerArchive = "docker-archive"
+	// TransportOCI is an OCI image layout directory
+	TransportOCI = "oci"
+	// TransportStore is servin's image store
+	TransportStore = "servin"
+)
+
+// ImageLocation is one side of an image conversion, written as
+// docker-archive:PATH[:REF], oci:DIR[:REF] or servin:IMAGE
+type ImageLocation struct {
+	Transport string
+	// Path is the archive file or layout directory
+	Path string
+	// Ref selects or names the image. In an OCI layout it is matched
+	// against the org.opencontainers.image.ref.name annotation.
+	Ref string
+}
+
+// ParseImageLocation parses an image location. The path ends at the first
+// colon after a Windows drive letter; the rest is the reference.
+func ParseImageLocation(spec string) (ImageLocation, error) {
+	transport, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return ImageLocation{}, errors.NewValidationError("image.ParseImageLocation",
+			fmt.Sprintf("invalid image location '%s', expected TRANSPORT:PATH[:REF] or servin:IMAGE", spec))
+	}
+
+	loc := ImageLocation{Transport: transport}
+	switch transport {
+	case TransportStore:
+		loc.Ref = rest
+	case TransportDockerArchive, TransportOCI:
+		start := 0
+		if len(rest) >= 3 && rest[1] == ':' && (rest[2] == '\\' || rest[2] == '/') {
+			start = 2
+		}
+		loc.Path = rest
+		if i := strings.Index(rest[start:], ":"); i >= 0 {
+			loc.Path, loc.Ref = rest[:start+i], rest[start+i+1:]
+		}
+		if loc.Path == "" {
+			return ImageLocation{}, errors.NewValidationError("image.ParseImageLocation",
+				fmt.Sprintf("invalid image location '%s', missing path", spec))
+		}
+	default:
+		return ImageLocation{}, errors.NewValidationError("image.ParseImageLocation",
+			fmt.Sprintf("unknown transport '%s' (expected %s, %s or %s)", transport, TransportDockerArchive, TransportOCI, TransportStore))
+	}
+
+	if transport == TransportStore && loc.Ref == "" {
+		return ImageLocation{}, errors.NewValidationError("image.ParseImageLocation",
+			fmt.Sprintf("invalid image location '%s', missing image", spec))
+	}
+	return loc, nil
+}
+
+// String formats the location as it is parsed
+func (l ImageLocation) String() string {
+	if l.Transport == TransportStore {
+		return l.Transport + ":" + l.Ref
+	}
+	if l.Ref == "" {
+		return l.Transport + ":" + l.Path
+	}
+	return l.Transport + ":" + l.Path + ":" + l.Ref
+}
+
+// ConvertOptions controls image conversion
+type ConvertOptions struct {
+	// Platform selects the image from a multi-platform OCI index.
+	// The zero value selects DefaultPlatform.
+	Platform Platform
+}
+
+// imageBlobs is an image whose config and layers are in a blob store
+type imageBlobs struct {
+	Config  descriptor
+	Layers  []descriptor
+	DiffIDs []string
+	// RepoTags are the names the image had in its source
+	RepoTags []string
+}
+
+// ConvertImage copies an image between a docker-archive tarball, an OCI
+// image layout and the image store. Every blob is checked against its
+// digest, and every layer against the diff ID recorded in the image config.
+func (m *Manager) ConvertImage(src, dst ImageLocation, opts ConvertOptions) (err error) {
+	span := telemetry.StartSpan("image.convert", nil)
+	span.SetAttribute("image.source", src.String())
+	span.SetAttribute("image.destination", dst.String())
+	defer func() { span.Finish(err) }()
+
+	if opts.Platform.OS == "" {
+		opts.Platform = DefaultPlatform()
+	}
+
+	// Conversions that do not involve the store stage blobs in a temporary
+	// store, so they leave nothing behind
+	staging := m
+	if src.Transport != TransportStore && dst.Transport != TransportStore {
+		dir, err := os.MkdirTemp("", "servin-convert-")
+		if err != nil {
+			return fmt.Errorf("failed to create staging directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		staging = &Manager{imageDir: dir}
+	}
+
+	var blobs *imageBlobs
+	switch src.Transport {
+	case TransportDockerArchive:
+		blobs, err = staging.readDockerArchive(src.Path, src.Ref)
+	case TransportOCI:
+		blobs, err = staging.readOCILayout(src.Path, src.Ref, opts.Platform)
+	case TransportStore:
+		blobs, err = m.readStoreImage(src.Ref)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", src, err)
+	}
+
+	// Layers imported into the store are checked as they are extracted
+	if dst.Transport != TransportStore {
+		if err := staging.verifyDiffIDs(blobs); err != nil {
+			return fmt.Errorf("invalid image in %s: %v", src, err)
+		}
+	}
+
+	tags := blobs.RepoTags
+	if dst.Ref != "" {
+		tags = []string{dst.Ref}
+	}
+	name := ""
+	if len(tags) > 0 {
+		name = tags[0]
+	}
+
+	switch dst.Transport {
+	case TransportDockerArchive:
+		blobs.RepoTags = tags
+		err = staging.writeDockerArchive(dst.Path, []*imageBlobs{blobs}, os.Stdout)
+	case TransportOCI:
+		err = staging.writeOCILayout(dst.Path, blobs, name, os.Stdout)
+	case TransportStore:
+		if name == "" {
+			return errors.NewValidationError("image convert",
+				fmt.Sprintf("image in %s has no name, give one with servin:NAME", src))
+		}
+		_, err = m.importImage(blobs, []string{name})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", dst, err)
+	}
+	return nil
+}
+
+// readStoreImage describes an image in the store by its blobs, packing
+// images stored before the layer store into a layer first
+func (m *Manager) readStoreImage(ref string) (*imageBlobs, error) {
+	img, err := m.GetImage(ref)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := m.prepareManifest(img)
+	if err != nil {
+		return nil, err
+	}
+	config, err := m.readConfigBlob(manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := &imageBlobs{Config: manifest.Config, Layers: manifest.Layers, DiffIDs: config.RootFS.DiffIDs}
+	if img.ID == ref || strings.HasPrefix(img.ID, ref) {
+		for _, tag := range img.RepoTags {
+			if tag != "<none>:<none>" {
+				blobs.RepoTags = append(blobs.RepoTags, tag)
+			}
+		}
+	} else {
+		blobs.RepoTags = []string{ref}
+	}
+	return blobs, nil
+}
+
+// verifyDiffIDs checks each layer's uncompressed content against the diff
+// ID in the image config
+func (m *Manager) verifyDiffIDs(blobs *imageBlobs) error {
+	if len(blobs.DiffIDs) != len(blobs.Layers) {
+		return fmt.Errorf("config lists %d layers, manifest has %d", len(blobs.DiffIDs), len(blobs.Layers))
+	}
+	for i, layer := range blobs.Layers {
+		diffID, err := m.layerDiffID(layer.Digest)
+		if err != nil {
+			return err
+		}
+		if diffID != blobs.DiffIDs[i] {
+			return fmt.Errorf("layer %s: diff ID mismatch: expected %s, got %s", shortDigest(layer.Digest), blobs.DiffIDs[i], diffID)
+		}
+	}
+	return nil
+}
+
+// layerDiffID computes the digest of a layer blob's uncompressed tar
+func (m *Manager) layerDiffID(digest string) (string, error) {
+	blob, err := m.OpenBlob(digest)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+
+	reader, err := decompress(blob)
+	if err != nil {
+		return "", fmt.Errorf("layer %s: %v", shortDigest(digest), err)
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", fmt.Errorf("failed to read layer %s: %v", shortDigest(digest), err)
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// importImage extracts an image's layers into the layer store and adds it
+// to the index under tags, or untagged if there are none
+func (m *Manager) importImage(blobs *imageBlobs, tags []string) (*Image, error) {
+	config, err := m.readConfigBlob(blobs.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.RootFS.DiffIDs) != len(blobs.Layers) {
+		return nil, fmt.Errorf("config lists %d layers, manifest has %d", len(config.RootFS.DiffIDs), len(blobs.Layers))
+	}
+
+	var chain []string
+	parent := ""
+	for i, layer := range blobs.Layers {
+		fmt.Printf("Extracting layer %d/%d %s...\n", i+1, len(blobs.Layers), shortDigest(layer.Digest))
+		created, err := m.CreateLayer(parent, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if created.DiffID != config.RootFS.DiffIDs[i] {
+			return nil, fmt.Errorf("layer %s: diff ID mismatch: expected %s, got %s", shortDigest(layer.Digest), config.RootFS.DiffIDs[i], created.DiffID)
+		}
+		chain = append(chain, created.ChainID)
+		parent = created.ChainID
+	}
+
+	img := imageFromConfig(blobs.Config.Digest, config, blobs.Layers, chain)
+	if len(tags) == 0 {
+		return img, m.AddImage(img, "")
+	}
+	for _, tag := range tags {
+		if err := m.AddImage(img, normalizeTag(tag)); err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// imageFromConfig creates the metadata of an image from its config blob
+// and layers
+func imageFromConfig(configDigest string, config *ImageConfigBlob, layers []descriptor, chain []string) *Image {
+	img := &Image{
+		// The image ID is the digest of its config, so storing the same
+		// image again (or under another tag) updates the existing entry
+		ID:           strings.TrimPrefix(configDigest, "sha256:"),
+		Created:      time.Now(),
+		LayerChain:   chain,
+		ConfigDigest: configDigest,
+		RootFSType:   "layers",
+		Config: ImageConfig{
+			Env:          config.Config.Env,
+			Cmd:          config.Config.Cmd,
+			Entrypoint:   config.Config.Entrypoint,
+			WorkingDir:   config.Config.WorkingDir,
+			User:         config.Config.User,
+			Labels:       config.Config.Labels,
+			ExposedPorts: config.Config.ExposedPorts,
+			Volumes:      config.Config.Volumes,
+			Shell:        config.Config.Shell,
+			StopSignal:   config.Config.StopSignal,
+			OnBuild:      config.Config.OnBuild,
+		},
+	}
+	for _, layer := range layers {
+		img.Size += layer.Size
+		img.Layers = append(img.Layers, layer.Digest)
+	}
+	if config.OS != "" {
+		img.Platform = Platform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}.String()
+	}
+	return img
+}
+
+// AddImage saves an image under tag, keeping the tags it already has. The
+// tag moves from any other image that carries it; an image without tags is
+// saved as <none>:<none>. With immutable tags, moving the tag to a
+// different image is refused unless forced. Every change of the tag is
+// recorded in its history.
+func (m *Manager) AddImage(img *Image, tag string) error {
+	if err := m.checkTagMove(tag, img.ID); err != nil {
+		return err
+	}
+
+	img.RepoTags = nil
+	if tag != "" {
+		if err := m.Untag(tag); err != nil {
+			return fmt.Errorf("failed to update tag %s: %v", tag, err)
+		}
+		img.RepoTags = []string{tag}
+	}
+	if existing, err := m.GetImage(img.ID); err == nil && existing.ID == img.ID {
+		for _, other := range existing.RepoTags {
+			if other != tag && other != "<none>:<none>" {
+				img.RepoTags = append(img.RepoTags, other)
+			}
+		}
+	}
+	if len(img.RepoTags) == 0 {
+		img.RepoTags = []string{"<none>:<none>"}
+	}
+
+	if err := m.SaveImage(img); err != nil {
+		return fmt.Errorf("failed to save image: %v", err)
+	}
+	return m.recordTag(tag, img)
+}
+
+// normalizeTag adds the default tag to a reference without one
+func normalizeTag(ref string) string {
+	if strings.LastIndex(ref, ":") <= strings.LastIndex(ref, "/") {
+		return ref + ":latest"
+	}
+	return ref
+}
+
+// decompress returns a reader of r's content, decompressing it if it is
+// gzip-compressed
+func decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
+		}
+		return gzipReader, nil
+	}
+	return buffered, nil
+}
+
+// copyBlob writes a blob from the store to w, checking it against its digest
+func (m *Manager) copyBlob(w io.Writer, digest string) (int64, error) {
+	blob, err := m.OpenBlob(digest)
+	if err != nil {
+		return 0, err
+	}
+	defer blob.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hasher), blob)
+	if err != nil {
+		return size, fmt.Errorf("failed to copy blob %s: %v", shortDigest(digest), err)
+	}
+	if actual := "sha256:" + hex.EncodeToString(hasher.Sum(nil)); actual != digest {
+		return size, fmt.Errorf("blob %s is corrupt: content has digest %s", digest, actual)
+	}
+	return size, nil
+}
@@ -0,0 +1,110 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// fileState is what a snapshot records about one file
+type fileState struct {
+	mode    os.FileMode
+	size    int64
+	modTime int64
+	inode   uint64
+	link    string
+}
+
+// Snapshot records the state of every file under a directory, so the
+// changes made to it afterwards can be written as a layer
+type Snapshot struct {
+	root  string
+	files map[string]fileState
+}
+
+// TakeSnapshot records the files under root
+func TakeSnapshot(root string) (*Snapshot, error) {
+	files, err := scanFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{root: root, files: files}, nil
+}
+
+// scanFiles returns the state of every file under root by slash-separated
+// path relative to root
+func scanFiles(root string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		state := fileState{
+			mode:    info.Mode(),
+			size:    info.Size(),
+			modTime: info.ModTime().UnixNano(),
+			inode:   inode(info),
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			state.link, _ = os.Readlink(file)
+		}
+		files[filepath.ToSlash(rel)] = state
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %v", root, err)
+	}
+	return files, nil
+}
+
+// CreateLayerFromChanges writes the files added, changed or removed under
+// the snapshot's directory since it was taken as a new layer on top of
+// parent. Removed files become whiteouts; a removed directory is a single
+// whiteout.
+func (m *Manager) CreateLayerFromChanges(parent string, before *Snapshot, opts LayerOptions) (*Layer, error) {
+	after, err := scanFiles(before.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []layerEntry
+	for name, state := range after {
+		old, existed := before.files[name]
+		if (existed && old == state) || state.mode&os.ModeSocket != 0 {
+			continue
+		}
+		// A directory whose entries changed is written for its mode and
+		// time; the changed entries are written on their own
+		source := filepath.Join(before.root, filepath.FromSlash(name))
+		info, err := os.Lstat(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		entries = append(entries, layerEntry{name: name, source: source, info: info})
+	}
+
+	for name := range before.files {
+		if _, ok := after[name]; ok {
+			continue
+		}
+		// Only the topmost removed path needs a whiteout
+		if dir := path.Dir(name); dir != "." {
+			if _, parentExists := after[dir]; !parentExists {
+				continue
+			}
+		}
+		entries = append(entries, layerEntry{
+			name:     path.Join(path.Dir(name), whiteoutPrefix+path.Base(name)),
+			whiteout: true,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	return m.writeLayer(parent, entries, opts)
+}
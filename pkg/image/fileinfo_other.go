@@ -0,0 +1,33 @@
+//go:build !windows
+
+package image
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links to the file at path
+func linkCount(path string, info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
+
+// inode returns the inode number of a file, which changes when the file is
+// replaced rather than written in place
+func inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
+
+// owner returns the user and group IDs owning a file
+func owner(info os.FileInfo) (uid, gid int) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid), int(stat.Gid)
+	}
+	return 0, 0
+}
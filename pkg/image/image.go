@@ -8,6 +8,9 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	"servin/pkg/health"
+	"servin/pkg/tenancy"
 )
 
 // Image represents a container image
@@ -21,6 +24,15 @@ type Image struct {
 	Metadata   map[string]string `json:"metadata"`
 	RootFSType string            `json:"rootfs_type"`
 	RootFSPath string            `json:"rootfs_path"`
+
+	// LayerChain lists the chain IDs of the image's layers in the layer
+	// store, base layer first; Layers holds the matching blob digests
+	LayerChain   []string `json:"layer_chain,omitempty"`
+	ConfigDigest string   `json:"config_digest,omitempty"`
+	// Platform is the platform the image was built for (e.g. linux/arm64)
+	Platform string `json:"platform,omitempty"`
+	// LastUsed is when a container was last created or started from the image
+	LastUsed time.Time `json:"last_used,omitempty"`
 }
 
 // ImageConfig holds the configuration for the image
@@ -32,12 +44,33 @@ type ImageConfig struct {
 	User         string              `json:"user"`
 	ExposedPorts map[string]struct{} `json:"exposed_ports"`
 	Labels       map[string]string   `json:"labels"`
+	Healthcheck  *health.Config      `json:"healthcheck,omitempty"`
+
+	// Volumes are the container paths VOLUME declares; a container gets an
+	// anonymous volume at each one it does not mount something at
+	Volumes map[string]struct{} `json:"volumes,omitempty"`
+
+	// Shell runs the shell form of RUN, CMD and ENTRYPOINT (default /bin/sh -c)
+	Shell []string `json:"shell,omitempty"`
+	// StopSignal is sent to stop a container of the image (default SIGTERM)
+	StopSignal string `json:"stop_signal,omitempty"`
+	// OnBuild holds the instructions run by builds FROM this image
+	OnBuild []string `json:"on_build,omitempty"`
 }
 
 // Manager manages container images
 type Manager struct {
 	imageDir  string
 	indexPath string
+	namespace string
+
+	// shared is the system-wide layer cache, if one has been created
+	shared *SharedCache
+
+	// immutableTags refuses to move a tag to another image unless force is
+	// set; see WithImmutableTags
+	immutableTags bool
+	force         bool
 }
 
 // NewManager creates a new image manager
@@ -61,15 +94,56 @@ func NewManager() *Manager {
 		imageDir = "/var/lib/servin/images"
 	}
 
+	return (&Manager{imageDir: imageDir, shared: OpenSharedCache()}).InNamespace(tenancy.Current())
+}
+
+// InNamespace returns an image manager scoped to the given namespace. Each
+// namespace keeps its own index; the default namespace uses the top-level one.
+func (m *Manager) InNamespace(namespace string) *Manager {
+	namespace = tenancy.Normalize(namespace)
+
+	indexPath := filepath.Join(m.imageDir, "index.json")
+	if namespace != tenancy.Default {
+		indexPath = filepath.Join(m.imageDir, "namespaces", namespace, "index.json")
+	}
+
 	return &Manager{
-		imageDir:  imageDir,
-		indexPath: filepath.Join(imageDir, "index.json"),
+		imageDir:  m.imageDir,
+		indexPath: indexPath,
+		namespace: namespace,
+		shared:    m.shared,
+
+		immutableTags: m.immutableTags,
+		force:         m.force,
 	}
 }
 
+// Namespace returns the namespace the manager is scoped to
+func (m *Manager) Namespace() string {
+	return m.namespace
+}
+
 // ensureImageDir creates the image directory if it doesn't exist
 func (m *Manager) ensureImageDir() error {
-	return os.MkdirAll(m.imageDir, 0755)
+	return os.MkdirAll(filepath.Dir(m.indexPath), 0755)
+}
+
+// writeIndex stores the image index of the manager's namespace
+func (m *Manager) writeIndex(images []*Image) error {
+	if err := tenancy.Create(m.namespace); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(images, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal image index: %v", err)
+	}
+
+	if err := os.WriteFile(m.indexPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write image index: %v", err)
+	}
+
+	return nil
 }
 
 // ListImages returns all available images
@@ -147,16 +221,7 @@ func (m *Manager) SaveImage(img *Image) error {
 	}
 
 	// Save updated index
-	data, err := json.MarshalIndent(images, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal image index: %v", err)
-	}
-
-	if err := os.WriteFile(m.indexPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write image index: %v", err)
-	}
-
-	return nil
+	return m.writeIndex(images)
 }
 
 // RemoveImage removes an image by reference
@@ -207,19 +272,22 @@ func (m *Manager) RemoveImage(ref string) error {
 	}
 
 	// Save updated index
-	data, err := json.MarshalIndent(updatedImages, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal image index: %v", err)
+	if err := m.writeIndex(updatedImages); err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(m.indexPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write image index: %v", err)
+	// Drop layers and blobs no other image shares
+	if len(removedImage.LayerChain) > 0 {
+		if _, err := m.GarbageCollect(); err != nil {
+			fmt.Printf("Warning: failed to remove unused layers: %v\n", err)
+		}
 	}
 
 	return nil
 }
 
-// TagImage adds a new tag to an existing image
+// TagImage adds a new tag to an existing image, moving it off any image
+// that carries it unless tags are immutable
 func (m *Manager) TagImage(sourceRef, targetTag string) error {
 	// Find the source image
 	sourceImage, err := m.GetImage(sourceRef)
@@ -227,34 +295,59 @@ func (m *Manager) TagImage(sourceRef, targetTag string) error {
 		return fmt.Errorf("source image not found: %v", err)
 	}
 
-	// Validate target tag format
-	if !strings.Contains(targetTag, ":") {
-		targetTag = targetTag + ":latest"
+	targetTag = normalizeTag(targetTag)
+	if err := m.checkTagMove(targetTag, sourceImage.ID); err != nil {
+		return err
 	}
-
-	// Check if target tag already exists
-	if existingImage, err := m.GetImage(targetTag); err == nil {
-		return fmt.Errorf("tag '%s' already exists on image %s", targetTag, existingImage.ID[:12])
+	if err := m.Untag(targetTag); err != nil {
+		return fmt.Errorf("failed to update tag %s: %v", targetTag, err)
 	}
 
-	// Create a copy of the source image with the new tag
-	newImage := &Image{
-		ID:         sourceImage.ID,                          // Same image ID
-		RepoTags:   append(sourceImage.RepoTags, targetTag), // Add new tag
-		Created:    sourceImage.Created,
-		Size:       sourceImage.Size,
-		Layers:     sourceImage.Layers,
-		Config:     sourceImage.Config,
-		Metadata:   sourceImage.Metadata,
-		RootFSType: sourceImage.RootFSType,
-		RootFSPath: sourceImage.RootFSPath,
+	// Untag may have changed the source image when it carried the tag
+	if sourceImage, err = m.GetImage(sourceImage.ID); err != nil {
+		return fmt.Errorf("source image not found: %v", err)
+	}
+	var tags []string
+	for _, tag := range sourceImage.RepoTags {
+		if tag != "<none>:<none>" {
+			tags = append(tags, tag)
+		}
 	}
+	sourceImage.RepoTags = append(tags, targetTag)
 
 	// Save the updated image
-	if err := m.SaveImage(newImage); err != nil {
+	if err := m.SaveImage(sourceImage); err != nil {
 		return fmt.Errorf("failed to save tagged image: %v", err)
 	}
 
+	return m.recordTag(targetTag, sourceImage)
+}
+
+// Untag removes a tag from the image that carries it, leaving the image
+// dangling if it has no other tags. It is not an error if no image has the tag.
+func (m *Manager) Untag(tag string) error {
+	images, err := m.ListImages()
+	if err != nil {
+		return err
+	}
+
+	for _, img := range images {
+		var kept []string
+		for _, existing := range img.RepoTags {
+			if existing != tag {
+				kept = append(kept, existing)
+			}
+		}
+		if len(kept) == len(img.RepoTags) {
+			continue
+		}
+		if len(kept) == 0 {
+			kept = []string{"<none>:<none>"}
+		}
+		img.RepoTags = kept
+		return m.writeIndex(images)
+	}
+
 	return nil
 }
 
@@ -272,21 +365,20 @@ func (m *Manager) CreateImageFromTarball(tarballPath, name, tag string) (*Image,
 	// Generate image ID
 	imageID := generateImageID(name, tag)
 
-	// Create image directory
-	imagePath := filepath.Join(m.imageDir, imageID)
-	if err := os.MkdirAll(imagePath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create image directory: %v", err)
+	// Store the tarball as a blob and extract it as the image's only layer
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tarball: %v", err)
 	}
-
-	// Extract tarball to image directory
-	if err := extractTarball(tarballPath, imagePath); err != nil {
-		return nil, fmt.Errorf("failed to extract tarball: %v", err)
+	digest, size, err := m.PutBlob(file, "")
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to store tarball: %v", err)
 	}
 
-	// Get tarball size
-	stat, err := os.Stat(tarballPath)
+	layer, err := m.CreateLayer("", digest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat tarball: %v", err)
+		return nil, fmt.Errorf("failed to extract tarball: %v", err)
 	}
 
 	// Create image metadata
@@ -295,10 +387,10 @@ func (m *Manager) CreateImageFromTarball(tarballPath, name, tag string) (*Image,
 		ID:         imageID,
 		RepoTags:   []string{repoTag},
 		Created:    time.Now(),
-		Size:       stat.Size(),
-		Layers:     []string{imageID}, // Simple single-layer for now
+		Size:       size,
+		Layers:     []string{digest},
+		LayerChain: []string{layer.ChainID},
 		RootFSType: "tarball",
-		RootFSPath: imagePath,
 		Config: ImageConfig{
 			Cmd:          []string{"/bin/sh"},
 			WorkingDir:   "/",
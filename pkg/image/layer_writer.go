@@ -0,0 +1,223 @@
+package image
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/errors"
+)
+
+// LayerSource is a file or directory from the host added to a layer. A
+// directory's contents are added below Dest, a path inside the image.
+type LayerSource struct {
+	Path string
+	Dest string
+
+	// UID and GID own the files added; root by default
+	UID, GID int
+	// Mode, if set, replaces the permission bits of the files added
+	Mode os.FileMode
+	// Skip leaves out the files below Path it returns true for, given
+	// their host path, and everything in directories it returns true for
+	Skip func(file string, info os.FileInfo) bool
+}
+
+// LayerOptions controls how layers are written
+type LayerOptions struct {
+	// Epoch clamps file modification times, so files touched after it get
+	// the same timestamp in every build (see SourceDateEpoch)
+	Epoch *time.Time
+}
+
+// SourceDateEpoch returns the time set by the SOURCE_DATE_EPOCH environment
+// variable, used by reproducible builds for timestamps, or nil if unset
+func SourceDateEpoch() (*time.Time, error) {
+	value := os.Getenv("SOURCE_DATE_EPOCH")
+	if value == "" {
+		return nil, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return nil, errors.NewValidationError("image.SourceDateEpoch",
+			fmt.Sprintf("invalid SOURCE_DATE_EPOCH '%s', expected seconds since the Unix epoch", value))
+	}
+	epoch := time.Unix(seconds, 0).UTC()
+	return &epoch, nil
+}
+
+// layerEntry is a file written to a layer tar
+type layerEntry struct {
+	name string
+	// source is the host file, or "" for a parent directory of a Dest
+	source string
+	info   os.FileInfo
+	// whiteout marks an empty file that deletes name from the layers below
+	whiteout bool
+	// uid, gid and mode are set from the LayerSource the file came from
+	uid, gid int
+	mode     os.FileMode
+}
+
+// CreateLayerFromSources writes files from the host as a new layer on top
+// of parent. The tar is normalized so the same files always produce the same
+// layer digest: entries are sorted by name, owners are root, and only
+// modification times (clamped to opts.Epoch) are kept.
+func (m *Manager) CreateLayerFromSources(parent string, sources []LayerSource, opts LayerOptions) (*Layer, error) {
+	entries, err := collectLayerEntries(sources)
+	if err != nil {
+		return nil, err
+	}
+	return m.writeLayer(parent, entries, opts)
+}
+
+// writeLayer stores entries as a compressed layer blob on top of parent
+func (m *Manager) writeLayer(parent string, entries []layerEntry, opts LayerOptions) (*Layer, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		err := writeLayerTar(gz, entries, opts)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	digest, _, err := m.PutBlob(pr, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write layer: %v", err)
+	}
+	return m.CreateLayer(parent, digest)
+}
+
+// collectLayerEntries lists the files of sources by their name in the layer.
+// Parent directories of each Dest are added, and later sources replace
+// earlier ones with the same name.
+func collectLayerEntries(sources []LayerSource) ([]layerEntry, error) {
+	byName := make(map[string]layerEntry)
+	for _, src := range sources {
+		dest := strings.Trim(path.Clean("/"+filepath.ToSlash(src.Dest)), "/")
+
+		for dir := path.Dir(dest); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if _, ok := byName[dir]; !ok {
+				byName[dir] = layerEntry{name: dir}
+			}
+		}
+
+		err := filepath.Walk(src.Path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(src.Path, file)
+			if err != nil {
+				return err
+			}
+			if src.Skip != nil && rel != "." && src.Skip(file, info) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			name := path.Join(dest, filepath.ToSlash(rel))
+			if name == "." || name == "" {
+				// A directory copied to / only adds its contents
+				return nil
+			}
+			byName[name] = layerEntry{name: name, source: file, info: info, uid: src.UID, gid: src.GID, mode: src.Mode}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", src.Path, err)
+		}
+	}
+
+	entries := make([]layerEntry, 0, len(byName))
+	for _, entry := range byName {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// writeLayerTar writes entries as a tar stream with normalized metadata
+func writeLayerTar(w io.Writer, entries []layerEntry, opts LayerOptions) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		modTime := time.Now()
+		if opts.Epoch != nil {
+			modTime = *opts.Epoch
+		}
+		var header *tar.Header
+		if entry.whiteout {
+			header = &tar.Header{Typeflag: tar.TypeReg, Mode: 0644, ModTime: modTime}
+		} else if entry.source == "" {
+			header = &tar.Header{Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime}
+		} else {
+			link := ""
+			if entry.info.Mode()&os.ModeSymlink != 0 {
+				var err error
+				if link, err = os.Readlink(entry.source); err != nil {
+					return err
+				}
+			}
+			fileHeader, err := tar.FileInfoHeader(entry.info, link)
+			if err != nil {
+				return err
+			}
+			header = normalizeHeader(fileHeader, opts)
+			header.Uid, header.Gid = entry.uid, entry.gid
+			if entry.mode != 0 && header.Typeflag != tar.TypeSymlink {
+				header.Mode = int64(entry.mode & os.ModePerm)
+			}
+		}
+
+		header.Name = entry.name
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if entry.source != "" && entry.info.Mode().IsRegular() {
+			f, err := os.Open(entry.source)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// normalizeHeader drops the metadata of a host file that differs between
+// machines and checkouts: owners, access and change times, and
+// modification times after the epoch
+func normalizeHeader(header *tar.Header, opts LayerOptions) *tar.Header {
+	normalized := &tar.Header{
+		Typeflag: header.Typeflag,
+		Linkname: header.Linkname,
+		Size:     header.Size,
+		Mode:     header.Mode & 07777,
+		ModTime:  header.ModTime.Truncate(time.Second),
+		Devmajor: header.Devmajor,
+		Devminor: header.Devminor,
+		Format:   tar.FormatPAX,
+	}
+	if opts.Epoch != nil && normalized.ModTime.After(*opts.Epoch) {
+		normalized.ModTime = *opts.Epoch
+	}
+	return normalized
+}
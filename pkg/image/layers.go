@@ -0,0 +1,648 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"servin/pkg/vfs"
+)
+
+// Layer whiteout markers, as defined by the OCI image layer specification
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// partialBlobMaxIdle is how long an interrupted download is left untouched
+// before garbage collection may remove it
+const partialBlobMaxIdle = time.Hour
+
+// Layer describes one extracted layer in the layer store. Layers are keyed by
+// their chain ID, which identifies the layer together with all layers below
+// it, so images built on the same base share the base layers on disk.
+type Layer struct {
+	ChainID string    `json:"chain_id"`
+	DiffID  string    `json:"diff_id"`
+	Digest  string    `json:"digest"`
+	Parent  string    `json:"parent,omitempty"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// ChainID computes the chain ID of a layer from its parent's chain ID and its
+// own diff ID (the digest of the uncompressed layer tar)
+func ChainID(parent, diffID string) string {
+	if parent == "" {
+		return diffID
+	}
+	sum := sha256.Sum256([]byte(parent + " " + diffID))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// blobPath returns where a content-addressed blob is stored
+func (m *Manager) blobPath(digest string) string {
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	return filepath.Join(m.imageDir, "blobs", algorithm, encoded)
+}
+
+// layerDir returns the directory of a layer in the layer store
+func (m *Manager) layerDir(chainID string) string {
+	algorithm, encoded, _ := strings.Cut(chainID, ":")
+	return filepath.Join(m.imageDir, "layers", algorithm, encoded)
+}
+
+// HasBlob reports whether a blob is already in the store
+func (m *Manager) HasBlob(digest string) bool {
+	if validateDigest(digest) != nil {
+		return false
+	}
+	_, err := os.Stat(m.blobPath(digest))
+	return err == nil
+}
+
+// PutBlob stores content under its sha256 digest. If expected is set the
+// content must match it. Storing a blob that already exists is a no-op.
+func (m *Manager) PutBlob(r io.Reader, expected string) (string, int64, error) {
+	if expected != "" {
+		if err := validateDigest(expected); err != nil {
+			return "", 0, err
+		}
+	}
+
+	tmpDir := filepath.Join(m.imageDir, "blobs", "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create blob directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(tmpDir, "blob-")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create blob: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	tmp.Close()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write blob: %v", err)
+	}
+
+	digest := "sha256:" + hex.EncodeToString(hasher.Sum(nil))
+	if expected != "" && digest != expected {
+		return "", 0, fmt.Errorf("blob digest mismatch: expected %s, got %s", expected, digest)
+	}
+
+	path := m.blobPath(digest)
+	if _, err := os.Stat(path); err == nil {
+		return digest, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create blob directory: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob %s: %v", digest, err)
+	}
+
+	return digest, size, nil
+}
+
+// partialBlobPath returns where an interrupted download of a blob is kept,
+// so a later pull can resume it
+func (m *Manager) partialBlobPath(digest string) string {
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	return filepath.Join(m.imageDir, "blobs", "partial", algorithm+"-"+encoded)
+}
+
+// commitBlob moves a downloaded file into the blob store once its content
+// matches digest. A file that does not match is removed.
+func (m *Manager) commitBlob(path, digest string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open download: %v", err)
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read download: %v", err)
+	}
+
+	if actual := "sha256:" + hex.EncodeToString(hasher.Sum(nil)); actual != digest {
+		os.Remove(path)
+		return fmt.Errorf("blob digest mismatch: expected %s, got %s", digest, actual)
+	}
+
+	dest := m.blobPath(digest)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %v", err)
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return fmt.Errorf("failed to store blob %s: %v", digest, err)
+	}
+	return nil
+}
+
+// OpenBlob opens a blob from the store
+func (m *Manager) OpenBlob(digest string) (*os.File, error) {
+	if err := validateDigest(digest); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(m.blobPath(digest))
+	if err != nil {
+		return nil, fmt.Errorf("blob %s not found", digest)
+	}
+	return file, nil
+}
+
+// GetLayer loads a layer's metadata by chain ID
+func (m *Manager) GetLayer(chainID string) (*Layer, error) {
+	if err := validateDigest(chainID); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(m.layerDir(chainID), "layer.json"))
+	if err != nil {
+		return nil, fmt.Errorf("layer %s not found", chainID)
+	}
+
+	var layer Layer
+	if err := json.Unmarshal(data, &layer); err != nil {
+		return nil, fmt.Errorf("failed to parse layer %s: %v", chainID, err)
+	}
+	return &layer, nil
+}
+
+// CreateLayer extracts a stored layer blob on top of parent (a chain ID, or
+// "" for a base layer). If the resulting layer already exists it is reused.
+func (m *Manager) CreateLayer(parent, digest string) (*Layer, error) {
+	if parent != "" {
+		if _, err := m.GetLayer(parent); err != nil {
+			return nil, fmt.Errorf("parent %v", err)
+		}
+	}
+	if layer := m.linkSharedLayer(parent, digest); layer != nil {
+		return layer, nil
+	}
+
+	blob, err := m.OpenBlob(digest)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+
+	stat, err := blob.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat blob %s: %v", digest, err)
+	}
+
+	// Layers are usually gzip-compressed tars, but plain tars are valid too
+	buffered := bufio.NewReader(blob)
+	var reader io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	layersDir := filepath.Join(m.imageDir, "layers")
+	if err := os.MkdirAll(layersDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create layer directory: %v", err)
+	}
+	tmpDir, err := os.MkdirTemp(layersDir, "tmp-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create layer directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// The diff ID is the digest of the uncompressed tar stream
+	hasher := sha256.New()
+	if err := extractLayer(io.TeeReader(reader, hasher), filepath.Join(tmpDir, "diff")); err != nil {
+		return nil, fmt.Errorf("failed to extract layer %s: %v", digest, err)
+	}
+	// Hash any trailing padding the tar reader did not consume
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return nil, fmt.Errorf("failed to read layer %s: %v", digest, err)
+	}
+
+	diffID := "sha256:" + hex.EncodeToString(hasher.Sum(nil))
+	layer := &Layer{
+		ChainID: ChainID(parent, diffID),
+		DiffID:  diffID,
+		Digest:  digest,
+		Parent:  parent,
+		Size:    stat.Size(),
+		Created: time.Now(),
+	}
+
+	if existing, err := m.GetLayer(layer.ChainID); err == nil {
+		return existing, nil
+	}
+
+	data, err := json.MarshalIndent(layer, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal layer: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "layer.json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write layer metadata: %v", err)
+	}
+
+	dir := m.layerDir(layer.ChainID)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create layer directory: %v", err)
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return nil, fmt.Errorf("failed to store layer %s: %v", layer.ChainID, err)
+	}
+	m.publishLayer(layer)
+
+	return layer, nil
+}
+
+// LayerDirs returns the directories holding an image's filesystem, from the
+// base layer up. Images stored before the layer store have a single directory.
+func (m *Manager) LayerDirs(img *Image) ([]string, error) {
+	if len(img.LayerChain) == 0 {
+		if img.RootFSPath == "" {
+			return nil, fmt.Errorf("image %s has no root filesystem", img.ID)
+		}
+		return []string{img.RootFSPath}, nil
+	}
+
+	dirs := make([]string, 0, len(img.LayerChain))
+	for _, chainID := range img.LayerChain {
+		if _, err := m.GetLayer(chainID); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, filepath.Join(m.layerDir(chainID), "diff"))
+	}
+	return dirs, nil
+}
+
+// ExtractRootFS writes an image's merged filesystem to dest, applying each
+// layer's whiteouts to the layers below it
+func (m *Manager) ExtractRootFS(img *Image, dest string) error {
+	dirs, err := m.LayerDirs(img)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if err := applyWhiteouts(dir, dest); err != nil {
+			return err
+		}
+		if err := copyLayer(dir, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GarbageCollect removes layers and blobs that no image in any namespace
+// and no cached build step references, returning the number of bytes freed
+func (m *Manager) GarbageCollect() (int64, error) {
+	return m.collectGarbage(nil, nil, false)
+}
+
+// collectGarbage removes layers and blobs that no image in any namespace
+// and no cached build step references once the images of the manager's
+// namespace with IDs in removing and the build cache entries with keys in
+// dropping are gone. With dryRun nothing is removed, and the bytes that
+// would be freed are returned.
+func (m *Manager) collectGarbage(removing, dropping map[string]bool, dryRun bool) (int64, error) {
+	indexes, err := m.allImages()
+	if err != nil {
+		return 0, err
+	}
+
+	usedLayers := make(map[string]bool)
+	usedBlobs := make(map[string]bool)
+	for index, images := range indexes {
+		for _, img := range images {
+			if index == m.indexPath && removing[img.ID] {
+				continue
+			}
+			markImageContent(img, usedLayers, usedBlobs)
+		}
+	}
+	for key, img := range m.buildCacheEntries() {
+		if !dropping[key] {
+			markImageContent(img, usedLayers, usedBlobs)
+		}
+	}
+
+	var freed int64
+	if entries, err := os.ReadDir(filepath.Join(m.imageDir, "layers", "sha256")); err == nil {
+		for _, entry := range entries {
+			chainID := "sha256:" + entry.Name()
+			if usedLayers[chainID] {
+				continue
+			}
+			dir := m.layerDir(chainID)
+			freed += dirSize(dir)
+			if dryRun {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				return freed, fmt.Errorf("failed to remove layer %s: %v", chainID, err)
+			}
+		}
+	}
+	if entries, err := os.ReadDir(filepath.Join(m.imageDir, "blobs", "sha256")); err == nil {
+		for _, entry := range entries {
+			digest := "sha256:" + entry.Name()
+			if usedBlobs[digest] {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				freed += info.Size()
+			}
+			if dryRun {
+				continue
+			}
+			if err := os.Remove(m.blobPath(digest)); err != nil {
+				return freed, fmt.Errorf("failed to remove blob %s: %v", digest, err)
+			}
+		}
+	}
+	// Interrupted downloads are dropped too, unless a pull may still be
+	// writing them
+	if entries, err := os.ReadDir(filepath.Join(m.imageDir, "blobs", "partial")); err == nil {
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < partialBlobMaxIdle {
+				continue
+			}
+			freed += info.Size()
+			if dryRun {
+				continue
+			}
+			if err := os.Remove(filepath.Join(m.imageDir, "blobs", "partial", entry.Name())); err != nil {
+				return freed, fmt.Errorf("failed to remove partial download %s: %v", entry.Name(), err)
+			}
+		}
+	}
+
+	return freed, nil
+}
+
+// markImageContent records the layers and blobs an image references
+func markImageContent(img *Image, usedLayers, usedBlobs map[string]bool) {
+	for _, chainID := range img.LayerChain {
+		usedLayers[chainID] = true
+	}
+	for _, digest := range img.Layers {
+		usedBlobs[digest] = true
+	}
+	if img.ConfigDigest != "" {
+		usedBlobs[img.ConfigDigest] = true
+	}
+}
+
+// allImages lists the images of every namespace by index path, since layers
+// are shared between namespaces
+func (m *Manager) allImages() (map[string][]*Image, error) {
+	indexes := []string{filepath.Join(m.imageDir, "index.json")}
+	if matches, err := filepath.Glob(filepath.Join(m.imageDir, "namespaces", "*", "index.json")); err == nil {
+		indexes = append(indexes, matches...)
+	}
+
+	all := make(map[string][]*Image)
+	for _, index := range indexes {
+		data, err := os.ReadFile(index)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image index: %v", err)
+		}
+
+		var images []*Image
+		if err := json.Unmarshal(data, &images); err != nil {
+			return nil, fmt.Errorf("failed to parse image index %s: %v", index, err)
+		}
+		all[index] = images
+	}
+	return all, nil
+}
+
+// validateDigest rejects digests that are not sha256 hex strings, so they can
+// be used safely as paths
+func validateDigest(digest string) error {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" || len(encoded) != 64 {
+		return fmt.Errorf("invalid digest %q", digest)
+	}
+	if _, err := hex.DecodeString(encoded); err != nil {
+		return fmt.Errorf("invalid digest %q", digest)
+	}
+	return nil
+}
+
+// extractLayer unpacks a layer tar into dir, keeping whiteout files as-is.
+// Every entry is written inside dir: what is already at an entry's path is
+// replaced rather than written through, and entries whose directory is
+// reached through a symlink of the layer are skipped.
+func extractLayer(r io.Reader, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tar read error: %v", err)
+		}
+
+		targetPath, err := layerPath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		if targetPath == "" || (targetPath == dir && header.Typeflag != tar.TypeDir) {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return fmt.Errorf("failed to create parent directory for %s: %v", targetPath, err)
+		}
+
+		existing, statErr := os.Lstat(targetPath)
+		if statErr == nil && !existing.IsDir() {
+			// Replace rather than write through what is there, which may be
+			// a symlink out of the layer
+			if err := os.Remove(targetPath); err != nil {
+				return fmt.Errorf("failed to replace %s: %v", targetPath, err)
+			}
+		} else if statErr == nil && header.Typeflag != tar.TypeDir {
+			return fmt.Errorf("cannot overwrite directory %s with a file", targetPath)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)&os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create directory %s: %v", targetPath, err)
+			}
+		case tar.TypeReg:
+			outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, os.FileMode(header.Mode)&os.ModePerm)
+			if err != nil {
+				return fmt.Errorf("failed to create file %s: %v", targetPath, err)
+			}
+			if _, err := io.Copy(outFile, tarReader); err != nil {
+				outFile.Close()
+				return fmt.Errorf("failed to write file %s: %v", targetPath, err)
+			}
+			outFile.Close()
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, targetPath); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %v", targetPath, err)
+			}
+		case tar.TypeLink:
+			linkTarget, err := layerPath(dir, header.Linkname)
+			if err != nil {
+				return err
+			}
+			if linkTarget == "" || linkTarget == dir {
+				continue
+			}
+			if err := os.Link(linkTarget, targetPath); err != nil {
+				return fmt.Errorf("failed to create hard link %s: %v", targetPath, err)
+			}
+		}
+	}
+}
+
+// layerPath returns the path in dir of name, a path in a layer, or "" when
+// the directory it is in is reached through a symlink, which would lead
+// wherever the symlink points
+func layerPath(dir, name string) (string, error) {
+	clean := path.Clean("/" + filepath.ToSlash(name))
+	parent, err := vfs.ResolvePath(dir, path.Dir(clean), true)
+	if err != nil {
+		return "", err
+	}
+	if parent != filepath.Join(dir, filepath.FromSlash(path.Dir(clean))) {
+		return "", nil
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// applyWhiteouts removes the paths a layer deletes from the layers below it
+func applyWhiteouts(layerDir, dest string) error {
+	return filepath.Walk(layerDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+		if !strings.HasPrefix(name, whiteoutPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(layerDir, p)
+		if err != nil {
+			return err
+		}
+		target, err := layerPath(dest, rel)
+		if err != nil || target == "" {
+			// Below a symlink of a lower layer there is nothing to delete:
+			// the layer's own directory replaces the symlink
+			return err
+		}
+		parent := filepath.Dir(target)
+
+		if name == whiteoutOpaque {
+			// An opaque directory hides everything below it
+			entries, _ := os.ReadDir(parent)
+			for _, entry := range entries {
+				if err := os.RemoveAll(filepath.Join(parent, entry.Name())); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		return os.RemoveAll(filepath.Join(parent, strings.TrimPrefix(name, whiteoutPrefix)))
+	})
+}
+
+// copyLayer copies a layer's contents (except whiteouts) over dest
+func copyLayer(layerDir, dest string) error {
+	return filepath.Walk(layerDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), whiteoutPrefix) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(layerDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, relPath)
+
+		switch {
+		case info.IsDir():
+			// A file in a lower layer may be replaced by a directory
+			if existing, err := os.Lstat(target); err == nil && !existing.IsDir() {
+				os.Remove(target)
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.RemoveAll(target)
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			os.RemoveAll(target)
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// dirSize returns the total size of the regular files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,329 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OCI image layout files and annotations
+const (
+	ociLayoutFile    = "oci-layout"
+	ociLayoutVersion = "1.0.0"
+	ociIndexFile     = "index.json"
+
+	mediaTypeOCIIndex   = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	annotationRefName   = "org.opencontainers.image.ref.name"
+	annotationImageName = "io.containerd.image.name"
+)
+
+// ociIndex is the index.json of an OCI image layout, or a nested index
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// ociDescriptor references a manifest or index from an index
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *ociPlatform      `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// readOCILayout stores the config and layers of an image in an OCI image
+// layout in the blob store. ref is matched against the image's ref.name
+// annotation; multi-platform images resolve to platform.
+func (m *Manager) readOCILayout(dir, ref string, platform Platform) (*imageBlobs, error) {
+	index, err := readLayoutIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := selectLayoutImage(index.Manifests, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := m.readLayoutImage(dir, desc, platform)
+	if err != nil {
+		return nil, err
+	}
+	if ref != "" {
+		blobs.RepoTags = []string{ref}
+	}
+	return blobs, nil
+}
+
+// readLayoutIndex reads index.json of an OCI image layout
+func readLayoutIndex(dir string) (*ociIndex, error) {
+	var layout struct {
+		ImageLayoutVersion string `json:"imageLayoutVersion"`
+	}
+	if err := readJSONFile(filepath.Join(dir, ociLayoutFile), &layout); err != nil || layout.ImageLayoutVersion == "" {
+		return nil, fmt.Errorf("%s is not an OCI image layout", dir)
+	}
+	var index ociIndex
+	if err := readJSONFile(filepath.Join(dir, ociIndexFile), &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// readLayoutImage stores the config and layers of the image desc refers to
+// in the blob store. Multi-platform images resolve to platform.
+func (m *Manager) readLayoutImage(dir string, desc ociDescriptor, platform Platform) (*imageBlobs, error) {
+	blobs := &imageBlobs{}
+	if name := layoutImageName(desc); name != "" {
+		blobs.RepoTags = []string{name}
+	}
+
+	if desc.MediaType == mediaTypeOCIIndex || desc.MediaType == mediaTypeDockerList {
+		var nested ociIndex
+		if err := readLayoutBlob(dir, desc.Digest, &nested); err != nil {
+			return nil, err
+		}
+		var platforms []Platform
+		for _, candidate := range nested.Manifests {
+			var p Platform
+			if candidate.Platform != nil {
+				p = Platform{OS: candidate.Platform.OS, Architecture: candidate.Platform.Architecture, Variant: candidate.Platform.Variant}
+			}
+			platforms = append(platforms, p)
+		}
+		i := selectPlatform(platform, platforms)
+		if i < 0 {
+			return nil, fmt.Errorf("no manifest for platform %s", platform)
+		}
+		desc = nested.Manifests[i]
+	}
+
+	var manifest ManifestV2
+	if err := readLayoutBlob(dir, desc.Digest, &manifest); err != nil {
+		return nil, err
+	}
+
+	if err := m.putLayoutBlob(dir, manifest.Config.Digest); err != nil {
+		return nil, err
+	}
+	config, err := m.readConfigBlob(manifest.Config.Digest)
+	if err != nil {
+		return nil, err
+	}
+	blobs.Config = descriptor{MediaType: mediaTypeOCIConfig, Digest: manifest.Config.Digest, Size: manifest.Config.Size}
+	blobs.DiffIDs = config.RootFS.DiffIDs
+
+	for i, layer := range manifest.Layers {
+		fmt.Printf("Copying layer %d/%d %s...\n", i+1, len(manifest.Layers), shortDigest(layer.Digest))
+		if err := m.putLayoutBlob(dir, layer.Digest); err != nil {
+			return nil, err
+		}
+		// Docker media types are replaced with their OCI equivalents
+		stored, err := m.blobDescriptor(layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		blobs.Layers = append(blobs.Layers, stored)
+	}
+	return blobs, nil
+}
+
+// selectLayoutImage picks the image named ref, or the only image
+func selectLayoutImage(manifests []ociDescriptor, ref string) (ociDescriptor, error) {
+	if ref == "" {
+		if len(manifests) != 1 {
+			return ociDescriptor{}, fmt.Errorf("layout holds %d images, select one with oci:DIR:REF", len(manifests))
+		}
+		return manifests[0], nil
+	}
+
+	for _, desc := range manifests {
+		name := desc.Annotations[annotationRefName]
+		if name == ref || desc.Annotations[annotationImageName] == ref {
+			return desc, nil
+		}
+	}
+	for _, desc := range manifests {
+		if name := layoutImageName(desc); name != "" && ParseReference(name) == ParseReference(ref) {
+			return desc, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("image %s not found in layout", ref)
+}
+
+// layoutImageName returns the full name of an image in a layout, if it has
+// one. The ref.name annotation is often just a tag, which is not a name.
+func layoutImageName(desc ociDescriptor) string {
+	if name := desc.Annotations[annotationImageName]; name != "" {
+		return name
+	}
+	if name := desc.Annotations[annotationRefName]; strings.ContainsAny(name, "/:") {
+		return name
+	}
+	return ""
+}
+
+// readLayoutBlob decodes a JSON blob of a layout, checking its digest
+func readLayoutBlob(dir, digest string, v interface{}) error {
+	if err := validateDigest(digest); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(blobPathInArchive(digest))))
+	if err != nil {
+		return fmt.Errorf("blob %s not found in layout", digest)
+	}
+	sum := sha256.Sum256(data)
+	if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != digest {
+		return fmt.Errorf("blob %s is corrupt: content has digest %s", digest, actual)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse blob %s: %v", digest, err)
+	}
+	return nil
+}
+
+// putLayoutBlob copies a blob of a layout into the blob store, checking its
+// digest
+func (m *Manager) putLayoutBlob(dir, digest string) error {
+	if err := validateDigest(digest); err != nil {
+		return err
+	}
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(blobPathInArchive(digest))))
+	if err != nil {
+		return fmt.Errorf("blob %s not found in layout", digest)
+	}
+	defer file.Close()
+	_, _, err = m.PutBlob(file, digest)
+	return err
+}
+
+// writeOCILayout adds an image to an OCI image layout, creating the layout
+// if needed. An image already in the layout under name is replaced.
+func (m *Manager) writeOCILayout(dir string, blobs *imageBlobs, name string, progress io.Writer) error {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return fmt.Errorf("failed to create layout: %v", err)
+	}
+	layoutPath := filepath.Join(dir, ociLayoutFile)
+	if _, err := os.Stat(layoutPath); os.IsNotExist(err) {
+		data, _ := json.Marshal(map[string]string{"imageLayoutVersion": ociLayoutVersion})
+		if err := writeFileAtomic(layoutPath, data); err != nil {
+			return err
+		}
+	}
+
+	manifest := ociManifest{SchemaVersion: 2, MediaType: mediaTypeOCIManifest, Config: blobs.Config, Layers: blobs.Layers}
+	manifest.Config.MediaType = mediaTypeOCIConfig
+	for i, blob := range append([]descriptor{blobs.Config}, blobs.Layers...) {
+		if i > 0 {
+			fmt.Fprintf(progress, "Writing layer %d/%d %s...\n", i, len(blobs.Layers), shortDigest(blob.Digest))
+		}
+		if err := m.writeLayoutBlob(dir, blob.Digest); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	manifestDesc := ociDescriptor{
+		MediaType: mediaTypeOCIManifest,
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Size:      int64(len(data)),
+	}
+	if err := writeFileAtomic(filepath.Join(dir, filepath.FromSlash(blobPathInArchive(manifestDesc.Digest))), data); err != nil {
+		return err
+	}
+
+	config, err := m.readConfigBlob(blobs.Config.Digest)
+	if err != nil {
+		return err
+	}
+	if config.OS != "" {
+		manifestDesc.Platform = &ociPlatform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}
+	}
+	if name != "" {
+		manifestDesc.Annotations = map[string]string{annotationRefName: name}
+	}
+
+	indexPath := filepath.Join(dir, ociIndexFile)
+	index := ociIndex{SchemaVersion: 2, MediaType: mediaTypeOCIIndex}
+	if _, err := os.Stat(indexPath); err == nil {
+		if err := readJSONFile(indexPath, &index); err != nil {
+			return err
+		}
+	}
+	var manifests []ociDescriptor
+	for _, existing := range index.Manifests {
+		if name == "" || existing.Annotations[annotationRefName] != name {
+			manifests = append(manifests, existing)
+		}
+	}
+	index.Manifests = append(manifests, manifestDesc)
+
+	data, err = json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %v", err)
+	}
+	return writeFileAtomic(indexPath, data)
+}
+
+// writeLayoutBlob copies a blob from the store into a layout unless the
+// layout already has it
+func (m *Manager) writeLayoutBlob(dir, digest string) error {
+	path := filepath.Join(dir, filepath.FromSlash(blobPathInArchive(digest)))
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create blob: %v", err)
+	}
+	defer os.Remove(tmp)
+
+	_, err = m.copyBlob(file, digest)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readJSONFile decodes a JSON file
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", filepath.Base(path), err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces a file with data
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,136 @@
+//go:build linux
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Markers kept next to a layer's diff directory once it has been checked
+// for use as an overlay lower directory
+const (
+	overlayDirName   = "overlay"
+	overlayCleanMark = "overlay-clean"
+)
+
+// OverlayLowerDirs returns the directories to stack as overlayfs lower
+// directories for an image, top layer first. Layers that contain OCI whiteout
+// files get a converted copy in overlayfs' own whiteout format (character
+// devices and opaque xattrs); regular files in the copy are hard links, so
+// the conversion costs almost no disk space and happens once per layer.
+func (m *Manager) OverlayLowerDirs(img *Image) ([]string, error) {
+	dirs, err := m.LayerDirs(img)
+	if err != nil {
+		return nil, err
+	}
+
+	lower := make([]string, 0, len(dirs))
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir, err := overlayLayerDir(dirs[i])
+		if err != nil {
+			return nil, err
+		}
+		lower = append(lower, dir)
+	}
+	return lower, nil
+}
+
+// overlayLayerDir returns a version of diffDir that overlayfs can use as a
+// lower directory, converting its whiteouts on first use
+func overlayLayerDir(diffDir string) (string, error) {
+	if filepath.Base(diffDir) != "diff" {
+		// Images stored before the layer store are a plain directory tree
+		return diffDir, nil
+	}
+
+	base := filepath.Dir(diffDir)
+	converted := filepath.Join(base, overlayDirName)
+
+	if _, err := os.Stat(filepath.Join(base, overlayCleanMark)); err == nil {
+		return diffDir, nil
+	}
+	if _, err := os.Stat(converted); err == nil {
+		return converted, nil
+	}
+
+	if !hasWhiteouts(diffDir) {
+		// Nothing to convert; remember that so the layer is not walked again
+		os.WriteFile(filepath.Join(base, overlayCleanMark), nil, 0644)
+		return diffDir, nil
+	}
+
+	tmp := converted + ".tmp"
+	os.RemoveAll(tmp)
+	if err := convertWhiteouts(diffDir, tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to prepare layer for overlay: %v", err)
+	}
+	if err := os.Rename(tmp, converted); err != nil {
+		os.RemoveAll(tmp)
+		return "", fmt.Errorf("failed to prepare layer for overlay: %v", err)
+	}
+	return converted, nil
+}
+
+// hasWhiteouts reports whether a layer directory contains whiteout files
+func hasWhiteouts(dir string) bool {
+	found := false
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasPrefix(info.Name(), whiteoutPrefix) {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+// convertWhiteouts recreates layerDir at dest, turning OCI whiteouts into
+// their overlayfs equivalents
+func convertWhiteouts(layerDir, dest string) error {
+	return filepath.Walk(layerDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(layerDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, relPath)
+		name := info.Name()
+
+		switch {
+		case name == whiteoutOpaque:
+			return unix.Setxattr(filepath.Dir(target), "trusted.overlay.opaque", []byte("y"), 0)
+		case strings.HasPrefix(name, whiteoutPrefix):
+			hidden := filepath.Join(filepath.Dir(target), strings.TrimPrefix(name, whiteoutPrefix))
+			return unix.Mknod(hidden, unix.S_IFCHR, int(unix.Mkdev(0, 0)))
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			if err := os.Link(path, target); err == nil {
+				return nil
+			}
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			// Device nodes and FIFOs
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				return unix.Mknod(target, stat.Mode, int(stat.Rdev))
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,127 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"servin/pkg/errors"
+)
+
+// Platform identifies the operating system and CPU architecture an image
+// is built for, as used in manifest lists and image configs
+type Platform struct {
+	OS           string
+	Architecture string
+	// Variant distinguishes CPU variants of an architecture (e.g. v7 for arm)
+	Variant string
+}
+
+// archAliases maps common architecture names to the names used by registries
+var archAliases = map[string]Platform{
+	"x86_64":  {Architecture: "amd64"},
+	"x86-64":  {Architecture: "amd64"},
+	"aarch64": {Architecture: "arm64"},
+	"armhf":   {Architecture: "arm", Variant: "v7"},
+	"armel":   {Architecture: "arm", Variant: "v6"},
+	"i386":    {Architecture: "386"},
+	"i686":    {Architecture: "386"},
+}
+
+// defaultVariants are the variants assumed when an architecture is given
+// without one
+var defaultVariants = map[string]string{
+	"arm64": "v8",
+	"arm":   "v7",
+}
+
+// ParsePlatform parses a platform in the form OS/ARCH[/VARIANT], e.g.
+// linux/arm64 or linux/arm/v7. An OS alone selects the host architecture.
+func ParsePlatform(spec string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(spec)), "/")
+	if len(parts) > 3 || parts[0] == "" {
+		return Platform{}, errors.NewValidationError("image.ParsePlatform",
+			fmt.Sprintf("invalid platform '%s', expected OS/ARCH[/VARIANT]", spec))
+	}
+
+	p := Platform{OS: parts[0]}
+	if len(parts) == 1 {
+		p.Architecture = hostArchitecture()
+		return p, nil
+	}
+
+	p.Architecture = parts[1]
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	if p.Architecture == "" {
+		return Platform{}, errors.NewValidationError("image.ParsePlatform",
+			fmt.Sprintf("invalid platform '%s', missing architecture", spec))
+	}
+	return p.normalize(), nil
+}
+
+// DefaultPlatform returns the platform images are pulled for when none is
+// given: Linux on the host's CPU architecture, which is also the
+// architecture of the VM containers run in on macOS and Windows
+func DefaultPlatform() Platform {
+	return Platform{OS: "linux", Architecture: hostArchitecture()}
+}
+
+// String formats the platform as OS/ARCH[/VARIANT]
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// normalize replaces architecture aliases with registry names
+func (p Platform) normalize() Platform {
+	if alias, ok := archAliases[p.Architecture]; ok {
+		p.Architecture = alias.Architecture
+		if p.Variant == "" {
+			p.Variant = alias.Variant
+		}
+	}
+	return p
+}
+
+// Matches reports whether an image built for other runs on p. A platform
+// without a variant matches every variant of its architecture.
+func (p Platform) Matches(other Platform) bool {
+	p, other = p.normalize(), other.normalize()
+	if p.OS != other.OS || p.Architecture != other.Architecture {
+		return false
+	}
+	if p.Variant == "" {
+		return true
+	}
+	return p.Variant == other.variant()
+}
+
+// variant returns the variant, or the architecture's default variant
+func (p Platform) variant() string {
+	if p.Variant != "" {
+		return p.Variant
+	}
+	return defaultVariants[p.Architecture]
+}
+
+// selectPlatform returns the index of the manifest best matching p, or -1.
+// When p has no variant, the architecture's default variant is preferred.
+func selectPlatform(p Platform, platforms []Platform) int {
+	best := -1
+	for i, candidate := range platforms {
+		if !p.Matches(candidate) {
+			continue
+		}
+		if candidate.variant() == p.variant() {
+			return i
+		}
+		if best < 0 {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,15 @@
+//go:build darwin
+
+package image
+
+import "golang.org/x/sys/unix"
+
+// hostArchitecture returns the CPU architecture of the Mac. On Apple
+// Silicon it is arm64 even when servin itself runs as an amd64 binary
+// under Rosetta, since containers run in a native arm64 VM.
+func hostArchitecture() string {
+	if arm64, err := unix.SysctlUint32("hw.optional.arm64"); err == nil && arm64 == 1 {
+		return "arm64"
+	}
+	return "amd64"
+}
@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package image
+
+import "runtime"
+
+// hostArchitecture returns the CPU architecture servin was built for
+func hostArchitecture() string {
+	return runtime.GOARCH
+}
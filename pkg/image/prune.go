@@ -0,0 +1,121 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"servin/pkg/telemetry"
+)
+
+// PruneOptions selects the images PruneImages removes
+type PruneOptions struct {
+	// All removes every image no container uses, not only dangling ones
+	All bool
+	// Until only removes images created before this time, if set
+	Until time.Time
+	// UnusedSince only removes images not used by a container since this
+	// time, if set
+	UnusedSince time.Time
+	// InUse reports whether a container uses the image; such images are kept
+	InUse func(img *Image) bool
+	// DryRun reports what would be removed without removing anything
+	DryRun bool
+}
+
+// PruneReport lists what PruneImages removed, or would remove in a dry run
+type PruneReport struct {
+	Images []*Image
+	// SpaceReclaimed counts the layers, blobs and root filesystems freed,
+	// including layers no image referenced before the prune
+	SpaceReclaimed int64
+}
+
+// DiskUsage returns the space the image store takes, shared by every
+// namespace. Content hard-linked from the shared layer cache is included.
+func (m *Manager) DiskUsage() int64 {
+	return dirSize(m.imageDir)
+}
+
+// IsDangling reports whether an image has no tags
+func (img *Image) IsDangling() bool {
+	for _, tag := range img.RepoTags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// PruneImages removes dangling images (or with opts.All, all unused images)
+// of the manager's namespace, except protected ones, then the layers and
+// blobs neither an image nor a cached build step references
+func (m *Manager) PruneImages(opts PruneOptions) (report *PruneReport, err error) {
+	span := telemetry.StartSpan("image.prune", nil)
+	span.SetAttribute("image.prune.all", fmt.Sprintf("%t", opts.All))
+	span.SetAttribute("image.prune.dry_run", fmt.Sprintf("%t", opts.DryRun))
+	defer func() { span.Finish(err) }()
+
+	images, err := m.ListImages()
+	if err != nil {
+		return nil, err
+	}
+
+	report = &PruneReport{}
+	var kept []*Image
+	removing := make(map[string]bool)
+	for _, img := range images {
+		prune := opts.All || img.IsDangling()
+		if prune && !opts.Until.IsZero() && !img.Created.Before(opts.Until) {
+			prune = false
+		}
+		if prune && !opts.UnusedSince.IsZero() && !img.LastActivity().Before(opts.UnusedSince) {
+			prune = false
+		}
+		if prune && opts.InUse != nil && opts.InUse(img) {
+			prune = false
+		}
+		if prune && img.IsProtected() {
+			prune = false
+		}
+
+		if !prune {
+			kept = append(kept, img)
+			continue
+		}
+		report.Images = append(report.Images, img)
+		removing[img.ID] = true
+		if img.RootFSPath != "" {
+			report.SpaceReclaimed += dirSize(img.RootFSPath)
+		}
+	}
+
+	if !opts.DryRun && len(report.Images) > 0 {
+		for _, img := range report.Images {
+			if img.RootFSPath == "" {
+				continue
+			}
+			if err := os.RemoveAll(img.RootFSPath); err != nil {
+				return report, fmt.Errorf("failed to remove root filesystem of image %s: %v", shortID(img.ID), err)
+			}
+		}
+		if err := m.writeIndex(kept); err != nil {
+			return report, err
+		}
+	}
+
+	freed, err := m.collectGarbage(removing, nil, opts.DryRun)
+	report.SpaceReclaimed += freed
+	if err != nil {
+		return report, fmt.Errorf("failed to remove unused layers: %v", err)
+	}
+	return report, nil
+}
+
+// shortID returns the first 12 characters of an image ID
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
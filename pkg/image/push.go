@@ -0,0 +1,598 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"servin/pkg/ratelimit"
+	"servin/pkg/telemetry"
+)
+
+// Media types of pushed manifests, configs and layers
+const (
+	mediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIConfig   = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayerGzip   = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeLayerTar    = "application/vnd.oci.image.layer.v1.tar"
+)
+
+// DefaultChunkSize is the chunk size for blob uploads. Smaller blobs are
+// uploaded in a single request.
+const DefaultChunkSize int64 = 5 << 20
+
+// PushOptions controls how images are pushed
+type PushOptions struct {
+	// AllTags pushes every local tag of the image's repository
+	AllTags bool
+	// ChunkSize is the upload chunk size in bytes (DefaultChunkSize if zero)
+	ChunkSize int64
+	// LimitRate limits the upload rate in bytes per second (0 for no limit)
+	LimitRate int64
+}
+
+// Reference is an image reference split into registry, repository and tag
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// ParseReference parses references such as "alpine", "user/app:v1" and
+// "registry.example.com:5000/team/app:v1". References without a registry
+// refer to Docker Hub.
+func ParseReference(ref string) Reference {
+	r := Reference{Registry: "docker.io", Tag: "latest"}
+
+	name := ref
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		name, r.Tag = ref[:i], ref[i+1:]
+	}
+
+	// The first component is a registry if it looks like a host name
+	if i := strings.Index(name, "/"); i > 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			r.Registry, name = host, name[i+1:]
+		}
+	}
+
+	if r.Registry == "docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	r.Repository = name
+	return r
+}
+
+// URL returns the base URL of the registry's API. Registries on the local
+// host are reached over plain HTTP.
+func (r Reference) URL() string {
+	switch {
+	case r.Registry == "docker.io":
+		return "https://registry-1.docker.io"
+	case strings.HasPrefix(r.Registry, "localhost"), strings.HasPrefix(r.Registry, "127.0.0.1"):
+		return "http://" + r.Registry
+	default:
+		return "https://" + r.Registry
+	}
+}
+
+// descriptor references a blob from a manifest
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// ociManifest is an OCI image manifest
+type ociManifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// ociConfig is the configuration generated for images that were not pulled
+type ociConfig struct {
+	Created      time.Time `json:"created"`
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	Variant      string    `json:"variant,omitempty"`
+	Config       struct {
+		Env          []string            `json:"Env,omitempty"`
+		Cmd          []string            `json:"Cmd,omitempty"`
+		Entrypoint   []string            `json:"Entrypoint,omitempty"`
+		WorkingDir   string              `json:"WorkingDir,omitempty"`
+		User         string              `json:"User,omitempty"`
+		Labels       map[string]string   `json:"Labels,omitempty"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+		Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+		Shell        []string            `json:"Shell,omitempty"`
+		StopSignal   string              `json:"StopSignal,omitempty"`
+		OnBuild      []string            `json:"OnBuild,omitempty"`
+	} `json:"config"`
+	RootFS struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// PushImage uploads an image's layers, config and manifest to the registry
+// named in imageRef, using the chunked blob upload flow of the Distribution
+// API for large blobs
+func (m *Manager) PushImage(imageRef string, opts PushOptions) (err error) {
+	span := telemetry.StartSpan("image.push", nil)
+	span.SetAttribute("image.ref", imageRef)
+	defer func() { span.Finish(err) }()
+
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+
+	ref := ParseReference(imageRef)
+	tags, err := m.pushTags(imageRef, ref, opts.AllTags)
+	if err != nil {
+		return err
+	}
+
+	client := newClientFor(ref)
+	client.limitRate(0, opts.LimitRate)
+	if opts.LimitRate > 0 {
+		fmt.Printf("Limiting uploads to %s\n", ratelimit.FormatRate(opts.LimitRate))
+	}
+	if err := client.authenticate(ref.Repository, "pull,push"); err != nil {
+		return fmt.Errorf("failed to authenticate with %s: %v", ref.Registry, err)
+	}
+
+	// Images with several tags are uploaded once
+	pushed := make(map[string]*ociManifest)
+	for _, tag := range tags {
+		img, err := m.GetImage(tag)
+		if err != nil {
+			return err
+		}
+
+		manifest, ok := pushed[img.ID]
+		if !ok {
+			if manifest, err = m.prepareManifest(img); err != nil {
+				return err
+			}
+			blobs := append([]descriptor{manifest.Config}, manifest.Layers...)
+			for i, blob := range blobs {
+				if err := m.pushBlob(client, ref.Repository, blob, opts.ChunkSize, i, len(blobs)); err != nil {
+					return fmt.Errorf("failed to push blob %s: %v", blob.Digest, err)
+				}
+			}
+			pushed[img.ID] = manifest
+		}
+
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			return fmt.Errorf("failed to encode manifest: %v", err)
+		}
+		target := ParseReference(tag)
+		if err := client.putManifest(ref.Repository, target.Tag, data); err != nil {
+			return fmt.Errorf("failed to push manifest for %s: %v", tag, err)
+		}
+
+		sum := sha256.Sum256(data)
+		fmt.Printf("%s: digest: sha256:%s size: %d\n", target.Tag, hex.EncodeToString(sum[:]), len(data))
+	}
+
+	return nil
+}
+
+// pushTags returns the local tags to push: the reference itself, or with
+// allTags every tag of the same repository
+func (m *Manager) pushTags(imageRef string, ref Reference, allTags bool) ([]string, error) {
+	if !allTags {
+		if _, err := m.GetImage(imageRef); err != nil {
+			return nil, err
+		}
+		return []string{imageRef}, nil
+	}
+
+	images, err := m.ListImages()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			other := ParseReference(tag)
+			if other.Registry == ref.Registry && other.Repository == ref.Repository {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags found for repository %s", ref.Repository)
+	}
+	return tags, nil
+}
+
+// prepareManifest makes sure every blob of an image is in the blob store and
+// returns the manifest describing them
+func (m *Manager) prepareManifest(img *Image) (*ociManifest, error) {
+	manifest := &ociManifest{SchemaVersion: 2, MediaType: mediaTypeOCIManifest}
+	var diffIDs []string
+
+	if len(img.LayerChain) > 0 {
+		for _, chainID := range img.LayerChain {
+			layer, err := m.GetLayer(chainID)
+			if err != nil {
+				return nil, err
+			}
+			desc, err := m.blobDescriptor(layer.Digest)
+			if err != nil {
+				return nil, fmt.Errorf("layer %s: %v", shortDigest(layer.Digest), err)
+			}
+			manifest.Layers = append(manifest.Layers, desc)
+			diffIDs = append(diffIDs, layer.DiffID)
+		}
+	} else {
+		// Images stored before the layer store are packed into one layer
+		dirs, err := m.LayerDirs(img)
+		if err != nil {
+			return nil, fmt.Errorf("image %s has no filesystem to push: %v", img.ID, err)
+		}
+		digest, diffID, err := m.packLayer(dirs[0])
+		if err != nil {
+			return nil, err
+		}
+		desc, err := m.blobDescriptor(digest)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+		diffIDs = append(diffIDs, diffID)
+	}
+
+	// Pulled images keep the registry's config; others get one generated
+	configDigest := img.ConfigDigest
+	if configDigest == "" || !m.HasBlob(configDigest) {
+		var err error
+		if configDigest, err = m.writeConfig(img, diffIDs); err != nil {
+			return nil, err
+		}
+	}
+	config, err := m.blobDescriptor(configDigest)
+	if err != nil {
+		return nil, err
+	}
+	config.MediaType = mediaTypeOCIConfig
+	manifest.Config = config
+
+	return manifest, nil
+}
+
+// blobDescriptor describes a blob in the blob store, detecting whether it is
+// a compressed layer
+func (m *Manager) blobDescriptor(digest string) (descriptor, error) {
+	f, err := m.OpenBlob(digest)
+	if err != nil {
+		return descriptor{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return descriptor{}, err
+	}
+
+	mediaType := mediaTypeLayerTar
+	magic := make([]byte, 2)
+	if n, _ := io.ReadFull(f, magic); n == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		mediaType = mediaTypeLayerGzip
+	}
+	return descriptor{MediaType: mediaType, Digest: digest, Size: info.Size()}, nil
+}
+
+// writeConfig stores a generated image configuration as a blob
+func (m *Manager) writeConfig(img *Image, diffIDs []string) (string, error) {
+	var config ociConfig
+	config.Created = img.Created.UTC()
+	platform := DefaultPlatform()
+	if img.Platform != "" {
+		if parsed, err := ParsePlatform(img.Platform); err == nil {
+			platform = parsed
+		}
+	}
+	config.Architecture = platform.Architecture
+	config.OS = platform.OS
+	config.Variant = platform.Variant
+	config.Config.Env = img.Config.Env
+	config.Config.Cmd = img.Config.Cmd
+	config.Config.Entrypoint = img.Config.Entrypoint
+	config.Config.WorkingDir = img.Config.WorkingDir
+	config.Config.User = img.Config.User
+	config.Config.Labels = img.Config.Labels
+	config.Config.ExposedPorts = img.Config.ExposedPorts
+	config.Config.Volumes = img.Config.Volumes
+	config.Config.Shell = img.Config.Shell
+	config.Config.StopSignal = img.Config.StopSignal
+	config.Config.OnBuild = img.Config.OnBuild
+	config.RootFS.Type = "layers"
+	config.RootFS.DiffIDs = diffIDs
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode image config: %v", err)
+	}
+	digest, _, err := m.PutBlob(bytes.NewReader(data), "")
+	return digest, err
+}
+
+// CommitConfig stores the config of a built image and sets the image ID to
+// its digest, as for pulled images. Images with the same layers, config and
+// creation time get the same ID.
+func (m *Manager) CommitConfig(img *Image) error {
+	diffIDs := []string{}
+	for _, chainID := range img.LayerChain {
+		layer, err := m.GetLayer(chainID)
+		if err != nil {
+			return err
+		}
+		diffIDs = append(diffIDs, layer.DiffID)
+	}
+
+	digest, err := m.writeConfig(img, diffIDs)
+	if err != nil {
+		return err
+	}
+	img.ConfigDigest = digest
+	img.ID = strings.TrimPrefix(digest, "sha256:")
+	return nil
+}
+
+// packLayer stores a directory as a gzip-compressed layer blob and returns
+// the blob digest and the digest of the uncompressed tar
+func (m *Manager) packLayer(dir string) (digest, diffID string, err error) {
+	pr, pw := io.Pipe()
+	tarHash := sha256.New()
+
+	go func() {
+		gz := gzip.NewWriter(pw)
+		err := writeTar(dir, io.MultiWriter(gz, tarHash))
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	digest, _, err = m.PutBlob(pr, "")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to pack layer: %v", err)
+	}
+	return digest, "sha256:" + hex.EncodeToString(tarHash.Sum(nil)), nil
+}
+
+// writeTar writes the contents of dir as a tar stream
+func writeTar(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// pushBlob uploads a blob unless the repository already has it
+func (m *Manager) pushBlob(client *RegistryClient, repo string, blob descriptor, chunkSize int64, index, total int) error {
+	exists, err := client.blobExists(repo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Printf("Blob %d/%d %s already exists\n", index+1, total, shortDigest(blob.Digest))
+		return nil
+	}
+
+	f, err := m.OpenBlob(blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Printf("Pushing blob %d/%d %s (%d bytes)...\n", index+1, total, shortDigest(blob.Digest), blob.Size)
+	return client.uploadBlob(repo, blob, f, chunkSize)
+}
+
+// blobExists reports whether a repository already has a blob
+func (rc *RegistryClient) blobExists(repo, digest string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, fmt.Sprintf("%s/v2/%s/blobs/%s", rc.registryURL, repo, digest), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := rc.do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("blob check failed with status %d", resp.StatusCode)
+	}
+}
+
+// uploadBlob uploads a blob in a single request, or in chunks when it is
+// larger than chunkSize
+func (rc *RegistryClient) uploadBlob(repo string, blob descriptor, f *os.File, chunkSize int64) error {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/v2/%s/blobs/uploads/", rc.registryURL, repo), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := rc.do(req)
+	if err != nil {
+		return err
+	}
+	location, err := rc.uploadLocation(resp, http.StatusAccepted)
+	if err != nil {
+		return fmt.Errorf("failed to start upload: %v", err)
+	}
+
+	// Monolithic upload
+	if blob.Size <= chunkSize {
+		return rc.finishUpload(location, blob.Digest, io.NewSectionReader(f, 0, blob.Size), blob.Size)
+	}
+
+	// Chunked upload: PATCH each chunk, then close the upload with PUT
+	for offset := int64(0); offset < blob.Size; offset += chunkSize {
+		size := chunkSize
+		if offset+size > blob.Size {
+			size = blob.Size - offset
+		}
+
+		req, err := http.NewRequest(http.MethodPatch, location, io.NewSectionReader(f, offset, size))
+		if err != nil {
+			return err
+		}
+		req.GetBody = sectionBody(f, offset, size)
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+size-1))
+
+		resp, err := rc.do(req)
+		if err != nil {
+			return err
+		}
+		if location, err = rc.uploadLocation(resp, http.StatusAccepted); err != nil {
+			return fmt.Errorf("failed to upload chunk at offset %d: %v", offset, err)
+		}
+	}
+
+	return rc.finishUpload(location, blob.Digest, nil, 0)
+}
+
+// finishUpload completes an upload, sending any remaining data
+func (rc *RegistryClient) finishUpload(location, digest string, body io.Reader, size int64) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	query.Set("digest", digest)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), body)
+	if err != nil {
+		return err
+	}
+	if section, ok := body.(*io.SectionReader); ok {
+		req.GetBody = sectionBody(section, 0, size)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := rc.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// uploadLocation checks an upload response and returns the absolute URL to
+// continue the upload at
+func (rc *RegistryClient) uploadLocation(resp *http.Response, expected int) (string, error) {
+	defer resp.Body.Close()
+	if resp.StatusCode != expected {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	base, err := url.Parse(rc.registryURL)
+	if err != nil {
+		return "", err
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return "", fmt.Errorf("registry returned no upload location")
+	}
+	return base.ResolveReference(location).String(), nil
+}
+
+// sectionBody lets a request that uploads part of a file be replayed after
+// its token is refreshed
+func sectionBody(r io.ReaderAt, offset, size int64) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(r, offset, size)), nil
+	}
+}
+
+// putManifest uploads a manifest under a tag
+func (rc *RegistryClient) putManifest(repo, tag string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/v2/%s/manifests/%s", rc.registryURL, repo, tag), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mediaTypeOCIManifest)
+
+	resp, err := rc.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("manifest upload failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
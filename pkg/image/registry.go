@@ -1,8 +1,6 @@
 package image
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,12 +9,35 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"servin/pkg/credentials"
+	"servin/pkg/logger"
+	"servin/pkg/ratelimit"
+	"servin/pkg/telemetry"
 )
 
-// RegistryClient handles pulling images from Docker registries
+// RegistryClient handles pulling images from and pushing images to registries
 type RegistryClient struct {
 	registryURL string
 	client      *http.Client
+
+	// registry is the registry host, which credentials are stored under
+	registry string
+	// credential is the stored credential, or nil for anonymous access
+	credential *credentials.Credential
+	// basic is set when the registry uses basic auth instead of tokens
+	basic bool
+
+	// realm and service locate the token server; scope is the access the
+	// token grants
+	realm   string
+	service string
+	scope   string
+	// token is the bearer token obtained by authenticate, renewed after expires
+	token   string
+	expires time.Time
+	// issuedToken is a refresh token issued by the token server
+	issuedToken string
 }
 
 // NewRegistryClient creates a new registry client
@@ -33,20 +54,20 @@ func NewRegistryClient(registryURL string) *RegistryClient {
 	}
 }
 
+// limitRate limits the client's downloads and uploads, in bytes per second
+// (0 for no limit)
+func (rc *RegistryClient) limitRate(down, up int64) {
+	if down > 0 || up > 0 {
+		rc.client = ratelimit.Client(rc.client.Timeout, ratelimit.New(down), ratelimit.New(up))
+	}
+}
+
 // ManifestV2 represents Docker Registry API v2 manifest
 type ManifestV2 struct {
-	SchemaVersion int    `json:"schemaVersion"`
-	MediaType     string `json:"mediaType"`
-	Config        struct {
-		MediaType string `json:"mediaType"`
-		Size      int64  `json:"size"`
-		Digest    string `json:"digest"`
-	} `json:"config"`
-	Layers []struct {
-		MediaType string `json:"mediaType"`
-		Size      int64  `json:"size"`
-		Digest    string `json:"digest"`
-	} `json:"layers"`
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
 }
 
 // ManifestList represents a manifest list (for multi-arch images)
@@ -60,51 +81,100 @@ type ManifestList struct {
 		Platform  struct {
 			Architecture string `json:"architecture"`
 			OS           string `json:"os"`
+			Variant      string `json:"variant"`
 		} `json:"platform"`
 	} `json:"manifests"`
 }
 
 // ImageConfig represents the configuration from the config blob
 type ImageConfigBlob struct {
-	Config struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant"`
+	Config       struct {
 		Env        []string          `json:"Env"`
 		Cmd        []string          `json:"Cmd"`
 		Entrypoint []string          `json:"Entrypoint"`
 		WorkingDir string            `json:"WorkingDir"`
 		User       string            `json:"User"`
 		Labels     map[string]string `json:"Labels"`
+
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		Volumes      map[string]struct{} `json:"Volumes"`
+		Shell        []string            `json:"Shell"`
+		StopSignal   string              `json:"StopSignal"`
+		OnBuild      []string            `json:"OnBuild"`
 	} `json:"config"`
 	RootFS struct {
 		Type    string   `json:"type"`
 		DiffIDs []string `json:"diff_ids"`
 	} `json:"rootfs"`
+	History []HistoryEntry `json:"history,omitempty"`
 }
 
-// PullImage pulls an image from Docker Hub or another registry
-func (m *Manager) PullImage(imageRef string) error {
-	fmt.Printf("Pulling image %s from Docker Hub...\n", imageRef)
+// HistoryEntry is a step of the build that produced an image. Steps that
+// only change the config, such as ENV or CMD, have EmptyLayer set.
+type HistoryEntry struct {
+	Created    time.Time `json:"created,omitzero"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
 
-	// Parse image reference
-	repo, tag := parseImageRef(imageRef)
-	if tag == "" {
-		tag = "latest"
+// History returns the build steps recorded in an image's config, oldest
+// first. Images without a config blob, such as those imported from a
+// rootfs tarball, have none.
+func (m *Manager) History(img *Image) ([]HistoryEntry, error) {
+	if img.ConfigDigest == "" {
+		return nil, nil
+	}
+	config, err := m.readConfigBlob(img.ConfigDigest)
+	if err != nil {
+		return nil, err
 	}
+	return config.History, nil
+}
 
-	fmt.Printf("Parsed image: repo=%s, tag=%s\n", repo, tag)
+// PullOptions controls how an image is pulled
+type PullOptions struct {
+	// Platform selects the image from a multi-platform manifest list.
+	// The zero value selects DefaultPlatform.
+	Platform Platform
+	// LimitRate limits the download rate in bytes per second (0 for no limit)
+	LimitRate int64
+}
 
-	// Create registry client
-	client := NewRegistryClient("")
+// PullImage pulls an image from Docker Hub or another registry
+func (m *Manager) PullImage(imageRef string, opts PullOptions) (err error) {
+	span := telemetry.StartSpan("image.pull", nil)
+	span.SetAttribute("image.ref", imageRef)
+	defer func() { span.Finish(err) }()
 
-	// Get auth token for Docker Hub
-	fmt.Printf("Getting auth token...\n")
-	token, err := client.getAuthToken(repo)
-	if err != nil {
-		return fmt.Errorf("failed to get auth token: %v", err)
+	platform := opts.Platform
+	if platform.OS == "" {
+		platform = DefaultPlatform()
+	}
+	span.SetAttribute("image.platform", platform.String())
+
+	ref := ParseReference(imageRef)
+	repo, tag := ref.Repository, ref.Tag
+	fmt.Printf("Pulling image %s (%s) from %s...\n", imageRef, platform, ref.Registry)
+	if opts.LimitRate > 0 {
+		fmt.Printf("Limiting downloads to %s\n", ratelimit.FormatRate(opts.LimitRate))
+	}
+
+	// Authenticate with stored credentials, or anonymously
+	client := newClientFor(ref)
+	client.limitRate(opts.LimitRate, 0)
+	if err := client.authenticate(repo, "pull"); err != nil {
+		return fmt.Errorf("failed to authenticate with %s: %v", ref.Registry, err)
 	}
 
 	// Get image manifest
 	fmt.Printf("Getting manifest...\n")
-	manifest, err := client.getManifest(repo, tag, token)
+	manifestSpan := telemetry.StartSpan("image.pull.manifest", span)
+	manifest, listed, err := client.getManifest(repo, tag, platform)
+	manifestSpan.Finish(err)
 	if err != nil {
 		return fmt.Errorf("failed to get manifest: %v", err)
 	}
@@ -113,120 +183,107 @@ func (m *Manager) PullImage(imageRef string) error {
 
 	// Get config blob
 	fmt.Printf("Getting config blob...\n")
-	configBlob, err := client.getConfigBlob(repo, manifest.Config.Digest, token)
-	if err != nil {
+	if err := m.fetchBlob(client, repo, manifest.Config.Digest); err != nil {
 		return fmt.Errorf("failed to get config blob: %v", err)
 	}
-
-	// Create image directory
-	imageID := generateImageID(fmt.Sprintf("%s:%s", repo, tag), "")
-	imageDir := filepath.Join(m.imageDir, imageID)
-	if err := os.MkdirAll(imageDir, 0755); err != nil {
-		return fmt.Errorf("failed to create image directory: %v", err)
+	configBlob, err := m.readConfigBlob(manifest.Config.Digest)
+	if err != nil {
+		return err
 	}
 
-	// Download and extract layers
-	rootfsDir := filepath.Join(imageDir, "rootfs")
-	if err := os.MkdirAll(rootfsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create rootfs directory: %v", err)
+	// Single-platform images carry their platform only in the config
+	imagePlatform := Platform{OS: configBlob.OS, Architecture: configBlob.Architecture, Variant: configBlob.Variant}
+	if imagePlatform.OS == "" {
+		imagePlatform = listed
+	}
+	if imagePlatform.OS != "" && !platform.Matches(imagePlatform) {
+		fmt.Printf("Warning: image platform %s does not match the requested platform %s\n", imagePlatform, platform)
 	}
 
+	// Download and extract layers into the layer store. Layers already
+	// present (e.g. a shared base image) are reused without downloading.
 	fmt.Printf("Downloading %d layers...\n", len(manifest.Layers))
+	var chain []string
+	parent := ""
 	for i, layer := range manifest.Layers {
-		fmt.Printf("Downloading layer %d/%d...\n", i+1, len(manifest.Layers))
-		if err := client.downloadAndExtractLayer(repo, layer.Digest, rootfsDir, token); err != nil {
+		layerSpan := telemetry.StartSpan("image.pull.layer", span)
+		layerSpan.SetAttribute("layer.digest", layer.Digest)
+		layerSpan.SetAttribute("layer.size", fmt.Sprintf("%d", layer.Size))
+
+		if i < len(configBlob.RootFS.DiffIDs) {
+			if existing, err := m.GetLayer(ChainID(parent, configBlob.RootFS.DiffIDs[i])); err == nil {
+				fmt.Printf("Layer %d/%d %s already exists\n", i+1, len(manifest.Layers), shortDigest(layer.Digest))
+				layerSpan.Finish(nil)
+				chain = append(chain, existing.ChainID)
+				parent = existing.ChainID
+				continue
+			}
+		}
+
+		fmt.Printf("Downloading layer %d/%d %s...\n", i+1, len(manifest.Layers), shortDigest(layer.Digest))
+		err := m.fetchBlob(client, repo, layer.Digest)
+		var created *Layer
+		if err == nil {
+			created, err = m.CreateLayer(parent, layer.Digest)
+		}
+		if err == nil && i < len(configBlob.RootFS.DiffIDs) && created.DiffID != configBlob.RootFS.DiffIDs[i] {
+			err = fmt.Errorf("diff ID mismatch: expected %s, got %s", configBlob.RootFS.DiffIDs[i], created.DiffID)
+		}
+		layerSpan.Finish(err)
+		if err != nil {
 			return fmt.Errorf("failed to download layer %s: %v", layer.Digest, err)
 		}
-	}
 
-	// Create image metadata
-	img := &Image{
-		ID:         imageID,
-		RepoTags:   []string{imageRef},
-		Created:    time.Now(),
-		Size:       calculateLayersSizes(manifest.Layers),
-		Layers:     extractLayerDigests(manifest.Layers),
-		RootFSType: "layers",
-		RootFSPath: rootfsDir,
-		Config: ImageConfig{
-			Env:        configBlob.Config.Env,
-			Cmd:        configBlob.Config.Cmd,
-			Entrypoint: configBlob.Config.Entrypoint,
-			WorkingDir: configBlob.Config.WorkingDir,
-			User:       configBlob.Config.User,
-			Labels:     configBlob.Config.Labels,
-		},
+		chain = append(chain, created.ChainID)
+		parent = created.ChainID
 	}
 
-	// Save image to index
-	if err := m.saveImage(img); err != nil {
-		return fmt.Errorf("failed to save image: %v", err)
+	img := imageFromConfig(manifest.Config.Digest, configBlob, manifest.Layers, chain)
+	if imagePlatform.OS != "" {
+		img.Platform = imagePlatform.String()
+	}
+	if err := m.AddImage(img, imageRef); err != nil {
+		return err
 	}
 
 	fmt.Printf("Successfully pulled %s\n", imageRef)
 	return nil
 }
 
-// getAuthToken gets an authentication token for Docker Hub
-func (rc *RegistryClient) getAuthToken(repo string) (string, error) {
-	// Docker Hub auth endpoint
-	authURL := fmt.Sprintf("https://auth.docker.io/token?service=registry.docker.io&scope=repository:%s:pull", repo)
-
-	resp, err := rc.client.Get(authURL)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("auth request failed with status %d", resp.StatusCode)
-	}
-
-	var authResp struct {
-		Token string `json:"token"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
-		return "", err
-	}
-
-	return authResp.Token, nil
-}
-
-// getManifest gets the image manifest, handling manifest lists
-func (rc *RegistryClient) getManifest(repo, tag, token string) (*ManifestV2, error) {
+// getManifest gets the image manifest. For a manifest list it returns the
+// manifest for the given platform along with the platform it is listed for.
+func (rc *RegistryClient) getManifest(repo, tag string, platform Platform) (*ManifestV2, Platform, error) {
 	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.registryURL, repo, tag)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, Platform{}, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
 	// Try multiple manifest formats including OCI
 	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json, application/vnd.docker.distribution.manifest.list.v2+json, application/vnd.oci.image.manifest.v1+json, application/vnd.oci.image.index.v1+json")
 
-	resp, err := rc.client.Do(req)
+	resp, err := rc.do(req)
 	if err != nil {
-		return nil, err
+		return nil, Platform{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("manifest request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, Platform{}, fmt.Errorf("manifest request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	// Read response body to determine manifest type
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read manifest response: %v", err)
+		return nil, Platform{}, fmt.Errorf("failed to read manifest response: %v", err)
 	}
 
 	// Parse as generic manifest first to check type
 	var genericManifest map[string]interface{}
 	if err := json.Unmarshal(body, &genericManifest); err != nil {
-		return nil, fmt.Errorf("failed to decode manifest: %v", err)
+		return nil, Platform{}, fmt.Errorf("failed to decode manifest: %v", err)
 	}
 
 	mediaType, _ := genericManifest["mediaType"].(string)
@@ -237,44 +294,50 @@ func (rc *RegistryClient) getManifest(repo, tag, token string) (*ManifestV2, err
 		mediaType == "application/vnd.oci.image.index.v1+json" {
 		var manifestList ManifestList
 		if err := json.Unmarshal(body, &manifestList); err != nil {
-			return nil, fmt.Errorf("failed to decode manifest list: %v", err)
+			return nil, Platform{}, fmt.Errorf("failed to decode manifest list: %v", err)
 		}
 
-		// Find the right manifest for our platform (prefer amd64/linux)
-		var targetDigest string
+		// Find the manifest for the requested platform
+		var platforms []Platform
 		for _, manifest := range manifestList.Manifests {
-			if manifest.Platform.OS == "linux" && manifest.Platform.Architecture == "amd64" {
-				targetDigest = manifest.Digest
-				break
-			}
-		}
-
-		// If no amd64 found, use the first one
-		if targetDigest == "" && len(manifestList.Manifests) > 0 {
-			targetDigest = manifestList.Manifests[0].Digest
+			platforms = append(platforms, Platform{
+				OS:           manifest.Platform.OS,
+				Architecture: manifest.Platform.Architecture,
+				Variant:      manifest.Platform.Variant,
+			})
 		}
 
-		if targetDigest == "" {
-			return nil, fmt.Errorf("no suitable manifest found in manifest list")
+		i := selectPlatform(platform, platforms)
+		if i < 0 {
+			var available []string
+			for _, p := range platforms {
+				// Attestations are listed with an unknown platform
+				if p.OS != "unknown" {
+					available = append(available, p.String())
+				}
+			}
+			return nil, Platform{}, fmt.Errorf("no manifest for platform %s (available: %s)", platform, strings.Join(available, ", "))
 		}
 
-		fmt.Printf("Found manifest list, using digest: %s\n", targetDigest)
+		targetDigest := manifestList.Manifests[i].Digest
+		fmt.Printf("Found manifest list, using %s digest: %s\n", platforms[i], targetDigest)
 
 		// Get the specific manifest
-		return rc.getManifestByDigest(repo, targetDigest, token)
+		manifest, err := rc.getManifestByDigest(repo, targetDigest)
+		return manifest, platforms[i], err
 	}
 
 	// Handle regular manifest
 	var manifest ManifestV2
 	if err := json.Unmarshal(body, &manifest); err != nil {
-		return nil, fmt.Errorf("failed to decode manifest: %v", err)
+		return nil, Platform{}, fmt.Errorf("failed to decode manifest: %v", err)
 	}
 
-	return &manifest, nil
+	return &manifest, Platform{}, nil
 }
 
 // getManifestByDigest gets a specific manifest by digest
-func (rc *RegistryClient) getManifestByDigest(repo, digest, token string) (*ManifestV2, error) {
+func (rc *RegistryClient) getManifestByDigest(repo, digest string) (*ManifestV2, error) {
 	url := fmt.Sprintf("%s/v2/%s/manifests/%s", rc.registryURL, repo, digest)
 
 	req, err := http.NewRequest("GET", url, nil)
@@ -282,10 +345,9 @@ func (rc *RegistryClient) getManifestByDigest(repo, digest, token string) (*Mani
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json, application/vnd.oci.image.manifest.v1+json")
 
-	resp, err := rc.client.Do(req)
+	resp, err := rc.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -304,179 +366,158 @@ func (rc *RegistryClient) getManifestByDigest(repo, digest, token string) (*Mani
 	return &manifest, nil
 }
 
-// getConfigBlob gets the image configuration blob
-func (rc *RegistryClient) getConfigBlob(repo, digest, token string) (*ImageConfigBlob, error) {
-	url := fmt.Sprintf("%s/v2/%s/blobs/%s", rc.registryURL, repo, digest)
+// maxStalledAttempts is how many download attempts in a row may fail
+// without receiving any data before a pull gives up
+const maxStalledAttempts = 3
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := rc.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("config blob request failed with status %d: %s (URL: %s)", resp.StatusCode, string(body), url)
-	}
-
-	var configBlob ImageConfigBlob
-	if err := json.NewDecoder(resp.Body).Decode(&configBlob); err != nil {
-		return nil, fmt.Errorf("failed to decode config blob: %v", err)
-	}
-
-	return &configBlob, nil
+// blobStatusError is a blob response that retrying will not fix
+type blobStatusError struct {
+	status int
+	body   string
+	url    string
 }
 
-// downloadAndExtractLayer downloads and extracts a layer to the rootfs
-func (rc *RegistryClient) downloadAndExtractLayer(repo, digest, rootfsDir, token string) error {
-	url := fmt.Sprintf("%s/v2/%s/blobs/%s", rc.registryURL, repo, digest)
+func (e *blobStatusError) Error() string {
+	return fmt.Sprintf("blob request failed with status %d: %s (URL: %s)", e.status, e.body, e.url)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return err
+// fetchBlob downloads a blob into the blob store unless it is already there.
+// Data is written to a partial file as it arrives; a dropped connection is
+// resumed with a range request, and so is a pull run again after a failure.
+func (m *Manager) fetchBlob(client *RegistryClient, repo, digest string) error {
+	if m.HasBlob(digest) {
+		return nil
 	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := rc.client.Do(req)
-	if err != nil {
+	if err := validateDigest(digest); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("layer download failed with status %d", resp.StatusCode)
+	if m.linkSharedBlob(digest) {
+		logger.Debug("Using %s from the shared layer cache", shortDigest(digest))
+		return nil
 	}
 
-	// Create gzip reader
-	gzipReader, err := gzip.NewReader(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %v", err)
+	partial := m.partialBlobPath(digest)
+	if err := os.MkdirAll(filepath.Dir(partial), 0755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %v", err)
 	}
-	defer gzipReader.Close()
-
-	// Create tar reader
-	tarReader := tar.NewReader(gzipReader)
 
-	// Extract tar contents
+	stalled := 0
 	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
+		var offset int64
+		if info, err := os.Stat(partial); err == nil {
+			offset = info.Size()
 		}
-		if err != nil {
-			return fmt.Errorf("tar read error: %v", err)
+		if offset > 0 {
+			fmt.Printf("Resuming %s at %s...\n", shortDigest(digest), formatSize(offset))
 		}
 
-		targetPath := filepath.Join(rootfsDir, header.Name)
-
-		// Security check: prevent path traversal
-		if !strings.HasPrefix(targetPath, rootfsDir) {
-			continue
+		received, err := client.downloadBlob(repo, digest, partial, offset)
+		if err == nil {
+			break
+		}
+		if _, ok := err.(*blobStatusError); ok {
+			return err
 		}
 
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("failed to create directory %s: %v", targetPath, err)
-			}
-		case tar.TypeReg:
-			// Create parent directories
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return fmt.Errorf("failed to create parent directory for %s: %v", targetPath, err)
-			}
-
-			// Create file
-			outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %v", targetPath, err)
-			}
-
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return fmt.Errorf("failed to write file %s: %v", targetPath, err)
-			}
-			outFile.Close()
-		case tar.TypeSymlink:
-			// Create symlink
-			if err := os.Symlink(header.Linkname, targetPath); err != nil {
-				// Ignore symlink errors for now
-				continue
-			}
+		if received > 0 {
+			stalled = 0
+		} else {
+			stalled++
+		}
+		if stalled >= maxStalledAttempts {
+			return fmt.Errorf("%v (the partial download is kept; pull again to resume)", err)
 		}
+		logger.Warn("Download of %s interrupted: %v", shortDigest(digest), err)
 	}
 
+	if err := m.commitBlob(partial, digest); err != nil {
+		return err
+	}
+	m.publishBlob(digest)
 	return nil
 }
 
-// parseImageRef parses an image reference into repository and tag
-func parseImageRef(imageRef string) (repo, tag string) {
-	parts := strings.Split(imageRef, ":")
-	if len(parts) == 1 {
-		// No tag specified, use latest
-		repo = parts[0]
-		tag = "latest"
-	} else {
-		repo = strings.Join(parts[:len(parts)-1], ":")
-		tag = parts[len(parts)-1]
+// downloadBlob appends a blob to the partial file at path, starting at
+// offset. It returns how many bytes were received.
+func (rc *RegistryClient) downloadBlob(repo, digest, path string, offset int64) (int64, error) {
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", rc.registryURL, repo, digest)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
 
-	// Handle Docker Hub library images (e.g., "alpine" -> "library/alpine")
-	if !strings.Contains(repo, "/") {
-		repo = "library/" + repo
+	resp, err := rc.do(req)
+	if err != nil {
+		return 0, err
 	}
+	defer resp.Body.Close()
 
-	return repo, tag
-}
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp) == offset:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// The registry ignored the range, so the download starts over
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusPartialContent:
+		os.Remove(path)
+		return 0, fmt.Errorf("registry returned the wrong range, restarting download")
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file is already complete; its digest is checked next
+		return 0, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return 0, &blobStatusError{status: resp.StatusCode, body: string(body), url: url}
+	}
 
-// calculateLayersSizes calculates total size of all layers
-func calculateLayersSizes(layers []struct {
-	MediaType string `json:"mediaType"`
-	Size      int64  `json:"size"`
-	Digest    string `json:"digest"`
-}) int64 {
-	var total int64
-	for _, layer := range layers {
-		total += layer.Size
-	}
-	return total
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open partial download: %v", err)
+	}
+	received, err := io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return received, err
 }
 
-// extractLayerDigests extracts layer digests
-func extractLayerDigests(layers []struct {
-	MediaType string `json:"mediaType"`
-	Size      int64  `json:"size"`
-	Digest    string `json:"digest"`
-}) []string {
-	var digests []string
-	for _, layer := range layers {
-		digests = append(digests, layer.Digest)
-	}
-	return digests
+// contentRangeStart returns the first byte of a partial response, or -1
+func contentRangeStart(resp *http.Response) int64 {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		// The total may be unknown ("bytes 0-99/*")
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/", &start, &end); err != nil {
+			return -1
+		}
+	}
+	return start
 }
 
-// saveImage saves an image to the index
-func (m *Manager) saveImage(img *Image) error {
-	images, err := m.ListImages()
+// readConfigBlob decodes an image configuration blob from the blob store
+func (m *Manager) readConfigBlob(digest string) (*ImageConfigBlob, error) {
+	blob, err := m.OpenBlob(digest)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer blob.Close()
 
-	// Add new image
-	images = append(images, img)
-
-	// Save updated index
-	data, err := json.MarshalIndent(images, "", "  ")
-	if err != nil {
-		return err
+	var configBlob ImageConfigBlob
+	if err := json.NewDecoder(blob).Decode(&configBlob); err != nil {
+		return nil, fmt.Errorf("failed to decode config blob: %v", err)
 	}
 
-	return os.WriteFile(m.indexPath, data, 0644)
+	return &configBlob, nil
+}
+
+// shortDigest abbreviates a digest for progress output
+func shortDigest(digest string) string {
+	encoded := strings.TrimPrefix(digest, "sha256:")
+	if len(encoded) > 12 {
+		return encoded[:12]
+	}
+	return encoded
 }
@@ -0,0 +1,77 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"servin/pkg/errors"
+	"servin/pkg/state"
+)
+
+// ProtectedLabel marks an image, like a container or volume, that may not be
+// removed unless protection is overridden. Any value but "false" protects it.
+const ProtectedLabel = "protected"
+
+// IsProtected reports whether the image carries the protected label
+func (img *Image) IsProtected() bool {
+	value, ok := img.Config.Labels[ProtectedLabel]
+	return ok && value != "false"
+}
+
+// RemoveChecked removes an image after the checks servin image rm and the
+// TUI make: a protected image is kept unless overrideProtection is set, and
+// an image a container of the manager's namespace was created from, or one
+// with tags when tags are immutable, unless the manager is forced (see
+// WithForce). It returns the image it removed.
+func (m *Manager) RemoveChecked(ref string, overrideProtection bool) (*Image, error) {
+	img, err := m.GetImage(ref)
+	if err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeNotFound, "image rm", fmt.Sprintf("image %s not found", ref))
+	}
+
+	if img.IsProtected() && !overrideProtection {
+		return nil, errors.NewConflictError("image rm", fmt.Sprintf("image %s is protected; use --override-protection to remove it", ref))
+	}
+	if !m.force {
+		if users := m.containersUsing(img); len(users) > 0 {
+			return nil, errors.NewConflictError("image rm", fmt.Sprintf("image %s is used by %s; remove them first or use --force", ref, strings.Join(users, ", ")))
+		}
+		if m.immutableTags && len(img.RepoTags) > 0 {
+			return nil, errors.NewConflictError("image rm", fmt.Sprintf("image %s is tagged %s and tags are immutable; use --force to remove it", ref, strings.Join(img.RepoTags, ", ")))
+		}
+	}
+
+	if err := m.RemoveImage(img.ID); err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeImage, "image rm", fmt.Sprintf("failed to remove image %s", ref))
+	}
+	return img, nil
+}
+
+// containersUsing returns the names of the containers in the manager's
+// namespace created from img, which servin image ls counts as its users
+func (m *Manager) containersUsing(img *Image) []string {
+	containers, err := state.NewStateManager().ListContainers()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, c := range containers {
+		if m.ContainerImageID(c) == img.ID {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// ContainerImageID returns the ID of the image a container was created
+// from, or "" if it is gone
+func (m *Manager) ContainerImageID(c *state.ContainerState) string {
+	img, err := m.GetImage(c.Image)
+	if err != nil && !strings.Contains(c.Image, ":") {
+		img, err = m.GetImage(c.Image + ":latest")
+	}
+	if err != nil {
+		return ""
+	}
+	return img.ID
+}
@@ -0,0 +1,262 @@
+package image
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"servin/pkg/errors"
+	"servin/pkg/telemetry"
+)
+
+// Formats images are saved in
+const (
+	// FormatDockerArchive is a tarball as written by `docker save`
+	FormatDockerArchive = "docker-archive"
+	// FormatOCIArchive is a tarball of an OCI image layout
+	FormatOCIArchive = "oci-archive"
+	// FormatOCI is an OCI image layout directory
+	FormatOCI = "oci"
+)
+
+// SaveImages writes images from the store to w as a docker-archive or
+// oci-archive tarball. Images referenced by name are saved under that name;
+// images referenced by ID keep all their tags. Progress goes to progress.
+func (m *Manager) SaveImages(refs []string, w io.Writer, format string, progress io.Writer) (err error) {
+	span := telemetry.StartSpan("image.save", nil)
+	span.SetAttribute("image.format", format)
+	defer func() { span.Finish(err) }()
+
+	images, err := m.readStoreImages(refs)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatDockerArchive, "":
+		return m.writeDockerArchiveTo(w, images, progress)
+	case FormatOCIArchive:
+		dir, err := os.MkdirTemp("", "servin-save-")
+		if err != nil {
+			return fmt.Errorf("failed to create staging directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := m.writeLayoutImages(dir, images, progress); err != nil {
+			return err
+		}
+		return writeDirTar(dir, w)
+	default:
+		return errors.NewValidationError("image save",
+			fmt.Sprintf("unknown format '%s' (expected %s or %s)", format, FormatDockerArchive, FormatOCIArchive))
+	}
+}
+
+// SaveImagesToLayout adds images from the store to an OCI image layout
+// directory, creating it if needed
+func (m *Manager) SaveImagesToLayout(refs []string, dir string, progress io.Writer) (err error) {
+	span := telemetry.StartSpan("image.save", nil)
+	span.SetAttribute("image.format", FormatOCI)
+	defer func() { span.Finish(err) }()
+
+	images, err := m.readStoreImages(refs)
+	if err != nil {
+		return err
+	}
+	return m.writeLayoutImages(dir, images, progress)
+}
+
+// readStoreImages describes images in the store by their blobs
+func (m *Manager) readStoreImages(refs []string) ([]*imageBlobs, error) {
+	var images []*imageBlobs
+	for _, ref := range refs {
+		blobs, err := m.readStoreImage(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image %s: %v", ref, err)
+		}
+		images = append(images, blobs)
+	}
+	return images, nil
+}
+
+// writeLayoutImages adds images to an OCI image layout, one entry per tag
+func (m *Manager) writeLayoutImages(dir string, images []*imageBlobs, progress io.Writer) error {
+	for _, blobs := range images {
+		names := blobs.RepoTags
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, name := range names {
+			if name != "" {
+				name = normalizeTag(name)
+			}
+			if err := m.writeOCILayout(dir, blobs, name, progress); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadImages imports every image in a docker-archive tarball, an OCI
+// archive or an OCI image layout directory into the store, and returns the
+// names of the loaded images (the ID for untagged images). Multi-platform
+// images in OCI layouts resolve to DefaultPlatform.
+func (m *Manager) LoadImages(input string) (names []string, err error) {
+	span := telemetry.StartSpan("image.load", nil)
+	defer func() { span.Finish(err) }()
+
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", input, err)
+	}
+	if info.IsDir() {
+		return m.loadLayout(input)
+	}
+
+	isDockerArchive, isOCIArchive := false, false
+	err = walkArchive(input, func(header *tar.Header, r io.Reader) error {
+		switch path.Clean(header.Name) {
+		case "manifest.json":
+			isDockerArchive = true
+		case ociLayoutFile:
+			isOCIArchive = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case isDockerArchive:
+		// Archives with both, as newer Docker versions write, are read as
+		// docker-archives since only those list every tag
+		return m.loadDockerArchive(input)
+	case isOCIArchive:
+		dir, err := os.MkdirTemp("", "servin-load-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create staging directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := extractLayoutArchive(input, dir); err != nil {
+			return nil, err
+		}
+		return m.loadLayout(dir)
+	default:
+		return nil, fmt.Errorf("%s is not a docker-archive or OCI archive", input)
+	}
+}
+
+// loadDockerArchive imports every image in a docker-archive tarball
+func (m *Manager) loadDockerArchive(archivePath string) ([]string, error) {
+	entries, links, err := readArchiveManifest(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	images, err := m.readArchiveImages(archivePath, entries, links)
+	if err != nil {
+		return nil, err
+	}
+	return m.importImages(images)
+}
+
+// loadLayout imports every image in an OCI image layout
+func (m *Manager) loadLayout(dir string) ([]string, error) {
+	index, err := readLayoutIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	var images []*imageBlobs
+	for _, desc := range index.Manifests {
+		blobs, err := m.readLayoutImage(dir, desc, DefaultPlatform())
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, blobs)
+	}
+	return m.importImages(images)
+}
+
+// importImages adds images to the store under their RepoTags
+func (m *Manager) importImages(images []*imageBlobs) ([]string, error) {
+	var names []string
+	for _, blobs := range images {
+		img, err := m.importImage(blobs, blobs.RepoTags)
+		if err != nil {
+			return names, err
+		}
+		if len(blobs.RepoTags) == 0 {
+			names = append(names, "sha256:"+img.ID)
+		}
+		for _, tag := range blobs.RepoTags {
+			names = append(names, normalizeTag(tag))
+		}
+	}
+	return names, nil
+}
+
+// extractLayoutArchive unpacks the regular files of an OCI archive into dir
+func extractLayoutArchive(archivePath, dir string) error {
+	return walkArchive(archivePath, func(header *tar.Header, r io.Reader) error {
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		name := path.Clean("/" + header.Name)[1:]
+		if name == "" {
+			return nil
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		file, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, r)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+// writeDirTar writes the files under dir to w as a tarball
+func writeDirTar(dir string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if info.IsDir() {
+			return tw.WriteHeader(&tar.Header{Name: name + "/", Mode: 0755, Typeflag: tar.TypeDir})
+		}
+		if strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+		return writeArchiveEntry(tw, name, info.Size(), func(w io.Writer) error {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(w, f)
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
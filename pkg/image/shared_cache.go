@@ -0,0 +1,379 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"servin/pkg/logger"
+)
+
+// SharedCacheEnvVar names the shared layer cache directory, or disables the
+// cache when set to "off"
+const SharedCacheEnvVar = "SERVIN_SHARED_CACHE"
+
+// SharedCache is an optional, system-wide, read-only cache of blobs and
+// extracted layers. It is owned by root (or an administrator on Windows), who
+// populates it on pull; every user's image store hard-links the content it
+// needs from it, so users on one machine store identical base layers once.
+//
+// The cache is content-addressed like the image store: blobs by digest and
+// layers by chain ID, with an index from (parent, blob digest) to the layer
+// it extracts to.
+type SharedCache struct {
+	dir string
+}
+
+// SharedCacheStats summarizes what the shared cache holds and saves
+type SharedCacheStats struct {
+	Dir    string `json:"dir"`
+	Blobs  int    `json:"blobs"`
+	Layers int    `json:"layers"`
+	// Size is the disk space the cache content takes once
+	Size int64 `json:"size"`
+	// Linked counts the files image stores hard-link from the cache
+	Linked int `json:"linked"`
+	// Saved is the space image stores would use for their own copies of
+	// linked content beyond the one copy kept
+	Saved int64 `json:"saved"`
+}
+
+// SharedCacheDir returns where the shared layer cache lives
+func SharedCacheDir() string {
+	if dir := os.Getenv(SharedCacheEnvVar); dir != "" && dir != "off" {
+		return dir
+	}
+	if runtime.GOOS == "windows" {
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		return filepath.Join(programData, "servin", "cache")
+	}
+	return "/var/cache/servin"
+}
+
+// OpenSharedCache returns the shared layer cache, or nil if it has not been
+// created with InitSharedCache or is disabled
+func OpenSharedCache() *SharedCache {
+	if os.Getenv(SharedCacheEnvVar) == "off" {
+		return nil
+	}
+	dir := SharedCacheDir()
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil
+	}
+	return &SharedCache{dir: dir}
+}
+
+// InitSharedCache creates the shared layer cache. It must be run by the user
+// who will own it, normally root.
+func InitSharedCache() (*SharedCache, error) {
+	dir := SharedCacheDir()
+	for _, sub := range []string{"blobs", "layers", "index", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create shared cache: %v", err)
+		}
+	}
+	return &SharedCache{dir: dir}, nil
+}
+
+// Dir returns the cache directory
+func (c *SharedCache) Dir() string {
+	return c.dir
+}
+
+// Writable reports whether the current user can add content to the cache
+func (c *SharedCache) Writable() bool {
+	f, err := os.CreateTemp(filepath.Join(c.dir, "tmp"), "probe-")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+func (c *SharedCache) blobPath(digest string) string {
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	return filepath.Join(c.dir, "blobs", algorithm, encoded)
+}
+
+func (c *SharedCache) layerDir(chainID string) string {
+	algorithm, encoded, _ := strings.Cut(chainID, ":")
+	return filepath.Join(c.dir, "layers", algorithm, encoded)
+}
+
+// indexPath returns the file naming the layer a blob extracts to on top of
+// parent
+func (c *SharedCache) indexPath(parent, digest string) string {
+	sum := sha256.Sum256([]byte(parent + " " + digest))
+	return filepath.Join(c.dir, "index", hex.EncodeToString(sum[:]))
+}
+
+// linkSharedBlob hard-links a blob from the shared cache into the store,
+// copying it if the two are on different filesystems or the system forbids
+// linking another user's files. It reports whether the blob was found.
+func (m *Manager) linkSharedBlob(digest string) bool {
+	if m.shared == nil || validateDigest(digest) != nil {
+		return false
+	}
+	src := m.shared.blobPath(digest)
+	info, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+
+	dest := m.blobPath(digest)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return false
+	}
+	if err := linkOrCopy(src, dest, info.Mode().Perm()); err != nil {
+		logger.Warn("Failed to use %s from the shared cache: %v", shortDigest(digest), err)
+		return false
+	}
+	return true
+}
+
+// publishBlob adds a stored blob to the shared cache if the current user
+// may write to it. Cached blobs are made read-only.
+func (m *Manager) publishBlob(digest string) {
+	if m.shared == nil || !m.shared.Writable() {
+		return
+	}
+	dest := m.shared.blobPath(digest)
+	if _, err := os.Stat(dest); err == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return
+	}
+	tmp := filepath.Join(m.shared.dir, "tmp", "blob-"+strings.TrimPrefix(digest, "sha256:"))
+	if err := linkOrCopy(m.blobPath(digest), tmp, 0444); err != nil {
+		logger.Warn("Failed to add %s to the shared cache: %v", shortDigest(digest), err)
+		return
+	}
+	os.Chmod(tmp, 0444)
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// linkSharedLayer recreates the layer a blob extracts to on top of parent
+// from the shared cache, hard-linking its files. It returns nil if the cache
+// does not have the layer.
+func (m *Manager) linkSharedLayer(parent, digest string) *Layer {
+	if m.shared == nil {
+		return nil
+	}
+	data, err := os.ReadFile(m.shared.indexPath(parent, digest))
+	if err != nil {
+		return nil
+	}
+	chainID := strings.TrimSpace(string(data))
+	if validateDigest(chainID) != nil {
+		return nil
+	}
+	if layer, err := m.GetLayer(chainID); err == nil {
+		return layer
+	}
+
+	src := m.shared.layerDir(chainID)
+	layersDir := filepath.Join(m.imageDir, "layers")
+	if err := os.MkdirAll(layersDir, 0755); err != nil {
+		return nil
+	}
+	tmpDir, err := os.MkdirTemp(layersDir, "tmp-")
+	if err != nil {
+		return nil
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := linkTree(src, tmpDir); err != nil {
+		logger.Warn("Failed to use layer %s from the shared cache: %v", shortDigest(chainID), err)
+		return nil
+	}
+	dir := m.layerDir(chainID)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return nil
+	}
+	layer, err := m.GetLayer(chainID)
+	if err != nil {
+		return nil
+	}
+	return layer
+}
+
+// publishLayer adds an extracted layer to the shared cache if the current
+// user may write to it
+func (m *Manager) publishLayer(layer *Layer) {
+	if m.shared == nil || !m.shared.Writable() {
+		return
+	}
+	dest := m.shared.layerDir(layer.ChainID)
+	if _, err := os.Stat(dest); err != nil {
+		tmpDir, err := os.MkdirTemp(filepath.Join(m.shared.dir, "tmp"), "layer-")
+		if err != nil {
+			return
+		}
+		defer os.RemoveAll(tmpDir)
+		if err := linkTree(m.layerDir(layer.ChainID), tmpDir); err != nil {
+			logger.Warn("Failed to add layer %s to the shared cache: %v", shortDigest(layer.ChainID), err)
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return
+		}
+		if err := os.Rename(tmpDir, dest); err != nil {
+			return
+		}
+	}
+	os.WriteFile(m.shared.indexPath(layer.Parent, layer.Digest), []byte(layer.ChainID+"\n"), 0644)
+}
+
+// Stats walks the cache and reports its size and how much the hard links
+// from image stores save
+func (c *SharedCache) Stats() (*SharedCacheStats, error) {
+	stats := &SharedCacheStats{Dir: c.dir}
+	count := func(path string, info os.FileInfo) {
+		stats.Size += info.Size()
+		// One link is the cache's own
+		if links := linkCount(path, info); links > 1 {
+			stats.Linked++
+			stats.Saved += info.Size() * int64(links-2)
+		}
+	}
+
+	err := filepath.Walk(filepath.Join(c.dir, "blobs"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			stats.Blobs++
+			count(path, info)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	layers, _ := filepath.Glob(filepath.Join(c.dir, "layers", "*", "*"))
+	stats.Layers = len(layers)
+	for _, layer := range layers {
+		filepath.Walk(filepath.Join(layer, "diff"), func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				count(path, info)
+			}
+			return nil
+		})
+	}
+	return stats, nil
+}
+
+// Prune removes the blobs and layers no image store links to any more,
+// returning the bytes freed
+func (c *SharedCache) Prune() (int64, error) {
+	var freed int64
+
+	blobs, _ := filepath.Glob(filepath.Join(c.dir, "blobs", "*", "*"))
+	for _, blob := range blobs {
+		info, err := os.Stat(blob)
+		if err != nil || linkCount(blob, info) > 1 {
+			continue
+		}
+		if err := os.Remove(blob); err != nil {
+			return freed, fmt.Errorf("failed to remove %s: %v", blob, err)
+		}
+		freed += info.Size()
+	}
+
+	layers, _ := filepath.Glob(filepath.Join(c.dir, "layers", "*", "*"))
+	for _, layer := range layers {
+		linked := false
+		var size int64
+		filepath.Walk(filepath.Join(layer, "diff"), func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				size += info.Size()
+				linked = linked || linkCount(path, info) > 1
+			}
+			return nil
+		})
+		if linked {
+			continue
+		}
+		if err := os.RemoveAll(layer); err != nil {
+			return freed, fmt.Errorf("failed to remove %s: %v", layer, err)
+		}
+		freed += size
+	}
+
+	// Drop index entries whose layer is gone
+	entries, _ := os.ReadDir(filepath.Join(c.dir, "index"))
+	for _, entry := range entries {
+		path := filepath.Join(c.dir, "index", entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(c.layerDir(strings.TrimSpace(string(data)))); os.IsNotExist(err) {
+			os.Remove(path)
+		}
+	}
+	return freed, nil
+}
+
+// linkOrCopy hard-links src to dest, falling back to a copy
+func linkOrCopy(src, dest string, mode os.FileMode) error {
+	os.Remove(dest)
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+	return copyFile(src, dest, mode)
+}
+
+// linkTree recreates the directory tree at src under dest, hard-linking
+// regular files
+func linkTree(src, dest string) error {
+	// Directory modes are applied last, so read-only directories can be
+	// filled first
+	modes := make(map[string]os.FileMode)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, relPath)
+
+		switch {
+		case info.IsDir():
+			modes[target] = info.Mode().Perm()
+			return os.MkdirAll(target, 0755)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return linkOrCopy(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for dir, mode := range modes {
+		if err := os.Chmod(dir, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"servin/pkg/errors"
+)
+
+// TagEvent records a tag being set to an image
+type TagEvent struct {
+	Tag     string    `json:"tag"`
+	ImageID string    `json:"image_id"`
+	Digest  string    `json:"digest,omitempty"` // config digest, when known
+	Time    time.Time `json:"time"`
+	// Forced is set when --force moved an immutable tag
+	Forced bool `json:"forced,omitempty"`
+}
+
+// WithImmutableTags returns a manager that refuses to move an existing tag
+// to a different image, as the daemon.images.immutable_tags setting asks
+func (m *Manager) WithImmutableTags(immutable bool) *Manager {
+	c := *m
+	c.immutableTags = immutable
+	return &c
+}
+
+// WithForce returns a manager that moves tags even when they are immutable,
+// recording that it did
+func (m *Manager) WithForce(force bool) *Manager {
+	c := *m
+	c.force = force
+	return &c
+}
+
+// checkTagMove refuses to point an existing tag at another image when tags
+// are immutable and the move is not forced
+func (m *Manager) checkTagMove(tag, imageID string) error {
+	if !m.immutableTags || m.force || tag == "" {
+		return nil
+	}
+	existing, err := m.GetImage(tag)
+	if err != nil || existing.ID == imageID {
+		return nil
+	}
+	return errors.NewConflictError("image.Tag", fmt.Sprintf(
+		"tag %s points to %s and tags are immutable; use --force to move it to %s",
+		tag, shortID(existing.ID), shortID(imageID)))
+}
+
+// historyPath is the tag history of the manager's namespace, next to its index
+func (m *Manager) historyPath() string {
+	return filepath.Join(filepath.Dir(m.indexPath), "tag-history.json")
+}
+
+func (m *Manager) readTagHistory() ([]TagEvent, error) {
+	data, err := os.ReadFile(m.historyPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tag history: %v", err)
+	}
+	var events []TagEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse tag history: %v", err)
+	}
+	return events, nil
+}
+
+// recordTag appends the tag's new image to the history, unless the tag
+// already pointed to it
+func (m *Manager) recordTag(tag string, img *Image) error {
+	if tag == "" || tag == "<none>:<none>" {
+		return nil
+	}
+	events, err := m.readTagHistory()
+	if err != nil {
+		return err
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Tag == tag {
+			if events[i].ImageID == img.ID {
+				return nil
+			}
+			break
+		}
+	}
+
+	events = append(events, TagEvent{
+		Tag:     tag,
+		ImageID: img.ID,
+		Digest:  img.ConfigDigest,
+		Time:    time.Now(),
+		Forced:  m.immutableTags && m.force,
+	})
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tag history: %v", err)
+	}
+	if err := os.WriteFile(m.historyPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write tag history: %v", err)
+	}
+	return nil
+}
+
+// TagHistory returns the images a tag pointed to, oldest first
+func (m *Manager) TagHistory(tag string) ([]TagEvent, error) {
+	tag = normalizeTag(tag)
+	events, err := m.readTagHistory()
+	if err != nil {
+		return nil, err
+	}
+	var history []TagEvent
+	for _, e := range events {
+		if e.Tag == tag {
+			history = append(history, e)
+		}
+	}
+	return history, nil
+}
+
+// TagAt returns the event in effect for the tag at t: the last one set at
+// or before it, or nil if the tag was not set yet
+func TagAt(history []TagEvent, t time.Time) *TagEvent {
+	var at *TagEvent
+	for i := range history {
+		if history[i].Time.After(t) {
+			break
+		}
+		at = &history[i]
+	}
+	return at
+}
@@ -0,0 +1,30 @@
+package image
+
+import (
+	"strings"
+	"time"
+)
+
+// MarkUsed records that a container was created or started from the image,
+// so reports and prunes can tell images in regular use from forgotten ones
+func (m *Manager) MarkUsed(ref string) error {
+	img, err := m.GetImage(ref)
+	if err != nil && !strings.Contains(ref, ":") {
+		img, err = m.GetImage(ref + ":latest")
+	}
+	if err != nil {
+		return err
+	}
+
+	img.LastUsed = time.Now()
+	return m.SaveImage(img)
+}
+
+// LastActivity returns when the image was last used by a container, or when
+// it was created if no container has used it yet
+func (img *Image) LastActivity() time.Time {
+	if img.LastUsed.IsZero() {
+		return img.Created
+	}
+	return img.LastUsed
+}
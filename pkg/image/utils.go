@@ -17,80 +17,6 @@ func generateImageID(name, tag string) string {
 	return fmt.Sprintf("%x", hash)[:16]
 }
 
-// extractTarball extracts a tarball to the specified directory
-func extractTarball(tarballPath, destDir string) error {
-	file, err := os.Open(tarballPath)
-	if err != nil {
-		return fmt.Errorf("failed to open tarball: %v", err)
-	}
-	defer file.Close()
-
-	var reader io.Reader = file
-
-	// Check if it's gzipped
-	if strings.HasSuffix(tarballPath, ".gz") || strings.HasSuffix(tarballPath, ".tgz") {
-		gzReader, err := gzip.NewReader(file)
-		if err != nil {
-			return fmt.Errorf("failed to create gzip reader: %v", err)
-		}
-		defer gzReader.Close()
-		reader = gzReader
-	}
-
-	tarReader := tar.NewReader(reader)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar header: %v", err)
-		}
-
-		targetPath := filepath.Join(destDir, header.Name)
-
-		// Security check: prevent path traversal
-		if !strings.HasPrefix(targetPath, destDir) {
-			return fmt.Errorf("invalid file path in tarball: %s", header.Name)
-		}
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("failed to create directory %s: %v", targetPath, err)
-			}
-
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return fmt.Errorf("failed to create parent directory for %s: %v", targetPath, err)
-			}
-
-			file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %v", targetPath, err)
-			}
-
-			if _, err := io.Copy(file, tarReader); err != nil {
-				file.Close()
-				return fmt.Errorf("failed to extract file %s: %v", targetPath, err)
-			}
-			file.Close()
-
-		case tar.TypeSymlink:
-			if err := os.Symlink(header.Linkname, targetPath); err != nil {
-				// Don't fail on symlink errors, just warn
-				fmt.Printf("Warning: failed to create symlink %s -> %s: %v\n", targetPath, header.Linkname, err)
-			}
-
-		default:
-			fmt.Printf("Warning: unsupported file type %c for %s\n", header.Typeflag, header.Name)
-		}
-	}
-
-	return nil
-}
-
 // createTarball creates a tarball from a directory
 func createTarball(sourceDir, tarballPath string) error {
 	file, err := os.Create(tarballPath)
@@ -0,0 +1,149 @@
+package image
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LayerFS is a read-only view of an image's merged filesystem that reads
+// files straight from the layer store, without extracting the image.
+// Paths are absolute paths inside the image and are not resolved through
+// symlinks; callers that need to follow symlinks do so themselves.
+type LayerFS struct {
+	// dirs are the layer directories, top layer first
+	dirs []string
+}
+
+// ImageFS returns a view of an image's merged filesystem
+func (m *Manager) ImageFS(img *Image) (*LayerFS, error) {
+	dirs, err := m.LayerDirs(img)
+	if err != nil {
+		return nil, err
+	}
+
+	top := make([]string, len(dirs))
+	for i, dir := range dirs {
+		top[len(dirs)-1-i] = dir
+	}
+	return &LayerFS{dirs: top}, nil
+}
+
+// find returns the host path of name in the topmost layer that has it
+func (l *LayerFS) find(name string) (string, error) {
+	parts := splitPath(name)
+
+	for _, dir := range l.dirs {
+		candidate := filepath.Join(append([]string{dir}, parts...)...)
+		if _, err := os.Lstat(candidate); err == nil {
+			return candidate, nil
+		}
+		if hidesLower(dir, parts) {
+			break
+		}
+	}
+	return "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+// hidesLower reports whether a layer deletes parts, or one of its parent
+// directories, from the layers below it
+func hidesLower(dir string, parts []string) bool {
+	for i := range parts {
+		parent := filepath.Join(append([]string{dir}, parts[:i]...)...)
+		if exists(filepath.Join(parent, whiteoutPrefix+parts[i])) {
+			return true
+		}
+		if i > 0 && exists(filepath.Join(parent, whiteoutOpaque)) {
+			return true
+		}
+	}
+	return len(parts) > 0 && exists(filepath.Join(append(append([]string{dir}, parts...), whiteoutOpaque)...))
+}
+
+// Lstat returns file information without following a final symlink
+func (l *LayerFS) Lstat(name string) (os.FileInfo, error) {
+	p, err := l.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+// Readlink returns the target of a symlink
+func (l *LayerFS) Readlink(name string) (string, error) {
+	p, err := l.find(name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(p)
+}
+
+// Open opens a file for reading
+func (l *LayerFS) Open(name string) (*os.File, error) {
+	p, err := l.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// ReadDir returns the sorted names in a directory, merged across layers
+func (l *LayerFS) ReadDir(name string) ([]string, error) {
+	parts := splitPath(name)
+	seen := make(map[string]bool)
+	found := false
+
+	for _, dir := range l.dirs {
+		entries, err := os.ReadDir(filepath.Join(append([]string{dir}, parts...)...))
+		if err == nil {
+			found = true
+			for _, entry := range entries {
+				entryName := entry.Name()
+				if strings.HasPrefix(entryName, whiteoutPrefix) {
+					// Deleted entries are hidden in every lower layer
+					if entryName != whiteoutOpaque {
+						if _, ok := seen[strings.TrimPrefix(entryName, whiteoutPrefix)]; !ok {
+							seen[strings.TrimPrefix(entryName, whiteoutPrefix)] = false
+						}
+					}
+					continue
+				}
+				if _, ok := seen[entryName]; !ok {
+					seen[entryName] = true
+				}
+			}
+		}
+		if hidesLower(dir, parts) {
+			break
+		}
+	}
+
+	if !found {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+
+	var names []string
+	for entryName, visible := range seen {
+		if visible {
+			names = append(names, entryName)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// splitPath splits a cleaned absolute path into its components
+func splitPath(name string) []string {
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+func exists(p string) bool {
+	_, err := os.Lstat(p)
+	return err == nil
+}
@@ -0,0 +1,34 @@
+package inventory
+
+import "os"
+
+// apkDatabase is the installed package database of Alpine's apk
+const apkDatabase = "/lib/apk/db/installed"
+
+// scanAPK reads the apk database, where each package is a stanza of
+// single-letter fields (P: name, V: version, A: architecture, o: origin)
+func scanAPK(src Source) ([]Package, error) {
+	f, err := open(src, apkDatabase)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var packages []Package
+	err = readStanzas(f, ":", func(fields map[string]string) {
+		if fields["P"] == "" {
+			return
+		}
+		packages = append(packages, Package{
+			Name:    fields["P"],
+			Version: fields["V"],
+			Arch:    fields["A"],
+			Manager: ManagerAPK,
+			Source:  fields["o"],
+		})
+	})
+	return packages, err
+}
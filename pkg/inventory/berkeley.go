@@ -0,0 +1,130 @@
+package inventory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// This is a minimal reader for Berkeley DB hash databases, enough to read
+// the values of rpm's Packages database. Keys are ignored.
+
+const (
+	bdbHashMagic = 0x061561
+	// bdbPageHeader is the size of the generic page header
+	bdbPageHeader = 26
+
+	bdbPageHashUnsorted = 2
+	bdbPageOverflow     = 7
+	bdbPageHash         = 13
+
+	// Hash item types
+	bdbKeyData = 1
+	bdbOffPage = 3
+)
+
+// readBerkeleyPackages calls fn with every value in a Berkeley DB hash database
+func readBerkeleyPackages(f *os.File, fn func([]byte)) error {
+	meta := make([]byte, 512)
+	if _, err := f.ReadAt(meta, 0); err != nil {
+		return fmt.Errorf("failed to read database metadata: %v", err)
+	}
+
+	// Databases are written in the byte order of the host that created them
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(meta[12:16]) == bdbHashMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(meta[12:16]) == bdbHashMagic:
+		order = binary.BigEndian
+	default:
+		return fmt.Errorf("not a Berkeley DB hash database")
+	}
+	if meta[24] != 0 {
+		return fmt.Errorf("encrypted databases are not supported")
+	}
+
+	pageSize := int(order.Uint32(meta[20:24]))
+	if pageSize < 512 || pageSize > 65536 || pageSize&(pageSize-1) != 0 {
+		return fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	pages := info.Size() / int64(pageSize)
+
+	page := make([]byte, pageSize)
+	for number := int64(1); number < pages; number++ {
+		if _, err := f.ReadAt(page, number*int64(pageSize)); err != nil {
+			return fmt.Errorf("failed to read page %d: %v", number, err)
+		}
+		if page[25] != bdbPageHash && page[25] != bdbPageHashUnsorted {
+			continue
+		}
+
+		// Items alternate key, value and are stored from the end of the page
+		// down, so each ends where the previous one starts
+		entries := int(order.Uint16(page[20:22]))
+		if bdbPageHeader+entries*2 > pageSize {
+			return fmt.Errorf("page %d has an invalid entry count", number)
+		}
+		offset := func(i int) int {
+			return int(order.Uint16(page[bdbPageHeader+i*2:]))
+		}
+
+		for i := 1; i < entries; i += 2 {
+			start, end := offset(i), offset(i-1)
+			if start >= end || end > pageSize {
+				return fmt.Errorf("page %d has an invalid item offset", number)
+			}
+			item := page[start:end]
+
+			switch item[0] {
+			case bdbKeyData:
+				fn(item[1:])
+			case bdbOffPage:
+				if len(item) < 12 {
+					return fmt.Errorf("page %d has an invalid overflow item", number)
+				}
+				value, err := readBerkeleyOverflow(f, order, pageSize, order.Uint32(item[4:8]), order.Uint32(item[8:12]))
+				if err != nil {
+					return err
+				}
+				fn(value)
+			}
+		}
+	}
+	return nil
+}
+
+// readBerkeleyOverflow reassembles a value stored in a chain of overflow pages
+func readBerkeleyOverflow(f *os.File, order binary.ByteOrder, pageSize int, number, length uint32) ([]byte, error) {
+	if length > 256<<20 {
+		return nil, fmt.Errorf("overflow item too large")
+	}
+
+	value := make([]byte, 0, length)
+	page := make([]byte, pageSize)
+	for len(value) < int(length) {
+		if number == 0 {
+			return nil, fmt.Errorf("overflow chain ends early")
+		}
+		if _, err := f.ReadAt(page, int64(number)*int64(pageSize)); err != nil {
+			return nil, fmt.Errorf("failed to read overflow page %d: %v", number, err)
+		}
+		if page[25] != bdbPageOverflow {
+			return nil, fmt.Errorf("page %d is not an overflow page", number)
+		}
+
+		// On overflow pages the free-space offset holds the bytes in use
+		used := int(order.Uint16(page[22:24]))
+		if bdbPageHeader+used > pageSize || used == 0 {
+			return nil, fmt.Errorf("overflow page %d has an invalid length", number)
+		}
+		value = append(value, page[bdbPageHeader:bdbPageHeader+min(used, int(length)-len(value))]...)
+		number = order.Uint32(page[16:20])
+	}
+	return value, nil
+}
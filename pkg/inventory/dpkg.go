@@ -0,0 +1,64 @@
+package inventory
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// dpkgStatus is dpkg's package status database
+	dpkgStatus = "/var/lib/dpkg/status"
+	// dpkgStatusDir holds one status file per package on distroless images,
+	// which ship no dpkg
+	dpkgStatusDir = "/var/lib/dpkg/status.d"
+)
+
+// scanDpkg reads the dpkg status database and distroless status files
+func scanDpkg(src Source) ([]Package, error) {
+	var packages []Package
+	add := func(fields map[string]string) {
+		if fields["Package"] == "" {
+			return
+		}
+		// The status database also records removed and half-installed packages
+		if status, ok := fields["Status"]; ok && !strings.HasSuffix(status, " installed") {
+			return
+		}
+		// Source may be followed by the source version in parentheses
+		source, _, _ := strings.Cut(fields["Source"], " ")
+		packages = append(packages, Package{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			Arch:    fields["Architecture"],
+			Manager: ManagerDpkg,
+			Source:  source,
+		})
+	}
+
+	files := []string{dpkgStatus}
+	if names, err := readDir(src, dpkgStatusDir); err == nil {
+		for _, name := range names {
+			// Skip the .md5sums files that accompany each status file
+			if !strings.Contains(name, ".") {
+				files = append(files, path.Join(dpkgStatusDir, name))
+			}
+		}
+	}
+
+	for _, name := range files {
+		f, err := open(src, name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = readStanzas(f, ":", add)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return packages, nil
+}
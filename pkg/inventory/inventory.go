@@ -0,0 +1,236 @@
+// Package inventory lists the OS packages installed in a container or image
+// by reading package manager databases straight from its root filesystem,
+// without running anything inside it.
+package inventory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package managers whose databases are read
+const (
+	ManagerAPK  = "apk"
+	ManagerDpkg = "dpkg"
+	ManagerRPM  = "rpm"
+)
+
+// Package is an installed OS package
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch,omitempty"`
+	Manager string `json:"manager"`
+	// Source is the source package the package was built from, when the
+	// database records one; distribution advisories are keyed by it
+	Source string `json:"source,omitempty"`
+}
+
+// Inventory describes what is installed in a root filesystem
+type Inventory struct {
+	OS string `json:"os,omitempty"`
+	// OSID and OSVersion are the ID and VERSION_ID fields of os-release
+	// (e.g. "debian" and "12")
+	OSID      string    `json:"os_id,omitempty"`
+	OSVersion string    `json:"os_version,omitempty"`
+	Packages  []Package `json:"packages"`
+	// Warnings lists package databases that were found but could not be read
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Source gives read access to a root filesystem. Paths are absolute paths
+// inside the filesystem.
+type Source interface {
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+	Open(name string) (*os.File, error)
+	ReadDir(name string) ([]string, error)
+}
+
+// DirSource is a root filesystem stored in a host directory
+type DirSource string
+
+func (d DirSource) hostPath(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// Lstat returns file information without following a final symlink
+func (d DirSource) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(d.hostPath(name))
+}
+
+// Readlink returns the target of a symlink
+func (d DirSource) Readlink(name string) (string, error) {
+	return os.Readlink(d.hostPath(name))
+}
+
+// Open opens a file for reading
+func (d DirSource) Open(name string) (*os.File, error) {
+	return os.Open(d.hostPath(name))
+}
+
+// ReadDir returns the sorted names in a directory
+func (d DirSource) ReadDir(name string) ([]string, error) {
+	entries, err := os.ReadDir(d.hostPath(name))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names, nil
+}
+
+// Scan reads the OS release and the databases of every supported package
+// manager found in src
+func Scan(src Source) (*Inventory, error) {
+	release := osRelease(src)
+	inv := &Inventory{OS: release["PRETTY_NAME"], OSID: release["ID"], OSVersion: release["VERSION_ID"]}
+
+	scanners := []struct {
+		manager string
+		scan    func(Source) ([]Package, error)
+	}{
+		{ManagerAPK, scanAPK},
+		{ManagerDpkg, scanDpkg},
+		{ManagerRPM, scanRPM},
+	}
+
+	for _, scanner := range scanners {
+		packages, err := scanner.scan(src)
+		if err != nil {
+			inv.Warnings = append(inv.Warnings, fmt.Sprintf("%s: %v", scanner.manager, err))
+			continue
+		}
+		inv.Packages = append(inv.Packages, packages...)
+	}
+
+	sort.Slice(inv.Packages, func(i, j int) bool {
+		if inv.Packages[i].Manager != inv.Packages[j].Manager {
+			return inv.Packages[i].Manager < inv.Packages[j].Manager
+		}
+		return inv.Packages[i].Name < inv.Packages[j].Name
+	})
+	return inv, nil
+}
+
+// osRelease returns the fields of os-release, if present
+func osRelease(src Source) map[string]string {
+	fields := make(map[string]string)
+	for _, name := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		f, err := open(src, name)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+				fields[key] = strings.Trim(value, `"'`)
+			}
+		}
+		break
+	}
+	return fields
+}
+
+// maxSymlinks bounds symlink resolution, like the kernel's ELOOP limit
+const maxSymlinks = 40
+
+// resolve follows symlinks in name the way the kernel would inside the
+// root filesystem, so absolute links never escape it
+func resolve(src Source, name string) (string, error) {
+	remaining := strings.Split(strings.TrimPrefix(path.Clean("/"+name), "/"), "/")
+	resolved := "/"
+	links := 0
+
+	for len(remaining) > 0 {
+		part := remaining[0]
+		remaining = remaining[1:]
+		if part == "" || part == "." {
+			continue
+		}
+		if part == ".." {
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		info, err := src.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links: %s", name)
+		}
+		target, err := src.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return resolved, nil
+}
+
+// open opens a file in src, following symlinks inside the root filesystem
+func open(src Source, name string) (*os.File, error) {
+	resolved, err := resolve(src, name)
+	if err != nil {
+		return nil, err
+	}
+	return src.Open(resolved)
+}
+
+// readDir lists a directory in src, following symlinks inside the root filesystem
+func readDir(src Source, name string) ([]string, error) {
+	resolved, err := resolve(src, name)
+	if err != nil {
+		return nil, err
+	}
+	return src.ReadDir(resolved)
+}
+
+// readStanzas parses RFC 822-style stanzas separated by blank lines, as used
+// by the apk and dpkg databases. Continuation lines are ignored.
+func readStanzas(r io.Reader, separator string, fn func(fields map[string]string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	fields := make(map[string]string)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				fn(fields)
+				fields = make(map[string]string)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, separator); ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	if len(fields) > 0 {
+		fn(fields)
+	}
+	return scanner.Err()
+}
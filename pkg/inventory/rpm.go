@@ -0,0 +1,133 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+)
+
+// rpmDirs are the locations of the rpm database, newest layout first.
+// /var/lib/rpm is often a symlink to the newer location.
+var rpmDirs = []string{"/usr/lib/sysimage/rpm", "/var/lib/rpm"}
+
+// rpm database formats, by file name
+const (
+	rpmSQLite   = "rpmdb.sqlite" // rpm 4.16 and later (Fedora 33+, RHEL 9)
+	rpmBerkeley = "Packages"     // Berkeley DB hash (RHEL 8 and earlier)
+	rpmNDB      = "Packages.db"  // rpm's own format (openSUSE)
+)
+
+// rpm header tags and types read from each package header
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagArch    = 1022
+
+	rpmTypeInt32  = 4
+	rpmTypeString = 6
+)
+
+// scanRPM reads the first rpm database found
+func scanRPM(src Source) ([]Package, error) {
+	for _, dir := range rpmDirs {
+		for _, format := range []string{rpmSQLite, rpmBerkeley, rpmNDB} {
+			f, err := open(src, path.Join(dir, format))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+
+			var packages []Package
+			add := func(blob []byte) {
+				pkg, err := parseRPMHeader(blob)
+				// The public keys rpm imports are stored as pseudo-packages
+				if err == nil && pkg.Name != "gpg-pubkey" {
+					packages = append(packages, pkg)
+				}
+			}
+
+			switch format {
+			case rpmSQLite:
+				err = readSQLitePackages(f, add)
+			case rpmBerkeley:
+				err = readBerkeleyPackages(f, add)
+			default:
+				err = fmt.Errorf("the ndb database format is not supported")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", path.Join(dir, format), err)
+			}
+			return packages, nil
+		}
+	}
+	return nil, nil
+}
+
+// parseRPMHeader reads the package name, version and architecture from a
+// header blob as stored in the rpm database: the entry count and data size,
+// followed by the index entries and the data they point into
+func parseRPMHeader(blob []byte) (Package, error) {
+	if len(blob) < 8 {
+		return Package{}, fmt.Errorf("header too short")
+	}
+	count := binary.BigEndian.Uint32(blob[0:4])
+	size := binary.BigEndian.Uint32(blob[4:8])
+	if uint64(count) > uint64(len(blob)-8)/16 {
+		return Package{}, fmt.Errorf("invalid header entry count %d", count)
+	}
+	dataStart := 8 + int(count)*16
+	if uint64(size) > uint64(len(blob)-dataStart) {
+		return Package{}, fmt.Errorf("invalid header data size %d", size)
+	}
+	data := blob[dataStart : dataStart+int(size)]
+
+	values := make(map[uint32]string)
+	for i := 0; i < int(count); i++ {
+		entry := blob[8+i*16 : 8+(i+1)*16]
+		tag := binary.BigEndian.Uint32(entry[0:4])
+		kind := binary.BigEndian.Uint32(entry[4:8])
+		offset := binary.BigEndian.Uint32(entry[8:12])
+		if uint64(offset) >= uint64(len(data)) {
+			continue
+		}
+
+		switch {
+		case tag == rpmTagEpoch && kind == rpmTypeInt32 && int(offset)+4 <= len(data):
+			values[tag] = strconv.FormatUint(uint64(binary.BigEndian.Uint32(data[offset:])), 10)
+		case tag >= rpmTagName && tag <= rpmTagRelease || tag == rpmTagArch:
+			if kind != rpmTypeString {
+				continue
+			}
+			if end := bytes.IndexByte(data[offset:], 0); end >= 0 {
+				values[tag] = string(data[offset : int(offset)+end])
+			}
+		}
+	}
+
+	if values[rpmTagName] == "" {
+		return Package{}, fmt.Errorf("header has no package name")
+	}
+
+	version := values[rpmTagVersion]
+	if release := values[rpmTagRelease]; release != "" {
+		version += "-" + release
+	}
+	if epoch := values[rpmTagEpoch]; epoch != "" && epoch != "0" {
+		version = epoch + ":" + version
+	}
+
+	return Package{
+		Name:    values[rpmTagName],
+		Version: version,
+		Arch:    values[rpmTagArch],
+		Manager: ManagerRPM,
+	}, nil
+}
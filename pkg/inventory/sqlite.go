@@ -0,0 +1,264 @@
+package inventory
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// This is a minimal reader for the SQLite file format, enough to walk the
+// Packages table of rpmdb.sqlite. Changes still in a write-ahead log are
+// not seen; rpm checkpoints the log when it closes the database.
+
+const (
+	sqliteMagic         = "SQLite format 3\x00"
+	sqliteLeafTable     = 0x0d
+	sqliteInteriorTable = 0x05
+	// sqliteMaxDepth bounds b-tree recursion on corrupt files
+	sqliteMaxDepth = 64
+	// sqliteMaxPayload bounds the size of a single row
+	sqliteMaxPayload = 256 << 20
+)
+
+type sqliteDB struct {
+	f        *os.File
+	pageSize int
+	usable   int
+}
+
+// readSQLitePackages calls fn with the header blob of every row in the
+// Packages table
+func readSQLitePackages(f *os.File, fn func([]byte)) error {
+	header := make([]byte, 100)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("failed to read database header: %v", err)
+	}
+	if string(header[:16]) != sqliteMagic {
+		return fmt.Errorf("not a SQLite database")
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(header[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return fmt.Errorf("invalid page size %d", pageSize)
+	}
+	db := &sqliteDB{f: f, pageSize: pageSize, usable: pageSize - int(header[20])}
+
+	// Find the Packages table in the schema table, which is rooted at page 1
+	var root uint32
+	err := db.walkTable(1, 0, func(record []byte) error {
+		kind, _ := recordColumn(record, 0)
+		name, _ := recordColumn(record, 1)
+		if string(kind) == "table" && string(name) == "Packages" {
+			rootPage, err := recordColumn(record, 3)
+			if err != nil {
+				return err
+			}
+			root = uint32(bigEndianInt(rootPage))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if root == 0 {
+		return fmt.Errorf("no Packages table")
+	}
+
+	// Packages has an INTEGER PRIMARY KEY column, stored as the rowid, and
+	// the header blob
+	return db.walkTable(root, 0, func(record []byte) error {
+		blob, err := recordColumn(record, 1)
+		if err != nil {
+			return err
+		}
+		fn(blob)
+		return nil
+	})
+}
+
+// page reads a page by its 1-based number
+func (db *sqliteDB) page(number uint32) ([]byte, error) {
+	if number == 0 {
+		return nil, fmt.Errorf("invalid page number 0")
+	}
+	buf := make([]byte, db.pageSize)
+	if _, err := db.f.ReadAt(buf, int64(number-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %v", number, err)
+	}
+	return buf, nil
+}
+
+// walkTable calls fn with the record of every row in a table b-tree
+func (db *sqliteDB) walkTable(number uint32, depth int, fn func([]byte) error) error {
+	if depth > sqliteMaxDepth {
+		return fmt.Errorf("b-tree too deep")
+	}
+	page, err := db.page(number)
+	if err != nil {
+		return err
+	}
+
+	// Page 1 starts with the database header
+	start := 0
+	if number == 1 {
+		start = 100
+	}
+
+	kind := page[start]
+	cells := int(binary.BigEndian.Uint16(page[start+3:]))
+	pointers := start + 8
+	if kind == sqliteInteriorTable {
+		pointers = start + 12
+	} else if kind != sqliteLeafTable {
+		return fmt.Errorf("page %d is not a table b-tree page", number)
+	}
+	if pointers+cells*2 > len(page) {
+		return fmt.Errorf("page %d has an invalid cell count", number)
+	}
+
+	for i := 0; i < cells; i++ {
+		offset := int(binary.BigEndian.Uint16(page[pointers+i*2:]))
+		if offset >= len(page) {
+			return fmt.Errorf("page %d has an invalid cell offset", number)
+		}
+
+		if kind == sqliteInteriorTable {
+			if offset+4 > len(page) {
+				return fmt.Errorf("page %d has an invalid cell offset", number)
+			}
+			if err := db.walkTable(binary.BigEndian.Uint32(page[offset:]), depth+1, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		payload, err := db.payload(page, offset)
+		if err != nil {
+			return err
+		}
+		if err := fn(payload); err != nil {
+			return err
+		}
+	}
+
+	if kind == sqliteInteriorTable {
+		return db.walkTable(binary.BigEndian.Uint32(page[start+8:]), depth+1, fn)
+	}
+	return nil
+}
+
+// payload reads the record in a table leaf cell, following overflow pages
+func (db *sqliteDB) payload(page []byte, offset int) ([]byte, error) {
+	size, n := sqliteVarint(page[offset:])
+	offset += n
+	_, n = sqliteVarint(page[offset:]) // rowid
+	offset += n
+	if size > sqliteMaxPayload {
+		return nil, fmt.Errorf("row too large")
+	}
+
+	total := int(size)
+	local := total
+	if maxLocal := db.usable - 35; total > maxLocal {
+		minLocal := (db.usable-12)*32/255 - 23
+		local = minLocal + (total-minLocal)%(db.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if offset+local > len(page) {
+		return nil, fmt.Errorf("cell overflows its page")
+	}
+
+	payload := make([]byte, 0, total)
+	payload = append(payload, page[offset:offset+local]...)
+	if local == total {
+		return payload, nil
+	}
+
+	if offset+local+4 > len(page) {
+		return nil, fmt.Errorf("cell overflows its page")
+	}
+	next := binary.BigEndian.Uint32(page[offset+local:])
+	for len(payload) < total {
+		overflow, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(overflow[0:4])
+		chunk := min(total-len(payload), db.usable-4)
+		payload = append(payload, overflow[4:4+chunk]...)
+	}
+	return payload, nil
+}
+
+// recordColumn returns the raw value of a column in a record
+func recordColumn(record []byte, column int) ([]byte, error) {
+	headerSize, n := sqliteVarint(record)
+	if headerSize > uint64(len(record)) {
+		return nil, fmt.Errorf("invalid record header")
+	}
+
+	pos := n
+	data := int(headerSize)
+	for i := 0; pos < int(headerSize); i++ {
+		serialType, n := sqliteVarint(record[pos:])
+		pos += n
+
+		size := serialTypeSize(serialType)
+		if data+size > len(record) {
+			return nil, fmt.Errorf("invalid record")
+		}
+		if i == column {
+			return record[data : data+size], nil
+		}
+		data += size
+	}
+	return nil, fmt.Errorf("record has no column %d", column)
+}
+
+// serialTypeSize returns the number of bytes a value of a serial type uses
+func serialTypeSize(serialType uint64) int {
+	switch {
+	case serialType >= 12:
+		return int((serialType - 12) / 2)
+	case serialType >= 1 && serialType <= 4:
+		return int(serialType)
+	case serialType == 5:
+		return 6
+	case serialType == 6, serialType == 7:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// sqliteVarint decodes a big-endian variable-length integer of up to 9 bytes
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 9; i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// bigEndianInt decodes a big-endian two's complement integer value
+func bigEndianInt(b []byte) int64 {
+	var v int64
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
@@ -0,0 +1,155 @@
+// Package jobs records the background work the daemon does, such as
+// prefetching images, so 'servin jobs ls' can report it from another
+// process.
+package jobs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"servin/pkg/errors"
+)
+
+// Status is the state of a job
+type Status string
+
+// Job states
+const (
+	StatusQueued  Status = "queued"
+	StatusWaiting Status = "waiting" // for its schedule, such as an off-peak window
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// TypePrefetch is the type of the jobs pulling pre-pull list images
+const TypePrefetch = "prefetch"
+
+// Job is one unit of background work
+type Job struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Target string `json:"target"` // what the job works on, such as an image
+	Status Status `json:"status"`
+	// Message explains the status, such as the error of a failed job
+	Message  string    `json:"message,omitempty"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+	// NextRun is when a waiting or finished job runs again
+	NextRun time.Time `json:"next_run,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Store persists jobs in a single file
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewStore opens the job store
+func NewStore() *Store {
+	var dir string
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		homeDir, _ := os.UserHomeDir()
+		dir = filepath.Join(homeDir, ".servin")
+	default:
+		dir = "/var/lib/servin"
+	}
+	return &Store{path: filepath.Join(dir, "jobs.json")}
+}
+
+// List returns the recorded jobs by type and ID
+func (s *Store) List() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Update records a job, replacing the one with the same ID
+func (s *Store) Update(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load()
+	if err != nil {
+		return err
+	}
+	job.Updated = time.Now()
+	replaced := false
+	for i, j := range list {
+		if j.ID == job.ID {
+			list[i] = job
+			replaced = true
+		}
+	}
+	if !replaced {
+		list = append(list, job)
+	}
+	return s.save(list)
+}
+
+// Retain removes the jobs of a type whose ID keep does not hold, such as the
+// prefetch jobs of images taken off the pre-pull list
+func (s *Store) Retain(jobType string, keep map[string]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := list[:0]
+	for _, j := range list {
+		if j.Type != jobType || keep[j.ID] {
+			kept = append(kept, j)
+		}
+	}
+	return s.save(kept)
+}
+
+func (s *Store) load() ([]*Job, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeIO, "jobs.List", "failed to read job list").
+			WithContext("path", s.path)
+	}
+
+	var list []*Job
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeIO, "jobs.List", "failed to parse job list").
+			WithContext("path", s.path)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type < list[j].Type
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (s *Store) save(list []*Job) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return errors.WrapError(err, errors.ErrTypeIO, "jobs.Update", "failed to create job directory")
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Readers in other processes never see a partly written list
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return errors.WrapError(err, errors.ErrTypeIO, "jobs.Update", "failed to write job list")
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -204,6 +204,25 @@ func InitLogger(level LogLevel, verbose bool, logFile string) error {
 	return nil
 }
 
+// InitFileLogger initializes the global logger to write to logFile only,
+// for programs that draw on the terminal
+func InitFileLogger(level LogLevel, logFile string) error {
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %v", err)
+	}
+	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defaultLogger = &Logger{
+		level:    level,
+		logger:   log.New(file, "", 0),
+		file:     file,
+		withFile: true,
+	}
+	return nil
+}
+
 // Close closes the global logger
 func Close() {
 	defaultLogger.Close()
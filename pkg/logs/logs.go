@@ -0,0 +1,106 @@
+// Package logs reads the stdout.log and stderr.log files the runtime writes
+// for each container
+package logs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+
+	"servin/pkg/state"
+)
+
+// maxRead caps how much of a log file ReadNew reads at once
+const maxRead = 1 << 20
+
+// Dir returns the directory the runtime writes a container's logs to, next
+// to the state directory
+func Dir(containerID string) string {
+	return filepath.Join(filepath.Dir(state.NewStateManager().GetStateDir()), "logs", containerID)
+}
+
+// File follows one of a container's log files. Only complete lines are
+// returned; a partial last line waits for its newline.
+type File struct {
+	Stream string
+	Path   string
+	offset int64
+}
+
+// Files returns the stdout and stderr logs of the container whose logs are
+// in dir, unread
+func Files(dir string) []*File {
+	return []*File{
+		{Stream: "stdout", Path: filepath.Join(dir, "stdout.log")},
+		{Stream: "stderr", Path: filepath.Join(dir, "stderr.log")},
+	}
+}
+
+// ReadTail returns the last n lines of the file, or all of them for n < 0,
+// and moves the offset to the end of the last complete line
+func (f *File) ReadTail(n int) []string {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil
+	}
+	end := bytes.LastIndexByte(data, '\n') + 1
+	f.offset = int64(end)
+
+	lines := splitLines(data[:end])
+	if n >= 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// ReadNew returns the complete lines written since the last read
+func (f *File) ReadNew() []string {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+	if info.Size() < f.offset {
+		// The log was truncated; start over
+		f.offset = 0
+	}
+	if info.Size() == f.offset {
+		return nil
+	}
+
+	size := info.Size() - f.offset
+	if size > maxRead {
+		size = maxRead
+	}
+	data := make([]byte, size)
+	n, err := file.ReadAt(data, f.offset)
+	if err != nil && err != io.EOF {
+		return nil
+	}
+	data = data[:n]
+
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if end == 0 && n == maxRead {
+		// A single line longer than the chunk is returned as it is
+		end = n
+	}
+	f.offset += int64(end)
+	return splitLines(data[:end])
+}
+
+// splitLines returns the non-empty lines of data
+func splitLines(data []byte) []string {
+	var lines []string
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines
+}
@@ -4,14 +4,19 @@ package namespaces
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"servin/pkg/security"
+	"servin/pkg/terminal"
+
 	"golang.org/x/sys/unix"
 )
 
@@ -43,14 +48,42 @@ type ContainerConfig struct {
 	LogDir      string            // Directory to store container logs
 	RootFS      string            // RootFS path for the container
 	Environment map[string]string // Environment variables
+	OnStart     func(pid int)     // Callback once the process has started; the command waits for it
 	OnExit      func(error)       // Callback when process exits
+	TTY         bool              // Run on a pseudo-terminal attached to servin's terminal
+
+	// Security restricts the process: ulimits, no_new_privs and seccomp
+	Security security.Options
+
+	// ForwardStdout and ForwardStderr, if set, also receive the process
+	// output, such as to forward it to the journal
+	ForwardStdout io.Writer
+	ForwardStderr io.Writer
 
 	// User namespace configuration
 	UserNamespace *UserNamespaceConfig
 }
 
+// StartFDEnvVar names the descriptor the container's init reads until
+// servin has set the process up, see WaitForStart
+const StartFDEnvVar = "SERVIN_START_FD"
+
+// WaitForStart blocks the container's init until servin has set its
+// process up: cgroups, network interfaces and user namespace mappings are
+// in place once it returns, before the command runs
+func WaitForStart() {
+	fd, err := strconv.Atoi(os.Getenv(StartFDEnvVar))
+	if err != nil {
+		return
+	}
+	os.Unsetenv(StartFDEnvVar)
+	f := os.NewFile(uintptr(fd), "start")
+	io.Copy(io.Discard, f)
+	f.Close()
+}
+
 // CreateContainer creates a new container with the specified namespaces
-func CreateContainer(config *ContainerConfig) error {
+func CreateContainer(config *ContainerConfig) (err error) {
 	// Combine all namespace flags
 	var cloneFlags uintptr
 	for _, ns := range config.Namespaces {
@@ -78,6 +111,16 @@ func CreateContainer(config *ContainerConfig) error {
 	if config.WorkDir != "" {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("WORKDIR=%s", config.WorkDir))
 	}
+	cmd.Env = append(cmd.Env, config.Security.Env()...)
+
+	// The command runs once the process is set up: closing start releases it
+	started, start, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create start pipe: %v", err)
+	}
+	defer start.Close()
+	cmd.ExtraFiles = []*os.File{started}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", StartFDEnvVar, 3))
 	// Add custom environment variables
 	for key, value := range config.Environment {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
@@ -93,17 +136,51 @@ func CreateContainer(config *ContainerConfig) error {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 	}
+	if config.ForwardStdout != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, config.ForwardStdout)
+	}
+	if config.ForwardStderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, config.ForwardStderr)
+	}
 
 	// Set up the clone flags for namespace creation
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS,
 	}
+	// Host networking shares the host's network namespace
+	if cloneFlags&uintptr(CLONE_NEWNET) != 0 {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+
+	// Output on a terminal is shown as well as logged
+	output := io.Writer(os.Stdout)
+	if config.LogDir != "" {
+		output = io.MultiWriter(os.Stdout, cmd.Stdout)
+	}
 
 	// Start the process
-	if err := cmd.Start(); err != nil {
+	var master *os.File
+	if config.TTY {
+		master, err = terminal.StartPTY(cmd)
+	} else {
+		err = cmd.Start()
+	}
+	started.Close()
+	if err != nil {
 		return fmt.Errorf("failed to start container process: %v", err)
 	}
 
+	if config.OnStart != nil {
+		config.OnStart(cmd.Process.Pid)
+	}
+
+	// Report the exit status once the process has terminated
+	if config.OnExit != nil {
+		defer func() {
+			config.OnExit(err)
+		}()
+	}
+
 	// Setup user namespace if configured
 	if config.UserNamespace != nil && config.UserNamespace.Enabled {
 		if err := SetupUserNamespace(config.UserNamespace, cmd.Process.Pid); err != nil {
@@ -112,11 +189,20 @@ func CreateContainer(config *ContainerConfig) error {
 		}
 	}
 
+	start.Close()
+
 	fmt.Printf("Creating container with namespaces: %v\n", config.Namespaces)
 	if config.UserNamespace != nil && config.UserNamespace.Enabled {
 		fmt.Printf("User namespace enabled with UID mappings: %+v\n", config.UserNamespace.UIDMappings)
 	}
 
+	// The terminal is restored before OnExit reports the exit
+	if master != nil {
+		defer master.Close()
+		detach := terminal.Connect(master, true, output)
+		defer detach()
+	}
+
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
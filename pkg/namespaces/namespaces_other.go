@@ -4,9 +4,12 @@ package namespaces
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+
+	"servin/pkg/security"
 )
 
 // NamespaceFlags represents the Linux namespace types (placeholder for non-Linux)
@@ -22,6 +25,10 @@ const (
 	CLONE_NEWUSER NamespaceFlags = 0
 )
 
+// WaitForStart returns at once: processes are not set up after they start
+// on non-Linux platforms
+func WaitForStart() {}
+
 // ContainerConfig holds namespace configuration (placeholder for non-Linux)
 type ContainerConfig struct {
 	Command     string
@@ -32,7 +39,17 @@ type ContainerConfig struct {
 	LogDir      string            // Directory to store container logs
 	RootFS      string            // RootFS path for the container
 	Environment map[string]string // Environment variables
+	OnStart     func(pid int)     // Callback once the process has started
 	OnExit      func(error)       // Callback when process exits
+	TTY         bool              // Run on a pseudo-terminal attached to servin's terminal
+
+	// Security restricts the process: ulimits, no_new_privs and seccomp
+	Security security.Options
+
+	// ForwardStdout and ForwardStderr, if set, also receive the process
+	// output, such as to forward it to the journal
+	ForwardStdout io.Writer
+	ForwardStderr io.Writer
 
 	// User namespace configuration
 	UserNamespace *UserNamespaceConfig
@@ -87,6 +104,10 @@ func CreateContainer(config *ContainerConfig) error {
 		return fmt.Errorf("failed to start command: %v", err)
 	}
 
+	if config.OnStart != nil {
+		config.OnStart(cmd.Process.Pid)
+	}
+
 	// Return immediately - don't wait for the command to finish
 	// This allows the container to be in "running" state while the command executes
 	fmt.Printf("Command started with PID %d, returning to allow container to run\n", cmd.Process.Pid)
@@ -0,0 +1,175 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// hostResolvConf is where the global DNS defaults are read from
+const hostResolvConf = "/etc/resolv.conf"
+
+// fallbackNameservers are used when the host has no usable resolv.conf
+var fallbackNameservers = []string{"8.8.8.8", "8.8.4.4"}
+
+// DNSConfig holds the DNS settings applied to containers on a network
+type DNSConfig struct {
+	Servers []string          `json:"servers,omitempty"` // Upstream nameservers
+	Search  []string          `json:"search,omitempty"`  // Search domains
+	Options []string          `json:"options,omitempty"` // resolv.conf options (e.g. ndots:2)
+	Hosts   map[string]string `json:"hosts,omitempty"`   // Static hostname to IP entries
+}
+
+// DefaultDNSConfig returns the global DNS defaults taken from the host
+func DefaultDNSConfig() *DNSConfig {
+	config := &DNSConfig{}
+	if data, err := os.ReadFile(hostResolvConf); err == nil {
+		config = ParseResolvConf(data)
+	}
+
+	// Loopback resolvers such as systemd-resolved are unreachable from a container network namespace
+	var servers []string
+	for _, server := range config.Servers {
+		if ip := net.ParseIP(server); ip != nil && !ip.IsLoopback() {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		servers = append(servers, fallbackNameservers...)
+	}
+	config.Servers = servers
+
+	return config
+}
+
+// ParseResolvConf reads nameserver, search and options entries from resolv.conf content
+func ParseResolvConf(data []byte) *DNSConfig {
+	config := &DNSConfig{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			config.Servers = append(config.Servers, fields[1])
+		case "search", "domain":
+			// The last search or domain line wins, as in the resolver
+			config.Search = append([]string{}, fields[1:]...)
+		case "options":
+			config.Options = append(config.Options, fields[1:]...)
+		}
+	}
+	return config
+}
+
+// Validate checks that servers and static hosts are valid IP addresses
+func (d *DNSConfig) Validate() error {
+	for _, server := range d.Servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid DNS server address: %s", server)
+		}
+	}
+	for _, domain := range d.Search {
+		if domain == "" || strings.ContainsAny(domain, " \t/") {
+			return fmt.Errorf("invalid DNS search domain: %q", domain)
+		}
+	}
+	for host, ip := range d.Hosts {
+		if host == "" || strings.ContainsAny(host, " \t") {
+			return fmt.Errorf("invalid host name: %q", host)
+		}
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address %q for host %s", ip, host)
+		}
+	}
+	return nil
+}
+
+// Merge returns a copy of d with the non-empty settings of override applied.
+// Servers, search domains and options are replaced as a whole; static hosts
+// are combined, with entries from override taking precedence.
+func (d *DNSConfig) Merge(override *DNSConfig) *DNSConfig {
+	merged := &DNSConfig{
+		Servers: append([]string{}, d.Servers...),
+		Search:  append([]string{}, d.Search...),
+		Options: append([]string{}, d.Options...),
+		Hosts:   make(map[string]string),
+	}
+	for host, ip := range d.Hosts {
+		merged.Hosts[host] = ip
+	}
+
+	if override == nil {
+		return merged
+	}
+
+	if len(override.Servers) > 0 {
+		merged.Servers = append([]string{}, override.Servers...)
+	}
+	if len(override.Search) > 0 {
+		merged.Search = append([]string{}, override.Search...)
+	}
+	if len(override.Options) > 0 {
+		merged.Options = append([]string{}, override.Options...)
+	}
+	for host, ip := range override.Hosts {
+		merged.Hosts[host] = ip
+	}
+
+	return merged
+}
+
+// ResolvConf renders the configuration as resolv.conf content
+func (d *DNSConfig) ResolvConf() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by servin\n")
+	for _, server := range d.Servers {
+		fmt.Fprintf(&buf, "nameserver %s\n", server)
+	}
+	if len(d.Search) > 0 {
+		fmt.Fprintf(&buf, "search %s\n", strings.Join(d.Search, " "))
+	}
+	if len(d.Options) > 0 {
+		fmt.Fprintf(&buf, "options %s\n", strings.Join(d.Options, " "))
+	}
+	return buf.Bytes()
+}
+
+// HostsFile renders /etc/hosts content for a container with the given
+// hostname and addresses, IPv4 and IPv6 on networks with IPv6
+func (d *DNSConfig) HostsFile(hostname string, ips ...net.IP) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by servin\n")
+	buf.WriteString("127.0.0.1\tlocalhost\n")
+	buf.WriteString("::1\tlocalhost ip6-localhost ip6-loopback\n")
+
+	if hostname != "" {
+		found := false
+		for _, ip := range ips {
+			if ip != nil {
+				fmt.Fprintf(&buf, "%s\t%s\n", ip, hostname)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintf(&buf, "127.0.1.1\t%s\n", hostname)
+		}
+	}
+
+	hosts := make([]string, 0, len(d.Hosts))
+	for host := range d.Hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		fmt.Fprintf(&buf, "%s\t%s\n", d.Hosts[host], host)
+	}
+
+	return buf.Bytes()
+}
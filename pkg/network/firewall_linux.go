@@ -0,0 +1,418 @@
+//go:build linux
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FirewallEnvVar selects the firewall servin programs, iptables or
+// nftables, instead of the first one installed, or userland to publish
+// ports with the userland proxy instead
+const FirewallEnvVar = "SERVIN_FIREWALL"
+
+// firewall programs the NAT of bridge networks: masquerading of their
+// outbound traffic and the DNAT rules of published ports, for IPv4 and, on
+// networks with IPv6, for IPv6. Rules are added idempotently, so setting a
+// bridge up again does not duplicate them.
+type firewall interface {
+	name() string
+	setupBridge(network *Network) error
+	removeBridge(bridge string, subnets []string)
+	publish(ip net.IP, mapping PortMapping) error
+	unpublish(ip net.IP, mapping PortMapping) error
+}
+
+// newFirewall returns the firewall named by $SERVIN_FIREWALL, else iptables
+// when installed, as it also drives the nftables backend of iptables-nft
+// and keeps to the chains other tools use, else nftables, else the
+// userland proxy, which publishes ports but cannot masquerade
+func newFirewall() (firewall, error) {
+	switch name := os.Getenv(FirewallEnvVar); name {
+	case "iptables":
+		return iptablesFirewall{}, nil
+	case "nftables", "nft":
+		return nftFirewall{}, nil
+	case "userland", "proxy":
+		return newProxyFirewall(), nil
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown firewall %q in $%s: use iptables, nftables or userland", name, FirewallEnvVar)
+	}
+
+	if _, err := exec.LookPath("iptables"); err == nil {
+		return iptablesFirewall{}, nil
+	}
+	if _, err := exec.LookPath("nft"); err == nil {
+		return nftFirewall{}, nil
+	}
+	return newProxyFirewall(), nil
+}
+
+// enableLocalnet lets connections to 127.0.0.1 be forwarded to the bridge,
+// so ports published on the host are reachable through localhost too. The
+// kernel then no longer drops packets to 127.0.0.0/8 arriving from the
+// bridge, so each firewall drops those itself unless they answer or were
+// DNATed from a connection the host made; otherwise containers could reach
+// services the host binds to localhost only.
+func enableLocalnet(bridge string) error {
+	return runCommand("sysctl", "-w", fmt.Sprintf("net.ipv4.conf.%s.route_localnet=1", bridge))
+}
+
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("command '%s %v' failed: %v, output: %s",
+			name, args, err, string(output))
+	}
+	return nil
+}
+
+// iptablesChain is the nat chain holding the DNAT rules of published ports,
+// jumped to for traffic to the host's own addresses
+const iptablesChain = "SERVIN"
+
+// iptablesFirewall programs iptables, and ip6tables for networks with IPv6
+type iptablesFirewall struct{}
+
+func (iptablesFirewall) name() string { return "iptables" }
+
+// iptablesCommand returns the command programming the rules of ip's family
+func iptablesCommand(ip net.IP) string {
+	if ip.To4() == nil {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+// ensure adds a rule unless iptables, or ip6tables, already has it
+func (iptablesFirewall) ensure(command, table, chain string, rule ...string) error {
+	check := append([]string{"-t", table, "-C", chain}, rule...)
+	if exec.Command(command, check...).Run() == nil {
+		return nil
+	}
+	return runCommand(command, append([]string{"-t", table, "-A", chain}, rule...)...)
+}
+
+// insert puts a rule first in its chain, ahead of the rules that accept
+// (such as the resolver rules), unless iptables already has it
+func (iptablesFirewall) insert(command, table, chain string, rule ...string) error {
+	check := append([]string{"-t", table, "-C", chain}, rule...)
+	if exec.Command(command, check...).Run() == nil {
+		return nil
+	}
+	return runCommand(command, append([]string{"-t", table, "-I", chain}, rule...)...)
+}
+
+// remove deletes a rule, if present
+func (iptablesFirewall) remove(command, table, chain string, rule ...string) {
+	exec.Command(command, append([]string{"-t", table, "-D", chain}, rule...)...).Run()
+}
+
+// iptablesRule is a rule of a table's chain
+type iptablesRule struct {
+	table, chain string
+	rule         []string
+}
+
+// localnetRule drops what reaches the host's localhost from a bridge, once
+// route_localnet lets it through, unless it belongs to a connection the host
+// made or was DNATed to a container
+func localnetRule(bridge string) []string {
+	return []string{"-i", bridge, "-d", "127.0.0.0/8", "-m", "conntrack", "!", "--ctstate", "RELATED,ESTABLISHED,DNAT", "-j", "DROP"}
+}
+
+// bridgeRules are the rules of a bridge common to IPv4 and IPv6
+func (iptablesFirewall) bridgeRules(bridge string) []iptablesRule {
+	return []iptablesRule{
+		{"filter", "FORWARD", []string{"-o", bridge, "-j", "ACCEPT"}},
+		{"filter", "FORWARD", []string{"-i", bridge, "-j", "ACCEPT"}},
+		// Queries to the network's resolver on the gateway
+		{"filter", "INPUT", []string{"-i", bridge, "-p", "udp", "--dport", "53", "-j", "ACCEPT"}},
+		{"filter", "INPUT", []string{"-i", bridge, "-p", "tcp", "--dport", "53", "-j", "ACCEPT"}},
+	}
+}
+
+func (fw iptablesFirewall) setupBridge(network *Network) error {
+	bridge := network.Bridge
+	subnets := []*net.IPNet{network.Subnet}
+	if network.Subnet6 != nil {
+		subnets = append(subnets, network.Subnet6)
+	}
+
+	for _, subnet := range subnets {
+		command := iptablesCommand(subnet.IP)
+
+		// The chain exists once created; creating it again fails harmlessly
+		exec.Command(command, "-t", "nat", "-N", iptablesChain).Run()
+
+		rules := []iptablesRule{
+			// Outbound traffic of the containers leaves with the host's address
+			{"nat", "POSTROUTING", []string{"-s", subnet.String(), "!", "-o", bridge, "-j", "MASQUERADE"}},
+		}
+		if command == "iptables" {
+			// Traffic forwarded to the containers from localhost too; IPv6
+			// has no equivalent of route_localnet, so not for ::1
+			rules = append(rules, iptablesRule{"nat", "POSTROUTING", []string{"-s", "127.0.0.0/8", "-o", bridge, "-j", "MASQUERADE"}})
+		}
+		rules = append(rules, fw.bridgeRules(bridge)...)
+		rules = append(rules,
+			// Published ports, for traffic from outside and from the host
+			iptablesRule{"nat", "PREROUTING", []string{"-m", "addrtype", "--dst-type", "LOCAL", "-j", iptablesChain}},
+			iptablesRule{"nat", "OUTPUT", []string{"-m", "addrtype", "--dst-type", "LOCAL", "-j", iptablesChain}},
+		)
+		for _, r := range rules {
+			if err := fw.ensure(command, r.table, r.chain, r.rule...); err != nil {
+				return fmt.Errorf("failed to add %s rule %v: %v", command, r.rule, err)
+			}
+		}
+	}
+	if err := fw.insert("iptables", "filter", "INPUT", localnetRule(bridge)...); err != nil {
+		return fmt.Errorf("failed to add iptables rule %v: %v", localnetRule(bridge), err)
+	}
+	return enableLocalnet(bridge)
+}
+
+func (fw iptablesFirewall) removeBridge(bridge string, subnets []string) {
+	for _, subnet := range subnets {
+		if ip, _, err := net.ParseCIDR(subnet); err == nil {
+			fw.remove(iptablesCommand(ip), "nat", "POSTROUTING", "-s", subnet, "!", "-o", bridge, "-j", "MASQUERADE")
+		}
+	}
+	fw.remove("iptables", "nat", "POSTROUTING", "-s", "127.0.0.0/8", "-o", bridge, "-j", "MASQUERADE")
+	fw.remove("iptables", "filter", "INPUT", localnetRule(bridge)...)
+	for _, command := range []string{"iptables", "ip6tables"} {
+		for _, r := range fw.bridgeRules(bridge) {
+			fw.remove(command, r.table, r.chain, r.rule...)
+		}
+	}
+}
+
+// dnatRule is the rule publishing a container port
+func (iptablesFirewall) dnatRule(ip net.IP, mapping PortMapping) []string {
+	rule := []string{"-p", publishedProtocol(mapping)}
+	if hostIP := publishedHostIP(mapping); hostIP != "" {
+		rule = append(rule, "-d", hostIP)
+	}
+	return append(rule,
+		"--dport", strconv.Itoa(mapping.HostPort),
+		"-j", "DNAT",
+		"--to-destination", net.JoinHostPort(ip.String(), strconv.Itoa(mapping.ContainerPort)),
+	)
+}
+
+func (fw iptablesFirewall) publish(ip net.IP, mapping PortMapping) error {
+	return fw.ensure(iptablesCommand(ip), "nat", iptablesChain, fw.dnatRule(ip, mapping)...)
+}
+
+func (fw iptablesFirewall) unpublish(ip net.IP, mapping PortMapping) error {
+	fw.remove(iptablesCommand(ip), "nat", iptablesChain, fw.dnatRule(ip, mapping)...)
+	return nil
+}
+
+// nftTable is servin's own nftables table, one in the ip family and one in
+// the ip6 family for networks with IPv6; their chains hook into netfilter
+// alongside the tables of other tools
+const nftTable = "servin"
+
+// nftSetup creates the table of a family and its chains, which nft leaves
+// alone when they exist
+func nftSetup(family string) string {
+	return fmt.Sprintf(`add table %[1]s %[2]s
+add chain %[1]s %[2]s publish
+add chain %[1]s %[2]s prerouting { type nat hook prerouting priority dstnat; }
+add chain %[1]s %[2]s output { type nat hook output priority -100; }
+add chain %[1]s %[2]s postrouting { type nat hook postrouting priority srcnat; }
+add chain %[1]s %[2]s forward { type filter hook forward priority filter; }
+add chain %[1]s %[2]s input { type filter hook input priority filter; }
+`, family, nftTable)
+}
+
+// nftFamily returns the nftables family of ip
+func nftFamily(ip net.IP) string {
+	if ip.To4() == nil {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// nftFirewall programs nftables. Rules carry a comment naming what they
+// are for, by which they are found and deleted.
+type nftFirewall struct{}
+
+func (nftFirewall) name() string { return "nftables" }
+
+func (nftFirewall) run(script string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nft failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// nftHandle matches a rule listed with its comment and handle
+var nftHandle = regexp.MustCompile(`comment "([^"]*)" # handle (\d+)`)
+
+// handles returns the handles of the rules of a family's chain with the
+// comment
+func (nftFirewall) handles(family, chain, comment string) []string {
+	output, err := exec.Command("nft", "-a", "list", "chain", family, nftTable, chain).Output()
+	if err != nil {
+		return nil
+	}
+	var handles []string
+	for _, m := range nftHandle.FindAllStringSubmatch(string(output), -1) {
+		if m[1] == comment {
+			handles = append(handles, m[2])
+		}
+	}
+	return handles
+}
+
+// ensure adds rule to a family's chain with the comment, unless a rule has it
+func (fw nftFirewall) ensure(family, chain, rule, comment string) error {
+	if len(fw.handles(family, chain, comment)) > 0 {
+		return nil
+	}
+	return fw.run(fmt.Sprintf("add rule %s %s %s %s comment %q\n", family, nftTable, chain, rule, comment))
+}
+
+// remove deletes the rules of a family's chain with the comment
+func (fw nftFirewall) remove(family, chain, comment string) {
+	for _, handle := range fw.handles(family, chain, comment) {
+		exec.Command("nft", "delete", "rule", family, nftTable, chain, "handle", handle).Run()
+	}
+}
+
+func (fw nftFirewall) setupBridge(network *Network) error {
+	bridge := network.Bridge
+	subnets := []*net.IPNet{network.Subnet}
+	if network.Subnet6 != nil {
+		subnets = append(subnets, network.Subnet6)
+	}
+
+	for _, subnet := range subnets {
+		family := nftFamily(subnet.IP)
+		if err := fw.run(nftSetup(family)); err != nil {
+			return err
+		}
+
+		rules := []struct{ chain, rule, comment string }{
+			{"postrouting", fmt.Sprintf("%s saddr %s oifname != %q masquerade", family, subnet, bridge), "masquerade " + bridge},
+		}
+		if family == "ip" {
+			rules = append(rules, []struct{ chain, rule, comment string }{
+				{"postrouting", fmt.Sprintf("ip saddr 127.0.0.0/8 oifname %q masquerade", bridge), "localhost " + bridge},
+				// As the iptables localnetRule, ahead of the resolver rule
+				// that would accept queries to a resolver on localhost
+				{"input", fmt.Sprintf("iifname %q ip daddr 127.0.0.0/8 ct state != { established, related } ct status & dnat == 0 drop", bridge), "localnet from " + bridge},
+			}...)
+		}
+		rules = append(rules, []struct{ chain, rule, comment string }{
+			{"forward", fmt.Sprintf("oifname %q accept", bridge), "forward to " + bridge},
+			{"forward", fmt.Sprintf("iifname %q accept", bridge), "forward from " + bridge},
+			{"input", fmt.Sprintf("iifname %q meta l4proto { tcp, udp } th dport 53 accept", bridge), "resolver on " + bridge},
+			{"prerouting", "fib daddr type local jump publish", "published ports"},
+			{"output", "fib daddr type local jump publish", "published ports"},
+		}...)
+		for _, r := range rules {
+			if err := fw.ensure(family, r.chain, r.rule, r.comment); err != nil {
+				return fmt.Errorf("failed to add nftables rule %q: %v", r.rule, err)
+			}
+		}
+	}
+	return enableLocalnet(bridge)
+}
+
+func (fw nftFirewall) removeBridge(bridge string, subnets []string) {
+	for _, family := range []string{"ip", "ip6"} {
+		fw.remove(family, "postrouting", "masquerade "+bridge)
+		fw.remove(family, "postrouting", "localhost "+bridge)
+		fw.remove(family, "forward", "forward to "+bridge)
+		fw.remove(family, "forward", "forward from "+bridge)
+		fw.remove(family, "input", "resolver on "+bridge)
+		fw.remove(family, "input", "localnet from "+bridge)
+	}
+}
+
+// publishComment names the rule publishing a container port
+func publishComment(ip net.IP, mapping PortMapping) string {
+	return fmt.Sprintf("publish %s:%d/%s to %s", publishedHostIP(mapping), mapping.HostPort, publishedProtocol(mapping),
+		net.JoinHostPort(ip.String(), strconv.Itoa(mapping.ContainerPort)))
+}
+
+func (fw nftFirewall) publish(ip net.IP, mapping PortMapping) error {
+	family := nftFamily(ip)
+	rule := ""
+	if hostIP := publishedHostIP(mapping); hostIP != "" {
+		rule = fmt.Sprintf("%s daddr %s ", family, hostIP)
+	}
+	rule += fmt.Sprintf("%s dport %d dnat to %s", publishedProtocol(mapping), mapping.HostPort,
+		net.JoinHostPort(ip.String(), strconv.Itoa(mapping.ContainerPort)))
+	return fw.ensure(family, "publish", rule, publishComment(ip, mapping))
+}
+
+func (fw nftFirewall) unpublish(ip net.IP, mapping PortMapping) error {
+	fw.remove(nftFamily(ip), "publish", publishComment(ip, mapping))
+	return nil
+}
+
+// proxyFirewall publishes ports with userland proxies where neither iptables
+// nor nft is installed. The proxies run in the process that started the
+// container, which lives as long as it does; bridges get no masquerading,
+// so containers reach the host and each other but not beyond.
+type proxyFirewall struct {
+	mu      sync.Mutex
+	proxies map[string]*PortProxy
+}
+
+func newProxyFirewall() *proxyFirewall {
+	return &proxyFirewall{proxies: make(map[string]*PortProxy)}
+}
+
+func (*proxyFirewall) name() string { return "userland" }
+
+func (*proxyFirewall) setupBridge(network *Network) error {
+	return fmt.Errorf("neither iptables nor nft is installed: containers on %s cannot reach beyond the host", network.Bridge)
+}
+
+func (*proxyFirewall) removeBridge(bridge string, subnets []string) {}
+
+func (fw *proxyFirewall) publish(ip net.IP, mapping PortMapping) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	key := publishComment(ip, mapping)
+	if fw.proxies[key] != nil {
+		return nil
+	}
+	proxy, err := StartPortProxy(publishedProtocol(mapping),
+		net.JoinHostPort(publishedHostIP(mapping), strconv.Itoa(mapping.HostPort)),
+		net.JoinHostPort(ip.String(), strconv.Itoa(mapping.ContainerPort)))
+	if err != nil {
+		return err
+	}
+	fw.proxies[key] = proxy
+	return nil
+}
+
+func (fw *proxyFirewall) unpublish(ip net.IP, mapping PortMapping) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	key := publishComment(ip, mapping)
+	if proxy := fw.proxies[key]; proxy != nil {
+		delete(fw.proxies, key)
+		return proxy.Close()
+	}
+	return nil
+}
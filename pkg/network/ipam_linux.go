@@ -3,144 +3,184 @@
 package network
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
-	"sync"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
 )
 
-// IPAddressManager manages IP address allocation for containers
+// IPAddressManager allocates container addresses in the subnets of servin
+// networks. Allocations are kept in ipam.json next to the network index and
+// changed under a lock, so containers started by different servin
+// processes never get the same address.
 type IPAddressManager struct {
-	allocated map[string]map[string]bool // subnet -> ip -> allocated
-	mutex     sync.RWMutex
+	path string
+	lock string
+}
+
+// allocation is an address given to a container
+type allocation struct {
+	Container string `json:"container"`
+	// Veth is the host side of the container's veth pair. An allocation
+	// whose veth no longer exists was left by a servin process that did
+	// not clean up, and is free again.
+	Veth string `json:"veth,omitempty"`
 }
 
+// ipamState maps subnets to their allocated addresses
+type ipamState map[string]map[string]allocation
+
 // NewIPAddressManager creates a new IP address manager
 func NewIPAddressManager() *IPAddressManager {
+	dir := NewStore().networkDir
 	return &IPAddressManager{
-		allocated: make(map[string]map[string]bool),
+		path: filepath.Join(dir, "ipam.json"),
+		lock: filepath.Join(dir, "ipam.lock"),
 	}
 }
 
-// AllocateIP allocates the next available IP address in the given subnet
-func (ipam *IPAddressManager) AllocateIP(subnet *net.IPNet) (net.IP, error) {
-	ipam.mutex.Lock()
-	defer ipam.mutex.Unlock()
-
-	subnetKey := subnet.String()
-
-	// Initialize subnet tracking if not exists
-	if ipam.allocated[subnetKey] == nil {
-		ipam.allocated[subnetKey] = make(map[string]bool)
-	}
-
-	// Get the network address and find the next available IP
-	networkIP := subnet.IP.Mask(subnet.Mask)
-
-	// Start from .2 (skip .0 for network and .1 for gateway)
-	ip := make(net.IP, len(networkIP))
-	copy(ip, networkIP)
-
-	// Increment to .2
-	incrementIP(ip)
-	incrementIP(ip)
-
-	// Find next available IP
-	for subnet.Contains(ip) {
-		ipStr := ip.String()
-
-		// Skip broadcast address
-		if isBroadcast(ip, subnet) {
-			break
-		}
-
-		// Check if IP is available
-		if !ipam.allocated[subnetKey][ipStr] {
-			ipam.allocated[subnetKey][ipStr] = true
-			return dupIP(ip), nil
+// AllocateIP allocates the next available IP address in the given subnet to
+// the container whose host-side veth is veth
+func (ipam *IPAddressManager) AllocateIP(subnet *net.IPNet, containerID, veth string) (net.IP, error) {
+	var allocated net.IP
+	err := ipam.update(func(state ipamState) error {
+		subnetKey := subnet.String()
+		if state[subnetKey] == nil {
+			state[subnetKey] = make(map[string]allocation)
 		}
+		used := state[subnetKey]
 
+		// Start from .2 (skip .0 for network and .1 for gateway)
+		ip := dupIP(subnet.IP.Mask(subnet.Mask))
+		incrementIP(ip)
 		incrementIP(ip)
-	}
 
-	return nil, fmt.Errorf("no available IP addresses in subnet %s", subnet.String())
+		for ; subnet.Contains(ip) && !isBroadcast(ip, subnet); incrementIP(ip) {
+			if a, ok := used[ip.String()]; ok && (a.Veth == "" || vethExists(a.Veth)) {
+				continue
+			}
+			used[ip.String()] = allocation{Container: containerID, Veth: veth}
+			allocated = dupIP(ip)
+			return nil
+		}
+		return fmt.Errorf("no available IP addresses in subnet %s", subnetKey)
+	})
+	return allocated, err
 }
 
 // ReleaseIP releases an allocated IP address
 func (ipam *IPAddressManager) ReleaseIP(subnet *net.IPNet, ip net.IP) {
-	ipam.mutex.Lock()
-	defer ipam.mutex.Unlock()
-
-	subnetKey := subnet.String()
-	ipStr := ip.String()
-
-	if ipam.allocated[subnetKey] != nil {
-		delete(ipam.allocated[subnetKey], ipStr)
-	}
+	ipam.update(func(state ipamState) error {
+		delete(state[subnet.String()], ip.String())
+		return nil
+	})
 }
 
 // IsIPAllocated checks if an IP is already allocated
 func (ipam *IPAddressManager) IsIPAllocated(subnet *net.IPNet, ip net.IP) bool {
-	ipam.mutex.RLock()
-	defer ipam.mutex.RUnlock()
-
-	subnetKey := subnet.String()
-	ipStr := ip.String()
-
-	if ipam.allocated[subnetKey] == nil {
+	state, err := ipam.load()
+	if err != nil {
 		return false
 	}
-
-	return ipam.allocated[subnetKey][ipStr]
+	_, ok := state[subnet.String()][ip.String()]
+	return ok
 }
 
 // GetAllocatedIPs returns all allocated IPs in a subnet
 func (ipam *IPAddressManager) GetAllocatedIPs(subnet *net.IPNet) []net.IP {
-	ipam.mutex.RLock()
-	defer ipam.mutex.RUnlock()
+	state, err := ipam.load()
+	if err != nil {
+		return nil
+	}
 
-	subnetKey := subnet.String()
 	var ips []net.IP
-
-	if ipam.allocated[subnetKey] != nil {
-		for ipStr := range ipam.allocated[subnetKey] {
-			if ip := net.ParseIP(ipStr); ip != nil {
-				ips = append(ips, ip)
-			}
+	for ipStr := range state[subnet.String()] {
+		if ip := net.ParseIP(ipStr); ip != nil {
+			ips = append(ips, ip)
 		}
 	}
-
 	return ips
 }
 
 // GetAvailableIPCount returns the number of available IPs in a subnet
 func (ipam *IPAddressManager) GetAvailableIPCount(subnet *net.IPNet) int {
-	ipam.mutex.RLock()
-	defer ipam.mutex.RUnlock()
-
-	// Calculate total IPs in subnet
+	// Calculate total IPs in subnet; IPv6 subnets are counted up to 2^31
 	ones, bits := subnet.Mask.Size()
+	if bits-ones > 31 {
+		ones = bits - 31
+	}
 	totalIPs := 1 << uint(bits-ones)
 
 	// Subtract network, gateway, and broadcast
-	usableIPs := totalIPs - 3
+	available := totalIPs - 3 - len(ipam.GetAllocatedIPs(subnet))
+	if available < 0 {
+		available = 0
+	}
+	return available
+}
 
-	// Subtract allocated IPs
-	subnetKey := subnet.String()
-	allocatedCount := 0
-	if ipam.allocated[subnetKey] != nil {
-		allocatedCount = len(ipam.allocated[subnetKey])
+// load reads the allocations
+func (ipam *IPAddressManager) load() (ipamState, error) {
+	state := make(ipamState)
+	data, err := os.ReadFile(ipam.path)
+	if os.IsNotExist(err) {
+		return state, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IP allocations: %v", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", ipam.path, err)
+	}
+	return state, nil
+}
 
-	available := usableIPs - allocatedCount
-	if available < 0 {
-		available = 0
+// update changes the allocations with fn under the IPAM lock, saving them
+// when fn succeeds
+func (ipam *IPAddressManager) update(fn func(ipamState) error) error {
+	if err := os.MkdirAll(filepath.Dir(ipam.path), 0755); err != nil {
+		return fmt.Errorf("failed to create network directory: %v", err)
+	}
+	lock, err := os.OpenFile(ipam.lock, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open IPAM lock: %v", err)
 	}
+	defer lock.Close()
+	if err := unix.Flock(int(lock.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock IP allocations: %v", err)
+	}
+	defer unix.Flock(int(lock.Fd()), unix.LOCK_UN)
 
-	return available
+	state, err := ipam.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(state); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal IP allocations: %v", err)
+	}
+	tmp := ipam.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write IP allocations: %v", err)
+	}
+	return os.Rename(tmp, ipam.path)
 }
 
 // Helper functions
 
+// vethExists reports whether the host has the network interface
+func vethExists(name string) bool {
+	_, err := os.Stat(filepath.Join("/sys/class/net", name))
+	return err == nil
+}
+
 // incrementIP increments an IP address by one
 func incrementIP(ip net.IP) {
 	for i := len(ip) - 1; i >= 0; i-- {
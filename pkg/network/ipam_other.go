@@ -16,7 +16,7 @@ func NewIPAddressManager() *IPAddressManager {
 }
 
 // AllocateIP allocates the next available IP address in the given subnet (stub)
-func (ipam *IPAddressManager) AllocateIP(subnet *net.IPNet) (net.IP, error) {
+func (ipam *IPAddressManager) AllocateIP(subnet *net.IPNet, containerID, veth string) (net.IP, error) {
 	return nil, fmt.Errorf("IP address management is only supported on Linux")
 }
 
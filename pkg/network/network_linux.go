@@ -5,6 +5,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -24,12 +25,15 @@ const (
 
 // Network represents a container network
 type Network struct {
-	Name       string      `json:"name"`
-	Mode       NetworkMode `json:"mode"`
-	Bridge     string      `json:"bridge"`
-	Subnet     *net.IPNet  `json:"subnet"`
-	Gateway    net.IP      `json:"gateway"`
-	IPAMDriver string      `json:"ipam_driver"`
+	Name    string      `json:"name"`
+	Mode    NetworkMode `json:"mode"`
+	Bridge  string      `json:"bridge"`
+	Subnet  *net.IPNet  `json:"subnet"`
+	Gateway net.IP      `json:"gateway"`
+	// Subnet6 and Gateway6 are set on networks with IPv6
+	Subnet6    *net.IPNet `json:"subnet6,omitempty"`
+	Gateway6   net.IP     `json:"gateway6,omitempty"`
+	IPAMDriver string     `json:"ipam_driver"`
 }
 
 // ContainerNetwork represents network configuration for a specific container
@@ -37,9 +41,11 @@ type ContainerNetwork struct {
 	ContainerID   string            `json:"container_id"`
 	NetworkName   string            `json:"network_name"`
 	IP            net.IP            `json:"ip"`
+	IP6           net.IP            `json:"ip6,omitempty"` // Set on networks with IPv6
 	MAC           string            `json:"mac"`
 	VethHost      string            `json:"veth_host"`      // Host-side veth interface
 	VethContainer string            `json:"veth_container"` // Container-side veth interface
+	Interface     string            `json:"interface"`      // Name of the interface in the container
 	PortMappings  []PortMapping     `json:"port_mappings"`
 	ExtraHosts    map[string]string `json:"extra_hosts"`
 }
@@ -56,124 +62,203 @@ type PortMapping struct {
 type NetworkManager struct {
 	networks map[string]*Network
 	ipam     *IPAddressManager
+	fw       firewall
 }
 
-// NewNetworkManager creates a new network manager
+// containerInterface is the name of the veth end in the container
+const containerInterface = "eth0"
+
+// NewNetworkManager creates a new network manager. The default bridge is
+// created when a container first needs it.
 func NewNetworkManager() *NetworkManager {
-	nm := &NetworkManager{
+	return &NetworkManager{
 		networks: make(map[string]*Network),
 		ipam:     NewIPAddressManager(),
 	}
+}
 
-	// Create default bridge network
-	if err := nm.CreateDefaultBridge(); err != nil {
-		fmt.Printf("Warning: failed to create default bridge: %v\n", err)
+// firewall returns the firewall NAT rules are programmed with
+func (nm *NetworkManager) firewall() (firewall, error) {
+	if nm.fw == nil {
+		fw, err := newFirewall()
+		if err != nil {
+			return nil, err
+		}
+		nm.fw = fw
 	}
-
-	return nm
+	return nm.fw, nil
 }
 
 // CreateDefaultBridge creates the default servin bridge network
 func (nm *NetworkManager) CreateDefaultBridge() error {
-	// Default subnet for servin containers: 172.17.0.0/16
-	_, subnet, err := net.ParseCIDR("172.17.0.0/16")
+	_, err := nm.ensureNetwork(DefaultBridge)
+	return err
+}
+
+// network returns the bridge network a container's network mode refers to,
+// as configured in the store
+func (nm *NetworkManager) network(name string) (*Network, error) {
+	name = CanonicalName(name)
+	if network := nm.networks[name]; network != nil {
+		return network, nil
+	}
+
+	config, err := NewStore().Resolve(name)
 	if err != nil {
-		return fmt.Errorf("failed to parse default subnet: %v", err)
+		return nil, err
+	}
+	if NetworkMode(config.Driver) != BridgeMode {
+		return nil, fmt.Errorf("network %s uses the %s driver and has no bridge", name, config.Driver)
 	}
 
-	gateway := net.ParseIP("172.17.0.1")
+	_, subnet, err := net.ParseCIDR(config.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subnet of network %s: %v", name, err)
+	}
+	gateway := net.ParseIP(config.Gateway)
 	if gateway == nil {
-		return fmt.Errorf("failed to parse gateway IP")
+		return nil, fmt.Errorf("failed to parse gateway IP of network %s", name)
 	}
 
 	network := &Network{
-		Name:       "servin0",
+		Name:       config.Name,
 		Mode:       BridgeMode,
-		Bridge:     "servin0",
+		Bridge:     config.BridgeName(),
 		Subnet:     subnet,
 		Gateway:    gateway,
 		IPAMDriver: "default",
 	}
+	if config.IPv6 {
+		if _, network.Subnet6, err = net.ParseCIDR(config.Subnet6); err != nil {
+			return nil, fmt.Errorf("failed to parse IPv6 subnet of network %s: %v", name, err)
+		}
+		if network.Gateway6 = net.ParseIP(config.Gateway6); network.Gateway6 == nil {
+			return nil, fmt.Errorf("failed to parse IPv6 gateway of network %s", name)
+		}
+	}
+	return network, nil
+}
 
-	return nm.CreateBridge(network)
+// ensureNetwork returns the named bridge network, creating its bridge on the
+// subnet the store assigned it on first use and warning when a host route,
+// typically one pushed by a VPN since, now overlaps it
+func (nm *NetworkManager) ensureNetwork(name string) (*Network, error) {
+	network, err := nm.network(name)
+	if err != nil {
+		return nil, err
+	}
+	if nm.networks[network.Name] != nil {
+		return network, nil
+	}
+
+	for _, r := range SubnetConflicts(network.Subnet.String(), HostRoutes()) {
+		fmt.Printf("Warning: subnet %s of network %s overlaps host route %s; run 'servin network reassign %s'\n",
+			network.Subnet, network.Name, r, network.Name)
+	}
+
+	if err := nm.CreateBridge(network); err != nil {
+		return nil, err
+	}
+	return network, nil
 }
 
-// CreateBridge creates a bridge network
+// CreateBridge creates a bridge network, or sets up the NAT of an existing
+// one again, as its rules do not survive a reboot or a firewall reload
 func (nm *NetworkManager) CreateBridge(network *Network) error {
 	bridgeName := network.Bridge
 
-	// Check if bridge already exists
-	if nm.bridgeExists(bridgeName) {
-		fmt.Printf("Bridge %s already exists\n", bridgeName)
-		nm.networks[network.Name] = network
-		return nil
-	}
+	if !nm.bridgeExists(bridgeName) {
+		// Create bridge interface
+		if err := runCommand("ip", "link", "add", "name", bridgeName, "type", "bridge"); err != nil {
+			return fmt.Errorf("failed to create bridge %s: %v", bridgeName, err)
+		}
 
-	// Create bridge interface
-	if err := nm.runCommand("ip", "link", "add", "name", bridgeName, "type", "bridge"); err != nil {
-		return fmt.Errorf("failed to create bridge %s: %v", bridgeName, err)
+		// Set bridge IP address
+		ones, _ := network.Subnet.Mask.Size()
+		cidr := fmt.Sprintf("%s/%d", network.Gateway.String(), ones)
+		if err := runCommand("ip", "addr", "add", cidr, "dev", bridgeName); err != nil {
+			runCommand("ip", "link", "del", bridgeName)
+			return fmt.Errorf("failed to set bridge IP: %v", err)
+		}
+		fmt.Printf("Created bridge network %s (%s)\n", network.Name, bridgeName)
 	}
 
-	// Set bridge IP address
-	cidr := fmt.Sprintf("%s/%d", network.Gateway.String(),
-		func() int { ones, _ := network.Subnet.Mask.Size(); return ones }())
-
-	if err := nm.runCommand("ip", "addr", "add", cidr, "dev", bridgeName); err != nil {
-		return fmt.Errorf("failed to set bridge IP: %v", err)
+	// The IPv6 gateway is set on every setup, replace keeping it idempotent;
+	// nodad makes it usable at once
+	if network.Subnet6 != nil {
+		ones, _ := network.Subnet6.Mask.Size()
+		cidr := fmt.Sprintf("%s/%d", network.Gateway6, ones)
+		if err := runCommand("ip", "-6", "addr", "replace", cidr, "dev", bridgeName, "nodad"); err != nil {
+			return fmt.Errorf("failed to set bridge IPv6 address: %v", err)
+		}
 	}
 
 	// Bring bridge up
-	if err := nm.runCommand("ip", "link", "set", bridgeName, "up"); err != nil {
+	if err := runCommand("ip", "link", "set", bridgeName, "up"); err != nil {
 		return fmt.Errorf("failed to bring bridge up: %v", err)
 	}
 
 	// Enable IP forwarding
-	if err := nm.runCommand("sysctl", "-w", "net.ipv4.ip_forward=1"); err != nil {
+	if err := runCommand("sysctl", "-w", "net.ipv4.ip_forward=1"); err != nil {
 		fmt.Printf("Warning: failed to enable IP forwarding: %v\n", err)
 	}
+	if network.Subnet6 != nil {
+		if err := runCommand("sysctl", "-w", "net.ipv6.conf.all.forwarding=1"); err != nil {
+			fmt.Printf("Warning: failed to enable IPv6 forwarding: %v\n", err)
+		}
+	}
 
-	// Add iptables rules for NAT (outbound traffic)
+	// Masquerade outbound traffic and route published ports
 	if err := nm.setupNATRules(network); err != nil {
 		fmt.Printf("Warning: failed to setup NAT rules: %v\n", err)
 	}
 
 	nm.networks[network.Name] = network
-	fmt.Printf("Created bridge network %s (%s)\n", network.Name, bridgeName)
 	return nil
 }
 
-// CreateVethPair creates a virtual ethernet pair for container networking
-func (nm *NetworkManager) CreateVethPair(containerID string) (*ContainerNetwork, error) {
-	// Generate unique interface names
-	vethHost := fmt.Sprintf("veth%s", containerID[:8])
-	vethContainer := fmt.Sprintf("veth%s_c", containerID[:8])
-
-	// Create veth pair
-	if err := nm.runCommand("ip", "link", "add", vethHost, "type", "veth", "peer", "name", vethContainer); err != nil {
-		return nil, fmt.Errorf("failed to create veth pair: %v", err)
+// CreateVethPair creates a virtual ethernet pair connecting a container to
+// a bridge network, the default one for the bridge mode, creating the bridge
+// if needed, and allocates the container's address on it
+func (nm *NetworkManager) CreateVethPair(containerID, networkName string) (*ContainerNetwork, error) {
+	network, err := nm.ensureNetwork(networkName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up network %s: %v", CanonicalName(networkName), err)
 	}
 
-	// Get default network
-	defaultNetwork := nm.networks["servin0"]
-	if defaultNetwork == nil {
-		return nil, fmt.Errorf("default network not found")
+	vethHost := VethName(containerID, network.Name)
+	vethContainer := vethHost + "_c"
+
+	// A pair left by a container that was not cleaned up is replaced
+	if vethExists(vethHost) {
+		runCommand("ip", "link", "del", vethHost)
+	}
+	if err := runCommand("ip", "link", "add", vethHost, "type", "veth", "peer", "name", vethContainer); err != nil {
+		return nil, fmt.Errorf("failed to create veth pair: %v", err)
 	}
 
 	// Allocate IP address for container
-	containerIP, err := nm.ipam.AllocateIP(defaultNetwork.Subnet)
+	containerIP, err := nm.ipam.AllocateIP(network.Subnet, containerID, vethHost)
 	if err != nil {
-		nm.runCommand("ip", "link", "del", vethHost) // Cleanup on failure
+		runCommand("ip", "link", "del", vethHost) // Cleanup on failure
 		return nil, fmt.Errorf("failed to allocate IP: %v", err)
 	}
 
-	// Generate MAC address
-	mac := generateMAC()
+	var containerIP6 net.IP
+	if network.Subnet6 != nil {
+		if containerIP6, err = nm.ipam.AllocateIP(network.Subnet6, containerID, vethHost); err != nil {
+			nm.ipam.ReleaseIP(network.Subnet, containerIP)
+			runCommand("ip", "link", "del", vethHost)
+			return nil, fmt.Errorf("failed to allocate IPv6 address: %v", err)
+		}
+	}
 
 	containerNet := &ContainerNetwork{
 		ContainerID:   containerID,
-		NetworkName:   "servin0",
+		NetworkName:   network.Name,
 		IP:            containerIP,
-		MAC:           mac,
+		IP6:           containerIP6,
+		MAC:           generateMAC(containerIP),
 		VethHost:      vethHost,
 		VethContainer: vethContainer,
 		PortMappings:  []PortMapping{},
@@ -183,170 +268,242 @@ func (nm *NetworkManager) CreateVethPair(containerID string) (*ContainerNetwork,
 	return containerNet, nil
 }
 
-// AttachContainerToNetwork attaches a container to the bridge network
-func (nm *NetworkManager) AttachContainerToNetwork(containerNet *ContainerNetwork, netNS string) error {
+// AttachContainerToNetwork plugs the host end of the container's veth pair
+// into its bridge and moves the other end into the network namespace of
+// the container process pid. It becomes eth0 with the container's address
+// and a default route through the bridge, or for a container already on
+// another network the next free ethN, without a route.
+func (nm *NetworkManager) AttachContainerToNetwork(containerNet *ContainerNetwork, pid int) error {
+	network, err := nm.ensureNetwork(containerNet.NetworkName)
+	if err != nil {
+		return err
+	}
 	vethHost := containerNet.VethHost
-	vethContainer := containerNet.VethContainer
-	bridgeName := "servin0"
 
 	// Attach host-side veth to bridge
-	if err := nm.runCommand("ip", "link", "set", vethHost, "master", bridgeName); err != nil {
+	if err := runCommand("ip", "link", "set", vethHost, "master", network.Bridge); err != nil {
 		return fmt.Errorf("failed to attach veth to bridge: %v", err)
 	}
 
 	// Bring host-side veth up
-	if err := nm.runCommand("ip", "link", "set", vethHost, "up"); err != nil {
+	if err := runCommand("ip", "link", "set", vethHost, "up"); err != nil {
 		return fmt.Errorf("failed to bring host veth up: %v", err)
 	}
 
 	// Move container-side veth to container network namespace
-	if netNS != "" {
-		if err := nm.runCommand("ip", "link", "set", vethContainer, "netns", netNS); err != nil {
-			return fmt.Errorf("failed to move veth to netns: %v", err)
-		}
+	if err := runCommand("ip", "link", "set", containerNet.VethContainer, "netns", strconv.Itoa(pid)); err != nil {
+		return fmt.Errorf("failed to move veth to netns: %v", err)
 	}
 
-	// Configure container-side interface (this would typically be done inside the container)
-	// For now, we'll set up what we can from the host side
-	if netNS != "" {
-		// Set container interface IP and bring it up
-		cidr := fmt.Sprintf("%s/16", containerNet.IP.String())
-		if err := nm.runInNetNS(netNS, "ip", "addr", "add", cidr, "dev", vethContainer); err != nil {
-			return fmt.Errorf("failed to set container IP: %v", err)
+	ifname := freeInterface(pid)
+	ones, _ := network.Subnet.Mask.Size()
+	steps := [][]string{
+		{"ip", "link", "set", containerNet.VethContainer, "name", ifname},
+		{"ip", "link", "set", ifname, "address", containerNet.MAC},
+		{"ip", "addr", "add", fmt.Sprintf("%s/%d", containerNet.IP, ones), "dev", ifname},
+		{"ip", "link", "set", ifname, "up"},
+	}
+	if ifname == containerInterface {
+		steps = append(steps, []string{"ip", "route", "add", "default", "via", network.Gateway.String()})
+	}
+	if containerNet.IP6 != nil {
+		ones, _ := network.Subnet6.Mask.Size()
+		steps = append(steps,
+			[]string{"sysctl", "-w", fmt.Sprintf("net.ipv6.conf.%s.disable_ipv6=0", ifname)},
+			[]string{"ip", "-6", "addr", "add", fmt.Sprintf("%s/%d", containerNet.IP6, ones), "dev", ifname, "nodad"})
+		if ifname == containerInterface {
+			steps = append(steps, []string{"ip", "-6", "route", "add", "default", "via", network.Gateway6.String()})
 		}
-
-		if err := nm.runInNetNS(netNS, "ip", "link", "set", vethContainer, "up"); err != nil {
-			return fmt.Errorf("failed to bring container veth up: %v", err)
+	}
+	for _, step := range steps {
+		if err := runInNetNS(pid, step[0], step[1:]...); err != nil {
+			return fmt.Errorf("failed to configure %s in the container: %v", ifname, err)
 		}
+	}
+	containerNet.Interface = ifname
+
+	addrs := containerNet.IP.String()
+	if containerNet.IP6 != nil {
+		addrs += ", " + containerNet.IP6.String()
+	}
+	fmt.Printf("Attached container %s to network %s (IP: %s)\n",
+		containerNet.ContainerID[:12], containerNet.NetworkName, addrs)
+	return nil
+}
 
-		// Set default route
-		gateway := "172.17.0.1"
-		if err := nm.runInNetNS(netNS, "ip", "route", "add", "default", "via", gateway); err != nil {
-			fmt.Printf("Warning: failed to set default route: %v\n", err)
+// freeInterface returns the first ethN the network namespace of process pid
+// does not have
+func freeInterface(pid int) string {
+	data, _ := os.ReadFile(fmt.Sprintf("/proc/%d/net/dev", pid))
+	used := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, _, ok := strings.Cut(line, ":"); ok {
+			used[strings.TrimSpace(name)] = true
 		}
 	}
+	for i := 0; ; i++ {
+		if name := fmt.Sprintf("eth%d", i); !used[name] {
+			return name
+		}
+	}
+}
 
-	fmt.Printf("Attached container %s to network (IP: %s)\n",
-		containerNet.ContainerID[:12], containerNet.IP.String())
-	return nil
+// SetupLoopback brings up the loopback interface in the network namespace
+// of the container process pid, which is all a container without a network
+// has
+func SetupLoopback(pid int) error {
+	return runInNetNS(pid, "ip", "link", "set", "lo", "up")
 }
 
-// DetachContainerFromNetwork removes container from network
+// DetachContainerFromNetwork removes the container's published ports and
+// veth pair, and releases its address
 func (nm *NetworkManager) DetachContainerFromNetwork(containerNet *ContainerNetwork) error {
-	// Delete veth pair (this automatically removes both ends)
-	if err := nm.runCommand("ip", "link", "del", containerNet.VethHost); err != nil {
-		// Don't return error if interface doesn't exist
-		if !strings.Contains(err.Error(), "Cannot find device") {
+	if fw, err := nm.firewall(); err == nil {
+		for _, mapping := range containerNet.PortMappings {
+			for _, ip := range publishTargets(containerNet, mapping) {
+				fw.unpublish(ip, mapping)
+			}
+		}
+	}
+	containerNet.PortMappings = nil
+
+	// Delete veth pair (this automatically removes both ends); it is gone
+	// already when the container's network namespace went away
+	if vethExists(containerNet.VethHost) {
+		if err := runCommand("ip", "link", "del", containerNet.VethHost); err != nil {
 			return fmt.Errorf("failed to delete veth pair: %v", err)
 		}
 	}
 
 	// Release IP address
-	network := nm.networks[containerNet.NetworkName]
-	if network != nil {
-		nm.ipam.ReleaseIP(network.Subnet, containerNet.IP)
+	if network, err := nm.network(containerNet.NetworkName); err == nil {
+		if containerNet.IP != nil {
+			nm.ipam.ReleaseIP(network.Subnet, containerNet.IP)
+		}
+		if containerNet.IP6 != nil && network.Subnet6 != nil {
+			nm.ipam.ReleaseIP(network.Subnet6, containerNet.IP6)
+		}
 	}
 
-	fmt.Printf("Detached container %s from network\n", containerNet.ContainerID[:12])
+	fmt.Printf("Detached container %s from network %s\n", containerNet.ContainerID[:12], containerNet.NetworkName)
 	return nil
 }
 
-// SetupPortMapping configures port forwarding from host to container
+// SetupPortMapping publishes a container port on the host with a DNAT rule,
+// for traffic from other machines and from the host itself, localhost
+// included. On a network with IPv6 a port published on all host addresses
+// is published on the host's IPv6 addresses too, to the container's.
 func (nm *NetworkManager) SetupPortMapping(containerNet *ContainerNetwork, mapping PortMapping) error {
+	fw, err := nm.firewall()
+	if err != nil {
+		return fmt.Errorf("failed to add port mapping rule: %v", err)
+	}
+	targets := publishTargets(containerNet, mapping)
+	if len(targets) == 0 {
+		return fmt.Errorf("container has no address of the family of host address %s", mapping.HostIP)
+	}
+	for _, ip := range targets {
+		if err := fw.publish(ip, mapping); err != nil {
+			return fmt.Errorf("failed to add port mapping rule: %v", err)
+		}
+	}
+
 	hostIP := mapping.HostIP
 	if hostIP == "" {
 		hostIP = "0.0.0.0"
 	}
-
-	protocol := strings.ToLower(mapping.Protocol)
-	if protocol == "" {
-		protocol = "tcp"
+	containerNet.PortMappings = append(containerNet.PortMappings, mapping)
+	for _, ip := range targets {
+		fmt.Printf("Port mapping: %s -> %s (%s)\n",
+			net.JoinHostPort(hostIPFor(hostIP, ip), strconv.Itoa(mapping.HostPort)),
+			net.JoinHostPort(ip.String(), strconv.Itoa(mapping.ContainerPort)), publishedProtocol(mapping))
 	}
 
-	// Add iptables DNAT rule for port forwarding
-	rule := []string{
-		"-t", "nat",
-		"-A", "PREROUTING",
-		"-p", protocol,
-		"-d", hostIP,
-		"--dport", strconv.Itoa(mapping.HostPort),
-		"-j", "DNAT",
-		"--to-destination", fmt.Sprintf("%s:%d", containerNet.IP.String(), mapping.ContainerPort),
-	}
+	return nil
+}
 
-	if err := nm.runCommand("iptables", rule...); err != nil {
-		return fmt.Errorf("failed to add port mapping rule: %v", err)
+// publishTargets returns the container addresses a port mapping forwards
+// to: the one of the family of its host address, 0.0.0.0 and :: included,
+// or both the container's IPv4 and IPv6 addresses when none was given
+func publishTargets(containerNet *ContainerNetwork, mapping PortMapping) []net.IP {
+	var targets []net.IP
+	hostIP := net.ParseIP(mapping.HostIP)
+	for _, ip := range []net.IP{containerNet.IP, containerNet.IP6} {
+		if ip == nil {
+			continue
+		}
+		if hostIP == nil || (hostIP.To4() == nil) == (ip.To4() == nil) {
+			targets = append(targets, ip)
+		}
 	}
+	return targets
+}
 
-	containerNet.PortMappings = append(containerNet.PortMappings, mapping)
-	fmt.Printf("Port mapping: %s:%d -> %s:%d (%s)\n",
-		hostIP, mapping.HostPort, containerNet.IP.String(), mapping.ContainerPort, protocol)
-
-	return nil
+// hostIPFor returns the host address a mapping to ip is published on, for
+// display: the unspecified address of ip's family for all addresses
+func hostIPFor(hostIP string, ip net.IP) string {
+	if hostIP == "0.0.0.0" && ip.To4() == nil {
+		return "::"
+	}
+	return hostIP
 }
 
 // Helper methods
 
 func (nm *NetworkManager) bridgeExists(bridgeName string) bool {
-	err := nm.runCommand("ip", "link", "show", bridgeName)
-	return err == nil
+	return vethExists(bridgeName)
 }
 
-func (nm *NetworkManager) runCommand(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("command '%s %v' failed: %v, output: %s",
-			name, args, err, string(output))
-	}
-	return nil
-}
-
-func (nm *NetworkManager) runInNetNS(netns, name string, args ...string) error {
-	// Prepend 'ip netns exec <namespace>' to the command
-	fullArgs := append([]string{"netns", "exec", netns, name}, args...)
-	return nm.runCommand("ip", fullArgs...)
+// runInNetNS runs a host command in the network namespace of process pid
+func runInNetNS(pid int, name string, args ...string) error {
+	fullArgs := append([]string{"--net=/proc/" + strconv.Itoa(pid) + "/ns/net", name}, args...)
+	return runCommand("nsenter", fullArgs...)
 }
 
 func (nm *NetworkManager) setupNATRules(network *Network) error {
-	subnet := network.Subnet.String()
-
-	// Enable masquerading for outbound traffic from containers
-	rules := [][]string{
-		{"-t", "nat", "-A", "POSTROUTING", "-s", subnet, "!", "-o", network.Bridge, "-j", "MASQUERADE"},
-		{"-A", "FORWARD", "-o", network.Bridge, "-j", "ACCEPT"},
-		{"-A", "FORWARD", "-i", network.Bridge, "!", "-o", network.Bridge, "-j", "ACCEPT"},
-		{"-A", "FORWARD", "-i", network.Bridge, "-o", network.Bridge, "-j", "ACCEPT"},
+	fw, err := nm.firewall()
+	if err != nil {
+		return err
 	}
+	return fw.setupBridge(network)
+}
 
-	for _, rule := range rules {
-		if err := nm.runCommand("iptables", rule...); err != nil {
-			return fmt.Errorf("failed to add iptables rule %v: %v", rule, err)
-		}
+// ResetBridge deletes a bridge and the NAT rules of its old subnets, IPv4
+// and IPv6, so the next start recreates it with its current configuration
+func ResetBridge(bridge string, oldSubnets ...string) error {
+	cmd := exec.Command("ip", "link", "del", bridge)
+	if output, err := cmd.CombinedOutput(); err != nil && !strings.Contains(string(output), "Cannot find device") {
+		return fmt.Errorf("failed to delete bridge %s: %v, output: %s", bridge, err, string(output))
+	}
+	if fw, err := newFirewall(); err == nil {
+		fw.removeBridge(bridge, oldSubnets)
 	}
-
 	return nil
 }
 
-// generateMAC generates a random MAC address for container interface
-func generateMAC() string {
-	// Use a locally administered MAC address (second bit of first octet set)
-	return fmt.Sprintf("02:42:%02x:%02x:%02x:%02x",
-		byte(0), byte(0), byte(0), byte(1)) // Simplified for demo
+// generateMAC derives the MAC address of a container interface from its
+// address, locally administered (second bit of first octet set) like
+// Docker's, so addresses and MACs never disagree on a bridge
+func generateMAC(ip net.IP) string {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return "02:42:00:00:00:01"
+	}
+	return fmt.Sprintf("02:42:%02x:%02x:%02x:%02x", ip4[0], ip4[1], ip4[2], ip4[3])
 }
 
-// Cleanup removes all servin network resources
+// Cleanup removes the bridges of this manager and their NAT rules
 func (nm *NetworkManager) Cleanup() error {
 	for _, network := range nm.networks {
 		if network.Mode == BridgeMode {
-			// Remove bridge
-			nm.runCommand("ip", "link", "del", network.Bridge)
+			subnets := []string{network.Subnet.String()}
+			if network.Subnet6 != nil {
+				subnets = append(subnets, network.Subnet6.String())
+			}
+			if err := ResetBridge(network.Bridge, subnets...); err != nil {
+				return err
+			}
 		}
 	}
-
-	// Remove iptables rules (would need more sophisticated tracking)
-	// For now, just print a warning
-	fmt.Println("Note: Manual iptables cleanup may be required")
-
 	return nil
 }
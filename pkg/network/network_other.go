@@ -23,6 +23,8 @@ type Network struct {
 	Bridge     string      `json:"bridge"`
 	Subnet     *net.IPNet  `json:"subnet"`
 	Gateway    net.IP      `json:"gateway"`
+	Subnet6    *net.IPNet  `json:"subnet6,omitempty"`
+	Gateway6   net.IP      `json:"gateway6,omitempty"`
 	IPAMDriver string      `json:"ipam_driver"`
 }
 
@@ -31,9 +33,11 @@ type ContainerNetwork struct {
 	ContainerID   string            `json:"container_id"`
 	NetworkName   string            `json:"network_name"`
 	IP            net.IP            `json:"ip"`
+	IP6           net.IP            `json:"ip6,omitempty"`
 	MAC           string            `json:"mac"`
 	VethHost      string            `json:"veth_host"`
 	VethContainer string            `json:"veth_container"`
+	Interface     string            `json:"interface"`
 	PortMappings  []PortMapping     `json:"port_mappings"`
 	ExtraHosts    map[string]string `json:"extra_hosts"`
 }
@@ -65,12 +69,17 @@ func (nm *NetworkManager) CreateBridge(network *Network) error {
 }
 
 // CreateVethPair creates a virtual ethernet pair for container networking (stub)
-func (nm *NetworkManager) CreateVethPair(containerID string) (*ContainerNetwork, error) {
+func (nm *NetworkManager) CreateVethPair(containerID, networkName string) (*ContainerNetwork, error) {
 	return nil, fmt.Errorf("networking is only supported on Linux")
 }
 
 // AttachContainerToNetwork attaches a container to the bridge network (stub)
-func (nm *NetworkManager) AttachContainerToNetwork(containerNet *ContainerNetwork, netNS string) error {
+func (nm *NetworkManager) AttachContainerToNetwork(containerNet *ContainerNetwork, pid int) error {
+	return fmt.Errorf("networking is only supported on Linux")
+}
+
+// SetupLoopback brings up the loopback interface of a container (stub)
+func SetupLoopback(pid int) error {
 	return fmt.Errorf("networking is only supported on Linux")
 }
 
@@ -84,6 +93,11 @@ func (nm *NetworkManager) SetupPortMapping(containerNet *ContainerNetwork, mappi
 	return fmt.Errorf("networking is only supported on Linux")
 }
 
+// ResetBridge deletes a bridge so it is recreated (stub)
+func ResetBridge(bridge string, oldSubnets ...string) error {
+	return nil
+}
+
 // Cleanup removes all servin network resources (stub)
 func (nm *NetworkManager) Cleanup() error {
 	return nil
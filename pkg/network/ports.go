@@ -0,0 +1,92 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Default ephemeral port range, used when the kernel's cannot be read
+const (
+	defaultPortRangeLow  = 32768
+	defaultPortRangeHigh = 60999
+)
+
+// publishedProtocol returns the protocol of a port mapping, tcp by default
+func publishedProtocol(mapping PortMapping) string {
+	if protocol := strings.ToLower(mapping.Protocol); protocol != "" {
+		return protocol
+	}
+	return "tcp"
+}
+
+// publishedHostIP returns the host address a port is published on, or ""
+// for all of them, of the family of 0.0.0.0 or ::
+func publishedHostIP(mapping PortMapping) string {
+	if ip := net.ParseIP(mapping.HostIP); ip != nil && ip.IsUnspecified() {
+		return ""
+	}
+	return mapping.HostIP
+}
+
+// String formats a published port like "0.0.0.0:8080->80/tcp"
+func (m PortMapping) String() string {
+	hostIP := m.HostIP
+	if hostIP == "" {
+		hostIP = "0.0.0.0"
+	}
+	return fmt.Sprintf("%s->%d/%s", net.JoinHostPort(hostIP, strconv.Itoa(m.HostPort)), m.ContainerPort, publishedProtocol(m))
+}
+
+// Overlaps reports whether two mappings publish the same host port for the
+// same protocol on a host address they share
+func (m PortMapping) Overlaps(other PortMapping) bool {
+	if m.HostPort != other.HostPort || publishedProtocol(m) != publishedProtocol(other) {
+		return false
+	}
+	hostIP, otherIP := publishedHostIP(m), publishedHostIP(other)
+	return hostIP == "" || otherIP == "" || net.ParseIP(hostIP).Equal(net.ParseIP(otherIP))
+}
+
+// PortFree reports, by binding it, whether nothing on the host listens on
+// the host port of a mapping
+func PortFree(mapping PortMapping) error {
+	address := net.JoinHostPort(publishedHostIP(mapping), strconv.Itoa(mapping.HostPort))
+	switch publishedProtocol(mapping) {
+	case "tcp":
+		ln, err := net.Listen("tcp", address)
+		if err != nil {
+			return err
+		}
+		return ln.Close()
+	case "udp":
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		return fmt.Errorf("unsupported protocol %q", mapping.Protocol)
+	}
+}
+
+// LocalPortRange returns the host's ephemeral port range, which ports
+// published without a host port are allocated from
+func LocalPortRange() (int, int) {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range")
+	if err != nil {
+		return defaultPortRangeLow, defaultPortRangeHigh
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return defaultPortRangeLow, defaultPortRangeHigh
+	}
+	low, err1 := strconv.Atoi(fields[0])
+	high, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil || low <= 0 || high < low || high > 65535 {
+		return defaultPortRangeLow, defaultPortRangeHigh
+	}
+	return low, high
+}
@@ -0,0 +1,151 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Userland proxy: publishes a container port by listening on the host port
+// and copying connections, or datagrams, to the container's address. It is
+// used where servin cannot program NAT rules, as it needs no firewall, but
+// only runs as long as the process that started it.
+
+const (
+	// proxyDialTimeout bounds connecting to the container for a client
+	proxyDialTimeout = 5 * time.Second
+	// proxyUDPIdle is how long a UDP client's flow is kept without traffic
+	proxyUDPIdle = 90 * time.Second
+)
+
+// PortProxy forwards a host address to a container address
+type PortProxy struct {
+	Protocol string
+	Listen   string
+	Target   string
+
+	closer io.Closer
+}
+
+// StartPortProxy listens on the host address listen, IPv4 or IPv6 like
+// target, and forwards what it receives over protocol, tcp or udp, to target
+func StartPortProxy(protocol, listen, target string) (*PortProxy, error) {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	family := "4"
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		family = "6"
+	}
+
+	p := &PortProxy{Protocol: protocol, Listen: listen, Target: target}
+	switch protocol {
+	case "tcp":
+		ln, err := net.Listen("tcp"+family, listen)
+		if err != nil {
+			return nil, err
+		}
+		p.closer = ln
+		go p.serveTCP(ln)
+	case "udp":
+		conn, err := net.ListenPacket("udp"+family, listen)
+		if err != nil {
+			return nil, err
+		}
+		p.closer = conn
+		go p.serveUDP(conn)
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+	return p, nil
+}
+
+// Close stops listening; connections already forwarded run until closed
+func (p *PortProxy) Close() error {
+	return p.closer.Close()
+}
+
+func (p *PortProxy) serveTCP(ln net.Listener) {
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.forwardTCP(client)
+	}
+}
+
+// forwardTCP copies a client connection to a new one to the container in
+// both directions, passing on half-closes
+func (p *PortProxy) forwardTCP(client net.Conn) {
+	defer client.Close()
+	backend, err := net.DialTimeout("tcp", p.Target, proxyDialTimeout)
+	if err != nil {
+		return
+	}
+	defer backend.Close()
+
+	var wg sync.WaitGroup
+	copyHalf := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+	}
+	wg.Add(2)
+	go copyHalf(backend, client)
+	go copyHalf(client, backend)
+	wg.Wait()
+}
+
+// serveUDP forwards datagrams, keeping a socket to the container per client
+// so replies go back to the client they answer
+func (p *PortProxy) serveUDP(conn net.PacketConn) {
+	var mu sync.Mutex
+	flows := make(map[string]net.Conn)
+	buf := make([]byte, 65535)
+
+	for {
+		n, client, err := conn.ReadFrom(buf)
+		if err != nil {
+			mu.Lock()
+			for _, backend := range flows {
+				backend.Close()
+			}
+			mu.Unlock()
+			return
+		}
+
+		mu.Lock()
+		backend := flows[client.String()]
+		if backend == nil {
+			if backend, err = net.Dial("udp", p.Target); err != nil {
+				mu.Unlock()
+				continue
+			}
+			flows[client.String()] = backend
+			go func(client net.Addr, backend net.Conn) {
+				reply := make([]byte, 65535)
+				for {
+					backend.SetReadDeadline(time.Now().Add(proxyUDPIdle))
+					n, err := backend.Read(reply)
+					if err != nil {
+						break
+					}
+					conn.WriteTo(reply[:n], client)
+				}
+				mu.Lock()
+				delete(flows, client.String())
+				mu.Unlock()
+				backend.Close()
+			}(client, backend)
+		}
+		mu.Unlock()
+
+		backend.Write(buf[:n])
+	}
+}
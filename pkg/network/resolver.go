@@ -0,0 +1,346 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Embedded DNS: every user-defined bridge network gets a resolver listening
+// on its gateway, which containers on the network use as their nameserver.
+// It answers the names of the containers on the network, their hostnames
+// and network aliases, and forwards other queries to the network's upstream
+// nameservers.
+
+const (
+	// resolverPort is the port the resolver listens on at the gateway
+	resolverPort = 53
+	// resolverTTL is the TTL of container records, short as containers come
+	// and go and change addresses when restarted
+	resolverTTL = 5
+	// upstreamTimeout bounds each query forwarded upstream
+	upstreamTimeout = 3 * time.Second
+	// resolverIdleCheck is how often a resolver checks it is still needed
+	resolverIdleCheck = time.Minute
+)
+
+// DNS message constants used by the resolver
+const (
+	dnsHeaderLen   = 12
+	dnsTypeA       = 1
+	dnsTypeAAAA    = 28
+	dnsClassIN     = 1
+	dnsRcodeFailed = 2
+)
+
+// Resolver is the DNS server of a user-defined network
+type Resolver struct {
+	Network string
+	// Lookup returns the addresses name resolves to for the client, a
+	// container on the network; found is false for names that are not
+	// containers, which are forwarded upstream
+	Lookup func(client net.IP, name string) (ips []net.IP, found bool)
+	// Upstream returns the nameservers a client set for itself, which its
+	// other queries go to instead of the network's
+	Upstream func(client net.IP) []string
+	// Active reports whether containers still use the network; the resolver
+	// exits once none do
+	Active func() bool
+}
+
+// ResolverPIDPath returns the file holding the PID of a network's resolver
+func ResolverPIDPath(name string) string {
+	return filepath.Join(NewStore().networkDir, "resolvers", name+".pid")
+}
+
+// ResolverLogPath returns the file a network's resolver writes its output to
+func ResolverLogPath(name string) string {
+	return filepath.Join(NewStore().networkDir, "resolvers", name+".log")
+}
+
+// Run serves DNS on the network's gateway over UDP and TCP until no
+// containers use the network any more
+func (r *Resolver) Run() error {
+	config, err := NewStore().Get(r.Network)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(config.Gateway, strconv.Itoa(resolverPort))
+
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s/udp: %v", addr, err)
+	}
+	defer udp.Close()
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s/tcp: %v", addr, err)
+	}
+	defer tcp.Close()
+
+	pidPath := ResolverPIDPath(r.Network)
+	if err := os.MkdirAll(filepath.Dir(pidPath), 0755); err != nil {
+		return fmt.Errorf("failed to create resolver directory: %v", err)
+	}
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write resolver PID file: %v", err)
+	}
+	defer os.Remove(pidPath)
+
+	fmt.Printf("Resolver for network %s listening on %s\n", r.Network, addr)
+	go r.serveUDP(udp)
+	go r.serveTCP(tcp)
+
+	for range time.Tick(resolverIdleCheck) {
+		if r.Active != nil && !r.Active() {
+			fmt.Printf("No containers on network %s, resolver exiting\n", r.Network)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			var client net.IP
+			if udpAddr, ok := addr.(*net.UDPAddr); ok {
+				client = udpAddr.IP
+			}
+			if reply := r.handle(client, query, "udp"); reply != nil {
+				conn.WriteTo(reply, addr)
+			}
+		}()
+	}
+}
+
+func (r *Resolver) serveTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var client net.IP
+			if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+				client = tcpAddr.IP
+			}
+			for {
+				conn.SetDeadline(time.Now().Add(upstreamTimeout * 2))
+				query, err := readTCPMessage(conn)
+				if err != nil {
+					return
+				}
+				reply := r.handle(client, query, "tcp")
+				if reply == nil || writeTCPMessage(conn, reply) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// handle answers a query for a container name, or forwards it upstream
+func (r *Resolver) handle(client net.IP, query []byte, proto string) []byte {
+	name, qtype, end, ok := parseQuestion(query)
+	if ok && r.Lookup != nil {
+		dns := r.dnsConfig()
+		if ips, found := r.Lookup(client, stripSearch(name, dns.Search)); found {
+			return answer(query, end, qtype, ips)
+		}
+	}
+	return r.forward(client, query, proto)
+}
+
+// dnsConfig returns the network's DNS settings, read on every query so
+// 'servin network update' applies at once
+func (r *Resolver) dnsConfig() *DNSConfig {
+	dns, err := NewStore().DNSFor(r.Network)
+	if err != nil {
+		return DefaultDNSConfig()
+	}
+	return dns
+}
+
+// forward relays a query to the client's or the network's upstream
+// nameservers in turn, answering SERVFAIL when none replies
+func (r *Resolver) forward(client net.IP, query []byte, proto string) []byte {
+	var servers []string
+	if r.Upstream != nil {
+		servers = r.Upstream(client)
+	}
+	if len(servers) == 0 {
+		servers = r.dnsConfig().Servers
+	}
+	for _, server := range servers {
+		if reply, err := exchange(net.JoinHostPort(server, "53"), query, proto); err == nil {
+			return reply
+		}
+	}
+	return failure(query)
+}
+
+// exchange sends a query to server and returns its reply
+func exchange(server string, query []byte, proto string) ([]byte, error) {
+	conn, err := net.DialTimeout(proto, server, upstreamTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(upstreamTimeout))
+
+	if proto == "tcp" {
+		if err := writeTCPMessage(conn, query); err != nil {
+			return nil, err
+		}
+		return readTCPMessage(conn)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// readTCPMessage reads a DNS message prefixed with its length
+func readTCPMessage(conn io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeTCPMessage writes a DNS message prefixed with its length
+func writeTCPMessage(conn io.Writer, msg []byte) error {
+	buf := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	_, err := conn.Write(append(buf, msg...))
+	return err
+}
+
+// stripSearch removes a search domain from a queried name, as resolvers
+// try short names with the search domains appended first
+func stripSearch(name string, search []string) string {
+	for _, domain := range search {
+		if suffix := "." + strings.ToLower(strings.TrimSuffix(domain, ".")); strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// parseQuestion returns the name and type of the first question of a
+// standard query, and where the question ends
+func parseQuestion(msg []byte) (name string, qtype uint16, end int, ok bool) {
+	if len(msg) < dnsHeaderLen {
+		return "", 0, 0, false
+	}
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	if flags&0x8000 != 0 || (flags>>11)&0xF != 0 || binary.BigEndian.Uint16(msg[4:6]) == 0 {
+		return "", 0, 0, false
+	}
+
+	var labels []string
+	i := dnsHeaderLen
+	for {
+		if i >= len(msg) {
+			return "", 0, 0, false
+		}
+		length := int(msg[i])
+		i++
+		if length == 0 {
+			break
+		}
+		// Compression is not used in the question of a query
+		if length&0xC0 != 0 || i+length > len(msg) {
+			return "", 0, 0, false
+		}
+		labels = append(labels, string(msg[i:i+length]))
+		i += length
+	}
+	if i+4 > len(msg) || binary.BigEndian.Uint16(msg[i+2:i+4]) != dnsClassIN {
+		return "", 0, 0, false
+	}
+	return strings.ToLower(strings.Join(labels, ".")), binary.BigEndian.Uint16(msg[i : i+2]), i + 4, true
+}
+
+// replyHeader returns the header of a reply to query with the rcode and
+// number of answers
+func replyHeader(query []byte, rcode uint16, answers int) []byte {
+	flags := binary.BigEndian.Uint16(query[2:4])
+	header := make([]byte, dnsHeaderLen)
+	copy(header[0:2], query[0:2])
+	// QR, the query's opcode and RD, AA and RA
+	binary.BigEndian.PutUint16(header[2:4], 0x8000|flags&0x7800|0x0400|flags&0x0100|0x0080|rcode)
+	binary.BigEndian.PutUint16(header[4:6], 1)
+	binary.BigEndian.PutUint16(header[6:8], uint16(answers))
+	return header
+}
+
+// answer replies to a query with the addresses of the queried type, none
+// for other types, so resolvers do not look the name up elsewhere
+func answer(query []byte, end int, qtype uint16, ips []net.IP) []byte {
+	var records [][]byte
+	for _, ip := range ips {
+		var rtype uint16
+		var data []byte
+		if ip4 := ip.To4(); ip4 != nil {
+			rtype, data = dnsTypeA, ip4
+		} else {
+			rtype, data = dnsTypeAAAA, ip.To16()
+		}
+		if rtype != qtype {
+			continue
+		}
+		record := make([]byte, 12, 12+len(data))
+		// The name is a pointer to the question's
+		binary.BigEndian.PutUint16(record[0:2], 0xC000|dnsHeaderLen)
+		binary.BigEndian.PutUint16(record[2:4], rtype)
+		binary.BigEndian.PutUint16(record[4:6], dnsClassIN)
+		binary.BigEndian.PutUint32(record[6:10], resolverTTL)
+		binary.BigEndian.PutUint16(record[10:12], uint16(len(data)))
+		records = append(records, append(record, data...))
+	}
+
+	reply := append(replyHeader(query, 0, len(records)), query[dnsHeaderLen:end]...)
+	for _, record := range records {
+		reply = append(reply, record...)
+	}
+	return reply
+}
+
+// failure answers a query with SERVFAIL
+func failure(query []byte) []byte {
+	if len(query) < dnsHeaderLen {
+		return nil
+	}
+	_, _, end, ok := parseQuestion(query)
+	if !ok {
+		reply := replyHeader(query, dnsRcodeFailed, 0)
+		binary.BigEndian.PutUint16(reply[4:6], 0)
+		return reply
+	}
+	return append(replyHeader(query, dnsRcodeFailed, 0), query[dnsHeaderLen:end]...)
+}
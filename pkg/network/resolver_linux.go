@@ -0,0 +1,97 @@
+//go:build linux
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// resolverStartTimeout is how long a new resolver has to start listening
+const resolverStartTimeout = 5 * time.Second
+
+// StartResolver starts the resolver of a user-defined bridge network unless
+// it runs already, creating the network's bridge first so the resolver can
+// listen on its gateway. The resolver runs in its own session, so it
+// outlives the container starting it.
+func StartResolver(name string) error {
+	if resolverPID(name) > 0 {
+		return nil
+	}
+	if _, err := NewNetworkManager().ensureNetwork(name); err != nil {
+		return err
+	}
+
+	logPath := ResolverLogPath(name)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return fmt.Errorf("failed to create resolver directory: %v", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create resolver log: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command("/proc/self/exe", "network", "resolver", name)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start resolver: %v", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	deadline := time.After(resolverStartTimeout)
+	for {
+		if resolverPID(name) > 0 {
+			return nil
+		}
+		select {
+		case <-exited:
+			// Another container may have started the resolver first
+			if resolverPID(name) > 0 {
+				return nil
+			}
+			data, _ := os.ReadFile(logPath)
+			return fmt.Errorf("resolver exited: %s", strings.TrimSpace(string(data)))
+		case <-deadline:
+			cmd.Process.Kill()
+			return fmt.Errorf("resolver did not start within %v", resolverStartTimeout)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// StopResolver stops the resolver of a network, if it runs, and removes
+// its files
+func StopResolver(name string) {
+	if pid := resolverPID(name); pid > 0 {
+		syscall.Kill(pid, syscall.SIGTERM)
+	}
+	os.Remove(ResolverPIDPath(name))
+	os.Remove(ResolverLogPath(name))
+}
+
+// resolverPID returns the PID of the running resolver of a network, or 0
+func resolverPID(name string) int {
+	data, err := os.ReadFile(ResolverPIDPath(name))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || syscall.Kill(pid, 0) != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package network
+
+import "fmt"
+
+// StartResolver starts the resolver of a network (stub)
+func StartResolver(name string) error {
+	return fmt.Errorf("networking is only supported on Linux")
+}
+
+// StopResolver stops the resolver of a network (stub)
+func StopResolver(name string) {}
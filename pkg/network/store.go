@@ -0,0 +1,583 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"servin/pkg/errors"
+	"servin/pkg/logger"
+)
+
+// NetworkConfig is the persisted definition of a user-defined network
+type NetworkConfig struct {
+	Name    string `json:"name"`
+	Driver  string `json:"driver"`
+	Subnet  string `json:"subnet,omitempty"`
+	Gateway string `json:"gateway,omitempty"`
+	// IPv6 gives the containers of a bridge network an address in Subnet6
+	// as well, with Gateway6 as their IPv6 default route
+	IPv6     bool   `json:"ipv6,omitempty"`
+	Subnet6  string `json:"subnet6,omitempty"`
+	Gateway6 string `json:"gateway6,omitempty"`
+	// Bridge is the host interface of a bridge network
+	Bridge    string            `json:"bridge,omitempty"`
+	DNS       *DNSConfig        `json:"dns,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// DefaultBridge is the name of the bridge network containers join unless
+// given another
+const DefaultBridge = "servin0"
+
+// IsBuiltinNetwork reports whether name refers to one of the built-in network modes
+func IsBuiltinNetwork(name string) bool {
+	switch NetworkMode(name) {
+	case BridgeMode, HostMode, NoneMode:
+		return true
+	}
+	return false
+}
+
+// bridgePrefix starts the interface names of user-defined bridges
+const bridgePrefix = "servin-"
+
+// BridgeName returns the host interface of a bridge network: servin0 for
+// the default bridge, else servin- and the network name, or a hash of it
+// when the name does not fit the 15 characters of an interface name
+func (c *NetworkConfig) BridgeName() string {
+	if c.Bridge != "" {
+		return c.Bridge
+	}
+	if c.Name == DefaultBridge {
+		return DefaultBridge
+	}
+	if name := bridgePrefix + c.Name; len(name) <= 15 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(c.Name))
+	return bridgePrefix + hex.EncodeToString(sum[:])[:15-len(bridgePrefix)]
+}
+
+// VethName returns the host end of the veth pair connecting a container to
+// a network; the container end is the same name with _c appended. Pairs on
+// the default bridge are named after the container alone, others also after
+// the network, so a container can be on several networks at once.
+func VethName(containerID, network string) string {
+	if CanonicalName(network) == DefaultBridge {
+		return "veth" + containerID[:8]
+	}
+	sum := sha256.Sum256([]byte(network))
+	return "veth" + containerID[:5] + hex.EncodeToString(sum[:])[:4]
+}
+
+// CanonicalName returns the network a container's network mode refers to,
+// mapping the bridge mode to the default bridge
+func CanonicalName(mode string) string {
+	if mode == "" || mode == string(BridgeMode) {
+		return DefaultBridge
+	}
+	return mode
+}
+
+// Store persists user-defined networks
+type Store struct {
+	networkDir  string
+	indexPath   string
+	defaultPath string
+}
+
+// NewStore creates a new network store
+func NewStore() *Store {
+	var networkDir string
+
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		// Windows and macOS: Use user home directory
+		homeDir, _ := os.UserHomeDir()
+		networkDir = filepath.Join(homeDir, ".servin", "networks")
+	default:
+		// Linux and other Unix-like systems: Use system directory
+		networkDir = "/var/lib/servin/networks"
+	}
+
+	return &Store{
+		networkDir:  networkDir,
+		indexPath:   filepath.Join(networkDir, "index.json"),
+		defaultPath: filepath.Join(networkDir, DefaultBridge+".json"),
+	}
+}
+
+// List returns all user-defined networks
+func (s *Store) List() ([]*NetworkConfig, error) {
+	data, err := os.ReadFile(s.indexPath)
+	if os.IsNotExist(err) {
+		return []*NetworkConfig{}, nil
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeIO, "ListNetworks", "failed to read network index").
+			WithContext("index_path", s.indexPath)
+	}
+
+	var networks []*NetworkConfig
+	if err := json.Unmarshal(data, &networks); err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeIO, "ListNetworks", "failed to parse network index").
+			WithContext("index_path", s.indexPath)
+	}
+
+	return networks, nil
+}
+
+// Get retrieves a user-defined network by name
+func (s *Store) Get(name string) (*NetworkConfig, error) {
+	networks, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range networks {
+		if n.Name == name {
+			return n, nil
+		}
+	}
+
+	return nil, errors.NewNotFoundError("GetNetwork", fmt.Sprintf("network '%s' not found", name))
+}
+
+// Resolve returns the network a container's network mode refers to: the
+// default bridge for the bridge mode, a user-defined network by name, or a
+// network of the host and none drivers for those modes
+func (s *Store) Resolve(mode string) (*NetworkConfig, error) {
+	switch name := CanonicalName(mode); name {
+	case DefaultBridge:
+		return s.Default()
+	case string(HostMode), string(NoneMode):
+		return &NetworkConfig{Name: name, Driver: name}, nil
+	default:
+		return s.Get(name)
+	}
+}
+
+// Create validates and stores a new user-defined network
+func (s *Store) Create(config *NetworkConfig) error {
+	if err := validateNetworkConfig(config); err != nil {
+		return err
+	}
+
+	if IsBuiltinNetwork(config.Name) {
+		return errors.NewConflictError("CreateNetwork", fmt.Sprintf("'%s' is a built-in network", config.Name))
+	}
+	if _, err := s.Get(config.Name); err == nil {
+		return errors.NewConflictError("CreateNetwork", fmt.Sprintf("network '%s' already exists", config.Name))
+	}
+
+	if config.Driver == "" {
+		config.Driver = string(BridgeMode)
+	}
+	if err := s.assignSubnet(config); err != nil {
+		return err
+	}
+	if NetworkMode(config.Driver) == BridgeMode {
+		config.Bridge = config.BridgeName()
+	}
+	if config.CreatedAt.IsZero() {
+		config.CreatedAt = time.Now()
+	}
+
+	logger.Debug("Creating network %s (driver: %s)", config.Name, config.Driver)
+	return s.Save(config)
+}
+
+// assignSubnet picks a free subnet for a bridge network created without one
+// and rejects a given subnet overlapping another servin network. A given
+// subnet overlapping a host route is only warned about: the user chose it.
+func (s *Store) assignSubnet(config *NetworkConfig) error {
+	if NetworkMode(config.Driver) != BridgeMode {
+		return nil
+	}
+
+	inUse, err := s.subnetsInUse(config.Name)
+	if err != nil {
+		return err
+	}
+
+	if config.Subnet == "" {
+		if config.Gateway != "" {
+			return errors.NewValidationError("CreateNetwork", "a gateway can only be given with a subnet")
+		}
+		subnet, err := PickSubnet("", inUse, HostRoutes())
+		if err != nil {
+			return err
+		}
+		config.Subnet = subnet
+		logger.Debug("Picked subnet %s for network %s", subnet, config.Name)
+	} else {
+		_, network, _ := net.ParseCIDR(config.Subnet)
+		for _, used := range inUse {
+			if _, other, err := net.ParseCIDR(used); err == nil && overlaps(network, other) {
+				return errors.NewConflictError("CreateNetwork", fmt.Sprintf("subnet %s overlaps %s, used by another servin network", config.Subnet, used))
+			}
+		}
+		for _, r := range SubnetConflicts(config.Subnet, HostRoutes()) {
+			logger.Warn("Subnet %s of network %s overlaps host route %s", config.Subnet, config.Name, r)
+		}
+	}
+
+	if config.Gateway == "" {
+		gateway, err := GatewayFor(config.Subnet)
+		if err != nil {
+			return errors.NewValidationError("CreateNetwork", err.Error())
+		}
+		config.Gateway = gateway
+	}
+	return s.assignSubnet6(config)
+}
+
+// assignSubnet6 picks a free unique local /64 for a network with IPv6
+// created without an IPv6 subnet, and rejects a given one overlapping
+// another servin network
+func (s *Store) assignSubnet6(config *NetworkConfig) error {
+	if !config.IPv6 {
+		return nil
+	}
+
+	networks, err := s.List()
+	if err != nil {
+		return err
+	}
+	var inUse []string
+	for _, n := range networks {
+		if n.Name != config.Name && n.Subnet6 != "" {
+			inUse = append(inUse, n.Subnet6)
+		}
+	}
+
+	if config.Subnet6 == "" {
+		subnet, err := PickSubnet6(inUse)
+		if err != nil {
+			return err
+		}
+		config.Subnet6 = subnet
+		logger.Debug("Picked IPv6 subnet %s for network %s", subnet, config.Name)
+	} else {
+		_, network, _ := net.ParseCIDR(config.Subnet6)
+		for _, used := range inUse {
+			if _, other, err := net.ParseCIDR(used); err == nil && overlaps(network, other) {
+				return errors.NewConflictError("CreateNetwork", fmt.Sprintf("IPv6 subnet %s overlaps %s, used by another servin network", config.Subnet6, used))
+			}
+		}
+	}
+
+	if config.Gateway6 == "" {
+		gateway, err := GatewayFor(config.Subnet6)
+		if err != nil {
+			return errors.NewValidationError("CreateNetwork", err.Error())
+		}
+		config.Gateway6 = gateway
+	}
+	return nil
+}
+
+// subnetsInUse returns the subnets of the default bridge and every network
+// other than the named one. The default bridge is assigned its subnet first
+// if it has none yet, so user networks never take the one it prefers.
+func (s *Store) subnetsInUse(except string) ([]string, error) {
+	var subnets []string
+	if except != DefaultBridge {
+		def, err := s.Default()
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, def.Subnet)
+	}
+
+	networks, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		if n.Name != except && n.Subnet != "" {
+			subnets = append(subnets, n.Subnet)
+		}
+	}
+	return subnets, nil
+}
+
+// Default returns the configuration of the default bridge. Its subnet is
+// picked on first use, preferring DefaultBridgeSubnet, and kept from then on
+// so containers keep their addresses when host routes change.
+func (s *Store) Default() (*NetworkConfig, error) {
+	config, err := s.loadDefault()
+	if err != nil || config != nil {
+		return config, err
+	}
+
+	inUse, err := s.subnetsInUse(DefaultBridge)
+	if err != nil {
+		return nil, err
+	}
+	subnet, err := PickSubnet(DefaultBridgeSubnet, inUse, HostRoutes())
+	if err != nil {
+		return nil, err
+	}
+	gateway, _ := GatewayFor(subnet)
+
+	config = &NetworkConfig{
+		Name:      DefaultBridge,
+		Driver:    string(BridgeMode),
+		Subnet:    subnet,
+		Gateway:   gateway,
+		CreatedAt: time.Now(),
+	}
+	if subnet != DefaultBridgeSubnet {
+		logger.Info("Default bridge uses %s because %s is in use on the host", subnet, DefaultBridgeSubnet)
+	}
+	return config, s.saveDefault(config)
+}
+
+func (s *Store) loadDefault() (*NetworkConfig, error) {
+	data, err := os.ReadFile(s.defaultPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeIO, "DefaultNetwork", "failed to read default network").
+			WithContext("path", s.defaultPath)
+	}
+
+	var config NetworkConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errors.WrapError(err, errors.ErrTypeIO, "DefaultNetwork", "failed to parse default network").
+			WithContext("path", s.defaultPath)
+	}
+	return &config, nil
+}
+
+func (s *Store) saveDefault(config *NetworkConfig) error {
+	if err := os.MkdirAll(s.networkDir, 0755); err != nil {
+		return fmt.Errorf("failed to create network directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal default network: %v", err)
+	}
+
+	if err := os.WriteFile(s.defaultPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write default network: %v", err)
+	}
+	return nil
+}
+
+// Reassign moves a bridge network, the default one included, to subnet, or
+// to a newly picked free subnet when subnet is empty. The new subnet avoids
+// the network's current one, since that is the one in conflict.
+func (s *Store) Reassign(name, subnet string) (*NetworkConfig, error) {
+	var config *NetworkConfig
+	var err error
+	if name == DefaultBridge || name == string(BridgeMode) {
+		config, err = s.Default()
+	} else {
+		config, err = s.Get(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if NetworkMode(config.Driver) != BridgeMode {
+		return nil, errors.NewValidationError("ReassignNetwork", fmt.Sprintf("network '%s' uses the %s driver and has no subnet", name, config.Driver))
+	}
+
+	inUse, err := s.subnetsInUse(config.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if subnet == "" {
+		subnet, err = PickSubnet("", append(inUse, config.Subnet), HostRoutes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated := *config
+	updated.Subnet = subnet
+	updated.Gateway = ""
+	if err := validateNetworkConfig(&updated); err != nil {
+		return nil, err
+	}
+	if err := s.assignSubnet(&updated); err != nil {
+		return nil, err
+	}
+
+	if updated.Name == DefaultBridge {
+		err = s.saveDefault(&updated)
+	} else {
+		err = s.Save(&updated)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("Reassigned network %s from %s to %s", updated.Name, config.Subnet, updated.Subnet)
+	return &updated, nil
+}
+
+// Save adds or replaces a network in the index
+func (s *Store) Save(config *NetworkConfig) error {
+	if err := validateNetworkConfig(config); err != nil {
+		return err
+	}
+
+	networks, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, n := range networks {
+		if n.Name == config.Name {
+			networks[i] = config
+			found = true
+			break
+		}
+	}
+	if !found {
+		networks = append(networks, config)
+	}
+
+	return s.write(networks)
+}
+
+// Remove deletes a user-defined network
+func (s *Store) Remove(name string) error {
+	if _, err := s.Get(name); err != nil {
+		return err
+	}
+
+	networks, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	remaining := []*NetworkConfig{}
+	for _, n := range networks {
+		if n.Name != name {
+			remaining = append(remaining, n)
+		}
+	}
+
+	return s.write(remaining)
+}
+
+// DNSFor returns the DNS configuration for containers on the named network:
+// the global defaults with the network's own settings applied on top
+func (s *Store) DNSFor(name string) (*DNSConfig, error) {
+	defaults := DefaultDNSConfig()
+	if name == "" || IsBuiltinNetwork(name) {
+		return defaults, nil
+	}
+
+	config, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return defaults.Merge(config.DNS), nil
+}
+
+func (s *Store) write(networks []*NetworkConfig) error {
+	if err := os.MkdirAll(s.networkDir, 0755); err != nil {
+		return fmt.Errorf("failed to create network directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(networks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal network index: %v", err)
+	}
+
+	if err := os.WriteFile(s.indexPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write network index: %v", err)
+	}
+
+	return nil
+}
+
+func validateNetworkConfig(config *NetworkConfig) error {
+	if config.Name == "" {
+		return errors.NewValidationError("CreateNetwork", "network name cannot be empty")
+	}
+	if strings.ContainsAny(config.Name, "/\\ \t") {
+		return errors.NewValidationError("CreateNetwork", "network name cannot contain spaces or path separators")
+	}
+
+	switch NetworkMode(config.Driver) {
+	case "", BridgeMode, HostMode, NoneMode:
+	default:
+		return errors.NewValidationError("CreateNetwork", fmt.Sprintf("unsupported network driver: %s", config.Driver))
+	}
+
+	var subnet *net.IPNet
+	if config.Subnet != "" {
+		var err error
+		if _, subnet, err = net.ParseCIDR(config.Subnet); err != nil {
+			return errors.NewValidationError("CreateNetwork", fmt.Sprintf("invalid subnet: %s", config.Subnet))
+		}
+	}
+	if config.Gateway != "" {
+		gateway := net.ParseIP(config.Gateway)
+		if gateway == nil {
+			return errors.NewValidationError("CreateNetwork", fmt.Sprintf("invalid gateway: %s", config.Gateway))
+		}
+		if subnet != nil && !subnet.Contains(gateway) {
+			return errors.NewValidationError("CreateNetwork", fmt.Sprintf("gateway %s is not in subnet %s", config.Gateway, config.Subnet))
+		}
+	}
+
+	if subnet != nil && subnet.IP.To4() == nil {
+		return errors.NewValidationError("CreateNetwork", fmt.Sprintf("subnet %s is not IPv4; give IPv6 subnets with --ipv6-subnet", config.Subnet))
+	}
+
+	if config.Subnet6 != "" || config.Gateway6 != "" {
+		if !config.IPv6 {
+			return errors.NewValidationError("CreateNetwork", "an IPv6 subnet or gateway needs IPv6 enabled")
+		}
+	}
+	if config.IPv6 && NetworkMode(config.Driver) != BridgeMode && config.Driver != "" {
+		return errors.NewValidationError("CreateNetwork", fmt.Sprintf("IPv6 needs the bridge driver, not %s", config.Driver))
+	}
+	var subnet6 *net.IPNet
+	if config.Subnet6 != "" {
+		var err error
+		if _, subnet6, err = net.ParseCIDR(config.Subnet6); err != nil || subnet6.IP.To4() != nil {
+			return errors.NewValidationError("CreateNetwork", fmt.Sprintf("invalid IPv6 subnet: %s", config.Subnet6))
+		}
+		if ones, _ := subnet6.Mask.Size(); ones > 120 {
+			return errors.NewValidationError("CreateNetwork", fmt.Sprintf("IPv6 subnet %s is too small; use a /120 or larger, such as a /64", config.Subnet6))
+		}
+	}
+	if config.Gateway6 != "" {
+		gateway := net.ParseIP(config.Gateway6)
+		if gateway == nil || gateway.To4() != nil {
+			return errors.NewValidationError("CreateNetwork", fmt.Sprintf("invalid IPv6 gateway: %s", config.Gateway6))
+		}
+		if subnet6 != nil && !subnet6.Contains(gateway) {
+			return errors.NewValidationError("CreateNetwork", fmt.Sprintf("gateway %s is not in subnet %s", config.Gateway6, config.Subnet6))
+		}
+	}
+
+	if config.DNS != nil {
+		if err := config.DNS.Validate(); err != nil {
+			return errors.NewValidationError("CreateNetwork", err.Error())
+		}
+	}
+
+	return nil
+}
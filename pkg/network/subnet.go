@@ -0,0 +1,225 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"servin/pkg/errors"
+)
+
+// DefaultBridgeSubnet is the subnet the default bridge prefers when nothing
+// on the host already uses it
+const DefaultBridgeSubnet = "172.17.0.0/16"
+
+// HostRoute is a network the host already reaches through one of its
+// interfaces
+type HostRoute struct {
+	Network   *net.IPNet `json:"network"`
+	Interface string     `json:"interface"`
+	// VPN is set when the interface is a tunnel, whose routes are usually
+	// pushed by a VPN client and may appear after networks were created
+	VPN bool `json:"vpn"`
+}
+
+func (r HostRoute) String() string {
+	s := fmt.Sprintf("%s on %s", r.Network, r.Interface)
+	if r.VPN {
+		s += " (VPN)"
+	}
+	return s
+}
+
+// vpnInterfacePrefixes are the interface names used by tunnel devices of
+// common VPN clients
+var vpnInterfacePrefixes = []string{
+	"tun", "tap", "utun", "wg", "ppp", "ipsec", "gpd", "cscotun", "tailscale", "zt", "nordlynx", "vpn",
+}
+
+func isVPNInterface(name string) bool {
+	name = strings.ToLower(name)
+	for _, prefix := range vpnInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isServinInterface reports whether servin itself created the interface, so
+// its routes are servin networks rather than conflicts
+func isServinInterface(name string) bool {
+	return strings.HasPrefix(name, "servin") || strings.HasPrefix(name, "veth")
+}
+
+// HostRoutes returns the IPv4 networks in use on the host: those of its
+// interface addresses and, where the platform exposes them, its routing
+// table. Default routes and servin's own interfaces are left out.
+func HostRoutes() []HostRoute {
+	var routes []HostRoute
+	add := func(network *net.IPNet, iface string) {
+		if network.IP.To4() == nil || network.IP.IsLoopback() || network.IP.IsLinkLocalUnicast() {
+			return
+		}
+		if ones, _ := network.Mask.Size(); ones == 0 {
+			return
+		}
+		if isServinInterface(iface) {
+			return
+		}
+		for _, r := range routes {
+			if r.Interface == iface && r.Network.String() == network.String() {
+				return
+			}
+		}
+		routes = append(routes, HostRoute{Network: network, Interface: iface, VPN: isVPNInterface(iface)})
+	}
+
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, iface := range ifaces {
+			addrs, err := iface.Addrs()
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				if ipnet, ok := addr.(*net.IPNet); ok {
+					_, network, _ := net.ParseCIDR(ipnet.String())
+					add(network, iface.Name)
+				}
+			}
+		}
+	}
+
+	for _, r := range platformRoutes() {
+		add(r.Network, r.Interface)
+	}
+
+	return routes
+}
+
+// overlaps reports whether two networks share any address
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// SubnetConflicts returns the host routes overlapping subnet
+func SubnetConflicts(subnet string, routes []HostRoute) []HostRoute {
+	_, network, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil
+	}
+	var conflicts []HostRoute
+	for _, r := range routes {
+		if overlaps(network, r.Network) {
+			conflicts = append(conflicts, r)
+		}
+	}
+	return conflicts
+}
+
+// subnetCandidates lists the private subnets servin picks from, in order of
+// preference: 172.17-31.0.0/16 as Docker does, then 192.168.0-240.0/20, then
+// 10.128-255.0.0/16, the half of 10/8 least likely to be used by offices
+func subnetCandidates() []*net.IPNet {
+	var candidates []*net.IPNet
+	add := func(a, b, c byte, ones int) {
+		candidates = append(candidates, &net.IPNet{
+			IP:   net.IPv4(a, b, c, 0).To4(),
+			Mask: net.CIDRMask(ones, 32),
+		})
+	}
+	for b := 17; b <= 31; b++ {
+		add(172, byte(b), 0, 16)
+	}
+	for b := 0; b <= 240; b += 16 {
+		add(192, 168, byte(b), 20)
+	}
+	for b := 128; b <= 255; b++ {
+		add(10, byte(b), 0, 16)
+	}
+	return candidates
+}
+
+// PickSubnet returns the first subnet, trying preferred and then the
+// candidates in order, that overlaps neither a host route nor any of the
+// subnets in use
+func PickSubnet(preferred string, inUse []string, routes []HostRoute) (string, error) {
+	var taken []*net.IPNet
+	for _, r := range routes {
+		taken = append(taken, r.Network)
+	}
+	for _, s := range inUse {
+		if _, network, err := net.ParseCIDR(s); err == nil {
+			taken = append(taken, network)
+		}
+	}
+
+	candidates := subnetCandidates()
+	if _, network, err := net.ParseCIDR(preferred); err == nil {
+		candidates = append([]*net.IPNet{network}, candidates...)
+	}
+
+next:
+	for _, candidate := range candidates {
+		for _, t := range taken {
+			if overlaps(candidate, t) {
+				continue next
+			}
+		}
+		return candidate.String(), nil
+	}
+
+	return "", errors.NewNetworkError("PickSubnet", "no free private subnet left; pass one with --subnet")
+}
+
+// subnet6Prefix is the unique local /48 servin picks the IPv6 subnets of
+// its networks from, one /64 each
+var subnet6Prefix = net.IP{0xfd, 0x5e, 0x72, 0x76, 0x69, 0x6e, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+// PickSubnet6 returns the first /64 of servin's unique local prefix that
+// overlaps neither the subnets in use nor an IPv6 network of the host
+func PickSubnet6(inUse []string) (string, error) {
+	var taken []*net.IPNet
+	for _, s := range inUse {
+		if _, network, err := net.ParseCIDR(s); err == nil {
+			taken = append(taken, network)
+		}
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() == nil && ipnet.IP.IsPrivate() {
+				taken = append(taken, &net.IPNet{IP: ipnet.IP.Mask(ipnet.Mask), Mask: ipnet.Mask})
+			}
+		}
+	}
+
+next:
+	for i := 0; i <= 0xffff; i++ {
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, subnet6Prefix)
+		binary.BigEndian.PutUint16(ip[6:8], uint16(i))
+		candidate := &net.IPNet{IP: ip, Mask: net.CIDRMask(64, 128)}
+		for _, t := range taken {
+			if overlaps(candidate, t) {
+				continue next
+			}
+		}
+		return candidate.String(), nil
+	}
+
+	return "", errors.NewNetworkError("PickSubnet6", "no free IPv6 subnet left; pass one with --ipv6-subnet")
+}
+
+// GatewayFor returns the first host address of subnet, the one servin gives
+// the bridge
+func GatewayFor(subnet string) (string, error) {
+	_, network, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return "", err
+	}
+	gateway := make(net.IP, len(network.IP))
+	copy(gateway, network.IP)
+	gateway[len(gateway)-1]++
+	return gateway.String(), nil
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"strings"
+)
+
+// platformRoutes reads the IPv4 routing table from /proc/net/route, where
+// destinations and masks are hex in host (little-endian) byte order
+func platformRoutes() []HostRoute {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var routes []HostRoute
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		dst, ok := parseProcAddr(fields[1])
+		if !ok {
+			continue
+		}
+		mask, ok := parseProcAddr(fields[7])
+		if !ok {
+			continue
+		}
+		routes = append(routes, HostRoute{
+			Network:   &net.IPNet{IP: dst.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)},
+			Interface: fields[0],
+		})
+	}
+	return routes
+}
+
+func parseProcAddr(s string) (net.IP, bool) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return nil, false
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+	return ip, true
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package network
+
+// platformRoutes is empty where the routing table is not readable without
+// parsing tool output; interface addresses still cover directly attached
+// networks and the tunnel addresses of most VPN clients
+func platformRoutes() []HostRoute {
+	return nil
+}
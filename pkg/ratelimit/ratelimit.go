@@ -0,0 +1,234 @@
+// Package ratelimit limits the bandwidth of registry transfers and VM asset
+// downloads, for hosts on metered or shared connections.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"servin/pkg/errors"
+)
+
+// burst is the most a limiter lets through at once, which also caps the
+// size of a single read or write
+const burst = 32 * 1024
+
+// ParseRate parses a rate such as "5MB/s", "500k" or "1.5M". Units are
+// powers of 1024 and the "/s" suffix is optional. Empty and "0" mean no
+// limit and return 0.
+func ParseRate(s string) (int64, error) {
+	invalid := errors.NewValidationError("ratelimit.ParseRate",
+		fmt.Sprintf("invalid rate '%s' (expected e.g. 500KB/s or 5MB/s)", s))
+
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(value, "/S")
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+
+	// KB, KiB and K are the same unit
+	value = strings.TrimSuffix(value, "B")
+	if n := len(value); n > 1 && value[n-1] == 'I' && strings.ContainsRune("KMG", rune(value[n-2])) {
+		value = value[:n-1]
+	}
+	if value == "" {
+		return 0, invalid
+	}
+
+	multiplier := int64(1)
+	switch value[len(value)-1] {
+	case 'K':
+		multiplier = 1 << 10
+	case 'M':
+		multiplier = 1 << 20
+	case 'G':
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, invalid
+	}
+	rate := int64(n * float64(multiplier))
+	if n > 0 && rate == 0 {
+		return 0, invalid
+	}
+	return rate, nil
+}
+
+// FormatRate formats a rate in bytes per second as e.g. "5.0MB/s"
+func FormatRate(rate int64) string {
+	switch {
+	case rate >= 1<<30:
+		return fmt.Sprintf("%.1fGB/s", float64(rate)/(1<<30))
+	case rate >= 1<<20:
+		return fmt.Sprintf("%.1fMB/s", float64(rate)/(1<<20))
+	case rate >= 1<<10:
+		return fmt.Sprintf("%.1fKB/s", float64(rate)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB/s", rate)
+	}
+}
+
+// Limiter is a token bucket shared by every transfer it limits. A nil
+// Limiter does not limit.
+type Limiter struct {
+	rate int64 // bytes per second
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter for rate bytes per second, or nil for a rate of 0
+func New(rate int64) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &Limiter{rate: rate, tokens: float64(burstFor(rate)), last: time.Now()}
+}
+
+// Rate returns the limit in bytes per second, or 0 for a nil limiter
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.rate
+}
+
+// burstFor keeps the burst below a second's worth of data at low rates, so
+// transfers do not arrive in visible bursts
+func burstFor(rate int64) int {
+	if rate < burst {
+		return int(rate)
+	}
+	return burst
+}
+
+// Wait blocks until n bytes may be transferred or ctx is done
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if max := float64(burstFor(l.rate)); l.tokens > max {
+		l.tokens = max
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / float64(l.rate) * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunk returns the most a limited reader reads at once
+func (l *Limiter) chunk() int {
+	if l == nil {
+		return 0
+	}
+	return burstFor(l.rate)
+}
+
+// reader limits the rate data is read from a stream
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+// Reader returns r limited to the limiter's rate. With a nil limiter it
+// returns r itself.
+func Reader(ctx context.Context, r io.Reader, l *Limiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiter: l}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.chunk() {
+		p = p[:r.limiter.chunk()]
+	}
+	n, err := r.r.Read(p)
+	if waitErr := r.limiter.Wait(r.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+// readCloser is a limited reader that closes the stream it reads
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Transport returns an HTTP transport that limits request bodies to up and
+// response bodies to down. Either limiter may be nil; with both nil it
+// returns base itself.
+func Transport(base http.RoundTripper, down, up *Limiter) http.RoundTripper {
+	if down == nil && up == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, down: down, up: up}
+}
+
+type transport struct {
+	base     http.RoundTripper
+	down, up *Limiter
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.up != nil && req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = readCloser{Reader(req.Context(), req.Body, t.up), req.Body}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || t.down == nil {
+		return resp, err
+	}
+	resp.Body = readCloser{Reader(req.Context(), resp.Body, t.down), resp.Body}
+	return resp, nil
+}
+
+// Client returns an HTTP client whose transfers are limited to down and up.
+// A limited transfer can outlast timeout, so for those only the wait for
+// the response headers is timed. With no limits it is a plain client with
+// the timeout.
+func Client(timeout time.Duration, down, up *Limiter) *http.Client {
+	if down == nil && up == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: Transport(base, down, up)}
+}
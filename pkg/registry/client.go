@@ -11,7 +11,9 @@ import (
 	"strings"
 	"time"
 
+	"servin/pkg/credentials"
 	"servin/pkg/logger"
+	"servin/pkg/ratelimit"
 )
 
 // Client handles communication with registries
@@ -36,6 +38,7 @@ func NewClient(dataDir string) (*Client, error) {
 			logger.Warn("Failed to save default registry config: %v", err)
 		}
 	}
+	migrateCredentials(dataDir, config)
 
 	return &Client{
 		config:     config,
@@ -132,32 +135,78 @@ func (c *Client) ListRegistryImages(registryURL string) (map[string][]string, er
 	return c.listFromRemote(targetRegistry)
 }
 
-// LoginToRegistry authenticates with a registry
+// LoginToRegistry stores credentials for a registry in the credential store.
+// The email is no longer used by registries and is ignored.
 func (c *Client) LoginToRegistry(registryURL, username, password, email string) error {
-	if c.config.Credentials == nil {
-		c.config.Credentials = make(map[string]Auth)
-	}
-
-	c.config.Credentials[registryURL] = Auth{
+	return credentials.Save(registryURL, &credentials.Credential{
 		Username: username,
 		Password: password,
-		Email:    email,
-	}
+	})
+}
+
+// LogoutFromRegistry removes the stored credentials for a registry
+func (c *Client) LogoutFromRegistry(registryURL string) error {
+	return credentials.Erase(registryURL)
+}
+
+// DefaultRegistry returns the registry push and pull use without one, or
+// "" for the local registry
+func (c *Client) DefaultRegistry() string {
+	return c.config.DefaultRegistry
+}
 
-	// Save updated config
+// SetDefaultRegistry changes the registry push and pull use without one; ""
+// goes back to the local registry
+func (c *Client) SetDefaultRegistry(registryURL string) error {
+	c.config.DefaultRegistry = registryURL
 	return saveConfig(c.dataDir, c.config)
 }
 
-// LogoutFromRegistry removes authentication for a registry
-func (c *Client) LogoutFromRegistry(registryURL string) error {
-	if c.config.Credentials != nil {
-		delete(c.config.Credentials, registryURL)
+// AddRegistry adds a remote registry under a name, or changes the registry
+// the name stands for
+func (c *Client) AddRegistry(name, registryURL string) error {
+	if c.config.Registries == nil {
+		c.config.Registries = make(map[string]string)
 	}
+	c.config.Registries[name] = registryURL
+	return saveConfig(c.dataDir, c.config)
+}
 
-	// Save updated config
+// RemoveRegistry removes a remote registry added by name. Its credentials
+// are kept; logout removes them.
+func (c *Client) RemoveRegistry(name string) error {
+	if _, ok := c.config.Registries[name]; !ok {
+		return os.ErrNotExist
+	}
+	delete(c.config.Registries, name)
 	return saveConfig(c.dataDir, c.config)
 }
 
+// migrateCredentials moves credentials saved in the registry config by
+// earlier versions into the credential store, so they are used by pull and
+// push and no longer kept in a world-readable file
+func migrateCredentials(dataDir string, config *RegistryConfig) {
+	if len(config.Credentials) == 0 {
+		return
+	}
+
+	for registryURL, auth := range config.Credentials {
+		if _, err := credentials.Get(registryURL); err == nil {
+			continue
+		}
+		cred := &credentials.Credential{Username: auth.Username, Password: auth.Password}
+		if err := credentials.Save(registryURL, cred); err != nil {
+			logger.Warn("Failed to migrate credentials for %s: %v", registryURL, err)
+			return
+		}
+	}
+
+	config.Credentials = make(map[string]Auth)
+	if err := saveConfig(dataDir, config); err != nil {
+		logger.Warn("Failed to save registry config: %v", err)
+	}
+}
+
 // GetRegistryInfo returns information about configured registries
 func (c *Client) GetRegistryInfo() ([]*RegistryInfo, error) {
 	var registries []*RegistryInfo
@@ -189,12 +238,16 @@ func (c *Client) GetRegistryInfo() ([]*RegistryInfo, error) {
 			Status: "unknown",
 		}
 
-		if c.isRegistryHealthy(url) {
+		if c.isRemoteReachable(url) {
 			info.Status = "healthy"
 		} else {
 			info.Status = "unreachable"
 		}
 		info.LastCheck = time.Now()
+		if cred, err := credentials.Get(url); err == nil {
+			info.Username = cred.Username
+			info.LoggedIn = true
+		}
 
 		registries = append(registries, info)
 	}
@@ -213,7 +266,11 @@ func (c *Client) pushToLocal(imageName, tag, registryURL string, imageData []byt
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
 
-	resp, err := c.httpClient.Do(req)
+	httpClient := c.httpClient
+	if options.LimitRate > 0 {
+		httpClient = ratelimit.Client(c.httpClient.Timeout, nil, ratelimit.New(options.LimitRate))
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to push to local registry: %w", err)
 	}
@@ -233,7 +290,11 @@ func (c *Client) pushToLocal(imageName, tag, registryURL string, imageData []byt
 func (c *Client) pullFromLocal(imageName, tag, registryURL string, options *PullOptions) ([]byte, error) {
 	url := fmt.Sprintf("http://%s/v2/%s/manifests/%s", registryURL, imageName, tag)
 
-	resp, err := c.httpClient.Get(url)
+	httpClient := c.httpClient
+	if options.LimitRate > 0 {
+		httpClient = ratelimit.Client(c.httpClient.Timeout, ratelimit.New(options.LimitRate), nil)
+	}
+	resp, err := httpClient.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to pull from local registry: %w", err)
 	}
@@ -311,6 +372,28 @@ func (c *Client) isRegistryHealthy(registryURL string) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
+// isRemoteReachable reports whether a remote registry answers the
+// Distribution API's version check. A registry that asks for credentials
+// answers it with 401.
+func (c *Client) isRemoteReachable(registryURL string) bool {
+	host := credentials.Normalize(registryURL)
+	base := "https://" + host
+	switch {
+	case host == credentials.DockerHub:
+		base = "https://registry-1.docker.io"
+	case strings.HasPrefix(host, "localhost"), strings.HasPrefix(host, "127.0.0.1"):
+		base = "http://" + host
+	}
+
+	resp, err := c.httpClient.Get(base + "/v2/")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnauthorized
+}
+
 // Configuration management
 
 func loadConfig(storageRoot string) (*RegistryConfig, error) {
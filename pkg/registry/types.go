@@ -31,7 +31,9 @@ type RegistryConfig struct {
 	// Remote registry settings
 	DefaultRegistry string            `json:"default_registry"`
 	Registries      map[string]string `json:"registries"`
-	Credentials     map[string]Auth   `json:"credentials"`
+	// Credentials is only read to migrate credentials saved by earlier
+	// versions into the credential store
+	Credentials map[string]Auth `json:"credentials,omitempty"`
 
 	// TLS settings
 	InsecureRegistries []string `json:"insecure_registries"`
@@ -73,6 +75,8 @@ type PushOptions struct {
 	Registry string
 	Force    bool
 	Quiet    bool
+	// LimitRate limits the upload rate in bytes per second (0 for no limit)
+	LimitRate int64
 }
 
 // PullOptions contains options for pulling images
@@ -80,6 +84,8 @@ type PullOptions struct {
 	Registry string
 	Quiet    bool
 	Platform string
+	// LimitRate limits the download rate in bytes per second (0 for no limit)
+	LimitRate int64
 }
 
 // RegistryInfo contains information about a registry
@@ -89,4 +95,8 @@ type RegistryInfo struct {
 	Type      string    `json:"type"` // "local" or "remote"
 	Status    string    `json:"status"`
 	LastCheck time.Time `json:"last_check"`
+	// LoggedIn is set when credentials are stored for the registry, under
+	// Username unless the registry issued a token
+	LoggedIn bool   `json:"logged_in"`
+	Username string `json:"username,omitempty"`
 }
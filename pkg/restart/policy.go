@@ -0,0 +1,110 @@
+package restart
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/errors"
+	"servin/pkg/state"
+)
+
+// Restart policy names
+const (
+	PolicyNo            = "no"
+	PolicyAlways        = "always"
+	PolicyOnFailure     = "on-failure"
+	PolicyUnlessStopped = "unless-stopped"
+)
+
+const (
+	// minBackoff is the delay before the first restart attempt
+	minBackoff = 100 * time.Millisecond
+	// maxBackoff caps the exponential backoff between restarts
+	maxBackoff = time.Minute
+)
+
+// Policy describes when a container should be restarted after it exits
+type Policy struct {
+	Name       string
+	MaxRetries int // only used by on-failure, 0 means unlimited
+}
+
+// ParsePolicy parses a restart policy in the form used by --restart
+// ("no", "always", "unless-stopped", "on-failure" or "on-failure:N")
+func ParsePolicy(spec string) (Policy, error) {
+	if spec == "" {
+		return Policy{Name: PolicyNo}, nil
+	}
+
+	name, retries, hasRetries := strings.Cut(spec, ":")
+	switch name {
+	case PolicyNo, PolicyAlways, PolicyUnlessStopped:
+		if hasRetries {
+			return Policy{}, errors.NewValidationError("ParsePolicy",
+				fmt.Sprintf("maximum retry count cannot be used with restart policy '%s'", name))
+		}
+		return Policy{Name: name}, nil
+	case PolicyOnFailure:
+		policy := Policy{Name: name}
+		if hasRetries {
+			n, err := strconv.Atoi(retries)
+			if err != nil || n < 0 {
+				return Policy{}, errors.NewValidationError("ParsePolicy",
+					fmt.Sprintf("invalid maximum retry count '%s'", retries))
+			}
+			policy.MaxRetries = n
+		}
+		return policy, nil
+	default:
+		return Policy{}, errors.NewValidationError("ParsePolicy",
+			fmt.Sprintf("invalid restart policy '%s' (expected no, always, on-failure[:N] or unless-stopped)", spec))
+	}
+}
+
+// String returns the policy in --restart syntax
+func (p Policy) String() string {
+	if p.Name == PolicyOnFailure && p.MaxRetries > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaxRetries)
+	}
+	return p.Name
+}
+
+// ShouldRestart reports whether a container in the given state should be restarted.
+// Containers stopped by the user are only restarted by "always", and only when
+// the daemon is starting up.
+func (p Policy) ShouldRestart(cs *state.ContainerState, daemonStartup bool) bool {
+	switch cs.Status {
+	case state.StatusExited:
+	case state.StatusStopped:
+		return daemonStartup && p.Name == PolicyAlways
+	default:
+		return false
+	}
+
+	switch p.Name {
+	case PolicyAlways, PolicyUnlessStopped:
+		return true
+	case PolicyOnFailure:
+		if cs.ExitCode == 0 {
+			return false
+		}
+		return p.MaxRetries == 0 || cs.RestartCount < p.MaxRetries
+	default:
+		return false
+	}
+}
+
+// Backoff returns the delay before restart attempt n (starting at 0),
+// doubling each time up to maxBackoff
+func Backoff(attempt int) time.Duration {
+	delay := minBackoff
+	for i := 0; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
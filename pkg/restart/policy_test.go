@@ -0,0 +1,85 @@
+package restart
+
+import (
+	"testing"
+	"time"
+
+	"servin/pkg/state"
+)
+
+// TestParsePolicy tests parsing of --restart values
+func TestParsePolicy(t *testing.T) {
+	valid := map[string]Policy{
+		"":               {Name: PolicyNo},
+		"no":             {Name: PolicyNo},
+		"always":         {Name: PolicyAlways},
+		"unless-stopped": {Name: PolicyUnlessStopped},
+		"on-failure":     {Name: PolicyOnFailure},
+		"on-failure:5":   {Name: PolicyOnFailure, MaxRetries: 5},
+	}
+	for spec, want := range valid {
+		got, err := ParsePolicy(spec)
+		if err != nil {
+			t.Errorf("ParsePolicy(%q) returned error: %v", spec, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParsePolicy(%q) = %+v, want %+v", spec, got, want)
+		}
+	}
+
+	for _, spec := range []string{"sometimes", "always:3", "on-failure:x", "on-failure:-1"} {
+		if _, err := ParsePolicy(spec); err == nil {
+			t.Errorf("ParsePolicy(%q) expected an error", spec)
+		}
+	}
+}
+
+// TestShouldRestart tests the restart decision for each policy
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy  string
+		status  string
+		code    int
+		count   int
+		startup bool
+		want    bool
+	}{
+		{"no", state.StatusExited, 1, 0, false, false},
+		{"always", state.StatusExited, 0, 0, false, true},
+		{"always", state.StatusRunning, 0, 0, false, false},
+		{"always", state.StatusStopped, 0, 0, false, false},
+		{"always", state.StatusStopped, 0, 0, true, true},
+		{"unless-stopped", state.StatusStopped, 0, 0, true, false},
+		{"unless-stopped", state.StatusExited, 0, 0, false, true},
+		{"on-failure", state.StatusExited, 0, 0, false, false},
+		{"on-failure", state.StatusExited, 2, 7, false, true},
+		{"on-failure:3", state.StatusExited, 2, 2, false, true},
+		{"on-failure:3", state.StatusExited, 2, 3, false, false},
+	}
+
+	for _, tt := range tests {
+		policy, err := ParsePolicy(tt.policy)
+		if err != nil {
+			t.Fatalf("ParsePolicy(%q) returned error: %v", tt.policy, err)
+		}
+		cs := &state.ContainerState{Status: tt.status, ExitCode: tt.code, RestartCount: tt.count}
+		if got := policy.ShouldRestart(cs, tt.startup); got != tt.want {
+			t.Errorf("%s with status=%s code=%d count=%d startup=%v: got %v, want %v",
+				tt.policy, tt.status, tt.code, tt.count, tt.startup, got, tt.want)
+		}
+	}
+}
+
+// TestBackoff tests that restart delays double and are capped
+func TestBackoff(t *testing.T) {
+	if got := Backoff(0); got != 100*time.Millisecond {
+		t.Errorf("Backoff(0) = %v, want 100ms", got)
+	}
+	if got := Backoff(3); got != 800*time.Millisecond {
+		t.Errorf("Backoff(3) = %v, want 800ms", got)
+	}
+	if got := Backoff(50); got != time.Minute {
+		t.Errorf("Backoff(50) = %v, want 1m", got)
+	}
+}
@@ -0,0 +1,153 @@
+package restart
+
+import (
+	"sync"
+	"time"
+
+	"servin/pkg/container"
+	"servin/pkg/logger"
+	"servin/pkg/state"
+)
+
+// stableRuntime is how long a container must stay up before its backoff is reset
+const stableRuntime = 10 * time.Second
+
+// tracked holds the supervisor's bookkeeping for a single container
+type tracked struct {
+	attempt    int       // consecutive restarts used for backoff
+	lastStart  time.Time // when the supervisor last started the container
+	nextStart  time.Time // earliest time the next restart may happen
+	restarting bool
+}
+
+// Supervisor watches container state and restarts exited containers
+// according to their restart policy
+type Supervisor struct {
+	stateManager *state.StateManager
+	interval     time.Duration
+
+	mu         sync.Mutex
+	containers map[string]*tracked
+}
+
+// NewSupervisor creates a supervisor that polls container state every interval
+func NewSupervisor(sm *state.StateManager, interval time.Duration) *Supervisor {
+	return &Supervisor{
+		stateManager: sm,
+		interval:     interval,
+		containers:   make(map[string]*tracked),
+	}
+}
+
+// Run supervises containers until stop is closed
+func (s *Supervisor) Run(stop <-chan struct{}) {
+	logger.Info("Restart supervisor started (interval: %v)", s.interval)
+	s.scan(true)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			logger.Info("Restart supervisor stopped")
+			return
+		case <-ticker.C:
+			s.scan(false)
+		}
+	}
+}
+
+// scan checks every container once and schedules the restarts that are due
+func (s *Supervisor) scan(daemonStartup bool) {
+	containers, err := s.stateManager.ListContainers()
+	if err != nil {
+		logger.Error("Restart supervisor failed to list containers: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	now := time.Now()
+
+	for _, cs := range containers {
+		seen[cs.ID] = true
+
+		policy, err := ParsePolicy(cs.RestartPolicy)
+		if err != nil {
+			logger.Warn("Container %s has an invalid restart policy: %v", cs.ID[:12], err)
+			continue
+		}
+		if policy.Name == PolicyNo {
+			continue
+		}
+
+		t, ok := s.containers[cs.ID]
+		if !ok {
+			t = &tracked{}
+			s.containers[cs.ID] = t
+		}
+		if t.restarting || !policy.ShouldRestart(cs, daemonStartup) {
+			continue
+		}
+
+		// A container that stayed up long enough starts over with the shortest delay
+		if !t.lastStart.IsZero() && cs.Finished.Sub(t.lastStart) >= stableRuntime {
+			t.attempt = 0
+		}
+
+		if t.nextStart.IsZero() {
+			// Containers found down when the daemon starts are brought back right away
+			delay := Backoff(t.attempt)
+			if daemonStartup {
+				delay = 0
+			}
+			t.nextStart = now.Add(delay)
+			logger.Info("Container %s is %s (exit code %d), restarting in %v (policy: %s)",
+				cs.ID[:12], cs.Status, cs.ExitCode, delay, policy)
+		}
+		if now.Before(t.nextStart) {
+			continue
+		}
+
+		t.restarting = true
+		t.attempt++
+		t.lastStart = now
+		t.nextStart = time.Time{}
+		go s.restart(cs)
+	}
+
+	// Forget containers that have been removed
+	for id := range s.containers {
+		if !seen[id] {
+			delete(s.containers, id)
+		}
+	}
+}
+
+// restart starts a container again from its saved state
+func (s *Supervisor) restart(cs *state.ContainerState) {
+	defer func() {
+		s.mu.Lock()
+		if t, ok := s.containers[cs.ID]; ok {
+			t.restarting = false
+		}
+		s.mu.Unlock()
+	}()
+
+	cs.RestartCount++
+	cs.Status = state.StatusCreated
+	if err := s.stateManager.SaveContainer(cs); err != nil {
+		logger.Error("Failed to update restart count for container %s: %v", cs.ID[:12], err)
+		return
+	}
+
+	// The container runs under a shim, so it survives daemon restarts
+	logger.Info("Restarting container %s (restart #%d)", cs.ID[:12], cs.RestartCount)
+	if err := container.FromState(cs).RunDetached(); err != nil {
+		logger.Warn("Failed to restart container %s: %v", cs.ID[:12], err)
+		s.stateManager.UpdateContainerExit(cs.ID, 1)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package rootfs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"servin/pkg/image"
+
+	"golang.org/x/sys/unix"
+)
+
+// overlaySupported reports whether the kernel provides overlayfs
+func overlaySupported() bool {
+	data, err := os.ReadFile("/proc/filesystems")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(strings.TrimPrefix(line, "nodev")) == "overlay" {
+			return true
+		}
+	}
+	return false
+}
+
+// mountOverlay mounts the image layers read-only at RootPath with a
+// per-container writable upper layer, so starting a container does not copy
+// the image and the container only uses disk space for the files it changes
+func (r *RootFS) mountOverlay(img *image.Image) error {
+	if !overlaySupported() {
+		return fmt.Errorf("overlayfs is not supported by the kernel")
+	}
+
+	lower, err := r.ImageManager.OverlayLowerDirs(img)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{r.UpperPath(), r.workPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create overlay directory: %v", err)
+		}
+	}
+
+	options := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s",
+		strings.Join(lower, ":"), r.UpperPath(), r.workPath())
+	if len(options) >= os.Getpagesize() {
+		r.removeOverlayDirs()
+		return fmt.Errorf("image has too many layers for an overlay mount (%d)", len(lower))
+	}
+
+	if err := unix.Mount("overlay", r.RootPath, "overlay", 0, options); err != nil {
+		r.removeOverlayDirs()
+		return fmt.Errorf("failed to mount overlay: %v", err)
+	}
+	return nil
+}
+
+// unmountOverlay detaches the overlay mount, and any mounts below it, from
+// RootPath. It is a no-op when RootPath is not a mount point.
+func (r *RootFS) unmountOverlay() {
+	if _, err := os.Stat(r.UpperPath()); err != nil {
+		return
+	}
+	if err := unix.Unmount(r.RootPath, unix.MNT_DETACH); err != nil && err != unix.EINVAL {
+		fmt.Printf("Warning: failed to unmount %s: %v\n", r.RootPath, err)
+	}
+}
+
+func (r *RootFS) removeOverlayDirs() {
+	os.RemoveAll(r.UpperPath())
+	os.RemoveAll(r.workPath())
+}
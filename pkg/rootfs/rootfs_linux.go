@@ -18,6 +18,9 @@ type RootFS struct {
 	RootPath     string
 	ImagePath    string
 	ImageManager *image.Manager
+
+	// Snapshotter is the snapshotter that provided the root filesystem
+	Snapshotter string
 }
 
 // New creates a new RootFS manager with image support
@@ -60,11 +63,23 @@ func (r *RootFS) createFromImage() error {
 		return fmt.Errorf("image not found: %v", err)
 	}
 
-	// Copy image rootfs to container rootfs
-	if err := r.copyDirectory(img.RootFSPath, r.RootPath); err != nil {
+	// Prefer a copy-on-write overlay of the image layers
+	if preferredSnapshotter() == SnapshotterOverlay {
+		err := r.mountOverlay(img)
+		if err == nil {
+			r.Snapshotter = SnapshotterOverlay
+			fmt.Printf("Mounted overlay rootfs from image %s at %s\n", r.ImagePath, r.RootPath)
+			return nil
+		}
+		fmt.Printf("Warning: overlay rootfs unavailable, copying image layers instead: %v\n", err)
+	}
+
+	// Apply the image layers to the container rootfs, base layer first
+	if err := r.ImageManager.ExtractRootFS(img, r.RootPath); err != nil {
 		return fmt.Errorf("failed to copy image rootfs: %v", err)
 	}
 
+	r.Snapshotter = SnapshotterVFS
 	fmt.Printf("Created rootfs from image %s at %s\n", r.ImagePath, r.RootPath)
 	return nil
 }
@@ -154,31 +169,10 @@ func (r *RootFS) SetupMounts() error {
 
 // Cleanup removes the container's filesystem
 func (r *RootFS) Cleanup() error {
+	r.unmountOverlay()
 	return os.RemoveAll(filepath.Dir(r.RootPath))
 }
 
-// copyDirectory recursively copies a directory
-func (r *RootFS) copyDirectory(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Calculate destination path
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-		destPath := filepath.Join(dst, relPath)
-
-		if info.IsDir() {
-			return os.MkdirAll(destPath, info.Mode())
-		}
-
-		return r.copyFile(path, destPath)
-	})
-}
-
 // copyEssentialFiles copies essential files from host to container
 func (r *RootFS) copyEssentialFiles() error {
 	// Copy essential binaries
@@ -17,6 +17,10 @@ type RootFS struct {
 	RootPath     string
 	ImagePath    string
 	ImageManager *image.Manager
+
+	// Snapshotter is the snapshotter that provided the root filesystem.
+	// Overlay mounts need Linux, so other platforms always copy.
+	Snapshotter string
 }
 
 // New creates a new RootFS manager (cross-platform)
@@ -87,11 +91,12 @@ func (r *RootFS) createFromImage() error {
 		return fmt.Errorf("image not found: %v", err)
 	}
 
-	// Copy image rootfs to container rootfs
-	if err := r.copyDirectory(img.RootFSPath, r.RootPath); err != nil {
+	// Apply the image layers to the container rootfs, base layer first
+	if err := r.ImageManager.ExtractRootFS(img, r.RootPath); err != nil {
 		return fmt.Errorf("failed to copy image rootfs: %v", err)
 	}
 
+	r.Snapshotter = SnapshotterVFS
 	fmt.Printf("Created rootfs from image %s at %s\n", r.ImagePath, r.RootPath)
 	return nil
 }
@@ -157,28 +162,6 @@ func (r *RootFS) Cleanup() error {
 	return nil
 }
 
-// copyDirectory recursively copies a directory (cross-platform)
-func (r *RootFS) copyDirectory(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Calculate destination path
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-		destPath := filepath.Join(dst, relPath)
-
-		if info.IsDir() {
-			return os.MkdirAll(destPath, info.Mode())
-		}
-
-		return r.copyFile(path, destPath)
-	})
-}
-
 // copyEssentialFiles copies essential files from host to container (cross-platform)
 func (r *RootFS) copyEssentialFiles() error {
 	// Create a minimal /etc/passwd
@@ -0,0 +1,40 @@
+package rootfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshotters that can provide a container's root filesystem
+const (
+	// SnapshotterOverlay mounts the image layers read-only under a thin
+	// writable layer (Linux only)
+	SnapshotterOverlay = "overlayfs"
+	// SnapshotterVFS copies the image layers into the container directory
+	SnapshotterVFS = "vfs"
+)
+
+// SnapshotterEnvVar forces a snapshotter, e.g. SERVIN_SNAPSHOTTER=vfs to
+// disable overlay mounts
+const SnapshotterEnvVar = "SERVIN_SNAPSHOTTER"
+
+// preferredSnapshotter returns the snapshotter requested through
+// SERVIN_SNAPSHOTTER, defaulting to overlayfs
+func preferredSnapshotter() string {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv(SnapshotterEnvVar)), SnapshotterVFS) {
+		return SnapshotterVFS
+	}
+	return SnapshotterOverlay
+}
+
+// UpperPath returns the directory holding the container's writable layer
+// when its root filesystem is an overlay mount
+func (r *RootFS) UpperPath() string {
+	return filepath.Join(filepath.Dir(r.RootPath), "upper")
+}
+
+// workPath returns overlayfs' scratch directory for the container
+func (r *RootFS) workPath() string {
+	return filepath.Join(filepath.Dir(r.RootPath), "work")
+}
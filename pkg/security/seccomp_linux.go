@@ -0,0 +1,98 @@
+//go:build linux
+
+package security
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// deniedSyscalls are refused with EPERM by the default profile on every
+// architecture: kernel module and keyring management, rebooting, swap,
+// clock changes, mounting and namespace changes, and interfaces commonly
+// used in kernel exploits
+var deniedSyscalls = []uint32{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_BPF,
+	unix.SYS_CLOCK_ADJTIME,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_FSCONFIG,
+	unix.SYS_FSMOUNT,
+	unix.SYS_FSOPEN,
+	unix.SYS_FSPICK,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_LOOKUP_DCOOKIE,
+	unix.SYS_MOUNT,
+	unix.SYS_MOUNT_SETATTR,
+	unix.SYS_MOVE_MOUNT,
+	unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_NFSSERVCTL,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_OPEN_TREE,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_QUOTACTL,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_VHANGUP,
+}
+
+// Offsets of the fields of struct seccomp_data the filter reads
+const (
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+// defaultFilter builds the BPF program of the default profile. Syscalls
+// made with another architecture's calling convention kill the process,
+// since their numbers would bypass the checks.
+func defaultFilter() []unix.SockFilter {
+	deny := bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM))
+
+	filter := []unix.SockFilter{
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArch),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, auditArch, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_KILL_PROCESS),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNr),
+	}
+	filter = append(filter, archChecks()...)
+
+	for _, nr := range append(deniedSyscalls, archDeniedSyscalls...) {
+		filter = append(filter,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, 0, 1),
+			deny)
+	}
+
+	return append(filter, bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW))
+}
+
+// loadSeccompFilter installs the filter on the calling thread
+func loadSeccompFilter(filter []unix.SockFilter) error {
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	return unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0)
+}
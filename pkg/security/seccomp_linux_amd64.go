@@ -0,0 +1,29 @@
+package security
+
+import "golang.org/x/sys/unix"
+
+const auditArch = unix.AUDIT_ARCH_X86_64
+
+// archDeniedSyscalls are x86-64 only: port I/O and obsolete interfaces
+var archDeniedSyscalls = []uint32{
+	unix.SYS_CREATE_MODULE,
+	unix.SYS_GET_KERNEL_SYMS,
+	unix.SYS_IOPERM,
+	unix.SYS_IOPL,
+	unix.SYS_QUERY_MODULE,
+	unix.SYS_SYSFS,
+	unix.SYS__SYSCTL,
+	unix.SYS_USELIB,
+	unix.SYS_USTAT,
+}
+
+// x32Bit marks syscalls made with the x32 ABI, which share the arch value
+const x32Bit = 0x40000000
+
+// archChecks denies every x32 syscall; the syscall number is loaded
+func archChecks() []unix.SockFilter {
+	return []unix.SockFilter{
+		bpfJump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32Bit, 0, 1),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM)),
+	}
+}
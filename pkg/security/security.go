@@ -0,0 +1,193 @@
+// Package security applies the process-level restrictions a container starts
+// with: resource limits (ulimits), no_new_privs and a seccomp profile. They
+// are passed from servin to the container's init process in environment
+// variables and applied just before the container command starts.
+package security
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"servin/pkg/errors"
+)
+
+// Seccomp profiles
+const (
+	// SeccompUnconfined runs the container without a seccomp filter
+	SeccompUnconfined = "unconfined"
+	// SeccompDefault denies syscalls containers have no business making,
+	// such as loading kernel modules, rebooting or mounting filesystems
+	SeccompDefault = "default"
+)
+
+// Environment variables the options are passed to the init process in
+const (
+	envSeccomp         = "SERVIN_SECCOMP"
+	envNoNewPrivileges = "SERVIN_NO_NEW_PRIVS"
+	envUlimits         = "SERVIN_ULIMITS"
+)
+
+// Unlimited is the value of an unlimited resource limit
+const Unlimited = math.MaxUint64
+
+// ulimitNames maps the names used by --ulimit to the RLIMIT_* resources
+var ulimitNames = map[string]int{
+	"core":       4,  // RLIMIT_CORE
+	"cpu":        0,  // RLIMIT_CPU
+	"data":       2,  // RLIMIT_DATA
+	"fsize":      1,  // RLIMIT_FSIZE
+	"locks":      10, // RLIMIT_LOCKS
+	"memlock":    8,  // RLIMIT_MEMLOCK
+	"msgqueue":   12, // RLIMIT_MSGQUEUE
+	"nice":       13, // RLIMIT_NICE
+	"nofile":     7,  // RLIMIT_NOFILE
+	"nproc":      6,  // RLIMIT_NPROC
+	"rss":        5,  // RLIMIT_RSS
+	"rtprio":     14, // RLIMIT_RTPRIO
+	"rttime":     15, // RLIMIT_RTTIME
+	"sigpending": 11, // RLIMIT_SIGPENDING
+	"stack":      3,  // RLIMIT_STACK
+}
+
+// Ulimit is a resource limit set on the container process
+type Ulimit struct {
+	Name string
+	Soft uint64
+	Hard uint64
+}
+
+// ParseUlimit parses a limit in the form used by --ulimit
+// ("nofile=1024:4096"); without a hard limit the soft limit is used for both
+func ParseUlimit(spec string) (Ulimit, error) {
+	name, limits, ok := strings.Cut(spec, "=")
+	if !ok || name == "" || limits == "" {
+		return Ulimit{}, errors.NewValidationError("ParseUlimit",
+			fmt.Sprintf("invalid ulimit '%s', expected NAME=SOFT[:HARD]", spec))
+	}
+	if _, ok := ulimitNames[name]; !ok {
+		return Ulimit{}, errors.NewValidationError("ParseUlimit",
+			fmt.Sprintf("unknown ulimit '%s' (valid: %s)", name, strings.Join(UlimitNames(), ", ")))
+	}
+
+	soft, hard, hasHard := strings.Cut(limits, ":")
+	u := Ulimit{Name: name}
+	var err error
+	if u.Soft, err = parseLimit(soft); err != nil {
+		return Ulimit{}, errors.NewValidationError("ParseUlimit", fmt.Sprintf("ulimit %s: %v", name, err))
+	}
+	u.Hard = u.Soft
+	if hasHard {
+		if u.Hard, err = parseLimit(hard); err != nil {
+			return Ulimit{}, errors.NewValidationError("ParseUlimit", fmt.Sprintf("ulimit %s: %v", name, err))
+		}
+	}
+	if u.Soft > u.Hard {
+		return Ulimit{}, errors.NewValidationError("ParseUlimit",
+			fmt.Sprintf("ulimit %s: soft limit %s exceeds hard limit %s", name, soft, hard))
+	}
+	return u, nil
+}
+
+func parseLimit(s string) (uint64, error) {
+	if s == "unlimited" || s == "-1" {
+		return Unlimited, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit '%s'", s)
+	}
+	return n, nil
+}
+
+func formatLimit(n uint64) string {
+	if n == Unlimited {
+		return "unlimited"
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// String returns the limit in the form accepted by ParseUlimit
+func (u Ulimit) String() string {
+	return fmt.Sprintf("%s=%s:%s", u.Name, formatLimit(u.Soft), formatLimit(u.Hard))
+}
+
+// UlimitNames returns the names of the limits ParseUlimit accepts
+func UlimitNames() []string {
+	names := make([]string, 0, len(ulimitNames))
+	for name := range ulimitNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateSeccomp checks that profile names a seccomp profile; empty means
+// unconfined
+func ValidateSeccomp(profile string) error {
+	switch profile {
+	case "", SeccompUnconfined, SeccompDefault:
+		return nil
+	}
+	return errors.NewValidationError("ValidateSeccomp",
+		fmt.Sprintf("unknown seccomp profile '%s' (valid: %s, %s)", profile, SeccompDefault, SeccompUnconfined))
+}
+
+// Options are the restrictions applied to a container process
+type Options struct {
+	Seccomp         string
+	NoNewPrivileges bool
+	Ulimits         []string // in the form used by --ulimit
+}
+
+// Env returns the environment variables passing the options to the init process
+func (o Options) Env() []string {
+	var env []string
+	if o.Seccomp != "" && o.Seccomp != SeccompUnconfined {
+		env = append(env, envSeccomp+"="+o.Seccomp)
+	}
+	if o.NoNewPrivileges {
+		env = append(env, envNoNewPrivileges+"=1")
+	}
+	if len(o.Ulimits) > 0 {
+		env = append(env, envUlimits+"="+strings.Join(o.Ulimits, ","))
+	}
+	return env
+}
+
+// FromEnv reads the options passed to the init process and removes them from
+// its environment, so the container command does not inherit them
+func FromEnv() Options {
+	o := Options{
+		Seccomp:         os.Getenv(envSeccomp),
+		NoNewPrivileges: os.Getenv(envNoNewPrivileges) == "1",
+	}
+	if ulimits := os.Getenv(envUlimits); ulimits != "" {
+		o.Ulimits = strings.Split(ulimits, ",")
+	}
+
+	os.Unsetenv(envSeccomp)
+	os.Unsetenv(envNoNewPrivileges)
+	os.Unsetenv(envUlimits)
+	return o
+}
+
+// MergeUlimits returns the defaults overridden by the limits of the same
+// name in overrides
+func MergeUlimits(defaults, overrides []Ulimit) []Ulimit {
+	merged := append([]Ulimit(nil), overrides...)
+	for _, d := range defaults {
+		overridden := false
+		for _, o := range overrides {
+			overridden = overridden || o.Name == d.Name
+		}
+		if !overridden {
+			merged = append(merged, d)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
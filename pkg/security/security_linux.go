@@ -0,0 +1,52 @@
+//go:build linux
+
+package security
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Apply sets the options on the calling process. no_new_privs and the
+// seccomp filter only apply to the calling thread, so Apply locks the
+// goroutine to it; the container command must be started from the same
+// goroutine to inherit them.
+func Apply(o Options) error {
+	runtime.LockOSThread()
+
+	for _, spec := range o.Ulimits {
+		u, err := ParseUlimit(spec)
+		if err != nil {
+			return err
+		}
+		// syscall.Setrlimit keeps the NOFILE limit Go restores in children in sync
+		limit := &syscall.Rlimit{Cur: u.Soft, Max: u.Hard}
+		if err := syscall.Setrlimit(ulimitNames[u.Name], limit); err != nil {
+			return fmt.Errorf("failed to set ulimit %s: %v", u.Name, err)
+		}
+	}
+
+	if o.NoNewPrivileges {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("failed to set no_new_privs: %v", err)
+		}
+	}
+
+	switch o.Seccomp {
+	case "", SeccompUnconfined:
+	case SeccompDefault:
+		if auditArch == 0 {
+			return fmt.Errorf("the default seccomp profile is not supported on %s", runtime.GOARCH)
+		}
+		if err := loadSeccompFilter(defaultFilter()); err != nil {
+			return fmt.Errorf("failed to load seccomp profile: %v", err)
+		}
+	default:
+		return ValidateSeccomp(o.Seccomp)
+	}
+
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package security
+
+// Apply does nothing; containers only run on Linux
+func Apply(o Options) error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package shim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"servin/pkg/state"
+)
+
+// server is the shim side of the socket
+type server struct {
+	sm   *state.StateManager
+	done chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Serve runs the container under this process. It listens on the
+// container's socket, calls run, which returns once the container exited,
+// answers the clients waiting for that and removes the socket.
+func Serve(id string, run func() error) error {
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		return fmt.Errorf("failed to create shim directory: %v", err)
+	}
+	path := SocketPath(id)
+	if _, err := Query(id); err == nil {
+		return fmt.Errorf("container %s already has a shim", shortID(id))
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	defer os.Remove(path)
+	defer ln.Close()
+
+	s := &server{
+		sm:   state.NewStateManager().AllNamespaces(),
+		done: make(chan struct{}),
+		status: Status{
+			ContainerID: id,
+			ShimPID:     os.Getpid(),
+			Started:     time.Now(),
+			Running:     true,
+		},
+	}
+	go s.accept(ln)
+
+	runErr := run()
+
+	s.mu.Lock()
+	s.status.Running = false
+	if runErr != nil {
+		s.status.Error = runErr.Error()
+	}
+	s.mu.Unlock()
+	close(s.done)
+
+	// Give waiting clients a moment to read the answer before the socket goes
+	time.Sleep(100 * time.Millisecond)
+	return runErr
+}
+
+func (s *server) accept(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return
+	}
+	if req.Op == OpWait {
+		<-s.done
+	}
+	json.NewEncoder(conn).Encode(s.snapshot())
+}
+
+// snapshot returns the status with the container PID from its state, which
+// the container records once its process started
+func (s *server) snapshot() Status {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+
+	if cs, err := s.sm.LoadContainer(status.ContainerID); err == nil {
+		status.ContainerPID = cs.PID
+	}
+	return status
+}
@@ -0,0 +1,126 @@
+// Package shim runs each detached container under a small process of its
+// own, a shim, which is the parent of the container process, writes its
+// logs and records its exit. The daemon only starts shims and reconnects to
+// them when it starts, so it can be restarted or upgraded while containers
+// keep running.
+package shim
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"servin/pkg/state"
+)
+
+// Operations a shim answers on its socket
+const (
+	// OpStatus reports the shim's state right away
+	OpStatus = "status"
+	// OpWait reports it once the container has exited
+	OpWait = "wait"
+)
+
+// dialTimeout bounds connecting to a shim's socket
+const dialTimeout = 2 * time.Second
+
+// Request is one line of JSON sent to a shim
+type Request struct {
+	Op string `json:"op"`
+}
+
+// Status is a shim's answer
+type Status struct {
+	ContainerID string `json:"container_id"`
+	// ShimPID is the shim process; ContainerPID the container's init
+	ShimPID      int       `json:"shim_pid"`
+	ContainerPID int       `json:"container_pid,omitempty"`
+	Started      time.Time `json:"started"`
+	Running      bool      `json:"running"`
+	// Error is why the container failed to run, once it has exited
+	Error string `json:"error,omitempty"`
+}
+
+// Dir is where shims keep their sockets and their own output
+func Dir() string {
+	return filepath.Join(filepath.Dir(state.NewStateManager().GetStateDir()), "shims")
+}
+
+// SocketPath is the socket of the container's shim
+func SocketPath(id string) string {
+	return filepath.Join(Dir(), id+".sock")
+}
+
+// LogPath is the file the container's shim writes its own output to
+func LogPath(id string) string {
+	return filepath.Join(Dir(), id+".log")
+}
+
+// Query asks the container's shim for its status
+func Query(id string) (*Status, error) {
+	return request(id, OpStatus, dialTimeout)
+}
+
+// Wait blocks until the container's shim reports that the container exited
+func Wait(id string) (*Status, error) {
+	return request(id, OpWait, 0)
+}
+
+func request(id, op string, timeout time.Duration) (*Status, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(id), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	if err := json.NewEncoder(conn).Encode(Request{Op: op}); err != nil {
+		return nil, err
+	}
+	var status Status
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("shim of container %s did not answer: %v", shortID(id), err)
+	}
+	return &status, nil
+}
+
+// List returns the status of every live shim. Sockets left behind by shims
+// that are gone are removed.
+func List() ([]*Status, error) {
+	entries, err := os.ReadDir(Dir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var shims []*Status
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".sock")
+		if !ok {
+			continue
+		}
+		status, err := Query(id)
+		if err != nil {
+			os.Remove(SocketPath(id))
+			continue
+		}
+		shims = append(shims, status)
+	}
+	return shims, nil
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
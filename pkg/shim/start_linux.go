@@ -0,0 +1,76 @@
+//go:build linux
+
+package shim
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// startTimeout is how long a new shim has to open its socket
+const startTimeout = 10 * time.Second
+
+// Start launches a shim for the container, which must have been saved to
+// state, and returns once the shim answers on its socket. The shim runs in
+// its own session, so it outlives the process starting it.
+func Start(id string) (*Status, error) {
+	if err := os.MkdirAll(Dir(), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create shim directory: %v", err)
+	}
+	logFile, err := os.OpenFile(LogPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shim log: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command("/proc/self/exe", "shim", id)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start shim: %v", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	deadline := time.After(startTimeout)
+	for {
+		if status, err := Query(id); err == nil {
+			return status, nil
+		}
+		select {
+		case <-exited:
+			return nil, fmt.Errorf("shim exited before serving: %s", lastLine(LogPath(id)))
+		case <-deadline:
+			cmd.Process.Kill()
+			return nil, fmt.Errorf("shim did not start within %v", startTimeout)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Reap collects the exit status of a shim started by an earlier instance of
+// this process, such as the daemon before it re-executed itself, so it does
+// not linger as a zombie. It does nothing for other processes' shims.
+func Reap(pid int) {
+	var ws syscall.WaitStatus
+	syscall.Wait4(pid, &ws, 0, nil)
+}
+
+// lastLine returns the last non-empty line of a file, to explain failures
+func lastLine(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "no output"
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package shim
+
+import "fmt"
+
+// Start launches a shim for the container (Linux only; elsewhere containers
+// run in the VM)
+func Start(id string) (*Status, error) {
+	return nil, fmt.Errorf("container shims are only supported on Linux")
+}
+
+// Reap collects the exit status of a shim (no-op)
+func Reap(pid int) {}
@@ -10,7 +10,10 @@ import (
 	"strings"
 	"time"
 
+	"servin/pkg/health"
+	"servin/pkg/hooks"
 	"servin/pkg/network"
+	"servin/pkg/tenancy"
 )
 
 // Container status constants
@@ -25,6 +28,7 @@ const (
 type ContainerState struct {
 	ID           string                `json:"id"`
 	Name         string                `json:"name"`
+	Namespace    string                `json:"namespace,omitempty"`
 	Image        string                `json:"image"`
 	Command      string                `json:"command"`
 	Args         []string              `json:"args"`
@@ -43,11 +47,70 @@ type ContainerState struct {
 	PortMappings []network.PortMapping `json:"port_mappings"`
 	Memory       string                `json:"memory"`
 	CPUs         string                `json:"cpus"`
+	CpusetCpus   string                `json:"cpuset_cpus,omitempty"`
+	CpusetMems   string                `json:"cpuset_mems,omitempty"`
+
+	// Restart policy ("no", "always", "on-failure[:N]", "unless-stopped")
+	RestartPolicy string `json:"restart_policy,omitempty"`
+	RestartCount  int    `json:"restart_count"`
+
+	// Process restrictions: the seccomp profile, no_new_privs and ulimits
+	Seccomp         string   `json:"seccomp,omitempty"`
+	NoNewPrivileges bool     `json:"no_new_privileges,omitempty"`
+	Ulimits         []string `json:"ulimits,omitempty"`
+
+	// StopSignal is sent to stop the container; empty means SIGTERM
+	StopSignal string `json:"stop_signal,omitempty"`
+
+	// Health check configuration and the latest probe results
+	Healthcheck *health.Config `json:"healthcheck,omitempty"`
+	Health      *health.State  `json:"health,omitempty"`
+
+	// Lifecycle hooks run on the host at container events
+	Hooks []hooks.Hook `json:"hooks,omitempty"`
+
+	// Labels are user metadata, such as the protected label
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// AnonymousVolumes are the volumes of Volumes created for the container,
+	// removed with it by 'servin rm --volumes'
+	AnonymousVolumes []string `json:"anonymous_volumes,omitempty"`
+
+	// IPAddress is the container's address on its network while it runs
+	IPAddress string `json:"ip_address,omitempty"`
+	// IP6Address is its IPv6 address, on networks with IPv6
+	IP6Address string `json:"ip6_address,omitempty"`
+	// PublishedPorts are the ports published on the host while it runs,
+	// PortMappings with the host ports allocated for those left to servin
+	PublishedPorts []network.PortMapping `json:"published_ports,omitempty"`
+	// NetworkAliases are extra names other containers on the network resolve it by
+	NetworkAliases []string `json:"network_aliases,omitempty"`
+	// Networks are the bridge networks the container was connected to
+	// besides its own with 'servin network connect', and NetworkIPs its
+	// address on each of them while it runs, with NetworkIP6s its IPv6
+	// address on those with IPv6
+	Networks    []string          `json:"networks,omitempty"`
+	NetworkIPs  map[string]string `json:"network_ips,omitempty"`
+	NetworkIP6s map[string]string `json:"network_ip6s,omitempty"`
+	// Links are containers ("name[:alias]") resolved by alias; with LinkEnv
+	// set, their addresses are also passed in legacy link variables
+	Links   []string `json:"links,omitempty"`
+	LinkEnv bool     `json:"link_env,omitempty"`
+	// DNS overrides the DNS settings of the container's network: its
+	// nameservers, search domains, options and extra /etc/hosts entries
+	DNS *network.DNSConfig `json:"dns,omitempty"`
+
+	// Machine registers the container with systemd-machined while it runs
+	// and forwards its output to the journal
+	Machine bool `json:"machine,omitempty"`
 }
 
 // StateManager manages container state persistence
 type StateManager struct {
 	stateDir string
+
+	// namespace limits the containers the manager sees; empty means all namespaces
+	namespace string
 }
 
 // NewStateManager creates a new state manager
@@ -73,10 +136,31 @@ func NewStateManager() *StateManager {
 	}
 
 	return &StateManager{
-		stateDir: stateDir,
+		stateDir:  stateDir,
+		namespace: tenancy.Current(),
 	}
 }
 
+// InNamespace returns a state manager scoped to the given namespace
+func (sm *StateManager) InNamespace(namespace string) *StateManager {
+	return &StateManager{stateDir: sm.stateDir, namespace: tenancy.Normalize(namespace)}
+}
+
+// AllNamespaces returns a state manager that sees containers in every namespace
+func (sm *StateManager) AllNamespaces() *StateManager {
+	return &StateManager{stateDir: sm.stateDir}
+}
+
+// Namespace returns the namespace the manager is scoped to, or "" for all namespaces
+func (sm *StateManager) Namespace() string {
+	return sm.namespace
+}
+
+// visible reports whether a container belongs to the manager's namespace
+func (sm *StateManager) visible(state *ContainerState) bool {
+	return sm.namespace == "" || tenancy.Normalize(state.Namespace) == sm.namespace
+}
+
 // ensureStateDir creates the state directory if it doesn't exist
 func (sm *StateManager) ensureStateDir() error {
 	return os.MkdirAll(sm.stateDir, 0755)
@@ -88,6 +172,13 @@ func (sm *StateManager) SaveContainer(state *ContainerState) error {
 		return fmt.Errorf("failed to create state directory: %v", err)
 	}
 
+	if state.Namespace == "" {
+		state.Namespace = tenancy.Normalize(sm.namespace)
+	}
+	if err := tenancy.Create(state.Namespace); err != nil {
+		return err
+	}
+
 	statePath := filepath.Join(sm.stateDir, state.ID+".json")
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
@@ -115,6 +206,10 @@ func (sm *StateManager) LoadContainer(id string) (*ContainerState, error) {
 		return nil, fmt.Errorf("failed to unmarshal container state: %v", err)
 	}
 
+	if !sm.visible(&state) {
+		return nil, fmt.Errorf("container %s not found in namespace '%s'", id, sm.namespace)
+	}
+
 	return &state, nil
 }
 
@@ -144,7 +239,9 @@ func (sm *StateManager) ListContainers() ([]*ContainerState, error) {
 				return nil
 			}
 
-			containers = append(containers, &state)
+			if sm.visible(&state) {
+				containers = append(containers, &state)
+			}
 		}
 
 		return nil
@@ -188,6 +285,40 @@ func (sm *StateManager) UpdateContainerStatus(id, status string) error {
 	return sm.SaveContainer(state)
 }
 
+// UpdateContainerExit records the exit code of a container whose process has terminated
+func (sm *StateManager) UpdateContainerExit(id string, exitCode int) error {
+	state, err := sm.LoadContainer(id)
+	if err != nil {
+		return err
+	}
+
+	// A container stopped by the user keeps its status so it is not restarted
+	if state.Status != StatusStopped {
+		state.Status = StatusExited
+	}
+	state.ExitCode = exitCode
+	state.PID = 0
+	state.IPAddress = ""
+	state.IP6Address = ""
+	state.PublishedPorts = nil
+	state.NetworkIPs = nil
+	state.NetworkIP6s = nil
+	state.Finished = time.Now()
+
+	return sm.SaveContainer(state)
+}
+
+// UpdateContainerHealth updates the health check state of a container
+func (sm *StateManager) UpdateContainerHealth(id string, h *health.State) error {
+	state, err := sm.LoadContainer(id)
+	if err != nil {
+		return err
+	}
+
+	state.Health = h
+	return sm.SaveContainer(state)
+}
+
 // UpdateContainerPID updates the PID of a container
 func (sm *StateManager) UpdateContainerPID(id string, pid int) error {
 	state, err := sm.LoadContainer(id)
@@ -199,6 +330,98 @@ func (sm *StateManager) UpdateContainerPID(id string, pid int) error {
 	return sm.SaveContainer(state)
 }
 
+// UpdateContainerIP records the addresses a container was given on its
+// network; ip6 is empty on networks without IPv6
+func (sm *StateManager) UpdateContainerIP(id, ip, ip6 string) error {
+	state, err := sm.LoadContainer(id)
+	if err != nil {
+		return err
+	}
+
+	state.IPAddress = ip
+	state.IP6Address = ip6
+	return sm.SaveContainer(state)
+}
+
+// UpdateContainerPorts records the ports a container publishes on the host,
+// or forgets them when ports is nil
+func (sm *StateManager) UpdateContainerPorts(id string, ports []network.PortMapping) error {
+	state, err := sm.LoadContainer(id)
+	if err != nil {
+		return err
+	}
+
+	state.PublishedPorts = ports
+	return sm.SaveContainer(state)
+}
+
+// UpdateContainerNetworkIP records the addresses a container was given on a
+// network it is connected to, or forgets them when ip is empty
+func (sm *StateManager) UpdateContainerNetworkIP(id, networkName, ip, ip6 string) error {
+	state, err := sm.LoadContainer(id)
+	if err != nil {
+		return err
+	}
+
+	state.SetNetworkIP(networkName, ip, ip6)
+	return sm.SaveContainer(state)
+}
+
+// SetNetworkIP records the addresses of the container on a network it is
+// connected to, or forgets them when ip is empty
+func (cs *ContainerState) SetNetworkIP(networkName, ip, ip6 string) {
+	delete(cs.NetworkIPs, networkName)
+	delete(cs.NetworkIP6s, networkName)
+	if ip == "" {
+		return
+	}
+	if cs.NetworkIPs == nil {
+		cs.NetworkIPs = make(map[string]string)
+	}
+	cs.NetworkIPs[networkName] = ip
+	if ip6 != "" {
+		if cs.NetworkIP6s == nil {
+			cs.NetworkIP6s = make(map[string]string)
+		}
+		cs.NetworkIP6s[networkName] = ip6
+	}
+}
+
+// AddressOn returns the container's address on the named network while it
+// runs, or "" when it is not on it
+func (cs *ContainerState) AddressOn(networkName string) string {
+	name := network.CanonicalName(networkName)
+	if network.CanonicalName(cs.NetworkMode) == name {
+		return cs.IPAddress
+	}
+	return cs.NetworkIPs[name]
+}
+
+// Address6On returns the container's IPv6 address on the named network
+// while it runs, or "" when it is not on it or the network has no IPv6
+func (cs *ContainerState) Address6On(networkName string) string {
+	name := network.CanonicalName(networkName)
+	if network.CanonicalName(cs.NetworkMode) == name {
+		return cs.IP6Address
+	}
+	return cs.NetworkIP6s[name]
+}
+
+// OnNetwork reports whether the container is on the named network, as its
+// own or as one it was connected to
+func (cs *ContainerState) OnNetwork(networkName string) bool {
+	name := network.CanonicalName(networkName)
+	if network.CanonicalName(cs.NetworkMode) == name {
+		return true
+	}
+	for _, n := range cs.Networks {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 // FindContainerByName finds a container by name (returns ID)
 func (sm *StateManager) FindContainerByName(name string) (string, error) {
 	containers, err := sm.ListContainers()
@@ -0,0 +1,168 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// History is a fixed-size ring buffer of one container's most recent samples
+type History struct {
+	samples []*Stats
+	next    int
+	full    bool
+}
+
+// NewHistory creates a history holding at most capacity samples
+func NewHistory(capacity int) *History {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &History{samples: make([]*Stats, capacity)}
+}
+
+// Add appends a sample, overwriting the oldest one when the buffer is full
+func (h *History) Add(s *Stats) {
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Samples returns the retained samples, oldest first
+func (h *History) Samples() []*Stats {
+	if !h.full {
+		return append([]*Stats(nil), h.samples[:h.next]...)
+	}
+	samples := make([]*Stats, 0, len(h.samples))
+	samples = append(samples, h.samples[h.next:]...)
+	return append(samples, h.samples[:h.next]...)
+}
+
+// Recorder keeps a history of recent samples for every container it is given
+// and persists it, so 'servin stats export' can read it from another process
+type Recorder struct {
+	mu        sync.Mutex
+	dir       string
+	retention time.Duration
+	capacity  int
+	histories map[string]*History
+}
+
+// NewRecorder creates a recorder retaining samples taken every interval for
+// the retention window. History saved by an earlier recorder is picked up.
+func NewRecorder(interval, retention time.Duration) *Recorder {
+	r := &Recorder{
+		dir:       historyDir(),
+		retention: retention,
+		capacity:  int(retention / interval),
+		histories: make(map[string]*History),
+	}
+
+	entries, _ := os.ReadDir(r.dir)
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if id == entry.Name() {
+			continue
+		}
+		samples, err := LoadHistory(id)
+		if err != nil {
+			continue
+		}
+		h := NewHistory(r.capacity)
+		for _, s := range samples {
+			if time.Since(s.Read) <= retention {
+				h.Add(s)
+			}
+		}
+		r.histories[id] = h
+	}
+
+	return r
+}
+
+// Record adds samples to their containers' histories and saves them
+func (r *Recorder) Record(samples []*Stats) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create stats directory: %v", err)
+	}
+
+	for _, s := range samples {
+		h, ok := r.histories[s.ID]
+		if !ok {
+			h = NewHistory(r.capacity)
+			r.histories[s.ID] = h
+		}
+		h.Add(s)
+
+		if err := r.save(s.ID, h.Samples()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget drops the history of containers that are not in keep
+func (r *Recorder) Forget(keep map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id := range r.histories {
+		if !keep[id] {
+			delete(r.histories, id)
+			os.Remove(historyPath(id))
+		}
+	}
+}
+
+func (r *Recorder) save(id string, samples []*Stats) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return fmt.Errorf("failed to encode stats history: %v", err)
+	}
+
+	// Write atomically so readers never see a partial file
+	tmp := historyPath(id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write stats history: %v", err)
+	}
+	return os.Rename(tmp, historyPath(id))
+}
+
+// LoadHistory returns the samples retained for a container, oldest first
+func LoadHistory(containerID string) ([]*Stats, error) {
+	data, err := os.ReadFile(historyPath(containerID))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*Stats
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return nil, fmt.Errorf("failed to parse stats history: %v", err)
+	}
+	return samples, nil
+}
+
+// historyDir returns the directory holding the retained stats history
+func historyDir() string {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, ".servin", "stats")
+	default:
+		return "/var/lib/servin/stats"
+	}
+}
+
+func historyPath(containerID string) string {
+	return filepath.Join(historyDir(), containerID+".json")
+}
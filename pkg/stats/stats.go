@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"sync"
+	"time"
+
+	"servin/pkg/state"
+)
+
+// Stats represents a point-in-time resource usage snapshot for a container
+type Stats struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Read          time.Time `json:"read"`
+	CPUUsage      uint64    `json:"cpu_usage_ns"`
+	CPUPercent    float64   `json:"cpu_percent"`
+	MemoryUsage   uint64    `json:"memory_usage"`
+	MemoryLimit   uint64    `json:"memory_limit"`
+	MemoryPercent float64   `json:"memory_percent"`
+	NetRx         uint64    `json:"net_rx_bytes"`
+	NetTx         uint64    `json:"net_tx_bytes"`
+	BlockRead     uint64    `json:"block_read_bytes"`
+	BlockWrite    uint64    `json:"block_write_bytes"`
+	PIDs          uint64    `json:"pids"`
+}
+
+// Collector samples container resource usage and derives CPU percentages
+// from the difference between consecutive samples of the same container
+type Collector struct {
+	mu       sync.Mutex
+	previous map[string]*Stats
+}
+
+// NewCollector creates a new stats collector
+func NewCollector() *Collector {
+	return &Collector{previous: make(map[string]*Stats)}
+}
+
+// Collect takes a new sample for the container. The CPU percentage is zero
+// until a previous sample for the same container is available.
+func (c *Collector) Collect(cs *state.ContainerState) (*Stats, error) {
+	s, err := sample(cs)
+	if err != nil {
+		return nil, err
+	}
+	s.ID = cs.ID
+	s.Name = cs.Name
+	s.Read = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if prev, ok := c.previous[cs.ID]; ok {
+		s.CPUPercent = cpuPercent(prev, s)
+	}
+	c.previous[cs.ID] = s
+
+	return s, nil
+}
+
+// Update derives CPU and memory percentages for a sample reported by another
+// source, such as the agent inside a VM
+func (c *Collector) Update(s *Stats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if prev, ok := c.previous[s.ID]; ok {
+		s.CPUPercent = cpuPercent(prev, s)
+	}
+	if s.MemoryPercent == 0 && s.MemoryLimit > 0 {
+		s.MemoryPercent = float64(s.MemoryUsage) / float64(s.MemoryLimit) * 100
+	}
+	c.previous[s.ID] = s
+}
+
+// cpuPercent returns the CPU time consumed between two samples as a
+// percentage of a single CPU, so a busy multi-threaded container can exceed 100%
+func cpuPercent(prev, cur *Stats) float64 {
+	elapsed := cur.Read.Sub(prev.Read)
+	if elapsed <= 0 || cur.CPUUsage < prev.CPUUsage {
+		return 0
+	}
+	return float64(cur.CPUUsage-prev.CPUUsage) / float64(elapsed.Nanoseconds()) * 100
+}
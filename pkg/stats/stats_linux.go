@@ -0,0 +1,161 @@
+//go:build linux
+
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"servin/pkg/state"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// clockTicks is the USER_HZ value used by /proc/<pid>/stat on all supported architectures
+const clockTicks = 100
+
+// sample reads resource usage from the container's cgroups, falling back to
+// the /proc entries of its init process when a controller is unavailable
+func sample(cs *state.ContainerState) (*Stats, error) {
+	s := &Stats{}
+	pid := cs.PID
+	if pid > 0 {
+		if _, err := os.Stat(fmt.Sprintf("/proc/%d", pid)); err != nil {
+			pid = 0
+		}
+	}
+
+	// CPU time in nanoseconds
+	if v, err := readCgroupUint("cpu", cs.ID, "cpuacct.usage"); err == nil {
+		s.CPUUsage = v
+	} else if pid > 0 {
+		s.CPUUsage = procCPUTime(pid)
+	}
+
+	// Memory usage and limit
+	if v, err := readCgroupUint("memory", cs.ID, "memory.usage_in_bytes"); err == nil {
+		s.MemoryUsage = v
+	} else if pid > 0 {
+		s.MemoryUsage = procStatusValue(pid, "VmRSS") * 1024
+	}
+	hostMemory := hostMemTotal()
+	if v, err := readCgroupUint("memory", cs.ID, "memory.limit_in_bytes"); err == nil && (hostMemory == 0 || v < hostMemory) {
+		s.MemoryLimit = v
+	} else {
+		s.MemoryLimit = hostMemory
+	}
+	if s.MemoryLimit > 0 {
+		s.MemoryPercent = float64(s.MemoryUsage) / float64(s.MemoryLimit) * 100
+	}
+
+	// Process count
+	if v, err := readCgroupUint("pids", cs.ID, "pids.current"); err == nil {
+		s.PIDs = v
+	} else if pid > 0 {
+		s.PIDs = procStatusValue(pid, "Threads")
+	}
+
+	if pid > 0 {
+		s.NetRx, s.NetTx = procNetIO(pid)
+		s.BlockRead, s.BlockWrite = procBlockIO(pid)
+	}
+
+	return s, nil
+}
+
+func readCgroupUint(subsystem, containerID, file string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(cgroupRoot, subsystem, "servin", containerID, file))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// procCPUTime returns the user and system time of a process in nanoseconds
+func procCPUTime(pid int) uint64 {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+
+	// The command name may contain spaces, so split after its closing parenthesis
+	content := string(data)
+	if i := strings.LastIndex(content, ")"); i >= 0 {
+		content = content[i+1:]
+	}
+	fields := strings.Fields(content)
+	// utime and stime are fields 14 and 15 of the full line
+	if len(fields) < 13 {
+		return 0
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	return (utime + stime) * (1e9 / clockTicks)
+}
+
+// procStatusValue returns the numeric value of a /proc/<pid>/status field
+func procStatusValue(pid int, key string) uint64 {
+	return readKeyedValue(fmt.Sprintf("/proc/%d/status", pid), key+":")
+}
+
+func hostMemTotal() uint64 {
+	return readKeyedValue("/proc/meminfo", "MemTotal:") * 1024
+}
+
+func readKeyedValue(path, key string) uint64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, key) {
+			fields := strings.Fields(strings.TrimPrefix(line, key))
+			if len(fields) == 0 {
+				return 0
+			}
+			v, _ := strconv.ParseUint(fields[0], 10, 64)
+			return v
+		}
+	}
+	return 0
+}
+
+// procNetIO sums the traffic of all non-loopback interfaces in the process's network namespace
+func procNetIO(pid int) (rx, tx uint64) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/net/dev", pid))
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		iface, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		r, _ := strconv.ParseUint(fields[0], 10, 64)
+		t, _ := strconv.ParseUint(fields[8], 10, 64)
+		rx += r
+		tx += t
+	}
+	return rx, tx
+}
+
+// procBlockIO returns the bytes the process has read from and written to storage
+func procBlockIO(pid int) (read, write uint64) {
+	path := fmt.Sprintf("/proc/%d/io", pid)
+	return readKeyedValue(path, "read_bytes:"), readKeyedValue(path, "write_bytes:")
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package stats
+
+import (
+	"fmt"
+
+	"servin/pkg/state"
+)
+
+// sample is not available on non-Linux hosts; containers running inside the
+// VM report their usage through the VM provider instead
+func sample(cs *state.ContainerState) (*Stats, error) {
+	return nil, fmt.Errorf("container resource usage is only available on Linux or through VM mode")
+}
@@ -0,0 +1,110 @@
+//go:build linux
+
+package systemd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// journalSocket is where journald receives entries in its native protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+// JournalWriter sends each line written to it to the journal as an entry
+// with a fixed set of fields
+type JournalWriter struct {
+	conn   *net.UnixConn
+	header []byte
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+// NewJournalWriter connects to journald. Lines are logged at priority, a
+// syslog level, with the given fields.
+func NewJournalWriter(priority int, fields map[string]string) (*JournalWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %v", err)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var header bytes.Buffer
+	header.WriteString("PRIORITY=" + strconv.Itoa(priority) + "\n")
+	for _, key := range keys {
+		appendJournalField(&header, key, []byte(fields[key]))
+	}
+	return &JournalWriter{conn: conn, header: header.Bytes()}, nil
+}
+
+// Write sends the complete lines in p and keeps a partial last line for
+// the next write
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.send(w.pending[:i])
+		w.pending = w.pending[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close sends a partial last line and disconnects
+func (w *JournalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) > 0 {
+		w.send(w.pending)
+		w.pending = nil
+	}
+	return w.conn.Close()
+}
+
+// send writes one entry, without the carriage return terminal output ends
+// lines with. Losing log lines must not fail the container, so
+// errors, such as journald restarting, are dropped.
+func (w *JournalWriter) send(message []byte) {
+	message = bytes.TrimSuffix(message, []byte("\r"))
+
+	var entry bytes.Buffer
+	entry.Write(w.header)
+	appendJournalField(&entry, "MESSAGE", message)
+	w.conn.Write(entry.Bytes())
+}
+
+// appendJournalField encodes a field in the native journal protocol. Values
+// with a newline use the length-prefixed binary form.
+func appendJournalField(buf *bytes.Buffer, key string, value []byte) {
+	if bytes.IndexByte(value, '\n') < 0 {
+		buf.WriteString(key + "=")
+		buf.Write(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	n := uint64(len(value))
+	for i := range size {
+		size[i] = byte(n >> (8 * i))
+	}
+	buf.WriteString(key + "\n")
+	buf.Write(size[:])
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
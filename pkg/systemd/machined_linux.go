@@ -0,0 +1,64 @@
+//go:build linux
+
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	machinedDestination = "org.freedesktop.machine1"
+	machinedPath        = "/org/freedesktop/machine1"
+	machinedInterface   = "org.freedesktop.machine1.Manager"
+)
+
+// Available reports whether the host runs systemd, which provides
+// systemd-machined and the journal
+func Available() bool {
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}
+
+// RegisterMachine registers a running container with systemd-machined. The
+// container keeps its own cgroups; machined only tracks its leader process.
+func RegisterMachine(m Machine) error {
+	id, err := machineID(m.ID)
+	if err != nil {
+		return err
+	}
+	if m.Name == "" {
+		return fmt.Errorf("machine name is empty")
+	}
+
+	// RegisterMachine(s name, ay id, s service, s class, u leader, s root_directory)
+	args := []string{"RegisterMachine", "sayssus", m.Name, strconv.Itoa(len(id))}
+	for _, b := range id {
+		args = append(args, strconv.Itoa(int(b)))
+	}
+	args = append(args, machineService, "container", strconv.Itoa(m.Leader), m.RootDirectory)
+	return callMachined(args...)
+}
+
+// UnregisterMachine removes a container's machine from systemd-machined
+// without touching its processes
+func UnregisterMachine(name string) error {
+	return callMachined("UnregisterMachine", "s", name)
+}
+
+// callMachined calls a method of the machined manager with busctl
+func callMachined(args ...string) error {
+	busctl, err := exec.LookPath("busctl")
+	if err != nil {
+		return fmt.Errorf("busctl not found in PATH: systemd is required to register machines")
+	}
+
+	cmdArgs := append([]string{"call", machinedDestination, machinedPath, machinedInterface}, args...)
+	if output, err := exec.Command(busctl, cmdArgs...).CombinedOutput(); err != nil {
+		return fmt.Errorf("machined %s failed: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package systemd integrates containers with the systemd tooling of Linux
+// hosts: containers can be registered with systemd-machined, so they show up
+// in machinectl, and their output can be forwarded to the journal.
+package systemd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Machine describes a container registered with systemd-machined
+type Machine struct {
+	// Name is the machine name machinectl shows, see MachineName
+	Name string
+	// ID is the container ID; its first 32 hex digits are the machine ID
+	ID string
+	// Leader is the PID of the container's init process
+	Leader int
+	// RootDirectory is the container's root filesystem on the host
+	RootDirectory string
+}
+
+// machineService is the service name machines are registered under
+const machineService = "servin"
+
+// invalidMachineChars matches the characters machined does not accept in
+// a machine name
+var invalidMachineChars = regexp.MustCompile(`[^a-zA-Z0-9.-]`)
+
+// MachineName converts a container name to a valid machine name: a host
+// name of at most 64 characters
+func MachineName(name string) string {
+	name = strings.Trim(invalidMachineChars.ReplaceAllString(name, "-"), "-.")
+	if len(name) > 64 {
+		name = strings.TrimRight(name[:64], "-.")
+	}
+	return name
+}
+
+// machineID returns the 128-bit machine ID for a container ID
+func machineID(id string) ([]byte, error) {
+	if len(id) < 32 {
+		return nil, fmt.Errorf("container ID %q is too short for a machine ID", id)
+	}
+	b, err := hex.DecodeString(id[:32])
+	if err != nil {
+		return nil, fmt.Errorf("container ID %q is not hexadecimal: %v", id, err)
+	}
+	return b, nil
+}
+
+// Journal priorities of container output, as syslog levels
+const (
+	// PriorityInfo is used for the container's standard output
+	PriorityInfo = 6
+	// PriorityError is used for the container's standard error
+	PriorityError = 3
+)
+
+// JournalFields returns the journal fields identifying a container's
+// entries. They match Docker's journald log driver, so the output can be
+// read with journalctl CONTAINER_NAME=<name>.
+func JournalFields(id, name, image string) map[string]string {
+	short := id
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	return map[string]string{
+		"SYSLOG_IDENTIFIER": name,
+		"CONTAINER_ID":      short,
+		"CONTAINER_ID_FULL": id,
+		"CONTAINER_NAME":    name,
+		"IMAGE_NAME":        image,
+	}
+}
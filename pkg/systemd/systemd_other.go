@@ -0,0 +1,38 @@
+//go:build !linux
+
+package systemd
+
+import "fmt"
+
+// Available reports false: systemd only runs on Linux
+func Available() bool {
+	return false
+}
+
+// RegisterMachine is not supported on non-Linux platforms
+func RegisterMachine(m Machine) error {
+	return fmt.Errorf("systemd-machined is only available on Linux")
+}
+
+// UnregisterMachine is not supported on non-Linux platforms
+func UnregisterMachine(name string) error {
+	return fmt.Errorf("systemd-machined is only available on Linux")
+}
+
+// JournalWriter is not supported on non-Linux platforms
+type JournalWriter struct{}
+
+// NewJournalWriter is not supported on non-Linux platforms
+func NewJournalWriter(priority int, fields map[string]string) (*JournalWriter, error) {
+	return nil, fmt.Errorf("journald is only available on Linux")
+}
+
+// Write is not supported on non-Linux platforms
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("journald is only available on Linux")
+}
+
+// Close is not supported on non-Linux platforms
+func (w *JournalWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"strconv"
+	"time"
+
+	"servin/pkg/cgroups"
+	"servin/pkg/state"
+)
+
+// cgroupStatMetrics maps cgroup stat keys to metric names and types
+var cgroupStatMetrics = map[string]struct {
+	name string
+	kind string
+}{
+	"memory_usage": {"container.memory.usage_bytes", Gauge},
+	"cpu_usage":    {"container.cpu.usage_ns", Counter},
+	"pids_current": {"container.pids", Gauge},
+}
+
+// CollectContainerMetrics gathers container counts and per-container resource usage
+func CollectContainerMetrics(sm *state.StateManager) ([]Metric, error) {
+	containers, err := sm.ListContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byStatus := map[string]int{
+		state.StatusCreated: 0,
+		state.StatusRunning: 0,
+		state.StatusStopped: 0,
+		state.StatusExited:  0,
+	}
+
+	var metrics []Metric
+	for _, c := range containers {
+		byStatus[c.Status]++
+
+		tags := map[string]string{"container": c.Name, "image": c.Image}
+		metrics = append(metrics, Metric{
+			Name: "container.restarts", Type: Counter, Value: float64(c.RestartCount), Tags: tags, Timestamp: now,
+		})
+
+		if c.Status != state.StatusRunning {
+			continue
+		}
+
+		stats, err := cgroups.New(c.ID).GetStats()
+		if err != nil {
+			continue
+		}
+		for key, value := range stats {
+			def, ok := cgroupStatMetrics[key]
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			metrics = append(metrics, Metric{Name: def.name, Type: def.kind, Value: v, Tags: tags, Timestamp: now})
+		}
+	}
+
+	for status, count := range byStatus {
+		metrics = append(metrics, Metric{
+			Name: "containers", Type: Gauge, Value: float64(count),
+			Tags: map[string]string{"status": status}, Timestamp: now,
+		})
+	}
+
+	return metrics, nil
+}
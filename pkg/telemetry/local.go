@@ -0,0 +1,162 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTraceFile is where the file exporter writes when no endpoint is given
+const defaultTraceFile = "servin-trace.json"
+
+// consoleExporter collects spans in memory and prints them as a tree on Close.
+// It is used by `servin --trace` when no collector is configured.
+type consoleExporter struct {
+	out   io.Writer
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func newConsoleExporter(endpoint string) (Exporter, error) {
+	return &consoleExporter{out: os.Stderr}, nil
+}
+
+func (e *consoleExporter) Name() string {
+	return "console"
+}
+
+func (e *consoleExporter) ExportMetrics(metrics []Metric) error {
+	for _, m := range metrics {
+		fmt.Fprintf(e.out, "metric %s=%g %v\n", m.Name, m.Value, m.Tags)
+	}
+	return nil
+}
+
+func (e *consoleExporter) ExportSpans(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *consoleExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.spans) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool, len(e.spans))
+	children := make(map[string][]*Span)
+	for _, s := range e.spans {
+		known[s.SpanID] = true
+	}
+
+	var roots []*Span
+	for _, s := range e.spans {
+		if s.ParentID != "" && known[s.ParentID] {
+			children[s.ParentID] = append(children[s.ParentID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	byStart := func(list []*Span) {
+		sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	}
+	byStart(roots)
+
+	var print func(s *Span, depth int)
+	print = func(s *Span, depth int) {
+		line := fmt.Sprintf("%s%-*s %10s", strings.Repeat("  ", depth), 40-2*depth, s.Name,
+			s.Duration().Round(time.Microsecond))
+		if s.Error != "" {
+			line += "  error: " + s.Error
+		}
+		fmt.Fprintln(e.out, line)
+
+		kids := children[s.SpanID]
+		byStart(kids)
+		for _, child := range kids {
+			print(child, depth+1)
+		}
+	}
+
+	fmt.Fprintf(e.out, "\nTrace %s\n", roots[0].TraceID)
+	for _, s := range roots {
+		print(s, 1)
+	}
+
+	e.spans = nil
+	return nil
+}
+
+// fileExporter appends spans and metrics to a file as JSON lines
+type fileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileExporter(endpoint string) (Exporter, error) {
+	if endpoint == "" {
+		endpoint = defaultTraceFile
+	}
+
+	file, err := os.OpenFile(endpoint, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileExporter{file: file}, nil
+}
+
+func (e *fileExporter) Name() string {
+	return "file"
+}
+
+func (e *fileExporter) ExportMetrics(metrics []Metric) error {
+	for _, m := range metrics {
+		if err := e.write(map[string]interface{}{
+			"type": "metric", "name": m.Name, "kind": m.Type, "value": m.Value,
+			"tags": m.Tags, "timestamp": m.Timestamp,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *fileExporter) ExportSpans(spans []*Span) error {
+	for _, s := range spans {
+		if err := e.write(map[string]interface{}{
+			"type": "span", "trace_id": s.TraceID, "span_id": s.SpanID, "parent_id": s.ParentID,
+			"name": s.Name, "start": s.Start, "end": s.End, "duration_ms": s.Duration().Milliseconds(),
+			"attributes": s.Attributes, "error": s.Error,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *fileExporter) Close() error {
+	return e.file.Close()
+}
+
+func (e *fileExporter) write(record map[string]interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.file.Write(append(data, '\n'))
+	return err
+}
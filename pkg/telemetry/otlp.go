@@ -0,0 +1,231 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultOTLPEndpoint is the standard OTLP/HTTP collector address
+const defaultOTLPEndpoint = "http://localhost:4318"
+
+// otlpExporter sends metrics and spans to an OpenTelemetry collector
+// using the OTLP/HTTP JSON encoding
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newOTLPExporter(endpoint string) (Exporter, error) {
+	if endpoint == "" {
+		endpoint = defaultOTLPEndpoint
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "http://" + endpoint
+	}
+
+	return &otlpExporter{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// OTLP JSON payload types (subset of the OpenTelemetry protocol)
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpDataPoint struct {
+	AsDouble     float64        `json:"asDouble"`
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+const (
+	otlpTemporalityCumulative = 2
+	otlpSpanKindInternal      = 1
+	otlpStatusOK              = 1
+	otlpStatusError           = 2
+)
+
+func (e *otlpExporter) Name() string {
+	return "otlp"
+}
+
+func (e *otlpExporter) ExportMetrics(metrics []Metric) error {
+	out := make([]otlpMetric, 0, len(metrics))
+	for _, m := range metrics {
+		ts := m.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		point := otlpDataPoint{
+			AsDouble:     m.Value,
+			TimeUnixNano: unixNano(ts),
+			Attributes:   otlpAttributes(m.Tags),
+		}
+
+		metric := otlpMetric{Name: "servin." + m.Name}
+		if m.Type == Counter {
+			metric.Sum = &otlpSum{
+				DataPoints:             []otlpDataPoint{point},
+				AggregationTemporality: otlpTemporalityCumulative,
+				IsMonotonic:            true,
+			}
+		} else {
+			metric.Gauge = &otlpGauge{DataPoints: []otlpDataPoint{point}}
+		}
+		out = append(out, metric)
+	}
+
+	payload := map[string]interface{}{
+		"resourceMetrics": []interface{}{
+			map[string]interface{}{
+				"resource": otlpServiceResource(),
+				"scopeMetrics": []interface{}{
+					map[string]interface{}{
+						"scope":   otlpScope{Name: "servin"},
+						"metrics": out,
+					},
+				},
+			},
+		},
+	}
+
+	return e.post("/v1/metrics", payload)
+}
+
+func (e *otlpExporter) ExportSpans(spans []*Span) error {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: unixNano(s.Start),
+			EndTimeUnixNano:   unixNano(s.End),
+			Attributes:        otlpAttributes(s.Attributes),
+			Status:            otlpStatus{Code: otlpStatusOK},
+		}
+		if s.Error != "" {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		out = append(out, span)
+	}
+
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": otlpServiceResource(),
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": otlpScope{Name: "servin"},
+						"spans": out,
+					},
+				},
+			},
+		},
+	}
+
+	return e.post("/v1/traces", payload)
+}
+
+func (e *otlpExporter) Close() error {
+	return nil
+}
+
+func (e *otlpExporter) post(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode OTLP payload: %v", err)
+	}
+
+	resp, err := e.client.Post(e.endpoint+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d for %s", resp.StatusCode, path)
+	}
+	return nil
+}
+
+func otlpServiceResource() otlpResource {
+	return otlpResource{Attributes: []otlpKeyValue{
+		{Key: "service.name", Value: otlpAnyValue{StringValue: "servin"}},
+	}}
+}
+
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: attrs[k]}})
+	}
+	return out
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
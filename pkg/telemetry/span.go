@@ -0,0 +1,146 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is a timed operation such as an image pull or a build step
+type Span struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Error      string
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// TraceparentEnv carries the trace context to servin's own child processes.
+// It is not TRACEPARENT, which containers inherit with the rest of the
+// environment and would join the workloads' traces to servin's.
+const TraceparentEnv = "SERVIN_TRACEPARENT"
+
+// root is the span covering the whole CLI invocation, if tracing is enabled
+var (
+	rootMu sync.RWMutex
+	root   *Span
+)
+
+// StartSpan starts a span. A nil parent attaches the span to the process root
+// span, or to the trace propagated through TraceparentEnv or TRACEPARENT;
+// without either a new trace is started.
+func StartSpan(name string, parent *Span) *Span {
+	if parent == nil {
+		parent = defaultParent()
+	}
+
+	span := &Span{
+		SpanID:     randomHex(8),
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: make(map[string]string),
+	}
+
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		span.TraceID = randomHex(16)
+	}
+
+	return span
+}
+
+// StartRootSpan starts the span for the current process and makes it the
+// default parent. Child processes inherit the trace through TraceparentEnv.
+func StartRootSpan(name string) *Span {
+	span := StartSpan(name, nil)
+
+	rootMu.Lock()
+	root = span
+	rootMu.Unlock()
+
+	os.Setenv(TraceparentEnv, span.Traceparent())
+	return span
+}
+
+// Root returns the process root span, or nil if tracing is not enabled
+func Root() *Span {
+	rootMu.RLock()
+	defer rootMu.RUnlock()
+	return root
+}
+
+// Traceparent returns the W3C trace context header value for the span
+func (s *Span) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", s.TraceID, s.SpanID)
+}
+
+// ParseTraceparent returns a remote parent span from a W3C traceparent value,
+// or nil if the value is empty or malformed
+func ParseTraceparent(value string) *Span {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return nil
+	}
+	if _, err := hex.DecodeString(parts[1] + parts[2]); err != nil {
+		return nil
+	}
+	return &Span{TraceID: parts[1], SpanID: parts[2]}
+}
+
+func defaultParent() *Span {
+	if span := Root(); span != nil {
+		return span
+	}
+	if span := ParseTraceparent(os.Getenv(TraceparentEnv)); span != nil {
+		return span
+	}
+	return ParseTraceparent(os.Getenv("TRACEPARENT"))
+}
+
+// SetAttribute attaches a key/value pair to the span
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// Finish ends the span, records err if non-nil, and exports it.
+// Calling Finish more than once has no effect.
+func (s *Span) Finish(err error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.mu.Unlock()
+
+	recordSpans([]*Span{s})
+}
+
+// Duration returns how long the span took
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
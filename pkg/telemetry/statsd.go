@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// defaultStatsDEndpoint is the standard StatsD agent address
+const defaultStatsDEndpoint = "127.0.0.1:8125"
+
+// statsdExporter sends metrics as StatsD lines over UDP, with DogStatsD-style tags.
+// Spans are reported as timers.
+type statsdExporter struct {
+	conn net.Conn
+}
+
+func newStatsDExporter(endpoint string) (Exporter, error) {
+	if endpoint == "" {
+		endpoint = defaultStatsDEndpoint
+	}
+
+	conn, err := net.Dial("udp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &statsdExporter{conn: conn}, nil
+}
+
+func (e *statsdExporter) Name() string {
+	return "statsd"
+}
+
+func (e *statsdExporter) ExportMetrics(metrics []Metric) error {
+	for _, m := range metrics {
+		kind := "g"
+		if m.Type == Counter {
+			kind = "c"
+		}
+		if err := e.send(fmt.Sprintf("servin.%s:%g|%s%s", m.Name, m.Value, kind, formatStatsDTags(m.Tags))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *statsdExporter) ExportSpans(spans []*Span) error {
+	for _, s := range spans {
+		tags := make(map[string]string, len(s.Attributes)+1)
+		for k, v := range s.Attributes {
+			tags[k] = v
+		}
+		if s.Error != "" {
+			tags["error"] = "true"
+		}
+
+		line := fmt.Sprintf("servin.span.%s:%d|ms%s", s.Name, s.Duration().Milliseconds(), formatStatsDTags(tags))
+		if err := e.send(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *statsdExporter) Close() error {
+	return e.conn.Close()
+}
+
+func (e *statsdExporter) send(line string) error {
+	_, err := e.conn.Write([]byte(line))
+	return err
+}
+
+// formatStatsDTags renders tags as "|#key:value,..." in a stable order
+func formatStatsDTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+":"+strings.ReplaceAll(v, ",", "_"))
+	}
+	sort.Strings(pairs)
+
+	return "|#" + strings.Join(pairs, ",")
+}
@@ -0,0 +1,158 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"servin/pkg/logger"
+)
+
+// Metric types
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Metric is a single runtime or container measurement
+type Metric struct {
+	Name      string
+	Type      string
+	Value     float64
+	Tags      map[string]string
+	Timestamp time.Time
+}
+
+// Exporter pushes metrics and spans to an external collector
+type Exporter interface {
+	Name() string
+	ExportMetrics(metrics []Metric) error
+	ExportSpans(spans []*Span) error
+	Close() error
+}
+
+// Factory creates an exporter for the given collector endpoint.
+// An empty endpoint selects the exporter's default.
+type Factory func(endpoint string) (Exporter, error)
+
+// Config selects the exporter and collector endpoint
+type Config struct {
+	Exporter string
+	Endpoint string
+}
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+	active    Exporter
+)
+
+func init() {
+	RegisterExporter("statsd", newStatsDExporter)
+	RegisterExporter("otlp", newOTLPExporter)
+	RegisterExporter("console", newConsoleExporter)
+	RegisterExporter("file", newFileExporter)
+}
+
+// RegisterExporter makes an exporter available by name
+func RegisterExporter(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Exporters returns the names of all registered exporters
+func Exporters() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfigFromEnv reads the exporter configuration from
+// SERVIN_TELEMETRY_EXPORTER and SERVIN_TELEMETRY_ENDPOINT
+func ConfigFromEnv() Config {
+	return Config{
+		Exporter: os.Getenv("SERVIN_TELEMETRY_EXPORTER"),
+		Endpoint: os.Getenv("SERVIN_TELEMETRY_ENDPOINT"),
+	}
+}
+
+// Init creates the configured exporter and makes it active.
+// An empty exporter name disables telemetry.
+func Init(config Config) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if active != nil {
+		active.Close()
+		active = nil
+	}
+
+	if config.Exporter == "" || config.Exporter == "none" {
+		return nil
+	}
+
+	factory, ok := factories[config.Exporter]
+	if !ok {
+		return fmt.Errorf("unknown telemetry exporter '%s'", config.Exporter)
+	}
+
+	exporter, err := factory(config.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to create %s exporter: %v", config.Exporter, err)
+	}
+
+	active = exporter
+	logger.Debug("Telemetry exporter enabled: %s", exporter.Name())
+	return nil
+}
+
+// Enabled reports whether an exporter is active
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active != nil
+}
+
+// Shutdown closes the active exporter
+func Shutdown() {
+	Init(Config{})
+}
+
+// RecordMetrics sends metrics to the active exporter, if any
+func RecordMetrics(metrics []Metric) {
+	mu.RLock()
+	exporter := active
+	mu.RUnlock()
+
+	if exporter == nil || len(metrics) == 0 {
+		return
+	}
+
+	if err := exporter.ExportMetrics(metrics); err != nil {
+		logger.Warn("Failed to export metrics via %s: %v", exporter.Name(), err)
+	}
+}
+
+// recordSpans sends finished spans to the active exporter, if any
+func recordSpans(spans []*Span) {
+	mu.RLock()
+	exporter := active
+	mu.RUnlock()
+
+	if exporter == nil || len(spans) == 0 {
+		return
+	}
+
+	if err := exporter.ExportSpans(spans); err != nil {
+		logger.Warn("Failed to export spans via %s: %v", exporter.Name(), err)
+	}
+}
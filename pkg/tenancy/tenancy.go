@@ -0,0 +1,176 @@
+// Package tenancy scopes containers and images to namespaces so that one
+// servin installation can serve isolated project- or user-scoped views.
+package tenancy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Default is the namespace used when none is selected. Objects created before
+// namespaces existed have no namespace recorded and belong to it.
+const Default = "default"
+
+// EnvVar selects the active namespace when the --namespace flag is not given
+const EnvVar = "SERVIN_NAMESPACE"
+
+// configFile persists the namespace selected with 'servin namespace use'
+const configFile = "namespace"
+
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+
+// active is the namespace selected for this process with Set
+var active string
+
+// Validate checks that name can be used as a namespace
+func Validate(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid namespace name %q: use lowercase letters, digits, '.', '_' or '-' (max 63 characters)", name)
+	}
+	return nil
+}
+
+// Normalize maps the empty namespace recorded on older objects to Default
+func Normalize(name string) string {
+	if name == "" {
+		return Default
+	}
+	return name
+}
+
+// Set selects the active namespace for this process
+func Set(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	active = name
+	return nil
+}
+
+// Unset drops the namespace selected with Set, so SERVIN_NAMESPACE and the
+// persisted selection apply again
+func Unset() {
+	active = ""
+}
+
+// Current returns the active namespace: the one selected with Set, then
+// SERVIN_NAMESPACE, then the persisted selection, then Default
+func Current() string {
+	if active != "" {
+		return active
+	}
+	if name := strings.TrimSpace(os.Getenv(EnvVar)); name != "" && Validate(name) == nil {
+		return name
+	}
+	if name := selected(); name != "" {
+		return name
+	}
+	return Default
+}
+
+// selected returns the namespace persisted with Use, if any
+func selected() string {
+	data, err := os.ReadFile(filepath.Join(rootDir(), configFile))
+	if err != nil {
+		return ""
+	}
+	if name := strings.TrimSpace(string(data)); Validate(name) == nil {
+		return name
+	}
+	return ""
+}
+
+// Use persists name as the namespace selected when no flag or environment
+// variable is given
+func Use(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rootDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create servin directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir(), configFile), []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save namespace selection: %v", err)
+	}
+	return nil
+}
+
+// List returns the known namespaces, sorted, always including Default
+func List() ([]string, error) {
+	names := []string{Default}
+
+	entries, err := os.ReadDir(namespacesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read namespaces: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != Default && Validate(entry.Name()) == nil {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Exists reports whether a namespace has been created
+func Exists(name string) bool {
+	if Normalize(name) == Default {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(namespacesDir(), name))
+	return err == nil && info.IsDir()
+}
+
+// Create registers a namespace. Creating an existing namespace is not an error.
+func Create(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	if name == Default {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(namespacesDir(), name), 0755); err != nil {
+		return fmt.Errorf("failed to create namespace '%s': %v", name, err)
+	}
+	return nil
+}
+
+// Remove unregisters a namespace. Callers must make sure it holds no objects.
+func Remove(name string) error {
+	if Normalize(name) == Default {
+		return fmt.Errorf("the default namespace cannot be removed")
+	}
+	if !Exists(name) {
+		return fmt.Errorf("namespace '%s' not found", name)
+	}
+	if err := os.RemoveAll(filepath.Join(namespacesDir(), name)); err != nil {
+		return fmt.Errorf("failed to remove namespace '%s': %v", name, err)
+	}
+
+	// Fall back to the default namespace if the removed one was selected
+	if selected() == name {
+		os.Remove(filepath.Join(rootDir(), configFile))
+	}
+	return nil
+}
+
+// rootDir returns the servin data directory for this platform
+func rootDir() string {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		homeDir, _ := os.UserHomeDir()
+		return filepath.Join(homeDir, ".servin")
+	default:
+		return "/var/lib/servin"
+	}
+}
+
+func namespacesDir() string {
+	return filepath.Join(rootDir(), "namespaces")
+}
@@ -0,0 +1,58 @@
+package terminal
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// controllingSize returns the size of the terminal servin runs on
+func controllingSize() (Size, error) {
+	size, err := GetSize(os.Stdin)
+	if err != nil {
+		size, err = GetSize(os.Stdout)
+	}
+	return size, err
+}
+
+// Connect attaches the terminal servin runs on to the pseudo-terminal
+// master: output is copied to out, input is passed through in raw mode if
+// input is set, and resizes are forwarded as they happen. The returned
+// function waits for the remaining output and restores the terminal; call
+// it once the processes on the pseudo-terminal have exited.
+func Connect(master *os.File, input bool, out io.Writer) func() {
+	var restore func()
+	if input && IsTerminal(os.Stdin) {
+		restore, _ = MakeRaw(os.Stdin)
+	}
+
+	resize := func() {
+		if size, err := controllingSize(); err == nil {
+			SetSize(master, size)
+		}
+	}
+	resize()
+	stopResize := OnResize(resize)
+
+	if input {
+		go io.Copy(master, os.Stdin)
+	}
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(out, master)
+		close(copied)
+	}()
+
+	return func() {
+		// The copy ends once every process has closed the pseudo-terminal;
+		// a background process keeping it open must not hang the session
+		select {
+		case <-copied:
+		case <-time.After(time.Second):
+		}
+		stopResize()
+		if restore != nil {
+			restore()
+		}
+	}
+}
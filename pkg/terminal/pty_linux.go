@@ -0,0 +1,65 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// OpenPTY allocates a pseudo-terminal and returns its master and slave ends
+func OpenPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %v", err)
+	}
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pseudo-terminal: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pseudo-terminal number: %v", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %v", err)
+	}
+	return master, slave, nil
+}
+
+// StartPTY starts cmd in a new session with a new pseudo-terminal, sized
+// like the terminal servin runs on, as its controlling terminal and stdio.
+// It returns the master end, which the caller closes once cmd has exited.
+func StartPTY(cmd *exec.Cmd) (*os.File, error) {
+	master, slave, err := OpenPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+
+	if size, err := controllingSize(); err == nil {
+		SetSize(master, size)
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// StartPTY starts cmd on a new pseudo-terminal. Containers only run
+// natively on Linux; elsewhere the VM allocates their terminals.
+func StartPTY(cmd *exec.Cmd) (*os.File, error) {
+	return nil, fmt.Errorf("pseudo-terminals are only supported on Linux")
+}
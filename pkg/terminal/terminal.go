@@ -0,0 +1,26 @@
+// Package terminal reads and changes the size and mode of terminals and
+// connects them to the pseudo-terminals containers run on
+package terminal
+
+import (
+	"os"
+	"strconv"
+)
+
+// Size is the size of a terminal in character cells
+type Size struct {
+	Rows uint16
+	Cols uint16
+}
+
+// Width returns the number of columns of the terminal f writes to. When f
+// is not a terminal it falls back to $COLUMNS, then to 0 for no limit.
+func Width(f *os.File) int {
+	if size, err := GetSize(f); err == nil && size.Cols > 0 {
+		return int(size.Cols)
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+)
+
+// IsTerminal reports whether f is a terminal. Windows consoles are not
+// resized or switched to raw mode by servin, so it always reports false.
+func IsTerminal(f *os.File) bool {
+	return false
+}
+
+// GetSize returns the size of the terminal f
+func GetSize(f *os.File) (Size, error) {
+	return Size{}, fmt.Errorf("terminal size is not available on this platform")
+}
+
+// SetSize resizes the terminal f
+func SetSize(f *os.File, size Size) error {
+	return fmt.Errorf("terminal size is not available on this platform")
+}
+
+// MakeRaw puts the terminal f into raw mode
+func MakeRaw(f *os.File) (func(), error) {
+	return nil, fmt.Errorf("raw terminal mode is not available on this platform")
+}
+
+// OnResize calls fn whenever the terminal is resized. Windows consoles do
+// not signal resizes, so fn is never called.
+func OnResize(fn func()) func() {
+	return func() {}
+}
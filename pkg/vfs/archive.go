@@ -0,0 +1,243 @@
+package vfs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxSymlinks bounds the symlinks followed resolving a path, as ELOOP does
+const maxSymlinks = 255
+
+// ResolvePath returns the host path of a path inside root, following the
+// symlinks along it the way the container sees them: absolute links start
+// from root and ".." never leaves it. Unless followLast is set, a symlink
+// at the end of the path is returned itself. Missing components are fine.
+func ResolvePath(root, p string, followLast bool) (string, error) {
+	rest := strings.Split(path.Clean("/"+filepath.ToSlash(p)), "/")
+	resolved := "/"
+	links := 0
+	for len(rest) > 0 {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		if len(rest) == 0 && !followLast {
+			resolved = next
+			break
+		}
+		hostPath := filepath.Join(root, filepath.FromSlash(next))
+		info, err := os.Lstat(hostPath)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", p)
+		}
+		target, err := os.Readlink(hostPath)
+		if err != nil {
+			return "", err
+		}
+		target = filepath.ToSlash(target)
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+// WriteArchive writes src, a file, directory or symlink, as a tar stream
+// whose entries are under name. Modes, modification times and symlinks are
+// kept; sockets are skipped.
+func WriteArchive(w io.Writer, src, name string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, f)
+			f.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ExtractArchive unpacks an archive written by WriteArchive to dest, a path
+// inside root, the way cp does: into dest when it is a directory, as dest
+// otherwise. A dest ending in a slash must be a directory, and the
+// directory dest is in must exist. Every path written, symlinks included,
+// is kept inside root.
+func ExtractArchive(r io.Reader, root, dest string) error {
+	tr := tar.NewReader(r)
+	header, err := tr.Next()
+	if err == io.EOF {
+		return fmt.Errorf("nothing to copy: the archive is empty")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %v", err)
+	}
+	top, _, _ := strings.Cut(strings.TrimPrefix(path.Clean("/"+header.Name), "/"), "/")
+	if top == "" {
+		return fmt.Errorf("invalid archive entry %q", header.Name)
+	}
+
+	// Work out the directory the copy goes into and its name there
+	cleanDest := path.Clean("/" + filepath.ToSlash(dest))
+	dir, name := path.Dir(cleanDest), path.Base(cleanDest)
+	destPath, err := ResolvePath(root, cleanDest, true)
+	if err != nil {
+		return err
+	}
+	info, statErr := os.Stat(destPath)
+	switch {
+	case statErr == nil && info.IsDir():
+		dir, name = cleanDest, top
+	case statErr == nil:
+		if header.Typeflag == tar.TypeDir {
+			return fmt.Errorf("cannot copy a directory to %s, a file", dest)
+		}
+	case os.IsNotExist(statErr):
+		if strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(filepath.Separator)) {
+			return fmt.Errorf("destination directory %s does not exist", dest)
+		}
+		parent, err := ResolvePath(root, dir, true)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(parent); err != nil || !info.IsDir() {
+			return fmt.Errorf("destination directory %s does not exist", path.Dir(filepath.ToSlash(dest)))
+		}
+	default:
+		return statErr
+	}
+
+	// Directories get their modes and times once their contents are written,
+	// as a read-only one could not be written to
+	type dirAttrs struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs []dirAttrs
+
+	for ; err != io.EOF; header, err = tr.Next() {
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		entry := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if entry != top && !strings.HasPrefix(entry, top+"/") {
+			return fmt.Errorf("invalid archive entry %q", header.Name)
+		}
+		target, err := ResolvePath(root, path.Join(dir, name+strings.TrimPrefix(entry, top)), false)
+		if err != nil {
+			return err
+		}
+		mode := header.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+		existing, statErr := os.Lstat(target)
+		if statErr == nil && header.Typeflag != tar.TypeDir {
+			if existing.IsDir() {
+				return fmt.Errorf("cannot overwrite directory %s with a file", target)
+			}
+			// Replace rather than write through what is there, which may
+			// be a symlink out of the destination
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if statErr == nil && !existing.IsDir() {
+				return fmt.Errorf("cannot overwrite %s with a directory", target)
+			}
+			if statErr != nil {
+				if err := os.Mkdir(target, 0700); err != nil {
+					return err
+				}
+			}
+			dirs = append(dirs, dirAttrs{target, mode, header.ModTime})
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return fmt.Errorf("failed to write %s: %v", target, err)
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+			os.Chtimes(target, header.ModTime, header.ModTime)
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %v", target, err)
+			}
+		default:
+			// Devices, fifos and hard links are not copied
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+		os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Client talks to the agent of one VM
+type Client struct {
+	// Addr is the agent's host:port as reachable from the host
+	Addr  string
+	Token string
+	// DialTimeout bounds connecting to the agent; 0 means 5 seconds
+	DialTimeout time.Duration
+}
+
+// ExecOptions describes a command run with Exec
+type ExecOptions struct {
+	Argv []string
+	Env  []string
+	// Stdin is streamed to the command when set
+	Stdin io.Reader
+	// Stdout and Stderr receive the command's output; nil discards it.
+	// With TTY set all output arrives on Stdout.
+	Stdout io.Writer
+	Stderr io.Writer
+	// TTY runs the command on a pseudo-terminal of Size in the guest,
+	// resized to every size received on Resize
+	TTY    bool
+	Size   *Size
+	Resize <-chan Size
+}
+
+// conn is a connection carrying one request
+type conn struct {
+	net.Conn
+	out *frameWriter
+}
+
+// open connects to the agent and sends req
+func (c *Client) open(req Request) (*conn, error) {
+	timeout := c.DialTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	nc, err := net.DialTimeout("tcp", c.Addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach VM agent at %s: %v", c.Addr, err)
+	}
+
+	req.Version = ProtocolVersion
+	req.Token = c.Token
+	data, err := json.Marshal(req)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	cn := &conn{Conn: nc, out: &frameWriter{w: nc}}
+	if err := cn.out.frame(frameRequest, data); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to send request to VM agent: %v", err)
+	}
+	return cn, nil
+}
+
+// wait reads frames until the result, copying output to stdout and stderr
+func (cn *conn) wait(stdout, stderr io.Writer) (*Result, error) {
+	for {
+		typ, payload, err := readFrame(cn)
+		if err != nil {
+			return nil, fmt.Errorf("lost connection to VM agent: %v", err)
+		}
+		switch typ {
+		case frameStdout:
+			if stdout != nil {
+				stdout.Write(payload)
+			}
+		case frameStderr:
+			if stderr != nil {
+				stderr.Write(payload)
+			}
+		case frameDone:
+			var result Result
+			if err := json.Unmarshal(payload, &result); err != nil {
+				return nil, fmt.Errorf("invalid result from VM agent: %v", err)
+			}
+			if result.Error != "" {
+				return &result, fmt.Errorf("VM agent: %s", result.Error)
+			}
+			return &result, nil
+		}
+	}
+}
+
+// sendStdin streams r to the agent as input frames, closing the input at
+// EOF
+func (cn *conn) sendStdin(r io.Reader) {
+	if _, err := io.Copy(cn.out.stream(frameStdin), r); err == nil {
+		cn.out.frame(frameStdin, nil)
+	}
+}
+
+// Health checks that the agent answers and returns its report
+func (c *Client) Health() (*Health, error) {
+	cn, err := c.open(Request{Op: OpHealth})
+	if err != nil {
+		return nil, err
+	}
+	defer cn.Close()
+
+	cn.SetDeadline(time.Now().Add(10 * time.Second))
+	result, err := cn.wait(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if result.Health == nil {
+		return nil, fmt.Errorf("VM agent sent no health report")
+	}
+	return result.Health, nil
+}
+
+// Exec runs a command in the guest and returns its exit code. The error is
+// set only when the command could not be run.
+func (c *Client) Exec(opts ExecOptions) (int, error) {
+	if len(opts.Argv) == 0 {
+		return -1, fmt.Errorf("no command to run in the VM")
+	}
+	cn, err := c.open(Request{
+		Op:    OpExec,
+		Argv:  opts.Argv,
+		Env:   opts.Env,
+		Stdin: opts.Stdin != nil,
+		TTY:   opts.TTY,
+		Size:  opts.Size,
+	})
+	if err != nil {
+		return -1, err
+	}
+	defer cn.Close()
+
+	if opts.Stdin != nil {
+		go cn.sendStdin(opts.Stdin)
+	}
+	if opts.Resize != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case size := <-opts.Resize:
+					if data, err := json.Marshal(size); err == nil {
+						cn.out.frame(frameResize, data)
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	result, err := cn.wait(opts.Stdout, opts.Stderr)
+	if err != nil {
+		return -1, err
+	}
+	return result.ExitCode, nil
+}
+
+// Put writes the contents of r to a file in the guest, creating its
+// directory
+func (c *Client) Put(r io.Reader, guestPath string, mode os.FileMode) error {
+	cn, err := c.open(Request{Op: OpPut, Path: guestPath, Mode: uint32(mode.Perm())})
+	if err != nil {
+		return err
+	}
+	defer cn.Close()
+
+	go cn.sendStdin(r)
+	_, err = cn.wait(nil, nil)
+	return err
+}
+
+// Get copies a file in the guest to w
+func (c *Client) Get(guestPath string, w io.Writer) error {
+	cn, err := c.open(Request{Op: OpGet, Path: guestPath})
+	if err != nil {
+		return err
+	}
+	defer cn.Close()
+
+	_, err = cn.wait(w, nil)
+	return err
+}
@@ -0,0 +1,154 @@
+// Package agent implements the protocol between servin on the host and the
+// agent servin runs inside its VMs. The host reaches the agent over a TCP
+// port the VM provider forwards to the guest. Every connection carries one
+// request, authenticated with a token generated for the VM on the host and
+// handed to the guest on its seed disc.
+package agent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the version of the protocol. The agent refuses requests
+// of another version, so host and guest binaries must be upgraded together.
+const ProtocolVersion = 1
+
+// GuestPort is the port the agent listens on in the guest
+const GuestPort = 7700
+
+// GuestTokenPath is where the guest keeps the token the agent accepts
+const GuestTokenPath = "/etc/servin/agent.token"
+
+// Operations
+const (
+	OpHealth = "health" // report the agent's version and the guest's uptime
+	OpExec   = "exec"   // run a command, streaming its stdio
+	OpPut    = "put"    // write a file in the guest
+	OpGet    = "get"    // read a file from the guest
+)
+
+// A connection is a sequence of frames: a type byte, a big-endian uint32
+// payload length and the payload. The client sends a request frame first.
+const (
+	frameRequest byte = 'R' // JSON Request
+	frameStdin   byte = 'I' // standard input or file data; empty closes it
+	frameResize  byte = 'W' // JSON Size of the command's terminal
+	frameStdout  byte = 'O' // standard output or file data
+	frameStderr  byte = 'E' // standard error
+	frameDone    byte = 'D' // JSON Result; the last frame of a connection
+)
+
+// maxFrame caps the payload of a frame
+const maxFrame = 1 << 20
+
+// Request is the first frame of a connection
+type Request struct {
+	Version int    `json:"version"`
+	Token   string `json:"token"`
+	Op      string `json:"op"`
+
+	// Exec: the command and its environment on top of the agent's. Stdin
+	// is set when the client streams input; otherwise it reads nothing.
+	Argv  []string `json:"argv,omitempty"`
+	Env   []string `json:"env,omitempty"`
+	Stdin bool     `json:"stdin,omitempty"`
+	TTY   bool     `json:"tty,omitempty"`
+	Size  *Size    `json:"size,omitempty"`
+
+	// Put and get: the file in the guest, and for put its permissions
+	Path string `json:"path,omitempty"`
+	Mode uint32 `json:"mode,omitempty"`
+}
+
+// Size is the size of a terminal in character cells
+type Size struct {
+	Rows uint16 `json:"rows"`
+	Cols uint16 `json:"cols"`
+}
+
+// Result is the last frame of a connection
+type Result struct {
+	// ExitCode is the exit status of an exec'd command
+	ExitCode int `json:"exit_code"`
+	// Error is set when the agent could not carry out the request
+	Error  string  `json:"error,omitempty"`
+	Health *Health `json:"health,omitempty"`
+}
+
+// Health is the result of a health request
+type Health struct {
+	Version  int    `json:"version"`
+	Hostname string `json:"hostname"`
+	// Uptime is how long the guest has been up, in seconds
+	Uptime float64 `json:"uptime"`
+}
+
+// writeFrame writes one frame
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	if len(payload) > maxFrame {
+		return fmt.Errorf("agent frame of %d bytes exceeds %d", len(payload), maxFrame)
+	}
+	header := make([]byte, 5, 5+len(payload))
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// readFrame reads one frame
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxFrame {
+		return 0, nil, fmt.Errorf("agent frame of %d bytes exceeds %d", n, maxFrame)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// frameWriter writes frames to a connection shared by several goroutines
+type frameWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (fw *frameWriter) frame(typ byte, payload []byte) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	return writeFrame(fw.w, typ, payload)
+}
+
+// stream returns a writer sending what is written to it as frames of typ
+func (fw *frameWriter) stream(typ byte) io.Writer {
+	return streamWriter{fw: fw, typ: typ}
+}
+
+type streamWriter struct {
+	fw  *frameWriter
+	typ byte
+}
+
+func (s streamWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxFrame {
+			chunk = chunk[:maxFrame]
+		}
+		if err := s.fw.frame(s.typ, chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
@@ -0,0 +1,240 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/logger"
+)
+
+// Server is the agent running in the guest
+type Server struct {
+	// Token is what every request must carry
+	Token string
+}
+
+// Serve answers requests on ln until it is closed
+func (s *Server) Serve(ln net.Listener) error {
+	if s.Token == "" {
+		return fmt.Errorf("agent token is empty")
+	}
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(nc)
+	}
+}
+
+// handle serves the request of one connection
+func (s *Server) handle(nc net.Conn) {
+	defer nc.Close()
+	out := &frameWriter{w: nc}
+
+	// The request must arrive promptly; the rest of the connection may
+	// last as long as the command it runs
+	nc.SetReadDeadline(time.Now().Add(10 * time.Second))
+	typ, payload, err := readFrame(nc)
+	if err != nil || typ != frameRequest {
+		return
+	}
+	nc.SetReadDeadline(time.Time{})
+
+	var req Request
+	if err := json.Unmarshal(payload, &req); err != nil {
+		finish(out, Result{Error: "invalid request"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.Token)) != 1 {
+		logger.Warn("Rejected VM agent request from %s: invalid token", nc.RemoteAddr())
+		finish(out, Result{Error: "invalid token"})
+		return
+	}
+	if req.Version != ProtocolVersion {
+		finish(out, Result{Error: fmt.Sprintf("unsupported protocol version %d (agent speaks %d)", req.Version, ProtocolVersion)})
+		return
+	}
+
+	var result Result
+	switch req.Op {
+	case OpHealth:
+		result.Health = health()
+	case OpExec:
+		result, err = s.exec(nc, out, &req)
+	case OpPut:
+		err = put(nc, &req)
+	case OpGet:
+		err = get(out, &req)
+	default:
+		err = fmt.Errorf("unknown operation %q", req.Op)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	finish(out, result)
+}
+
+// finish sends the result, the last frame of the connection
+func finish(out *frameWriter, result Result) {
+	data, _ := json.Marshal(result)
+	out.frame(frameDone, data)
+}
+
+// health reports the agent's version and how long the guest has been up
+func health() *Health {
+	h := &Health{Version: ProtocolVersion}
+	h.Hostname, _ = os.Hostname()
+	if data, err := os.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 0 {
+			h.Uptime, _ = strconv.ParseFloat(fields[0], 64)
+		}
+	}
+	return h
+}
+
+// exec runs the request's command, streaming its stdio over the connection
+func (s *Server) exec(nc net.Conn, out *frameWriter, req *Request) (Result, error) {
+	if len(req.Argv) == 0 {
+		return Result{}, fmt.Errorf("no command given")
+	}
+	cmd := exec.Command(req.Argv[0], req.Argv[1:]...)
+	cmd.Env = append(os.Environ(), req.Env...)
+
+	var stdin io.WriteCloser
+	var resize func(Size)
+	if req.TTY {
+		term, err := startTerminal(cmd, req.Size)
+		if err != nil {
+			return Result{}, err
+		}
+		defer term.Close()
+		stdin = term
+		resize = func(size Size) { resizeTerminal(term, size) }
+
+		copied := make(chan struct{})
+		go func() {
+			io.Copy(out.stream(frameStdout), term)
+			close(copied)
+		}()
+		// The copy ends once every process has closed the terminal; a
+		// background process keeping it open must not hang the session
+		defer func() {
+			select {
+			case <-copied:
+			case <-time.After(time.Second):
+			}
+		}()
+	} else {
+		cmd.Stdout = out.stream(frameStdout)
+		cmd.Stderr = out.stream(frameStderr)
+		if req.Stdin {
+			pipe, err := cmd.StdinPipe()
+			if err != nil {
+				return Result{}, err
+			}
+			stdin = pipe
+		}
+		if err := cmd.Start(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	go readInput(nc, stdin, resize)
+
+	err := cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return Result{ExitCode: exitErr.ExitCode()}, nil
+	}
+	return Result{}, err
+}
+
+// readInput applies the input and resize frames the client sends while a
+// command runs
+func readInput(nc net.Conn, stdin io.WriteCloser, resize func(Size)) {
+	for {
+		typ, payload, err := readFrame(nc)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case frameStdin:
+			if stdin == nil {
+				continue
+			}
+			if len(payload) == 0 {
+				stdin.Close()
+				stdin = nil
+				continue
+			}
+			stdin.Write(payload)
+		case frameResize:
+			var size Size
+			if resize != nil && json.Unmarshal(payload, &size) == nil {
+				resize(size)
+			}
+		}
+	}
+}
+
+// put writes the input frames to the request's file
+func put(nc net.Conn, req *Request) error {
+	if !filepath.IsAbs(req.Path) {
+		return fmt.Errorf("path %q is not absolute", req.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(req.Path), 0755); err != nil {
+		return err
+	}
+	mode := os.FileMode(req.Mode)
+	if mode == 0 {
+		mode = 0644
+	}
+	f, err := os.OpenFile(req.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	for {
+		typ, payload, err := readFrame(nc)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("transfer of %s interrupted: %v", req.Path, err)
+		}
+		if typ != frameStdin {
+			continue
+		}
+		if len(payload) == 0 {
+			break
+		}
+		if _, err := f.Write(payload); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// The mode given to OpenFile only applies to new files
+	return os.Chmod(req.Path, mode)
+}
+
+// get sends the request's file as output frames
+func get(out *frameWriter, req *Request) error {
+	f, err := os.Open(req.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(out.stream(frameStdout), f)
+	return err
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"servin/pkg/terminal"
+)
+
+// startTerminal starts cmd in a new session on a new pseudo-terminal of the
+// given size and returns its master end
+func startTerminal(cmd *exec.Cmd, size *Size) (*os.File, error) {
+	master, slave, err := terminal.OpenPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+
+	if size != nil {
+		resizeTerminal(master, *size)
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return master, nil
+}
+
+// resizeTerminal resizes the pseudo-terminal, signalling SIGWINCH to the
+// command running on it
+func resizeTerminal(master *os.File, size Size) {
+	terminal.SetSize(master, terminal.Size{Rows: size.Rows, Cols: size.Cols})
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// startTerminal is only supported in Linux guests, the only ones servin
+// creates
+func startTerminal(cmd *exec.Cmd, size *Size) (*os.File, error) {
+	return nil, fmt.Errorf("terminals are only supported in Linux guests")
+}
+
+func resizeTerminal(master *os.File, size Size) {}
@@ -0,0 +1,388 @@
+package vm
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"servin/pkg/config"
+)
+
+// upstreamAssets is the Alpine tree VM assets are downloaded from unless
+// daemon.vm_assets.mirror names a mirror of it
+const upstreamAssets = "https://dl-cdn.alpinelinux.org/alpine/"
+
+// How the digest of a cached asset is known
+const (
+	ChecksumConfigured = "configured" // daemon.vm_assets.checksums
+	ChecksumPublished  = "published"  // the .sha256 file next to the asset
+	ChecksumRecorded   = "recorded"   // taken on first download
+	ChecksumImported   = "imported"   // servin vm cache import
+)
+
+// CachedAsset is a kernel, initramfs, ISO or rootfs in the asset cache
+type CachedAsset struct {
+	// Key is the asset's path in the cache: its path in the Alpine tree
+	// under alpine/, or the host and path of its URL
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Checksum string    `json:"checksum"` // how SHA256 is known
+	Modified time.Time `json:"modified"`
+}
+
+// AssetCacheDir is the asset cache shared by all VMs, ~/.servin/cache
+func AssetCacheDir() (string, error) {
+	dir, err := servinDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cache"), nil
+}
+
+// assetKey is the path of the asset at url in the cache. Assets from the
+// Alpine tree have the same key whichever mirror they come from.
+func assetKey(url string) string {
+	if rest, ok := strings.CutPrefix(url, upstreamAssets); ok {
+		return "alpine/" + rest
+	}
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return path.Base(url)
+	}
+	return path.Join(u.Host, u.Path)
+}
+
+// mirrorURL points an upstream asset URL at mirror, a mirror of the
+// Alpine tree
+func mirrorURL(url, mirror string) string {
+	rest, ok := strings.CutPrefix(url, upstreamAssets)
+	if mirror == "" || !ok {
+		return url
+	}
+	return strings.TrimSuffix(mirror, "/") + "/" + rest
+}
+
+// checksumFile is where the digest of a cached asset is recorded
+func checksumFile(file string) string {
+	return file + ".sha256"
+}
+
+// fileSHA256 returns the hex SHA256 digest of a file
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readChecksum returns the digest recorded for a cached asset and how it
+// was known
+func readChecksum(file string) (digest, source string) {
+	data, err := os.ReadFile(checksumFile(file))
+	if err != nil {
+		return "", ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return "", ""
+	}
+	return fields[0], fields[1]
+}
+
+func writeChecksum(file, digest, source string) error {
+	return os.WriteFile(checksumFile(file), []byte(digest+" "+source+"\n"), 0644)
+}
+
+// validDigest reports whether s is a hex SHA256 digest
+func validDigest(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// publishedChecksum fetches the digest published next to an asset, as
+// Alpine does for its ISOs and root filesystems. It returns "" when there
+// is none.
+func publishedChecksum(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+".sha256", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s from %s.sha256", resp.Status, url)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 64*1024))
+	for scanner.Scan() {
+		// sha256sum format: "<digest>  <file name>"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && validDigest(strings.ToLower(fields[0])) {
+			if len(fields) == 1 || path.Base(fields[len(fields)-1]) == path.Base(url) {
+				return strings.ToLower(fields[0]), nil
+			}
+		}
+	}
+	return "", scanner.Err()
+}
+
+// verifyCached checks a cached asset against the configured digest, or the
+// one recorded when it was cached. An asset that does not match is removed.
+// Assets placed in the cache by hand have their digest recorded on first
+// use.
+func verifyCached(file, configured string) bool {
+	if _, err := os.Stat(file); err != nil {
+		return false
+	}
+	digest, err := fileSHA256(file)
+	if err != nil {
+		return false
+	}
+
+	want, source := readChecksum(file)
+	if configured != "" {
+		want, source = configured, ChecksumConfigured
+	}
+	if want == "" {
+		writeChecksum(file, digest, ChecksumRecorded)
+		return true
+	}
+	if digest != want {
+		fmt.Printf("Warning: cached %s does not match its %s SHA256 checksum; downloading it again\n", filepath.Base(file), source)
+		os.Remove(file)
+		os.Remove(checksumFile(file))
+		return false
+	}
+	if source == ChecksumConfigured {
+		writeChecksum(file, digest, source)
+	}
+	return true
+}
+
+// linkAsset puts a cached asset at dest, hard-linking it where the
+// filesystem allows
+func linkAsset(cached, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	os.Remove(dest)
+	if err := os.Link(cached, dest); err == nil {
+		return nil
+	}
+	return copyFile(cached, dest, 0644)
+}
+
+// downloadAsset puts the asset at url at dest, from the asset cache when it
+// holds a verified copy and otherwise downloading it into the cache first.
+// Downloads come from daemon.vm_assets.mirror when one is set, and are
+// checked against the configured or published SHA256 digest. Offline, only
+// the cache is used.
+func downloadAsset(vmConfig *VMConfig, asset, url, dest string) error {
+	var opts DownloadOptions
+	if vmConfig != nil {
+		opts = vmConfig.Downloads
+	}
+	if opts.Progress == nil {
+		opts.Progress = defaultProgress
+	}
+	cfg, _, err := config.Load()
+	if err != nil {
+		return err
+	}
+	settings := cfg.Daemon.VMAssets
+
+	cacheDir, err := AssetCacheDir()
+	if err != nil {
+		return err
+	}
+	key := assetKey(url)
+	cached := filepath.Join(cacheDir, filepath.FromSlash(key))
+	configured := strings.ToLower(settings.Checksums[key])
+	if configured != "" && !validDigest(configured) {
+		return fmt.Errorf("daemon.vm_assets.checksums: %s is not a SHA256 digest", key)
+	}
+
+	if verifyCached(cached, configured) {
+		if info, err := os.Stat(cached); err == nil && opts.Progress != nil {
+			opts.Progress(DownloadEvent{Asset: asset, URL: url, State: DownloadCached, Downloaded: info.Size(), Total: info.Size()})
+		}
+		return linkAsset(cached, dest)
+	}
+	if opts.Offline || settings.Offline {
+		return fmt.Errorf("offline and %s is not in the cache: import it with 'servin vm cache import FILE %s'", asset, key)
+	}
+
+	source := mirrorURL(url, settings.Mirror)
+	want, how := configured, ChecksumConfigured
+	if want == "" {
+		ctx := opts.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if want, err = publishedChecksum(ctx, source); err != nil {
+			return fmt.Errorf("failed to get the checksum of %s: %v", asset, err)
+		}
+		how = ChecksumPublished
+	}
+
+	if err := fetchAsset(opts, asset, source, cached); err != nil {
+		return err
+	}
+	digest, err := fileSHA256(cached)
+	if err != nil {
+		return err
+	}
+	if want == "" {
+		want, how = digest, ChecksumRecorded
+	}
+	if digest != want {
+		os.Remove(cached)
+		return fmt.Errorf("%s from %s failed verification: SHA256 %s, expected %s", asset, source, digest, want)
+	}
+	if err := writeChecksum(cached, digest, how); err != nil {
+		return err
+	}
+	return linkAsset(cached, dest)
+}
+
+// ImportAsset copies file into the asset cache under key, so VMs can be
+// created offline. The file is checked against the configured digest, if
+// any.
+func ImportAsset(file, key string) (*CachedAsset, error) {
+	key = path.Clean(strings.TrimPrefix(filepath.ToSlash(key), "/"))
+	if key == "." || strings.HasPrefix(key, "../") || strings.HasSuffix(key, ".sha256") {
+		return nil, fmt.Errorf("invalid cache path %q", key)
+	}
+	cfg, _, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	cacheDir, err := AssetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := fileSHA256(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	how := ChecksumImported
+	if want := strings.ToLower(cfg.Daemon.VMAssets.Checksums[key]); want != "" {
+		if digest != want {
+			return nil, fmt.Errorf("%s does not match the configured checksum of %s: SHA256 %s, expected %s", file, key, digest, want)
+		}
+		how = ChecksumConfigured
+	}
+
+	cached := filepath.Join(cacheDir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+		return nil, err
+	}
+	// VMs hard-link cached assets, so the new file replaces the old one
+	// instead of overwriting it
+	tmp := cached + ".download"
+	if err := copyFile(file, tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to import %s: %v", file, err)
+	}
+	if err := os.Rename(tmp, cached); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to import %s: %v", file, err)
+	}
+	if err := writeChecksum(cached, digest, how); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(cached)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedAsset{Key: key, Size: info.Size(), SHA256: digest, Checksum: how, Modified: info.ModTime()}, nil
+}
+
+// ListCachedAssets returns the assets in the cache, sorted by key
+func ListCachedAssets() ([]CachedAsset, error) {
+	cacheDir, err := AssetCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []CachedAsset
+	err = filepath.WalkDir(cacheDir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(file, ".sha256") || strings.HasSuffix(file, ".download") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(cacheDir, file)
+		if err != nil {
+			return err
+		}
+		digest, how := readChecksum(file)
+		assets = append(assets, CachedAsset{Key: filepath.ToSlash(rel), Size: info.Size(), SHA256: digest, Checksum: how, Modified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset cache: %v", err)
+	}
+
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Key < assets[j].Key })
+	return assets, nil
+}
+
+// ClearAssetCache empties the asset cache and returns the bytes freed.
+// VMs keep the assets they were created from.
+func ClearAssetCache() (int64, error) {
+	assets, err := ListCachedAssets()
+	if err != nil {
+		return 0, err
+	}
+	cacheDir, err := AssetCacheDir()
+	if err != nil {
+		return 0, err
+	}
+
+	var freed int64
+	for _, a := range assets {
+		freed += a.Size
+	}
+	if err := os.RemoveAll(cacheDir); err != nil {
+		return 0, fmt.Errorf("failed to clear asset cache: %v", err)
+	}
+	return freed, nil
+}
@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"servin/pkg/terminal"
+	"servin/pkg/vm/agent"
+)
+
+// ConsoleProvider is implemented by providers that give access to the
+// serial console of their VM, to debug a guest whose agent does not come up
+type ConsoleProvider interface {
+	// OpenConsole connects to the console of the running VM
+	OpenConsole() (io.ReadWriteCloser, error)
+	// ConsoleLog is the file the console output is logged to, or "" when
+	// it is not logged
+	ConsoleLog() string
+}
+
+// ShellProvider is implemented by providers that run a shell in the guest
+type ShellProvider interface {
+	Shell(command []string) error
+}
+
+// ErrConsoleReadOnly is returned by OpenConsole when the console output can
+// only be followed through its log
+var ErrConsoleReadOnly = errors.New("the VM console can only be followed through its log")
+
+// consoleSetupScript is the part of the guest setup that runs a login
+// prompt on the serial consoles, so 'servin vm console' can log in
+const consoleSetupScript = `
+# Log in on the serial console with 'servin vm console'
+for tty in ttyS0 hvc0; do
+    [ -c /dev/$tty ] || continue
+    grep -q "^$tty:" /etc/inittab || echo "$tty::respawn:/sbin/getty -L 115200 $tty vt100" >> /etc/inittab
+    grep -qx "$tty" /etc/securetty 2>/dev/null || echo "$tty" >> /etc/securetty
+done
+kill -HUP 1 2>/dev/null || true
+`
+
+// qemuConsole serves the serial console of a QEMU VM on a Unix socket,
+// logging its output
+type qemuConsole struct {
+	socket string
+	log    string
+}
+
+// args are the QEMU arguments connecting the serial port to the socket
+func (c qemuConsole) args() []string {
+	return []string{
+		"-chardev", fmt.Sprintf("socket,id=console,path=%s,server=on,wait=off,logfile=%s,logappend=on", c.socket, c.log),
+		"-serial", "chardev:console",
+	}
+}
+
+func (c qemuConsole) open() (io.ReadWriteCloser, error) {
+	conn, err := net.Dial("unix", c.socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the VM console: %v", err)
+	}
+	return conn, nil
+}
+
+// Shell runs command in the guest, or a login shell without one, with a
+// terminal when standard input is one
+func (o guestOps) Shell(command []string) error {
+	if !o.up() {
+		return fmt.Errorf("VM is not running")
+	}
+	if len(command) == 0 {
+		command = []string{"/bin/sh", "-l"}
+	}
+
+	var env []string
+	if term := os.Getenv("TERM"); term != "" {
+		env = append(env, "TERM="+term)
+	}
+	return o.attach(agent.ExecOptions{Argv: command, Env: env}, true, terminal.IsTerminal(os.Stdin))
+}
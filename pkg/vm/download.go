@@ -0,0 +1,164 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"servin/pkg/ratelimit"
+)
+
+// Download states reported in DownloadEvent.State
+const (
+	DownloadStarted   = "started"
+	DownloadProgress  = "progress"
+	DownloadDone      = "done"
+	DownloadFailed    = "failed"
+	DownloadCancelled = "cancelled"
+	// DownloadCached is sent instead of a download when the asset is
+	// already in the cache
+	DownloadCached = "cached"
+)
+
+// downloadEventInterval is how often progress events are sent while an
+// asset downloads
+const downloadEventInterval = 250 * time.Millisecond
+
+// DownloadEvent reports the progress of downloading a VM asset (kernel,
+// initramfs, ISO or rootfs)
+type DownloadEvent struct {
+	Asset      string `json:"asset"`
+	URL        string `json:"url"`
+	State      string `json:"state"`
+	Downloaded int64  `json:"downloaded"`
+	// Total is -1 when the server does not send the size
+	Total          int64   `json:"total"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// DownloadOptions controls the asset downloads of a VM provider
+type DownloadOptions struct {
+	// Context cancels downloads when it is done
+	Context context.Context
+	// Progress receives an event when a download starts and ends, and
+	// periodically while it runs
+	Progress func(DownloadEvent)
+	// LimitRate limits the download rate in bytes per second (0 for no limit)
+	LimitRate int64
+	// Offline only uses assets already in the cache, as does
+	// daemon.vm_assets.offline in config.yaml
+	Offline bool
+}
+
+// defaultProgress reports the downloads of VMs created without a reporter,
+// such as one provisioned by the first container command
+var defaultProgress func(DownloadEvent)
+
+// SetDefaultProgress sets the reporter of downloads made without one
+func SetDefaultProgress(progress func(DownloadEvent)) {
+	defaultProgress = progress
+}
+
+// fetchAsset downloads url to dest, reporting progress through opts. The
+// file is written next to dest and only renamed into place once complete,
+// so a cancelled or failed download leaves nothing behind.
+func fetchAsset(opts DownloadOptions, asset, url, dest string) (err error) {
+	ctx := context.Background()
+	if opts.Context != nil {
+		ctx = opts.Context
+	}
+
+	event := DownloadEvent{Asset: asset, URL: url, State: DownloadStarted, Total: -1}
+	report := func() {
+		if opts.Progress != nil {
+			opts.Progress(event)
+		}
+	}
+	defer func() {
+		switch {
+		case err == nil:
+			event.State = DownloadDone
+		case ctx.Err() != nil:
+			event.State = DownloadCancelled
+			err = fmt.Errorf("download of %s cancelled", asset)
+		default:
+			event.State = DownloadFailed
+		}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		report()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	if resp.ContentLength >= 0 {
+		event.Total = resp.ContentLength
+	}
+	report()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".download"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	body := ratelimit.Reader(ctx, resp.Body, ratelimit.New(opts.LimitRate))
+	start := time.Now()
+	lastReport := start
+	event.State = DownloadProgress
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := file.Write(buf[:n]); err != nil {
+				file.Close()
+				return err
+			}
+			event.Downloaded += int64(n)
+		}
+
+		now := time.Now()
+		if elapsed := now.Sub(start).Seconds(); elapsed > 0 {
+			event.BytesPerSecond = float64(event.Downloaded) / elapsed
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			file.Close()
+			return readErr
+		}
+		if now.Sub(lastReport) >= downloadEventInterval {
+			lastReport = now
+			report()
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if event.Total >= 0 && event.Downloaded != event.Total {
+		return fmt.Errorf("download of %s incomplete: got %d of %d bytes", asset, event.Downloaded, event.Total)
+	}
+	return os.Rename(tmp, dest)
+}
@@ -0,0 +1,462 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/stats"
+	"servin/pkg/terminal"
+	"servin/pkg/vm/agent"
+)
+
+// guestServinPath is where the servin binary is installed in the guest
+const guestServinPath = "/usr/local/bin/servin"
+
+// guest is how a provider reaches the operating system inside its VM.
+// Every backend talks to the servin agent in the guest except WSL2, which
+// runs commands in its distribution with wsl.exe.
+type guest interface {
+	// exec runs a command in the guest and returns its exit code
+	exec(opts agent.ExecOptions) (int, error)
+	copyTo(hostPath, vmPath string) error
+	copyFrom(vmPath, hostPath string) error
+}
+
+// agentGuest reaches the guest through its servin agent
+type agentGuest struct {
+	vmPath string // VM state directory, holding the agent token
+	// addr returns the agent's address as reachable from the host
+	addr func() (string, error)
+}
+
+// localAgent returns the guest of a VM whose agent port is forwarded to
+// port on localhost
+func localAgent(vmPath string, port int) *agentGuest {
+	return &agentGuest{
+		vmPath: vmPath,
+		addr: func() (string, error) {
+			return net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), nil
+		},
+	}
+}
+
+// client returns a client for the agent
+func (g *agentGuest) client() (*agent.Client, error) {
+	addr, err := g.addr()
+	if err != nil {
+		return nil, err
+	}
+	token, err := agentToken(g.vmPath)
+	if err != nil {
+		return nil, err
+	}
+	return &agent.Client{Addr: addr, Token: token}, nil
+}
+
+func (g *agentGuest) exec(opts agent.ExecOptions) (int, error) {
+	c, err := g.client()
+	if err != nil {
+		return -1, err
+	}
+	return c.Exec(opts)
+}
+
+func (g *agentGuest) copyTo(hostPath, vmPath string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(hostPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return c.Put(f, vmPath, info.Mode())
+}
+
+func (g *agentGuest) copyFrom(vmPath, hostPath string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(hostPath)
+	if err != nil {
+		return err
+	}
+	if err := c.Get(vmPath, f); err != nil {
+		f.Close()
+		os.Remove(hostPath)
+		return err
+	}
+	return f.Close()
+}
+
+// health returns the agent's report, failing fast while the guest boots
+func (g *agentGuest) health() (*agent.Health, error) {
+	c, err := g.client()
+	if err != nil {
+		return nil, err
+	}
+	c.DialTimeout = 2 * time.Second
+	return c.Health()
+}
+
+// reachable reports whether the agent answers yet
+func (g *agentGuest) reachable() bool {
+	_, err := g.health()
+	return err == nil
+}
+
+// uptime returns how long the guest has been up, or "" when the agent does
+// not answer
+func (g *agentGuest) uptime() string {
+	h, err := g.health()
+	if err != nil {
+		return ""
+	}
+	return (time.Duration(h.Uptime) * time.Second).String()
+}
+
+// waitReady waits up to maxWait for the agent of a booting VM to answer
+func (g *agentGuest) waitReady(maxWait time.Duration) bool {
+	start := time.Now()
+	for time.Since(start) < maxWait {
+		if g.reachable() {
+			fmt.Println("✅ VM agent is ready!")
+			return true
+		}
+		time.Sleep(2 * time.Second)
+	}
+
+	fmt.Println("⚠️ VM agent did not answer in time - check the VM console")
+	return false
+}
+
+// findServinBinary returns the servin binary to install in the guest: a
+// Linux build next to the working directory or the build output
+func findServinBinary() (string, error) {
+	for _, path := range []string{"./servin", "build/servin", "build/linux/servin", "/usr/local/bin/servin"} {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("servin binary not found")
+}
+
+// deployServin copies the servin binary into a guest that has no seed disc
+func deployServin(g guest) error {
+	binary, err := findServinBinary()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("📦 Deploying Servin to VM...")
+	if err := g.copyTo(binary, guestServinPath); err != nil {
+		return fmt.Errorf("failed to copy binary: %v", err)
+	}
+	if code, err := g.exec(agent.ExecOptions{Argv: []string{"chmod", "+x", guestServinPath}}); err != nil || code != 0 {
+		return fmt.Errorf("failed to make binary executable: %v", exitError(code, err))
+	}
+
+	fmt.Println("✅ Servin deployed to VM")
+	return nil
+}
+
+// exitError returns err, or an error for a non-zero exit code
+func exitError(code int, err error) error {
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("exit status %d", code)
+	}
+	return nil
+}
+
+// guestOps implements the VMProvider operations that only talk to the
+// guest. Providers embed it, so a new backend only implements the VM
+// lifecycle and how its guest is reached.
+type guestOps struct {
+	guest guest
+	// up reports whether the VM is running; guest operations fail when not
+	up func() bool
+}
+
+// guestRun runs a command in the guest
+func (o guestOps) guestRun(argv ...string) error {
+	if !o.up() {
+		return fmt.Errorf("VM is not running")
+	}
+	return exitError(o.guest.exec(agent.ExecOptions{Argv: argv}))
+}
+
+// guestOutput runs a command in the guest and returns its standard output
+func (o guestOps) guestOutput(argv ...string) ([]byte, error) {
+	if !o.up() {
+		return nil, fmt.Errorf("VM is not running")
+	}
+	var stdout bytes.Buffer
+	err := exitError(o.guest.exec(agent.ExecOptions{Argv: argv, Stdout: &stdout}))
+	return stdout.Bytes(), err
+}
+
+// RunContainer runs a container in the VM with the guest's servin
+func (o guestOps) RunContainer(config *ContainerConfig) (*ContainerResult, error) {
+	if !o.up() {
+		return nil, fmt.Errorf("VM is not running")
+	}
+
+	var output bytes.Buffer
+	err := exitError(o.guest.exec(agent.ExecOptions{Argv: guestRunArgs(config), Stdout: &output, Stderr: &output}))
+	result := &ContainerResult{
+		Name:   config.Name,
+		Output: output.String(),
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = 1
+	} else {
+		result.Status = "running"
+		result.ExitCode = 0
+		// The container ID is the last line of the output
+		if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) > 0 {
+			result.ID = strings.TrimSpace(lines[len(lines)-1])
+		}
+	}
+
+	return result, nil
+}
+
+// ListContainers lists the containers in the VM
+func (o guestOps) ListContainers() ([]*ContainerInfo, error) {
+	output, err := o.guestOutput(guestServinPath, "list")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %v", err)
+	}
+	return parseGuestContainerList(string(output)), nil
+}
+
+// StopContainer stops a container in the VM
+func (o guestOps) StopContainer(id string) error {
+	return o.guestRun(guestServinPath, "stop", id)
+}
+
+// RemoveContainer removes a container in the VM
+func (o guestOps) RemoveContainer(id string) error {
+	return o.guestRun(guestServinPath, "remove", id)
+}
+
+// ContainerStats reports resource usage for containers running in the VM
+func (o guestOps) ContainerStats(ids []string) ([]*stats.Stats, error) {
+	output, err := o.guestOutput(guestStatsArgs(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats from VM: %v", err)
+	}
+	return decodeGuestStats(output)
+}
+
+// ContainerTop lists the processes of a container running in the VM
+func (o guestOps) ContainerTop(id string) ([]byte, error) {
+	output, err := o.guestOutput(guestTopArgs(id)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list container processes in VM: %v", err)
+	}
+	return output, nil
+}
+
+// ContainerExec runs a command in a container in the VM, attached to stdio.
+// With tty set the guest terminal follows resizes of this one.
+func (o guestOps) ContainerExec(id string, command []string, interactive, tty bool) error {
+	if !o.up() {
+		return fmt.Errorf("VM is not running")
+	}
+
+	return o.attach(agent.ExecOptions{Argv: guestExecArgs(id, command, interactive, tty)}, interactive, tty)
+}
+
+// attach runs a command in the guest connected to the terminal: its output
+// goes to standard output and error, standard input is streamed to it when
+// interactive, and with tty it runs on a pseudo-terminal kept the size of
+// the local one
+func (o guestOps) attach(opts agent.ExecOptions, interactive, tty bool) error {
+	opts.Stdout = os.Stdout
+	opts.Stderr = os.Stderr
+	opts.TTY = tty
+	if interactive {
+		opts.Stdin = os.Stdin
+	}
+	if tty {
+		if interactive && terminal.IsTerminal(os.Stdin) {
+			if restore, err := terminal.MakeRaw(os.Stdin); err == nil {
+				defer restore()
+			}
+		}
+		if size, err := terminal.GetSize(os.Stdin); err == nil {
+			opts.Size = &agent.Size{Rows: size.Rows, Cols: size.Cols}
+		}
+		resize := make(chan agent.Size, 1)
+		stop := terminal.OnResize(func() {
+			if size, err := terminal.GetSize(os.Stdin); err == nil {
+				select {
+				case resize <- agent.Size{Rows: size.Rows, Cols: size.Cols}:
+				default:
+				}
+			}
+		})
+		defer stop()
+		opts.Resize = resize
+	}
+
+	return exitError(o.guest.exec(opts))
+}
+
+// CopyToContainer unpacks an archive into a container in the VM with the
+// guest's servin
+func (o guestOps) CopyToContainer(id, dest string, archive io.Reader) error {
+	if !o.up() {
+		return fmt.Errorf("VM is not running")
+	}
+	var stderr bytes.Buffer
+	err := exitError(o.guest.exec(agent.ExecOptions{Argv: guestCopyToArgs(id, dest), Stdin: archive, Stderr: &stderr}))
+	return guestCommandError(err, &stderr)
+}
+
+// CopyFromContainer writes an archive of a path in a container in the VM
+func (o guestOps) CopyFromContainer(id, src string, followLink bool, archive io.Writer) error {
+	if !o.up() {
+		return fmt.Errorf("VM is not running")
+	}
+	var stderr bytes.Buffer
+	err := exitError(o.guest.exec(agent.ExecOptions{Argv: guestCopyFromArgs(id, src, followLink), Stdout: archive, Stderr: &stderr}))
+	return guestCommandError(err, &stderr)
+}
+
+// guestCommandError returns the error a guest command reported on stderr,
+// if it failed
+func guestCommandError(err error, stderr *bytes.Buffer) error {
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%s", strings.TrimPrefix(msg, "Error: "))
+	}
+	return err
+}
+
+// CopyToVM copies a file from host to VM
+func (o guestOps) CopyToVM(hostPath, vmPath string) error {
+	if !o.up() {
+		return fmt.Errorf("VM is not running")
+	}
+	return o.guest.copyTo(hostPath, vmPath)
+}
+
+// CopyFromVM copies a file from VM to host
+func (o guestOps) CopyFromVM(vmPath, hostPath string) error {
+	if !o.up() {
+		return fmt.Errorf("VM is not running")
+	}
+	return o.guest.copyFrom(vmPath, hostPath)
+}
+
+// ForwardPort forwards a port from host to VM. Ports are forwarded when
+// the VM starts; dynamic forwarding is not supported yet.
+func (o guestOps) ForwardPort(hostPort, vmPort int) error {
+	return fmt.Errorf("dynamic port forwarding not implemented, configure during VM start")
+}
+
+// RemovePortForward removes a port forward
+func (o guestOps) RemovePortForward(hostPort int) error {
+	return fmt.Errorf("dynamic port forwarding not implemented")
+}
+
+// guestRunArgs builds the servin run command for a container
+func guestRunArgs(config *ContainerConfig) []string {
+	parts := []string{guestServinPath, "run"}
+
+	if config.Name != "" {
+		parts = append(parts, "--name", config.Name)
+	}
+	for hostPort, containerPort := range config.Ports {
+		parts = append(parts, "-p", fmt.Sprintf("%s:%s", hostPort, containerPort))
+	}
+	for hostPath, containerPath := range config.Volumes {
+		parts = append(parts, "-v", fmt.Sprintf("%s:%s", hostPath, containerPath))
+	}
+	for key, value := range config.Environment {
+		parts = append(parts, "-e", fmt.Sprintf("%s=%s", key, value))
+	}
+	if config.WorkDir != "" {
+		parts = append(parts, "-w", config.WorkDir)
+	}
+	if config.Detached {
+		parts = append(parts, "-d")
+	}
+
+	parts = append(parts, config.Image)
+	return append(parts, config.Command...)
+}
+
+// parseGuestContainerList parses the output of servin list in the guest
+func parseGuestContainerList(output string) []*ContainerInfo {
+	var containers []*ContainerInfo
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if strings.Contains(line, "CONTAINER") || line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 4 {
+			container := &ContainerInfo{
+				ID:     fields[0],
+				Name:   fields[1],
+				Image:  fields[2],
+				Status: fields[3],
+			}
+			if len(fields) >= 5 {
+				container.Created = fields[4]
+			}
+			containers = append(containers, container)
+		}
+	}
+	return containers
+}
+
+// isPortAvailable checks if a port is available for use
+func isPortAvailable(port int) bool {
+	conn, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// pickAgentPort returns the configured agent port, or the first free port
+// from DefaultAgentPort on when it is taken
+func pickAgentPort(port int) int {
+	if port == 0 {
+		port = DefaultAgentPort
+	}
+	if isPortAvailable(port) {
+		return port
+	}
+	for candidate := DefaultAgentPort; candidate < DefaultAgentPort+100; candidate++ {
+		if isPortAvailable(candidate) {
+			return candidate
+		}
+	}
+	return port
+}
@@ -0,0 +1,226 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultVMName is the VM used when none is selected
+const DefaultVMName = "servin-vm"
+
+// NameEnvVar selects the VM for a single command or shell
+const NameEnvVar = "SERVIN_VM_NAME"
+
+// ProjectFile names the VM a project uses. The nearest one in the working
+// directory or its parents selects the VM for commands run in the project.
+const ProjectFile = ".servin-vm"
+
+// Where the VM in use was selected
+const (
+	SelectedByEnv     = "env"     // SERVIN_VM_NAME
+	SelectedByProject = "project" // a .servin-vm file
+	SelectedByUse     = "use"     // servin vm use
+	SelectedByDefault = "default"
+)
+
+// currentVMFile persists the VM selected with 'servin vm use'
+const currentVMFile = "current-vm"
+
+var vmNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+
+// ValidateVMName checks that a VM name is usable with every backend, which
+// name the hypervisor's VM, or the WSL2 distribution, after it
+func ValidateVMName(name string) error {
+	if !vmNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid VM name %q: use lowercase letters, digits, '.', '_' or '-' (max 63 characters)", name)
+	}
+	return nil
+}
+
+// servinDir is ~/.servin, which holds the VMs and their configurations
+func servinDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".servin"), nil
+}
+
+// configPath is the configuration of the named VM. It is kept next to the
+// VM's directory rather than in it, so destroying the VM keeps its
+// resources for when it is created again.
+func configPath(name string) (string, error) {
+	dir, err := servinDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vms", name+".json"), nil
+}
+
+// CurrentVM returns the name of the VM in use and what selected it:
+// SERVIN_VM_NAME, then the nearest .servin-vm file, then 'servin vm use',
+// then DefaultVMName
+func CurrentVM() (name, selectedBy string) {
+	if name := strings.TrimSpace(os.Getenv(NameEnvVar)); name != "" && ValidateVMName(name) == nil {
+		return name, SelectedByEnv
+	}
+	if name, _ := ProjectVM(); name != "" {
+		return name, SelectedByProject
+	}
+	if dir, err := servinDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(dir, currentVMFile)); err == nil {
+			if name := strings.TrimSpace(string(data)); ValidateVMName(name) == nil {
+				return name, SelectedByUse
+			}
+		}
+	}
+	return DefaultVMName, SelectedByDefault
+}
+
+// ProjectVM returns the VM named by the nearest .servin-vm file in the
+// working directory or its parents, and the file's path
+func ProjectVM() (name, path string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if data, err := os.ReadFile(path); err == nil {
+			if name := strings.TrimSpace(string(data)); ValidateVMName(name) == nil {
+				return name, path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// UseVM persists name as the VM used when no environment variable or
+// project file selects one
+func UseVM(name string) error {
+	if err := ValidateVMName(name); err != nil {
+		return err
+	}
+	dir, err := servinDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create servin directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, currentVMFile), []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save VM selection: %v", err)
+	}
+	return nil
+}
+
+// UseVMInProject writes a .servin-vm file selecting name for commands run
+// in dir and its subdirectories
+func UseVMInProject(dir, name string) (string, error) {
+	if err := ValidateVMName(name); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, ProjectFile)
+	if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return path, nil
+}
+
+// VMExists reports whether a VM has been created with 'servin vm create'.
+// The default VM always exists.
+func VMExists(name string) bool {
+	if name == DefaultVMName {
+		return true
+	}
+	path, err := configPath(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// LoadVMConfig returns the configuration of the named VM: the defaults,
+// with what its configuration file sets. The default VM also reads the
+// ~/.servin/vm-config.json of earlier versions.
+func LoadVMConfig(name string) (*VMConfig, error) {
+	config := DefaultVMConfig(name)
+
+	path, err := configPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && name == DefaultVMName {
+		path = filepath.Join(filepath.Dir(filepath.Dir(path)), "vm-config.json")
+		data, err = os.ReadFile(path)
+	}
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VM config: %v", err)
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	config.Name = name
+	return config, nil
+}
+
+// SaveVMConfig writes the configuration of a VM, so it is used from then on
+func SaveVMConfig(config *VMConfig) error {
+	if err := ValidateVMName(config.Name); err != nil {
+		return err
+	}
+	path, err := configPath(config.Name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal VM config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save VM config: %v", err)
+	}
+	return nil
+}
+
+// ListVMs returns the names of the created VMs, sorted, always including
+// the default VM
+func ListVMs() ([]string, error) {
+	names := []string{DefaultVMName}
+
+	dir, err := servinDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "vms"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read VMs: %v", err)
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if ok && !entry.IsDir() && name != DefaultVMName && ValidateVMName(name) == nil {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -4,24 +4,44 @@ package vm
 
 import (
 	"fmt"
-	"net"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
+
+	"servin/pkg/vm/agent"
 )
 
 // KVMProvider implements VM operations using Linux KVM/QEMU
 type KVMProvider struct {
-	config  *VMConfig
-	vmPath  string
-	sshPort int
-	running bool
-	qemuCmd *exec.Cmd
-	qemuPid int
+	guestOps
+	config    *VMConfig
+	vmPath    string
+	agentPort int
+	agent     *agentGuest
+	machine   qemuMachine
+	snapshots qemuSnapshots
+	console   qemuConsole
+}
+
+func init() {
+	RegisterBackend(Backend{
+		Name:         "kvm",
+		Description:  "KVM (Kernel-based Virtual Machine) with QEMU",
+		Platforms:    []string{"linux"},
+		Priority:     1,
+		Acceleration: "hardware",
+		Available: func() error {
+			if !kvmAvailable() {
+				return fmt.Errorf("/dev/kvm is missing or not accessible")
+			}
+			return nil
+		},
+		New: NewKVMProvider,
+	})
 }
 
 // NewKVMProvider creates a new KVM provider
@@ -32,43 +52,32 @@ func NewKVMProvider(config *VMConfig) (VMProvider, error) {
 	}
 
 	vmPath := filepath.Join(homeDir, ".servin", "vms", config.Name)
-
-	// Find available SSH port
-	sshPort := config.SSHPort
-	if sshPort == 0 {
-		sshPort = 2222
-	}
-	if !isPortAvailable(sshPort) {
-		for port := 2222; port <= 2299; port++ {
-			if isPortAvailable(port) {
-				sshPort = port
-				break
-			}
-		}
-	}
-
-	return &KVMProvider{
-		config:  config,
-		vmPath:  vmPath,
-		sshPort: sshPort,
-		running: false,
-	}, nil
-}
-
-// isPortAvailable checks if a port is available for use
-func isPortAvailable(port int) bool {
-	conn, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
-		return false
+	agentPort := pickAgentPort(config.AgentPort)
+
+	p := &KVMProvider{
+		config:    config,
+		vmPath:    vmPath,
+		agentPort: agentPort,
+		agent:     localAgent(vmPath, agentPort),
+		machine:   newQEMUMachine(vmPath),
+		snapshots: qemuSnapshots{
+			name:    config.Name,
+			disk:    filepath.Join(vmPath, "disk.qcow2"),
+			monitor: filepath.Join(vmPath, "monitor.sock"),
+		},
+		console: qemuConsole{
+			socket: filepath.Join(vmPath, "console.sock"),
+			log:    filepath.Join(vmPath, "console.log"),
+		},
 	}
-	conn.Close()
-	return true
+	p.guestOps = guestOps{guest: p.agent, up: p.IsRunning}
+	return p, nil
 }
 
 // Create creates a new VM using KVM/QEMU with automated Alpine Linux setup
 func (p *KVMProvider) Create(config *VMConfig) error {
 	// Check if KVM is available
-	if !p.isKVMAvailable() {
+	if !kvmAvailable() {
 		return fmt.Errorf("KVM is not available on this system. Enable virtualization in BIOS and load kvm modules")
 	}
 
@@ -80,7 +89,7 @@ func (p *KVMProvider) Create(config *VMConfig) error {
 	return p.createKVMVM(config)
 }
 
-// createKVMVM creates a KVM VM with Alpine Linux and automated SSH setup
+// createKVMVM creates a KVM VM with Alpine Linux and the servin agent
 func (p *KVMProvider) createKVMVM(config *VMConfig) error {
 	fmt.Println("Setting up KVM VM with Alpine Linux...")
 
@@ -89,7 +98,7 @@ func (p *KVMProvider) createKVMVM(config *VMConfig) error {
 		return fmt.Errorf("failed to download Alpine kernel: %v", err)
 	}
 
-	// Create cloud-init ISO with automated SSH setup
+	// Create the seed ISO installing the servin agent
 	if err := p.createCloudInitISO(); err != nil {
 		return fmt.Errorf("failed to create cloud-init ISO: %v", err)
 	}
@@ -128,13 +137,13 @@ func (p *KVMProvider) downloadAlpineKernel() error {
 
 	// Download kernel
 	kernelURL := fmt.Sprintf("%s/vmlinuz-virt", baseURL)
-	if err := downloadFile(kernelURL, kernelPath); err != nil {
+	if err := downloadAsset(p.config, "kernel", kernelURL, kernelPath); err != nil {
 		return fmt.Errorf("failed to download kernel: %v", err)
 	}
 
 	// Download initramfs
 	initramfsURL := fmt.Sprintf("%s/initramfs-virt", baseURL)
-	if err := downloadFile(initramfsURL, initramfsPath); err != nil {
+	if err := downloadAsset(p.config, "initramfs", initramfsURL, initramfsPath); err != nil {
 		return fmt.Errorf("failed to download initramfs: %v", err)
 	}
 
@@ -142,7 +151,8 @@ func (p *KVMProvider) downloadAlpineKernel() error {
 	return nil
 }
 
-// createCloudInitISO creates a cloud-init ISO with automated SSH setup
+// createCloudInitISO creates the seed ISO: cloud-init data running the
+// setup script that installs the servin agent
 func (p *KVMProvider) createCloudInitISO() error {
 	isoPath := filepath.Join(p.vmPath, "cloud-init.iso")
 	tempDir := filepath.Join(p.vmPath, "cloud-init-temp")
@@ -154,68 +164,18 @@ func (p *KVMProvider) createCloudInitISO() error {
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Create autosetup script
-	setupScript := `#!/bin/sh
-# Automated SSH setup for Servin VM on Linux KVM
-echo "Starting automated SSH setup..."
-
-# Update package index
-apk update
-
-# Install required packages
-apk add openssh sudo curl wget bash
-
-# Configure SSH
-echo 'root:servin123' | chpasswd
-echo 'PermitRootLogin yes' >> /etc/ssh/sshd_config
-echo 'PasswordAuthentication yes' >> /etc/ssh/sshd_config
-echo 'PubkeyAuthentication yes' >> /etc/ssh/sshd_config
-
-# Generate SSH host keys
-ssh-keygen -A
-
-# Enable and start SSH service
-rc-update add sshd default
-rc-service sshd start
-
-# Setup container environment
-modprobe overlay 2>/dev/null || true
-modprobe bridge 2>/dev/null || true
-echo overlay >> /etc/modules
-echo bridge >> /etc/modules
-
-# Enable IP forwarding
-echo 'net.ipv4.ip_forward = 1' >> /etc/sysctl.conf
-sysctl -p
-
-# Create servin user
-adduser -D servin
-echo 'servin:servin123' | chpasswd
-addgroup servin wheel
-
-# Success indicator
-echo "SSH setup completed successfully" > /tmp/ssh-setup-complete
-echo "✅ SSH setup completed - ready for connections"
-`
-
-	scriptPath := filepath.Join(tempDir, "autosetup.sh")
-	if err := os.WriteFile(scriptPath, []byte(setupScript), 0755); err != nil {
-		return fmt.Errorf("failed to write setup script: %v", err)
+	if err := writeSeed(tempDir, p.vmPath, shareMountScript(p.SharedFolders(), shareVirtioFS)); err != nil {
+		return err
 	}
 
 	// Create cloud-init user-data
 	userData := `#cloud-config
-users:
-  - name: root
-    password: servin123
-    chpasswd: { expire: False }
-    sudo: ['ALL=(ALL) NOPASSWD:ALL']
-
 runcmd:
-  - /autosetup.sh
+  - sh /mnt/cidata/autosetup.sh || sh /media/cdrom/autosetup.sh
 
 bootcmd:
-  - echo "Starting Servin VM setup..."
+  - mkdir -p /mnt/cidata
+  - mount -L cidata /mnt/cidata || true
 
 final_message: "Servin VM setup completed"
 `
@@ -235,18 +195,8 @@ local-hostname: servin-vm
 		return fmt.Errorf("failed to write meta-data: %v", err)
 	}
 
-	// Create ISO using genisoimage or mkisofs
-	var cmd *exec.Cmd
-	if _, err := exec.LookPath("genisoimage"); err == nil {
-		cmd = exec.Command("genisoimage", "-output", isoPath, "-volid", "cidata", "-joliet", "-rock", tempDir)
-	} else if _, err := exec.LookPath("mkisofs"); err == nil {
-		cmd = exec.Command("mkisofs", "-o", isoPath, "-V", "cidata", "-J", "-R", tempDir)
-	} else {
-		return fmt.Errorf("neither genisoimage nor mkisofs found. Install cdrtools or genisoimage package")
-	}
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to create ISO: %v", err)
+	if err := buildSeedISO(tempDir, isoPath); err != nil {
+		return err
 	}
 
 	fmt.Println("✅ Cloud-init ISO created")
@@ -273,16 +223,17 @@ func (p *KVMProvider) createDiskImage() error {
 	return nil
 }
 
-// Start starts the VM using KVM/QEMU
+// Start starts the VM using KVM/QEMU, resuming it if it is paused
 func (p *KVMProvider) Start() error {
-	if p.running {
-		return nil
+	if up, err := p.machine.prepareStart(); up || err != nil {
+		return err
 	}
 
 	return p.startKVMVM()
 }
 
-// startKVMVM starts the KVM VM with proper acceleration and SSH automation
+// startKVMVM starts the KVM VM with proper acceleration and its agent port
+// forwarded
 func (p *KVMProvider) startKVMVM() error {
 	kernelPath := filepath.Join(p.vmPath, "vmlinuz-virt")
 	initramfsPath := filepath.Join(p.vmPath, "initramfs-virt")
@@ -297,9 +248,9 @@ func (p *KVMProvider) startKVMVM() error {
 	}
 
 	// Determine QEMU binary
-	qemuBinary := "qemu-system-x86_64"
+	qemuBinary, panicDevice := "qemu-system-x86_64", "pvpanic"
 	if strings.Contains(os.Getenv("GOARCH"), "arm") {
-		qemuBinary = "qemu-system-aarch64"
+		qemuBinary, panicDevice = "qemu-system-aarch64", "pvpanic-pci"
 	}
 
 	// Check if QEMU is available
@@ -314,212 +265,222 @@ func (p *KVMProvider) startKVMVM() error {
 		"-smp", strconv.Itoa(p.config.CPUs),
 		"-kernel", kernelPath,
 		"-initrd", initramfsPath,
-		"-append", "console=ttyS0 ip=dhcp ssh=1 SERVIN_AUTO_SETUP=1",
+		"-append", "console=ttyS0 ip=dhcp SERVIN_AUTO_SETUP=1",
 		"-drive", fmt.Sprintf("file=%s,format=qcow2", diskPath),
 		"-drive", fmt.Sprintf("file=%s,media=cdrom", isoPath),
-		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp::%d-:22", p.sshPort),
+		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp:127.0.0.1:%d-:%d", p.agentPort, agent.GuestPort),
 		"-device", "virtio-net,netdev=net0",
-		"-nographic",
-		"-serial", "stdio",
+		"-display", "none",
 		"-daemonize",
 	}
 
+	// QEMU is controlled through QMP, and the serial console is served on
+	// a socket for 'servin vm console'
+	qemuArgs = append(qemuArgs, p.machine.args(panicDevice)...)
+	qemuArgs = append(qemuArgs, p.console.args()...)
+
 	// Add CPU features for better performance
 	qemuArgs = append(qemuArgs, "-cpu", "host")
 
-	fmt.Printf("Starting KVM VM with SSH on port %d...\n", p.sshPort)
-	fmt.Println("VM will boot Alpine Linux with automated SSH setup")
+	// The monitor takes snapshots of the running VM; a snapshot restored
+	// while it was stopped is resumed rather than booted
+	qemuArgs = append(qemuArgs, p.snapshots.monitorArgs()...)
+	qemuArgs = append(qemuArgs, p.snapshots.startArgs()...)
 
-	p.qemuCmd = exec.Command(qemuBinary, qemuArgs...)
-	if err := p.qemuCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start QEMU: %v", err)
-	}
+	// Share the host directories volumes are mounted from
+	qemuArgs = append(qemuArgs, p.shareArgs()...)
 
-	p.qemuPid = p.qemuCmd.Process.Pid
-	p.running = true
+	fmt.Printf("Starting KVM VM with its agent on port %d...\n", p.agentPort)
+	fmt.Println("VM will boot Alpine Linux and install the servin agent")
 
-	fmt.Printf("✅ KVM VM started (PID: %d)\n", p.qemuPid)
-	fmt.Println("⏳ Waiting for SSH setup to complete...")
+	// QEMU daemonizes once the VM is set up, so its errors are known here
+	if output, err := exec.Command(qemuBinary, qemuArgs...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to start QEMU: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	if err := p.machine.waitStarted(10 * time.Second); err != nil {
+		return err
+	}
 
-	// Monitor SSH connectivity
-	go p.monitorSSHAndDeploy()
+	fmt.Println("✅ KVM VM started")
+	fmt.Println("⏳ Waiting for the VM agent to start...")
+	p.agent.waitReady(90 * time.Second)
 
 	return nil
 }
 
-// monitorSSHAndDeploy monitors SSH connectivity and deploys Servin when ready
-func (p *KVMProvider) monitorSSHAndDeploy() {
-	maxWait := 90 * time.Second
-	start := time.Now()
-
-	for time.Since(start) < maxWait {
-		if p.testSSHConnectivity() {
-			fmt.Println("✅ SSH is ready!")
-			time.Sleep(2 * time.Second) // Let SSH fully stabilize
-
-			if err := p.deployServinToVM(); err != nil {
-				fmt.Printf("⚠️ Failed to deploy Servin to VM: %v\n", err)
-			}
-			return
-		}
-		time.Sleep(2 * time.Second)
+// Stop powers the VM off with an ACPI shutdown request, stopping it after
+// 30 seconds if the guest has not powered off by then
+func (p *KVMProvider) Stop() error {
+	if err := p.machine.shutdown(30 * time.Second); err != nil {
+		return err
 	}
+	fmt.Println("✅ VM stopped")
+	return nil
+}
 
-	fmt.Println("⚠️ SSH setup timeout - manual configuration may be needed")
+// Pause freezes the running VM
+func (p *KVMProvider) Pause() error {
+	return p.machine.pause()
 }
 
-// testSSHConnectivity tests if SSH is working
-func (p *KVMProvider) testSSHConnectivity() bool {
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "ConnectTimeout=2",
-		"-o", "BatchMode=yes",
-		"root@localhost",
-		"echo 'SSH_WORKING'")
-
-	output, err := cmd.Output()
-	return err == nil && strings.Contains(string(output), "SSH_WORKING")
+// Resume lets the paused VM continue
+func (p *KVMProvider) Resume() error {
+	return p.machine.resume()
 }
 
-// deployServinToVM deploys the Servin binary to the VM
-func (p *KVMProvider) deployServinToVM() error {
-	// Find the servin binary
-	servinBinary := "./servin"
-	if _, err := os.Stat(servinBinary); err != nil {
-		// Try alternative locations
-		for _, path := range []string{"servin", "/usr/local/bin/servin", "build/servin"} {
-			if _, err := os.Stat(path); err == nil {
-				servinBinary = path
-				break
-			}
+// SharedFolders returns the host directories shared with the VM, over
+// virtio-fs when virtiofsd is installed and 9p otherwise
+func (p *KVMProvider) SharedFolders() []Share {
+	return hostShares()
+}
+
+// virtiofsdPaths are where distributions install virtiofsd outside PATH
+var virtiofsdPaths = []string{"/usr/libexec/virtiofsd", "/usr/lib/qemu/virtiofsd", "/usr/lib/virtiofsd"}
+
+func findVirtiofsd() string {
+	if path, err := exec.LookPath("virtiofsd"); err == nil {
+		return path
+	}
+	for _, path := range virtiofsdPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
 		}
 	}
+	return ""
+}
 
-	if _, err := os.Stat(servinBinary); err != nil {
-		return fmt.Errorf("servin binary not found")
+// shareArgs returns the QEMU arguments sharing the host directories. With
+// virtiofsd a daemon is started for each share, which exits with QEMU, and
+// guest memory is made shareable with it; otherwise, or if a daemon fails
+// to start, the shares use QEMU's own 9p server.
+func (p *KVMProvider) shareArgs() []string {
+	shares := p.SharedFolders()
+	if len(shares) == 0 {
+		return nil
 	}
 
-	fmt.Println("📦 Deploying Servin to VM...")
-
-	// Copy binary to VM
-	cmd := exec.Command("scp",
-		"-P", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		servinBinary,
-		"root@localhost:/usr/local/bin/servin")
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to copy binary: %v", err)
+	if virtiofsd := findVirtiofsd(); virtiofsd != "" {
+		args, err := p.startVirtiofsd(virtiofsd, shares)
+		if err == nil {
+			return args
+		}
+		fmt.Printf("⚠️  virtio-fs unavailable, sharing host directories with 9p: %v\n", err)
 	}
 
-	// Make it executable
-	cmd = exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		"chmod +x /usr/local/bin/servin")
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to make binary executable: %v", err)
+	var args []string
+	for _, s := range shares {
+		args = append(args, "-virtfs", fmt.Sprintf("local,path=%s,mount_tag=%s,security_model=passthrough,id=%s", s.HostPath, s.Tag, s.Tag))
 	}
-
-	fmt.Println("✅ Servin deployed to VM")
-	return nil
+	return args
 }
 
-// Stop stops the VM
-func (p *KVMProvider) Stop() error {
-	if !p.running {
-		return nil
+// startVirtiofsd starts a virtiofsd for each share and returns the QEMU
+// arguments connecting to them
+func (p *KVMProvider) startVirtiofsd(virtiofsd string, shares []Share) ([]string, error) {
+	args := []string{
+		"-object", fmt.Sprintf("memory-backend-memfd,id=mem,size=%dM,share=on", p.config.Memory),
+		"-numa", "node,memdev=mem",
 	}
 
-	// Try graceful shutdown via SSH first
-	if p.testSSHConnectivity() {
-		cmd := exec.Command("ssh",
-			"-p", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"root@localhost",
-			"shutdown -h now")
-		cmd.Run() // Ignore errors as VM might shutdown before SSH responds
-
-		// Wait for graceful shutdown
-		time.Sleep(10 * time.Second)
+	var started []*os.Process
+	fail := func(err error) ([]string, error) {
+		for _, process := range started {
+			process.Kill()
+		}
+		return nil, err
 	}
 
-	// Force kill QEMU process if still running
-	if p.qemuPid > 0 {
-		if process, err := os.FindProcess(p.qemuPid); err == nil {
-			process.Signal(syscall.SIGTERM)
-			time.Sleep(5 * time.Second)
-			process.Signal(syscall.SIGKILL)
+	for i, s := range shares {
+		socket := filepath.Join(p.vmPath, s.Tag+".sock")
+		os.Remove(socket)
+		cmd := exec.Command(virtiofsd, "--socket-path", socket, "--shared-dir", s.HostPath, "--cache", "auto")
+		if err := cmd.Start(); err != nil {
+			return fail(fmt.Errorf("failed to start virtiofsd for %s: %v", s.HostPath, err))
+		}
+		started = append(started, cmd.Process)
+		go cmd.Wait()
+
+		ready := false
+		for j := 0; j < 50 && !ready; j++ {
+			if _, err := os.Stat(socket); err == nil {
+				ready = true
+			} else {
+				time.Sleep(100 * time.Millisecond)
+			}
+		}
+		if !ready {
+			return fail(fmt.Errorf("virtiofsd for %s did not create %s", s.HostPath, socket))
 		}
-	}
 
-	p.running = false
-	p.qemuPid = 0
-	fmt.Println("✅ VM stopped")
-	return nil
+		args = append(args,
+			"-chardev", fmt.Sprintf("socket,id=fs%d,path=%s", i, socket),
+			"-device", fmt.Sprintf("vhost-user-fs-pci,queue-size=1024,chardev=fs%d,tag=%s", i, s.Tag))
+	}
+	return args, nil
 }
 
 // Destroy removes the VM completely
 func (p *KVMProvider) Destroy() error {
-	if p.running {
-		p.Stop()
-	}
+	p.Stop()
 
 	return os.RemoveAll(p.vmPath)
 }
 
-// IsRunning checks if the VM is currently running
-func (p *KVMProvider) IsRunning() bool {
-	// First check if we think it's running
-	if !p.running {
-		return false
+// CreateSnapshot saves the VM's disk, and its memory while it runs, as an
+// internal qcow2 snapshot
+func (p *KVMProvider) CreateSnapshot(name string) error {
+	return p.snapshots.create(name)
+}
+
+// RestoreSnapshot rolls the VM back to a snapshot
+func (p *KVMProvider) RestoreSnapshot(name string) error {
+	return p.snapshots.restore(name)
+}
+
+// ListSnapshots lists the VM's snapshots
+func (p *KVMProvider) ListSnapshots() ([]*Snapshot, error) {
+	return p.snapshots.list()
+}
+
+// DeleteSnapshot deletes a snapshot of the VM
+func (p *KVMProvider) DeleteSnapshot(name string) error {
+	return p.snapshots.remove(name)
+}
+
+// OpenConsole connects to the serial console of the running VM
+func (p *KVMProvider) OpenConsole() (io.ReadWriteCloser, error) {
+	if !p.IsRunning() {
+		return nil, fmt.Errorf("VM is not running")
 	}
+	return p.console.open()
+}
 
-	// Check if QEMU process is still alive
-	if p.qemuPid > 0 {
-		if process, err := os.FindProcess(p.qemuPid); err == nil {
-			// Send signal 0 to check if process exists
-			if err := process.Signal(syscall.Signal(0)); err == nil {
-				// Process exists, now check SSH connectivity
-				p.running = p.testSSHConnectivity()
-				return p.running
-			}
-		}
+// ConsoleLog is the file the serial console output is logged to
+func (p *KVMProvider) ConsoleLog() string {
+	return p.console.log
+}
+
+// Resize grows the VM's qcow2 disk; new CPUs and memory are passed to QEMU
+// when the VM next starts
+func (p *KVMProvider) Resize(res Resources) error {
+	if res.DiskSize == 0 {
+		return nil
 	}
+	return resizeQcow2(p.snapshots.disk, res.DiskSize)
+}
 
-	// Process not found or SSH not working
-	p.running = false
-	p.qemuPid = 0
-	return false
+// IsRunning checks that QEMU runs the VM and its agent answers
+func (p *KVMProvider) IsRunning() bool {
+	return p.machine.state() == machineRunning && p.agent.reachable()
 }
 
 // GetInfo returns VM information
 func (p *KVMProvider) GetInfo() (*VMInfo, error) {
-	status := "stopped"
-	if p.IsRunning() {
-		status = "running"
-	}
+	status := p.machine.state()
 
 	uptime := ""
-	if p.running && p.testSSHConnectivity() {
-		// Get uptime from VM
-		cmd := exec.Command("ssh",
-			"-p", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "ConnectTimeout=2",
-			"root@localhost",
-			"uptime -p")
-		if output, err := cmd.Output(); err == nil {
-			uptime = strings.TrimSpace(string(output))
-		}
+	if status == machineRunning {
+		uptime = p.agent.uptime()
 	}
 
 	return &VMInfo{
@@ -530,22 +491,24 @@ func (p *KVMProvider) GetInfo() (*VMInfo, error) {
 		CPUs:       p.config.CPUs,
 		Memory:     p.config.Memory,
 		IPAddress:  "127.0.0.1",
-		SSHPort:    p.sshPort,
+		AgentPort:  p.agentPort,
 		DockerPort: p.config.DockerPort,
 		Uptime:     uptime,
+		LastEvent:  p.machine.lastEvent(),
 		Capabilities: map[string]bool{
 			"containers":   true,
 			"networking":   true,
 			"volumes":      true,
 			"port_forward": true,
 			"nested_virt":  true, // KVM supports nested virtualization
-			"ssh_access":   p.testSSHConnectivity(),
+			"shared_dirs":  len(p.SharedFolders()) > 0,
+			"agent":        p.agent.reachable(),
 		},
 	}, nil
 }
 
-// isKVMAvailable checks if KVM acceleration is available
-func (p *KVMProvider) isKVMAvailable() bool {
+// kvmAvailable checks if KVM acceleration is available
+func kvmAvailable() bool {
 	// Check if KVM device exists
 	if _, err := os.Stat("/dev/kvm"); err != nil {
 		return false
@@ -555,210 +518,3 @@ func (p *KVMProvider) isKVMAvailable() bool {
 	cmd := exec.Command("test", "-r", "/dev/kvm", "-a", "-w", "/dev/kvm")
 	return cmd.Run() == nil
 }
-
-// downloadFile downloads a file from URL to destination
-func downloadFile(url, dest string) error {
-	cmd := exec.Command("curl", "-L", "-o", dest, url)
-	return cmd.Run()
-}
-
-// RunContainer runs a container inside the VM using native Servin runtime
-func (p *KVMProvider) RunContainer(config *ContainerConfig) (*ContainerResult, error) {
-	if !p.IsRunning() {
-		return nil, fmt.Errorf("VM is not running")
-	}
-
-	// Build servin run command
-	servinCmd := p.buildServinCommand(config)
-
-	// Execute via SSH
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		servinCmd)
-
-	output, err := cmd.CombinedOutput()
-
-	result := &ContainerResult{
-		Name:   config.Name,
-		Output: string(output),
-	}
-
-	if err != nil {
-		result.Error = err.Error()
-		result.ExitCode = 1
-	} else {
-		result.Status = "running"
-		result.ExitCode = 0
-		// Extract container ID from output if available
-		if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); len(lines) > 0 {
-			result.ID = strings.TrimSpace(lines[len(lines)-1])
-		}
-	}
-
-	return result, nil
-}
-
-// buildServinCommand builds a servin command for container operations
-func (p *KVMProvider) buildServinCommand(config *ContainerConfig) string {
-	parts := []string{"/usr/local/bin/servin", "run"}
-
-	// Add name if specified
-	if config.Name != "" {
-		parts = append(parts, "--name", config.Name)
-	}
-
-	// Add ports
-	for hostPort, containerPort := range config.Ports {
-		parts = append(parts, "-p", fmt.Sprintf("%s:%s", hostPort, containerPort))
-	}
-
-	// Add volumes
-	for hostPath, containerPath := range config.Volumes {
-		parts = append(parts, "-v", fmt.Sprintf("%s:%s", hostPath, containerPath))
-	}
-
-	// Add environment variables
-	for key, value := range config.Environment {
-		parts = append(parts, "-e", fmt.Sprintf("%s=%s", key, value))
-	}
-
-	// Add working directory
-	if config.WorkDir != "" {
-		parts = append(parts, "-w", config.WorkDir)
-	}
-
-	// Add detached mode
-	if config.Detached {
-		parts = append(parts, "-d")
-	}
-
-	// Add image
-	parts = append(parts, config.Image)
-
-	// Add command
-	if len(config.Command) > 0 {
-		parts = append(parts, config.Command...)
-	}
-
-	return strings.Join(parts, " ")
-}
-
-// ListContainers lists containers in the VM using Servin
-func (p *KVMProvider) ListContainers() ([]*ContainerInfo, error) {
-	if !p.IsRunning() {
-		return nil, fmt.Errorf("VM is not running")
-	}
-
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		"/usr/local/bin/servin list")
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list containers: %v", err)
-	}
-
-	return p.parseServinContainerList(string(output)), nil
-}
-
-// parseServinContainerList parses Servin container list output
-func (p *KVMProvider) parseServinContainerList(output string) []*ContainerInfo {
-	var containers []*ContainerInfo
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-
-	for _, line := range lines {
-		if strings.Contains(line, "CONTAINER") || line == "" {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) >= 4 {
-			container := &ContainerInfo{
-				ID:     fields[0],
-				Name:   fields[1],
-				Image:  fields[2],
-				Status: fields[3],
-			}
-			if len(fields) >= 5 {
-				container.Created = fields[4]
-			}
-			containers = append(containers, container)
-		}
-	}
-
-	return containers
-}
-
-// StopContainer stops a container in the VM
-func (p *KVMProvider) StopContainer(id string) error {
-	return p.executeServinCommand(fmt.Sprintf("/usr/local/bin/servin stop %s", id))
-}
-
-// RemoveContainer removes a container in the VM
-func (p *KVMProvider) RemoveContainer(id string) error {
-	return p.executeServinCommand(fmt.Sprintf("/usr/local/bin/servin remove %s", id))
-}
-
-// executeServinCommand executes a Servin command in the VM
-func (p *KVMProvider) executeServinCommand(command string) error {
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		command)
-
-	return cmd.Run()
-}
-
-// CopyToVM copies a file from host to VM
-func (p *KVMProvider) CopyToVM(hostPath, vmPath string) error {
-	if !p.IsRunning() {
-		return fmt.Errorf("VM is not running")
-	}
-
-	cmd := exec.Command("scp",
-		"-P", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		hostPath,
-		fmt.Sprintf("root@localhost:%s", vmPath))
-
-	return cmd.Run()
-}
-
-// CopyFromVM copies a file from VM to host
-func (p *KVMProvider) CopyFromVM(vmPath, hostPath string) error {
-	if !p.IsRunning() {
-		return fmt.Errorf("VM is not running")
-	}
-
-	cmd := exec.Command("scp",
-		"-P", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		fmt.Sprintf("root@localhost:%s", vmPath),
-		hostPath)
-
-	return cmd.Run()
-}
-
-// ForwardPort forwards a port from host to VM
-func (p *KVMProvider) ForwardPort(hostPort, vmPort int) error {
-	// Port forwarding is set up during VM start
-	// This is a placeholder for dynamic port forwarding
-	return fmt.Errorf("dynamic port forwarding not implemented, configure during VM start")
-}
-
-// RemovePortForward removes a port forward
-func (p *KVMProvider) RemovePortForward(hostPort int) error {
-	// Port forwarding is managed during VM start
-	// This is a placeholder for dynamic port forwarding
-	return fmt.Errorf("dynamic port forwarding not implemented")
-}
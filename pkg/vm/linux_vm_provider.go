@@ -19,7 +19,7 @@ import (
 type LinuxVMProvider struct {
 	config      *VMConfig
 	vmPath      string
-	sshPort     int
+	agentPort   int
 	running     bool
 	containers  map[string]*ContainerInfo
 	qemuProcess *os.Process
@@ -37,7 +37,7 @@ func NewLinuxVMProvider(config *VMConfig) (VMProvider, error) {
 	return &LinuxVMProvider{
 		config:     config,
 		vmPath:     vmPath,
-		sshPort:    config.SSHPort,
+		agentPort:  config.AgentPort,
 		running:    false,
 		containers: make(map[string]*ContainerInfo),
 	}, nil
@@ -179,7 +179,7 @@ func (p *LinuxVMProvider) GetInfo() (*VMInfo, error) {
 		CPUs:       p.config.CPUs,
 		Memory:     p.config.Memory,
 		IPAddress:  "127.0.0.1",
-		SSHPort:    p.sshPort,
+		AgentPort:  p.agentPort,
 		DockerPort: p.config.DockerPort,
 		Capabilities: map[string]bool{
 			"containers":        true,
@@ -356,11 +356,11 @@ func (p *LinuxVMProvider) downloadAlpineKernel() error {
 	kernelURL := "https://dl-cdn.alpinelinux.org/alpine/v3.18/releases/x86_64/netboot/vmlinuz-virt"
 	initramfsURL := "https://dl-cdn.alpinelinux.org/alpine/v3.18/releases/x86_64/netboot/initramfs-virt"
 
-	if err := p.downloadFile(kernelURL, kernelPath); err != nil {
+	if err := downloadAsset(p.config, "kernel", kernelURL, kernelPath); err != nil {
 		return fmt.Errorf("failed to download kernel: %v", err)
 	}
 
-	if err := p.downloadFile(initramfsURL, initramfsPath); err != nil {
+	if err := downloadAsset(p.config, "initramfs", initramfsURL, initramfsPath); err != nil {
 		return fmt.Errorf("failed to download initramfs: %v", err)
 	}
 
@@ -368,23 +368,6 @@ func (p *LinuxVMProvider) downloadAlpineKernel() error {
 	return nil
 }
 
-func (p *LinuxVMProvider) downloadFile(url, path string) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = io.Copy(file, resp.Body)
-	return err
-}
-
 func (p *LinuxVMProvider) createVMDisk() error {
 	diskPath := filepath.Join(p.vmPath, "disk.qcow2")
 
@@ -4,23 +4,50 @@ package vm
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"servin/pkg/vm/agent"
 )
 
-// VirtualizationFrameworkProvider implements VM operations using macOS Virtualization.framework
+// VirtualizationFrameworkProvider runs the VM with qemu-system-aarch64
+// accelerated by Hypervisor.framework. Despite its name it does not use
+// Virtualization.framework; VfkitProvider does, and is preferred when vfkit
+// is installed.
 type VirtualizationFrameworkProvider struct {
-	config  *VMConfig
-	vmPath  string
-	sshPort int
-	running bool
+	guestOps
+	config    *VMConfig
+	vmPath    string
+	agentPort int
+	agent     *agentGuest
+	machine   qemuMachine
+	snapshots qemuSnapshots
+	console   qemuConsole
+}
+
+func init() {
+	RegisterBackend(Backend{
+		Name:         "qemu",
+		Description:  "QEMU with Hypervisor.framework acceleration",
+		Platforms:    []string{"darwin"},
+		Priority:     1,
+		Acceleration: "hardware",
+		Available: func() error {
+			if _, err := exec.LookPath("qemu-system-aarch64"); err != nil {
+				return fmt.Errorf("qemu-system-aarch64 not found (brew install qemu)")
+			}
+			return nil
+		},
+		New: NewVirtualizationFrameworkProvider,
+	})
 }
 
-// NewVirtualizationFrameworkProvider creates a new Virtualization.framework provider
+// NewVirtualizationFrameworkProvider creates a new QEMU provider
 func NewVirtualizationFrameworkProvider(config *VMConfig) (VMProvider, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -28,13 +55,26 @@ func NewVirtualizationFrameworkProvider(config *VMConfig) (VMProvider, error) {
 	}
 
 	vmPath := filepath.Join(homeDir, ".servin", "vms", config.Name)
-
-	return &VirtualizationFrameworkProvider{
-		config:  config,
-		vmPath:  vmPath,
-		sshPort: config.SSHPort,
-		running: false,
-	}, nil
+	agentPort := pickAgentPort(config.AgentPort)
+
+	p := &VirtualizationFrameworkProvider{
+		config:    config,
+		vmPath:    vmPath,
+		agentPort: agentPort,
+		agent:     localAgent(vmPath, agentPort),
+		machine:   newQEMUMachine(vmPath),
+		snapshots: qemuSnapshots{
+			name:    config.Name,
+			disk:    filepath.Join(vmPath, "alpine.qcow2"),
+			monitor: filepath.Join(vmPath, "monitor.sock"),
+		},
+		console: qemuConsole{
+			socket: filepath.Join(vmPath, "console.sock"),
+			log:    filepath.Join(vmPath, "console.log"),
+		},
+	}
+	p.guestOps = guestOps{guest: p.agent, up: p.IsRunning}
+	return p, nil
 }
 
 // Create creates a new VM using QEMU with proper Alpine Linux setup
@@ -47,10 +87,10 @@ func (p *VirtualizationFrameworkProvider) Create(config *VMConfig) error {
 	return p.createQEMUVM(config)
 }
 
-// Start starts the VM using QEMU
+// Start starts the VM using QEMU, resuming it if it is paused
 func (p *VirtualizationFrameworkProvider) Start() error {
-	if p.running {
-		return nil
+	if up, err := p.machine.prepareStart(); up || err != nil {
+		return err
 	}
 
 	// Ensure VM is created with Alpine Linux and cloud-init
@@ -67,6 +107,8 @@ func (p *VirtualizationFrameworkProvider) Start() error {
 	return p.startQEMUVM()
 }
 
+// createCloudInitISO creates the seed ISO holding the setup script that
+// installs the servin agent
 func (p *VirtualizationFrameworkProvider) createCloudInitISO() error {
 	vmDir := p.vmPath
 	tempDir := filepath.Join(vmDir, "cloud-init-temp")
@@ -78,163 +120,117 @@ func (p *VirtualizationFrameworkProvider) createCloudInitISO() error {
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Create comprehensive auto-setup script for Alpine Linux
-	autoSetupScript := `#!/bin/ash
-# Automated SSH setup script for Alpine Linux VM
-# This script runs automatically when the VM boots
-
-echo "Starting automated SSH setup for Servin VM..."
-
-# Wait for system to be ready
-sleep 3
-
-# Update package repository
-apk update
-
-# Install essential packages
-apk add openssh sudo curl bash
-
-# Configure SSH for remote access
-echo 'PermitRootLogin yes' >> /etc/ssh/sshd_config
-echo 'PasswordAuthentication yes' >> /etc/ssh/sshd_config
-echo 'ClientAliveInterval 60' >> /etc/ssh/sshd_config
-echo 'ClientAliveCountMax 3' >> /etc/ssh/sshd_config
-
-# Set root password
-echo 'root:servin123' | chpasswd
-
-# Create servin user for container management
-adduser -D -s /bin/ash servin
-echo 'servin:servin123' | chpasswd
-echo 'servin ALL=(ALL) NOPASSWD:ALL' >> /etc/sudoers
-
-# Enable SSH service to start on boot
-rc-update add sshd default
-rc-service sshd start
-
-# Set up container environment
-echo "net.ipv4.ip_forward=1" >> /etc/sysctl.conf
-sysctl -p
-
-# Create directories for Servin
-mkdir -p /var/lib/servin /usr/local/bin
-
-# Create completion marker
-echo "SSH setup completed at $(date)" > /var/log/servin-setup.log
-echo "✅ SSH is now available on port 22"
-echo "✅ Servin VM setup complete!"
-
-# Make this script run automatically on boot
-if ! grep -q "autosetup.sh" /etc/local.d/*.start 2>/dev/null; then
-    echo '#!/bin/ash' > /etc/local.d/servin-setup.start
-    echo 'if [ ! -f /var/log/servin-setup.log ]; then' >> /etc/local.d/servin-setup.start
-    echo '    /tmp/autosetup.sh &' >> /etc/local.d/servin-setup.start
-    echo 'fi' >> /etc/local.d/servin-setup.start
-    chmod +x /etc/local.d/servin-setup.start
-    rc-update add local default
-fi
-`
+	if err := writeSeed(tempDir, p.vmPath, shareMountScript(p.SharedFolders(), share9P)); err != nil {
+		return err
+	}
 
 	// Create meta-data
 	metaData := `instance-id: alpine-servin-vm
 local-hostname: alpine-servin
 `
-
-	// Write files to temp directory
-	if err := os.WriteFile(filepath.Join(tempDir, "autosetup.sh"), []byte(autoSetupScript), 0755); err != nil {
-		return fmt.Errorf("failed to write auto-setup script: %v", err)
-	}
-
 	if err := os.WriteFile(filepath.Join(tempDir, "meta-data"), []byte(metaData), 0644); err != nil {
 		return fmt.Errorf("failed to write meta-data: %v", err)
 	}
 
-	// Create ISO using hdiutil (macOS native)
-	fmt.Println("Creating auto-setup ISO using hdiutil...")
-
-	// Remove existing ISO if it exists
-	if p.fileExists(isoPath) {
-		os.Remove(isoPath)
-	}
-
-	cmd := exec.Command("hdiutil", "makehybrid", "-iso", "-joliet", "-o", isoPath, tempDir)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to create auto-setup ISO: %v, output: %s", err, string(output))
+	fmt.Println("Creating auto-setup ISO...")
+	if err := buildSeedISO(tempDir, isoPath); err != nil {
+		return fmt.Errorf("failed to create auto-setup ISO: %v", err)
 	}
 
 	fmt.Printf("✅ Auto-setup ISO created at: %s\n", isoPath)
 	return nil
 }
 
-// Stop stops the VM
+// Stop powers the VM off with an ACPI shutdown request, stopping it after
+// 30 seconds if the guest has not powered off by then
 func (p *VirtualizationFrameworkProvider) Stop() error {
-	if !p.running {
-		return nil
-	}
-
-	// Send shutdown signal via SSH
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		"shutdown -h now")
-
-	cmd.Run() // Ignore errors as VM might shutdown before SSH responds
+	return p.machine.shutdown(30 * time.Second)
+}
 
-	// Wait for VM to stop
-	time.Sleep(5 * time.Second)
-	p.running = false
+// Pause freezes the running VM
+func (p *VirtualizationFrameworkProvider) Pause() error {
+	return p.machine.pause()
+}
 
-	return nil
+// Resume lets the paused VM continue
+func (p *VirtualizationFrameworkProvider) Resume() error {
+	return p.machine.resume()
 }
 
 // Destroy removes the VM completely
 func (p *VirtualizationFrameworkProvider) Destroy() error {
-	if p.running {
-		p.Stop()
-	}
+	p.Stop()
 
 	return os.RemoveAll(p.vmPath)
 }
 
-// IsRunning checks if the VM is currently running
-func (p *VirtualizationFrameworkProvider) IsRunning() bool {
-	// Check for running QEMU process
-	cmd := exec.Command("pgrep", "-f", "qemu-system-aarch64.*"+p.vmPath)
-	err := cmd.Run()
+// CreateSnapshot saves the VM's disk, and its memory while it runs, as an
+// internal qcow2 snapshot
+func (p *VirtualizationFrameworkProvider) CreateSnapshot(name string) error {
+	return p.snapshots.create(name)
+}
 
-	if err == nil {
-		p.running = true
-		return true
+// RestoreSnapshot rolls the VM back to a snapshot
+func (p *VirtualizationFrameworkProvider) RestoreSnapshot(name string) error {
+	return p.snapshots.restore(name)
+}
+
+// ListSnapshots lists the VM's snapshots
+func (p *VirtualizationFrameworkProvider) ListSnapshots() ([]*Snapshot, error) {
+	return p.snapshots.list()
+}
+
+// DeleteSnapshot deletes a snapshot of the VM
+func (p *VirtualizationFrameworkProvider) DeleteSnapshot(name string) error {
+	return p.snapshots.remove(name)
+}
+
+// OpenConsole connects to the serial console of the running VM
+func (p *VirtualizationFrameworkProvider) OpenConsole() (io.ReadWriteCloser, error) {
+	if !p.IsRunning() {
+		return nil, fmt.Errorf("VM is not running")
 	}
+	return p.console.open()
+}
 
-	// Fallback: Check if we can connect via SSH (if SSH is configured)
-	sshCmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "ConnectTimeout=1",
-		"root@localhost",
-		"echo 'alive'")
-
-	sshErr := sshCmd.Run()
-	p.running = (sshErr == nil)
-	return p.running
+// ConsoleLog is the file the serial console output is logged to
+func (p *VirtualizationFrameworkProvider) ConsoleLog() string {
+	return p.console.log
+}
+
+// Resize grows the VM's qcow2 disk; new CPUs and memory are passed to QEMU
+// when the VM next starts
+func (p *VirtualizationFrameworkProvider) Resize(res Resources) error {
+	if res.DiskSize == 0 {
+		return nil
+	}
+	return resizeQcow2(p.snapshots.disk, res.DiskSize)
+}
+
+// SharedFolders returns the host directories shared with the VM over 9p
+func (p *VirtualizationFrameworkProvider) SharedFolders() []Share {
+	return hostShares()
+}
+
+// IsRunning checks that QEMU runs the VM and its agent answers
+func (p *VirtualizationFrameworkProvider) IsRunning() bool {
+	return p.machine.state() == machineRunning && p.agent.reachable()
 }
 
 // GetInfo returns VM information
 func (p *VirtualizationFrameworkProvider) GetInfo() (*VMInfo, error) {
 	return &VMInfo{
 		Name:       p.config.Name,
-		Status:     p.getStatus(),
+		Status:     p.machine.state(),
 		Platform:   "macOS",
-		Provider:   "Virtualization.framework",
+		Provider:   "QEMU (Hypervisor.framework)",
 		CPUs:       p.config.CPUs,
 		Memory:     p.config.Memory,
 		IPAddress:  "127.0.0.1",
-		SSHPort:    p.sshPort,
+		AgentPort:  p.agentPort,
 		DockerPort: p.config.DockerPort,
+		Uptime:     p.agent.uptime(),
+		LastEvent:  p.machine.lastEvent(),
 		Capabilities: map[string]bool{
 			"containers":   true,
 			"networking":   true,
@@ -244,113 +240,6 @@ func (p *VirtualizationFrameworkProvider) GetInfo() (*VMInfo, error) {
 	}, nil
 }
 
-// RunContainer runs a container inside the VM using Servin's native containerization
-func (p *VirtualizationFrameworkProvider) RunContainer(config *ContainerConfig) (*ContainerResult, error) {
-	if !p.IsRunning() {
-		return nil, fmt.Errorf("VM is not running")
-	}
-
-	// Build Servin container command (not Docker!)
-	servinCmd := p.buildServinCommand(config)
-
-	// Execute via SSH to run Servin container natively in Linux VM
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "ConnectTimeout=5",
-		"root@localhost",
-		servinCmd)
-
-	output, err := cmd.CombinedOutput()
-
-	result := &ContainerResult{
-		Name:   config.Name,
-		Output: string(output),
-	}
-
-	if err != nil {
-		result.Error = err.Error()
-		result.ExitCode = 1
-	} else {
-		result.Status = "running"
-		result.ExitCode = 0
-		// Extract container ID from Servin output
-		if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); len(lines) > 0 {
-			result.ID = strings.TrimSpace(lines[len(lines)-1])
-		}
-	}
-
-	return result, nil
-}
-
-// ListContainers lists containers in the VM
-func (p *VirtualizationFrameworkProvider) ListContainers() ([]*ContainerInfo, error) {
-	if !p.IsRunning() {
-		return nil, fmt.Errorf("VM is not running")
-	}
-
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		"docker ps -a --format 'table {{.ID}}\\t{{.Names}}\\t{{.Image}}\\t{{.Status}}\\t{{.CreatedAt}}\\t{{.Command}}'")
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list containers: %v", err)
-	}
-
-	return p.parseContainerList(string(output)), nil
-}
-
-// StopContainer stops a container in the VM
-func (p *VirtualizationFrameworkProvider) StopContainer(id string) error {
-	return p.executeDockerCommand(fmt.Sprintf("docker stop %s", id))
-}
-
-// RemoveContainer removes a container in the VM
-func (p *VirtualizationFrameworkProvider) RemoveContainer(id string) error {
-	return p.executeDockerCommand(fmt.Sprintf("docker rm %s", id))
-}
-
-// CopyToVM copies files from host to VM
-func (p *VirtualizationFrameworkProvider) CopyToVM(hostPath, vmPath string) error {
-	cmd := exec.Command("scp",
-		"-P", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		hostPath,
-		fmt.Sprintf("root@localhost:%s", vmPath))
-
-	return cmd.Run()
-}
-
-// CopyFromVM copies files from VM to host
-func (p *VirtualizationFrameworkProvider) CopyFromVM(vmPath, hostPath string) error {
-	cmd := exec.Command("scp",
-		"-P", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		fmt.Sprintf("root@localhost:%s", vmPath),
-		hostPath)
-
-	return cmd.Run()
-}
-
-// ForwardPort forwards a port from host to VM
-func (p *VirtualizationFrameworkProvider) ForwardPort(hostPort, vmPort int) error {
-	// Port forwarding is typically configured during VM creation
-	// For dynamic forwarding, we'd need to use SSH tunneling
-	return fmt.Errorf("dynamic port forwarding not implemented")
-}
-
-// RemovePortForward removes a port forward
-func (p *VirtualizationFrameworkProvider) RemovePortForward(hostPort int) error {
-	return fmt.Errorf("dynamic port forwarding not implemented")
-}
-
 // Helper methods
 
 func (p *VirtualizationFrameworkProvider) isUTMAvailable() bool {
@@ -405,9 +294,25 @@ func (p *VirtualizationFrameworkProvider) startQEMUVM() error {
 		"-smp", strconv.Itoa(p.config.CPUs),
 		"-m", strconv.Itoa(p.config.Memory),
 		"-drive", fmt.Sprintf("file=%s,if=virtio,format=qcow2", diskPath),
-		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp::%d-:22", p.sshPort),
+		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp:127.0.0.1:%d-:%d", p.agentPort, agent.GuestPort),
 		"-device", "virtio-net-pci,netdev=net0",
-		"-nographic",
+		"-display", "none",
+	}
+
+	// QEMU is controlled through QMP, and the serial console is served on
+	// a socket for 'servin vm console'
+	args = append(args, p.machine.args("pvpanic-pci")...)
+	args = append(args, p.console.args()...)
+
+	// The monitor takes snapshots of the running VM; a snapshot restored
+	// while it was stopped is resumed rather than booted
+	args = append(args, p.snapshots.monitorArgs()...)
+	args = append(args, p.snapshots.startArgs()...)
+
+	// Share the host directories volumes are mounted from. virtiofsd does
+	// not run on macOS, so QEMU serves them with 9p.
+	for _, s := range p.SharedFolders() {
+		args = append(args, "-virtfs", fmt.Sprintf("local,path=%s,mount_tag=%s,security_model=mapped-xattr,id=%s", s.HostPath, s.Tag, s.Tag))
 	}
 
 	// Check if we have netboot kernel files and auto-setup ISO
@@ -416,7 +321,7 @@ func (p *VirtualizationFrameworkProvider) startQEMUVM() error {
 	autoSetupISO := filepath.Join(p.vmPath, "cloud-init.iso")
 
 	if p.fileExists(kernelPath) && p.fileExists(initrdPath) {
-		fmt.Println("Using netboot kernel with automated SSH setup...")
+		fmt.Println("Using netboot kernel with automated agent setup...")
 		args = append(args, "-kernel", kernelPath)
 		args = append(args, "-initrd", initrdPath)
 
@@ -427,7 +332,7 @@ func (p *VirtualizationFrameworkProvider) startQEMUVM() error {
 		if p.fileExists(autoSetupISO) {
 			args = append(args, "-drive", fmt.Sprintf("file=%s,if=virtio,media=cdrom", autoSetupISO))
 			appendCmd += " autosetup=cdrom"
-			fmt.Println("Auto-setup ISO attached for automated SSH configuration")
+			fmt.Println("Auto-setup ISO attached to install the servin agent")
 		}
 
 		args = append(args, "-append", appendCmd)
@@ -445,198 +350,60 @@ func (p *VirtualizationFrameworkProvider) startQEMUVM() error {
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start QEMU VM: %v", err)
 	}
+	go cmd.Wait()
+	if err := p.machine.waitStarted(10 * time.Second); err != nil {
+		return err
+	}
 
-	fmt.Printf("QEMU VM started with PID: %d\n", cmd.Process.Pid)
-	fmt.Println("🚀 VM is starting with automated SSH setup...")
+	fmt.Println("QEMU VM started")
+	fmt.Println("🚀 VM is starting and installing the servin agent...")
 
-	// Wait for VM to boot and auto-configure SSH
-	fmt.Println("Waiting for Alpine Linux to boot and configure SSH automatically...")
+	// Wait for VM to boot and start its agent
+	fmt.Println("Waiting for Alpine Linux to boot and start the servin agent...")
 	for i := 0; i < 60; i++ {
-		// Check if SSH is available
-		if p.testSSHConnectivity() {
-			p.running = true
-			fmt.Println("✅ VM is now running with SSH configured automatically!")
-			fmt.Printf("SSH access: ssh root@localhost -p %d (password: servin123)\n", p.sshPort)
-
-			// Deploy Servin binary to VM
-			if err := p.deployServinToVM(); err != nil {
-				fmt.Printf("Warning: Failed to deploy Servin to VM: %v\n", err)
-			} else {
-				fmt.Println("✅ Servin binary deployed to VM successfully!")
-			}
-
+		if p.agent.reachable() {
+			fmt.Printf("✅ VM is now running with its agent on port %d\n", p.agentPort)
 			return nil
 		}
 
 		// Show progress
 		if i%5 == 0 {
-			fmt.Printf("Waiting for SSH auto-setup... (%d/60 seconds)\n", i)
+			fmt.Printf("Waiting for the VM agent... (%d/60 seconds)\n", i)
 		}
 		time.Sleep(1 * time.Second)
 	}
 
-	fmt.Println("⚠️  SSH auto-setup taking longer than expected")
-	fmt.Printf("Manual setup may be required. Connect to VM console and run:\n")
+	fmt.Println("⚠️  The VM agent is taking longer than expected to start")
+	fmt.Printf("Manual setup may be required. Connect with 'servin vm console' and run:\n")
 	fmt.Printf("  mount /dev/sr0 /mnt && /mnt/autosetup.sh\n")
-	fmt.Printf("SSH will be available at: ssh root@localhost -p %d\n", p.sshPort)
 
-	p.running = true
 	return nil
 }
 
-func (p *VirtualizationFrameworkProvider) getStatus() string {
-	if p.IsRunning() {
-		return "running"
-	}
-	return "stopped"
-}
-
-func (p *VirtualizationFrameworkProvider) buildDockerCommand(config *ContainerConfig) string {
-	cmd := []string{"docker", "run"}
-
-	if config.Detached {
-		cmd = append(cmd, "-d")
-	}
-
-	if config.Name != "" {
-		cmd = append(cmd, "--name", config.Name)
-	}
-
-	// Add environment variables
-	for key, value := range config.Environment {
-		cmd = append(cmd, "-e", fmt.Sprintf("%s=%s", key, value))
-	}
-
-	// Add port mappings
-	for hostPort, containerPort := range config.Ports {
-		cmd = append(cmd, "-p", fmt.Sprintf("%s:%s", hostPort, containerPort))
-	}
-
-	// Add volumes
-	for hostPath, containerPath := range config.Volumes {
-		cmd = append(cmd, "-v", fmt.Sprintf("%s:%s", hostPath, containerPath))
-	}
-
-	// Add working directory
-	if config.WorkDir != "" {
-		cmd = append(cmd, "-w", config.WorkDir)
-	}
-
-	// Add image
-	cmd = append(cmd, config.Image)
-
-	// Add command
-	cmd = append(cmd, config.Command...)
-
-	return strings.Join(cmd, " ")
-}
-
-func (p *VirtualizationFrameworkProvider) executeDockerCommand(dockerCmd string) error {
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		dockerCmd)
-
-	return cmd.Run()
-}
-
-func (p *VirtualizationFrameworkProvider) parseContainerList(output string) []*ContainerInfo {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	if len(lines) <= 1 { // Skip header line
-		return []*ContainerInfo{}
-	}
-
-	containers := make([]*ContainerInfo, 0, len(lines)-1)
-
-	for _, line := range lines[1:] { // Skip header
-		fields := strings.Split(line, "\t")
-		if len(fields) >= 6 {
-			containers = append(containers, &ContainerInfo{
-				ID:      fields[0],
-				Name:    fields[1],
-				Image:   fields[2],
-				Status:  fields[3],
-				Created: fields[4],
-				Command: fields[5],
-			})
-		}
-	}
-
-	return containers
-}
-
 // downloadAlpineISO downloads Alpine Linux ISO for VM setup
 func (p *VirtualizationFrameworkProvider) downloadAlpineISO(isoPath string) error {
 	// Use a lightweight Alpine Linux ISO
 	url := "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/aarch64/alpine-virt-3.19.1-aarch64.iso"
 
-	// Simple download implementation
-	cmd := exec.Command("curl", "-L", "-o", isoPath, url)
-	return cmd.Run()
-}
-
-// createCloudInitConfig creates cloud-init configuration for automated VM setup
-func (p *VirtualizationFrameworkProvider) createCloudInitConfig() error {
-	userDataPath := filepath.Join(p.vmPath, "user-data")
-	metaDataPath := filepath.Join(p.vmPath, "meta-data")
-
-	// Create user-data for cloud-init (automated setup)
-	userData := `#cloud-config
-users:
-  - name: servin
-    sudo: ALL=(ALL) NOPASSWD:ALL
-    shell: /bin/bash
-    ssh_authorized_keys:
-      - ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABgQC... # We'll generate this
-packages:
-  - bash
-  - curl
-  - iproute2
-  - iptables
-runcmd:
-  - mkdir -p /var/lib/servin
-  - chown servin:servin /var/lib/servin
-  - echo "net.ipv4.ip_forward=1" >> /etc/sysctl.conf
-  - sysctl -p
-ssh_pwauth: true
-password: servin
-chpasswd:
-  expire: false
-`
-
-	if err := os.WriteFile(userDataPath, []byte(userData), 0644); err != nil {
-		return err
-	}
-
-	// Create minimal meta-data
-	metaData := `instance-id: servin-vm
-local-hostname: servin
-`
-
-	if err := os.WriteFile(metaDataPath, []byte(metaData), 0644); err != nil {
-		return err
-	}
-
-	// In production, we'd create an ISO with cloud-init data
-	return nil
+	return downloadAsset(p.config, "iso", url, isoPath)
 }
 
 // createBootableAlpineImage creates a bootable Alpine Linux image with cloud-init
 func (p *VirtualizationFrameworkProvider) createBootableAlpineImage(diskPath string) error {
-	fmt.Println("Creating bootable Alpine Linux disk image with SSH and Servin support...")
+	fmt.Println("Creating bootable Alpine Linux disk image with Servin support...")
 
 	// Ensure VM directory exists
 	if err := os.MkdirAll(p.vmPath, 0755); err != nil {
 		return fmt.Errorf("failed to create VM directory: %v", err)
 	}
 
-	// Step 1: Create empty disk
-	fmt.Println("Creating empty disk image...")
-	cmd := exec.Command("qemu-img", "create", "-f", "qcow2", diskPath, "8G")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to create disk image: %v, output: %s", err, string(output))
+	// Step 1: Create empty disk, keeping an existing one and what is on it
+	if !p.fileExists(diskPath) {
+		fmt.Println("Creating empty disk image...")
+		cmd := exec.Command("qemu-img", "create", "-f", "qcow2", diskPath, fmt.Sprintf("%dG", p.config.DiskSize))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to create disk image: %v, output: %s", err, string(output))
+		}
 	}
 
 	// Step 2: Download Alpine netboot kernel and initrd
@@ -646,8 +413,7 @@ func (p *VirtualizationFrameworkProvider) createBootableAlpineImage(diskPath str
 	if !p.fileExists(kernelPath) {
 		fmt.Println("Downloading Alpine kernel...")
 		kernelURL := "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/aarch64/netboot-3.19.1/vmlinuz-virt"
-		cmd = exec.Command("curl", "-L", "-o", kernelPath, kernelURL)
-		if err := cmd.Run(); err != nil {
+		if err := downloadAsset(p.config, "kernel", kernelURL, kernelPath); err != nil {
 			return fmt.Errorf("failed to download kernel: %v", err)
 		}
 	}
@@ -655,8 +421,7 @@ func (p *VirtualizationFrameworkProvider) createBootableAlpineImage(diskPath str
 	if !p.fileExists(initrdPath) {
 		fmt.Println("Downloading Alpine initramfs...")
 		initrdURL := "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/aarch64/netboot-3.19.1/initramfs-virt"
-		cmd = exec.Command("curl", "-L", "-o", initrdPath, initrdURL)
-		if err := cmd.Run(); err != nil {
+		if err := downloadAsset(p.config, "initramfs", initrdURL, initrdPath); err != nil {
 			return fmt.Errorf("failed to download initramfs: %v", err)
 		}
 	}
@@ -666,30 +431,8 @@ func (p *VirtualizationFrameworkProvider) createBootableAlpineImage(diskPath str
 		return fmt.Errorf("failed to create cloud-init ISO: %v", err)
 	}
 
-	// Step 4: Set up SSH keys
-	if err := p.setupSSHKeys(); err != nil {
-		fmt.Printf("Warning: SSH key setup failed: %v\n", err)
-	}
-
 	fmt.Println("Alpine Linux VM components ready")
-	fmt.Println("VM will auto-configure SSH and install Servin on first boot")
-
-	return nil
-}
-
-// setupSSHKeys sets up SSH keys for passwordless VM access
-func (p *VirtualizationFrameworkProvider) setupSSHKeys() error {
-	sshDir := filepath.Join(os.Getenv("HOME"), ".ssh")
-	keyPath := filepath.Join(sshDir, "servin_vm_key")
-
-	// Generate SSH key if it doesn't exist
-	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-		fmt.Println("Generating SSH key for VM access...")
-		cmd := exec.Command("ssh-keygen", "-t", "rsa", "-b", "2048", "-f", keyPath, "-N", "")
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to generate SSH key: %v", err)
-		}
-	}
+	fmt.Println("VM will install and start the servin agent on first boot")
 
 	return nil
 }
@@ -706,98 +449,7 @@ func (p *VirtualizationFrameworkProvider) needsInstallation(diskPath string) boo
 
 // fileExists checks if a file exists
 func (p *VirtualizationFrameworkProvider) fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
-}
-
-// buildServinCommand builds a Servin container command for execution in the VM
-func (p *VirtualizationFrameworkProvider) buildServinCommand(config *ContainerConfig) string {
-	cmd := []string{"/usr/local/bin/servin", "run"}
-
-	// Add image
-	cmd = append(cmd, config.Image)
-
-	// Add command if specified
-	if len(config.Command) > 0 {
-		cmd = append(cmd, config.Command...)
-	}
-
-	// Add container name
-	if config.Name != "" {
-		cmd = append(cmd, "--name", config.Name)
-	}
-
-	// Add environment variables
-	for key, value := range config.Environment {
-		cmd = append(cmd, "-e", fmt.Sprintf("%s=%s", key, value))
-	}
-
-	// Add ports
-	for hostPort, containerPort := range config.Ports {
-		cmd = append(cmd, "-p", fmt.Sprintf("%s:%s", hostPort, containerPort))
-	}
-
-	// Add volumes
-	for hostPath, containerPath := range config.Volumes {
-		cmd = append(cmd, "-v", fmt.Sprintf("%s:%s", hostPath, containerPath))
-	}
-
-	// Add working directory
-	if config.WorkDir != "" {
-		cmd = append(cmd, "-w", config.WorkDir)
-	}
-
-	// Add detached mode if specified
-	if config.Detached {
-		cmd = append(cmd, "-d")
-	}
-
-	return strings.Join(cmd, " ")
-}
-
-// createAutoSetupScript creates a script for automated Alpine setup
-func (p *VirtualizationFrameworkProvider) createAutoSetupScript() error {
-	setupPath := filepath.Join(p.vmPath, "setup.sh")
-
-	setupScript := `#!/bin/sh
-# Alpine Linux auto-setup script for Servin VM
-set -e
-
-# Install to disk if not already done
-if [ ! -f /mnt/servin-installed ]; then
-    echo "Setting up Alpine Linux for Servin..."
-    
-    # Setup repositories
-    echo "http://dl-cdn.alpinelinux.org/alpine/v3.19/main" > /etc/apk/repositories
-    echo "http://dl-cdn.alpinelinux.org/alpine/v3.19/community" >> /etc/apk/repositories
-    apk update
-    
-    # Install essential packages
-    apk add openssh bash curl
-    
-    # Enable SSH
-    rc-update add sshd default
-    
-    # Create servin user
-    adduser -D -s /bin/bash servin
-    echo "servin:servin" | chpasswd
-    
-    # Allow SSH with password (temporary)
-    sed -i 's/#PasswordAuthentication yes/PasswordAuthentication yes/' /etc/ssh/sshd_config
-    sed -i 's/#PermitRootLogin prohibit-password/PermitRootLogin yes/' /etc/ssh/sshd_config
-    
-    # Start SSH
-    service sshd start
-    
-    # Create marker file
-    mkdir -p /mnt
-    touch /mnt/servin-installed
-    
-    echo "Servin VM setup complete!"
-fi
-`
-
-	return os.WriteFile(setupPath, []byte(setupScript), 0755)
+	return fileExists(path)
 }
 
 // commandExists checks if a command is available in PATH
@@ -805,61 +457,3 @@ func (p *VirtualizationFrameworkProvider) commandExists(cmd string) bool {
 	_, err := exec.LookPath(cmd)
 	return err == nil
 }
-
-// testSSHConnectivity tests if SSH is available and working
-func (p *VirtualizationFrameworkProvider) testSSHConnectivity() bool {
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "ConnectTimeout=2",
-		"-o", "BatchMode=yes",
-		"root@localhost",
-		"echo 'SSH_READY'")
-
-	output, err := cmd.CombinedOutput()
-	return err == nil && strings.Contains(string(output), "SSH_READY")
-}
-
-// deployServinToVM copies the Servin binary to the VM and makes it executable
-func (p *VirtualizationFrameworkProvider) deployServinToVM() error {
-	// Get the current Servin binary path
-	servinPath := "./servin"
-	if !p.fileExists(servinPath) {
-		// Try to find servin binary
-		if wd, err := os.Getwd(); err == nil {
-			servinPath = filepath.Join(wd, "servin")
-		}
-		if !p.fileExists(servinPath) {
-			return fmt.Errorf("servin binary not found")
-		}
-	}
-
-	// Copy Servin binary to VM
-	cmd := exec.Command("scp",
-		"-P", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "BatchMode=yes",
-		servinPath,
-		"root@localhost:/usr/local/bin/servin")
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to copy servin binary: %v", err)
-	}
-
-	// Make it executable
-	cmd = exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "BatchMode=yes",
-		"root@localhost",
-		"chmod +x /usr/local/bin/servin")
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to make servin executable: %v", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProviderEnvVar names the variable that selects a VM backend by name
+// instead of the highest priority one available
+const ProviderEnvVar = "SERVIN_VM_PROVIDER"
+
+// Backend describes a VM provider. Backends register themselves from the
+// file that implements them, so adding one needs no changes elsewhere.
+type Backend struct {
+	Name        string
+	Description string
+	// Platforms are the GOOS values the backend runs on; empty means all
+	Platforms []string
+	// Priority orders the backends of a platform, lowest first
+	Priority     int
+	Acceleration string // "hardware" or "software"
+
+	// Available returns nil when the backend can run on this host, or why
+	// it cannot. Nil means always available.
+	Available func() error
+	// New creates the provider for a VM
+	New func(config *VMConfig) (VMProvider, error)
+}
+
+// Check reports whether the backend can run on this host
+func (b Backend) Check() error {
+	if !b.supports(runtime.GOOS) {
+		return fmt.Errorf("not supported on %s", runtime.GOOS)
+	}
+	if b.Available == nil {
+		return nil
+	}
+	return b.Available()
+}
+
+func (b Backend) supports(goos string) bool {
+	if len(b.Platforms) == 0 {
+		return true
+	}
+	for _, p := range b.Platforms {
+		if p == goos {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	backendsMu sync.Mutex
+	backends   = make(map[string]Backend)
+)
+
+// RegisterBackend adds a VM backend. It panics if the name is taken, as two
+// backends registering the same name is a programming error.
+func RegisterBackend(b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, exists := backends[b.Name]; exists {
+		panic(fmt.Sprintf("vm: backend %s registered twice", b.Name))
+	}
+	backends[b.Name] = b
+}
+
+// Backends returns the backends that run on this platform, in priority
+// order. The development backend is listed last.
+func Backends() []Backend {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	var list []Backend
+	for _, b := range backends {
+		if b.supports(runtime.GOOS) {
+			list = append(list, b)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority < list[j].Priority
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// LookupBackend returns the named backend
+func LookupBackend(name string) (Backend, bool) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	b, ok := backends[strings.ToLower(name)]
+	return b, ok
+}
+
+// SelectedBackend returns the backend new VMs are created with, or why
+// there is none
+func SelectedBackend() (Backend, error) {
+	return selectBackend()
+}
+
+// selectBackend returns the backend for new providers: the development
+// backend in development mode, the one named by $SERVIN_VM_PROVIDER, or
+// else the highest priority backend available on this host. The remote
+// backend needs a host, so it is only used for VMs created with one.
+func selectBackend() (Backend, error) {
+	name := os.Getenv(ProviderEnvVar)
+	if isDevelopmentMode() {
+		name = developmentBackend
+	}
+
+	if name != "" {
+		b, ok := LookupBackend(name)
+		if !ok {
+			return Backend{}, fmt.Errorf("unknown VM provider %q (see 'servin vm list-providers')", name)
+		}
+		if err := b.Check(); err != nil {
+			return Backend{}, fmt.Errorf("VM provider %s is not available: %v", b.Name, err)
+		}
+		return b, nil
+	}
+
+	var reasons []string
+	for _, b := range Backends() {
+		if b.Name == developmentBackend || b.Name == remoteBackend {
+			continue
+		}
+		err := b.Check()
+		if err == nil {
+			return b, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %v", b.Name, err))
+	}
+	if len(reasons) == 0 {
+		return Backend{}, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	}
+	return Backend{}, fmt.Errorf("no VM provider available (%s)", strings.Join(reasons, "; "))
+}
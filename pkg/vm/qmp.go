@@ -0,0 +1,363 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LifecycleEvent is a change of state of the VM: started or stopped by
+// servin, powered off by the guest, or a crash
+type LifecycleEvent struct {
+	// Event is START, STOP, PAUSE, RESUME, SHUTDOWN (the guest powered
+	// off), GUEST_PANICKED, INTERNAL_ERROR, IO_ERROR or QEMU_EXITED
+	Event  string    `json:"event"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Crashed reports whether the event is the VM crashing
+func (e *LifecycleEvent) Crashed() bool {
+	switch e.Event {
+	case "GUEST_PANICKED", "INTERNAL_ERROR", "IO_ERROR", "QEMU_EXITED":
+		return true
+	}
+	return false
+}
+
+// PauseProvider is implemented by providers that can freeze the running VM
+// and let it continue
+type PauseProvider interface {
+	Pause() error
+	Resume() error
+}
+
+// VM states reported by qemuMachine.state
+const (
+	machineRunning = "running"
+	machinePaused  = "paused"
+	machineCrashed = "crashed" // QEMU is up but the guest has stopped
+	machineStopped = "stopped"
+)
+
+// qemuMachine controls a QEMU VM through its QMP socket, which only the
+// running QEMU answers on, instead of looking for its process. QEMU is
+// started with -no-shutdown, so a guest that powers off is told apart from
+// a QEMU that died, and panics of the guest pause it for 'servin vm status'
+// to report. The last lifecycle event is recorded next to the socket.
+type qemuMachine struct {
+	socket string
+	events string // lifecycle.json, the last LifecycleEvent
+}
+
+func newQEMUMachine(vmPath string) qemuMachine {
+	return qemuMachine{
+		socket: filepath.Join(vmPath, "qmp.sock"),
+		events: filepath.Join(vmPath, "lifecycle.json"),
+	}
+}
+
+// args are the QEMU arguments serving QMP on the socket. panicDevice is the
+// pvpanic device of the machine type, through which the guest reports
+// kernel panics.
+func (m qemuMachine) args(panicDevice string) []string {
+	return []string{
+		"-qmp", fmt.Sprintf("unix:%s,server=on,wait=off", m.socket),
+		"-no-shutdown",
+		"-action", "panic=pause",
+		"-device", panicDevice,
+	}
+}
+
+// qmpTimeout bounds a QMP command
+const qmpTimeout = 30 * time.Second
+
+// qmpConn is a QMP session with capabilities negotiated
+type qmpConn struct {
+	m       qemuMachine
+	conn    net.Conn
+	decoder *json.Decoder
+}
+
+// qmpMessage is a reply, an error or an asynchronous event from QEMU
+type qmpMessage struct {
+	Return json.RawMessage `json:"return"`
+	Error  *struct {
+		Class string `json:"class"`
+		Desc  string `json:"desc"`
+	} `json:"error"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// dial opens a QMP session. It fails when QEMU is not running.
+func (m qemuMachine) dial() (*qmpConn, error) {
+	conn, err := net.DialTimeout("unix", m.socket, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(qmpTimeout))
+
+	c := &qmpConn{m: m, conn: conn, decoder: json.NewDecoder(conn)}
+	var greeting struct {
+		QMP json.RawMessage `json:"QMP"`
+	}
+	if err := c.decoder.Decode(&greeting); err != nil || greeting.QMP == nil {
+		conn.Close()
+		return nil, fmt.Errorf("QEMU did not greet on %s: %v", m.socket, err)
+	}
+	if _, err := c.execute("qmp_capabilities", nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *qmpConn) Close() error {
+	return c.conn.Close()
+}
+
+// execute runs a QMP command and returns its result. Events QEMU sends in
+// the meantime are recorded.
+func (c *qmpConn) execute(command string, arguments interface{}) (json.RawMessage, error) {
+	request := map[string]interface{}{"execute": command}
+	if arguments != nil {
+		request["arguments"] = arguments
+	}
+	if err := json.NewEncoder(c.conn).Encode(request); err != nil {
+		return nil, fmt.Errorf("failed to send %s to QEMU: %v", command, err)
+	}
+
+	for {
+		var msg qmpMessage
+		if err := c.decoder.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("QEMU did not answer %s: %v", command, err)
+		}
+		switch {
+		case msg.Event != "":
+			c.m.observe(msg.Event, msg.Data)
+		case msg.Error != nil:
+			return nil, fmt.Errorf("QEMU refused %s: %s", command, msg.Error.Desc)
+		default:
+			return msg.Return, nil
+		}
+	}
+}
+
+// runState returns QEMU's run state, e.g. running, paused, shutdown or
+// guest-panicked
+func (c *qmpConn) runState() (string, error) {
+	result, err := c.execute("query-status", nil)
+	if err != nil {
+		return "", err
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(result, &status); err != nil {
+		return "", fmt.Errorf("failed to decode QEMU status: %v", err)
+	}
+	return status.Status, nil
+}
+
+// observe records the QMP events that change the VM's lifecycle
+func (m qemuMachine) observe(event string, data json.RawMessage) {
+	switch event {
+	case "SHUTDOWN", "GUEST_PANICKED":
+		m.record(event, strings.TrimSpace(string(data)))
+	}
+}
+
+// record saves the last lifecycle event of the VM
+func (m qemuMachine) record(event, reason string) {
+	data, err := json.Marshal(LifecycleEvent{Event: event, Reason: reason, Time: time.Now()})
+	if err != nil {
+		return
+	}
+	os.WriteFile(m.events, append(data, '\n'), 0644)
+}
+
+// lastEvent returns the last recorded lifecycle event, or nil
+func (m qemuMachine) lastEvent() *LifecycleEvent {
+	data, err := os.ReadFile(m.events)
+	if err != nil {
+		return nil
+	}
+	var event LifecycleEvent
+	if json.Unmarshal(data, &event) != nil || event.Event == "" {
+		return nil
+	}
+	return &event
+}
+
+// state returns the state of the VM, recording what happened to it since it
+// was last looked at: a guest that powered off is left to QEMU to shut down
+// and reported stopped, and a QEMU that went away while the VM ran is
+// recorded as QEMU_EXITED
+func (m qemuMachine) state() string {
+	c, err := m.dial()
+	if err != nil {
+		if last := m.lastEvent(); last != nil {
+			switch last.Event {
+			case "START", "PAUSE", "RESUME":
+				m.record("QEMU_EXITED", "QEMU exited unexpectedly")
+			}
+		}
+		return machineStopped
+	}
+	defer c.Close()
+
+	status, err := c.runState()
+	if err != nil {
+		return machineStopped
+	}
+	switch status {
+	case "running":
+		return machineRunning
+	case "paused", "suspended":
+		return machinePaused
+	case "shutdown":
+		if last := m.lastEvent(); last == nil || last.Event != "SHUTDOWN" {
+			m.record("SHUTDOWN", "guest powered off")
+		}
+		c.execute("quit", nil)
+		return machineStopped
+	case "guest-panicked", "internal-error", "io-error":
+		event := strings.ToUpper(strings.ReplaceAll(status, "-", "_"))
+		if last := m.lastEvent(); last == nil || last.Event != event {
+			m.record(event, "")
+		}
+		return machineCrashed
+	}
+	// prelaunch, inmigrate and the like: QEMU is up but not running yet
+	return machinePaused
+}
+
+// waitStarted waits for a QEMU that was just started to answer on its
+// socket, and records the start
+func (m qemuMachine) waitStarted(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		c, err := m.dial()
+		if err == nil {
+			c.Close()
+			m.record("START", "")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("QEMU did not come up on %s: %v", m.socket, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// prepareStart readies the machine for a start: it reports whether the VM
+// is already running, resuming a paused one, and quits a QEMU whose guest
+// crashed so the VM boots again
+func (m qemuMachine) prepareStart() (bool, error) {
+	switch m.state() {
+	case machineRunning:
+		return true, nil
+	case machinePaused:
+		return true, m.resume()
+	case machineCrashed:
+		if err := m.quit(); err != nil {
+			return false, err
+		}
+	}
+	os.Remove(m.socket)
+	return false, nil
+}
+
+// shutdown powers the VM off with an ACPI request, then quits QEMU. A guest
+// that does not power off within timeout is stopped regardless.
+func (m qemuMachine) shutdown(timeout time.Duration) error {
+	switch m.state() {
+	case machineStopped:
+		return nil
+	case machineCrashed:
+		m.record("STOP", "")
+		return m.quit()
+	case machinePaused:
+		if err := m.resume(); err != nil {
+			return err
+		}
+	}
+
+	c, err := m.dial()
+	if err != nil {
+		return nil
+	}
+	defer c.Close()
+	if _, err := c.execute("system_powerdown", nil); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if status, err := c.runState(); err != nil || status == "shutdown" {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	if status, _ := c.runState(); status != "shutdown" {
+		fmt.Printf("⚠️  The VM did not power off within %s, stopping it\n", timeout)
+	}
+
+	m.record("STOP", "")
+	c.execute("quit", nil)
+	return m.waitExited(10 * time.Second)
+}
+
+// quit stops QEMU at once
+func (m qemuMachine) quit() error {
+	c, err := m.dial()
+	if err != nil {
+		return nil
+	}
+	c.execute("quit", nil) // QEMU may close the socket before answering
+	c.Close()
+	return m.waitExited(10 * time.Second)
+}
+
+// waitExited waits for QEMU to stop answering on its socket
+func (m qemuMachine) waitExited(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("unix", m.socket, time.Second)
+		if err != nil {
+			return nil
+		}
+		conn.Close()
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("QEMU did not exit within %s", timeout)
+}
+
+// pause freezes the running VM
+func (m qemuMachine) pause() error {
+	return m.command("stop", "PAUSE")
+}
+
+// resume lets a paused VM continue
+func (m qemuMachine) resume() error {
+	return m.command("cont", "RESUME")
+}
+
+// command runs a QMP command on the running QEMU and records event
+func (m qemuMachine) command(command, event string) error {
+	c, err := m.dial()
+	if err != nil {
+		return fmt.Errorf("VM is not running")
+	}
+	defer c.Close()
+	if _, err := c.execute(command, nil); err != nil {
+		return err
+	}
+	m.record(event, "")
+	return nil
+}
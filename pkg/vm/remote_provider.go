@@ -0,0 +1,391 @@
+package vm
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/vm/agent"
+)
+
+// remoteBackend is the name of the backend running containers on an
+// existing Linux host
+const remoteBackend = "remote"
+
+// RemoteConfig is the Linux host a remote VM runs its containers on,
+// reached over SSH with the user's key
+type RemoteConfig struct {
+	Host         string `json:"host"`
+	User         string `json:"user,omitempty"` // ssh's default when empty
+	Port         int    `json:"port,omitempty"` // 22 when 0
+	IdentityFile string `json:"identity_file"`
+}
+
+// ParseRemote parses a remote host given as [USER@]HOST[:PORT]
+func ParseRemote(target, identityFile string) (*RemoteConfig, error) {
+	remote := &RemoteConfig{IdentityFile: identityFile}
+	if user, host, ok := strings.Cut(target, "@"); ok {
+		remote.User, target = user, host
+	}
+	if host, port, ok := strings.Cut(target, ":"); ok {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return nil, fmt.Errorf("invalid SSH port %q", port)
+		}
+		remote.Port, target = n, host
+	}
+	if target == "" || strings.ContainsAny(target, " /") {
+		return nil, fmt.Errorf("invalid remote host %q: use [USER@]HOST[:PORT]", target)
+	}
+	remote.Host = target
+	return remote, nil
+}
+
+// destination is the host as ssh takes it
+func (r *RemoteConfig) destination() string {
+	if r.User != "" {
+		return r.User + "@" + r.Host
+	}
+	return r.Host
+}
+
+// String returns the host as ParseRemote takes it
+func (r *RemoteConfig) String() string {
+	if r.Port != 0 {
+		return fmt.Sprintf("%s:%d", r.destination(), r.Port)
+	}
+	return r.destination()
+}
+
+func init() {
+	RegisterBackend(Backend{
+		Name:         remoteBackend,
+		Description:  "An existing Linux host reached over SSH (servin vm create --remote)",
+		Priority:     100,
+		Acceleration: "hardware",
+		Available: func() error {
+			if _, err := exec.LookPath("ssh"); err != nil {
+				return fmt.Errorf("ssh not found")
+			}
+			return nil
+		},
+		New: NewRemoteProvider,
+	})
+}
+
+// RemoteProvider runs containers on an existing Linux host instead of a
+// local VM, for machines that cannot virtualize. servin is installed on the
+// host and run there over SSH as root, through sudo for other users. The
+// host is "running" between 'servin vm start' and 'servin vm stop', which
+// only connect to it and disconnect: containers on it keep running.
+type RemoteProvider struct {
+	guestOps
+	config *VMConfig
+	vmPath string
+	ssh    *sshGuest
+}
+
+// NewRemoteProvider creates the provider of a VM created with a remote host
+func NewRemoteProvider(config *VMConfig) (VMProvider, error) {
+	if config.Remote == nil {
+		return nil, fmt.Errorf("VM %s has no remote host: create one with 'servin vm create NAME --remote USER@HOST --identity KEY'", config.Name)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %v", err)
+	}
+
+	vmPath := filepath.Join(homeDir, ".servin", "vms", config.Name)
+	p := &RemoteProvider{
+		config: config,
+		vmPath: vmPath,
+		ssh:    &sshGuest{remote: config.Remote, vmPath: vmPath},
+	}
+	p.guestOps = guestOps{guest: p.ssh, up: p.IsRunning}
+	return p, nil
+}
+
+// connectedFile marks a remote VM started and not stopped since
+const connectedFile = "connected"
+
+// remoteMachines maps what uname -m reports on the host to the ELF machine
+// of the servin binaries that run there
+var remoteMachines = map[string]elf.Machine{
+	"x86_64":  elf.EM_X86_64,
+	"aarch64": elf.EM_AARCH64,
+	"arm64":   elf.EM_AARCH64,
+	"armv7l":  elf.EM_ARM,
+	"riscv64": elf.EM_RISCV,
+}
+
+// Create checks that the host is a Linux machine servin can reach and
+// installs servin on it
+func (p *RemoteProvider) Create(config *VMConfig) error {
+	if err := os.MkdirAll(p.vmPath, 0755); err != nil {
+		return fmt.Errorf("failed to create VM directory: %v", err)
+	}
+
+	fmt.Printf("Connecting to %s...\n", p.config.Remote)
+	var output bytes.Buffer
+	if err := exitError(p.ssh.exec(agent.ExecOptions{Argv: []string{"uname", "-sm"}, Stdout: &output})); err != nil {
+		return err
+	}
+	system := strings.Fields(output.String())
+	if len(system) != 2 || system[0] != "Linux" {
+		return fmt.Errorf("%s is not a Linux host (uname: %s)", p.config.Remote.Host, strings.TrimSpace(output.String()))
+	}
+	if err := checkServinBinary(system[1]); err != nil {
+		return err
+	}
+	return deployServin(p.ssh)
+}
+
+// checkServinBinary checks that the servin binary deployServin installs
+// runs on a Linux host of the machine uname -m reports
+func checkServinBinary(machine string) error {
+	binary, err := findServinBinary()
+	if err != nil {
+		return fmt.Errorf("%v: build servin for Linux so it can run on the remote host", err)
+	}
+	f, err := elf.Open(binary)
+	if err != nil {
+		return fmt.Errorf("%s is not a Linux binary: build servin with GOOS=linux for the remote host", binary)
+	}
+	defer f.Close()
+
+	if want, ok := remoteMachines[machine]; ok && f.Machine != want {
+		return fmt.Errorf("%s is built for %s but the remote host is %s: build servin for its architecture", binary, f.Machine, machine)
+	}
+	return nil
+}
+
+// Start connects to the host, installing servin on it if it was removed
+func (p *RemoteProvider) Start() error {
+	if p.IsRunning() {
+		return nil
+	}
+	if code, err := p.ssh.exec(agent.ExecOptions{Argv: []string{"test", "-x", guestServinPath}}); err != nil {
+		return err
+	} else if code != 0 {
+		if err := p.Create(p.config); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(p.path(connectedFile), nil, 0644); err != nil {
+		return fmt.Errorf("failed to mark %s connected: %v", p.config.Name, err)
+	}
+	fmt.Printf("✅ Connected to %s\n", p.config.Remote)
+	return nil
+}
+
+// Stop disconnects from the host. Its containers keep running and are
+// found again on the next start.
+func (p *RemoteProvider) Stop() error {
+	os.Remove(p.path(connectedFile))
+	p.ssh.disconnect()
+	return nil
+}
+
+// Destroy forgets the host. servin, its containers and images are left on
+// it.
+func (p *RemoteProvider) Destroy() error {
+	p.Stop()
+	return os.RemoveAll(p.vmPath)
+}
+
+// IsRunning reports whether the VM was started and the host answers
+func (p *RemoteProvider) IsRunning() bool {
+	if !fileExists(p.path(connectedFile)) {
+		return false
+	}
+	code, err := p.ssh.exec(agent.ExecOptions{Argv: []string{"true"}})
+	return err == nil && code == 0
+}
+
+// remoteInfoScript prints the host's CPUs, memory in MB and uptime in
+// seconds, one per line
+const remoteInfoScript = `nproc; awk '/^MemTotal:/ {print int($2 / 1024)}' /proc/meminfo; cut -d. -f1 /proc/uptime`
+
+// GetInfo returns VM information, with the host's CPUs and memory while
+// connected
+func (p *RemoteProvider) GetInfo() (*VMInfo, error) {
+	info := &VMInfo{
+		Name:       p.config.Name,
+		Status:     "stopped",
+		Platform:   "Linux (remote)",
+		Provider:   "Remote host over SSH (" + p.config.Remote.String() + ")",
+		CPUs:       p.config.CPUs,
+		Memory:     p.config.Memory,
+		IPAddress:  p.config.Remote.Host,
+		DockerPort: p.config.DockerPort,
+		Capabilities: map[string]bool{
+			"containers":   true,
+			"networking":   true,
+			"volumes":      true,
+			"port_forward": false,
+		},
+	}
+	if !p.IsRunning() {
+		return info, nil
+	}
+
+	info.Status = "running"
+	var output bytes.Buffer
+	if exitError(p.ssh.exec(agent.ExecOptions{Argv: []string{"sh", "-c", remoteInfoScript}, Stdout: &output})) == nil {
+		if lines := strings.Fields(output.String()); len(lines) == 3 {
+			if cpus, err := strconv.Atoi(lines[0]); err == nil {
+				info.CPUs = cpus
+			}
+			if memory, err := strconv.Atoi(lines[1]); err == nil {
+				info.Memory = memory
+			}
+			if uptime, err := strconv.Atoi(lines[2]); err == nil {
+				info.Uptime = (time.Duration(uptime) * time.Second).String()
+			}
+		}
+	}
+	return info, nil
+}
+
+// SharedFolders returns no directories: the host cannot see this machine's
+// files, so only named volumes can be mounted
+func (p *RemoteProvider) SharedFolders() []Share {
+	return nil
+}
+
+func (p *RemoteProvider) path(name string) string {
+	return filepath.Join(p.vmPath, name)
+}
+
+// sshFailure is the exit status of ssh when it fails itself rather than
+// the remote command
+const sshFailure = 255
+
+// sshRootScript runs its arguments as root, through sudo unless the SSH
+// user is root. sudo must not ask for a password.
+const sshRootScript = `if [ "$(id -u)" -eq 0 ]; then exec "$@"; fi; exec sudo -n -- "$@"`
+
+// sshGuest reaches a remote host with the ssh client. The host key is
+// accepted on first connection and kept in the VM's known_hosts, and on
+// Unix connections are shared through a control socket so each command
+// does not pay for a new handshake.
+type sshGuest struct {
+	remote *RemoteConfig
+	vmPath string
+}
+
+// options are the ssh options of every connection to the host
+func (g *sshGuest) options() []string {
+	args := []string{
+		"-i", g.remote.IdentityFile,
+		"-o", "IdentitiesOnly=yes",
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=accept-new",
+		"-o", fmt.Sprintf("UserKnownHostsFile=\"%s\"", filepath.Join(g.vmPath, "known_hosts")),
+		"-o", "ConnectTimeout=10",
+		"-o", "ServerAliveInterval=15",
+	}
+	// Windows' OpenSSH has no connection sharing
+	if runtime.GOOS != "windows" {
+		args = append(args,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPath="+filepath.Join(g.vmPath, "ssh.sock"),
+			"-o", "ControlPersist=10m",
+		)
+	}
+	if g.remote.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(g.remote.Port))
+	}
+	return args
+}
+
+// exec runs the command as root on the host. A terminal is allocated with
+// TTY set; ssh follows resizes of the local one itself.
+func (g *sshGuest) exec(opts agent.ExecOptions) (int, error) {
+	args := g.options()
+	if opts.TTY {
+		args = append(args, "-tt")
+	} else {
+		args = append(args, "-T")
+	}
+
+	argv := opts.Argv
+	if len(opts.Env) > 0 {
+		argv = append(append([]string{"env"}, opts.Env...), argv...)
+	}
+	remote := append([]string{"sh", "-c", sshRootScript, "sh"}, argv...)
+	for i, arg := range remote {
+		remote[i] = shellQuote(arg)
+	}
+	args = append(args, g.remote.destination(), "--", strings.Join(remote, " "))
+
+	var sshErr bytes.Buffer
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = opts.Stdin, opts.Stdout, opts.Stderr
+	if opts.Stderr == nil {
+		cmd.Stderr = &sshErr
+	}
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if exitErr.ExitCode() == sshFailure {
+			return -1, fmt.Errorf("failed to reach %s over SSH: %s", g.remote, strings.TrimSpace(sshErr.String()))
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to run ssh: %v", err)
+	}
+	return 0, nil
+}
+
+// copyTo streams the file to the host through ssh
+func (g *sshGuest) copyTo(hostPath, vmPath string) error {
+	f, err := os.Open(hostPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	script := `mkdir -p "$(dirname "$1")" && cat > "$1.tmp" && mv "$1.tmp" "$1"`
+	code, err := g.exec(agent.ExecOptions{Argv: []string{"sh", "-c", script, "sh", vmPath}, Stdin: f, Stderr: os.Stderr})
+	if err := exitError(code, err); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %v", hostPath, vmPath, err)
+	}
+	return nil
+}
+
+func (g *sshGuest) copyFrom(vmPath, hostPath string) error {
+	f, err := os.Create(hostPath)
+	if err != nil {
+		return err
+	}
+	code, err := g.exec(agent.ExecOptions{Argv: []string{"cat", vmPath}, Stdout: f, Stderr: os.Stderr})
+	f.Close()
+	if err := exitError(code, err); err != nil {
+		os.Remove(hostPath)
+		return fmt.Errorf("failed to copy %s from the remote host: %v", vmPath, err)
+	}
+	return nil
+}
+
+// disconnect closes the shared connection to the host, if any
+func (g *sshGuest) disconnect() {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	args := append(g.options(), "-O", "exit", g.remote.destination())
+	exec.Command("ssh", args...).Run()
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,136 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Resources are the resources of a VM that can change after it is created.
+// A zero field is left as it is.
+type Resources struct {
+	CPUs     int `json:"cpus,omitempty"`
+	Memory   int `json:"memory_mb,omitempty"`
+	DiskSize int `json:"disk_size_gb,omitempty"` // disks only grow
+}
+
+// ResizeProvider is implemented by providers that can change the resources
+// of their VM instead of recreating it. Resize is called with the VM
+// stopped: new CPUs and memory apply when it next starts, and the disk
+// image grows in place. The guest grows its filesystem to fill the disk
+// when it boots, or with GrowFilesystem while it runs.
+type ResizeProvider interface {
+	Resize(res Resources) error
+	GrowFilesystem() error
+}
+
+// growFilesystemScript grows the filesystem on the VM's disk, and the
+// partition holding it, to fill the disk. It does nothing on a disk
+// without an ext or XFS filesystem, and never fails, so it can run at
+// every boot.
+const growFilesystemScript = `
+# Grow the filesystem on the VM's disk to fill it after 'servin vm resize'
+grow_disk() {
+    dev=$1
+    [ -b "$dev" ] || return 0
+    if [ -b "${dev}1" ]; then
+        command -v growpart >/dev/null 2>&1 && growpart "$dev" 1 >/dev/null 2>&1
+        dev=${dev}1
+    fi
+    case $(blkid "$dev" 2>/dev/null | sed -n 's/.* TYPE="\([^"]*\)".*/\1/p') in
+    ext2|ext3|ext4)
+        resize2fs "$dev" >/dev/null 2>&1 || echo "Could not grow the filesystem on $dev"
+        ;;
+    xfs)
+        mnt=$(awk -v dev="$dev" '$1 == dev { print $2; exit }' /proc/mounts)
+        [ -n "$mnt" ] && { xfs_growfs "$mnt" >/dev/null 2>&1 || echo "Could not grow the filesystem on $dev"; }
+        ;;
+    esac
+    return 0
+}
+for disk in /dev/vda /dev/sda; do
+    grow_disk "$disk"
+done
+`
+
+// GrowFilesystem grows the guest's filesystem to fill its disk
+func (o guestOps) GrowFilesystem() error {
+	return o.guestRun("sh", "-c", growFilesystemScript)
+}
+
+// Resize changes the VM's resources, stopping it for the change and
+// starting it again if it was running. It reports whether it restarted the
+// VM. Config is updated so the caller can save it.
+func (vm *VMManager) Resize(res Resources) (restarted bool, err error) {
+	provider, ok := vm.Provider.(ResizeProvider)
+	if !ok {
+		return false, fmt.Errorf("VM provider does not support resizing; destroy and recreate the VM instead")
+	}
+	if res.DiskSize != 0 && res.DiskSize < vm.Config.DiskSize {
+		return false, fmt.Errorf("cannot shrink the VM disk from %dGB to %dGB", vm.Config.DiskSize, res.DiskSize)
+	}
+
+	running := vm.Provider.IsRunning()
+	if running {
+		if err := vm.Provider.Stop(); err != nil {
+			return false, fmt.Errorf("failed to stop VM: %v", err)
+		}
+	}
+
+	if err := provider.Resize(res); err != nil {
+		return false, err
+	}
+	if res.CPUs != 0 {
+		vm.Config.CPUs = res.CPUs
+	}
+	if res.Memory != 0 {
+		vm.Config.Memory = res.Memory
+	}
+	if res.DiskSize != 0 {
+		vm.Config.DiskSize = res.DiskSize
+	}
+
+	if !running {
+		return false, nil
+	}
+	if err := vm.EnsureRunning(); err != nil {
+		return false, err
+	}
+	if res.DiskSize != 0 {
+		if err := provider.GrowFilesystem(); err != nil {
+			return true, fmt.Errorf("VM disk grown, but not its filesystem: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// resizeQcow2 grows a qcow2 disk image. qemu-img refuses to resize images
+// with internal snapshots.
+func resizeQcow2(path string, sizeGB int) error {
+	output, err := exec.Command("qemu-img", "resize", path, fmt.Sprintf("%dG", sizeGB)).CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(output))
+		if strings.Contains(msg, "snapshot") {
+			msg += " (delete the VM's snapshots with 'servin vm snapshot rm' first)"
+		}
+		return fmt.Errorf("failed to resize disk: %v, output: %s", err, msg)
+	}
+	return nil
+}
+
+// resizeRaw grows a sparse raw disk image
+func resizeRaw(path string, sizeGB int) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to resize disk: %v", err)
+	}
+	size := int64(sizeGB) << 30
+	if size < info.Size() {
+		return fmt.Errorf("cannot shrink the VM disk from %dGB to %dGB", info.Size()>>30, sizeGB)
+	}
+	if err := os.Truncate(path, size); err != nil {
+		return fmt.Errorf("failed to resize disk: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package vm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"servin/pkg/vm/agent"
+)
+
+// agentTokenFile is the file in a VM's state directory holding the token
+// its agent accepts
+const agentTokenFile = "agent.token"
+
+// agentSetupScript installs the servin binary and token from the seed disc
+// and runs the agent as an OpenRC service. Containers need overlayfs,
+// bridges and IP forwarding, so it enables them too.
+var agentSetupScript = fmt.Sprintf(`#!/bin/sh
+# Installs the servin guest agent from the seed disc it is run from
+set -e
+SEED=$(dirname "$0")
+
+install -D -m 0755 "$SEED/servin" %[1]s
+install -D -m 0600 "$SEED/agent.token" %[2]s
+
+modprobe overlay 2>/dev/null || true
+modprobe bridge 2>/dev/null || true
+grep -qx overlay /etc/modules 2>/dev/null || echo overlay >> /etc/modules
+grep -qx bridge /etc/modules 2>/dev/null || echo bridge >> /etc/modules
+echo 'net.ipv4.ip_forward = 1' > /etc/sysctl.d/servin.conf
+sysctl -p /etc/sysctl.d/servin.conf
+
+cat > /etc/init.d/servin-agent <<'EOF'
+#!/sbin/openrc-run
+description="Servin VM guest agent"
+command=%[1]s
+command_args="vm agent --listen 0.0.0.0:%[3]d --token-file %[2]s"
+command_background=true
+pidfile=/run/servin-agent.pid
+depend() {
+    need net
+}
+EOF
+chmod +x /etc/init.d/servin-agent
+rc-update add servin-agent default
+rc-service servin-agent restart
+
+echo "Servin guest agent listening on port %[3]d"
+`, guestServinPath, agent.GuestTokenPath, agent.GuestPort)
+
+// agentToken returns the VM's agent token, generating it on first use
+func agentToken(vmPath string) (string, error) {
+	path := filepath.Join(vmPath, agentTokenFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate agent token: %v", err)
+	}
+	token := hex.EncodeToString(secret)
+
+	if err := os.MkdirAll(vmPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create VM directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write agent token: %v", err)
+	}
+	return token, nil
+}
+
+// writeSeed writes what the guest needs to run the agent into dir: the
+// servin binary, the VM's token and autosetup.sh, which installs both,
+// grows the filesystem on the VM's disk after a resize and offers a login
+// on the serial console. Backends that need
+// more in the guest pass it as setup, which autosetup.sh runs once the
+// agent is installed.
+func writeSeed(dir, vmPath string, setup ...string) error {
+	token, err := agentToken(vmPath)
+	if err != nil {
+		return err
+	}
+	binary, err := findServinBinary()
+	if err != nil {
+		return fmt.Errorf("%v: build servin for Linux so it can run in the VM", err)
+	}
+
+	if err := copyFile(binary, filepath.Join(dir, "servin"), 0755); err != nil {
+		return fmt.Errorf("failed to add servin to the seed disc: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "agent.token"), []byte(token+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to add agent token to the seed disc: %v", err)
+	}
+	script := agentSetupScript + growFilesystemScript + consoleSetupScript + strings.Join(setup, "\n")
+	if err := os.WriteFile(filepath.Join(dir, "autosetup.sh"), []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write setup script: %v", err)
+	}
+	return nil
+}
+
+// buildSeedISO packs dir into an ISO image labelled cidata, so cloud-init
+// also finds the files in it
+func buildSeedISO(dir, isoPath string) error {
+	os.Remove(isoPath)
+
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("genisoimage"); err == nil {
+		cmd = exec.Command("genisoimage", "-output", isoPath, "-volid", "cidata", "-joliet", "-rock", dir)
+	} else if _, err := exec.LookPath("mkisofs"); err == nil {
+		cmd = exec.Command("mkisofs", "-o", isoPath, "-V", "cidata", "-J", "-R", dir)
+	} else if runtime.GOOS == "darwin" {
+		cmd = exec.Command("hdiutil", "makehybrid", "-iso", "-joliet", "-default-volume-name", "cidata", "-o", isoPath, dir)
+	} else if _, err := exec.LookPath("oscdimg"); err == nil {
+		cmd = exec.Command("oscdimg", "-j1", "-lcidata", dir, isoPath)
+	} else {
+		return fmt.Errorf("no ISO tool found: install genisoimage or mkisofs")
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create seed ISO: %v, output: %s", err, string(output))
+	}
+	return nil
+}
+
+// copyFile copies src to dst with the given permissions
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Share is a host directory shared with the VM
+type Share struct {
+	// Tag is the mount tag, or shared folder name, the guest mounts it by
+	Tag       string `json:"tag"`
+	HostPath  string `json:"host_path"`
+	GuestPath string `json:"guest_path"`
+}
+
+// SharedFolderProvider is implemented by providers that share host
+// directories with their VM, so volumes of VM-backed containers mount host
+// files. The guest sees them at the paths volume.VMPath translates host
+// paths to.
+type SharedFolderProvider interface {
+	SharedFolders() []Share
+}
+
+// Share filesystems the guest mounts
+const (
+	shareVirtioFS = "virtiofs"
+	share9P       = "9p"
+	shareVBoxSF   = "vboxsf"
+)
+
+// hostShares are the directories shared with the VM on this host. macOS
+// shares its user directories, and /private which holds /tmp and
+// /var/folders; Linux shares home directories; Windows shares its drives
+// under /mnt. Directories that do not exist are left out.
+func hostShares() []Share {
+	var shares []Share
+	add := func(tag, hostPath, guestPath string) {
+		if _, err := os.Stat(hostPath); err == nil {
+			shares = append(shares, Share{Tag: tag, HostPath: hostPath, GuestPath: guestPath})
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		add("servin-users", "/Users", "/Users")
+		add("servin-volumes", "/Volumes", "/Volumes")
+		add("servin-private", "/private", "/private")
+	case "windows":
+		for drive := 'a'; drive <= 'z'; drive++ {
+			add(fmt.Sprintf("servin-%c", drive), fmt.Sprintf(`%c:\`, drive-'a'+'A'), fmt.Sprintf("/mnt/%c", drive))
+		}
+	default:
+		add("servin-home", "/home", "/home")
+		add("servin-root", "/root", "/root")
+		add("servin-srv", "/srv", "/srv")
+	}
+	return shares
+}
+
+// SharedGuestPath reports whether a path in the VM is inside one of shares
+func SharedGuestPath(shares []Share, guestPath string) bool {
+	for _, s := range shares {
+		if guestPath == s.GuestPath || strings.HasPrefix(guestPath, strings.TrimSuffix(s.GuestPath, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// shareMountScript is the part of the guest setup mounting shares with the
+// given filesystem. With virtiofs it falls back to 9p, which QEMU uses when
+// virtiofsd is not installed. Failures are reported but do not stop the
+// setup, so the agent still starts.
+func shareMountScript(shares []Share, fstype string) string {
+	if len(shares) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n# Mount the host directories shared with the VM\n")
+	if fstype == shareVBoxSF {
+		sb.WriteString("modprobe vboxsf 2>/dev/null || (apk add --no-cache virtualbox-guest-additions >/dev/null 2>&1 && modprobe vboxsf) || true\n")
+	}
+	for _, s := range shares {
+		var mount string
+		switch fstype {
+		case shareVirtioFS:
+			mount = fmt.Sprintf("mount -t virtiofs %[1]s %[2]s 2>/dev/null || mount -t 9p -o trans=virtio,version=9p2000.L,msize=262144 %[1]s %[2]s", s.Tag, s.GuestPath)
+		case share9P:
+			mount = fmt.Sprintf("mount -t 9p -o trans=virtio,version=9p2000.L,msize=262144 %s %s", s.Tag, s.GuestPath)
+		case shareVBoxSF:
+			mount = fmt.Sprintf("mount -t vboxsf %s %s", s.Tag, s.GuestPath)
+		}
+		fmt.Fprintf(&sb, "mkdir -p %[1]s && (mountpoint -q %[1]s || %[2]s) || echo \"Could not mount %[1]s from the host\"\n", s.GuestPath, mount)
+	}
+	return sb.String()
+}
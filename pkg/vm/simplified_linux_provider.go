@@ -16,7 +16,7 @@ import (
 type SimplifiedLinuxVMProvider struct {
 	config      *VMConfig
 	vmPath      string
-	sshPort     int
+	agentPort   int
 	running     bool
 	containers  map[string]*ContainerInfo
 	qemuProcess *os.Process
@@ -34,7 +34,7 @@ func NewSimplifiedLinuxVMProvider(config *VMConfig) (VMProvider, error) {
 	return &SimplifiedLinuxVMProvider{
 		config:     config,
 		vmPath:     vmPath,
-		sshPort:    config.SSHPort,
+		agentPort:  config.AgentPort,
 		running:    false,
 		containers: make(map[string]*ContainerInfo),
 	}, nil
@@ -140,7 +140,7 @@ func (p *SimplifiedLinuxVMProvider) GetInfo() (*VMInfo, error) {
 		CPUs:       p.config.CPUs,
 		Memory:     p.config.Memory,
 		IPAddress:  "127.0.0.1",
-		SSHPort:    p.sshPort,
+		AgentPort:  p.agentPort,
 		DockerPort: p.config.DockerPort,
 		Capabilities: map[string]bool{
 			"containers":         true,
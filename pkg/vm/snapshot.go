@@ -0,0 +1,257 @@
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Snapshot is a saved state of the VM it can be rolled back to
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created,omitzero"`
+	// Memory is set when the snapshot holds the memory of the running VM
+	// as well as its disk, so restoring resumes the VM instead of booting it
+	Memory bool `json:"memory"`
+}
+
+// SnapshotProvider is implemented by providers that can snapshot the VM
+// and roll it back
+type SnapshotProvider interface {
+	CreateSnapshot(name string) error
+	RestoreSnapshot(name string) error
+	ListSnapshots() ([]*Snapshot, error)
+	DeleteSnapshot(name string) error
+}
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidateSnapshotName checks that a snapshot name is usable with every
+// backend: letters, digits, '_', '.' and '-', at most 64 characters
+func ValidateSnapshotName(name string) error {
+	if !snapshotNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use letters, digits, '_', '.' and '-' (at most 64)", name)
+	}
+	return nil
+}
+
+// findSnapshot returns the named snapshot of the list, or an error naming
+// the VM when there is none
+func findSnapshot(snapshots []*Snapshot, name, vmName string) (*Snapshot, error) {
+	for _, s := range snapshots {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("VM %s has no snapshot %q", vmName, name)
+}
+
+// qemuSnapshots manages the internal snapshots of a QEMU VM's qcow2 disk.
+// While QEMU runs they are taken through its monitor and include memory;
+// while it is stopped qemu-img snapshots the disk alone.
+type qemuSnapshots struct {
+	name    string
+	disk    string
+	monitor string // HMP monitor socket, see monitorArgs
+}
+
+// monitorArgs are the QEMU arguments serving the human monitor on the socket
+func (q qemuSnapshots) monitorArgs() []string {
+	return []string{"-monitor", fmt.Sprintf("unix:%s,server,nowait", q.monitor)}
+}
+
+// pendingPath records a snapshot restored while QEMU was stopped, to be
+// loaded, memory included, when it next starts
+func (q qemuSnapshots) pendingPath() string {
+	return filepath.Join(filepath.Dir(q.disk), "restore-snapshot")
+}
+
+// startArgs are the QEMU arguments resuming a snapshot restored while the
+// VM was stopped, if there is one; it is only loaded once
+func (q qemuSnapshots) startArgs() []string {
+	data, err := os.ReadFile(q.pendingPath())
+	if err != nil {
+		return nil
+	}
+	os.Remove(q.pendingPath())
+	if name := strings.TrimSpace(string(data)); name != "" {
+		return []string{"-loadvm", name}
+	}
+	return nil
+}
+
+// running reports whether QEMU answers on its monitor
+func (q qemuSnapshots) running() bool {
+	conn, err := net.DialTimeout("unix", q.monitor, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func (q qemuSnapshots) create(name string) error {
+	if err := ValidateSnapshotName(name); err != nil {
+		return err
+	}
+	if existing, err := q.list(); err == nil {
+		if _, err := findSnapshot(existing, name, q.name); err == nil {
+			return fmt.Errorf("VM %s already has a snapshot %q", q.name, name)
+		}
+	}
+
+	if q.running() {
+		_, err := q.hmp("savevm " + name)
+		return err
+	}
+	return q.qemuImg("-c", name)
+}
+
+func (q qemuSnapshots) restore(name string) error {
+	snapshots, err := q.list()
+	if err != nil {
+		return err
+	}
+	snapshot, err := findSnapshot(snapshots, name, q.name)
+	if err != nil {
+		return err
+	}
+
+	if q.running() {
+		if !snapshot.Memory {
+			return fmt.Errorf("snapshot %q holds no memory and can only be restored while the VM is stopped", name)
+		}
+		_, err := q.hmp("loadvm " + name)
+		return err
+	}
+
+	if err := q.qemuImg("-a", name); err != nil {
+		return err
+	}
+	if snapshot.Memory {
+		return os.WriteFile(q.pendingPath(), []byte(name+"\n"), 0644)
+	}
+	return nil
+}
+
+func (q qemuSnapshots) remove(name string) error {
+	snapshots, err := q.list()
+	if err != nil {
+		return err
+	}
+	if _, err := findSnapshot(snapshots, name, q.name); err != nil {
+		return err
+	}
+
+	if q.running() {
+		_, err := q.hmp("delvm " + name)
+		return err
+	}
+	return q.qemuImg("-d", name)
+}
+
+func (q qemuSnapshots) list() ([]*Snapshot, error) {
+	if _, err := os.Stat(q.disk); err != nil {
+		return nil, fmt.Errorf("VM %s has not been created", q.name)
+	}
+	// -U reads the snapshot table while a running QEMU holds the disk lock
+	output, err := exec.Command("qemu-img", "snapshot", "-U", "-l", q.disk).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %v, output: %s", err, string(output))
+	}
+	return parseQemuSnapshots(string(output)), nil
+}
+
+// qemuImg runs 'qemu-img snapshot' with an operation flag on the disk
+func (q qemuSnapshots) qemuImg(op, name string) error {
+	output, err := exec.Command("qemu-img", "snapshot", op, name, q.disk).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("qemu-img snapshot %s %s failed: %v, output: %s", op, name, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// hmpTimeout bounds a monitor command; saving memory takes a while
+const hmpTimeout = 5 * time.Minute
+
+// hmp runs a command on the QEMU human monitor and returns its output.
+// The monitor reports failures as text, so output starting with "Error"
+// is returned as an error.
+func (q qemuSnapshots) hmp(command string) (string, error) {
+	conn, err := net.DialTimeout("unix", q.monitor, 5*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to the QEMU monitor: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(hmpTimeout))
+
+	reader := bufio.NewReader(conn)
+	if _, err := readUntilPrompt(reader); err != nil {
+		return "", fmt.Errorf("QEMU monitor did not answer: %v", err)
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", command); err != nil {
+		return "", err
+	}
+	output, err := readUntilPrompt(reader)
+	if err != nil {
+		return "", fmt.Errorf("QEMU monitor did not answer %q: %v", command, err)
+	}
+
+	// The monitor echoes the command, with terminal escapes, first
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(ansiEscape.ReplaceAllString(line, ""))
+		if line != "" && !strings.HasSuffix(line, command) {
+			lines = append(lines, line)
+		}
+	}
+	result := strings.Join(lines, "\n")
+	if strings.HasPrefix(result, "Error") || strings.Contains(result, "\nError") {
+		return "", fmt.Errorf("%s: %s", command, result)
+	}
+	return result, nil
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]|\r`)
+
+// readUntilPrompt reads monitor output up to the next "(qemu) " prompt
+func readUntilPrompt(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return sb.String(), err
+		}
+		sb.WriteByte(b)
+		if strings.HasSuffix(sb.String(), "(qemu) ") {
+			return strings.TrimSuffix(sb.String(), "(qemu) "), nil
+		}
+	}
+}
+
+var snapshotDate = regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`)
+
+// parseQemuSnapshots parses 'qemu-img snapshot -l', whose rows are
+// "ID TAG VM-SIZE DATE VM-CLOCK [ICOUNT]"; a VM size of 0 means the
+// snapshot holds no memory
+func parseQemuSnapshots(output string) []*Snapshot {
+	var snapshots []*Snapshot
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] == "ID" || fields[0] == "Snapshot" {
+			continue
+		}
+		s := &Snapshot{Name: fields[1], Memory: fields[2] != "0"}
+		if date := snapshotDate.FindString(line); date != "" {
+			s.Created, _ = time.ParseInLocation("2006-01-02 15:04:05", date, time.Local)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots
+}
@@ -13,11 +13,25 @@ import (
 type UniversalDevelopmentVMProvider struct {
 	config     *VMConfig
 	vmPath     string
-	sshPort    int
+	agentPort  int
 	running    bool
 	containers map[string]*ContainerInfo
 }
 
+// developmentBackend is the name of the simulated backend used in
+// development mode
+const developmentBackend = "development"
+
+func init() {
+	RegisterBackend(Backend{
+		Name:         developmentBackend,
+		Description:  "Simulated VM for development and testing (SERVIN_DEV_MODE=1 or --dev)",
+		Priority:     100,
+		Acceleration: "none",
+		New:          NewDevelopmentVMProvider,
+	})
+}
+
 // NewDevelopmentVMProvider creates a new development VM provider for all platforms
 func NewDevelopmentVMProvider(config *VMConfig) (VMProvider, error) {
 	homeDir, err := os.UserHomeDir()
@@ -30,7 +44,7 @@ func NewDevelopmentVMProvider(config *VMConfig) (VMProvider, error) {
 	provider := &UniversalDevelopmentVMProvider{
 		config:     config,
 		vmPath:     vmPath,
-		sshPort:    config.SSHPort,
+		agentPort:  config.AgentPort,
 		running:    false,
 		containers: make(map[string]*ContainerInfo),
 	}
@@ -117,7 +131,7 @@ func (p *UniversalDevelopmentVMProvider) GetInfo() (*VMInfo, error) {
 		CPUs:       p.config.CPUs,
 		Memory:     p.config.Memory,
 		IPAddress:  "127.0.0.1",
-		SSHPort:    p.sshPort,
+		AgentPort:  p.agentPort,
 		DockerPort: p.config.DockerPort,
 	}, nil
 }
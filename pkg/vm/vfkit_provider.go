@@ -0,0 +1,553 @@
+//go:build darwin
+
+package vm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"servin/pkg/vm/agent"
+)
+
+// VfkitProvider runs the VM with Apple's Virtualization.framework through
+// vfkit. The macOS directories volumes are mounted from are shared with
+// virtio-fs, and on Apple Silicon the guest runs x86_64 binaries with
+// Rosetta, so linux/amd64 images work without emulating a whole machine.
+type VfkitProvider struct {
+	guestOps
+	config *VMConfig
+	vmPath string
+	agent  *agentGuest
+}
+
+func init() {
+	RegisterBackend(Backend{
+		Name:         "vfkit",
+		Description:  "Virtualization.framework through vfkit, with virtio-fs and Rosetta",
+		Platforms:    []string{"darwin"},
+		Priority:     0,
+		Acceleration: "hardware",
+		Available: func() error {
+			if _, err := exec.LookPath("vfkit"); err != nil {
+				return fmt.Errorf("vfkit not found (brew install vfkit)")
+			}
+			return nil
+		},
+		New: NewVfkitProvider,
+	})
+}
+
+// rosettaRuntime is present once Rosetta is installed on the host
+const rosettaRuntime = "/Library/Apple/usr/libexec/oah/libRosettaRuntime"
+
+// rosettaTag is the mount tag of the Rosetta share in the guest
+const rosettaTag = "rosetta"
+
+// NewVfkitProvider creates a new vfkit provider
+func NewVfkitProvider(config *VMConfig) (VMProvider, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %v", err)
+	}
+
+	vmPath := filepath.Join(homeDir, ".servin", "vms", config.Name)
+	p := &VfkitProvider{
+		config: config,
+		vmPath: vmPath,
+	}
+	// The guest is on the vmnet NAT network and reached at its own address
+	p.agent = &agentGuest{vmPath: vmPath, addr: p.agentAddr}
+	p.guestOps = guestOps{guest: p.agent, up: p.IsRunning}
+	return p, nil
+}
+
+// Create downloads the kernel and initramfs and creates the VM's disk
+func (p *VfkitProvider) Create(config *VMConfig) error {
+	if err := os.MkdirAll(p.vmPath, 0755); err != nil {
+		return fmt.Errorf("failed to create VM directory: %v", err)
+	}
+
+	kernelPath := p.path("vmlinuz-virt")
+	if !fileExists(kernelPath) {
+		fmt.Println("Downloading Alpine kernel...")
+		url := "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/aarch64/netboot-3.19.1/vmlinuz-virt"
+		if runtime.GOARCH == "amd64" {
+			url = "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/x86_64/netboot-3.19.1/vmlinuz-virt"
+		}
+		if err := downloadAsset(p.config, "kernel", url, kernelPath); err != nil {
+			return fmt.Errorf("failed to download kernel: %v", err)
+		}
+	}
+	if err := uncompressKernel(kernelPath, p.path("Image")); err != nil {
+		return err
+	}
+
+	initrdPath := p.path("initramfs-virt")
+	if !fileExists(initrdPath) {
+		fmt.Println("Downloading Alpine initramfs...")
+		url := "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/aarch64/netboot-3.19.1/initramfs-virt"
+		if runtime.GOARCH == "amd64" {
+			url = "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/x86_64/netboot-3.19.1/initramfs-virt"
+		}
+		if err := downloadAsset(p.config, "initramfs", url, initrdPath); err != nil {
+			return fmt.Errorf("failed to download initramfs: %v", err)
+		}
+	}
+
+	// Virtualization.framework only takes raw disks; a sparse file takes no
+	// space until the guest writes to it
+	diskPath := p.path("disk.img")
+	if !fileExists(diskPath) {
+		f, err := os.Create(diskPath)
+		if err != nil {
+			return fmt.Errorf("failed to create disk image: %v", err)
+		}
+		err = f.Truncate(int64(p.config.DiskSize) << 30)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to size disk image: %v", err)
+		}
+	}
+
+	_, err := p.macAddress()
+	return err
+}
+
+// Start boots the VM and waits for its agent
+func (p *VfkitProvider) Start() error {
+	if p.IsRunning() {
+		return nil
+	}
+	if err := p.Create(p.config); err != nil {
+		return err
+	}
+	if err := p.createSeedISO(); err != nil {
+		return fmt.Errorf("failed to create seed ISO: %v", err)
+	}
+
+	args, err := p.vfkitArgs()
+	if err != nil {
+		return err
+	}
+
+	logFile, err := os.Create(p.path("vfkit.log"))
+	if err != nil {
+		return fmt.Errorf("failed to create vfkit log: %v", err)
+	}
+	defer logFile.Close()
+
+	os.Remove(p.restSocket())
+	fmt.Printf("Starting vfkit with command: vfkit %s\n", strings.Join(args, " "))
+	cmd := exec.Command("vfkit", args...)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start vfkit: %v", err)
+	}
+	fmt.Printf("vfkit started with PID: %d\n", cmd.Process.Pid)
+	cmd.Process.Release()
+
+	fmt.Println("Waiting for Alpine Linux to boot and start the servin agent...")
+	for i := 0; i < 90; i++ {
+		if p.agent.reachable() {
+			addr, _ := p.agentAddr()
+			fmt.Printf("✅ VM is now running with its agent at %s\n", addr)
+			return nil
+		}
+		if i%5 == 0 {
+			fmt.Printf("Waiting for the VM agent... (%d/90 seconds)\n", i)
+		}
+		time.Sleep(1 * time.Second)
+	}
+
+	fmt.Println("⚠️  The VM agent is taking longer than expected to start")
+	fmt.Printf("The console log is at %s. If the setup did not run, run in the VM:\n", p.path("console.log"))
+	fmt.Printf("  mount /dev/vdb /mnt && /mnt/autosetup.sh\n")
+	return nil
+}
+
+// vfkitArgs builds the vfkit command line
+func (p *VfkitProvider) vfkitArgs() ([]string, error) {
+	mac, err := p.macAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	cmdline := "console=hvc0 ip=dhcp modules=loop,squashfs,sd-mod,virtio_blk alpine_repo=http://dl-cdn.alpinelinux.org/alpine/v3.19/main autosetup=cdrom"
+	args := []string{
+		"--cpus", strconv.Itoa(p.config.CPUs),
+		"--memory", strconv.Itoa(p.config.Memory),
+		"--bootloader", fmt.Sprintf("linux,kernel=%s,initrd=%s,cmdline=\"%s\"", p.path("Image"), p.path("initramfs-virt"), cmdline),
+		"--device", "virtio-blk,path=" + p.path("disk.img"),
+		"--device", "virtio-blk,path=" + p.path("seed.iso"),
+		"--device", "virtio-net,nat,mac=" + mac,
+		"--device", "virtio-rng",
+		"--device", "virtio-serial,logFilePath=" + p.path("console.log"),
+		"--restful-uri", "unix://" + p.restSocket(),
+	}
+	for _, share := range p.SharedFolders() {
+		args = append(args, "--device", fmt.Sprintf("virtio-fs,sharedDir=%s,mountTag=%s", share.HostPath, share.Tag))
+	}
+	if rosettaAvailable() {
+		args = append(args, "--device", "rosetta,mountTag="+rosettaTag)
+	}
+	return args, nil
+}
+
+// createSeedISO creates the seed ISO installing the agent, mounting the
+// shares and, where available, registering Rosetta
+func (p *VfkitProvider) createSeedISO() error {
+	tempDir := p.path("seed-temp")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	setup := []string{shareMountScript(p.SharedFolders(), shareVirtioFS)}
+	if rosettaAvailable() {
+		setup = append(setup, rosettaSetupScript)
+	}
+	if err := writeSeed(tempDir, p.vmPath, setup...); err != nil {
+		return err
+	}
+	return buildSeedISO(tempDir, p.path("seed.iso"))
+}
+
+// rosettaSetupScript mounts the Rosetta share and registers it with
+// binfmt_misc for x86_64 ELF binaries. The F flag opens the interpreter
+// now, so it also runs inside containers, which do not see /mnt/rosetta.
+const rosettaSetupScript = `
+# Run x86_64 binaries with Rosetta
+mkdir -p /mnt/rosetta
+mountpoint -q /mnt/rosetta || mount -t virtiofs ` + rosettaTag + ` /mnt/rosetta || true
+mountpoint -q /proc/sys/fs/binfmt_misc || mount -t binfmt_misc binfmt_misc /proc/sys/fs/binfmt_misc || true
+if [ -x /mnt/rosetta/rosetta ] && [ ! -e /proc/sys/fs/binfmt_misc/rosetta ]; then
+    echo ':rosetta:M::\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00:\xff\xff\xff\xff\xff\xfe\xfe\x00\xff\xff\xff\xff\xff\xff\xff\xff\xfe\xff\xff\xff:/mnt/rosetta/rosetta:OCF' > /proc/sys/fs/binfmt_misc/register
+    echo "Rosetta registered for x86_64 binaries"
+fi
+`
+
+// rosettaAvailable reports whether the guest can run x86_64 binaries with
+// Rosetta: the host is Apple Silicon and Rosetta is installed
+// (softwareupdate --install-rosetta)
+func rosettaAvailable() bool {
+	return runtime.GOARCH == "arm64" && fileExists(rosettaRuntime)
+}
+
+// Stop powers the guest off through its agent, and stops the VM through
+// vfkit if it does not go down
+func (p *VfkitProvider) Stop() error {
+	if !p.IsRunning() {
+		return nil
+	}
+
+	p.agent.exec(agent.ExecOptions{Argv: []string{"poweroff"}}) // Ignore errors as the VM might shut down before the agent answers
+	for i := 0; i < 15; i++ {
+		time.Sleep(1 * time.Second)
+		if !p.IsRunning() {
+			return nil
+		}
+	}
+
+	if err := p.setState("Stop"); err != nil {
+		return p.setState("HardStop")
+	}
+	return nil
+}
+
+// Pause freezes the running VM
+func (p *VfkitProvider) Pause() error {
+	return p.setState("Pause")
+}
+
+// Resume lets the paused VM continue
+func (p *VfkitProvider) Resume() error {
+	return p.setState("Resume")
+}
+
+// SharedFolders returns the directories shared with the VM over virtio-fs
+func (p *VfkitProvider) SharedFolders() []Share {
+	return hostShares()
+}
+
+// OpenConsole fails with ErrConsoleReadOnly: vfkit only logs the console
+func (p *VfkitProvider) OpenConsole() (io.ReadWriteCloser, error) {
+	return nil, ErrConsoleReadOnly
+}
+
+// ConsoleLog is the file vfkit logs the console output to
+func (p *VfkitProvider) ConsoleLog() string {
+	return p.path("console.log")
+}
+
+// Resize grows the VM's raw disk; new CPUs and memory are passed to vfkit
+// when the VM next starts
+func (p *VfkitProvider) Resize(res Resources) error {
+	if res.DiskSize == 0 {
+		return nil
+	}
+	return resizeRaw(p.path("disk.img"), res.DiskSize)
+}
+
+// Destroy removes the VM completely
+func (p *VfkitProvider) Destroy() error {
+	p.Stop()
+	return os.RemoveAll(p.vmPath)
+}
+
+// IsRunning asks vfkit for the state of the VM
+func (p *VfkitProvider) IsRunning() bool {
+	state, err := p.state()
+	if err != nil {
+		return false
+	}
+	return state == "VirtualMachineStateRunning"
+}
+
+// GetInfo returns VM information
+func (p *VfkitProvider) GetInfo() (*VMInfo, error) {
+	status := "stopped"
+	switch state, _ := p.state(); state {
+	case "VirtualMachineStateRunning":
+		status = "running"
+	case "VirtualMachineStatePaused":
+		status = "paused"
+	}
+	ip, _ := p.guestIP()
+
+	return &VMInfo{
+		Name:       p.config.Name,
+		Status:     status,
+		Platform:   "macOS",
+		Provider:   "Virtualization.framework (vfkit)",
+		CPUs:       p.config.CPUs,
+		Memory:     p.config.Memory,
+		IPAddress:  ip,
+		AgentPort:  agent.GuestPort,
+		DockerPort: p.config.DockerPort,
+		Uptime:     p.agent.uptime(),
+		Capabilities: map[string]bool{
+			"containers":   true,
+			"networking":   true,
+			"volumes":      true,
+			"port_forward": true,
+			"virtiofs":     true,
+			"rosetta":      rosettaAvailable(),
+		},
+	}, nil
+}
+
+func (p *VfkitProvider) path(name string) string {
+	return filepath.Join(p.vmPath, name)
+}
+
+// restSocket is the socket of vfkit's REST API, which reports and changes
+// the state of the VM
+func (p *VfkitProvider) restSocket() string {
+	return p.path("vfkit.sock")
+}
+
+func (p *VfkitProvider) restClient() *http.Client {
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", p.restSocket())
+			},
+		},
+	}
+}
+
+// state returns the VM state vfkit reports, e.g. VirtualMachineStateRunning
+func (p *VfkitProvider) state() (string, error) {
+	resp, err := p.restClient().Get("http://vfkit/vm/state")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode vfkit state: %v", err)
+	}
+	return body.State, nil
+}
+
+// setState asks vfkit to change the VM state: Stop, HardStop, Pause or Resume
+func (p *VfkitProvider) setState(state string) error {
+	body, _ := json.Marshal(map[string]string{"state": state})
+	resp, err := p.restClient().Post("http://vfkit/vm/state", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to reach vfkit: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		output, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vfkit refused %s: %s", state, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// macAddress returns the MAC address of the VM's network interface,
+// generated once so the guest keeps its DHCP lease across restarts
+func (p *VfkitProvider) macAddress() (string, error) {
+	path := p.path("mac")
+	if data, err := os.ReadFile(path); err == nil {
+		if mac := strings.TrimSpace(string(data)); mac != "" {
+			return mac, nil
+		}
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate MAC address: %v", err)
+	}
+	// Locally administered, unicast
+	b[0] = (b[0] | 0x02) &^ 0x01
+	mac := net.HardwareAddr(b).String()
+	if err := os.WriteFile(path, []byte(mac+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write MAC address: %v", err)
+	}
+	return mac, nil
+}
+
+// dhcpLeases is where macOS's vmnet DHCP server records the addresses it
+// gives VMs
+const dhcpLeases = "/var/db/dhcpd_leases"
+
+// guestIP returns the address the VM leased for its MAC address
+func (p *VfkitProvider) guestIP() (string, error) {
+	mac, err := p.macAddress()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(dhcpLeases)
+	if err != nil {
+		return "", fmt.Errorf("VM %s has no address yet: %v", p.config.Name, err)
+	}
+	defer f.Close()
+
+	if ip := findLease(f, mac); ip != "" {
+		return ip, nil
+	}
+	return "", fmt.Errorf("VM %s has no address yet", p.config.Name)
+}
+
+// findLease returns the address of the most recent lease for mac in
+// dhcpd_leases, whose entries are blocks of key=value lines. The file drops
+// leading zeros from the MAC's bytes, so they are compared as numbers.
+func findLease(r io.Reader, mac string) string {
+	want, err := net.ParseMAC(mac)
+	if err != nil {
+		return ""
+	}
+
+	var ip, found string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			if strings.TrimSpace(scanner.Text()) == "{" {
+				ip = ""
+			}
+			continue
+		}
+		switch key {
+		case "ip_address":
+			ip = value
+		case "hw_address":
+			// "1,a:b:c:d:e:f", the 1 being the hardware type
+			_, addr, _ := strings.Cut(value, ",")
+			if sameMAC(addr, want) && ip != "" && found == "" {
+				// Leases are listed newest first
+				found = ip
+			}
+		}
+	}
+	return found
+}
+
+func sameMAC(s string, mac net.HardwareAddr) bool {
+	parts := strings.Split(s, ":")
+	if len(parts) != len(mac) {
+		return false
+	}
+	for i, part := range parts {
+		b, err := strconv.ParseUint(part, 16, 8)
+		if err != nil || byte(b) != mac[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// agentAddr returns the agent's address on the VM's leased address
+func (p *VfkitProvider) agentAddr() (string, error) {
+	ip, err := p.guestIP()
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ip, strconv.Itoa(agent.GuestPort)), nil
+}
+
+// uncompressKernel writes the kernel Virtualization.framework boots to dst.
+// It does not boot compressed arm64 kernels, so a gzipped one is unpacked;
+// others are copied as they are.
+func uncompressKernel(src, dst string) error {
+	if fileExists(dst) {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open kernel: %v", err)
+	}
+	defer in.Close()
+
+	var reader io.Reader = in
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(in, magic); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		in.Seek(0, io.SeekStart)
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to uncompress kernel: %v", err)
+		}
+		defer gz.Close()
+		reader = gz
+	} else {
+		in.Seek(0, io.SeekStart)
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write kernel: %v", err)
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to uncompress kernel: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -1,10 +1,17 @@
 package vm
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
+
+	"servin/pkg/stats"
+	"servin/pkg/telemetry"
 )
 
 // VMProvider represents different virtualization backends per platform
@@ -34,6 +41,93 @@ type VMProvider interface {
 	RemovePortForward(hostPort int) error
 }
 
+// StatsProvider is implemented by providers whose guest can report resource
+// usage for the containers running inside the VM
+type StatsProvider interface {
+	ContainerStats(ids []string) ([]*stats.Stats, error)
+}
+
+// ProcessProvider is implemented by providers whose guest can list the
+// processes running in a container
+type ProcessProvider interface {
+	ContainerTop(id string) ([]byte, error)
+}
+
+// ExecProvider is implemented by providers that can run a command in a
+// container inside the VM, attached to servin's stdio. With tty set the
+// session gets a terminal in the guest that follows resizes of this one.
+type ExecProvider interface {
+	ContainerExec(id string, command []string, interactive, tty bool) error
+}
+
+// CopyProvider is implemented by providers that can copy files in and out
+// of a container inside the VM, as tar archives of the kind servin cp
+// streams with "-"
+type CopyProvider interface {
+	CopyToContainer(id, dest string, archive io.Reader) error
+	CopyFromContainer(id, src string, followLink bool, archive io.Writer) error
+}
+
+// BackupProvider is implemented by providers that can save the whole VM to
+// a file and replace it with one saved before
+type BackupProvider interface {
+	Export(file string) error
+	Import(file string) error
+}
+
+// guestStatsArgs builds the command the guest runs to report container stats
+func guestStatsArgs(ids []string) []string {
+	return append([]string{guestServinPath, "stats", "--no-stream", "--format", "json"}, ids...)
+}
+
+// guestTopArgs builds the command the guest runs to list a container's processes
+func guestTopArgs(id string) []string {
+	return []string{guestServinPath, "top", id}
+}
+
+// guestExecArgs builds the command the guest runs to execute a command in a
+// container
+func guestExecArgs(id string, command []string, interactive, tty bool) []string {
+	args := []string{guestServinPath, "exec"}
+	if interactive {
+		args = append(args, "--interactive")
+	}
+	if tty {
+		args = append(args, "--tty")
+	}
+	args = append(args, id)
+	return append(args, command...)
+}
+
+// guestCopyToArgs builds the command the guest runs to unpack an archive
+// read from its input into a container
+func guestCopyToArgs(id, dest string) []string {
+	return []string{guestServinPath, "cp", "-", id + ":" + dest}
+}
+
+// guestCopyFromArgs builds the command the guest runs to write an archive
+// of a container path to its output
+func guestCopyFromArgs(id, src string, followLink bool) []string {
+	args := []string{guestServinPath, "cp"}
+	if followLink {
+		args = append(args, "--follow-link")
+	}
+	return append(args, id+":"+src, "-")
+}
+
+// decodeGuestStats parses the JSON stats reported by the servin binary inside the VM
+func decodeGuestStats(output []byte) ([]*stats.Stats, error) {
+	var result []*stats.Stats
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse stats from VM: %v", err)
+	}
+	return result, nil
+}
+
+// DefaultAgentPort is the host port forwarded to the guest agent, unless
+// the VM configuration names another or it is taken
+const DefaultAgentPort = 7700
+
 // VMConfig represents VM configuration
 type VMConfig struct {
 	Name             string            `json:"name"`
@@ -42,10 +136,17 @@ type VMConfig struct {
 	DiskSize         int               `json:"disk_size_gb"`
 	LinuxDistro      string            `json:"linux_distro"`      // "alpine", "ubuntu", "debian"
 	ContainerRuntime string            `json:"container_runtime"` // "docker", "containerd", "podman"
-	SSHPort          int               `json:"ssh_port"`
+	AgentPort        int               `json:"agent_port"`        // host port forwarded to the guest agent
 	DockerPort       int               `json:"docker_port"`
 	WorkDir          string            `json:"work_dir"`
 	Environment      map[string]string `json:"environment"`
+	// Remote is the host of a VM that runs its containers on an existing
+	// Linux machine over SSH instead of a local VM
+	Remote *RemoteConfig `json:"remote,omitempty"`
+
+	// Downloads reports the progress of VM asset downloads and lets the
+	// caller cancel them; it is not part of the saved configuration
+	Downloads DownloadOptions `json:"-"`
 }
 
 // VMInfo represents VM status and information
@@ -59,9 +160,22 @@ type VMInfo struct {
 	DiskUsage    int             `json:"disk_usage_mb"`
 	Uptime       string          `json:"uptime"`
 	IPAddress    string          `json:"ip_address"`
-	SSHPort      int             `json:"ssh_port"`
+	AgentPort    int             `json:"agent_port"`
 	DockerPort   int             `json:"docker_port"`
 	Capabilities map[string]bool `json:"capabilities"`
+	// LastEvent is the last start, stop or crash of the VM, for providers
+	// that track them
+	LastEvent *LifecycleEvent `json:"last_event,omitempty"`
+	// Services are the servin services supervised in the guest, for
+	// providers that supervise them
+	Services []ServiceHealth `json:"services,omitempty"`
+}
+
+// ServiceHealth is the state of a service servin runs in the guest
+type ServiceHealth struct {
+	Name   string `json:"name"`
+	State  string `json:"state"` // e.g. running, stopped, healthy, unreachable
+	Detail string `json:"detail,omitempty"`
 }
 
 // ContainerConfig represents container configuration for VM
@@ -97,25 +211,23 @@ type ContainerInfo struct {
 	Command string            `json:"command"`
 }
 
-// GetVMProvider returns the appropriate VM provider for the current platform
+// GetVMProvider returns the VM provider of the backend selected for this
+// host: see selectBackend. VMs created with a remote host use the remote
+// backend.
 func GetVMProvider(config *VMConfig) (VMProvider, error) {
-	// Check if we're in development mode
-	if isDevelopmentMode() {
-		// Use universal development provider for all platforms in dev mode
-		return NewDevelopmentVMProvider(config)
+	if config.Remote != nil && !isDevelopmentMode() {
+		backend, _ := LookupBackend(remoteBackend)
+		if err := backend.Check(); err != nil {
+			return nil, fmt.Errorf("VM provider %s is not available: %v", backend.Name, err)
+		}
+		return backend.New(config)
 	}
 
-	// Production VM providers
-	switch runtime.GOOS {
-	case "darwin": // macOS
-		return NewVirtualizationFrameworkProvider(config)
-	case "windows":
-		return NewHyperVProvider(config)
-	case "linux":
-		return NewKVMProvider(config)
-	default:
-		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+	backend, err := selectBackend()
+	if err != nil {
+		return nil, err
 	}
+	return backend.New(config)
 }
 
 // isDevelopmentMode checks if we're running in development mode
@@ -144,7 +256,7 @@ func DefaultVMConfig(name string) *VMConfig {
 		DiskSize:         20,   // 20GB
 		LinuxDistro:      "alpine",
 		ContainerRuntime: "docker",
-		SSHPort:          2222,
+		AgentPort:        DefaultAgentPort,
 		DockerPort:       2375,
 		WorkDir:          "/servin",
 		Environment: map[string]string{
@@ -172,17 +284,127 @@ func NewVMManager(config *VMConfig) (*VMManager, error) {
 	}, nil
 }
 
+// NoAutoVMEnvVar turns automatic VM provisioning off when set to 1 or true
+const NoAutoVMEnvVar = "SERVIN_NO_AUTO_VM"
+
+// defaultAutoProvision is whether container commands create and boot a VM
+// that has not been created yet. Linux runs containers natively without one.
+const defaultAutoProvision = runtime.GOOS != "linux"
+
+var autoProvision = defaultAutoProvision
+
+// SetAutoProvision sets whether container commands create and boot a VM
+// that has not been created yet, as 'servin vm start' does
+func SetAutoProvision(enabled bool) {
+	autoProvision = enabled
+}
+
+// ResetAutoProvision undoes SetAutoProvision, for a process that runs
+// several commands
+func ResetAutoProvision() {
+	autoProvision = defaultAutoProvision
+}
+
+// Files in the VM's directory marking a VM being created and one whose
+// creation completed
+const (
+	creatingFile = "creating"
+	createdFile  = "created"
+)
+
+func (vm *VMManager) vmDir() (string, error) {
+	dir, err := servinDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vms", vm.Config.Name), nil
+}
+
+// Created reports whether the VM has been created. A creation that did not
+// complete is started again; the directory of a VM created by an earlier
+// version, which is not marked, is taken as created unless it only holds
+// the agent token, which is generated as soon as the agent is looked for.
+func (vm *VMManager) Created() bool {
+	dir, err := vm.vmDir()
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(dir, createdFile)); err == nil {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(dir, creatingFile)); err == nil {
+		return false
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.Name() != agentTokenFile {
+			return true
+		}
+	}
+	return false
+}
+
+// create creates the VM and marks it created
+func (vm *VMManager) create() error {
+	dir, err := vm.vmDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create VM directory: %v", err)
+	}
+	now := []byte(time.Now().Format(time.RFC3339) + "\n")
+	if err := os.WriteFile(filepath.Join(dir, creatingFile), now, 0644); err != nil {
+		return err
+	}
+
+	if err := vm.Provider.Create(vm.Config); err != nil {
+		return fmt.Errorf("failed to create VM: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, createdFile), now, 0644); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(dir, creatingFile))
+}
+
 // EnsureRunning ensures the VM is created and running
 func (vm *VMManager) EnsureRunning() error {
+	return vm.ensureRunning(true)
+}
+
+// AutoStart ensures the VM is running for a container command. A VM that
+// has not been created yet is provisioned from its configuration first,
+// unless automatic provisioning is off.
+func (vm *VMManager) AutoStart() error {
+	return vm.ensureRunning(autoProvision)
+}
+
+func (vm *VMManager) ensureRunning(provision bool) (err error) {
+	span := telemetry.StartSpan("vm.ensure_running", nil)
+	span.SetAttribute("vm.name", vm.Config.Name)
+	defer func() { span.Finish(err) }()
+
 	if vm.Provider.IsRunning() {
+		span.SetAttribute("vm.already_running", "true")
 		return nil
 	}
 
-	// Check if VM exists, create if not
-	info, err := vm.Provider.GetInfo()
-	if err != nil || info == nil {
-		if err := vm.Provider.Create(vm.Config); err != nil {
-			return fmt.Errorf("failed to create VM: %v", err)
+	if !vm.Created() {
+		if !provision {
+			return fmt.Errorf("VM %s has not been created: run 'servin vm start' (automatic provisioning is off)", vm.Config.Name)
+		}
+		span.SetAttribute("vm.provisioned", "true")
+		if vm.Config.Remote != nil {
+			fmt.Printf("Setting up VM %s on %s...\n", vm.Config.Name, vm.Config.Remote)
+		} else {
+			fmt.Printf("Creating VM %s (%d CPUs, %d MB memory, %d GB disk)...\n", vm.Config.Name, vm.Config.CPUs, vm.Config.Memory, vm.Config.DiskSize)
+		}
+		if err := vm.create(); err != nil {
+			return err
 		}
 	}
 
@@ -196,14 +418,40 @@ func (vm *VMManager) EnsureRunning() error {
 
 // RunContainer runs a container inside the VM
 func (vm *VMManager) RunContainer(config *ContainerConfig) (*ContainerResult, error) {
-	if err := vm.EnsureRunning(); err != nil {
+	if err := vm.AutoStart(); err != nil {
 		return nil, err
 	}
 
-	return vm.Provider.RunContainer(config)
+	span := telemetry.StartSpan("vm.run_container", nil)
+	span.SetAttribute("container.image", config.Image)
+	span.SetAttribute("vm.name", vm.Config.Name)
+	result, err := vm.Provider.RunContainer(config)
+	span.Finish(err)
+
+	return result, err
 }
 
 // Shutdown gracefully shuts down the VM
 func (vm *VMManager) Shutdown() error {
 	return vm.Provider.Stop()
 }
+
+// Destroy stops the VM and deletes it along with its disk
+func (vm *VMManager) Destroy() (err error) {
+	span := telemetry.StartSpan("vm.destroy", nil)
+	span.SetAttribute("vm.name", vm.Config.Name)
+	defer func() { span.Finish(err) }()
+
+	if vm.Provider.IsRunning() {
+		if err := vm.Provider.Stop(); err != nil {
+			return fmt.Errorf("failed to stop VM: %v", err)
+		}
+	}
+	if err := vm.Provider.Destroy(); err != nil {
+		return err
+	}
+	if dir, err := vm.vmDir(); err == nil {
+		os.Remove(filepath.Join(dir, createdFile))
+	}
+	return nil
+}
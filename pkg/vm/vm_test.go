@@ -195,13 +195,13 @@ func BenchmarkVMCreation(b *testing.B) {
 	}
 }
 
-// TestVMSSHConnectivity tests SSH connectivity after VM start
-func TestVMSSHConnectivity(t *testing.T) {
+// TestVMAgentConnectivity tests guest agent connectivity after VM start
+func TestVMAgentConnectivity(t *testing.T) {
 	if testing.Short() {
-		t.Skip("Skipping SSH connectivity test in short mode")
+		t.Skip("Skipping agent connectivity test in short mode")
 	}
 
-	config := DefaultVMConfig("ssh-test-vm")
+	config := DefaultVMConfig("agent-test-vm")
 	config.Memory = 1024
 	config.CPUs = 1
 
@@ -222,26 +222,26 @@ func TestVMSSHConnectivity(t *testing.T) {
 
 	defer provider.Stop()
 
-	// Wait for SSH to be ready (with timeout)
+	// Wait for the agent to be ready (with timeout)
 	maxWait := 120 * time.Second
 	start := time.Now()
-	sshReady := false
+	agentReady := false
 
 	for time.Since(start) < maxWait {
 		info, err := provider.GetInfo()
-		if err == nil && info.Capabilities["ssh_access"] {
-			sshReady = true
+		if err == nil && info.Capabilities["agent"] {
+			agentReady = true
 			break
 		}
 		time.Sleep(5 * time.Second)
 	}
 
-	if !sshReady {
-		t.Errorf("SSH not ready after %v on %s", maxWait, runtime.GOOS)
+	if !agentReady {
+		t.Errorf("Agent not ready after %v on %s", maxWait, runtime.GOOS)
 		return
 	}
 
-	t.Logf("✅ SSH connectivity test passed on %s", runtime.GOOS)
+	t.Logf("✅ Agent connectivity test passed on %s", runtime.GOOS)
 }
 
 // TestContainerOperations tests basic container operations in VM
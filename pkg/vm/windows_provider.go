@@ -4,26 +4,77 @@ package vm
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
-	"net"
+
+	"servin/pkg/vm/agent"
 )
 
-// HyperVProvider implements VM operations using Windows Hyper-V or VirtualBox
+// HyperVProvider implements VM operations using Windows Hyper-V, WSL2 or
+// VirtualBox
 type HyperVProvider struct {
-	config     *VMConfig
-	vmPath     string
-	sshPort    int
-	running    bool
-	vmBackend  string // "hyperv" or "virtualbox" or "wsl2"
+	guestOps
+	config    *VMConfig
+	vmPath    string
+	agentPort int
+	agent     *agentGuest // nil for WSL2, reached with wsl.exe
+	running   bool
+	vmBackend string // "hyperv" or "virtualbox" or "wsl2"
+}
+
+func init() {
+	for _, b := range []struct {
+		name, description string
+		priority          int
+		available         func() bool
+	}{
+		{"hyperv", "Hyper-V", 1, hyperVAvailable},
+		{"wsl2", "WSL2 (Windows Subsystem for Linux)", 2, wsl2Available},
+		{"virtualbox", "VirtualBox", 3, virtualBoxAvailable},
+	} {
+		b := b
+		RegisterBackend(Backend{
+			Name:         b.name,
+			Description:  b.description,
+			Platforms:    []string{"windows"},
+			Priority:     b.priority,
+			Acceleration: "hardware",
+			Available: func() error {
+				if !b.available() {
+					return fmt.Errorf("%s is not installed or enabled", b.description)
+				}
+				return nil
+			},
+			New: func(config *VMConfig) (VMProvider, error) {
+				return newHyperVProvider(config, b.name)
+			},
+		})
+	}
+}
+
+// NewHyperVProvider creates a provider for the best available backend:
+// Hyper-V, then WSL2, then VirtualBox
+func NewHyperVProvider(config *VMConfig) (VMProvider, error) {
+	switch {
+	case hyperVAvailable():
+		return newHyperVProvider(config, "hyperv")
+	case wsl2Available():
+		return newHyperVProvider(config, "wsl2")
+	case virtualBoxAvailable():
+		return newHyperVProvider(config, "virtualbox")
+	default:
+		return nil, fmt.Errorf("no supported virtualization backend found (Hyper-V, WSL2, or VirtualBox)")
+	}
 }
 
-// NewHyperVProvider creates a new Hyper-V provider
-func NewHyperVProvider(config *VMConfig) (VMProvider, error) {
+// newHyperVProvider creates a provider for the given backend
+func newHyperVProvider(config *VMConfig, backend string) (VMProvider, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %v", err)
@@ -31,49 +82,42 @@ func NewHyperVProvider(config *VMConfig) (VMProvider, error) {
 
 	vmPath := filepath.Join(homeDir, ".servin", "vms", config.Name)
 
-	// Find available SSH port
-	sshPort := config.SSHPort
-	if sshPort == 0 {
-		sshPort = 2222
-	}
-	if !isPortAvailable(sshPort) {
-		for port := 2222; port <= 2299; port++ {
-			if isPortAvailable(port) {
-				sshPort = port
-				break
-			}
-		}
+	p := &HyperVProvider{
+		config:    config,
+		vmPath:    vmPath,
+		running:   false,
+		vmBackend: backend,
 	}
-
-	provider := &HyperVProvider{
-		config:  config,
-		vmPath:  vmPath,
-		sshPort: sshPort,
-		running: false,
-	}
-
-	// Determine the best backend
-	if provider.isHyperVAvailable() {
-		provider.vmBackend = "hyperv"
-	} else if provider.isWSL2Available() {
-		provider.vmBackend = "wsl2"
-	} else if provider.isVirtualBoxAvailable() {
-		provider.vmBackend = "virtualbox"
-	} else {
-		return nil, fmt.Errorf("no supported virtualization backend found (Hyper-V, WSL2, or VirtualBox)")
+	switch backend {
+	case "wsl2":
+		p.guestOps = guestOps{guest: &wsl2Guest{distro: p.wslDistro()}, up: p.IsRunning}
+		return p, nil
+	case "hyperv":
+		// Hyper-V guests are reached directly on their address on the
+		// virtual switch
+		p.agentPort = agent.GuestPort
+		p.agent = &agentGuest{vmPath: vmPath, addr: func() (string, error) { return hyperVAgentAddr(config.Name) }}
+	default:
+		p.agentPort = pickAgentPort(config.AgentPort)
+		p.agent = localAgent(vmPath, p.agentPort)
 	}
-
-	return provider, nil
+	p.guestOps = guestOps{guest: p.agent, up: p.IsRunning}
+	return p, nil
 }
 
-// isPortAvailable checks if a port is available for use
-func isPortAvailable(port int) bool {
-	conn, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+// hyperVAgentAddr returns the agent address of a Hyper-V VM from the IPv4
+// address its network adapter reports
+func hyperVAgentAddr(name string) (string, error) {
+	script := fmt.Sprintf(`(Get-VMNetworkAdapter -VMName '%s').IPAddresses | Where-Object { $_ -match '^\d+\.\d+\.\d+\.\d+$' } | Select-Object -First 1`, name)
+	output, err := exec.Command("powershell", "-Command", script).Output()
 	if err != nil {
-		return false
+		return "", fmt.Errorf("failed to get address of Hyper-V VM %s: %v", name, err)
+	}
+	ip := strings.TrimSpace(string(output))
+	if ip == "" {
+		return "", fmt.Errorf("Hyper-V VM %s has no IPv4 address yet", name)
 	}
-	conn.Close()
-	return true
+	return net.JoinHostPort(ip, strconv.Itoa(agent.GuestPort)), nil
 }
 
 // Create creates a new VM using the best available backend
@@ -83,6 +127,11 @@ func (p *HyperVProvider) Create(config *VMConfig) error {
 		return fmt.Errorf("failed to create VM directory: %v", err)
 	}
 
+	// VMs created before servin recorded it are not created twice
+	if p.registered() {
+		return nil
+	}
+
 	switch p.vmBackend {
 	case "hyperv":
 		return p.createHyperVVM(config)
@@ -95,28 +144,36 @@ func (p *HyperVProvider) Create(config *VMConfig) error {
 	}
 }
 
+// registered reports whether the backend already has the VM
+func (p *HyperVProvider) registered() bool {
+	switch p.vmBackend {
+	case "hyperv":
+		return powershell(fmt.Sprintf("Get-VM -Name '%s' -ErrorAction Stop | Out-Null", p.config.Name)) == nil
+	case "wsl2":
+		return wslDistroVersion(p.wslDistro()) != ""
+	case "virtualbox":
+		return vboxManage("showvminfo", p.config.Name, "--machinereadable") == nil
+	}
+	return false
+}
+
 // createWSL2VM creates a VM using WSL2 with automated setup
 func (p *HyperVProvider) createWSL2VM(config *VMConfig) error {
 	fmt.Println("Setting up WSL2 VM with Alpine Linux...")
 
-	// Check if WSL2 is properly configured
-	if err := p.ensureWSL2Setup(); err != nil {
-		return fmt.Errorf("failed to setup WSL2: %v", err)
-	}
-
 	// Create or import Alpine Linux distribution
-	distroName := fmt.Sprintf("servin-%s", config.Name)
-	
+	distroName := p.wslDistro()
+
 	// Download Alpine Linux rootfs
 	if err := p.downloadAlpineRootFS(); err != nil {
 		return fmt.Errorf("failed to download Alpine rootfs: %v", err)
 	}
 
-	// Import into WSL2
+	// Import into WSL2, pinned to WSL 2 whatever the default version is
 	rootfsPath := filepath.Join(p.vmPath, "alpine-rootfs.tar.gz")
-	cmd := exec.Command("wsl", "--import", distroName, p.vmPath, rootfsPath)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to import WSL2 distribution: %v", err)
+	cmd := exec.Command("wsl", "--import", distroName, p.vmPath, rootfsPath, "--version", wslVersion)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to import WSL2 distribution: %v, output: %s", err, strings.TrimSpace(string(output)))
 	}
 
 	// Configure the distribution
@@ -174,6 +231,16 @@ Add-VMDvdDrive -VMName '%s' -Path '%s'
 		return fmt.Errorf("failed to attach ISO: %v", err)
 	}
 
+	// Attach the seed ISO installing the servin agent
+	seedPath, err := p.createSeedISO()
+	if err != nil {
+		return err
+	}
+	cmd = exec.Command("powershell", "-Command", fmt.Sprintf("Add-VMDvdDrive -VMName '%s' -Path '%s'", vmName, seedPath))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to attach seed ISO: %v", err)
+	}
+
 	fmt.Println("✅ Hyper-V VM created successfully")
 	return nil
 }
@@ -196,7 +263,7 @@ func (p *HyperVProvider) createVirtualBoxVM(config *VMConfig) error {
 		{"VBoxManage", "modifyvm", vmName, "--memory", strconv.Itoa(config.Memory)},
 		{"VBoxManage", "modifyvm", vmName, "--cpus", strconv.Itoa(config.CPUs)},
 		{"VBoxManage", "modifyvm", vmName, "--ostype", "Linux26_64"},
-		{"VBoxManage", "modifyvm", vmName, "--natpf1", fmt.Sprintf("ssh,tcp,,%d,,22", p.sshPort)},
+		{"VBoxManage", "modifyvm", vmName, "--natpf1", fmt.Sprintf("agent,tcp,127.0.0.1,%d,,%d", p.agentPort, agent.GuestPort)},
 	}
 
 	for _, cmdArgs := range configCmds {
@@ -234,6 +301,16 @@ func (p *HyperVProvider) createVirtualBoxVM(config *VMConfig) error {
 		return fmt.Errorf("failed to attach ISO: %v", err)
 	}
 
+	// Attach the seed ISO installing the servin agent
+	seedPath, err := p.createSeedISO()
+	if err != nil {
+		return err
+	}
+	cmd = exec.Command("VBoxManage", "storageattach", vmName, "--storagectl", "SATA", "--port", "2", "--device", "0", "--type", "dvddrive", "--medium", seedPath)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to attach seed ISO: %v", err)
+	}
+
 	fmt.Println("✅ VirtualBox VM created successfully")
 	return nil
 }
@@ -256,66 +333,44 @@ func (p *HyperVProvider) Start() error {
 	}
 }
 
-// startWSL2VM starts the WSL2 VM with automated SSH setup
+// startWSL2VM starts the WSL2 VM, installs servin in it and starts
+// servin-init, which keeps servin running there
 func (p *HyperVProvider) startWSL2VM() error {
-	distroName := fmt.Sprintf("servin-%s", p.config.Name)
+	distroName := p.wslDistro()
 
 	fmt.Printf("Starting WSL2 VM: %s\n", distroName)
-
-	// Start the distribution and run setup script
-	setupScript := `#!/bin/sh
-# Setup SSH in WSL2
-apk update
-apk add openssh sudo curl wget bash
-
-# Configure SSH
-echo 'root:servin123' | chpasswd
-echo 'PermitRootLogin yes' >> /etc/ssh/sshd_config
-echo 'PasswordAuthentication yes' >> /etc/ssh/sshd_config
-
-# Generate SSH host keys
-ssh-keygen -A
-
-# Start SSH (WSL2 doesn't use systemd)
-/usr/sbin/sshd
-
-# Setup container environment
-modprobe overlay 2>/dev/null || true
-modprobe bridge 2>/dev/null || true
-
-echo "WSL2 setup completed" > /tmp/setup-complete
-`
-
-	// Write setup script to VM
-	scriptPath := filepath.Join(p.vmPath, "setup.sh")
-	if err := os.WriteFile(scriptPath, []byte(setupScript), 0755); err != nil {
-		return fmt.Errorf("failed to write setup script: %v", err)
+	if err := p.pinWSLVersion(); err != nil {
+		return err
 	}
 
-	// Copy script to WSL2 and execute
-	cmd := exec.Command("wsl", "-d", distroName, "--", "sh", "-c", "cat > /tmp/setup.sh && chmod +x /tmp/setup.sh && /tmp/setup.sh")
-	cmd.Stdin = strings.NewReader(setupScript)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to run setup script: %v", err)
+	// Starting the distribution runs the setup script, which installs
+	// servin-init and has WSL run it on the next boots
+	cmd := exec.Command("wsl", "-d", distroName, "-u", "root", "--", "sh", "-s")
+	cmd.Stdin = strings.NewReader(wslSetupScript)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run setup script: %v, output: %s", err, strings.TrimSpace(string(output)))
 	}
 
-	// Set up port forwarding for SSH
-	cmd = exec.Command("netsh", "interface", "portproxy", "add", "v4tov4",
-		fmt.Sprintf("listenport=%d", p.sshPort), "listenaddress=127.0.0.1",
-		"connectport=22", "connectaddress=127.0.0.1")
-	cmd.Run() // Ignore errors as rule might already exist
-
 	p.running = true
-	fmt.Printf("✅ WSL2 VM started with SSH on port %d\n", p.sshPort)
+	fmt.Println("✅ WSL2 VM started")
 
-	// Monitor SSH connectivity
-	go p.monitorSSHAndDeploy()
+	if err := deployServin(p.guest); err != nil {
+		fmt.Printf("⚠️ Failed to deploy Servin to VM: %v\n", err)
+	}
+	if err := p.startWSLInit(); err != nil {
+		fmt.Printf("⚠️ Failed to start servin-init: %v\n", err)
+	}
 
 	return nil
 }
 
 // startHyperVVM starts the Hyper-V VM
 func (p *HyperVProvider) startHyperVVM() error {
+	// COM1 is served on a named pipe for 'servin vm console'
+	if err := powershell(fmt.Sprintf("Set-VMComPort -VMName '%s' -Number 1 -Path '%s'", p.config.Name, p.consolePipe())); err != nil {
+		fmt.Printf("⚠️  Could not connect the VM console: %v\n", err)
+	}
+
 	cmd := exec.Command("powershell", "-Command", fmt.Sprintf("Start-VM -Name '%s'", p.config.Name))
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to start Hyper-V VM: %v", err)
@@ -323,21 +378,39 @@ func (p *HyperVProvider) startHyperVVM() error {
 
 	p.running = true
 	fmt.Printf("✅ Hyper-V VM started\n")
+
+	// Monitor agent connectivity
+	go p.agent.waitReady(90 * time.Second)
+
 	return nil
 }
 
 // startVirtualBoxVM starts the VirtualBox VM
 func (p *HyperVProvider) startVirtualBoxVM() error {
+	// Shared folders can only be added for good while the VM is off; ones
+	// added before are left as they are
+	for _, s := range p.SharedFolders() {
+		err := vboxManage("sharedfolder", "add", p.config.Name, "--name", s.Tag, "--hostpath", s.HostPath)
+		if err != nil && !strings.Contains(err.Error(), "already exists") {
+			fmt.Printf("⚠️  Could not share %s with the VM: %v\n", s.HostPath, err)
+		}
+	}
+
+	// COM1 is served on a named pipe for 'servin vm console'
+	if err := vboxManage("modifyvm", p.config.Name, "--uart1", "0x3F8", "4", "--uartmode1", "server", p.consolePipe()); err != nil {
+		fmt.Printf("⚠️  Could not connect the VM console: %v\n", err)
+	}
+
 	cmd := exec.Command("VBoxManage", "startvm", p.config.Name, "--type", "headless")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to start VirtualBox VM: %v", err)
 	}
 
 	p.running = true
-	fmt.Printf("✅ VirtualBox VM started with SSH on port %d\n", p.sshPort)
+	fmt.Printf("✅ VirtualBox VM started with its agent on port %d\n", p.agentPort)
 
-	// Monitor SSH connectivity
-	go p.monitorSSHAndDeploy()
+	// Monitor agent connectivity
+	go p.agent.waitReady(90 * time.Second)
 
 	return nil
 }
@@ -362,15 +435,9 @@ func (p *HyperVProvider) Stop() error {
 
 // stopWSL2VM stops the WSL2 VM
 func (p *HyperVProvider) stopWSL2VM() error {
-	distroName := fmt.Sprintf("servin-%s", p.config.Name)
-	cmd := exec.Command("wsl", "--terminate", distroName)
+	cmd := exec.Command("wsl", "--terminate", p.wslDistro())
 	cmd.Run() // Ignore errors
 
-	// Remove port forwarding
-	cmd = exec.Command("netsh", "interface", "portproxy", "delete", "v4tov4",
-		fmt.Sprintf("listenport=%d", p.sshPort), "listenaddress=127.0.0.1")
-	cmd.Run()
-
 	p.running = false
 	fmt.Println("✅ WSL2 VM stopped")
 	return nil
@@ -409,8 +476,7 @@ func (p *HyperVProvider) Destroy() error {
 		cmd := exec.Command("powershell", "-Command", fmt.Sprintf("Remove-VM -Name '%s' -Force", p.config.Name))
 		cmd.Run()
 	case "wsl2":
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd := exec.Command("wsl", "--unregister", distroName)
+		cmd := exec.Command("wsl", "--unregister", p.wslDistro())
 		cmd.Run()
 	case "virtualbox":
 		cmd := exec.Command("VBoxManage", "unregistervm", p.config.Name, "--delete")
@@ -420,18 +486,258 @@ func (p *HyperVProvider) Destroy() error {
 	return os.RemoveAll(p.vmPath)
 }
 
+// CreateSnapshot takes a Hyper-V checkpoint or VirtualBox snapshot of the
+// VM. Standard checkpoints and live snapshots include the memory of a
+// running VM.
+func (p *HyperVProvider) CreateSnapshot(name string) error {
+	if err := ValidateSnapshotName(name); err != nil {
+		return err
+	}
+	if existing, err := p.ListSnapshots(); err == nil {
+		if _, err := findSnapshot(existing, name, p.config.Name); err == nil {
+			return fmt.Errorf("VM %s already has a snapshot %q", p.config.Name, name)
+		}
+	}
+
+	switch p.vmBackend {
+	case "hyperv":
+		return powershell(fmt.Sprintf("Set-VM -Name '%[1]s' -CheckpointType Standard; Checkpoint-VM -Name '%[1]s' -SnapshotName '%[2]s'", p.config.Name, name))
+	case "virtualbox":
+		args := []string{"snapshot", p.config.Name, "take", name}
+		if p.IsRunning() {
+			args = append(args, "--live")
+		}
+		return vboxManage(args...)
+	default:
+		return fmt.Errorf("snapshots are not supported by the %s backend", p.vmBackend)
+	}
+}
+
+// RestoreSnapshot rolls the VM back to a snapshot. Both hypervisors only
+// apply snapshots to a VM that is off, so a running VM is turned off and
+// then resumed from the snapshot.
+func (p *HyperVProvider) RestoreSnapshot(name string) error {
+	snapshots, err := p.ListSnapshots()
+	if err != nil {
+		return err
+	}
+	if _, err := findSnapshot(snapshots, name, p.config.Name); err != nil {
+		return err
+	}
+	running := p.IsRunning()
+
+	switch p.vmBackend {
+	case "hyperv":
+		script := fmt.Sprintf("Restore-VMSnapshot -VMName '%s' -Name '%s' -Confirm:$false", p.config.Name, name)
+		if running {
+			script = fmt.Sprintf("Stop-VM -Name '%[1]s' -TurnOff -Force; %[2]s; Start-VM -Name '%[1]s'", p.config.Name, script)
+		}
+		return powershell(script)
+	case "virtualbox":
+		if running {
+			if err := vboxManage("controlvm", p.config.Name, "poweroff"); err != nil {
+				return err
+			}
+		}
+		if err := vboxManage("snapshot", p.config.Name, "restore", name); err != nil {
+			return err
+		}
+		if running {
+			return vboxManage("startvm", p.config.Name, "--type", "headless")
+		}
+		return nil
+	default:
+		return fmt.Errorf("snapshots are not supported by the %s backend", p.vmBackend)
+	}
+}
+
+// ListSnapshots lists the VM's checkpoints or snapshots. Neither hypervisor
+// reports whether one holds memory, and VirtualBox has no creation times.
+func (p *HyperVProvider) ListSnapshots() ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	switch p.vmBackend {
+	case "hyperv":
+		script := fmt.Sprintf("Get-VMSnapshot -VMName '%s' | ForEach-Object { '{0}|{1:o}' -f $_.Name, $_.CreationTime }", p.config.Name)
+		output, err := exec.Command("powershell", "-Command", script).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list checkpoints: %v", err)
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			name, created, ok := strings.Cut(strings.TrimSpace(line), "|")
+			if !ok {
+				continue
+			}
+			s := &Snapshot{Name: name}
+			s.Created, _ = time.Parse(time.RFC3339Nano, created)
+			snapshots = append(snapshots, s)
+		}
+	case "virtualbox":
+		output, err := exec.Command("VBoxManage", "snapshot", p.config.Name, "list", "--machinereadable").CombinedOutput()
+		if err != nil {
+			if strings.Contains(string(output), "does not have any snapshots") {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to list snapshots: %v, output: %s", err, string(output))
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if ok && strings.HasPrefix(key, "SnapshotName") {
+				snapshots = append(snapshots, &Snapshot{Name: strings.Trim(value, `"`)})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("snapshots are not supported by the %s backend", p.vmBackend)
+	}
+	return snapshots, nil
+}
+
+// DeleteSnapshot deletes a checkpoint or snapshot of the VM, merging its
+// changes into the ones after it
+func (p *HyperVProvider) DeleteSnapshot(name string) error {
+	snapshots, err := p.ListSnapshots()
+	if err != nil {
+		return err
+	}
+	if _, err := findSnapshot(snapshots, name, p.config.Name); err != nil {
+		return err
+	}
+
+	switch p.vmBackend {
+	case "hyperv":
+		return powershell(fmt.Sprintf("Remove-VMSnapshot -VMName '%s' -Name '%s' -Confirm:$false", p.config.Name, name))
+	case "virtualbox":
+		return vboxManage("snapshot", p.config.Name, "delete", name)
+	default:
+		return fmt.Errorf("snapshots are not supported by the %s backend", p.vmBackend)
+	}
+}
+
+// powershell runs a PowerShell script, returning its output on failure
+func powershell(script string) error {
+	output, err := exec.Command("powershell", "-Command", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("PowerShell failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// vboxManage runs VBoxManage, returning its output on failure
+func vboxManage(args ...string) error {
+	output, err := exec.Command("VBoxManage", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("VBoxManage %s failed: %v, output: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// consolePipe is the named pipe the serial console of the Hyper-V or
+// VirtualBox VM is served on
+func (p *HyperVProvider) consolePipe() string {
+	return fmt.Sprintf(`\\.\pipe\servin-%s-console`, p.config.Name)
+}
+
+// OpenConsole connects to the serial console of the running Hyper-V or
+// VirtualBox VM. WSL2 distributions have no serial console.
+func (p *HyperVProvider) OpenConsole() (io.ReadWriteCloser, error) {
+	if p.vmBackend == "wsl2" {
+		return nil, fmt.Errorf("WSL2 distributions have no serial console; use 'servin vm shell'")
+	}
+	if !p.IsRunning() {
+		return nil, fmt.Errorf("VM is not running")
+	}
+	pipe, err := os.OpenFile(p.consolePipe(), os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the VM console: %v", err)
+	}
+	return pipe, nil
+}
+
+// ConsoleLog returns "": Hyper-V and VirtualBox do not log the console
+func (p *HyperVProvider) ConsoleLog() string {
+	return ""
+}
+
+// Resize changes the CPUs and memory of the Hyper-V or VirtualBox VM and
+// grows its disk. WSL2 distributions share the resources of the WSL2
+// utility VM, which are set in .wslconfig.
+func (p *HyperVProvider) Resize(res Resources) error {
+	name := p.config.Name
+	switch p.vmBackend {
+	case "hyperv":
+		if res.CPUs != 0 {
+			if err := powershell(fmt.Sprintf("Set-VMProcessor -VMName '%s' -Count %d", name, res.CPUs)); err != nil {
+				return err
+			}
+		}
+		if res.Memory != 0 {
+			if err := powershell(fmt.Sprintf("Set-VMMemory -VMName '%s' -StartupBytes %dMB", name, res.Memory)); err != nil {
+				return err
+			}
+		}
+		if res.DiskSize != 0 {
+			return powershell(fmt.Sprintf("Resize-VHD -Path '%s' -SizeBytes %dGB", filepath.Join(p.vmPath, "disk.vhdx"), res.DiskSize))
+		}
+		return nil
+	case "virtualbox":
+		if res.CPUs != 0 {
+			if err := vboxManage("modifyvm", name, "--cpus", strconv.Itoa(res.CPUs)); err != nil {
+				return err
+			}
+		}
+		if res.Memory != 0 {
+			if err := vboxManage("modifyvm", name, "--memory", strconv.Itoa(res.Memory)); err != nil {
+				return err
+			}
+		}
+		if res.DiskSize != 0 {
+			return vboxManage("modifymedium", "disk", filepath.Join(p.vmPath, "disk.vdi"), "--resize", strconv.Itoa(res.DiskSize*1024))
+		}
+		return nil
+	default:
+		return fmt.Errorf("the %s backend takes its CPUs and memory from .wslconfig; edit it and run 'wsl --shutdown'", p.vmBackend)
+	}
+}
+
+// Pause freezes the running Hyper-V or VirtualBox VM
+func (p *HyperVProvider) Pause() error {
+	switch p.vmBackend {
+	case "hyperv":
+		return powershell(fmt.Sprintf("Suspend-VM -Name '%s'", p.config.Name))
+	case "virtualbox":
+		return vboxManage("controlvm", p.config.Name, "pause")
+	default:
+		return fmt.Errorf("the %s backend cannot pause the VM", p.vmBackend)
+	}
+}
+
+// Resume lets the paused Hyper-V or VirtualBox VM continue
+func (p *HyperVProvider) Resume() error {
+	switch p.vmBackend {
+	case "hyperv":
+		return powershell(fmt.Sprintf("Resume-VM -Name '%s'", p.config.Name))
+	case "virtualbox":
+		return vboxManage("controlvm", p.config.Name, "resume")
+	default:
+		return fmt.Errorf("the %s backend cannot pause the VM", p.vmBackend)
+	}
+}
+
+// SharedFolders returns the drives shared with the VM under /mnt: WSL2
+// mounts them itself over 9p and VirtualBox as shared folders. Hyper-V VMs
+// share no host directories.
+func (p *HyperVProvider) SharedFolders() []Share {
+	switch p.vmBackend {
+	case "wsl2", "virtualbox":
+		return hostShares()
+	}
+	return nil
+}
+
 // IsRunning checks if the VM is currently running
 func (p *HyperVProvider) IsRunning() bool {
 	switch p.vmBackend {
 	case "wsl2":
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd := exec.Command("wsl", "-l", "--running")
-		output, err := cmd.Output()
-		if err != nil {
-			p.running = false
-			return false
-		}
-		p.running = strings.Contains(string(output), distroName)
+		p.running = wslDistroRunning(p.wslDistro())
 		return p.running
 	case "hyperv":
 		cmd := exec.Command("powershell", "-Command", fmt.Sprintf("(Get-VM -Name '%s').State", p.config.Name))
@@ -463,18 +769,15 @@ func (p *HyperVProvider) GetInfo() (*VMInfo, error) {
 	}
 
 	uptime := ""
-	if p.running && p.testSSHConnectivity() {
-		// Get uptime from VM
-		cmd := exec.Command("ssh",
-			"-p", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "ConnectTimeout=2",
-			"root@localhost",
-			"uptime -p")
-		if output, err := cmd.Output(); err == nil {
-			uptime = strings.TrimSpace(string(output))
-		}
+	agentReady := false
+	if p.running && p.agent != nil {
+		uptime = p.agent.uptime()
+		agentReady = uptime != ""
+	}
+
+	var services []ServiceHealth
+	if p.running && p.vmBackend == "wsl2" {
+		services = p.wslHealth()
 	}
 
 	return &VMInfo{
@@ -485,22 +788,23 @@ func (p *HyperVProvider) GetInfo() (*VMInfo, error) {
 		CPUs:       p.config.CPUs,
 		Memory:     p.config.Memory,
 		IPAddress:  "127.0.0.1",
-		SSHPort:    p.sshPort,
+		AgentPort:  p.agentPort,
 		DockerPort: p.config.DockerPort,
 		Uptime:     uptime,
+		Services:   services,
 		Capabilities: map[string]bool{
 			"containers":   true,
 			"networking":   true,
 			"volumes":      true,
 			"port_forward": true,
 			"nested_virt":  p.vmBackend == "hyperv",
-			"ssh_access":   p.testSSHConnectivity(),
+			"agent":        agentReady,
 		},
 	}, nil
 }
 
-// Helper methods for checking backend availability
-func (p *HyperVProvider) isHyperVAvailable() bool {
+// Helpers checking backend availability
+func hyperVAvailable() bool {
 	cmd := exec.Command("powershell", "-Command", "Get-WindowsOptionalFeature -Online -FeatureName Microsoft-Hyper-V")
 	output, err := cmd.Output()
 	if err != nil {
@@ -509,13 +813,13 @@ func (p *HyperVProvider) isHyperVAvailable() bool {
 	return strings.Contains(string(output), "State") && strings.Contains(string(output), "Enabled")
 }
 
-func (p *HyperVProvider) isWSL2Available() bool {
+func wsl2Available() bool {
 	cmd := exec.Command("wsl", "--status")
 	err := cmd.Run()
 	return err == nil
 }
 
-func (p *HyperVProvider) isVirtualBoxAvailable() bool {
+func virtualBoxAvailable() bool {
 	_, err := exec.LookPath("VBoxManage")
 	return err == nil
 }
@@ -529,12 +833,8 @@ func (p *HyperVProvider) downloadAlpineISO() error {
 
 	fmt.Println("Downloading Alpine Linux ISO...")
 	url := "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/x86_64/alpine-standard-3.19.1-x86_64.iso"
-	
-	cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`
-Invoke-WebRequest -Uri '%s' -OutFile '%s'
-`, url, isoPath))
-	
-	return cmd.Run()
+
+	return downloadAsset(p.config, "iso", url, isoPath)
 }
 
 func (p *HyperVProvider) downloadAlpineRootFS() error {
@@ -545,27 +845,39 @@ func (p *HyperVProvider) downloadAlpineRootFS() error {
 
 	fmt.Println("Downloading Alpine Linux rootfs...")
 	url := "https://dl-cdn.alpinelinux.org/alpine/v3.19/releases/x86_64/alpine-minirootfs-3.19.1-x86_64.tar.gz"
-	
-	cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`
-Invoke-WebRequest -Uri '%s' -OutFile '%s'
-`, url, rootfsPath))
-	
-	return cmd.Run()
+
+	return downloadAsset(p.config, "rootfs", url, rootfsPath)
 }
 
-// WSL2 specific helpers
-func (p *HyperVProvider) ensureWSL2Setup() error {
-	// Check if WSL2 is the default version
-	cmd := exec.Command("wsl", "--set-default-version", "2")
-	return cmd.Run()
+// createSeedISO creates the seed ISO installing the servin agent and
+// returns its path
+func (p *HyperVProvider) createSeedISO() (string, error) {
+	tempDir := filepath.Join(p.vmPath, "seed-temp")
+	isoPath := filepath.Join(p.vmPath, "seed.iso")
+
+	os.RemoveAll(tempDir)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var setup string
+	if p.vmBackend == "virtualbox" {
+		setup = shareMountScript(p.SharedFolders(), shareVBoxSF)
+	}
+	if err := writeSeed(tempDir, p.vmPath, setup); err != nil {
+		return "", err
+	}
+	if err := buildSeedISO(tempDir, isoPath); err != nil {
+		return "", err
+	}
+	return isoPath, nil
 }
 
+// WSL2 specific helpers
 func (p *HyperVProvider) configureWSL2Distribution(distroName string) error {
 	// Set default user and configure
 	cmd := exec.Command("wsl", "-d", distroName, "--", "sh", "-c", `
-# Setup root user
-echo 'root:servin123' | chpasswd
-
 # Setup package manager
 echo "http://dl-cdn.alpinelinux.org/alpine/v3.19/main" > /etc/apk/repositories
 echo "http://dl-cdn.alpinelinux.org/alpine/v3.19/community" >> /etc/apk/repositories
@@ -573,303 +885,3 @@ apk update
 `)
 	return cmd.Run()
 }
-
-// SSH and monitoring helpers
-func (p *HyperVProvider) testSSHConnectivity() bool {
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"-o", "ConnectTimeout=2",
-		"-o", "BatchMode=yes",
-		"root@localhost",
-		"echo 'SSH_WORKING'")
-
-	output, err := cmd.Output()
-	return err == nil && strings.Contains(string(output), "SSH_WORKING")
-}
-
-func (p *HyperVProvider) monitorSSHAndDeploy() {
-	maxWait := 90 * time.Second
-	start := time.Now()
-
-	for time.Since(start) < maxWait {
-		if p.testSSHConnectivity() {
-			fmt.Println("✅ SSH is ready!")
-			time.Sleep(2 * time.Second)
-			
-			if err := p.deployServinToVM(); err != nil {
-				fmt.Printf("⚠️ Failed to deploy Servin to VM: %v\n", err)
-			}
-			return
-		}
-		time.Sleep(2 * time.Second)
-	}
-
-	fmt.Println("⚠️ SSH setup timeout - manual configuration may be needed")
-}
-
-func (p *HyperVProvider) deployServinToVM() error {
-	// Find the servin binary
-	servinBinary := "./servin.exe"
-	if _, err := os.Stat(servinBinary); err != nil {
-		for _, path := range []string{"servin.exe", "build/servin.exe"} {
-			if _, err := os.Stat(path); err == nil {
-				servinBinary = path
-				break
-			}
-		}
-	}
-
-	if _, err := os.Stat(servinBinary); err != nil {
-		return fmt.Errorf("servin binary not found")
-	}
-
-	fmt.Println("📦 Deploying Servin to VM...")
-
-	// Copy binary to VM using scp (if available) or WSL copy
-	if p.vmBackend == "wsl2" {
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd := exec.Command("wsl", "-d", distroName, "--", "mkdir", "-p", "/usr/local/bin")
-		cmd.Run()
-
-		// Copy using WSL
-		cmd = exec.Command("cmd", "/C", fmt.Sprintf(`copy "%s" "\\\\wsl$\\%s\\usr\\local\\bin\\servin"`, servinBinary, distroName))
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to copy binary: %v", err)
-		}
-	} else {
-		// Use SCP for Hyper-V and VirtualBox
-		cmd := exec.Command("scp",
-			"-P", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			servinBinary,
-			"root@localhost:/usr/local/bin/servin")
-
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to copy binary: %v", err)
-		}
-	}
-
-	// Make it executable
-	cmd := exec.Command("ssh",
-		"-p", strconv.Itoa(p.sshPort),
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		"root@localhost",
-		"chmod +x /usr/local/bin/servin")
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to make binary executable: %v", err)
-	}
-
-	fmt.Println("✅ Servin deployed to VM")
-	return nil
-}
-
-// Container operations (using Servin runtime)
-func (p *HyperVProvider) RunContainer(config *ContainerConfig) (*ContainerResult, error) {
-	if !p.IsRunning() {
-		return nil, fmt.Errorf("VM is not running")
-	}
-
-	// Build servin run command
-	servinCmd := p.buildServinCommand(config)
-
-	// Execute via SSH or WSL
-	var cmd *exec.Cmd
-	if p.vmBackend == "wsl2" {
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd = exec.Command("wsl", "-d", distroName, "--", "sh", "-c", servinCmd)
-	} else {
-		cmd = exec.Command("ssh",
-			"-p", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"root@localhost",
-			servinCmd)
-	}
-
-	output, err := cmd.CombinedOutput()
-
-	result := &ContainerResult{
-		Name:   config.Name,
-		Output: string(output),
-	}
-
-	if err != nil {
-		result.Error = err.Error()
-		result.ExitCode = 1
-	} else {
-		result.Status = "running"
-		result.ExitCode = 0
-		if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); len(lines) > 0 {
-			result.ID = strings.TrimSpace(lines[len(lines)-1])
-		}
-	}
-
-	return result, nil
-}
-
-func (p *HyperVProvider) buildServinCommand(config *ContainerConfig) string {
-	parts := []string{"/usr/local/bin/servin", "run"}
-
-	if config.Name != "" {
-		parts = append(parts, "--name", config.Name)
-	}
-
-	for hostPort, containerPort := range config.Ports {
-		parts = append(parts, "-p", fmt.Sprintf("%s:%s", hostPort, containerPort))
-	}
-
-	for hostPath, containerPath := range config.Volumes {
-		parts = append(parts, "-v", fmt.Sprintf("%s:%s", hostPath, containerPath))
-	}
-
-	for key, value := range config.Environment {
-		parts = append(parts, "-e", fmt.Sprintf("%s=%s", key, value))
-	}
-
-	if config.WorkDir != "" {
-		parts = append(parts, "-w", config.WorkDir)
-	}
-
-	if config.Detached {
-		parts = append(parts, "-d")
-	}
-
-	parts = append(parts, config.Image)
-
-	if len(config.Command) > 0 {
-		parts = append(parts, config.Command...)
-	}
-
-	return strings.Join(parts, " ")
-}
-
-func (p *HyperVProvider) ListContainers() ([]*ContainerInfo, error) {
-	if !p.IsRunning() {
-		return nil, fmt.Errorf("VM is not running")
-	}
-
-	var cmd *exec.Cmd
-	if p.vmBackend == "wsl2" {
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd = exec.Command("wsl", "-d", distroName, "--", "/usr/local/bin/servin", "list")
-	} else {
-		cmd = exec.Command("ssh",
-			"-p", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"root@localhost",
-			"/usr/local/bin/servin list")
-	}
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list containers: %v", err)
-	}
-
-	return p.parseServinContainerList(string(output)), nil
-}
-
-func (p *HyperVProvider) parseServinContainerList(output string) []*ContainerInfo {
-	var containers []*ContainerInfo
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-
-	for _, line := range lines {
-		if strings.Contains(line, "CONTAINER") || line == "" {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) >= 4 {
-			container := &ContainerInfo{
-				ID:     fields[0],
-				Name:   fields[1],
-				Image:  fields[2],
-				Status: fields[3],
-			}
-			if len(fields) >= 5 {
-				container.Created = fields[4]
-			}
-			containers = append(containers, container)
-		}
-	}
-
-	return containers
-}
-
-func (p *HyperVProvider) StopContainer(id string) error {
-	return p.executeServinCommand(fmt.Sprintf("/usr/local/bin/servin stop %s", id))
-}
-
-func (p *HyperVProvider) RemoveContainer(id string) error {
-	return p.executeServinCommand(fmt.Sprintf("/usr/local/bin/servin remove %s", id))
-}
-
-func (p *HyperVProvider) executeServinCommand(command string) error {
-	var cmd *exec.Cmd
-	if p.vmBackend == "wsl2" {
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd = exec.Command("wsl", "-d", distroName, "--", "sh", "-c", command)
-	} else {
-		cmd = exec.Command("ssh",
-			"-p", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"root@localhost",
-			command)
-	}
-
-	return cmd.Run()
-}
-
-func (p *HyperVProvider) CopyToVM(hostPath, vmPath string) error {
-	if !p.IsRunning() {
-		return fmt.Errorf("VM is not running")
-	}
-
-	if p.vmBackend == "wsl2" {
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd := exec.Command("cmd", "/C", fmt.Sprintf(`copy "%s" "\\\\wsl$\\%s\\%s"`, hostPath, distroName, vmPath))
-		return cmd.Run()
-	} else {
-		cmd := exec.Command("scp",
-			"-P", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			hostPath,
-			fmt.Sprintf("root@localhost:%s", vmPath))
-		return cmd.Run()
-	}
-}
-
-func (p *HyperVProvider) CopyFromVM(vmPath, hostPath string) error {
-	if !p.IsRunning() {
-		return fmt.Errorf("VM is not running")
-	}
-
-	if p.vmBackend == "wsl2" {
-		distroName := fmt.Sprintf("servin-%s", p.config.Name)
-		cmd := exec.Command("cmd", "/C", fmt.Sprintf(`copy "\\\\wsl$\\%s\\%s" "%s"`, distroName, vmPath, hostPath))
-		return cmd.Run()
-	} else {
-		cmd := exec.Command("scp",
-			"-P", strconv.Itoa(p.sshPort),
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			fmt.Sprintf("root@localhost:%s", vmPath),
-			hostPath)
-		return cmd.Run()
-	}
-}
-
-func (p *HyperVProvider) ForwardPort(hostPort, vmPort int) error {
-	return fmt.Errorf("dynamic port forwarding not implemented, configure during VM start")
-}
-
-func (p *HyperVProvider) RemovePortForward(hostPort int) error {
-	return fmt.Errorf("dynamic port forwarding not implemented")
-}
\ No newline at end of file
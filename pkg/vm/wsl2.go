@@ -0,0 +1,295 @@
+//go:build windows
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"servin/pkg/vm/agent"
+)
+
+// wslVersion is the WSL version servin distributions are pinned to,
+// whatever the user's default is
+const wslVersion = "2"
+
+// wslCRIPort is the port the CRI server listens on in the distribution
+const wslCRIPort = 8080
+
+// wslInitPath is the supervisor run when the distribution boots
+const wslInitPath = "/usr/local/sbin/servin-init"
+
+// wslInitScript supervises servin in the distribution, which has no init
+// system: it keeps the daemon and the CRI server running, restarting them
+// with a growing delay when they keep failing, and records their PIDs,
+// exit statuses and restarts in /run/servin for wslHealthScript.
+var wslInitScript = fmt.Sprintf(`#!/bin/sh
+# servin-init: keeps servin running in the WSL2 distribution
+mkdir -p /run/servin
+if [ -f /run/servin/init.pid ] && kill -0 "$(cat /run/servin/init.pid)" 2>/dev/null; then
+    exit 0
+fi
+echo $$ > /run/servin/init.pid
+exec >> /var/log/servin-init.log 2>&1
+
+supervise() {
+    name=$1; shift
+    delay=1
+    restarts=0
+    while :; do
+        started=$(date +%%s)
+        echo "$(date) starting $name"
+        "$@" &
+        echo $! > /run/servin/$name.pid
+        wait $!
+        echo $? > /run/servin/$name.exit
+        echo "$(date) $name exited with status $(cat /run/servin/$name.exit)"
+        restarts=$((restarts + 1))
+        echo $restarts > /run/servin/$name.restarts
+        if [ $(($(date +%%s) - started)) -lt 10 ]; then
+            delay=$((delay * 2)); [ $delay -gt 60 ] && delay=60
+        else
+            delay=1
+        fi
+        sleep $delay
+    done
+}
+
+supervise daemon %[1]s daemon &
+supervise cri %[1]s cri start --port %[2]d &
+wait
+`, guestServinPath, wslCRIPort)
+
+// wslSetupScript installs the supervisor and has WSL run it when the
+// distribution boots
+var wslSetupScript = fmt.Sprintf(`#!/bin/sh
+modprobe overlay 2>/dev/null || true
+modprobe bridge 2>/dev/null || true
+
+mkdir -p $(dirname %[1]s)
+cat > %[1]s <<'EOF'
+%[2]sEOF
+chmod +x %[1]s
+
+cat > /etc/wsl.conf <<'EOF'
+[boot]
+command = "setsid %[1]s >/dev/null 2>&1 </dev/null &"
+EOF
+`, wslInitPath, wslInitScript)
+
+// wslHealthScript reports the services supervised by servin-init, one per
+// line as name, state and detail separated by tabs
+var wslHealthScript = fmt.Sprintf(`
+for name in init daemon cri; do
+    pid=$(cat /run/servin/$name.pid 2>/dev/null)
+    restarts=$(cat /run/servin/$name.restarts 2>/dev/null || echo 0)
+    if [ -n "$pid" ] && kill -0 "$pid" 2>/dev/null; then
+        printf '%%s\trunning\tpid %%s, %%s restarts\n' $name $pid $restarts
+    elif [ -f /run/servin/$name.exit ]; then
+        printf '%%s\tstopped\texit status %%s, %%s restarts\n' $name "$(cat /run/servin/$name.exit)" $restarts
+    else
+        printf '%%s\tstopped\tnever started\n' $name
+    fi
+done
+if wget -q -T 2 -O /dev/null http://127.0.0.1:%[1]d/health; then
+    printf 'cri-endpoint\thealthy\t127.0.0.1:%[1]d\n'
+else
+    printf 'cri-endpoint\tunreachable\t127.0.0.1:%[1]d\n'
+fi
+`, wslCRIPort)
+
+// wsl2Guest reaches a WSL2 distribution with wsl.exe
+type wsl2Guest struct {
+	distro string
+}
+
+// exec runs the command attached to the Windows console, which WSL resizes
+// itself
+func (g *wsl2Guest) exec(opts agent.ExecOptions) (int, error) {
+	cmd := exec.Command("wsl", append([]string{"-d", g.distro, "--"}, opts.Argv...)...)
+	cmd.Env = append(os.Environ(), opts.Env...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = opts.Stdin, opts.Stdout, opts.Stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// copyTo streams the file into the distribution through wsl.exe, which
+// works whether or not the \\wsl$ share is reachable
+func (g *wsl2Guest) copyTo(hostPath, vmPath string) error {
+	f, err := os.Open(hostPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	script := `mkdir -p "$(dirname "$1")" && cat > "$1.tmp" && mv "$1.tmp" "$1"`
+	code, err := g.exec(agent.ExecOptions{Argv: []string{"sh", "-c", script, "sh", vmPath}, Stdin: f, Stderr: os.Stderr})
+	if err := exitError(code, err); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %v", hostPath, vmPath, err)
+	}
+	return nil
+}
+
+func (g *wsl2Guest) copyFrom(vmPath, hostPath string) error {
+	f, err := os.Create(hostPath)
+	if err != nil {
+		return err
+	}
+	code, err := g.exec(agent.ExecOptions{Argv: []string{"cat", vmPath}, Stdout: f, Stderr: os.Stderr})
+	f.Close()
+	if err := exitError(code, err); err != nil {
+		os.Remove(hostPath)
+		return fmt.Errorf("failed to copy %s from the VM: %v", vmPath, err)
+	}
+	return nil
+}
+
+// wslOutput runs wsl.exe and returns its output. wsl.exe writes UTF-16
+// unless WSL_UTF8 is set, which older versions ignore, so NULs are dropped.
+func wslOutput(args ...string) (string, error) {
+	cmd := exec.Command("wsl", args...)
+	cmd.Env = append(os.Environ(), "WSL_UTF8=1")
+	output, err := cmd.Output()
+	return strings.ReplaceAll(string(output), "\x00", ""), err
+}
+
+// wslDistroVersion returns the WSL version the distribution runs as, or ""
+// when it is not registered
+func wslDistroVersion(distro string) string {
+	output, err := wslOutput("-l", "-v")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(output, "\n") {
+		// "* NAME STATE VERSION", the default distribution starred
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		if len(fields) == 3 && fields[0] == distro {
+			return fields[2]
+		}
+	}
+	return ""
+}
+
+// wslDistroRunning reports whether the distribution is running
+func wslDistroRunning(distro string) bool {
+	output, err := wslOutput("-l", "-q", "--running")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == distro {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *HyperVProvider) wslDistro() string {
+	return fmt.Sprintf("servin-%s", p.config.Name)
+}
+
+// pinWSLVersion converts the distribution back to WSL 2 if it was changed
+func (p *HyperVProvider) pinWSLVersion() error {
+	version := wslDistroVersion(p.wslDistro())
+	if version == "" || version == wslVersion {
+		return nil
+	}
+	fmt.Printf("Converting %s from WSL %s to WSL %s...\n", p.wslDistro(), version, wslVersion)
+	if output, err := exec.Command("wsl", "--set-version", p.wslDistro(), wslVersion).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to convert %s to WSL %s: %v, output: %s", p.wslDistro(), wslVersion, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// startWSLInit starts servin-init in the running distribution, for WSL
+// versions that do not run boot commands; it exits at once when already
+// running
+func (p *HyperVProvider) startWSLInit() error {
+	cmd := exec.Command("wsl", "-d", p.wslDistro(), "-u", "root", "--", "sh", "-c", fmt.Sprintf("setsid %s >/dev/null 2>&1 </dev/null &", wslInitPath))
+	return cmd.Run()
+}
+
+// wslHealth reports the services servin-init supervises in the
+// distribution
+func (p *HyperVProvider) wslHealth() []ServiceHealth {
+	output, err := exec.Command("wsl", "-d", p.wslDistro(), "-u", "root", "--", "sh", "-c", wslHealthScript).Output()
+	if err != nil {
+		return []ServiceHealth{{Name: "init", State: "unknown", Detail: err.Error()}}
+	}
+
+	var services []ServiceHealth
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "\t", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		s := ServiceHealth{Name: fields[0], State: fields[1]}
+		if len(fields) == 3 {
+			s.Detail = fields[2]
+		}
+		services = append(services, s)
+	}
+	return services
+}
+
+// Export saves the WSL2 distribution to a tar file. A running distribution
+// is stopped for the export and started again.
+func (p *HyperVProvider) Export(file string) error {
+	if p.vmBackend != "wsl2" {
+		return fmt.Errorf("exporting the VM is only supported with WSL2; use a snapshot with %s", p.vmBackend)
+	}
+	if wslDistroVersion(p.wslDistro()) == "" {
+		return fmt.Errorf("VM %s has not been created", p.config.Name)
+	}
+
+	wasRunning := wslDistroRunning(p.wslDistro())
+	if wasRunning {
+		if err := p.stopWSL2VM(); err != nil {
+			return err
+		}
+	}
+	output, err := exec.Command("wsl", "--export", p.wslDistro(), file).CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("failed to export %s: %v, output: %s", p.wslDistro(), err, strings.TrimSpace(string(output)))
+	}
+	if wasRunning {
+		if startErr := p.startWSL2VM(); err == nil {
+			err = startErr
+		}
+	}
+	return err
+}
+
+// Import replaces the WSL2 distribution with one exported before, pinned
+// to WSL 2
+func (p *HyperVProvider) Import(file string) error {
+	if p.vmBackend != "wsl2" {
+		return fmt.Errorf("importing the VM is only supported with WSL2")
+	}
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.vmPath, 0755); err != nil {
+		return fmt.Errorf("failed to create VM directory: %v", err)
+	}
+
+	if wslDistroVersion(p.wslDistro()) != "" {
+		p.stopWSL2VM()
+		if output, err := exec.Command("wsl", "--unregister", p.wslDistro()).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to unregister %s: %v, output: %s", p.wslDistro(), err, strings.TrimSpace(string(output)))
+		}
+	}
+	output, err := exec.Command("wsl", "--import", p.wslDistro(), p.vmPath, file, "--version", wslVersion).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %v, output: %s", p.wslDistro(), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
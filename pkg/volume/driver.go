@@ -0,0 +1,328 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"servin/pkg/errors"
+)
+
+// Driver provides the storage of the volumes created with it. A volume's
+// data is always reached at its mountpoint; drivers other than a plain
+// local directory mount their storage there.
+type Driver interface {
+	// Validate checks the options a volume is created with
+	Validate(options map[string]string) error
+	// Create sets the storage of a new volume up and mounts it
+	Create(vol *Volume) error
+	// Mount mounts the storage of a volume again, after a reboot, unless
+	// it is mounted
+	Mount(vol *Volume) error
+	// Remove unmounts a volume and deletes what its storage keeps outside
+	// its mountpoint; the data of network shares is left alone
+	Remove(vol *Volume) error
+}
+
+// Drivers are the volume drivers servin provides
+var Drivers = []string{"local", "tmpfs", "loop", "nfs", "cifs"}
+
+// mountSpec is what a volume's storage is mounted from: the filesystem
+// type, the device or share, and the mount options
+type mountSpec struct {
+	fstype  string
+	device  string
+	options string
+}
+
+// mountDriver is a driver whose volumes are mounted from a mountSpec built
+// from their options; a nil spec is a plain directory
+type mountDriver struct {
+	name string
+	// allowed are the options it accepts and required those it needs
+	allowed  []string
+	required []string
+	// check validates the options further
+	check func(options map[string]string) error
+	// spec returns what a volume is mounted from
+	spec func(vol *Volume) *mountSpec
+	// prepare creates the storage a volume is mounted from, and cleanup
+	// deletes it
+	prepare func(vol *Volume) error
+	cleanup func(vol *Volume)
+}
+
+// driver returns the volume driver named name
+func (m *Manager) driver(name string) (Driver, error) {
+	loopImage := func(vol *Volume) string {
+		return filepath.Join(m.volumeDir, ".loop", vol.Name+".img")
+	}
+	credentials := func(vol *Volume) string {
+		return filepath.Join(m.volumeDir, ".credentials", vol.Name)
+	}
+
+	switch name {
+	case "local", "":
+		return &mountDriver{
+			name:    "local",
+			allowed: []string{"type", "device", "o"},
+			check: func(options map[string]string) error {
+				if len(options) > 0 && options["type"] == "" {
+					return fmt.Errorf("the local driver needs type with device and o")
+				}
+				if options["type"] != "" && options["type"] != "tmpfs" && options["device"] == "" {
+					return fmt.Errorf("the local driver needs device for a %s mount", options["type"])
+				}
+				return nil
+			},
+			spec: func(vol *Volume) *mountSpec {
+				if vol.Options["type"] == "" {
+					return nil
+				}
+				device := vol.Options["device"]
+				if device == "" {
+					device = vol.Options["type"]
+				}
+				return &mountSpec{vol.Options["type"], device, vol.Options["o"]}
+			},
+		}, nil
+	case "tmpfs":
+		return &mountDriver{
+			name:    name,
+			allowed: []string{"size", "mode", "uid", "gid"},
+			check: func(options map[string]string) error {
+				if size, ok := options["size"]; ok && !strings.HasSuffix(size, "%") {
+					if _, err := ParseSize(size); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			spec: func(vol *Volume) *mountSpec {
+				return &mountSpec{"tmpfs", "tmpfs", joinOptions(vol.Options, "size", "mode", "uid", "gid")}
+			},
+		}, nil
+	case "loop":
+		return &mountDriver{
+			name:     name,
+			allowed:  []string{"size", "fs", "o"},
+			required: []string{"size"},
+			check: func(options map[string]string) error {
+				size, err := ParseSize(options["size"])
+				if err != nil {
+					return err
+				}
+				if size < 1<<20 {
+					return fmt.Errorf("size %s is too small for a filesystem image", options["size"])
+				}
+				switch options["fs"] {
+				case "", "ext2", "ext3", "ext4", "xfs":
+					return nil
+				}
+				return fmt.Errorf("unsupported filesystem %q (use ext4, ext3, ext2 or xfs)", options["fs"])
+			},
+			spec: func(vol *Volume) *mountSpec {
+				return &mountSpec{loopFilesystem(vol), loopImage(vol), appendOption("loop", vol.Options["o"])}
+			},
+			prepare: func(vol *Volume) error {
+				size, _ := ParseSize(vol.Options["size"])
+				return createImage(loopImage(vol), size, loopFilesystem(vol))
+			},
+			cleanup: func(vol *Volume) {
+				os.Remove(loopImage(vol))
+			},
+		}, nil
+	case "nfs":
+		return &mountDriver{
+			name:     name,
+			allowed:  []string{"server", "share", "o"},
+			required: []string{"server", "share"},
+			spec: func(vol *Volume) *mountSpec {
+				return &mountSpec{"nfs", vol.Options["server"] + ":" + vol.Options["share"], vol.Options["o"]}
+			},
+		}, nil
+	case "cifs":
+		return &mountDriver{
+			name:     name,
+			allowed:  []string{"server", "share", "username", "password", "domain", "o"},
+			required: []string{"server", "share"},
+			spec: func(vol *Volume) *mountSpec {
+				device := "//" + vol.Options["server"] + "/" + strings.TrimPrefix(vol.Options["share"], "/")
+				options := vol.Options["o"]
+				if _, err := os.Stat(credentials(vol)); err == nil {
+					options = appendOption("credentials="+credentials(vol), options)
+				}
+				return &mountSpec{"cifs", device, options}
+			},
+			// The credentials are kept in a file only root can read
+			// rather than with the volume's options
+			prepare: func(vol *Volume) error {
+				if vol.Options["username"] == "" && vol.Options["password"] == "" {
+					return nil
+				}
+				var content strings.Builder
+				for _, key := range []string{"username", "password", "domain"} {
+					if value := vol.Options[key]; value != "" {
+						fmt.Fprintf(&content, "%s=%s\n", key, value)
+					}
+				}
+				if err := os.MkdirAll(filepath.Dir(credentials(vol)), 0700); err != nil {
+					return err
+				}
+				if err := os.WriteFile(credentials(vol), []byte(content.String()), 0600); err != nil {
+					return err
+				}
+				delete(vol.Options, "password")
+				return nil
+			},
+			cleanup: func(vol *Volume) {
+				os.Remove(credentials(vol))
+			},
+		}, nil
+	}
+	return nil, errors.NewValidationError("volume.driver",
+		fmt.Sprintf("unknown volume driver '%s' (use %s)", name, strings.Join(Drivers, ", ")))
+}
+
+func (d *mountDriver) Validate(options map[string]string) error {
+	for key := range options {
+		if !contains(d.allowed, key) {
+			if len(d.allowed) == 0 {
+				return fmt.Errorf("the %s driver takes no options", d.name)
+			}
+			return fmt.Errorf("unknown option '%s' for the %s driver (valid: %s)", key, d.name, strings.Join(d.allowed, ", "))
+		}
+	}
+	for _, key := range d.required {
+		if options[key] == "" {
+			return fmt.Errorf("the %s driver needs --opt %s=...", d.name, key)
+		}
+	}
+	if d.check != nil {
+		return d.check(options)
+	}
+	return nil
+}
+
+func (d *mountDriver) Create(vol *Volume) error {
+	if d.prepare != nil {
+		if err := d.prepare(vol); err != nil {
+			return err
+		}
+	}
+	if err := d.Mount(vol); err != nil {
+		if d.cleanup != nil {
+			d.cleanup(vol)
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *mountDriver) Mount(vol *Volume) error {
+	spec := d.spec(vol)
+	if spec == nil || isMounted(vol.Mountpoint) {
+		return nil
+	}
+	if err := mount(spec, vol.Mountpoint); err != nil {
+		return fmt.Errorf("failed to mount %s volume: %v", d.name, err)
+	}
+	return nil
+}
+
+func (d *mountDriver) Remove(vol *Volume) error {
+	if isMounted(vol.Mountpoint) {
+		if err := unmount(vol.Mountpoint); err != nil {
+			return fmt.Errorf("failed to unmount volume: %v", err)
+		}
+	}
+	if d.cleanup != nil {
+		d.cleanup(vol)
+	}
+	return nil
+}
+
+// loopFilesystem returns the filesystem of a loop volume's image
+func loopFilesystem(vol *Volume) string {
+	if fs := vol.Options["fs"]; fs != "" {
+		return fs
+	}
+	return "ext4"
+}
+
+// joinOptions joins the options named keys that are set as key=value
+// mount options
+func joinOptions(options map[string]string, keys ...string) string {
+	var joined []string
+	for _, key := range keys {
+		if value, ok := options[key]; ok {
+			joined = append(joined, key+"="+value)
+		}
+	}
+	return strings.Join(joined, ",")
+}
+
+// appendOption adds mount options to a list of them
+func appendOption(options, more string) string {
+	if options == "" {
+		return more
+	}
+	if more == "" {
+		return options
+	}
+	return options + "," + more
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSize parses a size such as 512m or 10G into bytes
+func ParseSize(size string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "b"), "i")
+	multiplier := int64(1)
+	if s != "" {
+		if shift := strings.IndexByte("kmgt", s[len(s)-1]); shift >= 0 {
+			multiplier = 1 << (10 * (shift + 1))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size '%s' (e.g. 512m, 10g)", size)
+	}
+	return n * multiplier, nil
+}
+
+// MountAll mounts the volumes whose drivers mount their storage and are not
+// mounted, as after a reboot
+func (m *Manager) MountAll() error {
+	volumes, err := m.ListVolumes()
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, vol := range volumes {
+		driver, err := m.driver(vol.Driver)
+		if err == nil {
+			err = driver.Mount(vol)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", vol.Name, err))
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to mount volumes:\n%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
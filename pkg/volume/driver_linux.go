@@ -0,0 +1,85 @@
+//go:build linux
+
+package volume
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// mount mounts spec at target with mount(8), so the helpers of network
+// filesystems such as mount.nfs and mount.cifs are used
+func mount(spec *mountSpec, target string) error {
+	args := []string{"-t", spec.fstype}
+	if spec.options != "" {
+		args = append(args, "-o", spec.options)
+	}
+	return runCommand("mount", append(args, spec.device, target)...)
+}
+
+func unmount(target string) error {
+	return runCommand("umount", target)
+}
+
+// isMounted reports whether something is mounted at path
+func isMounted(path string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The fifth field is the mount point, with spaces escaped as \040
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 4 && strings.ReplaceAll(fields[4], `\040`, " ") == path {
+			return true
+		}
+	}
+	return false
+}
+
+// createImage creates a sparse filesystem image of size bytes, whose size
+// bounds what a loop volume can hold
+func createImage(path string, size int64, fs string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(size)
+	f.Close()
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	force := "-F"
+	if fs == "xfs" {
+		force = "-f"
+	}
+	if err := runCommand("mkfs."+fs, "-q", force, path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+func runCommand(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
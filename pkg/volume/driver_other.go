@@ -0,0 +1,24 @@
+//go:build !linux
+
+package volume
+
+import "fmt"
+
+// Volumes other than plain local directories are only supported on Linux,
+// where the VM creates them
+
+func mount(spec *mountSpec, target string) error {
+	return fmt.Errorf("%s volumes are only supported on Linux", spec.fstype)
+}
+
+func unmount(target string) error {
+	return fmt.Errorf("volume mounts are only supported on Linux")
+}
+
+func isMounted(path string) bool {
+	return false
+}
+
+func createImage(path string, size int64, fs string) error {
+	return fmt.Errorf("loop volumes are only supported on Linux")
+}
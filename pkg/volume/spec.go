@@ -0,0 +1,293 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"servin/pkg/errors"
+)
+
+// Spec is a parsed -v volume spec: SOURCE:TARGET[:OPTIONS]
+type Spec struct {
+	// Source is the host path or volume name as given
+	Source string
+	// Target is the absolute path inside the container
+	Target   string
+	ReadOnly bool
+	// Named is set when Source names a managed volume rather than a path
+	Named bool
+	// Anonymous is set for a spec without a source, a bare container path,
+	// which is given a new anonymous volume
+	Anonymous bool
+}
+
+// vmSharedDirs are the macOS directories shared with the VM, at the same
+// paths inside it except /tmp and /var/folders, which it sees under /private
+var vmSharedDirs = []string{"/Users", "/Volumes", "/private", "/tmp", "/var/folders"}
+
+// ParseSpec parses a volume spec. The source may be a Unix path, a Windows
+// path with a drive letter (C:\work), a UNC path (\\server\share) or a
+// volume name; the target is a path inside the container. A container path
+// alone is an anonymous volume.
+func ParseSpec(spec string) (*Spec, error) {
+	invalid := func(msg string) error {
+		return errors.NewValidationError("volume.ParseSpec", fmt.Sprintf("invalid volume spec '%s': %s", spec, msg))
+	}
+
+	// The colon after a drive letter does not separate the source
+	start := 0
+	if isDrivePath(spec) {
+		start = 2
+	}
+	sep := strings.Index(spec[start:], ":")
+	if sep < 0 {
+		if strings.HasPrefix(spec, "/") {
+			return &Spec{Target: path.Clean(spec), Anonymous: true}, nil
+		}
+		return nil, invalid("expected SOURCE:TARGET[:ro|rw] or an absolute container path")
+	}
+	source, rest := spec[:start+sep], spec[start+sep+1:]
+	target, options, _ := strings.Cut(rest, ":")
+	if source == "" {
+		return nil, invalid("empty source")
+	}
+	if !strings.HasPrefix(target, "/") {
+		return nil, invalid(fmt.Sprintf("container path '%s' must be absolute", target))
+	}
+
+	s := &Spec{Source: source, Target: path.Clean(target), Named: isVolumeName(source)}
+	if options != "" {
+		var rw bool
+		for _, option := range strings.Split(options, ",") {
+			switch option {
+			case "ro":
+				s.ReadOnly = true
+			case "rw":
+				rw = true
+			case "cached", "delegated", "consistent":
+				// Docker Desktop's macOS consistency hints; mounts here
+				// are always consistent
+			default:
+				return nil, invalid(fmt.Sprintf("unknown option '%s' (valid: ro, rw)", option))
+			}
+		}
+		if s.ReadOnly && rw {
+			return nil, invalid("ro and rw cannot both be set")
+		}
+	}
+	return s, nil
+}
+
+// Mount returns the container side of the spec as stored with the
+// container: the target, followed by ":ro" for a read-only mount
+func (s *Spec) Mount() string {
+	if s.ReadOnly {
+		return s.Target + ":ro"
+	}
+	return s.Target
+}
+
+// HostPath resolves a bind mount source to an absolute path on this host.
+// Windows drive paths are translated to /mnt/<drive> inside WSL and WSL
+// paths back to drive paths on Windows; on macOS, whose filesystem ignores
+// case, the path is given the case it has on disk.
+func HostPath(source string) (string, error) {
+	return currentHost().hostPath(source)
+}
+
+// VMPath translates a path returned by HostPath to the path at which
+// servin's VM sees it. Windows drives are mounted under /mnt in the VM and
+// macOS shares its user directories at their own paths; paths the VM cannot
+// reach are an error. Volume names are returned unchanged.
+func VMPath(hostPath string) (string, error) {
+	return currentHost().vmPath(hostPath)
+}
+
+// host describes the platform paths are translated for
+type host struct {
+	goos string
+	// wsl is set on a Linux host running under WSL, which sees the
+	// Windows drives under /mnt
+	wsl bool
+	// distro is the WSL distribution's name
+	distro string
+}
+
+func currentHost() host {
+	h := host{goos: runtime.GOOS}
+	if h.goos == "linux" {
+		h.distro = os.Getenv("WSL_DISTRO_NAME")
+		_, err := os.Stat("/proc/sys/fs/binfmt_misc/WSLInterop")
+		h.wsl = h.distro != "" || err == nil
+	}
+	return h
+}
+
+func untranslatable(source, msg string) error {
+	return errors.NewValidationError("volume.HostPath", fmt.Sprintf("cannot mount '%s': %s", source, msg))
+}
+
+func (h host) hostPath(source string) (string, error) {
+	if source == "~" || strings.HasPrefix(source, "~/") || strings.HasPrefix(source, `~\`) {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", untranslatable(source, "cannot find the home directory")
+		}
+		source = homeDir + source[1:]
+	}
+
+	switch h.goos {
+	case "windows":
+		switch {
+		case isDrivePath(source), isUNCPath(source):
+			return filepath.Clean(source), nil
+		case wslDrivePath(source) != "":
+			return wslDrivePath(source), nil
+		case strings.HasPrefix(source, "/"):
+			return "", untranslatable(source, "Unix paths have no Windows equivalent; use a drive path such as C:\\work")
+		}
+	case "linux":
+		switch {
+		case isDrivePath(source):
+			if !h.wsl {
+				return "", untranslatable(source, "Windows paths can only be mounted on Windows or from WSL")
+			}
+			return driveMountPath(source), nil
+		case isUNCPath(source):
+			if distro, p, ok := wslSharePath(source); ok && h.wsl && strings.EqualFold(distro, h.distro) {
+				return p, nil
+			}
+			return "", untranslatable(source, "UNC paths cannot be mounted on Linux; mount the share and use its local path")
+		}
+	default:
+		if isDrivePath(source) || isUNCPath(source) {
+			return "", untranslatable(source, fmt.Sprintf("Windows paths cannot be mounted on %s", h.goos))
+		}
+	}
+
+	absPath, err := filepath.Abs(source)
+	if err != nil {
+		return "", untranslatable(source, err.Error())
+	}
+	if h.goos == "darwin" {
+		absPath = diskCase(absPath)
+	}
+	return absPath, nil
+}
+
+func (h host) vmPath(hostPath string) (string, error) {
+	if isVolumeName(hostPath) {
+		return hostPath, nil
+	}
+
+	switch h.goos {
+	case "windows":
+		if isUNCPath(hostPath) {
+			return "", errors.NewValidationError("volume.VMPath", fmt.Sprintf(
+				"cannot mount '%s' in the VM: network shares are not shared with it; map the share to a drive letter", hostPath))
+		}
+		if !isDrivePath(hostPath) {
+			return "", errors.NewValidationError("volume.VMPath", fmt.Sprintf(
+				"cannot mount '%s' in the VM: expected a drive path such as C:\\work", hostPath))
+		}
+		return driveMountPath(hostPath), nil
+	case "darwin":
+		for _, dir := range vmSharedDirs {
+			if hostPath == dir || strings.HasPrefix(hostPath, dir+"/") {
+				// /tmp and /var/folders are links into /private, which is
+				// what the VM shares
+				if dir == "/tmp" || dir == "/var/folders" {
+					return "/private" + hostPath, nil
+				}
+				return hostPath, nil
+			}
+		}
+		return "", errors.NewValidationError("volume.VMPath", fmt.Sprintf(
+			"cannot mount '%s' in the VM: only %s are shared with it", hostPath, strings.Join(vmSharedDirs, ", ")))
+	}
+	return hostPath, nil
+}
+
+// isDrivePath reports whether p starts with a Windows drive, as in C:\work
+// or C:/work
+func isDrivePath(p string) bool {
+	if len(p) < 2 || p[1] != ':' || !isLetter(p[0]) {
+		return false
+	}
+	return len(p) == 2 || p[2] == '\\' || p[2] == '/'
+}
+
+// isUNCPath reports whether p is a network path, as in \\server\share
+func isUNCPath(p string) bool {
+	return strings.HasPrefix(p, `\\`) || strings.HasPrefix(p, "//")
+}
+
+// isVolumeName reports whether a source names a volume rather than a path
+func isVolumeName(source string) bool {
+	return !isDrivePath(source) && !strings.ContainsAny(source, `/\`) &&
+		source != "." && source != ".." && !strings.HasPrefix(source, "~")
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// driveMountPath returns the path of a Windows drive path under /mnt, where
+// WSL and the VM mount the drives: C:\work becomes /mnt/c/work
+func driveMountPath(p string) string {
+	rest := strings.ReplaceAll(p[2:], `\`, "/")
+	return path.Join("/mnt", strings.ToLower(p[:1]), path.Clean("/"+rest))
+}
+
+// wslDrivePath translates a drive path under /mnt, as WSL writes it, or
+// under /<drive>, as Git Bash writes it, to a Windows path; it returns ""
+// for other paths
+func wslDrivePath(p string) string {
+	rest := strings.TrimPrefix(p, "/mnt")
+	if len(rest) < 2 || rest[0] != '/' || !isLetter(rest[1]) || (len(rest) > 2 && rest[2] != '/') {
+		return ""
+	}
+	return strings.ToUpper(rest[1:2]) + `:\` + strings.TrimPrefix(strings.ReplaceAll(rest[2:], "/", `\`), `\`)
+}
+
+// wslSharePath splits a path on a WSL distribution's network share, such as
+// \\wsl$\Ubuntu\home or \\wsl.localhost\Ubuntu\home, into the distribution
+// and the path inside it
+func wslSharePath(p string) (distro, inside string, ok bool) {
+	parts := strings.FieldsFunc(p, func(r rune) bool { return r == '\\' || r == '/' })
+	if len(parts) < 2 || (!strings.EqualFold(parts[0], "wsl$") && !strings.EqualFold(parts[0], "wsl.localhost")) {
+		return "", "", false
+	}
+	return parts[1], "/" + strings.Join(parts[2:], "/"), true
+}
+
+// diskCase returns an absolute path with each existing component spelt as
+// it is on disk. macOS matches names regardless of case, but the VM and the
+// container do not, so /users/Me must be mounted as /Users/me.
+func diskCase(p string) string {
+	names := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	result := "/"
+	for i, name := range names {
+		entries, err := os.ReadDir(result)
+		if err != nil {
+			// The rest of the path does not exist yet
+			return path.Join(append([]string{result}, names[i:]...)...)
+		}
+		match := name
+		for _, entry := range entries {
+			if entry.Name() == name {
+				match = name
+				break
+			}
+			if strings.EqualFold(entry.Name(), name) {
+				match = entry.Name()
+			}
+		}
+		result = path.Join(result, match)
+	}
+	return result
+}
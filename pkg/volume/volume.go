@@ -1,6 +1,8 @@
 package volume
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -23,6 +25,11 @@ type Volume struct {
 	Options    map[string]string `json:"options"`
 	Scope      string            `json:"scope"`
 	Status     map[string]string `json:"status"`
+
+	// Anonymous is set for volumes created for a container, for a -v
+	// without a source or a VOLUME of its image; 'servin rm --volumes'
+	// removes them with it
+	Anonymous bool `json:"anonymous,omitempty"`
 }
 
 // Manager manages container volumes
@@ -134,6 +141,25 @@ func (m *Manager) CreateVolume(name string, driver string, options map[string]st
 		return nil, errors.NewValidationError("CreateVolume", "volume name cannot contain path separators").
 			WithContext("volume_name", name)
 	}
+	if strings.HasPrefix(name, ".") {
+		return nil, errors.NewValidationError("CreateVolume", "volume name cannot start with '.'").
+			WithContext("volume_name", name)
+	}
+
+	// Set default driver if not specified
+	if driver == "" {
+		driver = "local"
+		logger.Debug("Using default driver 'local' for volume: %s", name)
+	}
+	volumeDriver, err := m.driver(driver)
+	if err != nil {
+		return nil, err
+	}
+	if err := volumeDriver.Validate(options); err != nil {
+		return nil, errors.NewValidationError("CreateVolume", err.Error()).
+			WithContext("volume_name", name).
+			WithContext("driver", driver)
+	}
 
 	// Create volume directory
 	volumePath := filepath.Join(m.volumeDir, name)
@@ -145,12 +171,6 @@ func (m *Manager) CreateVolume(name string, driver string, options map[string]st
 			WithContext("volume_name", name)
 	}
 
-	// Set default driver if not specified
-	if driver == "" {
-		driver = "local"
-		logger.Debug("Using default driver 'local' for volume: %s", name)
-	}
-
 	// Initialize labels and options if nil
 	if labels == nil {
 		labels = make(map[string]string)
@@ -170,9 +190,18 @@ func (m *Manager) CreateVolume(name string, driver string, options map[string]st
 		Status:     map[string]string{"state": "ready"},
 	}
 
+	// Set up the driver's storage at the volume directory
+	if err := volumeDriver.Create(volume); err != nil {
+		os.Remove(volumePath)
+		return nil, errors.WrapError(err, errors.ErrTypeVolume, "CreateVolume", "failed to set up volume storage").
+			WithContext("volume_name", name).
+			WithContext("driver", driver)
+	}
+
 	// Save volume to index
 	if err := m.SaveVolume(volume); err != nil {
 		// Clean up created directory on failure
+		volumeDriver.Remove(volume)
 		os.RemoveAll(volumePath)
 		return nil, fmt.Errorf("failed to save volume: %v", err)
 	}
@@ -180,6 +209,25 @@ func (m *Manager) CreateVolume(name string, driver string, options map[string]st
 	return volume, nil
 }
 
+// CreateAnonymousVolume creates a local volume with a random name for a
+// container to mount at a path no volume was given for
+func (m *Manager) CreateAnonymousVolume() (*Volume, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	vol, err := m.CreateVolume(hex.EncodeToString(id), "local", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	vol.Anonymous = true
+	if err := m.SaveVolume(vol); err != nil {
+		m.RemoveVolume(vol.Name, true)
+		return nil, err
+	}
+	return vol, nil
+}
+
 // SaveVolume saves a volume to the index
 func (m *Manager) SaveVolume(vol *Volume) error {
 	if err := m.ensureVolumeDir(); err != nil {
@@ -243,9 +291,24 @@ func (m *Manager) RemoveVolume(name string, force bool) error {
 		}
 	}
 
+	// Unmount the driver's storage first: removing the directory of a
+	// mounted network share would delete the data on the server
+	unmounted := true
+	if volumeDriver, err := m.driver(volume.Driver); err == nil {
+		if err := volumeDriver.Remove(volume); err != nil {
+			if !force {
+				return err
+			}
+			logger.Warn("Keeping directory of volume '%s': %v", name, err)
+			unmounted = false
+		}
+	}
+
 	// Remove volume directory
-	if err := os.RemoveAll(volume.Mountpoint); err != nil && !force {
-		return fmt.Errorf("failed to remove volume directory: %v", err)
+	if unmounted {
+		if err := os.RemoveAll(volume.Mountpoint); err != nil && !force {
+			return fmt.Errorf("failed to remove volume directory: %v", err)
+		}
 	}
 
 	// Save updated index
@@ -288,9 +351,66 @@ func (m *Manager) GetVolumeDir() string {
 	return m.volumeDir
 }
 
-// PruneVolumes removes all unused volumes
-func (m *Manager) PruneVolumes() ([]string, error) {
-	// TODO: Implement volume usage checking
-	// For now, this is a placeholder that returns empty list
-	return []string{}, nil
+// PruneOptions selects the volumes PruneVolumes removes
+type PruneOptions struct {
+	// InUse reports whether the volume must be kept, for instance because
+	// a container references it
+	InUse func(vol *Volume) bool
+	// Until only removes volumes created before this time, if set
+	Until time.Time
+	// DryRun reports what would be removed without removing anything
+	DryRun bool
+}
+
+// PruneReport lists what PruneVolumes removed, or would remove in a dry run
+type PruneReport struct {
+	Volumes        []string
+	SpaceReclaimed int64
+}
+
+// PruneVolumes removes volumes no container references
+func (m *Manager) PruneVolumes(opts PruneOptions) (*PruneReport, error) {
+	volumes, err := m.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{}
+	for _, vol := range volumes {
+		if !opts.Until.IsZero() && !vol.CreatedAt.Before(opts.Until) {
+			continue
+		}
+		if opts.InUse != nil && opts.InUse(vol) {
+			continue
+		}
+
+		size := dirSize(vol.Mountpoint)
+		if !opts.DryRun {
+			if err := m.RemoveVolume(vol.Name, false); err != nil {
+				return report, fmt.Errorf("failed to remove volume '%s': %v", vol.Name, err)
+			}
+		}
+		report.Volumes = append(report.Volumes, vol.Name)
+		report.SpaceReclaimed += size
+	}
+
+	logger.Debug("Pruned %d volumes (dry run: %v)", len(report.Volumes), opts.DryRun)
+	return report, nil
+}
+
+// DiskUsage returns the space a volume's data takes
+func (m *Manager) DiskUsage(vol *Volume) int64 {
+	return dirSize(vol.Mountpoint)
+}
+
+// dirSize returns the total size of the regular files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
 }
@@ -0,0 +1,102 @@
+package vulnerability
+
+import (
+	"math"
+	"strings"
+)
+
+// cvss3Weights are the metric values of the CVSS v3 base score formula
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3Score computes the base score of a CVSS v3.0 or v3.1 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H". ok is false if the vector
+// is not a complete v3 base vector.
+func cvss3Score(vector string) (score float64, ok bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, false
+	}
+	metrics := make(map[string]string)
+	for _, part := range parts[1:] {
+		if key, value, found := strings.Cut(part, ":"); found {
+			metrics[key] = value
+		}
+	}
+
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return 0, false
+	}
+	values := make(map[string]float64)
+	for metric, weights := range cvss3Weights {
+		weight, found := weights[metrics[metric]]
+		if !found {
+			return 0, false
+		}
+		values[metric] = weight
+	}
+	// Privileges required weigh more when the scope changes
+	switch metrics["PR"] {
+	case "N":
+		values["PR"] = 0.85
+	case "L":
+		values["PR"] = 0.62
+		if changed {
+			values["PR"] = 0.68
+		}
+	case "H":
+		values["PR"] = 0.27
+		if changed {
+			values["PR"] = 0.5
+		}
+	default:
+		return 0, false
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * values["AV"] * values["AC"] * values["PR"] * values["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp rounds up to one decimal as the CVSS v3.1 specification defines,
+// avoiding floating point errors such as 4.000001 becoming 4.1
+func roundUp(value float64) float64 {
+	scaled := int64(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return float64(scaled/10000+1) / 10
+}
+
+// scoreSeverity maps a CVSS score to its qualitative severity rating
+func scoreSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
@@ -0,0 +1,210 @@
+package vulnerability
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultOSVURL is the OSV API queried unless SERVIN_OSV_URL names a mirror
+const DefaultOSVURL = "https://api.osv.dev"
+
+// osvBatchSize is the most queries the OSV API accepts in one batch
+const osvBatchSize = 1000
+
+// osvWorkers bounds the vulnerability details fetched concurrently
+const osvWorkers = 8
+
+// Client queries the OSV (Open Source Vulnerabilities) API
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewClient returns a client for the OSV API
+func NewClient() *Client {
+	baseURL := os.Getenv("SERVIN_OSV_URL")
+	if baseURL == "" {
+		baseURL = DefaultOSVURL
+	}
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// osvQuery asks for the vulnerabilities of one package version
+type osvQuery struct {
+	Package   osvPackage `json:"package"`
+	Version   string     `json:"version"`
+	PageToken string     `json:"page_token,omitempty"`
+}
+
+type osvPackage struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// osvVuln is an entry of the OSV database, as defined by the OSV schema
+type osvVuln struct {
+	ID               string        `json:"id"`
+	Summary          string        `json:"summary"`
+	Details          string        `json:"details"`
+	Aliases          []string      `json:"aliases"`
+	Upstream         []string      `json:"upstream"`
+	Severity         []osvSeverity `json:"severity"`
+	Affected         []osvAffected `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+type osvAffected struct {
+	Package  osvPackage    `json:"package"`
+	Severity []osvSeverity `json:"severity"`
+	Ranges   []struct {
+		Type   string              `json:"type"`
+		Events []map[string]string `json:"events"`
+	} `json:"ranges"`
+	EcosystemSpecific struct {
+		Severity string `json:"severity"`
+		Urgency  string `json:"urgency"`
+	} `json:"ecosystem_specific"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// queryBatch returns the IDs of the vulnerabilities affecting each query
+func (c *Client) queryBatch(queries []osvQuery) ([][]string, error) {
+	results := make([][]string, len(queries))
+	for start := 0; start < len(queries); start += osvBatchSize {
+		end := start + osvBatchSize
+		if end > len(queries) {
+			end = len(queries)
+		}
+
+		pending := make(map[int]osvQuery)
+		for i := start; i < end; i++ {
+			pending[i] = queries[i]
+		}
+		// Queries with more results than fit in one response are repeated
+		// with their page token until every page is read
+		for len(pending) > 0 {
+			var indexes []int
+			var batch []osvQuery
+			for i := start; i < end; i++ {
+				if query, ok := pending[i]; ok {
+					indexes = append(indexes, i)
+					batch = append(batch, query)
+				}
+			}
+
+			var response struct {
+				Results []struct {
+					Vulns []struct {
+						ID string `json:"id"`
+					} `json:"vulns"`
+					NextPageToken string `json:"next_page_token"`
+				} `json:"results"`
+			}
+			if err := c.post("/v1/querybatch", map[string]interface{}{"queries": batch}, &response); err != nil {
+				return nil, err
+			}
+			if len(response.Results) != len(batch) {
+				return nil, fmt.Errorf("OSV returned %d results for %d queries", len(response.Results), len(batch))
+			}
+
+			for n, result := range response.Results {
+				i := indexes[n]
+				for _, vuln := range result.Vulns {
+					results[i] = append(results[i], vuln.ID)
+				}
+				if result.NextPageToken == "" {
+					delete(pending, i)
+					continue
+				}
+				query := pending[i]
+				query.PageToken = result.NextPageToken
+				pending[i] = query
+			}
+		}
+	}
+	return results, nil
+}
+
+// vulns fetches the full entries of vulnerabilities by ID
+func (c *Client) vulns(ids []string) (map[string]*osvVuln, error) {
+	entries := make(map[string]*osvVuln)
+	var mu sync.Mutex
+	var firstErr error
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < osvWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				var vuln osvVuln
+				err := c.get("/v1/vulns/"+url.PathEscape(id), &vuln)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("failed to fetch %s: %v", id, err)
+				} else if err == nil {
+					entries[id] = &vuln
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		work <- id
+	}
+	close(work)
+	wg.Wait()
+
+	return entries, firstErr
+}
+
+func (c *Client) post(path string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTP.Post(c.BaseURL+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("OSV request failed: %v", err)
+	}
+	return decodeResponse(resp, out)
+}
+
+func (c *Client) get(path string, out interface{}) error {
+	resp, err := c.HTTP.Get(c.BaseURL + path)
+	if err != nil {
+		return fmt.Errorf("OSV request failed: %v", err)
+	}
+	return decodeResponse(resp, out)
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OSV returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse OSV response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+// Package vulnerability checks the OS packages installed in an image against
+// the OSV (Open Source Vulnerabilities) database.
+package vulnerability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"servin/pkg/errors"
+	"servin/pkg/inventory"
+)
+
+// Severities, from most to least severe
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+var severityRanks = map[string]int{
+	SeverityCritical: 4,
+	SeverityHigh:     3,
+	SeverityMedium:   2,
+	SeverityLow:      1,
+	SeverityUnknown:  0,
+}
+
+// ParseSeverity validates a severity name, in any case
+func ParseSeverity(name string) (string, error) {
+	severity := strings.ToUpper(name)
+	if _, ok := severityRanks[severity]; !ok {
+		return "", errors.NewValidationError("vulnerability.ParseSeverity",
+			fmt.Sprintf("unknown severity '%s' (expected critical, high, medium, low or unknown)", name))
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether severity is at least as severe as min
+func AtLeast(severity, min string) bool {
+	return severityRanks[severity] >= severityRanks[min]
+}
+
+// Finding is a vulnerability affecting an installed package
+type Finding struct {
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases,omitempty"`
+	Package string   `json:"package"`
+	// Source is the source package the advisory names, if it differs
+	Source       string  `json:"source,omitempty"`
+	Version      string  `json:"version"`
+	FixedVersion string  `json:"fixed_version,omitempty"`
+	Severity     string  `json:"severity"`
+	Score        float64 `json:"score,omitempty"`
+	Summary      string  `json:"summary,omitempty"`
+	URL          string  `json:"url"`
+}
+
+// Report is the result of scanning a package inventory
+type Report struct {
+	OS        string `json:"os,omitempty"`
+	Ecosystem string `json:"ecosystem"`
+	// Packages counts the packages checked
+	Packages int       `json:"packages"`
+	Findings []Finding `json:"vulnerabilities"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// Ecosystem returns the OSV ecosystem holding the advisories of the
+// distribution an inventory was read from
+func Ecosystem(inv *inventory.Inventory) (string, error) {
+	version := inv.OSVersion
+	major, minor, _ := strings.Cut(version, ".")
+	minor, _, _ = strings.Cut(minor, ".")
+
+	switch inv.OSID {
+	case "alpine":
+		if minor != "" {
+			return fmt.Sprintf("Alpine:v%s.%s", major, minor), nil
+		}
+	case "debian":
+		if major != "" {
+			return "Debian:" + major, nil
+		}
+	case "ubuntu":
+		if major != "" && minor != "" {
+			// Even-year April releases are long-term support releases
+			if year := major[len(major)-1:]; minor == "04" && strings.Contains("02468", year) {
+				return fmt.Sprintf("Ubuntu:%s.%s:LTS", major, minor), nil
+			}
+			return fmt.Sprintf("Ubuntu:%s.%s", major, minor), nil
+		}
+	case "almalinux":
+		if major != "" {
+			return "AlmaLinux:" + major, nil
+		}
+	case "rocky":
+		if major != "" {
+			return "Rocky Linux:" + major, nil
+		}
+	}
+
+	name := inv.OS
+	if name == "" {
+		name = "an unknown distribution"
+	}
+	return "", errors.NewValidationError("vulnerability.Ecosystem",
+		fmt.Sprintf("no vulnerability data for %s (supported: Alpine, Debian, Ubuntu, AlmaLinux, Rocky Linux)", name))
+}
+
+// Scan looks up the vulnerabilities of every package in an inventory and
+// returns them most severe first
+func Scan(inv *inventory.Inventory, client *Client) (*Report, error) {
+	ecosystem, err := Ecosystem(inv)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{OS: inv.OS, Ecosystem: ecosystem, Packages: len(inv.Packages), Warnings: inv.Warnings}
+
+	// Advisories are keyed by source package, which several installed
+	// packages often share, so each source version is queried once
+	type key struct{ name, version string }
+	var queries []osvQuery
+	queryIndex := make(map[key]int)
+	for _, pkg := range inv.Packages {
+		k := key{advisoryName(pkg), pkg.Version}
+		if _, ok := queryIndex[k]; ok {
+			continue
+		}
+		queryIndex[k] = len(queries)
+		queries = append(queries, osvQuery{
+			Package: osvPackage{Name: k.name, Ecosystem: ecosystem},
+			Version: k.version,
+		})
+	}
+
+	results, err := client.queryBatch(queries)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, result := range results {
+		for _, id := range result {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	vulns, err := client.vulns(ids)
+	if err != nil {
+		return nil, err
+	}
+	related := relatedSeverities(client, vulns)
+
+	for _, pkg := range inv.Packages {
+		name := advisoryName(pkg)
+		for _, id := range results[queryIndex[key{name, pkg.Version}]] {
+			vuln := vulns[id]
+			finding := Finding{
+				ID:       id,
+				Aliases:  vuln.Aliases,
+				Package:  pkg.Name,
+				Version:  pkg.Version,
+				Severity: SeverityUnknown,
+				Summary:  vuln.Summary,
+				URL:      "https://osv.dev/vulnerability/" + id,
+			}
+			if name != pkg.Name {
+				finding.Source = name
+			}
+			if finding.Summary == "" {
+				finding.Summary = firstLine(vuln.Details)
+			}
+			affected := vuln.affectedPackage(name)
+			finding.Severity, finding.Score = vuln.severity(affected)
+			if finding.Severity == SeverityUnknown {
+				for _, other := range vuln.related() {
+					if rated, ok := related[other]; ok {
+						finding.Severity, finding.Score = rated.severity(nil)
+						break
+					}
+				}
+			}
+			if affected != nil {
+				finding.FixedVersion = affected.fixedVersion()
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if severityRanks[a.Severity] != severityRanks[b.Severity] {
+			return severityRanks[a.Severity] > severityRanks[b.Severity]
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.ID < b.ID
+	})
+	return report, nil
+}
+
+// relatedSeverities fetches the CVE entries of vulnerabilities the
+// distribution did not rate, since Alpine and Debian advisories usually
+// leave the severity to the CVE. CVEs that cannot be fetched stay unrated.
+func relatedSeverities(client *Client, vulns map[string]*osvVuln) map[string]*osvVuln {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, vuln := range vulns {
+		if severity, _ := vuln.severity(nil); severity != SeverityUnknown {
+			continue
+		}
+		for _, id := range vuln.related() {
+			if _, known := vulns[id]; !known && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	related, _ := client.vulns(ids)
+	for id, vuln := range vulns {
+		related[id] = vuln
+	}
+	return related
+}
+
+// related returns the CVE IDs a vulnerability is an alias or an instance of
+func (v *osvVuln) related() []string {
+	var ids []string
+	for _, id := range append(append([]string{}, v.Aliases...), v.Upstream...) {
+		if strings.HasPrefix(id, "CVE-") {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// advisoryName is the package name distribution advisories use
+func advisoryName(pkg inventory.Package) string {
+	if pkg.Source != "" {
+		return pkg.Source
+	}
+	return pkg.Name
+}
+
+// affectedPackage returns the entry of the vulnerability for a package
+func (v *osvVuln) affectedPackage(name string) *osvAffected {
+	for i := range v.Affected {
+		if v.Affected[i].Package.Name == name {
+			return &v.Affected[i]
+		}
+	}
+	return nil
+}
+
+// severity rates a vulnerability by its CVSS v3 score, or failing that by
+// the severity the distribution assigned
+func (v *osvVuln) severity(affected *osvAffected) (string, float64) {
+	severities := v.Severity
+	if affected != nil {
+		severities = append(append([]osvSeverity{}, affected.Severity...), severities...)
+	}
+	for _, s := range severities {
+		if strings.HasPrefix(s.Type, "CVSS_V3") {
+			if score, ok := cvss3Score(s.Score); ok {
+				return scoreSeverity(score), score
+			}
+		}
+	}
+
+	var ratings []string
+	if affected != nil {
+		ratings = append(ratings, affected.EcosystemSpecific.Severity, affected.EcosystemSpecific.Urgency,
+			affected.DatabaseSpecific.Severity)
+	}
+	for _, s := range severities {
+		if !strings.HasPrefix(s.Type, "CVSS") {
+			ratings = append(ratings, s.Score)
+		}
+	}
+	ratings = append(ratings, v.DatabaseSpecific.Severity)
+	for _, rating := range ratings {
+		if severity := normalizeRating(rating); severity != SeverityUnknown {
+			return severity, 0
+		}
+	}
+	return SeverityUnknown, 0
+}
+
+// normalizeRating maps the severity names distributions use to ours
+func normalizeRating(rating string) string {
+	switch strings.ToLower(strings.TrimSpace(rating)) {
+	case "critical":
+		return SeverityCritical
+	case "high", "important":
+		return SeverityHigh
+	case "medium", "moderate":
+		return SeverityMedium
+	case "low", "negligible", "unimportant":
+		return SeverityLow
+	default:
+		return SeverityUnknown
+	}
+}
+
+// fixedVersion returns the version that fixes the vulnerability, if any
+func (a *osvAffected) fixedVersion() string {
+	fixed := ""
+	for _, r := range a.Ranges {
+		if r.Type != "ECOSYSTEM" {
+			continue
+		}
+		for _, event := range r.Events {
+			if version := event["fixed"]; version != "" {
+				fixed = version
+			}
+		}
+	}
+	return fixed
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
@@ -3,16 +3,35 @@ Servin Runtime
 Flask API server for managing Servin containers, images, and volumes
 """
 
+import atexit
+import codecs
 import os
+import re
+import select
+import shlex
+import shutil
+import signal
 import sys
+import tempfile
 import threading
 import time
 import subprocess
 import json
-from flask import Flask, jsonify, request, render_template, send_from_directory
+try:
+    import fcntl
+    import pty
+    import struct
+    import termios
+except ImportError:
+    # Windows has no pseudo-terminals; exec sessions there run on pipes
+    pty = None
+from flask import Flask, Response, jsonify, request, render_template, send_from_directory
 from flask_cors import CORS
 from flask_socketio import SocketIO, emit, disconnect
 from servin_client import ServinClient, ServinError
+from cri_client import CRIClient, CRIError
+from notifications import EVENT_TYPES, NotificationWatcher
+from preferences import LOG_LEVELS, THEMES, Preferences
 
 app = Flask(__name__)
 app.config['SECRET_KEY'] = 'servin-gui-secret-key'
@@ -102,10 +121,30 @@ except Exception as e:
         def run(self, app, **kwargs): app.run(**kwargs)
     socketio = MockSocketIO()
 
+# Read-only mode disables every mutating action (demo machines, kiosks, teaching)
+READ_ONLY = os.environ.get('SERVIN_GUI_READ_ONLY', '').lower() in ('1', 'true', 'yes') or '--read-only' in sys.argv
+if READ_ONLY:
+    print("Servin GUI running in read-only mode")
+
 # Store active log streaming processes
 active_log_streams = {}
+active_stats_streams = {}
 active_exec_sessions = {}
 
+# The `servin vm start` process while the VM engine is starting
+vm_start_process = None
+vm_start_lock = threading.Lock()
+
+# The `servin build` process of the build started from the GUI, and whether
+# it was cancelled
+build_process = None
+build_cancelled = False
+build_lock = threading.Lock()
+
+# The `servin pull` process of the pull started from the GUI
+pull_process = None
+pull_lock = threading.Lock()
+
 # Initialize Servin client
 try:
     from servin_client import ServinClient, ServinError
@@ -118,12 +157,41 @@ except (ServinError, Exception) as e:
     print("Please ensure the servin binary is available and working properly")
     servin_client = None
 
+# The GUI's preferences, applied to the servin commands it runs
+preferences = Preferences()
+preferences.apply(servin_client)
+
+# Desktop notifications of container, image pull and VM engine events
+notifications = NotificationWatcher(servin_client, lambda: vm_status(),
+                                    lambda event: socketio.emit('notification', event),
+                                    preferences)
+
+# The CRI server's pods, on the port of the CRI port preference
+cri_client = CRIClient()
+
+@app.before_request
+def enforce_read_only():
+    """Reject mutating API calls when the GUI runs in read-only mode"""
+    if not READ_ONLY:
+        return None
+    # Switching namespaces only changes what the GUI shows
+    if request.path == '/api/namespaces/current':
+        return None
+    # Notification settings only change what the GUI shows. Preferences are
+    # refused like the rest: they are saved and apply to later servin commands.
+    if request.path == '/api/notifications/settings':
+        return None
+    if request.path.startswith('/api/') and request.method not in ('GET', 'HEAD', 'OPTIONS'):
+        return jsonify({'error': 'Servin GUI is running in read-only mode', 'read_only': True}), 403
+    return None
+
 @app.route('/')
 def index():
     """Serve the main HTML page"""
     import time
     timestamp = int(time.time())
-    return render_template('index.html', timestamp=timestamp)
+    return render_template('index.html', timestamp=timestamp, read_only=READ_ONLY,
+                           theme=preferences.load()['theme'])
 
 @app.route('/static/<path:filename>')
 def static_files(filename):
@@ -143,6 +211,135 @@ def get_containers():
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
+def run_options(data):
+    """Turn the options of the container creation wizard into the image,
+    command and keyword arguments of ServinClient.run_container"""
+    image = (data.get('image') or '').strip()
+    command = (data.get('command') or '').strip()
+    if not image:
+        raise ValueError('An image is required')
+    if not command:
+        raise ValueError('A command is required')
+    
+    options = {}
+    for key in ('name', 'hostname', 'workdir', 'network', 'memory', 'cpus'):
+        value = str(data.get(key) or '').strip()
+        if value:
+            options[key] = value
+    
+    ports = []
+    for port in data.get('ports') or []:
+        container_port = str(port.get('container') or '').strip()
+        if not container_port.isdigit():
+            raise ValueError(f"Container port '{container_port}' must be a number")
+        host_port = str(port.get('host') or '').strip()
+        if host_port and not host_port.isdigit():
+            raise ValueError(f"Host port '{host_port}' must be a number")
+        spec = f"{host_port}:{container_port}" if host_port else f":{container_port}"
+        protocol = port.get('protocol') or 'tcp'
+        if protocol not in ('tcp', 'udp'):
+            raise ValueError(f"Unknown protocol '{protocol}' (expected tcp or udp)")
+        if protocol != 'tcp':
+            spec += f"/{protocol}"
+        ports.append(spec)
+    options['ports'] = ports
+    
+    volumes = []
+    for volume in data.get('volumes') or []:
+        source = (volume.get('source') or '').strip()
+        target = (volume.get('target') or '').strip()
+        if not target.startswith('/'):
+            raise ValueError(f"Mount target '{target}' must be an absolute path in the container")
+        # Without a source the image's path gets an anonymous volume
+        spec = f"{source}:{target}" if source else target
+        if volume.get('read_only'):
+            spec += ':ro'
+        volumes.append(spec)
+    options['volumes'] = volumes
+    
+    env = []
+    for var in data.get('env') or []:
+        key = (var.get('key') or '').strip()
+        if not key:
+            continue
+        if '=' in key:
+            raise ValueError(f"Environment variable name '{key}' cannot contain '='")
+        env.append(f"{key}={var.get('value') or ''}")
+    options['env'] = env
+    
+    restart = data.get('restart') or 'no'
+    if restart not in ('no', 'always', 'on-failure', 'unless-stopped'):
+        raise ValueError(f"Unknown restart policy '{restart}'")
+    retries = str(data.get('max_retries') or '').strip()
+    if restart == 'on-failure' and retries:
+        if not retries.isdigit():
+            raise ValueError('Maximum retries must be a number')
+        restart += f":{retries}"
+    if restart != 'no':
+        options['restart'] = restart
+    
+    return image, command, options
+
+@app.route('/api/containers/run', methods=['POST'])
+def run_container():
+    """Create and start a container in the background"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        image, command, options = run_options(request.get_json() or {})
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    
+    try:
+        container_id = servin_client.run_container(image, command, **options)
+        return jsonify({'success': True, 'id': container_id})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/run/preview', methods=['POST'])
+def preview_run_container():
+    """Get the servin run command line the wizard's options correspond to"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        image, command, options = run_options(request.get_json() or {})
+        return jsonify({'command': servin_client.run_command_preview(image, command, **options)})
+    except (ValueError, ServinError) as e:
+        return jsonify({'error': str(e)}), 400
+
+@app.route('/api/host/directories', methods=['GET'])
+def list_host_directories():
+    """List the folders in a host directory, for choosing bind mount sources
+    and build contexts, and the Buildfiles and Dockerfiles in it"""
+    path = os.path.abspath(os.path.expanduser(request.args.get('path') or '~'))
+    directories = []
+    build_files = []
+    try:
+        for entry in os.scandir(path):
+            if entry.is_dir():
+                if not entry.name.startswith('.'):
+                    directories.append(entry.name)
+            elif is_build_file(entry.name):
+                build_files.append(entry.name)
+    except OSError as e:
+        return jsonify({'error': f'Cannot list {path}: {e.strerror}'}), 400
+    
+    parent = os.path.dirname(path)
+    return jsonify({
+        'path': path,
+        'parent': parent if parent != path else None,
+        'directories': sorted(directories, key=str.lower),
+        'build_files': sorted(build_files, key=str.lower)
+    })
+
+def is_build_file(name):
+    """Whether servin build reads a file of this name, as a Buildfile or a
+    Dockerfile"""
+    return (name.endswith('Buildfile') or name == 'Dockerfile'
+            or name.startswith('Dockerfile.') or name.endswith('.Dockerfile'))
+
 @app.route('/api/containers/<container_id>/start', methods=['POST'])
 def start_container(container_id):
     """Start a container"""
@@ -162,6 +359,7 @@ def stop_container(container_id):
         return jsonify({'error': 'Servin runtime not available'}), 500
     
     try:
+        notifications.expect_stop(container_id)
         servin_client.stop_container(container_id)
         return jsonify({'success': True, 'message': f'Container {container_id} stopped'})
     except ServinError as e:
@@ -174,11 +372,34 @@ def restart_container(container_id):
         return jsonify({'error': 'Servin runtime not available'}), 500
     
     try:
+        notifications.expect_stop(container_id)
         servin_client.restart_container(container_id)
         return jsonify({'success': True, 'message': f'Container {container_id} restarted'})
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
+@app.route('/api/containers/<container_id>/recreate', methods=['POST'])
+def recreate_container(container_id):
+    """Recreate a container with a changed environment"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    env = (request.get_json(silent=True) or {}).get('env')
+    if not isinstance(env, dict):
+        return jsonify({'error': 'env must map variable names to values'}), 400
+    for key, value in env.items():
+        if not key or '=' in key or any(c.isspace() for c in key):
+            return jsonify({'error': f"Invalid environment variable name '{key}'"}), 400
+        if not isinstance(value, str):
+            return jsonify({'error': f"Value of '{key}' must be a string"}), 400
+    
+    try:
+        notifications.expect_stop(container_id)
+        result = servin_client.recreate_container(container_id, env)
+        return jsonify(result)
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
 @app.route('/api/containers/<container_id>/remove', methods=['DELETE'])
 def remove_container(container_id):
     """Remove a container"""
@@ -186,11 +407,52 @@ def remove_container(container_id):
         return jsonify({'error': 'Servin runtime not available'}), 500
     
     try:
+        notifications.expect_stop(container_id)
         servin_client.remove_container(container_id, force=True)
         return jsonify({'success': True, 'message': f'Container {container_id} removed'})
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
+@app.route('/api/containers/bulk', methods=['POST'])
+def bulk_container_action():
+    """Start, stop or remove several containers"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json() or {}
+    force = bool(data.get('force'))
+    actions = {
+        'start': servin_client.start_container,
+        'stop': servin_client.stop_container,
+        'remove': lambda container_id: servin_client.remove_container(container_id, force=force)
+    }
+    action = actions.get(data.get('action'))
+    if not action:
+        return jsonify({'error': 'action must be start, stop or remove'}), 400
+    ids = data.get('ids')
+    if not isinstance(ids, list) or not ids:
+        return jsonify({'error': 'Container IDs required'}), 400
+    if data.get('action') != 'start':
+        for container_id in ids:
+            notifications.expect_stop(container_id)
+    
+    return jsonify(apply_to_each(ids, action))
+
+def apply_to_each(ids, action):
+    """Apply an action to each object in turn, so one failing does not stop
+    the rest, and report how each went"""
+    results = []
+    for object_id in ids:
+        try:
+            action(object_id)
+            results.append({'id': object_id, 'success': True})
+        except ServinError as e:
+            results.append({'id': object_id, 'success': False, 'error': str(e)})
+    return {
+        'results': results,
+        'failed': sum(1 for result in results if not result['success'])
+    }
+
 @app.route('/api/containers/<container_id>/details', methods=['GET'])
 def get_container_details(container_id):
     """Get detailed information about a container"""
@@ -217,88 +479,149 @@ def get_container_logs(container_id):
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
+@app.route('/api/containers/<container_id>/logs/export', methods=['GET'])
+def export_container_logs(container_id):
+    """Download all the logs of a container as a text file"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    timestamps = request.args.get('timestamps', 'true').lower() == 'true'
+    try:
+        logs = servin_client.get_logs(container_id, tail=0, timestamps=timestamps)
+        return Response(logs, mimetype='text/plain', headers={
+            'Content-Disposition': f'attachment; filename="{container_id[:12]}-logs.txt"'
+        })
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
 @app.route('/api/containers/<container_id>/files', methods=['GET'])
 def get_container_files(container_id):
-    """Get container filesystem listing using exec"""
+    """List a page of a directory in a container's filesystem"""
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
+    path = request.args.get('path', '/')
     try:
-        path = request.args.get('path', '/')
-        
-        # Use exec to run ls command inside the container for real filesystem access
-        # Try ls -la first, fallback to ls -l, then just ls
-        ls_commands = [
-            f"ls -la '{path}' 2>/dev/null",
-            f"ls -l '{path}' 2>/dev/null", 
-            f"ls '{path}' 2>/dev/null"
-        ]
-        
-        result = None
-        for cmd in ls_commands:
-            try:
-                result = servin_client.exec_command(container_id, cmd)
-                if result and result.strip():
-                    break
-            except:
-                continue
-        
-        if not result or not result.strip():
-            return jsonify([])
-        
-        # Parse the ls output to create file listing
-        files = []
-        lines = result.strip().split('\n')
-        
-        for line in lines:
-            line = line.strip()
-            if not line or line.startswith('total '):
-                continue
-                
-            # Skip . and .. entries from ls -la
-            if line.endswith(' .') or line.endswith(' ..'):
-                continue
-            
-            # Parse ls -la output: permissions links owner group size month day time/year name
-            parts = line.split()
-            if len(parts) >= 9:
-                permissions = parts[0]
-                size = parts[4] if parts[4].isdigit() else 0
-                name = ' '.join(parts[8:])  # Handle names with spaces
-                
-                is_directory = permissions.startswith('d')
-                is_symlink = permissions.startswith('l')
-                
-                files.append({
-                    'name': name,
-                    'type': 'directory' if is_directory else 'symlink' if is_symlink else 'file',
-                    'size': int(size) if str(size).isdigit() else 0,
-                    'permissions': permissions,
-                    'path': path
-                })
-            elif len(parts) >= 1:
-                # Fallback for simple ls output
-                name = ' '.join(parts)
-                # Try to determine if it's a directory by attempting to list it
-                try:
-                    test_result = servin_client.exec_command(container_id, f"test -d '{path.rstrip('/')}/{name}' && echo 'dir'")
-                    is_directory = 'dir' in test_result
-                except:
-                    is_directory = False
-                
-                files.append({
-                    'name': name,
-                    'type': 'directory' if is_directory else 'file',
-                    'size': 0,
-                    'permissions': '-',
-                    'path': path
-                })
-        
-        return jsonify(files)
-        
-    except Exception as e:
-        print(f"Error listing files: {e}")
-        return jsonify({'error': f'Failed to list files: {str(e)}'}), 500
+        offset = int(request.args.get('offset', 0))
+        limit = int(request.args.get('limit', 0))
+    except ValueError:
+        return jsonify({'error': 'offset and limit must be numbers'}), 400
+    if offset < 0 or limit < 0:
+        return jsonify({'error': 'offset and limit cannot be negative'}), 400
+    
+    try:
+        return jsonify(servin_client.list_directory(container_id, path, offset, limit))
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/<container_id>/files', methods=['DELETE'])
+def delete_container_file(container_id):
+    """Remove a file or directory from a container"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    path = request.args.get('path', '')
+    if not path.startswith('/') or path.rstrip('/') == '':
+        return jsonify({'error': 'An absolute path other than / is required'}), 400
+    
+    try:
+        servin_client.remove_path(container_id, path)
+        return jsonify({'success': True, 'path': path})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/<container_id>/files/download', methods=['GET'])
+def download_container_file(container_id):
+    """Download a file from a container, or a directory as a tar archive"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    path = request.args.get('path', '')
+    if not path.startswith('/'):
+        return jsonify({'error': 'An absolute path is required'}), 400
+    
+    name = os.path.basename(path.rstrip('/')) or 'rootfs'
+    try:
+        if request.args.get('type') == 'directory':
+            data = servin_client.archive_from_container(container_id, path)
+            mimetype = 'application/x-tar'
+            name += '.tar'
+        else:
+            data = servin_client.read_file(container_id, path)
+            mimetype = 'application/octet-stream'
+        return Response(data, mimetype=mimetype, headers={
+            'Content-Disposition': f'attachment; filename="{name}"'
+        })
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/<container_id>/files/mkdir', methods=['POST'])
+def make_container_directory(container_id):
+    """Create a directory in a container"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    path = (request.get_json(silent=True) or {}).get('path', '')
+    if not path.startswith('/'):
+        return jsonify({'error': 'An absolute path is required'}), 400
+    
+    try:
+        servin_client.make_directory(container_id, path)
+        return jsonify({'success': True, 'path': path})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/<container_id>/files/chmod', methods=['POST'])
+def chmod_container_file(container_id):
+    """Change the permissions of a file or directory in a container"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json(silent=True) or {}
+    path = data.get('path', '')
+    mode = str(data.get('mode', ''))
+    if not path.startswith('/'):
+        return jsonify({'error': 'An absolute path is required'}), 400
+    if len(mode) != 3 or any(c not in '01234567' for c in mode):
+        return jsonify({'error': 'Mode must be three octal digits, such as 644'}), 400
+    
+    try:
+        servin_client.chmod_path(container_id, path, mode)
+        return jsonify({'success': True, 'path': path, 'mode': mode})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/<container_id>/files/upload', methods=['POST'])
+def upload_container_files(container_id):
+    """Copy files dropped onto the GUI into a container directory"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+
+    path = request.form.get('path', '/')
+    uploads = request.files.getlist('files')
+    if not uploads:
+        return jsonify({'error': 'No files to upload'}), 400
+
+    # Stage the files with their relative paths (dropped folders keep their
+    # layout), then copy the staging directory's contents into the container
+    staging = tempfile.mkdtemp(prefix='servin-upload-')
+    try:
+        names = []
+        for upload in uploads:
+            rel = os.path.normpath(upload.filename.replace('\\', '/')).lstrip('/')
+            if not rel or rel == '.' or rel.startswith('..'):
+                return jsonify({'error': f'Invalid file name: {upload.filename}'}), 400
+            target = os.path.join(staging, rel)
+            os.makedirs(os.path.dirname(target), exist_ok=True)
+            upload.save(target)
+            names.append(rel)
+
+        servin_client.copy_to_container(container_id, staging, path)
+        return jsonify({'success': True, 'path': path, 'files': names})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+    finally:
+        shutil.rmtree(staging, ignore_errors=True)
 
 @app.route('/api/containers/<container_id>/exec', methods=['POST'])
 def exec_container_command(container_id):
@@ -334,6 +657,49 @@ def get_container_environment(container_id):
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
+@app.route('/api/containers/<container_id>/mounts', methods=['GET'])
+def get_container_mounts(container_id):
+    """Get container volumes and bind mounts"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        mounts = servin_client.get_mounts(container_id)
+        return jsonify({'mounts': mounts})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/<container_id>/stats/history', methods=['GET'])
+def get_container_stats_history(container_id):
+    """Get the resource usage samples retained for a container"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        samples = servin_client.get_stats_history(container_id, request.args.get('since', '30m'))
+        return jsonify({'samples': samples})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/containers/<container_id>/stats/export', methods=['GET'])
+def export_container_stats(container_id):
+    """Download the resource usage history of a container as CSV or JSON"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    fmt = request.args.get('format', 'csv')
+    if fmt not in ('csv', 'json'):
+        return jsonify({'error': 'Format must be csv or json'}), 400
+    
+    try:
+        data = servin_client.export_stats(container_id, request.args.get('since', '30m'), fmt)
+        mimetype = 'text/csv' if fmt == 'csv' else 'application/json'
+        return Response(data, mimetype=mimetype, headers={
+            'Content-Disposition': f'attachment; filename="{container_id[:12]}-stats.{fmt}"'
+        })
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
 # Image Management APIs
 @app.route('/api/images', methods=['GET'])
 def get_images():
@@ -349,20 +715,67 @@ def get_images():
 
 @app.route('/api/images/pull', methods=['POST'])
 def pull_image():
-    """Pull an image from registry (import for servin)"""
+    """Pull an image in the background.
+    
+    Each line servin prints is sent to clients as a `pull_output` event,
+    the start of each layer as a `pull_progress` event and the outcome as a
+    `pull_finished` event.
+    """
+    global pull_process
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
-    data = request.get_json()
-    if not data or 'image' not in data:
+    data = request.get_json() or {}
+    image_name = (data.get('image') or '').strip()
+    if not image_name:
         return jsonify({'error': 'Image name required'}), 400
+    platform = (data.get('platform') or '').strip() or None
     
-    try:
-        # For servin, we'll explain that pull is not supported
-        # Instead, users need to import images from tarballs
-        return jsonify({'error': 'Servin does not support pulling images from registries. Use image import with tarball files instead.'}), 400
-    except ServinError as e:
-        return jsonify({'error': str(e)}), 500
+    with pull_lock:
+        if pull_process and pull_process.poll() is None:
+            return jsonify({'error': 'A pull is already running'}), 409
+        
+        try:
+            pull_process = subprocess.Popen(
+                servin_client.pull_command_line(image_name, platform),
+                stdout=subprocess.PIPE, stderr=subprocess.STDOUT,
+                text=True, bufsize=1)
+        except (OSError, ServinError) as e:
+            return jsonify({'error': f'Failed to start pull: {e}'}), 500
+        
+        thread = threading.Thread(target=pull_thread, args=(pull_process, image_name), daemon=True)
+        thread.start()
+    
+    return jsonify({'success': True, 'message': f'Pulling {image_name}'})
+
+# Layers are downloaded, or found in the layer store, one after the other
+PULL_LAYER = re.compile(r'^(?:Downloading layer|Layer) (\d+)/(\d+) ')
+
+def pull_thread(process, image_name):
+    """Forward the output and layers of a running pull to clients"""
+    errors = []
+    for line in process.stdout:
+        line = line.rstrip('\n')
+        socketio.emit('pull_output', {'image': image_name, 'line': line})
+        
+        layer = PULL_LAYER.match(line)
+        if layer:
+            socketio.emit('pull_progress', {
+                'image': image_name,
+                'layer': int(layer.group(1)),
+                'total': int(layer.group(2))
+            })
+        elif line.startswith('Error: '):
+            errors.append(line[len('Error: '):])
+    process.wait()
+    process.stdout.close()
+    
+    success = process.returncode == 0
+    socketio.emit('pull_finished', {
+        'image': image_name,
+        'success': success,
+        'error': None if success else (errors[-1] if errors else 'Pull failed')
+    })
 
 @app.route('/api/images/import', methods=['POST'])
 def import_image():
@@ -382,6 +795,29 @@ def import_image():
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
+@app.route('/api/images/load', methods=['POST'])
+def load_image_archive():
+    """Load the images in a docker-archive or OCI archive dropped onto the
+    GUI"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    upload = request.files.get('archive')
+    if not upload:
+        return jsonify({'error': 'No archive to load'}), 400
+    
+    # servin load reads the archive more than once, so it is staged to a file
+    fd, path = tempfile.mkstemp(prefix='servin-load-', suffix='.tar')
+    os.close(fd)
+    try:
+        upload.save(path)
+        images = servin_client.load_image(path)
+        return jsonify({'success': True, 'images': images})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+    finally:
+        os.remove(path)
+
 @app.route('/api/images/<image_id>/remove', methods=['DELETE'])
 def remove_image(image_id):
     """Remove an image"""
@@ -389,151 +825,521 @@ def remove_image(image_id):
         return jsonify({'error': 'Servin runtime not available'}), 500
     
     try:
-        servin_client.remove_image(image_id, force=True)
+        servin_client.remove_image(image_id)
         return jsonify({'success': True, 'message': f'Image {image_id} removed'})
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
-# Volume Management APIs
-@app.route('/api/volumes', methods=['GET'])
-def get_volumes():
-    """Get list of all volumes"""
+@app.route('/api/images/bulk', methods=['POST'])
+def bulk_image_action():
+    """Remove several images"""
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
-    try:
-        volumes = servin_client.list_volumes()
-        return jsonify(volumes)
-    except ServinError as e:
-        return jsonify({'error': str(e)}), 500
+    data = request.get_json() or {}
+    if data.get('action') != 'remove':
+        return jsonify({'error': 'action must be remove'}), 400
+    ids = data.get('ids')
+    if not isinstance(ids, list) or not ids:
+        return jsonify({'error': 'Image IDs required'}), 400
+    
+    return jsonify(apply_to_each(ids, servin_client.remove_image))
 
-@app.route('/api/volumes/<volume_name>/remove', methods=['DELETE'])
-def remove_volume(volume_name):
-    """Remove a volume"""
+@app.route('/api/images/prune', methods=['GET', 'POST'])
+def prune_images():
+    """Remove unused images, or with GET list the images that would be
+    removed"""
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
+    if request.method == 'GET':
+        all_images = request.args.get('all', '').lower() in ('1', 'true', 'yes')
+    else:
+        all_images = bool((request.get_json() or {}).get('all'))
+    
     try:
-        servin_client.remove_volume(volume_name)
-        return jsonify({'success': True, 'message': f'Volume {volume_name} removed'})
+        return jsonify(servin_client.prune_images(all_images=all_images, dry_run=request.method == 'GET'))
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
-@app.route('/api/volumes/create', methods=['POST'])
-def create_volume():
-    """Create a new volume"""
+@app.route('/api/images/<image_id>/inspect', methods=['GET'])
+def inspect_image(image_id):
+    """Get the details of an image"""
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
-    data = request.get_json()
-    if not data or 'name' not in data:
-        return jsonify({'error': 'Volume name required'}), 400
-    
     try:
-        volume_name = data['name']
-        servin_client.create_volume(volume_name)
-        return jsonify({'success': True, 'message': f'Volume {volume_name} created successfully'})
+        return jsonify(servin_client.inspect_image(image_id))
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
-# VM Engine Management APIs
-@app.route('/api/vm/status', methods=['GET'])
-def get_vm_status():
-    """Get VM engine status"""
+@app.route('/api/images/<image_id>/tag', methods=['POST'])
+def tag_image(image_id):
+    """Add a tag to an image"""
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
+    data = request.get_json() or {}
+    target = (data.get('tag') or '').strip()
+    if not target:
+        return jsonify({'error': 'A tag is required'}), 400
+    
     try:
-        # Get the Servin root directory (parent of webview_gui)
-        servin_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
-        
-        # Call servin vm status command using go run
-        result = subprocess.run(['go', 'run', 'main.go', '--dev', 'vm', 'status'], 
-                              cwd=servin_root,
-                              capture_output=True, text=True, timeout=10)
-        
-        if result.returncode == 0:
-            # Parse the status output
-            output_lines = result.stdout.strip().split('\n')
-            status_info = {
-                'available': True,
-                'enabled': False,
-                'running': False,
-                'provider': 'Unknown',
-                'platform': 'Unknown',
-                'containers': 0,
-                'details': {}
-            }
-            
-            for line in output_lines:
-                if 'VM mode:' in line:
-                    status_info['enabled'] = 'Enabled' in line
-                elif 'VM Status:' in line:
-                    status_info['running'] = 'running' in line.lower()
-                elif 'VM Provider:' in line:
-                    status_info['provider'] = line.split(':', 1)[1].strip()
-                elif 'Platform:' in line:
-                    status_info['platform'] = line.split(':', 1)[1].strip()
-                elif 'VM Name:' in line:
-                    status_info['details']['name'] = line.split(':', 1)[1].strip()
-                elif 'IP Address:' in line:
-                    status_info['details']['ip'] = line.split(':', 1)[1].strip()
-                elif 'SSH Port:' in line:
-                    status_info['details']['ssh_port'] = line.split(':', 1)[1].strip()
-                elif 'Docker Port:' in line:
-                    status_info['details']['docker_port'] = line.split(':', 1)[1].strip()
-                elif 'Containers in VM:' in line:
-                    try:
-                        status_info['containers'] = int(line.split(':', 1)[1].strip())
-                    except:
-                        status_info['containers'] = 0
-            
-            return jsonify(status_info)
-        else:
-            return jsonify({'available': False, 'error': result.stderr or 'VM not available'})
-            
-    except subprocess.TimeoutExpired:
-        return jsonify({'available': False, 'error': 'VM status check timeout'})
-    except Exception as e:
-        return jsonify({'available': False, 'error': str(e)})
+        servin_client.tag_image(image_id, target)
+        return jsonify({'success': True, 'message': f'Image tagged {target}'})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
 
-@app.route('/api/vm/start', methods=['POST'])
-def start_vm():
-    """Start the VM engine"""
+@app.route('/api/images/push', methods=['POST'])
+def push_image():
+    """Push an image to the registry in its name"""
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
+    data = request.get_json() or {}
+    image_name = (data.get('image') or '').strip()
+    if not image_name:
+        return jsonify({'error': 'Image name required'}), 400
+    
     try:
-        # Get the Servin root directory (parent of webview_gui)
-        servin_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
-        
-        result = subprocess.run(['go', 'run', 'main.go', '--dev', 'vm', 'start'], 
-                              cwd=servin_root,
-                              capture_output=True, text=True, timeout=60)
-        
-        if result.returncode == 0:
-            return jsonify({'success': True, 'message': 'VM engine started successfully'})
-        else:
-            return jsonify({'error': result.stderr or 'Failed to start VM engine'}), 500
-            
-    except subprocess.TimeoutExpired:
-        return jsonify({'error': 'VM start timeout - VM may be starting in background'}), 500
-    except Exception as e:
+        servin_client.push_image(image_name)
+        return jsonify({'success': True, 'message': f'Image {image_name} pushed'})
+    except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
-@app.route('/api/vm/stop', methods=['POST'])
-def stop_vm():
-    """Stop the VM engine"""
+@app.route('/api/images/<image_id>/export', methods=['GET'])
+def export_image(image_id):
+    """Download an image as a docker-archive tarball"""
     if not servin_client:
         return jsonify({'error': 'Servin runtime not available'}), 500
     
+    # Progress goes to stderr as the archive is written; a file holds it
+    # without blocking servin while the download is read
+    stderr = tempfile.TemporaryFile()
     try:
-        # Get the Servin root directory (parent of webview_gui)
-        servin_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+        process = subprocess.Popen(servin_client.save_command_line(image_id),
+                                   stdout=subprocess.PIPE, stderr=stderr)
+    except (OSError, ServinError) as e:
+        stderr.close()
+        return jsonify({'error': f'Failed to export image: {e}'}), 500
+    
+    # A failure shows before any output, while the error can still be returned
+    first = process.stdout.read(64 * 1024)
+    if not first and process.wait() != 0:
+        stderr.seek(0)
+        message = servin_client._error_message(stderr.read())
+        stderr.close()
+        return jsonify({'error': f'Failed to export image: {message}'}), 500
+    
+    def generate():
+        try:
+            yield first
+            while True:
+                chunk = process.stdout.read(64 * 1024)
+                if not chunk:
+                    break
+                yield chunk
+        finally:
+            if process.poll() is None:
+                process.kill()
+            process.wait()
+            stderr.close()
+    
+    name = request.args.get('name') or image_id
+    filename = ''.join(c if c.isalnum() or c in '-_.' else '_' for c in name) + '.tar'
+    return Response(generate(), mimetype='application/x-tar', headers={
+        'Content-Disposition': f'attachment; filename="{filename}"'
+    })
+
+@app.route('/api/images/build', methods=['POST'])
+def build_image():
+    """Build an image in the background.
+    
+    Each line servin prints is sent to clients as a `build_output` event,
+    the start of each step as a `build_step` event and the outcome as a
+    `build_finished` event.
+    """
+    global build_process, build_cancelled
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json() or {}
+    context = os.path.expanduser((data.get('context') or '').strip())
+    if not context:
+        return jsonify({'error': 'Build context folder required'}), 400
+    if not os.path.isdir(context):
+        return jsonify({'error': f'{context} is not a folder'}), 400
+    build_args = data.get('build_args') or {}
+    if not isinstance(build_args, dict):
+        return jsonify({'error': 'build_args must be an object'}), 400
+    
+    with build_lock:
+        if build_process and build_process.poll() is None:
+            return jsonify({'error': 'A build is already running'}), 409
+        
+        try:
+            # A new session lets a cancel interrupt the steps servin runs too
+            build_process = subprocess.Popen(
+                servin_client.build_command_line(
+                    os.path.abspath(context),
+                    buildfile=(data.get('file') or '').strip() or None,
+                    tag=(data.get('tag') or '').strip() or None,
+                    build_args={str(key): str(value) for key, value in build_args.items()},
+                    no_cache=bool(data.get('no_cache'))),
+                stdout=subprocess.PIPE, stderr=subprocess.STDOUT,
+                text=True, bufsize=1,
+                start_new_session=(os.name != 'nt'))
+        except (OSError, ServinError) as e:
+            return jsonify({'error': f'Failed to start build: {e}'}), 500
+        build_cancelled = False
+        
+        thread = threading.Thread(target=build_thread, args=(build_process,), daemon=True)
+        thread.start()
+    
+    return jsonify({'success': True, 'message': 'Build started'})
+
+@app.route('/api/images/build/context', methods=['POST'])
+def stage_build_context():
+    """Copy a folder dropped onto the GUI to a host folder to build from,
+    since the webview does not tell where the dropped folder is"""
+    uploads = request.files.getlist('files')
+    if not uploads:
+        return jsonify({'error': 'No folder to build'}), 400
+    
+    staging = tempfile.mkdtemp(prefix='servin-context-')
+    # The copy is kept for builds from it until the GUI exits
+    atexit.register(shutil.rmtree, staging, True)
+    folders = set()
+    for upload in uploads:
+        rel = os.path.normpath(upload.filename.replace('\\', '/')).lstrip('/')
+        if not rel or rel == '.' or rel.startswith('..') or os.sep not in rel:
+            return jsonify({'error': f'Invalid file name: {upload.filename}'}), 400
+        folders.add(rel.split(os.sep, 1)[0])
+        target = os.path.join(staging, rel)
+        os.makedirs(os.path.dirname(target), exist_ok=True)
+        upload.save(target)
+    if len(folders) != 1:
+        return jsonify({'error': 'Drop one folder to build'}), 400
+    
+    context = os.path.join(staging, folders.pop())
+    build_files = [name for name in os.listdir(context)
+                   if is_build_file(name) and os.path.isfile(os.path.join(context, name))]
+    return jsonify({'path': context, 'build_files': sorted(build_files, key=str.lower)})
+
+@app.route('/api/images/build/cancel', methods=['POST'])
+def cancel_build():
+    """Cancel the running build"""
+    global build_cancelled
+    with build_lock:
+        process = build_process
+        if not process or process.poll() is not None:
+            return jsonify({'error': 'No build is running'}), 409
+        
+        try:
+            if os.name == 'nt':
+                process.terminate()
+            else:
+                os.killpg(process.pid, signal.SIGINT)
+        except Exception as e:
+            return jsonify({'error': str(e)}), 500
+        build_cancelled = True
+    
+    return jsonify({'success': True, 'message': 'Cancelling build'})
+
+# Stages building concurrently prefix their lines with the stage's name
+BUILD_STEP = re.compile(r'^(?:\[([^\]]+)\] )?Step (\d+)/(\d+) : (.*)$')
+
+def build_thread(process):
+    """Forward the output and steps of a running build to clients"""
+    errors = []
+    image_id = None
+    for line in process.stdout:
+        line = line.rstrip('\n')
+        socketio.emit('build_output', {'line': line})
         
-        result = subprocess.run(['go', 'run', 'main.go', '--dev', 'vm', 'stop'], 
-                              cwd=servin_root,
-                              capture_output=True, text=True, timeout=30)
+        step = BUILD_STEP.match(line)
+        if step:
+            socketio.emit('build_step', {
+                'stage': step.group(1) or '',
+                'step': int(step.group(2)),
+                'total': int(step.group(3)),
+                'instruction': step.group(4)
+            })
+        elif line.startswith('Successfully built image: '):
+            image_id = line[len('Successfully built image: '):].strip()
+        elif line.startswith('Error: '):
+            errors.append(line[len('Error: '):])
+    process.wait()
+    process.stdout.close()
+    
+    success = process.returncode == 0 and image_id is not None
+    socketio.emit('build_finished', {
+        'success': success,
+        'cancelled': build_cancelled and not success,
+        'image_id': image_id,
+        'error': None if success else (errors[-1] if errors else 'Build failed')
+    })
+
+# Volume Management APIs
+@app.route('/api/volumes', methods=['GET'])
+def get_volumes():
+    """Get list of all volumes"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        volumes = servin_client.list_volumes()
+        return jsonify(volumes)
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/volumes/<volume_name>/inspect', methods=['GET'])
+def inspect_volume(volume_name):
+    """Get the details of a volume"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify(servin_client.inspect_volume(volume_name))
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/volumes/<volume_name>/reveal', methods=['POST'])
+def reveal_volume(volume_name):
+    """Open a volume's mountpoint in the host's file manager"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        mountpoint = servin_client.inspect_volume(volume_name)['mountpoint']
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+    
+    # Volumes of remote or VM-backed drivers have no folder on this host
+    if not os.path.isdir(mountpoint):
+        return jsonify({'error': f'{mountpoint} is not a folder on this host'}), 404
+    
+    try:
+        if sys.platform == 'win32':
+            os.startfile(mountpoint)
+        elif sys.platform == 'darwin':
+            subprocess.Popen(['open', mountpoint])
+        else:
+            subprocess.Popen(['xdg-open', mountpoint], stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)
+        return jsonify({'success': True, 'path': mountpoint})
+    except OSError as e:
+        return jsonify({'error': f'Failed to open {mountpoint}: {e}'}), 500
+
+@app.route('/api/networks', methods=['GET'])
+def get_networks():
+    """Get list of all networks"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify(servin_client.list_networks())
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/volumes/<volume_name>/remove', methods=['DELETE'])
+def remove_volume(volume_name):
+    """Remove a volume"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        servin_client.remove_volume(volume_name)
+        return jsonify({'success': True, 'message': f'Volume {volume_name} removed'})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/volumes/create', methods=['POST'])
+def create_volume():
+    """Create a new volume"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json()
+    if not data or 'name' not in data:
+        return jsonify({'error': 'Volume name required'}), 400
+    
+    try:
+        volume_name = data['name']
+        servin_client.create_volume(volume_name)
+        return jsonify({'success': True, 'message': f'Volume {volume_name} created successfully'})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/volumes/prune', methods=['GET', 'POST'])
+def prune_volumes():
+    """Remove the volumes no container uses, or with GET list the volumes
+    that would be removed"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify(servin_client.prune_volumes(dry_run=request.method == 'GET'))
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+# VM Engine Management APIs
+
+# `servin vm` runs from source, in the Servin root directory (the parent of
+# webview_gui)
+SERVIN_ROOT = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+VM_COMMAND = ['go', 'run', 'main.go', '--dev', 'vm']
+
+def run_vm_command(args, timeout):
+    """Run `servin vm` with args from the Servin root directory"""
+    return subprocess.run(VM_COMMAND + args, cwd=SERVIN_ROOT,
+                          capture_output=True, text=True, timeout=timeout)
+
+@app.route('/api/vm/status', methods=['GET'])
+def get_vm_status():
+    """Get VM engine status"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    return jsonify(vm_status())
+
+def vm_status():
+    """The VM engine's status, parsed from `servin vm status`"""
+    try:
+        result = run_vm_command(['status'], 10)
+        
+        if result.returncode == 0:
+            # Parse the status output
+            output_lines = result.stdout.strip().split('\n')
+            status_info = {
+                'available': True,
+                'enabled': False,
+                'running': False,
+                'paused': False,
+                'provider': 'Unknown',
+                'platform': 'Unknown',
+                'containers': 0,
+                'details': {}
+            }
+            
+            for line in output_lines:
+                if 'VM mode:' in line:
+                    status_info['enabled'] = 'Enabled' in line
+                elif 'VM Status:' in line:
+                    status_info['running'] = 'running' in line.lower()
+                    status_info['paused'] = 'paused' in line.lower()
+                elif 'VM Provider:' in line:
+                    status_info['provider'] = line.split(':', 1)[1].strip()
+                elif 'Platform:' in line:
+                    status_info['platform'] = line.split(':', 1)[1].strip()
+                elif 'VM Name:' in line:
+                    status_info['details']['name'] = line.split(':', 1)[1].strip()
+                elif 'IP Address:' in line:
+                    status_info['details']['ip'] = line.split(':', 1)[1].strip()
+                elif 'SSH Port:' in line:
+                    status_info['details']['ssh_port'] = line.split(':', 1)[1].strip()
+                elif 'Docker Port:' in line:
+                    status_info['details']['docker_port'] = line.split(':', 1)[1].strip()
+                elif 'Containers in VM:' in line:
+                    try:
+                        status_info['containers'] = int(line.split(':', 1)[1].strip())
+                    except:
+                        status_info['containers'] = 0
+            
+            return status_info
+        else:
+            return {'available': False, 'error': result.stderr or 'VM not available'}
+            
+    except subprocess.TimeoutExpired:
+        return {'available': False, 'error': 'VM status check timeout'}
+    except Exception as e:
+        return {'available': False, 'error': str(e)}
+
+@app.route('/api/vm/start', methods=['POST'])
+def start_vm():
+    """Start the VM engine in the background.
+
+    Asset download progress is sent to clients as `vm_download` events and
+    the outcome as a `vm_start_finished` event.
+    """
+    global vm_start_process
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        with vm_start_lock:
+            if vm_start_process and vm_start_process.poll() is None:
+                return jsonify({'error': 'VM engine is already starting'}), 409
+            
+            # A new session lets a cancel interrupt `go run` and servin together
+            vm_start_process = subprocess.Popen(
+                VM_COMMAND + ['start', '--progress', 'json'],
+                cwd=SERVIN_ROOT,
+                stdout=subprocess.PIPE, stderr=subprocess.STDOUT,
+                text=True, bufsize=1,
+                start_new_session=(os.name != 'nt'))
+            
+            thread = threading.Thread(target=vm_start_thread, args=(vm_start_process,), daemon=True)
+            thread.start()
+        
+        return jsonify({'success': True, 'message': 'VM engine starting'})
+            
+    except Exception as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/start/cancel', methods=['POST'])
+def cancel_vm_start():
+    """Cancel a VM engine start, stopping any asset download"""
+    with vm_start_lock:
+        process = vm_start_process
+        if not process or process.poll() is not None:
+            return jsonify({'error': 'VM engine is not starting'}), 409
+        
+        try:
+            if os.name == 'nt':
+                process.terminate()
+            else:
+                os.killpg(process.pid, signal.SIGINT)
+        except Exception as e:
+            return jsonify({'error': str(e)}), 500
+    
+    return jsonify({'success': True, 'message': 'Cancelling VM engine start'})
+
+def vm_start_thread(process):
+    """Forward the download events and output of a starting VM engine to clients"""
+    errors = []
+    cancelled = False
+    for line in process.stdout:
+        line = line.strip()
+        if line.startswith('{'):
+            try:
+                event = json.loads(line)
+                cancelled = cancelled or event.get('state') == 'cancelled'
+                socketio.emit('vm_download', event)
+                continue
+            except ValueError:
+                pass
+        if line.startswith('Error'):
+            errors.append(line)
+        if line:
+            socketio.emit('vm_start_output', {'line': line})
+    process.wait()
+    
+    success = process.returncode == 0 and not errors and not cancelled
+    socketio.emit('vm_start_finished', {
+        'success': success,
+        'cancelled': cancelled,
+        'error': None if success else (errors[-1] if errors else 'Failed to start VM engine')
+    })
+
+@app.route('/api/vm/stop', methods=['POST'])
+def stop_vm():
+    """Stop the VM engine"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        notifications.expect_stop('vm')
+        result = run_vm_command(['stop'], 30)
         
         if result.returncode == 0:
             return jsonify({'success': True, 'message': 'VM engine stopped successfully'})
@@ -545,6 +1351,100 @@ def stop_vm():
     except Exception as e:
         return jsonify({'error': str(e)}), 500
 
+@app.route('/api/vm/pause', methods=['POST'])
+def pause_vm():
+    """Freeze the VM engine with every container in it"""
+    return vm_pause_command('pause')
+
+@app.route('/api/vm/resume', methods=['POST'])
+def resume_vm():
+    """Let a paused VM engine continue"""
+    return vm_pause_command('resume')
+
+def vm_pause_command(action):
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        result = run_vm_command([action], 30)
+        
+        if result.returncode == 0:
+            return jsonify({'success': True, 'message': f'VM engine {action}d'})
+        else:
+            return jsonify({'error': result.stderr or f'Failed to {action} VM engine'}), 500
+            
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': f'VM {action} timeout'}), 500
+    except Exception as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/resources', methods=['GET'])
+def get_vm_resources():
+    """The CPUs, memory and disk size of the VM, from `servin vm config`"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        result = run_vm_command(['config'], 30)
+        
+        resources = {}
+        for line in result.stdout.splitlines():
+            key, _, value = line.strip().partition(':')
+            field = {'Name': 'name', 'CPUs': 'cpus', 'Memory': 'memory', 'Disk': 'disk'}.get(key)
+            if field == 'name':
+                resources[field] = value.strip()
+            elif field:
+                try:
+                    resources[field] = int(value.split()[0])
+                except (IndexError, ValueError):
+                    pass
+        
+        if result.returncode != 0 or 'cpus' not in resources:
+            error = next((line[len('Error: '):] for line in result.stdout.splitlines() if line.startswith('Error: ')), None)
+            return jsonify({'error': error or result.stderr.strip() or 'VM configuration not available'}), 500
+        return jsonify(resources)
+            
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM config timeout'}), 500
+    except Exception as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/resize', methods=['POST'])
+def resize_vm():
+    """Change the VM's CPUs, memory (MB) or disk size (GB); a running VM is
+    restarted for it"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json() or {}
+    args = []
+    for field in ('cpus', 'memory', 'disk'):
+        if data.get(field) is None:
+            continue
+        value = data[field]
+        if not isinstance(value, int) or isinstance(value, bool) or value <= 0:
+            return jsonify({'error': f'{field} must be a positive number'}), 400
+        args += [f'--{field}', str(value)]
+    if not args:
+        return jsonify({'error': 'cpus, memory or disk required'}), 400
+    
+    try:
+        notifications.expect_stop('vm')
+        result = run_vm_command(['resize'] + args, 600)
+        
+        if result.returncode == 0:
+            # The new sizes, and whether they wait for the next start
+            message = ' '.join(line for line in result.stdout.splitlines()
+                               if line.startswith(('VM resized', 'The new resources')))
+            return jsonify({'success': True, 'message': message or 'VM resized'})
+        else:
+            return jsonify({'error': servin_client._error_message(result.stderr) or 'Failed to resize VM'}), 500
+            
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM resize timeout'}), 500
+    except Exception as e:
+        return jsonify({'error': str(e)}), 500
+
 @app.route('/api/vm/restart', methods=['POST'])
 def restart_vm():
     """Restart the VM engine"""
@@ -552,21 +1452,15 @@ def restart_vm():
         return jsonify({'error': 'Servin runtime not available'}), 500
     
     try:
-        # Get the Servin root directory (parent of webview_gui)
-        servin_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
-        
+        notifications.expect_stop('vm')
         # Stop first
-        stop_result = subprocess.run(['go', 'run', 'main.go', '--dev', 'vm', 'stop'], 
-                                   cwd=servin_root,
-                                   capture_output=True, text=True, timeout=30)
+        run_vm_command(['stop'], 30)
         
         # Wait a moment
         time.sleep(2)
         
         # Start again
-        start_result = subprocess.run(['go', 'run', 'main.go', '--dev', 'vm', 'start'], 
-                                    cwd=servin_root,
-                                    capture_output=True, text=True, timeout=60)
+        start_result = run_vm_command(['start'], 60)
         
         if start_result.returncode == 0:
             return jsonify({'success': True, 'message': 'VM engine restarted successfully'})
@@ -585,12 +1479,7 @@ def enable_vm():
         return jsonify({'error': 'Servin runtime not available'}), 500
     
     try:
-        # Get the Servin root directory (parent of webview_gui)
-        servin_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
-        
-        result = subprocess.run(['go', 'run', 'main.go', '--dev', 'vm', 'enable'], 
-                              cwd=servin_root,
-                              capture_output=True, text=True, timeout=15)
+        result = run_vm_command(['enable'], 15)
         
         if result.returncode == 0:
             return jsonify({'success': True, 'message': 'VM mode enabled successfully'})
@@ -609,12 +1498,8 @@ def disable_vm():
         return jsonify({'error': 'Servin runtime not available'}), 500
     
     try:
-        # Get the Servin root directory (parent of webview_gui)
-        servin_root = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
-        
-        result = subprocess.run(['go', 'run', 'main.go', '--dev', 'vm', 'disable'], 
-                              cwd=servin_root,
-                              capture_output=True, text=True, timeout=15)
+        notifications.expect_stop('vm')
+        result = run_vm_command(['disable'], 15)
         
         if result.returncode == 0:
             return jsonify({'success': True, 'message': 'VM mode disabled successfully'})
@@ -626,6 +1511,410 @@ def disable_vm():
     except Exception as e:
         return jsonify({'error': str(e)}), 500
 
+def vm_command_json(args, timeout=30):
+    """Run a `servin vm` command printing JSON, returning what it printed"""
+    result = run_vm_command(args + ['--format', 'json'], timeout)
+    if result.returncode != 0:
+        raise ServinError(servin_client._error_message(result.stderr) or f"servin vm {args[0]} failed")
+    try:
+        return json.loads(result.stdout)
+    except ValueError as e:
+        raise ServinError(f"Failed to parse servin vm {args[0]} output: {e}")
+
+@app.route('/api/vm/providers', methods=['GET'])
+def get_vm_providers():
+    """The VM providers of this platform, with the one new VMs use"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify({'providers': vm_command_json(['list-providers'])})
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM provider check timeout'}), 500
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/list', methods=['GET'])
+def list_vms():
+    """The VMs, with the one in use marked active"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify({'vms': vm_command_json(['ls'])})
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM list timeout'}), 500
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/create', methods=['POST'])
+def create_vm():
+    """Create a named VM with its own resources, optionally using it"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json() or {}
+    name = data.get('name')
+    if not isinstance(name, str) or not name.strip():
+        return jsonify({'error': 'VM name required'}), 400
+    args = ['create', name.strip()]
+    for field in ('cpus', 'memory', 'disk'):
+        if data.get(field) is None:
+            continue
+        value = data[field]
+        if not isinstance(value, int) or isinstance(value, bool) or value <= 0:
+            return jsonify({'error': f'{field} must be a positive number'}), 400
+        args += [f'--{field}', str(value)]
+    if data.get('use'):
+        args.append('--use')
+    
+    try:
+        result = run_vm_command(args, 30)
+        if result.returncode == 0:
+            return jsonify({'success': True, 'message': f'VM {name.strip()} created'})
+        return jsonify({'error': servin_client._error_message(result.stderr) or 'Failed to create VM'}), 500
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM create timeout'}), 500
+    except Exception as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/use', methods=['POST'])
+def use_vm():
+    """Use another VM from now on, as `servin vm use` does"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    name = (request.get_json() or {}).get('name')
+    if not isinstance(name, str) or not name:
+        return jsonify({'error': 'VM name required'}), 400
+    
+    try:
+        result = run_vm_command(['use', name], 15)
+        if result.returncode == 0:
+            return jsonify({'success': True, 'message': f'Using VM {name}'})
+        return jsonify({'error': servin_client._error_message(result.stderr) or f'Failed to use VM {name}'}), 500
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM use timeout'}), 500
+    except Exception as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/destroy', methods=['POST'])
+def destroy_vm():
+    """Delete the VM in use with its disk, containers and images. The name of
+    the VM must be given, as `servin vm destroy` asks for it"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    name = (request.get_json() or {}).get('name')
+    try:
+        active = next((vm['name'] for vm in vm_command_json(['ls']) if vm.get('active')), None)
+        if not name or name != active:
+            return jsonify({'error': f'Type the name of the VM in use ({active}) to destroy it'}), 400
+        
+        notifications.expect_stop('vm')
+        result = run_vm_command(['destroy', '--force'], 120)
+        if result.returncode == 0:
+            return jsonify({'success': True, 'message': f'VM {name} destroyed'})
+        return jsonify({'error': servin_client._error_message(result.stderr) or 'Failed to destroy VM'}), 500
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM destroy timeout'}), 500
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/vm/disk', methods=['GET'])
+def get_vm_disk():
+    """How much of the running VM's root filesystem is used, in MB"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        result = run_vm_command(['shell', '--', 'df', '-kP', '/'], 30)
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM disk usage timeout'}), 500
+    
+    # Filesystem 1024-blocks Used Available Capacity Mounted-on
+    fields = result.stdout.strip().splitlines()[-1].split() if result.stdout.strip() else []
+    if result.returncode != 0 or len(fields) < 6 or not fields[1].isdigit():
+        return jsonify({'error': servin_client._error_message(result.stderr) or 'VM disk usage not available'}), 500
+    size, used, available = (int(value) // 1024 for value in fields[1:4])
+    # As df does, leaving out the blocks reserved for root
+    return jsonify({'size_mb': size, 'used_mb': used, 'available_mb': available,
+                    'percent': round(used * 100 / (used + available), 1) if used + available else 0})
+
+@app.route('/api/vm/console', methods=['GET'])
+def get_vm_console():
+    """The last lines the VM wrote to its serial console"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        tail = max(1, min(int(request.args.get('tail', 200)), 5000))
+    except ValueError:
+        return jsonify({'error': 'tail must be a number'}), 400
+    
+    try:
+        result = run_vm_command(['console', '--log'], 15)
+    except subprocess.TimeoutExpired:
+        return jsonify({'error': 'VM console timeout'}), 500
+    if result.returncode != 0:
+        return jsonify({'error': servin_client._error_message(result.stderr) or 'VM console log not available'}), 500
+    return jsonify({'lines': result.stdout.splitlines()[-tail:]})
+
+@app.route('/api/vm/shell', methods=['POST'])
+def open_vm_shell():
+    """Open a terminal on the host with a shell in the VM"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    command = ' '.join(VM_COMMAND + ['shell'])
+    try:
+        if sys.platform == 'win32':
+            subprocess.Popen(['cmd', '/c', 'start', 'Servin VM', 'cmd', '/k', command], cwd=SERVIN_ROOT)
+        elif sys.platform == 'darwin':
+            script = f'cd {shlex.quote(SERVIN_ROOT)} && {command}'
+            subprocess.Popen(['osascript',
+                              '-e', f'tell application "Terminal" to do script {json.dumps(script)}',
+                              '-e', 'tell application "Terminal" to activate'])
+        else:
+            terminal = next((t for t in ('x-terminal-emulator', 'gnome-terminal', 'konsole', 'xterm')
+                             if shutil.which(t)), None)
+            if not terminal:
+                return jsonify({'error': "No terminal emulator found; run 'servin vm shell' in one"}), 404
+            # gnome-terminal takes the command after --, the others after -e
+            flag = '--' if terminal == 'gnome-terminal' else '-e'
+            subprocess.Popen([terminal, flag, 'sh', '-c', command], cwd=SERVIN_ROOT,
+                             stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)
+        return jsonify({'success': True})
+    except OSError as e:
+        return jsonify({'error': f'Failed to open a terminal: {e}'}), 500
+
+# Registry APIs
+@app.route('/api/registries', methods=['GET'])
+def get_registries():
+    """The local registry and the remote registries added by name"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify(servin_client.list_registries())
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/registries', methods=['POST'])
+def add_registry():
+    """Add a remote registry, logging in to it first when credentials are
+    given so a registry is not added with credentials it refuses"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json() or {}
+    name = (data.get('name') or '').strip()
+    registry = (data.get('registry') or '').strip()
+    username = (data.get('username') or '').strip()
+    password = data.get('password') or ''
+    if not name or not registry:
+        return jsonify({'error': 'A name and registry are required'}), 400
+    if bool(username) != bool(password):
+        return jsonify({'error': 'Give both a username and a password, or neither'}), 400
+    
+    try:
+        if username:
+            servin_client.registry_login(registry, username, password)
+        servin_client.add_registry(name, registry)
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 400
+    return jsonify({'success': True, 'message': f'Registry {registry} added as {name}'})
+
+@app.route('/api/registries/<name>', methods=['DELETE'])
+def remove_registry(name):
+    """Remove a remote registry added by name"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        servin_client.remove_registry(name)
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 400
+    return jsonify({'success': True, 'message': f'Registry {name} removed'})
+
+@app.route('/api/registries/logout', methods=['POST'])
+def registry_logout():
+    """Remove the credentials stored for a registry"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    registry = ((request.get_json() or {}).get('registry') or '').strip()
+    if not registry:
+        return jsonify({'error': 'A registry is required'}), 400
+    
+    try:
+        servin_client.registry_logout(registry)
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 400
+    return jsonify({'success': True, 'message': f'Logged out of {registry}'})
+
+@app.route('/api/registries/catalog', methods=['GET'])
+def registry_catalog():
+    """The repositories of a registry, from its catalog"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    registry = request.args.get('registry', '').strip()
+    if not registry:
+        return jsonify({'error': 'A registry is required'}), 400
+    try:
+        limit = max(0, int(request.args.get('n', 0)))
+    except ValueError:
+        return jsonify({'error': 'n must be a number'}), 400
+    
+    try:
+        return jsonify({'registry': registry, 'repositories': servin_client.registry_catalog(registry, limit)})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/registries/tags', methods=['GET'])
+def registry_tags():
+    """The tags of a repository, given with its registry"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    repository = request.args.get('repository', '').strip()
+    if not repository:
+        return jsonify({'error': 'A repository is required'}), 400
+    
+    try:
+        return jsonify({'repository': repository, 'tags': servin_client.registry_tags(repository)})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/registries/manifest', methods=['GET'])
+def registry_manifest():
+    """The manifest and config of an image in a registry"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    image_name = request.args.get('image', '').strip()
+    if not image_name:
+        return jsonify({'error': 'An image is required'}), 400
+    
+    try:
+        return jsonify(servin_client.registry_manifest(image_name, request.args.get('platform') or None))
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+# Pod APIs
+@app.route('/api/pods', methods=['GET'])
+def get_pods():
+    """The pod sandboxes and containers of the CRI server, when it runs"""
+    if not cri_client.active():
+        return jsonify({'active': False, 'port': cri_client.port, 'pods': []})
+    
+    try:
+        return jsonify({'active': True, 'port': cri_client.port, 'pods': cri_client.list_pods()})
+    except CRIError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/pods/containers/<container_id>/logs', methods=['GET'])
+def get_pod_container_logs(container_id):
+    """The last lines a pod's container logged"""
+    try:
+        tail = max(1, min(int(request.args.get('tail', 200)), 5000))
+    except ValueError:
+        return jsonify({'error': 'tail must be a number'}), 400
+    
+    try:
+        return jsonify({'lines': cri_client.container_logs(container_id, tail)})
+    except CRIError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/pods/containers/<container_id>/exec', methods=['POST'])
+def exec_pod_container(container_id):
+    """Run a command in a pod's container through the CRI server"""
+    command = (request.get_json() or {}).get('command')
+    if not isinstance(command, str) or not command.strip():
+        return jsonify({'error': 'Command required'}), 400
+    try:
+        args = shlex.split(command)
+    except ValueError as e:
+        return jsonify({'error': f'Invalid command: {e}'}), 400
+    
+    try:
+        return jsonify(cri_client.exec_sync(container_id, args))
+    except CRIError as e:
+        return jsonify({'error': str(e)}), 500
+
+# Notification APIs
+@app.route('/api/notifications/settings', methods=['GET'])
+def get_notification_settings():
+    """Which events raise desktop notifications"""
+    return jsonify({'settings': notifications.settings(), 'types': EVENT_TYPES})
+
+@app.route('/api/notifications/settings', methods=['PUT'])
+def update_notification_settings():
+    """Turn notifications of some event types on or off"""
+    data = request.get_json()
+    if not isinstance(data, dict) or not data:
+        return jsonify({'error': 'Notification settings required'}), 400
+    
+    try:
+        return jsonify({'settings': notifications.update_settings(data), 'types': EVENT_TYPES})
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    except OSError as e:
+        return jsonify({'error': f'Failed to save notification settings: {e}'}), 500
+
+def start_notifications():
+    """Start watching for notification events, when servin is available"""
+    if servin_client:
+        notifications.start()
+
+# Preferences APIs
+@app.route('/api/preferences', methods=['GET'])
+def get_preferences():
+    """The GUI's preferences, with servin's default registry"""
+    result = {
+        'preferences': {k: v for k, v in preferences.load().items() if k != 'notifications'},
+        'themes': THEMES,
+        'log_levels': LOG_LEVELS,
+        'default_registry': None
+    }
+    if servin_client:
+        try:
+            result['default_registry'] = servin_client.get_default_registry()
+        except ServinError as e:
+            result['registry_error'] = str(e)
+    return jsonify(result)
+
+@app.route('/api/preferences', methods=['PUT'])
+def update_preferences():
+    """Change preferences, applying them to the commands the GUI runs from
+    now on. The default registry is servin's own setting."""
+    data = request.get_json()
+    if not isinstance(data, dict) or not data:
+        return jsonify({'error': 'Preferences required'}), 400
+    
+    registry = data.pop('default_registry', None)
+    if registry is not None:
+        if not servin_client:
+            return jsonify({'error': 'Servin runtime not available'}), 500
+        if not isinstance(registry, str):
+            return jsonify({'error': 'Default registry must be a string'}), 400
+    
+    try:
+        if data:
+            preferences.update(data)
+            preferences.apply(servin_client)
+        if registry is not None:
+            servin_client.set_default_registry(registry.strip())
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+    except OSError as e:
+        return jsonify({'error': f'Failed to save preferences: {e}'}), 500
+    
+    return get_preferences()
+
 # System Information APIs
 @app.route('/api/system/info', methods=['GET'])
 def get_system_info():
@@ -639,6 +1928,65 @@ def get_system_info():
     except ServinError as e:
         return jsonify({'error': str(e)}), 500
 
+@app.route('/api/system/df', methods=['GET'])
+def get_disk_usage():
+    """Get the disk space images, containers, volumes and the build cache
+    use, and how much of it a prune would reclaim"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify(servin_client.disk_usage())
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/system/build-cache/prune', methods=['GET', 'POST'])
+def prune_build_cache():
+    """Remove the build cache, or with GET report what would be removed"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify(servin_client.prune_build_cache(dry_run=request.method == 'GET'))
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/system/mode', methods=['GET'])
+def get_system_mode():
+    """Get the GUI operating mode"""
+    return jsonify({'read_only': READ_ONLY})
+
+# Namespace APIs
+@app.route('/api/namespaces', methods=['GET'])
+def get_namespaces():
+    """List namespaces and the one the GUI is scoped to"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    try:
+        return jsonify({
+            'namespaces': servin_client.list_namespaces(),
+            'current': servin_client.current_namespace()
+        })
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 500
+
+@app.route('/api/namespaces/current', methods=['POST'])
+def set_current_namespace():
+    """Scope containers and images shown in the GUI to a namespace"""
+    if not servin_client:
+        return jsonify({'error': 'Servin runtime not available'}), 500
+    
+    data = request.get_json()
+    if not data or 'namespace' not in data:
+        return jsonify({'error': 'Namespace required'}), 400
+    
+    try:
+        servin_client.set_namespace(data['namespace'])
+        return jsonify({'success': True, 'current': data['namespace']})
+    except ServinError as e:
+        return jsonify({'error': str(e)}), 404
+
 # WebSocket Event Handlers for Real-time Features
 
 @socketio.on('connect')
@@ -670,18 +2018,21 @@ def handle_start_logs(data):
         # Stop any existing log stream for this container and client
         stream_key = f"{request.sid}:{container_id}"
         if stream_key in active_log_streams:
-            active_log_streams[stream_key]['stop'] = True
+            stop_stream(active_log_streams[stream_key])
         
         # Start new log streaming thread
-        active_log_streams[stream_key] = {'stop': False}
+        tail = int(data.get('tail', 1000))
+        follow = data.get('follow', True)
+        stream = {'stop': False, 'process': None}
+        active_log_streams[stream_key] = stream
         thread = threading.Thread(
             target=stream_logs_thread,
-            args=(container_id, request.sid, stream_key)
+            args=(container_id, request.sid, stream_key, stream, tail, follow)
         )
         thread.daemon = True
         thread.start()
         
-        emit('logs_started', {'container_id': container_id})
+        emit('logs_started', {'container_id': container_id, 'follow': follow})
     except Exception as e:
         emit('error', {'message': f'Failed to start log stream: {str(e)}'})
 
@@ -695,16 +2046,64 @@ def handle_stop_logs(data):
     
     stream_key = f"{request.sid}:{container_id}"
     if stream_key in active_log_streams:
-        active_log_streams[stream_key]['stop'] = True
-        del active_log_streams[stream_key]
+        stop_stream(active_log_streams.pop(stream_key))
         emit('logs_stopped', {'container_id': container_id})
 
+@socketio.on('start_stats')
+def handle_start_stats(data):
+    """Start streaming resource usage samples for a container"""
+    container_id = data.get('container_id')
+    if not container_id:
+        emit('error', {'message': 'Container ID required'})
+        return
+    
+    if not servin_client:
+        emit('error', {'message': 'Servin runtime not available'})
+        return
+    
+    try:
+        # Replace any existing stats stream for this container and client
+        stream_key = f"{request.sid}:{container_id}"
+        if stream_key in active_stats_streams:
+            stop_stream(active_stats_streams[stream_key])
+        
+        interval = max(int(data.get('interval', 2)), 1)
+        stream = {'stop': False, 'process': None}
+        active_stats_streams[stream_key] = stream
+        thread = threading.Thread(
+            target=stream_stats_thread,
+            args=(container_id, request.sid, stream_key, stream, interval)
+        )
+        thread.daemon = True
+        thread.start()
+        
+        emit('stats_started', {'container_id': container_id, 'interval': interval})
+    except Exception as e:
+        emit('error', {'message': f'Failed to start stats stream: {str(e)}'})
+
+@socketio.on('stop_stats')
+def handle_stop_stats(data):
+    """Stop streaming resource usage samples for a container"""
+    container_id = data.get('container_id')
+    if not container_id:
+        emit('error', {'message': 'Container ID required'})
+        return
+    
+    stream_key = f"{request.sid}:{container_id}"
+    if stream_key in active_stats_streams:
+        stop_stream(active_stats_streams.pop(stream_key))
+        emit('stats_stopped', {'container_id': container_id})
+
 @socketio.on('start_exec')
 def handle_start_exec(data):
-    """Start an exec session for a container"""
+    """Start an interactive exec session for a container"""
     container_id = data.get('container_id')
     shell = data.get('shell', '/bin/sh')
     
+    if READ_ONLY:
+        emit('error', {'message': 'Exec sessions are disabled in read-only mode'})
+        return
+    
     if not container_id:
         emit('error', {'message': 'Container ID required'})
         return
@@ -713,49 +2112,73 @@ def handle_start_exec(data):
         emit('error', {'message': 'Servin runtime not available'})
         return
     
+    try:
+        rows = int(data.get('rows') or 24)
+        cols = int(data.get('cols') or 80)
+    except (TypeError, ValueError):
+        emit('error', {'message': 'rows and cols must be numbers'})
+        return
+    
     try:
         # Stop any existing exec session for this container and client
         session_key = f"{request.sid}:{container_id}"
         if session_key in active_exec_sessions:
-            active_exec_sessions[session_key]['stop'] = True
+            stop_exec_session(active_exec_sessions.pop(session_key))
         
-        # Start new exec session thread
-        active_exec_sessions[session_key] = {'stop': False}
+        session = open_exec_session(container_id, shell, rows, cols)
+        active_exec_sessions[session_key] = session
         thread = threading.Thread(
             target=exec_session_thread,
-            args=(container_id, shell, request.sid, session_key)
+            args=(container_id, request.sid, session_key, session)
         )
         thread.daemon = True
         thread.start()
         
-        emit('exec_started', {'container_id': container_id, 'shell': shell})
+        emit('exec_started', {'container_id': container_id, 'shell': shell, 'tty': session['fd'] is not None})
     except Exception as e:
         emit('error', {'message': f'Failed to start exec session: {str(e)}'})
 
 @socketio.on('exec_input')
 def handle_exec_input(data):
-    """Send input to an exec session"""
+    """Send keystrokes to an exec session"""
     container_id = data.get('container_id')
-    command = data.get('command', '')
+    
+    if READ_ONLY:
+        emit('error', {'message': 'Exec sessions are disabled in read-only mode'})
+        return
     
     if not container_id:
         emit('error', {'message': 'Container ID required'})
         return
     
-    session_key = f"{request.sid}:{container_id}"
-    if session_key not in active_exec_sessions:
+    session = active_exec_sessions.get(f"{request.sid}:{container_id}")
+    if not session:
         emit('error', {'message': 'No active exec session'})
         return
     
     try:
-        # Add command to the session's input queue
-        session = active_exec_sessions[session_key]
-        if 'input_queue' not in session:
-            session['input_queue'] = []
-        session['input_queue'].append(command)
-    except Exception as e:
+        if session['fd'] is not None:
+            os.write(session['fd'], data.get('data', '').encode())
+        else:
+            # Without a terminal there is no line discipline to turn the
+            # Enter key's carriage return into a newline
+            session['process'].stdin.write(data.get('data', '').replace('\r', '\n').encode())
+    except OSError as e:
         emit('error', {'message': f'Failed to send input: {str(e)}'})
 
+@socketio.on('exec_resize')
+def handle_exec_resize(data):
+    """Resize the terminal of an exec session"""
+    container_id = data.get('container_id')
+    session = active_exec_sessions.get(f"{request.sid}:{container_id}")
+    if not session or session['fd'] is None:
+        return
+    
+    try:
+        set_terminal_size(session['fd'], int(data.get('rows')), int(data.get('cols')))
+    except (TypeError, ValueError, OSError) as e:
+        emit('error', {'message': f'Failed to resize terminal: {str(e)}'})
+
 @socketio.on('stop_exec')
 def handle_stop_exec(data):
     """Stop an exec session"""
@@ -766,177 +2189,259 @@ def handle_stop_exec(data):
     
     session_key = f"{request.sid}:{container_id}"
     if session_key in active_exec_sessions:
-        active_exec_sessions[session_key]['stop'] = True
-        del active_exec_sessions[session_key]
+        stop_exec_session(active_exec_sessions.pop(session_key))
         emit('exec_stopped', {'container_id': container_id})
 
-def stream_logs_thread(container_id, client_sid, stream_key):
-    """Thread function to stream container logs"""
+def open_exec_session(container_id, shell, rows, cols):
+    """Start `servin exec` running a shell for an interactive session.
+    
+    Where there are pseudo-terminals it runs on one of the given size that
+    is its controlling terminal, so servin gives the shell a terminal in the
+    container and passes resizes on to it; elsewhere it runs on pipes.
+    """
+    if pty is None:
+        process = subprocess.Popen(
+            servin_client.exec_command_line(container_id, [shell], tty=False),
+            stdin=subprocess.PIPE,
+            stdout=subprocess.PIPE,
+            stderr=subprocess.STDOUT,
+            bufsize=0
+        )
+        return {'process': process, 'fd': None, 'stop': False}
+    
+    master, slave = pty.openpty()
     try:
-        # Get initial logs
-        logs = servin_client.get_logs(container_id, follow=False, tail=100)
-        socketio.emit('log_data', {
-            'container_id': container_id,
-            'data': logs,
-            'type': 'initial'
-        }, room=client_sid)
-        
-        # Start following logs
-        log_process = None
-        try:
-            # Use servin logs command with follow
-            cmd = ['servin', 'logs', '-f', container_id]
-            log_process = subprocess.Popen(
-                cmd,
-                stdout=subprocess.PIPE,
-                stderr=subprocess.STDOUT,
-                universal_newlines=True,
-                bufsize=1
-            )
-            
-            while not active_log_streams.get(stream_key, {}).get('stop', True):
-                if log_process.poll() is not None:
-                    # Process ended
-                    break
-                
-                line = log_process.stdout.readline()
-                if line:
-                    socketio.emit('log_data', {
-                        'container_id': container_id,
-                        'data': line.rstrip(),
-                        'type': 'stream'
-                    }, room=client_sid)
-                else:
-                    time.sleep(0.1)
-                    
-        except Exception as e:
-            socketio.emit('error', {
-                'message': f'Log streaming error: {str(e)}'
+        set_terminal_size(master, rows, cols)
+        process = subprocess.Popen(
+            servin_client.exec_command_line(container_id, [shell]),
+            stdin=slave,
+            stdout=slave,
+            stderr=slave,
+            start_new_session=True,
+            preexec_fn=lambda: fcntl.ioctl(0, termios.TIOCSCTTY, 0),
+            env={**os.environ, 'TERM': 'xterm-256color'}
+        )
+    except Exception:
+        os.close(master)
+        raise
+    finally:
+        os.close(slave)
+    return {'process': process, 'fd': master, 'stop': False}
+
+def set_terminal_size(fd, rows, cols):
+    """Resize a pseudo-terminal, which signals its processes"""
+    fcntl.ioctl(fd, termios.TIOCSWINSZ, struct.pack('HHHH', rows, cols, 0, 0))
+
+def stop_exec_session(session):
+    """Ask an exec session's thread to end it"""
+    session['stop'] = True
+    if session['process'].poll() is None:
+        session['process'].terminate()
+
+def stop_stream(stream):
+    """Ask a log or stats stream's thread to end it"""
+    stream['stop'] = True
+    process = stream['process']
+    if process and process.poll() is None:
+        process.terminate()
+
+def stream_logs_thread(container_id, client_sid, stream_key, stream, tail, follow):
+    """Thread function streaming the last lines of a container's logs and,
+    when following, the lines written after them"""
+    process = None
+    batch = []
+    
+    def flush():
+        if batch and not stream['stop']:
+            socketio.emit('log_data', {
+                'container_id': container_id,
+                'lines': list(batch)
             }, room=client_sid)
-        finally:
-            if log_process:
-                log_process.terminate()
-                log_process.wait()
+            batch.clear()
+    
+    try:
+        process = subprocess.Popen(
+            servin_client.logs_command_line(container_id, tail=tail, follow=follow),
+            stdout=subprocess.PIPE,
+            stderr=subprocess.PIPE,
+            universal_newlines=True,
+            bufsize=1
+        )
+        stream['process'] = process
+        if stream['stop']:
+            process.terminate()
+        
+        for line in process.stdout:
+            if stream['stop']:
+                break
+            try:
+                batch.append(json.loads(line))
+            except json.JSONDecodeError:
+                continue
             
+            # Lines that arrive together are sent together; select cannot
+            # wait on pipes on Windows, so there each line is sent by itself
+            if len(batch) >= 200 or os.name == 'nt' or not select.select([process.stdout], [], [], 0)[0]:
+                flush()
+        flush()
     except Exception as e:
         socketio.emit('error', {
-            'message': f'Failed to stream logs: {str(e)}'
+            'message': f'Log streaming error: {str(e)}'
         }, room=client_sid)
     finally:
-        # Clean up
-        if stream_key in active_log_streams:
+        error = None
+        if process:
+            if process.poll() is None:
+                process.terminate()
+            process.wait()
+            if process.returncode not in (0, None) and not stream['stop']:
+                error = servin_client._error_message(process.stderr.read())
+            process.stdout.close()
+            process.stderr.close()
+        
+        # A stream stopped by the client was already reported; otherwise the
+        # container stopped or its logs could not be read
+        if not stream['stop']:
+            socketio.emit('logs_stopped', {
+                'container_id': container_id,
+                'error': error
+            }, room=client_sid)
+        if active_log_streams.get(stream_key) is stream:
             del active_log_streams[stream_key]
 
-def exec_session_thread(container_id, shell, client_sid, session_key):
-    """Thread function to handle container exec session"""
+def stream_stats_thread(container_id, client_sid, stream_key, stream, interval):
+    """Thread function relaying the samples `servin stats` takes of a
+    container every interval seconds"""
+    process = None
     try:
-        # Start exec session
-        socketio.emit('exec_output', {
-            'container_id': container_id,
-            'data': f'Starting {shell} session in container {container_id}...\n',
-            'type': 'system'
+        process = subprocess.Popen(
+            servin_client.stats_command_line(container_id, interval),
+            stdout=subprocess.PIPE,
+            stderr=subprocess.PIPE,
+            universal_newlines=True,
+            bufsize=1
+        )
+        stream['process'] = process
+        if stream['stop']:
+            process.terminate()
+        
+        # Each line is the list of samples taken at one time
+        for line in process.stdout:
+            if stream['stop']:
+                break
+            try:
+                samples = json.loads(line)
+            except json.JSONDecodeError:
+                continue
+            for sample in samples or []:
+                socketio.emit('stats_sample', {
+                    'container_id': container_id,
+                    'sample': sample
+                }, room=client_sid)
+    except Exception as e:
+        socketio.emit('error', {
+            'message': f'Stats streaming error: {str(e)}'
         }, room=client_sid)
+    finally:
+        error = None
+        if process:
+            if process.poll() is None:
+                process.terminate()
+            process.wait()
+            if process.returncode not in (0, None) and not stream['stop']:
+                error = servin_client._error_message(process.stderr.read())
+            process.stdout.close()
+            process.stderr.close()
         
-        exec_process = None
-        try:
-            # Use servin exec command
-            cmd = ['servin', 'exec', '-it', container_id, shell]
-            exec_process = subprocess.Popen(
-                cmd,
-                stdin=subprocess.PIPE,
-                stdout=subprocess.PIPE,
-                stderr=subprocess.STDOUT,
-                universal_newlines=True,
-                bufsize=1
-            )
-            
-            socketio.emit('exec_output', {
+        if not stream['stop']:
+            socketio.emit('stats_stopped', {
                 'container_id': container_id,
-                'data': f'{shell}$ ',
-                'type': 'prompt'
+                'error': error
             }, room=client_sid)
-            
-            while not active_exec_sessions.get(session_key, {}).get('stop', True):
-                session = active_exec_sessions.get(session_key, {})
-                
-                # Check for input commands
-                if 'input_queue' in session and session['input_queue']:
-                    command = session['input_queue'].pop(0)
-                    if exec_process.stdin:
-                        exec_process.stdin.write(command + '\n')
-                        exec_process.stdin.flush()
-                    
-                    # Echo the command
-                    socketio.emit('exec_output', {
-                        'container_id': container_id,
-                        'data': command + '\n',
-                        'type': 'input'
-                    }, room=client_sid)
-                
-                # Check for output
-                if exec_process.poll() is not None:
-                    # Process ended
-                    break
-                
-                # Read output (non-blocking)
+        if active_stats_streams.get(stream_key) is stream:
+            del active_stats_streams[stream_key]
+
+def exec_session_thread(container_id, client_sid, session_key, session):
+    """Thread function relaying an exec session's output until it ends"""
+    process = session['process']
+    fd = session['fd']
+    # Output arrives in chunks that may split a character
+    decoder = codecs.getincrementaldecoder('utf-8')(errors='replace')
+    try:
+        while not session['stop']:
+            if fd is not None:
+                if not select.select([fd], [], [], 0.1)[0]:
+                    if process.poll() is not None:
+                        break
+                    continue
                 try:
-                    import select
-                    if select.select([exec_process.stdout], [], [], 0.1)[0]:
-                        line = exec_process.stdout.readline()
-                        if line:
-                            socketio.emit('exec_output', {
-                                'container_id': container_id,
-                                'data': line,
-                                'type': 'output'
-                            }, room=client_sid)
-                except:
-                    # Fallback for platforms without select
-                    time.sleep(0.1)
-                    
-        except Exception as e:
-            socketio.emit('exec_output', {
-                'container_id': container_id,
-                'data': f'Exec session error: {str(e)}\n',
-                'type': 'error'
-            }, room=client_sid)
-        finally:
-            if exec_process:
-                exec_process.terminate()
-                exec_process.wait()
-                
-            socketio.emit('exec_output', {
-                'container_id': container_id,
-                'data': 'Exec session ended.\n',
-                'type': 'system'
-            }, room=client_sid)
+                    chunk = os.read(fd, 4096)
+                except OSError:
+                    # The terminal is gone once the session has exited
+                    chunk = b''
+            else:
+                chunk = process.stdout.read(4096)
             
+            if not chunk:
+                break
+            text = decoder.decode(chunk)
+            if text:
+                socketio.emit('exec_output', {
+                    'container_id': container_id,
+                    'data': text
+                }, room=client_sid)
     except Exception as e:
         socketio.emit('error', {
-            'message': f'Failed to run exec session: {str(e)}'
+            'message': f'Exec session error: {str(e)}'
         }, room=client_sid)
     finally:
-        # Clean up
-        if session_key in active_exec_sessions:
+        if process.poll() is None:
+            process.terminate()
+        try:
+            process.wait(timeout=5)
+        except subprocess.TimeoutExpired:
+            process.kill()
+            process.wait()
+        if fd is not None:
+            os.close(fd)
+        
+        # A session stopped by the client was already reported
+        if not session['stop']:
+            socketio.emit('exec_stopped', {
+                'container_id': container_id,
+                'exit_code': process.returncode
+            }, room=client_sid)
+        if active_exec_sessions.get(session_key) is session:
             del active_exec_sessions[session_key]
 
 def cleanup_client_streams(client_sid):
     """Clean up all active streams for a disconnected client"""
     streams_to_remove = []
+    stats_to_remove = []
     sessions_to_remove = []
     
-    for stream_key in active_log_streams:
+    for stream_key in list(active_log_streams):
         if stream_key.startswith(f"{client_sid}:"):
-            active_log_streams[stream_key]['stop'] = True
+            stop_stream(active_log_streams[stream_key])
             streams_to_remove.append(stream_key)
     
-    for session_key in active_exec_sessions:
+    for stream_key in list(active_stats_streams):
+        if stream_key.startswith(f"{client_sid}:"):
+            stop_stream(active_stats_streams[stream_key])
+            stats_to_remove.append(stream_key)
+    
+    for session_key in list(active_exec_sessions):
         if session_key.startswith(f"{client_sid}:"):
-            active_exec_sessions[session_key]['stop'] = True
+            stop_exec_session(active_exec_sessions[session_key])
             sessions_to_remove.append(session_key)
     
     for stream_key in streams_to_remove:
         if stream_key in active_log_streams:
             del active_log_streams[stream_key]
+    
+    for stream_key in stats_to_remove:
+        if stream_key in active_stats_streams:
+            del active_stats_streams[stream_key]
             
     for session_key in sessions_to_remove:
         if session_key in active_exec_sessions:
@@ -969,6 +2474,7 @@ def run_flask_app():
         print(f"Port 5555 is in use, using port {port} instead")
     
     print(f"Starting Servin GUI on http://127.0.0.1:{port}")
+    start_notifications()
     socketio.run(app, host='127.0.0.1', port=port, debug=False, use_reloader=False)
 
 if __name__ == '__main__':
@@ -0,0 +1,151 @@
+"""
+Servin Desktop GUI - CRI client
+Talks to the CRI server `servin cri start` runs, so the GUI can show the pod
+sandboxes and containers the kubelet drives
+"""
+
+import base64
+import json
+import os
+import urllib.error
+import urllib.request
+
+from preferences import CRI_PORT_ENV
+
+DEFAULT_CRI_PORT = 8080
+
+POD_STATES = {0: 'ready', 1: 'notready'}
+CONTAINER_STATES = {0: 'created', 1: 'running', 2: 'exited', 3: 'unknown'}
+
+
+class CRIError(Exception):
+    """A CRI call that failed, with the server's message"""
+
+
+class CRIClient:
+    """A client of the CRI server's HTTP API on this host"""
+
+    def __init__(self, host='localhost', timeout=5):
+        self.host = host
+        self.timeout = timeout
+
+    @property
+    def port(self):
+        # Read on every call, so a changed CRI port preference applies at once
+        try:
+            port = int(os.environ.get(CRI_PORT_ENV, DEFAULT_CRI_PORT))
+        except ValueError:
+            return DEFAULT_CRI_PORT
+        return port if 0 < port < 65536 else DEFAULT_CRI_PORT
+
+    def call(self, path, body=None):
+        """POST body to a CRI endpoint and return the JSON it answers with"""
+        request = urllib.request.Request(
+            f'http://{self.host}:{self.port}{path}',
+            data=json.dumps(body or {}).encode(),
+            headers={'Content-Type': 'application/json'},
+            method='POST')
+        try:
+            with urllib.request.urlopen(request, timeout=self.timeout) as response:
+                return json.loads(response.read() or b'{}')
+        except urllib.error.HTTPError as e:
+            raise CRIError(e.read().decode(errors='replace').strip() or str(e))
+        except (urllib.error.URLError, OSError) as e:
+            raise CRIError(f'CRI server not reachable on port {self.port}: {getattr(e, "reason", e)}')
+        except ValueError as e:
+            raise CRIError(f'Invalid answer from the CRI server: {e}')
+
+    def active(self):
+        """Whether the CRI server answers"""
+        try:
+            with urllib.request.urlopen(f'http://{self.host}:{self.port}/health', timeout=self.timeout):
+                return True
+        except (urllib.error.URLError, OSError):
+            return False
+
+    def list_pods(self):
+        """The pod sandboxes with their IP and containers, newest first"""
+        sandboxes = self.call('/v1/runtime/sandbox/list').get('items') or []
+        containers = self.call('/v1/runtime/container/list').get('containers') or []
+
+        pods = []
+        for sandbox in sorted(sandboxes, key=lambda s: s.get('created_at', 0), reverse=True):
+            metadata = sandbox.get('metadata') or {}
+            try:
+                status = self.call('/v1/runtime/sandbox/status',
+                                   {'pod_sandbox_id': sandbox['id']}).get('status') or {}
+            except CRIError:
+                status = {}
+            pods.append({
+                'id': sandbox['id'],
+                'name': metadata.get('name', ''),
+                'namespace': metadata.get('namespace', ''),
+                'uid': metadata.get('uid', ''),
+                'attempt': metadata.get('attempt', 0),
+                # The state is left out of the JSON when it is 0 (ready)
+                'state': POD_STATES.get(sandbox.get('state', 0), 'unknown'),
+                'created_at': sandbox.get('created_at', 0) // 1_000_000_000,
+                'ip': (status.get('network') or {}).get('ip', ''),
+                'labels': sandbox.get('labels') or {},
+                'containers': [self._container(c) for c in containers
+                               if c.get('pod_sandbox_id') == sandbox['id']]
+            })
+        return pods
+
+    @staticmethod
+    def _container(container):
+        metadata = container.get('metadata') or {}
+        return {
+            'id': container['id'],
+            'name': metadata.get('name', ''),
+            'attempt': metadata.get('attempt', 0),
+            'image': (container.get('image') or {}).get('image', '') or container.get('image_ref', ''),
+            'state': CONTAINER_STATES.get(container.get('state', 0), 'unknown'),
+            'created_at': container.get('created_at', 0) // 1_000_000_000
+        }
+
+    def container_logs(self, container_id, tail=200):
+        """The last lines of a container's log, which the kubelet's CRI
+        logging writes as `<time> <stream> <P|F> <message>`"""
+        status = self.call('/v1/runtime/container/status',
+                           {'container_id': container_id}).get('status') or {}
+        log_path = status.get('log_path')
+        if not log_path:
+            return []
+        try:
+            with open(log_path, encoding='utf-8', errors='replace') as log:
+                raw = log.read().splitlines()
+        except FileNotFoundError:
+            return []
+        except OSError as e:
+            raise CRIError(f'Failed to read {log_path}: {e}')
+
+        lines = []
+        partial = ''
+        for line in raw:
+            fields = line.split(' ', 3)
+            if len(fields) < 3 or fields[2] not in ('P', 'F'):
+                lines.append({'timestamp': '', 'stream': '', 'message': line})
+                continue
+            message = fields[3] if len(fields) > 3 else ''
+            # Long lines are split into partial (P) ones before the full (F) one
+            if fields[2] == 'P':
+                partial += message
+                continue
+            lines.append({'timestamp': fields[0], 'stream': fields[1], 'message': partial + message})
+            partial = ''
+        return lines[-tail:]
+
+    def exec_sync(self, container_id, command, timeout=10):
+        """Run a command in a container and wait for it"""
+        result = self.call('/v1/runtime/container/exec_sync', {
+            'container_id': container_id,
+            'cmd': command,
+            'timeout': timeout
+        })
+        # The output is bytes, which the server encodes as base64
+        return {
+            'stdout': base64.b64decode(result.get('stdout') or '').decode(errors='replace'),
+            'stderr': base64.b64decode(result.get('stderr') or '').decode(errors='replace'),
+            'exit_code': result.get('exit_code', 0)
+        }
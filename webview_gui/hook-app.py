@@ -11,6 +11,10 @@ hiddenimports += [
     'app',
     'servin_client',
     'mock_servin_client',
+    'tray',
+    'notifications',
+    'preferences',
+    'cri_client',
 ]
 
 # Ensure templates and static files are included
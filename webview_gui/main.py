@@ -3,6 +3,7 @@ Servin Desktop GUI - Main Application
 Tkinter wrapper that embeds the web interface using pywebview
 """
 
+import json
 import os
 import sys
 import threading
@@ -74,6 +75,9 @@ except ImportError as e1:
                 print("[DEBUG] Could not list PyInstaller temp contents")
         raise
 
+# The tray is optional; without it closing the window quits as before
+from tray import ServinTray, TRAY_AVAILABLE
+
 class ServinDesktopGUI:
     def __init__(self):
         self.flask_thread = None
@@ -81,6 +85,10 @@ class ServinDesktopGUI:
         self.webview_window = None
         self.root = None
         self.server_port = None
+        self.tray = None
+        self.quitting = False
+        # Started at login, the GUI waits in the tray until opened
+        self.minimized = '--minimized' in sys.argv
         
     def find_available_port(self, start_port=5555, max_attempts=10):
         """Find an available port starting from start_port"""
@@ -184,6 +192,7 @@ class ServinDesktopGUI:
         
         self.flask_thread = threading.Thread(target=run_server, daemon=True)
         self.flask_thread.start()
+        app_module.start_notifications()
         
         # Wait a moment for the server to start
         time.sleep(2)
@@ -197,6 +206,9 @@ class ServinDesktopGUI:
                 print("Error: No server port available")
                 return
             
+            if TRAY_AVAILABLE:
+                self.start_tray()
+            
             # Try to create the webview window
             self.webview_window = webview.create_window(
                 title='Servin Desktop GUI',
@@ -206,24 +218,74 @@ class ServinDesktopGUI:
                 min_size=(900, 600),
                 resizable=True,
                 fullscreen=False,
-                on_top=False
+                on_top=False,
+                hidden=self.minimized and self.tray is not None
             )
+            if self.tray:
+                self.webview_window.events.closing += self.on_window_closing
             
-            # Start the webview (this will block until the window is closed)
+            # Start the webview (this will block until the window is closed,
+            # or with a tray icon until Quit is chosen)
             webview.start(debug=False)
+            self.stop_tray()
             
         except ImportError as e:
             print(f"Webview import error: {e}")
+            self.stop_tray()
             print("Falling back to browser...")
             self.open_in_browser()
             self.show_fallback_ui()
         except Exception as e:
             print(f"Webview error: {e}")
             print("This might be due to missing Edge WebView2 runtime on Windows.")
+            self.stop_tray()
             print("Falling back to browser...")
             self.open_in_browser()
             self.show_fallback_ui()
     
+    def start_tray(self):
+        """Show the tray icon, which keeps the GUI running when its window
+        is closed"""
+        try:
+            self.tray = ServinTray(f'http://127.0.0.1:{self.server_port}',
+                                   self.show_window, self.quit)
+            self.tray.start()
+            app_module.notifications.add_notifier(self.tray.notify_event)
+        except Exception as e:
+            print(f"[WARN] Could not show the tray icon: {e}")
+            self.tray = None
+    
+    def stop_tray(self):
+        """Remove the tray icon, whose window is gone"""
+        if self.tray:
+            app_module.notifications.remove_notifier(self.tray.notify_event)
+            self.tray.stop()
+            self.tray = None
+    
+    def on_window_closing(self):
+        """Hide the window instead of closing it, unless quitting"""
+        if self.quitting:
+            return True
+        self.webview_window.hide()
+        self.tray.window_hidden()
+        return False
+    
+    def show_window(self, container_id=None):
+        """Bring the window back, optionally with a container's details"""
+        if not self.webview_window:
+            return
+        self.webview_window.show()
+        self.webview_window.restore()
+        if container_id:
+            self.webview_window.evaluate_js(
+                f"window.dockerGUI && dockerGUI.showContainerDetails({json.dumps(container_id)})")
+    
+    def quit(self):
+        """Close the window for good, which ends the GUI"""
+        self.quitting = True
+        if self.webview_window:
+            self.webview_window.destroy()
+    
     def show_fallback_ui(self):
         """Show a fallback Tkinter UI if webview fails"""
         self.root = tk.Tk()
@@ -3,8 +3,12 @@ Demo Servin Client for Windows
 This is a mock implementation for demonstration purposes when the actual servin binary is not available for Windows
 """
 
+import hashlib
 import json
 import os
+import shlex
+import sys
+import tarfile
 import time
 from datetime import datetime
 from typing import List, Dict, Any, Optional
@@ -29,9 +33,12 @@ class MockServinClient:
                 'image': 'nginx:latest',
                 'status': 'running',
                 'state': 'running',
+                'exit_code': 0,
                 'created': datetime.now().isoformat(),
-                'ports': ['8080:80'],
-                'networks': ['bridge']
+                'ports': [{'host_ip': '', 'host_port': 8080, 'container_port': 80, 'protocol': 'tcp'}],
+                'networks': ['bridge'],
+                'labels': {'app': 'web', 'tier': 'frontend'},
+                'volumes': {'demo-logs': '/var/log/nginx', 'demo-data': '/usr/share/nginx/html:ro'}
             },
             {
                 'id': 'def987654321',
@@ -39,12 +46,26 @@ class MockServinClient:
                 'image': 'ubuntu:20.04',
                 'status': 'stopped',
                 'state': 'stopped',
+                'exit_code': 0,
                 'created': datetime.now().isoformat(),
                 'ports': [],
                 'networks': ['bridge']
             }
         ]
         
+        # Environment of each container, by ID
+        self._env = {
+            'abc123456789': {
+                'PATH': '/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin',
+                'NGINX_VERSION': '1.25.3',
+                'NGINX_PORT': '80'
+            },
+            'def987654321': {
+                'PATH': '/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin',
+                'DEBIAN_FRONTEND': 'noninteractive'
+            }
+        }
+        
         self._images = [
             {
                 'id': 'sha256:abc123',
@@ -52,7 +73,9 @@ class MockServinClient:
                 'tag': 'latest',
                 'created': datetime.now().isoformat(),
                 'size': 133000000,
-                'virtual_size': 133000000
+                'virtual_size': 133000000,
+                'last_used': datetime.now().isoformat(),
+                'containers': 1
             },
             {
                 'id': 'sha256:def456',
@@ -60,7 +83,9 @@ class MockServinClient:
                 'tag': '20.04',
                 'created': datetime.now().isoformat(),
                 'size': 72800000,
-                'virtual_size': 72800000
+                'virtual_size': 72800000,
+                'last_used': None,
+                'containers': 0
             }
         ]
         
@@ -78,6 +103,39 @@ class MockServinClient:
                 'mountpoint': '/var/lib/servin/volumes/demo-logs',
                 'created': datetime.now().isoformat(),
                 'scope': 'local'
+            },
+            {
+                'name': 'demo-cache',
+                'driver': 'local',
+                'mountpoint': '/var/lib/servin/volumes/demo-cache',
+                'created': datetime.now().isoformat(),
+                'scope': 'local'
+            }
+        ]
+        
+        # Bytes each volume and container takes on disk, and the build cache
+        self._disk_sizes = {'demo-data': 2400000, 'demo-logs': 18500000, 'demo-cache': 96000000,
+                            'abc123456789': 1200000, 'def987654321': 34000000}
+        self._build_cache = {'entries': 14, 'size': 61400000}
+        
+        self._default_registry = ''
+        
+        # Remote registries by name, the credentials stored by host and the
+        # repositories and tags each host offers
+        self._registries = {'github': 'ghcr.io'}
+        self._logins = {'ghcr.io': 'demo'}
+        self._repositories = {
+            'localhost:5000': {'demo/web': ['latest', 'v1.0', 'v1.1'], 'demo/worker': ['latest']},
+            'ghcr.io': {'demo/api': ['latest', '2.3.0', '2.2.1']}
+        }
+        
+        self._jobs = [
+            {
+                'id': 'prefetch-alpine-latest',
+                'type': 'prefetch',
+                'target': 'alpine:latest',
+                'status': 'done',
+                'updated': datetime.now().isoformat()
             }
         ]
     
@@ -135,31 +193,203 @@ class MockServinClient:
             if container['id'].startswith(container_id) or container['name'] == container_id:
                 if container['status'] == 'running' and not force:
                     raise ServinError("Cannot remove running container. Use force=True or stop it first.")
+                if container.get('protected'):
+                    raise ServinError(f"container {container['name']} is protected; use --override-protection to remove it")
                 del self._containers[i]
                 return True
         raise ServinError(f"Container not found: {container_id}")
     
+    def run_command_preview(self, image: str, command: str = None, **kwargs) -> str:
+        """Get the servin run command line for the options"""
+        if not image or not (command or '').strip():
+            raise ServinError("An image and a command are required")
+        args = ['servin', 'run', '--detach']
+        for option in ('name', 'hostname', 'workdir', 'network', 'restart', 'memory', 'cpus'):
+            if kwargs.get(option):
+                args.extend([f'--{option}', str(kwargs[option])])
+        for flag, key in (('--publish', 'ports'), ('--volume', 'volumes'), ('--env', 'env')):
+            for value in kwargs.get(key) or []:
+                args.extend([flag, value])
+        return shlex.join(args + [image] + shlex.split(command))
+    
+    def run_container(self, image: str, command: str = None, **kwargs) -> str:
+        """Run a mock container"""
+        self.run_command_preview(image, command, **kwargs)
+        container_id = f'{int(time.time() * 1000):012x}'[-12:]
+        self._containers.append({
+            'id': container_id,
+            'name': kwargs.get('name') or f'demo-{container_id[:6]}',
+            'image': image,
+            'status': 'running',
+            'state': 'running',
+            'created': datetime.now().isoformat(),
+            'ports': [],
+            'networks': [kwargs.get('network') or 'bridge']
+        })
+        return container_id
+    
     # Image Management Methods
     
     def list_images(self) -> List[Dict[str, Any]]:
         """List images"""
         return self._images.copy()
     
-    def pull_image(self, image_name: str) -> bool:
-        """Pull an image (mock)"""
-        # Add a new mock image
-        new_image = {
-            'id': f'sha256:{hash(image_name + str(time.time()))}',
-            'repository': image_name.split(':')[0] if ':' in image_name else image_name,
-            'tag': image_name.split(':')[1] if ':' in image_name else 'latest',
-            'created': datetime.now().isoformat(),
-            'size': 50000000,
-            'virtual_size': 50000000
+    def _find_image(self, image_ref: str) -> Dict[str, Any]:
+        for image in self._images:
+            if image['id'].startswith(image_ref) or f"{image['repository']}:{image['tag']}" == image_ref:
+                return image
+        raise ServinError(f"image '{image_ref}' not found")
+    
+    def inspect_image(self, image_ref: str) -> Dict[str, Any]:
+        """Get the details of an image (mock)"""
+        image = self._find_image(image_ref)
+        tags = [f"{other['repository']}:{other['tag']}" for other in self._images if other['id'] == image['id']]
+        layer_sizes = [image['size'] * 3 // 4, image['size'] // 5, image['size'] - image['size'] * 3 // 4 - image['size'] // 5]
+        return {
+            'id': image['id'],
+            'repo_tags': tags,
+            'created': image['created'],
+            'last_used': image.get('last_used'),
+            'size': image['size'],
+            'platform': 'linux/amd64',
+            'config_digest': f"sha256:{image['id'].split(':')[-1]:0<64}",
+            'rootfs_type': 'layers',
+            'layers': [{
+                'diff_id': f'sha256:{i}{image["id"].split(":")[-1]:0<63}',
+                'digest': f'sha256:{i}{image["id"].split(":")[-1]:f<63}',
+                'size': size
+            } for i, size in enumerate(layer_sizes)],
+            'config': {
+                'env': ['PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin'],
+                'cmd': ['nginx', '-g', 'daemon off;'] if image['repository'] == 'nginx' else ['/bin/bash'],
+                'entrypoint': None,
+                'working_dir': '/',
+                'user': '',
+                'exposed_ports': {'80/tcp': {}} if image['repository'] == 'nginx' else {},
+                'labels': {}
+            },
+            'history': [
+                {'created': image['created'], 'created_by': '/bin/sh -c #(nop) ADD file:rootfs.tar.gz in / '},
+                {'created': image['created'], 'created_by': '/bin/sh -c apt-get update && apt-get install -y ca-certificates'},
+                {'created': image['created'], 'created_by': '/bin/sh -c #(nop) COPY dir:app in /app '},
+                {'created': image['created'], 'created_by': '/bin/sh -c #(nop)  CMD ["/bin/bash"]', 'empty_layer': True}
+            ],
+            'containers': [{'id': c['id'], 'name': c['name'], 'status': c['status']}
+                           for c in self._containers if c['image'] in tags]
         }
-        self._images.append(new_image)
+    
+    def tag_image(self, source: str, target: str) -> bool:
+        """Tag an image (mock)"""
+        image = self._find_image(source)
+        repository, _, tag = target.rpartition(':') if ':' in target.split('/')[-1] else (target, '', 'latest')
+        self._images = [other for other in self._images
+                        if f"{other['repository']}:{other['tag']}" != f'{repository}:{tag}']
+        self._images.append(dict(image, repository=repository, tag=tag))
+        return True
+    
+    def push_image(self, image_name: str) -> bool:
+        """Push an image (mock)"""
+        self._find_image(image_name)
+        if '/' not in image_name:
+            raise ServinError(f"no registry in image name '{image_name}'")
+        time.sleep(1)
         return True
     
-    def remove_image(self, image_id: str, force: bool = False) -> bool:
+    def save_command_line(self, image_ref: str) -> List[str]:
+        """Build a command that writes a small tarball in place of the image"""
+        self._find_image(image_ref)
+        script = (
+            "import io, sys, tarfile\n"
+            "data = ('mock archive of ' + sys.argv[1] + '\\n').encode()\n"
+            "with tarfile.open(fileobj=sys.stdout.buffer, mode='w|') as archive:\n"
+            "    info = tarfile.TarInfo('README')\n"
+            "    info.size = len(data)\n"
+            "    archive.addfile(info, io.BytesIO(data))\n"
+        )
+        return [sys.executable, '-c', script, image_ref]
+
+    def build_command_line(self, context: str, buildfile: Optional[str] = None,
+                           tag: Optional[str] = None,
+                           build_args: Optional[Dict[str, str]] = None,
+                           no_cache: bool = False) -> List[str]:
+        """Build a command that prints a step for each instruction of the
+        build file, slowly enough to watch and cancel"""
+        if not buildfile:
+            buildfile = 'Buildfile'
+            if not os.path.exists(os.path.join(context, buildfile)) and os.path.exists(os.path.join(context, 'Dockerfile')):
+                buildfile = 'Dockerfile'
+        path = os.path.join(context, buildfile)
+
+        # The mock cannot see the build end, so the image is listed up front
+        image_id = f'sha256:{abs(hash(path + str(time.time()))):x}'
+        if os.path.isfile(path):
+            repository, _, image_tag = (tag or '<none>:<none>').rpartition(':')
+            self._images.append({
+                'id': image_id,
+                'repository': repository,
+                'tag': image_tag,
+                'created': datetime.now().isoformat(),
+                'size': 25000000,
+                'virtual_size': 25000000,
+                'last_used': None,
+                'containers': 0
+            })
+
+        script = (
+            "import sys, time\n"
+            "path, image_id, tag = sys.argv[1:4]\n"
+            "try:\n"
+            "    with open(path) as f:\n"
+            "        steps = [line.strip() for line in f if line.strip() and not line.strip().startswith('#')]\n"
+            "except OSError:\n"
+            "    sys.stderr.write(\"Error: [NOT_FOUND] build: Buildfile '%s' not found\\n\" % path)\n"
+            "    sys.exit(3)\n"
+            "try:\n"
+            "    for number, step in enumerate(steps, 1):\n"
+            "        print('Step %d/%d : %s' % (number, len(steps), step), flush=True)\n"
+            "        if step.split()[0].upper() == 'RUN':\n"
+            "            print(' ---> Running ' + step[4:], flush=True)\n"
+            "        time.sleep(0.8)\n"
+            "except KeyboardInterrupt:\n"
+            "    sys.exit(130)\n"
+            "print('Successfully built image: ' + image_id)\n"
+            "if tag:\n"
+            "    print('Successfully tagged: ' + tag)\n"
+        )
+        return [sys.executable, '-c', script, path, image_id, tag or '']
+
+    def pull_command_line(self, image_name: str, platform: Optional[str] = None) -> List[str]:
+        """Build a command that prints the layers of a pull, slowly enough
+        to watch the progress"""
+        # The mock cannot see the pull end, so the image is listed up front
+        repository, _, tag = image_name.rpartition(':')
+        if not repository or '/' in tag:
+            repository, tag = image_name, 'latest'
+        self._images.append({
+            'id': f'sha256:{abs(hash(image_name + str(time.time()))):x}',
+            'repository': repository,
+            'tag': tag,
+            'created': datetime.now().isoformat(),
+            'size': 50000000,
+            'virtual_size': 50000000,
+            'last_used': None,
+            'containers': 0
+        })
+        
+        script = (
+            "import sys, time\n"
+            "ref = sys.argv[1]\n"
+            "print('Pulling image %s (%s)...' % (ref, sys.argv[2]), flush=True)\n"
+            "print('Downloading 3 layers...', flush=True)\n"
+            "for number in range(1, 4):\n"
+            "    time.sleep(0.8)\n"
+            "    print('Downloading layer %d/3 %012x...' % (number, number * 0x1f2e3d), flush=True)\n"
+            "time.sleep(0.8)\n"
+            "print('Successfully pulled %s' % ref)\n"
+        )
+        return [sys.executable, '-c', script, image_name, platform or 'linux/amd64']
+    
+    def remove_image(self, image_id: str) -> bool:
         """Remove an image"""
         for i, image in enumerate(self._images):
             if image['id'].startswith(image_id) or f"{image['repository']}:{image['tag']}" == image_id:
@@ -167,6 +397,19 @@ class MockServinClient:
                 return True
         raise ServinError(f"Image not found: {image_id}")
     
+    def prune_images(self, all_images: bool = False, dry_run: bool = False) -> Dict[str, Any]:
+        """Remove untagged images, or with all_images every unused one (mock)"""
+        pruned = [image for image in self._images
+                  if not image.get('containers') and (all_images or image['tag'] == '<none>')]
+        if not dry_run:
+            self._images = [image for image in self._images if image not in pruned]
+        return {
+            'images': [{'id': image['id'].replace('sha256:', '', 1),
+                        'tags': [] if image['tag'] == '<none>' else [f"{image['repository']}:{image['tag']}"]}
+                       for image in pruned],
+            'space': f"{sum(image['size'] for image in pruned) / 1e6:.1f} MB"
+        }
+    
     def import_image(self, tarball_path: str, image_name: str) -> bool:
         """Import an image from tarball (mock)"""
         if not os.path.exists(tarball_path):
@@ -183,11 +426,72 @@ class MockServinClient:
         self._images.append(new_image)
         return True
     
+    def load_image(self, input_path: str) -> List[str]:
+        """Load the images a docker-archive's manifest lists (mock)"""
+        try:
+            with tarfile.open(input_path) as archive:
+                manifest = json.load(archive.extractfile('manifest.json'))
+        except (OSError, KeyError, ValueError, tarfile.TarError):
+            raise ServinError(f"Failed to load image: {input_path} is not a docker-archive or OCI archive")
+        
+        names = []
+        for entry in manifest:
+            image_id = f'sha256:{hashlib.sha256(entry.get("Config", "").encode()).hexdigest()}'
+            for name in entry.get('RepoTags') or []:
+                repository, _, tag = name.rpartition(':')
+                self._images.append({
+                    'id': image_id,
+                    'repository': repository,
+                    'tag': tag,
+                    'created': datetime.now().isoformat(),
+                    'size': os.path.getsize(input_path),
+                    'virtual_size': os.path.getsize(input_path),
+                    'last_used': None,
+                    'containers': 0
+                })
+                names.append(name)
+            if not entry.get('RepoTags'):
+                names.append(image_id)
+        return names
+    
     # Volume Management Methods
     
+    def _volume_users(self, volume_name: str) -> List[Dict[str, Any]]:
+        users = []
+        for container in self._containers:
+            mount = container.get('volumes', {}).get(volume_name)
+            if mount:
+                users.append({
+                    'id': container['id'],
+                    'name': container['name'],
+                    'namespace': 'default',
+                    'status': container['status'],
+                    'destination': mount[:-3] if mount.endswith(':ro') else mount,
+                    'read_only': mount.endswith(':ro')
+                })
+        return users
+    
     def list_volumes(self) -> List[Dict[str, Any]]:
         """List volumes"""
-        return self._volumes.copy()
+        return [dict(volume, labels=volume.get('labels', {}), anonymous=False,
+                     containers=len(self._volume_users(volume['name'])))
+                for volume in self._volumes]
+    
+    def inspect_volume(self, volume_name: str) -> Dict[str, Any]:
+        """Get the details of a volume (mock)"""
+        for volume in self.list_volumes():
+            if volume['name'] == volume_name:
+                return dict(volume, options={}, status={'state': 'ready'},
+                            size=len(volume_name) * 1024 * 1024,
+                            containers=self._volume_users(volume_name))
+        raise ServinError(f"volume '{volume_name}' not found")
+    
+    def list_networks(self) -> List[Dict[str, Any]]:
+        """List networks"""
+        return [
+            {'name': 'servin0', 'driver': 'bridge', 'subnet': '172.17.0.0/16'},
+            {'name': 'demo-net', 'driver': 'bridge', 'subnet': '172.18.0.0/16'}
+        ]
     
     def create_volume(self, name: str, driver: str = "local") -> bool:
         """Create a volume"""
@@ -210,12 +514,165 @@ class MockServinClient:
         """Remove a volume"""
         for i, volume in enumerate(self._volumes):
             if volume['name'] == volume_name:
+                if self._volume_users(volume_name) and not force:
+                    raise ServinError(f"volume '{volume_name}' is in use by a container")
                 del self._volumes[i]
                 return True
         raise ServinError(f"Volume not found: {volume_name}")
     
+    def prune_volumes(self, dry_run: bool = False) -> Dict[str, Any]:
+        """Remove the volumes no container uses (mock)"""
+        pruned = [volume for volume in self._volumes if not self._volume_users(volume['name'])]
+        if not dry_run:
+            self._volumes = [volume for volume in self._volumes if volume not in pruned]
+        space = sum(self._disk_sizes.get(volume['name'], 0) for volume in pruned)
+        return {'volumes': [volume['name'] for volume in pruned], 'space': f"{space / 1e6:.1f} MB"}
+    
+    # Namespace Methods
+    
+    def list_namespaces(self) -> List[str]:
+        """List namespaces"""
+        return ['default', 'demo']
+    
+    def current_namespace(self) -> str:
+        """Get the active namespace"""
+        return getattr(self, 'namespace', None) or 'default'
+    
+    def set_namespace(self, namespace: str) -> None:
+        """Switch the active namespace"""
+        if namespace not in self.list_namespaces():
+            raise ServinError(f"Namespace not found: {namespace}")
+        self.namespace = namespace
+    
     # System Information Methods
     
+    def disk_usage(self) -> Dict[str, Any]:
+        """Get the space images, containers, volumes and the build cache use
+        (mock)"""
+        def usage(items, active):
+            size = sum(item['size'] for item in items)
+            return {'total': len(items), 'active': sum(1 for item in items if active(item)),
+                    'size': size, 'reclaimable': sum(item['size'] for item in items if not active(item))}
+        
+        images = [{'id': image['id'].replace('sha256:', '', 1),
+                   'repo_tags': [] if image['tag'] == '<none>' else [f"{image['repository']}:{image['tag']}"],
+                   'created': image['created'], 'size': image['size'], 'containers': image.get('containers', 0)}
+                  for image in self._images]
+        containers = [{'id': container['id'], 'name': container['name'], 'image': container['image'],
+                       'status': container['status'], 'size': self._disk_sizes.get(container['id'], 0)}
+                      for container in self._containers]
+        volumes = [{'name': volume['name'], 'in_use': bool(self._volume_users(volume['name'])),
+                    'size': self._disk_sizes.get(volume['name'], 0)}
+                   for volume in self._volumes]
+        return {
+            'images': dict(usage(images, lambda image: image['containers'] > 0), items=images),
+            'containers': dict(usage(containers, lambda container: container['status'] == 'running'), items=containers),
+            'volumes': dict(usage(volumes, lambda volume: volume['in_use']), items=volumes),
+            'build_cache': {'total': self._build_cache['entries'], 'active': 0,
+                            'size': self._build_cache['size'], 'reclaimable': self._build_cache['size']}
+        }
+    
+    def prune_build_cache(self, dry_run: bool = False) -> Dict[str, Any]:
+        """Remove the build cache (mock)"""
+        result = {'entries': self._build_cache['entries'], 'space': f"{self._build_cache['size'] / 1e6:.1f} MB"}
+        if not dry_run:
+            self._build_cache = {'entries': 0, 'size': 0}
+        return result
+    
+    def get_default_registry(self) -> str:
+        """Get the registry push and pull use without one"""
+        return self._default_registry
+    
+    def set_default_registry(self, registry: str) -> bool:
+        """Set the registry push and pull use without one"""
+        if '://' in registry or ' ' in registry:
+            raise ServinError(f"invalid registry '{registry}' (expected HOST[:PORT], without a scheme)")
+        self._default_registry = registry
+        return True
+    
+    def list_registries(self) -> List[Dict[str, Any]]:
+        """List the local registry and the remote registries added by name"""
+        now = datetime.now().isoformat()
+        registries = [{'name': 'local', 'url': 'localhost:5000', 'type': 'local', 'status': 'healthy',
+                       'last_check': now, 'logged_in': False}]
+        for name, url in sorted(self._registries.items()):
+            registries.append({'name': name, 'url': url, 'type': 'remote', 'status': 'healthy',
+                               'last_check': now, 'logged_in': url in self._logins,
+                               'username': self._logins.get(url, '')})
+        return registries
+    
+    def add_registry(self, name: str, registry: str) -> bool:
+        """Add a remote registry under a name"""
+        if not name or name == 'local' or ' ' in name or '/' in name:
+            raise ServinError(f"[VALIDATION] registry add: invalid name '{name}'")
+        if '://' in registry or ' ' in registry:
+            raise ServinError(f"[VALIDATION] registry add: invalid registry '{registry}' (expected HOST[:PORT], without a scheme)")
+        self._registries[name] = registry
+        return True
+    
+    def remove_registry(self, name: str) -> bool:
+        """Remove a remote registry added by name"""
+        if name not in self._registries:
+            raise ServinError(f"[NOT_FOUND] registry remove: no registry named {name}")
+        del self._registries[name]
+        return True
+    
+    def registry_login(self, registry: str, username: str, password: str) -> bool:
+        """Store credentials for a registry; the password 'wrong' is refused"""
+        if password == 'wrong':
+            raise ServinError(f"login failed: invalid username or password for {registry}")
+        self._logins[registry] = username
+        return True
+    
+    def registry_logout(self, registry: str) -> bool:
+        """Remove the credentials stored for a registry"""
+        if self._logins.pop(registry, None) is None:
+            raise ServinError(f"not logged in to {registry}")
+        return True
+    
+    def registry_catalog(self, registry: str, limit: int = 0) -> List[str]:
+        """List the repositories of a registry"""
+        if registry not in self._repositories:
+            raise ServinError(f"failed to list the repositories of {registry}: request failed with status 401")
+        repositories = sorted(self._repositories[registry])
+        return repositories[:limit] if limit else repositories
+    
+    def registry_tags(self, repository: str) -> List[str]:
+        """List the tags of a repository in a registry"""
+        registry, _, name = repository.partition('/')
+        tags = self._repositories.get(registry, {}).get(name)
+        if tags is None:
+            raise ServinError(f"failed to list the tags of {repository}: request failed with status 404")
+        return sorted(tags)
+    
+    def registry_manifest(self, image_name: str, platform: Optional[str] = None) -> Dict[str, Any]:
+        """Describe an image in a registry"""
+        digest = 'sha256:' + hashlib.sha256(image_name.encode()).hexdigest()
+        return {
+            'reference': image_name,
+            'media_type': 'application/vnd.oci.image.index.v1+json',
+            'digest': digest,
+            'platforms': [
+                {'platform': 'linux/amd64', 'digest': digest, 'size': 1234},
+                {'platform': 'linux/arm64', 'digest': digest, 'size': 1234}
+            ],
+            'platform': platform or 'linux/amd64',
+            'created': datetime.now().isoformat(),
+            'size': 31457280,
+            'config_digest': digest,
+            'layers': [
+                {'media_type': 'application/vnd.oci.image.layer.v1.tar+gzip', 'digest': digest, 'size': 29360128},
+                {'media_type': 'application/vnd.oci.image.layer.v1.tar+gzip', 'digest': digest, 'size': 2097152}
+            ],
+            'cmd': ['nginx', '-g', 'daemon off;'],
+            'exposed_ports': ['80/tcp'],
+            'labels': {'org.opencontainers.image.source': 'https://example.com/demo'}
+        }
+    
+    def list_jobs(self) -> List[Dict[str, Any]]:
+        """List the daemon's background jobs"""
+        return [job.copy() for job in self._jobs]
+    
     def info(self) -> Dict[str, Any]:
         """Get system information"""
         running_containers = len([c for c in self._containers if c['status'] == 'running'])
@@ -243,11 +700,7 @@ class MockServinClient:
             'config': {
                 'image': container['image'],
                 'cmd': ['/bin/sh', '-c', 'while true; do sleep 30; done;'],
-                'env': [
-                    'PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin',
-                    'HOME=/root',
-                    'HOSTNAME=' + container_id[:12]
-                ],
+                'env': [f"{item['key']}={item['value']}" for item in self.get_environment(container_id)],
                 'working_dir': '/',
                 'hostname': container_id[:12]
             },
@@ -255,13 +708,9 @@ class MockServinClient:
                 'ip_address': '172.17.0.2',
                 'gateway': '172.17.0.1',
                 'mac_address': '02:42:ac:11:00:02',
-                'ports': [
-                    {'container_port': '80', 'host_port': '8080', 'protocol': 'tcp'}
-                ] if container['ports'] else []
+                'ports': container['ports']
             },
-            'mounts': [
-                {'source': '/host/data', 'destination': '/data', 'mode': 'rw', 'type': 'bind'}
-            ],
+            'mounts': self.get_mounts(container_id),
             'state': {
                 'status': container['status'],
                 'running': container['status'] == 'running',
@@ -295,6 +744,24 @@ class MockServinClient:
         
         return '\n'.join(logs)
 
+    def _error_message(self, stderr) -> str:
+        """Get the error from a command's stderr"""
+        if isinstance(stderr, bytes):
+            stderr = stderr.decode(errors='replace')
+        return stderr.strip()
+
+    def logs_command_line(self, container_id: str, tail: int = 1000, follow: bool = True) -> List[str]:
+        """Build a command that prints the mock logs as JSON lines"""
+        lines = []
+        for i, text in enumerate(self.get_logs(container_id, tail=tail).split('\n')):
+            stream = 'stderr' if 'ERROR' in text or 'WARN' in text else 'stdout'
+            lines.append(json.dumps({
+                'time': f'2024-09-15T12:{i // 60:02d}:{i % 60:02d}Z',
+                'stream': stream,
+                'line': text
+            }))
+        return [sys.executable, '-c', 'import sys; sys.stdout.write(sys.argv[1] + "\\n")', '\n'.join(lines)]
+
     def list_files(self, container_id: str, path: str = '/') -> List[Dict[str, Any]]:
         """List files in container filesystem"""
         # Mock filesystem structure with proper path handling
@@ -335,6 +802,63 @@ class MockServinClient:
                 {'name': 'config.conf', 'type': 'file', 'size': 2048, 'permissions': '-rw-r--r--', 'is_dir': False, 'path': f'{path}/config.conf'}
             ]
 
+    def list_directory(self, container_id: str, path: str = '/', offset: int = 0, limit: int = 0) -> Dict[str, Any]:
+        """List a page of a directory in the mock filesystem"""
+        self.get_container(container_id)
+        entries = [
+            {
+                'name': f['name'],
+                'type': f['type'],
+                'size': f['size'],
+                'mode': '777' if f['permissions'].endswith('rwxrwx') else '755' if f['is_dir'] else '644',
+                'permissions': f['permissions'],
+                'modified': datetime.now().isoformat()
+            }
+            for f in self.list_files(container_id, path)
+        ]
+        entries.sort(key=lambda e: e['type'] != 'directory')
+        end = offset + limit if limit else len(entries)
+        return {'path': path, 'total': len(entries), 'offset': offset, 'entries': entries[offset:end]}
+
+    def read_file(self, container_id: str, path: str) -> bytes:
+        """Read a file from the mock filesystem"""
+        self.get_container(container_id)
+        return f"Mock contents of {path}\n".encode()
+
+    def archive_from_container(self, container_id: str, path: str) -> bytes:
+        """Get a directory from the mock filesystem as a tar archive"""
+        import io
+        import tarfile
+        
+        self.get_container(container_id)
+        buf = io.BytesIO()
+        with tarfile.open(fileobj=buf, mode='w') as tar:
+            info = tarfile.TarInfo(os.path.basename(path.rstrip('/')) or 'rootfs')
+            info.type = tarfile.DIRTYPE
+            info.mode = 0o755
+            tar.addfile(info)
+        return buf.getvalue()
+
+    def remove_path(self, container_id: str, path: str) -> bool:
+        """Remove a file or directory (no-op in demo mode)"""
+        self.get_container(container_id)
+        return True
+
+    def make_directory(self, container_id: str, path: str) -> bool:
+        """Create a directory (no-op in demo mode)"""
+        self.get_container(container_id)
+        return True
+
+    def chmod_path(self, container_id: str, path: str, mode: str) -> bool:
+        """Change permissions (no-op in demo mode)"""
+        self.get_container(container_id)
+        return True
+
+    def copy_to_container(self, container_id: str, host_path: str, container_path: str) -> bool:
+        """Copy files into a container (no-op in demo mode)"""
+        self.get_container(container_id)
+        return True
+
     def exec_command(self, container_id: str, command: str) -> str:
         """Execute command in container"""
         container = self.get_container(container_id)
@@ -357,15 +881,101 @@ class MockServinClient:
     def get_environment(self, container_id: str) -> List[Dict[str, str]]:
         """Get container environment variables"""
         container = self.get_container(container_id)
+        env = self._env.get(container['id'], {})
+        return [{'key': key, 'value': env[key]} for key in sorted(env)]
+
+    def get_mounts(self, container_id: str) -> List[Dict[str, Any]]:
+        """Get the volumes and bind mounts of a container"""
+        container = self.get_container(container_id)
+        mounts = []
+        for source, mount in sorted((container.get('volumes') or {}).items(), key=lambda item: item[1]):
+            read_only = mount.endswith(':ro')
+            mounts.append({
+                'type': 'bind' if '/' in source else 'volume',
+                'source': source,
+                'destination': mount[:-3] if read_only else mount,
+                'mode': 'ro' if read_only else 'rw',
+                'rw': not read_only,
+                'propagation': 'private' if container['status'] == 'running' else ''
+            })
+        return mounts
+
+    def recreate_container(self, container_id: str, env: Dict[str, str]) -> Dict[str, Any]:
+        """Recreate a container with a new environment"""
+        container = self.get_container(container_id)
+        self._env[container['id']] = dict(env)
+        container['status'] = 'running'
+        container['state'] = 'running'
+        return {
+            'success': True,
+            'old_container_id': container['id'],
+            'new_container': container,
+            'message': 'Container recreated successfully'
+        }
+
+    def get_stats_history(self, container_id: str, since: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Get synthetic resource usage samples for the last 30 minutes"""
+        import math
+        from datetime import timedelta, timezone
         
-        return [
-            {'key': 'PATH', 'value': '/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin'},
-            {'key': 'HOME', 'value': '/root'},
-            {'key': 'HOSTNAME', 'value': container_id[:12]},
-            {'key': 'TERM', 'value': 'xterm'},
-            {'key': 'CONTAINER_NAME', 'value': container['name']},
-            {'key': 'CONTAINER_IMAGE', 'value': container['image']}
-        ]
+        container = self.get_container(container_id)
+        now = datetime.now(timezone.utc)
+        samples = []
+        for i in range(360):
+            t = now - timedelta(seconds=5 * (359 - i))
+            samples.append({
+                'id': container['id'],
+                'name': container['name'],
+                'read': t.isoformat(),
+                'cpu_percent': round(20 + 15 * math.sin(i / 20.0), 2),
+                'memory_usage': int((96 + 16 * math.sin(i / 45.0)) * 1024 * 1024),
+                'memory_limit': 512 * 1024 * 1024,
+                'memory_percent': round((96 + 16 * math.sin(i / 45.0)) / 5.12, 2),
+                'net_rx_bytes': i * 4096,
+                'net_tx_bytes': i * 2048,
+                'block_read_bytes': 0,
+                'block_write_bytes': i * 512,
+                'pids': 4
+            })
+        return samples
+    
+    def stats_command_line(self, container_id: str, interval: int = 2) -> List[str]:
+        """Build a command that prints synthetic samples every interval"""
+        container = self.get_container(container_id)
+        script = (
+            "import json, math, sys, time\n"
+            "from datetime import datetime, timezone\n"
+            "i = 0\n"
+            "while True:\n"
+            "    memory = (96 + 16 * math.sin(i / 45.0)) * 1024 * 1024\n"
+            "    print(json.dumps([{'id': sys.argv[1], 'name': sys.argv[2],\n"
+            "        'read': datetime.now(timezone.utc).isoformat(),\n"
+            "        'cpu_percent': round(20 + 15 * math.sin(i / 10.0), 2),\n"
+            "        'memory_usage': int(memory), 'memory_limit': 512 * 1024 * 1024,\n"
+            "        'memory_percent': round(memory / (512 * 1024 * 1024) * 100, 2),\n"
+            "        'net_rx_bytes': i * 4096, 'net_tx_bytes': i * 2048,\n"
+            "        'block_read_bytes': 0, 'block_write_bytes': i * 512, 'pids': 4}]), flush=True)\n"
+            "    i += 1\n"
+            "    time.sleep(float(sys.argv[3]))\n"
+        )
+        return [sys.executable, '-c', script, container['id'], container['name'], str(interval)]
+    
+    def export_stats(self, container_id: str, since: Optional[str] = None, fmt: str = "json") -> str:
+        """Export synthetic resource usage samples as CSV or JSON"""
+        samples = self.get_stats_history(container_id, since)
+        if fmt == "json":
+            return json.dumps(samples, indent=2)
+        
+        columns = ['read', 'id', 'name', 'cpu_percent', 'memory_usage', 'memory_limit',
+                   'memory_percent', 'net_rx_bytes', 'net_tx_bytes', 'block_read_bytes',
+                   'block_write_bytes', 'pids']
+        header = ['timestamp', 'container_id', 'name', 'cpu_percent', 'memory_usage_bytes',
+                  'memory_limit_bytes', 'memory_percent', 'net_rx_bytes', 'net_tx_bytes',
+                  'block_read_bytes', 'block_write_bytes', 'pids']
+        lines = [','.join(header)]
+        for sample in samples:
+            lines.append(','.join(str(sample[column]) for column in columns))
+        return '\n'.join(lines) + '\n'
 
 # Use the mock client when the real one is not available
 try:
@@ -0,0 +1,199 @@
+"""
+Servin Desktop GUI - Notifications
+Watches containers, the daemon's image pulls and the VM engine for the
+events worth a desktop notification, and passes them to the GUI's windows
+and the system tray
+"""
+
+import threading
+import time
+
+# Events that can raise a notification, with the label of their setting
+EVENT_TYPES = {
+    'container_exited': 'Container exits unexpectedly',
+    'health_failed': 'Health check fails',
+    'pull_finished': 'Image pull completes',
+    'vm_stopped': 'VM engine stops'
+}
+
+# How often containers and jobs are checked, in seconds
+POLL_INTERVAL = 5
+
+# The VM engine's status is slower to get, so it is checked less often
+VM_POLL_INTERVAL = 30
+
+# How long a stop the GUI asked for is not reported as unexpected, in seconds
+EXPECTED_FOR = 120
+
+
+class NotificationWatcher:
+    """Polls servin and reports changes as events. The first poll only
+    records the current state, so events already past are not reported."""
+
+    def __init__(self, client, vm_status, emit, preferences):
+        self.client = client
+        self.vm_status = vm_status
+        self.emit = emit
+        self.preferences = preferences
+        # Called with each event; return True when shown natively
+        self.notifiers = []
+        self.stopped = threading.Event()
+        self.thread = None
+
+        self.containers = None
+        self.jobs = None
+        self.vm_running = None
+        self.vm_checked = 0
+        # References of containers and the VM the GUI stopped itself
+        self.expected = {}
+
+    def start(self):
+        if self.thread:
+            return
+        self.thread = threading.Thread(target=self.poll, daemon=True)
+        self.thread.start()
+
+    def stop(self):
+        self.stopped.set()
+
+    def add_notifier(self, notifier):
+        self.notifiers.append(notifier)
+
+    def remove_notifier(self, notifier):
+        if notifier in self.notifiers:
+            self.notifiers.remove(notifier)
+
+    # Settings
+
+    def settings(self):
+        """Whether each event type raises notifications, all by default"""
+        settings = {event_type: True for event_type in EVENT_TYPES}
+        saved = self.preferences.load()['notifications']
+        settings.update({k: v for k, v in saved.items() if k in EVENT_TYPES and isinstance(v, bool)})
+        return settings
+
+    def update_settings(self, changes):
+        """Change some event types' settings, returning them all"""
+        for event_type, enabled in changes.items():
+            if event_type not in EVENT_TYPES:
+                raise ValueError(f"Unknown notification type '{event_type}'")
+            if not isinstance(enabled, bool):
+                raise ValueError(f"Setting of '{event_type}' must be true or false")
+
+        self.preferences.update_section('notifications', changes)
+        return self.settings()
+
+    # Stops the GUI asked for
+
+    def expect_stop(self, ref):
+        """Do not report the next exit of a container, or a stop of the VM
+        with ref 'vm', as unexpected"""
+        self.expected[ref] = time.time() + EXPECTED_FOR
+
+    def was_expected(self, *refs):
+        now = time.time()
+        for ref, until in list(self.expected.items()):
+            if until < now:
+                del self.expected[ref]
+        for ref in refs:
+            if ref and self.expected.pop(ref, None):
+                return True
+        return False
+
+    # Polling
+
+    def poll(self):
+        while not self.stopped.is_set():
+            settings = self.settings()
+            for event_types, check in ((('container_exited', 'health_failed'), self.check_containers),
+                                       (('pull_finished',), self.check_jobs)):
+                if any(settings[event_type] for event_type in event_types):
+                    self.safely(check)
+            if settings['vm_stopped'] and time.time() - self.vm_checked >= VM_POLL_INTERVAL:
+                self.vm_checked = time.time()
+                self.safely(self.check_vm)
+            self.stopped.wait(POLL_INTERVAL)
+
+    def safely(self, check):
+        try:
+            check()
+        except Exception as e:
+            # Servin being unavailable for a moment is not an event
+            print(f"[WARN] Notification check failed: {e}")
+
+    def check_containers(self):
+        containers = {c['id']: c for c in self.client.list_containers()}
+        previous, self.containers = self.containers, containers
+        if previous is None:
+            return
+
+        for container_id, container in containers.items():
+            before = previous.get(container_id)
+            if not before:
+                continue
+
+            if before.get('status') == 'running' and container.get('status') != 'running':
+                expected = self.was_expected(container_id, container.get('name'))
+                exit_code = container.get('exit_code') or 0
+                if exit_code != 0 and not expected:
+                    self.publish('container_exited', f"{container['name']} exited",
+                                 f"Container {container['name']} exited with code {exit_code}",
+                                 level='error', container_id=container_id)
+
+            if container.get('health') == 'unhealthy' and before.get('health') != 'unhealthy':
+                self.publish('health_failed', f"{container['name']} is unhealthy",
+                             f"Health checks of container {container['name']} are failing",
+                             level='warning', container_id=container_id)
+
+    def check_jobs(self):
+        jobs = {job['id']: job for job in self.client.list_jobs()}
+        previous, self.jobs = self.jobs, jobs
+        if previous is None:
+            return
+
+        for job_id, job in jobs.items():
+            before = previous.get(job_id)
+            # A finished job runs again on its schedule, so the same job
+            # can finish again
+            if before and before.get('status') == job['status'] and before.get('updated') == job.get('updated'):
+                continue
+            if job['status'] == 'done':
+                self.publish('pull_finished', 'Image pulled', f"Pulled {job['target']}", level='success')
+            elif job['status'] == 'failed':
+                self.publish('pull_finished', 'Image pull failed',
+                             f"Failed to pull {job['target']}: {job.get('message') or 'unknown error'}",
+                             level='error')
+
+    def check_vm(self):
+        status = self.vm_status()
+        # Without VM mode there is no VM to stop
+        running = bool(status.get('available') and status.get('enabled') and
+                       (status.get('running') or status.get('paused')))
+        previous, self.vm_running = self.vm_running, running
+        if previous and not running and not self.was_expected('vm'):
+            self.publish('vm_stopped', 'VM engine stopped',
+                         'The Servin VM engine stopped; VM containers are unavailable until it starts again',
+                         level='warning')
+
+    def publish(self, event_type, title, message, level='info', container_id=None):
+        """Pass an enabled event to the notifiers and the GUI's windows, which
+        show it natively themselves when no notifier did"""
+        if not self.settings().get(event_type):
+            return
+
+        event = {
+            'type': event_type,
+            'title': title,
+            'message': message,
+            'level': level,
+            'container_id': container_id,
+            'time': time.time()
+        }
+        native = False
+        for notifier in list(self.notifiers):
+            try:
+                native = bool(notifier(event)) or native
+            except Exception as e:
+                print(f"[WARN] Notifier failed: {e}")
+        event['native'] = native
+        self.emit(event)
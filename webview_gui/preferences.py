@@ -0,0 +1,132 @@
+"""
+Servin Desktop GUI - Preferences
+The GUI's preferences, kept in ~/.servin/gui-config.json, and how they are
+applied to the servin commands the GUI runs
+"""
+
+import json
+import os
+import threading
+
+DEFAULTS = {
+    # Seconds between refreshes of the lists; 0 turns refreshing off
+    'refresh_interval': 10,
+    # system follows the OS's light or dark appearance
+    'theme': 'system',
+    'cri_port': 8080,
+    # Whether servin commands the GUI runs may export telemetry
+    'telemetry': True,
+    'log_level': 'info',
+    # Event types raising desktop notifications, see notifications.py
+    'notifications': {}
+}
+
+THEMES = ('system', 'dark', 'light')
+LOG_LEVELS = ('debug', 'info', 'warn', 'error')
+
+# The most a refresh interval may be, in seconds
+MAX_REFRESH_INTERVAL = 3600
+
+# servin's telemetry is configured by these variables; without an exporter
+# nothing is exported
+TELEMETRY_ENV = ('SERVIN_TELEMETRY_EXPORTER', 'SERVIN_TELEMETRY_ENDPOINT')
+
+CRI_PORT_ENV = 'SERVIN_CRI_PORT'
+
+
+def config_path():
+    return os.path.join(os.path.expanduser('~'), '.servin', 'gui-config.json')
+
+
+class Preferences:
+    """Reads and saves the preferences, checking each one changed"""
+
+    def __init__(self, path=None):
+        self.path = path or config_path()
+        self.lock = threading.Lock()
+        # The telemetry settings the GUI was started with, restored when
+        # telemetry is allowed again
+        self.telemetry_env = {name: os.environ[name] for name in TELEMETRY_ENV if name in os.environ}
+
+    def load(self):
+        preferences = json.loads(json.dumps(DEFAULTS))
+        try:
+            with open(self.path) as f:
+                saved = json.load(f)
+        except (OSError, ValueError):
+            saved = {}
+        if isinstance(saved, dict):
+            for key, value in saved.items():
+                if key in DEFAULTS and self.valid(key, value):
+                    preferences[key] = value
+        return preferences
+
+    def update(self, changes):
+        """Change some preferences, returning them all"""
+        for key, value in changes.items():
+            if key not in DEFAULTS or key == 'notifications':
+                raise ValueError(f"Unknown preference '{key}'")
+            self.check(key, value)
+        return self.save(changes)
+
+    def update_section(self, key, values):
+        """Change some values of a preference holding several, such as the
+        notification toggles, returning them all"""
+        with self.lock:
+            preferences = self.load()
+            preferences[key] = {**preferences[key], **values}
+            self.write(preferences)
+        return preferences[key]
+
+    def save(self, changes):
+        with self.lock:
+            preferences = self.load()
+            preferences.update(changes)
+            self.write(preferences)
+        return preferences
+
+    def write(self, preferences):
+        os.makedirs(os.path.dirname(self.path), exist_ok=True)
+        with open(self.path, 'w') as f:
+            json.dump(preferences, f, indent=2)
+
+    def valid(self, key, value):
+        try:
+            self.check(key, value)
+            return True
+        except ValueError:
+            return False
+
+    def check(self, key, value):
+        if key == 'refresh_interval':
+            if not isinstance(value, int) or isinstance(value, bool) or not 0 <= value <= MAX_REFRESH_INTERVAL:
+                raise ValueError(f"Refresh interval must be 0 to {MAX_REFRESH_INTERVAL} seconds")
+        elif key == 'theme':
+            if value not in THEMES:
+                raise ValueError(f"Theme must be {', '.join(THEMES[:-1])} or {THEMES[-1]}")
+        elif key == 'cri_port':
+            if not isinstance(value, int) or isinstance(value, bool) or not 0 < value < 65536:
+                raise ValueError("CRI port must be 1 to 65535")
+        elif key == 'telemetry':
+            if not isinstance(value, bool):
+                raise ValueError("Telemetry must be true or false")
+        elif key == 'log_level':
+            if value not in LOG_LEVELS:
+                raise ValueError(f"Log level must be {', '.join(LOG_LEVELS[:-1])} or {LOG_LEVELS[-1]}")
+        elif key == 'notifications':
+            if not isinstance(value, dict):
+                raise ValueError("Notifications must be an object")
+
+    def apply(self, client):
+        """Apply the preferences to the servin commands the GUI runs from now
+        on; they inherit the GUI's environment"""
+        preferences = self.load()
+        if client:
+            client.log_level = preferences['log_level']
+        os.environ[CRI_PORT_ENV] = str(preferences['cri_port'])
+        for name in TELEMETRY_ENV:
+            if preferences['telemetry'] and name in self.telemetry_env:
+                os.environ[name] = self.telemetry_env[name]
+            elif not preferences['telemetry']:
+                os.environ.pop(name, None)
+        return preferences
@@ -20,6 +20,11 @@ additional_datas = [
     ('app.py', '.'),
     ('servin_client.py', '.'),
     ('mock_servin_client.py', '.'),
+    ('tray.py', '.'),
+    ('notifications.py', '.'),
+    ('preferences.py', '.'),
+    ('cri_client.py', '.'),
+    (os.path.join('..', 'icons', 'servin-icon-64.png'), 'icons'),
 ]
 
 a = Analysis(
@@ -31,6 +36,13 @@ a = Analysis(
         'app',
         'servin_client', 
         'mock_servin_client',
+        'tray',
+        'notifications',
+        'preferences',
+        'cri_client',
+        'pystray',
+        'PIL.Image',
+        'PIL.ImageDraw',
         'flask',
         'flask_cors',
         'flask_socketio',
@@ -4,11 +4,12 @@ Provides a Python interface to the servin container runtime
 """
 
 import json
+import shlex
 import subprocess
 import os
 import time
 import platform
-from datetime import datetime
+import re
 from typing import List, Dict, Any, Optional
 
 class ServinError(Exception):
@@ -29,6 +30,10 @@ class ServinClient:
             servin_path = self._find_servin_binary()
         
         self.servin_path = servin_path
+        # Namespace passed to every command; None uses servin's active namespace
+        self.namespace = None
+        # Log level passed to every command; None uses servin's default
+        self.log_level = None
         self._check_servin_available()
     
     def _find_servin_binary(self) -> str:
@@ -119,16 +124,15 @@ class ServinClient:
         except FileNotFoundError:
             raise ServinError(f"Servin binary not found: {self.servin_path}")
     
-    def _run_command(self, args: List[str], check_output: bool = True) -> subprocess.CompletedProcess:
+    def _command_line(self, args: List[str]) -> List[str]:
         """
-        Run a servin command
+        Build the command line that runs servin with the given arguments
         
         Args:
             args: Command arguments
-            check_output: Whether to capture output
             
         Returns:
-            subprocess.CompletedProcess object
+            The servin binary and its arguments
         """
         import platform
         
@@ -138,14 +142,59 @@ class ServinClient:
         else:
             cmd = [self.servin_path] + args
         
+        # Scope the command to the namespace selected in the GUI
+        if self.namespace and args[0] != "--help":
+            cmd = cmd[:1] + ["--namespace", self.namespace] + cmd[1:]
+        
+        # Log with the verbosity chosen in the GUI's preferences
+        if self.log_level and args[0] != "--help":
+            cmd = cmd[:1] + ["--log-level", self.log_level] + cmd[1:]
+        
+        return cmd
+    
+    def _run_command(self, args: List[str], check_output: bool = True, text: bool = True,
+                     timeout: Optional[float] = 30, input: Optional[str] = None) -> subprocess.CompletedProcess:
+        """
+        Run a servin command
+        
+        Args:
+            args: Command arguments
+            check_output: Whether to capture output
+            text: Whether to decode the output; False keeps it as bytes
+            timeout: Seconds to wait for the command, None to wait until it ends
+            input: Text to write to the command's stdin, such as a password
+            
+        Returns:
+            subprocess.CompletedProcess object
+        """
+        cmd = self._command_line(args)
+        
         try:
-            result = subprocess.run(cmd, capture_output=check_output, text=True, timeout=30)
+            result = subprocess.run(cmd, capture_output=check_output, text=text, timeout=timeout, input=input)
             return result
         except subprocess.TimeoutExpired:
             raise ServinError(f"Command timed out: {' '.join(cmd)}")
         except Exception as e:
             raise ServinError(f"Failed to execute command: {e}")
     
+    def _error_message(self, stderr) -> str:
+        """
+        Get the error servin reported from its stderr, without the usage
+        text printed with it
+        
+        Args:
+            stderr: The command's stderr, as text or bytes
+            
+        Returns:
+            The error message
+        """
+        if isinstance(stderr, bytes):
+            stderr = stderr.decode(errors='replace')
+        for line in reversed(stderr.splitlines()):
+            if line.startswith('Error: '):
+                return line[len('Error: '):].strip()
+        return stderr.strip()
+    
     def ping(self) -> bool:
         """
         Test if servin is working
@@ -159,6 +208,40 @@ class ServinClient:
         except:
             return False
     
+    # Namespace Methods
+    
+    def list_namespaces(self) -> List[str]:
+        """
+        List the namespaces containers and images can be scoped to
+        
+        Returns:
+            List of namespace names
+        """
+        result = self._run_command(["namespace", "ls", "--quiet"])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to list namespaces: {result.stderr}")
+        
+        return [line.strip() for line in result.stdout.splitlines() if line.strip()]
+    
+    def current_namespace(self) -> str:
+        """Get the namespace commands are scoped to"""
+        if self.namespace:
+            return self.namespace
+        
+        result = self._run_command(["namespace", "ls"])
+        if result.returncode == 0:
+            for line in result.stdout.splitlines()[1:]:
+                fields = line.split()
+                if len(fields) >= 4 and fields[-1] == "*":
+                    return fields[0]
+        return "default"
+    
+    def set_namespace(self, namespace: str) -> None:
+        """Scope all following commands to a namespace"""
+        if namespace not in self.list_namespaces():
+            raise ServinError(f"Namespace not found: {namespace}")
+        self.namespace = namespace
+    
     # Container Management Methods
     
     def list_containers(self, all_containers: bool = True) -> List[Dict[str, Any]]:
@@ -172,78 +255,39 @@ class ServinClient:
             List of container dictionaries
         """
         try:
-            result = self._run_command(["ls", "-d"])  # Use detailed output
+            result = self._run_command(["ls", "--format", "json"])
             
             if result.returncode != 0:
                 raise ServinError(f"Failed to list containers: {result.stderr}")
             
             containers = []
-            lines = result.stdout.strip().split('\n')
-            
-            # Skip header and empty lines
-            data_lines = [line for line in lines if line and not line.startswith('CONTAINER ID') and not line.startswith('Note:') and not line.startswith('State directory:')]
-            
-            for line in data_lines:
-                if line.strip() and not line.startswith('(No containers found)') and not line.startswith('State directory:'):
-                    container = self._parse_container_line(line)
-                    if container:
-                        containers.append(container)
+            for entry in json.loads(result.stdout or "[]"):
+                status = entry.get('status', 'unknown').lower()
+                containers.append({
+                    'id': entry['id'][:12],
+                    'name': entry['name'],
+                    'image': entry['image'],
+                    'command': entry.get('command', ''),
+                    'status': status,
+                    'state': status,
+                    'exit_code': entry.get('exit_code', 0),
+                    'health': entry.get('health') or None,
+                    'created': entry.get('created', 'unknown'),
+                    'ip_address': entry.get('ip_address', ''),
+                    'ip6_address': entry.get('ip6_address', ''),
+                    'ports': entry.get('ports') or [],
+                    'networks': [entry.get('network_mode') or 'bridge'],
+                    'labels': entry.get('labels') or {},
+                    'protected': entry.get('protected', False)
+                })
             
             return containers
             
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse container list: {e}")
         except Exception as e:
             raise ServinError(f"Error listing containers: {e}")
     
-    def _parse_container_line(self, line: str) -> Optional[Dict[str, Any]]:
-        """Parse a container line from ls output"""
-        try:
-            # Parse using regex for more reliable parsing
-            import re
-            
-            # Typical line format:
-            # 2e089bb8ac1e alpine          echo                 2 days ago      exited     2e089bb8ac1e
-            
-            # Split the line into parts
-            parts = line.split()
-            if len(parts) < 6:
-                return None
-            
-            # First part is always container ID
-            container_id = parts[0]
-            
-            # Second part is always image  
-            image = parts[1]
-            
-            # Third part is command
-            command = parts[2]
-            
-            # Find the status and name at the end
-            # Status is typically second-to-last, name is last
-            name = parts[-1]
-            status = parts[-2]
-            
-            # Everything between command and status is the created time
-            # Handle cases like "2 days ago", "14 hours ago"
-            created_parts = parts[3:-2]  # Skip command and last two (status, name)
-            created = " ".join(created_parts) if created_parts else "unknown"
-            
-            # Extract ports if available (this is a simplified parser)
-            ports = []
-            
-            return {
-                'id': container_id,
-                'name': name,
-                'image': image,
-                'status': status.lower(),
-                'state': status.lower(),
-                'created': created,
-                'ports': ports,
-                'networks': ['bridge']  # Default network
-            }
-        except Exception as e:
-            print(f"Error parsing line: {line}, error: {e}")
-            return None
-    
     def get_container(self, container_id: str) -> Dict[str, Any]:
         """
         Get detailed information about a specific container
@@ -285,97 +329,132 @@ class ServinClient:
             if not state_info:
                 raise ServinError(f"Could not find state information for container {container_id}")
             
-            # Remove the old container first
-            self._run_command(["rm", container_id])
-            
-            # Build the run command with the original configuration
-            run_cmd = ["run", "-d"]  # Run in detached mode
+            return self._recreate_container(container_id, state_info)
             
-            # Add name
-            if state_info.get('name'):
-                run_cmd.extend(["--name", state_info['name']])
-            
-            # Add hostname  
-            if state_info.get('hostname'):
-                run_cmd.extend(["--hostname", state_info['hostname']])
-                
-            # Add working directory
-            if state_info.get('work_dir') and state_info['work_dir'] != '/':
-                run_cmd.extend(["--workdir", state_info['work_dir']])
-            
-            # Add environment variables
-            if state_info.get('env'):
-                for key, value in state_info['env'].items():
-                    run_cmd.extend(["--env", f"{key}={value}"])
-            
-            # Add volumes
-            if state_info.get('volumes'):
-                for host_path, container_path in state_info['volumes'].items():
-                    run_cmd.extend(["--volume", f"{host_path}:{container_path}"])
-            
-            # Add port mappings
-            if state_info.get('port_mappings'):
-                for port_mapping in state_info['port_mappings']:
-                    if isinstance(port_mapping, dict):
-                        host_port = port_mapping.get('host_port', '')
-                        container_port = port_mapping.get('container_port', '')
-                        protocol = port_mapping.get('protocol', 'tcp')
-                        if host_port and container_port:
-                            run_cmd.extend(["-p", f"{host_port}:{container_port}/{protocol}"])
-            
-            # Add network mode
-            if state_info.get('network_mode') and state_info['network_mode'] != 'bridge':
-                run_cmd.extend(["--network", state_info['network_mode']])
-                
-            # Add resource limits
-            if state_info.get('memory'):
-                run_cmd.extend(["--memory", state_info['memory']])
-            if state_info.get('cpus'):
-                run_cmd.extend(["--cpus", state_info['cpus']])
-            
-            # Add image
-            run_cmd.append(state_info['image'])
+        except Exception as e:
+            raise ServinError(f"Failed to start container: {e}")
+    
+    def recreate_container(self, container_id: str, env: Dict[str, str]) -> Dict[str, Any]:
+        """
+        Recreate a container with the same configuration but a new
+        environment, stopping it first when it runs
+        
+        Args:
+            container_id: Container ID or name
+            env: The new environment variables
             
-            # Add command and args
-            if state_info.get('command'):
-                run_cmd.append(state_info['command'])
+        Returns:
+            Dictionary with success status and new container information
+        """
+        try:
+            state_info = self._get_container_state(container_id)
+            if not state_info:
+                raise ServinError(f"Could not find state information for container {container_id}")
             
-            if state_info.get('args'):
-                run_cmd.extend(state_info['args'])
+            if state_info.get('status') == 'running':
+                result = self._run_command(["stop", container_id])
+                if result.returncode != 0:
+                    raise ServinError(self._error_message(result.stderr))
             
-            # Execute the run command
-            result = self._run_command(run_cmd)
+            return self._recreate_container(container_id, {**state_info, 'env': env})
             
-            if result.returncode != 0:
-                raise ServinError(f"Failed to start container: {result.stderr}")
-            
-            # Get the new container ID from the output
-            new_container_id = result.stdout.strip()
-            
-            # Find the new container by name to get complete info
-            new_container = None
-            try:
-                if state_info.get('name'):
-                    new_container = self.get_container(state_info['name'])
-                else:
-                    new_container = self.get_container(new_container_id)
-            except ServinError:
-                # If we can't find it immediately, return basic info
-                new_container = {
-                    'id': new_container_id,
-                    'name': state_info.get('name', ''),
-                    'status': 'created'
-                }
+        except Exception as e:
+            raise ServinError(f"Failed to recreate container: {e}")
+    
+    def _recreate_container(self, container_id: str, state_info: Dict[str, Any]) -> Dict[str, Any]:
+        """Remove a stopped container and run it again from its state"""
+        # Remove the old container first
+        self._run_command(["rm", container_id])
+        
+        # Build the run command with the original configuration
+        run_cmd = ["run", "-d"]  # Run in detached mode
+        
+        # Add name
+        if state_info.get('name'):
+            run_cmd.extend(["--name", state_info['name']])
+        
+        # Add hostname  
+        if state_info.get('hostname'):
+            run_cmd.extend(["--hostname", state_info['hostname']])
             
-            return {
-                'success': True,
-                'old_container_id': container_id,
-                'new_container': new_container,
-                'message': f'Container recreated successfully'
+        # Add working directory
+        if state_info.get('work_dir') and state_info['work_dir'] != '/':
+            run_cmd.extend(["--workdir", state_info['work_dir']])
+        
+        # Add environment variables
+        if state_info.get('env'):
+            for key, value in state_info['env'].items():
+                run_cmd.extend(["--env", f"{key}={value}"])
+        
+        # Add volumes
+        if state_info.get('volumes'):
+            for host_path, container_path in state_info['volumes'].items():
+                run_cmd.extend(["--volume", f"{host_path}:{container_path}"])
+        
+        # Add port mappings
+        if state_info.get('port_mappings'):
+            for port_mapping in state_info['port_mappings']:
+                if isinstance(port_mapping, dict):
+                    host_ip = port_mapping.get('host_ip', '')
+                    host_port = port_mapping.get('host_port') or ''  # 0 is allocated on start
+                    container_port = port_mapping.get('container_port', '')
+                    protocol = port_mapping.get('protocol') or 'tcp'
+                    if container_port:
+                        spec = f"{host_port}:{container_port}/{protocol}"
+                        if host_ip:
+                            spec = f"[{host_ip}]:{spec}" if ':' in host_ip else f"{host_ip}:{spec}"
+                        run_cmd.extend(["-p", spec])
+        
+        # Add network mode
+        if state_info.get('network_mode') and state_info['network_mode'] != 'bridge':
+            run_cmd.extend(["--network", state_info['network_mode']])
+            
+        # Add resource limits
+        if state_info.get('memory'):
+            run_cmd.extend(["--memory", state_info['memory']])
+        if state_info.get('cpus'):
+            run_cmd.extend(["--cpus", state_info['cpus']])
+        
+        # Add image
+        run_cmd.append(state_info['image'])
+        
+        # Add command and args
+        if state_info.get('command'):
+            run_cmd.append(state_info['command'])
+        
+        if state_info.get('args'):
+            run_cmd.extend(state_info['args'])
+        
+        # Execute the run command
+        result = self._run_command(run_cmd)
+        
+        if result.returncode != 0:
+            raise ServinError(f"Failed to start container: {result.stderr}")
+        
+        # Get the new container ID from the output
+        new_container_id = result.stdout.strip()
+        
+        # Find the new container by name to get complete info
+        new_container = None
+        try:
+            if state_info.get('name'):
+                new_container = self.get_container(state_info['name'])
+            else:
+                new_container = self.get_container(new_container_id)
+        except ServinError:
+            # If we can't find it immediately, return basic info
+            new_container = {
+                'id': new_container_id,
+                'name': state_info.get('name', ''),
+                'status': 'created'
             }
-            
-        except Exception as e:
-            raise ServinError(f"Failed to start container: {e}")
+        
+        return {
+            'success': True,
+            'old_container_id': container_id,
+            'new_container': new_container,
+            'message': f'Container recreated successfully'
+        }
     
     def _get_container_state(self, container_id: str) -> Optional[Dict[str, Any]]:
         """
@@ -392,9 +471,11 @@ class ServinClient:
             import os
             import os.path
             
-            # Get the state directory path
+            # Get the state directory path; Linux keeps it under /var/lib
             home_dir = os.path.expanduser("~")
             state_dir = os.path.join(home_dir, ".servin", "containers")
+            if platform.system() == "Linux":
+                state_dir = "/var/lib/servin/containers"
             
             if not os.path.exists(state_dir):
                 return None
@@ -432,16 +513,11 @@ class ServinClient:
         Returns:
             True if successful
         """
-        try:
-            result = self._run_command(["stop", container_id])
-            
-            if result.returncode != 0:
-                raise ServinError(f"Failed to stop container: {result.stderr}")
-            
-            return True
-            
-        except Exception as e:
-            raise ServinError(f"Failed to stop container: {e}")
+        result = self._run_command(["stop", container_id])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to stop container: {self._error_message(result.stderr)}")
+        
+        return True
     
     def restart_container(self, container_id: str) -> bool:
         """
@@ -472,70 +548,79 @@ class ServinClient:
         Returns:
             True if successful
         """
-        try:
-            args = ["remove", container_id]
-            if force:
-                args.insert(1, "--force")
-            
-            result = self._run_command(args)
-            
-            if result.returncode != 0:
-                raise ServinError(f"Failed to remove container: {result.stderr}")
-            
-            return True
+        args = ["remove", container_id]
+        if force:
+            args.insert(1, "--force")
+        
+        result = self._run_command(args)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to remove container: {self._error_message(result.stderr)}")
+        
+        return True
+    
+    def run_args(self, image: str, command: str = None, **kwargs) -> List[str]:
+        """
+        Build the arguments of the `servin run` command that creates a
+        container in the background
+        
+        Args:
+            image: Image name
+            command: Command to run, split into arguments like a shell would
+            **kwargs: Additional options: name, hostname, workdir, ports
+                ("[HOST:]CONTAINER[/PROTOCOL]"), volumes ("SOURCE:TARGET[:ro]"),
+                env ("KEY=VALUE"), network, restart, memory and cpus
             
-        except Exception as e:
-            raise ServinError(f"Failed to remove container: {e}")
+        Returns:
+            The arguments, starting with "run"
+        """
+        if not image:
+            raise ServinError("An image is required")
+        command_args = shlex.split(command or "")
+        if not command_args:
+            raise ServinError("A command is required")
+        
+        args = ["run", "--detach"]
+        for option in ("name", "hostname", "workdir", "network", "restart", "memory", "cpus"):
+            if kwargs.get(option):
+                args.extend([f"--{option}", str(kwargs[option])])
+        for port in kwargs.get("ports") or []:
+            args.extend(["--publish", port])
+        for volume in kwargs.get("volumes") or []:
+            args.extend(["--volume", volume])
+        for env_var in kwargs.get("env") or []:
+            args.extend(["--env", env_var])
+        
+        return args + [image] + command_args
+    
+    def run_command_preview(self, image: str, command: str = None, **kwargs) -> str:
+        """
+        Get the `servin run` command line that run_container would use, as
+        it would be typed in a shell
+        """
+        args = self.run_args(image, command, **kwargs)
+        if self.namespace:
+            args = ["--namespace", self.namespace] + args
+        return shlex.join(["servin"] + args)
     
     def run_container(self, image: str, command: str = None, **kwargs) -> str:
         """
-        Run a new container
+        Run a new container in the background
         
         Args:
             image: Image name
-            command: Command to run (optional)
-            **kwargs: Additional options (name, ports, volumes, etc.)
+            command: Command to run (required by servin run)
+            **kwargs: Additional options, see run_args
             
         Returns:
             Container ID
         """
-        try:
-            args = ["run"]
-            
-            # Add optional parameters
-            if 'name' in kwargs:
-                args.extend(["--name", kwargs['name']])
-            
-            if 'ports' in kwargs:
-                for port in kwargs['ports']:
-                    args.extend(["-p", port])
-            
-            if 'volumes' in kwargs:
-                for volume in kwargs['volumes']:
-                    args.extend(["--volume", volume])
-            
-            if 'env' in kwargs:
-                for env_var in kwargs['env']:
-                    args.extend(["--env", env_var])
-            
-            # Add image
-            args.append(image)
-            
-            # Add command if provided
-            if command:
-                args.append(command)
-            
-            result = self._run_command(args)
-            
-            if result.returncode != 0:
-                raise ServinError(f"Failed to run container: {result.stderr}")
-            
-            # Extract container ID from output (this is a simplified approach)
-            # In a real implementation, you'd parse the actual output
-            return f"servin-container-{int(time.time())}"
-            
-        except Exception as e:
-            raise ServinError(f"Failed to run container: {e}")
+        result = self._run_command(self.run_args(image, command, **kwargs))
+        if result.returncode != 0:
+            raise ServinError(f"Failed to run container: {self._error_message(result.stderr)}")
+        
+        # The ID is the last line servin prints when detaching
+        lines = result.stdout.strip().splitlines()
+        return lines[-1].strip() if lines else ""
     
     # Image Management Methods
     
@@ -544,55 +629,35 @@ class ServinClient:
         List images
         
         Returns:
-            List of image dictionaries
+            List of image dictionaries, including when a container last used
+            each image ('last_used', None if never) and how many use it now
         """
         try:
-            result = self._run_command(["image", "ls"])
+            result = self._run_command(["image", "ls", "--format", "json"])
             
             if result.returncode != 0:
                 raise ServinError(f"Failed to list images: {result.stderr}")
             
             images = []
-            lines = result.stdout.strip().split('\n')
-            
-            # Skip header and empty lines
-            data_lines = [line for line in lines if line and not line.startswith('REPOSITORY')]
-            
-            for line in data_lines:
-                if line.strip() and not line.startswith('(No images found)'):
-                    image = self._parse_image_line(line)
-                    if image:
-                        images.append(image)
+            for entry in json.loads(result.stdout or "[]"):
+                images.append({
+                    'id': entry['id'][:12],
+                    'repository': entry['repository'],
+                    'tag': entry['tag'],
+                    'created': entry['created'],
+                    'size': entry['size'],
+                    'virtual_size': entry['size'],
+                    'last_used': entry.get('last_used'),
+                    'containers': entry.get('containers', 0)
+                })
             
             return images
             
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse image list: {e}")
         except Exception as e:
             raise ServinError(f"Error listing images: {e}")
     
-    def _parse_image_line(self, line: str) -> Optional[Dict[str, Any]]:
-        """Parse an image line from image ls output"""
-        try:
-            parts = line.split()
-            if len(parts) < 4:
-                return None
-            
-            repository = parts[0]
-            tag = parts[1]
-            image_id = parts[2]
-            created = parts[3]
-            size = parts[4] if len(parts) > 4 else "unknown"
-            
-            return {
-                'id': image_id,
-                'repository': repository,
-                'tag': tag,
-                'created': created,
-                'size': self._parse_size(size),
-                'virtual_size': self._parse_size(size)
-            }
-        except Exception:
-            return None
-    
     def _parse_size(self, size_str: str) -> int:
         """Parse size string to bytes"""
         try:
@@ -607,45 +672,182 @@ class ServinClient:
         except:
             return 0
     
-    def pull_image(self, image_name: str) -> bool:
+    def inspect_image(self, image_ref: str) -> Dict[str, Any]:
         """
-        Pull an image (placeholder - servin uses import)
+        Get the details of an image
         
         Args:
-            image_name: Image name to pull
+            image_ref: Image ID or name
+            
+        Returns:
+            Image dictionary with its tags, layers, config, build history and
+            the containers created from it
+        """
+        result = self._run_command(["image", "inspect", "--format", image_ref])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to inspect image: {self._error_message(result.stderr)}")
+        
+        try:
+            return json.loads(result.stdout)
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse image details: {e}")
+    
+    def tag_image(self, source: str, target: str) -> bool:
+        """
+        Tag an image
+        
+        Args:
+            source: Image ID or name
+            target: New name of the image
             
         Returns:
             True if successful
         """
-        # Servin doesn't have a pull command like Docker
-        # It uses import for loading images from tarballs
-        raise ServinError("Servin doesn't support pulling images from registries. Use 'import' to load images from tarballs.")
+        result = self._run_command(["image", "tag", source, target])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to tag image: {self._error_message(result.stderr)}")
+        return True
     
-    def remove_image(self, image_id: str, force: bool = False) -> bool:
+    def push_image(self, image_name: str) -> bool:
         """
-        Remove an image
+        Push an image to the registry in its name
         
         Args:
-            image_id: Image ID or name
-            force: Force removal
+            image_name: Image name, including the registry host
             
         Returns:
             True if successful
         """
-        try:
-            args = ["image", "rm", image_id]
-            if force:
-                args.insert(2, "--force")
+        # Uploads take as long as the image and connection need
+        result = self._run_command(["image", "push", image_name], timeout=None)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to push image: {self._error_message(result.stderr)}")
+        return True
+    
+    def save_command_line(self, image_ref: str) -> List[str]:
+        """
+        Build the command line that writes an image to its stdout as a
+        docker-archive tarball, for callers that stream the archive
+        
+        Args:
+            image_ref: Image ID or name
             
-            result = self._run_command(args)
+        Returns:
+            The servin binary and its arguments
+        """
+        return self._command_line(["save", image_ref])
+
+    def build_command_line(self, context: str, buildfile: Optional[str] = None,
+                           tag: Optional[str] = None,
+                           build_args: Optional[Dict[str, str]] = None,
+                           no_cache: bool = False) -> List[str]:
+        """
+        Build the command line that builds an image, for callers that stream
+        its output; each step starts with a "Step N/TOTAL : " line
+
+        Args:
+            context: Build context folder
+            buildfile: Buildfile or Dockerfile name in the context; servin
+                picks one when None
+            tag: Name of the built image
+            build_args: Build-time variables
+            no_cache: Whether to run every step instead of reusing cached ones
+
+        Returns:
+            The servin binary and its arguments
+        """
+        args = ["build"]
+        if buildfile:
+            args += ["-f", buildfile]
+        if tag:
+            args += ["-t", tag]
+        for key, value in (build_args or {}).items():
+            args += ["--build-arg", f"{key}={value}"]
+        if no_cache:
+            args.append("--no-cache")
+        args.append(context)
+        return self._command_line(args)
+
+    def pull_command_line(self, image_name: str, platform: Optional[str] = None) -> List[str]:
+        """
+        Build the command line that pulls an image, for callers that stream
+        its output; each layer starts with a "Downloading layer N/TOTAL" or
+        "Layer N/TOTAL ... already exists" line
+        
+        Args:
+            image_name: Image reference, such as alpine:3.20 or ghcr.io/user/app
+            platform: OS/ARCH[/VARIANT] to pull from a multi-platform image;
+                the host's when None
             
-            if result.returncode != 0:
-                raise ServinError(f"Failed to remove image: {result.stderr}")
+        Returns:
+            The servin binary and its arguments
+        """
+        args = ["pull"]
+        if platform:
+            args += ["--platform", platform]
+        args.append(image_name)
+        return self._command_line(args)
+    
+    def remove_image(self, image_id: str) -> bool:
+        """
+        Remove an image
+        
+        Args:
+            image_id: Image ID or name
             
-            return True
+        Returns:
+            True if successful
+        """
+        result = self._run_command(["image", "rm", image_id])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to remove image: {self._error_message(result.stderr)}")
+        
+        # image rm reports an image it could not remove on stdout and
+        # carries on with the rest, so it still exits 0
+        prefix = f"Error removing image {image_id}: "
+        for line in result.stdout.splitlines():
+            if line.startswith(prefix):
+                raise ServinError(f"Failed to remove image: {line[len(prefix):]}")
+        
+        return True
+    
+    def prune_images(self, all_images: bool = False, dry_run: bool = False) -> Dict[str, Any]:
+        """
+        Remove unused images
+        
+        Args:
+            all_images: Remove every image no container was created from,
+                not just untagged ones
+            dry_run: Only report what would be removed
             
-        except Exception as e:
-            raise ServinError(f"Failed to remove image: {e}")
+        Returns:
+            Dictionary with the removed images ('images', each with its 'id'
+            and 'tags') and the space freed ('space')
+        """
+        args = ["image", "prune", "--force"]
+        if all_images:
+            args.append("--all")
+        if dry_run:
+            args.append("--dry-run")
+        
+        result = self._run_command(args, timeout=None)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to prune images: {self._error_message(result.stderr)}")
+        
+        # Each image's tags are listed before the line with its ID
+        images = []
+        tags = []
+        space = None
+        for line in result.stdout.splitlines():
+            line = line.strip()
+            if line.startswith("untagged: "):
+                tags.append(line[len("untagged: "):])
+            elif line.startswith("deleted: "):
+                images.append({'id': line[len("deleted: "):].replace("sha256:", "", 1), 'tags': tags})
+                tags = []
+            elif line.startswith("Total reclaim"):
+                space = line.split(":", 1)[1].strip()
+        return {'images': images, 'space': space}
     
     def import_image(self, tarball_path: str, image_name: str) -> bool:
         """
@@ -669,59 +871,106 @@ class ServinClient:
         except Exception as e:
             raise ServinError(f"Failed to import image: {e}")
     
-    # Volume Management Methods
-    
-    def list_volumes(self) -> List[Dict[str, Any]]:
+    def save_image(self, image_names: List[str], output_path: str, fmt: str = "docker-archive") -> bool:
         """
-        List volumes
+        Save images to an archive
         
+        Args:
+            image_names: Images to save
+            output_path: Archive file (or directory for the oci format)
+            fmt: docker-archive, oci-archive or oci
+            
         Returns:
-            List of volume dictionaries
+            True if successful
         """
         try:
-            result = self._run_command(["volume", "ls"])
+            result = self._run_command(["save", "--format", fmt, "-o", output_path] + list(image_names))
             
             if result.returncode != 0:
-                raise ServinError(f"Failed to list volumes: {result.stderr}")
+                raise ServinError(f"Failed to save image: {result.stderr}")
             
-            volumes = []
-            lines = result.stdout.strip().split('\n')
+            return True
             
-            # Skip header and empty lines
-            data_lines = [line for line in lines if line and not line.startswith('DRIVER')]
+        except Exception as e:
+            raise ServinError(f"Failed to save image: {e}")
+    
+    def load_image(self, input_path: str) -> List[str]:
+        """
+        Load every image in an archive or OCI layout
+        
+        Args:
+            input_path: Archive file or OCI layout directory
+            
+        Returns:
+            Names of the loaded images
+        """
+        try:
+            # Large archives take longer than the default timeout
+            result = self._run_command(["load", "-i", input_path], timeout=None)
             
-            for line in data_lines:
-                if line.strip() and not line.startswith('(No volumes found)'):
-                    volume = self._parse_volume_line(line)
-                    if volume:
-                        volumes.append(volume)
+            if result.returncode != 0:
+                raise ServinError(f"Failed to load image: {result.stderr}")
             
-            return volumes
+            return [line.split(":", 1)[1].strip() for line in result.stdout.splitlines()
+                    if line.startswith("Loaded image")]
             
         except Exception as e:
-            raise ServinError(f"Error listing volumes: {e}")
+            raise ServinError(f"Failed to load image: {e}")
+    
+    # Volume Management Methods
     
-    def _parse_volume_line(self, line: str) -> Optional[Dict[str, Any]]:
-        """Parse a volume line from volume ls output"""
+    def list_networks(self) -> List[Dict[str, Any]]:
+        """
+        List networks, the default bridge network first
+        
+        Returns:
+            List of network dictionaries with name, driver and subnet
+        """
+        result = self._run_command(["network", "ls", "--format", "json"])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to list networks: {self._error_message(result.stderr)}")
+        
         try:
-            parts = line.split()
-            if len(parts) < 3:
-                return None
-            
-            driver = parts[0]
-            volume_name = parts[1]
-            mount_point = parts[2] if len(parts) > 2 else "/var/lib/servin/volumes/" + volume_name
-            created = parts[3] if len(parts) > 3 else datetime.now().isoformat()
+            return json.loads(result.stdout or "[]")
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse network list: {e}")
+    
+    def list_volumes(self) -> List[Dict[str, Any]]:
+        """
+        List volumes
+        
+        Returns:
+            List of volume dictionaries, including how many containers
+            mount each volume
+        """
+        result = self._run_command(["volume", "ls", "--format", "json"])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to list volumes: {self._error_message(result.stderr)}")
+        
+        try:
+            return json.loads(result.stdout or "[]")
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse volume list: {e}")
+    
+    def inspect_volume(self, volume_name: str) -> Dict[str, Any]:
+        """
+        Get the details of a volume
+        
+        Args:
+            volume_name: Volume name
             
-            return {
-                'name': volume_name,
-                'driver': driver,
-                'mountpoint': mount_point,
-                'created': created,
-                'scope': 'local'
-            }
-        except Exception:
-            return None
+        Returns:
+            Volume dictionary with its options, status, the space its data
+            takes ('size') and the containers that mount it
+        """
+        result = self._run_command(["volume", "inspect", "--format", volume_name])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to inspect volume: {self._error_message(result.stderr)}")
+        
+        try:
+            return json.loads(result.stdout)[0]
+        except (json.JSONDecodeError, IndexError) as e:
+            raise ServinError(f"Failed to parse volume details: {e}")
     
     def create_volume(self, name: str, driver: str = "local") -> bool:
         """
@@ -761,23 +1010,222 @@ class ServinClient:
         Returns:
             True if successful
         """
-        try:
-            args = ["volume", "rm"]
-            if force:
-                args.append("--force")
-            args.append(volume_name)
+        args = ["volume", "rm"]
+        if force:
+            args.append("--force")
+        args.append(volume_name)
+        
+        result = self._run_command(args)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to remove volume: {self._error_message(result.stderr)}")
+        
+        return True
+    
+    def prune_volumes(self, dry_run: bool = False) -> Dict[str, Any]:
+        """
+        Remove the volumes no container uses
+        
+        Args:
+            dry_run: Only report what would be removed
             
-            result = self._run_command(args)
+        Returns:
+            Dictionary with the names of the removed volumes ('volumes') and
+            the space freed ('space')
+        """
+        args = ["volume", "prune", "--force"]
+        if dry_run:
+            args.append("--dry-run")
+        
+        result = self._run_command(args, timeout=None)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to prune volumes: {self._error_message(result.stderr)}")
+        
+        # The volumes are listed indented under a heading
+        volumes = []
+        space = None
+        for line in result.stdout.splitlines():
+            if line.startswith("  "):
+                volumes.append(line.strip())
+            elif line.startswith("Total reclaim"):
+                space = line.split(":", 1)[1].strip()
+        return {'volumes': volumes, 'space': space}
+    
+    # System Information Methods
+    
+    def get_default_registry(self) -> str:
+        """
+        Get the registry push and pull use without one
+        
+        Returns:
+            The registry, or an empty string for the local registry
+        """
+        result = self._run_command(["registry", "default"])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to get the default registry: {self._error_message(result.stderr)}")
+        
+        registry = result.stdout.strip()
+        return '' if registry.startswith('No default registry') else registry
+    
+    def set_default_registry(self, registry: str) -> bool:
+        """
+        Set the registry push and pull use without one
+        
+        Args:
+            registry: HOST[:PORT], or an empty string for the local registry
+        """
+        args = ["registry", "default", registry] if registry else ["registry", "default", "--unset"]
+        result = self._run_command(args)
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        return True
+    
+    def list_registries(self) -> List[Dict[str, Any]]:
+        """
+        List the local registry and the remote registries added by name
+        
+        Returns:
+            List of registry dictionaries with name, url, type, status and
+            whether credentials are stored for it
+        """
+        result = self._run_command(["registry", "list", "--format", "json"])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to list registries: {self._error_message(result.stderr)}")
+        
+        try:
+            return json.loads(result.stdout or "[]")
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse registry list: {e}")
+    
+    def add_registry(self, name: str, registry: str) -> bool:
+        """
+        Add a remote registry under a name
+        
+        Args:
+            name: Name to list the registry under
+            registry: HOST[:PORT]
+        """
+        result = self._run_command(["registry", "add", name, registry])
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        return True
+    
+    def remove_registry(self, name: str) -> bool:
+        """
+        Remove a remote registry added by name; its credentials are kept
+        
+        Args:
+            name: Name the registry was added under
+        """
+        result = self._run_command(["registry", "remove", name])
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        return True
+    
+    def registry_login(self, registry: str, username: str, password: str) -> bool:
+        """
+        Check credentials against a registry and store them
+        
+        Args:
+            registry: HOST[:PORT]
+            username: User name
+            password: Password or access token, passed on stdin
+        """
+        result = self._run_command(["registry", "login", registry, "-u", username, "--password-stdin"],
+                                   input=password + "\n")
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        return True
+    
+    def registry_logout(self, registry: str) -> bool:
+        """
+        Remove the credentials stored for a registry
+        
+        Args:
+            registry: HOST[:PORT]
+        """
+        result = self._run_command(["registry", "logout", registry])
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        return True
+    
+    def registry_catalog(self, registry: str, limit: int = 0) -> List[str]:
+        """
+        List the repositories of a registry through its catalog
+        
+        Args:
+            registry: HOST[:PORT]
+            limit: Most repositories to list, 0 for all
             
-            if result.returncode != 0:
-                raise ServinError(f"Failed to remove volume: {result.stderr}")
+        Returns:
+            Repository names, without the registry
+        """
+        result = self._run_command(["registry", "catalog", registry, "-n", str(limit), "--format", "json"])
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        
+        try:
+            return json.loads(result.stdout or "[]")
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse repository list: {e}")
+    
+    def registry_tags(self, repository: str) -> List[str]:
+        """
+        List the tags of a repository in a registry
+        
+        Args:
+            repository: Repository with its registry, such as ghcr.io/user/app
             
-            return True
+        Returns:
+            Tags sorted by name
+        """
+        result = self._run_command(["registry", "tags", repository, "--format", "json"])
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        
+        try:
+            return json.loads(result.stdout or "[]")
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse tag list: {e}")
+    
+    def registry_manifest(self, image_name: str, platform: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Describe an image in a registry from its manifest, without pulling it
+        
+        Args:
+            image_name: Image reference with its registry
+            platform: OS/ARCH[/VARIANT] to describe from a multi-platform
+                image; the host's when None
             
-        except Exception as e:
-            raise ServinError(f"Failed to remove volume: {e}")
+        Returns:
+            Manifest dictionary with digest, platforms, size, layers and config
+        """
+        args = ["registry", "manifest", image_name, "--format", "json"]
+        if platform:
+            args += ["--platform", platform]
+        result = self._run_command(args)
+        if result.returncode != 0:
+            raise ServinError(self._error_message(result.stderr))
+        
+        try:
+            return json.loads(result.stdout)
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse manifest: {e}")
     
-    # System Information Methods
+    def list_jobs(self) -> List[Dict[str, Any]]:
+        """
+        List the daemon's background jobs, such as pre-pull list image pulls
+        
+        Returns:
+            List of job dictionaries with type, target and status
+        """
+        result = self._run_command(["jobs", "ls", "--format", "json"])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to list jobs: {self._error_message(result.stderr)}")
+        
+        try:
+            return json.loads(result.stdout or "[]")
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse job list: {e}")
     
     def info(self) -> Dict[str, Any]:
         """
@@ -823,28 +1271,28 @@ class ServinClient:
         try:
             # Get basic container info
             container = self.get_container(container_id)
+            details = self._inspect(container['id'])
             
-            # Add additional details that would be available from inspect
+            # Add the details servin inspect reports
             container.update({
                 'config': {
                     'image': container.get('image', ''),
                     'cmd': container.get('command', '').split() if container.get('command') else [],
-                    'env': [],  # Will be populated by get_environment
+                    'env': details.get('Env') or [],
                     'working_dir': '/',
                     'hostname': container_id[:12]
                 },
                 'network_settings': {
-                    'ip_address': '172.17.0.2',  # Mock IP
-                    'gateway': '172.17.0.1',
-                    'mac_address': '02:42:ac:11:00:02',
-                    'ports': self._parse_ports(container.get('ports', ''))
+                    'ip_address': container.get('ip_address', ''),
+                    'ip6_address': container.get('ip6_address', ''),
+                    'ports': container.get('ports', [])
                 },
-                'mounts': [],  # Will be populated from volume info
+                'mounts': self._mounts(details),
                 'state': {
                     'status': container.get('status', 'unknown'),
                     'running': container.get('status') == 'running',
-                    'pid': 0,
-                    'exit_code': 0 if container.get('status') == 'running' else 1,
+                    'pid': details.get('PID', 0),
+                    'exit_code': details.get('ExitCode', 0),
                     'started_at': container.get('created', ''),
                     'finished_at': '' if container.get('status') == 'running' else container.get('created', '')
                 }
@@ -855,38 +1303,48 @@ class ServinClient:
         except Exception as e:
             raise ServinError(f"Failed to inspect container: {e}")
     
-    def get_logs(self, container_id: str, follow: bool = False, tail: int = 100) -> str:
+    def get_logs(self, container_id: str, follow: bool = False, tail: int = 100, timestamps: bool = False) -> str:
         """
         Get container logs
         
         Args:
             container_id: Container ID or name
             follow: Follow log output (not implemented for sync calls)
-            tail: Number of lines to return from end of logs
+            tail: Number of lines to return from end of logs, 0 for all
+            timestamps: Prefix each line with its time and stream
             
         Returns:
             Container logs as string
         """
-        try:
-            args = ["logs", container_id]
-            if tail > 0:
-                args.extend(["--tail", str(tail)])
-            
-            result = self._run_command(args)
-            
-            if result.returncode != 0:
-                if "no such container" in result.stderr.lower():
-                    raise ServinError(f"Container {container_id} not found")
-                # For containers without logs, return a helpful message instead of error
-                if "no logs available" in result.stderr.lower() or not result.stdout.strip():
-                    return f"Container {container_id[:12]} has no logs yet.\nContainer status: created (limited containerization on macOS)"
-                raise ServinError(f"Failed to get logs: {result.stderr}")
-            
-            return result.stdout if result.stdout.strip() else f"Container {container_id[:12]} is running but has no output yet."
+        args = ["logs", container_id]
+        if tail > 0:
+            args.extend(["--tail", str(tail)])
+        if timestamps:
+            args.append("--timestamps")
+        
+        result = self._run_command(args)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to get logs: {self._error_message(result.stderr)}")
+        
+        return result.stdout
+    
+    def logs_command_line(self, container_id: str, tail: int = 1000, follow: bool = True) -> List[str]:
+        """
+        Build the command line that prints a container's logs as JSON lines,
+        for callers that read its output as it is written
+        
+        Args:
+            container_id: Container ID or name
+            tail: Number of lines to start from, 0 for all
+            follow: Keep printing new lines until the container stops
             
-        except Exception as e:
-            # Fallback message for any other errors
-            return f"Container {container_id[:12]} logs unavailable.\nReason: {str(e)}\nNote: Running on macOS with limited containerization support."
+        Returns:
+            The servin binary and its arguments
+        """
+        args = ["logs", "--format", "json", "--tail", str(tail) if tail > 0 else "all"]
+        if follow:
+            args.append("--follow")
+        return self._command_line(args + [container_id])
     
     def list_files(self, container_id: str, path: str = '/') -> List[Dict[str, Any]]:
         """
@@ -1115,6 +1573,215 @@ class ServinClient:
             
         except Exception as e:
             raise ServinError(f"Failed to execute command: {e}")
+
+    def list_directory(self, container_id: str, path: str = '/', offset: int = 0, limit: int = 0) -> Dict[str, Any]:
+        """
+        List a page of a directory in a container's filesystem
+
+        Args:
+            container_id: Container ID or name
+            path: Directory to list
+            offset: Number of entries to skip
+            limit: Maximum number of entries to return, 0 for all
+
+        Returns:
+            Dictionary with the path, the total number of entries, the offset
+            and the entries (name, type, size, mode, permissions, modified),
+            directories first
+        """
+        args = ["fs-ls", "--all", "--format", "json", "--offset", str(offset), "--limit", str(limit), container_id, path]
+        result = self._run_command(args)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to list {path}: {self._error_message(result.stderr)}")
+
+        try:
+            return json.loads(result.stdout)
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse directory listing: {e}")
+
+    def read_file(self, container_id: str, path: str) -> bytes:
+        """
+        Read a file from a container
+
+        Args:
+            container_id: Container ID or name
+            path: File inside the container
+
+        Returns:
+            The file contents
+        """
+        result = self._run_command(["cat", container_id, path], text=False)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to read {path}: {self._error_message(result.stderr)}")
+        return result.stdout
+
+    def archive_from_container(self, container_id: str, path: str) -> bytes:
+        """
+        Get a file or directory from a container as a tar archive
+
+        Args:
+            container_id: Container ID or name
+            path: File or directory inside the container
+
+        Returns:
+            The tar archive, with the entries under the base name of path
+        """
+        result = self._run_command(["cp", f"{container_id}:{path}", "-"], text=False)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to archive {path}: {self._error_message(result.stderr)}")
+        return result.stdout
+
+    def remove_path(self, container_id: str, path: str) -> bool:
+        """
+        Remove a file or directory, with its contents, from a container
+
+        Args:
+            container_id: Container ID or name
+            path: File or directory inside the container
+
+        Returns:
+            True if successful
+        """
+        result = self._run_command(["rm", "--recursive", container_id, path])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to remove {path}: {self._error_message(result.stderr)}")
+        return True
+
+    def make_directory(self, container_id: str, path: str) -> bool:
+        """
+        Create a directory, and any missing parents, in a container
+
+        Args:
+            container_id: Container ID or name
+            path: Directory to create
+
+        Returns:
+            True if successful
+        """
+        result = self._run_command(["mkdir", "--parents", container_id, path])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to create {path}: {self._error_message(result.stderr)}")
+        return True
+
+    def chmod_path(self, container_id: str, path: str, mode: str) -> bool:
+        """
+        Change the permissions of a file or directory in a container
+
+        Args:
+            container_id: Container ID or name
+            path: File or directory inside the container
+            mode: Octal permissions such as "644"
+
+        Returns:
+            True if successful
+        """
+        result = self._run_command(["chmod", container_id, mode, path])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to change the mode of {path}: {self._error_message(result.stderr)}")
+        return True
+
+    def exec_command_line(self, container_id: str, command: List[str], tty: bool = True) -> List[str]:
+        """
+        Build the command line of an interactive exec session, for callers
+        that run it themselves attached to a terminal
+        
+        Args:
+            container_id: Container ID or name
+            command: Command and arguments to run in the container
+            tty: Whether to allocate a pseudo-TTY in the container
+            
+        Returns:
+            The servin binary and its arguments
+        """
+        flags = ["-it"] if tty else ["-i"]
+        return self._command_line(["exec"] + flags + [container_id] + command)
+
+    def copy_to_container(self, container_id: str, host_path: str, container_path: str) -> bool:
+        """
+        Copy a file or directory from the host into a container
+
+        Args:
+            container_id: Container ID or name
+            host_path: File or directory on the host; a directory's contents
+                are copied into container_path
+            container_path: Destination path inside the container
+
+        Returns:
+            True if successful
+        """
+        try:
+            # servin cp copies a directory itself into the destination, so
+            # a directory's entries are copied one by one
+            if os.path.isdir(host_path):
+                sources = [os.path.join(host_path, name) for name in sorted(os.listdir(host_path))]
+                dest = container_path.rstrip('/') + '/'
+            else:
+                sources = [host_path]
+                dest = container_path
+
+            for source in sources:
+                result = self._run_command(["cp", source, f"{container_id}:{dest}"])
+                if result.returncode != 0:
+                    raise ServinError(self._error_message(result.stderr))
+
+            return True
+
+        except Exception as e:
+            raise ServinError(f"Failed to copy to container: {e}")
+
+    def export_stats(self, container_id: str, since: Optional[str] = None, fmt: str = "json") -> str:
+        """
+        Export the resource usage history retained for a container
+        
+        Args:
+            container_id: Container ID or name
+            since: Only include samples since a timestamp or relative time (e.g. "30m")
+            fmt: Output format, "csv" or "json"
+            
+        Returns:
+            The exported history as CSV or JSON text
+        """
+        args = ["stats", "export", container_id, "--format", fmt]
+        if since:
+            args.extend(["--since", since])
+        
+        result = self._run_command(args)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to export stats: {result.stderr.strip()}")
+        
+        return result.stdout
+    
+    def stats_command_line(self, container_id: str, interval: int = 2) -> List[str]:
+        """
+        Build the command line that prints a container's resource usage as a
+        line of JSON every interval, for callers that read it as it is written
+        
+        Args:
+            container_id: Container ID or name
+            interval: Seconds between samples
+            
+        Returns:
+            The servin binary and its arguments
+        """
+        return self._command_line(["stats", "--format", "json", "--interval", str(interval), container_id])
+    
+    def get_stats_history(self, container_id: str, since: Optional[str] = None) -> List[Dict[str, Any]]:
+        """Get the resource usage samples retained for a container, oldest first"""
+        output = self.export_stats(container_id, since, "json")
+        try:
+            return json.loads(output) or []
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse stats history: {e}")
+    
+    def _inspect(self, container_id: str) -> Dict[str, Any]:
+        """Get a container's configuration as servin inspect reports it"""
+        result = self._run_command(["inspect", "--format", container_id])
+        if result.returncode != 0:
+            raise ServinError(f"Failed to inspect container: {self._error_message(result.stderr)}")
+        try:
+            return json.loads(result.stdout)
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse container details: {e}")
     
     def get_environment(self, container_id: str) -> List[Dict[str, str]]:
         """
@@ -1126,73 +1793,83 @@ class ServinClient:
         Returns:
             List of environment variables as key-value pairs
         """
-        try:
-            # Use exec to run env command in container
-            result = self.exec_command(container_id, "env")
-            
-            env_vars = []
-            for line in result.split('\n'):
-                line = line.strip()
-                if '=' in line:
-                    key, value = line.split('=', 1)
-                    env_vars.append({'key': key, 'value': value})
-            
-            # If no environment variables found, use fallback
-            if not env_vars:
-                return [
-                    {'key': 'PATH', 'value': '/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin'},
-                    {'key': 'HOME', 'value': '/root'},
-                    {'key': 'HOSTNAME', 'value': container_id[:12]},
-                    {'key': 'TERM', 'value': 'xterm'},
-                    {'key': 'CONTAINER_ID', 'value': container_id[:12]},
-                    {'key': 'SERVIN_MODE', 'value': 'limited-macos'}
-                ]
-            
-            return env_vars
+        env_vars = []
+        for variable in self._inspect(container_id).get('Env') or []:
+            key, _, value = variable.partition('=')
+            env_vars.append({'key': key, 'value': value})
+        return env_vars
+    
+    def get_mounts(self, container_id: str) -> List[Dict[str, Any]]:
+        """
+        Get the volumes and bind mounts of a container
+        
+        Args:
+            container_id: Container ID or name
             
-        except Exception as e:
-            # Return some common environment variables as fallback
-            return [
-                {'key': 'PATH', 'value': '/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin'},
-                {'key': 'HOME', 'value': '/root'},
-                {'key': 'HOSTNAME', 'value': container_id[:12]},
-                {'key': 'TERM', 'value': 'xterm'},
-                {'key': 'ERROR', 'value': f'Exec failed: {str(e)}'},
-                {'key': 'SERVIN_MODE', 'value': 'limited-macos'}
-            ]
+        Returns:
+            List of mounts with their type, source, destination, mode
+            ("rw" or "ro") and propagation, empty unless known
+        """
+        return self._mounts(self._inspect(container_id))
+    
+    def _mounts(self, details: Dict[str, Any]) -> List[Dict[str, Any]]:
+        return [{
+            'type': mount.get('Type', ''),
+            'source': mount.get('Source', ''),
+            'destination': mount.get('Destination', ''),
+            'mode': mount.get('Mode', ''),
+            'rw': bool(mount.get('RW')),
+            'propagation': mount.get('Propagation', '')
+        } for mount in details.get('Mounts') or []]
     
-    def _parse_ports(self, ports_str: str) -> List[Dict[str, Any]]:
+    def disk_usage(self) -> Dict[str, Any]:
         """
-        Parse port string into structured format
+        Get the disk space images, containers, volumes and the build cache
+        use, as servin system df reports it
+        
+        Returns:
+            Dictionary with 'images', 'containers', 'volumes' and
+            'build_cache', each with its 'total', 'active', 'size' and
+            'reclaimable' bytes, and the 'items' of the first three
+        """
+        # Measuring containers and volumes walks their files
+        result = self._run_command(["system", "df", "--verbose", "--format", "json"], timeout=None)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to get disk usage: {self._error_message(result.stderr)}")
+        
+        try:
+            return json.loads(result.stdout)
+        except json.JSONDecodeError as e:
+            raise ServinError(f"Failed to parse disk usage: {e}")
+    
+    def prune_build_cache(self, dry_run: bool = False) -> Dict[str, Any]:
+        """
+        Remove the build cache
         
         Args:
-            ports_str: Port string from container listing
+            dry_run: Only report what would be removed
             
         Returns:
-            List of port mappings
-        """
-        if not ports_str or ports_str == '-':
-            return []
-        
-        ports = []
-        # Parse format like "80:8080/tcp, 443:8443/tcp"
-        for port_mapping in ports_str.split(','):
-            port_mapping = port_mapping.strip()
-            if ':' in port_mapping:
-                host_port, container_part = port_mapping.split(':', 1)
-                if '/' in container_part:
-                    container_port, protocol = container_part.split('/', 1)
-                else:
-                    container_port = container_part
-                    protocol = 'tcp'
-                
-                ports.append({
-                    'container_port': container_port.strip(),
-                    'host_port': host_port.strip(),
-                    'protocol': protocol.strip()
-                })
+            Dictionary with the number of cache entries removed ('entries')
+            and the space freed ('space')
+        """
+        args = ["builder", "prune", "--force"]
+        if dry_run:
+            args.append("--dry-run")
+        
+        result = self._run_command(args, timeout=None)
+        if result.returncode != 0:
+            raise ServinError(f"Failed to prune build cache: {self._error_message(result.stderr)}")
         
-        return ports
+        entries = 0
+        space = None
+        for line in result.stdout.splitlines():
+            match = re.match(r'(?:Would delete|Deleted) (\d+) build cache entries', line)
+            if match:
+                entries = int(match.group(1))
+            elif line.startswith("Total reclaim"):
+                space = line.split(":", 1)[1].strip()
+        return {'entries': entries, 'space': space}
     
     def get_system_info(self) -> Dict[str, Any]:
         """
@@ -50,6 +50,24 @@ class APIClient {
         return await this.request(`/api/containers/${containerId}/details`);
     }
 
+    async runContainer(options) {
+        return await this.fileAction('/api/containers/run', {
+            method: 'POST',
+            body: JSON.stringify(options)
+        });
+    }
+
+    async previewRunContainer(options) {
+        return await this.fileAction('/api/containers/run/preview', {
+            method: 'POST',
+            body: JSON.stringify(options)
+        });
+    }
+
+    async getHostDirectories(path = '') {
+        return await this.fileAction(`/api/host/directories?path=${encodeURIComponent(path)}`);
+    }
+
     async startContainer(containerId) {
         return await this.request(`/api/containers/${containerId}/start`, {
             method: 'POST'
@@ -74,18 +92,155 @@ class APIClient {
         });
     }
 
+    /**
+     * Start, stop or remove several containers; the result reports each
+     * container, as some can fail while the rest succeed
+     */
+    async bulkContainers(action, ids, force = false) {
+        return await this.fileAction('/api/containers/bulk', {
+            method: 'POST',
+            body: JSON.stringify({ action, ids, force })
+        });
+    }
+
     async getContainerLogs(containerId) {
         return await this.request(`/api/containers/${containerId}/logs`);
     }
 
-    async getContainerFiles(containerId, path = '/') {
-        return await this.request(`/api/containers/${containerId}/files?path=${encodeURIComponent(path)}`);
+    async exportContainerLogs(containerId, timestamps = true) {
+        const url = `${this.baseUrl}/api/containers/${containerId}/logs/export?timestamps=${timestamps}`;
+        const response = await fetch(url);
+        if (!response.ok) {
+            const result = await response.json().catch(() => ({}));
+            throw new Error(result.error || `HTTP error! status: ${response.status}`);
+        }
+        return await response.blob();
+    }
+
+    async getContainerFiles(containerId, path = '/', offset = 0, limit = 0) {
+        return await this.fileAction(`/api/containers/${containerId}/files?path=${encodeURIComponent(path)}&offset=${offset}&limit=${limit}`);
+    }
+
+    async downloadContainerFile(containerId, path, type = 'file') {
+        const url = `${this.baseUrl}/api/containers/${containerId}/files/download?path=${encodeURIComponent(path)}&type=${type}`;
+        const response = await fetch(url);
+        if (!response.ok) {
+            const result = await response.json().catch(() => ({}));
+            throw new Error(result.error || `HTTP error! status: ${response.status}`);
+        }
+        return await response.blob();
+    }
+
+    async deleteContainerFile(containerId, path) {
+        return await this.fileAction(`/api/containers/${containerId}/files?path=${encodeURIComponent(path)}`, {
+            method: 'DELETE'
+        });
+    }
+
+    async makeContainerDirectory(containerId, path) {
+        return await this.fileAction(`/api/containers/${containerId}/files/mkdir`, {
+            method: 'POST',
+            body: JSON.stringify({ path })
+        });
+    }
+
+    async chmodContainerFile(containerId, path, mode) {
+        return await this.fileAction(`/api/containers/${containerId}/files/chmod`, {
+            method: 'POST',
+            body: JSON.stringify({ path, mode })
+        });
+    }
+
+    /**
+     * Like request(), but reports the error the server gave, which says why
+     * a file could not be listed or changed
+     */
+    async fileAction(endpoint, options) {
+        const response = await fetch(`${this.baseUrl}${endpoint}`, {
+            headers: { 'Content-Type': 'application/json' },
+            ...options
+        });
+        const result = await response.json().catch(() => ({}));
+        if (!response.ok) {
+            throw new Error(result.error || `HTTP error! status: ${response.status}`);
+        }
+        return result;
+    }
+
+    async uploadContainerFiles(containerId, path, files) {
+        // Multipart upload, so the JSON content type of request() is not used
+        const form = new FormData();
+        form.append('path', path);
+        files.forEach(({ file, name }) => form.append('files', file, name));
+
+        const response = await fetch(`${this.baseUrl}/api/containers/${containerId}/files/upload`, {
+            method: 'POST',
+            body: form
+        });
+        const result = await response.json();
+        if (!response.ok) {
+            throw new Error(result.error || `HTTP error! status: ${response.status}`);
+        }
+        return result;
+    }
+
+    /**
+     * POST a multipart form, calling onProgress with the fraction sent so
+     * far; fetch cannot report upload progress, so this uses XMLHttpRequest
+     */
+    upload(endpoint, form, onProgress) {
+        return new Promise((resolve, reject) => {
+            const xhr = new XMLHttpRequest();
+            xhr.open('POST', `${this.baseUrl}${endpoint}`);
+            xhr.upload.onprogress = (event) => {
+                if (onProgress && event.lengthComputable) onProgress(event.loaded / event.total);
+            };
+            xhr.onload = () => {
+                let result = {};
+                try {
+                    result = JSON.parse(xhr.responseText);
+                } catch (error) {
+                    // Not JSON, such as a proxy's error page
+                }
+                if (xhr.status >= 200 && xhr.status < 300) {
+                    resolve(result);
+                } else {
+                    reject(new Error(result.error || `HTTP error! status: ${xhr.status}`));
+                }
+            };
+            xhr.onerror = () => reject(new Error('Upload failed'));
+            xhr.send(form);
+        });
     }
 
     async getContainerEnvironment(containerId) {
         return await this.request(`/api/containers/${containerId}/env`);
     }
 
+    async getContainerMounts(containerId) {
+        return await this.request(`/api/containers/${containerId}/mounts`);
+    }
+
+    async recreateContainer(containerId, env) {
+        return await this.request(`/api/containers/${containerId}/recreate`, {
+            method: 'POST',
+            body: JSON.stringify({ env })
+        });
+    }
+
+    async getContainerStatsHistory(containerId, since = '30m') {
+        return await this.request(`/api/containers/${containerId}/stats/history?since=${encodeURIComponent(since)}`);
+    }
+
+    async exportContainerStats(containerId, since = '30m', format = 'csv') {
+        const url = `${this.baseUrl}/api/containers/${containerId}/stats/export?since=${encodeURIComponent(since)}&format=${format}`;
+        const response = await fetch(url);
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        return await response.blob();
+    }
+
     /**
      * Image API endpoints
      */
@@ -93,19 +248,103 @@ class APIClient {
         return await this.request('/api/images');
     }
 
-    async pullImage(imageName) {
-        return await this.request('/api/images/pull', {
+    /**
+     * Start a pull; its output arrives as pull_output, pull_progress and
+     * pull_finished socket events
+     */
+    async pullImage(imageName, platform = '') {
+        return await this.fileAction('/api/images/pull', {
             method: 'POST',
-            body: JSON.stringify({ image: imageName })
+            body: JSON.stringify({ image: imageName, platform })
         });
     }
 
+    /**
+     * Load the images in a dropped archive; resolves to their names
+     */
+    async loadImageArchive(file, onProgress) {
+        const form = new FormData();
+        form.append('archive', file, file.name);
+        return await this.upload('/api/images/load', form, onProgress);
+    }
+
     async removeImage(imageId) {
         return await this.request(`/api/images/${imageId}/remove`, {
             method: 'DELETE'
         });
     }
 
+    async bulkImages(action, ids) {
+        return await this.fileAction('/api/images/bulk', {
+            method: 'POST',
+            body: JSON.stringify({ action, ids })
+        });
+    }
+
+    async previewImagePrune(all = false) {
+        return await this.fileAction(`/api/images/prune?all=${all}`);
+    }
+
+    async pruneImages(all = false) {
+        return await this.fileAction('/api/images/prune', {
+            method: 'POST',
+            body: JSON.stringify({ all })
+        });
+    }
+
+    async inspectImage(imageId) {
+        return await this.fileAction(`/api/images/${encodeURIComponent(imageId)}/inspect`);
+    }
+
+    async tagImage(imageId, tag) {
+        return await this.fileAction(`/api/images/${encodeURIComponent(imageId)}/tag`, {
+            method: 'POST',
+            body: JSON.stringify({ tag })
+        });
+    }
+
+    async pushImage(imageName) {
+        return await this.fileAction('/api/images/push', {
+            method: 'POST',
+            body: JSON.stringify({ image: imageName })
+        });
+    }
+
+    /**
+     * URL that downloads an image as a tarball; archives can be large, so
+     * they are saved by the browser rather than read into memory
+     */
+    imageExportUrl(imageId, name) {
+        return `${this.baseUrl}/api/images/${encodeURIComponent(imageId)}/export?name=${encodeURIComponent(name)}`;
+    }
+
+    /**
+     * Start a build; its output arrives as build_output, build_step and
+     * build_finished socket events
+     */
+    async buildImage(options) {
+        return await this.fileAction('/api/images/build', {
+            method: 'POST',
+            body: JSON.stringify(options)
+        });
+    }
+
+    /**
+     * Copy a dropped folder to the host to build from; resolves to its path
+     * and build files
+     */
+    async stageBuildContext(files, onProgress) {
+        const form = new FormData();
+        files.forEach(({ file, name }) => form.append('files', file, name));
+        return await this.upload('/api/images/build/context', form, onProgress);
+    }
+
+    async cancelBuild() {
+        return await this.fileAction('/api/images/build/cancel', {
+            method: 'POST'
+        });
+    }
+
     /**
      * Volume API endpoints
      */
@@ -113,6 +352,24 @@ class APIClient {
         return await this.request('/api/volumes');
     }
 
+    async previewVolumePrune() {
+        return await this.fileAction('/api/volumes/prune');
+    }
+
+    async pruneVolumes() {
+        return await this.fileAction('/api/volumes/prune', { method: 'POST' });
+    }
+
+    async inspectVolume(volumeName) {
+        return await this.fileAction(`/api/volumes/${encodeURIComponent(volumeName)}/inspect`);
+    }
+
+    async revealVolume(volumeName) {
+        return await this.fileAction(`/api/volumes/${encodeURIComponent(volumeName)}/reveal`, {
+            method: 'POST'
+        });
+    }
+
     async createVolume(volumeName) {
         return await this.request('/api/volumes/create', {
             method: 'POST',
@@ -120,12 +377,170 @@ class APIClient {
         });
     }
 
+    async getNetworks() {
+        return await this.request('/api/networks');
+    }
+
     async removeVolume(volumeName) {
-        return await this.request(`/api/volumes/${volumeName}/remove`, {
+        return await this.fileAction(`/api/volumes/${encodeURIComponent(volumeName)}/remove`, {
+            method: 'DELETE'
+        });
+    }
+
+    /**
+     * Namespace API endpoints
+     */
+    async getNamespaces() {
+        return await this.request('/api/namespaces');
+    }
+
+    async setNamespace(namespace) {
+        return await this.request('/api/namespaces/current', {
+            method: 'POST',
+            body: JSON.stringify({ namespace })
+        });
+    }
+
+    /**
+     * Notification API endpoints
+     */
+    async getNotificationSettings() {
+        return await this.fileAction('/api/notifications/settings');
+    }
+
+    async updateNotificationSettings(settings) {
+        return await this.fileAction('/api/notifications/settings', {
+            method: 'PUT',
+            body: JSON.stringify(settings)
+        });
+    }
+
+    /**
+     * Preferences API endpoints
+     */
+    async getPreferences() {
+        return await this.fileAction('/api/preferences');
+    }
+
+    async updatePreferences(preferences) {
+        return await this.fileAction('/api/preferences', {
+            method: 'PUT',
+            body: JSON.stringify(preferences)
+        });
+    }
+
+    async getVMResources() {
+        return await this.fileAction('/api/vm/resources');
+    }
+
+    async resizeVM(resources) {
+        return await this.fileAction('/api/vm/resize', {
+            method: 'POST',
+            body: JSON.stringify(resources)
+        });
+    }
+
+    /**
+     * VM API endpoints
+     */
+    async getVMProviders() {
+        return await this.fileAction('/api/vm/providers');
+    }
+
+    async listVMs() {
+        return await this.fileAction('/api/vm/list');
+    }
+
+    async createVM(options) {
+        return await this.fileAction('/api/vm/create', {
+            method: 'POST',
+            body: JSON.stringify(options)
+        });
+    }
+
+    async useVM(name) {
+        return await this.fileAction('/api/vm/use', {
+            method: 'POST',
+            body: JSON.stringify({ name })
+        });
+    }
+
+    async destroyVM(name) {
+        return await this.fileAction('/api/vm/destroy', {
+            method: 'POST',
+            body: JSON.stringify({ name })
+        });
+    }
+
+    async getVMDiskUsage() {
+        return await this.fileAction('/api/vm/disk');
+    }
+
+    async getVMConsoleLog(tail = 200) {
+        return await this.fileAction(`/api/vm/console?tail=${tail}`);
+    }
+
+    async openVMShell() {
+        return await this.fileAction('/api/vm/shell', { method: 'POST' });
+    }
+
+    /**
+     * Registry API endpoints
+     */
+    async getRegistries() {
+        return await this.fileAction('/api/registries');
+    }
+
+    async addRegistry(registry) {
+        return await this.fileAction('/api/registries', {
+            method: 'POST',
+            body: JSON.stringify(registry)
+        });
+    }
+
+    async removeRegistry(name) {
+        return await this.fileAction(`/api/registries/${encodeURIComponent(name)}`, {
             method: 'DELETE'
         });
     }
 
+    async registryLogout(registry) {
+        return await this.fileAction('/api/registries/logout', {
+            method: 'POST',
+            body: JSON.stringify({ registry })
+        });
+    }
+
+    async getRegistryCatalog(registry, limit = 0) {
+        return await this.fileAction(`/api/registries/catalog?registry=${encodeURIComponent(registry)}&n=${limit}`);
+    }
+
+    async getRegistryTags(repository) {
+        return await this.fileAction(`/api/registries/tags?repository=${encodeURIComponent(repository)}`);
+    }
+
+    async getRegistryManifest(imageName, platform = '') {
+        return await this.fileAction(`/api/registries/manifest?image=${encodeURIComponent(imageName)}&platform=${encodeURIComponent(platform)}`);
+    }
+
+    /**
+     * Pod API endpoints
+     */
+    async getPods() {
+        return await this.fileAction('/api/pods');
+    }
+
+    async getPodContainerLogs(containerId, tail = 200) {
+        return await this.fileAction(`/api/pods/containers/${containerId}/logs?tail=${tail}`);
+    }
+
+    async execPodContainer(containerId, command) {
+        return await this.fileAction(`/api/pods/containers/${containerId}/exec`, {
+            method: 'POST',
+            body: JSON.stringify({ command })
+        });
+    }
+
     /**
      * System API endpoints
      */
@@ -133,6 +548,22 @@ class APIClient {
         return await this.request('/api/system/info');
     }
 
+    async getDiskUsage() {
+        return await this.fileAction('/api/system/df');
+    }
+
+    async previewBuildCachePrune() {
+        return await this.fileAction('/api/system/build-cache/prune');
+    }
+
+    async pruneBuildCache() {
+        return await this.fileAction('/api/system/build-cache/prune', { method: 'POST' });
+    }
+
+    async getMode() {
+        return await this.request('/api/system/mode');
+    }
+
     async checkConnection() {
         return await this.request('/api/system/info');
     }
@@ -0,0 +1,550 @@
+/**
+ * Bulk Actions Component
+ * Adds a checkbox to each container and image row, so several can be
+ * started, stopped or removed at once, and prunes stopped containers,
+ * unused images and volumes and the build cache. Each action is confirmed in
+ * one dialog that lists what it affects.
+ */
+
+class BulkActions {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.modal = document.getElementById('bulkConfirmModal');
+        this.pending = null;
+
+        // Selected rows by key, kept across the re-renders of each refresh
+        this.lists = {
+            containers: {
+                body: document.getElementById('containersTableBody'),
+                selectAll: document.getElementById('selectAllContainers'),
+                bar: document.getElementById('containersBulkBar'),
+                count: document.getElementById('containersBulkCount'),
+                noun: 'container',
+                selected: new Map()
+            },
+            images: {
+                body: document.getElementById('imagesTableBody'),
+                selectAll: document.getElementById('selectAllImages'),
+                bar: document.getElementById('imagesBulkBar'),
+                count: document.getElementById('imagesBulkCount'),
+                noun: 'image',
+                selected: new Map()
+            }
+        };
+
+        this.setupEventListeners();
+        Object.values(this.lists).forEach(list => this.decorate(list));
+    }
+
+    setupEventListeners() {
+        Object.values(this.lists).forEach(list => {
+            if (!list.body) return;
+
+            // Rows are rendered elsewhere, so each new set gets its checkboxes here
+            new MutationObserver(() => this.decorate(list)).observe(list.body, { childList: true });
+
+            // Rows hidden by a search or filter are left as they are
+            list.selectAll?.addEventListener('change', () => {
+                this.rows(list).filter(row => row.style.display !== 'none')
+                    .forEach(row => this.select(list, row, list.selectAll.checked));
+                this.updateBar(list);
+            });
+        });
+
+        document.getElementById('clearContainersSelectionBtn')?.addEventListener('click', () => this.clear(this.lists.containers));
+        document.getElementById('clearImagesSelectionBtn')?.addEventListener('click', () => this.clear(this.lists.images));
+        document.getElementById('bulkStartBtn')?.addEventListener('click', () => this.startContainers());
+        document.getElementById('bulkStopBtn')?.addEventListener('click', () => this.stopContainers());
+        document.getElementById('bulkRemoveContainersBtn')?.addEventListener('click', () => this.removeContainers());
+        document.getElementById('pruneContainersBtn')?.addEventListener('click', () => this.pruneContainers());
+        document.getElementById('bulkRemoveImagesBtn')?.addEventListener('click', () => this.removeImages());
+        document.getElementById('pruneImagesBtn')?.addEventListener('click', () => this.pruneImages());
+
+        document.getElementById('closeBulkConfirm')?.addEventListener('click', () => this.closeConfirm());
+        document.getElementById('cancelBulkConfirm')?.addEventListener('click', () => this.closeConfirm());
+        document.getElementById('bulkConfirmBtn')?.addEventListener('click', () => this.runConfirmed());
+        document.getElementById('bulkConfirmOptionInput')?.addEventListener('change', (e) => this.changeOption(e.target.checked));
+        this.modal?.addEventListener('click', (e) => {
+            if (e.target === this.modal) this.closeConfirm();
+        });
+    }
+
+    rows(list) {
+        return [...list.body.querySelectorAll('tr[data-key]')];
+    }
+
+    /**
+     * Identify a container row by its ID, and an image row by its tag, as
+     * an image has a row for each of its tags
+     */
+    rowKey(list, row) {
+        const strong = row.querySelector('strong');
+        if (list === this.lists.containers) {
+            return { key: row.dataset.id, id: row.dataset.id, label: strong ? strong.textContent.trim() : row.dataset.id };
+        }
+
+        const repository = strong ? strong.textContent.trim() : '<none>';
+        const tag = row.cells[1] ? row.cells[1].textContent.trim() : '<none>';
+        const shortId = row.dataset.id.replace('sha256:', '').substring(0, 12);
+        const ref = repository === '<none>' || tag === '<none>' ? shortId : `${repository}:${tag}`;
+        return { key: ref, id: row.dataset.id, label: ref };
+    }
+
+    decorate(list) {
+        if (!list.body) return;
+
+        const keys = new Set();
+        list.body.querySelectorAll('tr').forEach(row => {
+            if (!row.dataset.id) {
+                // Empty and loading rows span the table, which gained a column
+                const wide = row.querySelector('td[colspan]');
+                if (wide && !row.dataset.widened) {
+                    wide.colSpan += 1;
+                    row.dataset.widened = 'true';
+                }
+                return;
+            }
+            if (!row.dataset.key) {
+                const { key, id, label } = this.rowKey(list, row);
+                row.dataset.key = key;
+                row.dataset.label = label;
+                row.dataset.objectId = id;
+
+                const cell = document.createElement('td');
+                cell.className = 'select-cell';
+                const checkbox = document.createElement('input');
+                checkbox.type = 'checkbox';
+                checkbox.title = `Select ${label}`;
+                checkbox.addEventListener('change', () => {
+                    this.select(list, row, checkbox.checked);
+                    this.updateBar(list);
+                });
+                cell.appendChild(checkbox);
+                // Ticking a box should not open the row's details
+                cell.addEventListener('click', (e) => e.stopPropagation());
+                row.insertBefore(cell, row.firstChild);
+            }
+            keys.add(row.dataset.key);
+            this.select(list, row, list.selected.has(row.dataset.key));
+        });
+
+        // Forget rows that are gone, such as removed containers
+        [...list.selected.keys()].forEach(key => {
+            if (!keys.has(key)) list.selected.delete(key);
+        });
+        this.updateBar(list);
+    }
+
+    select(list, row, selected) {
+        const checkbox = row.querySelector('.select-cell input');
+        if (checkbox) checkbox.checked = selected;
+        row.classList.toggle('selected', selected);
+        if (selected) {
+            list.selected.set(row.dataset.key, { id: row.dataset.objectId, label: row.dataset.label });
+        } else {
+            list.selected.delete(row.dataset.key);
+        }
+    }
+
+    clear(list) {
+        this.rows(list).forEach(row => this.select(list, row, false));
+        list.selected.clear();
+        this.updateBar(list);
+    }
+
+    updateBar(list) {
+        const count = list.selected.size;
+        const shown = this.rows(list).filter(row => row.style.display !== 'none');
+        const shownSelected = shown.filter(row => list.selected.has(row.dataset.key)).length;
+        if (list.bar) {
+            list.bar.style.display = count > 0 ? 'flex' : 'none';
+        }
+        if (list.count) {
+            list.count.textContent = `${count} ${list.noun}${count === 1 ? '' : 's'} selected`;
+        }
+        if (list.selectAll) {
+            list.selectAll.checked = shown.length > 0 && shownSelected === shown.length;
+            list.selectAll.indeterminate = shownSelected > 0 && shownSelected < shown.length;
+        }
+    }
+
+    /**
+     * The selected containers as the runtime now lists them, so an action
+     * only counts containers it applies to
+     */
+    async selectedContainers() {
+        const selected = this.lists.containers.selected;
+        const containers = await this.apiClient.getContainers();
+        return containers.filter(container => selected.has(container.id));
+    }
+
+    async startContainers() {
+        await this.containerAction('start', container => container.status !== 'running', {
+            title: 'Start Containers',
+            button: 'Start',
+            done: 'Started',
+            skipped: 'already running'
+        });
+    }
+
+    async stopContainers() {
+        await this.containerAction('stop', container => container.status === 'running', {
+            title: 'Stop Containers',
+            button: 'Stop',
+            done: 'Stopped',
+            skipped: 'not running'
+        });
+    }
+
+    async containerAction(action, applies, text) {
+        let containers;
+        try {
+            containers = await this.selectedContainers();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to load containers: ${error.message}`, 'error');
+            return;
+        }
+
+        const affected = containers.filter(applies);
+        const skipped = containers.length - affected.length;
+        let message = `${text.button} ${this.count(affected.length, 'container')}?`;
+        if (skipped > 0) {
+            message += ` Skipping ${this.count(skipped, 'container')} that ${skipped === 1 ? 'is' : 'are'} ${text.skipped}.`;
+        }
+
+        this.openConfirm({
+            title: text.title,
+            message,
+            items: affected.map(container => ({ label: container.name })),
+            button: text.button,
+            danger: false,
+            run: async () => {
+                const result = await this.apiClient.bulkContainers(action, affected.map(container => container.id));
+                this.report(result, affected, text.done, 'container');
+                this.finish(this.lists.containers);
+            }
+        });
+    }
+
+    async removeContainers() {
+        let containers;
+        try {
+            containers = await this.selectedContainers();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to load containers: ${error.message}`, 'error');
+            return;
+        }
+
+        // Protected containers are refused by rm, so they are not offered
+        const affected = containers.filter(container => !container.protected);
+        const protectedCount = containers.length - affected.length;
+        const running = affected.filter(container => container.status === 'running').length;
+        let message = `Remove ${this.count(affected.length, 'container')}?`;
+        if (protectedCount > 0) {
+            message += ` Skipping ${this.count(protectedCount, 'protected container')}.`;
+        }
+
+        this.openConfirm({
+            title: 'Remove Containers',
+            message,
+            items: affected.map(container => ({
+                label: container.name,
+                note: container.status === 'running' ? 'running' : ''
+            })),
+            button: 'Remove',
+            danger: true,
+            option: running > 0 ? { text: `Stop the ${this.count(running, 'running container')} first` } : null,
+            run: async (force) => {
+                // Without force, running containers are refused and reported
+                const result = await this.apiClient.bulkContainers('remove', affected.map(container => container.id), force);
+                this.report(result, affected, 'Removed', 'container');
+                this.finish(this.lists.containers);
+            }
+        });
+    }
+
+    /**
+     * Remove every container that is not running, as system prune does,
+     * keeping protected ones
+     */
+    async pruneContainers() {
+        let containers;
+        try {
+            containers = await this.apiClient.getContainers();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to load containers: ${error.message}`, 'error');
+            return;
+        }
+
+        const affected = containers.filter(container => container.status !== 'running' && !container.protected);
+        this.openConfirm({
+            title: 'Prune Containers',
+            message: affected.length > 0
+                ? `Remove ${this.count(affected.length, 'container')} that ${affected.length === 1 ? 'is' : 'are'} not running? Protected containers are kept.`
+                : 'There are no stopped containers to remove.',
+            items: affected.map(container => ({ label: container.name, note: container.status })),
+            button: 'Prune',
+            danger: true,
+            run: async () => {
+                const result = await this.apiClient.bulkContainers('remove', affected.map(container => container.id));
+                this.report(result, affected, 'Removed', 'container');
+                this.finish(this.lists.containers);
+            }
+        });
+    }
+
+    async removeImages() {
+        let images;
+        try {
+            images = await this.apiClient.getImages();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to load images: ${error.message}`, 'error');
+            return;
+        }
+
+        // Removing an image by one of its tags removes the whole image, so
+        // each image is listed, and removed, once
+        const byId = new Map();
+        this.lists.images.selected.forEach(({ id, label }) => {
+            const entry = byId.get(id) || { id, labels: [] };
+            entry.labels.push(label);
+            byId.set(id, entry);
+        });
+        const affected = [...byId.values()].map(entry => {
+            const tags = images.filter(image => image.id === entry.id && image.tag !== '<none>')
+                .map(image => `${image.repository}:${image.tag}`);
+            const used = images.find(image => image.id === entry.id && image.containers > 0);
+            return {
+                id: entry.id,
+                name: entry.labels.join(', '),
+                note: [
+                    tags.length > entry.labels.length ? `also tagged ${tags.filter(tag => !entry.labels.includes(tag)).join(', ')}` : '',
+                    used ? `used by ${this.count(used.containers, 'container')}` : ''
+                ].filter(Boolean).join('; ')
+            };
+        });
+
+        this.openConfirm({
+            title: 'Remove Images',
+            message: `Remove ${this.count(affected.length, 'image')}? An image is removed with all of its tags.`,
+            items: affected.map(image => ({ label: image.name, note: image.note })),
+            button: 'Remove',
+            danger: true,
+            run: async () => {
+                const result = await this.apiClient.bulkImages('remove', affected.map(image => image.id));
+                this.report(result, affected, 'Removed', 'image');
+                this.finish(this.lists.images);
+            }
+        });
+    }
+
+    async pruneImages() {
+        let preview;
+        try {
+            preview = await this.apiClient.previewImagePrune(false);
+        } catch (error) {
+            UIHelpers.showToast(`Failed to list unused images: ${error.message}`, 'error');
+            return;
+        }
+
+        this.openConfirm({
+            ...this.pruneText(preview, false),
+            title: 'Prune Images',
+            button: 'Prune',
+            danger: true,
+            option: {
+                text: 'Also remove tagged images no container was created from',
+                change: async (all) => this.pruneText(await this.apiClient.previewImagePrune(all), all)
+            },
+            run: async (all) => {
+                const result = await this.apiClient.pruneImages(all);
+                const count = this.count(result.images.length, 'image');
+                UIHelpers.showToast(result.space ? `Pruned ${count}, reclaiming ${result.space}` : `Pruned ${count}`, 'success');
+                this.finish(this.lists.images);
+            }
+        });
+    }
+
+    /**
+     * Remove the volumes no container in any namespace uses, keeping
+     * protected ones
+     */
+    async pruneVolumes() {
+        let preview;
+        try {
+            preview = await this.apiClient.previewVolumePrune();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to list unused volumes: ${error.message}`, 'error');
+            return;
+        }
+
+        const volumes = preview.volumes;
+        let message = 'There are no unused volumes to remove.';
+        if (volumes.length > 0) {
+            message = `Remove ${this.count(volumes.length, 'unused volume')} and the data in ${volumes.length === 1 ? 'it' : 'them'}?`;
+            if (preview.space) message += ` This frees ${preview.space}.`;
+        }
+        this.openConfirm({
+            title: 'Prune Volumes',
+            message,
+            items: volumes.map(name => ({ label: name })),
+            button: 'Prune',
+            danger: true,
+            run: async () => {
+                const result = await this.apiClient.pruneVolumes();
+                const count = this.count(result.volumes.length, 'volume');
+                UIHelpers.showToast(result.space ? `Pruned ${count}, reclaiming ${result.space}` : `Pruned ${count}`, 'success');
+                document.getElementById('refreshBtn')?.click();
+            }
+        });
+    }
+
+    async pruneBuildCache() {
+        let preview;
+        try {
+            preview = await this.apiClient.previewBuildCachePrune();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to read the build cache: ${error.message}`, 'error');
+            return;
+        }
+
+        let message = 'The build cache is empty.';
+        if (preview.entries > 0) {
+            message = 'Remove the build cache? The next build of each image runs every step again.';
+            if (preview.space) message += ` This frees ${preview.space}.`;
+        }
+        this.openConfirm({
+            title: 'Prune Build Cache',
+            message,
+            items: preview.entries > 0 ? [{ label: this.count(preview.entries, 'cached build step') }] : [],
+            button: 'Prune',
+            danger: true,
+            run: async () => {
+                const result = await this.apiClient.pruneBuildCache();
+                const count = this.count(result.entries, 'cached build step');
+                UIHelpers.showToast(result.space ? `Pruned ${count}, reclaiming ${result.space}` : `Pruned ${count}`, 'success');
+                document.getElementById('refreshBtn')?.click();
+            }
+        });
+    }
+
+    pruneText(preview, all) {
+        const images = preview.images;
+        const kind = all ? 'unused' : 'untagged';
+        let message = `There are no ${kind} images to remove.`;
+        if (images.length > 0) {
+            message = `Remove ${this.count(images.length, `${kind} image`)}?`;
+            if (preview.space) message += ` This frees ${preview.space}.`;
+        }
+        return {
+            message,
+            items: images.map(image => ({
+                label: image.tags.length > 0 ? image.tags.join(', ') : image.id.substring(0, 12)
+            }))
+        };
+    }
+
+    count(n, noun) {
+        return `${n} ${noun}${n === 1 ? '' : 's'}`;
+    }
+
+    /**
+     * Summarize a bulk action, naming the objects it failed for and why
+     */
+    report(result, objects, verb, noun) {
+        const names = new Map(objects.map(object => [object.id, object.name]));
+        const failed = result.results.filter(item => !item.success);
+        const succeeded = result.results.length - failed.length;
+
+        if (failed.length === 0) {
+            UIHelpers.showToast(`${verb} ${this.count(succeeded, noun)}`, 'success');
+            return;
+        }
+        const reasons = failed.map(item => `${names.get(item.id) || item.id}: ${item.error}`).join('; ');
+        UIHelpers.showToast(`${verb} ${succeeded} of ${this.count(result.results.length, noun)}. Failed: ${reasons}`, 'error');
+    }
+
+    finish(list) {
+        this.clear(list);
+        document.getElementById('refreshBtn')?.click();
+    }
+
+    openConfirm(config) {
+        this.pending = config;
+        document.getElementById('bulkConfirmTitle').textContent = config.title;
+
+        const button = document.getElementById('bulkConfirmBtn');
+        button.textContent = config.button;
+        button.className = `action-btn ${config.danger ? 'danger' : 'primary'}`;
+
+        const option = document.getElementById('bulkConfirmOption');
+        option.style.display = config.option ? 'flex' : 'none';
+        document.getElementById('bulkConfirmOptionInput').checked = false;
+        document.getElementById('bulkConfirmOptionText').textContent = config.option ? config.option.text : '';
+
+        this.renderConfirm(config);
+        this.modal.style.display = 'block';
+    }
+
+    renderConfirm({ message, items }) {
+        document.getElementById('bulkConfirmMessage').textContent = message;
+        document.getElementById('bulkConfirmBtn').disabled = items.length === 0;
+
+        const list = document.getElementById('bulkConfirmList');
+        list.innerHTML = '';
+        items.forEach(item => {
+            const entry = document.createElement('li');
+            entry.textContent = item.label;
+            if (item.note) {
+                const note = document.createElement('span');
+                note.className = 'bulk-confirm-note';
+                note.textContent = item.note;
+                entry.appendChild(note);
+            }
+            list.appendChild(entry);
+        });
+        list.style.display = items.length > 0 ? 'block' : 'none';
+    }
+
+    /**
+     * Some options change what the action affects, so the list is redone
+     */
+    async changeOption(checked) {
+        const config = this.pending;
+        if (!config || !config.option || !config.option.change) return;
+
+        try {
+            const text = await config.option.change(checked);
+            if (this.pending === config) {
+                Object.assign(config, text);
+                this.renderConfirm(config);
+            }
+        } catch (error) {
+            UIHelpers.showToast(error.message, 'error');
+        }
+    }
+
+    async runConfirmed() {
+        const config = this.pending;
+        if (!config) return;
+
+        const button = document.getElementById('bulkConfirmBtn');
+        button.disabled = true;
+        try {
+            await config.run(document.getElementById('bulkConfirmOptionInput').checked);
+            this.closeConfirm();
+        } catch (error) {
+            UIHelpers.showToast(`${config.title} failed: ${error.message}`, 'error');
+            button.disabled = false;
+        }
+    }
+
+    closeConfirm() {
+        this.pending = null;
+        if (this.modal) this.modal.style.display = 'none';
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.bulkActions = new BulkActions(new APIClient());
+});
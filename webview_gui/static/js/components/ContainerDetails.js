@@ -8,7 +8,7 @@ class ContainerDetails {
         this.apiClient = apiClient;
         this.socketManager = socketManager;
         this.currentContainerId = null;
-        this.activeTab = 'logs';
+        this.activeTab = 'overview';
         
         this.init();
     }
@@ -33,8 +33,27 @@ class ContainerDetails {
             });
         });
 
+        // Copy the full ID; the header only shows the short one
+        const copyIdBtn = document.getElementById('copyContainerIdBtn');
+        if (copyIdBtn) {
+            copyIdBtn.addEventListener('click', () => {
+                if (this.currentContainerId) {
+                    UIHelpers.copyToClipboard(this.currentContainerId, 'Container ID');
+                }
+            });
+        }
+
         // Container action buttons
         this.setupActionButtons();
+        this.setupEnvironmentButtons();
+    }
+
+    setupEnvironmentButtons() {
+        document.getElementById('refreshEnvBtn')?.addEventListener('click', () => this.loadEnvironment());
+        document.getElementById('exportEnvBtn')?.addEventListener('click', () => this.exportEnvironment());
+        document.getElementById('addEnvBtn')?.addEventListener('click', () => this.addEnvironmentRow());
+        document.getElementById('recreateEnvBtn')?.addEventListener('click', () => this.recreateWithEnvironment());
+        document.getElementById('refreshVolumesBtn')?.addEventListener('click', () => this.loadVolumes());
     }
 
     setupActionButtons() {
@@ -80,7 +99,7 @@ class ContainerDetails {
             
             // Setup tabs and load default content
             this.setupTabEventListeners();
-            this.switchTab('logs');
+            this.switchTab('overview');
             
         } catch (error) {
             console.error('Failed to load container details:', error);
@@ -93,6 +112,21 @@ class ContainerDetails {
         document.getElementById('containerDetails').style.display = 'none';
         document.getElementById('containersList').style.display = 'block';
         this.currentContainerId = null;
+        if (window.statsHistory) {
+            window.statsHistory.stop();
+        }
+        if (window.fileExplorer) {
+            window.fileExplorer.cleanup();
+        }
+        if (window.terminal) {
+            window.terminal.cleanup();
+        }
+        if (window.logs) {
+            window.logs.cleanup();
+        }
+        if (window.resourceCharts) {
+            window.resourceCharts.cleanup();
+        }
     }
 
     renderContainerInfo(container) {
@@ -110,13 +144,16 @@ class ContainerDetails {
 
         // Update overview tab with detailed information
         this.renderOverview(container);
+        if (window.resourceCharts) {
+            window.resourceCharts.show(container.id, container.status);
+        }
         
         // Update action buttons based on container status
         this.updateActionButtons(container.status);
     }
 
     renderOverview(container) {
-        const overviewContainer = document.getElementById('overviewTab');
+        const overviewContainer = document.getElementById('overviewInfo');
         if (!overviewContainer) return;
 
         overviewContainer.innerHTML = `
@@ -139,6 +176,7 @@ class ContainerDetails {
                         <div class="info-item">
                             <label>Status:</label>
                             <span class="status-badge status-${container.status.toLowerCase()}">${container.status}</span>
+                            ${container.health ? `<span class="health-badge health-${container.health}">${container.health}</span>` : ''}
                         </div>
                         <div class="info-item">
                             <label>Created:</label>
@@ -156,7 +194,7 @@ class ContainerDetails {
                     <div class="info-grid">
                         <div class="info-item">
                             <label>Ports:</label>
-                            <span>${container.ports || '-'}</span>
+                            <span>${UIHelpers.formatPorts(container.ports)}</span>
                         </div>
                         <div class="info-item">
                             <label>Network Mode:</label>
@@ -188,9 +226,6 @@ class ContainerDetails {
                 </div>
             </div>
         `;
-
-        // Re-setup action button listeners after rendering
-        this.setupActionButtons();
     }
 
     updateActionButtons(containerStatus) {
@@ -237,6 +272,10 @@ class ContainerDetails {
     switchTab(tabName) {
         console.log('Switching to tab:', tabName);
         this.activeTab = tabName;
+
+        if (tabName !== 'stats' && window.statsHistory) {
+            window.statsHistory.stop();
+        }
         
         // Update tab buttons
         document.querySelectorAll('.tab-btn').forEach(btn => {
@@ -298,19 +337,19 @@ class ContainerDetails {
     }
 
     async loadLogs() {
-        // Logs functionality will be handled by the Logs component
-        // For now, show a placeholder
-        const logsContent = document.getElementById('logsContent');
-        if (logsContent) {
-            logsContent.innerHTML = '<div class="loading">Loading logs...</div>';
+        if (window.logs) {
+            window.logs.loadLogs(this.currentContainerId);
         }
     }
 
     async loadFiles(path = '/') {
-        // File explorer functionality will be handled by the FileExplorer component
-        const filesContent = document.getElementById('filesContent');
-        if (filesContent) {
-            filesContent.innerHTML = '<div class="loading">Loading files...</div>';
+        // Keep the directory being browsed when coming back to the tab
+        if (window.fileExplorer) {
+            const explorer = window.fileExplorer;
+            if (explorer.currentContainerId === this.currentContainerId) {
+                path = explorer.currentPath;
+            }
+            await explorer.loadFiles(this.currentContainerId, path);
         }
     }
 
@@ -322,68 +361,154 @@ class ContainerDetails {
     }
 
     async loadEnvironment() {
+        const envContent = document.getElementById('envContent');
         try {
             const response = await this.apiClient.getContainerEnvironment(this.currentContainerId);
-            // Extract the environment data from the response
-            const envVars = response.environment || response;
-            this.renderEnvironmentVariables(envVars);
+            this.environment = response.environment || [];
+            this.renderEnvironmentVariables(this.environment);
         } catch (error) {
             console.error('Failed to load environment variables:', error);
-            const envContent = document.getElementById('envContent');
+            this.environment = null;
             if (envContent) {
-                envContent.innerHTML = '<div class="error">Failed to load environment variables</div>';
+                envContent.innerHTML = `<div class="error">Failed to load environment variables: ${this.escapeHtml(error.message)}</div>`;
             }
         }
+        this.updateRecreateButton();
     }
 
+    /**
+     * Show the variables as an editable table; saving the changes recreates
+     * the container with them
+     */
     renderEnvironmentVariables(envVars) {
         const envContent = document.getElementById('envContent');
         if (!envContent) return;
 
-        if (!envVars || (Array.isArray(envVars) && envVars.length === 0) || 
-            (typeof envVars === 'object' && Object.keys(envVars).length === 0)) {
-            envContent.innerHTML = '<div class="empty">No environment variables found</div>';
+        envContent.innerHTML = `
+            <table class="data-table env-table">
+                <thead>
+                    <tr>
+                        <th>Name</th>
+                        <th>Value</th>
+                        <th></th>
+                    </tr>
+                </thead>
+                <tbody id="envRows"></tbody>
+            </table>
+            <div class="placeholder env-empty" id="envEmpty">No environment variables</div>
+        `;
+        envVars.forEach(({ key, value }) => this.addEnvironmentRow(key, value, false));
+        this.updateEnvironmentEmpty();
+    }
+
+    addEnvironmentRow(key = '', value = '', focus = true) {
+        const rows = document.getElementById('envRows');
+        if (!rows) return;
+
+        const row = document.createElement('tr');
+        row.innerHTML = `
+            <td><input type="text" class="env-key-input" data-mutating spellcheck="false" placeholder="NAME"></td>
+            <td><input type="text" class="env-value-input" data-mutating spellcheck="false"></td>
+            <td><button class="action-btn secondary env-remove" data-mutating title="Remove variable"><i class="fas fa-times"></i></button></td>
+        `;
+        row.querySelector('.env-key-input').value = key;
+        row.querySelector('.env-value-input').value = value;
+        row.querySelectorAll('input').forEach(input => input.addEventListener('input', () => this.updateRecreateButton()));
+        row.querySelector('.env-remove').addEventListener('click', () => {
+            row.remove();
+            this.updateEnvironmentEmpty();
+            this.updateRecreateButton();
+        });
+        rows.appendChild(row);
+        this.updateEnvironmentEmpty();
+
+        if (focus) {
+            row.querySelector('.env-key-input').focus();
+            this.updateRecreateButton();
+        }
+    }
+
+    updateEnvironmentEmpty() {
+        const empty = document.getElementById('envEmpty');
+        const rows = document.getElementById('envRows');
+        if (empty && rows) empty.hidden = rows.children.length > 0;
+    }
+
+    /**
+     * The variables as edited, or an error for names that cannot be used
+     */
+    editedEnvironment() {
+        const env = {};
+        for (const row of document.querySelectorAll('#envRows tr')) {
+            const key = row.querySelector('.env-key-input').value.trim();
+            const value = row.querySelector('.env-value-input').value;
+            if (!key && !value) continue;
+            if (!key || key.includes('=') || /\s/.test(key)) {
+                return { error: `Invalid variable name '${key}'` };
+            }
+            if (key in env) {
+                return { error: `${key} is set twice` };
+            }
+            env[key] = value;
+        }
+        return { env };
+    }
+
+    environmentChanged() {
+        if (!this.environment) return false;
+        const { env, error } = this.editedEnvironment();
+        if (error) return true;
+
+        const keys = Object.keys(env);
+        return keys.length !== this.environment.length ||
+            this.environment.some(({ key, value }) => env[key] !== value);
+    }
+
+    updateRecreateButton() {
+        const button = document.getElementById('recreateEnvBtn');
+        if (button && !document.body.classList.contains('read-only')) {
+            button.disabled = !this.environmentChanged();
+        }
+    }
+
+    exportEnvironment() {
+        if (!this.environment || this.environment.length === 0) {
+            UIHelpers.showToast('No environment variables to export', 'warning');
             return;
         }
 
-        let html = '<div class="env-list">';
-        
-        if (Array.isArray(envVars)) {
-            envVars.forEach(envVar => {
-                if (typeof envVar === 'string') {
-                    const [key, ...valueParts] = envVar.split('=');
-                    const value = valueParts.join('=');
-                    html += `
-                        <div class="env-item">
-                            <span class="env-key">${this.escapeHtml(key || '')}</span>
-                            <span class="env-value">${this.escapeHtml(value || '')}</span>
-                        </div>
-                    `;
-                } else if (typeof envVar === 'object' && envVar !== null) {
-                    // Handle object format like {key: "KEY", value: "VALUE"}
-                    const key = envVar.key || envVar.name || '';
-                    const value = envVar.value || '';
-                    html += `
-                        <div class="env-item">
-                            <span class="env-key">${this.escapeHtml(String(key))}</span>
-                            <span class="env-value">${this.escapeHtml(String(value))}</span>
-                        </div>
-                    `;
-                }
-            });
-        } else if (typeof envVars === 'object' && envVars !== null) {
-            Object.entries(envVars).forEach(([key, value]) => {
-                html += `
-                    <div class="env-item">
-                        <span class="env-key">${this.escapeHtml(String(key))}</span>
-                        <span class="env-value">${this.escapeHtml(String(value))}</span>
-                    </div>
-                `;
-            });
+        const text = this.environment.map(({ key, value }) => `${key}=${value}`).join('\n') + '\n';
+        const link = document.createElement('a');
+        link.href = URL.createObjectURL(new Blob([text], { type: 'text/plain' }));
+        link.download = `${this.currentContainerId.substring(0, 12)}.env`;
+        link.click();
+        URL.revokeObjectURL(link.href);
+    }
+
+    async recreateWithEnvironment() {
+        const { env, error } = this.editedEnvironment();
+        if (error) {
+            UIHelpers.showToast(error, 'error');
+            return;
+        }
+        if (!confirm('Recreate the container with these environment variables? It is stopped and run again from its image, losing changes made inside it.')) {
+            return;
+        }
+
+        const button = document.getElementById('recreateEnvBtn');
+        if (button) button.disabled = true;
+        try {
+            const response = await this.apiClient.recreateContainer(this.currentContainerId, env);
+            UIHelpers.showToast('Container recreated with the new environment', 'success');
+            if (this.onContainerUpdated) {
+                this.onContainerUpdated();
+            }
+            await this.show(response.new_container?.id || this.currentContainerId);
+            this.switchTab('env');
+        } catch (error) {
+            UIHelpers.showToast(`Failed to recreate container: ${error.message}`, 'error');
+            this.updateRecreateButton();
         }
-        
-        html += '</div>';
-        envContent.innerHTML = html;
     }
 
     escapeHtml(text) {
@@ -393,11 +518,47 @@ class ContainerDetails {
     }
 
     async loadVolumes() {
-        // Placeholder for volumes content
         const volumesContent = document.getElementById('volumesContent');
-        if (volumesContent) {
-            volumesContent.innerHTML = '<div class="placeholder">Volume information will be displayed here</div>';
+        if (!volumesContent) return;
+
+        try {
+            const { mounts } = await this.apiClient.getContainerMounts(this.currentContainerId);
+            volumesContent.innerHTML = this.renderMounts(mounts);
+        } catch (error) {
+            console.error('Failed to load mounts:', error);
+            volumesContent.innerHTML = `<div class="error">Failed to load mounts: ${this.escapeHtml(error.message)}</div>`;
+        }
+    }
+
+    renderMounts(mounts) {
+        if (mounts.length === 0) {
+            return '<div class="placeholder">This container has no volumes or bind mounts</div>';
         }
+
+        return `
+            <table class="data-table">
+                <thead>
+                    <tr>
+                        <th>Type</th>
+                        <th>Source</th>
+                        <th>Mounted At</th>
+                        <th>Access</th>
+                        <th>Propagation</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    ${mounts.map(mount => `
+                        <tr>
+                            <td>${mount.type === 'volume' ? 'Volume' : 'Bind'}</td>
+                            <td><code>${this.escapeHtml(mount.source)}</code></td>
+                            <td><code>${this.escapeHtml(mount.destination)}</code></td>
+                            <td>${mount.rw ? 'Read-write' : 'Read-only'}</td>
+                            <td title="${mount.propagation ? '' : 'Known while the container runs natively'}">${this.escapeHtml(mount.propagation || '-')}</td>
+                        </tr>
+                    `).join('')}
+                </tbody>
+            </table>
+        `;
     }
 
     async loadNetwork() {
@@ -409,10 +570,9 @@ class ContainerDetails {
     }
 
     async loadStats() {
-        // Placeholder for stats content
-        const statsContent = document.getElementById('statsContent');
-        if (statsContent) {
-            statsContent.innerHTML = '<div class="placeholder">Container statistics will be displayed here</div>';
+        // Stats history and chart export are handled by the StatsHistory component
+        if (window.statsHistory) {
+            await window.statsHistory.show(this.currentContainerId);
         }
     }
 
@@ -0,0 +1,150 @@
+/**
+ * Disk Usage Component
+ * Shows the space images, containers, volumes and the build cache use, as
+ * servin system df reports it, with how much a prune of each would reclaim
+ */
+
+class DiskUsage {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.loading = false;
+
+        // Each card, with the key of its usage in system df and its prune
+        this.types = [
+            { key: 'images', card: 'diskUsageImages', label: 'Images', noun: 'image', prune: 'pruneImages' },
+            { key: 'containers', card: 'diskUsageContainers', label: 'Containers', noun: 'container', prune: 'pruneContainers' },
+            { key: 'volumes', card: 'diskUsageVolumes', label: 'Volumes', noun: 'volume', prune: 'pruneVolumes' },
+            { key: 'build_cache', card: 'diskUsageBuildCache', label: 'Build Cache', noun: 'cached step', prune: 'pruneBuildCache' }
+        ];
+
+        this.setupEventListeners();
+    }
+
+    setupEventListeners() {
+        document.querySelector('[data-section="diskUsage"]')?.addEventListener('click', () => {
+            UIHelpers.switchSection('diskUsage');
+            this.load();
+        });
+        document.getElementById('refreshDiskUsageBtn')?.addEventListener('click', () => this.load());
+        // Prunes refresh through the global refresh button when they finish
+        document.getElementById('refreshBtn')?.addEventListener('click', () => {
+            if (this.isShown()) this.load();
+        });
+
+        this.types.forEach(type => {
+            document.querySelector(`#${type.card} [data-prune]`)?.addEventListener('click', () => {
+                window.bulkActions?.[type.prune]();
+            });
+        });
+    }
+
+    isShown() {
+        return document.getElementById('diskUsageSection')?.classList.contains('active');
+    }
+
+    async load() {
+        // Measuring walks every container and volume, so one runs at a time
+        if (this.loading) return;
+        this.loading = true;
+        document.getElementById('refreshDiskUsageBtn').disabled = true;
+
+        try {
+            this.render(await this.apiClient.getDiskUsage());
+        } catch (error) {
+            document.getElementById('diskUsageTotal').textContent = `Failed to measure disk usage: ${error.message}`;
+            document.getElementById('diskUsageReclaimable').textContent = '';
+        } finally {
+            this.loading = false;
+            document.getElementById('refreshDiskUsageBtn').disabled = false;
+        }
+    }
+
+    render(usage) {
+        const total = this.types.reduce((sum, type) => sum + usage[type.key].size, 0);
+        const reclaimable = this.types.reduce((sum, type) => sum + usage[type.key].reclaimable, 0);
+        document.getElementById('diskUsageTotal').textContent = `${this.formatSize(total)} used`;
+        document.getElementById('diskUsageReclaimable').textContent = `${this.formatSize(reclaimable)} reclaimable`;
+
+        // One bar split by type, with a legend naming each part
+        const bar = document.getElementById('diskUsageBar');
+        const legend = document.getElementById('diskUsageLegend');
+        bar.innerHTML = '';
+        legend.innerHTML = '';
+        this.types.forEach((type, index) => {
+            const size = usage[type.key].size;
+            const part = document.createElement('div');
+            part.className = `disk-usage-part part-${index}`;
+            part.style.width = total > 0 ? `${size * 100 / total}%` : '0';
+            part.title = `${type.label}: ${this.formatSize(size)}`;
+            bar.appendChild(part);
+
+            const item = document.createElement('li');
+            item.innerHTML = `<span class="disk-usage-swatch part-${index}"></span> `;
+            item.appendChild(document.createTextNode(`${type.label} ${this.formatSize(size)}`));
+            legend.appendChild(item);
+        });
+
+        const shared = usage.shared_cache;
+        document.getElementById('diskUsageSharedCache').textContent = shared
+            ? `Shared layer cache: ${this.formatSize(shared.size)} in ${shared.dir}, saving ${this.formatSize(shared.saved)}`
+            : '';
+
+        this.types.forEach(type => this.renderCard(type, usage[type.key]));
+    }
+
+    renderCard(type, usage) {
+        const card = document.getElementById(type.card);
+        if (!card) return;
+
+        card.querySelector('.disk-usage-size').textContent = this.formatSize(usage.size);
+        card.querySelector('.disk-usage-counts').textContent = type.key === 'build_cache'
+            ? this.count(usage.total, type.noun)
+            : `${this.count(usage.total, type.noun)}, ${usage.active} in use`;
+
+        const percent = usage.size > 0 ? Math.round(usage.reclaimable * 100 / usage.size) : 0;
+        card.querySelector('.download-progress-bar').style.width = `${percent}%`;
+        card.querySelector('.disk-usage-reclaimable').textContent = usage.reclaimable > 0
+            ? `${this.formatSize(usage.reclaimable)} reclaimable (${percent}%)`
+            : 'Nothing to reclaim';
+        card.querySelector('[data-prune]').classList.toggle('primary', usage.reclaimable > 0);
+
+        // The largest items, which are the first to look at to free space
+        const list = card.querySelector('.disk-usage-items');
+        list.innerHTML = '';
+        [...(usage.items || [])].sort((a, b) => b.size - a.size).slice(0, 5).forEach(item => {
+            const entry = document.createElement('li');
+            const name = document.createElement('span');
+            name.textContent = this.itemName(type.key, item);
+            const note = document.createElement('span');
+            note.className = 'disk-usage-note';
+            note.textContent = this.itemNote(type.key, item);
+            const size = document.createElement('span');
+            size.textContent = this.formatSize(item.size);
+            entry.append(name, note, size);
+            list.appendChild(entry);
+        });
+    }
+
+    itemName(key, item) {
+        if (key === 'images') return item.repo_tags.length > 0 ? item.repo_tags[0] : item.id.substring(0, 12);
+        return item.name;
+    }
+
+    itemNote(key, item) {
+        if (key === 'images') return item.containers > 0 ? this.count(item.containers, 'container') : 'unused';
+        if (key === 'containers') return item.status;
+        return item.in_use ? 'in use' : 'unused';
+    }
+
+    formatSize(bytes) {
+        return bytes > 0 ? UIHelpers.formatBytes(bytes) : '0 B';
+    }
+
+    count(n, noun) {
+        return `${n} ${noun}${n === 1 ? '' : 's'}`;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.diskUsage = new DiskUsage(new APIClient());
+});
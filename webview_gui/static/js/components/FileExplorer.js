@@ -1,6 +1,7 @@
 /**
  * File Explorer Component
- * Handles container filesystem browsing
+ * Browses a container's filesystem as a tree whose directories are listed
+ * a page at a time as they are expanded
  */
 
 class FileExplorer {
@@ -8,6 +9,9 @@ class FileExplorer {
         this.apiClient = apiClient;
         this.currentContainerId = null;
         this.currentPath = '/';
+        // Entries listed so far per directory path: { entries, total, expanded }
+        this.directories = new Map();
+        this.pageSize = 200;
         
         this.init();
     }
@@ -20,6 +24,7 @@ class FileExplorer {
         const refreshBtn = document.getElementById('refreshFilesBtn');
         const goBackBtn = document.getElementById('goBackBtn');
         const goToRootBtn = document.getElementById('goToRootBtn');
+        const copyPathBtn = document.getElementById('copyPathBtn');
         
         if (refreshBtn) {
             refreshBtn.addEventListener('click', () => this.refresh());
@@ -32,11 +37,106 @@ class FileExplorer {
         if (goToRootBtn) {
             goToRootBtn.addEventListener('click', () => this.goToRoot());
         }
+
+        if (copyPathBtn) {
+            copyPathBtn.addEventListener('click', () => this.copyPath(this.currentPath));
+        }
+
+        const newFolderBtn = document.getElementById('newFolderBtn');
+        if (newFolderBtn) {
+            newFolderBtn.addEventListener('click', () => this.makeDirectory(this.currentPath));
+        }
+
+        const uploadBtn = document.getElementById('uploadFilesBtn');
+        const uploadInput = document.getElementById('uploadFilesInput');
+        if (uploadBtn && uploadInput) {
+            uploadBtn.addEventListener('click', () => {
+                if (this.currentContainerId) uploadInput.click();
+            });
+            uploadInput.addEventListener('change', async () => {
+                const files = Array.from(uploadInput.files).map(file => ({ file, name: file.name }));
+                uploadInput.value = '';
+                if (files.length > 0) {
+                    await this.uploadFiles(files, this.currentPath);
+                }
+            });
+        }
+
+        this.setupDropZone();
+    }
+
+    /**
+     * Upload files dragged from the host file manager into the current directory
+     */
+    setupDropZone() {
+        const filesContent = document.getElementById('filesContent');
+        if (!filesContent) return;
+
+        let depth = 0;
+        const isFileDrag = (event) => event.dataTransfer && Array.from(event.dataTransfer.types).includes('Files');
+
+        filesContent.addEventListener('dragenter', (event) => {
+            if (!isFileDrag(event) || !this.currentContainerId) return;
+            event.preventDefault();
+            depth++;
+            filesContent.classList.add('drop-target');
+        });
+
+        filesContent.addEventListener('dragover', (event) => {
+            if (!isFileDrag(event) || !this.currentContainerId) return;
+            event.preventDefault();
+            event.dataTransfer.dropEffect = 'copy';
+        });
+
+        filesContent.addEventListener('dragleave', () => {
+            depth = Math.max(0, depth - 1);
+            if (depth === 0) {
+                filesContent.classList.remove('drop-target');
+            }
+        });
+
+        filesContent.addEventListener('drop', async (event) => {
+            if (!isFileDrag(event) || !this.currentContainerId) return;
+            event.preventDefault();
+            depth = 0;
+            filesContent.classList.remove('drop-target');
+
+            // Files dropped onto a folder in the tree go into that folder
+            const row = event.target.closest('.tree-row[data-type="directory"]');
+            const path = row ? row.dataset.path : this.currentPath;
+
+            const files = await UIHelpers.collectDroppedFiles(event.dataTransfer);
+            if (files.length > 0) {
+                await this.uploadFiles(files, path);
+            }
+        });
+    }
+
+    async uploadFiles(files, path) {
+        const containerId = this.currentContainerId;
+        const label = files.length === 1 ? files[0].name : `${files.length} files`;
+        UIHelpers.showToast(`Uploading ${this.escapeHtml(label)} to ${this.escapeHtml(path)}...`, 'info');
+
+        try {
+            await this.apiClient.uploadContainerFiles(containerId, path, files);
+            UIHelpers.showToast(`Uploaded ${this.escapeHtml(label)} to ${this.escapeHtml(path)}`, 'success');
+            if (containerId === this.currentContainerId) {
+                await this.reloadDirectory(path);
+            }
+        } catch (error) {
+            console.error('Failed to upload files:', error);
+            UIHelpers.showToast(`Upload failed: ${this.escapeHtml(error.message || 'Unknown error')}`, 'error');
+        }
+    }
+
+    copyPath(path) {
+        UIHelpers.copyToClipboard(path, 'Path');
     }
 
     async loadFiles(containerId, path = '/') {
-        console.log('FileExplorer: Loading files for container:', containerId, 'path:', path);
-        
+        if (containerId !== this.currentContainerId) {
+            this.directories.clear();
+        }
         this.currentContainerId = containerId;
         this.currentPath = path;
         
@@ -53,20 +153,92 @@ class FileExplorer {
         this.updateNavigationButtons();
 
         try {
-            const files = await this.apiClient.getContainerFiles(containerId, path);
-            this.renderFiles(files, path);
+            this.directories.delete(path);
+            await this.loadPage(path);
+            this.renderTree();
         } catch (error) {
             console.error('Failed to load files:', error);
             filesContent.innerHTML = `
                 <div class="error">
                     <i class="fas fa-exclamation-triangle"></i>
                     <p>Failed to load files</p>
-                    <small>${error.message || 'Unknown error'}</small>
+                    <small>${this.escapeHtml(error.message || 'Unknown error')}</small>
                 </div>
             `;
         }
     }
 
+    /**
+     * List the next page of a directory's entries
+     */
+    async loadPage(path) {
+        const containerId = this.currentContainerId;
+        let dir = this.directories.get(path);
+        if (!dir) {
+            dir = { entries: [], total: 0, expanded: false };
+            this.directories.set(path, dir);
+        }
+
+        const listing = await this.apiClient.getContainerFiles(containerId, path, dir.entries.length, this.pageSize);
+        if (containerId !== this.currentContainerId || this.directories.get(path) !== dir) {
+            return;
+        }
+        dir.entries.push(...(listing.entries || []));
+        dir.total = listing.total || 0;
+    }
+
+    /**
+     * List a directory again from its first page, after it has changed
+     */
+    async reloadDirectory(path) {
+        const dir = this.directories.get(path);
+        if (path !== this.currentPath && !(dir && dir.expanded)) {
+            return;
+        }
+        this.directories.delete(path);
+        try {
+            await this.loadPage(path);
+            if (dir) {
+                this.directories.get(path).expanded = dir.expanded;
+            }
+        } catch (error) {
+            UIHelpers.showToast(`Failed to list ${this.escapeHtml(path)}: ${this.escapeHtml(error.message)}`, 'error');
+        }
+        this.renderTree();
+    }
+
+    async toggleDirectory(path) {
+        const dir = this.directories.get(path);
+        if (dir && dir.expanded) {
+            dir.expanded = false;
+            this.renderTree();
+            return;
+        }
+
+        try {
+            if (!dir) {
+                await this.loadPage(path);
+            }
+            const loaded = this.directories.get(path);
+            if (loaded) {
+                loaded.expanded = true;
+            }
+        } catch (error) {
+            this.directories.delete(path);
+            UIHelpers.showToast(`Failed to list ${this.escapeHtml(path)}: ${this.escapeHtml(error.message)}`, 'error');
+        }
+        this.renderTree();
+    }
+
+    async loadMore(path) {
+        try {
+            await this.loadPage(path);
+        } catch (error) {
+            UIHelpers.showToast(`Failed to list ${this.escapeHtml(path)}: ${this.escapeHtml(error.message)}`, 'error');
+        }
+        this.renderTree();
+    }
+
     updateBreadcrumb(path) {
         const breadcrumbPath = document.getElementById('breadcrumbPath');
         if (!breadcrumbPath) return;
@@ -119,98 +291,219 @@ class FileExplorer {
         }
     }
 
-    renderFiles(files, currentPath) {
+    renderTree() {
         const filesContent = document.getElementById('filesContent');
-        if (!filesContent) return;
-
-        let html = '<div class="files-list">';
-
-        // Add parent directory link if not root
-        if (currentPath !== '/') {
-            const parentPath = currentPath.split('/').slice(0, -1).join('/') || '/';
-            html += `
-                <div class="file-item parent-dir" onclick="window.fileExplorer.navigateToPath('${parentPath}')">
-                    <div class="file-icon">
-                        <i class="fas fa-level-up-alt"></i>
-                    </div>
-                    <div class="file-info">
-                        <div class="file-name">.. (Parent Directory)</div>
-                        <div class="file-meta">Go up one level</div>
-                    </div>
-                    <div class="file-actions"></div>
-                </div>
-            `;
-        }
+        const root = this.directories.get(this.currentPath);
+        if (!filesContent || !root) return;
 
-        if (!files || files.length === 0) {
-            html += `
+        const tree = document.createElement('div');
+        tree.className = 'file-tree';
+        if (root.total === 0) {
+            tree.innerHTML = `
                 <div class="empty-state">
                     <i class="fas fa-folder-open"></i>
                     <p>This directory is empty</p>
                 </div>
             `;
         } else {
-            // Sort files: directories first, then files
-            const sortedFiles = [...files].sort((a, b) => {
-                if (a.type === 'directory' && b.type !== 'directory') return -1;
-                if (a.type !== 'directory' && b.type === 'directory') return 1;
-                return a.name.localeCompare(b.name);
-            });
+            this.renderDirectory(tree, this.currentPath, root, 0);
+        }
+
+        // Keep the scroll position as directories open and close
+        const scrollTop = filesContent.scrollTop;
+        filesContent.replaceChildren(tree);
+        filesContent.scrollTop = scrollTop;
+    }
 
-            sortedFiles.forEach(file => {
-                const isDirectory = file.type === 'directory';
-                const isSymlink = file.type === 'symlink';
-                
-                let icon = 'fa-file';
-                if (isDirectory) {
-                    icon = 'fa-folder';
-                } else if (isSymlink) {
-                    icon = 'fa-link';
-                } else {
-                    // Set specific icons based on file extension
-                    const ext = file.name.toLowerCase().split('.').pop();
-                    switch (ext) {
-                        case 'js': case 'json': icon = 'fa-file-code'; break;
-                        case 'py': icon = 'fa-file-code'; break;
-                        case 'html': case 'htm': icon = 'fa-file-code'; break;
-                        case 'css': icon = 'fa-file-code'; break;
-                        case 'txt': case 'md': case 'log': icon = 'fa-file-alt'; break;
-                        case 'jpg': case 'jpeg': case 'png': case 'gif': icon = 'fa-file-image'; break;
-                        case 'pdf': icon = 'fa-file-pdf'; break;
-                        case 'zip': case 'tar': case 'gz': icon = 'fa-file-archive'; break;
-                        default: icon = 'fa-file';
-                    }
+    renderDirectory(tree, path, dir, depth) {
+        dir.entries.forEach(file => {
+            const filePath = this.joinPath(path, file.name);
+            tree.appendChild(this.renderRow(file, filePath, depth));
+
+            const child = this.directories.get(filePath);
+            if (file.type === 'directory' && child && child.expanded) {
+                if (child.total === 0) {
+                    tree.appendChild(this.renderNote('Empty', depth + 1));
                 }
-                
-                const clickHandler = isDirectory ? 
-                    `onclick="window.fileExplorer.navigateToPath('${this.joinPath(currentPath, file.name)}')"` : 
-                    '';
-                
-                const fileSize = isDirectory ? '-' : UIHelpers.formatFileSize(file.size || 0);
-                const permissions = file.permissions || '-';
-                
-                html += `
-                    <div class="file-item ${file.type}" ${clickHandler}>
-                        <div class="file-icon">
-                            <i class="fas ${icon}"></i>
-                        </div>
-                        <div class="file-info">
-                            <div class="file-name">${this.escapeHtml(file.name)}</div>
-                            <div class="file-meta">
-                                <span class="file-permissions">${permissions}</span>
-                                <span class="file-size">${fileSize}</span>
-                            </div>
-                        </div>
-                        <div class="file-actions">
-                            ${isDirectory ? '<i class="fas fa-chevron-right"></i>' : ''}
-                        </div>
-                    </div>
-                `;
-            });
+                this.renderDirectory(tree, filePath, child, depth + 1);
+            }
+        });
+
+        const remaining = dir.total - dir.entries.length;
+        if (remaining > 0) {
+            const more = this.renderNote(`Show ${Math.min(remaining, this.pageSize)} more of ${remaining}`, depth);
+            more.classList.add('load-more');
+            more.addEventListener('click', () => this.loadMore(path));
+            tree.appendChild(more);
+        }
+    }
+
+    renderRow(file, filePath, depth) {
+        const isDirectory = file.type === 'directory';
+        const child = this.directories.get(filePath);
+        const expanded = isDirectory && child && child.expanded;
+
+        const row = document.createElement('div');
+        row.className = `tree-row file-item ${file.type}`;
+        row.dataset.path = filePath;
+        row.dataset.type = file.type;
+        row.style.paddingLeft = `calc(var(--spacing-md) + ${depth * 18}px)`;
+
+        const toggle = document.createElement('span');
+        toggle.className = 'tree-toggle';
+        if (isDirectory) {
+            toggle.innerHTML = `<i class="fas ${expanded ? 'fa-chevron-down' : 'fa-chevron-right'}"></i>`;
+        }
+
+        const icon = document.createElement('div');
+        icon.className = 'file-icon';
+        icon.innerHTML = `<i class="fas ${this.fileIcon(file, expanded)}"></i>`;
+
+        const name = document.createElement('div');
+        name.className = 'file-name';
+        name.textContent = file.name;
+
+        const permissions = document.createElement('span');
+        permissions.className = 'file-permissions';
+        permissions.textContent = file.permissions || '-';
+
+        const size = document.createElement('span');
+        size.className = 'file-size';
+        size.textContent = isDirectory ? '-' : UIHelpers.formatFileSize(file.size || 0);
+
+        const actions = document.createElement('div');
+        actions.className = 'file-actions';
+        if (isDirectory) {
+            actions.append(
+                this.actionButton('fa-sign-in-alt', 'Open', () => this.navigateToPath(filePath)),
+                this.actionButton('fa-folder-plus', 'New folder', () => this.makeDirectory(filePath))
+            );
+        }
+        if (file.type !== 'other') {
+            actions.append(this.actionButton('fa-download', isDirectory ? 'Download as tar' : 'Download',
+                () => this.download(filePath, file.type)));
+        }
+        actions.append(
+            this.actionButton('fa-key', 'Change permissions', () => this.changeMode(filePath, file)),
+            this.actionButton('fa-copy', 'Copy path', () => this.copyPath(filePath)),
+            this.actionButton('fa-trash', 'Delete', () => this.remove(filePath, file))
+        );
+
+        row.append(toggle, icon, name, permissions, size, actions);
+        if (isDirectory) {
+            row.addEventListener('click', () => this.toggleDirectory(filePath));
+            row.addEventListener('dblclick', () => this.navigateToPath(filePath));
+        }
+        return row;
+    }
+
+    renderNote(text, depth) {
+        const note = document.createElement('div');
+        note.className = 'tree-note';
+        note.style.paddingLeft = `calc(var(--spacing-md) + ${depth * 18 + 40}px)`;
+        note.textContent = text;
+        return note;
+    }
+
+    actionButton(icon, title, handler) {
+        const button = document.createElement('button');
+        button.className = 'file-action-btn';
+        button.title = title;
+        button.innerHTML = `<i class="fas ${icon}"></i>`;
+        button.addEventListener('click', (event) => {
+            event.stopPropagation();
+            handler();
+        });
+        return button;
+    }
+
+    fileIcon(file, expanded) {
+        if (file.type === 'directory') return expanded ? 'fa-folder-open' : 'fa-folder';
+        if (file.type === 'symlink') return 'fa-link';
+
+        // Set specific icons based on file extension
+        const ext = file.name.toLowerCase().split('.').pop();
+        switch (ext) {
+            case 'js': case 'json': case 'py': case 'html': case 'htm': case 'css': return 'fa-file-code';
+            case 'txt': case 'md': case 'log': return 'fa-file-alt';
+            case 'jpg': case 'jpeg': case 'png': case 'gif': return 'fa-file-image';
+            case 'pdf': return 'fa-file-pdf';
+            case 'zip': case 'tar': case 'gz': return 'fa-file-archive';
+            default: return 'fa-file';
+        }
+    }
+
+    async download(path, type) {
+        try {
+            const blob = await this.apiClient.downloadContainerFile(this.currentContainerId, path, type);
+            const name = path.split('/').pop() + (type === 'directory' ? '.tar' : '');
+            const url = URL.createObjectURL(blob);
+            const link = document.createElement('a');
+            link.href = url;
+            link.download = name;
+            document.body.appendChild(link);
+            link.click();
+            link.remove();
+            setTimeout(() => URL.revokeObjectURL(url), 1000);
+        } catch (error) {
+            console.error('Failed to download file:', error);
+            UIHelpers.showToast(`Download failed: ${this.escapeHtml(error.message)}`, 'error');
+        }
+    }
+
+    async remove(path, file) {
+        const what = file.type === 'directory' ? `the folder ${path} and everything in it` : path;
+        if (!confirm(`Delete ${what}?`)) {
+            return;
         }
 
-        html += '</div>';
-        filesContent.innerHTML = html;
+        try {
+            await this.apiClient.deleteContainerFile(this.currentContainerId, path);
+            UIHelpers.showToast(`Deleted ${this.escapeHtml(path)}`, 'success');
+            this.directories.delete(path);
+            await this.reloadDirectory(this.parentPath(path));
+        } catch (error) {
+            console.error('Failed to delete file:', error);
+            UIHelpers.showToast(`Delete failed: ${this.escapeHtml(error.message)}`, 'error');
+        }
+    }
+
+    async makeDirectory(parent) {
+        if (!this.currentContainerId) return;
+        const name = prompt(`New folder in ${parent}:`);
+        if (!name || !name.trim()) {
+            return;
+        }
+
+        const path = this.joinPath(parent, name.trim().replace(/^\/+|\/+$/g, ''));
+        try {
+            await this.apiClient.makeContainerDirectory(this.currentContainerId, path);
+            UIHelpers.showToast(`Created ${this.escapeHtml(path)}`, 'success');
+            await this.reloadDirectory(parent);
+        } catch (error) {
+            console.error('Failed to create folder:', error);
+            UIHelpers.showToast(`Could not create folder: ${this.escapeHtml(error.message)}`, 'error');
+        }
+    }
+
+    async changeMode(path, file) {
+        const mode = prompt(`Permissions for ${path} (octal, such as 644):`, file.mode || '');
+        if (mode === null || mode.trim() === (file.mode || '')) {
+            return;
+        }
+        if (!/^[0-7]{3}$/.test(mode.trim())) {
+            UIHelpers.showToast('Permissions must be three octal digits, such as 644', 'error');
+            return;
+        }
+
+        try {
+            await this.apiClient.chmodContainerFile(this.currentContainerId, path, mode.trim());
+            UIHelpers.showToast(`Changed permissions of ${this.escapeHtml(path)} to ${mode.trim()}`, 'success');
+            await this.reloadDirectory(this.parentPath(path));
+        } catch (error) {
+            console.error('Failed to change permissions:', error);
+            UIHelpers.showToast(`Could not change permissions: ${this.escapeHtml(error.message)}`, 'error');
+        }
     }
 
     navigateToPath(path) {
@@ -218,6 +511,10 @@ class FileExplorer {
         this.loadFiles(this.currentContainerId, path);
     }
 
+    parentPath(path) {
+        return path.split('/').slice(0, -1).join('/') || '/';
+    }
+
     joinPath(basePath, fileName) {
         if (basePath === '/') {
             return '/' + fileName;
@@ -231,16 +528,36 @@ class FileExplorer {
         return div.innerHTML;
     }
 
-    refresh() {
-        if (this.currentContainerId) {
-            this.loadFiles(this.currentContainerId, this.currentPath);
+    async refresh() {
+        if (!this.currentContainerId) return;
+
+        // List the directory and the folders open in it again, keeping them open
+        const expanded = [...this.directories.entries()]
+            .filter(([path, dir]) => dir.expanded && path.startsWith(this.currentPath))
+            .map(([path]) => path)
+            .sort((a, b) => a.length - b.length);
+        await this.loadFiles(this.currentContainerId, this.currentPath);
+        for (const path of expanded) {
+            this.directories.delete(path);
+        }
+        for (const path of expanded) {
+            const parent = this.directories.get(this.parentPath(path));
+            if (!parent || !parent.entries.some(entry => this.joinPath(this.parentPath(path), entry.name) === path)) {
+                continue;
+            }
+            try {
+                await this.loadPage(path);
+                this.directories.get(path).expanded = true;
+            } catch (error) {
+                this.directories.delete(path);
+            }
         }
+        this.renderTree();
     }
 
     goBack() {
         if (this.currentPath !== '/') {
-            const parentPath = this.currentPath.split('/').slice(0, -1).join('/') || '/';
-            this.navigateToPath(parentPath);
+            this.navigateToPath(this.parentPath(this.currentPath));
         }
     }
 
@@ -251,8 +568,13 @@ class FileExplorer {
     cleanup() {
         this.currentContainerId = null;
         this.currentPath = '/';
+        this.directories.clear();
     }
 }
 
+document.addEventListener('DOMContentLoaded', () => {
+    window.fileExplorer = new FileExplorer(new APIClient());
+});
+
 // Export the component
 window.FileExplorer = FileExplorer;
\ No newline at end of file
@@ -0,0 +1,376 @@
+/**
+ * Image Builder Component
+ * Builds an image from a Buildfile or Dockerfile in a host folder, showing
+ * the build's output and progress through its steps as it runs
+ */
+
+class ImageBuilder {
+    constructor(apiClient, socketManager) {
+        this.apiClient = apiClient;
+        this.socketManager = socketManager;
+        this.args = [];
+        this.running = false;
+        this.imageId = null;
+        // Steps by number; stages can build concurrently, so several run at once
+        this.steps = new Map();
+        this.pickerPath = null;
+        this.pickerParent = null;
+        this.pickerBuildFiles = [];
+
+        this.setupEventListeners();
+        this.renderArgs();
+    }
+
+    setupEventListeners() {
+        document.querySelector('[data-section="build"]')?.addEventListener('click', () => {
+            UIHelpers.switchSection('build');
+        });
+
+        document.getElementById('startBuildBtn')?.addEventListener('click', () => this.start());
+        document.getElementById('cancelBuildBtn')?.addEventListener('click', () => this.cancel());
+        document.getElementById('viewBuiltImageBtn')?.addEventListener('click', () => this.viewImage());
+        document.getElementById('addBuildArgBtn')?.addEventListener('click', () => {
+            this.args.push({ key: '', value: '' });
+            this.renderArgs();
+        });
+
+        document.getElementById('buildContext')?.addEventListener('change', (e) => {
+            this.loadBuildFiles(e.target.value.trim());
+        });
+        document.getElementById('buildBrowseBtn')?.addEventListener('click', () => this.openPicker());
+        document.getElementById('buildPickerUp')?.addEventListener('click', () => {
+            if (this.pickerParent) this.browse(this.pickerParent);
+        });
+        document.getElementById('buildPickerCancel')?.addEventListener('click', () => this.closePicker());
+        document.getElementById('buildPickerSelect')?.addEventListener('click', () => {
+            if (this.pickerPath) {
+                document.getElementById('buildContext').value = this.pickerPath;
+                this.setBuildFiles(this.pickerBuildFiles);
+            }
+            this.closePicker();
+        });
+
+        this.socketManager.on('build_output', (data) => this.handleOutput(data));
+        this.socketManager.on('build_step', (data) => this.handleStep(data));
+        this.socketManager.on('build_finished', (data) => this.handleFinished(data));
+    }
+
+    async start() {
+        if (this.running) return;
+
+        const context = document.getElementById('buildContext').value.trim();
+        if (!context) {
+            UIHelpers.showToast('Choose the folder to build', 'error');
+            return;
+        }
+        if (this.args.some(arg => !arg.key.trim() && arg.value)) {
+            UIHelpers.showToast('Every build argument needs a name', 'error');
+            return;
+        }
+
+        const buildArgs = {};
+        this.args.filter(arg => arg.key.trim()).forEach(arg => {
+            buildArgs[arg.key.trim()] = arg.value;
+        });
+
+        this.reset();
+        this.setRunning(true);
+        this.setStatus('Starting build...');
+        try {
+            await this.apiClient.buildImage({
+                context,
+                file: document.getElementById('buildFile').value,
+                tag: document.getElementById('buildTag').value.trim(),
+                build_args: buildArgs,
+                no_cache: document.getElementById('buildNoCache').checked
+            });
+        } catch (error) {
+            this.setRunning(false);
+            this.setStatus('No build running');
+            UIHelpers.showToast(`Failed to start build: ${error.message}`, 'error');
+        }
+    }
+
+    async cancel() {
+        const cancelBtn = document.getElementById('cancelBuildBtn');
+        cancelBtn.disabled = true;
+        try {
+            await this.apiClient.cancelBuild();
+            this.setStatus('Cancelling build...');
+        } catch (error) {
+            UIHelpers.showToast(`Failed to cancel build: ${error.message}`, 'error');
+            cancelBtn.disabled = false;
+        }
+    }
+
+    reset() {
+        this.imageId = null;
+        this.steps.clear();
+        document.getElementById('buildSteps').innerHTML = '';
+        document.getElementById('buildOutput').textContent = '';
+        document.getElementById('buildStepCount').textContent = '';
+        document.getElementById('viewBuiltImageBtn').style.display = 'none';
+        this.setProgress(0, '');
+    }
+
+    setRunning(running) {
+        this.running = running;
+        document.getElementById('startBuildBtn').disabled = running;
+        const cancelBtn = document.getElementById('cancelBuildBtn');
+        cancelBtn.style.display = running ? 'inline-flex' : 'none';
+        cancelBtn.disabled = false;
+    }
+
+    setStatus(text) {
+        document.getElementById('buildStatusText').textContent = text;
+    }
+
+    setProgress(percent, state) {
+        const bar = document.getElementById('buildProgressBar');
+        bar.style.width = `${percent}%`;
+        bar.parentElement.className = `download-progress ${state}`;
+    }
+
+    handleOutput(data) {
+        if (!this.running) return;
+
+        const output = document.getElementById('buildOutput');
+        // Follow the output unless the user scrolled up to read it
+        const atBottom = output.scrollHeight - output.scrollTop - output.clientHeight < 20;
+        output.appendChild(document.createTextNode(data.line + '\n'));
+        if (atBottom) {
+            output.scrollTop = output.scrollHeight;
+        }
+    }
+
+    handleStep(data) {
+        if (!this.running) return;
+
+        // A stage's next step means its previous one finished
+        this.steps.forEach(step => {
+            if (step.state === 'running' && step.stage === data.stage) {
+                this.setStepState(step, 'done');
+            }
+        });
+
+        const item = document.createElement('li');
+        item.innerHTML = '<i></i> <span class="build-step-number"></span> <code></code>';
+        item.querySelector('.build-step-number').textContent = data.stage
+            ? `[${data.stage}] ${data.step}/${data.total}` : `${data.step}/${data.total}`;
+        item.querySelector('code').textContent = data.instruction;
+        document.getElementById('buildSteps').appendChild(item);
+
+        const step = { item, stage: data.stage };
+        this.steps.set(data.step, step);
+        this.setStepState(step, 'running');
+
+        const done = [...this.steps.values()].filter(other => other.state === 'done').length;
+        this.setStatus(data.instruction);
+        document.getElementById('buildStepCount').textContent = `${this.steps.size} of ${data.total} steps`;
+        this.setProgress(Math.floor(done * 100 / data.total), '');
+    }
+
+    setStepState(step, state) {
+        step.state = state;
+        step.item.className = `build-step ${state}`;
+        const icons = {
+            running: 'fas fa-spinner fa-spin',
+            done: 'fas fa-check',
+            failed: 'fas fa-times',
+            cancelled: 'fas fa-ban'
+        };
+        step.item.querySelector('i').className = icons[state];
+    }
+
+    handleFinished(data) {
+        if (!this.running) return;
+        this.setRunning(false);
+
+        if (data.success) {
+            this.steps.forEach(step => this.setStepState(step, 'done'));
+            this.imageId = data.image_id;
+            const tag = document.getElementById('buildTag').value.trim();
+            const name = tag || data.image_id.substring(0, 12);
+            this.setStatus(`Built ${name}`);
+            this.setProgress(100, 'done');
+            document.getElementById('viewBuiltImageBtn').style.display = 'inline-flex';
+            UIHelpers.showToast(`Built image ${name}`, 'success');
+
+            // Show the new image in the Images tab
+            document.getElementById('refreshBtn')?.click();
+            return;
+        }
+
+        // The failing step is the last one started; a failure stops the
+        // steps other stages were running too
+        const running = [...this.steps.values()].filter(step => step.state === 'running');
+        running.forEach((step, index) => {
+            const failed = !data.cancelled && index === running.length - 1;
+            this.setStepState(step, failed ? 'failed' : 'cancelled');
+        });
+        this.setProgress(100, data.cancelled ? 'cancelled' : 'failed');
+        if (data.cancelled) {
+            this.setStatus('Build cancelled');
+            UIHelpers.showToast('Build cancelled', 'info');
+        } else {
+            this.setStatus(`Build failed: ${data.error}`);
+            UIHelpers.showToast(`Build failed: ${data.error}`, 'error');
+        }
+    }
+
+    viewImage() {
+        UIHelpers.switchSection('images');
+        document.getElementById('refreshBtn')?.click();
+        if (this.imageId && window.imageDetails) {
+            window.imageDetails.show(this.imageId);
+        }
+    }
+
+    renderArgs() {
+        const container = document.getElementById('buildArgs');
+        if (!container) return;
+        container.innerHTML = '';
+        if (this.args.length === 0) {
+            container.innerHTML = '<div class="wizard-empty">None</div>';
+            return;
+        }
+
+        this.args.forEach((arg, index) => {
+            const row = document.createElement('div');
+            row.className = 'wizard-row';
+
+            const separator = document.createElement('span');
+            separator.className = 'wizard-separator';
+            separator.textContent = '=';
+
+            const remove = document.createElement('button');
+            remove.className = 'action-btn danger';
+            remove.title = 'Remove';
+            remove.innerHTML = '<i class="fas fa-times"></i>';
+            remove.addEventListener('click', () => {
+                this.args.splice(index, 1);
+                this.renderArgs();
+            });
+
+            row.append(this.input(arg, 'key', 'NAME'), separator, this.input(arg, 'value', 'value'), remove);
+            container.appendChild(row);
+        });
+    }
+
+    input(item, key, placeholder) {
+        const input = document.createElement('input');
+        input.type = 'text';
+        input.placeholder = placeholder;
+        input.value = item[key];
+        input.addEventListener('input', () => { item[key] = input.value; });
+        return input;
+    }
+
+    async loadBuildFiles(path) {
+        if (!path) {
+            this.setBuildFiles(null);
+            return;
+        }
+        try {
+            const result = await this.apiClient.getHostDirectories(path);
+            this.setBuildFiles(result.build_files);
+        } catch (error) {
+            this.setBuildFiles(null);
+            UIHelpers.showToast(error.message, 'error');
+        }
+    }
+
+    /**
+     * Offer the build files of the context, choosing the one servin would
+     */
+    setBuildFiles(files) {
+        const select = document.getElementById('buildFile');
+        select.innerHTML = '';
+
+        if (!files || files.length === 0) {
+            const option = document.createElement('option');
+            option.value = '';
+            option.textContent = files ? 'No Buildfile or Dockerfile in this folder' : 'Choose a context folder';
+            select.appendChild(option);
+            return;
+        }
+
+        files.forEach(name => {
+            const option = document.createElement('option');
+            option.value = name;
+            option.textContent = name;
+            select.appendChild(option);
+        });
+        select.value = ['Buildfile', 'Dockerfile'].find(name => files.includes(name)) || files[0];
+    }
+
+    /**
+     * Open the build section with a context folder chosen, such as a folder
+     * dropped onto the Images tab, suggesting a tag when none is typed
+     */
+    useContext(path, buildFiles, tag) {
+        UIHelpers.switchSection('build');
+        document.getElementById('buildContext').value = path;
+        this.setBuildFiles(buildFiles);
+        const tagInput = document.getElementById('buildTag');
+        if (tag && !tagInput.value.trim()) {
+            tagInput.value = tag;
+        }
+    }
+
+    openPicker() {
+        document.getElementById('buildPicker').style.display = 'block';
+        // Start from the chosen folder, or the home folder
+        this.browse(document.getElementById('buildContext').value.trim());
+    }
+
+    closePicker() {
+        document.getElementById('buildPicker').style.display = 'none';
+    }
+
+    async browse(path) {
+        const list = document.getElementById('buildPickerList');
+        try {
+            const result = await this.apiClient.getHostDirectories(path);
+            this.pickerPath = result.path;
+            this.pickerParent = result.parent;
+            this.pickerBuildFiles = result.build_files;
+            document.getElementById('buildPickerPath').textContent = result.path;
+            document.getElementById('buildPickerUp').disabled = !result.parent;
+
+            list.innerHTML = '';
+            result.build_files.forEach(name => {
+                const item = document.createElement('li');
+                item.className = 'build-picker-file';
+                item.innerHTML = '<i class="fas fa-file-code"></i> ';
+                item.appendChild(document.createTextNode(name));
+                list.appendChild(item);
+            });
+            if (result.directories.length === 0 && result.build_files.length === 0) {
+                list.innerHTML = '<li class="wizard-empty">No folders</li>';
+            }
+            result.directories.forEach(name => {
+                const item = document.createElement('li');
+                item.innerHTML = '<i class="fas fa-folder"></i> ';
+                item.appendChild(document.createTextNode(name));
+                item.addEventListener('click', () => this.browse(this.joinPath(result.path, name)));
+                list.appendChild(item);
+            });
+        } catch (error) {
+            UIHelpers.showToast(error.message, 'error');
+        }
+    }
+
+    joinPath(parent, name) {
+        const separator = parent.includes('\\') && !parent.includes('/') ? '\\' : '/';
+        return parent.endsWith(separator) ? parent + name : parent + separator + name;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    if (!window.socketManager) {
+        window.socketManager = new SocketManager();
+        window.socketManager.init();
+    }
+    window.imageBuilder = new ImageBuilder(new APIClient(), window.socketManager);
+});
@@ -0,0 +1,339 @@
+/**
+ * Image Details Component
+ * Shows an image's tags, layers, config, build history and the containers
+ * created from it, with actions to run, tag, push and export it
+ */
+
+class ImageDetails {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.currentImageId = null;
+        this.image = null;
+
+        this.setupEventListeners();
+    }
+
+    setupEventListeners() {
+        // Rows are rendered elsewhere, so clicks are caught on the table body
+        document.getElementById('imagesTableBody')?.addEventListener('click', (e) => {
+            const row = e.target.closest('tr[data-id]');
+            if (!row || e.target.closest('button')) return;
+            this.show(row.dataset.id);
+        });
+
+        document.getElementById('backToImages')?.addEventListener('click', () => this.hide());
+        document.getElementById('copyImageIdBtn')?.addEventListener('click', () => {
+            if (this.image) {
+                UIHelpers.copyToClipboard(this.image.id, 'Image ID');
+            }
+        });
+
+        document.getElementById('runImageBtn')?.addEventListener('click', () => this.run());
+        document.getElementById('tagImageBtn')?.addEventListener('click', () => this.tag());
+        document.getElementById('pushImageBtn')?.addEventListener('click', () => this.push());
+        document.getElementById('exportImageBtn')?.addEventListener('click', () => this.export());
+    }
+
+    async show(imageId) {
+        this.currentImageId = imageId;
+        this.image = null;
+
+        document.getElementById('imageDetails').style.display = 'block';
+        document.getElementById('imagesList').style.display = 'none';
+        document.getElementById('imageDetailsTitle').textContent = 'Image Details';
+        document.getElementById('detailsImageId').textContent = imageId.substring(0, 12);
+
+        const content = document.getElementById('imageDetailsContent');
+        content.innerHTML = '<div class="placeholder">Loading image details...</div>';
+
+        try {
+            const image = await this.apiClient.inspectImage(imageId);
+            // Another image may have been opened while this one loaded
+            if (imageId !== this.currentImageId) return;
+            this.image = image;
+            this.render();
+        } catch (error) {
+            console.error('Failed to load image details:', error);
+            content.innerHTML = `<div class="placeholder">Failed to load image details: ${this.escapeHtml(error.message)}</div>`;
+        }
+    }
+
+    hide() {
+        document.getElementById('imageDetails').style.display = 'none';
+        document.getElementById('imagesList').style.display = 'block';
+        this.currentImageId = null;
+        this.image = null;
+    }
+
+    /**
+     * The image's name, or its short ID if it has no tags
+     */
+    displayName() {
+        const tags = this.tags();
+        return tags.length ? tags[0] : this.image.id.substring(0, 12);
+    }
+
+    tags() {
+        return (this.image.repo_tags || []).filter(tag => tag !== '<none>:<none>');
+    }
+
+    render() {
+        const image = this.image;
+        const config = image.config || {};
+
+        document.getElementById('imageDetailsTitle').textContent = this.displayName();
+        document.getElementById('detailsImageId').textContent = image.id.substring(0, 12);
+
+        const command = [...(config.entrypoint || []), ...(config.cmd || [])].join(' ');
+        const ports = Object.keys(config.exposed_ports || {}).sort();
+        const tags = this.tags();
+
+        document.getElementById('imageDetailsContent').innerHTML = `
+            <div class="overview-grid">
+                <div class="overview-card">
+                    <h4>Basic Information</h4>
+                    <div class="info-grid">
+                        ${this.infoItem('Image ID', image.id)}
+                        ${this.infoItem('Digest', image.config_digest || '-')}
+                        ${this.infoItem('Created', UIHelpers.formatDate(image.created))}
+                        ${this.infoItem('Last Used', image.last_used ? UIHelpers.formatDate(image.last_used) : 'Never')}
+                        ${this.infoItem('Size', UIHelpers.formatBytes(image.size))}
+                        ${this.infoItem('Platform', image.platform || '-')}
+                    </div>
+                </div>
+
+                <div class="overview-card">
+                    <h4>Tags</h4>
+                    ${tags.length ? `
+                        <div class="image-tags">
+                            ${tags.map(tag => `<span class="image-tag">${this.escapeHtml(tag)}</span>`).join('')}
+                        </div>
+                    ` : '<div class="placeholder">Untagged</div>'}
+                </div>
+
+                <div class="overview-card">
+                    <h4>Configuration</h4>
+                    <div class="info-grid">
+                        ${this.infoItem('Command', command || '-')}
+                        ${this.infoItem('Working Directory', config.working_dir || '/')}
+                        ${this.infoItem('User', config.user || 'root')}
+                        ${this.infoItem('Exposed Ports', ports.length ? ports.join(', ') : 'None')}
+                    </div>
+                    ${(config.env || []).length ? `
+                        <h4 class="image-subheading">Environment</h4>
+                        <div class="image-env">
+                            ${config.env.map(env => `<code>${this.escapeHtml(env)}</code>`).join('')}
+                        </div>
+                    ` : ''}
+                </div>
+
+                <div class="overview-card">
+                    <h4>Containers</h4>
+                    ${this.renderContainers(image.containers || [])}
+                </div>
+            </div>
+
+            <div class="details-section">
+                <h4>Layers</h4>
+                ${this.renderLayers(image.layers || [], image.size)}
+            </div>
+
+            <div class="details-section">
+                <h4>History</h4>
+                ${this.renderHistory(image.history || [], image.layers || [])}
+            </div>
+        `;
+    }
+
+    infoItem(label, value) {
+        return `
+            <div class="info-item">
+                <label>${label}:</label>
+                <span>${this.escapeHtml(String(value))}</span>
+            </div>
+        `;
+    }
+
+    renderContainers(containers) {
+        if (containers.length === 0) {
+            return '<div class="placeholder">No containers use this image</div>';
+        }
+
+        return `
+            <div class="info-grid">
+                ${containers.map(container => `
+                    <div class="info-item">
+                        <label title="${container.id}">${this.escapeHtml(container.name || container.id.substring(0, 12))}</label>
+                        <span class="status-badge status-${container.status.toLowerCase()}">${container.status}</span>
+                    </div>
+                `).join('')}
+            </div>
+        `;
+    }
+
+    /**
+     * Layers base first, each with a bar of its share of the image's size
+     */
+    renderLayers(layers, imageSize) {
+        if (layers.length === 0) {
+            return '<div class="placeholder">The image is a single root filesystem without layers</div>';
+        }
+
+        const total = imageSize || layers.reduce((sum, layer) => sum + (layer.size || 0), 0) || 1;
+        return `
+            <table class="data-table image-layers">
+                <thead>
+                    <tr>
+                        <th>#</th>
+                        <th>Diff ID</th>
+                        <th>Size</th>
+                        <th>Share</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    ${layers.map((layer, index) => {
+                        const share = Math.min((layer.size || 0) / total * 100, 100);
+                        return `
+                            <tr>
+                                <td>${index + 1}</td>
+                                <td><code title="${layer.digest || ''}">${this.shortDigest(layer.diff_id)}</code></td>
+                                <td>${layer.missing ? 'missing' : UIHelpers.formatBytes(layer.size)}</td>
+                                <td>
+                                    <div class="layer-share">
+                                        <div class="layer-share-bar" style="width: ${share.toFixed(1)}%"></div>
+                                    </div>
+                                    <small class="text-muted">${share.toFixed(1)}%</small>
+                                </td>
+                            </tr>
+                        `;
+                    }).join('')}
+                </tbody>
+            </table>
+        `;
+    }
+
+    /**
+     * Build steps oldest first. Steps that added a layer match the layers in
+     * order, which gives each its size when the counts agree.
+     */
+    renderHistory(history, layers) {
+        if (history.length === 0) {
+            return '<div class="placeholder">No build history is recorded for this image</div>';
+        }
+
+        const layerSteps = history.filter(step => !step.empty_layer).length;
+        const sizes = layerSteps === layers.length;
+        let layerIndex = 0;
+
+        return `
+            <table class="data-table image-history">
+                <thead>
+                    <tr>
+                        <th>Created</th>
+                        <th>Created By</th>
+                        <th>Size</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    ${history.map(step => {
+                        let size = '0 B';
+                        if (!step.empty_layer) {
+                            const layer = layers[layerIndex++];
+                            size = sizes && layer && !layer.missing ? UIHelpers.formatBytes(layer.size) : '-';
+                        }
+                        return `
+                            <tr>
+                                <td><small class="text-muted">${step.created ? UIHelpers.formatDate(step.created) : '-'}</small></td>
+                                <td><code>${this.escapeHtml(step.created_by || step.comment || '')}</code></td>
+                                <td>${size}</td>
+                            </tr>
+                        `;
+                    }).join('')}
+                </tbody>
+            </table>
+        `;
+    }
+
+    shortDigest(digest) {
+        const encoded = (digest || '').replace(/^sha256:/, '');
+        return encoded.length > 12 ? encoded.substring(0, 12) : encoded;
+    }
+
+    run() {
+        if (!this.image || !window.runWizard) return;
+        const tags = this.tags();
+        window.runWizard.open(tags.length ? tags[0] : this.image.id);
+    }
+
+    async tag() {
+        if (!this.image) return;
+
+        const tags = this.tags();
+        const target = prompt('Tag the image as (NAME[:TAG]):', tags.length ? tags[0] : '');
+        if (!target || !target.trim()) return;
+
+        try {
+            await this.apiClient.tagImage(this.image.id, target.trim());
+            UIHelpers.showToast(`Tagged ${this.displayName()} as ${target.trim()}`, 'success');
+            document.getElementById('refreshBtn')?.click();
+            this.show(this.currentImageId);
+        } catch (error) {
+            console.error('Failed to tag image:', error);
+            UIHelpers.showToast(`Failed to tag image: ${error.message}`, 'error');
+        }
+    }
+
+    async push() {
+        if (!this.image) return;
+
+        // Pushes go to the registry in the name, so prefer a tag that has one
+        const tags = this.tags();
+        const suggested = tags.find(tag => tag.split('/').length > 1) || tags[0] || '';
+        const name = prompt('Push the image as (REGISTRY/NAME[:TAG]):', suggested);
+        if (!name || !name.trim()) return;
+
+        const button = document.getElementById('pushImageBtn');
+        button.disabled = true;
+        UIHelpers.showToast(`Pushing ${name.trim()}...`, 'info');
+        try {
+            if (!tags.includes(name.trim())) {
+                await this.apiClient.tagImage(this.image.id, name.trim());
+            }
+            await this.apiClient.pushImage(name.trim());
+            UIHelpers.showToast(`Pushed ${name.trim()}`, 'success');
+            if (!tags.includes(name.trim())) {
+                document.getElementById('refreshBtn')?.click();
+                this.show(this.currentImageId);
+            }
+        } catch (error) {
+            console.error('Failed to push image:', error);
+            UIHelpers.showToast(`Failed to push image: ${error.message}`, 'error');
+        } finally {
+            button.disabled = false;
+        }
+    }
+
+    export() {
+        if (!this.image) return;
+
+        // Export by name keeps the tag in the archive; by ID keeps them all
+        const tags = this.tags();
+        const ref = tags.length ? tags[0] : this.image.id;
+        const link = document.createElement('a');
+        link.href = this.apiClient.imageExportUrl(ref, this.displayName());
+        link.download = '';
+        document.body.appendChild(link);
+        link.click();
+        link.remove();
+    }
+
+    escapeHtml(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.imageDetails = new ImageDetails(new APIClient());
+});
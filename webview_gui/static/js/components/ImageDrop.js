@@ -0,0 +1,196 @@
+/**
+ * Image Drop Component
+ * Loads image archives dropped onto the Images tab with servin load, and
+ * opens the build section for a folder with a Buildfile or Dockerfile
+ */
+
+class ImageDrop {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.modal = document.getElementById('imageDropModal');
+        this.busy = false;
+
+        this.setupDropZone();
+        document.getElementById('closeImageDrop')?.addEventListener('click', () => this.close());
+        document.getElementById('imageDropDoneBtn')?.addEventListener('click', () => this.close());
+    }
+
+    setupDropZone() {
+        const section = document.getElementById('imagesSection');
+        if (!section) return;
+
+        let depth = 0;
+        const accepts = (event) => event.dataTransfer && Array.from(event.dataTransfer.types).includes('Files') &&
+            !window.readOnlyMode?.enabled;
+
+        section.addEventListener('dragenter', (event) => {
+            if (!accepts(event)) return;
+            event.preventDefault();
+            depth++;
+            section.classList.add('drop-target');
+        });
+
+        section.addEventListener('dragover', (event) => {
+            if (!accepts(event)) return;
+            event.preventDefault();
+            event.dataTransfer.dropEffect = 'copy';
+        });
+
+        section.addEventListener('dragleave', () => {
+            depth = Math.max(0, depth - 1);
+            if (depth === 0) {
+                section.classList.remove('drop-target');
+            }
+        });
+
+        section.addEventListener('drop', async (event) => {
+            if (!accepts(event)) return;
+            event.preventDefault();
+            depth = 0;
+            section.classList.remove('drop-target');
+
+            if (this.busy) {
+                UIHelpers.showToast('Wait for the dropped files to finish first', 'warning');
+                return;
+            }
+            const files = await UIHelpers.collectDroppedFiles(event.dataTransfer);
+            await this.handleDrop(files);
+        });
+    }
+
+    /**
+     * A dropped folder is built, dropped files are loaded as archives
+     */
+    async handleDrop(files) {
+        const folders = new Set(files.filter(({ name }) => name.includes('/')).map(({ name }) => name.split('/')[0]));
+        if (folders.size > 1 || (folders.size === 1 && files.some(({ name }) => !name.includes('/')))) {
+            UIHelpers.showToast('Drop one folder to build, or image archives to load', 'warning');
+            return;
+        }
+        if (folders.size === 1) {
+            await this.build([...folders][0], files);
+        } else if (files.length > 0) {
+            await this.load(files.map(({ file }) => file));
+        }
+    }
+
+    async build(folder, files) {
+        const hasBuildFile = files.some(({ name }) => {
+            const [, file, ...rest] = name.split('/');
+            return rest.length === 0 && this.isBuildFile(file);
+        });
+        if (!hasBuildFile) {
+            UIHelpers.showToast(`${this.escapeHtml(folder)} has no Buildfile or Dockerfile`, 'error');
+            return;
+        }
+
+        this.open('Preparing Build');
+        this.setStatus(`Copying ${folder}...`, `${files.length} files`);
+        try {
+            const result = await this.apiClient.stageBuildContext(files, (fraction) => this.setProgress(fraction, ''));
+            this.finish();
+            this.close();
+            window.imageBuilder?.useContext(result.path, result.build_files, this.suggestTag(folder));
+        } catch (error) {
+            this.setProgress(1, 'failed');
+            this.setStatus(`Failed to copy ${folder}: ${error.message}`, '');
+            this.finish();
+        }
+    }
+
+    async load(archives) {
+        this.open(archives.length === 1 ? 'Loading Image' : 'Loading Images');
+        let loaded = 0;
+        let failed = 0;
+
+        for (const [index, archive] of archives.entries()) {
+            const count = archives.length > 1 ? `Archive ${index + 1} of ${archives.length}` : '';
+            this.setStatus(`Uploading ${archive.name}...`, count);
+            this.setProgress(0, '');
+            try {
+                const result = await this.apiClient.loadImageArchive(archive, (fraction) => {
+                    this.setProgress(fraction, '');
+                    if (fraction >= 1) this.setStatus(`Loading ${archive.name}...`, count);
+                });
+                result.images.forEach(name => this.addResult(`Loaded ${name}`, 'done'));
+                loaded += result.images.length;
+            } catch (error) {
+                this.addResult(`${archive.name}: ${error.message}`, 'failed');
+                failed++;
+            }
+        }
+
+        this.setProgress(1, failed > 0 ? 'failed' : 'done');
+        const summary = `Loaded ${loaded} image${loaded === 1 ? '' : 's'}`;
+        this.setStatus(failed > 0 ? `${summary}, ${failed} archive${failed === 1 ? '' : 's'} failed` : summary, '');
+        UIHelpers.showToast(this.escapeHtml(summary), failed > 0 ? 'warning' : 'success');
+        this.finish();
+        if (loaded > 0) {
+            document.getElementById('refreshBtn')?.click();
+        }
+    }
+
+    open(title) {
+        this.busy = true;
+        document.getElementById('imageDropTitle').textContent = title;
+        document.getElementById('imageDropResults').innerHTML = '';
+        document.getElementById('imageDropDoneBtn').disabled = true;
+        this.setProgress(0, '');
+        this.modal.style.display = 'block';
+    }
+
+    finish() {
+        this.busy = false;
+        document.getElementById('imageDropDoneBtn').disabled = false;
+    }
+
+    close() {
+        if (this.busy) return;
+        this.modal.style.display = 'none';
+    }
+
+    setStatus(text, count) {
+        document.getElementById('imageDropStatus').textContent = text;
+        document.getElementById('imageDropCount').textContent = count;
+    }
+
+    setProgress(fraction, state) {
+        const bar = document.getElementById('imageDropBar');
+        bar.style.width = `${Math.round(fraction * 100)}%`;
+        bar.parentElement.className = `download-progress ${state}`;
+    }
+
+    addResult(text, state) {
+        const item = document.createElement('li');
+        item.className = state;
+        item.innerHTML = `<i class="fas ${state === 'done' ? 'fa-check' : 'fa-times'}"></i> `;
+        item.appendChild(document.createTextNode(text));
+        document.getElementById('imageDropResults').appendChild(item);
+    }
+
+    /**
+     * Whether servin build reads a file of this name, as the GUI's host
+     * folder listing decides
+     */
+    isBuildFile(name) {
+        return name.endsWith('Buildfile') || name === 'Dockerfile' ||
+            name.startsWith('Dockerfile.') || name.endsWith('.Dockerfile');
+    }
+
+    /**
+     * An image name from a folder name, which may have capitals or spaces
+     */
+    suggestTag(folder) {
+        return folder.toLowerCase().replace(/[^a-z0-9._-]+/g, '-').replace(/^[^a-z0-9]+|[^a-z0-9]+$/g, '');
+    }
+
+    escapeHtml(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.imageDrop = new ImageDrop(new APIClient());
+});
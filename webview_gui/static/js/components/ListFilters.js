@@ -0,0 +1,340 @@
+/**
+ * List Filters Component
+ * Searches, filters and sorts the container, image and volume lists. Filters
+ * take the KEY=VALUE form of servin's --filter flags and show as chips; the
+ * list data they need is fetched whenever a filtered or sorted list is
+ * re-rendered.
+ */
+
+class ListFilters {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        // Render order of rows, restored when a list is no longer sorted
+        this.order = new WeakMap();
+        this.rendered = 0;
+
+        this.lists = {
+            containers: {
+                noun: 'container',
+                load: () => this.apiClient.getContainers(),
+                rowKey: row => row.dataset.id,
+                itemKey: container => container.id,
+                filters: {
+                    status: (container, value) => container.status === value,
+                    name: (container, value) => container.name.includes(value),
+                    image: (container, value) => this.matchesImage(container.image, value),
+                    label: (container, value) => this.matchesLabel(container.labels, value)
+                },
+                sorts: {
+                    name: container => container.name,
+                    image: container => container.image,
+                    status: container => container.status,
+                    created: container => container.created
+                }
+            },
+            images: {
+                noun: 'image',
+                load: () => this.apiClient.getImages(),
+                // An image has a row for each of its tags
+                rowKey: row => row.dataset.id && `${row.dataset.id} ${this.imageTag(row)}`,
+                itemKey: image => `${image.id} ${image.tag}`,
+                filters: {
+                    image: (image, value) => this.matchesImage(`${image.repository}:${image.tag}`, value),
+                    dangling: (image, value) => this.matchesBool(image.tag === '<none>', value),
+                    used: (image, value) => this.matchesBool(image.containers > 0, value)
+                },
+                sorts: {
+                    repository: image => image.repository,
+                    tag: image => image.tag,
+                    created: image => image.created,
+                    size: image => image.size
+                }
+            },
+            volumes: {
+                noun: 'volume',
+                load: () => this.apiClient.getVolumes(),
+                rowKey: row => row.dataset.name,
+                itemKey: volume => volume.name,
+                filters: {
+                    dangling: (volume, value) => this.matchesBool(volume.containers === 0, value),
+                    driver: (volume, value) => volume.driver === value,
+                    label: (volume, value) => this.matchesLabel(volume.labels, value)
+                },
+                sorts: {
+                    name: volume => volume.name,
+                    driver: volume => volume.driver,
+                    created: volume => volume.created
+                }
+            }
+        };
+
+        Object.entries(this.lists).forEach(([kind, list]) => {
+            Object.assign(list, {
+                kind,
+                body: document.getElementById(`${kind}TableBody`),
+                search: '',
+                active: [],
+                sort: null,
+                items: null,
+                generation: 0
+            });
+            this.setupList(list);
+        });
+    }
+
+    setupList(list) {
+        if (!list.body) return;
+
+        // Rows are rendered elsewhere; each render is searched, filtered and
+        // sorted again, with fresh data if filters or sorting need it. Rows
+        // this component moved are not a new render.
+        new MutationObserver((records) => {
+            const rendered = records.some(record => [...record.addedNodes].some(node => !this.order.has(node)));
+            if (rendered) this.apply(list, true);
+        }).observe(list.body, { childList: true });
+
+        document.getElementById(`${list.noun}Search`)?.addEventListener('input', (e) => {
+            list.search = e.target.value.trim().toLowerCase();
+            this.apply(list, false);
+        });
+
+        document.querySelectorAll(`#${list.kind}Filters .filter-chip[data-filter]`).forEach(chip => {
+            chip.addEventListener('click', () => this.toggle(list, chip.dataset.filter));
+        });
+
+        const input = document.getElementById(`${list.kind}FilterInput`);
+        if (input) {
+            input.title = `Filter by ${this.keyList(list)} (KEY=VALUE, Enter to add)`;
+            input.addEventListener('keydown', (e) => {
+                if (e.key !== 'Enter') return;
+                if (this.add(list, input.value.trim())) {
+                    input.value = '';
+                }
+            });
+        }
+
+        document.getElementById(`${list.kind}Table`)?.querySelectorAll('th[data-sort]').forEach(header => {
+            header.title = 'Sort';
+            header.addEventListener('click', () => this.sortBy(list, header.dataset.sort));
+        });
+    }
+
+    keyList(list) {
+        const keys = Object.keys(list.filters);
+        return `${keys.slice(0, -1).join(', ')} or ${keys[keys.length - 1]}`;
+    }
+
+    /**
+     * Add a KEY=VALUE filter, reporting why one is not understood
+     */
+    add(list, filter) {
+        if (!filter) return false;
+
+        const [key, value] = this.split(filter);
+        if (value === undefined || value === '') {
+            UIHelpers.showToast(`Invalid filter '${filter}' (expected KEY=VALUE)`, 'error');
+            return false;
+        }
+        if (!list.filters[key]) {
+            UIHelpers.showToast(`Unsupported filter '${key}' (use ${this.keyList(list)})`, 'error');
+            return false;
+        }
+        if (['dangling', 'used'].includes(key) && value !== 'true' && value !== 'false') {
+            UIHelpers.showToast(`Invalid ${key} filter '${value}' (expected true or false)`, 'error');
+            return false;
+        }
+
+        if (!list.active.includes(filter)) {
+            list.active.push(filter);
+            this.renderChips(list);
+            this.apply(list, false);
+        }
+        return true;
+    }
+
+    remove(list, filter) {
+        list.active = list.active.filter(active => active !== filter);
+        this.renderChips(list);
+        this.apply(list, false);
+    }
+
+    toggle(list, filter) {
+        if (list.active.includes(filter)) {
+            this.remove(list, filter);
+        } else {
+            this.add(list, filter);
+        }
+    }
+
+    renderChips(list) {
+        const quick = [];
+        document.querySelectorAll(`#${list.kind}Filters .filter-chip[data-filter]`).forEach(chip => {
+            quick.push(chip.dataset.filter);
+            chip.classList.toggle('active', list.active.includes(chip.dataset.filter));
+        });
+
+        const container = document.getElementById(`${list.kind}FilterChips`);
+        if (!container) return;
+        container.innerHTML = '';
+        list.active.filter(filter => !quick.includes(filter)).forEach(filter => {
+            const chip = document.createElement('span');
+            chip.className = 'filter-chip active';
+            chip.textContent = filter;
+
+            const remove = document.createElement('button');
+            remove.className = 'filter-chip-remove';
+            remove.title = 'Remove filter';
+            remove.innerHTML = '<i class="fas fa-times"></i>';
+            remove.addEventListener('click', () => this.remove(list, filter));
+            chip.appendChild(remove);
+            container.appendChild(chip);
+        });
+    }
+
+    /**
+     * Sort by a column, ascending then descending, then back to the order
+     * the list was rendered in
+     */
+    sortBy(list, key) {
+        if (!list.sort || list.sort.key !== key) {
+            list.sort = { key, descending: false };
+        } else if (!list.sort.descending) {
+            list.sort.descending = true;
+        } else {
+            list.sort = null;
+        }
+
+        document.getElementById(`${list.kind}Table`)?.querySelectorAll('th[data-sort]').forEach(header => {
+            const sorted = list.sort && list.sort.key === header.dataset.sort;
+            header.classList.toggle('sorted-asc', Boolean(sorted && !list.sort.descending));
+            header.classList.toggle('sorted-desc', Boolean(sorted && list.sort.descending));
+        });
+        this.apply(list, false);
+    }
+
+    async apply(list, rendered) {
+        const rows = [...list.body.querySelectorAll('tr')].filter(row => list.rowKey(row));
+        if (rendered) {
+            rows.forEach(row => {
+                if (!this.order.has(row)) this.order.set(row, this.rendered++);
+            });
+        }
+
+        const generation = ++list.generation;
+        if (list.active.length > 0 || list.sort) {
+            if (rendered || !list.items) {
+                try {
+                    const items = await list.load();
+                    list.items = new Map(items.map(item => [list.itemKey(item), item]));
+                } catch (error) {
+                    UIHelpers.showToast(`Failed to filter ${list.kind}: ${error.message}`, 'error');
+                    return;
+                }
+                // A newer render or change has been applied meanwhile
+                if (generation !== list.generation) return;
+            }
+        } else if (rendered) {
+            list.items = null;
+        }
+
+        let visible = 0;
+        rows.forEach(row => {
+            const shown = this.matches(list, row);
+            row.style.display = shown ? '' : 'none';
+            if (shown) visible++;
+        });
+
+        const noMatch = document.getElementById(`${list.kind}NoMatch`);
+        if (noMatch) {
+            noMatch.style.display = rows.length > 0 && visible === 0 ? '' : 'none';
+        }
+
+        this.reorder(list, rows);
+    }
+
+    /**
+     * Rows match the search anywhere in their text; filters on one key match
+     * any of their values, and filters on different keys must all match
+     */
+    matches(list, row) {
+        if (list.search && !row.textContent.toLowerCase().includes(list.search)) {
+            return false;
+        }
+
+        const item = list.items && list.items.get(list.rowKey(row));
+        // Rows the data does not have yet are not hidden by filters
+        if (!item) return true;
+
+        const byKey = new Map();
+        list.active.forEach(filter => {
+            const [key, value] = this.split(filter);
+            byKey.set(key, [...(byKey.get(key) || []), value]);
+        });
+        return [...byKey].every(([key, values]) => values.some(value => list.filters[key](item, value)));
+    }
+
+    reorder(list, rows) {
+        const sort = list.sort && list.sort.key in list.sorts ? list.sort : null;
+        const value = row => {
+            const item = list.items && list.items.get(list.rowKey(row));
+            return item ? list.sorts[sort.key](item) : undefined;
+        };
+
+        const sorted = [...rows].sort((a, b) => {
+            if (sort) {
+                const compared = this.compare(value(a), value(b));
+                if (compared !== 0) return sort.descending ? -compared : compared;
+            }
+            return this.order.get(a) - this.order.get(b);
+        });
+
+        // Moving rows re-renders nothing, so leave them when already in order
+        if (sorted.some((row, index) => row !== rows[index])) {
+            sorted.forEach(row => list.body.appendChild(row));
+        }
+    }
+
+    compare(a, b) {
+        if (a === b) return 0;
+        if (a === undefined || a === null) return 1;
+        if (b === undefined || b === null) return -1;
+        if (typeof a === 'number' && typeof b === 'number') return a - b;
+        return String(a).localeCompare(String(b), undefined, { numeric: true, sensitivity: 'base' });
+    }
+
+    split(filter) {
+        const index = filter.indexOf('=');
+        return index === -1 ? [filter, undefined] : [filter.substring(0, index), filter.substring(index + 1)];
+    }
+
+    imageTag(row) {
+        const repository = row.querySelector('strong');
+        const cell = repository && repository.closest('td');
+        return cell && cell.nextElementSibling ? cell.nextElementSibling.textContent.trim() : '';
+    }
+
+    /**
+     * An image matches by its full reference or by repository, so nginx
+     * matches nginx:latest
+     */
+    matchesImage(reference, value) {
+        return reference === value || reference.startsWith(`${value}:`) || reference.startsWith(`${value}@`);
+    }
+
+    /**
+     * label=KEY matches any value of the label, label=KEY=VALUE just that one
+     */
+    matchesLabel(labels, value) {
+        const [key, wanted] = this.split(value);
+        labels = labels || {};
+        return key in labels && (wanted === undefined || labels[key] === wanted);
+    }
+
+    matchesBool(actual, value) {
+        return value === 'true' ? actual : !actual;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.listFilters = new ListFilters(new APIClient());
+});
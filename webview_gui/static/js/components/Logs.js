@@ -1,21 +1,33 @@
 /**
  * Logs Component
- * Handles container log streaming and display
+ * Streams a container's logs into the Logs tab, with search, level filters,
+ * timestamps and export
  */
 
+// Levels from most to least severe; a filter shows a level and those above it
+const LOG_LEVELS = ['error', 'warn', 'info', 'debug'];
+
 class LogsComponent {
     constructor(apiClient, socketManager) {
         this.apiClient = apiClient;
         this.socketManager = socketManager;
         this.currentContainerId = null;
         this.isStreaming = false;
-        this.autoScroll = true; // Enable auto-scroll by default
-        
+        this.follow = true;
+        this.showTimestamps = false;
+        this.search = '';
+        this.level = 'debug';
+        // Lines kept in the tab; older ones are dropped as new ones arrive
+        this.maxLines = 5000;
+        this.tail = 1000;
+        this.lines = [];
+
         this.init();
     }
 
     init() {
         this.setupSocketHandlers();
+        this.setupControls();
     }
 
     setupSocketHandlers() {
@@ -24,71 +36,99 @@ class LogsComponent {
         this.socketManager.on('logs_stopped', (data) => this.handleLogsStopped(data));
     }
 
-    async loadLogs(containerId) {
-        this.currentContainerId = containerId;
-        const logsContent = document.getElementById('logsContent');
-        
-        if (!logsContent) return;
-        
-        // Only setup controls if not already set up for this container
-        const existingLogsText = document.getElementById('logsText');
-        if (!existingLogsText) {
-            logsContent.innerHTML = '<div class="loading">Loading logs...</div>';
-            this.setupLogsControls();
-        }
-        
-        try {
-            // Start log streaming automatically if not already streaming
-            if (!this.isStreaming) {
-                this.startStreaming();
-            }
-        } catch (error) {
-            console.error('Failed to load logs:', error);
-            logsContent.innerHTML = '<div class="error">Failed to load logs</div>';
+    setupControls() {
+        const followToggle = document.getElementById('logsFollow');
+        const timestampsToggle = document.getElementById('logsTimestamps');
+        const searchInput = document.getElementById('logsSearch');
+        const levelSelect = document.getElementById('logsLevel');
+        const exportBtn = document.getElementById('logsExportBtn');
+
+        if (followToggle) {
+            followToggle.addEventListener('change', () => {
+                this.follow = followToggle.checked;
+                if (this.follow) {
+                    this.startStreaming();
+                } else {
+                    this.stopStreaming();
+                }
+            });
+        }
+
+        if (timestampsToggle) {
+            timestampsToggle.addEventListener('change', () => {
+                this.showTimestamps = timestampsToggle.checked;
+                const logsText = document.getElementById('logsText');
+                if (logsText) {
+                    logsText.classList.toggle('show-timestamps', this.showTimestamps);
+                }
+            });
+        }
+
+        if (searchInput) {
+            let timer = null;
+            searchInput.addEventListener('input', () => {
+                clearTimeout(timer);
+                timer = setTimeout(() => {
+                    this.search = searchInput.value.trim();
+                    this.render();
+                }, 200);
+            });
+        }
+
+        if (levelSelect) {
+            levelSelect.addEventListener('change', () => {
+                this.level = levelSelect.value;
+                this.render();
+            });
+        }
+
+        if (exportBtn) {
+            exportBtn.addEventListener('click', () => this.export());
         }
     }
 
-    setupLogsControls() {
-        const logsContent = document.getElementById('logsContent');
-        if (!logsContent) return;
+    loadLogs(containerId) {
+        // Keep streaming the same container across tab switches
+        if (containerId === this.currentContainerId && (this.isStreaming || !this.follow)) {
+            return;
+        }
 
-        // Set up the logs stream HTML structure
-        logsContent.innerHTML = `
-            <div class="logs-stream">
-                <pre class="logs-text" id="logsText"></pre>
-            </div>
-        `;
+        this.stopStreaming();
+        this.currentContainerId = containerId;
+        this.startStreaming();
     }
 
     startStreaming() {
-        if (!this.currentContainerId || this.isStreaming) return;
+        if (!this.currentContainerId) return;
 
         const logsContent = document.getElementById('logsContent');
-        const existingLogsText = document.getElementById('logsText');
-        
         if (!logsContent) return;
 
-        // Only create the structure if it doesn't exist
-        if (!existingLogsText) {
-            logsContent.innerHTML = `
-                <div class="logs-stream">
-                    <pre class="logs-text" id="logsText"></pre>
-                </div>
-            `;
-        }
+        // The stream starts with the last lines, so it replaces those shown
+        this.lines = [];
+        logsContent.innerHTML = `
+            <div class="logs-stream">
+                <div class="logs-text${this.showTimestamps ? ' show-timestamps' : ''}" id="logsText"></div>
+            </div>
+        `;
+        this.updateMatchCount();
+        this.setStatus('Loading', '');
 
-        // Start streaming via WebSocket
         this.socketManager.emit('start_logs', {
-            container_id: this.currentContainerId
+            container_id: this.currentContainerId,
+            tail: this.tail,
+            follow: this.follow
         });
     }
 
     stopStreaming() {
-        if (!this.currentContainerId || !this.isStreaming) return;
+        if (!this.currentContainerId) return;
 
         this.socketManager.emit('stop_logs', {
             container_id: this.currentContainerId
         });
+        this.isStreaming = false;
+        this.setStatus('Paused', '');
     }
 
     handleLogData(data) {
@@ -97,60 +137,225 @@ class LogsComponent {
         const logsText = document.getElementById('logsText');
         if (!logsText) return;
 
-        // The server sends log data in the 'data' property, not 'logs'
-        const logContent = data.data || '';
+        const logsContent = document.getElementById('logsContent');
+        const atBottom = logsContent &&
+            logsContent.scrollHeight - logsContent.scrollTop - logsContent.clientHeight < 40;
 
-        if (data.type === 'initial') {
-            // Replace all content with initial logs
-            logsText.textContent = logContent;
-        } else {
-            // Append new log lines
-            logsText.textContent += logContent + '\n';
+        const fragment = document.createDocumentFragment();
+        (data.lines || []).forEach(entry => {
+            const line = {
+                time: entry.time,
+                stream: entry.stream,
+                text: entry.line,
+                level: this.detectLevel(entry.line),
+                node: null
+            };
+            this.lines.push(line);
+            if (this.matches(line)) {
+                line.node = this.renderLine(line);
+                fragment.appendChild(line.node);
+            }
+        });
+        logsText.appendChild(fragment);
+
+        // Drop the oldest lines once there are too many
+        const excess = this.lines.length - this.maxLines;
+        if (excess > 0) {
+            this.lines.splice(0, excess).forEach(line => {
+                if (line.node) line.node.remove();
+            });
         }
 
-        // Auto-scroll to bottom if enabled
-        this.autoScrollToBottom();
+        this.updateMatchCount();
+
+        // Follow new lines unless the user has scrolled up to read
+        if (this.follow && atBottom) {
+            this.autoScrollToBottom();
+        }
     }
 
     handleLogsStarted(data) {
+        if (data.container_id !== this.currentContainerId) return;
+
         console.log('Logs streaming started:', data);
         this.isStreaming = true;
-        this.updateStreamingStatus();
+        if (data.follow) {
+            this.setStatus('Following', 'streaming');
+        }
     }
 
     handleLogsStopped(data) {
+        if (data.container_id !== this.currentContainerId) return;
+
         console.log('Logs streaming stopped:', data);
         this.isStreaming = false;
-        this.updateStreamingStatus();
+
+        // A stream stopped from here has no error field
+        if (data.error === undefined) return;
+
+        const logsText = document.getElementById('logsText');
+        if (data.error) {
+            this.setStatus('Error', 'error');
+            this.addNote(logsText, `Failed to read logs: ${data.error}`, 'error');
+            return;
+        }
+
+        this.setStatus(this.follow ? 'Container not running' : 'Loaded', '');
+        if (!this.lines.length) {
+            this.addNote(logsText, 'No logs available for this container', '');
+        }
+    }
+
+    addNote(logsText, message, className) {
+        if (!logsText) return;
+
+        const note = document.createElement('div');
+        note.className = `log-note ${className}`;
+        note.textContent = message;
+        logsText.appendChild(note);
     }
 
-    updateStreamingStatus() {
+    /**
+     * Guess a line's level from the words in it; lines without one are info
+     */
+    detectLevel(text) {
+        if (/\b(fatal|panic|crit(ical)?|err(or)?)\b/i.test(text)) return 'error';
+        if (/\bwarn(ing)?\b/i.test(text)) return 'warn';
+        if (/\b(debug|trace)\b/i.test(text)) return 'debug';
+        return 'info';
+    }
+
+    matches(line) {
+        if (LOG_LEVELS.indexOf(line.level) > LOG_LEVELS.indexOf(this.level)) {
+            return false;
+        }
+        return !this.search || line.text.toLowerCase().includes(this.search.toLowerCase());
+    }
+
+    render() {
+        const logsText = document.getElementById('logsText');
+        if (!logsText) return;
+
+        const fragment = document.createDocumentFragment();
+        this.lines.forEach(line => {
+            line.node = this.matches(line) ? this.renderLine(line) : null;
+            if (line.node) fragment.appendChild(line.node);
+        });
+        logsText.innerHTML = '';
+        logsText.appendChild(fragment);
+
+        this.updateMatchCount();
+        if (this.follow) {
+            this.autoScrollToBottom();
+        }
+    }
+
+    renderLine(line) {
+        const row = document.createElement('div');
+        row.className = `log-line level-${line.level} stream-${line.stream}`;
+
+        const time = document.createElement('span');
+        time.className = 'log-time';
+        time.textContent = line.time ? new Date(line.time).toLocaleString() : '';
+        time.title = line.stream;
+        row.appendChild(time);
+
+        const text = document.createElement('span');
+        text.className = 'log-message';
+        this.appendHighlighted(text, line.text);
+        row.appendChild(text);
+        return row;
+    }
+
+    /**
+     * Add text to an element with the search matches marked
+     */
+    appendHighlighted(element, text) {
+        if (!this.search) {
+            element.textContent = text;
+            return;
+        }
+
+        const lower = text.toLowerCase();
+        const needle = this.search.toLowerCase();
+        let start = 0;
+        let index = lower.indexOf(needle);
+        while (index !== -1) {
+            element.appendChild(document.createTextNode(text.slice(start, index)));
+            const mark = document.createElement('mark');
+            mark.textContent = text.slice(index, index + needle.length);
+            element.appendChild(mark);
+            start = index + needle.length;
+            index = lower.indexOf(needle, start);
+        }
+        element.appendChild(document.createTextNode(text.slice(start)));
+    }
+
+    updateMatchCount() {
+        const count = document.getElementById('logsMatchCount');
+        if (!count) return;
+
+        if (this.search || this.level !== 'debug') {
+            const shown = this.lines.filter(line => line.node).length;
+            count.textContent = `${shown} of ${this.lines.length} lines`;
+        } else {
+            count.textContent = `${this.lines.length} lines`;
+        }
+    }
+
+    setStatus(text, state) {
         const statusIndicator = document.querySelector('.logs-status .status-indicator');
-        const statusText = document.querySelector('.logs-status span:last-child');
+        const statusText = document.querySelector('.logs-status .status-text');
 
         if (statusIndicator) {
-            statusIndicator.className = `status-indicator ${this.isStreaming ? 'streaming' : ''}`;
+            statusIndicator.className = `status-indicator ${state}`;
         }
 
         if (statusText) {
-            statusText.textContent = this.isStreaming ? 'Streaming' : 'Stopped';
+            statusText.textContent = text;
         }
     }
 
     autoScrollToBottom() {
         const logsContent = document.getElementById('logsContent');
-        if (logsContent && this.autoScroll) {
+        if (logsContent) {
             logsContent.scrollTop = logsContent.scrollHeight;
         }
     }
 
-    cleanup() {
-        if (this.isStreaming) {
-            this.stopStreaming();
+    async export() {
+        if (!this.currentContainerId) return;
+
+        try {
+            const blob = await this.apiClient.exportContainerLogs(this.currentContainerId, this.showTimestamps);
+            const url = URL.createObjectURL(blob);
+            const link = document.createElement('a');
+            link.href = url;
+            link.download = `${this.currentContainerId.substring(0, 12)}-logs.txt`;
+            document.body.appendChild(link);
+            link.click();
+            link.remove();
+            setTimeout(() => URL.revokeObjectURL(url), 1000);
+        } catch (error) {
+            console.error('Failed to export logs:', error);
+            UIHelpers.showToast(`Failed to export logs: ${error.message}`, 'error');
         }
+    }
+
+    cleanup() {
+        this.stopStreaming();
         this.currentContainerId = null;
+        this.lines = [];
     }
 }
 
+document.addEventListener('DOMContentLoaded', () => {
+    if (!window.socketManager) {
+        window.socketManager = new SocketManager();
+        window.socketManager.init();
+    }
+    window.logs = new LogsComponent(new APIClient(), window.socketManager);
+});
+
 // Export the component
-window.LogsComponent = LogsComponent;
\ No newline at end of file
+window.LogsComponent = LogsComponent;
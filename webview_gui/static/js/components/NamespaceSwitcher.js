@@ -0,0 +1,71 @@
+/**
+ * Namespace Switcher Component
+ * Scopes the containers and images shown in the GUI to one namespace
+ */
+
+class NamespaceSwitcher {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.select = document.getElementById('namespaceSelect');
+
+        if (this.select) {
+            this.select.addEventListener('change', () => this.switchTo(this.select.value));
+            this.load();
+        }
+    }
+
+    async load() {
+        try {
+            const data = await this.apiClient.getNamespaces();
+            this.render(data.namespaces || [], data.current);
+        } catch (error) {
+            console.warn('Could not load namespaces:', error);
+            this.render(['default'], 'default');
+        }
+    }
+
+    render(namespaces, current) {
+        this.select.innerHTML = '';
+        namespaces.forEach((name) => {
+            const option = document.createElement('option');
+            option.value = name;
+            option.textContent = name;
+            option.selected = name === current;
+            this.select.appendChild(option);
+        });
+        this.current = current;
+    }
+
+    async switchTo(namespace) {
+        if (namespace === this.current) {
+            return;
+        }
+
+        try {
+            await this.apiClient.setNamespace(namespace);
+            this.current = namespace;
+
+            // Reload every list for the new namespace
+            const refreshBtn = document.getElementById('refreshBtn');
+            if (refreshBtn) {
+                refreshBtn.click();
+            }
+            if (window.UIHelpers && UIHelpers.showToast) {
+                UIHelpers.showToast(`Switched to namespace ${namespace}`, 'success');
+            }
+        } catch (error) {
+            console.error('Failed to switch namespace:', error);
+            this.select.value = this.current;
+            if (window.UIHelpers && UIHelpers.showToast) {
+                UIHelpers.showToast(`Failed to switch to namespace ${namespace}`, 'error');
+            }
+        }
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.namespaceSwitcher = new NamespaceSwitcher(new APIClient());
+});
+
+// Export for use in other modules
+window.NamespaceSwitcher = NamespaceSwitcher;
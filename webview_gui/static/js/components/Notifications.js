@@ -0,0 +1,128 @@
+/**
+ * Notifications Component
+ * Shows the events the GUI's notification watcher reports: containers that
+ * exit unexpectedly, failing health checks, finished image pulls and a
+ * stopped VM engine. Events the tray icon did not already show natively are
+ * shown as desktop notifications while the window is in the background.
+ */
+
+class Notifications {
+    constructor(apiClient, socketManager) {
+        this.apiClient = apiClient;
+        this.socketManager = socketManager;
+        this.modal = document.getElementById('notificationSettingsModal');
+
+        this.socketManager.on('notification', (event) => this.show(event));
+
+        document.getElementById('notificationSettingsBtn')?.addEventListener('click', () => this.openSettings());
+        document.getElementById('closeNotificationSettings')?.addEventListener('click', () => this.closeSettings());
+        document.getElementById('doneNotificationSettings')?.addEventListener('click', () => this.closeSettings());
+        document.getElementById('testNotificationBtn')?.addEventListener('click', () => this.show({
+            title: 'Servin',
+            message: 'Notifications are working',
+            level: 'info',
+            native: false
+        }, true));
+        this.modal?.addEventListener('click', (e) => {
+            if (e.target === this.modal) this.closeSettings();
+        });
+    }
+
+    /**
+     * Show an event in the window, and on the desktop unless the tray did
+     */
+    show(event, always = false) {
+        UIHelpers.showToast(`${event.title}: ${event.message}`, event.level || 'info');
+
+        if (event.native || !this.canNotify()) return;
+        if (!always && document.hasFocus()) return;
+
+        const notification = new Notification(event.title, { body: event.message });
+        notification.onclick = () => {
+            window.focus();
+            if (event.container_id) {
+                window.dockerGUI?.showContainerDetails(event.container_id);
+            }
+            notification.close();
+        };
+    }
+
+    canNotify() {
+        return 'Notification' in window && Notification.permission === 'granted';
+    }
+
+    async openSettings() {
+        try {
+            const { settings, types } = await this.apiClient.getNotificationSettings();
+            this.renderSettings(settings, types);
+        } catch (error) {
+            UIHelpers.showToast(`Failed to load notification settings: ${error.message}`, 'error');
+            return;
+        }
+
+        // Opening the settings is the user action browsers want before
+        // they ask for permission
+        if ('Notification' in window && Notification.permission === 'default') {
+            await Notification.requestPermission().catch(() => {});
+        }
+        this.renderPermission();
+        this.modal.style.display = 'block';
+    }
+
+    closeSettings() {
+        if (this.modal) this.modal.style.display = 'none';
+    }
+
+    renderSettings(settings, types) {
+        const list = document.getElementById('notificationSettingsList');
+        if (!list) return;
+        list.innerHTML = '';
+
+        Object.entries(types).forEach(([type, label]) => {
+            const setting = document.createElement('label');
+            setting.className = 'notification-setting';
+
+            const input = document.createElement('input');
+            input.type = 'checkbox';
+            input.checked = Boolean(settings[type]);
+            input.addEventListener('change', () => this.update(type, input));
+
+            const text = document.createElement('span');
+            text.textContent = label;
+
+            setting.append(input, text);
+            list.appendChild(setting);
+        });
+    }
+
+    renderPermission() {
+        const note = document.getElementById('notificationPermission');
+        if (!note) return;
+
+        if (!('Notification' in window)) {
+            note.textContent = 'This window cannot show desktop notifications; events are shown inside it and by the tray icon where there is one.';
+        } else if (Notification.permission === 'denied') {
+            note.textContent = 'Desktop notifications are blocked for this window; events are shown inside it and by the tray icon where there is one.';
+        } else {
+            note.textContent = '';
+        }
+        note.hidden = !note.textContent;
+    }
+
+    async update(type, input) {
+        try {
+            await this.apiClient.updateNotificationSettings({ [type]: input.checked });
+        } catch (error) {
+            input.checked = !input.checked;
+            UIHelpers.showToast(`Failed to save notification settings: ${error.message}`, 'error');
+        }
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    if (!window.socketManager) {
+        window.socketManager = new SocketManager();
+        window.socketManager.init();
+    }
+    window.notifications = new Notifications(new APIClient(), window.socketManager);
+});
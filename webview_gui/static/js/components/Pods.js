@@ -0,0 +1,219 @@
+/**
+ * Pods Component
+ * Lists the pod sandboxes and containers of the CRI server while it runs,
+ * with the logs of each container and a way to run commands in it
+ */
+
+class Pods {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.active = false;
+        this.pods = [];
+        this.selected = null; // { id, name, view } of the container in the panel
+
+        this.setupEventListeners();
+        this.check();
+        // The Pods tab comes and goes with the CRI server
+        this.checkTimer = setInterval(() => {
+            if (document.visibilityState === 'visible') this.check();
+        }, 15000);
+    }
+
+    setupEventListeners() {
+        document.querySelector('[data-section="pods"]')?.addEventListener('click', () => {
+            UIHelpers.switchSection('pods');
+            this.check();
+        });
+        document.getElementById('refreshPodsBtn')?.addEventListener('click', () => this.check());
+        document.getElementById('refreshBtn')?.addEventListener('click', () => {
+            if (this.isShown()) this.check();
+        });
+
+        document.getElementById('closePodContainerBtn')?.addEventListener('click', () => this.closePanel());
+        document.getElementById('podExecForm')?.addEventListener('submit', (event) => {
+            event.preventDefault();
+            this.exec();
+        });
+    }
+
+    isShown() {
+        return document.getElementById('podsSection')?.classList.contains('active');
+    }
+
+    /**
+     * See whether the CRI server runs, and list its pods when it does
+     */
+    async check() {
+        let result;
+        try {
+            result = await this.apiClient.getPods();
+        } catch (error) {
+            if (this.isShown()) {
+                this.showEmpty('Failed to list pods', error.message);
+            }
+            return;
+        }
+
+        this.active = result.active;
+        const navItem = document.getElementById('podsNavItem');
+        if (navItem) navItem.hidden = !this.active && !this.isShown();
+
+        const source = document.getElementById('podsSource');
+        if (source) source.textContent = this.active ? `CRI server on port ${result.port}` : '';
+
+        if (!this.active) {
+            this.pods = [];
+            if (this.isShown()) {
+                this.renderPods();
+                this.showEmpty('CRI server not running', `Start it with 'servin cri start' to see the pods on port ${result.port}`);
+            }
+            return;
+        }
+
+        this.pods = result.pods;
+        if (this.isShown()) this.renderPods();
+    }
+
+    renderPods() {
+        const tbody = document.getElementById('podsTableBody');
+        if (!tbody) return;
+        tbody.innerHTML = '';
+
+        if (this.pods.length === 0) {
+            this.showEmpty('No pods', 'Pods the kubelet runs through the CRI server show up here');
+            return;
+        }
+        document.getElementById('podsEmpty').style.display = 'none';
+
+        this.pods.forEach(pod => {
+            const row = document.createElement('tr');
+            row.className = 'pod-row';
+            row.innerHTML = `
+                <td></td>
+                <td></td>
+                <td><span class="status-badge status-${pod.state}"></span></td>
+                <td></td>
+                <td></td>
+                <td></td>
+                <td></td>`;
+            const cells = row.querySelectorAll('td');
+            cells[0].textContent = pod.name;
+            cells[0].title = `${pod.id}\nUID ${pod.uid}`;
+            cells[1].textContent = pod.namespace || '-';
+            cells[2].querySelector('.status-badge').textContent = pod.state === 'notready' ? 'Not ready' : 'Ready';
+            cells[3].textContent = pod.ip || '-';
+            cells[4].textContent = `${pod.containers.length} container${pod.containers.length === 1 ? '' : 's'}`;
+            cells[5].textContent = pod.created_at ? UIHelpers.formatDate(pod.created_at * 1000) : '-';
+            tbody.appendChild(row);
+
+            pod.containers.forEach(container => tbody.appendChild(this.containerRow(pod, container)));
+        });
+    }
+
+    containerRow(pod, container) {
+        const row = document.createElement('tr');
+        row.className = 'pod-container-row';
+        row.dataset.containerId = container.id;
+        if (this.selected?.id === container.id) row.classList.add('selected');
+        row.innerHTML = `
+            <td></td>
+            <td></td>
+            <td><span class="status-badge status-${container.state}"></span></td>
+            <td></td>
+            <td></td>
+            <td></td>
+            <td>
+                <button class="action-btn secondary small pod-logs-btn" title="Logs">
+                    <i class="fas fa-file-alt"></i>
+                </button>
+                <button class="action-btn secondary small pod-exec-btn" title="Exec" data-mutating>
+                    <i class="fas fa-terminal"></i>
+                </button>
+            </td>`;
+        const cells = row.querySelectorAll('td');
+        cells[0].textContent = container.name;
+        cells[0].title = container.id;
+        cells[2].querySelector('.status-badge').textContent = container.state;
+        cells[4].textContent = container.image || '-';
+        cells[5].textContent = container.created_at ? UIHelpers.formatDate(container.created_at * 1000) : '-';
+
+        const name = `${pod.name}/${container.name}`;
+        row.querySelector('.pod-logs-btn').addEventListener('click', () => this.openPanel(container.id, name, 'logs'));
+        const execBtn = row.querySelector('.pod-exec-btn');
+        execBtn.disabled = container.state !== 'running';
+        execBtn.addEventListener('click', () => this.openPanel(container.id, name, 'exec'));
+        return row;
+    }
+
+    showEmpty(title, text) {
+        const empty = document.getElementById('podsEmpty');
+        if (!empty) return;
+        document.getElementById('podsEmptyTitle').textContent = title;
+        document.getElementById('podsEmptyText').textContent = text;
+        empty.style.display = '';
+    }
+
+    openPanel(containerId, name, view) {
+        this.selected = { id: containerId, name, view };
+        document.querySelectorAll('.pod-container-row').forEach(row => {
+            row.classList.toggle('selected', row.dataset.containerId === containerId);
+        });
+
+        document.getElementById('podContainerTitle').textContent =
+            view === 'logs' ? `Logs of ${name}` : `Run in ${name}`;
+        document.getElementById('podLogsView').hidden = view !== 'logs';
+        document.getElementById('podExecView').hidden = view !== 'exec';
+        document.getElementById('podContainerPanel').hidden = false;
+
+        if (view === 'logs') {
+            this.loadLogs();
+        } else {
+            document.getElementById('podExecOutput').textContent = '';
+            document.getElementById('podExecCommand').focus();
+        }
+    }
+
+    closePanel() {
+        this.selected = null;
+        document.getElementById('podContainerPanel').hidden = true;
+        document.querySelectorAll('.pod-container-row.selected').forEach(row => row.classList.remove('selected'));
+    }
+
+    async loadLogs() {
+        const output = document.getElementById('podLogsOutput');
+        output.textContent = 'Loading logs...';
+        try {
+            const { lines } = await this.apiClient.getPodContainerLogs(this.selected.id);
+            output.textContent = lines.length
+                ? lines.map(line => line.timestamp ? `${line.timestamp} ${line.stream} ${line.message}` : line.message).join('\n')
+                : 'No logs yet';
+            output.scrollTop = output.scrollHeight;
+        } catch (error) {
+            output.textContent = `Failed to load logs: ${error.message}`;
+        }
+    }
+
+    async exec() {
+        const input = document.getElementById('podExecCommand');
+        const output = document.getElementById('podExecOutput');
+        const command = input.value.trim();
+        if (!command || !this.selected) return;
+
+        output.textContent += `$ ${command}\n`;
+        try {
+            const result = await this.apiClient.execPodContainer(this.selected.id, command);
+            output.textContent += result.stdout + result.stderr;
+            if (result.exit_code) {
+                output.textContent += `[exit code ${result.exit_code}]\n`;
+            }
+            input.value = '';
+        } catch (error) {
+            output.textContent += `${error.message}\n`;
+        }
+        output.scrollTop = output.scrollHeight;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.pods = new Pods(new APIClient());
+});
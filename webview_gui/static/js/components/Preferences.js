@@ -0,0 +1,200 @@
+/**
+ * Preferences Component
+ * Edits the GUI's preferences, servin's default registry and the VM's
+ * resources. The theme and refresh interval apply at once; the rest apply to
+ * the servin commands the GUI runs from then on.
+ */
+
+class Preferences {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.modal = document.getElementById('preferencesModal');
+        this.current = null;
+        this.registry = null;
+        this.vm = null;
+        this.refreshTimer = null;
+
+        document.getElementById('preferencesBtn')?.addEventListener('click', () => this.open());
+        document.getElementById('closePreferences')?.addEventListener('click', () => this.close());
+        document.getElementById('cancelPreferences')?.addEventListener('click', () => this.close());
+        document.getElementById('savePreferences')?.addEventListener('click', () => this.save());
+        document.getElementById('prefNotificationsBtn')?.addEventListener('click', () => {
+            this.close();
+            window.notifications?.openSettings();
+        });
+        this.modal?.addEventListener('click', (e) => {
+            if (e.target === this.modal) this.close();
+        });
+
+        this.load();
+    }
+
+    /**
+     * Start refreshing with the saved interval
+     */
+    async load() {
+        try {
+            const { preferences } = await this.apiClient.getPreferences();
+            this.apply(preferences);
+        } catch (error) {
+            console.warn('Could not load preferences:', error);
+        }
+    }
+
+    apply(preferences) {
+        this.current = preferences;
+        document.documentElement.dataset.theme = preferences.theme;
+
+        clearInterval(this.refreshTimer);
+        this.refreshTimer = null;
+        if (preferences.refresh_interval > 0) {
+            this.refreshTimer = setInterval(() => {
+                if (document.visibilityState === 'visible') {
+                    document.getElementById('refreshBtn')?.click();
+                }
+            }, preferences.refresh_interval * 1000);
+        }
+    }
+
+    async open() {
+        let result;
+        try {
+            result = await this.apiClient.getPreferences();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to load preferences: ${error.message}`, 'error');
+            return;
+        }
+
+        const { preferences, themes, log_levels: logLevels } = result;
+        this.current = preferences;
+        this.registry = result.default_registry;
+
+        this.fillSelect('prefTheme', themes, preferences.theme, theme => theme[0].toUpperCase() + theme.slice(1));
+        this.fillSelect('prefLogLevel', logLevels, preferences.log_level, level => level);
+        document.getElementById('prefRefreshInterval').value = preferences.refresh_interval;
+        document.getElementById('prefCriPort').value = preferences.cri_port;
+        document.getElementById('prefTelemetry').checked = preferences.telemetry;
+
+        const registry = document.getElementById('prefDefaultRegistry');
+        registry.value = this.registry || '';
+        registry.disabled = this.registry === null || document.body.classList.contains('read-only');
+        registry.title = result.registry_error || '';
+
+        this.modal.style.display = 'block';
+        this.loadVM();
+    }
+
+    close() {
+        if (this.modal) this.modal.style.display = 'none';
+    }
+
+    fillSelect(id, values, selected, label) {
+        const select = document.getElementById(id);
+        select.innerHTML = '';
+        values.forEach(value => {
+            const option = document.createElement('option');
+            option.value = value;
+            option.textContent = label(value);
+            option.selected = value === selected;
+            select.appendChild(option);
+        });
+    }
+
+    /**
+     * The VM's configuration takes a while, so it fills in after the rest
+     */
+    async loadVM() {
+        const status = document.getElementById('prefVmStatus');
+        const fields = document.getElementById('prefVmFields');
+        this.vm = null;
+        fields.hidden = true;
+        status.hidden = false;
+        status.textContent = 'Loading VM configuration...';
+
+        try {
+            this.vm = await this.apiClient.getVMResources();
+        } catch (error) {
+            status.textContent = `VM resources are not available: ${error.message}`;
+            return;
+        }
+
+        document.getElementById('prefVmCpus').value = this.vm.cpus;
+        document.getElementById('prefVmMemory').value = this.vm.memory;
+        document.getElementById('prefVmDisk').value = this.vm.disk;
+        status.hidden = true;
+        fields.hidden = false;
+    }
+
+    /**
+     * The preferences that differ from the saved ones
+     */
+    changes() {
+        const values = {
+            refresh_interval: parseInt(document.getElementById('prefRefreshInterval').value, 10),
+            theme: document.getElementById('prefTheme').value,
+            cri_port: parseInt(document.getElementById('prefCriPort').value, 10),
+            telemetry: document.getElementById('prefTelemetry').checked,
+            log_level: document.getElementById('prefLogLevel').value
+        };
+
+        const changes = {};
+        Object.entries(values).forEach(([key, value]) => {
+            if (value !== this.current[key]) changes[key] = value;
+        });
+
+        const registry = document.getElementById('prefDefaultRegistry');
+        if (!registry.disabled && registry.value.trim() !== (this.registry || '')) {
+            changes.default_registry = registry.value.trim();
+        }
+        return changes;
+    }
+
+    vmChanges() {
+        if (!this.vm) return {};
+        const changes = {};
+        [['cpus', 'prefVmCpus'], ['memory', 'prefVmMemory'], ['disk', 'prefVmDisk']].forEach(([key, id]) => {
+            const value = parseInt(document.getElementById(id).value, 10);
+            if (value !== this.vm[key]) changes[key] = value;
+        });
+        return changes;
+    }
+
+    async save() {
+        const changes = this.changes();
+        const vmChanges = this.vmChanges();
+
+        if (Object.keys(changes).length > 0) {
+            try {
+                const { preferences } = await this.apiClient.updatePreferences(changes);
+                this.apply(preferences);
+            } catch (error) {
+                UIHelpers.showToast(`Failed to save preferences: ${error.message}`, 'error');
+                return;
+            }
+        }
+
+        if (Object.keys(vmChanges).length > 0) {
+            if (!confirm('Change the VM\'s resources? A running VM is restarted for it.')) {
+                return;
+            }
+            this.close();
+            UIHelpers.showToast('Resizing the VM...', 'info');
+            try {
+                const result = await this.apiClient.resizeVM(vmChanges);
+                UIHelpers.showToast(result.message, 'success');
+            } catch (error) {
+                UIHelpers.showToast(`Failed to resize the VM: ${error.message}`, 'error');
+            }
+            return;
+        }
+
+        this.close();
+        if (Object.keys(changes).length > 0) {
+            UIHelpers.showToast('Preferences saved', 'success');
+        }
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.preferences = new Preferences(new APIClient());
+});
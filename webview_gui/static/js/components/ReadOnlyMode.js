@@ -0,0 +1,96 @@
+/**
+ * Read-only Mode Component
+ * Greys out every mutating action when the GUI runs in read-only mode
+ */
+
+class ReadOnlyMode {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.enabled = document.body.classList.contains('read-only');
+        this.observer = null;
+
+        // Buttons that change containers, images, volumes or the VM.
+        // New components can opt in by adding a data-mutating attribute.
+        this.mutatingSelectors = [
+            '[data-mutating]',
+            '#createContainerBtn',
+            '#startContainerBtn',
+            '#stopContainerBtn',
+            '#restartContainerBtn',
+            '#removeContainerBtn',
+            '#pullImageBtn',
+            '#createVolumeBtn',
+            '#startVmBtn',
+            '#stopVmBtn',
+            '#restartVmBtn',
+            '#enableVmBtn',
+            '#disableVmBtn',
+            '.action-btn.start',
+            '.action-btn.stop',
+            '.action-btn.remove',
+            '.action-btn[title="Restart"]',
+            '#terminalInput'
+        ].join(', ');
+
+        this.initialize();
+    }
+
+    async initialize() {
+        if (!this.enabled && this.apiClient) {
+            try {
+                const mode = await this.apiClient.getMode();
+                this.enabled = Boolean(mode && mode.read_only);
+            } catch (error) {
+                console.warn('Could not determine GUI mode:', error);
+            }
+        }
+
+        if (this.enabled) {
+            this.enable();
+        }
+    }
+
+    enable() {
+        document.body.classList.add('read-only');
+        this.disableMutatingElements(document);
+
+        // Lists and details are re-rendered on refresh, so keep watching
+        this.observer = new MutationObserver(() => this.disableMutatingElements(document));
+        this.observer.observe(document.body, {
+            childList: true,
+            subtree: true,
+            attributes: true,
+            attributeFilter: ['disabled']
+        });
+
+        // Block clicks that slip through (e.g. inline onclick handlers)
+        document.addEventListener('click', (event) => {
+            if (event.target.closest && event.target.closest(this.mutatingSelectors)) {
+                event.preventDefault();
+                event.stopImmediatePropagation();
+                if (window.UIHelpers && UIHelpers.showToast) {
+                    UIHelpers.showToast('This action is disabled in read-only mode', 'warning');
+                }
+            }
+        }, true);
+    }
+
+    disableMutatingElements(root) {
+        root.querySelectorAll(this.mutatingSelectors).forEach((element) => {
+            if (!element.disabled) {
+                element.disabled = true;
+            }
+            if (!element.classList.contains('read-only-disabled')) {
+                element.classList.add('read-only-disabled');
+                element.title = 'Disabled in read-only mode';
+            }
+        });
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.readOnlyMode = new ReadOnlyMode(new APIClient());
+});
+
+// Export for use in other modules
+window.ReadOnlyMode = ReadOnlyMode;
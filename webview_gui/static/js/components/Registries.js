@@ -0,0 +1,432 @@
+/**
+ * Registries Component
+ * Lists the registries servin knows with their credentials, browses their
+ * repositories and tags, shows an image's manifest and pulls it with its
+ * progress through the layers
+ */
+
+class Registries {
+    constructor(apiClient, socketManager) {
+        this.apiClient = apiClient;
+        this.socketManager = socketManager;
+        this.registries = [];
+        this.registry = null;     // host whose repositories are listed
+        this.repositories = [];
+        this.repository = null;   // repository whose tags are listed, without the host
+        this.tag = null;
+        this.pulling = null;      // image being pulled
+
+        this.setupEventListeners();
+    }
+
+    setupEventListeners() {
+        document.querySelector('[data-section="registries"]')?.addEventListener('click', () => {
+            UIHelpers.switchSection('registries');
+            this.load();
+        });
+        document.getElementById('refreshRegistriesBtn')?.addEventListener('click', () => this.load());
+        document.getElementById('refreshBtn')?.addEventListener('click', () => {
+            if (this.isShown()) this.load();
+        });
+
+        document.getElementById('addRegistryBtn')?.addEventListener('click', () => this.showAddForm(true));
+        document.getElementById('registryAddCancel')?.addEventListener('click', () => this.showAddForm(false));
+        document.getElementById('registryAddForm')?.addEventListener('submit', (event) => {
+            event.preventDefault();
+            this.add();
+        });
+
+        const repoInput = document.getElementById('registryRepoInput');
+        repoInput?.addEventListener('input', () => this.renderRepositories());
+        document.getElementById('registryOpenForm')?.addEventListener('submit', (event) => {
+            event.preventDefault();
+            const name = repoInput.value.trim();
+            if (this.registry && name) this.openRepository(name);
+        });
+
+        document.getElementById('registryPlatform')?.addEventListener('change', (event) => {
+            this.loadManifest(event.target.value);
+        });
+        document.getElementById('registryPullBtn')?.addEventListener('click', () => {
+            this.pull(this.imageName(), document.getElementById('registryPlatform').value);
+        });
+
+        // The Images tab's Pull Image button pulls a reference typed in,
+        // showing its progress here
+        document.getElementById('pullImageBtn')?.addEventListener('click', () => {
+            const image = prompt('Image to pull, such as alpine:latest or ghcr.io/user/app:v1');
+            if (!image || !image.trim()) return;
+            UIHelpers.switchSection('registries');
+            this.load();
+            this.pull(image.trim(), '');
+        });
+
+        this.socketManager.on('pull_output', (data) => this.handleOutput(data));
+        this.socketManager.on('pull_progress', (data) => this.handleProgress(data));
+        this.socketManager.on('pull_finished', (data) => this.handleFinished(data));
+    }
+
+    isShown() {
+        return document.getElementById('registriesSection')?.classList.contains('active');
+    }
+
+    async load() {
+        try {
+            this.registries = await this.apiClient.getRegistries();
+        } catch (error) {
+            this.registries = [];
+            UIHelpers.showToast(`Failed to list registries: ${error.message}`, 'error');
+        }
+        this.renderRegistries();
+    }
+
+    renderRegistries() {
+        const list = document.getElementById('registryList');
+        if (!list) return;
+        list.innerHTML = '';
+
+        this.registries.forEach(registry => {
+            const item = document.createElement('li');
+            item.className = 'registry-list-item';
+            item.classList.toggle('selected', registry.url === this.registry);
+            item.innerHTML = `
+                <div class="registry-list-main">
+                    <span class="registry-list-name"></span>
+                    <span class="status-badge"></span>
+                </div>
+                <div class="registry-list-detail"></div>
+                <div class="registry-list-actions"></div>`;
+            item.querySelector('.registry-list-name').textContent = registry.name;
+            const badge = item.querySelector('.status-badge');
+            badge.classList.add(registry.status === 'healthy' ? 'status-running' : 'status-stopped');
+            badge.textContent = registry.status;
+            item.querySelector('.registry-list-detail').textContent = registry.logged_in
+                ? `${registry.url} · ${registry.username ? `logged in as ${registry.username}` : 'logged in'}`
+                : registry.url;
+
+            const actions = item.querySelector('.registry-list-actions');
+            if (registry.logged_in) {
+                actions.appendChild(this.button('fa-sign-out-alt', 'Log out', () => this.logout(registry)));
+            } else if (registry.type === 'remote') {
+                actions.appendChild(this.button('fa-sign-in-alt', 'Log in', () => this.login(registry)));
+            }
+            if (registry.type === 'remote') {
+                actions.appendChild(this.button('fa-trash', 'Remove', () => this.remove(registry)));
+            }
+
+            item.addEventListener('click', (event) => {
+                if (!event.target.closest('button')) this.openRegistry(registry.url);
+            });
+            list.appendChild(item);
+        });
+    }
+
+    button(icon, title, onClick) {
+        const button = document.createElement('button');
+        button.className = 'action-btn secondary small';
+        button.title = title;
+        button.dataset.mutating = '';
+        button.innerHTML = `<i class="fas ${icon}"></i>`;
+        button.addEventListener('click', onClick);
+        return button;
+    }
+
+    showAddForm(shown) {
+        const form = document.getElementById('registryAddForm');
+        form.hidden = !shown;
+        if (shown) {
+            form.reset();
+            document.getElementById('registryAddName').focus();
+        }
+    }
+
+    async add() {
+        const registry = {
+            name: document.getElementById('registryAddName').value.trim(),
+            registry: document.getElementById('registryAddHost').value.trim(),
+            username: document.getElementById('registryAddUsername').value.trim(),
+            password: document.getElementById('registryAddPassword').value
+        };
+        try {
+            const result = await this.apiClient.addRegistry(registry);
+            UIHelpers.showToast(result.message, 'success');
+            this.showAddForm(false);
+            await this.load();
+            this.openRegistry(registry.registry);
+        } catch (error) {
+            UIHelpers.showToast(`Failed to add registry: ${error.message}`, 'error');
+        }
+    }
+
+    async remove(registry) {
+        if (!confirm(`Remove ${registry.name} (${registry.url}) from the list? Its credentials are kept.`)) return;
+        try {
+            await this.apiClient.removeRegistry(registry.name);
+            UIHelpers.showToast(`Removed ${registry.name}`, 'success');
+            this.load();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to remove registry: ${error.message}`, 'error');
+        }
+    }
+
+    // Logging in saves the registry again with credentials, through the
+    // form that checks them
+    login(registry) {
+        this.showAddForm(true);
+        document.getElementById('registryAddName').value = registry.name;
+        document.getElementById('registryAddHost').value = registry.url;
+        document.getElementById('registryAddUsername').focus();
+    }
+
+    async logout(registry) {
+        if (!confirm(`Log out of ${registry.url}? Its stored credentials are removed.`)) return;
+        try {
+            await this.apiClient.registryLogout(registry.url);
+            UIHelpers.showToast(`Logged out of ${registry.url}`, 'success');
+            this.load();
+        } catch (error) {
+            UIHelpers.showToast(`Failed to log out: ${error.message}`, 'error');
+        }
+    }
+
+    async openRegistry(host) {
+        this.registry = host;
+        this.repositories = [];
+        this.repository = null;
+        this.tag = null;
+        this.renderRegistries();
+        this.renderTags([]);
+        this.showManifest(null);
+        document.getElementById('registryReposTitle').textContent = `Repositories in ${host}`;
+        document.getElementById('registryRepoInput').value = '';
+
+        const list = document.getElementById('registryRepoList');
+        list.innerHTML = '<li class="wizard-empty">Loading repositories...</li>';
+        try {
+            const result = await this.apiClient.getRegistryCatalog(host);
+            if (this.registry !== host) return;
+            this.repositories = result.repositories;
+            this.renderRepositories();
+        } catch (error) {
+            if (this.registry !== host) return;
+            // Docker Hub and others do not list their repositories
+            list.innerHTML = '<li class="wizard-empty"></li>';
+            list.firstChild.textContent = `${error.message}. Open a repository by name instead.`;
+        }
+    }
+
+    renderRepositories() {
+        const list = document.getElementById('registryRepoList');
+        if (!list || !this.registry) return;
+        const filter = document.getElementById('registryRepoInput').value.trim().toLowerCase();
+        const shown = this.repositories.filter(name => name.toLowerCase().includes(filter));
+
+        list.innerHTML = '';
+        if (shown.length === 0) {
+            list.innerHTML = '<li class="wizard-empty"></li>';
+            list.firstChild.textContent = this.repositories.length
+                ? 'No matching repositories; press Enter to open it by name'
+                : 'No repositories';
+            return;
+        }
+        shown.forEach(name => {
+            const item = document.createElement('li');
+            item.textContent = name;
+            item.classList.toggle('selected', name === this.repository);
+            item.addEventListener('click', () => this.openRepository(name));
+            list.appendChild(item);
+        });
+    }
+
+    async openRepository(name) {
+        this.repository = name;
+        this.tag = null;
+        this.renderRepositories();
+        this.showManifest(null);
+        document.getElementById('registryTagsTitle').textContent = `Tags of ${name}`;
+
+        const list = document.getElementById('registryTagList');
+        list.innerHTML = '<li class="wizard-empty">Loading tags...</li>';
+        const repository = `${this.registry}/${name}`;
+        try {
+            const result = await this.apiClient.getRegistryTags(repository);
+            if (`${this.registry}/${this.repository}` !== repository) return;
+            this.renderTags(result.tags);
+        } catch (error) {
+            list.innerHTML = '<li class="wizard-empty"></li>';
+            list.firstChild.textContent = error.message;
+        }
+    }
+
+    renderTags(tags) {
+        const list = document.getElementById('registryTagList');
+        list.innerHTML = '';
+        if (!this.repository) {
+            document.getElementById('registryTagsTitle').textContent = 'Tags';
+            return;
+        }
+        if (tags.length === 0) {
+            list.innerHTML = '<li class="wizard-empty">No tags</li>';
+            return;
+        }
+        tags.forEach(tag => {
+            const item = document.createElement('li');
+            item.textContent = tag;
+            item.addEventListener('click', () => {
+                list.querySelectorAll('li').forEach(other => other.classList.toggle('selected', other === item));
+                this.tag = tag;
+                this.loadManifest('');
+            });
+            list.appendChild(item);
+        });
+    }
+
+    imageName() {
+        return `${this.registry}/${this.repository}:${this.tag}`;
+    }
+
+    async loadManifest(platform) {
+        const image = this.imageName();
+        document.getElementById('registryImageTitle').textContent = image;
+        document.getElementById('registryManifest').innerHTML = '<div class="wizard-empty">Loading manifest...</div>';
+        try {
+            const manifest = await this.apiClient.getRegistryManifest(image, platform);
+            if (this.imageName() !== image) return;
+            this.showManifest(manifest);
+        } catch (error) {
+            const content = document.getElementById('registryManifest');
+            content.innerHTML = '<div class="wizard-empty"></div>';
+            content.firstChild.textContent = `Failed to load the manifest: ${error.message}`;
+        }
+    }
+
+    showManifest(manifest) {
+        const content = document.getElementById('registryManifest');
+        const platforms = document.getElementById('registryPlatform');
+        this.updatePullButton();
+        if (!manifest) {
+            document.getElementById('registryImageTitle').textContent = 'Image';
+            platforms.hidden = true;
+            content.innerHTML = '<div class="wizard-empty">Choose a tag to see its manifest</div>';
+            return;
+        }
+
+        // A multi-platform image can be shown and pulled for each platform
+        platforms.hidden = !manifest.platforms?.length;
+        platforms.innerHTML = '';
+        (manifest.platforms || []).forEach(p => {
+            platforms.add(new Option(p.platform, p.platform, false, p.platform === manifest.platform));
+        });
+
+        const rows = [
+            ['Digest', manifest.digest],
+            ['Media type', manifest.media_type],
+            ['Platform', manifest.platform || 'None for this host'],
+            ['Created', manifest.created ? UIHelpers.formatDate(manifest.created) : '-'],
+            ['Size', UIHelpers.formatBytes(manifest.size || 0)],
+            ['Entrypoint', (manifest.entrypoint || []).join(' ')],
+            ['Command', (manifest.cmd || []).join(' ')],
+            ['Working directory', manifest.working_dir],
+            ['User', manifest.user],
+            ['Ports', (manifest.exposed_ports || []).join(', ')],
+            ...Object.entries(manifest.labels || {}).map(([key, value]) => [key, value])
+        ].filter(([, value]) => value);
+
+        content.innerHTML = '<dl class="registry-manifest"></dl><h4></h4><ol class="registry-layers"></ol>';
+        const details = content.querySelector('dl');
+        rows.forEach(([label, value]) => {
+            const term = document.createElement('dt');
+            term.textContent = label;
+            const description = document.createElement('dd');
+            description.textContent = value;
+            details.append(term, description);
+        });
+
+        const layers = manifest.layers || [];
+        content.querySelector('h4').textContent = `Layers (${layers.length})`;
+        const layerList = content.querySelector('ol');
+        layers.forEach(layer => {
+            const item = document.createElement('li');
+            item.innerHTML = '<code></code><span></span>';
+            item.querySelector('code').textContent = layer.digest;
+            item.querySelector('span').textContent = UIHelpers.formatBytes(layer.size);
+            layerList.appendChild(item);
+        });
+    }
+
+    updatePullButton() {
+        const button = document.getElementById('registryPullBtn');
+        if (button) button.disabled = !this.tag || this.pulling !== null;
+    }
+
+    async pull(image, platform) {
+        if (this.pulling) {
+            UIHelpers.showToast(`Already pulling ${this.pulling}`, 'error');
+            return;
+        }
+        this.pulling = image;
+        this.updatePullButton();
+        document.getElementById('registryPull').hidden = false;
+        document.getElementById('registryPullOutput').textContent = '';
+        document.getElementById('registryPullCount').textContent = '';
+        this.setPullStatus(`Pulling ${image}...`, 0, '');
+
+        try {
+            await this.apiClient.pullImage(image, platform);
+        } catch (error) {
+            this.pulling = null;
+            this.updatePullButton();
+            this.setPullStatus(`Failed to start the pull: ${error.message}`, 100, 'failed');
+            UIHelpers.showToast(`Failed to pull ${image}: ${error.message}`, 'error');
+        }
+    }
+
+    setPullStatus(text, percent, state) {
+        document.getElementById('registryPullStatus').textContent = text;
+        const bar = document.getElementById('registryPullBar');
+        bar.style.width = `${percent}%`;
+        bar.parentElement.className = `download-progress ${state}`;
+    }
+
+    handleOutput(data) {
+        if (data.image !== this.pulling) return;
+        const output = document.getElementById('registryPullOutput');
+        // Follow the output unless the user scrolled up to read it
+        const atBottom = output.scrollHeight - output.scrollTop - output.clientHeight < 20;
+        output.appendChild(document.createTextNode(data.line + '\n'));
+        if (atBottom) {
+            output.scrollTop = output.scrollHeight;
+        }
+    }
+
+    handleProgress(data) {
+        if (data.image !== this.pulling) return;
+        // A layer's line is printed as it starts, so the ones before it are done
+        document.getElementById('registryPullCount').textContent = `Layer ${data.layer} of ${data.total}`;
+        this.setPullStatus(`Pulling ${data.image}...`, Math.floor((data.layer - 1) * 100 / data.total), '');
+    }
+
+    handleFinished(data) {
+        if (data.image !== this.pulling) return;
+        this.pulling = null;
+        this.updatePullButton();
+
+        if (data.success) {
+            this.setPullStatus(`Pulled ${data.image}`, 100, 'done');
+            UIHelpers.showToast(`Pulled ${data.image}`, 'success');
+            // Show the new image in the Images tab
+            document.getElementById('refreshBtn')?.click();
+        } else {
+            this.setPullStatus(`Pull failed: ${data.error}`, 100, 'failed');
+            UIHelpers.showToast(`Failed to pull ${data.image}: ${data.error}`, 'error');
+        }
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    if (!window.socketManager) {
+        window.socketManager = new SocketManager();
+        window.socketManager.init();
+    }
+    window.registries = new Registries(new APIClient(), window.socketManager);
+});
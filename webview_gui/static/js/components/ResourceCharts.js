@@ -0,0 +1,198 @@
+/**
+ * Resource Charts Component
+ * Draws live CPU, memory, network and disk I/O charts for a container in the
+ * Overview tab, from the samples `servin stats` streams while it runs
+ */
+
+class ResourceCharts {
+    constructor(socketManager) {
+        this.socketManager = socketManager;
+        this.containerId = null;
+        this.isStreaming = false;
+        this.interval = 2;
+        // Samples of each container seen this session, kept for an hour
+        this.history = new Map();
+        this.maxSamples = 1800;
+
+        this.content = document.getElementById('resourceCharts');
+
+        this.setupSocketHandlers();
+    }
+
+    setupSocketHandlers() {
+        this.socketManager.on('stats_sample', (data) => this.handleSample(data));
+        this.socketManager.on('stats_started', (data) => {
+            if (data.container_id === this.containerId) this.isStreaming = true;
+        });
+        this.socketManager.on('stats_stopped', (data) => this.handleStopped(data));
+    }
+
+    /**
+     * Show the charts of a container, streaming new samples while it runs
+     */
+    show(containerId, status) {
+        const running = (status || '').toLowerCase() === 'running';
+
+        if (containerId !== this.containerId) {
+            this.stop();
+            this.containerId = containerId;
+        }
+        if (!this.history.has(containerId)) {
+            this.history.set(containerId, []);
+        }
+
+        if (running && !this.isStreaming) {
+            this.socketManager.emit('start_stats', {
+                container_id: containerId,
+                interval: this.interval
+            });
+            this.isStreaming = true;
+        } else if (!running) {
+            this.stop();
+        }
+
+        this.render(running ? '' : 'The container is not running');
+    }
+
+    stop() {
+        if (this.containerId && this.isStreaming) {
+            this.socketManager.emit('stop_stats', { container_id: this.containerId });
+        }
+        this.isStreaming = false;
+    }
+
+    cleanup() {
+        this.stop();
+        this.containerId = null;
+    }
+
+    handleSample(data) {
+        const samples = this.history.get(data.container_id);
+        if (!samples) return;
+
+        samples.push(data.sample);
+        if (samples.length > this.maxSamples) {
+            samples.splice(0, samples.length - this.maxSamples);
+        }
+
+        if (data.container_id === this.containerId) {
+            this.render();
+        }
+    }
+
+    handleStopped(data) {
+        if (data.container_id !== this.containerId) return;
+
+        this.isStreaming = false;
+        if (data.error) {
+            this.render(`Failed to read resource usage: ${data.error}`);
+        }
+    }
+
+    render(note = '') {
+        if (!this.content || !this.containerId) return;
+
+        const samples = this.history.get(this.containerId) || [];
+        if (samples.length === 0) {
+            this.content.innerHTML = `<div class="placeholder">${note || 'Waiting for the first sample...'}</div>`;
+            return;
+        }
+
+        const latest = samples[samples.length - 1];
+        const rates = this.rates(samples);
+        const lastRate = rates[rates.length - 1] || { netRx: 0, netTx: 0, blockRead: 0, blockWrite: 0 };
+        const memoryLimit = latest.memory_limit || Math.max(...samples.map(s => s.memory_usage));
+
+        this.content.innerHTML = `
+            ${note ? `<div class="resource-note">${note}</div>` : ''}
+            <div class="resource-grid">
+                ${this.card('CPU', `${latest.cpu_percent.toFixed(2)}%`, samples, [
+                    { values: samples.map(s => s.cpu_percent), color: '#0078d4' }
+                ], Math.max(100, ...samples.map(s => s.cpu_percent)))}
+                ${this.card('Memory', `${UIHelpers.formatBytes(latest.memory_usage)} / ${latest.memory_limit ? UIHelpers.formatBytes(latest.memory_limit) : 'no limit'}`, samples, [
+                    { values: samples.map(s => s.memory_usage), color: '#16c60c' }
+                ], memoryLimit)}
+                ${this.card('Network I/O', `<span style="color: #0078d4">↓ ${this.formatRate(lastRate.netRx)}</span> <span style="color: #c239b3">↑ ${this.formatRate(lastRate.netTx)}</span>`, samples.slice(1), [
+                    { values: rates.map(r => r.netRx), color: '#0078d4' },
+                    { values: rates.map(r => r.netTx), color: '#c239b3' }
+                ])}
+                ${this.card('Disk I/O', `<span style="color: #ffb900">R ${this.formatRate(lastRate.blockRead)}</span> <span style="color: #e74856">W ${this.formatRate(lastRate.blockWrite)}</span>`, samples.slice(1), [
+                    { values: rates.map(r => r.blockRead), color: '#ffb900' },
+                    { values: rates.map(r => r.blockWrite), color: '#e74856' }
+                ])}
+            </div>
+            <div class="resource-footer">
+                ${samples.length} samples since ${new Date(samples[0].read).toLocaleTimeString()} · PIDs ${latest.pids}
+            </div>
+        `;
+    }
+
+    /**
+     * Bytes per second between consecutive samples of the cumulative counters
+     */
+    rates(samples) {
+        const rates = [];
+        for (let i = 1; i < samples.length; i++) {
+            const previous = samples[i - 1];
+            const current = samples[i];
+            const seconds = Math.max((new Date(current.read) - new Date(previous.read)) / 1000, 0.001);
+            const rate = key => Math.max(current[key] - previous[key], 0) / seconds;
+            rates.push({
+                netRx: rate('net_rx_bytes'),
+                netTx: rate('net_tx_bytes'),
+                blockRead: rate('block_read_bytes'),
+                blockWrite: rate('block_write_bytes')
+            });
+        }
+        return rates;
+    }
+
+    formatRate(bytesPerSecond) {
+        return `${UIHelpers.formatBytes(Math.round(bytesPerSecond))}/s`;
+    }
+
+    card(title, value, samples, series, max = 0) {
+        return `
+            <div class="resource-card">
+                <div class="resource-card-header">
+                    <span class="resource-title">${title}</span>
+                    <span class="resource-value">${value}</span>
+                </div>
+                ${this.sparkline(samples, series, max)}
+            </div>
+        `;
+    }
+
+    /**
+     * Draws series of values taken at the samples' times as an SVG sparkline
+     */
+    sparkline(samples, series, max) {
+        const width = 300;
+        const height = 60;
+        if (samples.length < 2) {
+            return `<svg class="sparkline" viewBox="0 0 ${width} ${height}" preserveAspectRatio="none"></svg>`;
+        }
+
+        const times = samples.map(s => new Date(s.read).getTime());
+        const start = times[0];
+        const span = Math.max(times[times.length - 1] - start, 1);
+        const top = Math.max(max, ...series.map(s => Math.max(...s.values)), 1);
+
+        const x = t => (t - start) / span * width;
+        const y = v => height - 2 - Math.min(v, top) / top * (height - 4);
+        const lines = series.map(s => {
+            const points = s.values.map((v, i) => `${x(times[i]).toFixed(1)},${y(v).toFixed(1)}`).join(' ');
+            return `<polyline points="${points}" fill="none" stroke="${s.color}" stroke-width="1.5" vector-effect="non-scaling-stroke"/>`;
+        }).join('');
+
+        return `<svg class="sparkline" viewBox="0 0 ${width} ${height}" preserveAspectRatio="none">${lines}</svg>`;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    if (!window.socketManager) {
+        window.socketManager = new SocketManager();
+        window.socketManager.init();
+    }
+    window.resourceCharts = new ResourceCharts(window.socketManager);
+});
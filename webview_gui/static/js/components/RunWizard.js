@@ -0,0 +1,408 @@
+/**
+ * Run Wizard Component
+ * Creates a container step by step, with ports, mounts, environment,
+ * network, restart policy and resource limits, showing the equivalent
+ * `servin run` command before creating it
+ */
+
+class RunWizard {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.modal = document.getElementById('runWizardModal');
+        this.step = 0;
+        this.lastStep = 6;
+        this.ports = [];
+        this.volumes = [];
+        this.env = [];
+        // The mount whose source the folder picker is choosing
+        this.pickerTarget = null;
+        this.pickerPath = null;
+        this.pickerParent = null;
+
+        if (this.modal) {
+            this.setupEventListeners();
+        }
+    }
+
+    setupEventListeners() {
+        document.getElementById('createContainerBtn')?.addEventListener('click', () => this.open());
+        document.getElementById('closeRunWizard')?.addEventListener('click', () => this.close());
+        document.getElementById('cancelRunWizard')?.addEventListener('click', () => this.close());
+        document.getElementById('wizardBackBtn')?.addEventListener('click', () => this.showStep(this.step - 1));
+        document.getElementById('wizardNextBtn')?.addEventListener('click', () => this.next());
+        document.getElementById('wizardCreateBtn')?.addEventListener('click', () => this.create());
+
+        document.querySelectorAll('#wizardSteps li').forEach(item => {
+            item.addEventListener('click', () => {
+                const step = Number(item.dataset.step);
+                // Steps can be revisited, but not skipped past an invalid one
+                if (step <= this.step || this.validate()) {
+                    this.showStep(step);
+                }
+            });
+        });
+
+        document.getElementById('addPortBtn')?.addEventListener('click', () => {
+            this.ports.push({ host: '', container: '', protocol: 'tcp' });
+            this.renderPorts();
+        });
+        document.getElementById('addVolumeBtn')?.addEventListener('click', () => {
+            this.volumes.push({ source: '', target: '', read_only: false });
+            this.renderVolumes();
+        });
+        document.getElementById('addEnvBtn')?.addEventListener('click', () => {
+            this.env.push({ key: '', value: '' });
+            this.renderEnv();
+        });
+
+        const restartSelect = document.getElementById('wizardRestart');
+        restartSelect?.addEventListener('change', () => {
+            document.getElementById('wizardRetriesGroup').style.display =
+                restartSelect.value === 'on-failure' ? 'block' : 'none';
+        });
+
+        document.getElementById('copyRunCommandBtn')?.addEventListener('click', () => {
+            UIHelpers.copyToClipboard(document.getElementById('wizardPreview').textContent, 'Command');
+        });
+
+        document.getElementById('folderPickerUp')?.addEventListener('click', () => {
+            if (this.pickerParent) this.browse(this.pickerParent);
+        });
+        document.getElementById('folderPickerCancel')?.addEventListener('click', () => this.closePicker());
+        document.getElementById('folderPickerSelect')?.addEventListener('click', () => {
+            if (this.pickerTarget && this.pickerPath) {
+                this.pickerTarget.source = this.pickerPath;
+                this.renderVolumes();
+            }
+            this.closePicker();
+        });
+
+        this.modal.addEventListener('click', (e) => {
+            if (e.target === this.modal) this.close();
+        });
+    }
+
+    /**
+     * Open the wizard, optionally with the image already chosen
+     */
+    open(image = '') {
+        this.reset();
+        this.modal.style.display = 'block';
+        this.loadChoices();
+        const imageInput = document.getElementById('wizardImage');
+        imageInput.value = image;
+        (image ? document.getElementById('wizardCommand') : imageInput).focus();
+    }
+
+    close() {
+        this.modal.style.display = 'none';
+        this.closePicker();
+    }
+
+    reset() {
+        ['wizardImage', 'wizardCommand', 'wizardName', 'wizardHostname', 'wizardWorkdir',
+         'wizardRetries', 'wizardMemory', 'wizardCpus'].forEach(id => {
+            document.getElementById(id).value = '';
+        });
+        document.getElementById('wizardNetwork').value = '';
+        document.getElementById('wizardRestart').value = 'no';
+        document.getElementById('wizardRetriesGroup').style.display = 'none';
+
+        this.ports = [];
+        this.volumes = [];
+        this.env = [];
+        this.renderPorts();
+        this.renderVolumes();
+        this.renderEnv();
+        this.showStep(0);
+    }
+
+    /**
+     * Offer the local images, volumes and networks as choices
+     */
+    async loadChoices() {
+        const [images, volumes, networks] = await Promise.all([
+            this.apiClient.getImages().catch(() => []),
+            this.apiClient.getVolumes().catch(() => []),
+            this.apiClient.getNetworks().catch(() => [])
+        ]);
+
+        this.fillDatalist('wizardImageList', (images || []).map(image => `${image.repository}:${image.tag}`));
+        this.fillDatalist('wizardVolumeList', (volumes || []).map(volume => volume.name));
+
+        const select = document.getElementById('wizardNetwork');
+        select.querySelectorAll('option[data-user]').forEach(option => option.remove());
+        (networks || []).forEach(network => {
+            // The default bridge network is the first option already
+            if (network.name === 'servin0' || network.name === 'bridge') return;
+            const option = document.createElement('option');
+            option.value = network.name;
+            option.textContent = `${network.name} (${network.driver})`;
+            option.dataset.user = 'true';
+            select.appendChild(option);
+        });
+    }
+
+    fillDatalist(id, values) {
+        const list = document.getElementById(id);
+        list.innerHTML = '';
+        values.forEach(value => {
+            const option = document.createElement('option');
+            option.value = value;
+            list.appendChild(option);
+        });
+    }
+
+    showStep(step) {
+        this.step = Math.max(0, Math.min(step, this.lastStep));
+        this.showError('');
+
+        document.querySelectorAll('#runWizardModal .wizard-page').forEach(page => {
+            page.style.display = Number(page.dataset.step) === this.step ? 'block' : 'none';
+        });
+        document.querySelectorAll('#wizardSteps li').forEach(item => {
+            const itemStep = Number(item.dataset.step);
+            item.classList.toggle('active', itemStep === this.step);
+            item.classList.toggle('done', itemStep < this.step);
+        });
+
+        document.getElementById('wizardBackBtn').style.display = this.step > 0 ? '' : 'none';
+        document.getElementById('wizardNextBtn').style.display = this.step < this.lastStep ? '' : 'none';
+        document.getElementById('wizardCreateBtn').style.display = this.step === this.lastStep ? '' : 'none';
+
+        if (this.step === this.lastStep) {
+            this.preview();
+        }
+    }
+
+    next() {
+        if (this.validate()) {
+            this.showStep(this.step + 1);
+        }
+    }
+
+    /**
+     * Check the current step, showing what is wrong with it
+     */
+    validate() {
+        let error = '';
+        if (this.step === 0) {
+            if (!document.getElementById('wizardImage').value.trim()) {
+                error = 'Choose an image';
+            } else if (!document.getElementById('wizardCommand').value.trim()) {
+                error = 'Enter the command to run';
+            }
+        } else if (this.step === 1) {
+            const port = this.ports.find(p => !/^\d+$/.test(p.container.trim()) || (p.host.trim() && !/^\d+$/.test(p.host.trim())));
+            if (port) error = 'Ports must be numbers, and every mapping needs a container port';
+        } else if (this.step === 2) {
+            if (this.volumes.some(v => !v.target.trim().startsWith('/'))) {
+                error = 'Every mount needs an absolute path in the container';
+            }
+        }
+
+        this.showError(error);
+        return !error;
+    }
+
+    showError(message) {
+        const error = document.getElementById('wizardError');
+        error.textContent = message;
+        error.style.display = message ? 'block' : 'none';
+    }
+
+    options() {
+        const value = id => document.getElementById(id).value.trim();
+        return {
+            image: value('wizardImage'),
+            command: value('wizardCommand'),
+            name: value('wizardName'),
+            hostname: value('wizardHostname'),
+            workdir: value('wizardWorkdir'),
+            ports: this.ports,
+            volumes: this.volumes,
+            env: this.env,
+            network: value('wizardNetwork'),
+            restart: value('wizardRestart'),
+            max_retries: value('wizardRetries'),
+            memory: value('wizardMemory'),
+            cpus: value('wizardCpus')
+        };
+    }
+
+    async preview() {
+        const preview = document.getElementById('wizardPreview');
+        const createBtn = document.getElementById('wizardCreateBtn');
+        preview.textContent = '';
+        try {
+            const result = await this.apiClient.previewRunContainer(this.options());
+            preview.textContent = result.command;
+            createBtn.disabled = false;
+        } catch (error) {
+            this.showError(error.message);
+            createBtn.disabled = true;
+        }
+    }
+
+    async create() {
+        const createBtn = document.getElementById('wizardCreateBtn');
+        createBtn.disabled = true;
+        try {
+            const result = await this.apiClient.runContainer(this.options());
+            UIHelpers.showToast(`Container ${(result.id || '').substring(0, 12)} created`, 'success');
+            this.close();
+
+            // Show the new container in the list
+            document.getElementById('refreshBtn')?.click();
+        } catch (error) {
+            this.showError(`Failed to create container: ${error.message}`);
+        } finally {
+            createBtn.disabled = false;
+        }
+    }
+
+    renderPorts() {
+        this.renderRows('wizardPorts', this.ports, (port) => [
+            this.input(port, 'host', 'Host port (any)', 'number'),
+            this.label('→'),
+            this.input(port, 'container', 'Container port', 'number'),
+            this.select(port, 'protocol', ['tcp', 'udp'])
+        ], () => this.renderPorts());
+    }
+
+    renderVolumes() {
+        this.renderRows('wizardVolumes', this.volumes, (volume) => {
+            const source = this.input(volume, 'source', 'Host folder or volume name');
+            source.setAttribute('list', 'wizardVolumeList');
+
+            const browse = document.createElement('button');
+            browse.className = 'action-btn secondary';
+            browse.title = 'Choose a host folder';
+            browse.innerHTML = '<i class="fas fa-folder-open"></i>';
+            browse.addEventListener('click', () => this.openPicker(volume));
+
+            const readOnly = document.createElement('label');
+            readOnly.className = 'wizard-checkbox';
+            const checkbox = document.createElement('input');
+            checkbox.type = 'checkbox';
+            checkbox.checked = volume.read_only;
+            checkbox.addEventListener('change', () => { volume.read_only = checkbox.checked; });
+            readOnly.append(checkbox, ' Read-only');
+
+            return [source, browse, this.label('→'), this.input(volume, 'target', '/path/in/container'), readOnly];
+        }, () => this.renderVolumes());
+    }
+
+    renderEnv() {
+        this.renderRows('wizardEnv', this.env, (variable) => [
+            this.input(variable, 'key', 'NAME'),
+            this.label('='),
+            this.input(variable, 'value', 'value')
+        ], () => this.renderEnv());
+    }
+
+    /**
+     * Render editable rows for a list of objects, each with a remove button
+     */
+    renderRows(containerId, items, fields, rerender) {
+        const container = document.getElementById(containerId);
+        container.innerHTML = '';
+        if (items.length === 0) {
+            container.innerHTML = '<div class="wizard-empty">None</div>';
+            return;
+        }
+
+        items.forEach((item, index) => {
+            const row = document.createElement('div');
+            row.className = 'wizard-row';
+            row.append(...fields(item));
+
+            const remove = document.createElement('button');
+            remove.className = 'action-btn danger';
+            remove.title = 'Remove';
+            remove.innerHTML = '<i class="fas fa-times"></i>';
+            remove.addEventListener('click', () => {
+                items.splice(index, 1);
+                rerender();
+            });
+            row.appendChild(remove);
+            container.appendChild(row);
+        });
+    }
+
+    input(item, key, placeholder, type = 'text') {
+        const input = document.createElement('input');
+        input.type = type;
+        input.placeholder = placeholder;
+        input.value = item[key];
+        input.addEventListener('input', () => { item[key] = input.value; });
+        return input;
+    }
+
+    select(item, key, values) {
+        const select = document.createElement('select');
+        values.forEach(value => {
+            const option = document.createElement('option');
+            option.value = value;
+            option.textContent = value;
+            select.appendChild(option);
+        });
+        select.value = item[key];
+        select.addEventListener('change', () => { item[key] = select.value; });
+        return select;
+    }
+
+    label(text) {
+        const span = document.createElement('span');
+        span.className = 'wizard-separator';
+        span.textContent = text;
+        return span;
+    }
+
+    openPicker(volume) {
+        this.pickerTarget = volume;
+        document.getElementById('folderPicker').style.display = 'block';
+        // Start from the chosen folder, or the home folder
+        this.browse(volume.source.startsWith('/') || /^[A-Za-z]:\\/.test(volume.source) ? volume.source : '');
+    }
+
+    closePicker() {
+        this.pickerTarget = null;
+        const picker = document.getElementById('folderPicker');
+        if (picker) picker.style.display = 'none';
+    }
+
+    async browse(path) {
+        const list = document.getElementById('folderPickerList');
+        try {
+            const result = await this.apiClient.getHostDirectories(path);
+            this.pickerPath = result.path;
+            this.pickerParent = result.parent;
+            document.getElementById('folderPickerPath').textContent = result.path;
+            document.getElementById('folderPickerUp').disabled = !result.parent;
+
+            list.innerHTML = '';
+            if (result.directories.length === 0) {
+                list.innerHTML = '<li class="wizard-empty">No folders</li>';
+            }
+            result.directories.forEach(name => {
+                const item = document.createElement('li');
+                item.innerHTML = '<i class="fas fa-folder"></i> ';
+                item.appendChild(document.createTextNode(name));
+                item.addEventListener('click', () => this.browse(this.joinPath(result.path, name)));
+                list.appendChild(item);
+            });
+        } catch (error) {
+            UIHelpers.showToast(error.message, 'error');
+        }
+    }
+
+    joinPath(parent, name) {
+        const separator = parent.includes('\\') && !parent.includes('/') ? '\\' : '/';
+        return parent.endsWith(separator) ? parent + name : parent + separator + name;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.runWizard = new RunWizard(new APIClient());
+});
@@ -0,0 +1,203 @@
+/**
+ * Stats History Component
+ * Charts a container's recent resource usage and exports it as CSV, JSON or PNG
+ */
+
+class StatsHistory {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.containerId = null;
+        this.samples = [];
+        this.refreshTimer = null;
+        this.refreshInterval = 5000;
+
+        this.content = document.getElementById('statsContent');
+        this.rangeSelect = document.getElementById('statsRange');
+        this.autoRefresh = document.getElementById('autoRefreshStats');
+
+        this.setupEventListeners();
+    }
+
+    setupEventListeners() {
+        const refreshBtn = document.getElementById('refreshStatsBtn');
+        if (refreshBtn) {
+            refreshBtn.addEventListener('click', () => this.load());
+        }
+        if (this.rangeSelect) {
+            this.rangeSelect.addEventListener('change', () => this.load());
+        }
+        if (this.autoRefresh) {
+            this.autoRefresh.addEventListener('change', () => this.scheduleRefresh());
+        }
+
+        document.querySelectorAll('[data-stats-export]').forEach(btn => {
+            btn.addEventListener('click', () => this.export(btn.dataset.statsExport));
+        });
+    }
+
+    get since() {
+        return this.rangeSelect ? this.rangeSelect.value : '30m';
+    }
+
+    async show(containerId) {
+        this.containerId = containerId;
+        await this.load();
+        this.scheduleRefresh();
+    }
+
+    stop() {
+        if (this.refreshTimer) {
+            clearInterval(this.refreshTimer);
+            this.refreshTimer = null;
+        }
+    }
+
+    scheduleRefresh() {
+        this.stop();
+        if (this.containerId && this.autoRefresh && this.autoRefresh.checked) {
+            this.refreshTimer = setInterval(() => this.load(), this.refreshInterval);
+        }
+    }
+
+    async load() {
+        if (!this.containerId || !this.content) return;
+
+        try {
+            const data = await this.apiClient.getContainerStatsHistory(this.containerId, this.since);
+            this.samples = data.samples || [];
+            this.render();
+        } catch (error) {
+            console.error('Failed to load stats history:', error);
+            this.samples = [];
+            this.content.innerHTML = `
+                <div class="placeholder">
+                    No stats history available. History is recorded while <code>servin daemon</code> runs.
+                </div>
+            `;
+        }
+    }
+
+    render() {
+        if (this.samples.length === 0) {
+            this.content.innerHTML = '<div class="placeholder">No samples recorded in this time range</div>';
+            return;
+        }
+
+        const latest = this.samples[this.samples.length - 1];
+        this.content.innerHTML = `
+            <div class="stats-summary">
+                <div class="stats-metric"><span class="stats-label">CPU</span><span>${latest.cpu_percent.toFixed(2)}%</span></div>
+                <div class="stats-metric"><span class="stats-label">Memory</span><span>${this.memoryPercent(latest).toFixed(2)}%</span></div>
+                <div class="stats-metric"><span class="stats-label">PIDs</span><span>${latest.pids}</span></div>
+                <div class="stats-metric"><span class="stats-label">Samples</span><span>${this.samples.length}</span></div>
+            </div>
+            <div class="stats-chart" id="statsChart">${this.chartSVG()}</div>
+        `;
+    }
+
+    memoryPercent(sample) {
+        if (sample.memory_percent) return sample.memory_percent;
+        if (sample.memory_limit) return sample.memory_usage / sample.memory_limit * 100;
+        return 0;
+    }
+
+    /**
+     * Draws CPU and memory percentages as an SVG line chart. Colors are set
+     * inline so the chart looks the same when exported as an image.
+     */
+    chartSVG() {
+        const width = 800;
+        const height = 300;
+        const pad = { top: 20, right: 20, bottom: 30, left: 45 };
+        const plotWidth = width - pad.left - pad.right;
+        const plotHeight = height - pad.top - pad.bottom;
+
+        const times = this.samples.map(s => new Date(s.read).getTime());
+        const start = times[0];
+        const span = Math.max(times[times.length - 1] - start, 1);
+        const maxCPU = Math.max(100, ...this.samples.map(s => s.cpu_percent));
+        const yMax = Math.ceil(maxCPU / 50) * 50;
+
+        const x = t => pad.left + (t - start) / span * plotWidth;
+        const y = v => pad.top + plotHeight - Math.min(v, yMax) / yMax * plotHeight;
+        const line = values => values.map((v, i) => `${x(times[i]).toFixed(1)},${y(v).toFixed(1)}`).join(' ');
+
+        let grid = '';
+        for (let i = 0; i <= 4; i++) {
+            const value = yMax / 4 * i;
+            grid += `<line x1="${pad.left}" x2="${width - pad.right}" y1="${y(value)}" y2="${y(value)}" stroke="#3c3c3c" stroke-width="1"/>`;
+            grid += `<text x="${pad.left - 6}" y="${y(value) + 4}" fill="#969696" font-size="11" text-anchor="end">${value}%</text>`;
+        }
+
+        const label = t => new Date(t).toLocaleTimeString();
+        return `
+            <svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 ${width} ${height}" width="100%" font-family="sans-serif">
+                <rect width="${width}" height="${height}" fill="#1e1e1e"/>
+                ${grid}
+                <text x="${pad.left}" y="${height - 8}" fill="#969696" font-size="11">${label(start)}</text>
+                <text x="${width - pad.right}" y="${height - 8}" fill="#969696" font-size="11" text-anchor="end">${label(start + span)}</text>
+                <polyline points="${line(this.samples.map(s => s.cpu_percent))}" fill="none" stroke="#0078d4" stroke-width="2"/>
+                <polyline points="${line(this.samples.map(s => this.memoryPercent(s)))}" fill="none" stroke="#16c60c" stroke-width="2"/>
+                <text x="${width - pad.right - 140}" y="${pad.top - 6}" fill="#0078d4" font-size="12">CPU %</text>
+                <text x="${width - pad.right - 70}" y="${pad.top - 6}" fill="#16c60c" font-size="12">Memory %</text>
+            </svg>
+        `;
+    }
+
+    async export(format) {
+        if (!this.containerId) return;
+
+        const name = `${this.containerId.substring(0, 12)}-stats`;
+        try {
+            if (format === 'png') {
+                this.download(await this.chartPNG(), `${name}.png`);
+            } else {
+                this.download(await this.apiClient.exportContainerStats(this.containerId, this.since, format), `${name}.${format}`);
+            }
+        } catch (error) {
+            console.error('Failed to export stats:', error);
+            UIHelpers.showToast('Failed to export stats', 'error');
+        }
+    }
+
+    chartPNG() {
+        return new Promise((resolve, reject) => {
+            const svg = document.querySelector('#statsChart svg');
+            if (!svg) {
+                reject(new Error('No chart to export'));
+                return;
+            }
+
+            const viewBox = svg.viewBox.baseVal;
+            const source = new XMLSerializer().serializeToString(svg);
+            const image = new Image();
+            image.onload = () => {
+                const canvas = document.createElement('canvas');
+                canvas.width = viewBox.width;
+                canvas.height = viewBox.height;
+                canvas.getContext('2d').drawImage(image, 0, 0, viewBox.width, viewBox.height);
+                canvas.toBlob(blob => blob ? resolve(blob) : reject(new Error('Failed to render chart')), 'image/png');
+            };
+            image.onerror = () => reject(new Error('Failed to render chart'));
+            image.src = 'data:image/svg+xml;charset=utf-8,' + encodeURIComponent(source);
+        });
+    }
+
+    download(blob, filename) {
+        const url = URL.createObjectURL(blob);
+        const link = document.createElement('a');
+        link.href = url;
+        link.download = filename;
+        document.body.appendChild(link);
+        link.click();
+        link.remove();
+        setTimeout(() => URL.revokeObjectURL(url), 1000);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.statsHistory = new StatsHistory(new APIClient());
+});
+
+// Export for use in other modules
+window.StatsHistory = StatsHistory;
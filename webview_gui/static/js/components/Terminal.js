@@ -1,22 +1,28 @@
 /**
  * Terminal Component
- * Handles container exec sessions and terminal interactions
+ * Runs an interactive shell in a container in an xterm.js terminal attached
+ * to an exec session on a pseudo-terminal
  */
 
-class Terminal {
+class TerminalComponent {
     constructor(apiClient, socketManager) {
         this.apiClient = apiClient;
         this.socketManager = socketManager;
         this.currentContainerId = null;
         this.isConnected = false;
-        this.commandHistory = [];
-        this.historyIndex = -1;
-        
+        // Whether the session has a terminal; without one input is echoed here
+        this.hasTTY = true;
+        this.term = null;
+        this.fitAddon = null;
+        this.resizeObserver = null;
+        this.shell = '/bin/sh';
+
         this.init();
     }
 
     init() {
         this.setupSocketHandlers();
+        this.setupControls();
     }
 
     setupSocketHandlers() {
@@ -25,263 +31,183 @@ class Terminal {
         this.socketManager.on('exec_output', (data) => this.handleExecOutput(data));
     }
 
-    setupTerminal(containerId) {
-        this.currentContainerId = containerId;
-        
-        const execTerminal = document.getElementById('execTerminal');
-        if (!execTerminal) return;
-
-        // Simple placeholder for direct connection
-        if (!execTerminal.querySelector('.terminal-placeholder')) {
-            execTerminal.innerHTML = `
-                <div class="terminal-placeholder">
-                    <i class="fas fa-terminal"></i>
-                    <p>Connecting to container shell...</p>
-                </div>
-            `;
-        }
+    setupControls() {
+        const reconnectBtn = document.getElementById('terminalReconnectBtn');
+        const clearBtn = document.getElementById('terminalClearBtn');
+        const shellSelect = document.getElementById('terminalShell');
 
-        // Set up event listeners for terminal input
-        this.setupTerminalControls();
-        
-        // Automatically connect when terminal is set up
-        this.connect();
-    }
+        if (reconnectBtn) {
+            reconnectBtn.addEventListener('click', () => this.connect());
+        }
 
-    setupTerminalControls() {
-        const terminalInput = document.getElementById('terminalInput');
+        if (clearBtn) {
+            clearBtn.addEventListener('click', () => this.clearTerminal());
+        }
 
-        if (terminalInput) {
-            terminalInput.addEventListener('keydown', (e) => this.handleTerminalInput(e));
+        if (shellSelect) {
+            shellSelect.addEventListener('change', () => {
+                this.shell = shellSelect.value;
+                this.connect();
+            });
         }
     }
 
-    connect() {
-        if (!this.currentContainerId) return;
-
-        // Use bash as default shell for running containers
-        const shell = '/bin/bash';
+    /**
+     * Create the terminal the first time the Exec tab is shown
+     */
+    createTerminal() {
+        if (this.term) return true;
 
-        // Update placeholder to show connecting status
-        const placeholder = document.getElementById('terminalPlaceholder');
-        if (placeholder) {
-            placeholder.innerHTML = `
-                <i class="fas fa-spinner fa-spin"></i>
-                <p>Connecting to container shell...</p>
-            `;
+        const container = document.getElementById('terminalScreen');
+        if (!container || typeof window.Terminal !== 'function') {
+            this.showPlaceholder('fa-exclamation-triangle', 'The terminal emulator could not be loaded');
+            return false;
         }
 
-        // Start exec session via WebSocket
-        this.socketManager.emit('start_exec', {
-            container_id: this.currentContainerId,
-            shell: shell
+        this.term = new window.Terminal({
+            cursorBlink: true,
+            scrollback: 5000,
+            fontFamily: "'Consolas', 'Monaco', 'Courier New', monospace",
+            fontSize: 13,
+            theme: { background: '#1e1e1e' }
         });
-    }
-
-    disconnect() {
-        if (!this.currentContainerId) return;
-
-        // Stop exec session via WebSocket
-        this.socketManager.emit('stop_exec', {
-            container_id: this.currentContainerId
+        if (window.FitAddon) {
+            this.fitAddon = new window.FitAddon.FitAddon();
+            this.term.loadAddon(this.fitAddon);
+        }
+        this.term.open(container);
+
+        // Keystrokes, Ctrl-C included, go to the session as they are typed
+        this.term.onData((data) => this.sendInput(data));
+        this.term.onResize(({ cols, rows }) => {
+            if (this.isConnected) {
+                this.socketManager.emit('exec_resize', {
+                    container_id: this.currentContainerId,
+                    cols,
+                    rows
+                });
+            }
         });
-    }
-
-    handleExecStarted(data) {
-        console.log('Terminal: Exec session started:', data);
-        this.isConnected = true;
-
-        const terminalPlaceholder = document.getElementById('terminalPlaceholder');
-        const terminalOutput = document.getElementById('terminalOutput');
-        const terminalInput = document.getElementById('terminalInput');
 
-        if (terminalPlaceholder) {
-            terminalPlaceholder.style.display = 'none';
-        }
-
-        if (terminalOutput) {
-            terminalOutput.style.display = 'block';
-        }
+        // Keep the terminal the size of the tab
+        this.resizeObserver = new ResizeObserver(() => this.fit());
+        this.resizeObserver.observe(container);
+        return true;
+    }
 
-        if (terminalInput) {
-            terminalInput.disabled = false;
-            terminalInput.focus();
+    fit() {
+        if (!this.fitAddon || !this.term) return;
+        const container = document.getElementById('terminalScreen');
+        if (container && container.offsetWidth > 0 && container.offsetHeight > 0) {
+            this.fitAddon.fit();
         }
-
-        // Simple welcome message
-        this.addTerminalLine('system', 'Shell session started. Type commands and press Enter.');
     }
 
-    handleExecStopped(data) {
-        console.log('Terminal: Exec session stopped:', data);
-        this.isConnected = false;
-
-        const terminalPlaceholder = document.getElementById('terminalPlaceholder');
-        const terminalOutput = document.getElementById('terminalOutput');
-        const terminalInput = document.getElementById('terminalInput');
-
-        if (terminalPlaceholder) {
-            terminalPlaceholder.style.display = 'block';
-            terminalPlaceholder.innerHTML = `
-                <i class="fas fa-exclamation-triangle"></i>
-                <p>Terminal session ended</p>
-            `;
-        }
+    setupTerminal(containerId) {
+        if (!this.createTerminal()) return;
 
-        if (terminalOutput) {
-            terminalOutput.style.display = 'none';
+        // Keep a session to the same container running across tab switches
+        if (containerId === this.currentContainerId && this.isConnected) {
+            this.fit();
+            this.term.focus();
+            return;
         }
 
-        if (terminalInput) {
-            terminalInput.disabled = true;
+        if (this.isConnected) {
+            this.disconnect();
         }
-
-        this.addTerminalLine('system', 'Terminal session ended.');
+        this.currentContainerId = containerId;
+        this.connect();
     }
 
-    handleExecOutput(data) {
-        if (data.container_id !== this.currentContainerId) return;
-
-        const terminalContent = document.getElementById('terminalContent');
-        const terminalPrompt = document.getElementById('terminalPrompt');
+    connect() {
+        if (!this.currentContainerId || !this.term) return;
 
-        if (!terminalContent) return;
+        this.showPlaceholder('fa-spinner fa-spin', 'Connecting to container shell...');
+        this.term.reset();
+        this.fit();
 
-        if (data.type === 'prompt') {
-            // Update prompt with proper format: user@hostname:path$
-            if (terminalPrompt) {
-                terminalPrompt.textContent = data.output || 'root@container:/# ';
-            }
-        } else if (data.type === 'output') {
-            // Add command output to terminal content
-            this.addCommandOutput(data.output);
-        } else if (data.type === 'command') {
-            // Show the executed command in terminal
-            this.addCommandLine(data.output);
-        } else {
-            // Default: add any output to terminal
-            this.addTerminalLine(data.type || 'output', data.output);
-        }
-    }
-
-    handleTerminalInput(e) {
-        if (e.key === 'Enter') {
-            e.preventDefault();
-            this.sendCommand();
-        } else if (e.key === 'ArrowUp') {
-            e.preventDefault();
-            this.navigateHistory(-1);
-        } else if (e.key === 'ArrowDown') {
-            e.preventDefault();
-            this.navigateHistory(1);
-        } else if (e.key === 'Tab') {
-            e.preventDefault();
-            // Tab completion could be implemented here
-        }
+        // Start exec session via WebSocket with the terminal's size
+        this.socketManager.emit('start_exec', {
+            container_id: this.currentContainerId,
+            shell: this.shell,
+            cols: this.term.cols,
+            rows: this.term.rows
+        });
     }
 
-    sendCommand() {
-        const terminalInput = document.getElementById('terminalInput');
-        if (!terminalInput || !this.isConnected) return;
-
-        const command = terminalInput.value.trim();
-        if (!command) return;
+    disconnect() {
+        if (!this.currentContainerId) return;
 
-        // Add to command history
-        this.commandHistory.push(command);
-        this.historyIndex = this.commandHistory.length;
+        // Stop exec session via WebSocket
+        this.socketManager.emit('stop_exec', {
+            container_id: this.currentContainerId
+        });
+        this.isConnected = false;
+    }
 
-        // Display the command in terminal with proper prompt
-        this.addCommandLine(command);
+    sendInput(data) {
+        if (!this.isConnected) return;
 
-        // Send command via WebSocket
+        if (!this.hasTTY) {
+            this.term.write(data.replace(/\r/g, '\r\n'));
+        }
         this.socketManager.emit('exec_input', {
             container_id: this.currentContainerId,
-            command: command
+            data
         });
-
-        // Clear input
-        terminalInput.value = '';
     }
 
-    navigateHistory(direction) {
-        const terminalInput = document.getElementById('terminalInput');
-        if (!terminalInput || this.commandHistory.length === 0) return;
-
-        this.historyIndex += direction;
+    handleExecStarted(data) {
+        if (data.container_id !== this.currentContainerId) return;
 
-        if (this.historyIndex < 0) {
-            this.historyIndex = 0;
-        } else if (this.historyIndex >= this.commandHistory.length) {
-            this.historyIndex = this.commandHistory.length;
-            terminalInput.value = '';
-            return;
-        }
+        console.log('Terminal: Exec session started:', data);
+        this.isConnected = true;
+        this.hasTTY = data.tty !== false;
 
-        terminalInput.value = this.commandHistory[this.historyIndex] || '';
+        this.hidePlaceholder();
+        this.fit();
+        this.term.focus();
     }
 
-    addCommandLine(command) {
-        const terminalContent = document.getElementById('terminalContent');
-        if (!terminalContent) return;
-
-        // Add command line with prompt
-        const commandLine = document.createElement('div');
-        commandLine.className = 'terminal-line terminal-command';
-        commandLine.innerHTML = `<span class="terminal-prompt-text">root@container:/# </span><span class="terminal-command-text">${this.escapeHtml(command)}</span>`;
-        
-        terminalContent.appendChild(commandLine);
-        this.autoScroll();
-    }
+    handleExecStopped(data) {
+        if (data.container_id !== this.currentContainerId || !this.term) return;
 
-    addCommandOutput(output) {
-        const terminalContent = document.getElementById('terminalContent');
-        if (!terminalContent) return;
-
-        if (output && output.trim()) {
-            const outputLine = document.createElement('div');
-            outputLine.className = 'terminal-line terminal-output';
-            outputLine.textContent = output;
-            
-            terminalContent.appendChild(outputLine);
-            this.autoScroll();
-        }
+        console.log('Terminal: Exec session stopped:', data);
+        this.isConnected = false;
+
+        const status = data.exit_code !== undefined && data.exit_code !== null ? ` (exit code ${data.exit_code})` : '';
+        this.term.write(`\r\n\x1b[90m[Session ended${status}. Use Reconnect to start a new one.]\x1b[0m\r\n`);
     }
 
-    escapeHtml(text) {
-        const div = document.createElement('div');
-        div.textContent = text;
-        return div.innerHTML;
+    handleExecOutput(data) {
+        if (data.container_id !== this.currentContainerId || !this.term) return;
+
+        this.term.write(data.data);
     }
 
-    autoScroll() {
-        const terminalOutput = document.getElementById('terminalOutput');
-        if (terminalOutput) {
-            terminalOutput.scrollTop = terminalOutput.scrollHeight;
+    showPlaceholder(icon, message) {
+        const placeholder = document.getElementById('terminalPlaceholder');
+        if (placeholder) {
+            placeholder.style.display = 'flex';
+            placeholder.innerHTML = `
+                <i class="fas ${icon}"></i>
+                <p>${message}</p>
+            `;
         }
     }
 
-    addTerminalLine(type, content) {
-        const terminalContent = document.getElementById('terminalContent');
-        if (!terminalContent) return;
-
-        const line = document.createElement('div');
-        line.className = `terminal-line terminal-${type}`;
-        line.textContent = content;
-
-        terminalContent.appendChild(line);
-
-        // Auto-scroll to bottom
-        const terminalOutput = document.getElementById('terminalOutput');
-        if (terminalOutput) {
-            terminalOutput.scrollTop = terminalOutput.scrollHeight;
+    hidePlaceholder() {
+        const placeholder = document.getElementById('terminalPlaceholder');
+        if (placeholder) {
+            placeholder.style.display = 'none';
         }
     }
 
     clearTerminal() {
-        const terminalContent = document.getElementById('terminalContent');
-        if (terminalContent) {
-            terminalContent.innerHTML = '';
+        if (this.term) {
+            this.term.clear();
+            this.term.focus();
         }
     }
 
@@ -290,10 +216,19 @@ class Terminal {
             this.disconnect();
         }
         this.currentContainerId = null;
-        this.commandHistory = [];
-        this.historyIndex = -1;
+        if (this.term) {
+            this.term.reset();
+        }
     }
 }
 
-// Export the component
-window.Terminal = Terminal;
\ No newline at end of file
+document.addEventListener('DOMContentLoaded', () => {
+    if (!window.socketManager) {
+        window.socketManager = new SocketManager();
+        window.socketManager.init();
+    }
+    window.terminal = new TerminalComponent(new APIClient(), window.socketManager);
+});
+
+// Export the component; window.Terminal is the xterm.js terminal
+window.TerminalComponent = TerminalComponent;
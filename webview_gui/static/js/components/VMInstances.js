@@ -0,0 +1,358 @@
+/**
+ * VM Instances Component
+ * Manages the named VMs, their resources and the providers behind them,
+ * as `servin vm` does on the command line
+ */
+
+class VMInstances {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.vms = [];
+        this.resources = null; // The sizes of the VM in use, as configured
+        this.loading = null;
+
+        this.initializeEventListeners();
+    }
+
+    initializeEventListeners() {
+        // Load with the VM status when the section is shown or refreshed
+        document.querySelector('[data-section="vm"]')?.addEventListener('click', () => this.load());
+        document.getElementById('refreshVmBtn')?.addEventListener('click', () => this.load());
+
+        ['vmCpusRange', 'vmMemoryRange', 'vmDiskRange'].forEach(id => {
+            document.getElementById(id)?.addEventListener('input', () => this.updateResourceLabels());
+        });
+        document.getElementById('applyVmResourcesBtn')?.addEventListener('click', () => this.applyResources());
+
+        document.getElementById('newVmBtn')?.addEventListener('click', () => this.showCreateForm(true));
+        document.getElementById('vmCreateCancel')?.addEventListener('click', () => this.showCreateForm(false));
+        document.getElementById('vmCreateForm')?.addEventListener('submit', (event) => {
+            event.preventDefault();
+            this.createVM();
+        });
+        document.getElementById('destroyVmBtn')?.addEventListener('click', () => this.destroyVM());
+
+        document.getElementById('vmShellBtn')?.addEventListener('click', () => this.openShell());
+        document.getElementById('vmConsoleBtn')?.addEventListener('click', () => this.toggleConsoleLog());
+    }
+
+    load() {
+        // Each of these runs the CLI, so don't start another round meanwhile
+        if (!this.loading) {
+            this.loading = Promise.all([
+                this.loadVMs(),
+                this.loadResources(),
+                this.loadDiskUsage(),
+                this.loadProviders()
+            ]).finally(() => { this.loading = null; });
+        }
+        return this.loading;
+    }
+
+    async loadProviders() {
+        const list = document.getElementById('vmProvidersList');
+        if (!list) return;
+
+        try {
+            const { providers } = await this.apiClient.getVMProviders();
+            list.innerHTML = '';
+            const items = document.createElement('div');
+            items.className = 'vm-list';
+            providers.forEach(provider => {
+                const item = document.createElement('div');
+                item.className = `vm-list-item${provider.selected ? ' active' : ''}${provider.available ? '' : ' unavailable'}`;
+                item.innerHTML = `
+                    <span class="vm-list-name"></span>
+                    <span class="vm-list-detail"></span>
+                    <span class="status-badge"></span>`;
+                item.querySelector('.vm-list-name').textContent = provider.name;
+                item.querySelector('.vm-list-detail').textContent = provider.available
+                    ? `${provider.description} (${provider.acceleration})`
+                    : provider.reason || provider.description;
+                const badge = item.querySelector('.status-badge');
+                badge.textContent = provider.selected ? 'In use' : (provider.available ? 'Available' : 'Unavailable');
+                badge.classList.add(provider.available ? 'status-running' : 'status-stopped');
+                items.appendChild(item);
+            });
+            list.appendChild(items);
+        } catch (error) {
+            this.showNote(list, `Failed to detect providers: ${error.message}`);
+        }
+    }
+
+    async loadVMs() {
+        const list = document.getElementById('vmInstancesList');
+        if (!list) return;
+
+        try {
+            const { vms } = await this.apiClient.listVMs();
+            this.vms = vms;
+            this.renderVMs(list);
+        } catch (error) {
+            this.vms = [];
+            this.showNote(list, `Failed to list VMs: ${error.message}`);
+        }
+    }
+
+    renderVMs(list) {
+        list.innerHTML = '';
+        if (this.vms.length === 0) {
+            this.showNote(list, 'No VMs yet; the first start creates one.');
+            return;
+        }
+
+        const items = document.createElement('div');
+        items.className = 'vm-list';
+        this.vms.forEach(vm => {
+            const item = document.createElement('div');
+            item.className = `vm-list-item${vm.active ? ' active' : ''}`;
+            item.innerHTML = `
+                <span class="vm-list-name"></span>
+                <span class="vm-list-detail"></span>`;
+            item.querySelector('.vm-list-name').textContent = vm.name;
+            item.querySelector('.vm-list-detail').textContent = vm.error
+                ? vm.error
+                : `${vm.cpus} CPUs, ${vm.memory_mb} MB, ${vm.disk_size_gb} GB${vm.remote ? `, ${vm.remote}` : ''}`;
+
+            if (vm.active) {
+                const badge = document.createElement('span');
+                badge.className = 'status-badge status-running';
+                badge.textContent = vm.selected_by ? `In use (${vm.selected_by})` : 'In use';
+                item.appendChild(badge);
+            } else {
+                const useBtn = document.createElement('button');
+                useBtn.className = 'action-btn secondary small';
+                useBtn.dataset.mutating = '';
+                useBtn.textContent = 'Use';
+                useBtn.addEventListener('click', () => this.useVM(vm.name));
+                item.appendChild(useBtn);
+            }
+            items.appendChild(item);
+        });
+        list.appendChild(items);
+    }
+
+    async loadResources() {
+        const status = document.getElementById('vmResourcesStatus');
+        const fields = document.getElementById('vmResourcesFields');
+        if (!status || !fields) return;
+
+        try {
+            this.resources = await this.apiClient.getVMResources();
+            // VM disks only grow, so the disk slider starts at the current size
+            document.getElementById('vmDiskRange').min = this.resources.disk;
+            this.setRange('vmCpusRange', this.resources.cpus);
+            this.setRange('vmMemoryRange', this.resources.memory);
+            this.setRange('vmDiskRange', this.resources.disk);
+            this.updateResourceLabels();
+            status.textContent = `Resources of ${this.resources.name}. Changing them restarts a running VM.`;
+            fields.hidden = false;
+        } catch (error) {
+            this.resources = null;
+            status.textContent = `VM configuration not available: ${error.message}`;
+            fields.hidden = true;
+            this.updateApplyButton();
+        }
+    }
+
+    setRange(id, value) {
+        const range = document.getElementById(id);
+        if (!range) return;
+        // Let the sliders reach sizes set beyond them on the command line
+        if (value > Number(range.max)) range.max = value;
+        if ((value - Number(range.min)) % Number(range.step)) range.step = 1;
+        range.value = value;
+    }
+
+    selectedResources() {
+        return {
+            cpus: Number(document.getElementById('vmCpusRange')?.value),
+            memory: Number(document.getElementById('vmMemoryRange')?.value),
+            disk: Number(document.getElementById('vmDiskRange')?.value)
+        };
+    }
+
+    updateResourceLabels() {
+        const selected = this.selectedResources();
+        const labels = {
+            vmCpusValue: `${selected.cpus}`,
+            vmMemoryValue: `${selected.memory} MB`,
+            vmDiskValue: `${selected.disk} GB`
+        };
+        Object.entries(labels).forEach(([id, text]) => {
+            const label = document.getElementById(id);
+            if (label) label.textContent = text;
+        });
+        this.updateApplyButton();
+    }
+
+    /**
+     * The resources that differ from the VM's, as /api/vm/resize takes them
+     */
+    resourceChanges() {
+        if (!this.resources) return {};
+        const selected = this.selectedResources();
+        const changes = {};
+        ['cpus', 'memory', 'disk'].forEach(field => {
+            if (selected[field] !== this.resources[field]) {
+                changes[field] = selected[field];
+            }
+        });
+        return changes;
+    }
+
+    updateApplyButton() {
+        const applyBtn = document.getElementById('applyVmResourcesBtn');
+        if (applyBtn) applyBtn.disabled = Object.keys(this.resourceChanges()).length === 0;
+    }
+
+    async applyResources() {
+        const changes = this.resourceChanges();
+        if (Object.keys(changes).length === 0) return;
+        if (!confirm(`Resize ${this.resources.name}? A running VM is restarted for it.`)) return;
+
+        const applyBtn = document.getElementById('applyVmResourcesBtn');
+        if (applyBtn) applyBtn.disabled = true;
+        window.vmManager?.addLogEntry(`Resizing ${this.resources.name}...`, 'info');
+        try {
+            const result = await this.apiClient.resizeVM(changes);
+            UIHelpers.showToast(result.message, 'success');
+            window.vmManager?.addLogEntry(result.message, 'success');
+        } catch (error) {
+            UIHelpers.showToast(`Failed to resize the VM: ${error.message}`, 'error');
+            window.vmManager?.addLogEntry(`Failed to resize the VM: ${error.message}`, 'error');
+        }
+        await this.load();
+        window.vmManager?.loadVMStatus(false);
+    }
+
+    async loadDiskUsage() {
+        const usage = document.getElementById('vmDiskUsage');
+        if (!usage) return;
+
+        // Only a running VM can be asked, so hide the bar when it can't
+        try {
+            const disk = await this.apiClient.getVMDiskUsage();
+            document.getElementById('vmDiskUsageBar').style.width = `${disk.percent}%`;
+            document.getElementById('vmDiskUsageText').textContent =
+                `${UIHelpers.formatBytes(disk.used_mb * 1024 * 1024)} of ${UIHelpers.formatBytes(disk.size_mb * 1024 * 1024)} (${disk.percent}%)`;
+            usage.className = `vm-disk-usage${disk.percent >= 90 ? ' critical' : disk.percent >= 75 ? ' warning' : ''}`;
+            usage.hidden = false;
+        } catch (error) {
+            usage.hidden = true;
+        }
+    }
+
+    showCreateForm(show) {
+        const form = document.getElementById('vmCreateForm');
+        if (!form) return;
+        form.hidden = !show;
+        if (show) {
+            form.reset();
+            document.getElementById('vmCreateName')?.focus();
+        }
+    }
+
+    async createVM() {
+        const name = document.getElementById('vmCreateName').value.trim();
+        const options = {
+            name,
+            cpus: Number(document.getElementById('vmCreateCpus').value),
+            memory: Number(document.getElementById('vmCreateMemory').value),
+            disk: Number(document.getElementById('vmCreateDisk').value),
+            use: document.getElementById('vmCreateUse').checked
+        };
+
+        try {
+            const result = await this.apiClient.createVM(options);
+            UIHelpers.showToast(result.message, 'success');
+            window.vmManager?.addLogEntry(result.message, 'success');
+            this.showCreateForm(false);
+            await this.load();
+            if (options.use) window.vmManager?.loadVMStatus(false);
+        } catch (error) {
+            UIHelpers.showToast(`Failed to create VM ${name}: ${error.message}`, 'error');
+        }
+    }
+
+    async useVM(name) {
+        try {
+            const result = await this.apiClient.useVM(name);
+            UIHelpers.showToast(result.message, 'success');
+            window.vmManager?.addLogEntry(result.message, 'info');
+            await this.load();
+            window.vmManager?.loadVMStatus(false);
+        } catch (error) {
+            UIHelpers.showToast(`Failed to use VM ${name}: ${error.message}`, 'error');
+        }
+    }
+
+    async destroyVM() {
+        const active = this.vms.find(vm => vm.active);
+        if (!active) {
+            UIHelpers.showToast('There is no VM to destroy', 'warning');
+            return;
+        }
+        const name = prompt(`Destroying ${active.name} deletes its disk with every container and image in it.\n\nType the name of the VM to destroy it:`);
+        if (name === null) return;
+        if (name !== active.name) {
+            UIHelpers.showToast('The name does not match; nothing was destroyed', 'warning');
+            return;
+        }
+
+        window.vmManager?.addLogEntry(`Destroying ${active.name}...`, 'info');
+        try {
+            const result = await this.apiClient.destroyVM(name);
+            UIHelpers.showToast(result.message, 'success');
+            window.vmManager?.addLogEntry(result.message, 'success');
+        } catch (error) {
+            UIHelpers.showToast(`Failed to destroy VM: ${error.message}`, 'error');
+            window.vmManager?.addLogEntry(`Failed to destroy VM: ${error.message}`, 'error');
+        }
+        await this.load();
+        window.vmManager?.loadVMStatus(false);
+    }
+
+    async openShell() {
+        try {
+            await this.apiClient.openVMShell();
+            UIHelpers.showToast('Opened a shell in the VM in a new terminal', 'success');
+        } catch (error) {
+            UIHelpers.showToast(`Failed to open a shell: ${error.message}`, 'error');
+        }
+    }
+
+    async toggleConsoleLog() {
+        const log = document.getElementById('vmConsoleLog');
+        const consoleBtn = document.getElementById('vmConsoleBtn');
+        if (!log) return;
+        if (!log.hidden) {
+            log.hidden = true;
+            return;
+        }
+
+        if (consoleBtn) consoleBtn.disabled = true;
+        try {
+            const { lines } = await this.apiClient.getVMConsoleLog();
+            log.textContent = lines.length ? lines.join('\n') : 'The VM has not written to its console yet.';
+            log.hidden = false;
+            log.scrollTop = log.scrollHeight;
+        } catch (error) {
+            UIHelpers.showToast(`Failed to read the console log: ${error.message}`, 'error');
+        } finally {
+            if (consoleBtn) consoleBtn.disabled = false;
+        }
+    }
+
+    showNote(container, text) {
+        container.innerHTML = '';
+        const note = document.createElement('p');
+        note.className = 'vm-card-note';
+        note.textContent = text;
+        container.appendChild(note);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.vmInstances = new VMInstances(new APIClient());
+});
@@ -9,6 +9,8 @@ class VMManager {
         this.pollInterval = null;
         this.currentStatus = null;
         this.isLoading = false; // Prevent concurrent loading operations
+        this.downloadEventsBound = false;
+        this.startFinished = null; // Resolves when a background VM start ends
         
         this.initializeEventListeners();
         // Don't load VM status immediately, wait for section to be shown
@@ -46,6 +48,100 @@ class VMManager {
             }
         }); // Show loading for manual refresh
         document.getElementById('clearVmLogsBtn')?.addEventListener('click', () => this.clearLogs());
+        document.getElementById('cancelVmDownloadBtn')?.addEventListener('click', () => this.cancelStart());
+    }
+
+    bindDownloadEvents() {
+        const socket = window.dockerGUI?.socket;
+        if (this.downloadEventsBound) return true;
+        if (!socket) return false;
+        this.downloadEventsBound = true;
+
+        socket.on('vm_download', (event) => this.handleDownloadEvent(event));
+        // What provisioning prints while the VM starts
+        socket.on('vm_start_output', (event) => this.addLogEntry(event.line, 'info'));
+        socket.on('vm_start_finished', (result) => {
+            if (this.startFinished) {
+                this.startFinished(result);
+                this.startFinished = null;
+            }
+        });
+        return true;
+    }
+
+    handleDownloadEvent(event) {
+        const panel = document.getElementById('vmDownloads');
+        const list = document.getElementById('vmDownloadList');
+        if (!panel || !list) return;
+        panel.hidden = false;
+
+        let item = list.querySelector(`[data-asset="${event.asset}"]`);
+        if (!item) {
+            item = document.createElement('div');
+            item.className = 'download-item';
+            item.dataset.asset = event.asset;
+            item.innerHTML = `
+                <div class="download-item-header">
+                    <span class="download-asset"></span>
+                    <span class="download-detail"></span>
+                </div>
+                <div class="download-progress"><div class="download-progress-bar"></div></div>`;
+            item.querySelector('.download-asset').textContent = event.asset;
+            list.appendChild(item);
+            this.addLogEntry(`Downloading ${event.asset} from ${event.url}`, 'info');
+        }
+
+        const bar = item.querySelector('.download-progress-bar');
+        const detail = item.querySelector('.download-detail');
+        const total = event.total > 0 ? event.total : 0;
+        let text = UIHelpers.formatBytes(event.downloaded);
+        if (total) {
+            const percent = Math.floor(event.downloaded * 100 / total);
+            bar.style.width = `${percent}%`;
+            text += ` / ${UIHelpers.formatBytes(total)} (${percent}%)`;
+        }
+
+        item.className = `download-item ${event.state}`;
+        switch (event.state) {
+            case 'done':
+                bar.style.width = '100%';
+                detail.textContent = UIHelpers.formatBytes(event.downloaded);
+                this.addLogEntry(`Downloaded ${event.asset} (${UIHelpers.formatBytes(event.downloaded)})`, 'success');
+                break;
+            case 'failed':
+                detail.textContent = 'Failed';
+                this.addLogEntry(`Download of ${event.asset} failed: ${event.error}`, 'error');
+                break;
+            case 'cancelled':
+                detail.textContent = 'Cancelled';
+                this.addLogEntry(`Download of ${event.asset} cancelled`, 'info');
+                break;
+            default:
+                detail.textContent = `${text} - ${UIHelpers.formatBytes(Math.round(event.bytes_per_second))}/s`;
+        }
+    }
+
+    resetDownloads() {
+        const panel = document.getElementById('vmDownloads');
+        const list = document.getElementById('vmDownloadList');
+        if (list) list.innerHTML = '';
+        if (panel) panel.hidden = true;
+    }
+
+    async cancelStart() {
+        const cancelBtn = document.getElementById('cancelVmDownloadBtn');
+        if (cancelBtn) cancelBtn.disabled = true;
+        try {
+            const response = await fetch('/api/vm/start/cancel', { method: 'POST' });
+            const data = await response.json();
+            if (!data.success) {
+                throw new Error(data.error || 'Failed to cancel');
+            }
+            this.addLogEntry('Cancelling VM engine start...', 'info');
+        } catch (error) {
+            UIHelpers.showToast(`Failed to cancel: ${error.message}`, 'error');
+            if (cancelBtn) cancelBtn.disabled = false;
+        }
     }
 
     showVMSection() {
@@ -275,16 +371,33 @@ class VMManager {
             // Show transitional state
             this.updateEngineTransitionState('starting', 'Starting');
             this.addLogEntry('Starting VM engine...', 'info');
+            this.resetDownloads();
+            const tracked = this.bindDownloadEvents();
+            const cancelBtn = document.getElementById('cancelVmDownloadBtn');
+            if (cancelBtn) cancelBtn.disabled = false;
             
+            // The engine starts in the background; downloads report progress
+            // until it finishes
+            const finished = new Promise(resolve => { this.startFinished = resolve; });
             const response = await fetch('/api/vm/start', { method: 'POST' });
             const data = await response.json();
+            if (!data.success) {
+                this.startFinished = null;
+                throw new Error(data.error || 'Failed to start VM');
+            }
             
-            if (data.success) {
+            // Without a socket connection the outcome shows up in the status
+            const result = tracked ? await finished : { success: true };
+            if (result.success) {
                 UIHelpers.showToast('VM engine started successfully', 'success');
                 this.addLogEntry('VM engine started successfully', 'success');
                 setTimeout(() => this.loadVMStatus(false), 1000); // Reduced delay
+            } else if (result.cancelled) {
+                UIHelpers.showToast('VM engine start cancelled', 'info');
+                this.addLogEntry('VM engine start cancelled', 'info');
+                this.loadVMStatus(false);
             } else {
-                throw new Error(data.error || 'Failed to start VM');
+                throw new Error(result.error || 'Failed to start VM');
             }
         } catch (error) {
             console.error('Failed to start VM:', error);
@@ -437,7 +550,8 @@ class VMManager {
         const timestamp = new Date().toLocaleTimeString();
         const logEntry = document.createElement('div');
         logEntry.className = `log-entry log-${type}`;
-        logEntry.innerHTML = `<span class="log-time">[${timestamp}]</span> <span class="log-message">${message}</span>`;
+        logEntry.innerHTML = `<span class="log-time">[${timestamp}]</span> <span class="log-message"></span>`;
+        logEntry.querySelector('.log-message').textContent = message;
         
         logsContent.appendChild(logEntry);
         logsContent.scrollTop = logsContent.scrollHeight;
@@ -0,0 +1,219 @@
+/**
+ * Volume Details Component
+ * Shows a volume's usage, options and the containers that mount it, and
+ * opens its mountpoint in the host's file manager
+ */
+
+class VolumeDetails {
+    constructor(apiClient) {
+        this.apiClient = apiClient;
+        this.currentVolume = null;
+        this.volume = null;
+
+        this.setupEventListeners();
+        this.setRevealLabel();
+    }
+
+    setupEventListeners() {
+        // Rows are rendered elsewhere, so clicks are caught on the table body
+        document.getElementById('volumesTableBody')?.addEventListener('click', (e) => {
+            const row = e.target.closest('tr[data-name]');
+            if (!row || e.target.closest('button')) return;
+            this.show(row.dataset.name);
+        });
+
+        document.getElementById('backToVolumes')?.addEventListener('click', () => this.hide());
+        document.getElementById('copyVolumePathBtn')?.addEventListener('click', () => {
+            if (this.volume) {
+                UIHelpers.copyToClipboard(this.volume.mountpoint, 'Path');
+            }
+        });
+
+        document.getElementById('revealVolumeBtn')?.addEventListener('click', () => this.reveal());
+        document.getElementById('removeVolumeBtn')?.addEventListener('click', () => this.remove());
+    }
+
+    /**
+     * Name the reveal action after the file manager of the host, which is
+     * where the GUI runs
+     */
+    setRevealLabel() {
+        const label = document.getElementById('revealVolumeLabel');
+        if (!label) return;
+
+        const platform = navigator.platform || '';
+        if (platform.startsWith('Mac')) {
+            label.textContent = 'Open in Finder';
+        } else if (platform.startsWith('Win')) {
+            label.textContent = 'Open in Explorer';
+        }
+    }
+
+    async show(volumeName) {
+        this.currentVolume = volumeName;
+        this.volume = null;
+
+        document.getElementById('volumeDetails').style.display = 'block';
+        document.getElementById('volumesList').style.display = 'none';
+        document.getElementById('volumeDetailsTitle').textContent = volumeName;
+        document.getElementById('detailsVolumeMountpoint').textContent = '-';
+
+        const content = document.getElementById('volumeDetailsContent');
+        content.innerHTML = '<div class="placeholder">Loading volume details...</div>';
+
+        try {
+            const volume = await this.apiClient.inspectVolume(volumeName);
+            // Another volume may have been opened while this one loaded
+            if (volumeName !== this.currentVolume) return;
+            this.volume = volume;
+            this.render();
+        } catch (error) {
+            console.error('Failed to load volume details:', error);
+            content.innerHTML = `<div class="placeholder">Failed to load volume details: ${this.escapeHtml(error.message)}</div>`;
+        }
+    }
+
+    hide() {
+        document.getElementById('volumeDetails').style.display = 'none';
+        document.getElementById('volumesList').style.display = 'block';
+        this.currentVolume = null;
+        this.volume = null;
+    }
+
+    render() {
+        const volume = this.volume;
+        const containers = volume.containers || [];
+
+        document.getElementById('detailsVolumeMountpoint').textContent = volume.mountpoint;
+
+        document.getElementById('volumeDetailsContent').innerHTML = `
+            <div class="overview-grid">
+                <div class="overview-card">
+                    <h4>Basic Information</h4>
+                    <div class="info-grid">
+                        ${this.infoItem('Name', volume.name)}
+                        ${this.infoItem('Driver', volume.driver)}
+                        ${this.infoItem('Mountpoint', volume.mountpoint)}
+                        ${this.infoItem('Created', UIHelpers.formatDate(volume.created))}
+                        ${this.infoItem('Scope', volume.scope || 'local')}
+                        ${volume.anonymous ? this.infoItem('Anonymous', 'Removed with its container by servin rm --volumes') : ''}
+                    </div>
+                </div>
+
+                <div class="overview-card">
+                    <h4>Usage</h4>
+                    <div class="info-grid">
+                        ${this.infoItem('Size', UIHelpers.formatBytes(volume.size || 0))}
+                        ${this.infoItem('Containers', containers.length ? `${containers.length} mounting it` : 'Not in use')}
+                        ${Object.entries(volume.status || {}).map(([key, value]) => this.infoItem(this.titleCase(key), value)).join('')}
+                    </div>
+                </div>
+
+                ${this.renderPairs('Labels', volume.labels)}
+                ${this.renderPairs('Driver Options', volume.options)}
+            </div>
+
+            <div class="details-section">
+                <h4>Containers</h4>
+                ${this.renderContainers(containers)}
+            </div>
+        `;
+    }
+
+    infoItem(label, value) {
+        return `
+            <div class="info-item">
+                <label>${this.escapeHtml(label)}:</label>
+                <span>${this.escapeHtml(String(value))}</span>
+            </div>
+        `;
+    }
+
+    renderPairs(title, pairs) {
+        const keys = Object.keys(pairs || {}).sort();
+        if (keys.length === 0) return '';
+
+        return `
+            <div class="overview-card">
+                <h4>${title}</h4>
+                <div class="info-grid">
+                    ${keys.map(key => this.infoItem(key, pairs[key])).join('')}
+                </div>
+            </div>
+        `;
+    }
+
+    renderContainers(containers) {
+        if (containers.length === 0) {
+            return '<div class="placeholder">No containers mount this volume</div>';
+        }
+
+        return `
+            <table class="data-table">
+                <thead>
+                    <tr>
+                        <th>Name</th>
+                        <th>Namespace</th>
+                        <th>Status</th>
+                        <th>Mounted At</th>
+                        <th>Access</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    ${containers.map(container => `
+                        <tr>
+                            <td><strong title="${container.id}">${this.escapeHtml(container.name || container.id.substring(0, 12))}</strong></td>
+                            <td>${this.escapeHtml(container.namespace)}</td>
+                            <td><span class="status-badge status-${container.status.toLowerCase()}">${container.status}</span></td>
+                            <td><code>${this.escapeHtml(container.destination)}</code></td>
+                            <td>${container.read_only ? 'Read-only' : 'Read-write'}</td>
+                        </tr>
+                    `).join('')}
+                </tbody>
+            </table>
+        `;
+    }
+
+    titleCase(text) {
+        return text.charAt(0).toUpperCase() + text.slice(1).replace(/_/g, ' ');
+    }
+
+    async reveal() {
+        if (!this.volume) return;
+
+        try {
+            await this.apiClient.revealVolume(this.volume.name);
+        } catch (error) {
+            console.error('Failed to open volume folder:', error);
+            UIHelpers.showToast(`Failed to open folder: ${error.message}`, 'error');
+        }
+    }
+
+    async remove() {
+        if (!this.volume) return;
+
+        if (!confirm(`Remove volume ${this.volume.name} and all of its data?`)) {
+            return;
+        }
+
+        try {
+            await this.apiClient.removeVolume(this.volume.name);
+            UIHelpers.showToast(`Volume ${this.volume.name} removed`, 'success');
+            this.hide();
+            document.getElementById('refreshBtn')?.click();
+        } catch (error) {
+            console.error('Failed to remove volume:', error);
+            UIHelpers.showToast(`Failed to remove volume: ${error.message}`, 'error');
+        }
+    }
+
+    escapeHtml(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.volumeDetails = new VolumeDetails(new APIClient());
+});
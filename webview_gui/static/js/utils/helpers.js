@@ -59,6 +59,20 @@ class UIHelpers {
         }
     }
 
+    /**
+     * Format published ports like "0.0.0.0:8080->80/tcp", or '-' for none
+     */
+    static formatPorts(ports) {
+        if (!Array.isArray(ports) || ports.length === 0) {
+            return '-';
+        }
+        return ports.map(p => {
+            const hostIP = p.host_ip || '0.0.0.0';
+            const host = hostIP.includes(':') ? `[${hostIP}]` : hostIP;
+            return `${host}:${p.host_port}->${p.container_port}/${p.protocol || 'tcp'}`;
+        }).join(', ');
+    }
+
     /**
      * Format date string for display
      */
@@ -100,6 +114,68 @@ class UIHelpers {
         return this.formatBytes(bytes);
     }
 
+    /**
+     * Copy text to the clipboard and confirm with a toast
+     */
+    static async copyToClipboard(text, label = 'Text') {
+        try {
+            if (navigator.clipboard && window.isSecureContext) {
+                await navigator.clipboard.writeText(text);
+            } else {
+                // Fallback for webviews without the async clipboard API
+                const textarea = document.createElement('textarea');
+                textarea.value = text;
+                textarea.style.position = 'fixed';
+                textarea.style.opacity = '0';
+                document.body.appendChild(textarea);
+                textarea.select();
+                const copied = document.execCommand('copy');
+                textarea.remove();
+                if (!copied) throw new Error('copy command failed');
+            }
+            this.showToast(`${label} copied to clipboard`, 'success');
+        } catch (error) {
+            console.error('Failed to copy to clipboard:', error);
+            this.showToast(`Failed to copy ${label.toLowerCase()}`, 'error');
+        }
+    }
+
+    /**
+     * List dropped files with their path relative to the drop, walking into
+     * dropped folders where the webview supports it
+     */
+    static async collectDroppedFiles(dataTransfer) {
+        const entries = Array.from(dataTransfer.items || [])
+            .map(item => item.webkitGetAsEntry ? item.webkitGetAsEntry() : null)
+            .filter(entry => entry);
+
+        if (entries.length === 0) {
+            return Array.from(dataTransfer.files).map(file => ({ file, name: file.name }));
+        }
+
+        const files = [];
+        const walk = async (entry, prefix) => {
+            if (entry.isFile) {
+                const file = await new Promise((resolve, reject) => entry.file(resolve, reject));
+                files.push({ file, name: prefix + entry.name });
+            } else if (entry.isDirectory) {
+                const reader = entry.createReader();
+                // readEntries returns the directory in batches until it returns none
+                let batch;
+                do {
+                    batch = await new Promise((resolve, reject) => reader.readEntries(resolve, reject));
+                    for (const child of batch) {
+                        await walk(child, prefix + entry.name + '/');
+                    }
+                } while (batch.length > 0);
+            }
+        };
+        for (const entry of entries) {
+            await walk(entry, '');
+        }
+        return files;
+    }
+
     /**
      * Filter table rows based on search term
      */
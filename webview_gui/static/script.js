@@ -395,6 +395,7 @@ class DockerGUI {
                     <span class="status-badge status-${status.toLowerCase()}">
                         ${status}
                     </span>
+                    ${container.health ? `<span class="health-badge health-${container.health}" title="Health check">${container.health}</span>` : ''}
                 </td>
                 <td>
                     <small class="text-muted">
@@ -402,7 +403,7 @@ class DockerGUI {
                     </small>
                 </td>
                 <td>${container.ports && container.ports.length > 0 ? 
-                    container.ports.map(p => `${p.host_port || p.container_port}:${p.container_port}/${p.protocol || 'tcp'}`).join(', ') 
+                    UIHelpers.formatPorts(container.ports) 
                     : '<span class="text-muted">No ports</span>'}</td>
                 <td>
                     <div class="action-buttons" onclick="event.stopPropagation()">
@@ -420,6 +421,9 @@ class DockerGUI {
                         <button class="action-btn remove" onclick="dockerGUI.removeContainer('${container.id}')" title="Remove">
                             <i class="fas fa-trash"></i>
                         </button>
+                        <button class="action-btn" onclick="UIHelpers.copyToClipboard('${container.id}', 'Container ID')" title="Copy container ID">
+                            <i class="fas fa-copy"></i>
+                        </button>
                         <button class="action-btn details" onclick="dockerGUI.showContainerDetails('${container.id}')" title="Details">
                             <i class="fas fa-info-circle"></i>
                         </button>
@@ -447,10 +451,13 @@ class DockerGUI {
         
         tbody.innerHTML = this.data.images.map(image => `
             <tr data-id="${image.id}">
-                <td><strong>${image.repository}</strong></td>
+                <td><strong>${image.repository}</strong>${this.renderImageUsage(image)}</td>
                 <td>${image.tag}</td>
                 <td>
                     <small class="text-muted">${image.id}</small>
+                    <button class="copy-btn" onclick="UIHelpers.copyToClipboard('${image.id}', 'Image ID')" title="Copy image ID">
+                        <i class="fas fa-copy"></i>
+                    </button>
                 </td>
                 <td>
                     <small class="text-muted">
@@ -469,6 +476,24 @@ class DockerGUI {
         `).join('');
     }
     
+    // Flags images no container has used for a while, the candidates for
+    // 'servin image prune -a --filter unused-since=30d'
+    renderImageUsage(image) {
+        if (image.containers > 0) {
+            return `<span class="usage-badge usage-in-use" title="Used by ${image.containers} container(s)">in use</span>`;
+        }
+        const lastActivity = new Date(image.last_used || image.created);
+        if (isNaN(lastActivity.getTime())) {
+            return '';
+        }
+        const days = Math.floor((Date.now() - lastActivity.getTime()) / 86400000);
+        if (days < 30) {
+            return '';
+        }
+        const title = image.last_used ? `Last used by a container ${days} days ago` : `Never used by a container, created ${days} days ago`;
+        return `<span class="usage-badge usage-stale" title="${title}">unused ${days}d</span>`;
+    }
+    
     renderVolumes() {
         const tbody = document.getElementById('volumesTableBody');
         const emptyState = document.getElementById('volumesEmpty');
@@ -489,6 +514,9 @@ class DockerGUI {
                 <td>${volume.driver}</td>
                 <td>
                     <small class="text-muted">${volume.mountpoint}</small>
+                    <button class="copy-btn" onclick="UIHelpers.copyToClipboard('${volume.mountpoint}', 'Path')" title="Copy mountpoint">
+                        <i class="fas fa-copy"></i>
+                    </button>
                 </td>
                 <td>
                     <small class="text-muted">
@@ -0,0 +1,309 @@
+"""
+Servin Desktop GUI - System Tray
+Tray and menu bar icon showing the VM engine's status, with quick actions,
+so the GUI window can be closed without losing control of Servin
+"""
+
+import json
+import os
+import plistlib
+import shlex
+import subprocess
+import sys
+import threading
+import urllib.error
+import urllib.request
+
+try:
+    import pystray
+    from PIL import Image, ImageDraw
+    TRAY_AVAILABLE = True
+except Exception as e:
+    # pystray raises more than ImportError when there is no display or
+    # tray to attach to
+    print(f"[WARN] System tray not available: {e}")
+    TRAY_AVAILABLE = False
+
+# How often the status and recent containers are refreshed, in seconds
+POLL_INTERVAL = 10
+
+# Containers listed under Recent Containers
+RECENT_CONTAINERS = 5
+
+# Status dot drawn on the icon
+STATUS_COLORS = {
+    'running': (46, 204, 113),
+    'paused': (241, 196, 15),
+    'stopped': (149, 165, 166),
+    'unavailable': (231, 76, 60)
+}
+
+AUTOSTART_NAME = 'servin-gui'
+AUTOSTART_LABEL = 'dev.servin.gui'
+
+
+class ServinTray:
+    """Tray icon backed by the GUI's own API, so its actions behave as the
+    GUI's do"""
+
+    def __init__(self, base_url, show_window, quit_app):
+        self.base_url = base_url
+        self.show_window = show_window
+        self.quit_app = quit_app
+        self.icon = None
+        self.vm = {}
+        self.containers = []
+        self.stopped = threading.Event()
+        self.notified = False
+
+    def start(self):
+        """Show the icon without taking over the main thread, which the GUI
+        window's event loop needs"""
+        self.icon = pystray.Icon('servin', self.image(), 'Servin', self.menu())
+        self.icon.run_detached()
+        threading.Thread(target=self.poll, daemon=True).start()
+
+    def stop(self):
+        self.stopped.set()
+        if self.icon:
+            self.icon.stop()
+
+    def notify(self, message, title='Servin'):
+        """Show a native notification, returning whether it could be"""
+        if self.icon and self.icon.HAS_NOTIFICATION:
+            self.icon.notify(message, title)
+            return True
+        print(f"[INFO] {message}")
+        return False
+
+    def notify_event(self, event):
+        """Show an event of the notification watcher"""
+        return self.notify(event['message'], event['title'])
+
+    def window_hidden(self):
+        """Tell the user, the first time only, where the GUI went"""
+        if not self.notified:
+            self.notified = True
+            self.notify('Servin is still running. Use the tray icon to open it or quit.')
+
+    # Status
+
+    def api(self, path, method='GET'):
+        """Call the GUI's API, raising with the error it reports"""
+        request = urllib.request.Request(f"{self.base_url}{path}", method=method,
+                                         headers={'Content-Type': 'application/json'})
+        try:
+            with urllib.request.urlopen(request, data=b'{}' if method == 'POST' else None, timeout=60) as response:
+                return json.loads(response.read() or b'{}')
+        except urllib.error.HTTPError as e:
+            try:
+                message = json.loads(e.read()).get('error')
+            except ValueError:
+                message = None
+            raise RuntimeError(message or f"HTTP error {e.code}")
+
+    def poll(self):
+        while not self.stopped.is_set():
+            self.refresh()
+            self.stopped.wait(POLL_INTERVAL)
+
+    def refresh(self):
+        try:
+            self.vm = self.api('/api/vm/status')
+        except Exception as e:
+            self.vm = {'available': False, 'error': str(e)}
+        try:
+            containers = self.api('/api/containers')
+            self.containers = sorted(containers, key=lambda c: c.get('created') or '', reverse=True)
+        except Exception:
+            self.containers = []
+
+        if self.icon:
+            self.icon.icon = self.image()
+            self.icon.title = f"Servin: {self.status_text()}"
+            self.icon.update_menu()
+
+    def state(self):
+        if not self.vm.get('available'):
+            return 'unavailable'
+        if not self.vm.get('enabled'):
+            # Native containers need no VM, so the engine is ready
+            return 'running'
+        if self.vm.get('paused'):
+            return 'paused'
+        return 'running' if self.vm.get('running') else 'stopped'
+
+    def status_text(self):
+        running = sum(1 for c in self.containers if c.get('status') == 'running')
+        containers = f"{running} container{'' if running == 1 else 's'} running"
+        if not self.vm.get('available'):
+            return 'Servin not available'
+        if not self.vm.get('enabled'):
+            return f"Native mode, {containers}"
+        if self.vm.get('paused'):
+            return 'VM paused'
+        if self.vm.get('running'):
+            return f"VM running, {containers}"
+        return 'VM stopped'
+
+    def image(self):
+        """The Servin icon with a dot for the engine's state"""
+        path = icon_path()
+        if path:
+            image = Image.open(path).convert('RGBA').resize((64, 64))
+        else:
+            image = Image.new('RGBA', (64, 64), (0, 0, 0, 0))
+            ImageDraw.Draw(image).rounded_rectangle((4, 4, 60, 60), radius=12, fill=(0, 120, 212))
+        draw = ImageDraw.Draw(image)
+        draw.ellipse((38, 38, 62, 62), fill=STATUS_COLORS[self.state()], outline=(30, 30, 30), width=2)
+        return image
+
+    # Menu
+
+    def menu(self):
+        item = pystray.MenuItem
+        vm_enabled = lambda _: bool(self.vm.get('enabled'))
+        return pystray.Menu(
+            item(lambda _: self.status_text(), None, enabled=False),
+            pystray.Menu.SEPARATOR,
+            item('Open Servin', self.show(None), default=True),
+            pystray.Menu.SEPARATOR,
+            item('Start VM', self.run('/api/vm/start', 'Starting the VM engine'),
+                 visible=lambda _: self.vm.get('enabled') and not self.vm.get('running') and not self.vm.get('paused')),
+            item('Stop VM', self.run('/api/vm/stop', 'VM engine stopped'),
+                 visible=lambda _: self.vm.get('enabled') and (self.vm.get('running') or self.vm.get('paused'))),
+            item(lambda _: 'Resume All Containers' if self.vm.get('paused') else 'Pause All Containers',
+                 self.toggle_pause, visible=vm_enabled,
+                 enabled=lambda _: self.vm.get('running') or self.vm.get('paused')),
+            item('Recent Containers', pystray.Menu(self.recent_items),
+                 enabled=lambda _: bool(self.containers)),
+            pystray.Menu.SEPARATOR,
+            item('Start at Login', self.toggle_autostart, checked=lambda _: autostart_enabled()),
+            item('Quit Servin', self.quit)
+        )
+
+    def recent_items(self):
+        items = []
+        for container in self.containers[:RECENT_CONTAINERS]:
+            container_id = container['id']
+            running = container.get('status') == 'running'
+            actions = pystray.Menu(
+                pystray.MenuItem('Show Details', self.show(container_id)),
+                pystray.MenuItem('Stop' if running else 'Start',
+                                 self.run(f"/api/containers/{container_id}/{'stop' if running else 'start'}",
+                                          f"{'Stopped' if running else 'Started'} {container['name']}"))
+            )
+            items.append(pystray.MenuItem(f"{container['name']} ({container.get('status', 'unknown')})", actions))
+        return items
+
+    def show(self, container_id):
+        # pystray passes the icon and item to actions that take arguments
+        return lambda: self.show_window(container_id)
+
+    def run(self, path, done):
+        """Menu action posting to the API in the background, so the menu
+        does not wait for the runtime"""
+        def action():
+            def call():
+                try:
+                    self.api(path, method='POST')
+                    self.notify(done)
+                except Exception as e:
+                    self.notify(f"Failed: {e}")
+                self.refresh()
+            threading.Thread(target=call, daemon=True).start()
+        return action
+
+    def toggle_pause(self):
+        if self.vm.get('paused'):
+            self.run('/api/vm/resume', 'VM resumed')()
+        else:
+            self.run('/api/vm/pause', 'VM and its containers paused')()
+
+    def toggle_autostart(self):
+        try:
+            set_autostart(not autostart_enabled())
+        except Exception as e:
+            self.notify(f"Failed to change start at login: {e}")
+        self.icon.update_menu()
+
+    def quit(self):
+        self.stop()
+        self.quit_app()
+
+
+def icon_path():
+    """The Servin icon, bundled with the executable or in the source tree"""
+    if hasattr(sys, '_MEIPASS'):
+        candidates = [os.path.join(sys._MEIPASS, 'icons', 'servin-icon-64.png')]
+    else:
+        here = os.path.dirname(os.path.abspath(__file__))
+        candidates = [os.path.join(os.path.dirname(here), 'icons', 'servin-icon-64.png')]
+    return next((path for path in candidates if os.path.exists(path)), None)
+
+
+# Start at login
+
+def launch_command():
+    """Command that starts the GUI in the tray, without its window"""
+    if getattr(sys, 'frozen', False):
+        return [sys.executable, '--minimized']
+    main = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'main.py')
+    return [sys.executable, main, '--minimized']
+
+
+def autostart_file():
+    if sys.platform == 'darwin':
+        return os.path.expanduser(f"~/Library/LaunchAgents/{AUTOSTART_LABEL}.plist")
+    config = os.environ.get('XDG_CONFIG_HOME') or os.path.expanduser('~/.config')
+    return os.path.join(config, 'autostart', f"{AUTOSTART_NAME}.desktop")
+
+
+def autostart_enabled():
+    if sys.platform == 'win32':
+        import winreg
+        try:
+            with winreg.OpenKey(winreg.HKEY_CURRENT_USER, r"Software\Microsoft\Windows\CurrentVersion\Run") as key:
+                winreg.QueryValueEx(key, AUTOSTART_NAME)
+                return True
+        except OSError:
+            return False
+    return os.path.exists(autostart_file())
+
+
+def set_autostart(enabled):
+    """Start the GUI in the tray at login with a Run key on Windows, a
+    launch agent on macOS and an XDG autostart entry elsewhere"""
+    command = launch_command()
+
+    if sys.platform == 'win32':
+        import winreg
+        with winreg.OpenKey(winreg.HKEY_CURRENT_USER, r"Software\Microsoft\Windows\CurrentVersion\Run",
+                            0, winreg.KEY_SET_VALUE) as key:
+            if enabled:
+                winreg.SetValueEx(key, AUTOSTART_NAME, 0, winreg.REG_SZ, subprocess.list2cmdline(command))
+            else:
+                try:
+                    winreg.DeleteValue(key, AUTOSTART_NAME)
+                except FileNotFoundError:
+                    pass
+        return
+
+    path = autostart_file()
+    if not enabled:
+        if os.path.exists(path):
+            os.remove(path)
+        return
+
+    os.makedirs(os.path.dirname(path), exist_ok=True)
+    if sys.platform == 'darwin':
+        with open(path, 'wb') as f:
+            plistlib.dump({'Label': AUTOSTART_LABEL, 'ProgramArguments': command, 'RunAtLoad': True}, f)
+    else:
+        with open(path, 'w') as f:
+            f.write("[Desktop Entry]\n"
+                    "Type=Application\n"
+                    "Name=Servin\n"
+                    "Comment=Servin Desktop GUI in the system tray\n"
+                    f"Exec={shlex.join(command)}\n"
+                    "X-GNOME-Autostart-enabled=true\n")